@@ -0,0 +1,384 @@
+// Package accesslog logs the requests served over HTTP as JSON lines, with their
+// route, status, latency and user. Successful requests are sampled, while failed
+// and slow requests are always logged. Credentials and tokens are redacted from
+// the query strings and bodies logged.
+package accesslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+)
+
+// DefaultMaxBodySize is the size of the largest request bodies logged, in bytes
+const DefaultMaxBodySize = 4096
+
+// Redacted replaces the values of credentials and tokens in the entries logged
+const Redacted = "[REDACTED]"
+
+// Config configures which requests are logged and how
+type Config struct {
+	Output        io.Writer     // Where entries are written, standard output when nil
+	SampleRate    float64       // Of the successful requests logged, from 0 to 1
+	SlowThreshold time.Duration // Beyond which requests are always logged, zero logging none for being slow
+	LogBodies     bool          // Logs the JSON and form bodies of requests, redacted
+	MaxBodySize   int           // Of the bodies logged, DefaultMaxBodySize when not positive
+}
+
+// DefaultConfig returns a configuration logging every request, without its body
+func DefaultConfig() Config {
+	return Config{
+		SampleRate:    1,
+		SlowThreshold: time.Second,
+		MaxBodySize:   DefaultMaxBodySize,
+	}
+}
+
+// Entry is the access log entry of a request
+type Entry struct {
+	Time        time.Time       `json:"time"`
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Route       string          `json:"route,omitempty"` // Template of the route matched
+	Query       string          `json:"query,omitempty"`
+	Status      int             `json:"status"`
+	LatencyMs   float64         `json:"latencyMs"`
+	Bytes       int64           `json:"bytes"` // Of the response body
+	User        string          `json:"user,omitempty"`
+	ClientIP    string          `json:"clientIp"`
+	UserAgent   string          `json:"userAgent,omitempty"`
+	RequestBody json.RawMessage `json:"requestBody,omitempty"`
+	Sampled     bool            `json:"sampled"` // Logged for being sampled rather than failed or slow
+}
+
+// Logger logs the requests served by handlers
+type Logger struct {
+	config Config
+	output io.Writer
+	sample func() float64 // Returns a number in [0, 1) to sample requests
+	mutex  sync.Mutex
+}
+
+// NewLogger creates a new Logger
+func NewLogger(config Config) *Logger {
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultMaxBodySize
+	}
+	output := config.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	return &Logger{
+		config: config,
+		output: output,
+		sample: rand.Float64,
+	}
+}
+
+// contextKey is the type of the keys of the values the package keeps in contexts
+type contextKey string
+
+// entryKey is the key of the entry of the request in contexts
+const entryKey contextKey = "accessLogEntry"
+
+// Middleware logs the requests of a router once handled
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &Entry{
+			Time:      start.UTC(),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Query:     redactQuery(r.URL.RawQuery),
+			ClientIP:  clientIP(r),
+			UserAgent: r.UserAgent(),
+		}
+
+		var body *bodyCapture
+		if l.config.LogBodies && r.Body != nil && loggableBody(r.Header.Get("Content-Type")) {
+			body = &bodyCapture{ReadCloser: r.Body, limit: l.config.MaxBodySize}
+			r.Body = body
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		ctx := context.WithValue(r.Context(), entryKey, entry)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		latency := time.Since(start)
+		entry.Status = recorder.status
+		if recorder.hijacked {
+			entry.Status = http.StatusSwitchingProtocols
+		}
+		entry.LatencyMs = float64(latency.Microseconds()) / 1000
+		entry.Bytes = recorder.bytes
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				entry.Route = template
+			}
+		}
+
+		failed := entry.Status >= http.StatusBadRequest
+		slow := l.config.SlowThreshold > 0 && latency >= l.config.SlowThreshold
+		if !failed && !slow {
+			if l.sample() >= l.config.SampleRate {
+				return
+			}
+			entry.Sampled = true
+		}
+
+		if body != nil {
+			entry.RequestBody = body.redacted(r.Header.Get("Content-Type"))
+		}
+		l.write(entry)
+	})
+}
+
+// UserMiddleware records the user making a request in its entry. Use it after the
+// authentication middleware.
+func UserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := r.Context().Value(entryKey).(*Entry); ok {
+			entry.User = auth.GetUserIDFromContext(r.Context())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// write writes an entry as a JSON line
+func (l *Logger) write(entry *Entry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode access log entry for %s %s: %v", entry.Method, entry.Path, err)
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, err := l.output.Write(append(data, '\n')); err != nil {
+		log.Printf("Failed to write access log entry for %s %s: %v", entry.Method, entry.Path, err)
+	}
+}
+
+// sensitiveKeyParts are the parts of the keys of credentials and tokens, in lower
+// case without separators
+var sensitiveKeyParts = []string{
+	"password", "passwd", "secret", "token", "apikey", "accesskey", "privatekey",
+	"authorization", "credential", "cookie", "session", "signature",
+}
+
+// sensitiveKeys are the keys of credentials too short to be matched as parts of
+// keys without matching others
+var sensitiveKeys = map[string]bool{
+	"pin": true, "mpin": true, "otp": true, "totp": true, "cvv": true, "key": true,
+}
+
+// instrumentKeys are the keys matching sensitive parts that identify instruments,
+// as brokers' instrument tokens do, rather than credentials
+var instrumentKeys = map[string]bool{
+	"instrumenttoken": true, "exchangetoken": true,
+}
+
+// IsSensitive reports whether the value of a key is a credential or token to be
+// redacted
+func IsSensitive(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "", ".", "").Replace(strings.ToLower(key))
+	if instrumentKeys[normalized] {
+		return false
+	}
+	if sensitiveKeys[normalized] {
+		return true
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(normalized, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactJSON redacts the values of the sensitive keys of a JSON document, at any
+// depth
+func RedactJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactValue(document))
+}
+
+// redactValue redacts the values of the sensitive keys of a decoded JSON value
+func redactValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if IsSensitive(key) {
+				value[key] = Redacted
+			} else {
+				value[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+// redactQuery redacts the values of the sensitive parameters of a query string,
+// such as the tokens WebSocket clients authenticate with
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	for key := range values {
+		if IsSensitive(key) {
+			values[key] = []string{Redacted}
+		}
+	}
+	return values.Encode()
+}
+
+// loggableBody reports whether bodies of a content type can be redacted to be
+// logged
+func loggableBody(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "application/x-www-form-urlencoded"
+}
+
+// bodyCapture keeps the start of a request body as the handler reads it
+type bodyCapture struct {
+	io.ReadCloser
+	limit     int
+	data      []byte
+	truncated bool
+}
+
+// Read reads the body, keeping what is read up to the limit
+func (b *bodyCapture) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if room := b.limit - len(b.data); room >= n {
+			b.data = append(b.data, p[:n]...)
+		} else {
+			b.data = append(b.data, p[:room]...)
+			b.truncated = true
+		}
+	}
+	return n, err
+}
+
+// redacted returns the body read, redacted, as JSON: JSON bodies as they are and
+// form bodies as objects. Bodies truncated or failing to parse are not returned,
+// as they could not be redacted reliably.
+func (b *bodyCapture) redacted(contentType string) json.RawMessage {
+	if len(b.data) == 0 || b.truncated {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(b.data))
+		if err != nil {
+			return nil
+		}
+		form := make(map[string]string, len(values))
+		for key := range values {
+			form[key] = values.Get(key)
+			if IsSensitive(key) {
+				form[key] = Redacted
+			}
+		}
+		data, err := json.Marshal(form)
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+
+	data, err := RedactJSON(b.data)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// clientIP returns the address of the client of a request, the first address of
+// X-Forwarded-For when behind a proxy
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// statusRecorder records the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+	hijacked    bool
+}
+
+// WriteHeader records the status code before writing it
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the body of the response, counting its bytes
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Hijack lets handlers take over the connection, as WebSocket upgrades do
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	r.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Flush flushes buffered data to the client, for streamed responses
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
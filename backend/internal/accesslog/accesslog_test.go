@@ -0,0 +1,163 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/auth"
+)
+
+// entries decodes the entries written to an access log
+func entries(t *testing.T, output *bytes.Buffer) []Entry {
+	var logged []Entry
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry Entry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		logged = append(logged, entry)
+	}
+	return logged
+}
+
+func TestMiddleware(t *testing.T) {
+	var output bytes.Buffer
+	logger := NewLogger(Config{Output: &output, SampleRate: 1, LogBodies: true})
+
+	router := mux.NewRouter()
+	router.Use(logger.Middleware)
+	router.HandleFunc("/api/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"order1"}`))
+	})
+	protected := router.PathPrefix("/api/users").Subrouter()
+	protected.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.SetUserIDInContext(r.Context(), "user1")))
+		})
+	})
+	protected.Use(UserMiddleware)
+	protected.HandleFunc("/profile", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest("POST", "/api/orders/order1?token=secret1&symbol=NIFTY", strings.NewReader(`{"symbol":"NIFTY","quantity":50,"apiSecret":"secret2","legs":[{"accessToken":"secret3"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users/profile", nil))
+
+	logged := entries(t, &output)
+	require.Len(t, logged, 2)
+	assert.NotContains(t, output.String(), "secret1")
+	assert.NotContains(t, output.String(), "secret2")
+	assert.NotContains(t, output.String(), "secret3")
+
+	assert.Equal(t, "POST", logged[0].Method)
+	assert.Equal(t, "/api/orders/order1", logged[0].Path)
+	assert.Equal(t, "/api/orders/{id}", logged[0].Route)
+	assert.Equal(t, "symbol=NIFTY&token=%5BREDACTED%5D", logged[0].Query)
+	assert.Equal(t, http.StatusCreated, logged[0].Status)
+	assert.Equal(t, int64(15), logged[0].Bytes)
+	assert.Equal(t, "203.0.113.7", logged[0].ClientIP)
+	assert.JSONEq(t, `{"symbol":"NIFTY","quantity":50,"apiSecret":"[REDACTED]","legs":[{"accessToken":"[REDACTED]"}]}`, string(logged[0].RequestBody))
+	assert.True(t, logged[0].Sampled)
+
+	// Users are logged once authenticated
+	assert.Equal(t, "user1", logged[1].User)
+	assert.Equal(t, http.StatusNotFound, logged[1].Status)
+	assert.False(t, logged[1].Sampled)
+}
+
+func TestSampling(t *testing.T) {
+	var output bytes.Buffer
+	logger := NewLogger(Config{Output: &output, SampleRate: 0.1, SlowThreshold: 20 * time.Millisecond})
+	sample := 0.5
+	logger.sample = func() float64 { return sample }
+
+	delay := time.Duration(0)
+	status := http.StatusOK
+	handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(status)
+	}))
+	serve := func() int {
+		output.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/positions", nil))
+		return len(entries(t, &output))
+	}
+
+	// Successful requests not sampled are not logged
+	assert.Equal(t, 0, serve())
+
+	sample = 0.05
+	assert.Equal(t, 1, serve())
+
+	// Failed and slow requests always are
+	sample = 0.5
+	status = http.StatusInternalServerError
+	assert.Equal(t, 1, serve())
+
+	status = http.StatusOK
+	delay = 30 * time.Millisecond
+	assert.Equal(t, 1, serve())
+}
+
+func TestRequestBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    string
+	}{
+		{"form", "application/x-www-form-urlencoded", "username=trader1&password=secret1", `{"username":"trader1","password":"[REDACTED]"}`},
+		{"instrument tokens", "application/json", `{"instrument_token":738561,"pin":"1234"}`, `{"instrument_token":738561,"pin":"[REDACTED]"}`},
+		{"truncated", "application/json", `{"symbol":"` + strings.Repeat("N", 64) + `","password":"secret1"}`, ""},
+		{"malformed", "application/json", `{"password":"secret1"`, ""},
+		{"other content type", "text/plain", "password=secret1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			logger := NewLogger(Config{Output: &output, SampleRate: 1, LogBodies: true, MaxBodySize: 64})
+			handler := logger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.ReadAll(r.Body)
+			}))
+
+			req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			logged := entries(t, &output)
+			require.Len(t, logged, 1)
+			assert.NotContains(t, output.String(), "secret1")
+			if tt.expected == "" {
+				assert.Empty(t, logged[0].RequestBody)
+			} else {
+				assert.JSONEq(t, tt.expected, string(logged[0].RequestBody))
+			}
+		})
+	}
+}
+
+func TestIsSensitive(t *testing.T) {
+	for _, key := range []string{"password", "Authorization", "refresh_token", "apiKey", "api-secret", "X-Api-Key", "otp", "sessionId", "client_secret"} {
+		assert.True(t, IsSensitive(key), key)
+	}
+	for _, key := range []string{"symbol", "quantity", "instrumentToken", "username", "keyword", "spinner", "price"} {
+		assert.False(t, IsSensitive(key), key)
+	}
+}
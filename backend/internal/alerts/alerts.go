@@ -0,0 +1,247 @@
+// Package alerts evaluates the alert rules users define on prices, technical
+// indicators, P&L, greeks and margin against live data, such as "NIFTY crosses
+// 22000", "NIFTY 5m RSI(14) > 70" or "portfolio delta > 500", and delivers the
+// alerts they trigger over the channels each rule names.
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/trading-platform/backend/internal/indicators"
+)
+
+// Metric is the live value an alert rule watches
+type Metric string
+
+const (
+	MetricPrice             Metric = "PRICE"              // Last price of the rule's symbol
+	MetricIndicator         Metric = "INDICATOR"          // A line of an indicator on the candles of the rule's symbol
+	MetricPnL               Metric = "PNL"                // P&L of the user's open positions
+	MetricDelta             Metric = "DELTA"              // Of the user's open positions
+	MetricGamma             Metric = "GAMMA"              // Of the user's open positions
+	MetricTheta             Metric = "THETA"              // Of the user's open positions
+	MetricVega              Metric = "VEGA"               // Of the user's open positions
+	MetricMarginUtilization Metric = "MARGIN_UTILIZATION" // Percentage of the user's funds utilized
+	MetricMarginAvailable   Metric = "MARGIN_AVAILABLE"   // Margin available across the user's broker accounts
+)
+
+// Operator compares a metric's value with a rule's threshold
+type Operator string
+
+const (
+	OperatorGT           Operator = "GT"
+	OperatorGTE          Operator = "GTE"
+	OperatorLT           Operator = "LT"
+	OperatorLTE          Operator = "LTE"
+	OperatorCrossesAbove Operator = "CROSSES_ABOVE" // From at or below the threshold to above it
+	OperatorCrossesBelow Operator = "CROSSES_BELOW" // From at or above the threshold to below it
+)
+
+// Channel is a way alerts are delivered to users
+type Channel string
+
+const (
+	ChannelInApp   Channel = "IN_APP" // Over the user's websocket connections
+	ChannelEmail   Channel = "EMAIL"
+	ChannelSMS     Channel = "SMS"
+	ChannelWebhook Channel = "WEBHOOK" // To the user's webhooks subscribed to alerts
+	ChannelPush    Channel = "PUSH"    // To the user's mobile devices
+)
+
+var (
+	// ErrRuleNotFound is returned when an alert rule does not exist
+	ErrRuleNotFound = errors.New("alert rule not found")
+	// ErrInvalidRule is returned for alert rules that cannot be evaluated
+	ErrInvalidRule = errors.New("invalid alert rule")
+)
+
+// Rule is a condition on a live value a user is alerted of when it comes to hold.
+// Rules with the GT, GTE, LT and LTE operators trigger once when their condition
+// comes to hold and again only after it has stopped holding. Rules with crossing
+// operators trigger each time the value crosses the threshold, which requires a
+// value to have been seen on the other side of it first.
+type Rule struct {
+	ID              string           `json:"id" bson:"_id"`
+	UserID          string           `json:"userId" bson:"userId"`
+	Name            string           `json:"name" bson:"name"`
+	Metric          Metric           `json:"metric" bson:"metric"`
+	Symbol          string           `json:"symbol,omitempty" bson:"symbol,omitempty"`       // Of price and indicator rules
+	Exchange        string           `json:"exchange,omitempty" bson:"exchange,omitempty"`   // Of price rules
+	Indicator       *indicators.Spec `json:"indicator,omitempty" bson:"indicator,omitempty"` // Of indicator rules
+	Interval        string           `json:"interval,omitempty" bson:"interval,omitempty"`   // Of the candles of indicator rules
+	Line            string           `json:"line,omitempty" bson:"line,omitempty"`           // Of indicator rules, the indicator's main line when empty
+	Operator        Operator         `json:"operator" bson:"operator"`
+	Value           float64          `json:"value" bson:"value"`
+	Channels        []Channel        `json:"channels" bson:"channels"`
+	Active          bool             `json:"active" bson:"active"`
+	LastTriggeredAt time.Time        `json:"lastTriggeredAt,omitempty" bson:"lastTriggeredAt,omitempty"`
+	CreatedAt       time.Time        `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       time.Time        `json:"updatedAt" bson:"updatedAt"`
+}
+
+// String describes the rule's condition, such as "NIFTY CROSSES_ABOVE 22000" or
+// "NIFTY 5m MACD(12,26,9,close) signal GT 0"
+func (r *Rule) String() string {
+	subject := string(r.Metric)
+	switch r.Metric {
+	case MetricPrice:
+		subject = r.Symbol
+	case MetricIndicator:
+		if r.Indicator != nil {
+			subject = fmt.Sprintf("%s %s %s", r.Symbol, r.Interval, r.Indicator)
+			if r.Line != "" {
+				subject += " " + r.Line
+			}
+		}
+	}
+	return fmt.Sprintf("%s %s %g", subject, r.Operator, r.Value)
+}
+
+// Request creates or changes an alert rule
+type Request struct {
+	Name      string           `json:"name"`
+	Metric    Metric           `json:"metric"`
+	Symbol    string           `json:"symbol"`
+	Exchange  string           `json:"exchange"`
+	Indicator *indicators.Spec `json:"indicator"`
+	Interval  string           `json:"interval"`
+	Line      string           `json:"line"`
+	Operator  Operator         `json:"operator"`
+	Value     float64          `json:"value"`
+	Channels  []Channel        `json:"channels"` // In-app only when empty
+	Active    *bool            `json:"active"`   // Active when not given
+}
+
+// Validate checks the request is valid
+func (r *Request) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+
+	switch r.Metric {
+	case MetricPrice:
+		if r.Symbol == "" {
+			return fmt.Errorf("%w: symbol is required for price alerts", ErrInvalidRule)
+		}
+	case MetricIndicator:
+		if r.Symbol == "" || r.Interval == "" || r.Indicator == nil {
+			return fmt.Errorf("%w: symbol, interval and indicator are required for indicator alerts", ErrInvalidRule)
+		}
+		if err := r.Indicator.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+		if r.Line != "" && !hasLine(r.Indicator.Type, r.Line) {
+			return fmt.Errorf("%w: %s has no line %q", ErrInvalidRule, r.Indicator, r.Line)
+		}
+	case MetricPnL, MetricDelta, MetricGamma, MetricTheta, MetricVega, MetricMarginUtilization, MetricMarginAvailable:
+	default:
+		return fmt.Errorf("%w: unknown metric %q", ErrInvalidRule, r.Metric)
+	}
+
+	switch r.Operator {
+	case OperatorGT, OperatorGTE, OperatorLT, OperatorLTE, OperatorCrossesAbove, OperatorCrossesBelow:
+	default:
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidRule, r.Operator)
+	}
+
+	for _, channel := range r.Channels {
+		switch channel {
+		case ChannelInApp, ChannelEmail, ChannelSMS, ChannelWebhook, ChannelPush:
+		default:
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidRule, channel)
+		}
+	}
+
+	return nil
+}
+
+// apply sets the fields of a rule the request gives
+func (r *Request) apply(rule *Rule) {
+	rule.Name = strings.TrimSpace(r.Name)
+	rule.Metric = r.Metric
+	rule.Symbol, rule.Exchange = "", ""
+	rule.Indicator, rule.Interval, rule.Line = nil, "", ""
+	switch r.Metric {
+	case MetricPrice:
+		rule.Symbol, rule.Exchange = r.Symbol, r.Exchange
+	case MetricIndicator:
+		spec := *r.Indicator
+		rule.Symbol, rule.Indicator, rule.Interval, rule.Line = r.Symbol, &spec, r.Interval, r.Line
+	}
+	rule.Operator = r.Operator
+	rule.Value = r.Value
+	rule.Channels = append([]Channel{}, r.Channels...)
+	if len(rule.Channels) == 0 {
+		rule.Channels = []Channel{ChannelInApp}
+	}
+	if r.Active != nil {
+		rule.Active = *r.Active
+	}
+}
+
+// hasLine reports whether a type of indicator has a line
+func hasLine(indicatorType indicators.Type, line string) bool {
+	for _, name := range indicators.Lines(indicatorType) {
+		if name == line {
+			return true
+		}
+	}
+	return false
+}
+
+// Alert is a rule triggering, with the outcome of its delivery
+type Alert struct {
+	ID        string    `json:"id" bson:"_id"`
+	RuleID    string    `json:"ruleId" bson:"ruleId"`
+	RuleName  string    `json:"ruleName" bson:"ruleName"`
+	UserID    string    `json:"userId" bson:"userId"`
+	Metric    Metric    `json:"metric" bson:"metric"`
+	Symbol    string    `json:"symbol,omitempty" bson:"symbol,omitempty"`
+	Operator  Operator  `json:"operator" bson:"operator"`
+	Threshold float64   `json:"threshold" bson:"threshold"`
+	Value     float64   `json:"value" bson:"value"` // Of the metric when the rule triggered
+	Message   string    `json:"message" bson:"message"`
+	Link      string    `json:"link,omitempty" bson:"link,omitempty"` // To act on the alert, for notifications
+	Channels  []Channel `json:"channels" bson:"channels"`             // Delivered to
+	Errors    []string  `json:"errors,omitempty" bson:"errors"`       // Of the channels it failed to be delivered to
+	Time      time.Time `json:"time" bson:"time"`
+
+	Suppressed Suppression `json:"suppressed,omitempty" bson:"suppressed,omitempty"` // Why it was not delivered
+	Throttled  []Channel   `json:"throttled,omitempty" bson:"throttled,omitempty"`   // Channels it exceeded the rate limit of
+	Grouped    []string    `json:"grouped,omitempty" bson:"grouped,omitempty"`       // IDs of the alerts a digest groups
+	Read       bool        `json:"read" bson:"read"`                                 // Whether the user has read it in the notification center
+	ReadAt     time.Time   `json:"readAt,omitempty" bson:"readAt,omitempty"`
+}
+
+// AlertFilter selects alerts, empty fields matching every alert
+type AlertFilter struct {
+	UserID    string
+	RuleID    string
+	Unread    bool // Only alerts not yet read
+	Delivered bool // Only alerts not suppressed, those of the notification center
+	Offset    int  // Of the alerts skipped, newest first
+	Limit     int  // Of the alerts returned, newest first
+}
+
+// Matches reports whether an alert is selected by the filter
+func (f AlertFilter) Matches(alert Alert) bool {
+	return (f.UserID == "" || alert.UserID == f.UserID) &&
+		(f.RuleID == "" || alert.RuleID == f.RuleID) &&
+		(!f.Unread || !alert.Read) &&
+		(!f.Delivered || alert.Suppressed == "")
+}
+
+// Store persists alert rules and the alerts they trigger
+type Store interface {
+	SaveRule(rule *Rule) error
+	GetRule(id string) (*Rule, error)
+	ListRules(userID string) ([]Rule, error) // Every rule when userID is empty
+	DeleteRule(id string) error
+	SaveAlert(alert *Alert) error
+	ListAlerts(filter AlertFilter) ([]Alert, error)
+	CountAlerts(filter AlertFilter) (int, error)                           // Ignoring the filter's offset and limit
+	MarkAlertsRead(userID string, ids []string, at time.Time) (int, error) // Every unread alert of the user when ids is empty
+}
@@ -0,0 +1,510 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/indicators"
+	"github.com/trading-platform/backend/internal/messagequeue"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/margin"
+	"github.com/trading-platform/backend/internal/services/position"
+	"github.com/trading-platform/backend/internal/services/risk"
+)
+
+// stubPositions is a position service returning fixed open positions, each with
+// fixed greeks
+type stubPositions struct {
+	position.PositionService
+	positions []models.Position
+	greeks    models.Greeks
+}
+
+func (s *stubPositions) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	var positions []models.Position
+	for _, openPosition := range s.positions {
+		if openPosition.UserID == filter.UserID && openPosition.Status == filter.Status {
+			positions = append(positions, openPosition)
+		}
+	}
+	return positions, len(positions), nil
+}
+
+func (s *stubPositions) CalculateGreeks(position *models.Position) (*models.Greeks, error) {
+	greeks := s.greeks
+	return &greeks, nil
+}
+
+// stubFunds is a funds provider returning fixed funds
+type stubFunds map[string]*margin.UserFunds
+
+func (s stubFunds) GetUserFunds(userID string) (*margin.UserFunds, error) {
+	funds, exists := s[userID]
+	if !exists {
+		return nil, errors.New("no funds")
+	}
+	return funds, nil
+}
+
+// recordingSender records the alerts sent over it
+type recordingSender struct {
+	alerts []Alert
+	mutex  sync.Mutex
+}
+
+func (s *recordingSender) Send(ctx context.Context, alert *Alert) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.alerts = append(s.alerts, *alert)
+	return nil
+}
+
+// users is a user directory of fixed users
+type users map[string]*models.User
+
+func (u users) GetByID(id string) (*models.User, error) {
+	user, exists := u[id]
+	if !exists {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+func TestRuleCRUD(t *testing.T) {
+	service := NewService(NewMemoryStore(), Sources{}, nil, Config{})
+
+	for _, request := range []Request{
+		{Metric: MetricDelta, Operator: OperatorGT, Value: 500},
+		{Name: "NIFTY", Metric: MetricPrice, Operator: OperatorCrossesAbove, Value: 22000},
+		{Name: "Delta", Metric: "RHO", Operator: OperatorGT, Value: 500},
+		{Name: "Delta", Metric: MetricDelta, Operator: "ABOVE", Value: 500},
+		{Name: "Delta", Metric: MetricDelta, Operator: OperatorGT, Value: 500, Channels: []Channel{"PAGER"}},
+	} {
+		_, err := service.CreateRule("user1", request)
+		assert.ErrorIs(t, err, ErrInvalidRule, request)
+	}
+
+	// Rules are active and delivered in-app unless told otherwise
+	rule, err := service.CreateRule("user1", Request{Name: "NIFTY", Metric: MetricPrice, Symbol: "NIFTY", Operator: OperatorCrossesAbove, Value: 22000})
+	require.NoError(t, err)
+	assert.True(t, rule.Active)
+	assert.Equal(t, []Channel{ChannelInApp}, rule.Channels)
+	assert.Equal(t, "NIFTY CROSSES_ABOVE 22000", rule.String())
+
+	inactive := false
+	updated, err := service.UpdateRule(rule.ID, Request{Name: "Delta", Metric: MetricDelta, Symbol: "NIFTY", Operator: OperatorGT, Value: 500, Active: &inactive})
+	require.NoError(t, err)
+	assert.False(t, updated.Active)
+	assert.Empty(t, updated.Symbol)
+	assert.Equal(t, rule.CreatedAt, updated.CreatedAt)
+
+	rules, err := service.ListRules("user1")
+	require.NoError(t, err)
+	assert.Len(t, rules, 1)
+
+	assert.NoError(t, service.DeleteRule(rule.ID))
+	_, err = service.GetRule(rule.ID)
+	assert.ErrorIs(t, err, ErrRuleNotFound)
+	assert.ErrorIs(t, service.DeleteRule(rule.ID), ErrRuleNotFound)
+}
+
+func TestPriceCrossing(t *testing.T) {
+	inApp := new(recordingSender)
+	service := NewService(NewMemoryStore(), Sources{}, map[Channel]Sender{ChannelInApp: inApp}, Config{})
+
+	rule, err := service.CreateRule("user1", Request{Name: "NIFTY breakout", Metric: MetricPrice, Symbol: "NIFTY", Exchange: "NSE", Operator: OperatorCrossesAbove, Value: 22000})
+	require.NoError(t, err)
+
+	// A price already above the threshold is not a crossing
+	assert.Empty(t, service.HandleQuote("NIFTY", "NSE", 22100))
+	assert.Empty(t, service.HandleQuote("NIFTY", "NSE", 21950))
+
+	// Other instruments are not evaluated
+	assert.Empty(t, service.HandleQuote("NIFTY", "BSE", 22050))
+	assert.Empty(t, service.HandleQuote("BANKNIFTY", "NSE", 48000))
+
+	triggered := service.HandleQuote("NIFTY", "NSE", 22010)
+	require.Len(t, triggered, 1)
+	assert.Equal(t, rule.ID, triggered[0].RuleID)
+	assert.Equal(t, 22010.0, triggered[0].Value)
+	assert.Equal(t, []Channel{ChannelInApp}, triggered[0].Channels)
+	assert.Len(t, inApp.alerts, 1)
+
+	// Until the price crosses again
+	assert.Empty(t, service.HandleQuote("NIFTY", "NSE", 22100))
+	assert.Empty(t, service.HandleQuote("NIFTY", "NSE", 22000))
+	assert.Len(t, service.HandleQuote("NIFTY", "NSE", 22001), 1)
+
+	alerts, err := service.ListAlerts(AlertFilter{UserID: "user1"})
+	require.NoError(t, err)
+	assert.Len(t, alerts, 2)
+
+	updated, err := service.GetRule(rule.ID)
+	require.NoError(t, err)
+	assert.False(t, updated.LastTriggeredAt.IsZero())
+}
+
+func TestRun(t *testing.T) {
+	positions := &stubPositions{
+		positions: []models.Position{
+			{ID: "position1", UserID: "user1", Status: models.PositionStatusOpen, RealizedPnL: -2000},
+			{ID: "position2", UserID: "user1", Status: models.PositionStatusPartial},
+		},
+		greeks: models.Greeks{Delta: 300},
+	}
+	funds := stubFunds{"user1": {UserID: "user1", Available: 50000, Utilization: 80}}
+	inApp := new(recordingSender)
+	service := NewService(NewMemoryStore(), Sources{Positions: positions, Funds: funds}, map[Channel]Sender{ChannelInApp: inApp}, Config{})
+
+	delta, err := service.CreateRule("user1", Request{Name: "Portfolio delta", Metric: MetricDelta, Operator: OperatorGT, Value: 500})
+	require.NoError(t, err)
+	utilization, err := service.CreateRule("user1", Request{Name: "Margin", Metric: MetricMarginUtilization, Operator: OperatorGTE, Value: 75, Channels: []Channel{ChannelInApp, ChannelSMS}})
+	require.NoError(t, err)
+	_, err = service.CreateRule("user2", Request{Name: "Margin", Metric: MetricMarginAvailable, Operator: OperatorLT, Value: 10000})
+	require.NoError(t, err)
+
+	// The portfolio's delta is 600 and 80% of its margin is used, each alerted once
+	// while it holds. Channels without a sender fail to deliver.
+	result, err := service.Run(time.Now())
+	require.NoError(t, err)
+	require.Len(t, result.Alerts, 2)
+	assert.Len(t, result.Errors, 1)
+	for _, alert := range result.Alerts {
+		switch alert.RuleID {
+		case delta.ID:
+			assert.Equal(t, 600.0, alert.Value)
+			assert.Contains(t, alert.Message, "Portfolio delta")
+		case utilization.ID:
+			assert.Equal(t, []Channel{ChannelInApp}, alert.Channels)
+			assert.Equal(t, []string{"SMS: channel not configured"}, alert.Errors)
+		default:
+			t.Errorf("unexpected alert of rule %s", alert.RuleID)
+		}
+	}
+
+	result, err = service.Run(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, result.Alerts)
+
+	// Once the delta falls back the rule triggers again when it rises
+	positions.greeks.Delta = 200
+	result, err = service.Run(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, result.Alerts)
+
+	positions.greeks.Delta = 260
+	result, err = service.Run(time.Now())
+	require.NoError(t, err)
+	require.Len(t, result.Alerts, 1)
+	assert.Equal(t, delta.ID, result.Alerts[0].RuleID)
+	assert.Len(t, inApp.alerts, 3)
+}
+
+func TestIndicatorRule(t *testing.T) {
+	candles := indicators.NewStore(0)
+	inApp := new(recordingSender)
+	service := NewService(NewMemoryStore(), Sources{Indicators: candles}, map[Channel]Sender{ChannelInApp: inApp}, Config{})
+
+	sma := &indicators.Spec{Type: indicators.TypeSMA, Period: 3}
+	for _, request := range []Request{
+		{Name: "SMA", Metric: MetricIndicator, Interval: "5m", Indicator: sma, Operator: OperatorGT, Value: 100},
+		{Name: "SMA", Metric: MetricIndicator, Symbol: "NIFTY", Interval: "5m", Operator: OperatorGT, Value: 100},
+		{Name: "SMA", Metric: MetricIndicator, Symbol: "NIFTY", Interval: "5m", Indicator: &indicators.Spec{Type: "KAMA"}, Operator: OperatorGT, Value: 100},
+		{Name: "SMA", Metric: MetricIndicator, Symbol: "NIFTY", Interval: "5m", Indicator: sma, Line: indicators.LineSignal, Operator: OperatorGT, Value: 100},
+	} {
+		_, err := service.CreateRule("user1", request)
+		assert.ErrorIs(t, err, ErrInvalidRule, request)
+	}
+
+	rule, err := service.CreateRule("user1", Request{Name: "Above average", Metric: MetricIndicator, Symbol: "NIFTY", Interval: "5m", Indicator: sma, Operator: OperatorCrossesAbove, Value: 100})
+	require.NoError(t, err)
+	assert.Equal(t, "NIFTY 5m SMA(3,close) CROSSES_ABOVE 100", rule.String())
+
+	add := func(prices ...float64) {
+		last := candles.Candles("NIFTY", "5m", 1)
+		next := time.Date(2026, 10, 12, 9, 15, 0, 0, time.UTC)
+		if len(last) > 0 {
+			next = last[0].Time.Add(5 * time.Minute)
+		}
+		for _, price := range prices {
+			require.NoError(t, candles.Add("NIFTY", "5m", indicators.Candle{Time: next, Open: price, High: price, Low: price, Close: price}))
+			next = next.Add(5 * time.Minute)
+		}
+	}
+
+	// Rules are not evaluated until there are enough candles
+	add(96, 98)
+	result, err := service.Run(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, result.Alerts)
+	assert.Empty(t, result.Errors)
+
+	add(100)
+	result, err = service.Run(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, result.Alerts)
+
+	add(104, 106)
+	result, err = service.Run(time.Now())
+	require.NoError(t, err)
+	require.Len(t, result.Alerts, 1)
+	assert.Equal(t, rule.ID, result.Alerts[0].RuleID)
+	assert.InDelta(t, 310.0/3, result.Alerts[0].Value, 1e-9)
+	assert.Contains(t, result.Alerts[0].Message, "SMA(3,close)")
+
+	// Without an indicator source the rule fails to evaluate
+	service = NewService(NewMemoryStore(), Sources{}, nil, Config{})
+	_, err = service.CreateRule("user1", Request{Name: "Above average", Metric: MetricIndicator, Symbol: "NIFTY", Interval: "5m", Indicator: sma, Operator: OperatorGT, Value: 100})
+	require.NoError(t, err)
+	result, err = service.Run(time.Now())
+	require.NoError(t, err)
+	assert.Len(t, result.Errors, 1)
+}
+
+func TestNotify(t *testing.T) {
+	inApp := &recordingSender{}
+	service := NewService(NewMemoryStore(), Sources{}, map[Channel]Sender{ChannelInApp: inApp}, Config{})
+
+	alert := service.Notify("user1", "Broker session expiring", "Log in again", "/brokers/login", []Channel{ChannelInApp, ChannelSMS})
+	assert.Empty(t, alert.RuleID)
+	assert.Equal(t, []Channel{ChannelInApp}, alert.Channels)
+	assert.Equal(t, []string{"SMS: channel not configured"}, alert.Errors)
+	require.Len(t, inApp.alerts, 1)
+	assert.Equal(t, "/brokers/login", inApp.alerts[0].Link)
+
+	// Notifications are part of the user's alert history
+	history, err := service.ListAlerts(AlertFilter{UserID: "user1"})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, alert.ID, history[0].ID)
+}
+
+func TestThrottle(t *testing.T) {
+	inApp, sms := &recordingSender{}, &recordingSender{}
+	service := NewService(NewMemoryStore(), Sources{}, map[Channel]Sender{ChannelInApp: inApp, ChannelSMS: sms}, Config{
+		Throttle: ThrottleConfig{
+			DedupeWindow: time.Minute,
+			GroupWindow:  100 * time.Millisecond,
+			GroupAfter:   2,
+			RateLimits:   map[Channel]RateLimit{ChannelSMS: {Limit: 3, Period: time.Hour}},
+		},
+	})
+	channels := []Channel{ChannelInApp, ChannelSMS}
+
+	// Identical alerts are delivered once
+	service.Notify("user1", "Circuit breaker tripped", "Trading paused", "", channels)
+	duplicate := service.Notify("user1", "Circuit breaker tripped", "Trading paused", "", channels)
+	assert.Equal(t, SuppressedDuplicate, duplicate.Suppressed)
+	assert.Empty(t, duplicate.Channels)
+	assert.Len(t, inApp.alerts, 1)
+
+	// Bursts are grouped into a digest after the first alerts. SMS is capped at 3
+	// an hour, the digest exceeding it.
+	for _, symbol := range []string{"NIFTY24JUN22000CE", "NIFTY24JUN22000PE", "NIFTY24JUN22100CE", "NIFTY24JUN22100PE", "NIFTY24JUN22200CE"} {
+		service.Notify("user1", "Order filled", "BUY 50/50 "+symbol, "", channels)
+	}
+	assert.Len(t, inApp.alerts, 3)
+	assert.Len(t, sms.alerts, 3)
+
+	assert.Eventually(t, func() bool {
+		inApp.mutex.Lock()
+		defer inApp.mutex.Unlock()
+		return len(inApp.alerts) == 4
+	}, time.Second, 10*time.Millisecond)
+	inApp.mutex.Lock()
+	digest := inApp.alerts[3]
+	inApp.mutex.Unlock()
+	assert.Equal(t, "Order filled (3 more)", digest.RuleName)
+	assert.Equal(t, "BUY 50/50 NIFTY24JUN22100CE\nBUY 50/50 NIFTY24JUN22100PE\nBUY 50/50 NIFTY24JUN22200CE", digest.Message)
+	assert.Len(t, digest.Grouped, 3)
+	assert.Len(t, sms.alerts, 3)
+
+	history, err := service.ListAlerts(AlertFilter{UserID: "user1"})
+	require.NoError(t, err)
+	var grouped, throttled int
+	for _, alert := range history {
+		if alert.Suppressed == SuppressedGrouped {
+			grouped++
+		}
+		if len(alert.Throttled) > 0 {
+			throttled++
+		}
+	}
+	assert.Equal(t, 3, grouped)
+	assert.Equal(t, 1, throttled)
+
+	// Other users are not throttled
+	alert := service.Notify("user2", "Circuit breaker tripped", "Trading paused", "", channels)
+	assert.Equal(t, channels, alert.Channels)
+}
+
+// recordingHub records the messages broadcast to each topic
+type recordingHub struct {
+	messages map[string][]map[string]interface{}
+	mutex    sync.Mutex
+}
+
+func (h *recordingHub) BroadcastToTopic(topic string, message []byte) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var decoded map[string]interface{}
+	json.Unmarshal(message, &decoded)
+	if h.messages == nil {
+		h.messages = make(map[string][]map[string]interface{})
+	}
+	h.messages[topic] = append(h.messages[topic], decoded)
+}
+
+func TestInbox(t *testing.T) {
+	hub := &recordingHub{}
+	service := NewService(NewMemoryStore(), Sources{}, map[Channel]Sender{ChannelEmail: &recordingSender{}}, Config{
+		Throttle: ThrottleConfig{DedupeWindow: time.Minute},
+	})
+	service.SetHub(hub)
+
+	var ids []string
+	for _, message := range []string{"Fill 1", "Fill 2", "Fill 3", "Fill 3"} {
+		ids = append(ids, service.Notify("user1", "Order filled", message, "", []Channel{ChannelEmail}).ID)
+	}
+	service.Notify("user2", "Order filled", "Fill 1", "", []Channel{ChannelEmail})
+
+	// Suppressed alerts are not notifications. Notifications are published as
+	// they are delivered.
+	inbox, err := service.Inbox("user1", false, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, inbox.Total)
+	assert.Equal(t, 3, inbox.Unread)
+	assert.Equal(t, 2, inbox.TotalPages)
+	assert.True(t, inbox.HasNextPage)
+	require.Len(t, inbox.Notifications, 2)
+
+	messages := hub.messages["user:user1:notifications"]
+	require.Len(t, messages, 3)
+	assert.Equal(t, "NOTIFICATION", messages[2]["type"])
+	assert.Equal(t, 3.0, messages[2]["payload"].(map[string]interface{})["unread"])
+
+	inbox, err = service.Inbox("user1", false, 2, 2)
+	require.NoError(t, err)
+	assert.Len(t, inbox.Notifications, 1)
+	assert.False(t, inbox.HasNextPage)
+
+	unread, err := service.MarkRead("user1", ids[:1])
+	require.NoError(t, err)
+	assert.Equal(t, 2, unread)
+	messages = hub.messages["user:user1:notifications"]
+	require.Len(t, messages, 4)
+	assert.Equal(t, "NOTIFICATIONS_READ", messages[3]["type"])
+
+	inbox, err = service.Inbox("user1", true, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inbox.Total)
+	for _, notification := range inbox.Notifications {
+		assert.NotEqual(t, ids[0], notification.ID)
+	}
+
+	// Every notification of the user only
+	unread, err = service.MarkRead("user1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, unread)
+	inbox, err = service.Inbox("user2", false, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, inbox.Unread)
+}
+
+func TestOrderNotifier(t *testing.T) {
+	push := &recordingSender{}
+	service := NewService(NewMemoryStore(), Sources{}, map[Channel]Sender{ChannelPush: push}, Config{})
+	notifier := NewOrderNotifier(service, []Channel{ChannelPush})
+
+	// Only fills are notified
+	require.NoError(t, notifier.PublishOrderEvent(context.Background(), messagequeue.OrderNew, messagequeue.OrderEvent{UserID: "user1"}))
+	assert.Empty(t, push.alerts)
+
+	fill := messagequeue.OrderEvent{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: "BUY", OrderType: "LIMIT", Quantity: 50, FilledQuantity: 25, Price: 101, AveragePrice: 100.5}
+	require.NoError(t, notifier.PublishOrderEvent(context.Background(), messagequeue.OrderFill, fill))
+	fill.OrderType, fill.Direction, fill.FilledQuantity = string(models.OrderTypeSLLimit), "SELL", 50
+	require.NoError(t, notifier.PublishOrderEvent(context.Background(), messagequeue.OrderFill, &fill))
+
+	require.Len(t, push.alerts, 2)
+	assert.Equal(t, "Order partially filled", push.alerts[0].RuleName)
+	assert.Equal(t, "BUY 25/50 NIFTY on NFO at 100.50", push.alerts[0].Message)
+	assert.Equal(t, "Stop-loss hit", push.alerts[1].RuleName)
+
+	// Fills that reach no channel are errors
+	notifier = NewOrderNotifier(service, []Channel{ChannelSMS})
+	assert.Error(t, notifier.PublishOrderEvent(context.Background(), messagequeue.OrderFill, fill))
+}
+
+func TestRiskNotifier(t *testing.T) {
+	push := &recordingSender{}
+	service := NewService(NewMemoryStore(), Sources{}, map[Channel]Sender{ChannelPush: push}, Config{})
+	notifier := NewRiskNotifier(service, []Channel{ChannelPush, ChannelInApp})
+
+	require.NoError(t, notifier.Notify(risk.Notification{UserID: "user1", Type: risk.NotificationCircuitBreakerTripped, Message: "Trading paused"}))
+	require.Len(t, push.alerts, 1)
+	assert.Equal(t, "Circuit breaker tripped", push.alerts[0].RuleName)
+	assert.Equal(t, "Trading paused", push.alerts[0].Message)
+
+	history, err := service.ListAlerts(AlertFilter{UserID: "user1"})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, []Channel{ChannelPush}, history[0].Channels)
+	assert.Equal(t, []string{"IN_APP: channel not configured"}, history[0].Errors)
+}
+
+func TestEmailSender(t *testing.T) {
+	sender := NewEmailSender(users{"user1": {ID: "user1", Email: "trader@example.com"}}, SMTPConfig{Host: "smtp.example.com", Port: 587, From: "alerts@example.com"})
+
+	var addr string
+	var to []string
+	var message []byte
+	sender.sendMail = func(a string, auth smtp.Auth, from string, recipients []string, msg []byte) error {
+		addr, to, message = a, recipients, msg
+		return nil
+	}
+
+	alert := &Alert{UserID: "user1", RuleName: "NIFTY\r\nBcc: someone@example.com", Message: "Alert triggered", Time: time.Now()}
+	require.NoError(t, sender.Send(context.Background(), alert))
+	assert.Equal(t, "smtp.example.com:587", addr)
+	assert.Equal(t, []string{"trader@example.com"}, to)
+	assert.Contains(t, string(message), "Subject: Alert: NIFTY Bcc: someone@example.com\r\n")
+	assert.NotContains(t, string(message), "\r\nBcc:")
+	assert.True(t, strings.HasSuffix(string(message), "\r\n\r\nAlert triggered\r\n"))
+
+	assert.Error(t, sender.Send(context.Background(), &Alert{UserID: "user2"}))
+
+	// Links follow the message
+	require.NoError(t, sender.Send(context.Background(), &Alert{UserID: "user1", Message: "Session expiring", Link: "https://example.com/login", Time: time.Now()}))
+	assert.True(t, strings.HasSuffix(string(message), "\r\n\r\nSession expiring\r\n\r\nhttps://example.com/login\r\n"))
+}
+
+func TestSMSSender(t *testing.T) {
+	var request map[string]string
+	var authorization string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&request)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer gateway.Close()
+
+	sender := NewSMSSender(users{"user1": {ID: "user1", Phone: "+919800000000"}, "user2": {ID: "user2"}}, SMSConfig{URL: gateway.URL, APIKey: "key", From: "ALERTS"})
+
+	require.NoError(t, sender.Send(context.Background(), &Alert{UserID: "user1", Message: "Alert triggered"}))
+	assert.Equal(t, "Bearer key", authorization)
+	assert.Equal(t, map[string]string{"to": "+919800000000", "from": "ALERTS", "message": "Alert triggered"}, request)
+
+	// Users without a phone number cannot be texted
+	assert.Error(t, sender.Send(context.Background(), &Alert{UserID: "user2"}))
+}
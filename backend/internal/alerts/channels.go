@@ -0,0 +1,221 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// inAppMessageType is the type of the websocket messages alerts are sent in
+const inAppMessageType = "ALERT"
+
+// UserDirectory looks users up, for the email address and phone number alerts are
+// sent to
+type UserDirectory interface {
+	GetByID(id string) (*models.User, error)
+}
+
+// TopicBroadcaster broadcasts messages to the websocket clients subscribed to a
+// topic, as the websocket hub does
+type TopicBroadcaster interface {
+	BroadcastToTopic(topic string, message []byte)
+}
+
+// InAppSender delivers alerts over users' websocket connections, on the
+// user:<id>:alerts topic
+type InAppSender struct {
+	hub TopicBroadcaster
+}
+
+// NewInAppSender creates a new InAppSender
+func NewInAppSender(hub TopicBroadcaster) *InAppSender {
+	return &InAppSender{hub: hub}
+}
+
+// Send broadcasts an alert to its user's websocket clients
+func (s *InAppSender) Send(ctx context.Context, alert *Alert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	message, err := json.Marshal(struct {
+		Type      string          `json:"type"`
+		Timestamp time.Time       `json:"timestamp"`
+		Payload   json.RawMessage `json:"payload"`
+	}{
+		Type:      inAppMessageType,
+		Timestamp: alert.Time,
+		Payload:   payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.hub.BroadcastToTopic("user:"+alert.UserID+":alerts", message)
+	return nil
+}
+
+// SMTPConfig configures the mail server alerts are emailed through
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // No authentication when empty
+	Password string
+	From     string
+}
+
+// EmailSender emails alerts to their users' email addresses
+type EmailSender struct {
+	users    UserDirectory
+	config   SMTPConfig
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailSender creates a new EmailSender
+func NewEmailSender(users UserDirectory, config SMTPConfig) *EmailSender {
+	return &EmailSender{
+		users:    users,
+		config:   config,
+		sendMail: smtp.SendMail,
+	}
+}
+
+// Send emails an alert to its user
+func (s *EmailSender) Send(ctx context.Context, alert *Alert) error {
+	user, err := s.users.GetByID(alert.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.Email == "" {
+		return errors.New("user has no email address")
+	}
+
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+
+	return s.sendMail(addr, auth, s.config.From, []string{user.Email}, emailMessage(s.config.From, user.Email, alert))
+}
+
+// emailMessage returns the message of an alert's email. Header values are
+// stripped of line breaks, rule names being user input.
+func emailMessage(from, to string, alert *Alert) []byte {
+	header := strings.NewReplacer("\r", "", "\n", " ")
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(&message, "To: %s\r\n", header.Replace(to))
+	fmt.Fprintf(&message, "Subject: Alert: %s\r\n", header.Replace(alert.RuleName))
+	fmt.Fprintf(&message, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	message.WriteString(alert.Message)
+	message.WriteString("\r\n")
+	if alert.Link != "" {
+		fmt.Fprintf(&message, "\r\n%s\r\n", alert.Link)
+	}
+
+	return message.Bytes()
+}
+
+// SMSConfig configures the HTTP gateway alerts are texted through
+type SMSConfig struct {
+	URL     string // Requests are POSTed here as JSON with to, from and message fields
+	APIKey  string // Sent as a bearer token
+	From    string
+	Timeout time.Duration // Of requests, defaults to DefaultSendTimeout
+}
+
+// SMSSender texts alerts to their users' phone numbers through an HTTP gateway
+type SMSSender struct {
+	users  UserDirectory
+	config SMSConfig
+	client *http.Client
+}
+
+// NewSMSSender creates a new SMSSender
+func NewSMSSender(users UserDirectory, config SMSConfig) *SMSSender {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultSendTimeout
+	}
+	return &SMSSender{
+		users:  users,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Send texts an alert to its user
+func (s *SMSSender) Send(ctx context.Context, alert *Alert) error {
+	user, err := s.users.GetByID(alert.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.Phone == "" {
+		return errors.New("user has no phone number")
+	}
+
+	text := alert.Message
+	if alert.Link != "" {
+		text += " " + alert.Link
+	}
+	body, err := json.Marshal(map[string]string{
+		"to":      user.Phone,
+		"from":    s.config.From,
+		"message": text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.APIKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("SMS gateway responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// AlertPublisher publishes alerts to users' webhooks, as the webhook service does
+type AlertPublisher interface {
+	PublishAlert(ctx context.Context, userID, alertID string, alert interface{}) error
+}
+
+// WebhookSender delivers alerts to their users' webhooks subscribed to them
+type WebhookSender struct {
+	publisher AlertPublisher
+}
+
+// NewWebhookSender creates a new WebhookSender
+func NewWebhookSender(publisher AlertPublisher) *WebhookSender {
+	return &WebhookSender{publisher: publisher}
+}
+
+// Send queues the delivery of an alert to its user's webhooks
+func (s *WebhookSender) Send(ctx context.Context, alert *Alert) error {
+	return s.publisher.PublishAlert(ctx, alert.UserID, alert.ID, alert)
+}
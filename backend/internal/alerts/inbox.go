@@ -0,0 +1,144 @@
+package alerts
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+const (
+	// notificationMessageType is the type of the websocket messages new
+	// notifications are sent in
+	notificationMessageType = "NOTIFICATION"
+
+	// notificationsReadMessageType is the type of the websocket messages telling
+	// the user's clients notifications were read
+	notificationsReadMessageType = "NOTIFICATIONS_READ"
+)
+
+// Inbox is a page of a user's notification center: the alerts delivered to them
+// over any channel, newest first
+type Inbox struct {
+	Notifications []Alert `json:"notifications"`
+	Total         int     `json:"total"`  // Of the notifications selected
+	Unread        int     `json:"unread"` // Of every notification of the user
+	Page          int     `json:"page"`
+	Limit         int     `json:"limit"`
+	TotalPages    int     `json:"totalPages"`
+	HasNextPage   bool    `json:"hasNextPage"`
+}
+
+// SetHub sets the websocket hub notifications are published to as they are
+// delivered and read, on the user:<id>:notifications topic
+func (s *Service) SetHub(hub TopicBroadcaster) {
+	s.hub = hub
+}
+
+// Inbox returns a page of a user's notification center, only unread
+// notifications when unreadOnly is set. Pages count from 1.
+func (s *Service) Inbox(userID string, unreadOnly bool, page, limit int) (*Inbox, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 1
+	}
+
+	filter := AlertFilter{
+		UserID:    userID,
+		Unread:    unreadOnly,
+		Delivered: true,
+		Offset:    (page - 1) * limit,
+		Limit:     limit,
+	}
+	notifications, err := s.store.ListAlerts(filter)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.store.CountAlerts(filter)
+	if err != nil {
+		return nil, err
+	}
+	unread := total
+	if !unreadOnly {
+		if unread, err = s.unread(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Inbox{
+		Notifications: notifications,
+		Total:         total,
+		Unread:        unread,
+		Page:          page,
+		Limit:         limit,
+		TotalPages:    (total + limit - 1) / limit,
+		HasNextPage:   page*limit < total,
+	}, nil
+}
+
+// MarkRead marks notifications of a user read, every unread notification when
+// ids is empty, returning the number of notifications still unread. The user's
+// clients are told, so that every open terminal shows them read.
+func (s *Service) MarkRead(userID string, ids []string) (int, error) {
+	marked, err := s.store.MarkAlertsRead(userID, ids, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	unread, err := s.unread(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if marked > 0 {
+		s.broadcast(userID, notificationsReadMessageType, map[string]interface{}{
+			"ids":    ids,
+			"all":    len(ids) == 0,
+			"unread": unread,
+		})
+	}
+
+	return unread, nil
+}
+
+// unread returns the number of unread notifications of a user
+func (s *Service) unread(userID string) (int, error) {
+	return s.store.CountAlerts(AlertFilter{UserID: userID, Unread: true, Delivered: true})
+}
+
+// publishNotification publishes an alert just delivered to its user's
+// notification center, with their unread count
+func (s *Service) publishNotification(alert *Alert) {
+	if s.hub == nil {
+		return
+	}
+
+	unread, err := s.unread(alert.UserID)
+	if err != nil {
+		log.Printf("Error counting unread notifications of user %s: %v", alert.UserID, err)
+		return
+	}
+	s.broadcast(alert.UserID, notificationMessageType, map[string]interface{}{
+		"notification": alert,
+		"unread":       unread,
+	})
+}
+
+// broadcast sends a message to a user's clients subscribed to their
+// notifications
+func (s *Service) broadcast(userID, messageType string, payload interface{}) {
+	if s.hub == nil {
+		return
+	}
+
+	message, err := json.Marshal(map[string]interface{}{
+		"type":      messageType,
+		"timestamp": time.Now(),
+		"payload":   payload,
+	})
+	if err != nil {
+		log.Printf("Error encoding notification message: %v", err)
+		return
+	}
+	s.hub.BroadcastToTopic("user:"+userID+":notifications", message)
+}
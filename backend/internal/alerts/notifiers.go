@@ -0,0 +1,94 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/trading-platform/backend/internal/messagequeue"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+)
+
+// OrderNotifier notifies users of the fills of their orders over channels, fills
+// of stop-loss orders as stop-outs. It implements services.OrderEventPublisher, for
+// the order service to publish order events to it.
+type OrderNotifier struct {
+	service  *Service
+	channels []Channel
+}
+
+// NewOrderNotifier creates a new OrderNotifier
+func NewOrderNotifier(service *Service, channels []Channel) *OrderNotifier {
+	return &OrderNotifier{
+		service:  service,
+		channels: channels,
+	}
+}
+
+// PublishOrderEvent notifies the user of an order fill, ignoring other events
+func (n *OrderNotifier) PublishOrderEvent(ctx context.Context, msgType messagequeue.MessageType, data interface{}) error {
+	if msgType != messagequeue.OrderFill {
+		return nil
+	}
+
+	var event messagequeue.OrderEvent
+	switch data := data.(type) {
+	case messagequeue.OrderEvent:
+		event = data
+	case *messagequeue.OrderEvent:
+		event = *data
+	default:
+		return errors.New("order events must be messagequeue.OrderEvent")
+	}
+
+	price := event.AveragePrice
+	if price == 0 {
+		price = event.Price
+	}
+	name := "Order filled"
+	if event.FilledQuantity < event.Quantity {
+		name = "Order partially filled"
+	}
+	if event.OrderType == string(models.OrderTypeSLLimit) {
+		name = "Stop-loss hit"
+	}
+	message := fmt.Sprintf("%s %d/%d %s on %s at %.2f", event.Direction, event.FilledQuantity, event.Quantity, event.Symbol, event.Exchange, price)
+
+	return deliveryError(n.service.Notify(event.UserID, name, message, "", n.channels))
+}
+
+// RiskNotifier notifies users of their risk controls over channels, such as the
+// circuit breaker tripping or a risk rule triggering. It implements risk.Notifier.
+type RiskNotifier struct {
+	service  *Service
+	channels []Channel
+}
+
+// NewRiskNotifier creates a new RiskNotifier
+func NewRiskNotifier(service *Service, channels []Channel) *RiskNotifier {
+	return &RiskNotifier{
+		service:  service,
+		channels: channels,
+	}
+}
+
+// Notify delivers a risk notification to its user
+func (n *RiskNotifier) Notify(notification risk.Notification) error {
+	// CIRCUIT_BREAKER_TRIPPED is titled "Circuit breaker tripped"
+	name := strings.ToLower(strings.ReplaceAll(string(notification.Type), "_", " "))
+	if name != "" {
+		name = strings.ToUpper(name[:1]) + name[1:]
+	}
+
+	return deliveryError(n.service.Notify(notification.UserID, name, notification.Message, "", n.channels))
+}
+
+// deliveryError returns the error of a notification delivered over no channel
+func deliveryError(alert Alert) error {
+	if len(alert.Channels) > 0 || len(alert.Errors) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(alert.Errors, "; "))
+}
@@ -0,0 +1,674 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/indicators"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/margin"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+const (
+	// DefaultInterval is how often every active rule is evaluated
+	DefaultInterval = 5 * time.Second
+
+	// DefaultSendTimeout limits how long delivering an alert over its channels may take
+	DefaultSendTimeout = 10 * time.Second
+
+	// pageSize is the number of positions read at a time
+	pageSize = 100
+)
+
+// errNotReady is returned for the values of indicator rules until there are enough
+// candles to compute them, which rules are not evaluated on
+var errNotReady = errors.New("not enough candles")
+
+// QuoteProvider retrieves the last traded prices of instruments
+type QuoteProvider interface {
+	GetLastPrice(symbol, exchange string) (float64, error)
+}
+
+// FundsProvider retrieves the margin of users' broker accounts, as the funds
+// synchronizer does
+type FundsProvider interface {
+	GetUserFunds(userID string) (*margin.UserFunds, error)
+}
+
+// IndicatorProvider computes technical indicators on the candles of instruments, as
+// the indicator store does. Values are false while there are too few candles.
+type IndicatorProvider interface {
+	Value(symbol, interval string, spec indicators.Spec, line string) (float64, bool, error)
+}
+
+// Sources are the live data rules are evaluated against. Rules on metrics whose
+// source is missing fail to evaluate.
+type Sources struct {
+	Positions  position.PositionService // P&L and greeks
+	Quotes     QuoteProvider            // Prices
+	Funds      FundsProvider            // Margin
+	Indicators IndicatorProvider        // Technical indicators
+}
+
+// Sender delivers alerts over a channel
+type Sender interface {
+	Send(ctx context.Context, alert *Alert) error
+}
+
+// Config configures the alert service
+type Config struct {
+	Interval    time.Duration  // Between evaluations, defaults to DefaultInterval
+	SendTimeout time.Duration  // Of the delivery of an alert, defaults to DefaultSendTimeout
+	Throttle    ThrottleConfig // Of alerts before delivery, none when zero
+}
+
+// RunResult is the outcome of one evaluation of every active rule
+type RunResult struct {
+	Time   time.Time `json:"time"`
+	Alerts []Alert   `json:"alerts"`
+	Errors []string  `json:"errors,omitempty"`
+}
+
+// ruleState is what a rule's evaluations have seen so far
+type ruleState struct {
+	value    float64 // Last value of the rule's metric
+	observed bool    // Whether a value has been seen
+	holding  bool    // Whether the rule's condition held for the last value
+}
+
+// Service manages users' alert rules and evaluates them, every interval and as
+// prices stream in, delivering the alerts they trigger over the channels each
+// rule names. Channels without a sender fail to deliver, which is recorded with
+// the alert. Alerts are deduped, grouped and rate limited as configured before
+// delivery.
+type Service struct {
+	store    Store
+	sources  Sources
+	senders  map[Channel]Sender
+	config   Config
+	throttle *throttle
+	hub      TopicBroadcaster // Of the notification center, optional
+	rules    map[string]Rule  // Cache of the store's rules, loaded when first needed
+	states   map[string]*ruleState
+	stop     chan struct{}
+	mutex    sync.Mutex
+}
+
+// NewService creates a new Service. Rules are evaluated every interval once
+// started, and on the prices passed to HandleQuote.
+func NewService(store Store, sources Sources, senders map[Channel]Sender, config Config) *Service {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.SendTimeout <= 0 {
+		config.SendTimeout = DefaultSendTimeout
+	}
+
+	registered := make(map[Channel]Sender, len(senders))
+	for channel, sender := range senders {
+		if sender != nil {
+			registered[channel] = sender
+		}
+	}
+
+	service := &Service{
+		store:   store,
+		sources: sources,
+		senders: registered,
+		config:  config,
+		states:  make(map[string]*ruleState),
+	}
+	service.throttle = newThrottle(config.Throttle, service.sendDigest)
+
+	return service
+}
+
+// CreateRule adds a rule for a user
+func (s *Service) CreateRule(userID string, request Request) (*Rule, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	rule := &Rule{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	request.apply(rule)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.loadRules(); err != nil {
+		return nil, err
+	}
+	if err := s.store.SaveRule(rule); err != nil {
+		return nil, err
+	}
+	s.rules[rule.ID] = *rule
+
+	return rule, nil
+}
+
+// GetRule returns a rule by ID
+func (s *Service) GetRule(id string) (*Rule, error) {
+	return s.store.GetRule(id)
+}
+
+// ListRules returns the rules of a user, every rule when userID is empty
+func (s *Service) ListRules(userID string) ([]Rule, error) {
+	return s.store.ListRules(userID)
+}
+
+// UpdateRule changes a rule. It is evaluated afresh, as if just created.
+func (s *Service) UpdateRule(id string, request Request) (*Rule, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.loadRules(); err != nil {
+		return nil, err
+	}
+	rule, err := s.store.GetRule(id)
+	if err != nil {
+		return nil, err
+	}
+	request.apply(rule)
+	rule.UpdatedAt = time.Now()
+
+	if err := s.store.SaveRule(rule); err != nil {
+		return nil, err
+	}
+	s.rules[rule.ID] = *rule
+	delete(s.states, rule.ID)
+
+	return rule, nil
+}
+
+// DeleteRule removes a rule. The alerts it triggered are kept.
+func (s *Service) DeleteRule(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.store.DeleteRule(id); err != nil {
+		return err
+	}
+	delete(s.rules, id)
+	delete(s.states, id)
+
+	return nil
+}
+
+// ListAlerts returns the alerts selected by a filter, newest first
+func (s *Service) ListAlerts(filter AlertFilter) ([]Alert, error) {
+	return s.store.ListAlerts(filter)
+}
+
+// Start evaluates every active rule every interval until the service is stopped
+func (s *Service) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stop != nil {
+		return errors.New("alert service is already running")
+	}
+	if err := s.loadRules(); err != nil {
+		return fmt.Errorf("failed to load alert rules: %w", err)
+	}
+	s.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := s.Run(now)
+				if err != nil {
+					log.Printf("Error evaluating alert rules: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Alert rule error: %s", message)
+				}
+			}
+		}
+	}(s.stop)
+
+	return nil
+}
+
+// Stop stops the service
+func (s *Service) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// Run evaluates every active rule at now, reading each live value once
+func (s *Service) Run(now time.Time) (*RunResult, error) {
+	rules, err := s.activeRules()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RunResult{
+		Time:   now,
+		Alerts: []Alert{},
+	}
+	values := newSnapshot(s.sources)
+	for i := range rules {
+		rule := &rules[i]
+		value, err := values.value(rule)
+		if errors.Is(err, errNotReady) {
+			continue
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("rule %s of user %s: %v", rule.ID, rule.UserID, err))
+			continue
+		}
+		if s.observe(rule, value) {
+			result.Alerts = append(result.Alerts, s.trigger(rule, value, now))
+		}
+	}
+
+	return result, nil
+}
+
+// HandleQuote evaluates the active price rules on an instrument against a price
+// streamed for it, returning the alerts they trigger. Rules without an exchange
+// match the symbol on any exchange.
+func (s *Service) HandleQuote(symbol, exchange string, price float64) []Alert {
+	rules, err := s.activeRules()
+	if err != nil {
+		log.Printf("Error evaluating price alerts of %s: %v", symbol, err)
+		return nil
+	}
+
+	var triggered []Alert
+	now := time.Now()
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Metric != MetricPrice || rule.Symbol != symbol || (rule.Exchange != "" && rule.Exchange != exchange) {
+			continue
+		}
+		if s.observe(rule, price) {
+			triggered = append(triggered, s.trigger(rule, price, now))
+		}
+	}
+
+	return triggered
+}
+
+// loadRules fills the cache of rules from the store when it is not yet loaded.
+// The caller holds the mutex.
+func (s *Service) loadRules() error {
+	if s.rules != nil {
+		return nil
+	}
+
+	rules, err := s.store.ListRules("")
+	if err != nil {
+		return err
+	}
+	s.rules = make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		s.rules[rule.ID] = rule
+	}
+
+	return nil
+}
+
+// activeRules returns the rules being evaluated, forgetting what was seen of
+// inactive ones so that they start afresh when reactivated
+func (s *Service) activeRules() ([]Rule, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.loadRules(); err != nil {
+		return nil, err
+	}
+
+	rules := make([]Rule, 0, len(s.rules))
+	for id, rule := range s.rules {
+		if !rule.Active {
+			delete(s.states, id)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// observe records a value of a rule's metric, reporting whether the rule
+// triggers on it
+func (s *Service) observe(rule *Rule, value float64) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state, exists := s.states[rule.ID]
+	if !exists {
+		state = &ruleState{}
+		s.states[rule.ID] = state
+	}
+
+	var triggers bool
+	switch rule.Operator {
+	case OperatorCrossesAbove:
+		triggers = state.observed && state.value <= rule.Value && value > rule.Value
+	case OperatorCrossesBelow:
+		triggers = state.observed && state.value >= rule.Value && value < rule.Value
+	default:
+		holds := compare(rule.Operator, value, rule.Value)
+		triggers = holds && !state.holding
+		state.holding = holds
+	}
+	state.value, state.observed = value, true
+
+	return triggers
+}
+
+// compare reports whether a value compares with a threshold as a level operator
+// requires
+func compare(operator Operator, value, threshold float64) bool {
+	switch operator {
+	case OperatorGT:
+		return value > threshold
+	case OperatorGTE:
+		return value >= threshold
+	case OperatorLT:
+		return value < threshold
+	case OperatorLTE:
+		return value <= threshold
+	}
+	return false
+}
+
+// trigger delivers the alert of a rule triggering on a value over the rule's
+// channels, and records it
+func (s *Service) trigger(rule *Rule, value float64, now time.Time) Alert {
+	alert := Alert{
+		ID:        uuid.New().String(),
+		RuleID:    rule.ID,
+		RuleName:  rule.Name,
+		UserID:    rule.UserID,
+		Metric:    rule.Metric,
+		Symbol:    rule.Symbol,
+		Operator:  rule.Operator,
+		Threshold: rule.Value,
+		Value:     value,
+		Message:   fmt.Sprintf("Alert %s triggered: %s, now %g", rule.Name, rule.String(), value),
+		Channels:  []Channel{},
+		Time:      now,
+	}
+	log.Printf("Alert rule %s (%s) triggered for user %s at %g", rule.Name, rule.ID, rule.UserID, value)
+	s.deliver(&alert, rule.Channels)
+
+	// Record when the rule last triggered, unless it changed meanwhile
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if cached, exists := s.rules[rule.ID]; exists && cached.UpdatedAt.Equal(rule.UpdatedAt) {
+		cached.LastTriggeredAt = now
+		s.rules[rule.ID] = cached
+		if err := s.store.SaveRule(&cached); err != nil {
+			log.Printf("Error saving alert rule %s: %v", rule.ID, err)
+		}
+	}
+
+	return alert
+}
+
+// Notify delivers a notification the platform raises, rather than a rule, to a
+// user over channels, recording it in their alert history. Link points to where
+// the user can act on it.
+func (s *Service) Notify(userID, name, message, link string, channels []Channel) Alert {
+	alert := Alert{
+		ID:       uuid.New().String(),
+		RuleName: name,
+		UserID:   userID,
+		Message:  message,
+		Link:     link,
+		Channels: []Channel{},
+		Time:     time.Now(),
+	}
+	log.Printf("Notifying user %s: %s", userID, name)
+	s.deliver(&alert, channels)
+
+	return alert
+}
+
+// deliver sends an alert over channels, unless the throttle suppresses it, and
+// saves it
+func (s *Service) deliver(alert *Alert, channels []Channel) {
+	if alert.Suppressed = s.throttle.admit(alert, channels); alert.Suppressed != "" {
+		log.Printf("Alert %s of user %s suppressed: %s", alert.ID, alert.UserID, alert.Suppressed)
+		if err := s.store.SaveAlert(alert); err != nil {
+			log.Printf("Error saving alert %s: %v", alert.ID, err)
+		}
+		return
+	}
+
+	s.send(alert, channels)
+}
+
+// sendDigest delivers the digest of alerts grouped from a burst, over every
+// channel they were to be delivered over
+func (s *Service) sendDigest(held []Alert, channels []Channel) {
+	first := held[0]
+	alert := Alert{
+		ID:       uuid.New().String(),
+		RuleName: fmt.Sprintf("%s (%d more)", first.RuleName, len(held)),
+		UserID:   first.UserID,
+		Metric:   first.Metric,
+		Symbol:   first.Symbol,
+		Message:  digest(held),
+		Channels: []Channel{},
+		Time:     time.Now(),
+	}
+	for _, grouped := range held {
+		alert.Grouped = append(alert.Grouped, grouped.ID)
+	}
+	log.Printf("Sending digest of %d %s alerts to user %s", len(held), first.RuleName, first.UserID)
+	s.send(&alert, channels)
+}
+
+// send delivers an alert over channels, recording those it was delivered to,
+// those whose rate limit it exceeded and the errors of the others, and saves it
+func (s *Service) send(alert *Alert, channels []Channel) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.SendTimeout)
+	defer cancel()
+
+	for _, channel := range channels {
+		sender, exists := s.senders[channel]
+		if !exists {
+			alert.Errors = append(alert.Errors, fmt.Sprintf("%s: channel not configured", channel))
+			continue
+		}
+		if !s.throttle.allow(alert.UserID, channel, alert.Time) {
+			alert.Throttled = append(alert.Throttled, channel)
+			continue
+		}
+		if err := sender.Send(ctx, alert); err != nil {
+			alert.Errors = append(alert.Errors, fmt.Sprintf("%s: %v", channel, err))
+			continue
+		}
+		alert.Channels = append(alert.Channels, channel)
+	}
+
+	if err := s.store.SaveAlert(alert); err != nil {
+		log.Printf("Error saving alert %s: %v", alert.ID, err)
+		return
+	}
+	s.publishNotification(alert)
+}
+
+// snapshot reads the live values rules are evaluated against, each once
+type snapshot struct {
+	sources    Sources
+	prices     map[string]float64            // By exchange and symbol
+	portfolios map[string]map[Metric]float64 // P&L and greeks, by user
+	funds      map[string]*margin.UserFunds  // By user
+}
+
+func newSnapshot(sources Sources) *snapshot {
+	return &snapshot{
+		sources:    sources,
+		prices:     make(map[string]float64),
+		portfolios: make(map[string]map[Metric]float64),
+		funds:      make(map[string]*margin.UserFunds),
+	}
+}
+
+// value returns the value of a rule's metric
+func (n *snapshot) value(rule *Rule) (float64, error) {
+	switch rule.Metric {
+	case MetricPrice:
+		return n.price(rule.Symbol, rule.Exchange)
+	case MetricIndicator:
+		return n.indicator(rule)
+	case MetricPnL, MetricDelta, MetricGamma, MetricTheta, MetricVega:
+		portfolio, err := n.portfolio(rule.UserID)
+		if err != nil {
+			return 0, err
+		}
+		return portfolio[rule.Metric], nil
+	case MetricMarginUtilization, MetricMarginAvailable:
+		funds, err := n.userFunds(rule.UserID)
+		if err != nil {
+			return 0, err
+		}
+		if rule.Metric == MetricMarginAvailable {
+			return funds.Available, nil
+		}
+		return funds.Utilization, nil
+	}
+	return 0, fmt.Errorf("unknown metric %q", rule.Metric)
+}
+
+// price returns the last price of an instrument
+func (n *snapshot) price(symbol, exchange string) (float64, error) {
+	if n.sources.Quotes == nil {
+		return 0, errors.New("no quote provider")
+	}
+
+	key := exchange + ":" + symbol
+	if price, exists := n.prices[key]; exists {
+		return price, nil
+	}
+	price, err := n.sources.Quotes.GetLastPrice(symbol, exchange)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get price of %s: %w", symbol, err)
+	}
+	n.prices[key] = price
+
+	return price, nil
+}
+
+// indicator returns the line of the indicator an indicator rule watches, or
+// errNotReady while there are too few candles to compute it
+func (n *snapshot) indicator(rule *Rule) (float64, error) {
+	if n.sources.Indicators == nil {
+		return 0, errors.New("no indicator provider")
+	}
+	if rule.Indicator == nil {
+		return 0, errors.New("no indicator")
+	}
+
+	value, ok, err := n.sources.Indicators.Value(rule.Symbol, rule.Interval, *rule.Indicator, rule.Line)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute %s of %s: %w", rule.Indicator, rule.Symbol, err)
+	}
+	if !ok {
+		return 0, errNotReady
+	}
+
+	return value, nil
+}
+
+// portfolio returns the P&L and greeks of a user's open positions
+func (n *snapshot) portfolio(userID string) (map[Metric]float64, error) {
+	if n.sources.Positions == nil {
+		return nil, errors.New("no position service")
+	}
+	if portfolio, exists := n.portfolios[userID]; exists {
+		return portfolio, nil
+	}
+
+	positions, err := openPositions(n.sources.Positions, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+	portfolio := make(map[Metric]float64)
+	for i := range positions {
+		portfolio[MetricPnL] += positions[i].CalculateTotalPnL()
+		greeks, err := n.sources.Positions.CalculateGreeks(&positions[i])
+		if err != nil {
+			return nil, fmt.Errorf("position %s: %w", positions[i].ID, err)
+		}
+		portfolio[MetricDelta] += greeks.Delta
+		portfolio[MetricGamma] += greeks.Gamma
+		portfolio[MetricTheta] += greeks.Theta
+		portfolio[MetricVega] += greeks.Vega
+	}
+	n.portfolios[userID] = portfolio
+
+	return portfolio, nil
+}
+
+// userFunds returns the margin of a user's broker accounts
+func (n *snapshot) userFunds(userID string) (*margin.UserFunds, error) {
+	if n.sources.Funds == nil {
+		return nil, errors.New("no funds provider")
+	}
+	if funds, exists := n.funds[userID]; exists {
+		return funds, nil
+	}
+
+	funds, err := n.sources.Funds.GetUserFunds(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funds: %w", err)
+	}
+	n.funds[userID] = funds
+
+	return funds, nil
+}
+
+// openPositions returns the open and partially closed positions of a user
+func openPositions(positionService position.PositionService, userID string) ([]models.Position, error) {
+	var positions []models.Position
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial} {
+		filter := models.PositionFilter{UserID: userID, Status: status}
+		for page := 1; ; page++ {
+			batch, total, err := positionService.GetPositions(filter, page, pageSize)
+			if err != nil {
+				return nil, err
+			}
+			positions = append(positions, batch...)
+			if len(batch) == 0 || page*pageSize >= total {
+				break
+			}
+		}
+	}
+
+	return positions, nil
+}
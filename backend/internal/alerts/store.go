@@ -0,0 +1,334 @@
+package alerts
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxMemoryAlerts is the number of alerts the memory store keeps per user, the
+// oldest being dropped
+const maxMemoryAlerts = 1000
+
+// MemoryStore keeps alert rules and alerts in memory
+type MemoryStore struct {
+	rules  map[string]Rule
+	alerts map[string][]Alert // By user, oldest first
+	mutex  sync.RWMutex
+}
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		rules:  make(map[string]Rule),
+		alerts: make(map[string][]Alert),
+	}
+}
+
+// SaveRule adds or replaces a rule
+func (s *MemoryStore) SaveRule(rule *Rule) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	saved := *rule
+	saved.Channels = append([]Channel{}, rule.Channels...)
+	s.rules[rule.ID] = saved
+	return nil
+}
+
+// GetRule returns a rule by ID
+func (s *MemoryStore) GetRule(id string) (*Rule, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	rule, exists := s.rules[id]
+	if !exists {
+		return nil, ErrRuleNotFound
+	}
+	return &rule, nil
+}
+
+// ListRules returns the rules of a user, every rule when userID is empty, oldest
+// first
+func (s *MemoryStore) ListRules(userID string) ([]Rule, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	rules := make([]Rule, 0)
+	for _, rule := range s.rules {
+		if userID == "" || rule.UserID == userID {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules, nil
+}
+
+// DeleteRule removes a rule. The alerts it triggered are kept.
+func (s *MemoryStore) DeleteRule(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.rules[id]; !exists {
+		return ErrRuleNotFound
+	}
+	delete(s.rules, id)
+	return nil
+}
+
+// SaveAlert adds an alert
+func (s *MemoryStore) SaveAlert(alert *Alert) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	alerts := s.alerts[alert.UserID]
+	if len(alerts) >= maxMemoryAlerts {
+		alerts = alerts[1:]
+	}
+	s.alerts[alert.UserID] = append(alerts, *alert)
+	return nil
+}
+
+// ListAlerts returns the alerts selected by a filter, newest first
+func (s *MemoryStore) ListAlerts(filter AlertFilter) ([]Alert, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	alerts := make([]Alert, 0)
+	for userID, triggered := range s.alerts {
+		if filter.UserID != "" && userID != filter.UserID {
+			continue
+		}
+		for _, alert := range triggered {
+			if filter.Matches(alert) {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Time.After(alerts[j].Time)
+	})
+	if filter.Offset > 0 {
+		if filter.Offset >= len(alerts) {
+			return make([]Alert, 0), nil
+		}
+		alerts = alerts[filter.Offset:]
+	}
+	if filter.Limit > 0 && len(alerts) > filter.Limit {
+		alerts = alerts[:filter.Limit]
+	}
+	return alerts, nil
+}
+
+// CountAlerts returns the number of alerts selected by a filter, ignoring its
+// offset and limit
+func (s *MemoryStore) CountAlerts(filter AlertFilter) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	count := 0
+	for userID, triggered := range s.alerts {
+		if filter.UserID != "" && userID != filter.UserID {
+			continue
+		}
+		for _, alert := range triggered {
+			if filter.Matches(alert) {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// MarkAlertsRead marks alerts of a user read at a time, every unread alert of the
+// user when ids is empty, returning the number marked
+func (s *MemoryStore) MarkAlertsRead(userID string, ids []string, at time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	marked := 0
+	alerts := s.alerts[userID]
+	for i := range alerts {
+		if alerts[i].Read || (len(ids) > 0 && !selected[alerts[i].ID]) {
+			continue
+		}
+		alerts[i].Read, alerts[i].ReadAt = true, at
+		marked++
+	}
+	return marked, nil
+}
+
+// MongoStore keeps alert rules and alerts in MongoDB collections
+type MongoStore struct {
+	rules  *mongo.Collection
+	alerts *mongo.Collection
+}
+
+// NewMongoStore creates a new MongoStore
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		rules:  db.Collection("alert_rules"),
+		alerts: db.Collection("alerts"),
+	}
+}
+
+// SaveRule adds or replaces a rule
+func (s *MongoStore) SaveRule(rule *Rule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.rules.ReplaceOne(ctx, bson.M{"_id": rule.ID}, rule, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetRule returns a rule by ID
+func (s *MongoStore) GetRule(id string) (*Rule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var rule Rule
+	err := s.rules.FindOne(ctx, bson.M{"_id": id}).Decode(&rule)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListRules returns the rules of a user, every rule when userID is empty, oldest
+// first
+func (s *MongoStore) ListRules(userID string) ([]Rule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	if userID != "" {
+		query["userId"] = userID
+	}
+
+	cursor, err := s.rules.Find(ctx, query, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rules := make([]Rule, 0)
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// DeleteRule removes a rule. The alerts it triggered are kept.
+func (s *MongoStore) DeleteRule(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.rules.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+// SaveAlert adds an alert
+func (s *MongoStore) SaveAlert(alert *Alert) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.alerts.InsertOne(ctx, alert)
+	return err
+}
+
+// ListAlerts returns the alerts selected by a filter, newest first
+func (s *MongoStore) ListAlerts(filter AlertFilter) ([]Alert, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.M{"time": -1})
+	if filter.Offset > 0 {
+		findOptions.SetSkip(int64(filter.Offset))
+	}
+	if filter.Limit > 0 {
+		findOptions.SetLimit(int64(filter.Limit))
+	}
+
+	cursor, err := s.alerts.Find(ctx, alertQuery(filter), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	alerts := make([]Alert, 0)
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// CountAlerts returns the number of alerts selected by a filter, ignoring its
+// offset and limit
+func (s *MongoStore) CountAlerts(filter AlertFilter) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count, err := s.alerts.CountDocuments(ctx, alertQuery(filter))
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// MarkAlertsRead marks alerts of a user read at a time, every unread alert of the
+// user when ids is empty, returning the number marked
+func (s *MongoStore) MarkAlertsRead(userID string, ids []string, at time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := bson.M{"userId": userID, "read": bson.M{"$ne": true}}
+	if len(ids) > 0 {
+		query["_id"] = bson.M{"$in": ids}
+	}
+
+	result, err := s.alerts.UpdateMany(ctx, query, bson.M{"$set": bson.M{"read": true, "readAt": at}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.ModifiedCount), nil
+}
+
+// alertQuery returns the query of the alerts selected by a filter. Alerts saved
+// before they had a read state are unread.
+func alertQuery(filter AlertFilter) bson.M {
+	query := bson.M{}
+	if filter.UserID != "" {
+		query["userId"] = filter.UserID
+	}
+	if filter.RuleID != "" {
+		query["ruleId"] = filter.RuleID
+	}
+	if filter.Unread {
+		query["read"] = bson.M{"$ne": true}
+	}
+	if filter.Delivered {
+		query["suppressed"] = bson.M{"$in": bson.A{nil, ""}}
+	}
+	return query
+}
@@ -0,0 +1,196 @@
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultGroupAfter is the number of alerts of a burst delivered individually
+	// before the rest are grouped into a digest
+	DefaultGroupAfter = 3
+
+	// maxDigestLines is the number of grouped alerts a digest's message lists
+	maxDigestLines = 20
+)
+
+// Suppression is why an alert was not delivered
+type Suppression string
+
+const (
+	SuppressedDuplicate Suppression = "DUPLICATE" // Identical to an alert the user was just sent
+	SuppressedGrouped   Suppression = "GROUPED"   // Part of a burst, delivered in its digest
+)
+
+// RateLimit caps the alerts delivered to a user over a channel
+type RateLimit struct {
+	Limit  int           // Alerts delivered per period
+	Period time.Duration // Sliding, from each alert delivered
+}
+
+// ThrottleConfig configures the stage alerts pass through before delivery, which
+// keeps bursts of them from flooding users. Zero values disable each part.
+type ThrottleConfig struct {
+	DedupeWindow time.Duration         // Alerts identical to one the user was sent within it are dropped
+	GroupWindow  time.Duration         // Bursts of alerts of the same name within it are grouped into a digest
+	GroupAfter   int                   // Alerts of a burst delivered individually, defaults to DefaultGroupAfter
+	RateLimits   map[Channel]RateLimit // Channels without one are not capped
+}
+
+// DefaultThrottleConfig returns the throttling of alerts suited to most users:
+// duplicates dropped for a minute, bursts grouped over 30 seconds, and email, SMS
+// and push capped per hour
+func DefaultThrottleConfig() ThrottleConfig {
+	return ThrottleConfig{
+		DedupeWindow: time.Minute,
+		GroupWindow:  30 * time.Second,
+		GroupAfter:   DefaultGroupAfter,
+		RateLimits: map[Channel]RateLimit{
+			ChannelEmail: {Limit: 30, Period: time.Hour},
+			ChannelSMS:   {Limit: 10, Period: time.Hour},
+			ChannelPush:  {Limit: 60, Period: time.Hour},
+		},
+	}
+}
+
+// burst is the alerts of the same name a user is sent within a group window
+type burst struct {
+	count    int       // Alerts of the burst so far
+	held     []Alert   // Alerts grouped into the digest
+	channels []Channel // Union of the held alerts' channels
+}
+
+// throttle dedupes, groups and rate limits alerts. Digests of the bursts it
+// groups are passed to flush when their window ends.
+type throttle struct {
+	config ThrottleConfig
+	flush  func(held []Alert, channels []Channel)
+	seen   map[string]time.Time   // When each alert was last let through, by dedupe key
+	bursts map[string]*burst      // By user and alert name
+	sent   map[string][]time.Time // Deliveries within their rate limit period, by user and channel
+	mutex  sync.Mutex
+}
+
+func newThrottle(config ThrottleConfig, flush func(held []Alert, channels []Channel)) *throttle {
+	if config.GroupAfter <= 0 {
+		config.GroupAfter = DefaultGroupAfter
+	}
+
+	return &throttle{
+		config: config,
+		flush:  flush,
+		seen:   make(map[string]time.Time),
+		bursts: make(map[string]*burst),
+		sent:   make(map[string][]time.Time),
+	}
+}
+
+// admit reports why an alert about to be delivered over channels is suppressed,
+// if it is. Grouped alerts are held for the digest of their burst.
+func (t *throttle) admit(alert *Alert, channels []Channel) Suppression {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.config.DedupeWindow > 0 {
+		for key, last := range t.seen {
+			if alert.Time.Sub(last) >= t.config.DedupeWindow {
+				delete(t.seen, key)
+			}
+		}
+		key := strings.Join([]string{alert.UserID, alert.RuleID, alert.RuleName, alert.Message}, "\x00")
+		if _, exists := t.seen[key]; exists {
+			return SuppressedDuplicate
+		}
+		t.seen[key] = alert.Time
+	}
+
+	if t.config.GroupWindow > 0 {
+		key := alert.UserID + "\x00" + alert.RuleName
+		group, exists := t.bursts[key]
+		if !exists {
+			group = &burst{}
+			t.bursts[key] = group
+			time.AfterFunc(t.config.GroupWindow, func() { t.end(key) })
+		}
+		group.count++
+		if group.count > t.config.GroupAfter {
+			group.held = append(group.held, *alert)
+			group.channels = union(group.channels, channels)
+			return SuppressedGrouped
+		}
+	}
+
+	return ""
+}
+
+// end ends a burst, flushing the digest of the alerts it held
+func (t *throttle) end(key string) {
+	t.mutex.Lock()
+	group := t.bursts[key]
+	delete(t.bursts, key)
+	t.mutex.Unlock()
+
+	if group != nil && len(group.held) > 0 {
+		t.flush(group.held, group.channels)
+	}
+}
+
+// allow reports whether an alert may be delivered to a user over a channel at
+// now, counting it against the channel's rate limit when it may
+func (t *throttle) allow(userID string, channel Channel, now time.Time) bool {
+	limit, exists := t.config.RateLimits[channel]
+	if !exists || limit.Limit <= 0 {
+		return true
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	key := userID + "\x00" + string(channel)
+	recent := t.sent[key][:0]
+	for _, sentAt := range t.sent[key] {
+		if now.Sub(sentAt) < limit.Period {
+			recent = append(recent, sentAt)
+		}
+	}
+	if len(recent) >= limit.Limit {
+		t.sent[key] = recent
+		return false
+	}
+	t.sent[key] = append(recent, now)
+
+	return true
+}
+
+// digest returns the message of the digest of grouped alerts, listing the first
+// of them
+func digest(held []Alert) string {
+	lines := make([]string, 0, maxDigestLines+1)
+	for i := range held {
+		if i == maxDigestLines {
+			lines = append(lines, fmt.Sprintf("and %d more", len(held)-maxDigestLines))
+			break
+		}
+		lines = append(lines, held[i].Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// union adds the channels missing from a list of them
+func union(channels, others []Channel) []Channel {
+	for _, other := range others {
+		found := false
+		for _, channel := range channels {
+			if channel == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			channels = append(channels, other)
+		}
+	}
+	return channels
+}
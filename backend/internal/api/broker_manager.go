@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/trading-platform/backend/internal/broker/common"
 	"github.com/trading-platform/backend/internal/broker/factory"
@@ -14,7 +15,9 @@ import (
 type BrokerManager struct {
 	clients     map[string]common.BrokerClient
 	configs     map[string]*common.BrokerConfig
-	activeUsers map[string]string // Maps userID to clientID
+	activeUsers map[string]string          // Maps userID to clientID
+	sessions    map[string]*common.Session // Maps clientID to its session
+	loginTimes  map[string]time.Time       // Maps clientID to when its session started
 	mu          sync.RWMutex
 }
 
@@ -24,6 +27,8 @@ func NewBrokerManager() *BrokerManager {
 		clients:     make(map[string]common.BrokerClient),
 		configs:     make(map[string]*common.BrokerConfig),
 		activeUsers: make(map[string]string),
+		sessions:    make(map[string]*common.Session),
+		loginTimes:  make(map[string]time.Time),
 	}
 }
 
@@ -99,9 +104,11 @@ func (m *BrokerManager) Login(clientID string, credentials *common.Credentials)
 		return nil, err
 	}
 
-	// Store the active user
+	// Store the active user and their session
 	m.mu.Lock()
 	m.activeUsers[session.UserID] = clientID
+	m.sessions[clientID] = session
+	m.loginTimes[clientID] = time.Now()
 	m.mu.Unlock()
 
 	return session, nil
@@ -119,8 +126,10 @@ func (m *BrokerManager) Logout(clientID string) error {
 		return err
 	}
 
-	// Remove the active user
+	// Remove the active user and their session
 	m.mu.Lock()
+	delete(m.sessions, clientID)
+	delete(m.loginTimes, clientID)
 	for userID, cID := range m.activeUsers {
 		if cID == clientID {
 			delete(m.activeUsers, userID)
@@ -132,6 +141,40 @@ func (m *BrokerManager) Logout(clientID string) error {
 	return nil
 }
 
+// SessionExpiries returns when the session of each client logged in expires, by
+// client ID
+func (m *BrokerManager) SessionExpiries() map[string]time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	expiries := make(map[string]time.Time, len(m.sessions))
+	for clientID, session := range m.sessions {
+		expiries[clientID] = time.Unix(session.ExpiresAt, 0)
+	}
+	return expiries
+}
+
+// Sessions returns the sessions of the clients logged in
+func (m *BrokerManager) Sessions() []common.SessionInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := make([]common.SessionInfo, 0, len(m.sessions))
+	for clientID, session := range m.sessions {
+		info := common.SessionInfo{
+			ClientID:  clientID,
+			UserID:    session.UserID,
+			LoginAt:   m.loginTimes[clientID],
+			ExpiresAt: time.Unix(session.ExpiresAt, 0),
+		}
+		if config, exists := m.configs[clientID]; exists {
+			info.Broker = config.BrokerType
+		}
+		sessions = append(sessions, info)
+	}
+	return sessions
+}
+
 // GetClientIDForUser gets the client ID for the specified user ID
 func (m *BrokerManager) GetClientIDForUser(userID string) (string, error) {
 	if userID == "" {
@@ -284,6 +327,28 @@ func (m *BrokerManager) UnsubscribeFromQuotes(userID string, symbols []string) e
 	return client.UnsubscribeFromQuotes(symbols)
 }
 
+// GetBasketMargin gets the margin a basket of orders requires for the specified user
+func (m *BrokerManager) GetBasketMargin(userID string, orders []*common.Order) (*common.BasketMargin, error) {
+	clientID, err := m.GetClientIDForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := m.GetBrokerClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the client has a margin calculator
+	if marginClient, ok := client.(interface {
+		GetBasketMargin(orders []*common.Order) (*common.BasketMargin, error)
+	}); ok {
+		return marginClient.GetBasketMargin(orders)
+	}
+
+	return nil, errors.New("margin calculation not supported by this broker")
+}
+
 // For dealer-specific operations, we need to add methods that take a dealer client ID and a target client ID
 
 // PlaceDealerOrder places an order on behalf of a client as a dealer
@@ -2,6 +2,7 @@ package api
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/trading-platform/backend/internal/broker/common"
@@ -160,6 +161,13 @@ func TestBrokerManagerWithMockClient(t *testing.T) {
 	assert.NotNil(t, session)
 	assert.Equal(t, "test_token", session.Token)
 	assert.Equal(t, "user1", session.UserID)
+	assert.Equal(t, map[string]time.Time{"client1": time.Unix(1617345678, 0)}, manager.SessionExpiries())
+	sessions := manager.Sessions()
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "user1", sessions[0].UserID)
+	assert.Equal(t, common.BrokerTypeXTSClient, sessions[0].Broker)
+	assert.Equal(t, time.Unix(1617345678, 0), sessions[0].ExpiresAt)
+	assert.False(t, sessions[0].LoginAt.IsZero())
 	
 	// Test place order
 	order := &common.Order{
@@ -232,6 +240,8 @@ func TestBrokerManagerWithMockClient(t *testing.T) {
 	// Test logout
 	err = manager.Logout("client1")
 	assert.NoError(t, err)
+	assert.Empty(t, manager.SessionExpiries())
+	assert.Empty(t, manager.Sessions())
 }
 
 // MockBrokerClient is a mock implementation of the BrokerClient interface for testing
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/alerts"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// defaultAlertLimit is the number of alerts returned when no limit is given
+const defaultAlertLimit = 100
+
+// AlertService is the part of the alert service the handler uses
+type AlertService interface {
+	CreateRule(userID string, request alerts.Request) (*alerts.Rule, error)
+	GetRule(id string) (*alerts.Rule, error)
+	ListRules(userID string) ([]alerts.Rule, error)
+	UpdateRule(id string, request alerts.Request) (*alerts.Rule, error)
+	DeleteRule(id string) error
+	ListAlerts(filter alerts.AlertFilter) ([]alerts.Alert, error)
+}
+
+// AlertHandler handles alert rule and alert history API endpoints. Users manage
+// their own rules, admins everyone's.
+type AlertHandler struct {
+	service AlertService
+}
+
+// NewAlertHandler creates a new AlertHandler
+func NewAlertHandler(service AlertService) *AlertHandler {
+	return &AlertHandler{
+		service: service,
+	}
+}
+
+// GetRules handles listing the caller's alert rules, or for admins those of the
+// userId query parameter, every rule when it is not given
+func (h *AlertHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = r.URL.Query().Get("userId")
+	}
+
+	rules, err := h.service.ListRules(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving alert rules")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, rules)
+}
+
+// CreateRule handles adding an alert rule for the caller
+func (h *AlertHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var request alerts.Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	created, err := h.service.CreateRule(userID, request)
+	if err != nil {
+		utils.RespondWithError(w, alertErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, created)
+}
+
+// GetRule handles retrieving an alert rule by ID
+func (h *AlertHandler) GetRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ownedRule(w, r)
+	if !ok {
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, rule)
+}
+
+// UpdateRule handles changing an alert rule
+func (h *AlertHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ownedRule(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse request body
+	var request alerts.Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	updated, err := h.service.UpdateRule(rule.ID, request)
+	if err != nil {
+		utils.RespondWithError(w, alertErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, updated)
+}
+
+// DeleteRule handles removing an alert rule
+func (h *AlertHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ownedRule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteRule(rule.ID); err != nil {
+		utils.RespondWithError(w, alertErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Alert rule deleted successfully"})
+}
+
+// GetAlerts handles listing the alerts triggered for the caller, newest first,
+// optionally of the ruleId query parameter only. Admins may give the userId query
+// parameter, seeing every user's alerts when it is not given.
+func (h *AlertHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = query.Get("userId")
+	}
+
+	filter := alerts.AlertFilter{
+		UserID: userID,
+		RuleID: query.Get("ruleId"),
+		Limit:  defaultAlertLimit,
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := utils.ParseInt(limitStr)
+		if err != nil || limit <= 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	triggered, err := h.service.ListAlerts(filter)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving alerts")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, triggered)
+}
+
+// ownedRule returns the alert rule of the ruleId path parameter, responding with
+// an error when the caller is not authenticated, it does not exist or it is not
+// the caller's and the caller is not an admin
+func (h *AlertHandler) ownedRule(w http.ResponseWriter, r *http.Request) (*alerts.Rule, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	rule, err := h.service.GetRule(mux.Vars(r)["ruleId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Alert rule not found")
+		return nil, false
+	}
+	if rule.UserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+
+	return rule, true
+}
+
+// alertErrorStatus returns the status of the response to an error of the alert
+// service
+func alertErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, alerts.ErrRuleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, alerts.ErrInvalidRule):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/alerts"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockAlertService is a mock implementation of the AlertService interface
+type MockAlertService struct {
+	mock.Mock
+}
+
+func (m *MockAlertService) CreateRule(userID string, request alerts.Request) (*alerts.Rule, error) {
+	args := m.Called(userID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*alerts.Rule), args.Error(1)
+}
+
+func (m *MockAlertService) GetRule(id string) (*alerts.Rule, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*alerts.Rule), args.Error(1)
+}
+
+func (m *MockAlertService) ListRules(userID string) ([]alerts.Rule, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]alerts.Rule), args.Error(1)
+}
+
+func (m *MockAlertService) UpdateRule(id string, request alerts.Request) (*alerts.Rule, error) {
+	args := m.Called(id, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*alerts.Rule), args.Error(1)
+}
+
+func (m *MockAlertService) DeleteRule(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockAlertService) ListAlerts(filter alerts.AlertFilter) ([]alerts.Alert, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]alerts.Alert), args.Error(1)
+}
+
+func TestCreateAlertRule(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockAlertService)
+	handler := NewAlertHandler(mockService)
+
+	request := alerts.Request{Name: "NIFTY breakout", Metric: alerts.MetricPrice, Symbol: "NIFTY", Operator: alerts.OperatorCrossesAbove, Value: 22000, Channels: []alerts.Channel{alerts.ChannelEmail}}
+	mockService.On("CreateRule", "user123", request).
+		Return(&alerts.Rule{ID: "rule1", UserID: "user123", Name: "NIFTY breakout", Active: true}, nil)
+	mockService.On("CreateRule", "user123", alerts.Request{Name: "Delta", Metric: "RHO"}).Return(nil, alerts.ErrInvalidRule)
+
+	req := httptest.NewRequest("POST", "/api/alerts/rules", strings.NewReader(`{"name":"NIFTY breakout","metric":"PRICE","symbol":"NIFTY","operator":"CROSSES_ABOVE","value":22000,"channels":["EMAIL"]}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.CreateRule(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var rule alerts.Rule
+	err := json.Unmarshal(rr.Body.Bytes(), &rule)
+	assert.NoError(t, err)
+	assert.Equal(t, "rule1", rule.ID)
+
+	// Invalid rules are rejected
+	req = httptest.NewRequest("POST", "/api/alerts/rules", strings.NewReader(`{"name":"Delta","metric":"RHO"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+
+	handler.CreateRule(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteAlertRule(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockAlertService)
+	handler := NewAlertHandler(mockService)
+
+	mockService.On("GetRule", "rule1").Return(&alerts.Rule{ID: "rule1", UserID: "user123"}, nil)
+	mockService.On("DeleteRule", "rule1").Return(nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/alerts/rules/{ruleId}", handler.DeleteRule).Methods("DELETE")
+
+	// Users may not delete others' rules
+	req := httptest.NewRequest("DELETE", "/api/alerts/rules/rule1", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user456"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockService.AssertNotCalled(t, "DeleteRule", mock.Anything)
+
+	req = httptest.NewRequest("DELETE", "/api/alerts/rules/rule1", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetAlerts(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockAlertService)
+	handler := NewAlertHandler(mockService)
+
+	mockService.On("ListAlerts", alerts.AlertFilter{UserID: "user123", RuleID: "rule1", Limit: 10}).
+		Return([]alerts.Alert{{ID: "alert1", RuleID: "rule1", UserID: "user123", Value: 22010}}, nil)
+
+	// Users only see their own alerts
+	req := httptest.NewRequest("GET", "/api/alerts?userId=user456&ruleId=rule1&limit=10", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetAlerts(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var triggered []alerts.Alert
+	err := json.Unmarshal(rr.Body.Bytes(), &triggered)
+	assert.NoError(t, err)
+	assert.Len(t, triggered, 1)
+
+	req = httptest.NewRequest("GET", "/api/alerts?limit=0", nil)
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+
+	handler.GetAlerts(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/trading-platform/backend/internal/audit"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// defaultAuditLimit is the number of audit events returned when no limit is given
+const defaultAuditLimit = 100
+
+// AuditLog is the part of the audit logger the handler uses
+type AuditLog interface {
+	Query(filter audit.Filter) ([]audit.Event, error)
+}
+
+// AuditHandler handles audit log API endpoints
+type AuditHandler struct {
+	auditLog AuditLog
+}
+
+// NewAuditHandler creates a new AuditHandler
+func NewAuditHandler(auditLog AuditLog) *AuditHandler {
+	return &AuditHandler{
+		auditLog: auditLog,
+	}
+}
+
+// GetEvents handles querying the audit log. Admins see every event, users the
+// events of their own actions.
+func (h *AuditHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	filter, ok := auditFilter(w, r)
+	if !ok {
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	}
+
+	events, err := h.auditLog.Query(filter)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving audit log")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, events)
+}
+
+// ExportEvents handles exporting the audit log as a file, in the format of the
+// format query parameter, JSON lines by default. Every event selected is exported
+// unless a limit is given.
+func (h *AuditHandler) ExportEvents(w http.ResponseWriter, r *http.Request) {
+	filter, ok := auditFilter(w, r)
+	if !ok {
+		return
+	}
+
+	format := audit.Format(r.URL.Query().Get("format"))
+	if format == "" {
+		format = audit.FormatJSONLines
+	}
+	if format != audit.FormatJSONLines && format != audit.FormatCSV {
+		utils.RespondWithError(w, http.StatusBadRequest, audit.ErrUnsupportedFormat.Error())
+		return
+	}
+
+	events, err := h.auditLog.Query(filter)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving audit log")
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit-%s.%s\"", time.Now().UTC().Format("20060102T150405Z"), format))
+	w.WriteHeader(http.StatusOK)
+	// The status is sent, a failed export can only be cut short
+	audit.Export(w, events, format)
+}
+
+// auditFilter returns the filter of the query parameters of a request, limited to
+// the caller's own actions unless they are an admin, responding with an error when
+// the caller is not authenticated or the parameters are invalid
+func auditFilter(w http.ResponseWriter, r *http.Request) (audit.Filter, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return audit.Filter{}, false
+	}
+
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Actor:        query.Get("actor"),
+		Category:     audit.Category(query.Get("category")),
+		Action:       audit.Action(query.Get("action")),
+		ResourceType: query.Get("resourceType"),
+		ResourceID:   query.Get("resourceId"),
+	}
+	if auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		filter.Actor = userID
+	}
+
+	for name, bound := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid "+name+" time, expected RFC 3339")
+				return audit.Filter{}, false
+			}
+			*bound = parsed
+		}
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := utils.ParseInt(limitStr)
+		if err != nil || limit <= 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return audit.Filter{}, false
+		}
+		filter.Limit = limit
+	}
+
+	return filter, true
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/audit"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockAuditLog is a mock implementation of the AuditLog interface
+type MockAuditLog struct {
+	mock.Mock
+}
+
+func (m *MockAuditLog) Query(filter audit.Filter) ([]audit.Event, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]audit.Event), args.Error(1)
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	// Create handler with mock audit log
+	mockAuditLog := new(MockAuditLog)
+	handler := NewAuditHandler(mockAuditLog)
+
+	events := []audit.Event{{ID: "event1", Category: audit.CategoryOrder, Action: audit.ActionOrderCreated, Actor: "user123"}}
+
+	// Users only see their own actions, whatever the actor asked for
+	mockAuditLog.On("Query", audit.Filter{Actor: "user123", Category: audit.CategoryOrder, Limit: defaultAuditLimit}).Return(events, nil).Once()
+
+	req := httptest.NewRequest("GET", "/api/audit/events?actor=user456&category=ORDER", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetEvents(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response []audit.Event
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, events, response)
+
+	// Admins see anyone's
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	mockAuditLog.On("Query", audit.Filter{Actor: "user456", Action: audit.ActionLimitUpdated, From: from, Limit: 10}).Return([]audit.Event{}, nil).Once()
+
+	req = httptest.NewRequest("GET", "/api/audit/events?actor=user456&action=LIMIT_UPDATED&from=2026-03-02T00:00:00Z&limit=10", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.GetEvents(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockAuditLog.AssertExpectations(t)
+
+	// Invalid parameters are rejected
+	for _, query := range []string{"from=yesterday", "limit=-1"} {
+		req = httptest.NewRequest("GET", "/api/audit/events?"+query, nil)
+		req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+		rr = httptest.NewRecorder()
+
+		handler.GetEvents(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestExportAuditEvents(t *testing.T) {
+	// Create handler with mock audit log
+	mockAuditLog := new(MockAuditLog)
+	handler := NewAuditHandler(mockAuditLog)
+
+	events := []audit.Event{
+		{ID: "event1", Category: audit.CategoryAdmin, Action: audit.ActionRuleDeleted, Actor: "admin1", ResourceType: "rule", ResourceID: "rule1"},
+		{ID: "event2", Category: audit.CategoryAuth, Action: audit.ActionLogin, Actor: "admin1", ResourceType: "user", ResourceID: "admin1"},
+	}
+	mockAuditLog.On("Query", audit.Filter{}).Return(events, nil)
+
+	// Exports are not limited unless asked
+	req := httptest.NewRequest("GET", "/api/audit/export?format=csv", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr := httptest.NewRecorder()
+
+	handler.ExportEvents(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), ".csv")
+	assert.Len(t, strings.Split(strings.TrimSpace(rr.Body.String()), "\n"), 3)
+
+	// Unknown formats are rejected
+	req = httptest.NewRequest("GET", "/api/audit/export?format=xml", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.ExportEvents(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Exports need an authenticated user
+	req = httptest.NewRequest("GET", "/api/audit/export", nil)
+	rr = httptest.NewRecorder()
+
+	handler.ExportEvents(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	mockAuditLog.AssertNumberOfCalls(t, "Query", 1)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/drift"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// BacktestDriftMonitor is the part of the drift monitor the handler uses
+type BacktestDriftMonitor interface {
+	Check(strategyID, userID string) (*drift.Report, error)
+	Reports(userID string) []drift.Report
+}
+
+// BacktestDriftHandler handles the API endpoints comparing live strategies with
+// the backtest they were promoted from
+type BacktestDriftHandler struct {
+	monitor BacktestDriftMonitor
+}
+
+// NewBacktestDriftHandler creates a new BacktestDriftHandler
+func NewBacktestDriftHandler(monitor BacktestDriftMonitor) *BacktestDriftHandler {
+	return &BacktestDriftHandler{
+		monitor: monitor,
+	}
+}
+
+// GetStrategyDrift handles comparing a live strategy with its backtest now,
+// backtesting it over the period it has been live
+func (h *BacktestDriftHandler) GetStrategyDrift(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Admins may check anyone's strategies
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = ""
+	}
+
+	report, err := h.monitor.Check(mux.Vars(r)["strategyId"], userID)
+	switch {
+	case err == nil:
+		utils.RespondWithJSON(w, http.StatusOK, report)
+	case errors.Is(err, drift.ErrStrategyNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, drift.ErrAccessDenied):
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+	case errors.Is(err, drift.ErrNotTracked):
+		utils.RespondWithError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// GetReports handles listing the latest drift reports of the caller's live
+// strategies, or for admins those of the userId query parameter, every strategy
+// when it is not given
+func (h *BacktestDriftHandler) GetReports(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = r.URL.Query().Get("userId")
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, h.monitor.Reports(userID))
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/drift"
+)
+
+// MockBacktestDriftMonitor is a mock implementation of the BacktestDriftMonitor interface
+type MockBacktestDriftMonitor struct {
+	mock.Mock
+}
+
+func (m *MockBacktestDriftMonitor) Check(strategyID, userID string) (*drift.Report, error) {
+	args := m.Called(strategyID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*drift.Report), args.Error(1)
+}
+
+func (m *MockBacktestDriftMonitor) Reports(userID string) []drift.Report {
+	args := m.Called(userID)
+	return args.Get(0).([]drift.Report)
+}
+
+func TestGetStrategyDrift(t *testing.T) {
+	// Create handler with mock monitor
+	mockMonitor := new(MockBacktestDriftMonitor)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/strategies/{strategyId}/backtest-drift", NewBacktestDriftHandler(mockMonitor).GetStrategyDrift).Methods("GET")
+
+	mockMonitor.On("Check", "live123", "user123").Return(&drift.Report{StrategyID: "live123", WinRateDelta: -30, Diverged: true}, nil)
+	mockMonitor.On("Check", "live123", "").Return(&drift.Report{StrategyID: "live123"}, nil)
+	mockMonitor.On("Check", "live123", "user456").Return(nil, drift.ErrAccessDenied)
+	mockMonitor.On("Check", "paper123", "user123").Return(nil, drift.ErrNotTracked)
+	mockMonitor.On("Check", "missing", "user123").Return(nil, drift.ErrStrategyNotFound)
+	mockMonitor.On("Check", "broken", "user123").Return(nil, errors.New("error backtesting"))
+
+	request := func(strategyID, userID string, role models.UserRole) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/strategies/"+strategyID+"/backtest-drift", nil)
+		req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), userID), string(role)))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request("live123", "user123", models.UserRoleTrader)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var report drift.Report
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, -30.0, report.WinRateDelta)
+	assert.True(t, report.Diverged)
+
+	// Admins check anyone's strategies
+	assert.Equal(t, http.StatusOK, request("live123", "admin1", models.UserRoleAdmin).Code)
+
+	assert.Equal(t, http.StatusForbidden, request("live123", "user456", models.UserRoleTrader).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, request("paper123", "user123", models.UserRoleTrader).Code)
+	assert.Equal(t, http.StatusNotFound, request("missing", "user123", models.UserRoleTrader).Code)
+	assert.Equal(t, http.StatusInternalServerError, request("broken", "user123", models.UserRoleTrader).Code)
+	assert.Equal(t, http.StatusUnauthorized, request("live123", "", models.UserRoleTrader).Code)
+
+	mockMonitor.AssertExpectations(t)
+}
+
+func TestGetDriftReports(t *testing.T) {
+	// Create handler with mock monitor
+	mockMonitor := new(MockBacktestDriftMonitor)
+	handler := NewBacktestDriftHandler(mockMonitor)
+
+	reports := []drift.Report{{StrategyID: "live123", UserID: "user123", SlippageDelta: 25}}
+
+	// Users only see their own strategies, whatever user they asked for
+	mockMonitor.On("Reports", "user123").Return(reports).Once()
+
+	req := httptest.NewRequest("GET", "/api/drift/reports?userId=user456", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetReports(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response []drift.Report
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Len(t, response, 1)
+	assert.Equal(t, 25.0, response[0].SlippageDelta)
+
+	// Admins see those of the user they ask for
+	mockMonitor.On("Reports", "user456").Return([]drift.Report{}).Once()
+
+	req = httptest.NewRequest("GET", "/api/drift/reports?userId=user456", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.GetReports(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]", rr.Body.String())
+
+	mockMonitor.AssertExpectations(t)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/brokersession"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// BrokerSessionMonitor is the part of the broker session monitor the handler uses
+type BrokerSessionMonitor interface {
+	Health(userID string) []brokersession.Health
+}
+
+// BrokerSessionHandler handles broker session health API endpoints
+type BrokerSessionHandler struct {
+	monitor BrokerSessionMonitor
+}
+
+// NewBrokerSessionHandler creates a new BrokerSessionHandler
+func NewBrokerSessionHandler(monitor BrokerSessionMonitor) *BrokerSessionHandler {
+	return &BrokerSessionHandler{
+		monitor: monitor,
+	}
+}
+
+// GetSessions handles listing the session health of the caller's broker accounts,
+// with the link to log in to each again, or for admins those of the userId query
+// parameter, every account when it is not given
+func (h *BrokerSessionHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = r.URL.Query().Get("userId")
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, h.monitor.Health(userID))
+}
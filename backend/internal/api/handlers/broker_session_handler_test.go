@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/brokersession"
+)
+
+// MockBrokerSessionMonitor is a mock implementation of the BrokerSessionMonitor interface
+type MockBrokerSessionMonitor struct {
+	mock.Mock
+}
+
+func (m *MockBrokerSessionMonitor) Health(userID string) []brokersession.Health {
+	args := m.Called(userID)
+	return args.Get(0).([]brokersession.Health)
+}
+
+func TestGetBrokerSessions(t *testing.T) {
+	// Create handler with mock monitor
+	mockMonitor := new(MockBrokerSessionMonitor)
+	handler := NewBrokerSessionHandler(mockMonitor)
+
+	sessions := []brokersession.Health{{ClientID: "client1", UserID: "user123", Status: brokersession.StatusExpiring, ExpiresIn: 600, ReauthURL: "/brokers/login?clientId=client1"}}
+
+	// Users only see their own accounts, whatever user they asked for
+	mockMonitor.On("Health", "user123").Return(sessions).Once()
+
+	req := httptest.NewRequest("GET", "/api/brokers/sessions?userId=user456", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetSessions(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response []brokersession.Health
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, sessions, response)
+
+	// Admins see every account without a userId
+	mockMonitor.On("Health", "").Return([]brokersession.Health{}).Once()
+
+	req = httptest.NewRequest("GET", "/api/brokers/sessions", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.GetSessions(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]", rr.Body.String())
+
+	// Unauthenticated requests are rejected
+	req = httptest.NewRequest("GET", "/api/brokers/sessions", nil)
+	rr = httptest.NewRecorder()
+
+	handler.GetSessions(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	mockMonitor.AssertExpectations(t)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/audit"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// CircuitBreaker is the part of the circuit breaker the handler uses
+type CircuitBreaker interface {
+	GetStatus(userID string) (*risk.BreakerStatus, error)
+	Reset(userID string, adminID string, reason string) (*risk.BreakerStatus, error)
+}
+
+// CircuitBreakerHandler handles circuit breaker API endpoints
+type CircuitBreakerHandler struct {
+	breaker     CircuitBreaker
+	auditLogger *audit.Logger
+}
+
+// NewCircuitBreakerHandler creates a new CircuitBreakerHandler
+func NewCircuitBreakerHandler(breaker CircuitBreaker) *CircuitBreakerHandler {
+	return &CircuitBreakerHandler{
+		breaker: breaker,
+	}
+}
+
+// SetAuditLogger records the resets made through the handler in the audit log
+func (h *CircuitBreakerHandler) SetAuditLogger(auditLogger *audit.Logger) {
+	h.auditLogger = auditLogger
+}
+
+// ResetRequest is an admin's reason for resetting a user's circuit breaker
+type ResetRequest struct {
+	Reason string `json:"reason"`
+}
+
+// GetStatus handles retrieving the state of a user's circuit breaker. Users may
+// only see their own, admins anyone's.
+func (h *CircuitBreakerHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	targetUserID := mux.Vars(r)["userId"]
+	if targetUserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	status, err := h.breaker.GetStatus(targetUserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, status)
+}
+
+// Reset handles an admin ending a user's cooling-off period early
+func (h *CircuitBreakerHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	// Parse request body
+	var request ResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	status, err := h.breaker.Reset(mux.Vars(r)["userId"], userID, request.Reason)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAdmin,
+		Action:       audit.ActionCircuitBreakerReset,
+		ResourceType: "user",
+		ResourceID:   mux.Vars(r)["userId"],
+		After:        audit.Snapshot(status),
+		Details:      request.Reason,
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, status)
+}
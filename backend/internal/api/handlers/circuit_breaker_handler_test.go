@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+)
+
+// MockCircuitBreaker is a mock implementation of the CircuitBreaker interface
+type MockCircuitBreaker struct {
+	mock.Mock
+}
+
+func (m *MockCircuitBreaker) GetStatus(userID string) (*risk.BreakerStatus, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.BreakerStatus), args.Error(1)
+}
+
+func (m *MockCircuitBreaker) Reset(userID string, adminID string, reason string) (*risk.BreakerStatus, error) {
+	args := m.Called(userID, adminID, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.BreakerStatus), args.Error(1)
+}
+
+func TestGetCircuitBreakerStatus(t *testing.T) {
+	// Create handler with mock breaker
+	mockBreaker := new(MockCircuitBreaker)
+	handler := NewCircuitBreakerHandler(mockBreaker)
+
+	mockBreaker.On("GetStatus", "user123").Return(&risk.BreakerStatus{UserID: "user123", Threshold: 5000, WindowLoss: 6000, Tripped: true, Reason: risk.TripReasonLossVelocity}, nil)
+
+	// A user may see their own breaker
+	req := httptest.NewRequest("GET", "/api/users/user123/circuit-breaker", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetStatus(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var status risk.BreakerStatus
+	err := json.Unmarshal(rr.Body.Bytes(), &status)
+	assert.NoError(t, err)
+	assert.True(t, status.Tripped)
+	assert.Equal(t, risk.TripReasonLossVelocity, status.Reason)
+
+	// But not anyone else's
+	req = httptest.NewRequest("GET", "/api/users/user456/circuit-breaker", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user456"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetStatus(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockBreaker.AssertNotCalled(t, "GetStatus", "user456")
+}
+
+func TestResetCircuitBreaker(t *testing.T) {
+	// Create handler with mock breaker
+	mockBreaker := new(MockCircuitBreaker)
+	handler := NewCircuitBreakerHandler(mockBreaker)
+
+	// Users may not reset their own breaker
+	reqBody := `{"reason":"strategy reviewed"}`
+	req := httptest.NewRequest("POST", "/api/users/user123/circuit-breaker/reset", strings.NewReader(reqBody))
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.Reset(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockBreaker.AssertNotCalled(t, "Reset", mock.Anything, mock.Anything, mock.Anything)
+
+	// The reset is recorded as the calling admin's
+	mockBreaker.On("Reset", "user123", "admin1", "strategy reviewed").Return(&risk.BreakerStatus{UserID: "user123"}, nil)
+
+	req = httptest.NewRequest("POST", "/api/users/user123/circuit-breaker/reset", strings.NewReader(reqBody))
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.Reset(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Breakers that have not tripped cannot be reset
+	mockBreaker.On("Reset", "user456", "admin1", "strategy reviewed").Return(nil, errors.New("circuit breaker has not tripped"))
+
+	req = httptest.NewRequest("POST", "/api/users/user456/circuit-breaker/reset", strings.NewReader(reqBody))
+	req = mux.SetURLVars(req, map[string]string{"userId": "user456"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.Reset(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockBreaker.AssertExpectations(t)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/audit"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// DailyLossMonitor is the part of the daily loss monitor the handler uses
+type DailyLossMonitor interface {
+	GetStatus(userID string) (*risk.LossStatus, error)
+	Override(userID string, override risk.Override) (*risk.LossStatus, error)
+}
+
+// DailyLossHandler handles max daily loss API endpoints
+type DailyLossHandler struct {
+	monitor     DailyLossMonitor
+	auditLogger *audit.Logger
+}
+
+// NewDailyLossHandler creates a new DailyLossHandler
+func NewDailyLossHandler(monitor DailyLossMonitor) *DailyLossHandler {
+	return &DailyLossHandler{
+		monitor: monitor,
+	}
+}
+
+// SetAuditLogger records the overrides made through the handler in the audit log
+func (h *DailyLossHandler) SetAuditLogger(auditLogger *audit.Logger) {
+	h.auditLogger = auditLogger
+}
+
+// GetStatus handles retrieving where a user stands against the max daily loss.
+// Users may only see their own, admins anyone's.
+func (h *DailyLossHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	targetUserID := mux.Vars(r)["userId"]
+	if targetUserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	status, err := h.monitor.GetStatus(targetUserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, status)
+}
+
+// Override handles an admin's override of a user's max daily loss for the rest of
+// the day, raising the limit or lifting the block
+func (h *DailyLossHandler) Override(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	// Parse request body
+	var override risk.Override
+	if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	// The override is always recorded as the admin's own
+	override.AdminID = userID
+
+	status, err := h.monitor.Override(mux.Vars(r)["userId"], override)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAdmin,
+		Action:       audit.ActionDailyLossOverride,
+		ResourceType: "user",
+		ResourceID:   mux.Vars(r)["userId"],
+		After:        audit.Snapshot(status),
+		Details:      override.Reason,
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+)
+
+// MockDailyLossMonitor is a mock implementation of the DailyLossMonitor interface
+type MockDailyLossMonitor struct {
+	mock.Mock
+}
+
+func (m *MockDailyLossMonitor) GetStatus(userID string) (*risk.LossStatus, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.LossStatus), args.Error(1)
+}
+
+func (m *MockDailyLossMonitor) Override(userID string, override risk.Override) (*risk.LossStatus, error) {
+	args := m.Called(userID, override)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.LossStatus), args.Error(1)
+}
+
+func TestGetDailyLossStatus(t *testing.T) {
+	// Create handler with mock monitor
+	mockMonitor := new(MockDailyLossMonitor)
+	handler := NewDailyLossHandler(mockMonitor)
+
+	mockMonitor.On("GetStatus", "user123").Return(&risk.LossStatus{UserID: "user123", PnL: -12000, Limit: 10000, Breached: true, EntriesBlocked: true}, nil)
+
+	// A user may see their own status
+	req := httptest.NewRequest("GET", "/api/users/user123/daily-loss", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetStatus(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var status risk.LossStatus
+	err := json.Unmarshal(rr.Body.Bytes(), &status)
+	assert.NoError(t, err)
+	assert.True(t, status.EntriesBlocked)
+
+	// But not anyone else's
+	req = httptest.NewRequest("GET", "/api/users/user456/daily-loss", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user456"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetStatus(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockMonitor.AssertNotCalled(t, "GetStatus", "user456")
+
+	// Admins may see any user's
+	req = httptest.NewRequest("GET", "/api/users/user123/daily-loss", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.GetStatus(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestOverrideDailyLoss(t *testing.T) {
+	// Create handler with mock monitor
+	mockMonitor := new(MockDailyLossMonitor)
+	handler := NewDailyLossHandler(mockMonitor)
+
+	// Users may not override their own limit
+	reqBody := `{"adminId":"user123","limit":20000,"reason":"hedged book"}`
+	req := httptest.NewRequest("POST", "/api/users/user123/daily-loss/override", strings.NewReader(reqBody))
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.Override(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockMonitor.AssertNotCalled(t, "Override", mock.Anything, mock.Anything)
+
+	// The override is recorded as the calling admin's
+	expected := risk.Override{AdminID: "admin1", Limit: 20000, Reason: "hedged book"}
+	mockMonitor.On("Override", "user123", expected).Return(&risk.LossStatus{UserID: "user123", Limit: 20000, Override: &expected}, nil)
+
+	req = httptest.NewRequest("POST", "/api/users/user123/daily-loss/override", strings.NewReader(reqBody))
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.Override(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var status risk.LossStatus
+	err := json.Unmarshal(rr.Body.Bytes(), &status)
+	assert.NoError(t, err)
+	assert.Equal(t, 20000.0, status.Limit)
+
+	mockMonitor.AssertExpectations(t)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/exports"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// ExportService is the part of the export service the handler uses
+type ExportService interface {
+	Background(request exports.Request) (bool, error)
+	Export(w io.Writer, request exports.Request) (int, error)
+	Start(request exports.Request) (*exports.Job, error)
+	Jobs(userID string) []exports.Job
+	Job(id string) (*exports.Job, error)
+	Open(id string) (io.ReadCloser, *exports.Job, error)
+}
+
+// ExportHandler handles CSV and XLSX export API endpoints
+type ExportHandler struct {
+	service ExportService
+}
+
+// NewExportHandler creates a new ExportHandler
+func NewExportHandler(service ExportService) *ExportHandler {
+	return &ExportHandler{
+		service: service,
+	}
+}
+
+// Export handles exporting the caller's orders, trades or positions, filtered by
+// the symbol, status, direction, portfolioId, strategyId, from and to query
+// parameters, as CSV or, with the format query parameter, XLSX. Exports are
+// streamed as they are read unless they are too large, or the background query
+// parameter is true, when they are generated in the background and the job is
+// returned to poll or wait to be notified of. Admins may give the userId query
+// parameter.
+func (h *ExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	if other := query.Get("userId"); other != "" && auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = other
+	}
+
+	request := exports.Request{
+		UserID:  userID,
+		Dataset: exports.Dataset(mux.Vars(r)["dataset"]),
+		Format:  exports.Format(query.Get("format")),
+		Filter: exports.Filter{
+			Symbol:      query.Get("symbol"),
+			Status:      query.Get("status"),
+			Direction:   query.Get("direction"),
+			PortfolioID: query.Get("portfolioId"),
+			StrategyID:  query.Get("strategyId"),
+			From:        query.Get("from"),
+			To:          query.Get("to"),
+		},
+	}
+	if request.Format == "" {
+		request.Format = exports.FormatCSV
+	}
+
+	background := query.Get("background") == "true"
+	if !background {
+		large, err := h.service.Background(request)
+		if err != nil {
+			respondWithExportError(w, err, "Error preparing export")
+			return
+		}
+		background = large
+	}
+
+	if background {
+		job, err := h.service.Start(request)
+		if err != nil {
+			respondWithExportError(w, err, "Error starting export")
+			return
+		}
+		utils.RespondWithJSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	w.Header().Set("Content-Type", request.Format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+request.FileName()+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	// The response has started, so errors can only cut it short
+	if _, err := h.service.Export(w, request); err != nil {
+		log.Printf("Error streaming %s export of user %s: %v", request.Dataset, userID, err)
+	}
+}
+
+// GetJobs handles listing the caller's background exports, newest first
+func (h *ExportHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, h.service.Jobs(userID))
+}
+
+// GetJob handles retrieving a background export of the caller
+func (h *ExportHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.ownJob(w, r)
+	if !ok {
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, job)
+}
+
+// DownloadJob handles downloading the file of a completed background export of
+// the caller
+func (h *ExportHandler) DownloadJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.ownJob(w, r)
+	if !ok {
+		return
+	}
+
+	file, _, err := h.service.Open(job.ID)
+	if err != nil {
+		respondWithExportError(w, err, "Error opening export")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", job.Format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+job.FileName+`"`)
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
+
+// ownJob returns the background export of the jobId path variable, responding
+// with an error unless it is the caller's or the caller is an admin
+func (h *ExportHandler) ownJob(w http.ResponseWriter, r *http.Request) (*exports.Job, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	job, err := h.service.Job(mux.Vars(r)["jobId"])
+	if err != nil {
+		respondWithExportError(w, err, "Error retrieving export")
+		return nil, false
+	}
+	if job.UserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+
+	return job, true
+}
+
+// respondWithExportError responds with the status of an export service error
+func respondWithExportError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, exports.ErrInvalidDataset), errors.Is(err, exports.ErrInvalidFormat),
+		errors.Is(err, exports.ErrInvalidDate), errors.Is(err, exports.ErrInvalidPeriod):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, exports.ErrJobNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, exports.ErrJobNotReady):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, message)
+	}
+}
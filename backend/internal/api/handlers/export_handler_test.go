@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/exports"
+)
+
+// MockExportService is a mock implementation of the ExportService interface
+type MockExportService struct {
+	mock.Mock
+}
+
+func (m *MockExportService) Background(request exports.Request) (bool, error) {
+	args := m.Called(request)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockExportService) Export(w io.Writer, request exports.Request) (int, error) {
+	args := m.Called(w, request)
+	io.WriteString(w, args.String(0))
+	return args.Int(1), args.Error(2)
+}
+
+func (m *MockExportService) Start(request exports.Request) (*exports.Job, error) {
+	args := m.Called(request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*exports.Job), args.Error(1)
+}
+
+func (m *MockExportService) Jobs(userID string) []exports.Job {
+	args := m.Called(userID)
+	return args.Get(0).([]exports.Job)
+}
+
+func (m *MockExportService) Job(id string) (*exports.Job, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*exports.Job), args.Error(1)
+}
+
+func (m *MockExportService) Open(id string) (io.ReadCloser, *exports.Job, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(io.ReadCloser), args.Get(1).(*exports.Job), args.Error(2)
+}
+
+// exportRouter routes the export endpoints
+func exportRouter(handler *ExportHandler) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/exports/jobs", handler.GetJobs).Methods("GET")
+	router.HandleFunc("/api/exports/jobs/{jobId}", handler.GetJob).Methods("GET")
+	router.HandleFunc("/api/exports/jobs/{jobId}/download", handler.DownloadJob).Methods("GET")
+	router.HandleFunc("/api/exports/{dataset}", handler.Export).Methods("GET")
+	return router
+}
+
+// exportRequest creates a request of a user with a role
+func exportRequest(target, userID string, role models.UserRole) *http.Request {
+	req := httptest.NewRequest("GET", target, nil)
+	return req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), userID), string(role)))
+}
+
+func TestExportStreams(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockExportService)
+	router := exportRouter(NewExportHandler(mockService))
+
+	// Users only export their own rows, whatever user they asked for
+	request := exports.Request{
+		UserID:  "user123",
+		Dataset: exports.DatasetOrders,
+		Format:  exports.FormatCSV,
+		Filter:  exports.Filter{Symbol: "NIFTY", Status: "EXECUTED", From: "2024-03-01", To: "2024-03-31"},
+	}
+	mockService.On("Background", request).Return(false, nil)
+	mockService.On("Export", mock.Anything, request).Return("Order ID\norder1\n", 1, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, exportRequest("/api/exports/orders?symbol=NIFTY&status=EXECUTED&from=2024-03-01&to=2024-03-31&userId=user456", "user123", models.UserRoleTrader))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="orders-from-2024-03-01-to-2024-03-31.csv"`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "Order ID\norder1\n", rr.Body.String())
+
+	// Invalid requests are refused before anything is streamed
+	invalid := exports.Request{UserID: "user123", Dataset: exports.DatasetTrades, Format: "pdf"}
+	mockService.On("Background", invalid).Return(false, exports.ErrInvalidFormat)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, exportRequest("/api/exports/trades?format=pdf", "user123", models.UserRoleTrader))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestExportInBackground(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockExportService)
+	router := exportRouter(NewExportHandler(mockService))
+
+	// Exports too large to stream are generated in the background
+	large := exports.Request{UserID: "user456", Dataset: exports.DatasetPositions, Format: exports.FormatXLSX}
+	mockService.On("Background", large).Return(true, nil)
+	mockService.On("Start", large).Return(&exports.Job{ID: "job1", UserID: "user456", Status: exports.JobStatusRunning}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, exportRequest("/api/exports/positions?format=xlsx&userId=user456", "admin1", models.UserRoleAdmin))
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	var job exports.Job
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+	assert.Equal(t, "job1", job.ID)
+
+	// As are exports asked to be
+	requested := exports.Request{UserID: "user123", Dataset: exports.DatasetTrades, Format: exports.FormatCSV}
+	mockService.On("Start", requested).Return(&exports.Job{ID: "job2", UserID: "user123"}, nil)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, exportRequest("/api/exports/trades?background=true", "user123", models.UserRoleTrader))
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	mockService.AssertExpectations(t)
+	mockService.AssertNotCalled(t, "Background", requested)
+}
+
+func TestExportJobs(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockExportService)
+	router := exportRouter(NewExportHandler(mockService))
+
+	completed := &exports.Job{ID: "job1", UserID: "user123", Format: exports.FormatCSV, FileName: "orders.csv", Status: exports.JobStatusCompleted}
+	mockService.On("Jobs", "user123").Return([]exports.Job{*completed})
+	mockService.On("Job", "job1").Return(completed, nil)
+	mockService.On("Job", "job2").Return(&exports.Job{ID: "job2", UserID: "user123", Status: exports.JobStatusRunning}, nil)
+	mockService.On("Job", "missing").Return(nil, exports.ErrJobNotFound)
+	mockService.On("Open", "job1").Return(io.NopCloser(strings.NewReader("Order ID\n")), completed, nil)
+	mockService.On("Open", "job2").Return(nil, nil, exports.ErrJobNotReady)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, exportRequest("/api/exports/jobs", "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var jobs []exports.Job
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &jobs))
+	assert.Len(t, jobs, 1)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, exportRequest("/api/exports/jobs/job1", "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, exportRequest("/api/exports/jobs/missing", "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Other users' exports are refused
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, exportRequest("/api/exports/jobs/job1/download", "user456", models.UserRoleTrader))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, exportRequest("/api/exports/jobs/job1/download", "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `attachment; filename="orders.csv"`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "Order ID\n", rr.Body.String())
+
+	// Running exports are not ready to download
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, exportRequest("/api/exports/jobs/job2/download", "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestExportUnauthorized(t *testing.T) {
+	mockService := new(MockExportService)
+	router := exportRouter(NewExportHandler(mockService))
+
+	for _, target := range []string{"/api/exports/orders", "/api/exports/jobs", "/api/exports/jobs/job1/download"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, target)
+	}
+
+	mockService.AssertNotCalled(t, "Job", mock.Anything)
+}
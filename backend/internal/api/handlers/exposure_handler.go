@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// ExposureReporter is the part of the exposure reporter the handler uses
+type ExposureReporter interface {
+	GetUserExposure(userID string) (*risk.UserExposure, error)
+	GetAccountExposure(userID, accountID string) (*risk.AccountExposure, error)
+}
+
+// ExposureHandler handles account exposure API endpoints
+type ExposureHandler struct {
+	reporter ExposureReporter
+}
+
+// NewExposureHandler creates a new ExposureHandler
+func NewExposureHandler(reporter ExposureReporter) *ExposureHandler {
+	return &ExposureHandler{
+		reporter: reporter,
+	}
+}
+
+// GetUserExposure handles retrieving the exposure of a user and their broker
+// accounts. Users may only see their own, admins anyone's.
+func (h *ExposureHandler) GetUserExposure(w http.ResponseWriter, r *http.Request) {
+	targetUserID, ok := exposureUser(w, r)
+	if !ok {
+		return
+	}
+
+	exposure, err := h.reporter.GetUserExposure(targetUserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, exposure)
+}
+
+// GetAccountExposure handles retrieving the exposure of one of a user's broker
+// accounts
+func (h *ExposureHandler) GetAccountExposure(w http.ResponseWriter, r *http.Request) {
+	targetUserID, ok := exposureUser(w, r)
+	if !ok {
+		return
+	}
+
+	exposure, err := h.reporter.GetAccountExposure(targetUserID, mux.Vars(r)["accountId"])
+	if err != nil {
+		if err == risk.ErrAccountNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, exposure)
+}
+
+// exposureUser returns the user of the path whose exposure and funds the caller
+// may see
+func exposureUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", false
+	}
+
+	targetUserID := mux.Vars(r)["userId"]
+	if targetUserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return "", false
+	}
+
+	return targetUserID, true
+}
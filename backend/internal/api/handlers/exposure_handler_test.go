@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+)
+
+// MockExposureReporter is a mock implementation of the ExposureReporter interface
+type MockExposureReporter struct {
+	mock.Mock
+}
+
+func (m *MockExposureReporter) GetUserExposure(userID string) (*risk.UserExposure, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.UserExposure), args.Error(1)
+}
+
+func (m *MockExposureReporter) GetAccountExposure(userID, accountID string) (*risk.AccountExposure, error) {
+	args := m.Called(userID, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.AccountExposure), args.Error(1)
+}
+
+func TestGetUserExposure(t *testing.T) {
+	// Create handler with mock reporter
+	mockReporter := new(MockExposureReporter)
+	handler := NewExposureHandler(mockReporter)
+
+	mockReporter.On("GetUserExposure", "user123").Return(&risk.UserExposure{UserID: "user123", Exposure: risk.Exposure{GrossExposure: 14300, Leverage: 0.286}}, nil)
+
+	// A user may see their own exposure
+	req := httptest.NewRequest("GET", "/api/users/user123/exposure", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetUserExposure(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response map[string]interface{}
+	err := json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 14300.0, response["grossExposure"])
+
+	// But not anyone else's
+	req = httptest.NewRequest("GET", "/api/users/user456/exposure", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user456"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetUserExposure(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockReporter.AssertNotCalled(t, "GetUserExposure", "user456")
+}
+
+func TestGetAccountExposure(t *testing.T) {
+	// Create handler with mock reporter
+	mockReporter := new(MockExposureReporter)
+	handler := NewExposureHandler(mockReporter)
+
+	mockReporter.On("GetAccountExposure", "user123", "account1").Return(&risk.AccountExposure{AccountID: "account1", Exposure: risk.Exposure{NetExposure: 7700}}, nil)
+	mockReporter.On("GetAccountExposure", "user123", "account9").Return(nil, risk.ErrAccountNotFound)
+
+	// Admins may see anyone's accounts
+	req := httptest.NewRequest("GET", "/api/users/user123/exposure/accounts/account1", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123", "accountId": "account1"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr := httptest.NewRecorder()
+
+	handler.GetAccountExposure(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var account risk.AccountExposure
+	err := json.Unmarshal(rr.Body.Bytes(), &account)
+	assert.NoError(t, err)
+	assert.Equal(t, 7700.0, account.NetExposure)
+
+	req = httptest.NewRequest("GET", "/api/users/user123/exposure/accounts/account9", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123", "accountId": "account9"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetAccountExposure(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockReporter.AssertExpectations(t)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/trading-platform/backend/internal/services/margin"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// FundsSynchronizer is the part of the funds synchronizer the handler uses
+type FundsSynchronizer interface {
+	GetUserFunds(userID string) (*margin.UserFunds, error)
+	Sync(userID string) (*margin.UserFunds, error)
+	GetHistory(userID, accountID string, since time.Time) []margin.FundsSnapshot
+}
+
+// FundsHandler handles broker funds API endpoints
+type FundsHandler struct {
+	synchronizer FundsSynchronizer
+}
+
+// NewFundsHandler creates a new FundsHandler
+func NewFundsHandler(synchronizer FundsSynchronizer) *FundsHandler {
+	return &FundsHandler{
+		synchronizer: synchronizer,
+	}
+}
+
+// GetUserFunds handles retrieving a user's broker funds as last synchronized, or
+// synchronized now with refresh=true. Users may only see their own, admins anyone's.
+func (h *FundsHandler) GetUserFunds(w http.ResponseWriter, r *http.Request) {
+	targetUserID, ok := exposureUser(w, r)
+	if !ok {
+		return
+	}
+
+	var funds *margin.UserFunds
+	var err error
+	if r.URL.Query().Get("refresh") == "true" {
+		funds, err = h.synchronizer.Sync(targetUserID)
+	} else {
+		funds, err = h.synchronizer.GetUserFunds(targetUserID)
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, funds)
+}
+
+// GetFundsHistory handles retrieving the snapshots of a user's broker accounts, of
+// the accountId query parameter only when given, since the since query parameter
+// or over the last day
+func (h *FundsHandler) GetFundsHistory(w http.ResponseWriter, r *http.Request) {
+	targetUserID, ok := exposureUser(w, r)
+	if !ok {
+		return
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid since time")
+			return
+		}
+		since = parsed
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, h.synchronizer.GetHistory(targetUserID, r.URL.Query().Get("accountId"), since))
+}
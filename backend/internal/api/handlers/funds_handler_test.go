@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/margin"
+)
+
+// MockFundsSynchronizer is a mock implementation of the FundsSynchronizer interface
+type MockFundsSynchronizer struct {
+	mock.Mock
+}
+
+func (m *MockFundsSynchronizer) GetUserFunds(userID string) (*margin.UserFunds, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*margin.UserFunds), args.Error(1)
+}
+
+func (m *MockFundsSynchronizer) Sync(userID string) (*margin.UserFunds, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*margin.UserFunds), args.Error(1)
+}
+
+func (m *MockFundsSynchronizer) GetHistory(userID, accountID string, since time.Time) []margin.FundsSnapshot {
+	args := m.Called(userID, accountID, since)
+	return args.Get(0).([]margin.FundsSnapshot)
+}
+
+func TestGetUserFunds(t *testing.T) {
+	// Create handler with mock synchronizer
+	mockSynchronizer := new(MockFundsSynchronizer)
+	handler := NewFundsHandler(mockSynchronizer)
+
+	mockSynchronizer.On("GetUserFunds", "user123").Return(&margin.UserFunds{UserID: "user123", Funds: 300000}, nil)
+	mockSynchronizer.On("Sync", "user123").Return(&margin.UserFunds{UserID: "user123", Funds: 310000}, nil)
+
+	// A user may see their own funds, synchronized now when asked to
+	req := httptest.NewRequest("GET", "/api/users/user123/funds?refresh=true", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetUserFunds(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var funds margin.UserFunds
+	err := json.Unmarshal(rr.Body.Bytes(), &funds)
+	assert.NoError(t, err)
+	assert.Equal(t, 310000.0, funds.Funds)
+	mockSynchronizer.AssertNotCalled(t, "GetUserFunds", "user123")
+
+	// But not anyone else's
+	req = httptest.NewRequest("GET", "/api/users/user456/funds", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user456"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetUserFunds(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestGetFundsHistory(t *testing.T) {
+	// Create handler with mock synchronizer
+	mockSynchronizer := new(MockFundsSynchronizer)
+	handler := NewFundsHandler(mockSynchronizer)
+
+	since := time.Date(2024, 6, 3, 9, 15, 0, 0, time.UTC)
+	mockSynchronizer.On("GetHistory", "user123", "account1", since).Return([]margin.FundsSnapshot{{AccountID: "account1", Utilized: 60000}, {AccountID: "account1", Utilized: 95000}})
+
+	// Admins may see anyone's history
+	req := httptest.NewRequest("GET", "/api/users/user123/funds/history?accountId=account1&since=2024-06-03T09:15:00Z", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr := httptest.NewRecorder()
+
+	handler.GetFundsHistory(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var snapshots []margin.FundsSnapshot
+	err := json.Unmarshal(rr.Body.Bytes(), &snapshots)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+
+	req = httptest.NewRequest("GET", "/api/users/user123/funds/history?since=yesterday", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "user123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetFundsHistory(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
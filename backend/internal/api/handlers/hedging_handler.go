@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/audit"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/hedging"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// DeltaHedger is the part of the delta hedger the handler uses
+type DeltaHedger interface {
+	Enable(portfolioID string, settings hedging.Settings, userID string) (*hedging.Hedge, error)
+	Disable(portfolioID string, userID string) error
+	GetHedge(portfolioID string, userID string) (*hedging.Hedge, error)
+}
+
+// HedgingHandler handles portfolio delta hedging API endpoints
+type HedgingHandler struct {
+	hedger      DeltaHedger
+	auditLogger *audit.Logger
+}
+
+// NewHedgingHandler creates a new HedgingHandler
+func NewHedgingHandler(hedger DeltaHedger) *HedgingHandler {
+	return &HedgingHandler{
+		hedger: hedger,
+	}
+}
+
+// SetAuditLogger records the portfolios hedging is started and stopped for made through the handler in the audit log
+func (h *HedgingHandler) SetAuditLogger(auditLogger *audit.Logger) {
+	h.auditLogger = auditLogger
+}
+
+// GetHedge handles retrieving the state of a portfolio's delta hedging
+func (h *HedgingHandler) GetHedge(w http.ResponseWriter, r *http.Request) {
+	userID, ok := hedgingUser(w, r)
+	if !ok {
+		return
+	}
+
+	hedge, err := h.hedger.GetHedge(mux.Vars(r)["portfolioId"], userID)
+	if err != nil {
+		respondWithHedgingError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, hedge)
+}
+
+// EnableHedging handles starting, or restarting, a portfolio's delta hedging
+func (h *HedgingHandler) EnableHedging(w http.ResponseWriter, r *http.Request) {
+	userID, ok := hedgingUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse request body
+	var settings hedging.Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	hedge, err := h.hedger.Enable(mux.Vars(r)["portfolioId"], settings, userID)
+	if err != nil {
+		respondWithHedgingError(w, err)
+		return
+	}
+
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryPortfolio,
+		Action:       audit.ActionHedgingEnabled,
+		ResourceType: "portfolio",
+		ResourceID:   mux.Vars(r)["portfolioId"],
+		After:        audit.Snapshot(hedge),
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, hedge)
+}
+
+// DisableHedging handles stopping a portfolio's delta hedging
+func (h *HedgingHandler) DisableHedging(w http.ResponseWriter, r *http.Request) {
+	userID, ok := hedgingUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.hedger.Disable(mux.Vars(r)["portfolioId"], userID); err != nil {
+		respondWithHedgingError(w, err)
+		return
+	}
+
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryPortfolio,
+		Action:       audit.ActionHedgingDisabled,
+		ResourceType: "portfolio",
+		ResourceID:   mux.Vars(r)["portfolioId"],
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Hedging disabled successfully"})
+}
+
+// hedgingUser returns the user whose portfolios may be hedged, empty for admins
+// who may hedge anyone's
+func hedgingUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", false
+	}
+
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		return "", true
+	}
+	return userID, true
+}
+
+// respondWithHedgingError responds with the status matching a hedging error
+func respondWithHedgingError(w http.ResponseWriter, err error) {
+	switch err {
+	case hedging.ErrPortfolioNotFound, hedging.ErrHedgeNotFound:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case hedging.ErrAccessDenied:
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/hedging"
+)
+
+// MockDeltaHedger is a mock implementation of the DeltaHedger interface
+type MockDeltaHedger struct {
+	mock.Mock
+}
+
+func (m *MockDeltaHedger) Enable(portfolioID string, settings hedging.Settings, userID string) (*hedging.Hedge, error) {
+	args := m.Called(portfolioID, settings, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*hedging.Hedge), args.Error(1)
+}
+
+func (m *MockDeltaHedger) Disable(portfolioID string, userID string) error {
+	args := m.Called(portfolioID, userID)
+	return args.Error(0)
+}
+
+func (m *MockDeltaHedger) GetHedge(portfolioID string, userID string) (*hedging.Hedge, error) {
+	args := m.Called(portfolioID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*hedging.Hedge), args.Error(1)
+}
+
+func TestEnableHedging(t *testing.T) {
+	// Create handler with mock hedger
+	mockHedger := new(MockDeltaHedger)
+	handler := NewHedgingHandler(mockHedger)
+
+	settings := hedging.Settings{Instrument: hedging.InstrumentOption, Band: 25}
+	mockHedger.On("Enable", "portfolio123", settings, "user123").Return(&hedging.Hedge{PortfolioID: "portfolio123", UserID: "user123", Settings: settings, Status: hedging.HedgeStatusActive}, nil)
+	mockHedger.On("Enable", "portfolio456", settings, "user123").Return(nil, hedging.ErrAccessDenied)
+
+	reqBody := `{"instrument":"OPTION","band":25}`
+	req := httptest.NewRequest("POST", "/api/portfolios/portfolio123/hedging", strings.NewReader(reqBody))
+	req = mux.SetURLVars(req, map[string]string{"portfolioId": "portfolio123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.EnableHedging(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var hedge hedging.Hedge
+	err := json.Unmarshal(rr.Body.Bytes(), &hedge)
+	assert.NoError(t, err)
+	assert.Equal(t, hedging.HedgeStatusActive, hedge.Status)
+
+	// Another user's portfolio cannot be hedged
+	req = httptest.NewRequest("POST", "/api/portfolios/portfolio456/hedging", strings.NewReader(reqBody))
+	req = mux.SetURLVars(req, map[string]string{"portfolioId": "portfolio456"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.EnableHedging(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockHedger.AssertExpectations(t)
+}
+
+func TestGetAndDisableHedging(t *testing.T) {
+	// Create handler with mock hedger
+	mockHedger := new(MockDeltaHedger)
+	handler := NewHedgingHandler(mockHedger)
+
+	// Admins may see any portfolio's hedging
+	mockHedger.On("GetHedge", "portfolio123", "").Return(&hedging.Hedge{PortfolioID: "portfolio123", UserID: "user123", Delta: 40}, nil)
+
+	req := httptest.NewRequest("GET", "/api/portfolios/portfolio123/hedging", nil)
+	req = mux.SetURLVars(req, map[string]string{"portfolioId": "portfolio123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr := httptest.NewRecorder()
+
+	handler.GetHedge(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var hedge hedging.Hedge
+	err := json.Unmarshal(rr.Body.Bytes(), &hedge)
+	assert.NoError(t, err)
+	assert.Equal(t, 40.0, hedge.Delta)
+
+	// Portfolios that are not hedged are not found
+	mockHedger.On("Disable", "portfolio456", "user123").Return(hedging.ErrHedgeNotFound)
+	mockHedger.On("Disable", "portfolio123", "user123").Return(nil)
+
+	req = httptest.NewRequest("DELETE", "/api/portfolios/portfolio456/hedging", nil)
+	req = mux.SetURLVars(req, map[string]string{"portfolioId": "portfolio456"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.DisableHedging(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	req = httptest.NewRequest("DELETE", "/api/portfolios/portfolio123/hedging", nil)
+	req = mux.SetURLVars(req, map[string]string{"portfolioId": "portfolio123"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.DisableHedging(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockHedger.AssertExpectations(t)
+}
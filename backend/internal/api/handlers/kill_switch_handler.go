@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/killswitch"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// KillSwitchHandler handles kill switch API endpoints
+type KillSwitchHandler struct {
+	killSwitchService killswitch.KillSwitchService
+}
+
+// NewKillSwitchHandler creates a new KillSwitchHandler
+func NewKillSwitchHandler(killSwitchService killswitch.KillSwitchService) *KillSwitchHandler {
+	return &KillSwitchHandler{
+		killSwitchService: killSwitchService,
+	}
+}
+
+// RequestKill handles a request to kill a user's, a strategy's or the platform's
+// trading, returning the token that confirms it
+func (h *KillSwitchHandler) RequestKill(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	isAdmin := auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin)
+
+	// Parse request body
+	var request killswitch.KillRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Only admins may kill the platform or another user's trading, everyone else's
+	// kill is limited to their own orders and positions
+	if !isAdmin {
+		if request.Scope == killswitch.ScopePlatform || (request.UserID != "" && request.UserID != userID) {
+			utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+			return
+		}
+		request.UserID = userID
+	}
+
+	// Request kill
+	confirmation, err := h.killSwitchService.RequestKill(request, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, confirmation)
+}
+
+// ConfirmKill handles the confirmation of a requested kill, which then cancels the
+// open orders and flattens the positions
+func (h *KillSwitchHandler) ConfirmKill(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body for the confirmation token
+	var confirmRequest struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&confirmRequest); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Confirm kill
+	result, err := h.killSwitchService.ConfirmKill(confirmRequest.Token, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, result)
+}
+
+// GetAuditLog handles retrieving the kill switch audit log, admins seeing every
+// entry and users those concerning them
+func (h *KillSwitchHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	filterUserID := userID
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		filterUserID = r.URL.Query().Get("userId")
+	}
+
+	// Get audit log
+	entries, err := h.killSwitchService.GetAuditLog(filterUserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving audit log")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, entries)
+}
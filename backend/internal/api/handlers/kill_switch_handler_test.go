@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/killswitch"
+)
+
+// MockKillSwitchService is a mock implementation of the KillSwitchService interface
+type MockKillSwitchService struct {
+	mock.Mock
+}
+
+func (m *MockKillSwitchService) RequestKill(request killswitch.KillRequest, requestedBy string) (*killswitch.Confirmation, error) {
+	args := m.Called(request, requestedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*killswitch.Confirmation), args.Error(1)
+}
+
+func (m *MockKillSwitchService) ConfirmKill(token string, confirmedBy string) (*killswitch.KillResult, error) {
+	args := m.Called(token, confirmedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*killswitch.KillResult), args.Error(1)
+}
+
+func (m *MockKillSwitchService) Kill(request killswitch.KillRequest, triggeredBy string) (*killswitch.KillResult, error) {
+	args := m.Called(request, triggeredBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*killswitch.KillResult), args.Error(1)
+}
+
+func (m *MockKillSwitchService) GetAuditLog(userID string) ([]killswitch.AuditEntry, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]killswitch.AuditEntry), args.Error(1)
+}
+
+func TestRequestKill(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockKillSwitchService)
+	handler := NewKillSwitchHandler(mockService)
+
+	// A user's kill is limited to their own orders
+	expected := killswitch.KillRequest{
+		Scope:            killswitch.ScopeStrategy,
+		UserID:           "user123",
+		StrategyID:       "strategy123",
+		FlattenPositions: true,
+		Reason:           "runaway strategy",
+	}
+	mockService.On("RequestKill", expected, "user123").Return(&killswitch.Confirmation{Token: "token123", Request: expected}, nil)
+
+	reqBody := `{"scope":"STRATEGY","strategyId":"strategy123","flattenPositions":true,"reason":"runaway strategy"}`
+	req := httptest.NewRequest("POST", "/api/kill-switch", strings.NewReader(reqBody))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.RequestKill(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var confirmation killswitch.Confirmation
+	err := json.Unmarshal(rr.Body.Bytes(), &confirmation)
+	assert.NoError(t, err)
+	assert.Equal(t, "token123", confirmation.Token)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRequestKillForbidden(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockKillSwitchService)
+	handler := NewKillSwitchHandler(mockService)
+
+	for _, reqBody := range []string{
+		`{"scope":"PLATFORM","reason":"exchange outage"}`,
+		`{"scope":"USER","userId":"user456","reason":"runaway strategy"}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/kill-switch", strings.NewReader(reqBody))
+		req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+		rr := httptest.NewRecorder()
+
+		handler.RequestKill(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	}
+
+	// Admins may kill the platform
+	mockService.On("RequestKill", mock.Anything, "admin1").Return(&killswitch.Confirmation{Token: "token456"}, nil)
+	req := httptest.NewRequest("POST", "/api/kill-switch", strings.NewReader(`{"scope":"PLATFORM","reason":"exchange outage"}`))
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr := httptest.NewRecorder()
+
+	handler.RequestKill(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	mockService.AssertNotCalled(t, "RequestKill", mock.Anything, "user123")
+}
+
+func TestConfirmKill(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockKillSwitchService)
+	handler := NewKillSwitchHandler(mockService)
+
+	mockService.On("ConfirmKill", "token123", "user123").Return(&killswitch.KillResult{CancelledOrders: []string{"order1"}}, nil)
+
+	req := httptest.NewRequest("POST", "/api/kill-switch/confirm", strings.NewReader(`{"token":"token123"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.ConfirmKill(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var result killswitch.KillResult
+	err := json.Unmarshal(rr.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"order1"}, result.CancelledOrders)
+
+	mockService.AssertExpectations(t)
+}
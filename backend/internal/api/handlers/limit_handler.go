@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/audit"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// LimitMonitor is the part of the position limit monitor the handler uses
+type LimitMonitor interface {
+	CreateLimit(limit *risk.Limit) (*risk.Limit, error)
+	GetLimit(id string) (*risk.Limit, error)
+	GetLimits(userID string) []risk.Limit
+	UpdateLimit(limit *risk.Limit) (*risk.Limit, error)
+	DeleteLimit(id string) error
+	GetBreaches() []risk.Breach
+}
+
+// LimitHandler handles position and notional limit API endpoints
+type LimitHandler struct {
+	monitor     LimitMonitor
+	auditLogger *audit.Logger
+}
+
+// NewLimitHandler creates a new LimitHandler
+func NewLimitHandler(monitor LimitMonitor) *LimitHandler {
+	return &LimitHandler{
+		monitor: monitor,
+	}
+}
+
+// SetAuditLogger records the changes to limits made through the handler in the audit log
+func (h *LimitHandler) SetAuditLogger(auditLogger *audit.Logger) {
+	h.auditLogger = auditLogger
+}
+
+// GetLimits handles listing limits. Users see the limits applying to them, admins
+// all limits or those of the userId query parameter.
+func (h *LimitHandler) GetLimits(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = r.URL.Query().Get("userId")
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, h.monitor.GetLimits(userID))
+}
+
+// GetLimit handles retrieving a limit by ID
+func (h *LimitHandler) GetLimit(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, err := h.monitor.GetLimit(mux.Vars(r)["limitId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Limit not found")
+		return
+	}
+	if limit.UserID != "" && limit.UserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, limit)
+}
+
+// CreateLimit handles an admin adding a limit
+func (h *LimitHandler) CreateLimit(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse request body
+	var limit risk.Limit
+	if err := json.NewDecoder(r.Body).Decode(&limit); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	limit.CreatedBy = userID
+
+	created, err := h.monitor.CreateLimit(&limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAdmin,
+		Action:       audit.ActionLimitCreated,
+		ResourceType: "limit",
+		ResourceID:   created.ID,
+		After:        audit.Snapshot(created),
+	})
+
+	utils.RespondWithJSON(w, http.StatusCreated, created)
+}
+
+// UpdateLimit handles an admin changing a limit
+func (h *LimitHandler) UpdateLimit(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return
+	}
+
+	// Parse request body
+	var limit risk.Limit
+	if err := json.NewDecoder(r.Body).Decode(&limit); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	limit.ID = mux.Vars(r)["limitId"]
+
+	// Keep the limit as it was for the audit log
+	var before *risk.Limit
+	if h.auditLogger != nil {
+		before, _ = h.monitor.GetLimit(limit.ID)
+	}
+
+	updated, err := h.monitor.UpdateLimit(&limit)
+	if errors.Is(err, risk.ErrLimitNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, "Limit not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAdmin,
+		Action:       audit.ActionLimitUpdated,
+		ResourceType: "limit",
+		ResourceID:   updated.ID,
+		Before:       audit.Snapshot(before),
+		After:        audit.Snapshot(updated),
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, updated)
+}
+
+// DeleteLimit handles an admin removing a limit
+func (h *LimitHandler) DeleteLimit(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return
+	}
+
+	id := mux.Vars(r)["limitId"]
+
+	// Keep the limit as it was for the audit log
+	var before *risk.Limit
+	if h.auditLogger != nil {
+		before, _ = h.monitor.GetLimit(id)
+	}
+
+	if err := h.monitor.DeleteLimit(id); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Limit not found")
+		return
+	}
+
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAdmin,
+		Action:       audit.ActionLimitDeleted,
+		ResourceType: "limit",
+		ResourceID:   id,
+		Before:       audit.Snapshot(before),
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Limit deleted successfully"})
+}
+
+// GetBreaches handles listing the limits open positions were found past by the
+// last run of the monitor
+func (h *LimitHandler) GetBreaches(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, h.monitor.GetBreaches())
+}
+
+// requireAdmin returns the ID of the calling admin, responding with an error when
+// the caller is not one
+func (h *LimitHandler) requireAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", false
+	}
+	if auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return "", false
+	}
+
+	return userID, true
+}
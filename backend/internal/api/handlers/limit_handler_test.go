@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/audit"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+)
+
+// MockLimitMonitor is a mock implementation of the LimitMonitor interface
+type MockLimitMonitor struct {
+	mock.Mock
+}
+
+func (m *MockLimitMonitor) CreateLimit(limit *risk.Limit) (*risk.Limit, error) {
+	args := m.Called(limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.Limit), args.Error(1)
+}
+
+func (m *MockLimitMonitor) GetLimit(id string) (*risk.Limit, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.Limit), args.Error(1)
+}
+
+func (m *MockLimitMonitor) GetLimits(userID string) []risk.Limit {
+	args := m.Called(userID)
+	return args.Get(0).([]risk.Limit)
+}
+
+func (m *MockLimitMonitor) UpdateLimit(limit *risk.Limit) (*risk.Limit, error) {
+	args := m.Called(limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.Limit), args.Error(1)
+}
+
+func (m *MockLimitMonitor) DeleteLimit(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockLimitMonitor) GetBreaches() []risk.Breach {
+	args := m.Called()
+	return args.Get(0).([]risk.Breach)
+}
+
+func TestCreateLimit(t *testing.T) {
+	// Create handler with mock monitor
+	mockMonitor := new(MockLimitMonitor)
+	handler := NewLimitHandler(mockMonitor)
+
+	reqBody := `{"type":"MAX_LOTS","symbol":"NIFTY","lotSize":50,"value":10}`
+
+	// Users may not add limits
+	req := httptest.NewRequest("POST", "/api/risk/limits", strings.NewReader(reqBody))
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.CreateLimit(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockMonitor.AssertNotCalled(t, "CreateLimit", mock.Anything)
+
+	// The limit is recorded as created by the calling admin
+	mockMonitor.On("CreateLimit", mock.MatchedBy(func(limit *risk.Limit) bool {
+		return limit.Type == risk.LimitTypeMaxLots && limit.Symbol == "NIFTY" && limit.CreatedBy == "admin1"
+	})).Return(&risk.Limit{ID: "limit1", Type: risk.LimitTypeMaxLots, Symbol: "NIFTY", LotSize: 50, Value: 10, CreatedBy: "admin1"}, nil)
+
+	req = httptest.NewRequest("POST", "/api/risk/limits", strings.NewReader(reqBody))
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.CreateLimit(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var limit risk.Limit
+	err := json.Unmarshal(rr.Body.Bytes(), &limit)
+	assert.NoError(t, err)
+	assert.Equal(t, "limit1", limit.ID)
+
+	mockMonitor.AssertExpectations(t)
+}
+
+func TestGetLimits(t *testing.T) {
+	// Create handler with mock monitor
+	mockMonitor := new(MockLimitMonitor)
+	handler := NewLimitHandler(mockMonitor)
+
+	mockMonitor.On("GetLimits", "user123").Return([]risk.Limit{{ID: "limit1", UserID: "user123"}, {ID: "limit2"}})
+	mockMonitor.On("GetLimits", "").Return([]risk.Limit{{ID: "limit1", UserID: "user123"}, {ID: "limit2"}, {ID: "limit3", UserID: "user456"}})
+	mockMonitor.On("GetLimit", "limit3").Return(&risk.Limit{ID: "limit3", UserID: "user456"}, nil)
+
+	// Users only see the limits applying to them, whatever they ask for
+	req := httptest.NewRequest("GET", "/api/risk/limits?userId=user456", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetLimits(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var limits []risk.Limit
+	err := json.Unmarshal(rr.Body.Bytes(), &limits)
+	assert.NoError(t, err)
+	assert.Len(t, limits, 2)
+
+	req = httptest.NewRequest("GET", "/api/risk/limits/limit3", nil)
+	req = mux.SetURLVars(req, map[string]string{"limitId": "limit3"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetLimit(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// Admins see all limits
+	req = httptest.NewRequest("GET", "/api/risk/limits", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.GetLimits(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	err = json.Unmarshal(rr.Body.Bytes(), &limits)
+	assert.NoError(t, err)
+	assert.Len(t, limits, 3)
+}
+
+func TestUpdateLimitNotFound(t *testing.T) {
+	// Create handler with mock monitor
+	mockMonitor := new(MockLimitMonitor)
+	handler := NewLimitHandler(mockMonitor)
+
+	mockMonitor.On("UpdateLimit", mock.MatchedBy(func(limit *risk.Limit) bool {
+		return limit.ID == "limit9"
+	})).Return(nil, risk.ErrLimitNotFound)
+
+	req := httptest.NewRequest("PUT", "/api/risk/limits/limit9", strings.NewReader(`{"type":"MAX_OPEN_POSITIONS","value":5}`))
+	req = mux.SetURLVars(req, map[string]string{"limitId": "limit9"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr := httptest.NewRecorder()
+
+	handler.UpdateLimit(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockMonitor.AssertExpectations(t)
+}
+
+func TestUpdateLimitAudited(t *testing.T) {
+	// Create handler with mock monitor, recording changes in the audit log
+	mockMonitor := new(MockLimitMonitor)
+	handler := NewLimitHandler(mockMonitor)
+	store := audit.NewMemoryStore()
+	handler.SetAuditLogger(audit.NewLogger(store))
+
+	before := &risk.Limit{ID: "limit1", Type: risk.LimitTypeMaxOpenPositions, Value: 5}
+	after := &risk.Limit{ID: "limit1", Type: risk.LimitTypeMaxOpenPositions, Value: 8}
+	mockMonitor.On("GetLimit", "limit1").Return(before, nil)
+	mockMonitor.On("UpdateLimit", mock.MatchedBy(func(limit *risk.Limit) bool {
+		return limit.ID == "limit1"
+	})).Return(after, nil)
+
+	req := httptest.NewRequest("PUT", "/api/risk/limits/limit1", strings.NewReader(`{"type":"MAX_OPEN_POSITIONS","value":8}`))
+	req = mux.SetURLVars(req, map[string]string{"limitId": "limit1"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr := httptest.NewRecorder()
+
+	handler.UpdateLimit(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// The change is audited with the limit before and after it
+	events, err := store.Query(audit.Filter{})
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, audit.CategoryAdmin, events[0].Category)
+		assert.Equal(t, audit.ActionLimitUpdated, events[0].Action)
+		assert.Equal(t, "admin1", events[0].Actor)
+		assert.Equal(t, "limit1", events[0].ResourceID)
+		assert.JSONEq(t, string(audit.Snapshot(before)), string(events[0].Before))
+		assert.JSONEq(t, string(audit.Snapshot(after)), string(events[0].After))
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/services/margin"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// MarginEstimator is the part of the margin estimator the handler uses
+type MarginEstimator interface {
+	Estimate(userID string, legs []margin.Leg) (*margin.Estimate, error)
+}
+
+// MarginHandler handles margin estimation API endpoints
+type MarginHandler struct {
+	estimator MarginEstimator
+}
+
+// NewMarginHandler creates a new MarginHandler
+func NewMarginHandler(estimator MarginEstimator) *MarginHandler {
+	return &MarginHandler{
+		estimator: estimator,
+	}
+}
+
+// EstimateRequest is a proposed order, or the legs of a multi-leg basket
+type EstimateRequest struct {
+	Order *margin.Leg  `json:"order,omitempty"`
+	Legs  []margin.Leg `json:"legs,omitempty"`
+}
+
+// Estimate handles estimating the margin the caller's proposed order or basket
+// requires, and their margin once it executes
+func (h *MarginHandler) Estimate(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var request EstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	legs := request.Legs
+	if request.Order != nil {
+		legs = append([]margin.Leg{*request.Order}, legs...)
+	}
+
+	estimate, err := h.estimator.Estimate(userID, legs)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, estimate)
+}
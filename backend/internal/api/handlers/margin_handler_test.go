@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/margin"
+)
+
+// MockMarginEstimator is a mock implementation of the MarginEstimator interface
+type MockMarginEstimator struct {
+	mock.Mock
+}
+
+func (m *MockMarginEstimator) Estimate(userID string, legs []margin.Leg) (*margin.Estimate, error) {
+	args := m.Called(userID, legs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*margin.Estimate), args.Error(1)
+}
+
+func TestEstimateMargin(t *testing.T) {
+	// Create handler with mock estimator
+	mockEstimator := new(MockMarginEstimator)
+	handler := NewMarginHandler(mockEstimator)
+
+	// A single order and a basket are both estimated for the caller
+	mockEstimator.On("Estimate", "user123", mock.MatchedBy(func(legs []margin.Leg) bool {
+		return len(legs) == 1 && legs[0].Symbol == "NIFTY24JUNFUT" && legs[0].Direction == models.OrderDirectionBuy
+	})).Return(&margin.Estimate{Source: margin.SourceInternal, RequiredMargin: 80000, MarginAfter: 80000}, nil)
+	mockEstimator.On("Estimate", "user123", mock.MatchedBy(func(legs []margin.Leg) bool {
+		return len(legs) == 2 && legs[1].Underlying == "NIFTY"
+	})).Return(&margin.Estimate{Source: margin.SourceBroker, RequiredMargin: 30000, MarginAfter: 30000}, nil)
+
+	reqBody := `{"order":{"symbol":"NIFTY24JUNFUT","exchange":"NFO","orderType":"MARKET","direction":"BUY","quantity":50,"productType":"NRML","instrumentType":"FUTURE"}}`
+	req := httptest.NewRequest("POST", "/api/margin/estimate", strings.NewReader(reqBody))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.Estimate(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var estimate margin.Estimate
+	err := json.Unmarshal(rr.Body.Bytes(), &estimate)
+	assert.NoError(t, err)
+	assert.Equal(t, 80000.0, estimate.RequiredMargin)
+
+	reqBody = `{"legs":[{"symbol":"NIFTY24JUN20000CE","direction":"SELL"},{"symbol":"NIFTY24JUN20500CE","direction":"BUY","underlying":"NIFTY"}]}`
+	req = httptest.NewRequest("POST", "/api/margin/estimate", strings.NewReader(reqBody))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+
+	handler.Estimate(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	err = json.Unmarshal(rr.Body.Bytes(), &estimate)
+	assert.NoError(t, err)
+	assert.Equal(t, margin.SourceBroker, estimate.Source)
+
+	mockEstimator.AssertExpectations(t)
+}
+
+func TestEstimateMarginInvalid(t *testing.T) {
+	// Create handler with mock estimator
+	mockEstimator := new(MockMarginEstimator)
+	handler := NewMarginHandler(mockEstimator)
+
+	mockEstimator.On("Estimate", "user123", mock.Anything).Return(nil, errors.New("at least one order is required"))
+
+	req := httptest.NewRequest("POST", "/api/margin/estimate", strings.NewReader(`{}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.Estimate(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Estimates need a caller
+	req = httptest.NewRequest("POST", "/api/margin/estimate", strings.NewReader(`{}`))
+	rr = httptest.NewRecorder()
+
+	handler.Estimate(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
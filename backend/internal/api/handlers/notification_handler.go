@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/trading-platform/backend/internal/alerts"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// defaultNotificationLimit is the number of notifications returned per page when
+// no limit is given
+const defaultNotificationLimit = 20
+
+// NotificationService is the part of the alert service the handler uses
+type NotificationService interface {
+	Inbox(userID string, unreadOnly bool, page, limit int) (*alerts.Inbox, error)
+	MarkRead(userID string, ids []string) (int, error)
+}
+
+// NotificationHandler handles the notification center API endpoints, the inbox
+// of the alerts and notifications delivered to the caller over any channel
+type NotificationHandler struct {
+	service NotificationService
+}
+
+// NewNotificationHandler creates a new NotificationHandler
+func NewNotificationHandler(service NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		service: service,
+	}
+}
+
+// MarkReadRequest marks notifications read
+type MarkReadRequest struct {
+	IDs []string `json:"ids"`
+	All bool     `json:"all"` // Every unread notification, instead of those of IDs
+}
+
+// GetNotifications handles listing a page of the caller's notifications, newest
+// first, only unread ones when the unread query parameter is true
+func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse pagination parameters
+	query := r.URL.Query()
+	page := 1
+	limit := defaultNotificationLimit
+	if pageStr := query.Get("page"); pageStr != "" {
+		parsedPage, err := utils.ParseInt(pageStr)
+		if err != nil || parsedPage <= 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid page")
+			return
+		}
+		page = parsedPage
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsedLimit, err := utils.ParseInt(limitStr)
+		if err != nil || parsedLimit <= 0 || parsedLimit > defaultAlertLimit {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsedLimit
+	}
+	unreadOnly := false
+	if unreadStr := query.Get("unread"); unreadStr != "" {
+		parsedUnread, err := utils.ParseBool(unreadStr)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid unread")
+			return
+		}
+		unreadOnly = parsedUnread
+	}
+
+	inbox, err := h.service.Inbox(userID, unreadOnly, page, limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving notifications")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, inbox)
+}
+
+// MarkRead handles marking the caller's notifications read, responding with the
+// number still unread
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var request MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	// Marking every notification read is asked for explicitly
+	if len(request.IDs) == 0 && !request.All {
+		utils.RespondWithError(w, http.StatusBadRequest, "Notification IDs are required")
+		return
+	}
+	ids := request.IDs
+	if request.All {
+		ids = nil
+	}
+
+	unread, err := h.service.MarkRead(userID, ids)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error marking notifications read")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]int{"unread": unread})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/alerts"
+	"github.com/trading-platform/backend/internal/auth"
+)
+
+// MockNotificationService is a mock implementation of the NotificationService
+// interface
+type MockNotificationService struct {
+	mock.Mock
+}
+
+func (m *MockNotificationService) Inbox(userID string, unreadOnly bool, page, limit int) (*alerts.Inbox, error) {
+	args := m.Called(userID, unreadOnly, page, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*alerts.Inbox), args.Error(1)
+}
+
+func (m *MockNotificationService) MarkRead(userID string, ids []string) (int, error) {
+	args := m.Called(userID, ids)
+	return args.Int(0), args.Error(1)
+}
+
+func TestGetNotifications(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockNotificationService)
+	handler := NewNotificationHandler(mockService)
+
+	mockService.On("Inbox", "user123", true, 2, 10).Return(&alerts.Inbox{
+		Notifications: []alerts.Alert{{ID: "alert1", UserID: "user123", RuleName: "Order filled"}},
+		Total:         11,
+		Unread:        11,
+		Page:          2,
+		Limit:         10,
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/api/notifications?unread=true&page=2&limit=10", nil)
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.GetNotifications(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response alerts.Inbox
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, 11, response.Unread)
+	assert.Len(t, response.Notifications, 1)
+	mockService.AssertExpectations(t)
+
+	// Invalid pagination is rejected
+	for _, query := range []string{"page=0", "limit=1000", "unread=maybe"} {
+		req = httptest.NewRequest("GET", "/api/notifications?"+query, nil)
+		req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+		rr = httptest.NewRecorder()
+
+		handler.GetNotifications(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockNotificationService)
+	handler := NewNotificationHandler(mockService)
+
+	mockService.On("MarkRead", "user123", []string{"alert1"}).Return(4, nil)
+	mockService.On("MarkRead", "user123", []string(nil)).Return(0, nil)
+
+	for body, unread := range map[string]float64{`{"ids":["alert1"]}`: 4, `{"all":true}`: 0} {
+		req := httptest.NewRequest("POST", "/api/notifications/read", strings.NewReader(body))
+		req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+		rr := httptest.NewRecorder()
+
+		handler.MarkRead(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, unread, response["unread"])
+	}
+	mockService.AssertExpectations(t)
+
+	// Every notification is marked read only when asked explicitly
+	req := httptest.NewRequest("POST", "/api/notifications/read", strings.NewReader(`{}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.MarkRead(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -2,18 +2,23 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/audit"
 	"github.com/trading-platform/backend/internal/models"
 	"github.com/trading-platform/backend/internal/services"
+	"github.com/trading-platform/backend/internal/services/margin"
+	"github.com/trading-platform/backend/internal/services/risk"
 	"github.com/trading-platform/backend/pkg/utils"
 )
 
 // OrderHandler handles HTTP requests related to orders
 type OrderHandler struct {
 	orderService services.OrderService
+	auditLogger  *audit.Logger
 }
 
 // NewOrderHandler creates a new OrderHandler
@@ -23,6 +28,11 @@ func NewOrderHandler(orderService services.OrderService) *OrderHandler {
 	}
 }
 
+// SetAuditLogger records the orders placed, modified and cancelled made through the handler in the audit log
+func (h *OrderHandler) SetAuditLogger(auditLogger *audit.Logger) {
+	h.auditLogger = auditLogger
+}
+
 // Response represents a standard API response
 type Response struct {
 	Success bool        `json:"success"`
@@ -51,11 +61,23 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 
 	// Create the order
 	createdOrder, err := h.orderService.CreateOrder(&order)
+	if errors.Is(err, risk.ErrEntriesBlocked) || errors.Is(err, risk.ErrCircuitBreakerTripped) || errors.Is(err, risk.ErrLimitExceeded) || errors.Is(err, risk.ErrRuleBlocked) || errors.Is(err, risk.ErrStrategyHalted) || errors.Is(err, margin.ErrInsufficientMargin) {
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryOrder,
+		Action:       audit.ActionOrderCreated,
+		ResourceType: "order",
+		ResourceID:   createdOrder.ID,
+		After:        audit.Snapshot(createdOrder),
+	})
+
 	utils.RespondWithJSON(w, http.StatusCreated, createdOrder)
 }
 
@@ -189,6 +211,15 @@ func (h *OrderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryOrder,
+		Action:       audit.ActionOrderModified,
+		ResourceType: "order",
+		ResourceID:   id,
+		Before:       audit.Snapshot(existingOrder),
+		After:        audit.Snapshot(updatedOrder),
+	})
+
 	utils.RespondWithJSON(w, http.StatusOK, updatedOrder)
 }
 
@@ -205,7 +236,7 @@ func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if order can be cancelled
-	if existingOrder.Status != models.OrderStatusPending && existingOrder.Status != models.OrderStatusPartial {
+	if !existingOrder.CanTransitionTo(models.OrderStatusCancelled) {
 		utils.RespondWithError(w, http.StatusBadRequest, "Only pending or partially filled orders can be cancelled")
 		return
 	}
@@ -217,9 +248,31 @@ func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryOrder,
+		Action:       audit.ActionOrderCancelled,
+		ResourceType: "order",
+		ResourceID:   id,
+		Before:       audit.Snapshot(existingOrder),
+	})
+
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Order cancelled successfully"})
 }
 
+// GetOrderTimeline handles the retrieval of the status transitions of an order
+func (h *OrderHandler) GetOrderTimeline(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	timeline, err := h.orderService.GetOrderTimeline(id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Order not found")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, timeline)
+}
+
 // GetOrdersByUser handles the retrieval of all orders for a specific user
 func (h *OrderHandler) GetOrdersByUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
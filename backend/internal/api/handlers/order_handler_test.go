@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -47,6 +48,22 @@ func (m *MockOrderService) CancelOrder(id string) error {
 	return args.Error(0)
 }
 
+func (m *MockOrderService) TransitionOrder(id string, status models.OrderStatus, filledQuantity int, source models.TransitionSource, reason string) (*models.Order, error) {
+	args := m.Called(id, status, filledQuantity, source, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderTimeline(id string) ([]models.OrderTransition, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OrderTransition), args.Error(1)
+}
+
 func TestCreateOrder(t *testing.T) {
 	// Create a mock order service
 	mockService := new(MockOrderService)
@@ -377,6 +394,59 @@ func TestCancelOrder(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestGetOrderTimeline(t *testing.T) {
+	// Create a mock order service
+	mockService := new(MockOrderService)
+	
+	// Create a sample timeline
+	timeline := []models.OrderTransition{
+		{OrderID: "order123", ToStatus: models.OrderStatusNew, Source: models.TransitionSourceUser},
+		{OrderID: "order123", FromStatus: models.OrderStatusNew, ToStatus: models.OrderStatusPending, Source: models.TransitionSourceSystem},
+		{OrderID: "order123", FromStatus: models.OrderStatusPending, ToStatus: models.OrderStatusFilled, FilledQuantity: 10, Source: models.TransitionSourceBroker},
+	}
+	
+	// Set up the mock service expectations
+	mockService.On("GetOrderTimeline", "order123").Return(timeline, nil)
+	mockService.On("GetOrderTimeline", "nonexistent").Return(nil, errors.New("order not found"))
+	
+	// Create the handler with the mock service
+	handler := NewOrderHandler(mockService)
+	
+	// Set up the router to get the URL parameters
+	router := mux.NewRouter()
+	router.HandleFunc("/api/orders/{id}/timeline", handler.GetOrderTimeline)
+	
+	// Test successful retrieval
+	req, err := http.NewRequest("GET", "/api/orders/order123/timeline", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	
+	// Parse the response
+	var response []models.OrderTransition
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, response, 3)
+	assert.Equal(t, models.TransitionSourceBroker, response[2].Source)
+	
+	// Test timeline of non-existent order
+	req, err = http.NewRequest("GET", "/api/orders/nonexistent/timeline", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	
+	// Verify that the mock service was called
+	mockService.AssertExpectations(t)
+}
+
 func TestGetOrdersByUser(t *testing.T) {
 	// Create a mock order service
 	mockService := new(MockOrderService)
@@ -6,14 +6,18 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/audit"
 	"github.com/trading-platform/backend/internal/models"
 	"github.com/trading-platform/backend/internal/services/position"
+	"github.com/trading-platform/backend/internal/webhooks"
 	"github.com/trading-platform/backend/pkg/utils"
 )
 
 // PositionHandler handles HTTP requests related to positions
 type PositionHandler struct {
 	positionService position.PositionService
+	auditLogger     *audit.Logger
+	webhooks        *webhooks.Service
 }
 
 // NewPositionHandler creates a new PositionHandler
@@ -23,6 +27,16 @@ func NewPositionHandler(positionService position.PositionService) *PositionHandl
 	}
 }
 
+// SetAuditLogger records the changes to positions made through the handler in the audit log
+func (h *PositionHandler) SetAuditLogger(auditLogger *audit.Logger) {
+	h.auditLogger = auditLogger
+}
+
+// SetWebhooks notifies users' webhooks of the positions closed through the handler
+func (h *PositionHandler) SetWebhooks(webhookService *webhooks.Service) {
+	h.webhooks = webhookService
+}
+
 // CreatePositionFromOrder handles the creation of a new position from an order
 func (h *PositionHandler) CreatePositionFromOrder(w http.ResponseWriter, r *http.Request) {
 	var order models.Order
@@ -39,6 +53,14 @@ func (h *PositionHandler) CreatePositionFromOrder(w http.ResponseWriter, r *http
 		return
 	}
 
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryPortfolio,
+		Action:       audit.ActionPositionCreated,
+		ResourceType: "position",
+		ResourceID:   createdPosition.ID,
+		After:        audit.Snapshot(createdPosition),
+	})
+
 	utils.RespondWithJSON(w, http.StatusCreated, createdPosition)
 }
 
@@ -153,6 +175,12 @@ func (h *PositionHandler) UpdatePosition(w http.ResponseWriter, r *http.Request)
 	// Set ID
 	positionUpdate.ID = id
 
+	// Keep the position as it was for the audit log
+	var existingPosition *models.Position
+	if h.auditLogger != nil {
+		existingPosition, _ = h.positionService.GetPositionByID(id)
+	}
+
 	// Update the position
 	updatedPosition, err := h.positionService.UpdatePosition(&positionUpdate)
 	if err != nil {
@@ -160,6 +188,15 @@ func (h *PositionHandler) UpdatePosition(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryPortfolio,
+		Action:       audit.ActionPositionUpdated,
+		ResourceType: "position",
+		ResourceID:   id,
+		Before:       audit.Snapshot(existingPosition),
+		After:        audit.Snapshot(updatedPosition),
+	})
+
 	utils.RespondWithJSON(w, http.StatusOK, updatedPosition)
 }
 
@@ -179,6 +216,12 @@ func (h *PositionHandler) ClosePosition(w http.ResponseWriter, r *http.Request)
 	}
 	defer r.Body.Close()
 
+	// Keep the position as it was for the audit log
+	var existingPosition *models.Position
+	if h.auditLogger != nil {
+		existingPosition, _ = h.positionService.GetPositionByID(id)
+	}
+
 	// Close the position
 	closedPosition, err := h.positionService.ClosePosition(id, closeParams.ExitPrice, closeParams.ExitQuantity)
 	if err != nil {
@@ -186,6 +229,20 @@ func (h *PositionHandler) ClosePosition(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryPortfolio,
+		Action:       audit.ActionPositionClosed,
+		ResourceType: "position",
+		ResourceID:   id,
+		Before:       audit.Snapshot(existingPosition),
+		After:        audit.Snapshot(closedPosition),
+	})
+
+	// Partial exits leave the position open
+	if closedPosition.Status == models.PositionStatusClosed {
+		h.webhooks.PublishPositionClosed(r.Context(), closedPosition)
+	}
+
 	utils.RespondWithJSON(w, http.StatusOK, closedPosition)
 }
 
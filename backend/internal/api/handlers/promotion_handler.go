@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/promotion"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// PromotionHandler handles paper to live strategy promotion API endpoints
+type PromotionHandler struct {
+	promotionService promotion.PromotionService
+}
+
+// NewPromotionHandler creates a new PromotionHandler
+func NewPromotionHandler(promotionService promotion.PromotionService) *PromotionHandler {
+	return &PromotionHandler{
+		promotionService: promotionService,
+	}
+}
+
+// CheckPromotion handles running the promotion checklist of a paper strategy for
+// the broker given in the query
+func (h *PromotionHandler) CheckPromotion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := promotionUser(w, r)
+	if !ok {
+		return
+	}
+
+	strategyID := mux.Vars(r)["strategyId"]
+	result, err := h.promotionService.CheckPromotion(strategyID, r.URL.Query().Get("broker"), userID)
+	if err != nil {
+		respondWithPromotionError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, result)
+}
+
+// PromoteStrategy handles promoting a paper strategy to live, responding with the
+// failed checklist when the strategy is not ready
+func (h *PromotionHandler) PromoteStrategy(w http.ResponseWriter, r *http.Request) {
+	userID, ok := promotionUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse request body for the broker
+	var promoteRequest struct {
+		BrokerName string `json:"brokerName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&promoteRequest); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	strategyID := mux.Vars(r)["strategyId"]
+	result, err := h.promotionService.PromoteStrategy(strategyID, promoteRequest.BrokerName, userID)
+	if err != nil {
+		respondWithPromotionError(w, err)
+		return
+	}
+
+	if !result.Passed {
+		utils.RespondWithJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, result)
+}
+
+// GetDrift handles comparing a live strategy with the paper strategy it was
+// promoted from
+func (h *PromotionHandler) GetDrift(w http.ResponseWriter, r *http.Request) {
+	userID, ok := promotionUser(w, r)
+	if !ok {
+		return
+	}
+
+	strategyID := mux.Vars(r)["strategyId"]
+	drift, err := h.promotionService.GetDrift(strategyID, userID)
+	if err != nil {
+		respondWithPromotionError(w, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, drift)
+}
+
+// promotionUser returns the user whose strategies may be promoted, empty for
+// admins who may promote anyone's
+func promotionUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", false
+	}
+
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		return "", true
+	}
+	return userID, true
+}
+
+// respondWithPromotionError responds with the status matching a promotion error
+func respondWithPromotionError(w http.ResponseWriter, err error) {
+	switch err {
+	case promotion.ErrStrategyNotFound:
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case promotion.ErrAccessDenied:
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	}
+}
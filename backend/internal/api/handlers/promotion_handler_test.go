@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/promotion"
+)
+
+// MockPromotionService is a mock implementation of the PromotionService interface
+type MockPromotionService struct {
+	mock.Mock
+}
+
+func (m *MockPromotionService) CheckPromotion(strategyID, brokerName, userID string) (*promotion.Result, error) {
+	args := m.Called(strategyID, brokerName, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*promotion.Result), args.Error(1)
+}
+
+func (m *MockPromotionService) PromoteStrategy(strategyID, brokerName, userID string) (*promotion.Result, error) {
+	args := m.Called(strategyID, brokerName, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*promotion.Result), args.Error(1)
+}
+
+func (m *MockPromotionService) GetDrift(strategyID, userID string) (*promotion.Drift, error) {
+	args := m.Called(strategyID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*promotion.Drift), args.Error(1)
+}
+
+func TestPromoteStrategy(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockPromotionService)
+	handler := NewPromotionHandler(mockService)
+
+	mockService.On("PromoteStrategy", "strategy123", "ZERODHA", "user123").Return(&promotion.Result{
+		PaperStrategyID: "strategy123",
+		Passed:          true,
+		LiveStrategy:    &models.Strategy{ID: "live123"},
+	}, nil)
+
+	req := httptest.NewRequest("POST", "/api/strategies/strategy123/promote", strings.NewReader(`{"brokerName":"ZERODHA"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "strategy123"})
+	rr := httptest.NewRecorder()
+
+	handler.PromoteStrategy(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var result promotion.Result
+	err := json.Unmarshal(rr.Body.Bytes(), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, "live123", result.LiveStrategy.ID)
+
+	// A strategy failing the checklist is not promoted
+	mockService.On("PromoteStrategy", "strategy456", "ZERODHA", "user123").Return(&promotion.Result{
+		PaperStrategyID: "strategy456",
+		Checks:          []promotion.Check{{Name: promotion.CheckRiskLimits, Message: "risk limits not set: max loss"}},
+	}, nil)
+
+	req = httptest.NewRequest("POST", "/api/strategies/strategy456/promote", strings.NewReader(`{"brokerName":"ZERODHA"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "strategy456"})
+	rr = httptest.NewRecorder()
+
+	handler.PromoteStrategy(rr, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	// Another user's strategy cannot be promoted
+	mockService.On("PromoteStrategy", "strategy789", "ZERODHA", "user123").Return(nil, promotion.ErrAccessDenied)
+
+	req = httptest.NewRequest("POST", "/api/strategies/strategy789/promote", strings.NewReader(`{"brokerName":"ZERODHA"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "strategy789"})
+	rr = httptest.NewRecorder()
+
+	handler.PromoteStrategy(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestCheckPromotion(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockPromotionService)
+	handler := NewPromotionHandler(mockService)
+
+	// Admins may check anyone's strategy
+	mockService.On("CheckPromotion", "strategy123", "XTS", "").Return(&promotion.Result{PaperStrategyID: "strategy123", Passed: true}, nil)
+
+	req := httptest.NewRequest("GET", "/api/strategies/strategy123/promotion?broker=XTS", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "strategy123"})
+	rr := httptest.NewRecorder()
+
+	handler.CheckPromotion(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Test unauthenticated requests
+	req = httptest.NewRequest("GET", "/api/strategies/strategy123/promotion?broker=XTS", nil)
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "strategy123"})
+	rr = httptest.NewRecorder()
+
+	handler.CheckPromotion(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDrift(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockPromotionService)
+	handler := NewPromotionHandler(mockService)
+
+	mockService.On("GetDrift", "live123", "user123").Return(&promotion.Drift{PaperStrategyID: "strategy123", LiveStrategyID: "live123", AveragePnLDrift: -200}, nil)
+	mockService.On("GetDrift", "missing", "user123").Return(nil, promotion.ErrStrategyNotFound)
+
+	req := httptest.NewRequest("GET", "/api/strategies/live123/drift", nil)
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "live123"})
+	rr := httptest.NewRecorder()
+
+	handler.GetDrift(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var drift promotion.Drift
+	err := json.Unmarshal(rr.Body.Bytes(), &drift)
+	assert.NoError(t, err)
+	assert.Equal(t, -200.0, drift.AveragePnLDrift)
+
+	req = httptest.NewRequest("GET", "/api/strategies/missing/drift", nil)
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "missing"})
+	rr = httptest.NewRecorder()
+
+	handler.GetDrift(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
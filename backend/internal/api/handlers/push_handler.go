@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/push"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// PushService is the part of the push service the handler uses
+type PushService interface {
+	RegisterDevice(userID string, request push.Request) (*push.Device, error)
+	GetDevice(id string) (*push.Device, error)
+	ListDevices(userID string) ([]push.Device, error)
+	UnregisterDevice(id string) error
+}
+
+// PushHandler handles the registration of users' mobile devices for push
+// notifications
+type PushHandler struct {
+	service PushService
+}
+
+// NewPushHandler creates a new PushHandler
+func NewPushHandler(service PushService) *PushHandler {
+	return &PushHandler{
+		service: service,
+	}
+}
+
+// RegisterDevice handles registering a device of the caller for push
+// notifications, with the token the app was issued by FCM or APNs
+func (h *PushHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var request push.Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	device, err := h.service.RegisterDevice(userID, request)
+	if err != nil {
+		if errors.Is(err, push.ErrInvalidDevice) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error registering device")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, device)
+}
+
+// GetDevices handles listing the caller's devices registered for push
+// notifications
+func (h *PushHandler) GetDevices(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	devices, err := h.service.ListDevices(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving devices")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, devices)
+}
+
+// DeleteDevice handles unregistering a device, which is no longer pushed to.
+// Users unregister their own devices, admins anyone's.
+func (h *PushHandler) DeleteDevice(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	device, err := h.service.GetDevice(mux.Vars(r)["deviceId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Device not found")
+		return
+	}
+	if device.UserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	if err := h.service.UnregisterDevice(device.ID); err != nil {
+		if errors.Is(err, push.ErrDeviceNotFound) {
+			utils.RespondWithError(w, http.StatusNotFound, "Device not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error unregistering device")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Device unregistered successfully"})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/push"
+)
+
+// MockPushService is a mock implementation of the PushService interface
+type MockPushService struct {
+	mock.Mock
+}
+
+func (m *MockPushService) RegisterDevice(userID string, request push.Request) (*push.Device, error) {
+	args := m.Called(userID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*push.Device), args.Error(1)
+}
+
+func (m *MockPushService) GetDevice(id string) (*push.Device, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*push.Device), args.Error(1)
+}
+
+func (m *MockPushService) ListDevices(userID string) ([]push.Device, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]push.Device), args.Error(1)
+}
+
+func (m *MockPushService) UnregisterDevice(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func TestRegisterDevice(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockPushService)
+	handler := NewPushHandler(mockService)
+
+	request := push.Request{Platform: push.PlatformIOS, Token: "apns-token", Name: "iPhone"}
+	mockService.On("RegisterDevice", "user123", request).Return(&push.Device{ID: "device1", UserID: "user123", Platform: push.PlatformIOS, Token: "apns-token"}, nil)
+
+	req := httptest.NewRequest("POST", "/api/push/devices", strings.NewReader(`{"platform":"IOS","token":"apns-token","name":"iPhone"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.RegisterDevice(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "device1", response["id"])
+	assert.NotContains(t, response, "token")
+
+	// Invalid registrations are rejected
+	mockService.On("RegisterDevice", "user123", push.Request{Platform: "WINDOWS", Token: "token"}).Return(nil, push.ErrInvalidDevice)
+
+	req = httptest.NewRequest("POST", "/api/push/devices", strings.NewReader(`{"platform":"WINDOWS","token":"token"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+
+	handler.RegisterDevice(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteDevice(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockPushService)
+	handler := NewPushHandler(mockService)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/push/devices/{deviceId}", handler.DeleteDevice).Methods("DELETE")
+
+	mockService.On("GetDevice", "device1").Return(&push.Device{ID: "device1", UserID: "user123"}, nil)
+	mockService.On("GetDevice", "device2").Return(nil, push.ErrDeviceNotFound)
+	mockService.On("UnregisterDevice", "device1").Return(nil).Once()
+
+	// Users cannot unregister others' devices
+	req := httptest.NewRequest("DELETE", "/api/push/devices/device1", nil)
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user456"))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	req = httptest.NewRequest("DELETE", "/api/push/devices/device2", nil)
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	req = httptest.NewRequest("DELETE", "/api/push/devices/device1", nil)
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/reports"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// ReportService is the part of the report service the handler uses
+type ReportService interface {
+	TradeStatement(userID, from, to string) (*reports.Report, error)
+	PortfolioSummary(portfolioID, userID string) (*reports.Report, error)
+	StrategyPerformance(strategyID, userID, from, to string) (*reports.Report, error)
+	TaxReport(userID, financialYear string) (*reports.TaxReport, error)
+	TaxStatement(userID, financialYear string, format reports.Format) (*reports.Report, error)
+	Reports(userID string) ([]reports.Report, error)
+	Download(key, expires, signature string) ([]byte, string, error)
+}
+
+// ReportHandler handles PDF and tax report API endpoints
+type ReportHandler struct {
+	service ReportService
+}
+
+// NewReportHandler creates a new ReportHandler
+func NewReportHandler(service ReportService) *ReportHandler {
+	return &ReportHandler{
+		service: service,
+	}
+}
+
+// ReportPeriodRequest is the period a report is of, dates as YYYY-MM-DD, both
+// included
+type ReportPeriodRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// TaxStatementRequest is the financial year, YYYY-YY, and format, csv or pdf, of
+// a tax report
+type TaxStatementRequest struct {
+	FinancialYear string         `json:"financialYear"`
+	Format        reports.Format `json:"format"`
+}
+
+// CreateTradeStatement handles rendering the caller's trade statement of the
+// period in the request body, the month so far when it is not given. Admins may
+// give the userId query parameter.
+func (h *ReportHandler) CreateTradeStatement(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if other := r.URL.Query().Get("userId"); other != "" && auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = other
+	}
+
+	request, ok := reportPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.TradeStatement(userID, request.From, request.To)
+	if err != nil {
+		respondWithReportError(w, err, "Error rendering trade statement")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, report)
+}
+
+// CreatePortfolioSummary handles rendering the summary of a portfolio of the
+// caller, or of anyone for admins
+func (h *ReportHandler) CreatePortfolioSummary(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = ""
+	}
+
+	report, err := h.service.PortfolioSummary(mux.Vars(r)["portfolioId"], userID)
+	if err != nil {
+		respondWithReportError(w, err, "Error rendering portfolio summary")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, report)
+}
+
+// CreateStrategyPerformance handles rendering the performance of a strategy of
+// the caller, or of anyone for admins, over the period in the request body, the
+// strategy's lifetime when it is not given
+func (h *ReportHandler) CreateStrategyPerformance(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = ""
+	}
+
+	request, ok := reportPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.StrategyPerformance(mux.Vars(r)["strategyId"], userID, request.From, request.To)
+	if err != nil {
+		respondWithReportError(w, err, "Error rendering strategy performance")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, report)
+}
+
+// GetTaxReport handles compiling the caller's tax report of the financialYear
+// query parameter, the current financial year when it is not given. Admins may
+// give the userId query parameter.
+func (h *ReportHandler) GetTaxReport(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if other := r.URL.Query().Get("userId"); other != "" && auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = other
+	}
+
+	tax, err := h.service.TaxReport(userID, r.URL.Query().Get("financialYear"))
+	if err != nil {
+		respondWithReportError(w, err, "Error compiling tax report")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, tax)
+}
+
+// CreateTaxStatement handles rendering the caller's tax report of the financial
+// year in the request body as CSV or PDF, the current financial year as PDF when
+// they are not given. Admins may give the userId query parameter.
+func (h *ReportHandler) CreateTaxStatement(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if other := r.URL.Query().Get("userId"); other != "" && auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = other
+	}
+
+	var request TaxStatementRequest
+	if r.Body != nil {
+		defer r.Body.Close()
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+
+	report, err := h.service.TaxStatement(userID, request.FinancialYear, request.Format)
+	if err != nil {
+		respondWithReportError(w, err, "Error rendering tax report")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, report)
+}
+
+// GetReports handles listing the caller's reports, newest first, with download
+// URLs signed afresh. Admins may give the userId query parameter.
+func (h *ReportHandler) GetReports(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if other := r.URL.Query().Get("userId"); other != "" && auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = other
+	}
+
+	list, err := h.service.Reports(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving reports")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, list)
+}
+
+// Download handles downloading a report by the signed URL it was listed with.
+// The signature authorizes the download, so it needs no authentication and the
+// URL may be handed to a browser or shared until it expires.
+func (h *ReportHandler) Download(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	key := query.Get("key")
+
+	data, contentType, err := h.service.Download(key, query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		respondWithReportError(w, err, "Error downloading report")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+path.Base(key)+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// reportPeriod decodes the period of the request body, which may be empty,
+// responding with an error when it is not valid
+func reportPeriod(w http.ResponseWriter, r *http.Request) (ReportPeriodRequest, bool) {
+	var request ReportPeriodRequest
+	if r.Body == nil {
+		return request, true
+	}
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return request, false
+	}
+	return request, true
+}
+
+// respondWithReportError responds with the status of a report service error
+func respondWithReportError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, reports.ErrInvalidDate), errors.Is(err, reports.ErrInvalidPeriod),
+		errors.Is(err, reports.ErrInvalidFinancialYear), errors.Is(err, reports.ErrInvalidFormat):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, reports.ErrPortfolioNotFound), errors.Is(err, reports.ErrStrategyNotFound), errors.Is(err, reports.ErrReportNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, reports.ErrAccessDenied), errors.Is(err, reports.ErrInvalidSignature):
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, reports.ErrLinkExpired):
+		utils.RespondWithError(w, http.StatusGone, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, message)
+	}
+}
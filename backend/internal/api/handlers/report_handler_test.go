@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/reports"
+)
+
+// MockReportService is a mock implementation of the ReportService interface
+type MockReportService struct {
+	mock.Mock
+}
+
+func (m *MockReportService) TradeStatement(userID, from, to string) (*reports.Report, error) {
+	args := m.Called(userID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*reports.Report), args.Error(1)
+}
+
+func (m *MockReportService) PortfolioSummary(portfolioID, userID string) (*reports.Report, error) {
+	args := m.Called(portfolioID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*reports.Report), args.Error(1)
+}
+
+func (m *MockReportService) StrategyPerformance(strategyID, userID, from, to string) (*reports.Report, error) {
+	args := m.Called(strategyID, userID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*reports.Report), args.Error(1)
+}
+
+func (m *MockReportService) TaxReport(userID, financialYear string) (*reports.TaxReport, error) {
+	args := m.Called(userID, financialYear)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*reports.TaxReport), args.Error(1)
+}
+
+func (m *MockReportService) TaxStatement(userID, financialYear string, format reports.Format) (*reports.Report, error) {
+	args := m.Called(userID, financialYear, format)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*reports.Report), args.Error(1)
+}
+
+func (m *MockReportService) Reports(userID string) ([]reports.Report, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]reports.Report), args.Error(1)
+}
+
+func (m *MockReportService) Download(key, expires, signature string) ([]byte, string, error) {
+	args := m.Called(key, expires, signature)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).([]byte), args.String(1), args.Error(2)
+}
+
+// reportRouter routes the report endpoints
+func reportRouter(handler *ReportHandler) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/reports/trade-statement", handler.CreateTradeStatement).Methods("POST")
+	router.HandleFunc("/api/reports/portfolios/{portfolioId}", handler.CreatePortfolioSummary).Methods("POST")
+	router.HandleFunc("/api/reports/strategies/{strategyId}", handler.CreateStrategyPerformance).Methods("POST")
+	router.HandleFunc("/api/reports/tax", handler.GetTaxReport).Methods("GET")
+	router.HandleFunc("/api/reports/tax", handler.CreateTaxStatement).Methods("POST")
+	router.HandleFunc("/api/reports/download", handler.Download).Methods("GET")
+	router.HandleFunc("/api/reports", handler.GetReports).Methods("GET")
+	return router
+}
+
+// reportRequest creates a request of a user with a role
+func reportRequest(method, target, body, userID string, role models.UserRole) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	return req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), userID), string(role)))
+}
+
+func TestCreateTradeStatement(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockReportService)
+	router := reportRouter(NewReportHandler(mockService))
+
+	report := &reports.Report{Key: "reports/user123/statement.pdf", Kind: reports.KindTradeStatement, UserID: "user123"}
+
+	// Users only get their own statement, whatever user they asked for
+	mockService.On("TradeStatement", "user123", "2024-03-01", "2024-03-31").Return(report, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/trade-statement?userId=user456", `{"from":"2024-03-01","to":"2024-03-31"}`, "user123", models.UserRoleTrader))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var response reports.Report
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, report.Key, response.Key)
+
+	// The period may be left out, and admins get anyone's
+	mockService.On("TradeStatement", "user456", "", "").Return(&reports.Report{UserID: "user456"}, nil)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/trade-statement?userId=user456", "", "admin1", models.UserRoleAdmin))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// Invalid periods are bad requests
+	mockService.On("TradeStatement", "user123", "2024-03-31", "2024-03-01").Return(nil, reports.ErrInvalidPeriod)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/trade-statement", `{"from":"2024-03-31","to":"2024-03-01"}`, "user123", models.UserRoleTrader))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/trade-statement", `{"from":`, "user123", models.UserRoleTrader))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Unauthenticated requests are refused
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/api/reports/trade-statement", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreatePortfolioAndStrategyReports(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockReportService)
+	router := reportRouter(NewReportHandler(mockService))
+
+	mockService.On("PortfolioSummary", "portfolio1", "user123").Return(&reports.Report{Kind: reports.KindPortfolioSummary}, nil)
+	mockService.On("PortfolioSummary", "portfolio2", "user123").Return(nil, reports.ErrAccessDenied)
+	mockService.On("PortfolioSummary", "portfolio2", "").Return(&reports.Report{Kind: reports.KindPortfolioSummary}, nil)
+	mockService.On("StrategyPerformance", "missing", "user123", "2024-01-01", "").Return(nil, reports.ErrStrategyNotFound)
+	mockService.On("StrategyPerformance", "strategy1", "user123", "", "").Return(nil, errors.New("database unavailable"))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/portfolios/portfolio1", "", "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/portfolios/portfolio2", "", "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// Admins report on anyone's portfolio
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/portfolios/portfolio2", "", "admin1", models.UserRoleAdmin))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/strategies/missing", `{"from":"2024-01-01"}`, "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/strategies/strategy1", "", "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestTaxReport(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockReportService)
+	router := reportRouter(NewReportHandler(mockService))
+
+	// Users only get their own tax report, whatever user they asked for
+	tax := &reports.TaxReport{UserID: "user123", FinancialYear: "2023-24", AssessmentYear: "2024-25"}
+	mockService.On("TaxReport", "user123", "2023-24").Return(tax, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("GET", "/api/reports/tax?financialYear=2023-24&userId=user456", "", "user123", models.UserRoleTrader))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response reports.TaxReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "2024-25", response.AssessmentYear)
+
+	mockService.On("TaxReport", "user123", "2023").Return(nil, reports.ErrInvalidFinancialYear)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("GET", "/api/reports/tax?financialYear=2023", "", "user123", models.UserRoleTrader))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateTaxStatement(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockReportService)
+	router := reportRouter(NewReportHandler(mockService))
+
+	report := &reports.Report{Key: "reports/user456/tax-report.csv", Kind: reports.KindTaxReport, UserID: "user456"}
+	mockService.On("TaxStatement", "user456", "2023-24", reports.FormatCSV).Return(report, nil)
+
+	// Admins get anyone's
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/tax?userId=user456", `{"financialYear":"2023-24","format":"csv"}`, "admin1", models.UserRoleAdmin))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var response reports.Report
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, report.Key, response.Key)
+
+	// The year and format may be left out, and invalid formats are bad requests
+	mockService.On("TaxStatement", "user123", "", reports.Format("")).Return(&reports.Report{UserID: "user123"}, nil)
+	mockService.On("TaxStatement", "user123", "", reports.Format("xlsx")).Return(nil, reports.ErrInvalidFormat)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/tax", "", "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/tax", `{"format":"xlsx"}`, "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("POST", "/api/reports/tax", `{"format":`, "user123", models.UserRoleTrader))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetReports(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockReportService)
+	router := reportRouter(NewReportHandler(mockService))
+
+	mockService.On("Reports", "user123").Return([]reports.Report{{Key: "reports/user123/a.pdf"}, {Key: "reports/user123/b.pdf"}}, nil)
+	mockService.On("Reports", "user456").Return([]reports.Report{}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("GET", "/api/reports?userId=user456", "", "user123", models.UserRoleTrader))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response []reports.Report
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Len(t, response, 2)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, reportRequest("GET", "/api/reports?userId=user456", "", "admin1", models.UserRoleAdmin))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Empty(t, response)
+	mockService.AssertExpectations(t)
+}
+
+func TestDownloadReport(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockReportService)
+	router := reportRouter(NewReportHandler(mockService))
+
+	mockService.On("Download", "reports/user123/statement.pdf", "1710000000", "good").Return([]byte("%PDF-1.4"), "application/pdf", nil)
+	mockService.On("Download", "reports/user123/statement.pdf", "1710000000", "bad").Return(nil, "", reports.ErrInvalidSignature)
+	mockService.On("Download", "reports/user123/statement.pdf", "1600000000", "old").Return(nil, "", reports.ErrLinkExpired)
+
+	// Signed URLs need no authentication
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/reports/download?key=reports%2Fuser123%2Fstatement.pdf&expires=1710000000&signature=good", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="statement.pdf"`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "%PDF-1.4", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/reports/download?key=reports%2Fuser123%2Fstatement.pdf&expires=1710000000&signature=bad", nil))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/reports/download?key=reports%2Fuser123%2Fstatement.pdf&expires=1600000000&signature=old", nil))
+	assert.Equal(t, http.StatusGone, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/audit"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// RuleEngine is the part of the risk rule engine the handler uses
+type RuleEngine interface {
+	CreateRule(rule *risk.Rule) (*risk.Rule, error)
+	GetRule(id string) (*risk.Rule, error)
+	GetRules(userID string) []risk.Rule
+	UpdateRule(rule *risk.Rule) (*risk.Rule, error)
+	DeleteRule(id string) error
+	GetTriggers() []risk.RuleTrigger
+}
+
+// RuleHandler handles risk rule API endpoints
+type RuleHandler struct {
+	engine      RuleEngine
+	auditLogger *audit.Logger
+}
+
+// NewRuleHandler creates a new RuleHandler
+func NewRuleHandler(engine RuleEngine) *RuleHandler {
+	return &RuleHandler{
+		engine: engine,
+	}
+}
+
+// SetAuditLogger records the changes to rules made through the handler in the audit log
+func (h *RuleHandler) SetAuditLogger(auditLogger *audit.Logger) {
+	h.auditLogger = auditLogger
+}
+
+// GetRules handles listing risk rules. Users see the rules applying to them, admins
+// all rules or those of the userId query parameter.
+func (h *RuleHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = r.URL.Query().Get("userId")
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, h.engine.GetRules(userID))
+}
+
+// GetRule handles retrieving a risk rule by ID
+func (h *RuleHandler) GetRule(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rule, err := h.engine.GetRule(mux.Vars(r)["ruleId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Rule not found")
+		return
+	}
+	if rule.UserID != "" && rule.UserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, rule)
+}
+
+// CreateRule handles an admin adding a risk rule
+func (h *RuleHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse request body
+	var rule risk.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	rule.CreatedBy = userID
+
+	created, err := h.engine.CreateRule(&rule)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAdmin,
+		Action:       audit.ActionRuleCreated,
+		ResourceType: "rule",
+		ResourceID:   created.ID,
+		After:        audit.Snapshot(created),
+	})
+
+	utils.RespondWithJSON(w, http.StatusCreated, created)
+}
+
+// UpdateRule handles an admin changing a risk rule
+func (h *RuleHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return
+	}
+
+	// Parse request body
+	var rule risk.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	rule.ID = mux.Vars(r)["ruleId"]
+
+	// Keep the rule as it was for the audit log
+	var before *risk.Rule
+	if h.auditLogger != nil {
+		before, _ = h.engine.GetRule(rule.ID)
+	}
+
+	updated, err := h.engine.UpdateRule(&rule)
+	if errors.Is(err, risk.ErrRuleNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, "Rule not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAdmin,
+		Action:       audit.ActionRuleUpdated,
+		ResourceType: "rule",
+		ResourceID:   updated.ID,
+		Before:       audit.Snapshot(before),
+		After:        audit.Snapshot(updated),
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, updated)
+}
+
+// DeleteRule handles an admin removing a risk rule
+func (h *RuleHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return
+	}
+
+	id := mux.Vars(r)["ruleId"]
+
+	// Keep the rule as it was for the audit log
+	var before *risk.Rule
+	if h.auditLogger != nil {
+		before, _ = h.engine.GetRule(id)
+	}
+
+	if err := h.engine.DeleteRule(id); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAdmin,
+		Action:       audit.ActionRuleDeleted,
+		ResourceType: "rule",
+		ResourceID:   id,
+		Before:       audit.Snapshot(before),
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Rule deleted successfully"})
+}
+
+// GetTriggers handles listing the most recent times rules triggered
+func (h *RuleHandler) GetTriggers(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireAdmin(w, r); !ok {
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, h.engine.GetTriggers())
+}
+
+// requireAdmin returns the ID of the calling admin, responding with an error when
+// the caller is not one
+func (h *RuleHandler) requireAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", false
+	}
+	if auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return "", false
+	}
+
+	return userID, true
+}
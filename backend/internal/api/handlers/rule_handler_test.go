@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+)
+
+// MockRuleEngine is a mock implementation of the RuleEngine interface
+type MockRuleEngine struct {
+	mock.Mock
+}
+
+func (m *MockRuleEngine) CreateRule(rule *risk.Rule) (*risk.Rule, error) {
+	args := m.Called(rule)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.Rule), args.Error(1)
+}
+
+func (m *MockRuleEngine) GetRule(id string) (*risk.Rule, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.Rule), args.Error(1)
+}
+
+func (m *MockRuleEngine) GetRules(userID string) []risk.Rule {
+	args := m.Called(userID)
+	return args.Get(0).([]risk.Rule)
+}
+
+func (m *MockRuleEngine) UpdateRule(rule *risk.Rule) (*risk.Rule, error) {
+	args := m.Called(rule)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.Rule), args.Error(1)
+}
+
+func (m *MockRuleEngine) DeleteRule(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRuleEngine) GetTriggers() []risk.RuleTrigger {
+	args := m.Called()
+	return args.Get(0).([]risk.RuleTrigger)
+}
+
+func TestCreateRule(t *testing.T) {
+	// Create handler with mock engine
+	mockEngine := new(MockRuleEngine)
+	handler := NewRuleHandler(mockEngine)
+
+	reqBody := `{"name":"Loss alert","conditions":[{"metric":"PNL","operator":"LT","value":-5000}],"action":"ALERT"}`
+
+	// Users may not add rules
+	req := httptest.NewRequest("POST", "/api/risk/rules", strings.NewReader(reqBody))
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.CreateRule(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockEngine.AssertNotCalled(t, "CreateRule", mock.Anything)
+
+	// The rule is recorded as created by the calling admin
+	mockEngine.On("CreateRule", mock.MatchedBy(func(rule *risk.Rule) bool {
+		return rule.Action == risk.RuleActionAlert && len(rule.Conditions) == 1 && rule.Conditions[0].Metric == risk.RuleMetricPnL && rule.CreatedBy == "admin1"
+	})).Return(&risk.Rule{ID: "rule1", Name: "Loss alert", Action: risk.RuleActionAlert, CreatedBy: "admin1"}, nil)
+
+	req = httptest.NewRequest("POST", "/api/risk/rules", strings.NewReader(reqBody))
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.CreateRule(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var rule risk.Rule
+	err := json.Unmarshal(rr.Body.Bytes(), &rule)
+	assert.NoError(t, err)
+	assert.Equal(t, "rule1", rule.ID)
+
+	mockEngine.AssertExpectations(t)
+}
+
+func TestGetRules(t *testing.T) {
+	// Create handler with mock engine
+	mockEngine := new(MockRuleEngine)
+	handler := NewRuleHandler(mockEngine)
+
+	mockEngine.On("GetRules", "user123").Return([]risk.Rule{{ID: "rule1", UserID: "user123"}, {ID: "rule2"}})
+	mockEngine.On("GetRule", "rule3").Return(&risk.Rule{ID: "rule3", UserID: "user456"}, nil)
+
+	// Users only see the rules applying to them
+	req := httptest.NewRequest("GET", "/api/risk/rules?userId=user456", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetRules(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var rules []risk.Rule
+	err := json.Unmarshal(rr.Body.Bytes(), &rules)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	req = httptest.NewRequest("GET", "/api/risk/rules/rule3", nil)
+	req = mux.SetURLVars(req, map[string]string{"ruleId": "rule3"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetRule(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// Triggers are for admins only
+	req = httptest.NewRequest("GET", "/api/risk/rules/triggers", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetTriggers(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockEngine.AssertNotCalled(t, "GetTriggers")
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/signals"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+const (
+	// defaultSignalLimit is the number of signals returned when no limit is given
+	defaultSignalLimit = 100
+
+	// maxSignalSize is the size of the largest signal body accepted
+	maxSignalSize = 64 << 10
+)
+
+// SignalService is the part of the signal service the handler uses
+type SignalService interface {
+	CreateHook(userID string, request signals.HookRequest) (*signals.Hook, error)
+	GetHook(id string) (*signals.Hook, error)
+	ListHooks(userID string) ([]signals.Hook, error)
+	UpdateHook(id string, request signals.HookRequest) (*signals.Hook, error)
+	DeleteHook(id string) error
+	ListSignals(filter signals.SignalFilter) ([]signals.Signal, error)
+	Receive(hookID string, body []byte, secret string, now time.Time) (*signals.Signal, error)
+}
+
+// SignalHandler handles the signal webhooks external tools such as TradingView
+// post to, and the API endpoints users manage them through. Users manage their
+// own signal webhooks, admins everyone's.
+type SignalHandler struct {
+	service SignalService
+}
+
+// NewSignalHandler creates a new SignalHandler
+func NewSignalHandler(service SignalService) *SignalHandler {
+	return &SignalHandler{
+		service: service,
+	}
+}
+
+// ReceiveSignal handles a signal posted to the webhook of the hookId path
+// parameter. It is not authenticated by the auth middleware but by the webhook's
+// secret, sent in the signals.HeaderSecret header or the payload's passphrase.
+func (h *SignalHandler) ReceiveSignal(w http.ResponseWriter, r *http.Request) {
+	// Read request body
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignalSize))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	signal, err := h.service.Receive(mux.Vars(r)["hookId"], body, r.Header.Get(signals.HeaderSecret), time.Now())
+	if err != nil {
+		utils.RespondWithError(w, signalErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusAccepted, signal)
+}
+
+// GetHooks handles listing the caller's signal webhooks, or for admins those of
+// the userId query parameter, every webhook when it is not given
+func (h *SignalHandler) GetHooks(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = r.URL.Query().Get("userId")
+	}
+
+	hooks, err := h.service.ListHooks(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving signal webhooks")
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, hooks)
+}
+
+// CreateHook handles registering a signal webhook for the caller. The response
+// holds the webhook's secret, which is not returned again.
+func (h *SignalHandler) CreateHook(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var request signals.HookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	created, err := h.service.CreateHook(userID, request)
+	if err != nil {
+		utils.RespondWithError(w, signalErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, created)
+}
+
+// GetHook handles retrieving a signal webhook by ID
+func (h *SignalHandler) GetHook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.ownedHook(w, r)
+	if !ok {
+		return
+	}
+	hook.Secret = ""
+
+	utils.RespondWithJSON(w, http.StatusOK, hook)
+}
+
+// UpdateHook handles changing the name, secret, symbol mappings or state of a
+// signal webhook
+func (h *SignalHandler) UpdateHook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.ownedHook(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse request body
+	var request signals.HookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	updated, err := h.service.UpdateHook(hook.ID, request)
+	if err != nil {
+		utils.RespondWithError(w, signalErrorStatus(err), err.Error())
+		return
+	}
+	updated.Secret = ""
+
+	utils.RespondWithJSON(w, http.StatusOK, updated)
+}
+
+// DeleteHook handles removing a signal webhook
+func (h *SignalHandler) DeleteHook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.ownedHook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteHook(hook.ID); err != nil {
+		utils.RespondWithError(w, signalErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Signal webhook deleted successfully"})
+}
+
+// GetSignals handles listing the signals a webhook received, newest first, with
+// the portfolios they triggered
+func (h *SignalHandler) GetSignals(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.ownedHook(w, r)
+	if !ok {
+		return
+	}
+
+	filter := signals.SignalFilter{
+		HookID: hook.ID,
+		Limit:  defaultSignalLimit,
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := utils.ParseInt(limitStr)
+		if err != nil || limit <= 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	received, err := h.service.ListSignals(filter)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving signals")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, received)
+}
+
+// ownedHook returns the signal webhook of the hookId path parameter, responding
+// with an error when the caller is not authenticated, it does not exist or it is
+// not the caller's and the caller is not an admin
+func (h *SignalHandler) ownedHook(w http.ResponseWriter, r *http.Request) (*signals.Hook, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	hook, err := h.service.GetHook(mux.Vars(r)["hookId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Signal webhook not found")
+		return nil, false
+	}
+	if hook.UserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+
+	return hook, true
+}
+
+// signalErrorStatus returns the status of the response to an error of the signal
+// service
+func signalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, signals.ErrHookNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, signals.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, signals.ErrReplayedSignal):
+		return http.StatusConflict
+	case errors.Is(err, signals.ErrInvalidHook), errors.Is(err, signals.ErrInvalidSignal), errors.Is(err, signals.ErrStaleSignal):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
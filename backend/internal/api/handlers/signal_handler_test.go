@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/signals"
+)
+
+// MockSignalService is a mock implementation of the SignalService interface
+type MockSignalService struct {
+	mock.Mock
+}
+
+func (m *MockSignalService) CreateHook(userID string, request signals.HookRequest) (*signals.Hook, error) {
+	args := m.Called(userID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*signals.Hook), args.Error(1)
+}
+
+func (m *MockSignalService) GetHook(id string) (*signals.Hook, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*signals.Hook), args.Error(1)
+}
+
+func (m *MockSignalService) ListHooks(userID string) ([]signals.Hook, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]signals.Hook), args.Error(1)
+}
+
+func (m *MockSignalService) UpdateHook(id string, request signals.HookRequest) (*signals.Hook, error) {
+	args := m.Called(id, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*signals.Hook), args.Error(1)
+}
+
+func (m *MockSignalService) DeleteHook(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockSignalService) ListSignals(filter signals.SignalFilter) ([]signals.Signal, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]signals.Signal), args.Error(1)
+}
+
+func (m *MockSignalService) Receive(hookID string, body []byte, secret string, now time.Time) (*signals.Signal, error) {
+	args := m.Called(hookID, string(body), secret)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*signals.Signal), args.Error(1)
+}
+
+func TestReceiveSignal(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockSignalService)
+	handler := NewSignalHandler(mockService)
+
+	mockService.On("Receive", "hook1", `{"ticker":"NIFTY","action":"buy"}`, "secret").
+		Return(&signals.Signal{ID: "signal1", HookID: "hook1", Symbol: "NIFTY", Status: signals.SignalAccepted}, nil)
+	mockService.On("Receive", "hook1", `{"ticker":"NIFTY","action":"buy"}`, "").Return(nil, signals.ErrUnauthorized)
+	mockService.On("Receive", "hook1", `{"id":"alert1"}`, "secret").Return(nil, signals.ErrReplayedSignal)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/signals/webhook/{hookId}", handler.ReceiveSignal).Methods("POST")
+
+	// Signals are accepted without authentication by the auth middleware
+	req := httptest.NewRequest("POST", "/api/signals/webhook/hook1", strings.NewReader(`{"ticker":"NIFTY","action":"buy"}`))
+	req.Header.Set(signals.HeaderSecret, "secret")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	var signal signals.Signal
+	err := json.Unmarshal(rr.Body.Bytes(), &signal)
+	assert.NoError(t, err)
+	assert.Equal(t, "signal1", signal.ID)
+
+	// Signals without the secret, and replayed signals, are rejected
+	req = httptest.NewRequest("POST", "/api/signals/webhook/hook1", strings.NewReader(`{"ticker":"NIFTY","action":"buy"}`))
+	rr = httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	req = httptest.NewRequest("POST", "/api/signals/webhook/hook1", strings.NewReader(`{"id":"alert1"}`))
+	req.Header.Set(signals.HeaderSecret, "secret")
+	rr = httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// Oversized signals are not read
+	req = httptest.NewRequest("POST", "/api/signals/webhook/hook1", strings.NewReader(strings.Repeat(" ", maxSignalSize+1)))
+	rr = httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateSignalHook(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockSignalService)
+	handler := NewSignalHandler(mockService)
+
+	mockService.On("CreateHook", "user123", signals.HookRequest{Name: "TradingView"}).
+		Return(&signals.Hook{ID: "hook1", UserID: "user123", Name: "TradingView", Secret: "sigsec_secret"}, nil)
+	mockService.On("CreateHook", "user123", signals.HookRequest{Name: "TradingView", Secret: "short"}).Return(nil, signals.ErrInvalidHook)
+
+	// The secret is returned on creation
+	req := httptest.NewRequest("POST", "/api/signals/hooks", strings.NewReader(`{"name":"TradingView"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.CreateHook(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var hook signals.Hook
+	err := json.Unmarshal(rr.Body.Bytes(), &hook)
+	assert.NoError(t, err)
+	assert.Equal(t, "sigsec_secret", hook.Secret)
+
+	// Invalid webhooks are rejected
+	req = httptest.NewRequest("POST", "/api/signals/hooks", strings.NewReader(`{"name":"TradingView","secret":"short"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+
+	handler.CreateHook(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetSignals(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockSignalService)
+	handler := NewSignalHandler(mockService)
+
+	mockService.On("GetHook", "hook1").Return(&signals.Hook{ID: "hook1", UserID: "user123", Secret: "sigsec_secret"}, nil)
+	mockService.On("ListSignals", signals.SignalFilter{HookID: "hook1", Limit: defaultSignalLimit}).
+		Return([]signals.Signal{{ID: "signal1", HookID: "hook1", Status: signals.SignalExecuted}}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/signals/hooks/{hookId}", handler.GetHook).Methods("GET")
+	router.HandleFunc("/api/signals/hooks/{hookId}/signals", handler.GetSignals).Methods("GET")
+
+	// Users may not see the signals of others' webhooks
+	req := httptest.NewRequest("GET", "/api/signals/hooks/hook1/signals", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user456"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockService.AssertNotCalled(t, "ListSignals", mock.Anything)
+
+	req = httptest.NewRequest("GET", "/api/signals/hooks/hook1/signals", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var received []signals.Signal
+	err := json.Unmarshal(rr.Body.Bytes(), &received)
+	assert.NoError(t, err)
+	assert.Len(t, received, 1)
+
+	// Secrets are not returned after creation
+	req = httptest.NewRequest("GET", "/api/signals/hooks/hook1", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "sigsec_secret")
+
+	mockService.AssertExpectations(t)
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 	
 	"github.com/gorilla/mux"
@@ -17,16 +20,24 @@ type SimulationHandler struct {
 	simulationOrderService   *simulation.SimulationOrderService
 	marketSimulationService  *simulation.MarketSimulationService
 	backtestService          *simulation.BacktestService
+	backtestScheduler        *simulation.BacktestScheduler
 }
 
 // NewSimulationHandler creates a new instance of SimulationHandler
 func NewSimulationHandler() *SimulationHandler {
+	backtestService := simulation.NewBacktestService()
+	
+	// Run scheduled backtests on 4 workers, 2 at a time per user
+	backtestScheduler := simulation.NewBacktestScheduler(backtestService, 4, 2)
+	backtestScheduler.Start(context.Background())
+	
 	return &SimulationHandler{
 		simulationAccountService: simulation.NewSimulationAccountService(),
 		virtualBalanceService:    simulation.NewVirtualBalanceService(),
 		simulationOrderService:   simulation.NewSimulationOrderService(),
 		marketSimulationService:  simulation.NewMarketSimulationService(),
-		backtestService:          simulation.NewBacktestService(),
+		backtestService:          backtestService,
+		backtestScheduler:        backtestScheduler,
 	}
 }
 
@@ -814,7 +825,35 @@ func (h *SimulationHandler) OptimizeStrategy(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(results)
 }
 
-// ExportBacktestResults handles exporting backtest results to a specified format
+// ValidateBacktestHoldout handles optimizing a session's strategy on its training window
+// and measuring how the result holds up on the session's holdout period
+func (h *SimulationHandler) ValidateBacktestHoldout(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var requestData struct {
+		Session            models.BacktestSession            `json:"session"`
+		ParameterRanges    map[string]map[string]interface{} `json:"parameterRanges"`
+		OptimizationMetric string                            `json:"optimizationMetric"`
+		Optimization       models.OptimizationConfig         `json:"optimization"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	
+	// Run validation
+	result, err := h.backtestService.RunHoldoutValidation(&requestData.Session, requestData.ParameterRanges, requestData.OptimizationMetric, requestData.Optimization)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	// Return result
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ExportBacktestResults handles rendering a backtest report as CSV, HTML or PDF. The report
+// is streamed to the client, or saved to report storage when store=true.
 func (h *SimulationHandler) ExportBacktestResults(w http.ResponseWriter, r *http.Request) {
 	// Extract session ID from URL
 	vars := mux.Vars(r)
@@ -826,14 +865,236 @@ func (h *SimulationHandler) ExportBacktestResults(w http.ResponseWriter, r *http
 		format = "csv" // Default to CSV
 	}
 	
+	// Store the report and return its location
+	if r.URL.Query().Get("store") == "true" {
+		export, err := h.backtestService.StoreBacktestReport(sessionID, format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(export)
+		return
+	}
+	
 	// Export backtest results
-	filePath, err := h.backtestService.ExportBacktestResults(sessionID, format)
+	export, err := h.backtestService.ExportBacktestResults(sessionID, format)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	
-	// Return file path
+	// Stream report
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+export.FileName+"\"")
+	w.Header().Set("Content-Length", strconv.Itoa(len(export.Data)))
+	w.Write(export.Data)
+}
+
+// UploadBacktestData handles uploading an OHLCV or tick CSV file as a virtual symbol for backtesting
+func (h *SimulationHandler) UploadBacktestData(w http.ResponseWriter, r *http.Request) {
+	// Extract account ID from URL
+	vars := mux.Vars(r)
+	accountID := vars["accountID"]
+	
+	// Parse multipart form with the file in the "file" field
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	
+	// Import data
+	dataset, err := h.backtestService.ImportCustomData(accountID, r.FormValue("symbol"), r.FormValue("timeframe"), file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	// Return created dataset
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dataset)
+}
+
+// GetBacktestDatasets handles the retrieval of the datasets uploaded by an account
+func (h *SimulationHandler) GetBacktestDatasets(w http.ResponseWriter, r *http.Request) {
+	// Extract account ID from URL
+	vars := mux.Vars(r)
+	accountID := vars["accountID"]
+	
+	// Get datasets
+	datasets, err := h.backtestService.GetCustomDatasets(accountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	// Return datasets
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(datasets)
+}
+
+// DeleteBacktestDataset handles deleting an uploaded dataset
+func (h *SimulationHandler) DeleteBacktestDataset(w http.ResponseWriter, r *http.Request) {
+	// Extract account ID and symbol from URL
+	vars := mux.Vars(r)
+	accountID := vars["accountID"]
+	symbol := vars["symbol"]
+	
+	// Delete dataset
+	if err := h.backtestService.DeleteCustomDataset(accountID, symbol); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunStrategyBacktest handles running a Go strategy backtest, including on uploaded data
+func (h *SimulationHandler) RunStrategyBacktest(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var requestData struct {
+		Session        models.BacktestSession `json:"session"`
+		MarketSettings *models.MarketSettings `json:"marketSettings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	
+	// Run backtest
+	report, err := h.backtestService.RunStrategyBacktest(&requestData.Session, requestData.MarketSettings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	// Return report
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// CreateRuleStrategy handles registering a strategy declared as JSON or YAML rules
+func (h *SimulationHandler) CreateRuleStrategy(w http.ResponseWriter, r *http.Request) {
+	// Read request body
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	
+	// Parse and compile rules
+	definition, err := simulation.ParseRuleStrategy(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	// Register strategy
+	name, err := simulation.RegisterRuleStrategy(definition)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	
+	// Return the name to run the strategy with
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"strategyName": name})
+}
+
+// SubmitScheduledBacktest handles queueing a strategy backtest on the backtest scheduler
+func (h *SimulationHandler) SubmitScheduledBacktest(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := r.Context().Value("userID").(string)
+	
+	// Parse request body
+	var requestData struct {
+		Session        models.BacktestSession `json:"session"`
+		MarketSettings *models.MarketSettings `json:"marketSettings"`
+		Priority       string                 `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	
+	// Queue backtest
+	backtest, err := h.backtestScheduler.Submit(userID, requestData.Priority, requestData.Session, requestData.MarketSettings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	// Return queued backtest
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(backtest)
+}
+
+// GetScheduledBacktests handles listing the user's scheduled backtests
+func (h *SimulationHandler) GetScheduledBacktests(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := r.Context().Value("userID").(string)
+	
+	// Get backtests
+	backtests, err := h.backtestScheduler.List(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	
+	// Return backtests
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backtests)
+}
+
+// GetScheduledBacktest handles retrieving a scheduled backtest with its state and report
+func (h *SimulationHandler) GetScheduledBacktest(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := r.Context().Value("userID").(string)
+	
+	// Extract backtest ID from URL
+	vars := mux.Vars(r)
+	backtestID := vars["backtestID"]
+	
+	// Get backtest
+	backtest, err := h.backtestScheduler.Get(userID, backtestID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	
+	// Return backtest
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backtest)
+}
+
+// CancelScheduledBacktest handles cancelling a queued or running backtest
+func (h *SimulationHandler) CancelScheduledBacktest(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := r.Context().Value("userID").(string)
+	
+	// Extract backtest ID from URL
+	vars := mux.Vars(r)
+	backtestID := vars["backtestID"]
+	
+	// Cancel backtest
+	backtest, err := h.backtestScheduler.Cancel(userID, backtestID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	
+	// Return cancelled backtest
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"filePath": filePath})
+	json.NewEncoder(w).Encode(backtest)
 }
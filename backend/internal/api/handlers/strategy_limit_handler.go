@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// StrategyLimitMonitor is the part of the strategy limit monitor the handler uses
+type StrategyLimitMonitor interface {
+	GetStatus(strategyID string) (*risk.StrategyLimitStatus, error)
+	Rearm(strategyID string, rearmedBy string) (*risk.StrategyLimitStatus, error)
+}
+
+// StrategyLimitHandler handles strategy daily limit API endpoints
+type StrategyLimitHandler struct {
+	monitor StrategyLimitMonitor
+}
+
+// NewStrategyLimitHandler creates a new StrategyLimitHandler
+func NewStrategyLimitHandler(monitor StrategyLimitMonitor) *StrategyLimitHandler {
+	return &StrategyLimitHandler{
+		monitor: monitor,
+	}
+}
+
+// GetStatus handles retrieving where a strategy stands against its daily limits.
+// Users may only see their own strategies, admins anyone's.
+func (h *StrategyLimitHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	status, ok := h.strategyStatus(w, r)
+	if !ok {
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, status)
+}
+
+// Rearm handles re-arming a strategy halted on breaching a daily limit. Users may
+// only re-arm their own strategies, admins anyone's.
+func (h *StrategyLimitHandler) Rearm(w http.ResponseWriter, r *http.Request) {
+	status, ok := h.strategyStatus(w, r)
+	if !ok {
+		return
+	}
+	if !status.Halted {
+		utils.RespondWithError(w, http.StatusConflict, "Strategy is not halted")
+		return
+	}
+
+	status, err := h.monitor.Rearm(status.StrategyID, auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, status)
+}
+
+// strategyStatus returns the status of the strategy of the request, responding
+// with an error when there is none or it is not the user's
+func (h *StrategyLimitHandler) strategyStatus(w http.ResponseWriter, r *http.Request) (*risk.StrategyLimitStatus, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	status, err := h.monitor.GetStatus(mux.Vars(r)["strategyId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+
+	if status.UserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+
+	return status, true
+}
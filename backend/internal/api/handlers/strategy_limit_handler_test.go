@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+)
+
+// MockStrategyLimitMonitor is a mock implementation of the StrategyLimitMonitor interface
+type MockStrategyLimitMonitor struct {
+	mock.Mock
+}
+
+func (m *MockStrategyLimitMonitor) GetStatus(strategyID string) (*risk.StrategyLimitStatus, error) {
+	args := m.Called(strategyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.StrategyLimitStatus), args.Error(1)
+}
+
+func (m *MockStrategyLimitMonitor) Rearm(strategyID string, rearmedBy string) (*risk.StrategyLimitStatus, error) {
+	args := m.Called(strategyID, rearmedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*risk.StrategyLimitStatus), args.Error(1)
+}
+
+func TestGetStrategyLimitStatus(t *testing.T) {
+	// Create handler with mock monitor
+	mockMonitor := new(MockStrategyLimitMonitor)
+	handler := NewStrategyLimitHandler(mockMonitor)
+
+	mockMonitor.On("GetStatus", "strategy1").Return(&risk.StrategyLimitStatus{StrategyID: "strategy1", UserID: "user123", PnL: -2500, MaxLoss: 5000}, nil)
+	mockMonitor.On("GetStatus", "strategy2").Return(nil, errors.New("strategy not found"))
+
+	// A user may see their own strategy's limits
+	req := httptest.NewRequest("GET", "/api/strategies/strategy1/limits", nil)
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "strategy1"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetStatus(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var status risk.StrategyLimitStatus
+	err := json.Unmarshal(rr.Body.Bytes(), &status)
+	assert.NoError(t, err)
+	assert.Equal(t, -2500.0, status.PnL)
+
+	// But not anyone else's
+	req = httptest.NewRequest("GET", "/api/strategies/strategy1/limits", nil)
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "strategy1"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user456"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetStatus(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	req = httptest.NewRequest("GET", "/api/strategies/strategy2/limits", nil)
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "strategy2"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.GetStatus(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestRearmStrategy(t *testing.T) {
+	// Create handler with mock monitor
+	mockMonitor := new(MockStrategyLimitMonitor)
+	handler := NewStrategyLimitHandler(mockMonitor)
+
+	mockMonitor.On("GetStatus", "strategy1").Return(&risk.StrategyLimitStatus{StrategyID: "strategy1", UserID: "user123", Halted: true}, nil)
+	mockMonitor.On("GetStatus", "strategy2").Return(&risk.StrategyLimitStatus{StrategyID: "strategy2", UserID: "user123"}, nil)
+	mockMonitor.On("Rearm", "strategy1", "admin1").Return(&risk.StrategyLimitStatus{StrategyID: "strategy1", UserID: "user123", Status: models.StrategyStatusActive}, nil)
+
+	// Admins may re-arm anyone's halted strategy
+	req := httptest.NewRequest("POST", "/api/strategies/strategy1/rearm", nil)
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "strategy1"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr := httptest.NewRecorder()
+
+	handler.Rearm(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var status risk.StrategyLimitStatus
+	err := json.Unmarshal(rr.Body.Bytes(), &status)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StrategyStatusActive, status.Status)
+
+	// Strategies that are not halted need no re-arming
+	req = httptest.NewRequest("POST", "/api/strategies/strategy2/rearm", nil)
+	req = mux.SetURLVars(req, map[string]string{"strategyId": "strategy2"})
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	handler.Rearm(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	mockMonitor.AssertNumberOfCalls(t, "Rearm", 1)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/summary"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// SummaryService is the part of the daily summary service the handler uses
+type SummaryService interface {
+	Compile(userID, date string) (*summary.Summary, error)
+	GetSubscription(userID string) (*summary.Subscription, error)
+	UpdateSubscription(userID string, subscription summary.Subscription) (*summary.Subscription, error)
+}
+
+// SummaryHandler handles daily P&L and activity summary API endpoints
+type SummaryHandler struct {
+	service SummaryService
+}
+
+// NewSummaryHandler creates a new SummaryHandler
+func NewSummaryHandler(service SummaryService) *SummaryHandler {
+	return &SummaryHandler{
+		service: service,
+	}
+}
+
+// GetDailySummary handles compiling the caller's summary of the date query
+// parameter, today when it is not given, as JSON or as the HTML report emailed
+// when the format query parameter is html. Admins may give the userId query
+// parameter.
+func (h *SummaryHandler) GetDailySummary(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	if other := query.Get("userId"); other != "" && auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = other
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "html" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Format must be json or html")
+		return
+	}
+
+	compiled, err := h.service.Compile(userID, query.Get("date"))
+	if err != nil {
+		if errors.Is(err, summary.ErrInvalidDate) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error compiling daily summary")
+		return
+	}
+
+	if format != "html" {
+		utils.RespondWithJSON(w, http.StatusOK, compiled)
+		return
+	}
+
+	email, err := summary.Render(compiled)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error rendering daily summary")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(email.HTML))
+}
+
+// GetSubscription handles retrieving the caller's opt-in to daily summary emails
+func (h *SummaryHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	subscription, err := h.service.GetSubscription(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving daily summary subscription")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, subscription)
+}
+
+// UpdateSubscription handles opting the caller in to or out of daily summary
+// emails
+func (h *SummaryHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var request summary.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	subscription, err := h.service.UpdateSubscription(userID, request)
+	if err != nil {
+		if errors.Is(err, summary.ErrInvalidSendTime) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating daily summary subscription")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, subscription)
+}
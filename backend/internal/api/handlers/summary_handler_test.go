@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/summary"
+)
+
+// MockSummaryService is a mock implementation of the SummaryService interface
+type MockSummaryService struct {
+	mock.Mock
+}
+
+func (m *MockSummaryService) Compile(userID, date string) (*summary.Summary, error) {
+	args := m.Called(userID, date)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*summary.Summary), args.Error(1)
+}
+
+func (m *MockSummaryService) GetSubscription(userID string) (*summary.Subscription, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*summary.Subscription), args.Error(1)
+}
+
+func (m *MockSummaryService) UpdateSubscription(userID string, subscription summary.Subscription) (*summary.Subscription, error) {
+	args := m.Called(userID, subscription)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*summary.Subscription), args.Error(1)
+}
+
+func TestGetDailySummary(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockSummaryService)
+	handler := NewSummaryHandler(mockService)
+
+	compiled := &summary.Summary{UserID: "user123", Name: "Asha", Date: "2024-03-14", RealizedPnL: 1500, NetPnL: 1450, Fees: 50}
+
+	// Users only get their own summary, whatever user they asked for
+	mockService.On("Compile", "user123", "2024-03-14").Return(compiled, nil)
+
+	req := httptest.NewRequest("GET", "/api/reports/daily?date=2024-03-14&userId=user456", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetDailySummary(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response summary.Summary
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, 1450.0, response.NetPnL)
+
+	// The HTML report is the one emailed
+	req = httptest.NewRequest("GET", "/api/reports/daily?date=2024-03-14&format=html", nil)
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+
+	handler.GetDailySummary(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.True(t, strings.Contains(rr.Body.String(), "Hi Asha,"))
+
+	// Admins get anyone's
+	mockService.On("Compile", "user456", "").Return(&summary.Summary{UserID: "user456"}, nil).Once()
+
+	req = httptest.NewRequest("GET", "/api/reports/daily?userId=user456", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "admin1"), string(models.UserRoleAdmin)))
+	rr = httptest.NewRecorder()
+
+	handler.GetDailySummary(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Invalid dates and formats are rejected
+	mockService.On("Compile", "user123", "yesterday").Return(nil, summary.ErrInvalidDate).Once()
+	for _, query := range []string{"date=yesterday", "format=pdf"} {
+		req = httptest.NewRequest("GET", "/api/reports/daily?"+query, nil)
+		req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+		rr = httptest.NewRecorder()
+
+		handler.GetDailySummary(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	// Unauthenticated requests are rejected
+	req = httptest.NewRequest("GET", "/api/reports/daily", nil)
+	rr = httptest.NewRecorder()
+
+	handler.GetDailySummary(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateSummarySubscription(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockSummaryService)
+	handler := NewSummaryHandler(mockService)
+
+	subscription := summary.Subscription{Enabled: true, SendTime: "19:30"}
+	mockService.On("UpdateSubscription", "user123", subscription).Return(&summary.Subscription{Enabled: true, SendTime: "19:30", TimeZone: "Asia/Kolkata"}, nil)
+
+	req := httptest.NewRequest("PUT", "/api/reports/daily/subscription", strings.NewReader(`{"enabled":true,"sendTime":"19:30"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.UpdateSubscription(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response summary.Subscription
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "Asia/Kolkata", response.TimeZone)
+
+	// Invalid send times are rejected
+	mockService.On("UpdateSubscription", "user123", summary.Subscription{Enabled: true, SendTime: "7pm"}).Return(nil, summary.ErrInvalidSendTime)
+
+	req = httptest.NewRequest("PUT", "/api/reports/daily/subscription", strings.NewReader(`{"enabled":true,"sendTime":"7pm"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+
+	handler.UpdateSubscription(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Failures to read the subscription are errors
+	mockService.On("GetSubscription", "user123").Return(nil, errors.New("database error"))
+
+	req = httptest.NewRequest("GET", "/api/reports/daily/subscription", nil)
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+
+	handler.GetSubscription(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	mockService.AssertExpectations(t)
+}
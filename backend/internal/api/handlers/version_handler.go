@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/versions"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// VersionService is the part of the versions service the handler uses
+type VersionService interface {
+	Owner(kind versions.Kind, entityID string) (string, error)
+	ListVersions(kind versions.Kind, entityID string) ([]versions.Version, error)
+	GetVersion(kind versions.Kind, entityID string, number int) (*versions.Version, error)
+	Diff(kind versions.Kind, entityID string, from, to int) (*versions.Diff, error)
+	Rollback(kind versions.Kind, entityID string, number int, userID string) (*versions.Version, error)
+}
+
+// VersionHandler handles the API endpoints of the configuration versions of
+// strategies and portfolios, under the strategyId or portfolioId path parameter.
+// Users see and roll back the versions of their own strategies and portfolios,
+// admins everyone's.
+type VersionHandler struct {
+	service VersionService
+}
+
+// NewVersionHandler creates a new VersionHandler
+func NewVersionHandler(service VersionService) *VersionHandler {
+	return &VersionHandler{
+		service: service,
+	}
+}
+
+// GetVersions handles listing the versions of a strategy or portfolio, newest
+// first
+func (h *VersionHandler) GetVersions(w http.ResponseWriter, r *http.Request) {
+	kind, entityID, _, ok := h.ownedEntity(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.service.ListVersions(kind, entityID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving versions")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, list)
+}
+
+// GetVersion handles retrieving the version of the version path parameter
+func (h *VersionHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	kind, entityID, _, ok := h.ownedEntity(w, r)
+	if !ok {
+		return
+	}
+	number, ok := versionNumber(w, mux.Vars(r)["version"])
+	if !ok {
+		return
+	}
+
+	version, err := h.service.GetVersion(kind, entityID, number)
+	if err != nil {
+		utils.RespondWithError(w, versionErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, version)
+}
+
+// GetDiff handles comparing the version of the version path parameter with the
+// one of the against query parameter, the version before it when not given
+func (h *VersionHandler) GetDiff(w http.ResponseWriter, r *http.Request) {
+	kind, entityID, _, ok := h.ownedEntity(w, r)
+	if !ok {
+		return
+	}
+	number, ok := versionNumber(w, mux.Vars(r)["version"])
+	if !ok {
+		return
+	}
+
+	against := number - 1
+	if againstStr := r.URL.Query().Get("against"); againstStr != "" {
+		if against, ok = versionNumber(w, againstStr); !ok {
+			return
+		}
+	}
+	if against < 1 {
+		utils.RespondWithError(w, http.StatusBadRequest, "The first version has no version before it to compare with")
+		return
+	}
+
+	diff, err := h.service.Diff(kind, entityID, against, number)
+	if err != nil {
+		utils.RespondWithError(w, versionErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, diff)
+}
+
+// Rollback handles restoring the version of the version path parameter,
+// responding with the new version it is saved as
+func (h *VersionHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	kind, entityID, userID, ok := h.ownedEntity(w, r)
+	if !ok {
+		return
+	}
+	number, ok := versionNumber(w, mux.Vars(r)["version"])
+	if !ok {
+		return
+	}
+
+	version, err := h.service.Rollback(kind, entityID, number, userID)
+	if err != nil {
+		utils.RespondWithError(w, versionErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, version)
+}
+
+// ownedEntity returns the kind and ID of the strategy or portfolio of the path
+// parameters, and the caller's ID, responding with an error when the caller is
+// not authenticated, it does not exist or it is not the caller's and the caller
+// is not an admin
+func (h *VersionHandler) ownedEntity(w http.ResponseWriter, r *http.Request) (versions.Kind, string, string, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", "", "", false
+	}
+
+	vars := mux.Vars(r)
+	kind, entityID := versions.KindStrategy, vars["strategyId"]
+	if entityID == "" {
+		kind, entityID = versions.KindPortfolio, vars["portfolioId"]
+	}
+
+	owner, err := h.service.Owner(kind, entityID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Strategy or portfolio not found")
+		return "", "", "", false
+	}
+	if owner != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return "", "", "", false
+	}
+
+	return kind, entityID, userID, true
+}
+
+// versionNumber parses a version number, responding with an error when it is
+// invalid
+func versionNumber(w http.ResponseWriter, numberStr string) (int, bool) {
+	number, err := utils.ParseInt(numberStr)
+	if err != nil || number < 1 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid version")
+		return 0, false
+	}
+	return number, true
+}
+
+// versionErrorStatus returns the status of the response to an error of the
+// versions service
+func versionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, versions.ErrVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, versions.ErrActivePortfolio):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/versions"
+)
+
+// MockVersionService is a mock implementation of the VersionService interface
+type MockVersionService struct {
+	mock.Mock
+}
+
+func (m *MockVersionService) Owner(kind versions.Kind, entityID string) (string, error) {
+	args := m.Called(kind, entityID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockVersionService) ListVersions(kind versions.Kind, entityID string) ([]versions.Version, error) {
+	args := m.Called(kind, entityID)
+	return args.Get(0).([]versions.Version), args.Error(1)
+}
+
+func (m *MockVersionService) GetVersion(kind versions.Kind, entityID string, number int) (*versions.Version, error) {
+	args := m.Called(kind, entityID, number)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*versions.Version), args.Error(1)
+}
+
+func (m *MockVersionService) Diff(kind versions.Kind, entityID string, from, to int) (*versions.Diff, error) {
+	args := m.Called(kind, entityID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*versions.Diff), args.Error(1)
+}
+
+func (m *MockVersionService) Rollback(kind versions.Kind, entityID string, number int, userID string) (*versions.Version, error) {
+	args := m.Called(kind, entityID, number, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*versions.Version), args.Error(1)
+}
+
+// versionRouter routes the version endpoints of strategies and portfolios
+func versionRouter(handler *VersionHandler) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/strategies/{strategyId}/versions", handler.GetVersions).Methods("GET")
+	router.HandleFunc("/api/portfolios/{portfolioId}/versions/{version}", handler.GetVersion).Methods("GET")
+	router.HandleFunc("/api/portfolios/{portfolioId}/versions/{version}/diff", handler.GetDiff).Methods("GET")
+	router.HandleFunc("/api/portfolios/{portfolioId}/versions/{version}/rollback", handler.Rollback).Methods("POST")
+	return router
+}
+
+// versionRequest creates a request of a trader
+func versionRequest(method, target, userID string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	return req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), userID), string(models.UserRoleTrader)))
+}
+
+func TestGetVersions(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockVersionService)
+	router := versionRouter(NewVersionHandler(mockService))
+
+	mockService.On("Owner", versions.KindStrategy, "strategy1").Return("user123", nil)
+	mockService.On("Owner", versions.KindStrategy, "missing").Return("", errors.New("strategy not found"))
+	mockService.On("ListVersions", versions.KindStrategy, "strategy1").
+		Return([]versions.Version{{Kind: versions.KindStrategy, EntityID: "strategy1", Number: 2}, {Kind: versions.KindStrategy, EntityID: "strategy1", Number: 1}}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("GET", "/api/strategies/strategy1/versions", "user123"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var listed []versions.Version
+	err := json.Unmarshal(rr.Body.Bytes(), &listed)
+	assert.NoError(t, err)
+	assert.Len(t, listed, 2)
+
+	// Users may not see the versions of others' strategies
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("GET", "/api/strategies/strategy1/versions", "user456"))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("GET", "/api/strategies/missing/versions", "user123"))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	mockService.AssertNumberOfCalls(t, "ListVersions", 1)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetVersionDiff(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockVersionService)
+	router := versionRouter(NewVersionHandler(mockService))
+
+	mockService.On("Owner", versions.KindPortfolio, "portfolio1").Return("user123", nil)
+	mockService.On("Diff", versions.KindPortfolio, "portfolio1", 2, 3).
+		Return(&versions.Diff{From: 2, To: 3, Changes: []versions.Change{{Path: "stopLossValue", From: 1000.0, To: 1500.0}}}, nil)
+	mockService.On("Diff", versions.KindPortfolio, "portfolio1", 1, 3).Return(&versions.Diff{From: 1, To: 3}, nil)
+	mockService.On("GetVersion", versions.KindPortfolio, "portfolio1", 9).Return(nil, versions.ErrVersionNotFound)
+
+	// Versions are compared with the one before them by default
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("GET", "/api/portfolios/portfolio1/versions/3/diff", "user123"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var diff versions.Diff
+	err := json.Unmarshal(rr.Body.Bytes(), &diff)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, diff.From)
+	assert.Len(t, diff.Changes, 1)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("GET", "/api/portfolios/portfolio1/versions/3/diff?against=1", "user123"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// The first version has nothing before it, and versions must be numbers
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("GET", "/api/portfolios/portfolio1/versions/1/diff", "user123"))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("GET", "/api/portfolios/portfolio1/versions/latest", "user123"))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("GET", "/api/portfolios/portfolio1/versions/9", "user123"))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRollbackVersion(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockVersionService)
+	router := versionRouter(NewVersionHandler(mockService))
+
+	mockService.On("Owner", versions.KindPortfolio, "portfolio1").Return("user123", nil)
+	mockService.On("Rollback", versions.KindPortfolio, "portfolio1", 1, "user123").
+		Return(&versions.Version{Kind: versions.KindPortfolio, EntityID: "portfolio1", Number: 4, RolledBackFrom: 1}, nil).Once()
+	mockService.On("Rollback", versions.KindPortfolio, "portfolio1", 1, "user123").Return(nil, versions.ErrActivePortfolio)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("POST", "/api/portfolios/portfolio1/versions/1/rollback", "user123"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var version versions.Version
+	err := json.Unmarshal(rr.Body.Bytes(), &version)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, version.Number)
+	assert.Equal(t, 1, version.RolledBackFrom)
+
+	// Active portfolios are not rolled back
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("POST", "/api/portfolios/portfolio1/versions/1/rollback", "user123"))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// Users may not roll back others' portfolios
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, versionRequest("POST", "/api/portfolios/portfolio1/versions/1/rollback", "user456"))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	mockService.AssertNumberOfCalls(t, "Rollback", 2)
+	mockService.AssertExpectations(t)
+}
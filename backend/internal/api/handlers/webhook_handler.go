@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/webhooks"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// defaultDeliveryLimit is the number of deliveries returned when no limit is given
+const defaultDeliveryLimit = 100
+
+// WebhookService is the part of the webhook service the handler uses
+type WebhookService interface {
+	CreateWebhook(userID string, request webhooks.Request) (*webhooks.Webhook, error)
+	GetWebhook(id string) (*webhooks.Webhook, error)
+	ListWebhooks(userID string) ([]webhooks.Webhook, error)
+	UpdateWebhook(id string, request webhooks.Request) (*webhooks.Webhook, error)
+	DeleteWebhook(id string) error
+	ListDeliveries(filter webhooks.DeliveryFilter) ([]webhooks.Delivery, error)
+}
+
+// WebhookHandler handles webhook registration and delivery log API endpoints.
+// Users manage their own webhooks, admins everyone's.
+type WebhookHandler struct {
+	service WebhookService
+}
+
+// NewWebhookHandler creates a new WebhookHandler
+func NewWebhookHandler(service WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		service: service,
+	}
+}
+
+// GetWebhooks handles listing the caller's webhooks, or for admins those of the
+// userId query parameter, every webhook when it is not given
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if auth.GetRoleFromContext(r.Context()) == string(models.UserRoleAdmin) {
+		userID = r.URL.Query().Get("userId")
+	}
+
+	registered, err := h.service.ListWebhooks(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving webhooks")
+		return
+	}
+	for i := range registered {
+		registered[i].Secret = ""
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, registered)
+}
+
+// CreateWebhook handles registering a webhook for the caller. The response holds
+// the webhook's secret, which is not returned again.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var request webhooks.Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	created, err := h.service.CreateWebhook(userID, request)
+	if err != nil {
+		utils.RespondWithError(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, created)
+}
+
+// GetWebhook handles retrieving a webhook by ID
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+	webhook.Secret = ""
+
+	utils.RespondWithJSON(w, http.StatusOK, webhook)
+}
+
+// UpdateWebhook handles changing the URL, events, secret or state of a webhook
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse request body
+	var request webhooks.Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	updated, err := h.service.UpdateWebhook(webhook.ID, request)
+	if err != nil {
+		utils.RespondWithError(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+	updated.Secret = ""
+
+	utils.RespondWithJSON(w, http.StatusOK, updated)
+}
+
+// DeleteWebhook handles removing a webhook
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteWebhook(webhook.ID); err != nil {
+		utils.RespondWithError(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted successfully"})
+}
+
+// GetDeliveries handles listing the deliveries of a webhook, newest first,
+// optionally of the status query parameter only
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := h.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := webhooks.DeliveryFilter{
+		WebhookID: webhook.ID,
+		Status:    webhooks.DeliveryStatus(query.Get("status")),
+		Limit:     defaultDeliveryLimit,
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := utils.ParseInt(limitStr)
+		if err != nil || limit <= 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	deliveries, err := h.service.ListDeliveries(filter)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving webhook deliveries")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// ownedWebhook returns the webhook of the webhookId path parameter, responding
+// with an error when the caller is not authenticated, it does not exist or it is
+// not the caller's and the caller is not an admin
+func (h *WebhookHandler) ownedWebhook(w http.ResponseWriter, r *http.Request) (*webhooks.Webhook, bool) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, false
+	}
+
+	webhook, err := h.service.GetWebhook(mux.Vars(r)["webhookId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Webhook not found")
+		return nil, false
+	}
+	if webhook.UserID != userID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+// webhookErrorStatus returns the status of the response to an error of the
+// webhook service
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, webhooks.ErrWebhookNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, webhooks.ErrInvalidURL), errors.Is(err, webhooks.ErrSecretTooShort), errors.Is(err, webhooks.ErrUnknownEvent):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
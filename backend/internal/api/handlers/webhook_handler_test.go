@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/webhooks"
+)
+
+// MockWebhookService is a mock implementation of the WebhookService interface
+type MockWebhookService struct {
+	mock.Mock
+}
+
+func (m *MockWebhookService) CreateWebhook(userID string, request webhooks.Request) (*webhooks.Webhook, error) {
+	args := m.Called(userID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*webhooks.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) GetWebhook(id string) (*webhooks.Webhook, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*webhooks.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) ListWebhooks(userID string) ([]webhooks.Webhook, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]webhooks.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) UpdateWebhook(id string, request webhooks.Request) (*webhooks.Webhook, error) {
+	args := m.Called(id, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*webhooks.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) DeleteWebhook(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) ListDeliveries(filter webhooks.DeliveryFilter) ([]webhooks.Delivery, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]webhooks.Delivery), args.Error(1)
+}
+
+func TestCreateWebhook(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockWebhookService)
+	handler := NewWebhookHandler(mockService)
+
+	mockService.On("CreateWebhook", "user123", webhooks.Request{URL: "https://example.com/hooks", Events: []webhooks.EventType{webhooks.EventOrderFill}}).
+		Return(&webhooks.Webhook{ID: "webhook1", UserID: "user123", URL: "https://example.com/hooks", Secret: "whsec_secret"}, nil)
+	mockService.On("CreateWebhook", "user123", webhooks.Request{URL: "ftp://example.com/hooks"}).Return(nil, webhooks.ErrInvalidURL)
+
+	// The secret is returned on creation
+	req := httptest.NewRequest("POST", "/api/webhooks", strings.NewReader(`{"url":"https://example.com/hooks","events":["order.fill"]}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr := httptest.NewRecorder()
+
+	handler.CreateWebhook(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var webhook webhooks.Webhook
+	err := json.Unmarshal(rr.Body.Bytes(), &webhook)
+	assert.NoError(t, err)
+	assert.Equal(t, "webhook1", webhook.ID)
+	assert.Equal(t, "whsec_secret", webhook.Secret)
+
+	// Invalid webhooks are rejected
+	req = httptest.NewRequest("POST", "/api/webhooks", strings.NewReader(`{"url":"ftp://example.com/hooks"}`))
+	req = req.WithContext(auth.SetUserIDInContext(req.Context(), "user123"))
+	rr = httptest.NewRecorder()
+
+	handler.CreateWebhook(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetWebhooks(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockWebhookService)
+	handler := NewWebhookHandler(mockService)
+
+	mockService.On("ListWebhooks", "user123").Return([]webhooks.Webhook{{ID: "webhook1", UserID: "user123", Secret: "whsec_secret"}}, nil)
+
+	// Users only see their own webhooks, without their secrets
+	req := httptest.NewRequest("GET", "/api/webhooks?userId=user456", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	handler.GetWebhooks(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "whsec_secret")
+	var registered []webhooks.Webhook
+	err := json.Unmarshal(rr.Body.Bytes(), &registered)
+	assert.NoError(t, err)
+	assert.Len(t, registered, 1)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetWebhookDeliveries(t *testing.T) {
+	// Create handler with mock service
+	mockService := new(MockWebhookService)
+	handler := NewWebhookHandler(mockService)
+
+	mockService.On("GetWebhook", "webhook1").Return(&webhooks.Webhook{ID: "webhook1", UserID: "user123"}, nil)
+	mockService.On("ListDeliveries", webhooks.DeliveryFilter{WebhookID: "webhook1", Status: webhooks.DeliveryFailed, Limit: 10}).
+		Return([]webhooks.Delivery{{ID: "delivery1", WebhookID: "webhook1", Status: webhooks.DeliveryFailed, Attempts: 8}}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/webhooks/{webhookId}/deliveries", handler.GetDeliveries).Methods("GET")
+
+	// Users may not see the deliveries of others' webhooks
+	req := httptest.NewRequest("GET", "/api/webhooks/webhook1/deliveries", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user456"), string(models.UserRoleTrader)))
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockService.AssertNotCalled(t, "ListDeliveries", mock.Anything)
+
+	req = httptest.NewRequest("GET", "/api/webhooks/webhook1/deliveries?status=FAILED&limit=10", nil)
+	req = req.WithContext(auth.SetRoleInContext(auth.SetUserIDInContext(req.Context(), "user123"), string(models.UserRoleTrader)))
+	rr = httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var deliveries []webhooks.Delivery
+	err := json.Unmarshal(rr.Body.Bytes(), &deliveries)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, 8, deliveries[0].Attempts)
+
+	mockService.AssertExpectations(t)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,12 +14,14 @@ import (
 	"trading_platform/backend/internal/models"
 	"trading_platform/backend/internal/auth"
 	"trading_platform/backend/internal/utils"
+	"trading_platform/backend/internal/versions"
 )
 
 // PortfolioHandler handles portfolio-related API endpoints
 type PortfolioHandler struct {
 	portfolioRepo *database.PortfolioRepository
 	strategyRepo  *database.StrategyRepository
+	versions      *versions.Service
 }
 
 // NewPortfolioHandler creates a new PortfolioHandler
@@ -29,6 +32,12 @@ func NewPortfolioHandler(portfolioRepo *database.PortfolioRepository, strategyRe
 	}
 }
 
+// SetVersions sets where the versions of portfolios are recorded as users save
+// them
+func (h *PortfolioHandler) SetVersions(versionService *versions.Service) {
+	h.versions = versionService
+}
+
 // CreatePortfolio handles the creation of a new portfolio
 func (h *PortfolioHandler) CreatePortfolio(w http.ResponseWriter, r *http.Request) {
 	// Extract user ID from context (set by auth middleware)
@@ -81,6 +90,7 @@ func (h *PortfolioHandler) CreatePortfolio(w http.ResponseWriter, r *http.Reques
 
 	// Set ID in response
 	portfolio.ID = id
+	h.recordVersion(&portfolio, userID)
 
 	utils.RespondWithJSON(w, http.StatusCreated, portfolio)
 }
@@ -159,6 +169,7 @@ func (h *PortfolioHandler) UpdatePortfolio(w http.ResponseWriter, r *http.Reques
 	updatedPortfolio.ID = id
 	updatedPortfolio.UserID = userID
 	updatedPortfolio.CreatedAt = existingPortfolio.CreatedAt
+	updatedPortfolio.Version = existingPortfolio.Version
 
 	// Validate portfolio
 	if err := updatedPortfolio.Validate(); err != nil {
@@ -189,6 +200,7 @@ func (h *PortfolioHandler) UpdatePortfolio(w http.ResponseWriter, r *http.Reques
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating portfolio")
 		return
 	}
+	h.recordVersion(&updatedPortfolio, userID)
 
 	utils.RespondWithJSON(w, http.StatusOK, updatedPortfolio)
 }
@@ -475,6 +487,7 @@ func (h *PortfolioHandler) AddLegToPortfolio(w http.ResponseWriter, r *http.Requ
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error adding leg to portfolio")
 		return
 	}
+	h.recordVersion(existingPortfolio, userID)
 
 	utils.RespondWithJSON(w, http.StatusOK, existingPortfolio)
 }
@@ -558,6 +571,7 @@ func (h *PortfolioHandler) UpdateLegInPortfolio(w http.ResponseWriter, r *http.R
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating leg in portfolio")
 		return
 	}
+	h.recordVersion(existingPortfolio, userID)
 
 	utils.RespondWithJSON(w, http.StatusOK, existingPortfolio)
 }
@@ -622,10 +636,108 @@ func (h *PortfolioHandler) RemoveLegFromPortfolio(w http.ResponseWriter, r *http
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error removing leg from portfolio")
 		return
 	}
+	h.recordVersion(existingPortfolio, userID)
+
+	utils.RespondWithJSON(w, http.StatusOK, existingPortfolio)
+}
+
+// BuildPredefinedLegsRequest is the request body for building a portfolio's legs
+// from a predefined strategy
+type BuildPredefinedLegsRequest struct {
+	PredefinedStrategy string                   `json:"predefinedStrategy,omitempty"` // Replaces the portfolio's when given
+	PredefinedParams   *models.PredefinedParams `json:"predefinedParams,omitempty"`   // Replace the portfolio's when given
+	UnderlyingPrice    float64                  `json:"underlyingPrice"`              // ATM is the strike nearest to it
+}
+
+// BuildPredefinedLegs handles replacing the legs of a portfolio with those of its
+// predefined strategy
+func (h *PortfolioHandler) BuildPredefinedLegs(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from context (set by auth middleware)
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get portfolio ID from URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// Get existing portfolio
+	existingPortfolio, err := h.portfolioRepo.GetByID(id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Portfolio not found")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving portfolio")
+		}
+		return
+	}
+
+	// Check if user has access to this portfolio
+	if existingPortfolio.UserID != userID {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	// The legs of an active portfolio are being traded
+	if existingPortfolio.Status == models.PortfolioStatusActive {
+		utils.RespondWithError(w, http.StatusBadRequest, "Cannot replace the legs of an active portfolio")
+		return
+	}
+
+	// Parse request body
+	var req BuildPredefinedLegsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.PredefinedStrategy != "" {
+		existingPortfolio.PredefinedStrategy = req.PredefinedStrategy
+	}
+	if req.PredefinedParams != nil {
+		existingPortfolio.PredefinedParams = req.PredefinedParams
+	}
+
+	// Build legs
+	legs, err := existingPortfolio.BuildPredefinedLegs(req.UnderlyingPrice)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existingPortfolio.Legs = legs
+	existingPortfolio.UpdatedAt = time.Now()
+
+	// Validate portfolio
+	if err := existingPortfolio.Validate(); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Update portfolio
+	if err := h.portfolioRepo.Update(existingPortfolio); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error building portfolio legs")
+		return
+	}
+	h.recordVersion(existingPortfolio, userID)
 
 	utils.RespondWithJSON(w, http.StatusOK, existingPortfolio)
 }
 
+// recordVersion records the configuration of a portfolio a user saved as its next
+// version when it changed. Failing to record it does not fail the save.
+func (h *PortfolioHandler) recordVersion(portfolio *models.Portfolio, userID string) {
+	if h.versions == nil {
+		return
+	}
+
+	if _, err := h.versions.RecordPortfolio(portfolio, userID); err != nil {
+		log.Printf("Error recording version of portfolio %s: %v", portfolio.ID, err)
+	}
+}
+
 // RegisterPortfolioRoutes registers portfolio-related routes
 func RegisterPortfolioRoutes(
 	router *mux.Router, 
@@ -650,4 +762,5 @@ func RegisterPortfolioRoutes(
 	portfolioRouter.HandleFunc("/{id}/legs", handler.AddLegToPortfolio).Methods("POST")
 	portfolioRouter.HandleFunc("/{id}/legs/{legId}", handler.UpdateLegInPortfolio).Methods("PUT")
 	portfolioRouter.HandleFunc("/{id}/legs/{legId}", handler.RemoveLegFromPortfolio).Methods("DELETE")
+	portfolioRouter.HandleFunc("/{id}/predefined-legs", handler.BuildPredefinedLegs).Methods("POST")
 }
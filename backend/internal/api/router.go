@@ -2,9 +2,29 @@ package api
 
 import (
 	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/accesslog"
+	"github.com/trading-platform/backend/internal/alerts"
 	"github.com/trading-platform/backend/internal/api/handlers"
+	"github.com/trading-platform/backend/internal/audit"
+	"github.com/trading-platform/backend/internal/errorreporting"
+	"github.com/trading-platform/backend/internal/metrics"
+	"github.com/trading-platform/backend/internal/push"
 	"github.com/trading-platform/backend/internal/services"
+	"github.com/trading-platform/backend/internal/services/brokersession"
+	"github.com/trading-platform/backend/internal/services/drift"
+	"github.com/trading-platform/backend/internal/services/exports"
+	"github.com/trading-platform/backend/internal/services/hedging"
+	"github.com/trading-platform/backend/internal/services/killswitch"
+	"github.com/trading-platform/backend/internal/services/margin"
 	"github.com/trading-platform/backend/internal/services/position"
+	"github.com/trading-platform/backend/internal/services/promotion"
+	"github.com/trading-platform/backend/internal/services/reports"
+	"github.com/trading-platform/backend/internal/services/risk"
+	"github.com/trading-platform/backend/internal/services/summary"
+	"github.com/trading-platform/backend/internal/signals"
+	"github.com/trading-platform/backend/internal/tracing"
+	"github.com/trading-platform/backend/internal/versions"
+	"github.com/trading-platform/backend/internal/webhooks"
 )
 
 // Router sets up the API routes
@@ -12,29 +32,196 @@ type Router struct {
 	router         *mux.Router
 	orderHandler   *handlers.OrderHandler
 	positionHandler *handlers.PositionHandler
+	killSwitchHandler *handlers.KillSwitchHandler
+	promotionHandler *handlers.PromotionHandler
+	dailyLossHandler *handlers.DailyLossHandler
+	limitHandler *handlers.LimitHandler
+	marginHandler *handlers.MarginHandler
+	circuitBreakerHandler *handlers.CircuitBreakerHandler
+	hedgingHandler *handlers.HedgingHandler
+	exposureHandler *handlers.ExposureHandler
+	ruleHandler *handlers.RuleHandler
+	fundsHandler *handlers.FundsHandler
+	strategyLimitHandler *handlers.StrategyLimitHandler
+	auditHandler *handlers.AuditHandler
+	webhookHandler *handlers.WebhookHandler
+	alertHandler *handlers.AlertHandler
+	summaryHandler *handlers.SummaryHandler
+	brokerSessionHandler *handlers.BrokerSessionHandler
+	pushHandler *handlers.PushHandler
+	notificationHandler *handlers.NotificationHandler
+	signalHandler *handlers.SignalHandler
+	versionHandler *handlers.VersionHandler
+	backtestDriftHandler *handlers.BacktestDriftHandler
+	reportHandler *handlers.ReportHandler
+	exportHandler *handlers.ExportHandler
+}
+
+// Dependencies are the services the API routes are served by. The services from
+// WebhookService on are optional, their routes being left out when nil.
+type Dependencies struct {
+	OrderService         services.OrderService
+	PositionService      position.PositionService
+	KillSwitchService    killswitch.KillSwitchService
+	PromotionService     promotion.PromotionService
+	DailyLossMonitor     *risk.DailyLossMonitor
+	LimitMonitor         *risk.LimitMonitor
+	MarginEstimator      *margin.MarginEstimator
+	CircuitBreaker       *risk.CircuitBreaker
+	DeltaHedger          *hedging.DeltaHedger
+	ExposureReporter     *risk.ExposureReporter
+	RuleEngine           *risk.RuleEngine
+	FundsSynchronizer    *margin.FundsSynchronizer
+	StrategyLimitMonitor *risk.StrategyLimitMonitor
+	AuditLogger          *audit.Logger
+
+	WebhookService *webhooks.Service
+	AlertService   *alerts.Service
+	SummaryService *summary.Service
+	SessionMonitor *brokersession.Monitor
+	PushService    *push.Service
+	SignalService  *signals.Service
+	VersionService *versions.Service
+	DriftMonitor   *drift.Monitor
+	ReportService  *reports.Service
+	ExportService  *exports.Service
 }
 
 // NewRouter creates a new Router
-func NewRouter(orderService services.OrderService, positionService position.PositionService) *Router {
+func NewRouter(deps Dependencies) *Router {
 	router := mux.NewRouter()
-	orderHandler := handlers.NewOrderHandler(orderService)
-	positionHandler := handlers.NewPositionHandler(positionService)
+	orderHandler := handlers.NewOrderHandler(deps.OrderService)
+	positionHandler := handlers.NewPositionHandler(deps.PositionService)
+	killSwitchHandler := handlers.NewKillSwitchHandler(deps.KillSwitchService)
+	promotionHandler := handlers.NewPromotionHandler(deps.PromotionService)
+	dailyLossHandler := handlers.NewDailyLossHandler(deps.DailyLossMonitor)
+	limitHandler := handlers.NewLimitHandler(deps.LimitMonitor)
+	marginHandler := handlers.NewMarginHandler(deps.MarginEstimator)
+	circuitBreakerHandler := handlers.NewCircuitBreakerHandler(deps.CircuitBreaker)
+	hedgingHandler := handlers.NewHedgingHandler(deps.DeltaHedger)
+	exposureHandler := handlers.NewExposureHandler(deps.ExposureReporter)
+	ruleHandler := handlers.NewRuleHandler(deps.RuleEngine)
+	fundsHandler := handlers.NewFundsHandler(deps.FundsSynchronizer)
+	strategyLimitHandler := handlers.NewStrategyLimitHandler(deps.StrategyLimitMonitor)
+	auditHandler := handlers.NewAuditHandler(deps.AuditLogger)
+
+	// Record orders, portfolio changes and admin actions in the audit log
+	orderHandler.SetAuditLogger(deps.AuditLogger)
+	positionHandler.SetAuditLogger(deps.AuditLogger)
+	hedgingHandler.SetAuditLogger(deps.AuditLogger)
+	dailyLossHandler.SetAuditLogger(deps.AuditLogger)
+	circuitBreakerHandler.SetAuditLogger(deps.AuditLogger)
+	limitHandler.SetAuditLogger(deps.AuditLogger)
+	ruleHandler.SetAuditLogger(deps.AuditLogger)
+
+	// Notify users' webhooks of the positions they close, order events reaching
+	// them through the order service
+	var webhookHandler *handlers.WebhookHandler
+	if deps.WebhookService != nil {
+		webhookHandler = handlers.NewWebhookHandler(deps.WebhookService)
+		positionHandler.SetWebhooks(deps.WebhookService)
+	}
+
+	var alertHandler *handlers.AlertHandler
+	var notificationHandler *handlers.NotificationHandler
+	if deps.AlertService != nil {
+		alertHandler = handlers.NewAlertHandler(deps.AlertService)
+		notificationHandler = handlers.NewNotificationHandler(deps.AlertService)
+	}
+
+	var summaryHandler *handlers.SummaryHandler
+	if deps.SummaryService != nil {
+		summaryHandler = handlers.NewSummaryHandler(deps.SummaryService)
+	}
+
+	var brokerSessionHandler *handlers.BrokerSessionHandler
+	if deps.SessionMonitor != nil {
+		brokerSessionHandler = handlers.NewBrokerSessionHandler(deps.SessionMonitor)
+	}
+
+	var pushHandler *handlers.PushHandler
+	if deps.PushService != nil {
+		pushHandler = handlers.NewPushHandler(deps.PushService)
+	}
+
+	var signalHandler *handlers.SignalHandler
+	if deps.SignalService != nil {
+		signalHandler = handlers.NewSignalHandler(deps.SignalService)
+	}
+
+	var versionHandler *handlers.VersionHandler
+	if deps.VersionService != nil {
+		versionHandler = handlers.NewVersionHandler(deps.VersionService)
+	}
+
+	var backtestDriftHandler *handlers.BacktestDriftHandler
+	if deps.DriftMonitor != nil {
+		backtestDriftHandler = handlers.NewBacktestDriftHandler(deps.DriftMonitor)
+	}
+
+	var reportHandler *handlers.ReportHandler
+	if deps.ReportService != nil {
+		reportHandler = handlers.NewReportHandler(deps.ReportService)
+	}
+
+	var exportHandler *handlers.ExportHandler
+	if deps.ExportService != nil {
+		exportHandler = handlers.NewExportHandler(deps.ExportService)
+	}
 
 	return &Router{
 		router:         router,
 		orderHandler:   orderHandler,
 		positionHandler: positionHandler,
+		killSwitchHandler: killSwitchHandler,
+		promotionHandler: promotionHandler,
+		dailyLossHandler: dailyLossHandler,
+		limitHandler: limitHandler,
+		marginHandler: marginHandler,
+		circuitBreakerHandler: circuitBreakerHandler,
+		hedgingHandler: hedgingHandler,
+		exposureHandler: exposureHandler,
+		ruleHandler: ruleHandler,
+		fundsHandler: fundsHandler,
+		strategyLimitHandler: strategyLimitHandler,
+		auditHandler: auditHandler,
+		webhookHandler: webhookHandler,
+		alertHandler: alertHandler,
+		summaryHandler: summaryHandler,
+		brokerSessionHandler: brokerSessionHandler,
+		pushHandler: pushHandler,
+		notificationHandler: notificationHandler,
+		signalHandler: signalHandler,
+		versionHandler: versionHandler,
+		backtestDriftHandler: backtestDriftHandler,
+		reportHandler: reportHandler,
+		exportHandler: exportHandler,
 	}
 }
 
 // SetupRoutes configures all the routes for the API
 func (r *Router) SetupRoutes() *mux.Router {
+	// Trace, count and time the requests of every route
+	r.router.Use(tracing.Middleware("trading-platform-api"))
+	r.router.Use(metrics.Middleware)
+	r.router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Log every request, failed and slow requests included
+	r.router.Use(accesslog.NewLogger(accesslog.DefaultConfig()).Middleware)
+
+	// Report panics and server errors, recovering from panics
+	r.router.Use(errorreporting.Middleware)
+
+	// Keep the client of every request for the audit log
+	r.router.Use(audit.Middleware)
+
 	// Order routes
 	r.router.HandleFunc("/api/orders", r.orderHandler.CreateOrder).Methods("POST")
 	r.router.HandleFunc("/api/orders", r.orderHandler.GetOrders).Methods("GET")
 	r.router.HandleFunc("/api/orders/{id}", r.orderHandler.GetOrder).Methods("GET")
 	r.router.HandleFunc("/api/orders/{id}", r.orderHandler.UpdateOrder).Methods("PUT")
 	r.router.HandleFunc("/api/orders/{id}/cancel", r.orderHandler.CancelOrder).Methods("POST")
+	r.router.HandleFunc("/api/orders/{id}/timeline", r.orderHandler.GetOrderTimeline).Methods("GET")
 	
 	// User-specific order routes
 	r.router.HandleFunc("/api/users/{userId}/orders", r.orderHandler.GetOrdersByUser).Methods("GET")
@@ -67,6 +254,162 @@ func (r *Router) SetupRoutes() *mux.Router {
 	// Position aggregation route
 	r.router.HandleFunc("/api/positions/aggregate", r.positionHandler.AggregatePositions).Methods("GET")
 
+	// Kill switch routes, platform-wide kills being limited to admins by the handler
+	r.router.HandleFunc("/api/kill-switch", r.killSwitchHandler.RequestKill).Methods("POST")
+	r.router.HandleFunc("/api/kill-switch/confirm", r.killSwitchHandler.ConfirmKill).Methods("POST")
+	r.router.HandleFunc("/api/kill-switch/audit", r.killSwitchHandler.GetAuditLog).Methods("GET")
+
+	// Paper to live strategy promotion routes
+	r.router.HandleFunc("/api/strategies/{strategyId}/promotion", r.promotionHandler.CheckPromotion).Methods("GET")
+	r.router.HandleFunc("/api/strategies/{strategyId}/promote", r.promotionHandler.PromoteStrategy).Methods("POST")
+	r.router.HandleFunc("/api/strategies/{strategyId}/drift", r.promotionHandler.GetDrift).Methods("GET")
+
+	// Strategy daily limit routes
+	r.router.HandleFunc("/api/strategies/{strategyId}/limits", r.strategyLimitHandler.GetStatus).Methods("GET")
+	r.router.HandleFunc("/api/strategies/{strategyId}/rearm", r.strategyLimitHandler.Rearm).Methods("POST")
+
+	// Max daily loss routes, overrides being limited to admins by the handler
+	r.router.HandleFunc("/api/users/{userId}/daily-loss", r.dailyLossHandler.GetStatus).Methods("GET")
+	r.router.HandleFunc("/api/users/{userId}/daily-loss/override", r.dailyLossHandler.Override).Methods("POST")
+
+	// Circuit breaker routes, resets being limited to admins by the handler
+	r.router.HandleFunc("/api/users/{userId}/circuit-breaker", r.circuitBreakerHandler.GetStatus).Methods("GET")
+	r.router.HandleFunc("/api/users/{userId}/circuit-breaker/reset", r.circuitBreakerHandler.Reset).Methods("POST")
+
+	// Position and notional limit routes, changes being limited to admins by the handler
+	r.router.HandleFunc("/api/risk/limits", r.limitHandler.GetLimits).Methods("GET")
+	r.router.HandleFunc("/api/risk/limits", r.limitHandler.CreateLimit).Methods("POST")
+	r.router.HandleFunc("/api/risk/limits/breaches", r.limitHandler.GetBreaches).Methods("GET")
+	r.router.HandleFunc("/api/risk/limits/{limitId}", r.limitHandler.GetLimit).Methods("GET")
+	r.router.HandleFunc("/api/risk/limits/{limitId}", r.limitHandler.UpdateLimit).Methods("PUT")
+	r.router.HandleFunc("/api/risk/limits/{limitId}", r.limitHandler.DeleteLimit).Methods("DELETE")
+
+	// Risk rule routes
+	r.router.HandleFunc("/api/risk/rules", r.ruleHandler.GetRules).Methods("GET")
+	r.router.HandleFunc("/api/risk/rules", r.ruleHandler.CreateRule).Methods("POST")
+	r.router.HandleFunc("/api/risk/rules/triggers", r.ruleHandler.GetTriggers).Methods("GET")
+	r.router.HandleFunc("/api/risk/rules/{ruleId}", r.ruleHandler.GetRule).Methods("GET")
+	r.router.HandleFunc("/api/risk/rules/{ruleId}", r.ruleHandler.UpdateRule).Methods("PUT")
+	r.router.HandleFunc("/api/risk/rules/{ruleId}", r.ruleHandler.DeleteRule).Methods("DELETE")
+
+	// Exposure routes
+	r.router.HandleFunc("/api/users/{userId}/exposure", r.exposureHandler.GetUserExposure).Methods("GET")
+	r.router.HandleFunc("/api/users/{userId}/exposure/accounts/{accountId}", r.exposureHandler.GetAccountExposure).Methods("GET")
+
+	// Broker funds routes
+	r.router.HandleFunc("/api/users/{userId}/funds", r.fundsHandler.GetUserFunds).Methods("GET")
+	r.router.HandleFunc("/api/users/{userId}/funds/history", r.fundsHandler.GetFundsHistory).Methods("GET")
+
+	// Portfolio delta hedging routes
+	r.router.HandleFunc("/api/portfolios/{portfolioId}/hedging", r.hedgingHandler.GetHedge).Methods("GET")
+	r.router.HandleFunc("/api/portfolios/{portfolioId}/hedging", r.hedgingHandler.EnableHedging).Methods("POST")
+	r.router.HandleFunc("/api/portfolios/{portfolioId}/hedging", r.hedgingHandler.DisableHedging).Methods("DELETE")
+
+	// Margin routes
+	r.router.HandleFunc("/api/margin/estimate", r.marginHandler.Estimate).Methods("POST")
+
+	// Audit log routes, users seeing their own actions and admins everyone's
+	r.router.HandleFunc("/api/audit/events", r.auditHandler.GetEvents).Methods("GET")
+	r.router.HandleFunc("/api/audit/export", r.auditHandler.ExportEvents).Methods("GET")
+
+	// Webhook routes, users managing their own webhooks and admins everyone's
+	if r.webhookHandler != nil {
+		r.router.HandleFunc("/api/webhooks", r.webhookHandler.GetWebhooks).Methods("GET")
+		r.router.HandleFunc("/api/webhooks", r.webhookHandler.CreateWebhook).Methods("POST")
+		r.router.HandleFunc("/api/webhooks/{webhookId}", r.webhookHandler.GetWebhook).Methods("GET")
+		r.router.HandleFunc("/api/webhooks/{webhookId}", r.webhookHandler.UpdateWebhook).Methods("PUT")
+		r.router.HandleFunc("/api/webhooks/{webhookId}", r.webhookHandler.DeleteWebhook).Methods("DELETE")
+		r.router.HandleFunc("/api/webhooks/{webhookId}/deliveries", r.webhookHandler.GetDeliveries).Methods("GET")
+	}
+
+	// Alert routes, users managing their own rules and admins everyone's
+	if r.alertHandler != nil {
+		r.router.HandleFunc("/api/alerts", r.alertHandler.GetAlerts).Methods("GET")
+		r.router.HandleFunc("/api/alerts/rules", r.alertHandler.GetRules).Methods("GET")
+		r.router.HandleFunc("/api/alerts/rules", r.alertHandler.CreateRule).Methods("POST")
+		r.router.HandleFunc("/api/alerts/rules/{ruleId}", r.alertHandler.GetRule).Methods("GET")
+		r.router.HandleFunc("/api/alerts/rules/{ruleId}", r.alertHandler.UpdateRule).Methods("PUT")
+		r.router.HandleFunc("/api/alerts/rules/{ruleId}", r.alertHandler.DeleteRule).Methods("DELETE")
+	}
+
+	// Notification center routes, the inbox of what users were alerted of over
+	// any channel
+	if r.notificationHandler != nil {
+		r.router.HandleFunc("/api/notifications", r.notificationHandler.GetNotifications).Methods("GET")
+		r.router.HandleFunc("/api/notifications/read", r.notificationHandler.MarkRead).Methods("POST")
+	}
+
+	// Daily summary routes, the summaries users opt in to receiving by email
+	if r.summaryHandler != nil {
+		r.router.HandleFunc("/api/reports/daily", r.summaryHandler.GetDailySummary).Methods("GET")
+		r.router.HandleFunc("/api/reports/daily/subscription", r.summaryHandler.GetSubscription).Methods("GET")
+		r.router.HandleFunc("/api/reports/daily/subscription", r.summaryHandler.UpdateSubscription).Methods("PUT")
+	}
+
+	// Broker session health routes, with the links to log in to accounts again
+	if r.brokerSessionHandler != nil {
+		r.router.HandleFunc("/api/brokers/sessions", r.brokerSessionHandler.GetSessions).Methods("GET")
+	}
+
+	// Push routes, the mobile devices users receive notifications on
+	if r.pushHandler != nil {
+		r.router.HandleFunc("/api/push/devices", r.pushHandler.GetDevices).Methods("GET")
+		r.router.HandleFunc("/api/push/devices", r.pushHandler.RegisterDevice).Methods("POST")
+		r.router.HandleFunc("/api/push/devices/{deviceId}", r.pushHandler.DeleteDevice).Methods("DELETE")
+	}
+
+	// Signal routes, the webhooks external tools post signals to, authenticated
+	// by each webhook's secret, and those users manage them through
+	if r.signalHandler != nil {
+		r.router.HandleFunc("/api/signals/webhook/{hookId}", r.signalHandler.ReceiveSignal).Methods("POST")
+		r.router.HandleFunc("/api/signals/hooks", r.signalHandler.GetHooks).Methods("GET")
+		r.router.HandleFunc("/api/signals/hooks", r.signalHandler.CreateHook).Methods("POST")
+		r.router.HandleFunc("/api/signals/hooks/{hookId}", r.signalHandler.GetHook).Methods("GET")
+		r.router.HandleFunc("/api/signals/hooks/{hookId}", r.signalHandler.UpdateHook).Methods("PUT")
+		r.router.HandleFunc("/api/signals/hooks/{hookId}", r.signalHandler.DeleteHook).Methods("DELETE")
+		r.router.HandleFunc("/api/signals/hooks/{hookId}/signals", r.signalHandler.GetSignals).Methods("GET")
+	}
+
+	// Configuration version routes, the history of strategies and portfolios as
+	// they were saved, with diffs between versions and rollbacks
+	if r.versionHandler != nil {
+		r.router.HandleFunc("/api/strategies/{strategyId}/versions", r.versionHandler.GetVersions).Methods("GET")
+		r.router.HandleFunc("/api/strategies/{strategyId}/versions/{version}", r.versionHandler.GetVersion).Methods("GET")
+		r.router.HandleFunc("/api/strategies/{strategyId}/versions/{version}/diff", r.versionHandler.GetDiff).Methods("GET")
+		r.router.HandleFunc("/api/strategies/{strategyId}/versions/{version}/rollback", r.versionHandler.Rollback).Methods("POST")
+		r.router.HandleFunc("/api/portfolios/{portfolioId}/versions", r.versionHandler.GetVersions).Methods("GET")
+		r.router.HandleFunc("/api/portfolios/{portfolioId}/versions/{version}", r.versionHandler.GetVersion).Methods("GET")
+		r.router.HandleFunc("/api/portfolios/{portfolioId}/versions/{version}/diff", r.versionHandler.GetDiff).Methods("GET")
+		r.router.HandleFunc("/api/portfolios/{portfolioId}/versions/{version}/rollback", r.versionHandler.Rollback).Methods("POST")
+	}
+
+	// Backtest drift routes, how live strategies fare against the backtest they
+	// were promoted from
+	if r.backtestDriftHandler != nil {
+		r.router.HandleFunc("/api/strategies/{strategyId}/backtest-drift", r.backtestDriftHandler.GetStrategyDrift).Methods("GET")
+		r.router.HandleFunc("/api/drift/reports", r.backtestDriftHandler.GetReports).Methods("GET")
+	}
+
+	// PDF and tax report routes. Downloads are authorized by the signature of their URL
+	// rather than by authentication.
+	if r.reportHandler != nil {
+		r.router.HandleFunc("/api/reports", r.reportHandler.GetReports).Methods("GET")
+		r.router.HandleFunc("/api/reports/download", r.reportHandler.Download).Methods("GET")
+		r.router.HandleFunc("/api/reports/trade-statement", r.reportHandler.CreateTradeStatement).Methods("POST")
+		r.router.HandleFunc("/api/reports/portfolios/{portfolioId}", r.reportHandler.CreatePortfolioSummary).Methods("POST")
+		r.router.HandleFunc("/api/reports/strategies/{strategyId}", r.reportHandler.CreateStrategyPerformance).Methods("POST")
+		r.router.HandleFunc("/api/reports/tax", r.reportHandler.GetTaxReport).Methods("GET")
+		r.router.HandleFunc("/api/reports/tax", r.reportHandler.CreateTaxStatement).Methods("POST")
+	}
+
+	// CSV and XLSX export routes, streamed or generated in the background
+	if r.exportHandler != nil {
+		r.router.HandleFunc("/api/exports/jobs", r.exportHandler.GetJobs).Methods("GET")
+		r.router.HandleFunc("/api/exports/jobs/{jobId}", r.exportHandler.GetJob).Methods("GET")
+		r.router.HandleFunc("/api/exports/jobs/{jobId}/download", r.exportHandler.DownloadJob).Methods("GET")
+		r.router.HandleFunc("/api/exports/{dataset:orders|trades|positions}", r.exportHandler.Export).Methods("GET")
+	}
+
 	return r.router
 }
 
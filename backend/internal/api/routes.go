@@ -4,8 +4,10 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"trading_platform/backend/internal/accesslog"
 	"trading_platform/backend/internal/api/handlers"
 	"trading_platform/backend/internal/auth"
+	"trading_platform/backend/internal/errorreporting"
 	"trading_platform/backend/internal/models"
 	"trading_platform/backend/internal/repositories"
 	"trading_platform/backend/internal/services/user"
@@ -29,6 +31,8 @@ func SetupRoutes(r *mux.Router, repos *repositories.Repositories) {
 	// Protected routes
 	protected := r.PathPrefix("/api").Subrouter()
 	protected.Use(auth.AuthMiddleware)
+	protected.Use(errorreporting.UserMiddleware)
+	protected.Use(accesslog.UserMiddleware)
 
 	// User routes
 	protected.HandleFunc("/users/profile", userHandler.GetProfile).Methods("GET")
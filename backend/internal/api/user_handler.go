@@ -10,6 +10,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 
+	"trading_platform/backend/internal/audit"
 	"trading_platform/backend/internal/database"
 	"trading_platform/backend/internal/models"
 	"trading_platform/backend/internal/auth"
@@ -21,6 +22,7 @@ type UserHandler struct {
 	userRepo      *database.UserRepository
 	preferenceRepo *database.UserPreferenceRepository
 	apiKeyRepo    *database.APIKeyRepository
+	auditLogger   *audit.Logger
 }
 
 // NewUserHandler creates a new UserHandler
@@ -36,6 +38,12 @@ func NewUserHandler(
 	}
 }
 
+// SetAuditLogger records logins, password changes and API key changes made
+// through the handler in the audit log
+func (h *UserHandler) SetAuditLogger(auditLogger *audit.Logger) {
+	h.auditLogger = auditLogger
+}
+
 // Register handles user registration
 func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
@@ -119,6 +127,7 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	user, err := h.userRepo.GetByUsername(loginRequest.Username)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
+			h.auditLoginFailed(r, loginRequest.Username, "unknown username")
 			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		} else {
 			utils.RespondWithError(w, http.StatusInternalServerError, "Error retrieving user")
@@ -128,6 +137,7 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	// Check if user is active
 	if !user.Active {
+		h.auditLoginFailed(r, loginRequest.Username, "account is inactive")
 		utils.RespondWithError(w, http.StatusUnauthorized, "Account is inactive")
 		return
 	}
@@ -135,6 +145,7 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Check password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginRequest.Password))
 	if err != nil {
+		h.auditLoginFailed(r, loginRequest.Username, "wrong password")
 		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
@@ -161,6 +172,15 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		// TODO: Add proper logging
 	}
 
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAuth,
+		Action:       audit.ActionLogin,
+		Actor:        user.ID,
+		ActorRole:    string(user.Role),
+		ResourceType: "user",
+		ResourceID:   user.ID,
+	})
+
 	// Remove password from response
 	user.Password = ""
 
@@ -378,6 +398,13 @@ func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAuth,
+		Action:       audit.ActionPasswordChanged,
+		ResourceType: "user",
+		ResourceID:   userID,
+	})
+
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Password changed successfully"})
 }
 
@@ -497,6 +524,15 @@ func (h *UserHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 	// Set ID in response
 	apiKey.ID = id
 
+	// The key and secret are never serialized, so never audited
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAuth,
+		Action:       audit.ActionAPIKeyCreated,
+		ResourceType: "apiKey",
+		ResourceID:   id,
+		After:        audit.Snapshot(apiKey),
+	})
+
 	utils.RespondWithJSON(w, http.StatusCreated, apiKey)
 }
 
@@ -593,18 +629,41 @@ func (h *UserHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAuth,
+		Action:       audit.ActionAPIKeyDeleted,
+		ResourceType: "apiKey",
+		ResourceID:   id,
+		Before:       audit.Snapshot(existingAPIKey),
+	})
+
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "API key deleted successfully"})
 }
 
-// RegisterUserRoutes registers user-related routes
+// auditLoginFailed records a failed login, by the username tried as no user is
+// authenticated
+func (h *UserHandler) auditLoginFailed(r *http.Request, username string, reason string) {
+	h.auditLogger.Record(r.Context(), audit.Event{
+		Category:     audit.CategoryAuth,
+		Action:       audit.ActionLoginFailed,
+		Actor:        username,
+		ResourceType: "user",
+		Details:      reason,
+	})
+}
+
+// RegisterUserRoutes registers user-related routes, recording logins and account
+// changes in the audit log when an audit logger is given
 func RegisterUserRoutes(
 	router *mux.Router,
 	userRepo *database.UserRepository,
 	preferenceRepo *database.UserPreferenceRepository,
 	apiKeyRepo *database.APIKeyRepository,
 	authMiddleware func(http.Handler) http.Handler,
+	auditLogger *audit.Logger,
 ) {
 	handler := NewUserHandler(userRepo, preferenceRepo, apiKeyRepo)
+	handler.SetAuditLogger(auditLogger)
 
 	// Public routes
 	router.HandleFunc("/auth/register", handler.Register).Methods("POST")
@@ -0,0 +1,201 @@
+// Package audit records who did what to which resource across the platform, in
+// one schema for authentication, orders, portfolio changes and admin actions.
+// Events are appended to a store and never changed or removed, and can be queried
+// and exported for compliance reviews.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/auth"
+)
+
+// Category groups audit events by the part of the platform they come from
+type Category string
+
+const (
+	CategoryAuth      Category = "AUTH"
+	CategoryOrder     Category = "ORDER"
+	CategoryPortfolio Category = "PORTFOLIO"
+	CategoryAdmin     Category = "ADMIN"
+)
+
+// Action represents what was done in an audit event
+type Action string
+
+const (
+	ActionLogin           Action = "LOGIN"
+	ActionLoginFailed     Action = "LOGIN_FAILED"
+	ActionPasswordChanged Action = "PASSWORD_CHANGED"
+	ActionAPIKeyCreated   Action = "API_KEY_CREATED"
+	ActionAPIKeyDeleted   Action = "API_KEY_DELETED"
+
+	ActionOrderCreated   Action = "ORDER_CREATED"
+	ActionOrderModified  Action = "ORDER_MODIFIED"
+	ActionOrderCancelled Action = "ORDER_CANCELLED"
+
+	ActionPositionCreated Action = "POSITION_CREATED"
+	ActionPositionUpdated Action = "POSITION_UPDATED"
+	ActionPositionClosed  Action = "POSITION_CLOSED"
+	ActionHedgingEnabled  Action = "HEDGING_ENABLED"
+	ActionHedgingDisabled Action = "HEDGING_DISABLED"
+
+	ActionLimitCreated        Action = "LIMIT_CREATED"
+	ActionLimitUpdated        Action = "LIMIT_UPDATED"
+	ActionLimitDeleted        Action = "LIMIT_DELETED"
+	ActionRuleCreated         Action = "RULE_CREATED"
+	ActionRuleUpdated         Action = "RULE_UPDATED"
+	ActionRuleDeleted         Action = "RULE_DELETED"
+	ActionCircuitBreakerReset Action = "CIRCUIT_BREAKER_RESET"
+	ActionDailyLossOverride   Action = "DAILY_LOSS_OVERRIDE"
+)
+
+// Event is one entry of the audit log. Before and after hold the state of the
+// resource as JSON, either being empty when it did not exist.
+type Event struct {
+	ID           string          `json:"id" bson:"_id"`
+	Timestamp    time.Time       `json:"timestamp" bson:"timestamp"`
+	Category     Category        `json:"category" bson:"category"`
+	Action       Action          `json:"action" bson:"action"`
+	Actor        string          `json:"actor" bson:"actor"` // ID of the user acting, or the username of failed logins
+	ActorRole    string          `json:"actorRole,omitempty" bson:"actorRole,omitempty"`
+	ResourceType string          `json:"resourceType" bson:"resourceType"`
+	ResourceID   string          `json:"resourceId,omitempty" bson:"resourceId,omitempty"`
+	Before       json.RawMessage `json:"before,omitempty" bson:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty" bson:"after,omitempty"`
+	IP           string          `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent    string          `json:"userAgent,omitempty" bson:"userAgent,omitempty"`
+	Details      string          `json:"details,omitempty" bson:"details,omitempty"`
+}
+
+// Filter selects audit events, empty fields matching every event
+type Filter struct {
+	Actor        string
+	Category     Category
+	Action       Action
+	ResourceType string
+	ResourceID   string
+	From         time.Time
+	To           time.Time
+	Limit        int // Of the events returned, oldest first
+}
+
+// Matches reports whether an event is selected by the filter
+func (f Filter) Matches(event Event) bool {
+	return (f.Actor == "" || event.Actor == f.Actor) &&
+		(f.Category == "" || event.Category == f.Category) &&
+		(f.Action == "" || event.Action == f.Action) &&
+		(f.ResourceType == "" || event.ResourceType == f.ResourceType) &&
+		(f.ResourceID == "" || event.ResourceID == f.ResourceID) &&
+		(f.From.IsZero() || !event.Timestamp.Before(f.From)) &&
+		(f.To.IsZero() || event.Timestamp.Before(f.To))
+}
+
+// Store persists audit events. Events are only ever appended, a store offers no
+// way of changing or removing them.
+type Store interface {
+	Append(event *Event) error
+	Query(filter Filter) ([]Event, error)
+}
+
+// Logger records audit events to a store. A nil logger records nothing, so that
+// auditing stays optional for the components using it.
+type Logger struct {
+	store Store
+}
+
+// NewLogger creates a new Logger
+func NewLogger(store Store) *Logger {
+	return &Logger{store: store}
+}
+
+// Record records an audit event. The actor, their role and the client they called
+// from are taken from ctx unless set. The audit log failing does not fail the
+// audited operation, but is logged.
+func (l *Logger) Record(ctx context.Context, event Event) {
+	if l == nil {
+		return
+	}
+
+	event.ID = uuid.New().String()
+	event.Timestamp = time.Now()
+	if event.Actor == "" {
+		event.Actor = auth.GetUserIDFromContext(ctx)
+	}
+	if event.ActorRole == "" {
+		event.ActorRole = auth.GetRoleFromContext(ctx)
+	}
+	if client, ok := ctx.Value(clientKey).(clientInfo); ok {
+		event.IP = client.ip
+		event.UserAgent = client.userAgent
+	}
+
+	if err := l.store.Append(&event); err != nil {
+		log.Printf("Error writing audit event %s %s %s/%s by %s: %v",
+			event.Category, event.Action, event.ResourceType, event.ResourceID, event.Actor, err)
+	}
+}
+
+// Query returns the audit events selected by a filter, oldest first
+func (l *Logger) Query(filter Filter) ([]Event, error) {
+	if l == nil {
+		return []Event{}, nil
+	}
+	return l.store.Query(filter)
+}
+
+// Snapshot returns the state of a resource to record as the before or after of an
+// event, nil when there is none, nil pointers included
+func Snapshot(resource interface{}) json.RawMessage {
+	if resource == nil {
+		return nil
+	}
+	data, err := json.Marshal(resource)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return data
+}
+
+// contextKey is the type of the keys of the values the package keeps in contexts
+type contextKey string
+
+// clientKey is the key of the client of the request in contexts
+const clientKey contextKey = "auditClient"
+
+// clientInfo describes the client a request came from
+type clientInfo struct {
+	ip        string
+	userAgent string
+}
+
+// Middleware keeps the address and user agent of the client of every request in
+// its context, for the events recorded while handling it
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientKey, clientInfo{
+			ip:        clientIP(r),
+			userAgent: r.UserAgent(),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// clientIP returns the address of the client of a request, the first address of
+// X-Forwarded-For when behind a proxy
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
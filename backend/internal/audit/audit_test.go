@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trading-platform/backend/internal/auth"
+)
+
+// failingStore is a store failing every append
+type failingStore struct{}
+
+func (failingStore) Append(event *Event) error            { return errors.New("store unavailable") }
+func (failingStore) Query(filter Filter) ([]Event, error) { return nil, nil }
+
+func TestLoggerRecord(t *testing.T) {
+	store := NewMemoryStore()
+	logger := NewLogger(store)
+
+	// Events are recorded by the authenticated user, from the client of the request
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := auth.SetUserIDInContext(r.Context(), "admin1")
+		ctx = auth.SetRoleInContext(ctx, "ADMIN")
+		logger.Record(ctx, Event{
+			Category:     CategoryAdmin,
+			Action:       ActionLimitUpdated,
+			ResourceType: "limit",
+			ResourceID:   "limit1",
+			Before:       Snapshot(map[string]int{"maxQuantity": 100}),
+			After:        Snapshot(map[string]int{"maxQuantity": 200}),
+		})
+	}))
+	req := httptest.NewRequest("PUT", "/api/risk/limits/limit1", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	req.Header.Set("User-Agent", "console/1.0")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	events, err := store.Query(Filter{})
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.NotEmpty(t, events[0].ID)
+		assert.False(t, events[0].Timestamp.IsZero())
+		assert.Equal(t, "admin1", events[0].Actor)
+		assert.Equal(t, "ADMIN", events[0].ActorRole)
+		assert.Equal(t, "203.0.113.7", events[0].IP)
+		assert.Equal(t, "console/1.0", events[0].UserAgent)
+		assert.JSONEq(t, `{"maxQuantity":100}`, string(events[0].Before))
+		assert.JSONEq(t, `{"maxQuantity":200}`, string(events[0].After))
+	}
+
+	// An actor given is kept, such as the username of a failed login
+	logger.Record(auth.SetUserIDInContext(req.Context(), "user1"), Event{Category: CategoryAuth, Action: ActionLoginFailed, Actor: "trader"})
+	events, _ = store.Query(Filter{Action: ActionLoginFailed})
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "trader", events[0].Actor)
+	}
+
+	// Failing stores and nil loggers do not fail the audited operation
+	NewLogger(failingStore{}).Record(req.Context(), Event{Category: CategoryOrder, Action: ActionOrderCreated})
+	var nilLogger *Logger
+	nilLogger.Record(req.Context(), Event{Category: CategoryOrder, Action: ActionOrderCreated})
+	events, err = nilLogger.Query(Filter{})
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestSnapshot(t *testing.T) {
+	type limit struct {
+		MaxQuantity int `json:"maxQuantity"`
+	}
+	var missing *limit
+
+	assert.JSONEq(t, `{"maxQuantity":100}`, string(Snapshot(&limit{MaxQuantity: 100})))
+	assert.Nil(t, Snapshot(nil))
+	assert.Nil(t, Snapshot(missing))
+}
+
+func TestMemoryStoreQuery(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2026, 3, 2, 9, 15, 0, 0, time.UTC)
+	for i, event := range []Event{
+		{Category: CategoryAuth, Action: ActionLogin, Actor: "user1", ResourceType: "user", ResourceID: "user1"},
+		{Category: CategoryOrder, Action: ActionOrderCreated, Actor: "user1", ResourceType: "order", ResourceID: "order1"},
+		{Category: CategoryOrder, Action: ActionOrderCancelled, Actor: "user1", ResourceType: "order", ResourceID: "order1"},
+		{Category: CategoryOrder, Action: ActionOrderCreated, Actor: "user2", ResourceType: "order", ResourceID: "order2"},
+	} {
+		event.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		assert.NoError(t, store.Append(&event))
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{"everything", Filter{}, []string{"user1", "order1", "order1", "order2"}},
+		{"actor", Filter{Actor: "user2"}, []string{"order2"}},
+		{"category", Filter{Category: CategoryAuth}, []string{"user1"}},
+		{"action", Filter{Action: ActionOrderCreated}, []string{"order1", "order2"}},
+		{"resource", Filter{ResourceType: "order", ResourceID: "order1"}, []string{"order1", "order1"}},
+		{"time range", Filter{From: start.Add(time.Minute), To: start.Add(3 * time.Minute)}, []string{"order1", "order1"}},
+		{"limit", Filter{Category: CategoryOrder, Limit: 2}, []string{"order1", "order1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := store.Query(tt.filter)
+			assert.NoError(t, err)
+			resources := make([]string, 0, len(events))
+			for _, event := range events {
+				resources = append(resources, event.ResourceID)
+			}
+			assert.Equal(t, tt.expected, resources)
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	events := []Event{
+		{
+			ID:           "event1",
+			Timestamp:    time.Date(2026, 3, 2, 9, 15, 0, 0, time.UTC),
+			Category:     CategoryOrder,
+			Action:       ActionOrderModified,
+			Actor:        "user1",
+			ResourceType: "order",
+			ResourceID:   "order1",
+			Before:       json.RawMessage(`{"price":100,"note":"a, b"}`),
+			After:        json.RawMessage(`{"price":101}`),
+			IP:           "203.0.113.7",
+		},
+		{
+			ID:        "event2",
+			Timestamp: time.Date(2026, 3, 2, 9, 16, 0, 0, time.UTC),
+			Category:  CategoryAuth,
+			Action:    ActionLoginFailed,
+			Actor:     "trader",
+			Details:   "wrong password",
+		},
+	}
+
+	// CSV exports have a header row and one row per event, snapshots quoted
+	var buffer bytes.Buffer
+	assert.NoError(t, Export(&buffer, events, FormatCSV))
+	rows, err := csv.NewReader(&buffer).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, csvHeader, rows[0])
+		assert.Equal(t, []string{
+			"event1", "2026-03-02T09:15:00Z", "ORDER", "ORDER_MODIFIED", "user1", "", "order", "order1",
+			`{"price":100,"note":"a, b"}`, `{"price":101}`, "203.0.113.7", "", "",
+		}, rows[1])
+		assert.Equal(t, "wrong password", rows[2][12])
+	}
+
+	// JSON lines exports have one event per line
+	buffer.Reset()
+	assert.NoError(t, Export(&buffer, events, FormatJSONLines))
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		var event Event
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &event))
+		assert.Equal(t, events[0], event)
+	}
+
+	assert.Equal(t, ErrUnsupportedFormat, Export(&buffer, events, Format("xml")))
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// Format is a file format audit events are exported in
+type Format string
+
+const (
+	FormatJSONLines Format = "jsonl" // One JSON event per line
+	FormatCSV       Format = "csv"
+)
+
+// ErrUnsupportedFormat is returned when exporting in a format that is not supported
+var ErrUnsupportedFormat = errors.New("unsupported audit export format")
+
+// csvHeader is the header row of CSV exports
+var csvHeader = []string{
+	"id", "timestamp", "category", "action", "actor", "actorRole",
+	"resourceType", "resourceId", "before", "after", "ip", "userAgent", "details",
+}
+
+// ContentType returns the MIME type of a format
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+// Export writes events to w in a format
+func Export(w io.Writer, events []Event, format Format) error {
+	switch format {
+	case FormatJSONLines:
+		encoder := json.NewEncoder(w)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := writer.Write([]string{
+				event.ID, event.Timestamp.UTC().Format(time.RFC3339Nano), string(event.Category), string(event.Action),
+				event.Actor, event.ActorRole, event.ResourceType, event.ResourceID,
+				string(event.Before), string(event.After), event.IP, event.UserAgent, event.Details,
+			}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return ErrUnsupportedFormat
+	}
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MemoryStore keeps audit events in memory
+type MemoryStore struct {
+	events []Event
+	mutex  sync.RWMutex
+}
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append adds an event to the store
+func (s *MemoryStore) Append(event *Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, *event)
+	return nil
+}
+
+// Query returns the events selected by a filter, oldest first
+func (s *MemoryStore) Query(filter Filter) ([]Event, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	events := make([]Event, 0)
+	for _, event := range s.events {
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+		if filter.Matches(event) {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// MongoStore keeps audit events in a MongoDB collection, only ever inserting into
+// it. Write access to the collection should be limited to inserts as well.
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoStore creates a new MongoStore
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		collection: db.Collection("audit_events"),
+	}
+}
+
+// Append inserts an event into the collection
+func (s *MongoStore) Append(event *Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.collection.InsertOne(ctx, event)
+	return err
+}
+
+// Query returns the events selected by a filter, oldest first
+func (s *MongoStore) Query(filter Filter) ([]Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.Actor != "" {
+		query["actor"] = filter.Actor
+	}
+	if filter.Category != "" {
+		query["category"] = filter.Category
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.ResourceType != "" {
+		query["resourceType"] = filter.ResourceType
+	}
+	if filter.ResourceID != "" {
+		query["resourceId"] = filter.ResourceID
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		timestamp := bson.M{}
+		if !filter.From.IsZero() {
+			timestamp["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			timestamp["$lt"] = filter.To
+		}
+		query["timestamp"] = timestamp
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.M{"timestamp": 1})
+	if filter.Limit > 0 {
+		findOptions.SetLimit(int64(filter.Limit))
+	}
+
+	cursor, err := s.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := make([]Event, 0)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -1,6 +1,8 @@
 // Package common provides models shared across all broker implementations
 package common
 
+import "time"
+
 // Credentials represents authentication credentials for a broker
 type Credentials struct {
 	APIKey     string
@@ -18,6 +20,15 @@ type Session struct {
 	RefreshToken string
 }
 
+// SessionInfo describes the session of a broker account logged in
+type SessionInfo struct {
+	ClientID  string
+	UserID    string
+	Broker    BrokerType
+	LoginAt   time.Time
+	ExpiresAt time.Time
+}
+
 // Order represents a trading order
 type Order struct {
 	ExchangeSegment      string
@@ -136,3 +147,15 @@ type Quote struct {
 	AskSize              int
 	Timestamp            int64
 }
+
+// BasketMargin represents the margin a basket of orders requires
+type BasketMargin struct {
+	Initial float64 // Margin of the basket on its own
+	Final   float64 // Margin of the account's positions and the basket together
+}
+
+// AccountMargin represents the margin of a broker account
+type AccountMargin struct {
+	Available float64 // Margin free to take new positions
+	Utilized  float64 // Margin blocked by the account's positions and orders
+}
@@ -10,25 +10,32 @@ import (
 	"github.com/trading-platform/backend/internal/broker/zerodha"
 )
 
-// NewBrokerClient creates a new broker client based on the provided configuration
+// NewBrokerClient creates a new broker client based on the provided configuration,
+// its calls being counted and timed
 func NewBrokerClient(config *common.BrokerConfig) (common.BrokerClient, error) {
+	var brokerClient common.BrokerClient
+	var err error
 	switch config.BrokerType {
 	case common.BrokerTypeXTSPro:
 		if config.XTSPro == nil {
 			return nil, errors.New("XTS Pro configuration is required")
 		}
-		return pro.NewXTSProClient(config.XTSPro)
+		brokerClient, err = pro.NewXTSProClient(config.XTSPro)
 	case common.BrokerTypeXTSClient:
 		if config.XTSClient == nil {
 			return nil, errors.New("XTS Client configuration is required")
 		}
-		return client.NewXTSClientImpl(config.XTSClient)
+		brokerClient, err = client.NewXTSClientImpl(config.XTSClient)
 	case common.BrokerTypeZerodha:
 		if config.Zerodha == nil {
 			return nil, errors.New("Zerodha configuration is required")
 		}
-		return zerodha.NewZerodhaAdapter(config.Zerodha)
+		brokerClient, err = zerodha.NewZerodhaAdapter(config.Zerodha)
 	default:
 		return nil, errors.New("unsupported broker type")
 	}
+	if err != nil {
+		return nil, err
+	}
+	return instrument(brokerClient, config.BrokerType), nil
 }
@@ -0,0 +1,108 @@
+package factory
+
+import (
+	"time"
+
+	"github.com/trading-platform/backend/internal/broker/common"
+	"github.com/trading-platform/backend/internal/metrics"
+)
+
+// instrumentedClient is a broker client counting and timing the calls made to
+// the client it wraps
+type instrumentedClient struct {
+	client common.BrokerClient
+	broker string
+}
+
+// instrument wraps a broker client to count and time its calls
+func instrument(client common.BrokerClient, brokerType common.BrokerType) common.BrokerClient {
+	return &instrumentedClient{client: client, broker: string(brokerType)}
+}
+
+// Login implements common.BrokerClient
+func (c *instrumentedClient) Login(credentials *common.Credentials) (*common.Session, error) {
+	start := time.Now()
+	session, err := c.client.Login(credentials)
+	metrics.ObserveBrokerCall(c.broker, "Login", start, err)
+	return session, err
+}
+
+// Logout implements common.BrokerClient
+func (c *instrumentedClient) Logout() error {
+	start := time.Now()
+	err := c.client.Logout()
+	metrics.ObserveBrokerCall(c.broker, "Logout", start, err)
+	return err
+}
+
+// PlaceOrder implements common.BrokerClient
+func (c *instrumentedClient) PlaceOrder(order *common.Order) (*common.OrderResponse, error) {
+	start := time.Now()
+	response, err := c.client.PlaceOrder(order)
+	metrics.ObserveBrokerCall(c.broker, "PlaceOrder", start, err)
+	return response, err
+}
+
+// ModifyOrder implements common.BrokerClient
+func (c *instrumentedClient) ModifyOrder(order *common.ModifyOrder) (*common.OrderResponse, error) {
+	start := time.Now()
+	response, err := c.client.ModifyOrder(order)
+	metrics.ObserveBrokerCall(c.broker, "ModifyOrder", start, err)
+	return response, err
+}
+
+// CancelOrder implements common.BrokerClient
+func (c *instrumentedClient) CancelOrder(orderID string, clientID string) (*common.OrderResponse, error) {
+	start := time.Now()
+	response, err := c.client.CancelOrder(orderID, clientID)
+	metrics.ObserveBrokerCall(c.broker, "CancelOrder", start, err)
+	return response, err
+}
+
+// GetOrderBook implements common.BrokerClient
+func (c *instrumentedClient) GetOrderBook(clientID string) (*common.OrderBook, error) {
+	start := time.Now()
+	orderBook, err := c.client.GetOrderBook(clientID)
+	metrics.ObserveBrokerCall(c.broker, "GetOrderBook", start, err)
+	return orderBook, err
+}
+
+// GetPositions implements common.BrokerClient
+func (c *instrumentedClient) GetPositions(clientID string) ([]common.Position, error) {
+	start := time.Now()
+	positions, err := c.client.GetPositions(clientID)
+	metrics.ObserveBrokerCall(c.broker, "GetPositions", start, err)
+	return positions, err
+}
+
+// GetHoldings implements common.BrokerClient
+func (c *instrumentedClient) GetHoldings(clientID string) ([]common.Holding, error) {
+	start := time.Now()
+	holdings, err := c.client.GetHoldings(clientID)
+	metrics.ObserveBrokerCall(c.broker, "GetHoldings", start, err)
+	return holdings, err
+}
+
+// GetQuote implements common.BrokerClient
+func (c *instrumentedClient) GetQuote(symbols []string) (map[string]common.Quote, error) {
+	start := time.Now()
+	quotes, err := c.client.GetQuote(symbols)
+	metrics.ObserveBrokerCall(c.broker, "GetQuote", start, err)
+	return quotes, err
+}
+
+// SubscribeToQuotes implements common.BrokerClient
+func (c *instrumentedClient) SubscribeToQuotes(symbols []string) (chan common.Quote, error) {
+	start := time.Now()
+	quotes, err := c.client.SubscribeToQuotes(symbols)
+	metrics.ObserveBrokerCall(c.broker, "SubscribeToQuotes", start, err)
+	return quotes, err
+}
+
+// UnsubscribeFromQuotes implements common.BrokerClient
+func (c *instrumentedClient) UnsubscribeFromQuotes(symbols []string) error {
+	start := time.Now()
+	err := c.client.UnsubscribeFromQuotes(symbols)
+	metrics.ObserveBrokerCall(c.broker, "UnsubscribeFromQuotes", start, err)
+	return err
+}
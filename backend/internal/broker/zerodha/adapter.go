@@ -2,6 +2,8 @@
 package zerodha
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -428,6 +430,95 @@ func (z *ZerodhaAdapter) UnsubscribeFromQuotes(symbols []string) error {
 	return errors.New("real-time quotes not yet implemented")
 }
 
+// basketMarginOrder is an order of a basket margin request
+type basketMarginOrder struct {
+	Exchange        string  `json:"exchange"`
+	TradingSymbol   string  `json:"tradingsymbol"`
+	TransactionType string  `json:"transaction_type"`
+	Variety         string  `json:"variety"`
+	Product         string  `json:"product"`
+	OrderType       string  `json:"order_type"`
+	Quantity        int     `json:"quantity"`
+	Price           float64 `json:"price"`
+	TriggerPrice    float64 `json:"trigger_price"`
+}
+
+// basketMarginResponse is the response of the basket margin API
+type basketMarginResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Initial struct {
+			Total float64 `json:"total"`
+		} `json:"initial"`
+		Final struct {
+			Total float64 `json:"total"`
+		} `json:"final"`
+	} `json:"data"`
+}
+
+// GetBasketMargin retrieves the margin a basket of orders requires from the Zerodha
+// API, taking the account's open positions into account for the final margin
+func (z *ZerodhaAdapter) GetBasketMargin(orders []*common.Order) (*common.BasketMargin, error) {
+	if z.accessToken == "" {
+		return nil, errors.New("not logged in")
+	}
+
+	if len(orders) == 0 {
+		return nil, errors.New("at least one order is required")
+	}
+
+	// Map common orders to Zerodha margin orders
+	basket := make([]basketMarginOrder, len(orders))
+	for i, order := range orders {
+		basket[i] = basketMarginOrder{
+			Exchange:        mapExchangeSegment(order.ExchangeSegment),
+			TradingSymbol:   order.TradingSymbol,
+			TransactionType: mapOrderSide(order.OrderSide),
+			Variety:         kiteconnect.VarietyRegular,
+			Product:         mapProductType(order.ProductType),
+			OrderType:       mapOrderType(order.OrderType),
+			Quantity:        order.OrderQuantity,
+			Price:           order.LimitPrice,
+			TriggerPrice:    order.StopPrice,
+		}
+	}
+
+	body, err := json.Marshal(basket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode basket: %w", err)
+	}
+
+	request, err := http.NewRequest("POST", z.baseURL+"/margins/basket?consider_positions=true", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Kite-Version", "3")
+	request.Header.Set("Authorization", fmt.Sprintf("token %s:%s", z.apiKey, z.accessToken))
+
+	// Get the basket margin
+	response, err := z.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get basket margin: %w", err)
+	}
+	defer response.Body.Close()
+
+	var marginResponse basketMarginResponse
+	if err := json.NewDecoder(response.Body).Decode(&marginResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode basket margin: %w", err)
+	}
+	if response.StatusCode != http.StatusOK || marginResponse.Status != "success" {
+		return nil, fmt.Errorf("failed to get basket margin: %s", marginResponse.Message)
+	}
+
+	// Convert the response to the common BasketMargin model
+	return &common.BasketMargin{
+		Initial: marginResponse.Data.Initial.Total,
+		Final:   marginResponse.Data.Final.Total,
+	}, nil
+}
+
 // Helper functions to map between common and Zerodha-specific values
 
 // mapExchangeSegment maps common exchange segment to Zerodha exchange
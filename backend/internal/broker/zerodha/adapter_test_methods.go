@@ -383,3 +383,80 @@ func TestCancelOrder(t *testing.T) {
 	assert.Nil(t, orderResponse)
 	assert.Contains(t, err.Error(), "order ID is required")
 }
+
+// TestGetBasketMargin tests the GetBasketMargin method
+func TestGetBasketMargin(t *testing.T) {
+	// Setup mock server for the basket margin
+	server := mockKiteConnectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/margins/basket", r.URL.Path)
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "true", r.URL.Query().Get("consider_positions"))
+		assert.Equal(t, "token test_api_key:test_access_token", r.Header.Get("Authorization"))
+
+		// Parse the basket
+		var basket []map[string]interface{}
+		err := json.NewDecoder(r.Body).Decode(&basket)
+		assert.NoError(t, err)
+		assert.Len(t, basket, 1)
+		assert.Equal(t, "NFO", basket[0]["exchange"])
+		assert.Equal(t, "NIFTY24JUNFUT", basket[0]["tradingsymbol"])
+		assert.Equal(t, "SELL", basket[0]["transaction_type"])
+		assert.Equal(t, "NRML", basket[0]["product"])
+
+		// Return success response
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		response := `{
+			"status": "success",
+			"data": {
+				"initial": {"span": 95000, "exposure": 20000, "total": 115000},
+				"final": {"span": 20000, "exposure": 20000, "total": 40000}
+			}
+		}`
+		w.Write([]byte(response))
+	})
+	defer server.Close()
+
+	// Create adapter with mock server URL
+	config := &common.ZerodhaConfig{
+		APIKey:      "test_api_key",
+		APISecret:   "test_api_secret",
+		RedirectURL: "https://example.com/redirect",
+		BaseURL:     server.URL,
+	}
+
+	adapter, err := NewZerodhaAdapter(config)
+	assert.NoError(t, err)
+
+	// Set access token
+	adapter.accessToken = "test_access_token"
+
+	// Test basket margin
+	orders := []*common.Order{{
+		ExchangeSegment: "NSEFO",
+		TradingSymbol:   "NIFTY24JUNFUT",
+		OrderSide:       "SELL",
+		OrderQuantity:   50,
+		ProductType:     "NRML",
+		OrderType:       "MARKET",
+	}}
+
+	margin, err := adapter.GetBasketMargin(orders)
+	assert.NoError(t, err)
+	assert.NotNil(t, margin)
+	assert.Equal(t, 115000.0, margin.Initial)
+	assert.Equal(t, 40000.0, margin.Final)
+
+	// Test basket margin when not logged in
+	adapter.accessToken = ""
+	margin, err = adapter.GetBasketMargin(orders)
+	assert.Error(t, err)
+	assert.Nil(t, margin)
+	assert.Contains(t, err.Error(), "not logged in")
+
+	// Test basket margin with an empty basket
+	adapter.accessToken = "test_access_token"
+	margin, err = adapter.GetBasketMargin(nil)
+	assert.Error(t, err)
+	assert.Nil(t, margin)
+}
@@ -0,0 +1,177 @@
+// Package businessmetrics reports the state of the business for operational
+// dashboards: the orders placed, filled, rejected and cancelled each interval, the
+// strategies trading, the live P&L of every tenant and the backtests running.
+// Snapshots are taken every interval, exported to Prometheus and optionally
+// stored in a warehouse for reporting over longer periods.
+package businessmetrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"trading-platform/backend/internal/metrics"
+)
+
+// DefaultInterval is how often snapshots are taken unless configured otherwise,
+// orders being counted per minute
+const DefaultInterval = time.Minute
+
+// Snapshot is the state of the business at a point in time, with the orders of
+// the interval that ended then
+type Snapshot struct {
+	Time             time.Time          `json:"time"`
+	Interval         time.Duration      `json:"interval"`
+	OrdersPlaced     int64              `json:"ordersPlaced"`
+	OrdersFilled     int64              `json:"ordersFilled"`
+	OrdersRejected   int64              `json:"ordersRejected"`
+	OrdersCancelled  int64              `json:"ordersCancelled"`
+	ActiveStrategies int                `json:"activeStrategies"`
+	BacktestsRunning int                `json:"backtestsRunning"`
+	LivePnL          map[string]float64 `json:"livePnl"` // By tenant
+}
+
+// Sources report the state of the business orders are not counted for. Sources
+// left nil are reported zero.
+type Sources struct {
+	ActiveStrategies func() int
+	LivePnL          func() map[string]float64 // By tenant
+}
+
+// Warehouse stores snapshots for reporting
+type Warehouse interface {
+	Store(ctx context.Context, snapshot Snapshot) error
+}
+
+// Pipeline takes snapshots of the business every interval, exporting them to
+// Prometheus and storing them in its warehouse if any
+type Pipeline struct {
+	sources     Sources
+	interval    time.Duration
+	warehouse   Warehouse
+	lastTotals  map[string]int64 // Orders counted by event as of the last snapshot
+	lastTakenAt time.Time
+	latest      Snapshot
+	isRunning   bool
+	stopChan    chan struct{}
+	mutex       sync.Mutex
+}
+
+// NewPipeline creates a new pipeline taking snapshots every interval,
+// DefaultInterval when not positive. Orders counted before are not reported.
+func NewPipeline(sources Sources, interval time.Duration) *Pipeline {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Pipeline{
+		sources:     sources,
+		interval:    interval,
+		lastTotals:  metrics.OrderEventTotals(),
+		lastTakenAt: time.Now(),
+	}
+}
+
+// SetWarehouse stores every snapshot taken in a warehouse
+func (p *Pipeline) SetWarehouse(warehouse Warehouse) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.warehouse = warehouse
+}
+
+// Start takes a snapshot every interval until stopped
+func (p *Pipeline) Start() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.isRunning {
+		return errors.New("business metrics pipeline is already running")
+	}
+	p.isRunning = true
+	p.stopChan = make(chan struct{})
+
+	go p.run(p.stopChan)
+	return nil
+}
+
+// Stop stops taking snapshots
+func (p *Pipeline) Stop() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.isRunning {
+		return
+	}
+	close(p.stopChan)
+	p.isRunning = false
+}
+
+// run takes a snapshot every interval until stop is closed
+func (p *Pipeline) run(stop chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+			if _, err := p.Collect(ctx); err != nil {
+				log.Printf("Failed to store business metrics: %v", err)
+			}
+			cancel()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Collect takes a snapshot, with the orders counted since the last one, exporting
+// it to Prometheus and storing it in the warehouse. The snapshot is returned even
+// when storing it failed.
+func (p *Pipeline) Collect(ctx context.Context) (Snapshot, error) {
+	snapshot := Snapshot{
+		Time:             time.Now(),
+		BacktestsRunning: metrics.BacktestsRunning(),
+		LivePnL:          make(map[string]float64),
+	}
+	if p.sources.ActiveStrategies != nil {
+		snapshot.ActiveStrategies = p.sources.ActiveStrategies()
+	}
+	if p.sources.LivePnL != nil {
+		for tenant, pnl := range p.sources.LivePnL() {
+			snapshot.LivePnL[tenant] = pnl
+		}
+	}
+	totals := metrics.OrderEventTotals()
+
+	p.mutex.Lock()
+	snapshot.Interval = snapshot.Time.Sub(p.lastTakenAt)
+	snapshot.OrdersPlaced = totals[metrics.OrderPlaced] - p.lastTotals[metrics.OrderPlaced]
+	snapshot.OrdersFilled = totals[metrics.OrderFilled] - p.lastTotals[metrics.OrderFilled]
+	snapshot.OrdersRejected = totals[metrics.OrderRejected] - p.lastTotals[metrics.OrderRejected]
+	snapshot.OrdersCancelled = totals[metrics.OrderCancelled] - p.lastTotals[metrics.OrderCancelled]
+	p.lastTotals = totals
+	p.lastTakenAt = snapshot.Time
+	p.latest = snapshot
+	warehouse := p.warehouse
+	p.mutex.Unlock()
+
+	// Orders are exported as they are counted, rates being taken by Prometheus
+	metrics.SetActiveStrategies(snapshot.ActiveStrategies)
+	metrics.SetLivePnL(snapshot.LivePnL)
+
+	if warehouse != nil {
+		if err := warehouse.Store(ctx, snapshot); err != nil {
+			return snapshot, err
+		}
+	}
+	return snapshot, nil
+}
+
+// Latest returns the last snapshot taken, zero before the first one
+func (p *Pipeline) Latest() Snapshot {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.latest
+}
@@ -0,0 +1,86 @@
+package businessmetrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"trading-platform/backend/internal/metrics"
+)
+
+// mockWarehouse records the snapshots stored
+type mockWarehouse struct {
+	snapshots []Snapshot
+	err       error
+}
+
+func (w *mockWarehouse) Store(ctx context.Context, snapshot Snapshot) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.snapshots = append(w.snapshots, snapshot)
+	return nil
+}
+
+func TestPipelineCollect(t *testing.T) {
+	// Orders counted before the pipeline was created are not reported
+	metrics.ObserveOrderEvent(metrics.OrderPlaced)
+
+	pnl := map[string]float64{"user1": 1250.5, "user2": -300}
+	pipeline := NewPipeline(Sources{
+		ActiveStrategies: func() int { return 3 },
+		LivePnL:          func() map[string]float64 { return pnl },
+	}, 0)
+	warehouse := &mockWarehouse{}
+	pipeline.SetWarehouse(warehouse)
+
+	for i := 0; i < 4; i++ {
+		metrics.ObserveOrderEvent(metrics.OrderPlaced)
+	}
+	metrics.ObserveOrderEvent(metrics.OrderFilled)
+	metrics.ObserveOrderEvent(metrics.OrderFilled)
+	metrics.ObserveOrderEvent(metrics.OrderRejected)
+	metrics.BacktestStarted()
+	defer metrics.BacktestFinished()
+
+	snapshot, err := pipeline.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), snapshot.OrdersPlaced)
+	assert.Equal(t, int64(2), snapshot.OrdersFilled)
+	assert.Equal(t, int64(1), snapshot.OrdersRejected)
+	assert.Equal(t, int64(0), snapshot.OrdersCancelled)
+	assert.Equal(t, 3, snapshot.ActiveStrategies)
+	assert.Equal(t, 1, snapshot.BacktestsRunning)
+	assert.Equal(t, pnl, snapshot.LivePnL)
+	assert.Positive(t, snapshot.Interval)
+	assert.Equal(t, snapshot, pipeline.Latest())
+	assert.Len(t, warehouse.snapshots, 1)
+
+	// Each snapshot reports the orders of its own interval
+	metrics.ObserveOrderEvent(metrics.OrderCancelled)
+	snapshot, err = pipeline.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), snapshot.OrdersPlaced)
+	assert.Equal(t, int64(1), snapshot.OrdersCancelled)
+
+	// Snapshots are returned when they could not be stored
+	warehouse.err = errors.New("connection refused")
+	snapshot, err = pipeline.Collect(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 3, snapshot.ActiveStrategies)
+}
+
+func TestRows(t *testing.T) {
+	rows := Rows(Snapshot{
+		OrdersPlaced:     12,
+		OrdersFilled:     10,
+		ActiveStrategies: 2,
+		LivePnL:          map[string]float64{"user2": -300, "user1": 1250.5},
+	})
+
+	assert.Len(t, rows, 8)
+	assert.Equal(t, Row{Metric: MetricOrdersPlaced, Value: 12}, rows[0])
+	assert.Equal(t, Row{Metric: MetricLivePnL, Tenant: "user1", Value: 1250.5}, rows[6])
+	assert.Equal(t, Row{Metric: MetricLivePnL, Tenant: "user2", Value: -300}, rows[7])
+}
@@ -0,0 +1,113 @@
+package businessmetrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// Names of the metrics stored in the warehouse
+const (
+	MetricOrdersPlaced     = "orders_placed"
+	MetricOrdersFilled     = "orders_filled"
+	MetricOrdersRejected   = "orders_rejected"
+	MetricOrdersCancelled  = "orders_cancelled"
+	MetricActiveStrategies = "active_strategies"
+	MetricBacktestsRunning = "backtests_running"
+	MetricLivePnL          = "live_pnl"
+)
+
+// Row is a metric of a snapshot as stored in the warehouse, one per tenant for
+// metrics by tenant
+type Row struct {
+	Metric string
+	Tenant string // Empty for metrics of the whole platform
+	Value  float64
+}
+
+// Rows flattens a snapshot into the rows stored in the warehouse, one per metric
+// and tenant, for warehouses to aggregate over any period and tenant
+func Rows(snapshot Snapshot) []Row {
+	rows := []Row{
+		{Metric: MetricOrdersPlaced, Value: float64(snapshot.OrdersPlaced)},
+		{Metric: MetricOrdersFilled, Value: float64(snapshot.OrdersFilled)},
+		{Metric: MetricOrdersRejected, Value: float64(snapshot.OrdersRejected)},
+		{Metric: MetricOrdersCancelled, Value: float64(snapshot.OrdersCancelled)},
+		{Metric: MetricActiveStrategies, Value: float64(snapshot.ActiveStrategies)},
+		{Metric: MetricBacktestsRunning, Value: float64(snapshot.BacktestsRunning)},
+	}
+
+	tenants := make([]string, 0, len(snapshot.LivePnL))
+	for tenant := range snapshot.LivePnL {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		rows = append(rows, Row{Metric: MetricLivePnL, Tenant: tenant, Value: snapshot.LivePnL[tenant]})
+	}
+	return rows
+}
+
+// PostgresWarehouse stores snapshots in a PostgreSQL or TimescaleDB table, one row
+// per metric and tenant
+type PostgresWarehouse struct {
+	db *sql.DB
+}
+
+// NewPostgresWarehouse creates a new PostgreSQL warehouse
+func NewPostgresWarehouse(db *sql.DB) *PostgresWarehouse {
+	return &PostgresWarehouse{
+		db: db,
+	}
+}
+
+// InitializeSchema creates the business_metrics table
+func (w *PostgresWarehouse) InitializeSchema(ctx context.Context) error {
+	_, err := w.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS business_metrics (
+			recorded_at TIMESTAMPTZ NOT NULL,
+			interval_seconds DOUBLE PRECISION NOT NULL,
+			metric TEXT NOT NULL,
+			tenant TEXT NOT NULL DEFAULT '',
+			value DOUBLE PRECISION NOT NULL,
+			PRIMARY KEY (metric, tenant, recorded_at)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create business_metrics table: %w", err)
+	}
+	return nil
+}
+
+// Store stores a snapshot, all its rows or none
+func (w *PostgresWarehouse) Store(ctx context.Context, snapshot Snapshot) error {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO business_metrics (recorded_at, interval_seconds, metric, tenant, value)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (metric, tenant, recorded_at) DO UPDATE SET
+			interval_seconds = EXCLUDED.interval_seconds,
+			value = EXCLUDED.value
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range Rows(snapshot) {
+		if _, err := stmt.ExecContext(ctx, snapshot.Time, snapshot.Interval.Seconds(), row.Metric, row.Tenant, row.Value); err != nil {
+			return fmt.Errorf("failed to store %s: %w", row.Metric, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
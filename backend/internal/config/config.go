@@ -28,8 +28,9 @@ type ServerConfig struct {
 
 // MongoDBConfig represents the MongoDB configuration
 type MongoDBConfig struct {
-	URI      string `json:"uri"`
-	Database string `json:"database"`
+	URI                string        `json:"uri"`
+	Database           string        `json:"database"`
+	SlowQueryThreshold time.Duration `json:"slowQueryThreshold"` // Beyond which commands are logged as slow
 }
 
 // JWTConfig represents the JWT configuration
@@ -87,8 +88,9 @@ func DefaultConfig() *Config {
 			AllowedHeaders:  []string{"Content-Type", "Authorization"},
 		},
 		MongoDB: MongoDBConfig{
-			URI:      "mongodb://localhost:27017",
-			Database: "trading_platform",
+			URI:                "mongodb://localhost:27017",
+			Database:           "trading_platform",
+			SlowQueryThreshold: 100 * time.Millisecond,
 		},
 		JWT: JWTConfig{
 			Secret:           "your-secret-key",
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 	
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/bson"
@@ -11,12 +12,16 @@ import (
 	
 	"trading_platform/backend/internal/models"
 	"trading_platform/backend/internal/config"
+	"trading_platform/backend/internal/metrics"
+	"trading_platform/backend/internal/slowquery"
+	"trading_platform/backend/internal/tracing"
 )
 
 // MongoDB represents the MongoDB client and database connection
 type MongoDB struct {
-	Client   *mongo.Client
-	Database *mongo.Database
+	Client      *mongo.Client
+	Database    *mongo.Database
+	SlowQueries *slowquery.Detector // Of the commands slower than the configured threshold
 }
 
 // Collections
@@ -35,7 +40,10 @@ func NewMongoDB(cfg *config.Config) (*MongoDB, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	
-	clientOptions := options.Client().ApplyURI(cfg.MongoDB.URI)
+	// Trace, count and time every command the client runs, detecting slow ones
+	slowQueries := slowquery.NewDetector(cfg.MongoDB.SlowQueryThreshold)
+	monitor := chainMonitors(tracing.MongoMonitor(), metrics.MongoMonitor(), slowquery.MongoMonitor(slowQueries))
+	clientOptions := options.Client().ApplyURI(cfg.MongoDB.URI).SetMonitor(monitor)
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return nil, err
@@ -53,11 +61,40 @@ func NewMongoDB(cfg *config.Config) (*MongoDB, error) {
 	createIndexes(ctx, db)
 	
 	return &MongoDB{
-		Client:   client,
-		Database: db,
+		Client:      client,
+		Database:    db,
+		SlowQueries: slowQueries,
 	}, nil
 }
 
+// chainMonitors returns a command monitor notifying every monitor in turn, as a
+// client takes a single monitor
+func chainMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Started != nil {
+					monitor.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, monitor := range monitors {
+				if monitor.Succeeded != nil {
+					monitor.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Failed != nil {
+					monitor.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
+
 // Close closes the MongoDB connection
 func (m *MongoDB) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -101,6 +138,13 @@ func createIndexes(ctx context.Context, db *mongo.Database) {
 				{Key: "status", Value: 1},
 			},
 		},
+		{
+			// Orders of a user, latest first, as OrderRepository.Find lists them
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "createdAt", Value: -1},
+			},
+		},
 	}
 	db.Collection(OrderCollection).Indexes().CreateMany(ctx, orderIndexes)
 	
@@ -481,6 +525,18 @@ func (r *PositionRepository) Find(filter models.PositionFilter, page, limit int)
 		query["entryTime"] = bson.M{"$lte": filter.ToDate}
 	}
 	
+	// Positions are last updated when they are closed
+	closed := bson.M{}
+	if !filter.ClosedFrom.IsZero() {
+		closed["$gte"] = filter.ClosedFrom
+	}
+	if !filter.ClosedBefore.IsZero() {
+		closed["$lt"] = filter.ClosedBefore
+	}
+	if len(closed) > 0 {
+		query["updatedAt"] = closed
+	}
+	
 	if len(filter.Tags) > 0 {
 		query["tags"] = bson.M{"$in": filter.Tags}
 	}
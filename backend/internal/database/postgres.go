@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
+
+	"trading_platform/backend/internal/slowquery"
 )
 
 // Config holds the database configuration
@@ -17,11 +19,16 @@ type Config struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// SlowQueryThreshold is the duration beyond which queries are logged as slow,
+	// slowquery.DefaultThreshold when zero
+	SlowQueryThreshold time.Duration
 }
 
 // PostgresDB represents a PostgreSQL database connection
 type PostgresDB struct {
-	pool *pgxpool.Pool
+	pool        *pgxpool.Pool
+	slowQueries *slowquery.Detector
 }
 
 // NewPostgresDB creates a new PostgreSQL database connection
@@ -34,7 +41,16 @@ func NewPostgresDB(config Config) (*PostgresDB, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	pool, err := pgxpool.Connect(ctx, connString)
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %w", err)
+	}
+
+	// Detect slow queries from those the connections log
+	slowQueries := slowquery.NewDetector(config.SlowQueryThreshold)
+	poolConfig.ConnConfig.Logger = slowquery.PgxLogger(slowQueries, nil)
+
+	pool, err := pgxpool.ConnectConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -45,7 +61,7 @@ func NewPostgresDB(config Config) (*PostgresDB, error) {
 	}
 
 	log.Println("Successfully connected to PostgreSQL database")
-	return &PostgresDB{pool: pool}, nil
+	return &PostgresDB{pool: pool, slowQueries: slowQueries}, nil
 }
 
 // Close closes the database connection
@@ -60,6 +76,11 @@ func (db *PostgresDB) GetPool() *pgxpool.Pool {
 	return db.pool
 }
 
+// GetSlowQueries returns the detector of the slow queries run on the pool
+func (db *PostgresDB) GetSlowQueries() *slowquery.Detector {
+	return db.slowQueries
+}
+
 // InitSchema initializes the database schema
 func (db *PostgresDB) InitSchema(ctx context.Context) error {
 	// Create TimescaleDB extension if not exists
@@ -0,0 +1,154 @@
+// Package diagnostics exposes the profiles, goroutines and internal state of the
+// service under /debug, for admins to find what causes latency spikes in
+// production. Every endpoint requires an admin token.
+package diagnostics
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// StateFunc returns the internal state of a component, such as the depth of its
+// queues or the size of its caches, to be encoded as JSON
+type StateFunc func() interface{}
+
+// RuntimeStats reports the goroutines, memory and garbage collections of the
+// service
+type RuntimeStats struct {
+	GoVersion      string        `json:"goVersion"`
+	Uptime         string        `json:"uptime"`
+	CPUs           int           `json:"cpus"`
+	GOMAXPROCS     int           `json:"gomaxprocs"`
+	Goroutines     int           `json:"goroutines"`
+	HeapAlloc      uint64        `json:"heapAlloc"` // In bytes
+	HeapInuse      uint64        `json:"heapInuse"`
+	HeapObjects    uint64        `json:"heapObjects"`
+	Sys            uint64        `json:"sys"`
+	NumGC          uint32        `json:"numGC"`
+	LastGC         time.Time     `json:"lastGC"`
+	LastGCPause    time.Duration `json:"lastGCPauseNs"`
+	TotalGCPause   time.Duration `json:"totalGCPauseNs"`
+	GCCPUFraction  float64       `json:"gcCPUFraction"` // Of the CPU time used by garbage collections since start
+	NextGCHeapSize uint64        `json:"nextGCHeapSize"`
+}
+
+// Handler handles the debug endpoints, reporting the state of the components
+// registered with it
+type Handler struct {
+	components map[string]StateFunc
+	started    time.Time
+	mutex      sync.RWMutex
+}
+
+// NewHandler creates a new Handler
+func NewHandler() *Handler {
+	return &Handler{
+		components: make(map[string]StateFunc),
+		started:    time.Now(),
+	}
+}
+
+// Register reports the state of a component under its name
+func (h *Handler) Register(name string, state StateFunc) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.components[name] = state
+}
+
+// RegisterRoutes registers the debug endpoints on a router, limited to admins:
+//
+//	/debug/pprof/...      CPU, heap, goroutine, mutex and block profiles and traces
+//	/debug/runtime        goroutines, memory and garbage collections
+//	/debug/state          state of every component, or of those named by the
+//	                      component query parameter
+//
+// Goroutine dumps with their stacks are served by /debug/pprof/goroutine?debug=2.
+// CPU profiles and traces must take less than the server's write timeout, with
+// the seconds query parameter.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	debug := router.PathPrefix("/debug").Subrouter()
+	debug.Use(auth.AuthMiddleware)
+	debug.Use(auth.RoleMiddleware(string(models.UserRoleAdmin)))
+
+	debug.HandleFunc("/pprof/cmdline", pprof.Cmdline).Methods("GET")
+	debug.HandleFunc("/pprof/profile", pprof.Profile).Methods("GET")
+	debug.HandleFunc("/pprof/symbol", pprof.Symbol).Methods("GET", "POST")
+	debug.HandleFunc("/pprof/trace", pprof.Trace).Methods("GET")
+	debug.PathPrefix("/pprof/").HandlerFunc(pprof.Index).Methods("GET")
+
+	debug.HandleFunc("/runtime", h.GetRuntime).Methods("GET")
+	debug.HandleFunc("/state", h.GetState).Methods("GET")
+}
+
+// GetRuntime handles retrieving the runtime stats of the service
+func (h *Handler) GetRuntime(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, h.runtimeStats())
+}
+
+// GetState handles retrieving the state of the registered components, by name
+func (h *Handler) GetState(w http.ResponseWriter, r *http.Request) {
+	names := r.URL.Query()["component"]
+
+	h.mutex.RLock()
+	components := make(map[string]StateFunc, len(h.components))
+	for name, state := range h.components {
+		components[name] = state
+	}
+	h.mutex.RUnlock()
+
+	if len(names) == 0 {
+		for name := range components {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	state := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		component, exists := components[name]
+		if !exists {
+			utils.RespondWithError(w, http.StatusNotFound, "Unknown component: "+name)
+			return
+		}
+		state[name] = component()
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, state)
+}
+
+// runtimeStats reads the runtime stats of the service. Reading memory stats stops
+// the world briefly.
+func (h *Handler) runtimeStats() RuntimeStats {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	stats := RuntimeStats{
+		GoVersion:      runtime.Version(),
+		Uptime:         time.Since(h.started).Round(time.Second).String(),
+		CPUs:           runtime.NumCPU(),
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAlloc:      memStats.HeapAlloc,
+		HeapInuse:      memStats.HeapInuse,
+		HeapObjects:    memStats.HeapObjects,
+		Sys:            memStats.Sys,
+		NumGC:          memStats.NumGC,
+		TotalGCPause:   time.Duration(memStats.PauseTotalNs),
+		GCCPUFraction:  memStats.GCCPUFraction,
+		NextGCHeapSize: memStats.NextGC,
+	}
+	if memStats.NumGC > 0 {
+		stats.LastGC = time.Unix(0, int64(memStats.LastGC))
+		stats.LastGCPause = time.Duration(memStats.PauseNs[(memStats.NumGC+255)%256])
+	}
+	return stats
+}
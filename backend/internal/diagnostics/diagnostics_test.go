@@ -0,0 +1,90 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+func TestRoutesRequireAdmin(t *testing.T) {
+	router := mux.NewRouter()
+	NewHandler().RegisterRoutes(router)
+
+	adminToken, err := auth.GenerateToken("admin1", "admin", string(models.UserRoleAdmin), string(models.UserTypeAdmin), string(models.EnvironmentLive))
+	require.NoError(t, err)
+	traderToken, err := auth.GenerateToken("user1", "trader", string(models.UserRoleTrader), string(models.UserTypeStandard), string(models.EnvironmentLive))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		path         string
+		token        string
+		expectedCode int
+	}{
+		{"no token", "/debug/runtime", "", http.StatusUnauthorized},
+		{"trader", "/debug/runtime", traderToken, http.StatusForbidden},
+		{"trader profiling", "/debug/pprof/heap", traderToken, http.StatusForbidden},
+		{"admin", "/debug/runtime", adminToken, http.StatusOK},
+		{"admin state", "/debug/state", adminToken, http.StatusOK},
+		{"admin profile index", "/debug/pprof/", adminToken, http.StatusOK},
+		{"admin goroutine dump", "/debug/pprof/goroutine?debug=2", adminToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedCode, rr.Code)
+		})
+	}
+}
+
+func TestGetState(t *testing.T) {
+	handler := NewHandler()
+	handler.Register("websocket", func() interface{} {
+		return map[string]int{"connections": 12, "queuedFrames": 40}
+	})
+	handler.Register("analytics", func() interface{} {
+		return map[string]int{"queuedTasks": 3}
+	})
+
+	// Every component is reported by default
+	rr := httptest.NewRecorder()
+	handler.GetState(rr, httptest.NewRequest("GET", "/debug/state", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"analytics":{"queuedTasks":3},"websocket":{"connections":12,"queuedFrames":40}}`, rr.Body.String())
+
+	// Or only those asked for
+	rr = httptest.NewRecorder()
+	handler.GetState(rr, httptest.NewRequest("GET", "/debug/state?component=websocket", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"websocket":{"connections":12,"queuedFrames":40}}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	handler.GetState(rr, httptest.NewRequest("GET", "/debug/state?component=redis", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetRuntime(t *testing.T) {
+	rr := httptest.NewRecorder()
+	NewHandler().GetRuntime(rr, httptest.NewRequest("GET", "/debug/runtime", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var stats RuntimeStats
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	assert.Greater(t, stats.Goroutines, 0)
+	assert.Greater(t, stats.GOMAXPROCS, 0)
+	assert.NotZero(t, stats.Sys)
+	assert.NotEmpty(t, stats.GoVersion)
+}
@@ -0,0 +1,290 @@
+// Package errorreporting reports panics and unexpected errors to Sentry, with
+// their stack traces, the user and request they happened for and the release of
+// the service. Errors are sampled, and repeats of an error and bursts beyond a
+// budget are dropped, so that a market burst does not flood the project with
+// the same error. Until Init is called, nothing is reported.
+package errorreporting
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gorilla/mux"
+	"github.com/trading-platform/backend/internal/auth"
+	"github.com/trading-platform/backend/pkg/utils"
+)
+
+// maxRememberedErrors is the number of errors remembered to drop their repeats,
+// beyond which errors reported before the repeat window are forgotten
+const maxRememberedErrors = 1000
+
+// Config configures where errors are reported and how many
+type Config struct {
+	DSN                string
+	Environment        string
+	Release            string        // Version of the service, tagged on every event
+	SampleRate         float64       // Of the errors reported, zero reporting every error as Sentry does
+	MaxEventsPerMinute int           // Beyond which errors are dropped, zero not limiting them
+	RepeatWindow       time.Duration // Within which an error already reported is not reported again
+}
+
+// Init reports errors to Sentry, returning a function flushing the events left
+// within a timeout
+func Init(config Config) (func(time.Duration) bool, error) {
+	limiter := newLimiter(config.MaxEventsPerMinute, config.RepeatWindow)
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:              config.DSN,
+		Environment:      config.Environment,
+		Release:          config.Release,
+		SampleRate:       config.SampleRate,
+		AttachStacktrace: true,
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			if !limiter.allow(eventKey(event), time.Now()) {
+				return nil
+			}
+			return event
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize error reporting: %w", err)
+	}
+	return sentry.Flush, nil
+}
+
+// CaptureError reports an unexpected error of a component, with the user of ctx
+// and the request it is handling if any
+func CaptureError(ctx context.Context, component string, err error) {
+	if err == nil {
+		return
+	}
+
+	hub := hubFromContext(ctx)
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("component", component)
+		setUser(ctx, scope)
+		hub.CaptureException(err)
+	})
+}
+
+// Recover recovers from a panic of the goroutine it is deferred in, reporting it
+// with its stack trace. When err is not nil, it is set to an error describing the
+// panic, for workers to fail the task they were running and go on with the next.
+func Recover(ctx context.Context, component string, err *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	hub := hubFromContext(ctx)
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("component", component)
+		scope.SetLevel(sentry.LevelFatal)
+		setUser(ctx, scope)
+		hub.RecoverWithContext(ctx, recovered)
+	})
+	log.Printf("Recovered from panic in %s: %v\n%s", component, recovered, debug.Stack())
+
+	if err != nil {
+		*err = fmt.Errorf("panic in %s: %v", component, recovered)
+	}
+}
+
+// Middleware reports the panics of the handlers of a router and their responses
+// with a server error status, with the request they were handling. Panics are
+// recovered from, responding with an internal server error.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub := sentry.CurrentHub().Clone()
+		hub.Scope().SetRequest(r)
+		ctx := sentry.SetHubOnContext(r.Context(), hub)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// Aborting handlers panic on purpose
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			hub.WithScope(func(scope *sentry.Scope) {
+				scope.SetTag("component", "http")
+				scope.SetLevel(sentry.LevelFatal)
+				hub.RecoverWithContext(ctx, recovered)
+			})
+			log.Printf("Recovered from panic handling %s %s: %v\n%s", r.Method, r.URL.Path, recovered, debug.Stack())
+
+			if !recorder.wroteHeader && !recorder.hijacked {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+			}
+		}()
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		// Handlers respond with server errors to errors they did not expect
+		if recorder.status >= http.StatusInternalServerError && !recorder.hijacked {
+			hub.WithScope(func(scope *sentry.Scope) {
+				scope.SetTag("component", "http")
+				scope.SetTag("status", fmt.Sprint(recorder.status))
+				hub.CaptureMessage(fmt.Sprintf("%s %s responded %d", r.Method, route(r), recorder.status))
+			})
+		}
+	})
+}
+
+// UserMiddleware tags the errors reported for a request with the user making it.
+// Use it after the authentication middleware.
+func UserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hub := sentry.GetHubFromContext(r.Context()); hub != nil {
+			setUser(r.Context(), hub.Scope())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hubFromContext returns the hub of the request of ctx, or a hub of its own
+func hubFromContext(ctx context.Context) *sentry.Hub {
+	if hub := sentry.GetHubFromContext(ctx); hub != nil {
+		return hub
+	}
+	return sentry.CurrentHub().Clone()
+}
+
+// setUser sets the authenticated user of ctx, if any, on a scope
+func setUser(ctx context.Context, scope *sentry.Scope) {
+	userID := auth.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return
+	}
+	scope.SetUser(sentry.User{ID: userID})
+	if role := auth.GetRoleFromContext(ctx); role != "" {
+		scope.SetTag("role", role)
+	}
+}
+
+// route returns the route template of a request, labelling its errors without the
+// IDs of its path
+func route(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// eventKey identifies the error of an event, to drop its repeats
+func eventKey(event *sentry.Event) string {
+	if n := len(event.Exception); n > 0 {
+		return event.Exception[n-1].Type + ": " + event.Exception[n-1].Value
+	}
+	return event.Message
+}
+
+// limiter drops the repeats of errors reported within a window, and the errors
+// beyond a budget of errors a minute
+type limiter struct {
+	maxPerMinute int
+	repeatWindow time.Duration
+	minuteStart  time.Time
+	count        int                  // Errors reported since minuteStart
+	reported     map[string]time.Time // When each error was last reported
+	mutex        sync.Mutex
+}
+
+// newLimiter creates a new limiter, zero not limiting errors
+func newLimiter(maxPerMinute int, repeatWindow time.Duration) *limiter {
+	return &limiter{
+		maxPerMinute: maxPerMinute,
+		repeatWindow: repeatWindow,
+		reported:     make(map[string]time.Time),
+	}
+}
+
+// allow reports whether an error may be reported at now, counting it if so
+func (l *limiter) allow(key string, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.repeatWindow > 0 {
+		if last, exists := l.reported[key]; exists && now.Sub(last) < l.repeatWindow {
+			return false
+		}
+	}
+
+	if l.maxPerMinute > 0 {
+		if now.Sub(l.minuteStart) >= time.Minute {
+			l.minuteStart = now
+			l.count = 0
+		}
+		if l.count >= l.maxPerMinute {
+			return false
+		}
+		l.count++
+	}
+
+	if l.repeatWindow > 0 {
+		if len(l.reported) >= maxRememberedErrors {
+			for k, last := range l.reported {
+				if now.Sub(last) >= l.repeatWindow {
+					delete(l.reported, k)
+				}
+			}
+		}
+		l.reported[key] = now
+	}
+
+	return true
+}
+
+// statusRecorder records the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	hijacked    bool
+}
+
+// WriteHeader records the status code before writing it
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the body of the response, with a 200 OK status unless written
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(data)
+}
+
+// Hijack lets handlers take over the connection, as WebSocket upgrades do
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	r.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Flush flushes buffered data to the client, for streamed responses
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,105 @@
+package errorreporting
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 15, 0, 0, time.UTC)
+
+	// Repeats of an error are dropped within the window
+	l := newLimiter(0, time.Minute)
+	assert.True(t, l.allow("order rejected", start))
+	assert.False(t, l.allow("order rejected", start.Add(30*time.Second)))
+	assert.True(t, l.allow("broker timeout", start.Add(30*time.Second)))
+	assert.True(t, l.allow("order rejected", start.Add(time.Minute)))
+
+	// Errors beyond the budget are dropped until the next minute
+	l = newLimiter(2, 0)
+	assert.True(t, l.allow("error1", start))
+	assert.True(t, l.allow("error2", start.Add(time.Second)))
+	assert.False(t, l.allow("error3", start.Add(2*time.Second)))
+	assert.True(t, l.allow("error3", start.Add(time.Minute)))
+
+	// Dropped repeats do not use the budget
+	l = newLimiter(2, time.Minute)
+	assert.True(t, l.allow("error1", start))
+	assert.False(t, l.allow("error1", start))
+	assert.True(t, l.allow("error2", start))
+	assert.False(t, l.allow("error3", start))
+
+	// Without limits every error is reported
+	l = newLimiter(0, 0)
+	for i := 0; i < 10; i++ {
+		assert.True(t, l.allow("error1", start))
+	}
+}
+
+func TestEventKey(t *testing.T) {
+	event := &sentry.Event{Message: "GET /api/orders/{id} responded 500"}
+	assert.Equal(t, "GET /api/orders/{id} responded 500", eventKey(event))
+
+	event.Exception = []sentry.Exception{{Type: "*errors.errorString", Value: "connection refused"}}
+	assert.Equal(t, "*errors.errorString: connection refused", eventKey(event))
+}
+
+func TestMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.HandleFunc("/api/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// Handlers get the hub of the request
+		assert.NotNil(t, sentry.GetHubFromContext(r.Context()))
+		panic("nil order")
+	})
+	router.HandleFunc("/api/positions", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+	})
+
+	// Panics are recovered from with an internal server error
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/orders/order1", nil))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.JSONEq(t, `{"error":"Internal server error"}`, rr.Body.String())
+
+	// Server errors are passed on as they are
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/positions", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	// Aborted handlers still abort
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+}
+
+func TestRecover(t *testing.T) {
+	task := func() (err error) {
+		defer Recover(context.Background(), "analytics", &err)
+		var positions map[string][]float64
+		positions["portfolio1"] = append(positions["portfolio1"], 1)
+		return errors.New("unreachable")
+	}
+
+	err := task()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "panic in analytics")
+	}
+
+	// Workers not failing a task only recover
+	assert.NotPanics(t, func() {
+		defer Recover(context.Background(), "worker", nil)
+		panic("job failed")
+	})
+}
@@ -3,12 +3,15 @@ package gateway
 import (
 	"context"
 	"errors"
+	"strings"
 	"sync"
 	"time"
 	
 	"trading_platform/backend/internal/interfaces"
+	"trading_platform/backend/internal/metrics"
 	"trading_platform/backend/internal/models"
 	"trading_platform/backend/internal/services/simulation"
+	"trading_platform/backend/internal/tracing"
 )
 
 // APIGateway implements the interfaces.ExecutionSimulationInterface and serves as the
@@ -21,6 +24,7 @@ type APIGateway struct {
 	simulationOrderService *simulation.SimulationOrderService
 	marketSimulationService *simulation.MarketSimulationService
 	backtestService      *simulation.BacktestService
+	snapshotService      *simulation.SimulationSnapshotService
 	
 	// Execution platform interface
 	executionPlatform    interfaces.ExecutionPlatformInterface
@@ -57,6 +61,7 @@ func NewAPIGateway(executionPlatform interfaces.ExecutionPlatformInterface) *API
 		simulationOrderService: simulation.NewSimulationOrderService(),
 		marketSimulationService: simulation.NewMarketSimulationService(),
 		backtestService:       simulation.NewBacktestService(),
+		snapshotService:       simulation.NewSimulationSnapshotService(),
 		executionPlatform:     executionPlatform,
 		accessControlList:     make(map[string][]string),
 		rateLimits:            initializeRateLimits(),
@@ -187,6 +192,14 @@ func (g *APIGateway) checkPermission(ctx context.Context, permission string) err
 		return errors.New("user type not found in context")
 	}
 	
+	return g.Authorize(userID, userType, permission)
+}
+
+// Authorize verifies if a user of a user type has a permission, for callers that
+// identify users other than through the request context, such as WebSocket
+// connections. A granted permission ending in "*" covers every permission it
+// prefixes.
+func (g *APIGateway) Authorize(userID, userType, permission string) error {
 	// Admin users have all permissions
 	if userType == "ADMIN" {
 		return nil
@@ -204,7 +217,7 @@ func (g *APIGateway) checkPermission(ctx context.Context, permission string) err
 	}
 	
 	for _, p := range permissions {
-		if p == permission || p == "*" {
+		if p == permission || (strings.HasSuffix(p, "*") && strings.HasPrefix(permission, strings.TrimSuffix(p, "*"))) {
 			return nil
 		}
 	}
@@ -229,8 +242,23 @@ func isSimulationPermission(permission string) bool {
 	return false
 }
 
+// begin starts a request to a method of the gateway, returning the context of
+// its span and a function to call once the request is done, ending the span and
+// recording the request
+func (g *APIGateway) begin(ctx context.Context, method string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "gateway", method)
+	return ctx, func() {
+		span.End()
+		metrics.ObserveGatewayRequest(method, start)
+	}
+}
+
 // handleError processes errors through the appropriate handler
 func (g *APIGateway) handleError(ctx context.Context, category string, err error) error {
+	metrics.GatewayError(category)
+	tracing.RecordError(ctx, err)
+	
 	handler, exists := g.errorHandlers[category]
 	if !exists {
 		// Use system error handler as fallback
@@ -277,6 +305,10 @@ func (g *APIGateway) synchronizeData(ctx context.Context, resource string) error
 
 // CreateSimulationAccount implements the ExecutionSimulationInterface
 func (g *APIGateway) CreateSimulationAccount(ctx context.Context, userID string, account models.SimulationAccount) (*models.SimulationAccount, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "CreateSimulationAccount")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:account:create"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -298,6 +330,10 @@ func (g *APIGateway) CreateSimulationAccount(ctx context.Context, userID string,
 
 // GetSimulationAccount implements the ExecutionSimulationInterface
 func (g *APIGateway) GetSimulationAccount(ctx context.Context, accountID string) (*models.SimulationAccount, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetSimulationAccount")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:account:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -319,6 +355,10 @@ func (g *APIGateway) GetSimulationAccount(ctx context.Context, accountID string)
 
 // GetSimulationAccountsByUser implements the ExecutionSimulationInterface
 func (g *APIGateway) GetSimulationAccountsByUser(ctx context.Context, userID string) ([]*models.SimulationAccount, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetSimulationAccountsByUser")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:account:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -340,6 +380,10 @@ func (g *APIGateway) GetSimulationAccountsByUser(ctx context.Context, userID str
 
 // UpdateSimulationAccount implements the ExecutionSimulationInterface
 func (g *APIGateway) UpdateSimulationAccount(ctx context.Context, accountID string, updates map[string]interface{}) (*models.SimulationAccount, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "UpdateSimulationAccount")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:account:update"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -361,6 +405,10 @@ func (g *APIGateway) UpdateSimulationAccount(ctx context.Context, accountID stri
 
 // DeleteSimulationAccount implements the ExecutionSimulationInterface
 func (g *APIGateway) DeleteSimulationAccount(ctx context.Context, accountID string) error {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "DeleteSimulationAccount")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:account:delete"); err != nil {
 		return g.handleError(ctx, "authorization", err)
@@ -382,6 +430,10 @@ func (g *APIGateway) DeleteSimulationAccount(ctx context.Context, accountID stri
 
 // AddFunds implements the ExecutionSimulationInterface
 func (g *APIGateway) AddFunds(ctx context.Context, accountID string, amount float64, description string) (*models.SimulationTransaction, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "AddFunds")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:balance:add"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -403,6 +455,10 @@ func (g *APIGateway) AddFunds(ctx context.Context, accountID string, amount floa
 
 // WithdrawFunds implements the ExecutionSimulationInterface
 func (g *APIGateway) WithdrawFunds(ctx context.Context, accountID string, amount float64, description string) (*models.SimulationTransaction, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "WithdrawFunds")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:balance:withdraw"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -424,6 +480,10 @@ func (g *APIGateway) WithdrawFunds(ctx context.Context, accountID string, amount
 
 // GetAccountBalance implements the ExecutionSimulationInterface
 func (g *APIGateway) GetAccountBalance(ctx context.Context, accountID string) (float64, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetAccountBalance")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:balance:read"); err != nil {
 		return 0, g.handleError(ctx, "authorization", err)
@@ -445,6 +505,10 @@ func (g *APIGateway) GetAccountBalance(ctx context.Context, accountID string) (f
 
 // GetAccountEquity implements the ExecutionSimulationInterface
 func (g *APIGateway) GetAccountEquity(ctx context.Context, accountID string) (float64, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetAccountEquity")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:balance:read"); err != nil {
 		return 0, g.handleError(ctx, "authorization", err)
@@ -466,6 +530,10 @@ func (g *APIGateway) GetAccountEquity(ctx context.Context, accountID string) (fl
 
 // GetTransactions implements the ExecutionSimulationInterface
 func (g *APIGateway) GetTransactions(ctx context.Context, accountID string, startDate, endDate time.Time) ([]*models.SimulationTransaction, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetTransactions")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:transaction:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -487,6 +555,10 @@ func (g *APIGateway) GetTransactions(ctx context.Context, accountID string, star
 
 // CreateOrder implements the ExecutionSimulationInterface
 func (g *APIGateway) CreateOrder(ctx context.Context, accountID string, order models.SimulationOrder) (*models.SimulationOrder, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "CreateOrder")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:order:create"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -513,6 +585,10 @@ func (g *APIGateway) CreateOrder(ctx context.Context, accountID string, order mo
 
 // GetOrder implements the ExecutionSimulationInterface
 func (g *APIGateway) GetOrder(ctx context.Context, orderID string) (*models.SimulationOrder, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetOrder")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:order:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -534,6 +610,10 @@ func (g *APIGateway) GetOrder(ctx context.Context, orderID string) (*models.Simu
 
 // GetOrdersByAccount implements the ExecutionSimulationInterface
 func (g *APIGateway) GetOrdersByAccount(ctx context.Context, accountID string) ([]*models.SimulationOrder, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetOrdersByAccount")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:order:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -555,6 +635,10 @@ func (g *APIGateway) GetOrdersByAccount(ctx context.Context, accountID string) (
 
 // CancelOrder implements the ExecutionSimulationInterface
 func (g *APIGateway) CancelOrder(ctx context.Context, orderID string) (*models.SimulationOrder, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "CancelOrder")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:order:cancel"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -576,6 +660,10 @@ func (g *APIGateway) CancelOrder(ctx context.Context, orderID string) (*models.S
 
 // ModifyOrder implements the ExecutionSimulationInterface
 func (g *APIGateway) ModifyOrder(ctx context.Context, orderID string, updates models.SimulationOrder) (*models.SimulationOrder, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "ModifyOrder")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:order:update"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -602,6 +690,10 @@ func (g *APIGateway) ModifyOrder(ctx context.Context, orderID string, updates mo
 
 // GetOrderHistory implements the ExecutionSimulationInterface
 func (g *APIGateway) GetOrderHistory(ctx context.Context, accountID string, startDate, endDate time.Time, symbol string) ([]*models.SimulationOrder, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetOrderHistory")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:order:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -623,6 +715,10 @@ func (g *APIGateway) GetOrderHistory(ctx context.Context, accountID string, star
 
 // GetPositions implements the ExecutionSimulationInterface
 func (g *APIGateway) GetPositions(ctx context.Context, accountID string) ([]*models.SimulationPosition, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetPositions")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:position:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -644,6 +740,10 @@ func (g *APIGateway) GetPositions(ctx context.Context, accountID string) ([]*mod
 
 // GetPosition implements the ExecutionSimulationInterface
 func (g *APIGateway) GetPosition(ctx context.Context, positionID string) (*models.SimulationPosition, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetPosition")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:position:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -665,6 +765,10 @@ func (g *APIGateway) GetPosition(ctx context.Context, positionID string) (*model
 
 // ClosePosition implements the ExecutionSimulationInterface
 func (g *APIGateway) ClosePosition(ctx context.Context, positionID string, price float64) (*models.SimulationPosition, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "ClosePosition")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:position:close"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -691,6 +795,10 @@ func (g *APIGateway) ClosePosition(ctx context.Context, positionID string, price
 
 // GetPositionHistory implements the ExecutionSimulationInterface
 func (g *APIGateway) GetPositionHistory(ctx context.Context, accountID string, startDate, endDate time.Time) ([]*models.SimulationPosition, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetPositionHistory")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:position:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -712,6 +820,10 @@ func (g *APIGateway) GetPositionHistory(ctx context.Context, accountID string, s
 
 // GetCurrentMarketPrice implements the ExecutionSimulationInterface
 func (g *APIGateway) GetCurrentMarketPrice(ctx context.Context, symbol string) (*models.MarketDataSnapshot, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetCurrentMarketPrice")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:market:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -738,6 +850,10 @@ func (g *APIGateway) GetCurrentMarketPrice(ctx context.Context, symbol string) (
 
 // GetHistoricalMarketData implements the ExecutionSimulationInterface
 func (g *APIGateway) GetHistoricalMarketData(ctx context.Context, symbol string, startDate, endDate time.Time, timeframe string) ([]*models.MarketDataSnapshot, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetHistoricalMarketData")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:market:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -759,6 +875,10 @@ func (g *APIGateway) GetHistoricalMarketData(ctx context.Context, symbol string,
 
 // GetMarketDepth implements the ExecutionSimulationInterface
 func (g *APIGateway) GetMarketDepth(ctx context.Context, symbol string, levels int) (map[string]interface{}, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetMarketDepth")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:market:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -785,6 +905,10 @@ func (g *APIGateway) GetMarketDepth(ctx context.Context, symbol string, levels i
 
 // CreateBacktestSession implements the ExecutionSimulationInterface
 func (g *APIGateway) CreateBacktestSession(ctx context.Context, accountID string, session models.BacktestSession) (*models.BacktestSession, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "CreateBacktestSession")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "backtest:session:create"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -806,6 +930,10 @@ func (g *APIGateway) CreateBacktestSession(ctx context.Context, accountID string
 
 // GetBacktestSession implements the ExecutionSimulationInterface
 func (g *APIGateway) GetBacktestSession(ctx context.Context, sessionID string) (*models.BacktestSession, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetBacktestSession")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "backtest:session:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -827,6 +955,10 @@ func (g *APIGateway) GetBacktestSession(ctx context.Context, sessionID string) (
 
 // GetBacktestSessionsByAccount implements the ExecutionSimulationInterface
 func (g *APIGateway) GetBacktestSessionsByAccount(ctx context.Context, accountID string) ([]*models.BacktestSession, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetBacktestSessionsByAccount")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "backtest:session:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -848,6 +980,10 @@ func (g *APIGateway) GetBacktestSessionsByAccount(ctx context.Context, accountID
 
 // RunBacktest implements the ExecutionSimulationInterface
 func (g *APIGateway) RunBacktest(ctx context.Context, sessionID string) error {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "RunBacktest")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "backtest:session:run"); err != nil {
 		return g.handleError(ctx, "authorization", err)
@@ -869,6 +1005,10 @@ func (g *APIGateway) RunBacktest(ctx context.Context, sessionID string) error {
 
 // StopBacktest implements the ExecutionSimulationInterface
 func (g *APIGateway) StopBacktest(ctx context.Context, sessionID string) error {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "StopBacktest")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "backtest:session:stop"); err != nil {
 		return g.handleError(ctx, "authorization", err)
@@ -890,6 +1030,10 @@ func (g *APIGateway) StopBacktest(ctx context.Context, sessionID string) error {
 
 // GetBacktestResults implements the ExecutionSimulationInterface
 func (g *APIGateway) GetBacktestResults(ctx context.Context, sessionID string) ([]*models.BacktestResult, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetBacktestResults")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "backtest:result:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -911,6 +1055,10 @@ func (g *APIGateway) GetBacktestResults(ctx context.Context, sessionID string) (
 
 // GetBacktestPerformanceMetrics implements the ExecutionSimulationInterface
 func (g *APIGateway) GetBacktestPerformanceMetrics(ctx context.Context, sessionID string) (map[string]interface{}, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetBacktestPerformanceMetrics")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "backtest:result:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -932,6 +1080,10 @@ func (g *APIGateway) GetBacktestPerformanceMetrics(ctx context.Context, sessionI
 
 // GetSystemStatus implements the ExecutionSimulationInterface
 func (g *APIGateway) GetSystemStatus(ctx context.Context) (map[string]interface{}, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetSystemStatus")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "system:status:read"); err != nil {
 		return nil, g.handleError(ctx, "authorization", err)
@@ -976,6 +1128,10 @@ func (g *APIGateway) GetSystemStatus(ctx context.Context) (map[string]interface{
 
 // SynchronizeMarketData implements the ExecutionSimulationInterface
 func (g *APIGateway) SynchronizeMarketData(ctx context.Context, symbols []string) error {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "SynchronizeMarketData")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "system:sync:execute"); err != nil {
 		return g.handleError(ctx, "authorization", err)
@@ -1006,6 +1162,10 @@ func (g *APIGateway) SynchronizeMarketData(ctx context.Context, symbols []string
 
 // ResetSimulationEnvironment implements the ExecutionSimulationInterface
 func (g *APIGateway) ResetSimulationEnvironment(ctx context.Context, accountID string) error {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "ResetSimulationEnvironment")
+	defer end()
+	
 	// Check permissions
 	if err := g.checkPermission(ctx, "simulation:account:reset"); err != nil {
 		return g.handleError(ctx, "authorization", err)
@@ -1024,3 +1184,83 @@ func (g *APIGateway) ResetSimulationEnvironment(ctx context.Context, accountID s
 	
 	return nil
 }
+
+// CreateSimulationSnapshot implements the ExecutionSimulationInterface
+func (g *APIGateway) CreateSimulationSnapshot(ctx context.Context, accountID string, name string, description string) (*models.SimulationSnapshot, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "CreateSimulationSnapshot")
+	defer end()
+	
+	// Check permissions
+	if err := g.checkPermission(ctx, "simulation:snapshot:create"); err != nil {
+		return nil, g.handleError(ctx, "authorization", err)
+	}
+	
+	// Check rate limits
+	if err := g.checkRateLimit(ctx, "account_management"); err != nil {
+		return nil, g.handleError(ctx, "rate_limit", err)
+	}
+	
+	// Create the snapshot
+	result, err := g.snapshotService.CreateSnapshot(accountID, name, description)
+	if err != nil {
+		return nil, g.handleError(ctx, "validation", err)
+	}
+	
+	return result, nil
+}
+
+// GetSimulationSnapshots implements the ExecutionSimulationInterface
+func (g *APIGateway) GetSimulationSnapshots(ctx context.Context, accountID string) ([]*models.SimulationSnapshot, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "GetSimulationSnapshots")
+	defer end()
+	
+	// Check permissions
+	if err := g.checkPermission(ctx, "simulation:snapshot:read"); err != nil {
+		return nil, g.handleError(ctx, "authorization", err)
+	}
+	
+	// Check rate limits
+	if err := g.checkRateLimit(ctx, "account_management"); err != nil {
+		return nil, g.handleError(ctx, "rate_limit", err)
+	}
+	
+	// Get the snapshots
+	snapshots, err := g.snapshotService.GetSnapshots(accountID)
+	if err != nil {
+		return nil, g.handleError(ctx, "validation", err)
+	}
+	
+	result := make([]*models.SimulationSnapshot, len(snapshots))
+	for i := range snapshots {
+		result[i] = &snapshots[i]
+	}
+	
+	return result, nil
+}
+
+// RestoreSimulationSnapshot implements the ExecutionSimulationInterface
+func (g *APIGateway) RestoreSimulationSnapshot(ctx context.Context, accountID string, snapshotID string) (*models.SimulationAccount, error) {
+	// Trace and record the request
+	ctx, end := g.begin(ctx, "RestoreSimulationSnapshot")
+	defer end()
+	
+	// Check permissions
+	if err := g.checkPermission(ctx, "simulation:snapshot:restore"); err != nil {
+		return nil, g.handleError(ctx, "authorization", err)
+	}
+	
+	// Check rate limits
+	if err := g.checkRateLimit(ctx, "account_management"); err != nil {
+		return nil, g.handleError(ctx, "rate_limit", err)
+	}
+	
+	// Restore the snapshot
+	result, err := g.snapshotService.RestoreSnapshot(accountID, snapshotID)
+	if err != nil {
+		return nil, g.handleError(ctx, "validation", err)
+	}
+	
+	return result, nil
+}
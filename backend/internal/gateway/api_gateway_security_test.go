@@ -383,6 +383,26 @@ func TestPermissionChecking(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "user type not found in context")
 	})
+
+	t.Run("Authorize Without Context", func(t *testing.T) {
+		// Add stream permissions, as WebSocket subscriptions check them
+		gateway.accessControlList["stream_user"] = []string{
+			"stream:ticks:subscribe",
+			"stream:orders:*",
+		}
+
+		// Check permissions of a user identified outside any request context
+		err1 := gateway.Authorize("stream_user", "STANDARD", "stream:ticks:subscribe")
+		err2 := gateway.Authorize("stream_user", "STANDARD", "stream:orders:subscribe")
+		err3 := gateway.Authorize("stream_user", "STANDARD", "stream:positions:subscribe")
+		err4 := gateway.Authorize("unknown_user", "ADMIN", "stream:positions:subscribe")
+
+		// Assert that permissions are checked as for requests
+		assert.NoError(t, err1)
+		assert.NoError(t, err2)
+		assert.Error(t, err3)
+		assert.NoError(t, err4)
+	})
 }
 
 // TestSimulationPermissionCheck tests the isSimulationPermission function
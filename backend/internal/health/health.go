@@ -0,0 +1,252 @@
+// Package health reports whether the service and the dependencies it relies on
+// are working, for orchestrators' liveness and readiness probes and for the status
+// page.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the state of a dependency or of the service as a whole
+type Status string
+
+const (
+	StatusUp       Status = "UP"
+	StatusDegraded Status = "DEGRADED" // Working, but not fully
+	StatusDown     Status = "DOWN"
+)
+
+// DefaultTimeout is how long a dependency check may take before it is reported
+// down
+const DefaultTimeout = 2 * time.Second
+
+// CheckFunc checks a dependency, returning nil when it is up. Errors wrapped with
+// Degraded report it degraded rather than down.
+type CheckFunc func(ctx context.Context) error
+
+// degradedError marks the error of a check as the dependency being degraded
+type degradedError struct {
+	err error
+}
+
+func (e *degradedError) Error() string { return e.err.Error() }
+func (e *degradedError) Unwrap() error { return e.err }
+
+// Degraded wraps the error of a check to report the dependency degraded
+func Degraded(err error) error {
+	return &degradedError{err: err}
+}
+
+// DependencyStatus is the result of checking a dependency
+type DependencyStatus struct {
+	Status    Status `json:"status"`
+	Critical  bool   `json:"critical"` // The service is not ready while it is down
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+// Report is the result of checking every dependency of the service. The service
+// is down when a critical dependency is, and degraded when any other dependency
+// is not up.
+type Report struct {
+	Status       Status                      `json:"status"`
+	CheckedAt    time.Time                   `json:"checkedAt"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// check is a dependency registered with a checker
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// Checker checks the dependencies of the service, all at once
+type Checker struct {
+	checks  []check
+	timeout time.Duration
+	mutex   sync.RWMutex
+}
+
+// NewChecker creates a new Checker, checks taking longer than timeout being
+// reported down. DefaultTimeout is used when timeout is not positive.
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a dependency to check. The service is not ready while a critical
+// dependency is down, other dependencies only degrading it.
+func (c *Checker) Register(name string, critical bool, fn CheckFunc) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.checks = append(c.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// Check checks every dependency concurrently
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mutex.RLock()
+	checks := make([]check, len(c.checks))
+	copy(checks, c.checks)
+	c.mutex.RUnlock()
+
+	report := Report{
+		Status:       StatusUp,
+		CheckedAt:    time.Now(),
+		Dependencies: make(map[string]DependencyStatus, len(checks)),
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+	for _, chk := range checks {
+		wg.Add(1)
+		go func(chk check) {
+			defer wg.Done()
+			status := c.run(ctx, chk)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Dependencies[chk.name] = status
+		}(chk)
+	}
+	wg.Wait()
+
+	for _, status := range report.Dependencies {
+		switch {
+		case status.Status == StatusDown && status.Critical:
+			report.Status = StatusDown
+		case status.Status != StatusUp && report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+// run runs a check within the checker's timeout. Checks not returning in time are
+// left running, their result being ignored.
+func (c *Checker) run(ctx context.Context, chk check) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := make(chan error, 1)
+	go func() {
+		result <- chk.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %v", c.timeout)
+	}
+
+	status := DependencyStatus{
+		Status:    StatusUp,
+		Critical:  chk.critical,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		var degraded *degradedError
+		status.Status = StatusDown
+		if errors.As(err, &degraded) {
+			status.Status = StatusDegraded
+		}
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Liveness handles /healthz, reporting every dependency but always responding
+// 200 OK while the service can serve requests: restarting the service would not
+// bring its dependencies back.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, c.Check(r.Context()))
+}
+
+// Readiness handles /readyz, responding 503 Service Unavailable while a critical
+// dependency is down for orchestrators to stop routing requests to the service
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	code := http.StatusOK
+	if report.Status == StatusDown {
+		code = http.StatusServiceUnavailable
+	}
+	respond(w, code, report)
+}
+
+// respond writes a report as JSON, never to be cached
+func respond(w http.ResponseWriter, code int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+// Pinger is a database connection that can be pinged, as *sql.DB is
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Database checks a database is reachable
+func Database(db Pinger) CheckFunc {
+	return db.PingContext
+}
+
+// Freshness checks data keeps coming, lastUpdate returning when it last came: the
+// check fails when nothing came for maxAge, or ever
+func Freshness(lastUpdate func() time.Time, maxAge time.Duration) CheckFunc {
+	return func(ctx context.Context) error {
+		last := lastUpdate()
+		if last.IsZero() {
+			return errors.New("no update received")
+		}
+		if age := time.Since(last); age > maxAge {
+			return fmt.Errorf("last update %v ago, older than %v", age.Round(time.Second), maxAge)
+		}
+		return nil
+	}
+}
+
+// Sessions checks sessions are valid, expiries returning when each session
+// expires by name: the check fails when there is no valid session, and reports
+// degraded when only some expired
+func Sessions(expiries func() map[string]time.Time) CheckFunc {
+	return func(ctx context.Context) error {
+		sessions := expiries()
+		if len(sessions) == 0 {
+			return errors.New("no session")
+		}
+
+		now := time.Now()
+		var expired []string
+		for name, expiresAt := range sessions {
+			if !now.Before(expiresAt) {
+				expired = append(expired, name)
+			}
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+
+		sort.Strings(expired)
+		err := fmt.Errorf("expired sessions: %s", strings.Join(expired, ", "))
+		if len(expired) < len(sessions) {
+			return Degraded(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,147 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker(t *testing.T) {
+	up := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	degraded := func(ctx context.Context) error { return Degraded(errors.New("slow")) }
+	hanging := func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		register func(c *Checker)
+		expected Status
+	}{
+		{"no dependencies", func(c *Checker) {}, StatusUp},
+		{"all up", func(c *Checker) {
+			c.Register("database", true, up)
+			c.Register("nats", false, up)
+		}, StatusUp},
+		{"non-critical down", func(c *Checker) {
+			c.Register("database", true, up)
+			c.Register("nats", false, down)
+		}, StatusDegraded},
+		{"critical degraded", func(c *Checker) {
+			c.Register("database", true, degraded)
+		}, StatusDegraded},
+		{"critical down", func(c *Checker) {
+			c.Register("database", true, down)
+			c.Register("nats", false, degraded)
+		}, StatusDown},
+		{"critical timed out", func(c *Checker) {
+			c.Register("database", true, hanging)
+		}, StatusDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(50 * time.Millisecond)
+			tt.register(checker)
+			report := checker.Check(context.Background())
+			assert.Equal(t, tt.expected, report.Status)
+		})
+	}
+
+	// Every dependency is reported in detail
+	checker := NewChecker(50 * time.Millisecond)
+	checker.Register("database", true, up)
+	checker.Register("nats", false, down)
+	checker.Register("redis", false, hanging)
+
+	report := checker.Check(context.Background())
+	assert.Equal(t, DependencyStatus{Status: StatusUp, Critical: true, LatencyMs: report.Dependencies["database"].LatencyMs}, report.Dependencies["database"])
+	assert.Equal(t, StatusDown, report.Dependencies["nats"].Status)
+	assert.False(t, report.Dependencies["nats"].Critical)
+	assert.Equal(t, "connection refused", report.Dependencies["nats"].Error)
+	assert.Equal(t, StatusDown, report.Dependencies["redis"].Status)
+	assert.Contains(t, report.Dependencies["redis"].Error, "timed out")
+}
+
+func TestHandlers(t *testing.T) {
+	databaseUp := true
+	checker := NewChecker(0)
+	checker.Register("database", true, func(ctx context.Context) error {
+		if databaseUp {
+			return nil
+		}
+		return errors.New("connection refused")
+	})
+	checker.Register("nats", false, func(ctx context.Context) error { return errors.New("not connected") })
+
+	tests := []struct {
+		name         string
+		databaseUp   bool
+		handler      http.HandlerFunc
+		expectedCode int
+	}{
+		{"liveness while ready", true, checker.Liveness, http.StatusOK},
+		{"readiness while ready", true, checker.Readiness, http.StatusOK},
+		{"liveness while not ready", false, checker.Liveness, http.StatusOK},
+		{"readiness while not ready", false, checker.Readiness, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			databaseUp = tt.databaseUp
+			rr := httptest.NewRecorder()
+			tt.handler(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			var report Report
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+			assert.Len(t, report.Dependencies, 2)
+			assert.Equal(t, StatusDown, report.Dependencies["nats"].Status)
+		})
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	var last time.Time
+	check := Freshness(func() time.Time { return last }, time.Minute)
+
+	assert.EqualError(t, check(context.Background()), "no update received")
+
+	last = time.Now().Add(-10 * time.Second)
+	assert.NoError(t, check(context.Background()))
+
+	last = time.Now().Add(-5 * time.Minute)
+	assert.Error(t, check(context.Background()))
+}
+
+func TestSessions(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		sessions map[string]time.Time
+		expected Status
+	}{
+		{"no session", map[string]time.Time{}, StatusDown},
+		{"all valid", map[string]time.Time{"client1": now.Add(time.Hour), "client2": now.Add(time.Hour)}, StatusUp},
+		{"some expired", map[string]time.Time{"client1": now.Add(time.Hour), "client2": now.Add(-time.Hour)}, StatusDegraded},
+		{"all expired", map[string]time.Time{"client1": now.Add(-time.Hour)}, StatusDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(0)
+			checker.Register("brokers", true, Sessions(func() map[string]time.Time { return tt.sessions }))
+			assert.Equal(t, tt.expected, checker.Check(context.Background()).Dependencies["brokers"].Status)
+		})
+	}
+}
@@ -0,0 +1,281 @@
+// Package indicators computes technical indicators (SMA, EMA, RSI, MACD, ATR,
+// Bollinger bands, Supertrend and VWAP) on candles. Indicators are updated
+// incrementally, one completed candle at a time, so that the rules of strategies,
+// alert rules and backtests evaluate them on every candle without recomputing
+// their history. The Store keeps the recent candles of instruments and the
+// indicators declared on them up to date as candles are added.
+package indicators
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidSpec is returned for indicator declarations that cannot be computed
+	ErrInvalidSpec = errors.New("invalid indicator")
+	// ErrOutOfOrder is returned for candles added before the last one of a series
+	ErrOutOfOrder = errors.New("candle is not after the last one")
+)
+
+// Candle is an instrument's prices over an interval, once it has completed
+type Candle struct {
+	Time   time.Time `json:"time"` // Start of the interval
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume"`
+}
+
+// Source is the price of candles an indicator is computed on
+type Source string
+
+const (
+	SourceOpen  Source = "open"
+	SourceHigh  Source = "high"
+	SourceLow   Source = "low"
+	SourceClose Source = "close"
+	SourceHL2   Source = "hl2"  // Average of the high and low
+	SourceHLC3  Source = "hlc3" // Typical price, average of the high, low and close
+)
+
+// price returns the candle's price of the source
+func (s Source) price(candle Candle) float64 {
+	switch s {
+	case SourceOpen:
+		return candle.Open
+	case SourceHigh:
+		return candle.High
+	case SourceLow:
+		return candle.Low
+	case SourceHL2:
+		return (candle.High + candle.Low) / 2
+	case SourceHLC3:
+		return (candle.High + candle.Low + candle.Close) / 3
+	}
+	return candle.Close
+}
+
+// Type is a kind of indicator
+type Type string
+
+const (
+	TypeSMA        Type = "SMA"        // Simple moving average of the source
+	TypeEMA        Type = "EMA"        // Exponential moving average of the source, seeded with its SMA
+	TypeRSI        Type = "RSI"        // Relative strength index of the source, with Wilder's smoothing
+	TypeMACD       Type = "MACD"       // Difference of the fast and slow EMAs, with its signal EMA and histogram
+	TypeATR        Type = "ATR"        // Average true range, with Wilder's smoothing
+	TypeBollinger  Type = "BOLLINGER"  // SMA of the source with bands a multiple of standard deviations away
+	TypeSupertrend Type = "SUPERTREND" // Trailing band a multiple of the ATR away from the hl2, flipping with the trend
+	TypeVWAP       Type = "VWAP"       // Volume weighted average of the source, anchored to each day
+)
+
+// The lines of indicators with several, the first of each being its main line
+const (
+	LineMACD      = "macd"
+	LineSignal    = "signal"
+	LineHistogram = "histogram"
+	LineMiddle    = "middle"
+	LineUpper     = "upper"
+	LineLower     = "lower"
+	LineTrend     = "supertrend"
+	LineDirection = "direction" // 1 in an uptrend, -1 in a downtrend
+)
+
+// Spec declares an indicator. Zero values take the indicator's defaults: a period
+// of 14 for RSI and ATR, 10 for Supertrend and 20 otherwise, MACD's 12, 26 and 9,
+// a multiplier of 2 standard deviations for Bollinger bands and of 3 ATRs for
+// Supertrend, and the close as source (the typical price for VWAP).
+type Spec struct {
+	Type       Type    `json:"type" bson:"type"`
+	Period     int     `json:"period,omitempty" bson:"period,omitempty"`
+	Source     Source  `json:"source,omitempty" bson:"source,omitempty"`
+	Fast       int     `json:"fast,omitempty" bson:"fast,omitempty"`     // MACD's fast EMA period
+	Slow       int     `json:"slow,omitempty" bson:"slow,omitempty"`     // MACD's slow EMA period
+	Signal     int     `json:"signal,omitempty" bson:"signal,omitempty"` // MACD's signal EMA period
+	Multiplier float64 `json:"multiplier,omitempty" bson:"multiplier,omitempty"`
+}
+
+// withDefaults returns the spec with its type normalized and defaults set
+func (s Spec) withDefaults() Spec {
+	s.Type = Type(strings.ToUpper(string(s.Type)))
+	s.Source = Source(strings.ToLower(string(s.Source)))
+
+	if s.Period == 0 {
+		switch s.Type {
+		case TypeRSI, TypeATR:
+			s.Period = 14
+		case TypeSupertrend:
+			s.Period = 10
+		default:
+			s.Period = 20
+		}
+	}
+	if s.Source == "" {
+		s.Source = SourceClose
+		if s.Type == TypeVWAP {
+			s.Source = SourceHLC3
+		}
+	}
+	if s.Type == TypeMACD {
+		if s.Fast == 0 {
+			s.Fast = 12
+		}
+		if s.Slow == 0 {
+			s.Slow = 26
+		}
+		if s.Signal == 0 {
+			s.Signal = 9
+		}
+	}
+	if s.Multiplier == 0 {
+		switch s.Type {
+		case TypeBollinger:
+			s.Multiplier = 2
+		case TypeSupertrend:
+			s.Multiplier = 3
+		}
+	}
+
+	return s
+}
+
+// Validate checks the spec declares an indicator that can be computed
+func (s Spec) Validate() error {
+	s = s.withDefaults()
+
+	switch s.Type {
+	case TypeSMA, TypeEMA, TypeRSI, TypeMACD, TypeATR, TypeBollinger, TypeSupertrend, TypeVWAP:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidSpec, s.Type)
+	}
+	switch s.Source {
+	case SourceOpen, SourceHigh, SourceLow, SourceClose, SourceHL2, SourceHLC3:
+	default:
+		return fmt.Errorf("%w: unknown source %q", ErrInvalidSpec, s.Source)
+	}
+	if s.Period < 0 || s.Fast < 0 || s.Slow < 0 || s.Signal < 0 {
+		return fmt.Errorf("%w: periods must be greater than zero", ErrInvalidSpec)
+	}
+	if s.Type == TypeMACD && s.Fast >= s.Slow {
+		return fmt.Errorf("%w: the fast period must be shorter than the slow one", ErrInvalidSpec)
+	}
+	if s.Multiplier < 0 {
+		return fmt.Errorf("%w: multiplier cannot be negative", ErrInvalidSpec)
+	}
+
+	return nil
+}
+
+// String names the indicator the spec declares, such as "EMA(20,close)" or
+// "MACD(12,26,9,close)", telling apart specs of different indicators
+func (s Spec) String() string {
+	s = s.withDefaults()
+
+	switch s.Type {
+	case TypeMACD:
+		return fmt.Sprintf("%s(%d,%d,%d,%s)", s.Type, s.Fast, s.Slow, s.Signal, s.Source)
+	case TypeATR:
+		return fmt.Sprintf("%s(%d)", s.Type, s.Period)
+	case TypeBollinger:
+		return fmt.Sprintf("%s(%d,%g,%s)", s.Type, s.Period, s.Multiplier, s.Source)
+	case TypeSupertrend:
+		return fmt.Sprintf("%s(%d,%g)", s.Type, s.Period, s.Multiplier)
+	case TypeVWAP:
+		return fmt.Sprintf("%s(%s)", s.Type, s.Source)
+	}
+	return fmt.Sprintf("%s(%d,%s)", s.Type, s.Period, s.Source)
+}
+
+// Indicator is an indicator computed incrementally on the candles of an
+// instrument over an interval
+type Indicator interface {
+	// Update adds the next completed candle
+	Update(candle Candle)
+	// Value returns the indicator's main line, false until enough candles were
+	// added for it to be computed
+	Value() (float64, bool)
+	// Line returns one of the indicator's lines by name, false for lines the
+	// indicator does not have or cannot compute yet
+	Line(name string) (float64, bool)
+}
+
+// New creates the indicator a spec declares
+func New(spec Spec) (Indicator, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	spec = spec.withDefaults()
+
+	switch spec.Type {
+	case TypeSMA:
+		return &single{name: "sma", source: spec.Source, average: newSMA(spec.Period)}, nil
+	case TypeEMA:
+		return &single{name: "ema", source: spec.Source, average: newEMA(spec.Period)}, nil
+	case TypeRSI:
+		return newRSI(spec.Period, spec.Source), nil
+	case TypeMACD:
+		return newMACD(spec.Fast, spec.Slow, spec.Signal, spec.Source), nil
+	case TypeATR:
+		return &atr{average: newWilder(spec.Period)}, nil
+	case TypeBollinger:
+		return newBollinger(spec.Period, spec.Multiplier, spec.Source), nil
+	case TypeSupertrend:
+		return &supertrend{atr: atr{average: newWilder(spec.Period)}, multiplier: spec.Multiplier}, nil
+	case TypeVWAP:
+		return &vwap{source: spec.Source}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidSpec, spec.Type)
+}
+
+// Point is the lines of an indicator at a candle
+type Point struct {
+	Time  time.Time          `json:"time"`
+	Lines map[string]float64 `json:"lines"` // Only those computed by then
+}
+
+// Compute computes an indicator over candles, returning its lines at each of them
+// from the first its main line is computed at
+func Compute(spec Spec, candles []Candle, lines ...string) ([]Point, error) {
+	indicator, err := New(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		lines = Lines(spec.withDefaults().Type)
+	}
+
+	points := make([]Point, 0, len(candles))
+	for _, candle := range candles {
+		indicator.Update(candle)
+		if _, ok := indicator.Value(); !ok {
+			continue
+		}
+
+		point := Point{Time: candle.Time, Lines: make(map[string]float64, len(lines))}
+		for _, line := range lines {
+			if value, ok := indicator.Line(line); ok {
+				point.Lines[line] = value
+			}
+		}
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+// Lines returns the names of the lines of a type of indicator, its main line first
+func Lines(indicatorType Type) []string {
+	switch Type(strings.ToUpper(string(indicatorType))) {
+	case TypeMACD:
+		return []string{LineMACD, LineSignal, LineHistogram}
+	case TypeBollinger:
+		return []string{LineMiddle, LineUpper, LineLower}
+	case TypeSupertrend:
+		return []string{LineTrend, LineDirection}
+	}
+	return []string{strings.ToLower(string(indicatorType))}
+}
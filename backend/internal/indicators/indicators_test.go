@@ -0,0 +1,191 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2026, 10, 12, 9, 15, 0, 0, time.UTC)
+
+// closes returns a minute candle closing at each price, with the price as its
+// open, high and low too
+func closes(prices ...float64) []Candle {
+	candles := make([]Candle, len(prices))
+	for i, price := range prices {
+		candles[i] = Candle{Time: start.Add(time.Duration(i) * time.Minute), Open: price, High: price, Low: price, Close: price, Volume: 100}
+	}
+	return candles
+}
+
+// mainLine returns the main line of an indicator at each candle it is computed at
+func mainLine(t *testing.T, spec Spec, candles []Candle) []float64 {
+	points, err := Compute(spec, candles)
+	require.NoError(t, err)
+
+	line := Lines(spec.Type)[0]
+	values := make([]float64, len(points))
+	for i, point := range points {
+		values[i] = point.Lines[line]
+	}
+	return values
+}
+
+func TestSpec(t *testing.T) {
+	for _, spec := range []Spec{
+		{Type: "KAMA"},
+		{Type: TypeSMA, Source: "median"},
+		{Type: TypeEMA, Period: -1},
+		{Type: TypeMACD, Fast: 26, Slow: 12},
+		{Type: TypeBollinger, Multiplier: -2},
+	} {
+		assert.ErrorIs(t, spec.Validate(), ErrInvalidSpec, spec)
+		_, err := New(spec)
+		assert.ErrorIs(t, err, ErrInvalidSpec)
+	}
+
+	assert.Equal(t, "RSI(14,close)", Spec{Type: "rsi"}.String())
+	assert.Equal(t, "MACD(12,26,9,close)", Spec{Type: TypeMACD}.String())
+	assert.Equal(t, "SUPERTREND(10,3)", Spec{Type: TypeSupertrend}.String())
+	assert.Equal(t, "VWAP(hlc3)", Spec{Type: TypeVWAP}.String())
+}
+
+func TestMovingAverages(t *testing.T) {
+	candles := closes(1, 2, 3, 4, 5)
+
+	assert.Equal(t, []float64{2, 3, 4}, mainLine(t, Spec{Type: TypeSMA, Period: 3}, candles))
+	// Seeded with the SMA of the first 3 closes, then smoothed by 2/(3+1)
+	assert.Equal(t, []float64{2, 3, 4}, mainLine(t, Spec{Type: TypeEMA, Period: 3}, candles))
+	assert.Equal(t, []float64{2, 3.5}, mainLine(t, Spec{Type: TypeEMA, Period: 3}, closes(1, 2, 3, 5)))
+	assert.Equal(t, []float64{3, 4, 5}, mainLine(t, Spec{Type: TypeSMA, Period: 3, Source: SourceHigh}, closes(2, 3, 4, 5, 6)))
+}
+
+func TestRSI(t *testing.T) {
+	// Averages of the first 2 changes, then Wilder's smoothing
+	assert.Equal(t, []float64{100, 50}, mainLine(t, Spec{Type: TypeRSI, Period: 2}, closes(1, 2, 3, 2)))
+	assert.Equal(t, []float64{50}, mainLine(t, Spec{Type: TypeRSI, Period: 2}, closes(5, 5, 5)))
+}
+
+func TestMACD(t *testing.T) {
+	candles := closes(10, 11, 12, 11, 13, 14, 13, 15, 16, 15)
+	spec := Spec{Type: TypeMACD, Fast: 2, Slow: 4, Signal: 3}
+
+	fast := mainLine(t, Spec{Type: TypeEMA, Period: 2}, candles)
+	slow := mainLine(t, Spec{Type: TypeEMA, Period: 4}, candles)
+	points, err := Compute(spec, candles)
+	require.NoError(t, err)
+	require.Len(t, points, len(slow))
+
+	var line []float64
+	for i, point := range points {
+		assert.InDelta(t, fast[i+2]-slow[i], point.Lines[LineMACD], 1e-9)
+		line = append(line, point.Lines[LineMACD])
+	}
+
+	// The signal line is the EMA of the MACD line, only computed after 3 values
+	_, hasSignal := points[1].Lines[LineSignal]
+	assert.False(t, hasSignal)
+	signal := mainLine(t, Spec{Type: TypeEMA, Period: 3}, closes(line...))
+	for i := 2; i < len(points); i++ {
+		assert.InDelta(t, signal[i-2], points[i].Lines[LineSignal], 1e-9)
+		assert.InDelta(t, line[i]-signal[i-2], points[i].Lines[LineHistogram], 1e-9)
+	}
+}
+
+func TestATR(t *testing.T) {
+	candles := []Candle{
+		{Time: start, High: 10, Low: 8, Close: 9},
+		{Time: start.Add(time.Minute), High: 11, Low: 9, Close: 10},
+		{Time: start.Add(2 * time.Minute), High: 15, Low: 13, Close: 14}, // Gapping 5 above the last close
+	}
+
+	// The average of the first 2 true ranges, then smoothed with the gap's range
+	// of 15-10
+	assert.Equal(t, []float64{2, 3.5}, mainLine(t, Spec{Type: TypeATR, Period: 2}, candles))
+}
+
+func TestBollinger(t *testing.T) {
+	points, err := Compute(Spec{Type: TypeBollinger, Period: 3}, closes(1, 2, 3))
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+
+	deviation := math.Sqrt(2.0 / 3)
+	assert.Equal(t, 2.0, points[0].Lines[LineMiddle])
+	assert.InDelta(t, 2+2*deviation, points[0].Lines[LineUpper], 1e-9)
+	assert.InDelta(t, 2-2*deviation, points[0].Lines[LineLower], 1e-9)
+}
+
+func TestSupertrend(t *testing.T) {
+	var candles []Candle
+	for i, price := range []float64{100, 102, 104, 106, 108, 110, 100, 95} {
+		candles = append(candles, Candle{Time: start.Add(time.Duration(i) * time.Minute), Open: price, High: price + 1, Low: price - 1, Close: price})
+	}
+
+	points, err := Compute(Spec{Type: TypeSupertrend, Period: 2, Multiplier: 1}, candles)
+	require.NoError(t, err)
+	require.Len(t, points, 7)
+
+	// The band trails below the rising closes, never moving down, until the close
+	// falls through it and it flips above
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, 1.0, points[i].Lines[LineDirection], i)
+		assert.Less(t, points[i].Lines[LineTrend], candles[i+1].Close)
+		if i > 0 {
+			assert.GreaterOrEqual(t, points[i].Lines[LineTrend], points[i-1].Lines[LineTrend])
+		}
+	}
+	assert.Equal(t, -1.0, points[5].Lines[LineDirection])
+	assert.Greater(t, points[5].Lines[LineTrend], candles[6].Close)
+	assert.Equal(t, -1.0, points[6].Lines[LineDirection])
+}
+
+func TestVWAP(t *testing.T) {
+	candles := []Candle{
+		{Time: start, High: 12, Low: 9, Close: 9, Volume: 100},                                 // Typical price 10
+		{Time: start.Add(time.Minute), High: 22, Low: 19, Close: 19, Volume: 300},              // 20
+		{Time: start.Add(24 * time.Hour), High: 32, Low: 29, Close: 29, Volume: 50},            // 30, the next day
+		{Time: start.Add(24*time.Hour + time.Minute), High: 32, Low: 29, Close: 29, Volume: 0}, // Without volume
+	}
+
+	assert.Equal(t, []float64{10, 17.5, 30, 30}, mainLine(t, Spec{Type: TypeVWAP}, candles))
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore(4)
+	for _, candle := range closes(1, 2, 3) {
+		require.NoError(t, store.Add("NIFTY", "1m", candle))
+	}
+	assert.ErrorIs(t, store.Add("NIFTY", "1m", Candle{Time: start}), ErrOutOfOrder)
+
+	// Indicators are computed on the candles held when first asked for
+	value, ok, err := store.Value("NIFTY", "1m", Spec{Type: TypeSMA, Period: 3}, "")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, value)
+	_, ok, err = store.Value("NIFTY", "1m", Spec{Type: TypeMACD, Fast: 2, Slow: 3, Signal: 2}, LineSignal)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, _, err = store.Value("NIFTY", "1m", Spec{Type: TypeSMA, Period: 3}, LineUpper)
+	assert.ErrorIs(t, err, ErrInvalidSpec)
+
+	// and updated as candles are added, past those the store keeps
+	for _, candle := range closes(1, 2, 3, 7, 8)[3:] {
+		require.NoError(t, store.Add("NIFTY", "1m", candle))
+	}
+	value, ok, err = store.Value("NIFTY", "1m", Spec{Type: TypeSMA, Period: 3}, "sma")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 6.0, value)
+	_, ok, err = store.Value("NIFTY", "1m", Spec{Type: TypeMACD, Fast: 2, Slow: 3, Signal: 2}, LineSignal)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	candles := store.Candles("NIFTY", "1m", 0)
+	assert.Len(t, candles, 4)
+	assert.Equal(t, 8.0, candles[3].Close)
+	assert.Len(t, store.Candles("NIFTY", "1m", 2), 2)
+	assert.Empty(t, store.Candles("NIFTY", "5m", 0))
+}
@@ -0,0 +1,349 @@
+package indicators
+
+import "math"
+
+// average is a moving average updated one value at a time
+type average interface {
+	update(value float64)
+	value() (float64, bool) // False until it has seen a period of values
+}
+
+// sma is the simple moving average of the last period values
+type sma struct {
+	window []float64 // Ring of the last period values
+	next   int       // Index of the oldest value, the next to be replaced
+	count  int
+	sum    float64
+}
+
+func newSMA(period int) *sma {
+	return &sma{window: make([]float64, period)}
+}
+
+func (a *sma) update(value float64) {
+	if a.count == len(a.window) {
+		a.sum -= a.window[a.next]
+	} else {
+		a.count++
+	}
+	a.window[a.next] = value
+	a.sum += value
+	a.next = (a.next + 1) % len(a.window)
+}
+
+func (a *sma) value() (float64, bool) {
+	if a.count == 0 {
+		return 0, false
+	}
+	return a.sum / float64(a.count), a.count == len(a.window)
+}
+
+// stddev returns the population standard deviation of the window's values
+func (a *sma) stddev() float64 {
+	mean := a.sum / float64(a.count)
+	var squares float64
+	for _, value := range a.window[:a.count] {
+		squares += (value - mean) * (value - mean)
+	}
+	return math.Sqrt(squares / float64(a.count))
+}
+
+// ema is an exponential moving average seeded with the simple average of its first
+// period values. Wilder's smoothing is the same with a smoothing factor of
+// 1/period instead of 2/(period+1).
+type ema struct {
+	period    int
+	smoothing float64
+	count     int
+	current   float64
+}
+
+func newEMA(period int) *ema {
+	return &ema{period: period, smoothing: 2 / float64(period+1)}
+}
+
+func newWilder(period int) *ema {
+	return &ema{period: period, smoothing: 1 / float64(period)}
+}
+
+func (a *ema) update(value float64) {
+	a.count++
+	if a.count <= a.period {
+		a.current += (value - a.current) / float64(a.count)
+		return
+	}
+	a.current += (value - a.current) * a.smoothing
+}
+
+func (a *ema) value() (float64, bool) {
+	return a.current, a.count >= a.period
+}
+
+// single is an indicator of a single line, a moving average of a source
+type single struct {
+	name    string
+	source  Source
+	average average
+}
+
+func (i *single) Update(candle Candle) {
+	i.average.update(i.source.price(candle))
+}
+
+func (i *single) Value() (float64, bool) {
+	return i.average.value()
+}
+
+func (i *single) Line(name string) (float64, bool) {
+	if name != i.name {
+		return 0, false
+	}
+	return i.Value()
+}
+
+// rsi is the relative strength index, the ratio of the Wilder averages of a
+// source's gains and losses as a value from 0 to 100
+type rsi struct {
+	source   Source
+	previous float64
+	seen     bool
+	gains    *ema
+	losses   *ema
+}
+
+func newRSI(period int, source Source) *rsi {
+	return &rsi{source: source, gains: newWilder(period), losses: newWilder(period)}
+}
+
+func (i *rsi) Update(candle Candle) {
+	price := i.source.price(candle)
+	if i.seen {
+		change := price - i.previous
+		i.gains.update(math.Max(change, 0))
+		i.losses.update(math.Max(-change, 0))
+	}
+	i.previous, i.seen = price, true
+}
+
+func (i *rsi) Value() (float64, bool) {
+	gain, ok := i.gains.value()
+	if !ok {
+		return 0, false
+	}
+	loss, _ := i.losses.value()
+
+	switch {
+	case gain == 0 && loss == 0:
+		return 50, true
+	case loss == 0:
+		return 100, true
+	}
+	return 100 - 100/(1+gain/loss), true
+}
+
+func (i *rsi) Line(name string) (float64, bool) {
+	if name != "rsi" {
+		return 0, false
+	}
+	return i.Value()
+}
+
+// macd is the difference of a source's fast and slow EMAs, with the EMA of that
+// difference as its signal line and their difference as its histogram
+type macd struct {
+	source Source
+	fast   *ema
+	slow   *ema
+	signal *ema
+	line   float64
+}
+
+func newMACD(fast, slow, signal int, source Source) *macd {
+	return &macd{source: source, fast: newEMA(fast), slow: newEMA(slow), signal: newEMA(signal)}
+}
+
+func (i *macd) Update(candle Candle) {
+	price := i.source.price(candle)
+	i.fast.update(price)
+	i.slow.update(price)
+
+	slow, ok := i.slow.value()
+	if !ok {
+		return
+	}
+	fast, _ := i.fast.value()
+	i.line = fast - slow
+	i.signal.update(i.line)
+}
+
+func (i *macd) Value() (float64, bool) {
+	_, ok := i.slow.value()
+	return i.line, ok
+}
+
+func (i *macd) Line(name string) (float64, bool) {
+	switch name {
+	case LineMACD:
+		return i.Value()
+	case LineSignal:
+		return i.signal.value()
+	case LineHistogram:
+		signal, ok := i.signal.value()
+		return i.line - signal, ok
+	}
+	return 0, false
+}
+
+// atr is the average true range, the Wilder average of the range of candles
+// including any gap from the close of the one before
+type atr struct {
+	average  *ema
+	previous float64 // Close of the last candle
+	seen     bool
+}
+
+func (i *atr) Update(candle Candle) {
+	trueRange := candle.High - candle.Low
+	if i.seen {
+		trueRange = math.Max(trueRange, math.Max(math.Abs(candle.High-i.previous), math.Abs(candle.Low-i.previous)))
+	}
+	i.average.update(trueRange)
+	i.previous, i.seen = candle.Close, true
+}
+
+func (i *atr) Value() (float64, bool) {
+	return i.average.value()
+}
+
+func (i *atr) Line(name string) (float64, bool) {
+	if name != "atr" {
+		return 0, false
+	}
+	return i.Value()
+}
+
+// bollinger is the SMA of a source with bands a multiple of the standard
+// deviation of the same values above and below it
+type bollinger struct {
+	source     Source
+	average    *sma
+	multiplier float64
+}
+
+func newBollinger(period int, multiplier float64, source Source) *bollinger {
+	return &bollinger{source: source, average: newSMA(period), multiplier: multiplier}
+}
+
+func (i *bollinger) Update(candle Candle) {
+	i.average.update(i.source.price(candle))
+}
+
+func (i *bollinger) Value() (float64, bool) {
+	return i.average.value()
+}
+
+func (i *bollinger) Line(name string) (float64, bool) {
+	middle, ok := i.average.value()
+	switch name {
+	case LineMiddle:
+		return middle, ok
+	case LineUpper:
+		return middle + i.multiplier*i.average.stddev(), ok
+	case LineLower:
+		return middle - i.multiplier*i.average.stddev(), ok
+	}
+	return 0, false
+}
+
+// supertrend trails a band a multiple of the ATR below the hl2 of candles in an
+// uptrend, and above it in a downtrend. Bands only move in the trend's favour,
+// the trend flipping when a candle closes beyond the band.
+type supertrend struct {
+	atr        atr
+	multiplier float64
+	upper      float64 // Final upper band
+	lower      float64 // Final lower band
+	trend      int     // 1 up, -1 down, 0 until the ATR is computed
+}
+
+func (i *supertrend) Update(candle Candle) {
+	previous := i.atr.previous
+	i.atr.Update(candle)
+	atr, ok := i.atr.Value()
+	if !ok {
+		return
+	}
+
+	hl2 := (candle.High + candle.Low) / 2
+	upper := hl2 + i.multiplier*atr
+	lower := hl2 - i.multiplier*atr
+	if i.trend != 0 {
+		if upper > i.upper && previous <= i.upper {
+			upper = i.upper
+		}
+		if lower < i.lower && previous >= i.lower {
+			lower = i.lower
+		}
+	}
+
+	switch {
+	case i.trend == 0 && candle.Close >= hl2, i.trend < 0 && candle.Close > upper:
+		i.trend = 1
+	case i.trend == 0, i.trend > 0 && candle.Close < lower:
+		i.trend = -1
+	}
+	i.upper, i.lower = upper, lower
+}
+
+func (i *supertrend) Value() (float64, bool) {
+	switch i.trend {
+	case 1:
+		return i.lower, true
+	case -1:
+		return i.upper, true
+	}
+	return 0, false
+}
+
+func (i *supertrend) Line(name string) (float64, bool) {
+	switch name {
+	case LineTrend:
+		return i.Value()
+	case LineDirection:
+		return float64(i.trend), i.trend != 0
+	}
+	return 0, false
+}
+
+// vwap is the volume weighted average of a source over the candles of the day so
+// far, starting over with the first candle of each day
+type vwap struct {
+	source Source
+	year   int
+	day    int // Of the year, of the candles averaged
+	value  float64
+	volume float64
+}
+
+func (i *vwap) Update(candle Candle) {
+	if year, day := candle.Time.Year(), candle.Time.YearDay(); year != i.year || day != i.day {
+		i.year, i.day = year, day
+		i.value, i.volume = 0, 0
+	}
+	i.value += i.source.price(candle) * candle.Volume
+	i.volume += candle.Volume
+}
+
+func (i *vwap) Value() (float64, bool) {
+	if i.volume == 0 {
+		return 0, false
+	}
+	return i.value / i.volume, true
+}
+
+func (i *vwap) Line(name string) (float64, bool) {
+	if name != "vwap" {
+		return 0, false
+	}
+	return i.Value()
+}
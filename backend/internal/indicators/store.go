@@ -0,0 +1,131 @@
+package indicators
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultMaxCandles is the number of candles the store keeps per instrument and
+// interval
+const DefaultMaxCandles = 5000
+
+// series is the candles of an instrument over an interval and the indicators
+// declared on them
+type series struct {
+	candles    []Candle             // Oldest first
+	indicators map[string]Indicator // By spec
+}
+
+// Store keeps the recent completed candles of instruments by interval. Indicators
+// are declared on a series the first time their value is asked for, computed on
+// the candles it holds, and updated incrementally as candles are added to it
+// afterwards.
+type Store struct {
+	maxCandles int
+	series     map[string]*series // By symbol and interval
+	mutex      sync.Mutex
+}
+
+// NewStore creates a new Store keeping maxCandles candles per instrument and
+// interval, DefaultMaxCandles when it is not positive
+func NewStore(maxCandles int) *Store {
+	if maxCandles <= 0 {
+		maxCandles = DefaultMaxCandles
+	}
+
+	return &Store{
+		maxCandles: maxCandles,
+		series:     make(map[string]*series),
+	}
+}
+
+// Add adds a completed candle of a symbol over an interval, updating the
+// indicators declared on them. Candles must be added in order.
+func (s *Store) Add(symbol, interval string, candle Candle) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := symbol + "\x00" + interval
+	candles, exists := s.series[key]
+	if !exists {
+		candles = &series{indicators: make(map[string]Indicator)}
+		s.series[key] = candles
+	}
+	if last := len(candles.candles) - 1; last >= 0 && !candle.Time.After(candles.candles[last].Time) {
+		return fmt.Errorf("%w: %s %s at %s", ErrOutOfOrder, symbol, interval, candle.Time)
+	}
+
+	if len(candles.candles) >= s.maxCandles {
+		candles.candles = append(candles.candles[:0], candles.candles[len(candles.candles)-s.maxCandles+1:]...)
+	}
+	candles.candles = append(candles.candles, candle)
+	for _, indicator := range candles.indicators {
+		indicator.Update(candle)
+	}
+
+	return nil
+}
+
+// Candles returns the last limit candles of a symbol over an interval, oldest
+// first, every candle kept when limit is not positive
+func (s *Store) Candles(symbol, interval string, limit int) []Candle {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	candles, exists := s.series[symbol+"\x00"+interval]
+	if !exists {
+		return []Candle{}
+	}
+	from := 0
+	if limit > 0 && len(candles.candles) > limit {
+		from = len(candles.candles) - limit
+	}
+	return append([]Candle{}, candles.candles[from:]...)
+}
+
+// Value returns a line of an indicator on the candles of a symbol over an
+// interval, its main line when line is empty. It is false while there are too few
+// candles for the line to be computed.
+func (s *Store) Value(symbol, interval string, spec Spec, line string) (float64, bool, error) {
+	if line == "" {
+		line = Lines(spec.withDefaults().Type)[0]
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := symbol + "\x00" + interval
+	candles, exists := s.series[key]
+	if !exists {
+		candles = &series{indicators: make(map[string]Indicator)}
+		s.series[key] = candles
+	}
+
+	indicator, exists := candles.indicators[spec.String()]
+	if !exists {
+		var err error
+		if indicator, err = New(spec); err != nil {
+			return 0, false, err
+		}
+		for _, candle := range candles.candles {
+			indicator.Update(candle)
+		}
+		candles.indicators[spec.String()] = indicator
+	}
+
+	value, ok := indicator.Line(line)
+	if !ok && !hasLine(spec, line) {
+		return 0, false, fmt.Errorf("%w: %s has no line %q", ErrInvalidSpec, spec, line)
+	}
+	return value, ok, nil
+}
+
+// hasLine reports whether the indicator a spec declares has a line
+func hasLine(spec Spec, line string) bool {
+	for _, name := range Lines(spec.withDefaults().Type) {
+		if name == line {
+			return true
+		}
+	}
+	return false
+}
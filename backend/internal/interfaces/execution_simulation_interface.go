@@ -57,6 +57,11 @@ type ExecutionSimulationInterface interface {
 	GetSystemStatus(ctx context.Context) (map[string]interface{}, error)
 	SynchronizeMarketData(ctx context.Context, symbols []string) error
 	ResetSimulationEnvironment(ctx context.Context, accountID string) error
+	
+	// Snapshots
+	CreateSimulationSnapshot(ctx context.Context, accountID string, name string, description string) (*models.SimulationSnapshot, error)
+	GetSimulationSnapshots(ctx context.Context, accountID string) ([]*models.SimulationSnapshot, error)
+	RestoreSimulationSnapshot(ctx context.Context, accountID string, snapshotID string) (*models.SimulationAccount, error)
 }
 
 // ExecutionPlatformInterface defines the contract for the execution platform
@@ -106,6 +111,7 @@ func GetInterfaceMetadata() InterfaceMetadata {
 			"market_data",
 			"backtesting",
 			"system_management",
+			"simulation_snapshots",
 		},
 		DeprecatedFeatures: []string{},
 		MaxBatchSize: 100,
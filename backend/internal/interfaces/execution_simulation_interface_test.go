@@ -24,6 +24,7 @@ func TestInterfaceDefinitions(t *testing.T) {
 		"market_data",
 		"backtesting",
 		"system_management",
+		"simulation_snapshots",
 	}
 	
 	for _, feature := range expectedFeatures {
@@ -183,6 +184,18 @@ func (m *mockExecutionSimulationInterface) ResetSimulationEnvironment(ctx contex
 	return nil
 }
 
+func (m *mockExecutionSimulationInterface) CreateSimulationSnapshot(ctx context.Context, accountID string, name string, description string) (interface{}, error) {
+	return nil, nil
+}
+
+func (m *mockExecutionSimulationInterface) GetSimulationSnapshots(ctx context.Context, accountID string) (interface{}, error) {
+	return nil, nil
+}
+
+func (m *mockExecutionSimulationInterface) RestoreSimulationSnapshot(ctx context.Context, accountID string, snapshotID string) (interface{}, error) {
+	return nil, nil
+}
+
 type mockExecutionPlatformInterface struct{}
 
 func (m *mockExecutionPlatformInterface) GetRealTimeMarketData(ctx context.Context, symbol string) (interface{}, error) {
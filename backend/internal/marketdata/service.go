@@ -3,6 +3,7 @@ package marketdata
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -61,6 +62,7 @@ type DataSourceConnector interface {
 
 // DataSourceManager manages multiple data sources
 type DataSourceManager struct {
+	lastUpdate      int64 // Unix nanoseconds of the last data received, first for atomic alignment
 	primarySource   DataSourceConnector
 	backupSources   []DataSourceConnector
 	activeSource    DataSourceConnector
@@ -240,6 +242,8 @@ func (m *DataSourceManager) SubscribeToMarketData(ctx context.Context, symbols [
 
 	// Create a wrapper callback that distributes the data to all registered callbacks
 	wrapperCallback := func(data MarketData) {
+		atomic.StoreInt64(&m.lastUpdate, time.Now().UnixNano())
+
 		m.subscriptionsMu.RLock()
 		callbacks := m.subscriptions[data.Symbol]
 		m.subscriptionsMu.RUnlock()
@@ -253,6 +257,16 @@ func (m *DataSourceManager) SubscribeToMarketData(ctx context.Context, symbols [
 	return activeSource.SubscribeToMarketData(ctx, symbols, wrapperCallback)
 }
 
+// LastUpdate returns when market data was last received from the active source,
+// the zero time when none was
+func (m *DataSourceManager) LastUpdate() time.Time {
+	nanos := atomic.LoadInt64(&m.lastUpdate)
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
 // UnsubscribeFromMarketData unsubscribes from market data for the specified symbols
 func (m *DataSourceManager) UnsubscribeFromMarketData(ctx context.Context, symbols []string) error {
 	m.mutex.RLock()
@@ -0,0 +1,114 @@
+package messagequeue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSConfig holds the NATS configuration
+type NATSConfig struct {
+	URL  string
+	Name string // Connection name shown by the NATS server
+}
+
+// NATSClient represents a NATS client. It implements MessageBroker, topics being
+// NATS subjects.
+type NATSClient struct {
+	conn *nats.Conn
+}
+
+// NewNATSClient creates a new NATS client
+func NewNATSClient(config NATSConfig) (*NATSClient, error) {
+	conn, err := nats.Connect(config.URL,
+		nats.Name(config.Name),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	log.Println("Successfully connected to NATS")
+	return &NATSClient{conn: conn}, nil
+}
+
+// Close drains and closes the NATS connection, delivering buffered messages first
+func (n *NATSClient) Close() error {
+	if n.conn == nil {
+		return nil
+	}
+	return n.conn.Drain()
+}
+
+// Ping checks the connection to the NATS server is up, round-tripping to the
+// server until ctx is done
+func (n *NATSClient) Ping(ctx context.Context) error {
+	if n.conn == nil || !n.conn.IsConnected() {
+		status := nats.DISCONNECTED
+		if n.conn != nil {
+			status = n.conn.Status()
+		}
+		return fmt.Errorf("not connected to NATS: %v", status)
+	}
+	return n.conn.FlushWithContext(ctx)
+}
+
+// Publish publishes a message to a subject
+func (n *NATSClient) Publish(ctx context.Context, topic string, message interface{}) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	return n.conn.Publish(topic, payload)
+}
+
+// Subscribe subscribes to a subject, which may contain wildcards, until ctx is done
+func (n *NATSClient) Subscribe(ctx context.Context, topic string, handler func([]byte) error) error {
+	subscription, err := n.conn.Subscribe(topic, func(msg *nats.Msg) {
+		if err := handler(msg.Data); err != nil {
+			log.Printf("Error handling NATS message on %s: %v", msg.Subject, err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := subscription.Unsubscribe(); err != nil {
+			log.Printf("Error unsubscribing from %s: %v", topic, err)
+		}
+	}()
+
+	return nil
+}
+
+// BrokerEventPublisher publishes events to topics of a message broker, such as
+// NATS subjects, named like the RabbitMQ routing keys of the message service
+type BrokerEventPublisher struct {
+	broker MessageBroker
+}
+
+// NewBrokerEventPublisher creates a new publisher of events to broker
+func NewBrokerEventPublisher(broker MessageBroker) *BrokerEventPublisher {
+	return &BrokerEventPublisher{broker: broker}
+}
+
+// PublishOrderEvent publishes an order event to order.events.<event>
+func (p *BrokerEventPublisher) PublishOrderEvent(ctx context.Context, msgType MessageType, data interface{}) error {
+	message := Message{
+		Type:      msgType,
+		Timestamp: time.Now(),
+		Payload:   data,
+	}
+
+	topic := "order.events." + strings.TrimPrefix(string(msgType), "order.")
+	return p.broker.Publish(ctx, topic, message)
+}
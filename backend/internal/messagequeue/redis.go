@@ -48,6 +48,11 @@ func (r *RedisClient) Close() error {
 	return r.client.Close()
 }
 
+// Ping checks the Redis server is reachable
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
 // Publish publishes a message to a channel
 func (r *RedisClient) Publish(ctx context.Context, channel string, message interface{}) error {
 	payload, err := json.Marshal(message)
@@ -71,6 +76,55 @@ func (r *RedisClient) Subscribe(ctx context.Context, channel string) (<-chan *re
 	return pubsub.Channel(), nil
 }
 
+// RedisBroker adapts a RedisClient to MessageBroker, topics being Redis channels
+type RedisBroker struct {
+	client *RedisClient
+}
+
+// NewRedisBroker creates a new broker publishing to the channels of client
+func NewRedisBroker(client *RedisClient) *RedisBroker {
+	return &RedisBroker{client: client}
+}
+
+// Publish publishes a message to a channel
+func (b *RedisBroker) Publish(ctx context.Context, topic string, message interface{}) error {
+	return b.client.Publish(ctx, topic, message)
+}
+
+// Subscribe subscribes to a channel until ctx is done
+func (b *RedisBroker) Subscribe(ctx context.Context, topic string, handler func([]byte) error) error {
+	pubsub := b.client.client.Subscribe(ctx, topic)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+	}
+
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				if err := handler([]byte(msg.Payload)); err != nil {
+					log.Printf("Error handling Redis message on %s: %v", msg.Channel, err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close leaves the Redis client open, as its creator owns it
+func (b *RedisBroker) Close() error {
+	return nil
+}
+
 // Set sets a key-value pair with optional expiration
 func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	payload, err := json.Marshal(value)
@@ -22,6 +22,9 @@ const (
 	OrderUpdate         MessageType = "order.update"
 	OrderCancel         MessageType = "order.cancel"
 	OrderExecution      MessageType = "order.execution"
+	OrderAcked          MessageType = "order.acked"
+	OrderFill           MessageType = "order.fill"
+	OrderReject         MessageType = "order.reject"
 	
 	// Portfolio message types
 	PortfolioUpdate     MessageType = "portfolio.update"
@@ -43,6 +46,32 @@ type Message struct {
 	Payload   interface{}     `json:"payload"`
 }
 
+// OrderEvent is the normalized order lifecycle event published with the order
+// message types: OrderNew when an order is created, OrderAcked when it is accepted,
+// OrderFill for each fill, OrderCancel and OrderReject
+type OrderEvent struct {
+	ID             string      `json:"id"` // Unique per event, for consumers to drop duplicates
+	Type           MessageType `json:"type"`
+	OrderID        string      `json:"orderId"`
+	UserID         string      `json:"userId"`
+	StrategyID     string      `json:"strategyId,omitempty"`
+	PortfolioID    string      `json:"portfolioId,omitempty"`
+	BrokerOrderID  string      `json:"brokerOrderId,omitempty"`
+	Symbol         string      `json:"symbol"`
+	Exchange       string      `json:"exchange"`
+	Direction      string      `json:"direction"`
+	OrderType      string      `json:"orderType"`
+	Quantity       int         `json:"quantity"`
+	FilledQuantity int         `json:"filledQuantity"`
+	Price          float64     `json:"price"`
+	AveragePrice   float64     `json:"averagePrice"`
+	Status         string      `json:"status"`
+	PreviousStatus string      `json:"previousStatus,omitempty"`
+	Source         string      `json:"source"`
+	Reason         string      `json:"reason,omitempty"`
+	Timestamp      time.Time   `json:"timestamp"`
+}
+
 // MessageBroker is an interface for message brokers
 type MessageBroker interface {
 	// Publish publishes a message to a topic
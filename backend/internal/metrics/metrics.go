@@ -0,0 +1,392 @@
+// Package metrics exposes the platform's metrics to Prometheus: the requests of
+// HTTP handlers and gateway methods, broker client calls, database operations and
+// the tasks of work queues, each counted by result and timed, and the latency and
+// fill quality of the orders placed with each broker.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Results of the operations counted
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+// Results of the orders placed with brokers
+const (
+	orderAccepted = "accepted"
+	orderRejected = "rejected"
+	orderFailed   = "failed"
+)
+
+// Events of the orders of the platform, counted for business dashboards
+const (
+	OrderPlaced    = "placed"
+	OrderFilled    = "filled"
+	OrderRejected  = "rejected"
+	OrderCancelled = "cancelled"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by route, method and status code.",
+	}, []string{"route", "method", "status"})
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	gatewayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_requests_total",
+		Help: "Requests to the API gateway, by method.",
+	}, []string{"method"})
+	gatewayRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_request_duration_seconds",
+		Help:    "Time taken by API gateway methods, by method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+	gatewayErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_errors_total",
+		Help: "Errors returned by the API gateway, by category.",
+	}, []string{"category"})
+
+	brokerCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "broker_calls_total",
+		Help: "Calls to broker clients, by broker, operation and result.",
+	}, []string{"broker", "operation", "result"})
+	brokerCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "broker_call_duration_seconds",
+		Help:    "Time taken by calls to broker clients, by broker and operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"broker", "operation"})
+
+	brokerOrders = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "broker_orders_total",
+		Help: "Orders placed with brokers, by broker and result: accepted, rejected or failed.",
+	}, []string{"broker", "result"})
+	brokerOrderLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "broker_order_latency_seconds",
+		Help:    "Round trip time of placing orders with brokers, by broker.",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"broker"})
+	brokerOrderQuantity = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "broker_order_quantity_total",
+		Help: "Quantity of the orders brokers finished with, by broker and whether requested or filled.",
+	}, []string{"broker", "quantity"})
+	brokerSlippage = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "broker_slippage_ratio",
+		Help:    "Slippage of fills from the order price as a fraction of it, by broker. Negative slippage is price improvement.",
+		Buckets: []float64{-.005, -.001, -.0005, 0, .0005, .001, .0025, .005, .01},
+	}, []string{"broker"})
+
+	dbOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_operations_total",
+		Help: "Database operations, by operation and result.",
+	}, []string{"operation", "result"})
+	dbOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_operation_duration_seconds",
+		Help:    "Time taken by database operations, by operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+	slowQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_slow_queries_total",
+		Help: "Queries slower than the slow query threshold, by database system, operation and collection or table.",
+	}, []string{"system", "operation", "source"})
+
+	queueTasks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_tasks_total",
+		Help: "Tasks processed from work queues, by queue, task and result.",
+	}, []string{"queue", "task", "result"})
+	queueTaskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "queue_task_duration_seconds",
+		Help:    "Time taken to process the tasks of work queues, by queue and task.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"queue", "task"})
+	queueRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_rejected_tasks_total",
+		Help: "Tasks rejected by full work queues, by queue.",
+	}, []string{"queue"})
+
+	orders = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_total",
+		Help: "Orders of the platform, by event: placed, filled, rejected or cancelled.",
+	}, []string{"event"})
+	activeStrategies = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "active_strategies",
+		Help: "Strategies trading, with open positions in live portfolios.",
+	})
+	livePnL = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "live_pnl",
+		Help: "Realized and unrealized P&L of the live portfolios of each tenant.",
+	}, []string{"tenant"})
+	backtestsRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "backtests_running",
+		Help: "Backtests being run by workers.",
+	})
+)
+
+// businessTotals are the orders counted by event and the backtests running, for
+// the business metrics pipeline to read back
+var businessTotals = struct {
+	orders    map[string]int64
+	backtests int
+	mutex     sync.Mutex
+}{orders: make(map[string]int64)}
+
+func init() {
+	prometheus.MustRegister(
+		httpRequests, httpRequestDuration,
+		gatewayRequests, gatewayRequestDuration, gatewayErrors,
+		brokerCalls, brokerCallDuration,
+		brokerOrders, brokerOrderLatency, brokerOrderQuantity, brokerSlippage,
+		dbOperations, dbOperationDuration, slowQueries,
+		queueTasks, queueTaskDuration, queueRejections,
+		orders, activeStrategies, livePnL, backtestsRunning,
+	)
+}
+
+// Handler returns the handler of the /metrics endpoint, serving the metrics of
+// the default registry
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// result returns the result label of an operation
+func result(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultSuccess
+}
+
+// Middleware counts and times the requests of the routes of a router, labelled
+// by route template rather than path to keep the number of series bounded.
+// Hijacked connections, such as WebSocket connections, are not counted.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		if recorder.hijacked {
+			return
+		}
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder records the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	hijacked    bool
+}
+
+// WriteHeader records the status code before writing it
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Hijack lets handlers take over the connection, as WebSocket upgrades do
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	r.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Flush flushes buffered data to the client, for streamed responses
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// ObserveGatewayRequest counts and times a request to a method of the API gateway
+// started at start
+func ObserveGatewayRequest(method string, start time.Time) {
+	gatewayRequests.WithLabelValues(method).Inc()
+	gatewayRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// GatewayError counts an error returned by the API gateway
+func GatewayError(category string) {
+	gatewayErrors.WithLabelValues(category).Inc()
+}
+
+// ObserveBrokerCall counts and times a call to a broker client started at start
+func ObserveBrokerCall(broker, operation string, start time.Time, err error) {
+	brokerCalls.WithLabelValues(broker, operation, result(err)).Inc()
+	brokerCallDuration.WithLabelValues(broker, operation).Observe(time.Since(start).Seconds())
+}
+
+// ObserveBrokerOrder counts and times an order placed with a broker, err being the
+// error placing it and rejected whether the broker rejected it
+func ObserveBrokerOrder(broker string, latency time.Duration, rejected bool, err error) {
+	result := orderAccepted
+	switch {
+	case err != nil:
+		result = orderFailed
+	case rejected:
+		result = orderRejected
+	}
+	brokerOrders.WithLabelValues(broker, result).Inc()
+	brokerOrderLatency.WithLabelValues(broker).Observe(latency.Seconds())
+}
+
+// ObserveBrokerFill counts the quantity requested and filled of an order a broker
+// finished with
+func ObserveBrokerFill(broker string, quantity, filled int) {
+	brokerOrderQuantity.WithLabelValues(broker, "requested").Add(float64(quantity))
+	brokerOrderQuantity.WithLabelValues(broker, "filled").Add(float64(filled))
+}
+
+// ObserveBrokerSlippage records the slippage of the fill of an order, as a fraction
+// of the order price
+func ObserveBrokerSlippage(broker string, slippage float64) {
+	brokerSlippage.WithLabelValues(broker).Observe(slippage)
+}
+
+// ObserveDBOperation counts and times a database operation started at start
+func ObserveDBOperation(operation string, start time.Time, err error) {
+	observeDBOperation(operation, time.Since(start), err)
+}
+
+// observeDBOperation counts and times a database operation that took duration
+func observeDBOperation(operation string, duration time.Duration, err error) {
+	dbOperations.WithLabelValues(operation, result(err)).Inc()
+	dbOperationDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// ObserveSlowQuery counts a query slower than the slow query threshold
+func ObserveSlowQuery(system, operation, source string) {
+	slowQueries.WithLabelValues(system, operation, source).Inc()
+}
+
+// MongoMonitor returns a command monitor counting and timing the commands a
+// MongoDB client runs, by command name
+func MongoMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			observeDBOperation(e.CommandName, e.Duration, nil)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			observeDBOperation(e.CommandName, e.Duration, errors.New(e.Failure))
+		},
+	}
+}
+
+// ObserveQueueTask counts and times a task of a work queue started at start
+func ObserveQueueTask(queue, task string, start time.Time, err error) {
+	queueTasks.WithLabelValues(queue, task, result(err)).Inc()
+	queueTaskDuration.WithLabelValues(queue, task).Observe(time.Since(start).Seconds())
+}
+
+// QueueRejection counts a task rejected by a full work queue
+func QueueRejection(queue string) {
+	queueRejections.WithLabelValues(queue).Inc()
+}
+
+// RegisterQueueDepth reports the depth of a work queue, as depth returns it on
+// every scrape
+func RegisterQueueDepth(queue string, depth func() int) error {
+	return prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "queue_depth",
+		Help:        "Tasks waiting in work queues, by queue.",
+		ConstLabels: prometheus.Labels{"queue": queue},
+	}, func() float64 {
+		return float64(depth())
+	}))
+}
+
+// ObserveOrderEvent counts an event of an order: OrderPlaced, OrderFilled,
+// OrderRejected or OrderCancelled
+func ObserveOrderEvent(event string) {
+	orders.WithLabelValues(event).Inc()
+
+	businessTotals.mutex.Lock()
+	defer businessTotals.mutex.Unlock()
+	businessTotals.orders[event]++
+}
+
+// OrderEventTotals returns the orders counted since start, by event
+func OrderEventTotals() map[string]int64 {
+	businessTotals.mutex.Lock()
+	defer businessTotals.mutex.Unlock()
+
+	totals := make(map[string]int64, len(businessTotals.orders))
+	for event, total := range businessTotals.orders {
+		totals[event] = total
+	}
+	return totals
+}
+
+// SetActiveStrategies reports the number of strategies trading
+func SetActiveStrategies(count int) {
+	activeStrategies.Set(float64(count))
+}
+
+// SetLivePnL reports the P&L of the live portfolios of every tenant, tenants left
+// out no longer being reported
+func SetLivePnL(pnlByTenant map[string]float64) {
+	livePnL.Reset()
+	for tenant, pnl := range pnlByTenant {
+		livePnL.WithLabelValues(tenant).Set(pnl)
+	}
+}
+
+// BacktestStarted counts a backtest a worker started running
+func BacktestStarted() {
+	backtestsRunning.Inc()
+
+	businessTotals.mutex.Lock()
+	defer businessTotals.mutex.Unlock()
+	businessTotals.backtests++
+}
+
+// BacktestFinished counts a backtest a worker finished running, whatever its
+// result
+func BacktestFinished() {
+	backtestsRunning.Dec()
+
+	businessTotals.mutex.Lock()
+	defer businessTotals.mutex.Unlock()
+	businessTotals.backtests--
+}
+
+// BacktestsRunning returns the number of backtests being run by workers
+func BacktestsRunning() int {
+	businessTotals.mutex.Lock()
+	defer businessTotals.mutex.Unlock()
+	return businessTotals.backtests
+}
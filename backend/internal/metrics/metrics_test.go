@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	// Create router with a route and a route hijacking connections
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.HandleFunc("/api/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "order not found", http.StatusNotFound)
+	}).Methods("GET")
+	router.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if assert.NoError(t, err) {
+			conn.Close()
+		}
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// Requests are counted by route template
+	requests := httpRequests.WithLabelValues("/api/orders/{id}", "GET", "404")
+	before := testutil.ToFloat64(requests)
+	for _, id := range []string{"order1", "order2"} {
+		response, err := http.Get(server.URL + "/api/orders/" + id)
+		assert.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	}
+	assert.Equal(t, before+2, testutil.ToFloat64(requests))
+
+	// Hijacked connections are not
+	hijacked := httpRequests.WithLabelValues("/ws", "GET", "200")
+	_, err := http.Get(server.URL + "/ws")
+	assert.Error(t, err)
+	assert.Equal(t, float64(0), testutil.ToFloat64(hijacked))
+}
+
+func TestObserveBrokerCall(t *testing.T) {
+	succeeded := brokerCalls.WithLabelValues("ZERODHA", "PlaceOrder", resultSuccess)
+	failed := brokerCalls.WithLabelValues("ZERODHA", "PlaceOrder", resultError)
+	succeededBefore, failedBefore := testutil.ToFloat64(succeeded), testutil.ToFloat64(failed)
+
+	// Calls are counted by result
+	ObserveBrokerCall("ZERODHA", "PlaceOrder", time.Now(), nil)
+	ObserveBrokerCall("ZERODHA", "PlaceOrder", time.Now(), nil)
+	ObserveBrokerCall("ZERODHA", "PlaceOrder", time.Now(), errors.New("order rejected"))
+
+	assert.Equal(t, succeededBefore+2, testutil.ToFloat64(succeeded))
+	assert.Equal(t, failedBefore+1, testutil.ToFloat64(failed))
+}
+
+func TestObserveBrokerOrder(t *testing.T) {
+	accepted := brokerOrders.WithLabelValues("XTS", orderAccepted)
+	rejected := brokerOrders.WithLabelValues("XTS", orderRejected)
+	failed := brokerOrders.WithLabelValues("XTS", orderFailed)
+	acceptedBefore, rejectedBefore, failedBefore := testutil.ToFloat64(accepted), testutil.ToFloat64(rejected), testutil.ToFloat64(failed)
+
+	// Orders are counted by result, failures to place them taking precedence
+	ObserveBrokerOrder("XTS", 40*time.Millisecond, false, nil)
+	ObserveBrokerOrder("XTS", 60*time.Millisecond, true, nil)
+	ObserveBrokerOrder("XTS", time.Second, true, errors.New("connection reset"))
+
+	assert.Equal(t, acceptedBefore+1, testutil.ToFloat64(accepted))
+	assert.Equal(t, rejectedBefore+1, testutil.ToFloat64(rejected))
+	assert.Equal(t, failedBefore+1, testutil.ToFloat64(failed))
+
+	// Fills are counted by quantity
+	requested := brokerOrderQuantity.WithLabelValues("XTS", "requested")
+	filled := brokerOrderQuantity.WithLabelValues("XTS", "filled")
+	requestedBefore, filledBefore := testutil.ToFloat64(requested), testutil.ToFloat64(filled)
+
+	ObserveBrokerFill("XTS", 100, 75)
+
+	assert.Equal(t, requestedBefore+100, testutil.ToFloat64(requested))
+	assert.Equal(t, filledBefore+75, testutil.ToFloat64(filled))
+}
+
+func TestBusinessMetrics(t *testing.T) {
+	filledBefore := OrderEventTotals()[OrderFilled]
+	ObserveOrderEvent(OrderFilled)
+	ObserveOrderEvent(OrderFilled)
+	assert.Equal(t, filledBefore+2, OrderEventTotals()[OrderFilled])
+	assert.Equal(t, float64(filledBefore+2), testutil.ToFloat64(orders.WithLabelValues(OrderFilled)))
+
+	// Tenants left out are no longer reported
+	SetLivePnL(map[string]float64{"user1": 1250.5, "user2": -300})
+	SetLivePnL(map[string]float64{"user1": 1400})
+	assert.Equal(t, 1, testutil.CollectAndCount(livePnL))
+	assert.Equal(t, 1400.0, testutil.ToFloat64(livePnL.WithLabelValues("user1")))
+
+	BacktestStarted()
+	BacktestStarted()
+	BacktestFinished()
+	assert.Equal(t, 1, BacktestsRunning())
+	assert.Equal(t, 1.0, testutil.ToFloat64(backtestsRunning))
+	BacktestFinished()
+}
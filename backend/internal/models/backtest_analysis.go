@@ -0,0 +1,386 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// WalkForwardConfig defines how a backtest date range is split into rolling
+// in-sample (training) and out-of-sample (test) windows
+type WalkForwardConfig struct {
+	TrainingDays int  `json:"trainingDays" db:"training_days"`
+	TestDays     int  `json:"testDays" db:"test_days"`
+	StepDays     int  `json:"stepDays" db:"step_days"` // Defaults to TestDays when zero
+	Anchored     bool `json:"anchored" db:"anchored"`  // Training windows always start at the range start
+}
+
+// Validate validates the walk-forward configuration
+func (c *WalkForwardConfig) Validate() error {
+	if c.TrainingDays <= 0 {
+		return errors.New("training days must be greater than zero")
+	}
+
+	if c.TestDays <= 0 {
+		return errors.New("test days must be greater than zero")
+	}
+
+	if c.StepDays < 0 {
+		return errors.New("step days cannot be negative")
+	}
+
+	return nil
+}
+
+// WalkForwardWindow represents a single train/test window of a walk-forward analysis
+type WalkForwardWindow struct {
+	Index              int                    `json:"index"`
+	TrainingStart      time.Time              `json:"trainingStart"`
+	TrainingEnd        time.Time              `json:"trainingEnd"`
+	TestStart          time.Time              `json:"testStart"`
+	TestEnd            time.Time              `json:"testEnd"`
+	OptimalParameters  map[string]interface{} `json:"optimalParameters"`
+	InSampleMetric     float64                `json:"inSampleMetric"`
+	OutOfSampleMetric  float64                `json:"outOfSampleMetric"`
+	OutOfSampleMetrics map[string]float64     `json:"outOfSampleMetrics"`
+}
+
+// WalkForwardResult represents the outcome of a walk-forward analysis
+type WalkForwardResult struct {
+	StrategyID            string              `json:"strategyId"`
+	OptimizationMetric    string              `json:"optimizationMetric"`
+	Config                WalkForwardConfig   `json:"config"`
+	Windows               []WalkForwardWindow `json:"windows"`
+	AggregateOutOfSample  map[string]float64  `json:"aggregateOutOfSample"`
+	WalkForwardEfficiency float64             `json:"walkForwardEfficiency"` // Mean OOS metric / mean IS metric
+	CreatedAt             time.Time           `json:"createdAt"`
+}
+
+// ConfidenceInterval represents percentile bounds of a simulated metric distribution
+type ConfidenceInterval struct {
+	P5     float64 `json:"p5"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+}
+
+// MonteCarloResult summarizes bootstrapped resamples of a backtest's return sequence
+type MonteCarloResult struct {
+	Simulations   int                `json:"simulations"`
+	MaxDrawdown   ConfidenceInterval `json:"maxDrawdown"` // Percent of peak equity
+	CAGR          ConfidenceInterval `json:"cagr"`        // Percent per year
+	FinalEquity   ConfidenceInterval `json:"finalEquity"`
+	RiskOfRuin    float64            `json:"riskOfRuin"`    // Fraction of simulations hitting the ruin threshold
+	RuinThreshold float64            `json:"ruinThreshold"` // Fraction of initial equity considered ruin
+}
+
+// Optimization methods supported by the backtest optimizer
+const (
+	OptimizationMethodGrid     = "GRID"
+	OptimizationMethodGenetic  = "GENETIC"
+	OptimizationMethodBayesian = "BAYESIAN"
+)
+
+// OptimizationConfig configures a strategy parameter optimization run
+type OptimizationConfig struct {
+	Method              string    `json:"method"`              // GRID, GENETIC or BAYESIAN
+	PopulationSize      int       `json:"populationSize"`      // GENETIC only
+	Generations         int       `json:"generations"`         // GENETIC only
+	MutationRate        float64   `json:"mutationRate"`        // GENETIC only, between 0 and 1
+	Iterations          int       `json:"iterations"`          // BAYESIAN only, evaluations after the initial samples
+	InitialSamples      int       `json:"initialSamples"`      // BAYESIAN only
+	EarlyStoppingRounds int       `json:"earlyStoppingRounds"` // Stop after this many rounds without improvement, 0 disables
+	Seed                int64     `json:"seed"`
+	StartDate           time.Time `json:"startDate"`
+	EndDate             time.Time `json:"endDate"`
+}
+
+// Validate validates the optimization configuration
+func (c *OptimizationConfig) Validate() error {
+	switch c.Method {
+	case OptimizationMethodGrid:
+	case OptimizationMethodGenetic:
+		if c.PopulationSize < 2 {
+			return errors.New("population size must be at least 2")
+		}
+		if c.Generations <= 0 {
+			return errors.New("generations must be greater than zero")
+		}
+		if c.MutationRate < 0 || c.MutationRate > 1 {
+			return errors.New("mutation rate must be between 0 and 1")
+		}
+	case OptimizationMethodBayesian:
+		if c.InitialSamples <= 0 {
+			return errors.New("initial samples must be greater than zero")
+		}
+		if c.Iterations <= 0 {
+			return errors.New("iterations must be greater than zero")
+		}
+	default:
+		return errors.New("invalid optimization method")
+	}
+
+	if c.EarlyStoppingRounds < 0 {
+		return errors.New("early stopping rounds cannot be negative")
+	}
+
+	if !c.StartDate.IsZero() && !c.EndDate.IsZero() && !c.StartDate.Before(c.EndDate) {
+		return errors.New("start date must be before end date")
+	}
+
+	return nil
+}
+
+// OptimizationTrial represents a single evaluated parameter set
+type OptimizationTrial struct {
+	Parameters  map[string]interface{} `json:"parameters"`
+	MetricValue float64                `json:"metricValue"`
+}
+
+// ParameterStability reports how sensitive the optimal result is to a single parameter.
+// Large degradation when moving to neighbouring values is a sign of overfitting.
+type ParameterStability struct {
+	Parameter             string  `json:"parameter"`
+	AverageNeighborMetric float64 `json:"averageNeighborMetric"`
+	RelativeDegradation   float64 `json:"relativeDegradation"`
+	IsStable              bool    `json:"isStable"`
+}
+
+// OptimizationResult represents the outcome of a strategy parameter optimization
+type OptimizationResult struct {
+	StrategyID         string                 `json:"strategyId"`
+	Method             string                 `json:"method"`
+	OptimizationMetric string                 `json:"optimizationMetric"`
+	OptimalParameters  map[string]interface{} `json:"optimalParameters"`
+	MetricValue        float64                `json:"metricValue"`
+	Trials             []OptimizationTrial    `json:"trials"`
+	StoppedEarly       bool                   `json:"stoppedEarly"`
+	Stability          []ParameterStability   `json:"stability"`
+	IsLikelyOverfit    bool                   `json:"isLikelyOverfit"`
+	TimeElapsed        time.Duration          `json:"timeElapsed"`
+}
+
+// HoldoutValidationResult compares a strategy optimized on the training window of a
+// session with how the same parameters perform on the holdout window reserved after it
+type HoldoutValidationResult struct {
+	StrategyID         string              `json:"strategyId"`
+	OptimizationMetric string              `json:"optimizationMetric"`
+	TrainingStart      time.Time           `json:"trainingStart"`
+	TrainingEnd        time.Time           `json:"trainingEnd"`
+	HoldoutStart       time.Time           `json:"holdoutStart"`
+	HoldoutEnd         time.Time           `json:"holdoutEnd"`
+	Optimization       *OptimizationResult `json:"optimization"` // Run on the training window only
+	InSampleMetrics    map[string]float64  `json:"inSampleMetrics"`
+	OutOfSampleMetrics map[string]float64  `json:"outOfSampleMetrics"`
+	Degradation        map[string]float64  `json:"degradation"`       // Relative change from in-sample to out-of-sample per metric, positive when worse
+	MetricDegradation  float64             `json:"metricDegradation"` // Degradation of the optimization metric
+	IsLikelyOverfit    bool                `json:"isLikelyOverfit"`
+	CreatedAt          time.Time           `json:"createdAt"`
+}
+
+// BacktestJob is a unit of work for a backtest worker: one parameter set run over
+// one shard of symbols
+type BacktestJob struct {
+	ID         string                 `json:"id"`
+	BatchID    string                 `json:"batchId"`
+	StrategyID string                 `json:"strategyId"`
+	Symbols    []string               `json:"symbols"`
+	Parameters map[string]interface{} `json:"parameters"`
+	StartDate  time.Time              `json:"startDate"`
+	EndDate    time.Time              `json:"endDate"`
+	CreatedAt  time.Time              `json:"createdAt"`
+}
+
+// BacktestJobResult is the outcome of a BacktestJob reported back by a worker
+type BacktestJobResult struct {
+	JobID       string                 `json:"jobId"`
+	BatchID     string                 `json:"batchId"`
+	WorkerID    string                 `json:"workerId"`
+	Symbols     []string               `json:"symbols"`
+	Parameters  map[string]interface{} `json:"parameters"`
+	Metrics     map[string]float64     `json:"metrics"`
+	Error       string                 `json:"error,omitempty"`
+	CompletedAt time.Time              `json:"completedAt"`
+}
+
+// Priority tiers of scheduled backtests, served highest first
+const (
+	BacktestPriorityHigh   = "HIGH"
+	BacktestPriorityNormal = "NORMAL"
+	BacktestPriorityLow    = "LOW"
+)
+
+// States of a scheduled backtest
+const (
+	ScheduledBacktestQueued    = "QUEUED"
+	ScheduledBacktestRunning   = "RUNNING"
+	ScheduledBacktestCompleted = "COMPLETED"
+	ScheduledBacktestFailed    = "FAILED"
+	ScheduledBacktestCancelled = "CANCELLED"
+)
+
+// ScheduledBacktest is a strategy backtest submitted to the backtest scheduler
+type ScheduledBacktest struct {
+	ID             string                  `json:"id"`
+	UserID         string                  `json:"userId"`
+	Priority       string                  `json:"priority"`
+	Status         string                  `json:"status"`
+	Session        BacktestSession         `json:"session"`
+	MarketSettings *MarketSettings         `json:"marketSettings,omitempty"`
+	Report         *StrategyBacktestReport `json:"report,omitempty"`
+	Error          string                  `json:"error,omitempty"`
+	SubmittedAt    time.Time               `json:"submittedAt"`
+	StartedAt      *time.Time              `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time              `json:"finishedAt,omitempty"`
+}
+
+// StrategyBacktestReport is the outcome of running a Go strategy through the backtester
+type StrategyBacktestReport struct {
+	Results       []BacktestResult   `json:"results"`
+	Trades        []SimulationOrder  `json:"trades"`
+	FinalBalance  float64            `json:"finalBalance"`
+	RealizedPnL   float64            `json:"realizedPnL"`
+	GrossPnL      float64            `json:"grossPnL"`
+	NetPnL        float64            `json:"netPnL"`
+	TotalCharges  float64            `json:"totalCharges"`
+	TotalSlippage float64            `json:"totalSlippage"`
+	LegSlippage   map[string]float64 `json:"legSlippage"`
+	TotalTrades   int                `json:"totalTrades"`
+	WinningTrades int                `json:"winningTrades"`
+	LosingTrades  int                `json:"losingTrades"`
+
+	IntradayMargin *IntradayMarginReport `json:"intradayMargin,omitempty"` // Set when intraday margin was modeled
+}
+
+// IntradayMarginConfig models the margin a broker blocks for leveraged intraday (MIS)
+// trading, margin calls when losses eat into it, and the forced square-off of MIS
+// positions before the close. Orders without a product type are treated as MIS.
+type IntradayMarginConfig struct {
+	MarginRate        float64            `json:"marginRate"`              // Margin as a fraction of notional for carry-forward positions, defaults to 1
+	MISLeverage       float64            `json:"misLeverage"`             // MIS positions need 1/MISLeverage of the carry-forward margin, defaults to 1
+	SymbolMargins     map[string]float64 `json:"symbolMargins,omitempty"` // Carry-forward margin per unit held, e.g. SPAN plus exposure for short options
+	MaintenanceMargin float64            `json:"maintenanceMargin"`       // Margin call when equity falls below this fraction of margin used, 0 disables
+	SquareOffTime     string             `json:"squareOffTime"`           // HH:MM:SS at which open MIS positions are squared off, empty disables
+	Timezone          string             `json:"timezone,omitempty"`      // Location of SquareOffTime, defaults to UTC
+}
+
+// Validate validates the intraday margin configuration
+func (c *IntradayMarginConfig) Validate() error {
+	if c.MarginRate < 0 || c.MISLeverage < 0 || c.MaintenanceMargin < 0 {
+		return errors.New("margin rate, leverage and maintenance margin cannot be negative")
+	}
+
+	if c.MaintenanceMargin > 1 {
+		return errors.New("maintenance margin cannot exceed 1")
+	}
+
+	for symbol, margin := range c.SymbolMargins {
+		if margin < 0 {
+			return errors.New("margin cannot be negative for " + symbol)
+		}
+	}
+
+	if c.SquareOffTime != "" {
+		if _, err := time.Parse("15:04:05", c.SquareOffTime); err != nil {
+			return errors.New("square-off time must be in HH:MM:SS format")
+		}
+	}
+
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return errors.New("invalid timezone: " + c.Timezone)
+	}
+
+	return nil
+}
+
+// IntradayMarginReport summarizes how margin constrained a backtest
+type IntradayMarginReport struct {
+	MarginRejections int     `json:"marginRejections"` // Orders rejected for insufficient margin
+	MarginCalls      int     `json:"marginCalls"`      // Times every position was liquidated for breaching maintenance margin
+	SquareOffs       int     `json:"squareOffs"`       // MIS positions squared off at the square-off time
+	PeakMarginUsed   float64 `json:"peakMarginUsed"`
+}
+
+// TCALegReport compares the costs a backtest assumed for one symbol with the
+// costs realized when the strategy traded it live
+type TCALegReport struct {
+	Symbol                  string  `json:"symbol"`
+	BacktestQuantity        int     `json:"backtestQuantity"`
+	LiveQuantity            int     `json:"liveQuantity"`
+	LiveOrders              int     `json:"liveOrders"`
+	AssumedSlippagePerUnit  float64 `json:"assumedSlippagePerUnit"`
+	RealizedSlippagePerUnit float64 `json:"realizedSlippagePerUnit"`
+	AssumedChargesRate      float64 `json:"assumedChargesRate"`  // Charges as a fraction of traded notional
+	RealizedChargesRate     float64 `json:"realizedChargesRate"` // Charges as a fraction of traded notional
+	AssumedCost             float64 `json:"assumedCost"`         // Backtest cost assumptions applied to the live volume
+	RealizedCost            float64 `json:"realizedCost"`
+	CostDifference          float64 `json:"costDifference"` // Realized minus assumed, positive when live trading cost more
+}
+
+// TCAReport is a transaction cost analysis of a strategy run live against its backtest
+type TCAReport struct {
+	BacktestSessionID    string         `json:"backtestSessionId"`
+	Legs                 []TCALegReport `json:"legs"`
+	TotalAssumedCost     float64        `json:"totalAssumedCost"`
+	TotalRealizedCost    float64        `json:"totalRealizedCost"`
+	TotalCostDifference  float64        `json:"totalCostDifference"`
+	UnmatchedLiveSymbols []string       `json:"unmatchedLiveSymbols,omitempty"` // Traded live but never in the backtest
+	GeneratedAt          time.Time      `json:"generatedAt"`
+}
+
+// PortfolioBacktestLeg is one strategy in a portfolio backtest, trading its own
+// symbols out of the portfolio's shared capital
+type PortfolioBacktestLeg struct {
+	Name         string                 `json:"name"`
+	StrategyName string                 `json:"strategyName"` // Registered Go strategy
+	Symbols      []string               `json:"symbols"`
+	Parameters   map[string]interface{} `json:"parameters"`
+}
+
+// PortfolioBacktestConfig defines the shared margin model and portfolio-level stop rules
+type PortfolioBacktestConfig struct {
+	MarginRate     float64           `json:"marginRate"`     // Margin as a fraction of notional, 0 disables margin checks
+	HedgeOffset    float64           `json:"hedgeOffset"`    // Fraction of margin waived on offsetting positions in the same underlying
+	Underlyings    map[string]string `json:"underlyings"`    // Symbol to underlying for cross-margining, defaults to the symbol itself
+	MaxDrawdownPct float64           `json:"maxDrawdownPct"` // Flatten and halt when drawdown from peak equity reaches this percentage
+	MaxDailyLoss   float64           `json:"maxDailyLoss"`   // Flatten and halt when the day's loss reaches this amount
+	ProfitTarget   float64           `json:"profitTarget"`   // Flatten and halt when total profit reaches this amount
+}
+
+// Validate validates the portfolio backtest configuration
+func (c *PortfolioBacktestConfig) Validate() error {
+	if c.MarginRate < 0 {
+		return errors.New("margin rate cannot be negative")
+	}
+
+	if c.HedgeOffset < 0 || c.HedgeOffset > 1 {
+		return errors.New("hedge offset must be between 0 and 1")
+	}
+
+	if c.MaxDrawdownPct < 0 || c.MaxDailyLoss < 0 || c.ProfitTarget < 0 {
+		return errors.New("stop rules cannot be negative")
+	}
+
+	return nil
+}
+
+// PortfolioBacktestReport is the outcome of a portfolio backtest
+type PortfolioBacktestReport struct {
+	StrategyBacktestReport
+	StrategyOrders   map[string]int `json:"strategyOrders"` // Filled orders per leg
+	MarginRejections int            `json:"marginRejections"`
+	PeakMarginUsed   float64        `json:"peakMarginUsed"`
+	Halted           bool           `json:"halted"`
+	HaltReason       string         `json:"haltReason,omitempty"`
+	HaltedAt         *time.Time     `json:"haltedAt,omitempty"`
+}
+
+// CustomDataset describes user-supplied market data uploaded for backtesting under a virtual symbol
+type CustomDataset struct {
+	ID                  string    `json:"id"`
+	SimulationAccountID string    `json:"simulationAccountId"`
+	Symbol              string    `json:"symbol"`    // Virtual symbol to use in backtest sessions
+	Timeframe           string    `json:"timeframe"` // "tick" for tick data
+	Rows                int       `json:"rows"`
+	StartDate           time.Time `json:"startDate"`
+	EndDate             time.Time `json:"endDate"`
+	CreatedAt           time.Time `json:"createdAt"`
+}
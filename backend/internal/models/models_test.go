@@ -130,6 +130,68 @@ func TestOrderValidation(t *testing.T) {
 	}
 }
 
+func TestOrderStateMachine(t *testing.T) {
+	order := &Order{Quantity: 10, Status: OrderStatusNew}
+
+	// Test the valid path of an order through its statuses
+	steps := []struct {
+		status         OrderStatus
+		filledQuantity int
+	}{
+		{OrderStatusPending, 0},
+		{OrderStatusPartial, 3},
+		{OrderStatusPartial, 7},
+		{OrderStatusFilled, 10},
+	}
+	for _, step := range steps {
+		if err := order.TransitionTo(step.status, step.filledQuantity); err != nil {
+			t.Errorf("Expected transition to %s to succeed, got %v", step.status, err)
+		}
+	}
+	if order.Status != OrderStatusExecuted || order.FilledQuantity != 10 || !order.IsFinal() {
+		t.Errorf("Expected a final filled order, got %s with %d filled", order.Status, order.FilledQuantity)
+	}
+
+	// Test invalid transitions
+	tests := []struct {
+		name           string
+		from           OrderStatus
+		to             OrderStatus
+		filledQuantity int
+	}{
+		{"New to Filled", OrderStatusNew, OrderStatusExecuted, 10},
+		{"New to Cancelled", OrderStatusNew, OrderStatusCancelled, 0},
+		{"Partial to Rejected", OrderStatusPartial, OrderStatusRejected, 5},
+		{"Partial to Pending", OrderStatusPartial, OrderStatusPending, 5},
+		{"Partial without fill", OrderStatusPartial, OrderStatusPartial, 5},
+		{"Partial filling everything", OrderStatusPending, OrderStatusPartial, 10},
+		{"Filled to Cancelled", OrderStatusExecuted, OrderStatusCancelled, 10},
+		{"Cancelled to Pending", OrderStatusCancelled, OrderStatusPending, 0},
+		{"Rejected to Pending", OrderStatusRejected, OrderStatusPending, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testOrder := &Order{Quantity: 10, Status: tc.from}
+			if tc.from == OrderStatusPartial {
+				testOrder.FilledQuantity = 5
+			}
+			if err := testOrder.TransitionTo(tc.to, tc.filledQuantity); err == nil {
+				t.Errorf("Expected transition error for %s, but got none", tc.name)
+			}
+			if testOrder.Status != tc.from {
+				t.Errorf("Expected status to stay %s, got %s", tc.from, testOrder.Status)
+			}
+		})
+	}
+
+	// Test cancelling keeps what has filled
+	order = &Order{Quantity: 10, FilledQuantity: 4, Status: OrderStatusPartial}
+	if err := order.TransitionTo(OrderStatusCancelled, 0); err != nil || order.FilledQuantity != 4 {
+		t.Errorf("Expected cancellation to keep 4 filled, got %d: %v", order.FilledQuantity, err)
+	}
+}
+
 func TestPositionValidation(t *testing.T) {
 	// Test valid position
 	validPosition := &Position{
@@ -836,6 +898,12 @@ func TestPortfolioValidation(t *testing.T) {
 				p.StrikeSelection = "INVALID"
 			},
 		},
+		{
+			name: "Invalid PredefinedStrategy",
+			modifyPortfolio: func(p *Portfolio) {
+				p.PredefinedStrategy = "INVALID"
+			},
+		},
 		{
 			name: "Invalid UnderlyingRef",
 			modifyPortfolio: func(p *Portfolio) {
@@ -1335,3 +1403,155 @@ func TestCalculationMethods(t *testing.T) {
 		t.Errorf("Expected UnrealizedPnL to be 2000, got %f", leg.UnrealizedPnL)
 	}
 }
+
+func TestPredefinedStrategies(t *testing.T) {
+	expiry := time.Now().AddDate(0, 0, 7)
+	newPortfolio := func(strategy string, params PredefinedParams) *Portfolio {
+		return &Portfolio{
+			ID:                 "portfolio123",
+			Symbol:             "NIFTY",
+			Exchange:           "NSE",
+			Expiry:             expiry,
+			DefaultLots:        2,
+			PredefinedStrategy: strategy,
+			PredefinedParams:   &params,
+			StrikeSelection:    StrikeSelectionModeRelative,
+			StrikeStep:         50,
+			EntryOrderType:     OrderTypeMarket,
+			ExitOrderType:      OrderTypeMarket,
+		}
+	}
+
+	// legShape is the direction, option type, strike and lots of a leg
+	type legShape struct {
+		buySell    OrderDirection
+		optionType OptionType
+		strike     float64
+		lots       int
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		params   PredefinedParams
+		legs     []legShape
+	}{
+		{
+			name:     "Straddle",
+			strategy: PredefinedStrategyStraddle,
+			params:   PredefinedParams{LotSize: 50},
+			legs: []legShape{
+				{OrderDirectionSell, OptionTypeCall, 18000, 2},
+				{OrderDirectionSell, OptionTypePut, 18000, 2},
+			},
+		},
+		{
+			name:     "Strangle",
+			strategy: "strangle",
+			params:   PredefinedParams{Direction: OrderDirectionBuy, ATMOffset: 1, Width: 2, Lots: 1, LotSize: 50},
+			legs: []legShape{
+				{OrderDirectionBuy, OptionTypeCall, 18150, 1},
+				{OrderDirectionBuy, OptionTypePut, 17950, 1},
+			},
+		},
+		{
+			name:     "Iron condor",
+			strategy: PredefinedStrategyIronCondor,
+			params:   PredefinedParams{Width: 4, Wings: 2, LotSize: 50},
+			legs: []legShape{
+				{OrderDirectionSell, OptionTypeCall, 18200, 2},
+				{OrderDirectionSell, OptionTypePut, 17800, 2},
+				{OrderDirectionBuy, OptionTypeCall, 18300, 2},
+				{OrderDirectionBuy, OptionTypePut, 17700, 2},
+			},
+		},
+		{
+			name:     "Butterfly",
+			strategy: PredefinedStrategyButterfly,
+			params:   PredefinedParams{OptionType: OptionTypePut, Wings: 3, LotSize: 50},
+			legs: []legShape{
+				{OrderDirectionBuy, OptionTypePut, 17850, 2},
+				{OrderDirectionSell, OptionTypePut, 18000, 4},
+				{OrderDirectionBuy, OptionTypePut, 18150, 2},
+			},
+		},
+		{
+			name:     "Ratio spread",
+			strategy: PredefinedStrategyRatioSpread,
+			params:   PredefinedParams{OptionType: OptionTypePut, Wings: 2, Ratio: 3, Lots: 1, LotSize: 50},
+			legs: []legShape{
+				{OrderDirectionBuy, OptionTypePut, 18000, 1},
+				{OrderDirectionSell, OptionTypePut, 17900, 3},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			portfolio := newPortfolio(tc.strategy, tc.params)
+			// ATM is the strike nearest to the underlying
+			legs, err := portfolio.BuildPredefinedLegs(18012.5)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(legs) != len(tc.legs) {
+				t.Fatalf("Expected %d legs, got %d", len(tc.legs), len(legs))
+			}
+			for i, want := range tc.legs {
+				leg := legs[i]
+				if leg.BuySell != string(want.buySell) || leg.OptionType != string(want.optionType) || leg.StrikePrice != want.strike || leg.Lots != want.lots {
+					t.Errorf("Expected leg %d to be %s %s %.0f x%d, got %s %s %.0f x%d", i+1,
+						want.buySell, want.optionType, want.strike, want.lots, leg.BuySell, leg.OptionType, leg.StrikePrice, leg.Lots)
+				}
+				if leg.ID != i+1 || leg.PortfolioID != portfolio.ID || leg.Quantity != want.lots*50 {
+					t.Errorf("Expected leg %d of portfolio %s with quantity %d, got leg %d of %s with %d", i+1, portfolio.ID, want.lots*50, leg.ID, leg.PortfolioID, leg.Quantity)
+				}
+				// Relative legs select their strike again from ATM at entry
+				if leg.StrikeSelectionType != StrikeSelectionTypeATMOffset || leg.StrikeSelectionValue != (want.strike-18000)/50 {
+					t.Errorf("Expected leg %d to be %.0f strikes from ATM, got %s %.0f", i+1, (want.strike-18000)/50, leg.StrikeSelectionType, leg.StrikeSelectionValue)
+				}
+				if err := leg.Validate(); err != nil {
+					t.Errorf("Leg %d failed validation: %v", i+1, err)
+				}
+			}
+		})
+	}
+
+	// Calendars buy the far month against the near month
+	portfolio := newPortfolio(PredefinedStrategyCalendar, PredefinedParams{LotSize: 50, FarExpiry: expiry.AddDate(0, 0, 28)})
+	portfolio.StrikeSelection = StrikeSelectionModeNormal
+	legs, err := portfolio.BuildPredefinedLegs(18000)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(legs) != 2 || legs[0].BuySell != string(OrderDirectionSell) || !legs[0].Expiry.Equal(expiry) ||
+		legs[1].BuySell != string(OrderDirectionBuy) || !legs[1].Expiry.Equal(expiry.AddDate(0, 0, 28)) {
+		t.Errorf("Expected a calendar selling the near month and buying the far month, got %+v", legs)
+	}
+	if legs[0].StrikeSelectionType != "" {
+		t.Errorf("Expected normal legs to select fixed strikes, got %s", legs[0].StrikeSelectionType)
+	}
+
+	invalid := []struct {
+		name      string
+		portfolio *Portfolio
+		price     float64
+	}{
+		{"Custom", newPortfolio(PredefinedStrategyCustom, PredefinedParams{LotSize: 50}), 18000},
+		{"Unknown strategy", newPortfolio("JADE_LIZARD", PredefinedParams{LotSize: 50}), 18000},
+		{"Missing underlying price", newPortfolio(PredefinedStrategyStraddle, PredefinedParams{LotSize: 50}), 0},
+		{"Missing lot size", newPortfolio(PredefinedStrategyStraddle, PredefinedParams{}), 18000},
+		{"Strangle without width", newPortfolio(PredefinedStrategyStrangle, PredefinedParams{LotSize: 50}), 18000},
+		{"Iron condor without wings", newPortfolio(PredefinedStrategyIronCondor, PredefinedParams{Width: 2, LotSize: 50}), 18000},
+		{"Calendar without far expiry", newPortfolio(PredefinedStrategyCalendar, PredefinedParams{LotSize: 50}), 18000},
+		{"Ratio of one", newPortfolio(PredefinedStrategyRatioSpread, PredefinedParams{Wings: 2, Ratio: 1, LotSize: 50}), 18000},
+		{"Strike below zero", newPortfolio(PredefinedStrategyStrangle, PredefinedParams{Width: 10, LotSize: 50}), 400},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.portfolio.BuildPredefinedLegs(tc.price); err == nil {
+				t.Errorf("Expected error for %s, but got none", tc.name)
+			}
+		})
+	}
+}
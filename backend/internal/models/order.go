@@ -2,6 +2,7 @@ package models
 
 import (
         "errors"
+        "fmt"
         "time"
 )
 
@@ -9,13 +10,47 @@ import (
 type OrderStatus string
 
 const (
+        OrderStatusNew       OrderStatus = "NEW"
         OrderStatusPending   OrderStatus = "PENDING"
         OrderStatusExecuted  OrderStatus = "EXECUTED"
         OrderStatusCancelled OrderStatus = "CANCELLED"
         OrderStatusRejected  OrderStatus = "REJECTED"
         OrderStatusPartial   OrderStatus = "PARTIAL"
+
+        // OrderStatusFilled is the status of a completely filled order
+        OrderStatusFilled = OrderStatusExecuted
+)
+
+// orderTransitions lists the statuses an order can move to from each status. A new
+// order is accepted or rejected, a pending order fills, partially or completely, or
+// ends without filling, and filled, cancelled and rejected orders are final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+        OrderStatusNew:     {OrderStatusPending, OrderStatusRejected},
+        OrderStatusPending: {OrderStatusPartial, OrderStatusExecuted, OrderStatusCancelled, OrderStatusRejected},
+        OrderStatusPartial: {OrderStatusPartial, OrderStatusExecuted, OrderStatusCancelled},
+}
+
+// TransitionSource represents who moved an order to a new status
+type TransitionSource string
+
+const (
+        TransitionSourceUser   TransitionSource = "USER"
+        TransitionSourceBroker TransitionSource = "BROKER"
+        TransitionSourceSystem TransitionSource = "SYSTEM"
 )
 
+// OrderTransition records an order moving from one status to another
+type OrderTransition struct {
+        ID             string           `json:"id" bson:"_id,omitempty"`
+        OrderID        string           `json:"orderId" bson:"orderId"`
+        FromStatus     OrderStatus      `json:"fromStatus,omitempty" bson:"fromStatus,omitempty"` // Empty for the order's creation
+        ToStatus       OrderStatus      `json:"toStatus" bson:"toStatus"`
+        Source         TransitionSource `json:"source" bson:"source"`
+        FilledQuantity int              `json:"filledQuantity" bson:"filledQuantity"`
+        Reason         string           `json:"reason,omitempty" bson:"reason,omitempty"`
+        Timestamp      time.Time        `json:"timestamp" bson:"timestamp"`
+}
+
 // OrderType represents the type of order
 type OrderType string
 
@@ -79,6 +114,8 @@ type Order struct {
         Expiry          time.Time       `json:"expiry,omitempty" bson:"expiry,omitempty"`
         PortfolioID     string          `json:"portfolioId,omitempty" bson:"portfolioId,omitempty"`
         StrategyID      string          `json:"strategyId,omitempty" bson:"strategyId,omitempty"`
+        StrategyVersion int             `json:"strategyVersion,omitempty" bson:"strategyVersion,omitempty"`   // Configuration version of the strategy that placed it
+        PortfolioVersion int            `json:"portfolioVersion,omitempty" bson:"portfolioVersion,omitempty"` // Configuration version of the portfolio that placed it
         LegID           int             `json:"legId,omitempty" bson:"legId,omitempty"`
         ParentOrderID   string          `json:"parentOrderId,omitempty" bson:"parentOrderId,omitempty"`
         BrokerOrderID   string          `json:"brokerOrderId,omitempty" bson:"brokerOrderId,omitempty"`
@@ -192,7 +229,7 @@ func (o *Order) Validate() error {
 
         // Validate status
         switch o.Status {
-        case OrderStatusPending, OrderStatusExecuted, OrderStatusCancelled, OrderStatusRejected, OrderStatusPartial:
+        case OrderStatusNew, OrderStatusPending, OrderStatusExecuted, OrderStatusCancelled, OrderStatusRejected, OrderStatusPartial:
                 // Valid statuses
         default:
                 return errors.New("invalid order status")
@@ -209,6 +246,48 @@ func (o *Order) Validate() error {
         return nil
 }
 
+// CanTransitionTo checks if the order can move from its current status to status
+func (o *Order) CanTransitionTo(status OrderStatus) bool {
+        for _, next := range orderTransitions[o.Status] {
+                if next == status {
+                        return true
+                }
+        }
+        return false
+}
+
+// TransitionTo moves the order to status, returning an error if the state machine
+// does not allow it. filledQuantity is only used by partial fills, a complete fill
+// fills the whole order and other statuses keep what has already filled.
+func (o *Order) TransitionTo(status OrderStatus, filledQuantity int) error {
+        if !o.CanTransitionTo(status) {
+                return fmt.Errorf("invalid order status transition from %s to %s", o.Status, status)
+        }
+
+        // Only fills change the filled quantity
+        switch status {
+        case OrderStatusExecuted:
+                filledQuantity = o.Quantity
+                o.ExecutionTime = time.Now()
+        case OrderStatusPartial:
+                if filledQuantity <= o.FilledQuantity || filledQuantity >= o.Quantity {
+                        return errors.New("partial fill must increase the filled quantity and leave part of the order unfilled")
+                }
+        default:
+                filledQuantity = o.FilledQuantity
+        }
+
+        o.Status = status
+        o.FilledQuantity = filledQuantity
+        o.UpdatedAt = time.Now()
+        return nil
+}
+
+// IsFinal checks if the order can no longer change status
+func (o *Order) IsFinal() bool {
+        return len(orderTransitions[o.Status]) == 0
+}
+
 // CalculateSlippage calculates the slippage for the order
 func (o *Order) CalculateSlippage() float64 {
         if o.Status != OrderStatusExecuted && o.Status != OrderStatusPartial {
@@ -3,6 +3,7 @@ package models
 import (
         "errors"
         "regexp"
+        "strings"
         "time"
 )
 
@@ -42,6 +43,71 @@ const (
         PriceTypeBidAskAvg PriceType = "BID_ASK_AVG"
 )
 
+// FailureAction represents what happens to the placed legs when a leg fails
+type FailureAction string
+
+const (
+        FailureActionKeepPlacedLegs FailureAction = "KEEP_PLACED_LEGS"
+        FailureActionExitPlacedLegs FailureAction = "EXIT_PLACED_LEGS"
+)
+
+// LegExecutionMode represents whether legs are placed together or one at a time
+type LegExecutionMode string
+
+const (
+        LegExecutionModeParallel   LegExecutionMode = "PARALLEL"
+        LegExecutionModeSequential LegExecutionMode = "SEQUENTIAL"
+)
+
+// ExecutionMode represents what triggers a portfolio's entry
+type ExecutionMode string
+
+const (
+        ExecutionModeTime            ExecutionMode = "START_TIME"       // At its start time
+        ExecutionModeSignal          ExecutionMode = "SIGNAL"           // On signals posted to the signal webhook
+        ExecutionModeCombinedPremium ExecutionMode = "COMBINED_PREMIUM" // When its legs' combined premium reaches a value
+        ExecutionModeManual          ExecutionMode = "MANUAL"
+        ExecutionModeUnderlyingLevel ExecutionMode = "UNDERLYING_LEVEL" // When the underlying reaches a level
+)
+
+// MonitoringType represents how often legs and portfolios are checked against their
+// targets and stop losses
+type MonitoringType string
+
+const (
+        MonitoringTypeRealtime    MonitoringType = "REALTIME"     // On every price update
+        MonitoringTypeMinuteClose MonitoringType = "MINUTE_CLOSE" // Once a minute, at its close
+        MonitoringTypeInterval    MonitoringType = "INTERVAL"     // Every monitoring interval
+)
+
+// TargetType represents how a portfolio's target is measured
+type TargetType string
+
+const (
+        TargetTypeCombinedProfit  TargetType = "COMBINED_PROFIT"  // Profit of the legs together
+        TargetTypeCombinedPremium TargetType = "COMBINED_PREMIUM" // Premium of the legs together
+        TargetTypeUnderlying      TargetType = "UNDERLYING"       // Level of the underlying
+)
+
+// StopLossType represents how a portfolio's stop loss is measured
+type StopLossType string
+
+const (
+        StopLossTypeCombinedLoss           StopLossType = "COMBINED_LOSS"             // Loss of the legs together
+        StopLossTypeCombinedPremium        StopLossType = "COMBINED_PREMIUM"          // Premium of the legs together
+        StopLossTypeLossAndUnderlyingRange StopLossType = "LOSS_AND_UNDERLYING_RANGE" // Combined loss, or the underlying leaving a range
+        StopLossTypeDeltaTheta             StopLossType = "DELTA_THETA"               // Net delta or theta of the legs
+)
+
+// ExitMode represents the order in which a portfolio's legs are exited
+type ExitMode string
+
+const (
+        ExitModeNormal               ExitMode = "NORMAL"                 // All legs together
+        ExitModeLegByLeg             ExitMode = "LEG_BY_LEG"             // One leg at a time
+        ExitModeReverseEntrySequence ExitMode = "REVERSE_ENTRY_SEQUENCE" // In the reverse of the order they were entered
+)
+
 // Portfolio represents a multi-leg options portfolio in the system
 type Portfolio struct {
         ID                 string            `json:"id" bson:"_id,omitempty"`
@@ -49,6 +115,7 @@ type Portfolio struct {
         Name               string            `json:"name" bson:"name"`
         StrategyID         string            `json:"strategyId" bson:"strategyId"`
         Status             PortfolioStatus   `json:"status" bson:"status"`
+        Version            int               `json:"version,omitempty" bson:"version,omitempty"` // Latest configuration version, stamped on what it executes
         
         // Default Portfolio Settings
         Exchange           string            `json:"exchange" bson:"exchange"`
@@ -56,6 +123,7 @@ type Portfolio struct {
         Expiry             time.Time         `json:"expiry" bson:"expiry"`
         DefaultLots        int               `json:"defaultLots" bson:"defaultLots"`
         PredefinedStrategy string            `json:"predefinedStrategy" bson:"predefinedStrategy"`
+        PredefinedParams   *PredefinedParams `json:"predefinedParams,omitempty" bson:"predefinedParams,omitempty"` // Of the predefined strategy its legs are built from
         StrikeSelection    StrikeSelectionMode `json:"strikeSelection" bson:"strikeSelection"`
         UnderlyingRef      UnderlyingReference `json:"underlyingRef" bson:"underlyingRef"`
         PriceType          PriceType         `json:"priceType" bson:"priceType"`
@@ -80,6 +148,7 @@ type Portfolio struct {
         SquareOffTime      string            `json:"squareOffTime" bson:"squareOffTime"`
         ExecutionMode      ExecutionMode     `json:"executionMode" bson:"executionMode"`
         EntryOrderType     OrderType         `json:"entryOrderType" bson:"entryOrderType"`
+        EntryPriceBuffer   float64           `json:"entryPriceBuffer" bson:"entryPriceBuffer"`
         EstimatedMargin    float64           `json:"estimatedMargin" bson:"estimatedMargin"`
         
         // Range Breakout Settings
@@ -230,6 +299,15 @@ func (p *Portfolio) Validate() error {
                 return errors.New("invalid strike selection mode")
         }
 
+        // Validate predefined strategy
+        switch strings.ToUpper(p.PredefinedStrategy) {
+        case "", PredefinedStrategyCustom, PredefinedStrategyStraddle, PredefinedStrategyStrangle, PredefinedStrategyIronCondor,
+                PredefinedStrategyButterfly, PredefinedStrategyCalendar, PredefinedStrategyRatioSpread:
+                // Valid predefined strategies
+        default:
+                return errors.New("invalid predefined strategy")
+        }
+
         // Validate underlying reference
         switch p.UnderlyingRef {
         case UnderlyingReferenceFuture, UnderlyingReferenceSpot:
@@ -319,6 +397,9 @@ func (p *Portfolio) Validate() error {
         default:
                 return errors.New("invalid entry order type")
         }
+        if p.EntryPriceBuffer < 0 {
+                return errors.New("entry price buffer cannot be negative")
+        }
 
         // Validate range breakout settings if enabled
         if p.RangeBreakoutEnabled {
@@ -473,14 +554,7 @@ func (p *Portfolio) IsActive() bool {
         }
 
         // Check if portfolio should run today
-        today := time.Now().Weekday().String()
-        for _, day := range p.RunOnDays {
-                if day == today {
-                        return true
-                }
-        }
-
-        return false
+        return runsOn(p.RunOnDays, time.Now())
 }
 
 // ShouldExecuteNow checks if the portfolio should execute at the current time
@@ -493,11 +567,41 @@ func (p *Portfolio) ShouldExecuteNow() bool {
                 return false // Only time-based portfolios are checked
         }
 
-        now := time.Now()
-        currentTime := now.Format("15:04:05")
-
         // Check if current time is between start and end time
-        return currentTime >= p.StartTime && currentTime <= p.EndTime
+        return withinHours(p.StartTime, p.EndTime, time.Now())
+}
+
+// ShouldExecuteOnSignal checks if a signal received at a time may enter the
+// portfolio: it must be active in signal execution mode and run at that time
+func (p *Portfolio) ShouldExecuteOnSignal(at time.Time) bool {
+        if p.Status != PortfolioStatusActive || p.ExecutionMode != ExecutionModeSignal {
+                return false
+        }
+
+        return p.RunsAt(at)
+}
+
+// RunsAt checks if a time is on one of the portfolio's run days, between its start
+// and end time
+func (p *Portfolio) RunsAt(at time.Time) bool {
+        return runsOn(p.RunOnDays, at) && withinHours(p.StartTime, p.EndTime, at)
+}
+
+// runsOn checks if a time is on one of days, named in any case
+func runsOn(days []string, at time.Time) bool {
+        for _, day := range days {
+                if strings.EqualFold(day, at.Weekday().String()) {
+                        return true
+                }
+        }
+        return false
+}
+
+// withinHours checks if the clock time of a time is between start and end, both
+// HH:MM:SS
+func withinHours(start, end string, at time.Time) bool {
+        currentTime := at.Format("15:04:05")
+        return currentTime >= start && currentTime <= end
 }
 
 // ShouldSquareOff checks if the portfolio should square off positions at the current time
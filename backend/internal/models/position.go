@@ -85,6 +85,8 @@ type PositionFilter struct {
 	OrderID        string
 	FromDate       time.Time
 	ToDate         time.Time
+	ClosedFrom     time.Time // Positions last updated, as they are when closed, at or after it
+	ClosedBefore   time.Time // Positions last updated before it
 	Tags           []string
 }
 
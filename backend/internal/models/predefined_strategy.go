@@ -0,0 +1,246 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Predefined strategies a portfolio's legs can be built from
+const (
+	PredefinedStrategyCustom      = "CUSTOM" // Legs are entered by hand
+	PredefinedStrategyStraddle    = "STRADDLE"
+	PredefinedStrategyStrangle    = "STRANGLE"
+	PredefinedStrategyIronCondor  = "IRON_CONDOR"
+	PredefinedStrategyButterfly   = "BUTTERFLY"
+	PredefinedStrategyCalendar    = "CALENDAR"
+	PredefinedStrategyRatioSpread = "RATIO_SPREAD"
+)
+
+// StrikeSelectionTypeATMOffset is the strike selection type of legs selecting
+// their strike relative to ATM, their strike selection value being the number
+// of strikes from ATM, above it when positive
+const StrikeSelectionTypeATMOffset = "ATM_OFFSET"
+
+// PredefinedParams are the parameters a predefined strategy's legs are built
+// from. Offsets are counted in strikes of the portfolio's strike step. The body
+// legs are the straddle, the strangle, the short strikes of an iron condor, the
+// middle strike of a butterfly, the near month of a calendar and the ratio legs of
+// a ratio spread, and the other legs trade in the opposite direction: selling the
+// body, the default, sells straddles, strangles and iron condors and buys
+// butterflies, calendars and front ratio spreads.
+type PredefinedParams struct {
+	Direction  OrderDirection `json:"direction,omitempty" bson:"direction,omitempty"`   // Of the body legs, SELL when empty
+	OptionType OptionType     `json:"optionType,omitempty" bson:"optionType,omitempty"` // Of butterflies, calendars and ratio spreads, CE when empty
+	ATMOffset  int            `json:"atmOffset" bson:"atmOffset"`                       // Strikes the body is from ATM, above it when positive
+	Width      int            `json:"width,omitempty" bson:"width,omitempty"`           // Strikes the legs of strangles and iron condors are either side of the body
+	Wings      int            `json:"wings,omitempty" bson:"wings,omitempty"`           // Strikes the wings of iron condors, butterflies and ratio spreads are from the legs they offset
+	Lots       int            `json:"lots,omitempty" bson:"lots,omitempty"`             // Of the legs, the portfolio's default lots when zero
+	LotSize    int            `json:"lotSize" bson:"lotSize"`
+	Ratio      int            `json:"ratio,omitempty" bson:"ratio,omitempty"`         // Ratio legs per opposite leg of ratio spreads, 2 when zero
+	FarExpiry  time.Time      `json:"farExpiry,omitempty" bson:"farExpiry,omitempty"` // Of the far month of calendars
+}
+
+// legSpec is a leg of a predefined strategy, its strike relative to ATM
+type legSpec struct {
+	optionType OptionType
+	body       bool // Trades in the body's direction, otherwise the opposite
+	offset     int  // Strikes from ATM
+	lots       int
+	expiry     time.Time
+}
+
+// IsPredefined reports whether the portfolio's legs are built from a predefined
+// strategy rather than entered by hand
+func (p *Portfolio) IsPredefined() bool {
+	return p.PredefinedStrategy != "" && !strings.EqualFold(p.PredefinedStrategy, PredefinedStrategyCustom)
+}
+
+// BuildPredefinedLegs returns the legs of the portfolio's predefined strategy
+// with its predefined parameters, ATM being the strike nearest to
+// underlyingPrice. Legs select their strikes with the portfolio's strike
+// selection mode: relative legs record their offset from ATM, so that their
+// strike is selected again from ATM at entry, and their strike as of
+// underlyingPrice.
+func (p *Portfolio) BuildPredefinedLegs(underlyingPrice float64) ([]Leg, error) {
+	if !p.IsPredefined() {
+		return nil, errors.New("portfolio has no predefined strategy")
+	}
+	if p.StrikeStep <= 0 {
+		return nil, errors.New("strike step must be greater than zero")
+	}
+	if underlyingPrice <= 0 {
+		return nil, errors.New("underlying price must be greater than zero")
+	}
+
+	params := PredefinedParams{}
+	if p.PredefinedParams != nil {
+		params = *p.PredefinedParams
+	}
+	if params.Direction == "" {
+		params.Direction = OrderDirectionSell
+	}
+	if params.Direction != OrderDirectionBuy && params.Direction != OrderDirectionSell {
+		return nil, errors.New("invalid direction")
+	}
+	if params.OptionType == "" {
+		params.OptionType = OptionTypeCall
+	}
+	if params.OptionType != OptionTypeCall && params.OptionType != OptionTypePut {
+		return nil, errors.New("invalid option type")
+	}
+	if params.Lots == 0 {
+		params.Lots = p.DefaultLots
+	}
+	if params.Lots <= 0 {
+		return nil, errors.New("lots must be greater than zero")
+	}
+	if params.LotSize <= 0 {
+		return nil, errors.New("lot size must be greater than zero")
+	}
+	if params.Ratio == 0 {
+		params.Ratio = 2
+	}
+	if params.Width < 0 || params.Wings < 0 {
+		return nil, errors.New("width and wings cannot be negative")
+	}
+
+	specs, err := p.predefinedLegSpecs(params)
+	if err != nil {
+		return nil, err
+	}
+
+	atm := math.Round(underlyingPrice/p.StrikeStep) * p.StrikeStep
+	now := time.Now()
+	legs := make([]Leg, 0, len(specs))
+	for i, spec := range specs {
+		direction := params.Direction
+		if !spec.body {
+			direction = oppositeDirection(direction)
+		}
+
+		strike := atm + float64(spec.offset)*p.StrikeStep
+		if strike <= 0 {
+			return nil, fmt.Errorf("leg %d is %d strikes from ATM at %.2f, below the lowest strike", i+1, spec.offset, atm)
+		}
+
+		leg := Leg{
+			ID:                  i + 1,
+			PortfolioID:         p.ID,
+			Symbol:              p.Symbol,
+			Exchange:            p.Exchange,
+			Type:                LegTypeOption,
+			BuySell:             string(direction),
+			OptionType:          string(spec.optionType),
+			StrikePrice:         strike,
+			Expiry:              spec.expiry,
+			Lots:                spec.lots,
+			LotSize:             params.LotSize,
+			StrikeSelectionMode: p.StrikeSelection,
+			EntryOrderType:      p.EntryOrderType,
+			EntryPriceBuffer:    p.EntryPriceBuffer,
+			ExitOrderType:       p.ExitOrderType,
+			ExitPriceBuffer:     p.ExitPriceBuffer,
+			MaxExitRetries:      p.MaxExitRetries,
+			ExitRetryInterval:   p.ExitRetryInterval,
+			Status:              "PENDING",
+			CreatedAt:           now,
+			UpdatedAt:           now,
+		}
+		if leg.StrikeSelectionMode == "" {
+			leg.StrikeSelectionMode = StrikeSelectionModeRelative
+		}
+		if leg.StrikeSelectionMode != StrikeSelectionModeNormal {
+			leg.StrikeSelectionType = StrikeSelectionTypeATMOffset
+			leg.StrikeSelectionValue = float64(spec.offset)
+		}
+		leg.CalculateQuantity()
+
+		legs = append(legs, leg)
+	}
+
+	return legs, nil
+}
+
+// predefinedLegSpecs returns the legs of the portfolio's predefined strategy
+func (p *Portfolio) predefinedLegSpecs(params PredefinedParams) ([]legSpec, error) {
+	body := params.ATMOffset
+	lots := params.Lots
+
+	switch strings.ToUpper(p.PredefinedStrategy) {
+	case PredefinedStrategyStraddle:
+		return []legSpec{
+			{optionType: OptionTypeCall, body: true, offset: body, lots: lots, expiry: p.Expiry},
+			{optionType: OptionTypePut, body: true, offset: body, lots: lots, expiry: p.Expiry},
+		}, nil
+
+	case PredefinedStrategyStrangle:
+		if params.Width <= 0 {
+			return nil, errors.New("strangles need a width greater than zero")
+		}
+		return []legSpec{
+			{optionType: OptionTypeCall, body: true, offset: body + params.Width, lots: lots, expiry: p.Expiry},
+			{optionType: OptionTypePut, body: true, offset: body - params.Width, lots: lots, expiry: p.Expiry},
+		}, nil
+
+	case PredefinedStrategyIronCondor:
+		if params.Width <= 0 || params.Wings <= 0 {
+			return nil, errors.New("iron condors need a width and wings greater than zero")
+		}
+		return []legSpec{
+			{optionType: OptionTypeCall, body: true, offset: body + params.Width, lots: lots, expiry: p.Expiry},
+			{optionType: OptionTypePut, body: true, offset: body - params.Width, lots: lots, expiry: p.Expiry},
+			{optionType: OptionTypeCall, offset: body + params.Width + params.Wings, lots: lots, expiry: p.Expiry},
+			{optionType: OptionTypePut, offset: body - params.Width - params.Wings, lots: lots, expiry: p.Expiry},
+		}, nil
+
+	case PredefinedStrategyButterfly:
+		if params.Wings <= 0 {
+			return nil, errors.New("butterflies need wings greater than zero")
+		}
+		return []legSpec{
+			{optionType: params.OptionType, offset: body - params.Wings, lots: lots, expiry: p.Expiry},
+			{optionType: params.OptionType, body: true, offset: body, lots: 2 * lots, expiry: p.Expiry},
+			{optionType: params.OptionType, offset: body + params.Wings, lots: lots, expiry: p.Expiry},
+		}, nil
+
+	case PredefinedStrategyCalendar:
+		if !params.FarExpiry.After(p.Expiry) {
+			return nil, errors.New("calendars need a far expiry after the portfolio's expiry")
+		}
+		return []legSpec{
+			{optionType: params.OptionType, body: true, offset: body, lots: lots, expiry: p.Expiry},
+			{optionType: params.OptionType, offset: body, lots: lots, expiry: params.FarExpiry},
+		}, nil
+
+	case PredefinedStrategyRatioSpread:
+		if params.Wings <= 0 {
+			return nil, errors.New("ratio spreads need wings greater than zero")
+		}
+		if params.Ratio < 2 {
+			return nil, errors.New("ratio must be at least 2")
+		}
+		// The ratio legs are further out of the money than the leg they offset
+		wings := params.Wings
+		if params.OptionType == OptionTypePut {
+			wings = -wings
+		}
+		return []legSpec{
+			{optionType: params.OptionType, offset: body, lots: lots, expiry: p.Expiry},
+			{optionType: params.OptionType, body: true, offset: body + wings, lots: params.Ratio * lots, expiry: p.Expiry},
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown predefined strategy: %s", p.PredefinedStrategy)
+	}
+}
+
+// oppositeDirection returns the direction opposite to direction
+func oppositeDirection(direction OrderDirection) OrderDirection {
+	if direction == OrderDirectionBuy {
+		return OrderDirectionSell
+	}
+	return OrderDirectionBuy
+}
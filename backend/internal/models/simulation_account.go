@@ -45,6 +45,9 @@ type MarketSettings struct {
 	SpreadValue        float64 `json:"spreadValue" db:"spread_value"`
 	AllowShortSelling  bool    `json:"allowShortSelling" db:"allow_short_selling"`
 	AllowFractionalLots bool   `json:"allowFractionalLots" db:"allow_fractional_lots"`
+	MarginInterestRate  float64 `json:"marginInterestRate" db:"margin_interest_rate"` // Annual rate charged on the leveraged portion of held positions
+	FuturesRolloverCost float64 `json:"futuresRolloverCost" db:"futures_rollover_cost"` // Annual carry cost as a fraction of futures notional
+	CryptoFundingRate   float64 `json:"cryptoFundingRate" db:"crypto_funding_rate"` // Daily funding rate for crypto perpetuals, paid by longs when positive
 }
 
 // SimulationTransaction represents a transaction in a simulation account
@@ -94,7 +97,7 @@ type BacktestSession struct {
 	StartDate          time.Time `json:"startDate" db:"start_date"`
 	EndDate            time.Time `json:"endDate" db:"end_date"`
 	Symbols            []string  `json:"symbols" db:"symbols"`
-	Timeframe          string    `json:"timeframe" db:"timeframe"` // "1m", "5m", "15m", "1h", "1d", etc.
+	Timeframe          string    `json:"timeframe" db:"timeframe"` // "tick", "1m", "5m", "15m", "1h", "1d", etc.
 	InitialBalance     float64   `json:"initialBalance" db:"initial_balance"`
 	FinalBalance       float64   `json:"finalBalance" db:"final_balance"`
 	TotalTrades        int       `json:"totalTrades" db:"total_trades"`
@@ -106,9 +109,13 @@ type BacktestSession struct {
 	AnnualizedReturn   float64   `json:"annualizedReturn" db:"annualized_return"`
 	CreatedAt          time.Time `json:"createdAt" db:"created_at"`
 	CompletedAt        *time.Time `json:"completedAt" db:"completed_at"`
-	Status             string    `json:"status" db:"status"` // "PENDING", "RUNNING", "COMPLETED", "FAILED"
+	Status             string    `json:"status" db:"status"` // "PENDING", "RUNNING", "COMPLETED", "FAILED", "CANCELLED"
 	StrategyID         string    `json:"strategyId" db:"strategy_id"`
+	GoStrategyName     string    `json:"goStrategyName,omitempty" db:"go_strategy_name"` // Registered Go strategy to run instead of the declarative strategy
+	RandomSeed         int64     `json:"randomSeed" db:"random_seed"` // Seeds slippage and latency randomness so identical inputs give identical results
 	Parameters         map[string]interface{} `json:"parameters" db:"parameters"`
+	IntradayMargin     *IntradayMarginConfig  `json:"intradayMargin,omitempty" db:"intraday_margin"` // Models MIS leverage, margin calls and square-off when set
+	HoldoutPercent     float64                `json:"holdoutPercent" db:"holdout_percent"` // Percentage of the date range, at its end, reserved for out-of-sample validation
 }
 
 // BacktestResult represents a single result point in a backtest
@@ -123,6 +130,10 @@ type BacktestResult struct {
 	DailyPnL         float64   `json:"dailyPnL" db:"daily_pnl"`
 	MarketValue      float64   `json:"marketValue" db:"market_value"`
 	CashBalance      float64   `json:"cashBalance" db:"cash_balance"`
+	GrossPnL         float64   `json:"grossPnL" db:"gross_pnl"`           // Cumulative P&L before commission and slippage
+	TotalCharges     float64   `json:"totalCharges" db:"total_charges"`   // Cumulative commission and charges paid
+	TotalSlippage    float64   `json:"totalSlippage" db:"total_slippage"` // Cumulative slippage cost
+	LegSlippage      map[string]float64 `json:"legSlippage,omitempty" db:"leg_slippage"` // Cumulative slippage cost per symbol
 }
 
 // MarketDataSnapshot represents a snapshot of market data for simulation
@@ -143,3 +154,16 @@ type MarketDataSnapshot struct {
 	Source      string    `json:"source" db:"source"`
 	IsSimulated bool      `json:"isSimulated" db:"is_simulated"`
 }
+
+// SimulationSnapshot represents a point-in-time copy of a simulation account's state
+// that the account can later be restored to
+type SimulationSnapshot struct {
+	ID                  string               `json:"id" db:"id"`
+	SimulationAccountID string               `json:"simulationAccountId" db:"simulation_account_id"`
+	Name                string               `json:"name" db:"name"`
+	Description         string               `json:"description" db:"description"`
+	Balance             float64              `json:"balance" db:"balance"`
+	Positions           []SimulationPosition `json:"positions" db:"positions"`
+	OpenOrders          []SimulationOrder    `json:"openOrders" db:"open_orders"`
+	CreatedAt           time.Time            `json:"createdAt" db:"created_at"`
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"regexp"
 	"time"
 )
 
@@ -24,6 +25,8 @@ const (
 	StrategyStatusStopped StrategyStatus = "STOPPED"
 	StrategyStatusFailed  StrategyStatus = "FAILED"
 	StrategyStatusArchived StrategyStatus = "ARCHIVED"
+	StrategyStatusHalted  StrategyStatus = "HALTED" // A daily limit was breached, trading resumes once re-armed
+	StrategyStatusScheduled StrategyStatus = "SCHEDULED" // Outside its run days and hours, activated again at its start time
 )
 
 // ScheduleFrequency defines the frequency of strategy execution
@@ -45,14 +48,34 @@ type Strategy struct {
 	UserID          string         `json:"userId" bson:"userId"`
 	Type            StrategyType   `json:"type" bson:"type"`
 	Status          StrategyStatus `json:"status" bson:"status"`
+	Version         int            `json:"version,omitempty" bson:"version,omitempty"` // Latest configuration version, stamped on what it executes
 	EntryConditions []Condition    `json:"entryConditions" bson:"entryConditions"`
 	ExitConditions  []Condition    `json:"exitConditions" bson:"exitConditions"`
 	RiskParameters  RiskParameters `json:"riskParameters" bson:"riskParameters"`
 	Instruments     []string       `json:"instruments" bson:"instruments"`
 	Tags            []string       `json:"tags" bson:"tags"`
+	Environment     Environment    `json:"environment,omitempty" bson:"environment,omitempty"`         // Paper trading when empty
+	BrokerName      string         `json:"brokerName,omitempty" bson:"brokerName,omitempty"`           // Broker a live strategy trades through
+	PaperStrategyID string         `json:"paperStrategyId,omitempty" bson:"paperStrategyId,omitempty"` // Paper strategy a live strategy was promoted from
+	LiveStrategyID  string         `json:"liveStrategyId,omitempty" bson:"liveStrategyId,omitempty"`   // Live strategy a paper strategy was promoted to
+	PromotedAt      time.Time      `json:"promotedAt,omitempty" bson:"promotedAt,omitempty"`
+	BacktestSessionID string       `json:"backtestSessionId,omitempty" bson:"backtestSessionId,omitempty"` // Backtest a live strategy was promoted from, its live results being tracked against
 	CreatedAt       time.Time      `json:"createdAt" bson:"createdAt"`
 	UpdatedAt       time.Time      `json:"updatedAt" bson:"updatedAt"`
 	LastExecutedAt  time.Time      `json:"lastExecutedAt,omitempty" bson:"lastExecutedAt,omitempty"`
+
+	// Daily limits, the strategy halting until it is re-armed when one is breached
+	MaxLossPerStrategy float64   `json:"maxLossPerStrategy,omitempty" bson:"maxLossPerStrategy,omitempty"` // Loss in a trading day, none when 0
+	MaxTradesPerDay    int       `json:"maxTradesPerDay,omitempty" bson:"maxTradesPerDay,omitempty"`       // Positions opened in a trading day, none when 0
+	HaltReason         string    `json:"haltReason,omitempty" bson:"haltReason,omitempty"`
+	HaltedAt           time.Time `json:"haltedAt,omitempty" bson:"haltedAt,omitempty"`
+	RearmedAt          time.Time `json:"rearmedAt,omitempty" bson:"rearmedAt,omitempty"`
+
+	// Trading window, the strategy being active only on its run days between its
+	// start and end time when it has run days
+	RunOnDays []string `json:"runOnDays,omitempty" bson:"runOnDays,omitempty"` // MONDAY to SUNDAY
+	StartTime string   `json:"startTime,omitempty" bson:"startTime,omitempty"` // HH:MM:SS
+	EndTime   string   `json:"endTime,omitempty" bson:"endTime,omitempty"`     // HH:MM:SS
 }
 
 // Condition represents a trading condition
@@ -118,10 +141,59 @@ func (s *Strategy) Validate() error {
 	if s.RiskParameters.MaxPositionSize <= 0 {
 		return errors.New("max position size must be greater than zero")
 	}
+	if s.MaxLossPerStrategy < 0 {
+		return errors.New("max loss per strategy cannot be negative")
+	}
+	if s.MaxTradesPerDay < 0 {
+		return errors.New("max trades per day cannot be negative")
+	}
+	
+	// Validate the trading window
+	if len(s.RunOnDays) > 0 {
+		validDays := map[string]bool{
+			"MONDAY": true, "TUESDAY": true, "WEDNESDAY": true,
+			"THURSDAY": true, "FRIDAY": true, "SATURDAY": true, "SUNDAY": true,
+		}
+		for _, day := range s.RunOnDays {
+			if !validDays[day] {
+				return errors.New("invalid day in run on days: " + day)
+			}
+		}
+		
+		timeRegex := regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9]$`)
+		if !timeRegex.MatchString(s.StartTime) {
+			return errors.New("invalid start time format (use HH:MM:SS)")
+		}
+		if !timeRegex.MatchString(s.EndTime) {
+			return errors.New("invalid end time format (use HH:MM:SS)")
+		}
+		if s.StartTime >= s.EndTime {
+			return errors.New("start time must be before end time")
+		}
+	}
 	
 	return nil
 }
 
+// IsLive checks if the strategy trades in the live environment
+func (s *Strategy) IsLive() bool {
+	return s.Environment == EnvironmentLive
+}
+
+// IsScheduled checks if the strategy only runs on its run days and hours
+func (s *Strategy) IsScheduled() bool {
+	return len(s.RunOnDays) > 0
+}
+
+// RunsAt checks if a time is on one of the strategy's run days, between its start
+// and end time. Strategies without run days run at any time.
+func (s *Strategy) RunsAt(at time.Time) bool {
+	if !s.IsScheduled() {
+		return true
+	}
+	return runsOn(s.RunOnDays, at) && withinHours(s.StartTime, s.EndTime, at)
+}
+
 // Validate validates the strategy schedule
 func (s *StrategySchedule) Validate() error {
 	if s.StrategyID == "" {
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// RuleStrategy is a strategy declared as entry and exit rules instead of code. Entry
+// rules are evaluated while the strategy is flat and exit rules while it holds a position.
+type RuleStrategy struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description,omitempty"`
+	Symbol      string                   `json:"symbol"`               // Default symbol for prices, indicators and orders
+	Timezone    string                   `json:"timezone,omitempty"`   // Location used by time conditions, defaults to UTC
+	Indicators  map[string]RuleIndicator `json:"indicators,omitempty"` // Indicators by the name rules refer to them with
+	Options     map[string]RuleOption    `json:"options,omitempty"`    // Option contracts by the name rules refer to them with
+	Entry       []TradingRule            `json:"entry"`
+	Exit        []TradingRule            `json:"exit,omitempty"`
+	StopLoss    float64                  `json:"stopLoss,omitempty"`   // Exit when the open position loses this amount, 0 disables
+	Target      float64                  `json:"target,omitempty"`     // Exit when the open position gains this amount, 0 disables
+	MaxEntries  int                      `json:"maxEntries,omitempty"` // Maximum number of entries, 0 for no limit
+}
+
+// RuleIndicator declares a technical indicator computed on a symbol's prices. Zero
+// values take the indicator's defaults.
+type RuleIndicator struct {
+	Type       string  `json:"type"`             // SMA, EMA, RSI, MACD, ATR, BOLLINGER, SUPERTREND or VWAP
+	Symbol     string  `json:"symbol,omitempty"` // Defaults to the strategy symbol
+	Period     int     `json:"period"`
+	Source     string  `json:"source,omitempty"`     // open, high, low, close (default), hl2 or hlc3
+	Fast       int     `json:"fast,omitempty"`       // MACD's fast EMA period
+	Slow       int     `json:"slow,omitempty"`       // MACD's slow EMA period
+	Signal     int     `json:"signal,omitempty"`     // MACD's signal EMA period
+	Multiplier float64 `json:"multiplier,omitempty"` // Of the standard deviation for Bollinger bands, of the ATR for Supertrend
+}
+
+// RuleOption declares an option contract whose greeks rules can use. Greeks are
+// computed with Black-Scholes from the volatility implied by the option's price.
+type RuleOption struct {
+	Symbol       string    `json:"symbol"`     // Option contract symbol
+	Underlying   string    `json:"underlying"` // Defaults to the strategy symbol
+	OptionType   string    `json:"optionType"` // CE or PE
+	Strike       float64   `json:"strike"`
+	Expiry       time.Time `json:"expiry"`
+	RiskFreeRate float64   `json:"riskFreeRate,omitempty"` // Annual rate as a fraction
+}
+
+// TradingRule places orders when its condition holds
+type TradingRule struct {
+	Name    string        `json:"name,omitempty"`
+	When    RuleCondition `json:"when"`
+	Actions []RuleAction  `json:"actions,omitempty"` // Exit rules without actions close every open position
+}
+
+// RuleCondition is either a comparison of two operands or a combination of conditions.
+// Operands are numbers, clock times such as "09:20", or references: a price field
+// (open, high, low, close, volume, bid, ask) optionally followed by a symbol or option
+// name in parentheses, an indicator name optionally followed by one of its lines in
+// parentheses (e.g. "macd(signal)" or "bands(upper)"), a greek of an option (delta,
+// gamma, theta, vega or iv, e.g. "delta(atmCall)"), "time", "position(symbol)" or
+// "param(name)".
+type RuleCondition struct {
+	All   []RuleCondition `json:"all,omitempty"`
+	Any   []RuleCondition `json:"any,omitempty"`
+	Not   *RuleCondition  `json:"not,omitempty"`
+	Left  RuleOperand     `json:"left,omitempty"`
+	Op    string          `json:"op,omitempty"` // >, >=, <, <=, ==, !=, crossesAbove or crossesBelow
+	Right RuleOperand     `json:"right,omitempty"`
+}
+
+// RuleAction is an order placed by a rule
+type RuleAction struct {
+	Direction OrderDirection `json:"direction"`        // BUY or SELL
+	Symbol    string         `json:"symbol,omitempty"` // Symbol or option name, defaults to the strategy symbol
+	Quantity  int            `json:"quantity"`
+}
+
+// RuleOperand is an operand of a rule condition, written as a string or a number
+type RuleOperand string
+
+// UnmarshalJSON accepts numbers as well as strings
+func (o *RuleOperand) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*o = RuleOperand(text)
+		return nil
+	}
+
+	var number float64
+	if err := json.Unmarshal(data, &number); err != nil {
+		return errors.New("rule operand must be a string or a number")
+	}
+
+	*o = RuleOperand(strconv.FormatFloat(number, 'f', -1, 64))
+	return nil
+}
+
+// Validate validates the parts of the strategy that do not need compiling
+func (s *RuleStrategy) Validate() error {
+	if s.Name == "" {
+		return errors.New("strategy name is required")
+	}
+
+	if s.Symbol == "" {
+		return errors.New("symbol is required")
+	}
+
+	if len(s.Entry) == 0 {
+		return errors.New("at least one entry rule is required")
+	}
+
+	if s.StopLoss < 0 || s.Target < 0 || s.MaxEntries < 0 {
+		return errors.New("stop loss, target and max entries cannot be negative")
+	}
+
+	for _, rule := range s.Entry {
+		if len(rule.Actions) == 0 {
+			return errors.New("entry rules must have at least one action")
+		}
+	}
+
+	return nil
+}
@@ -77,6 +77,9 @@ type UserNotificationSettings struct {
 	PriceAlerts              bool      `json:"priceAlerts" bson:"priceAlerts"`
 	MarginCallAlerts         bool      `json:"marginCallAlerts" bson:"marginCallAlerts"`
 	NewsAlerts               bool      `json:"newsAlerts" bson:"newsAlerts"`
+	DailySummaryEmails       bool      `json:"dailySummaryEmails" bson:"dailySummaryEmails"`                 // Opted in to end-of-day summary emails
+	DailySummaryTime         string    `json:"dailySummaryTime,omitempty" bson:"dailySummaryTime,omitempty"` // HH:MM in the user's time zone, the default when empty
+	LastDailySummary         string    `json:"lastDailySummary,omitempty" bson:"lastDailySummary,omitempty"` // Date of the last summary sent, YYYY-MM-DD
 	CreatedAt              time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt              time.Time `json:"updatedAt" bson:"updatedAt"`
 }
@@ -162,5 +165,10 @@ func (s *UserNotificationSettings) Validate() error {
 	if s.UserID == "" {
 		return errors.New("user ID is required")
 	}
+	if s.DailySummaryTime != "" {
+		if _, err := time.Parse("15:04", s.DailySummaryTime); err != nil {
+			return errors.New("daily summary time must be HH:MM")
+		}
+	}
 	return nil
 }
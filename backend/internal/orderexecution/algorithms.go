@@ -92,6 +92,7 @@ func (a *POVAlgorithm) Execute(ctx context.Context, engine *OrderExecutionEngine
 				Exchange:        request.Exchange,
 				Product:         request.Product,
 				StrategyID:      request.StrategyID,
+				UserID:          request.UserID,
 				Tags:            append([]string{}, request.Tags...),
 			}
 			
@@ -396,6 +397,7 @@ func (a *AdaptiveAlgorithm) Execute(ctx context.Context, engine *OrderExecutionE
 				Exchange:        request.Exchange,
 				Product:         request.Product,
 				StrategyID:      request.StrategyID,
+				UserID:          request.UserID,
 				Tags:            append([]string{}, request.Tags...),
 			}
 			
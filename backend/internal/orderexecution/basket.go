@@ -0,0 +1,294 @@
+package orderexecution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// BasketLegResult is the outcome of placing one leg of a basket
+type BasketLegResult struct {
+	LegID       int
+	Order       *Order // Entry order, nil when the leg could not be placed
+	Attempts    int
+	Error       string
+	ExitOrderID string // Order closing the leg's fills after another leg failed
+}
+
+// BasketResult is the outcome of placing a portfolio's legs as a basket
+type BasketResult struct {
+	PortfolioID      string
+	PortfolioVersion int                // Configuration version of the portfolio placed
+	Legs             []*BasketLegResult // In the order the legs were placed
+	Status           bool               // Whether every leg was placed
+	Exited           bool               // Whether the placed legs were exited after a failure
+	Error            string
+}
+
+// BasketExecutor places the legs of a portfolio as one basket. Legs are placed in
+// order of execution priority, buys ahead of sells when the portfolio trades buys
+// first, either together or one at a time as its leg execution mode says. A leg is
+// retried as its entry settings allow, and when it still fails the remaining legs
+// are not placed and the placed ones are exited if the portfolio's failure action
+// says so.
+type BasketExecutor struct {
+	engine  *OrderExecutionEngine
+	retries *RetryExecutor
+}
+
+// NewBasketExecutor creates a new basket executor placing orders through engine
+func NewBasketExecutor(engine *OrderExecutionEngine) *BasketExecutor {
+	return &BasketExecutor{
+		engine:  engine,
+		retries: NewRetryExecutor(engine),
+	}
+}
+
+// Execute places the legs of a portfolio. It returns once every leg is placed or a
+// leg has failed and the placed legs have been dealt with.
+func (b *BasketExecutor) Execute(ctx context.Context, portfolio *models.Portfolio) (*BasketResult, error) {
+	if len(portfolio.Legs) == 0 {
+		return nil, errors.New("portfolio has no legs")
+	}
+
+	switch portfolio.LegExecutionMode {
+	case models.LegExecutionModeParallel, models.LegExecutionModeSequential, "":
+	default:
+		return nil, fmt.Errorf("unknown leg execution mode: %s", portfolio.LegExecutionMode)
+	}
+
+	result := &BasketResult{
+		PortfolioID:      portfolio.ID,
+		PortfolioVersion: portfolio.Version,
+	}
+
+	// Place each stage once the one before it is placed
+	for _, stage := range basketStages(portfolio) {
+		if err := ctx.Err(); err != nil {
+			result.Error = fmt.Sprintf("basket cancelled: %v", err)
+			break
+		}
+
+		legResults := make([]*BasketLegResult, len(stage))
+		var wg sync.WaitGroup
+		for i, leg := range stage {
+			wg.Add(1)
+			go func(idx int, leg *models.Leg) {
+				defer wg.Done()
+				legResults[idx] = b.placeLeg(ctx, portfolio, leg)
+			}(i, leg)
+		}
+		wg.Wait()
+
+		result.Legs = append(result.Legs, legResults...)
+		for _, legResult := range legResults {
+			if legResult.Order == nil && result.Error == "" {
+				result.Error = fmt.Sprintf("leg %d failed: %s", legResult.LegID, legResult.Error)
+			}
+		}
+		if result.Error != "" {
+			break
+		}
+	}
+
+	if result.Error == "" {
+		result.Status = true
+		return result, nil
+	}
+
+	log.Printf("Basket of portfolio %s stopped after %d legs: %s", portfolio.ID, len(result.Legs), result.Error)
+	if portfolio.FailureAction == models.FailureActionExitPlacedLegs {
+		b.exitPlacedLegs(ctx, portfolio, result)
+		result.Exited = true
+	}
+
+	return result, nil
+}
+
+// placeLeg places the entry order of a leg, retrying and repricing it as the leg's
+// entry retry policy says
+func (b *BasketExecutor) placeLeg(ctx context.Context, portfolio *models.Portfolio, leg *models.Leg) *BasketLegResult {
+	result := &BasketLegResult{
+		LegID: leg.ID,
+	}
+
+	retried, err := b.retries.Execute(ctx, legRequest(portfolio, leg), NewLegEntryRetryPolicy(portfolio, leg))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Attempts = retried.Attempts
+	result.Error = retried.Error
+	if retried.Order == nil {
+		return result
+	}
+
+	// An order cancelled or rejected before it filled leaves the leg unplaced
+	b.engine.ordersMutex.RLock()
+	status := retried.Order.Status
+	b.engine.ordersMutex.RUnlock()
+	if status != Cancelled && status != Rejected {
+		result.Order = retried.Order
+	}
+	return result
+}
+
+// exitPlacedLegs cancels whatever is still open of the placed legs and closes what
+// has filled with market orders. Legs are exited in the reverse of the order they
+// were placed in, so the buys placed first to hedge the sells are exited last.
+func (b *BasketExecutor) exitPlacedLegs(ctx context.Context, portfolio *models.Portfolio, result *BasketResult) {
+	for i := len(result.Legs) - 1; i >= 0; i-- {
+		legResult := result.Legs[i]
+		if legResult.Order == nil {
+			continue
+		}
+
+		b.engine.ordersMutex.RLock()
+		entry := *legResult.Order
+		b.engine.ordersMutex.RUnlock()
+
+		// Cancel the unfilled part
+		if entry.Status == Pending || entry.Status == Open || entry.Status == PartiallyExecuted {
+			if _, err := b.engine.CancelOrder(ctx, entry.ID); err != nil {
+				log.Printf("Error cancelling order %s of leg %d: %v", entry.ID, legResult.LegID, err)
+			}
+		}
+
+		// Close the filled part
+		if entry.FilledQuantity == 0 {
+			continue
+		}
+
+		exit := &OrderRequest{
+			Symbol:          entry.Symbol,
+			Quantity:        entry.FilledQuantity,
+			OrderType:       Market,
+			TransactionType: Sell,
+			Validity:        entry.Validity,
+			Exchange:        entry.Exchange,
+			Product:         entry.Product,
+			StrategyID:      entry.StrategyID,
+			UserID:          portfolio.UserID,
+			Tags:            append(append([]string(nil), entry.Tags...), "basket_exit"),
+		}
+		if entry.TransactionType == Sell {
+			exit.TransactionType = Buy
+		}
+
+		response, err := b.engine.ExecuteOrder(ctx, exit)
+		if err != nil {
+			log.Printf("Error exiting leg %d: %v", legResult.LegID, err)
+			continue
+		}
+		if !response.Status || response.Order == nil {
+			log.Printf("Exit of leg %d was rejected: %s", legResult.LegID, response.Error)
+			continue
+		}
+		legResult.ExitOrderID = response.Order.ID
+	}
+}
+
+// basketStages groups a portfolio's legs into the stages they are placed in. In
+// parallel mode legs of the same priority, and side when buys go first, are placed
+// together, in sequential mode every leg is a stage of its own.
+func basketStages(portfolio *models.Portfolio) [][]*models.Leg {
+	legs := make([]*models.Leg, len(portfolio.Legs))
+	for i := range portfolio.Legs {
+		legs[i] = &portfolio.Legs[i]
+	}
+
+	// Lower priorities are placed first, keeping the portfolio's order among equals
+	sort.SliceStable(legs, func(i, j int) bool {
+		if portfolio.BuyTradesFirst && isBuyLeg(legs[i]) != isBuyLeg(legs[j]) {
+			return isBuyLeg(legs[i])
+		}
+		return legs[i].ExecutionPriority < legs[j].ExecutionPriority
+	})
+
+	var stages [][]*models.Leg
+	for i, leg := range legs {
+		if i > 0 && portfolio.LegExecutionMode != models.LegExecutionModeSequential {
+			previous := legs[i-1]
+			if previous.ExecutionPriority == leg.ExecutionPriority &&
+				(!portfolio.BuyTradesFirst || isBuyLeg(previous) == isBuyLeg(leg)) {
+				stages[len(stages)-1] = append(stages[len(stages)-1], leg)
+				continue
+			}
+		}
+		stages = append(stages, []*models.Leg{leg})
+	}
+
+	return stages
+}
+
+// legRequest creates the entry order request of a leg, falling back to the
+// portfolio's settings where the leg has none of its own. It is tagged with the
+// portfolio and the configuration version it ran.
+func legRequest(portfolio *models.Portfolio, leg *models.Leg) *OrderRequest {
+	quantity := leg.Quantity
+	if quantity == 0 {
+		quantity = leg.Lots * leg.LotSize
+	}
+
+	exchange := leg.Exchange
+	if exchange == "" {
+		exchange = portfolio.Exchange
+	}
+
+	orderType := leg.EntryOrderType
+	if orderType == "" {
+		orderType = portfolio.EntryOrderType
+	}
+
+	request := &OrderRequest{
+		Symbol:          leg.Symbol,
+		Quantity:        quantity,
+		OrderType:       Market,
+		TransactionType: Sell,
+		Validity:        Day,
+		Exchange:        exchange,
+		Product:         ProductType(portfolio.ProductType),
+		StrategyID:      portfolio.StrategyID,
+		UserID:          portfolio.UserID,
+		Tags:            []string{"basket", "portfolio_" + portfolio.ID, fmt.Sprintf("leg_%d", leg.ID)},
+		LotSize:         leg.LotSize,
+	}
+	if isBuyLeg(leg) {
+		request.TransactionType = Buy
+	}
+	if portfolio.Version > 0 {
+		request.Tags = append(request.Tags, fmt.Sprintf("portfolio_version_%d", portfolio.Version))
+	}
+
+	switch orderType {
+	case models.OrderTypeLimit:
+		request.OrderType = Limit
+		request.Price = leg.EntryLimitPrice
+	case models.OrderTypeSLLimit:
+		request.OrderType = StopLoss
+		request.Price = leg.EntryLimitPrice
+		request.TriggerPrice = leg.EntryTriggerPrice
+	}
+
+	// The leg's buffer overrides the portfolio's
+	protection := NewPortfolioPriceProtection(portfolio, false)
+	if leg.EntryPriceBuffer > 0 {
+		protection.BufferPercent = leg.EntryPriceBuffer
+	}
+	if protection.BufferPercent > 0 {
+		request.PriceProtection = protection
+	}
+
+	return request
+}
+
+// isBuyLeg reports whether a leg is bought
+func isBuyLeg(leg *models.Leg) bool {
+	return leg.BuySell == string(models.OrderDirectionBuy)
+}
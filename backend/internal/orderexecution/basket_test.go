@@ -0,0 +1,111 @@
+package orderexecution
+
+import (
+	"context"
+	"testing"
+
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// TestBasketExecutor tests placing a portfolio's legs as a basket
+func TestBasketExecutor(t *testing.T) {
+	// Create an engine routing NFO orders to a mock broker, BFO has no broker
+	mockBroker := NewMockBrokerAdapter()
+	router := NewVenueRouter()
+	router.AddVenue("MOCK", mockBroker, []string{"NFO"}, 1)
+	engine := NewOrderExecutionEngine(router)
+	engine.RegisterBroker("NFO", mockBroker)
+	executor := NewBasketExecutor(engine)
+	
+	// A short strangle hedged with long wings
+	portfolio := &models.Portfolio{
+		ID:               "PF-1",
+		Exchange:         "NFO",
+		ProductType:      models.ProductTypeNRML,
+		EntryOrderType:   models.OrderTypeMarket,
+		BuyTradesFirst:   true,
+		LegExecutionMode: models.LegExecutionModeParallel,
+		FailureAction:    models.FailureActionExitPlacedLegs,
+		Legs: []models.Leg{
+			{ID: 1, Symbol: "NIFTY24JUN23000CE", BuySell: "SELL", Quantity: 50, ExecutionPriority: 1},
+			{ID: 2, Symbol: "NIFTY24JUN23500CE", BuySell: "BUY", Quantity: 50, ExecutionPriority: 2},
+			{ID: 3, Symbol: "NIFTY24JUN22000PE", BuySell: "BUY", Quantity: 50, ExecutionPriority: 2},
+			{ID: 4, Symbol: "NIFTY24JUN22500PE", BuySell: "SELL", Quantity: 50, ExecutionPriority: 1},
+		},
+	}
+	
+	// Buys go first, legs of the same side and priority together
+	stages := basketStages(portfolio)
+	if len(stages) != 2 || len(stages[0]) != 2 || stages[0][0].ID != 2 || stages[0][1].ID != 3 || stages[1][0].ID != 1 || stages[1][1].ID != 4 {
+		t.Errorf("Expected stages [2 3] [1 4], got %d stages", len(stages))
+	}
+	
+	result, err := executor.Execute(context.Background(), portfolio)
+	if err != nil {
+		t.Errorf("Error executing basket: %v", err)
+		return
+	}
+	if !result.Status || len(result.Legs) != 4 {
+		t.Errorf("Expected all 4 legs to be placed, got %d: %s", len(result.Legs), result.Error)
+		return
+	}
+	for i, legID := range []int{2, 3, 1, 4} {
+		if result.Legs[i].LegID != legID || result.Legs[i].Order.Product != Normal {
+			t.Errorf("Expected leg %d to be placed %d, got leg %d", legID, i+1, result.Legs[i].LegID)
+		}
+	}
+	
+	// Filled legs are closed with opposite market orders
+	for _, legResult := range result.Legs {
+		mockBroker.SimulateOrderStatusChange(legResult.Order.ID, Executed)
+	}
+	executor.exitPlacedLegs(context.Background(), portfolio, result)
+	for _, legResult := range result.Legs {
+		exit, err := engine.GetOrder(legResult.ExitOrderID)
+		if err != nil || exit.Quantity != 50 || exit.OrderType != Market || exit.TransactionType == legResult.Order.TransactionType {
+			t.Errorf("Expected leg %d to be closed with an opposite market order", legResult.LegID)
+		}
+	}
+	
+	// Sequentially, a leg that fails all its retries stops the basket and the legs
+	// placed before it are exited
+	portfolio.LegExecutionMode = models.LegExecutionModeSequential
+	portfolio.BuyTradesFirst = false
+	portfolio.Legs = []models.Leg{
+		{ID: 1, Symbol: "NIFTY24JUN23500CE", BuySell: "BUY", Quantity: 50, ExecutionPriority: 1},
+		{ID: 2, Symbol: "SENSEX24JUN75000CE", Exchange: "BFO", BuySell: "SELL", Quantity: 10, ExecutionPriority: 2, MaxEntryRetries: 2},
+		{ID: 3, Symbol: "NIFTY24JUN23000CE", BuySell: "SELL", Quantity: 50, ExecutionPriority: 3},
+	}
+	result, err = executor.Execute(context.Background(), portfolio)
+	if err != nil {
+		t.Errorf("Error executing basket: %v", err)
+		return
+	}
+	if result.Status || !result.Exited || len(result.Legs) != 2 {
+		t.Errorf("Expected the basket to stop at the failed leg and exit, got %d legs", len(result.Legs))
+		return
+	}
+	if result.Legs[1].Order != nil || result.Legs[1].Attempts != 3 {
+		t.Errorf("Expected the failed leg to be tried 3 times, got %d", result.Legs[1].Attempts)
+	}
+	placed, _ := engine.GetOrder(result.Legs[0].Order.ID)
+	if placed.Status != Cancelled || result.Legs[0].ExitOrderID != "" {
+		t.Errorf("Expected the unfilled first leg to be cancelled, got %s", placed.Status)
+	}
+}
+
+// TestLegRequest tests the entry order requests of legs
+func TestLegRequest(t *testing.T) {
+	portfolio := &models.Portfolio{ID: "PF-1", Exchange: "NFO", ProductType: models.ProductTypeNRML}
+	
+	// Quantities in lots carry the lot size, so the order is only ever sliced into whole lots
+	request := legRequest(portfolio, &models.Leg{ID: 1, Symbol: "NIFTY24JUN23000CE", BuySell: "BUY", Lots: 3, LotSize: 75})
+	if request.Quantity != 225 || request.LotSize != 75 || request.TransactionType != Buy || request.Exchange != "NFO" {
+		t.Errorf("Expected a buy of 225 in lots of 75 on NFO, got %s of %d in lots of %d on %s", request.TransactionType, request.Quantity, request.LotSize, request.Exchange)
+	}
+	
+	request = legRequest(portfolio, &models.Leg{ID: 2, Symbol: "SENSEX24JUN75000CE", Exchange: "BFO", BuySell: "SELL", Quantity: 10})
+	if request.Quantity != 10 || request.lotSize() != 1 || request.TransactionType != Sell || request.Exchange != "BFO" {
+		t.Errorf("Expected a sell of 10 on BFO, got %s of %d on %s", request.TransactionType, request.Quantity, request.Exchange)
+	}
+}
@@ -0,0 +1,428 @@
+package orderexecution
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"trading-platform/backend/internal/metrics"
+)
+
+// BrokerSLO is the service level expected of every broker. Objectives left zero
+// are not checked.
+type BrokerSLO struct {
+	LatencyPercentile float64       // Percentile of order placement round trips MaxLatency applies to
+	MaxLatency        time.Duration // Of order placement round trips
+	MaxRejectRate     float64       // Orders rejected or failed over orders placed
+	MinFillRate       float64       // Filled quantity over requested quantity
+	MaxSlippage       float64       // Average slippage, as a fraction of the order price
+}
+
+// DefaultBrokerSLO is the service level expected of brokers unless configured
+// otherwise
+var DefaultBrokerSLO = BrokerSLO{
+	LatencyPercentile: 0.99,
+	MaxLatency:        500 * time.Millisecond,
+	MaxRejectRate:     0.02,
+	MinFillRate:       0.95,
+	MaxSlippage:       0.001,
+}
+
+// Objectives of the broker SLO, as reported breached
+const (
+	ObjectiveLatency    = "latency"
+	ObjectiveRejectRate = "rejectRate"
+	ObjectiveFillRate   = "fillRate"
+	ObjectiveSlippage   = "slippage"
+)
+
+// DefaultQualityWindow is the number of latest orders and fills the quality of
+// brokers is measured over
+const DefaultQualityWindow = 500
+
+// pendingOrderTTL is how long an order is waited on to finish before its fill is
+// no longer tracked
+const pendingOrderTTL = 24 * time.Hour
+
+// BrokerQuality is the latency and fill quality of a broker over its latest orders
+// and fills, against the SLO
+type BrokerQuality struct {
+	Broker            string
+	Orders            int
+	RejectedOrders    int
+	RejectRate        float64
+	MedianLatency     time.Duration
+	Latency           time.Duration // At the percentile of the SLO
+	Fills             int           // Orders the broker finished with
+	RequestedQuantity int
+	FilledQuantity    int
+	FillRate          float64
+	AverageSlippage   float64  // Weighted by filled quantity, over fills of orders placed with a price
+	Breaches          []string // Objectives of the SLO not met
+}
+
+// MeetsSLO reports whether the broker meets every objective of the SLO
+func (q BrokerQuality) MeetsSLO() bool {
+	return len(q.Breaches) == 0
+}
+
+// placement is the outcome of placing an order with a broker
+type placement struct {
+	latency  time.Duration
+	rejected bool
+}
+
+// fill is the outcome of an order a broker finished with
+type fill struct {
+	quantity int
+	filled   int
+	slippage float64
+	priced   bool // Whether the order had a price to measure slippage from
+}
+
+// pendingOrder is an order placed with a broker, waited on to finish
+type pendingOrder struct {
+	price           float64 // Reference price of the order, zero when it had none
+	transactionType TransactionType
+	quantity        int
+	placedAt        time.Time
+}
+
+// brokerQuality holds the latest orders and fills of a broker
+type brokerQuality struct {
+	placements []placement
+	fills      []fill
+	pending    map[string]pendingOrder // By order ID
+	prunedAt   time.Time
+}
+
+// BrokerQualityTracker measures the order round trip latency, reject rate, fill
+// rate and slippage of each broker over its latest orders, exporting them to
+// Prometheus for SLO dashboards and feeding them to the venue router's scoring.
+type BrokerQualityTracker struct {
+	slo     BrokerSLO
+	window  int
+	brokers map[string]*brokerQuality
+	router  *VenueRouter
+	mutex   sync.Mutex
+}
+
+// NewBrokerQualityTracker creates a new broker quality tracker measuring brokers
+// over their latest window orders and fills, DefaultQualityWindow when not
+// positive
+func NewBrokerQualityTracker(slo BrokerSLO, window int) *BrokerQualityTracker {
+	if slo.LatencyPercentile <= 0 || slo.LatencyPercentile > 1 {
+		slo.LatencyPercentile = DefaultBrokerSLO.LatencyPercentile
+	}
+	if window <= 0 {
+		window = DefaultQualityWindow
+	}
+
+	return &BrokerQualityTracker{
+		slo:     slo,
+		window:  window,
+		brokers: make(map[string]*brokerQuality),
+	}
+}
+
+// SetVenueRouter feeds the latency, rejections and fills of brokers to a venue
+// router, to score its venues by. Brokers are venues of the same name.
+func (t *BrokerQualityTracker) SetVenueRouter(router *VenueRouter) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.router = router
+}
+
+// RecordPlacement records placing an order with a broker, the order being waited
+// on to finish when the broker accepted it. Orders the broker rejected or failed
+// to place count as rejected.
+func (t *BrokerQualityTracker) RecordPlacement(broker string, request *OrderRequest, response *OrderResponse, latency time.Duration, err error) {
+	rejected := err != nil || response == nil || !response.Status ||
+		(response.Order != nil && response.Order.Status == Rejected)
+	metrics.ObserveBrokerOrder(broker, latency, rejected, err)
+
+	now := time.Now()
+	t.mutex.Lock()
+	q := t.broker(broker)
+	q.placements = append(q.placements, placement{latency: latency, rejected: rejected})
+	if len(q.placements) > t.window {
+		q.placements = q.placements[len(q.placements)-t.window:]
+	}
+	q.prune(now)
+	if !rejected && response.Order != nil && response.Order.ID != "" {
+		q.pending[response.Order.ID] = pendingOrder{
+			price:           referencePrice(request),
+			transactionType: request.TransactionType,
+			quantity:        request.Quantity,
+			placedAt:        now,
+		}
+	}
+	router := t.router
+	t.mutex.Unlock()
+
+	// Brokers not routed to by the router are not venues of it
+	if router != nil {
+		router.RecordOrderResult(broker, latency, rejected)
+	}
+
+	// Orders may be filled as they are placed
+	if !rejected {
+		t.RecordOrderUpdate(broker, response.Order)
+	}
+}
+
+// RecordOrderUpdate records the latest state of an order placed with a broker,
+// its fill being recorded once the broker finished with it
+func (t *BrokerQualityTracker) RecordOrderUpdate(broker string, order *Order) {
+	if order == nil || !orderFinished(order.Status) {
+		return
+	}
+
+	t.mutex.Lock()
+	q, exists := t.brokers[broker]
+	if !exists {
+		t.mutex.Unlock()
+		return
+	}
+	pending, exists := q.pending[order.ID]
+	if !exists {
+		t.mutex.Unlock()
+		return
+	}
+	delete(q.pending, order.ID)
+
+	// The quantity of the order may have been modified since it was placed
+	f := fill{quantity: pending.quantity, filled: order.FilledQuantity}
+	if order.Quantity > 0 {
+		f.quantity = order.Quantity
+	}
+	if f.filled > f.quantity {
+		f.filled = f.quantity
+	}
+	if pending.price > 0 && f.filled > 0 && order.AveragePrice > 0 {
+		f.slippage = fillSlippage(pending.transactionType, pending.price, order.AveragePrice)
+		f.priced = true
+	}
+	q.fills = append(q.fills, f)
+	if len(q.fills) > t.window {
+		q.fills = q.fills[len(q.fills)-t.window:]
+	}
+	router := t.router
+	t.mutex.Unlock()
+
+	metrics.ObserveBrokerFill(broker, f.quantity, f.filled)
+	if f.priced {
+		metrics.ObserveBrokerSlippage(broker, f.slippage)
+	}
+	if router != nil && f.quantity > 0 {
+		router.recordFill(broker, f.quantity, f.filled, f.slippage, f.priced)
+	}
+}
+
+// Quality returns the quality of a broker, false when nothing was recorded for it
+func (t *BrokerQualityTracker) Quality(broker string) (BrokerQuality, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	q, exists := t.brokers[broker]
+	if !exists {
+		return BrokerQuality{}, false
+	}
+	return t.quality(broker, q), true
+}
+
+// Qualities returns the quality of every broker, by name
+func (t *BrokerQualityTracker) Qualities() []BrokerQuality {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	qualities := make([]BrokerQuality, 0, len(t.brokers))
+	for name, q := range t.brokers {
+		qualities = append(qualities, t.quality(name, q))
+	}
+	sort.Slice(qualities, func(i, j int) bool {
+		return qualities[i].Broker < qualities[j].Broker
+	})
+	return qualities
+}
+
+// broker returns the quality of a broker, creating it when first recorded. The
+// tracker's lock must be held.
+func (t *BrokerQualityTracker) broker(name string) *brokerQuality {
+	q, exists := t.brokers[name]
+	if !exists {
+		q = &brokerQuality{pending: make(map[string]pendingOrder)}
+		t.brokers[name] = q
+	}
+	return q
+}
+
+// quality measures a broker against the SLO. The tracker's lock must be held.
+func (t *BrokerQualityTracker) quality(name string, q *brokerQuality) BrokerQuality {
+	quality := BrokerQuality{Broker: name, Orders: len(q.placements), Fills: len(q.fills)}
+
+	if quality.Orders > 0 {
+		latencies := make([]time.Duration, 0, len(q.placements))
+		for _, p := range q.placements {
+			latencies = append(latencies, p.latency)
+			if p.rejected {
+				quality.RejectedOrders++
+			}
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		quality.RejectRate = float64(quality.RejectedOrders) / float64(quality.Orders)
+		quality.MedianLatency = percentile(latencies, 0.5)
+		quality.Latency = percentile(latencies, t.slo.LatencyPercentile)
+	}
+
+	var slippage float64
+	pricedQuantity := 0
+	for _, f := range q.fills {
+		quality.RequestedQuantity += f.quantity
+		quality.FilledQuantity += f.filled
+		if f.priced {
+			slippage += f.slippage * float64(f.filled)
+			pricedQuantity += f.filled
+		}
+	}
+	if quality.RequestedQuantity > 0 {
+		quality.FillRate = float64(quality.FilledQuantity) / float64(quality.RequestedQuantity)
+	}
+	if pricedQuantity > 0 {
+		quality.AverageSlippage = slippage / float64(pricedQuantity)
+	}
+
+	// Objectives are only checked once there is something to measure
+	if t.slo.MaxLatency > 0 && quality.Orders > 0 && quality.Latency > t.slo.MaxLatency {
+		quality.Breaches = append(quality.Breaches, ObjectiveLatency)
+	}
+	if t.slo.MaxRejectRate > 0 && quality.Orders > 0 && quality.RejectRate > t.slo.MaxRejectRate {
+		quality.Breaches = append(quality.Breaches, ObjectiveRejectRate)
+	}
+	if t.slo.MinFillRate > 0 && quality.RequestedQuantity > 0 && quality.FillRate < t.slo.MinFillRate {
+		quality.Breaches = append(quality.Breaches, ObjectiveFillRate)
+	}
+	if t.slo.MaxSlippage > 0 && pricedQuantity > 0 && quality.AverageSlippage > t.slo.MaxSlippage {
+		quality.Breaches = append(quality.Breaches, ObjectiveSlippage)
+	}
+
+	return quality
+}
+
+// prune stops waiting on orders that did not finish in time, such as orders valid
+// until cancelled, at most once a minute
+func (q *brokerQuality) prune(now time.Time) {
+	if now.Sub(q.prunedAt) < time.Minute {
+		return
+	}
+	q.prunedAt = now
+
+	for id, pending := range q.pending {
+		if now.Sub(pending.placedAt) > pendingOrderTTL {
+			delete(q.pending, id)
+		}
+	}
+}
+
+// percentile returns the value at percentile p of sorted values
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
+
+// orderFinished reports whether a broker is done with an order of the status
+func orderFinished(status OrderStatus) bool {
+	return status == Executed || status == Cancelled || status == Rejected
+}
+
+// referencePrice returns the price slippage is measured from, the order price or
+// the trigger price of stop-loss market orders. Market orders placed without a
+// price have none.
+func referencePrice(request *OrderRequest) float64 {
+	if request.Price > 0 {
+		return request.Price
+	}
+	return request.TriggerPrice
+}
+
+// fillSlippage returns how much worse than the reference price a fill was, as a
+// fraction of the reference price
+func fillSlippage(transactionType TransactionType, reference, price float64) float64 {
+	if transactionType == Sell {
+		return (reference - price) / reference
+	}
+	return (price - reference) / reference
+}
+
+// TrackedBroker is a broker whose orders are recorded by a broker quality tracker.
+// Wrap brokers in it within any throttle, for the time orders wait on the
+// throttle not to count as broker latency.
+type TrackedBroker struct {
+	name    string
+	broker  BrokerAdapter
+	tracker *BrokerQualityTracker
+}
+
+// NewTrackedBroker wraps a broker so its orders are recorded by tracker under name
+func NewTrackedBroker(name string, broker BrokerAdapter, tracker *BrokerQualityTracker) *TrackedBroker {
+	return &TrackedBroker{
+		name:    name,
+		broker:  broker,
+		tracker: tracker,
+	}
+}
+
+// PlaceOrder places an order, recording its round trip and outcome
+func (b *TrackedBroker) PlaceOrder(ctx context.Context, request *OrderRequest) (*OrderResponse, error) {
+	start := time.Now()
+	response, err := b.broker.PlaceOrder(ctx, request)
+	b.tracker.RecordPlacement(b.name, request, response, time.Since(start), err)
+	return response, err
+}
+
+// ModifyOrder modifies an order
+func (b *TrackedBroker) ModifyOrder(ctx context.Context, orderID string, request *OrderRequest) (*OrderResponse, error) {
+	response, err := b.broker.ModifyOrder(ctx, orderID, request)
+	if err == nil && response != nil {
+		b.tracker.RecordOrderUpdate(b.name, response.Order)
+	}
+	return response, err
+}
+
+// CancelOrder cancels an order, recording its fill
+func (b *TrackedBroker) CancelOrder(ctx context.Context, orderID string) (*OrderResponse, error) {
+	response, err := b.broker.CancelOrder(ctx, orderID)
+	if err == nil && response != nil {
+		b.tracker.RecordOrderUpdate(b.name, response.Order)
+	}
+	return response, err
+}
+
+// GetOrderStatus gets the status of an order, recording its fill once finished
+func (b *TrackedBroker) GetOrderStatus(ctx context.Context, orderID string) (*Order, error) {
+	order, err := b.broker.GetOrderStatus(ctx, orderID)
+	if err == nil {
+		b.tracker.RecordOrderUpdate(b.name, order)
+	}
+	return order, err
+}
+
+// GetOrders gets all orders, recording the fills of those finished
+func (b *TrackedBroker) GetOrders(ctx context.Context) ([]*Order, error) {
+	orders, err := b.broker.GetOrders(ctx)
+	if err == nil {
+		for _, order := range orders {
+			b.tracker.RecordOrderUpdate(b.name, order)
+		}
+	}
+	return orders, err
+}
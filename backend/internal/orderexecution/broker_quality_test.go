@@ -0,0 +1,89 @@
+package orderexecution
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestBrokerQualityTracker tests measuring brokers against their SLO and feeding
+// the measures to the venue router
+func TestBrokerQualityTracker(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewBrokerQualityTracker(BrokerSLO{
+		LatencyPercentile: 0.99,
+		MaxLatency:        100 * time.Millisecond,
+		MaxRejectRate:     0.1,
+		MinFillRate:       0.9,
+		MaxSlippage:       0.001,
+	}, 0)
+	router := NewVenueRouter()
+	router.AddVenue("MOCK1", NewMockBrokerAdapter(), []string{"NSE"}, 1)
+	router.AddVenue("MOCK2", NewMockBrokerAdapter(), []string{"NSE"}, 1)
+	tracker.SetVenueRouter(router)
+	
+	request := &OrderRequest{
+		Symbol:          "RELIANCE-EQ",
+		Quantity:        100,
+		Price:           2500.0,
+		OrderType:       Limit,
+		TransactionType: Buy,
+		Validity:        Day,
+		Exchange:        "NSE",
+		Product:         Normal,
+	}
+	
+	// Orders placed through a tracked broker are recorded, and their fills once
+	// the broker is done with them
+	mockBroker := NewMockBrokerAdapter()
+	broker := NewTrackedBroker("MOCK1", mockBroker, tracker)
+	response, err := broker.PlaceOrder(ctx, request)
+	if err != nil || !response.Status {
+		t.Fatalf("Failed to place order through tracked broker: %v", err)
+	}
+	broker.GetOrderStatus(ctx, response.Order.ID)
+	mockBroker.SimulateOrderStatusChange(response.Order.ID, Executed)
+	broker.GetOrderStatus(ctx, response.Order.ID)
+	broker.GetOrderStatus(ctx, response.Order.ID)
+	
+	quality, ok := tracker.Quality("MOCK1")
+	if !ok || quality.Orders != 1 || quality.Fills != 1 || quality.FillRate != 1 {
+		t.Errorf("Expected one order filled in full, got %+v", quality)
+	}
+	if !quality.MeetsSLO() {
+		t.Errorf("Expected MOCK1 to meet the SLO, breached %v", quality.Breaches)
+	}
+	
+	// Slow and rejected orders breach the SLO, and rank the broker lower
+	tracker.RecordPlacement("MOCK2", request, &OrderResponse{Status: true, Order: &Order{ID: "order1", Status: Open}}, 50*time.Millisecond, nil)
+	tracker.RecordPlacement("MOCK2", request, &OrderResponse{Status: false, Error: "insufficient margin"}, 250*time.Millisecond, nil)
+	quality, _ = tracker.Quality("MOCK2")
+	if quality.RejectRate != 0.5 || quality.MedianLatency != 50*time.Millisecond || quality.Latency != 250*time.Millisecond {
+		t.Errorf("Expected half the orders rejected with a median latency of 50ms, got %+v", quality)
+	}
+	if len(quality.Breaches) != 2 || quality.Breaches[0] != ObjectiveLatency || quality.Breaches[1] != ObjectiveRejectRate {
+		t.Errorf("Expected latency and reject rate breached, got %v", quality.Breaches)
+	}
+	if name, err := router.GetRoutingDecision(request); err != nil || name != "MOCK1" {
+		t.Errorf("Expected MOCK1 for its lower reject rate, got %s (%v)", name, err)
+	}
+	
+	// Slippage is measured from the order price, against the side of the order
+	tracker.RecordOrderUpdate("MOCK2", &Order{ID: "order1", Status: Executed, Quantity: 100, FilledQuantity: 80, AveragePrice: 2505.0})
+	quality, _ = tracker.Quality("MOCK2")
+	if math.Abs(quality.AverageSlippage-0.002) > 1e-9 || quality.FillRate != 0.8 {
+		t.Errorf("Expected 0.2%% slippage on 80%% filled, got %v on %v", quality.AverageSlippage, quality.FillRate)
+	}
+	if len(quality.Breaches) != 4 {
+		t.Errorf("Expected every objective breached, got %v", quality.Breaches)
+	}
+	if fillSlippage(Sell, 2500.0, 2505.0) >= 0 {
+		t.Error("Expected selling above the order price to be price improvement")
+	}
+	
+	// Every broker is reported, by name
+	if qualities := tracker.Qualities(); len(qualities) != 2 || qualities[0].Broker != "MOCK1" || qualities[1].Broker != "MOCK2" {
+		t.Errorf("Expected MOCK1 and MOCK2 reported, got %+v", qualities)
+	}
+}
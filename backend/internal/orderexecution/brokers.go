@@ -11,6 +11,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"trading-platform/backend/internal/tracing"
 )
 
 // XTSBrokerAdapter implements the BrokerAdapter interface for XTS
@@ -148,7 +150,7 @@ func NewXTSBrokerAdapter(baseURL, apiKey, apiSecret, clientCode string, isIntera
 		apiKey:        apiKey,
 		apiSecret:     apiSecret,
 		clientCode:    clientCode,
-		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		httpClient:    &http.Client{Timeout: 30 * time.Second, Transport: tracing.Transport(http.DefaultTransport)}, // Requests traced
 		isInteractive: isInteractive,
 	}
 }
@@ -784,14 +786,18 @@ func (f *BrokerFactory) RegisterBroker(name string, creator func(config map[stri
 	f.brokers[name] = creator
 }
 
-// CreateBroker creates a broker adapter
+// CreateBroker creates a broker adapter, its calls being counted and timed
 func (f *BrokerFactory) CreateBroker(name string, config map[string]string) (BrokerAdapter, error) {
 	creator, ok := f.brokers[name]
 	if !ok {
 		return nil, fmt.Errorf("broker not found: %s", name)
 	}
 
-	return creator(config)
+	adapter, err := creator(config)
+	if err != nil {
+		return nil, err
+	}
+	return instrumentBroker(name, adapter), nil
 }
 
 // ListBrokers returns a list of available broker names
@@ -3,9 +3,15 @@ package orderexecution
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"trading-platform/backend/internal/metrics"
+	"trading-platform/backend/internal/tracing"
 )
 
 // OrderType represents the type of order
@@ -83,21 +89,34 @@ type Order struct {
 	ParentOrderID   string          `json:"parentOrderID,omitempty"`
 	StrategyID      string          `json:"strategyID,omitempty"`
 	Tags            []string        `json:"tags,omitempty"`
+	Algorithm       string          `json:"algorithm,omitempty"`     // Set on parent orders worked by an execution algorithm
+	ChildOrderIDs   []string        `json:"childOrderIDs,omitempty"` // Slices placed for a parent order
 }
 
 // OrderRequest represents a request to place an order
 type OrderRequest struct {
-	Symbol          string          `json:"symbol"`
-	Quantity        int             `json:"quantity"`
-	Price           float64         `json:"price"`
-	OrderType       OrderType       `json:"orderType"`
-	TransactionType TransactionType `json:"transactionType"`
-	Validity        ValidityType    `json:"validity"`
-	TriggerPrice    float64         `json:"triggerPrice,omitempty"`
-	Exchange        string          `json:"exchange"`
-	Product         ProductType     `json:"product"`
-	StrategyID      string          `json:"strategyID,omitempty"`
-	Tags            []string        `json:"tags,omitempty"`
+	Symbol          string           `json:"symbol"`
+	Quantity        int              `json:"quantity"`
+	Price           float64          `json:"price"`
+	OrderType       OrderType        `json:"orderType"`
+	TransactionType TransactionType  `json:"transactionType"`
+	Validity        ValidityType     `json:"validity"`
+	TriggerPrice    float64          `json:"triggerPrice,omitempty"`
+	Exchange        string           `json:"exchange"`
+	Product         ProductType      `json:"product"`
+	StrategyID      string           `json:"strategyID,omitempty"`
+	UserID          string           `json:"userID,omitempty"` // Paces the order with the user's other orders when brokers are throttled
+	Tags            []string         `json:"tags,omitempty"`
+	PriceProtection *PriceProtection `json:"priceProtection,omitempty"` // Limits the price of each slice when the order is sliced
+	LotSize         int              `json:"lotSize,omitempty"`         // Slices and child orders are whole lots of it, 1 when unset
+}
+
+// lotSize returns the request's lot size, 1 when it is not set
+func (r *OrderRequest) lotSize() int {
+	if r.LotSize > 0 {
+		return r.LotSize
+	}
+	return 1
 }
 
 // OrderResponse represents a response after placing an order
@@ -124,14 +143,21 @@ type SmartRouter interface {
 	RouteOrder(ctx context.Context, request *OrderRequest) (BrokerAdapter, error)
 }
 
+// ErrNoRoute is returned for orders no broker was found for, which were never
+// sent to one
+var ErrNoRoute = errors.New("no broker to route the order to")
+
 // OrderExecutionEngine is the main engine for executing orders
 type OrderExecutionEngine struct {
-	brokers       map[string]BrokerAdapter
-	smartRouter   SmartRouter
-	orders        map[string]*Order
-	ordersMutex   sync.RWMutex
-	callbacks     []OrderUpdateCallback
-	callbackMutex sync.RWMutex
+	brokers         map[string]BrokerAdapter
+	smartRouter     SmartRouter
+	orders          map[string]*Order
+	ordersMutex     sync.RWMutex
+	callbacks       []OrderUpdateCallback
+	callbackMutex   sync.RWMutex
+	store           OrderStore      // Persists working orders when set
+	storeMutex      sync.RWMutex
+	restoredParents map[string]bool // Parents restored without their algorithm, guarded by ordersMutex
 }
 
 // NewOrderExecutionEngine creates a new order execution engine
@@ -157,23 +183,36 @@ func (e *OrderExecutionEngine) RegisterCallback(callback OrderUpdateCallback) {
 
 // ExecuteOrder executes an order using the smart router
 func (e *OrderExecutionEngine) ExecuteOrder(ctx context.Context, request *OrderRequest) (*OrderResponse, error) {
+	ctx, span := tracing.Start(ctx, "orderexecution", "ExecuteOrder",
+		attribute.String("order.symbol", request.Symbol), attribute.String("order.exchange", request.Exchange))
+	defer span.End()
+
 	// Use smart router to determine the best broker for this order
 	broker, err := e.smartRouter.RouteOrder(ctx, request)
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(ctx, fmt.Errorf("%w: %v", ErrNoRoute, err))
 	}
 
 	// Place the order with the selected broker
 	response, err := broker.PlaceOrder(ctx, request)
+	metrics.ObserveOrderEvent(metrics.OrderPlaced)
 	if err != nil {
-		return nil, err
+		metrics.ObserveOrderEvent(metrics.OrderRejected)
+		return nil, tracing.RecordError(ctx, err)
+	}
+	if !response.Status {
+		metrics.ObserveOrderEvent(metrics.OrderRejected)
 	}
 
 	// Store the order in our local cache
 	if response.Status && response.Order != nil {
 		e.ordersMutex.Lock()
+		previous := e.statusOf(response.Order.ID)
 		e.orders[response.Order.ID] = response.Order
 		e.ordersMutex.Unlock()
+		observeOrderEvent(previous, response.Order)
+
+		e.persistOrders(response.Order)
 
 		// Notify callbacks
 		e.notifyOrderUpdate(response.Order)
@@ -218,6 +257,9 @@ func (e *OrderExecutionEngine) ExecuteOrderBatch(ctx context.Context, requests [
 
 // ModifyOrder modifies an existing order
 func (e *OrderExecutionEngine) ModifyOrder(ctx context.Context, orderID string, request *OrderRequest) (*OrderResponse, error) {
+	ctx, span := tracing.Start(ctx, "orderexecution", "ModifyOrder", attribute.String("order.id", orderID))
+	defer span.End()
+	
 	e.ordersMutex.RLock()
 	order, exists := e.orders[orderID]
 	e.ordersMutex.RUnlock()
@@ -226,6 +268,11 @@ func (e *OrderExecutionEngine) ModifyOrder(ctx context.Context, orderID string,
 		return nil, errors.New("order not found")
 	}
 	
+	// Parent orders are worked by their algorithm, only its slices are at a broker
+	if order.Algorithm != "" {
+		return nil, fmt.Errorf("order %s is worked by the %s algorithm and cannot be modified directly", orderID, order.Algorithm)
+	}
+	
 	// Find the broker that placed this order
 	broker, exists := e.brokers[order.Exchange]
 	if !exists {
@@ -235,14 +282,18 @@ func (e *OrderExecutionEngine) ModifyOrder(ctx context.Context, orderID string,
 	// Modify the order with the broker
 	response, err := broker.ModifyOrder(ctx, orderID, request)
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(ctx, err)
 	}
 	
 	// Update our local cache
 	if response.Status && response.Order != nil {
 		e.ordersMutex.Lock()
+		previous := e.statusOf(response.Order.ID)
 		e.orders[response.Order.ID] = response.Order
 		e.ordersMutex.Unlock()
+		observeOrderEvent(previous, response.Order)
+		
+		e.persistOrders(response.Order)
 		
 		// Notify callbacks
 		e.notifyOrderUpdate(response.Order)
@@ -253,6 +304,9 @@ func (e *OrderExecutionEngine) ModifyOrder(ctx context.Context, orderID string,
 
 // CancelOrder cancels an existing order
 func (e *OrderExecutionEngine) CancelOrder(ctx context.Context, orderID string) (*OrderResponse, error) {
+	ctx, span := tracing.Start(ctx, "orderexecution", "CancelOrder", attribute.String("order.id", orderID))
+	defer span.End()
+	
 	e.ordersMutex.RLock()
 	order, exists := e.orders[orderID]
 	e.ordersMutex.RUnlock()
@@ -261,6 +315,11 @@ func (e *OrderExecutionEngine) CancelOrder(ctx context.Context, orderID string)
 		return nil, errors.New("order not found")
 	}
 	
+	// Parent orders are worked by their algorithm, only its slices are at a broker
+	if order.Algorithm != "" {
+		return nil, fmt.Errorf("order %s is worked by the %s algorithm and cannot be cancelled directly", orderID, order.Algorithm)
+	}
+	
 	// Find the broker that placed this order
 	broker, exists := e.brokers[order.Exchange]
 	if !exists {
@@ -270,14 +329,18 @@ func (e *OrderExecutionEngine) CancelOrder(ctx context.Context, orderID string)
 	// Cancel the order with the broker
 	response, err := broker.CancelOrder(ctx, orderID)
 	if err != nil {
-		return nil, err
+		return nil, tracing.RecordError(ctx, err)
 	}
 	
 	// Update our local cache
 	if response.Status && response.Order != nil {
 		e.ordersMutex.Lock()
+		previous := e.statusOf(response.Order.ID)
 		e.orders[response.Order.ID] = response.Order
 		e.ordersMutex.Unlock()
+		observeOrderEvent(previous, response.Order)
+		
+		e.persistOrders(response.Order)
 		
 		// Notify callbacks
 		e.notifyOrderUpdate(response.Order)
@@ -314,6 +377,9 @@ func (e *OrderExecutionEngine) GetOrders() []*Order {
 
 // SyncOrderStatus synchronizes the order status with the broker
 func (e *OrderExecutionEngine) SyncOrderStatus(ctx context.Context, orderID string) error {
+	ctx, span := tracing.Start(ctx, "orderexecution", "SyncOrderStatus", attribute.String("order.id", orderID))
+	defer span.End()
+	
 	e.ordersMutex.RLock()
 	order, exists := e.orders[orderID]
 	e.ordersMutex.RUnlock()
@@ -322,6 +388,17 @@ func (e *OrderExecutionEngine) SyncOrderStatus(ctx context.Context, orderID stri
 		return errors.New("order not found")
 	}
 	
+	// Parent orders take their status from their slices
+	if order.Algorithm != "" {
+		e.ordersMutex.Lock()
+		e.aggregateChildOrders(order)
+		e.ordersMutex.Unlock()
+		
+		e.persistOrders(order)
+		e.notifyOrderUpdate(order)
+		return nil
+	}
+	
 	// Find the broker that placed this order
 	broker, exists := e.brokers[order.Exchange]
 	if !exists {
@@ -331,20 +408,215 @@ func (e *OrderExecutionEngine) SyncOrderStatus(ctx context.Context, orderID stri
 	// Get the latest order status from the broker
 	updatedOrder, err := broker.GetOrderStatus(ctx, orderID)
 	if err != nil {
-		return err
+		return tracing.RecordError(ctx, err)
 	}
 	
-	// Update our local cache
+	// Update our local cache, keeping the slice linked to its parent order
 	e.ordersMutex.Lock()
+	updatedOrder.ParentOrderID = order.ParentOrderID
+	previous := e.statusOf(orderID)
 	e.orders[orderID] = updatedOrder
+	parent := e.orders[order.ParentOrderID]
+	if parent != nil {
+		e.aggregateChildOrders(parent)
+	}
 	e.ordersMutex.Unlock()
+	observeOrderEvent(previous, updatedOrder)
+	
+	if parent != nil {
+		e.persistOrders(updatedOrder, parent)
+		e.settleRestoredParent(parent)
+	} else {
+		e.persistOrders(updatedOrder)
+	}
 	
 	// Notify callbacks
 	e.notifyOrderUpdate(updatedOrder)
+	if parent != nil {
+		e.notifyOrderUpdate(parent)
+	}
 	
 	return nil
 }
 
+// FindOrderByTag looks up the order carrying a tag at the broker of an exchange,
+// caching it when found. The order is nil when the broker has none with the tag.
+func (e *OrderExecutionEngine) FindOrderByTag(ctx context.Context, exchange string, tag string) (*Order, error) {
+	ctx, span := tracing.Start(ctx, "orderexecution", "FindOrderByTag", attribute.String("order.exchange", exchange))
+	defer span.End()
+	
+	broker, exists := e.brokers[exchange]
+	if !exists {
+		return nil, errors.New("broker not found for this exchange")
+	}
+	
+	orders, err := broker.GetOrders(ctx)
+	if err != nil {
+		return nil, tracing.RecordError(ctx, err)
+	}
+	
+	for _, order := range orders {
+		for _, orderTag := range order.Tags {
+			if orderTag != tag {
+				continue
+			}
+			
+			e.ordersMutex.Lock()
+			previous := e.statusOf(order.ID)
+			e.orders[order.ID] = order
+			e.ordersMutex.Unlock()
+			observeOrderEvent(previous, order)
+			
+			e.persistOrders(order)
+			e.notifyOrderUpdate(order)
+			return order, nil
+		}
+	}
+	
+	return nil, nil
+}
+
+// createParentOrder stores the parent order of an execution algorithm, which is never
+// sent to a broker itself
+func (e *OrderExecutionEngine) createParentOrder(request *OrderRequest, algorithm string) *Order {
+	now := time.Now()
+	parent := &Order{
+		ID:              fmt.Sprintf("%s-%s", algorithm, uuid.New().String()),
+		Symbol:          request.Symbol,
+		Quantity:        request.Quantity,
+		Price:           request.Price,
+		OrderType:       request.OrderType,
+		TransactionType: request.TransactionType,
+		Status:          Open,
+		PlacedAt:        now,
+		UpdatedAt:       now,
+		Validity:        request.Validity,
+		TriggerPrice:    request.TriggerPrice,
+		Exchange:        request.Exchange,
+		Product:         request.Product,
+		StrategyID:      request.StrategyID,
+		Tags:            append([]string{}, request.Tags...),
+		Algorithm:       algorithm,
+	}
+	
+	e.ordersMutex.Lock()
+	e.orders[parent.ID] = parent
+	e.ordersMutex.Unlock()
+	
+	e.persistOrders(parent)
+	e.notifyOrderUpdate(parent)
+	return parent
+}
+
+// finishParentOrder records that an execution algorithm has stopped placing slices
+// and returns the parent order as it then stands. A parent with nothing placed is
+// finished, slices still open can fill later.
+func (e *OrderExecutionEngine) finishParentOrder(parent *Order, placed, scheduled int, cancelled bool) *OrderResponse {
+	e.ordersMutex.Lock()
+	if parent.FilledQuantity == 0 && placed == 0 {
+		if cancelled {
+			parent.Status = Cancelled
+			parent.Message = "Cancelled before any slice was placed"
+		} else {
+			parent.Status = Rejected
+			parent.Message = "Failed to place any slice"
+		}
+		parent.UpdatedAt = time.Now()
+	} else if cancelled {
+		parent.Message = fmt.Sprintf("Cancelled after %d of %d slices", placed, scheduled)
+	}
+	result := *parent
+	result.ChildOrderIDs = append([]string(nil), parent.ChildOrderIDs...)
+	e.ordersMutex.Unlock()
+	
+	e.persistOrders(parent)
+	e.notifyOrderUpdate(parent)
+	
+	response := &OrderResponse{
+		Order:  &result,
+		Status: placed > 0,
+	}
+	if !response.Status {
+		response.Error = result.Message
+	}
+	
+	return response
+}
+
+// sliceRequest creates the request for one slice of an algorithm's parent order
+func sliceRequest(request *OrderRequest, quantity int, tag string) *OrderRequest {
+	slice := &OrderRequest{
+		Symbol:          request.Symbol,
+		Quantity:        quantity,
+		Price:           request.Price,
+		OrderType:       request.OrderType,
+		TransactionType: request.TransactionType,
+		Validity:        request.Validity,
+		TriggerPrice:    request.TriggerPrice,
+		Exchange:        request.Exchange,
+		Product:         request.Product,
+		StrategyID:      request.StrategyID,
+		UserID:          request.UserID,
+		Tags:            append([]string{}, request.Tags...),
+		LotSize:         request.LotSize,
+	}
+	
+	// Add a tag to indicate which slice of the parent order this is
+	slice.Tags = append(slice.Tags, tag)
+	return slice
+}
+
+// addChildOrder links a slice placed by an execution algorithm to its parent order
+// and updates the parent's fills
+func (e *OrderExecutionEngine) addChildOrder(parentID string, child *Order) error {
+	e.ordersMutex.Lock()
+	parent, exists := e.orders[parentID]
+	if !exists {
+		e.ordersMutex.Unlock()
+		return fmt.Errorf("parent order %s not found", parentID)
+	}
+	
+	child.ParentOrderID = parentID
+	parent.ChildOrderIDs = append(parent.ChildOrderIDs, child.ID)
+	e.aggregateChildOrders(parent)
+	e.ordersMutex.Unlock()
+	
+	e.persistOrders(child, parent)
+	e.notifyOrderUpdate(parent)
+	return nil
+}
+
+// aggregateChildOrders sets a parent order's filled quantity and average price from
+// its slices. A parent is only executed once all of its quantity is filled, so one
+// that has not filled anything keeps the status its algorithm gave it. The orders
+// lock must be held.
+func (e *OrderExecutionEngine) aggregateChildOrders(parent *Order) {
+	filled := 0
+	value := 0.0
+	for _, childID := range parent.ChildOrderIDs {
+		child, exists := e.orders[childID]
+		if !exists {
+			continue
+		}
+		filled += child.FilledQuantity
+		value += float64(child.FilledQuantity) * child.AveragePrice
+	}
+	
+	parent.FilledQuantity = filled
+	parent.AveragePrice = 0
+	if filled > 0 {
+		parent.AveragePrice = value / float64(filled)
+	}
+	
+	switch {
+	case filled >= parent.Quantity:
+		parent.Status = Executed
+	case filled > 0:
+		parent.Status = PartiallyExecuted
+	}
+	parent.UpdatedAt = time.Now()
+}
+
 // SyncAllOrders synchronizes all orders with their respective brokers
 func (e *OrderExecutionEngine) SyncAllOrders(ctx context.Context) error {
 	var wg sync.WaitGroup
@@ -389,6 +661,32 @@ func (e *OrderExecutionEngine) SyncAllOrders(ctx context.Context) error {
 	return nil
 }
 
+// statusOf returns the status of a stored order, empty when it is not stored. The
+// orders lock must be held.
+func (e *OrderExecutionEngine) statusOf(orderID string) OrderStatus {
+	if order, exists := e.orders[orderID]; exists {
+		return order.Status
+	}
+	return ""
+}
+
+// observeOrderEvent counts an order filled, rejected or cancelled for the business
+// metrics as it moves there from its previous status. Parent orders are not
+// counted, only the slices sent to brokers are.
+func observeOrderEvent(previous OrderStatus, order *Order) {
+	if order.Algorithm != "" || order.Status == previous {
+		return
+	}
+	switch order.Status {
+	case Executed:
+		metrics.ObserveOrderEvent(metrics.OrderFilled)
+	case Rejected:
+		metrics.ObserveOrderEvent(metrics.OrderRejected)
+	case Cancelled:
+		metrics.ObserveOrderEvent(metrics.OrderCancelled)
+	}
+}
+
 // notifyOrderUpdate notifies all registered callbacks about an order update
 func (e *OrderExecutionEngine) notifyOrderUpdate(order *Order) {
 	e.callbackMutex.RLock()
@@ -0,0 +1,75 @@
+package orderexecution
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"trading-platform/backend/internal/metrics"
+	"trading-platform/backend/internal/tracing"
+)
+
+// instrumentedBroker is a broker adapter tracing, counting and timing the calls
+// made to the adapter it wraps
+type instrumentedBroker struct {
+	adapter BrokerAdapter
+	name    string
+}
+
+// instrumentBroker wraps a broker adapter to trace, count and time its calls
+func instrumentBroker(name string, adapter BrokerAdapter) BrokerAdapter {
+	return &instrumentedBroker{adapter: adapter, name: name}
+}
+
+// begin starts a call to the adapter, returning the context of its span and a
+// function to call with the call's error once done, ending the span and
+// recording the call
+func (b *instrumentedBroker) begin(ctx context.Context, operation string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "broker", operation, attribute.String("broker", b.name))
+	return ctx, func(err error) {
+		tracing.RecordError(ctx, err)
+		span.End()
+		metrics.ObserveBrokerCall(b.name, operation, start, err)
+	}
+}
+
+// PlaceOrder implements BrokerAdapter
+func (b *instrumentedBroker) PlaceOrder(ctx context.Context, request *OrderRequest) (*OrderResponse, error) {
+	ctx, end := b.begin(ctx, "PlaceOrder")
+	response, err := b.adapter.PlaceOrder(ctx, request)
+	end(err)
+	return response, err
+}
+
+// ModifyOrder implements BrokerAdapter
+func (b *instrumentedBroker) ModifyOrder(ctx context.Context, orderID string, request *OrderRequest) (*OrderResponse, error) {
+	ctx, end := b.begin(ctx, "ModifyOrder")
+	response, err := b.adapter.ModifyOrder(ctx, orderID, request)
+	end(err)
+	return response, err
+}
+
+// CancelOrder implements BrokerAdapter
+func (b *instrumentedBroker) CancelOrder(ctx context.Context, orderID string) (*OrderResponse, error) {
+	ctx, end := b.begin(ctx, "CancelOrder")
+	response, err := b.adapter.CancelOrder(ctx, orderID)
+	end(err)
+	return response, err
+}
+
+// GetOrderStatus implements BrokerAdapter
+func (b *instrumentedBroker) GetOrderStatus(ctx context.Context, orderID string) (*Order, error) {
+	ctx, end := b.begin(ctx, "GetOrderStatus")
+	order, err := b.adapter.GetOrderStatus(ctx, orderID)
+	end(err)
+	return order, err
+}
+
+// GetOrders implements BrokerAdapter
+func (b *instrumentedBroker) GetOrders(ctx context.Context) ([]*Order, error) {
+	ctx, end := b.begin(ctx, "GetOrders")
+	orders, err := b.adapter.GetOrders(ctx)
+	end(err)
+	return orders, err
+}
@@ -0,0 +1,331 @@
+package orderexecution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// OrderStore persists the engine's working orders, so that orders still working at
+// brokers and the parent orders of execution algorithms survive a restart
+type OrderStore interface {
+	SaveOrder(order *Order) error
+	DeleteOrder(orderID string) error
+	LoadOrders() ([]*Order, error)
+}
+
+// MemoryOrderStore is an OrderStore keeping orders in memory
+type MemoryOrderStore struct {
+	orders map[string]Order
+	mutex  sync.Mutex
+}
+
+// NewMemoryOrderStore creates a new in-memory order store
+func NewMemoryOrderStore() *MemoryOrderStore {
+	return &MemoryOrderStore{
+		orders: make(map[string]Order),
+	}
+}
+
+// SaveOrder saves a copy of the order
+func (s *MemoryOrderStore) SaveOrder(order *Order) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.orders[order.ID] = copyOrder(order)
+	return nil
+}
+
+// DeleteOrder deletes an order, if it is stored
+func (s *MemoryOrderStore) DeleteOrder(orderID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.orders, orderID)
+	return nil
+}
+
+// LoadOrders returns copies of the stored orders
+func (s *MemoryOrderStore) LoadOrders() ([]*Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	orders := make([]*Order, 0, len(s.orders))
+	for _, order := range s.orders {
+		loaded := copyOrder(&order)
+		orders = append(orders, &loaded)
+	}
+
+	return orders, nil
+}
+
+// FileOrderStore is an OrderStore keeping orders in a JSON file. The file is
+// rewritten on every change through a temporary file, so a crash while saving
+// leaves the previous orders in place.
+type FileOrderStore struct {
+	path   string
+	orders map[string]Order
+	mutex  sync.Mutex
+}
+
+// NewFileOrderStore creates an order store in the file at path, loading the orders
+// already saved there
+func NewFileOrderStore(path string) (*FileOrderStore, error) {
+	store := &FileOrderStore{
+		path:   path,
+		orders: make(map[string]Order),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read order store: %w", err)
+	}
+
+	var orders []Order
+	if err := json.Unmarshal(data, &orders); err != nil {
+		return nil, fmt.Errorf("failed to decode order store: %w", err)
+	}
+	for _, order := range orders {
+		store.orders[order.ID] = order
+	}
+
+	return store, nil
+}
+
+// SaveOrder saves the order and rewrites the file
+func (s *FileOrderStore) SaveOrder(order *Order) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous, existed := s.orders[order.ID]
+	s.orders[order.ID] = copyOrder(order)
+	if err := s.write(); err != nil {
+		if existed {
+			s.orders[order.ID] = previous
+		} else {
+			delete(s.orders, order.ID)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DeleteOrder deletes an order, if it is stored, and rewrites the file
+func (s *FileOrderStore) DeleteOrder(orderID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous, exists := s.orders[orderID]
+	if !exists {
+		return nil
+	}
+
+	delete(s.orders, orderID)
+	if err := s.write(); err != nil {
+		s.orders[orderID] = previous
+		return err
+	}
+
+	return nil
+}
+
+// LoadOrders returns copies of the stored orders
+func (s *FileOrderStore) LoadOrders() ([]*Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	orders := make([]*Order, 0, len(s.orders))
+	for _, order := range s.orders {
+		loaded := copyOrder(&order)
+		orders = append(orders, &loaded)
+	}
+
+	return orders, nil
+}
+
+// write replaces the file with the stored orders. The lock must be held.
+func (s *FileOrderStore) write() error {
+	orders := make([]Order, 0, len(s.orders))
+	for _, order := range s.orders {
+		orders = append(orders, order)
+	}
+
+	data, err := json.Marshal(orders)
+	if err != nil {
+		return fmt.Errorf("failed to encode order store: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write order store: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write order store: %w", err)
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write order store: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write order store: %w", err)
+	}
+
+	if err := os.Rename(temp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write order store: %w", err)
+	}
+
+	return nil
+}
+
+// copyOrder returns a copy of an order that shares none of its slices
+func copyOrder(order *Order) Order {
+	result := *order
+	result.Tags = append([]string(nil), order.Tags...)
+	result.ChildOrderIDs = append([]string(nil), order.ChildOrderIDs...)
+	return result
+}
+
+// SetOrderStore sets the store the engine persists its working orders to. Orders
+// are saved while they are working and deleted from the store once final.
+func (e *OrderExecutionEngine) SetOrderStore(store OrderStore) {
+	e.storeMutex.Lock()
+	defer e.storeMutex.Unlock()
+	e.store = store
+}
+
+// RestoreOrders loads the working orders saved before a restart and syncs them with
+// their brokers, so that fills while the engine was down are picked up and orders
+// still working, such as protective stops, are tracked again. Execution algorithms
+// do not resume: a restored parent order keeps the slices it had placed and is
+// cancelled once they are all final without having filled its quantity. It returns
+// the number of orders restored.
+func (e *OrderExecutionEngine) RestoreOrders(ctx context.Context) (int, error) {
+	e.storeMutex.RLock()
+	store := e.store
+	e.storeMutex.RUnlock()
+
+	if store == nil {
+		return 0, errors.New("no order store set")
+	}
+
+	orders, err := store.LoadOrders()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load orders: %w", err)
+	}
+
+	// Orders the engine already knows are newer than the stored ones
+	var restored []*Order
+	e.ordersMutex.Lock()
+	for _, order := range orders {
+		if _, exists := e.orders[order.ID]; exists {
+			continue
+		}
+		e.orders[order.ID] = order
+		restored = append(restored, order)
+		if order.Algorithm != "" {
+			if e.restoredParents == nil {
+				e.restoredParents = make(map[string]bool)
+			}
+			e.restoredParents[order.ID] = true
+		}
+	}
+	e.ordersMutex.Unlock()
+
+	// Sync the orders at brokers, then the parents from their slices
+	for _, order := range restored {
+		if order.Algorithm != "" || isTerminalStatus(order.Status) {
+			continue
+		}
+		if err := e.SyncOrderStatus(ctx, order.ID); err != nil {
+			log.Printf("Error syncing restored order %s: %v", order.ID, err)
+		}
+	}
+	for _, order := range restored {
+		if order.Algorithm != "" {
+			e.settleRestoredParent(order)
+		}
+	}
+
+	log.Printf("Restored %d working orders", len(restored))
+	return len(restored), nil
+}
+
+// settleRestoredParent cancels a restored parent order once none of its slices can
+// fill any more, its algorithm no longer placing new ones. It is called again as
+// the slices still working are synced.
+func (e *OrderExecutionEngine) settleRestoredParent(parent *Order) {
+	e.ordersMutex.Lock()
+	if !e.restoredParents[parent.ID] {
+		e.ordersMutex.Unlock()
+		return
+	}
+	e.aggregateChildOrders(parent)
+	working := false
+	for _, childID := range parent.ChildOrderIDs {
+		child, exists := e.orders[childID]
+		if exists && !isTerminalStatus(child.Status) {
+			working = true
+		}
+	}
+	if !working && !isTerminalStatus(parent.Status) {
+		parent.Status = Cancelled
+		parent.Message = fmt.Sprintf("Interrupted by a restart after filling %d of %d", parent.FilledQuantity, parent.Quantity)
+		parent.UpdatedAt = time.Now()
+	}
+	if isTerminalStatus(parent.Status) {
+		delete(e.restoredParents, parent.ID)
+	}
+	e.ordersMutex.Unlock()
+
+	e.persistOrders(parent)
+	e.notifyOrderUpdate(parent)
+}
+
+// persistOrders saves working orders to the order store and deletes final ones
+// from it. Slices are kept while their parent order is working, the parent taking
+// its fills from them, and deleted with it. Failures are logged, the orders being
+// kept in memory regardless. The orders lock must not be held.
+func (e *OrderExecutionEngine) persistOrders(orders ...*Order) {
+	e.storeMutex.RLock()
+	defer e.storeMutex.RUnlock()
+
+	if e.store == nil {
+		return
+	}
+
+	for _, order := range orders {
+		e.ordersMutex.RLock()
+		snapshot := copyOrder(order)
+		working := !isTerminalStatus(snapshot.Status)
+		if parent, exists := e.orders[snapshot.ParentOrderID]; exists && !isTerminalStatus(parent.Status) {
+			working = true
+		}
+		e.ordersMutex.RUnlock()
+
+		if working {
+			if err := e.store.SaveOrder(&snapshot); err != nil {
+				log.Printf("Error persisting order %s: %v", snapshot.ID, err)
+			}
+			continue
+		}
+
+		for _, orderID := range append(snapshot.ChildOrderIDs, snapshot.ID) {
+			if err := e.store.DeleteOrder(orderID); err != nil {
+				log.Printf("Error deleting persisted order %s: %v", orderID, err)
+			}
+		}
+	}
+}
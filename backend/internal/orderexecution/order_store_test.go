@@ -0,0 +1,134 @@
+package orderexecution
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestOrderStore tests that working orders survive a restart of the engine
+func TestOrderStore(t *testing.T) {
+	dir, err := os.MkdirTemp("", "orderstore")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "orders.json")
+	
+	newEngine := func(broker *MockBrokerAdapter) *OrderExecutionEngine {
+		smartRouter := NewDefaultSmartRouter(BestPrice)
+		smartRouter.RegisterBroker("MOCK", broker)
+		engine := NewOrderExecutionEngine(smartRouter)
+		engine.RegisterBroker("NSE", broker)
+		
+		store, err := NewFileOrderStore(path)
+		if err != nil {
+			t.Fatalf("Failed to open the order store: %v", err)
+		}
+		engine.SetOrderStore(store)
+		return engine
+	}
+	storedOrders := func() map[string]Order {
+		store, err := NewFileOrderStore(path)
+		if err != nil {
+			t.Fatalf("Failed to open the order store: %v", err)
+		}
+		orders, _ := store.LoadOrders()
+		stored := make(map[string]Order)
+		for _, order := range orders {
+			stored[order.ID] = *order
+		}
+		return stored
+	}
+	
+	ctx := context.Background()
+	mockBroker := NewMockBrokerAdapter()
+	engine := newEngine(mockBroker)
+	
+	// A protective stop is persisted while it is working
+	stop, err := engine.ExecuteOrder(ctx, &OrderRequest{
+		Symbol:          "NIFTY-FUT",
+		Quantity:        50,
+		Price:           21900,
+		TriggerPrice:    21950,
+		OrderType:       StopLoss,
+		TransactionType: Buy,
+		Validity:        Day,
+		Exchange:        "NSE",
+		Product:         Intraday,
+		Tags:            []string{"stop_loss"},
+	})
+	if err != nil || !stop.Status {
+		t.Fatalf("Failed to place the stop order: %v", err)
+	}
+	
+	// A filled order is deleted from the store
+	filled, _ := engine.ExecuteOrder(ctx, &OrderRequest{Symbol: "TCS-EQ", Quantity: 10, Price: 3500, OrderType: Limit, TransactionType: Buy, Exchange: "NSE", Product: Normal})
+	mockBroker.SimulateOrderStatusChange(filled.Order.ID, Executed)
+	if err := engine.SyncOrderStatus(ctx, filled.Order.ID); err != nil {
+		t.Fatalf("Failed to sync the filled order: %v", err)
+	}
+	
+	// A parent order keeps its slices in the store, filled or not
+	twap, _ := NewTWAPAlgorithm(20*time.Millisecond, 2, 0)
+	response, err := twap.Execute(ctx, engine, &OrderRequest{Symbol: "INFY-EQ", Quantity: 100, Price: 1500, OrderType: Limit, TransactionType: Sell, Exchange: "NSE", Product: Normal})
+	if err != nil || len(response.Order.ChildOrderIDs) != 2 {
+		t.Fatalf("Failed to work the TWAP order: %v", err)
+	}
+	parentID := response.Order.ID
+	firstSlice, secondSlice := response.Order.ChildOrderIDs[0], response.Order.ChildOrderIDs[1]
+	mockBroker.SimulateOrderStatusChange(firstSlice, Executed)
+	if err := engine.SyncOrderStatus(ctx, firstSlice); err != nil {
+		t.Fatalf("Failed to sync the first slice: %v", err)
+	}
+	
+	stored := storedOrders()
+	if len(stored) != 4 {
+		t.Errorf("Expected the stop, the parent and its 2 slices to be stored, got %d orders", len(stored))
+	}
+	if _, exists := stored[filled.Order.ID]; exists {
+		t.Errorf("Expected the filled order to be deleted from the store")
+	}
+	if stored[firstSlice].ParentOrderID != parentID {
+		t.Errorf("Expected the stored slice to be linked to its parent")
+	}
+	
+	// After a restart the working orders are restored and synced with the broker
+	restarted := newEngine(mockBroker)
+	restored, err := restarted.RestoreOrders(ctx)
+	if err != nil {
+		t.Fatalf("Failed to restore orders: %v", err)
+	}
+	if restored != 4 {
+		t.Errorf("Expected 4 orders restored, got %d", restored)
+	}
+	if _, err := restarted.GetOrder(stop.Order.ID); err != nil {
+		t.Errorf("Expected the stop order to be tracked after the restart: %v", err)
+	}
+	parent, err := restarted.GetOrder(parentID)
+	if err != nil {
+		t.Fatalf("Expected the parent order to be restored: %v", err)
+	}
+	if parent.Status != PartiallyExecuted || parent.FilledQuantity != 50 {
+		t.Errorf("Expected the restored parent to be partially executed with 50 filled, got %s with %d", parent.Status, parent.FilledQuantity)
+	}
+	
+	// The parent is cancelled once its last working slice is done, its algorithm no longer running
+	if _, err := restarted.CancelOrder(ctx, secondSlice); err != nil {
+		t.Fatalf("Failed to cancel the second slice: %v", err)
+	}
+	if err := restarted.SyncOrderStatus(ctx, secondSlice); err != nil {
+		t.Fatalf("Failed to sync the second slice: %v", err)
+	}
+	parent, _ = restarted.GetOrder(parentID)
+	if parent.Status != Cancelled || parent.FilledQuantity != 50 {
+		t.Errorf("Expected the parent to be cancelled with 50 filled, got %s with %d", parent.Status, parent.FilledQuantity)
+	}
+	
+	stored = storedOrders()
+	if _, exists := stored[stop.Order.ID]; len(stored) != 1 || !exists {
+		t.Errorf("Expected only the stop order left in the store, got %d orders", len(stored))
+	}
+}
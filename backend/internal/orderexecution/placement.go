@@ -15,6 +15,7 @@ type OrderPlacementService struct {
 	engine           *OrderExecutionEngine
 	strategyManager  *StrategyManager
 	orderSplitter    *OrderSplitter
+	largeOrderSlicer *LargeOrderSlicer
 	rateLimiter      *RateLimiter
 	orderQueue       chan *OrderRequest
 	workers          int
@@ -185,6 +186,12 @@ func (s *OrderPlacementService) processOrder(request *OrderRequest) {
 		}
 	}
 	
+	// Slice orders above the freeze quantity or market impact threshold
+	if slicer := s.getLargeOrderSlicer(); slicer != nil && slicer.NeedsSlicing(request) {
+		response, err = slicer.Execute(ctx, s.engine, request)
+		goto done
+	}
+	
 	// Check if we need to split the order
 	if request.Quantity > s.orderSplitter.maxOrderSize {
 		// Split and execute the order
@@ -326,6 +333,11 @@ func (s *OrderPlacementService) PlaceOrderSync(ctx context.Context, request *Ord
 		}
 	}
 	
+	// Slice orders above the freeze quantity or market impact threshold
+	if slicer := s.getLargeOrderSlicer(); slicer != nil && slicer.NeedsSlicing(request) {
+		return slicer.Execute(ctx, s.engine, request)
+	}
+	
 	// Check if we need to split the order
 	if request.Quantity > s.orderSplitter.maxOrderSize {
 		// Split and execute the order
@@ -501,6 +513,23 @@ func (s *OrderPlacementService) SetOrderSplitterConfig(maxOrderSize int, timeBet
 	s.orderSplitter.timeBetweenChunks = timeBetweenChunks
 }
 
+// SetLargeOrderSlicer sets the slicer for orders above the freeze quantity or market
+// impact threshold, nil to only split orders by the order splitter's size
+func (s *OrderPlacementService) SetLargeOrderSlicer(slicer *LargeOrderSlicer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	s.largeOrderSlicer = slicer
+}
+
+// getLargeOrderSlicer returns the large order slicer, if any
+func (s *OrderPlacementService) getLargeOrderSlicer() *LargeOrderSlicer {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	return s.largeOrderSlicer
+}
+
 // SetRateLimiterConfig configures the rate limiter
 func (s *OrderPlacementService) SetRateLimiterConfig(maxOrdersPerSecond int) {
 	s.rateLimiter.mutex.Lock()
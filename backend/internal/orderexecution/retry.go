@@ -0,0 +1,312 @@
+package orderexecution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// RetryEventType represents a step in retrying an order
+type RetryEventType string
+
+// Retry event types
+const (
+	RetryPlaced            RetryEventType = "PLACED"
+	RetryRejected          RetryEventType = "REJECTED"
+	RetryRepriced          RetryEventType = "REPRICED"
+	RetryConvertedToMarket RetryEventType = "CONVERTED_TO_MARKET"
+	RetryFilled            RetryEventType = "FILLED"
+	RetryExhausted         RetryEventType = "EXHAUSTED"
+)
+
+// RetryEvent is emitted for every attempt at getting an order placed and filled
+type RetryEvent struct {
+	Type      RetryEventType
+	OrderID   string // Empty until the order has been placed
+	Symbol    string
+	Attempt   int
+	OrderType OrderType
+	Price     float64
+	Error     string
+	Timestamp time.Time
+}
+
+// RetryPolicy says how an order that is rejected or left unfilled is retried. A
+// limit order still open after the retry interval is repriced by the price buffer
+// towards the market, and once the retries are used up converted to a market
+// order if the policy allows.
+type RetryPolicy struct {
+	MaxRetries      int
+	RetryInterval   time.Duration // Between attempts, and how long a limit order is given to fill. 0 leaves placed orders working.
+	PriceBuffer     float64       // Percent of the original limit price each retry moves the price by
+	ConvertToMarket bool
+}
+
+// NewLegEntryRetryPolicy returns the retry policy of a leg's entry order, falling
+// back to the portfolio's entry buffer
+func NewLegEntryRetryPolicy(portfolio *models.Portfolio, leg *models.Leg) RetryPolicy {
+	buffer := leg.EntryPriceBuffer
+	if buffer == 0 {
+		buffer = portfolio.EntryPriceBuffer
+	}
+
+	return RetryPolicy{
+		MaxRetries:      leg.MaxEntryRetries,
+		RetryInterval:   time.Duration(leg.EntryRetryInterval) * time.Second,
+		PriceBuffer:     buffer,
+		ConvertToMarket: portfolio.ConvertToMarket,
+	}
+}
+
+// NewLegExitRetryPolicy returns the retry policy of a leg's exit order, falling back
+// to the portfolio's exit settings where the leg has none of its own
+func NewLegExitRetryPolicy(portfolio *models.Portfolio, leg *models.Leg) RetryPolicy {
+	policy := RetryPolicy{
+		MaxRetries:      leg.MaxExitRetries,
+		RetryInterval:   time.Duration(leg.ExitRetryInterval) * time.Second,
+		PriceBuffer:     leg.ExitPriceBuffer,
+		ConvertToMarket: portfolio.ConvertToMarket,
+	}
+	if policy.MaxRetries == 0 {
+		policy.MaxRetries = portfolio.MaxExitRetries
+	}
+	if policy.RetryInterval == 0 {
+		policy.RetryInterval = time.Duration(portfolio.ExitRetryInterval) * time.Second
+	}
+	if policy.PriceBuffer == 0 {
+		policy.PriceBuffer = portfolio.ExitPriceBuffer
+	}
+
+	return policy
+}
+
+// RetryResult is the outcome of placing an order with retries
+type RetryResult struct {
+	Order             *Order // The placed order, nil when it was never placed
+	Attempts          int
+	ConvertedToMarket bool
+	Error             string
+}
+
+// RetryExecutor places orders and retries them as their retry policy says,
+// emitting an event for every attempt
+type RetryExecutor struct {
+	engine    *OrderExecutionEngine
+	listeners []func(RetryEvent)
+	mutex     sync.RWMutex
+}
+
+// NewRetryExecutor creates a new retry executor placing orders through engine
+func NewRetryExecutor(engine *OrderExecutionEngine) *RetryExecutor {
+	return &RetryExecutor{
+		engine: engine,
+	}
+}
+
+// AddEventListener registers a listener called with every retry event
+func (r *RetryExecutor) AddEventListener(listener func(RetryEvent)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// Execute places an order, retrying rejections and repricing it while it is left
+// unfilled. It returns once the order is filled, left working or has used up its
+// retries. An attempt failing without the broker rejecting it, as on a timeout,
+// may still have been placed, so it is looked up at the broker by a tag every
+// attempt carries and only retried once it is known not to have been.
+func (r *RetryExecutor) Execute(ctx context.Context, request *OrderRequest, policy RetryPolicy) (*RetryResult, error) {
+	if request.Quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+
+	if policy.MaxRetries < 0 || policy.RetryInterval < 0 || policy.PriceBuffer < 0 {
+		return nil, errors.New("retry policy cannot be negative")
+	}
+
+	result := &RetryResult{}
+	attempt := *request
+	tag := "retry_" + uuid.New().String()
+	attempt.Tags = append(append([]string(nil), request.Tags...), tag)
+
+	// Place the order, retrying rejections
+	var order *Order
+	for order == nil {
+		if result.Attempts > 0 {
+			if err := r.wait(ctx, policy.RetryInterval); err != nil {
+				result.Error = fmt.Sprintf("retries cancelled: %v", err)
+				return result, nil
+			}
+			if attempt.OrderType == Limit {
+				attempt.Price = retryPrice(request, policy, result.Attempts)
+			}
+		}
+		result.Attempts++
+
+		response, err := r.engine.ExecuteOrder(ctx, &attempt)
+		if err != nil && !errors.Is(err, ErrNoRoute) {
+			placed, lookupErr := r.engine.FindOrderByTag(ctx, attempt.Exchange, tag)
+			if lookupErr != nil {
+				result.Error = fmt.Sprintf("order may have been placed, not retrying it: %v, looking it up: %v", err, lookupErr)
+				r.emit(RetryExhausted, &attempt, "", result.Attempts, result.Error)
+				return result, nil
+			}
+			if placed != nil {
+				response = &OrderResponse{Order: placed, Status: placed.Status != Rejected, Error: placed.Message}
+				err = nil
+			}
+		}
+		if err == nil && (!response.Status || response.Order == nil) {
+			err = fmt.Errorf("order rejected: %s", response.Error)
+		}
+		if err == nil {
+			order = response.Order
+			r.emit(RetryPlaced, &attempt, order.ID, result.Attempts, "")
+			break
+		}
+
+		r.emit(RetryRejected, &attempt, "", result.Attempts, err.Error())
+		result.Error = err.Error()
+		if result.Attempts <= policy.MaxRetries {
+			continue
+		}
+
+		// Out of retries, a limit order gets one last attempt at market
+		if !policy.ConvertToMarket || attempt.OrderType != Limit {
+			r.emit(RetryExhausted, &attempt, "", result.Attempts, result.Error)
+			return result, nil
+		}
+		attempt.OrderType = Market
+		attempt.Price = 0
+		result.ConvertedToMarket = true
+		r.emit(RetryConvertedToMarket, &attempt, "", result.Attempts, "")
+	}
+	result.Error = ""
+
+	// Give a limit order the retry interval to fill before repricing it
+	for attempt.OrderType == Limit && policy.RetryInterval > 0 {
+		if err := r.wait(ctx, policy.RetryInterval); err != nil {
+			break
+		}
+
+		if err := r.engine.SyncOrderStatus(ctx, order.ID); err != nil {
+			log.Printf("Error syncing order %s before retrying it: %v", order.ID, err)
+		}
+		status := r.orderStatus(order.ID)
+		if status == Executed {
+			r.emit(RetryFilled, &attempt, order.ID, result.Attempts, "")
+			break
+		}
+		if status == Cancelled || status == Rejected {
+			result.Error = fmt.Sprintf("order %s was %s before it filled", order.ID, status)
+			r.emit(RetryExhausted, &attempt, order.ID, result.Attempts, result.Error)
+			break
+		}
+
+		if result.Attempts <= policy.MaxRetries {
+			result.Attempts++
+			attempt.Price = retryPrice(request, policy, result.Attempts-1)
+			if err := r.modify(ctx, order.ID, &attempt); err != nil {
+				result.Error = err.Error()
+				r.emit(RetryExhausted, &attempt, order.ID, result.Attempts, result.Error)
+				break
+			}
+			r.emit(RetryRepriced, &attempt, order.ID, result.Attempts, "")
+			continue
+		}
+
+		if !policy.ConvertToMarket {
+			r.emit(RetryExhausted, &attempt, order.ID, result.Attempts, "left working at the last price")
+			break
+		}
+		attempt.OrderType = Market
+		attempt.Price = 0
+		if err := r.modify(ctx, order.ID, &attempt); err != nil {
+			result.Error = err.Error()
+			r.emit(RetryExhausted, &attempt, order.ID, result.Attempts, result.Error)
+			break
+		}
+		result.ConvertedToMarket = true
+		r.emit(RetryConvertedToMarket, &attempt, order.ID, result.Attempts, "")
+	}
+
+	// Modifications and syncs replace the cached order
+	if current, err := r.engine.GetOrder(order.ID); err == nil {
+		order = current
+	}
+	result.Order = order
+
+	return result, nil
+}
+
+// modify moves a working order to the attempt's price and order type
+func (r *RetryExecutor) modify(ctx context.Context, orderID string, attempt *OrderRequest) error {
+	response, err := r.engine.ModifyOrder(ctx, orderID, attempt)
+	if err != nil {
+		return fmt.Errorf("error modifying order %s: %w", orderID, err)
+	}
+	if !response.Status {
+		return fmt.Errorf("modification of order %s rejected: %s", orderID, response.Error)
+	}
+	return nil
+}
+
+// orderStatus returns the cached status of an order
+func (r *RetryExecutor) orderStatus(orderID string) OrderStatus {
+	r.engine.ordersMutex.RLock()
+	defer r.engine.ordersMutex.RUnlock()
+
+	order, exists := r.engine.orders[orderID]
+	if !exists {
+		return ""
+	}
+	return order.Status
+}
+
+// wait waits out a retry interval unless the context is done first
+func (r *RetryExecutor) wait(ctx context.Context, interval time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(interval):
+		return nil
+	}
+}
+
+// emit notifies the listeners of a retry event
+func (r *RetryExecutor) emit(eventType RetryEventType, attempt *OrderRequest, orderID string, number int, message string) {
+	event := RetryEvent{
+		Type:      eventType,
+		OrderID:   orderID,
+		Symbol:    attempt.Symbol,
+		Attempt:   number,
+		OrderType: attempt.OrderType,
+		Price:     attempt.Price,
+		Error:     message,
+		Timestamp: time.Now(),
+	}
+
+	r.mutex.RLock()
+	listeners := r.listeners
+	r.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		go listener(event)
+	}
+}
+
+// retryPrice returns the limit price of an order after a number of retries, each
+// moving the original price by the policy's buffer towards the market
+func retryPrice(request *OrderRequest, policy RetryPolicy, retries int) float64 {
+	adjustment := float64(retries) * policy.PriceBuffer / 100
+	if request.TransactionType == Buy {
+		return request.Price * (1 + adjustment)
+	}
+	return request.Price * (1 - adjustment)
+}
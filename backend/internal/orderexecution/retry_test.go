@@ -0,0 +1,205 @@
+package orderexecution
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// TestRetryExecutor tests retrying and repricing orders
+func TestRetryExecutor(t *testing.T) {
+	// Create an engine routing NFO orders to a mock broker, BFO has no broker
+	mockBroker := NewMockBrokerAdapter()
+	router := NewVenueRouter()
+	router.AddVenue("MOCK", mockBroker, []string{"NFO"}, 1)
+	engine := NewOrderExecutionEngine(router)
+	engine.RegisterBroker("NFO", mockBroker)
+	executor := NewRetryExecutor(engine)
+	
+	events := make(chan RetryEvent, 20)
+	executor.AddEventListener(func(event RetryEvent) {
+		events <- event
+	})
+	countEvents := func(expected int) map[RetryEventType]int {
+		counts := make(map[RetryEventType]int)
+		for i := 0; i < expected; i++ {
+			select {
+			case event := <-events:
+				counts[event.Type]++
+			case <-time.After(time.Second):
+				t.Errorf("Expected %d retry events, got %d", expected, i)
+				return counts
+			}
+		}
+		return counts
+	}
+	
+	// A limit buy left unfilled is repriced up by the buffer, then converted to market
+	request := &OrderRequest{
+		Symbol:          "NIFTY24JUN23000CE",
+		Quantity:        50,
+		Price:           100,
+		OrderType:       Limit,
+		TransactionType: Buy,
+		Validity:        Day,
+		Exchange:        "NFO",
+		Product:         Intraday,
+	}
+	policy := RetryPolicy{
+		MaxRetries:      2,
+		RetryInterval:   20 * time.Millisecond,
+		PriceBuffer:     1,
+		ConvertToMarket: true,
+	}
+	
+	result, err := executor.Execute(context.Background(), request, policy)
+	if err != nil {
+		t.Errorf("Error executing order: %v", err)
+		return
+	}
+	if result.Order == nil || result.Attempts != 3 || !result.ConvertedToMarket || result.Order.OrderType != Market {
+		t.Errorf("Expected the order to be converted to market after 3 attempts, got %d", result.Attempts)
+	}
+	if request.Price != 100 || request.OrderType != Limit {
+		t.Errorf("Expected the request to be left unchanged")
+	}
+	if price := retryPrice(request, policy, 2); math.Abs(price-102) > 0.0001 {
+		t.Errorf("Expected the second retry at 102, got %.2f", price)
+	}
+	counts := countEvents(4)
+	if counts[RetryPlaced] != 1 || counts[RetryRepriced] != 2 || counts[RetryConvertedToMarket] != 1 {
+		t.Errorf("Expected 1 placed, 2 repriced and 1 converted event, got %v", counts)
+	}
+	
+	// A limit order that fills is left alone
+	request.TransactionType = Sell
+	policy.RetryInterval = 50 * time.Millisecond
+	go func() {
+		event := <-events
+		mockBroker.SimulateOrderStatusChange(event.OrderID, Executed)
+	}()
+	result, err = executor.Execute(context.Background(), request, policy)
+	if err != nil {
+		t.Errorf("Error executing order: %v", err)
+		return
+	}
+	if result.Order == nil || result.Order.Status != Executed || result.Attempts != 1 || result.ConvertedToMarket {
+		t.Errorf("Expected the order to fill on its first attempt, got %d attempts", result.Attempts)
+	}
+	counts = countEvents(1)
+	if counts[RetryFilled] != 1 {
+		t.Errorf("Expected a filled event, got %v", counts)
+	}
+	
+	// A rejected order is retried, converted to market and then given up on
+	request.Exchange = "BFO"
+	policy.MaxRetries = 1
+	policy.RetryInterval = 0
+	result, err = executor.Execute(context.Background(), request, policy)
+	if err != nil {
+		t.Errorf("Error executing order: %v", err)
+		return
+	}
+	if result.Order != nil || result.Attempts != 3 || !result.ConvertedToMarket || result.Error == "" {
+		t.Errorf("Expected the order to be given up on after 3 attempts, got %d", result.Attempts)
+	}
+	counts = countEvents(5)
+	if counts[RetryRejected] != 3 || counts[RetryConvertedToMarket] != 1 || counts[RetryExhausted] != 1 {
+		t.Errorf("Expected 3 rejected, 1 converted and 1 exhausted event, got %v", counts)
+	}
+	
+	// Retry policies fall back to the portfolio's settings
+	portfolio := &models.Portfolio{ConvertToMarket: true, EntryPriceBuffer: 0.5, MaxExitRetries: 3, ExitRetryInterval: 2, ExitPriceBuffer: 1}
+	leg := &models.Leg{MaxEntryRetries: 2, EntryRetryInterval: 5, ExitRetryInterval: 1}
+	entry := NewLegEntryRetryPolicy(portfolio, leg)
+	if entry.MaxRetries != 2 || entry.RetryInterval != 5*time.Second || entry.PriceBuffer != 0.5 || !entry.ConvertToMarket {
+		t.Errorf("Unexpected entry retry policy: %+v", entry)
+	}
+	exit := NewLegExitRetryPolicy(portfolio, leg)
+	if exit.MaxRetries != 3 || exit.RetryInterval != time.Second || exit.PriceBuffer != 1 {
+		t.Errorf("Unexpected exit retry policy: %+v", exit)
+	}
+}
+
+// unansweredBroker is a mock broker failing to answer placements, as on a timeout
+type unansweredBroker struct {
+	*MockBrokerAdapter
+	failures  int   // Placements left to fail
+	placed    bool  // Whether the failed placements reached the broker
+	lookupErr error // Returned for looking up the broker's orders
+}
+
+func (b *unansweredBroker) PlaceOrder(ctx context.Context, request *OrderRequest) (*OrderResponse, error) {
+	if b.failures == 0 {
+		return b.MockBrokerAdapter.PlaceOrder(ctx, request)
+	}
+	b.failures--
+	if b.placed {
+		b.MockBrokerAdapter.PlaceOrder(ctx, request)
+	}
+	return nil, context.DeadlineExceeded
+}
+
+func (b *unansweredBroker) GetOrders(ctx context.Context) ([]*Order, error) {
+	if b.lookupErr != nil {
+		return nil, b.lookupErr
+	}
+	return b.MockBrokerAdapter.GetOrders(ctx)
+}
+
+// TestRetryExecutorUnansweredPlacement tests that orders the broker may have taken
+// are looked up rather than placed again
+func TestRetryExecutorUnansweredPlacement(t *testing.T) {
+	request := &OrderRequest{
+		Symbol:          "NIFTY24JUN23000CE",
+		Quantity:        50,
+		OrderType:       Market,
+		TransactionType: Buy,
+		Validity:        Day,
+		Exchange:        "NFO",
+		Product:         Intraday,
+		Tags:            []string{"entry"},
+	}
+	policy := RetryPolicy{MaxRetries: 2}
+	execute := func(broker *unansweredBroker) *RetryResult {
+		router := NewVenueRouter()
+		router.AddVenue("MOCK", broker, []string{"NFO"}, 1)
+		engine := NewOrderExecutionEngine(router)
+		engine.RegisterBroker("NFO", broker)
+		result, err := NewRetryExecutor(engine).Execute(context.Background(), request, policy)
+		if err != nil {
+			t.Fatalf("Error executing order: %v", err)
+		}
+		return result
+	}
+	
+	// An order the broker took without answering is found rather than duplicated
+	broker := &unansweredBroker{MockBrokerAdapter: NewMockBrokerAdapter(), failures: 1, placed: true}
+	result := execute(broker)
+	orders, _ := broker.MockBrokerAdapter.GetOrders(context.Background())
+	if result.Order == nil || result.Attempts != 1 || len(orders) != 1 {
+		t.Errorf("Expected the order to be placed once in 1 attempt, got %d orders in %d attempts", len(orders), result.Attempts)
+	}
+	if len(request.Tags) != 1 {
+		t.Errorf("Expected the request's tags to be left unchanged, got %v", request.Tags)
+	}
+	
+	// One the broker never got is placed again
+	broker = &unansweredBroker{MockBrokerAdapter: NewMockBrokerAdapter(), failures: 1}
+	result = execute(broker)
+	orders, _ = broker.MockBrokerAdapter.GetOrders(context.Background())
+	if result.Order == nil || result.Attempts != 2 || len(orders) != 1 {
+		t.Errorf("Expected the order to be placed once in 2 attempts, got %d orders in %d attempts", len(orders), result.Attempts)
+	}
+	
+	// And one that cannot be looked up is not retried
+	broker = &unansweredBroker{MockBrokerAdapter: NewMockBrokerAdapter(), failures: 1, lookupErr: errors.New("connection reset")}
+	result = execute(broker)
+	if result.Order != nil || result.Attempts != 1 || result.Error == "" {
+		t.Errorf("Expected the order to be given up on after 1 attempt, got %d", result.Attempts)
+	}
+}
@@ -0,0 +1,252 @@
+package orderexecution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// Quote is the market's current prices for a symbol
+type Quote struct {
+	LastPrice float64
+	Bid       float64
+	Ask       float64
+}
+
+// PriceProtection caps the limit price of each slice of a large order at a buffer
+// beyond the market, so a slice can never sweep deep into the book
+type PriceProtection struct {
+	PriceType     models.PriceType // Market price the buffer is applied to
+	BufferPercent float64          // How far beyond that price a slice may trade
+}
+
+// NewPortfolioPriceProtection returns the price protection of a portfolio's entry or
+// exit orders
+func NewPortfolioPriceProtection(portfolio *models.Portfolio, exit bool) *PriceProtection {
+	buffer := portfolio.EntryPriceBuffer
+	if exit {
+		buffer = portfolio.ExitPriceBuffer
+	}
+
+	return &PriceProtection{
+		PriceType:     portfolio.PriceType,
+		BufferPercent: buffer,
+	}
+}
+
+// LimitPrice returns the worst price a slice may trade at given the current quote.
+// Buys cross the ask and sells the bid when the price type is BID_ASK.
+func (p *PriceProtection) LimitPrice(transactionType TransactionType, quote *Quote) (float64, error) {
+	var reference float64
+	switch p.PriceType {
+	case models.PriceTypeLTP, "":
+		reference = quote.LastPrice
+	case models.PriceTypeBidAsk:
+		reference = quote.Bid
+		if transactionType == Buy {
+			reference = quote.Ask
+		}
+	case models.PriceTypeBidAskAvg:
+		if quote.Bid > 0 && quote.Ask > 0 {
+			reference = (quote.Bid + quote.Ask) / 2
+		}
+	default:
+		return 0, fmt.Errorf("unknown price type: %s", p.PriceType)
+	}
+
+	if reference <= 0 {
+		return 0, fmt.Errorf("no %s price to protect the order with", p.PriceType)
+	}
+
+	if transactionType == Buy {
+		return reference * (1 + p.BufferPercent/100), nil
+	}
+	return reference * (1 - p.BufferPercent/100), nil
+}
+
+// SlicingConfig decides when an order is too large to send in one piece
+type SlicingConfig struct {
+	FreezeQuantities      map[string]int // Largest quantity the exchange accepts in one order, by symbol
+	DefaultFreezeQuantity int            // Freeze quantity of symbols without their own, 0 for none
+	MaxSliceValue         float64        // Largest value of one slice before it moves the market, 0 for no limit
+	TimeBetweenSlices     time.Duration
+}
+
+// LargeOrderSlicer splits orders above the exchange freeze quantity or the market
+// impact threshold into slices, each priced no worse than the order's price
+// protection allows at the time it is placed. The order is tracked as one parent.
+type LargeOrderSlicer struct {
+	config    SlicingConfig
+	quoteFunc func(symbol string) (*Quote, error)
+}
+
+// NewLargeOrderSlicer creates a new large order slicer
+func NewLargeOrderSlicer(config SlicingConfig, quoteFunc func(symbol string) (*Quote, error)) (*LargeOrderSlicer, error) {
+	if config.DefaultFreezeQuantity < 0 || config.MaxSliceValue < 0 {
+		return nil, errors.New("slicing thresholds cannot be negative")
+	}
+
+	for symbol, quantity := range config.FreezeQuantities {
+		if quantity <= 0 {
+			return nil, fmt.Errorf("freeze quantity of %s must be positive", symbol)
+		}
+	}
+
+	if quoteFunc == nil {
+		return nil, errors.New("quote function is required")
+	}
+
+	return &LargeOrderSlicer{
+		config:    config,
+		quoteFunc: quoteFunc,
+	}, nil
+}
+
+// NeedsSlicing reports whether an order is above the freeze quantity or market
+// impact threshold
+func (s *LargeOrderSlicer) NeedsSlicing(request *OrderRequest) bool {
+	return s.maxSliceQuantity(request, s.referencePrice(request)) < request.Quantity
+}
+
+// referencePrice returns the price an order's value is judged at, the last price
+// for market orders
+func (s *LargeOrderSlicer) referencePrice(request *OrderRequest) float64 {
+	if request.Price > 0 {
+		return request.Price
+	}
+
+	quote, err := s.quoteFunc(request.Symbol)
+	if err != nil {
+		return 0
+	}
+	return quote.LastPrice
+}
+
+// maxSliceQuantity returns the largest slice of an order allowed at a price, in
+// whole lots and never less than one lot
+func (s *LargeOrderSlicer) maxSliceQuantity(request *OrderRequest, price float64) int {
+	limit := math.MaxInt32
+	if freeze, exists := s.config.FreezeQuantities[request.Symbol]; exists {
+		limit = freeze
+	} else if s.config.DefaultFreezeQuantity > 0 {
+		limit = s.config.DefaultFreezeQuantity
+	}
+
+	if s.config.MaxSliceValue > 0 && price > 0 {
+		limit = min(limit, int(s.config.MaxSliceValue/price))
+	}
+
+	lotSize := request.lotSize()
+	return max(lotSize, limit-limit%lotSize)
+}
+
+// Execute implements the ExecutionAlgorithm interface. Slices are placed one after
+// another until the order is placed in full, a slice cannot be priced or the context
+// is done.
+func (s *LargeOrderSlicer) Execute(ctx context.Context, engine *OrderExecutionEngine, request *OrderRequest) (*OrderResponse, error) {
+	if request.Quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+
+	if lotSize := request.lotSize(); request.Quantity%lotSize != 0 {
+		return nil, fmt.Errorf("quantity %d is not a whole number of lots of %d", request.Quantity, lotSize)
+	}
+
+	// Create the parent order
+	parent := engine.createParentOrder(request, s.Name())
+	slices := int(math.Ceil(float64(request.Quantity) / float64(s.maxSliceQuantity(request, s.referencePrice(request)))))
+
+	remaining := request.Quantity
+	placed := 0
+	cancelled := false
+	for i := 1; remaining > 0; i++ {
+		// Wait between slices, but not before the first one
+		if i > 1 && s.config.TimeBetweenSlices > 0 {
+			select {
+			case <-ctx.Done():
+				cancelled = true
+			case <-time.After(s.config.TimeBetweenSlices):
+			}
+		} else if ctx.Err() != nil {
+			cancelled = true
+		}
+		if cancelled {
+			break
+		}
+
+		// Price the slice off the market as it is now
+		price := s.referencePrice(request)
+		if request.PriceProtection != nil && (request.OrderType == Market || request.OrderType == Limit) {
+			protected, err := s.protectedPrice(request)
+			if err != nil {
+				log.Printf("Stopping large order %s after %d slices: %v", parent.ID, placed, err)
+				break
+			}
+			price = protected
+		}
+
+		slice := sliceRequest(request, min(remaining, s.maxSliceQuantity(request, price)), fmt.Sprintf("large_order_slice_%d", i))
+		if request.PriceProtection != nil && price != request.Price {
+			slice.OrderType = Limit
+			slice.Price = price
+		}
+
+		// Execute the slice
+		response, err := engine.ExecuteOrder(ctx, slice)
+		if err != nil {
+			log.Printf("Stopping large order %s, slice %d failed: %v", parent.ID, i, err)
+			break
+		}
+		if !response.Status || response.Order == nil {
+			log.Printf("Stopping large order %s, slice %d was rejected: %s", parent.ID, i, response.Error)
+			break
+		}
+
+		if err := engine.addChildOrder(parent.ID, response.Order); err != nil {
+			return nil, err
+		}
+		remaining -= slice.Quantity
+		placed++
+	}
+
+	return engine.finishParentOrder(parent, placed, max(slices, placed), cancelled), nil
+}
+
+// protectedPrice returns the limit price of the next slice of an order. An order's
+// own limit price is kept when it is already better than the protection.
+func (s *LargeOrderSlicer) protectedPrice(request *OrderRequest) (float64, error) {
+	quote, err := s.quoteFunc(request.Symbol)
+	if err != nil {
+		return 0, fmt.Errorf("error getting quote for %s: %w", request.Symbol, err)
+	}
+
+	price, err := request.PriceProtection.LimitPrice(request.TransactionType, quote)
+	if err != nil {
+		return 0, err
+	}
+
+	if request.OrderType == Limit && request.Price > 0 {
+		if request.TransactionType == Buy {
+			price = math.Min(price, request.Price)
+		} else {
+			price = math.Max(price, request.Price)
+		}
+	}
+
+	return price, nil
+}
+
+// Name returns the algorithm name
+func (s *LargeOrderSlicer) Name() string {
+	return "LargeOrderSlicing"
+}
+
+// Description returns the algorithm description
+func (s *LargeOrderSlicer) Description() string {
+	return fmt.Sprintf("Large order slicing at freeze quantity %d and slice value %.2f", s.config.DefaultFreezeQuantity, s.config.MaxSliceValue)
+}
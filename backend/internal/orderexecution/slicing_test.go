@@ -0,0 +1,133 @@
+package orderexecution
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestLargeOrderSlicer tests slicing orders above the freeze quantity with price protection
+func TestLargeOrderSlicer(t *testing.T) {
+	// Create an engine with a mock broker
+	mockBroker := NewMockBrokerAdapter()
+	smartRouter := NewDefaultSmartRouter(BestPrice)
+	smartRouter.RegisterBroker("MOCK", mockBroker)
+	engine := NewOrderExecutionEngine(smartRouter)
+	
+	quote := func(symbol string) (*Quote, error) {
+		return &Quote{LastPrice: 100.0, Bid: 99.5, Ask: 100.5}, nil
+	}
+	
+	// NIFTY options freeze at 1800, everything else at 10000 or 500000 in value
+	slicer, err := NewLargeOrderSlicer(SlicingConfig{
+		FreezeQuantities:      map[string]int{"NIFTY24JUN23000CE": 1800},
+		DefaultFreezeQuantity: 10000,
+		MaxSliceValue:         500000,
+	}, quote)
+	if err != nil {
+		t.Errorf("Error creating large order slicer: %v", err)
+		return
+	}
+	
+	request := &OrderRequest{
+		Symbol:          "NIFTY24JUN23000CE",
+		Quantity:        5000,
+		OrderType:       Market,
+		TransactionType: Buy,
+		Validity:        Day,
+		Exchange:        "NFO",
+		Product:         Normal,
+		PriceProtection: &PriceProtection{PriceType: "BID_ASK", BufferPercent: 1},
+	}
+	
+	// Orders within the thresholds are left alone
+	small := *request
+	small.Quantity = 1800
+	if slicer.NeedsSlicing(&small) {
+		t.Errorf("Expected an order at the freeze quantity not to need slicing")
+	}
+	
+	response, err := slicer.Execute(context.Background(), engine, request)
+	if err != nil {
+		t.Errorf("Error executing large order: %v", err)
+		return
+	}
+	
+	// Slices stay within the freeze quantity and are limited to 1% beyond the ask
+	expected := []int{1800, 1800, 1400}
+	if len(response.Order.ChildOrderIDs) != len(expected) {
+		t.Errorf("Expected %d slices, got %d", len(expected), len(response.Order.ChildOrderIDs))
+		return
+	}
+	for i, childID := range response.Order.ChildOrderIDs {
+		child, _ := engine.GetOrder(childID)
+		if child.Quantity != expected[i] || child.OrderType != Limit || math.Abs(child.Price-101.505) > 1e-9 {
+			t.Errorf("Expected slice %d to be a limit order for %d at 101.505, got %s for %d at %f", i+1, expected[i], child.OrderType, child.Quantity, child.Price)
+		}
+	}
+	
+	// A limit price better than the protection is kept, and slices stay within the
+	// market impact threshold
+	request.Symbol = "RELIANCE-EQ"
+	request.OrderType = Limit
+	request.TransactionType = Sell
+	request.Price = 125.0
+	response, err = slicer.Execute(context.Background(), engine, request)
+	if err != nil {
+		t.Errorf("Error executing large order: %v", err)
+		return
+	}
+	if len(response.Order.ChildOrderIDs) != 2 {
+		t.Errorf("Expected 2 slices of at most 4000, got %d", len(response.Order.ChildOrderIDs))
+		return
+	}
+	child, _ := engine.GetOrder(response.Order.ChildOrderIDs[0])
+	if child.Quantity != 4000 || child.Price != 125.0 {
+		t.Errorf("Expected a first slice of 4000 at 125, got %d at %f", child.Quantity, child.Price)
+	}
+}
+
+// TestLargeOrderSlicerLots tests that slices are whole lots within the thresholds
+func TestLargeOrderSlicerLots(t *testing.T) {
+	quote := func(symbol string) (*Quote, error) {
+		return &Quote{LastPrice: 100.0, Bid: 99.5, Ask: 100.5}, nil
+	}
+	slicer, err := NewLargeOrderSlicer(SlicingConfig{
+		FreezeQuantities:      map[string]int{"NIFTY24JUN23000CE": 1800},
+		DefaultFreezeQuantity: 10000,
+		MaxSliceValue:         500000,
+	}, quote)
+	if err != nil {
+		t.Fatalf("Error creating large order slicer: %v", err)
+	}
+	
+	tests := []struct {
+		name     string
+		symbol   string
+		lotSize  int
+		price    float64
+		expected int
+	}{
+		{"Freeze quantity", "NIFTY24JUN23000CE", 0, 100, 1800},
+		{"Freeze quantity in whole lots", "NIFTY24JUN23000CE", 75, 100, 1800},
+		{"Freeze quantity rounded down to lots", "NIFTY24JUN23000CE", 70, 100, 1750},
+		{"Slice value rounded down to lots", "RELIANCE-EQ", 50, 130, 3800},
+		{"At least one lot", "RELIANCE-EQ", 50, 20000, 50},
+		{"At least one unit", "RELIANCE-EQ", 0, 1000000, 1},
+	}
+	
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := &OrderRequest{Symbol: test.symbol, LotSize: test.lotSize}
+			if quantity := slicer.maxSliceQuantity(request, test.price); quantity != test.expected {
+				t.Errorf("Expected slices of at most %d, got %d", test.expected, quantity)
+			}
+		})
+	}
+	
+	// Orders must be whole lots
+	request := &OrderRequest{Symbol: "NIFTY24JUN23000CE", Quantity: 5000, LotSize: 75, OrderType: Market}
+	if _, err := slicer.Execute(context.Background(), nil, request); err == nil {
+		t.Errorf("Expected an error for an order that is not whole lots")
+	}
+}
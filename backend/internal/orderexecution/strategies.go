@@ -52,6 +52,7 @@ func (s *OrderSplitter) SplitOrder(request *OrderRequest) []*OrderRequest {
 			Exchange:        request.Exchange,
 			Product:         request.Product,
 			StrategyID:      request.StrategyID,
+			UserID:          request.UserID,
 			Tags:            append([]string{}, request.Tags...),
 		}
 
@@ -248,6 +249,7 @@ func (s *VwapStrategy) Execute(ctx context.Context, engine *OrderExecutionEngine
 			Exchange:        request.Exchange,
 			Product:         request.Product,
 			StrategyID:      request.StrategyID,
+			UserID:          request.UserID,
 			Tags:            append([]string{}, request.Tags...),
 		}
 
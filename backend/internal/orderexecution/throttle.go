@@ -0,0 +1,369 @@
+package orderexecution
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottlePriority decides which waiting order goes to the broker first
+type ThrottlePriority int
+
+// Throttle priorities
+const (
+	// PriorityExit is for orders reducing risk: exits, stop-losses and cancellations
+	PriorityExit ThrottlePriority = iota
+
+	// PriorityEntry is for orders opening or adding to positions
+	PriorityEntry
+)
+
+// exitTags mark requests placed to close positions
+var exitTags = []string{"exit", "square_off", "stop_loss", "kill_switch"}
+
+// ThrottleLimits is the pace orders may be sent at. Up to Burst orders are sent at
+// once, after which orders are spaced to OrdersPerSecond. A zero OrdersPerSecond
+// does not limit orders.
+type ThrottleLimits struct {
+	OrdersPerSecond float64
+	Burst           int
+}
+
+// Default throttle limits, the exchanges' limit of ten orders per second for each
+// user and the same for each broker connection unless configured otherwise
+var (
+	DefaultBrokerThrottleLimits = ThrottleLimits{OrdersPerSecond: 10, Burst: 10}
+	DefaultUserThrottleLimits   = ThrottleLimits{OrdersPerSecond: 10, Burst: 10}
+)
+
+// RequestPriority returns the throttle priority of an order request. Stop-loss
+// orders and orders tagged as exits are exits, everything else is an entry.
+func RequestPriority(request *OrderRequest) ThrottlePriority {
+	if request.OrderType == StopLoss || request.OrderType == StopLossMarket {
+		return PriorityExit
+	}
+
+	for _, tag := range request.Tags {
+		for _, exitTag := range exitTags {
+			if strings.Contains(tag, exitTag) {
+				return PriorityExit
+			}
+		}
+	}
+
+	return PriorityEntry
+}
+
+// tokenBucket paces the orders of one broker or user
+type tokenBucket struct {
+	limits  ThrottleLimits
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens earned since the bucket was last updated
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.updated).Seconds() * b.limits.OrdersPerSecond
+	if b.tokens > float64(b.limits.Burst) {
+		b.tokens = float64(b.limits.Burst)
+	}
+	b.updated = now
+}
+
+// available reports whether an order can be sent, or else how long until it can
+func (b *tokenBucket) available() (bool, time.Duration) {
+	if b.limits.OrdersPerSecond <= 0 || b.tokens >= 1 {
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.limits.OrdersPerSecond * float64(time.Second))
+}
+
+// take uses up the token of a sent order
+func (b *tokenBucket) take() {
+	if b.limits.OrdersPerSecond > 0 {
+		b.tokens--
+	}
+}
+
+// throttleTicket is an order waiting for its turn
+type throttleTicket struct {
+	buckets  []string
+	priority ThrottlePriority
+	sequence uint64
+	ready    chan struct{}
+}
+
+// OrderThrottle smooths bursts of orders, such as the legs of a basket placed
+// together, into the pace each broker and user is allowed. Waiting orders are sent
+// exits first, then in the order they arrived, and an order only waits behind the
+// orders sharing its broker or user.
+type OrderThrottle struct {
+	brokerLimits map[string]ThrottleLimits
+	userLimits   map[string]ThrottleLimits
+	buckets      map[string]*tokenBucket
+	waiting      []*throttleTicket
+	sequence     uint64
+	mutex        sync.Mutex
+	now          func() time.Time
+}
+
+// NewOrderThrottle creates a new order throttle with the default limits
+func NewOrderThrottle() *OrderThrottle {
+	return &OrderThrottle{
+		brokerLimits: make(map[string]ThrottleLimits),
+		userLimits:   make(map[string]ThrottleLimits),
+		buckets:      make(map[string]*tokenBucket),
+		now:          time.Now,
+	}
+}
+
+// SetBrokerLimits sets the pace orders may be sent to a broker at
+func (t *OrderThrottle) SetBrokerLimits(broker string, limits ThrottleLimits) error {
+	if err := validateThrottleLimits(limits); err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.brokerLimits[broker] = limits
+	t.resetBucket(brokerBucket(broker), limits)
+	return nil
+}
+
+// SetUserLimits sets the pace a user's orders may be sent at
+func (t *OrderThrottle) SetUserLimits(userID string, limits ThrottleLimits) error {
+	if err := validateThrottleLimits(limits); err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.userLimits[userID] = limits
+	t.resetBucket(userBucket(userID), limits)
+	return nil
+}
+
+// Wait blocks until an order of a user may be sent to a broker, or ctx is done. An
+// empty user ID only limits the order by its broker.
+func (t *OrderThrottle) Wait(ctx context.Context, broker, userID string, priority ThrottlePriority) error {
+	t.mutex.Lock()
+	t.sequence++
+	ticket := &throttleTicket{
+		buckets:  []string{t.bucket(brokerBucket(broker), t.limitsOf(t.brokerLimits, broker, DefaultBrokerThrottleLimits))},
+		priority: priority,
+		sequence: t.sequence,
+		ready:    make(chan struct{}),
+	}
+	if userID != "" {
+		ticket.buckets = append(ticket.buckets, t.bucket(userBucket(userID), t.limitsOf(t.userLimits, userID, DefaultUserThrottleLimits)))
+	}
+	t.waiting = append(t.waiting, ticket)
+	next := t.dispatch()
+	t.mutex.Unlock()
+
+	for {
+		select {
+		case <-ticket.ready:
+			return nil
+		default:
+		}
+
+		timer := time.NewTimer(next)
+		select {
+		case <-ticket.ready:
+			timer.Stop()
+			return nil
+		case <-ctx.Done():
+			timer.Stop()
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+
+			// The order may have been let through while ctx was being cancelled
+			select {
+			case <-ticket.ready:
+				return nil
+			default:
+			}
+			t.remove(ticket)
+			t.dispatch()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		t.mutex.Lock()
+		next = t.dispatch()
+		t.mutex.Unlock()
+	}
+}
+
+// Pending returns the number of orders waiting for their turn
+func (t *OrderThrottle) Pending() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return len(t.waiting)
+}
+
+// dispatch lets through every waiting order whose broker and user have a token
+// left and returns how long until the next one might. Once a broker or user is out
+// of tokens, its next token goes to the first waiting order that needs it, so
+// lower priority and later orders cannot take it.
+func (t *OrderThrottle) dispatch() time.Duration {
+	now := t.now()
+	for _, bucket := range t.buckets {
+		bucket.refill(now)
+	}
+
+	sort.SliceStable(t.waiting, func(i, j int) bool {
+		if t.waiting[i].priority != t.waiting[j].priority {
+			return t.waiting[i].priority < t.waiting[j].priority
+		}
+		return t.waiting[i].sequence < t.waiting[j].sequence
+	})
+
+	next := time.Second
+	held := make(map[string]bool)
+	remaining := t.waiting[:0]
+	for _, ticket := range t.waiting {
+		free := true
+		for _, key := range ticket.buckets {
+			if held[key] {
+				free = false
+				continue
+			}
+			if available, wait := t.buckets[key].available(); !available {
+				free = false
+				held[key] = true
+				if wait < next {
+					next = wait
+				}
+			}
+		}
+
+		if !free {
+			remaining = append(remaining, ticket)
+			continue
+		}
+
+		for _, key := range ticket.buckets {
+			t.buckets[key].take()
+		}
+		close(ticket.ready)
+	}
+	t.waiting = remaining
+
+	return next
+}
+
+// remove drops a ticket from the waiting orders
+func (t *OrderThrottle) remove(ticket *throttleTicket) {
+	for i, waiting := range t.waiting {
+		if waiting == ticket {
+			t.waiting = append(t.waiting[:i], t.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// bucket returns the key of a bucket, creating the bucket full if it does not exist
+func (t *OrderThrottle) bucket(key string, limits ThrottleLimits) string {
+	if _, exists := t.buckets[key]; !exists {
+		t.resetBucket(key, limits)
+	}
+	return key
+}
+
+// resetBucket replaces a bucket with a full one with new limits
+func (t *OrderThrottle) resetBucket(key string, limits ThrottleLimits) {
+	t.buckets[key] = &tokenBucket{
+		limits:  limits,
+		tokens:  float64(limits.Burst),
+		updated: t.now(),
+	}
+}
+
+// limitsOf returns the configured limits of a broker or user, or the defaults
+func (t *OrderThrottle) limitsOf(configured map[string]ThrottleLimits, name string, defaults ThrottleLimits) ThrottleLimits {
+	if limits, exists := configured[name]; exists {
+		return limits
+	}
+	return defaults
+}
+
+// brokerBucket returns the bucket key of a broker
+func brokerBucket(broker string) string {
+	return "broker:" + broker
+}
+
+// userBucket returns the bucket key of a user
+func userBucket(userID string) string {
+	return "user:" + userID
+}
+
+// validateThrottleLimits checks limits can pace orders
+func validateThrottleLimits(limits ThrottleLimits) error {
+	if limits.OrdersPerSecond < 0 {
+		return errors.New("orders per second cannot be negative")
+	}
+	if limits.OrdersPerSecond > 0 && limits.Burst < 1 {
+		return errors.New("burst must be at least one order")
+	}
+	return nil
+}
+
+// ThrottledBroker is a broker whose orders, modifications and cancellations are
+// paced by an order throttle
+type ThrottledBroker struct {
+	name     string
+	broker   BrokerAdapter
+	throttle *OrderThrottle
+}
+
+// NewThrottledBroker wraps a broker so its orders are paced by throttle under name
+func NewThrottledBroker(name string, broker BrokerAdapter, throttle *OrderThrottle) *ThrottledBroker {
+	return &ThrottledBroker{
+		name:     name,
+		broker:   broker,
+		throttle: throttle,
+	}
+}
+
+// PlaceOrder places an order once the throttle lets it through
+func (b *ThrottledBroker) PlaceOrder(ctx context.Context, request *OrderRequest) (*OrderResponse, error) {
+	if err := b.throttle.Wait(ctx, b.name, request.UserID, RequestPriority(request)); err != nil {
+		return nil, err
+	}
+	return b.broker.PlaceOrder(ctx, request)
+}
+
+// ModifyOrder modifies an order once the throttle lets it through
+func (b *ThrottledBroker) ModifyOrder(ctx context.Context, orderID string, request *OrderRequest) (*OrderResponse, error) {
+	if err := b.throttle.Wait(ctx, b.name, request.UserID, RequestPriority(request)); err != nil {
+		return nil, err
+	}
+	return b.broker.ModifyOrder(ctx, orderID, request)
+}
+
+// CancelOrder cancels an order once the throttle lets it through. Cancellations
+// reduce risk, so they go ahead of new entries.
+func (b *ThrottledBroker) CancelOrder(ctx context.Context, orderID string) (*OrderResponse, error) {
+	if err := b.throttle.Wait(ctx, b.name, "", PriorityExit); err != nil {
+		return nil, err
+	}
+	return b.broker.CancelOrder(ctx, orderID)
+}
+
+// GetOrderStatus gets the status of an order from the broker
+func (b *ThrottledBroker) GetOrderStatus(ctx context.Context, orderID string) (*Order, error) {
+	return b.broker.GetOrderStatus(ctx, orderID)
+}
+
+// GetOrders gets all orders from the broker
+func (b *ThrottledBroker) GetOrders(ctx context.Context) ([]*Order, error) {
+	return b.broker.GetOrders(ctx)
+}
@@ -0,0 +1,115 @@
+package orderexecution
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestOrderThrottle tests pacing orders per broker and user
+func TestOrderThrottle(t *testing.T) {
+	throttle := NewOrderThrottle()
+	ctx := context.Background()
+	
+	// Orders beyond the burst are spaced to the broker's pace
+	if err := throttle.SetBrokerLimits("MOCK", ThrottleLimits{OrdersPerSecond: 50, Burst: 2}); err != nil {
+		t.Fatalf("Failed to set broker limits: %v", err)
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := throttle.Wait(ctx, "MOCK", "", PriorityEntry); err != nil {
+			t.Fatalf("Failed to wait for the throttle: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected 4 orders with a burst of 2 at 50 per second to take at least 30ms, took %v", elapsed)
+	}
+	
+	// Exits waiting for the broker go ahead of entries that arrived before them
+	throttle.SetBrokerLimits("SLOW", ThrottleLimits{OrdersPerSecond: 20, Burst: 1})
+	throttle.Wait(ctx, "SLOW", "", PriorityEntry)
+	released := make(chan string, 4)
+	wait := func(name string, priority ThrottlePriority) {
+		if err := throttle.Wait(ctx, "SLOW", "", priority); err == nil {
+			released <- name
+		}
+	}
+	for _, name := range []string{"entry1", "entry2", "entry3"} {
+		go wait(name, PriorityEntry)
+	}
+	for throttle.Pending() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	go wait("exit", PriorityExit)
+	var order []string
+	for i := 0; i < 4; i++ {
+		select {
+		case name := <-released:
+			order = append(order, name)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected 4 orders through the throttle, got %v", order)
+		}
+	}
+	if order[0] != "exit" {
+		t.Errorf("Expected the exit to go first, got %v", order)
+	}
+	
+	// A user's orders are paced without holding back other users
+	throttle.SetBrokerLimits("FAST", ThrottleLimits{})
+	throttle.SetUserLimits("user1", ThrottleLimits{OrdersPerSecond: 10, Burst: 1})
+	throttle.Wait(ctx, "FAST", "user1", PriorityEntry)
+	go throttle.Wait(ctx, "FAST", "user1", PriorityEntry)
+	for throttle.Pending() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	start = time.Now()
+	throttle.Wait(ctx, "FAST", "user2", PriorityEntry)
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected user2 not to wait behind user1, waited %v", elapsed)
+	}
+	
+	// A cancelled wait gives up its turn
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	throttle.SetUserLimits("user3", ThrottleLimits{OrdersPerSecond: 1, Burst: 1})
+	throttle.Wait(ctx, "FAST", "user3", PriorityEntry)
+	if err := throttle.Wait(timeoutCtx, "FAST", "user3", PriorityEntry); err != context.DeadlineExceeded {
+		t.Errorf("Expected the wait to time out, got %v", err)
+	}
+	
+	if err := throttle.SetUserLimits("user4", ThrottleLimits{OrdersPerSecond: 5}); err == nil {
+		t.Error("Expected limits without a burst to be rejected")
+	}
+	
+	// Stop-losses and orders tagged as exits are exits
+	if RequestPriority(&OrderRequest{OrderType: StopLossMarket}) != PriorityExit {
+		t.Error("Expected stop-loss orders to be exits")
+	}
+	if RequestPriority(&OrderRequest{OrderType: Market, Tags: []string{"basket", "basket_exit"}}) != PriorityExit {
+		t.Error("Expected orders tagged as exits to be exits")
+	}
+	if RequestPriority(&OrderRequest{OrderType: Limit, Tags: []string{"basket"}}) != PriorityEntry {
+		t.Error("Expected other orders to be entries")
+	}
+	
+	// A throttled broker places orders through the broker it wraps
+	mockBroker := NewMockBrokerAdapter()
+	broker := NewThrottledBroker("MOCK", mockBroker, throttle)
+	response, err := broker.PlaceOrder(ctx, &OrderRequest{
+		Symbol:          "NIFTY24JUN23000CE",
+		Quantity:        50,
+		Price:           100,
+		OrderType:       Limit,
+		TransactionType: Buy,
+		Validity:        Day,
+		Exchange:        "NFO",
+		Product:         Intraday,
+		UserID:          "user1",
+	})
+	if err != nil || !response.Status {
+		t.Fatalf("Failed to place order through throttled broker: %v", err)
+	}
+	if _, err := broker.GetOrderStatus(ctx, response.Order.ID); err != nil {
+		t.Errorf("Failed to get order status through throttled broker: %v", err)
+	}
+}
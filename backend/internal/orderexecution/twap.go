@@ -0,0 +1,154 @@
+package orderexecution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// TWAPAlgorithm implements Time-Weighted Average Price execution. The order is split
+// into slices spread evenly over a window, each slice's size and time randomized so
+// the schedule cannot be read off the tape. The order is tracked as one parent order
+// whose fills are the total of its slices.
+type TWAPAlgorithm struct {
+	window          time.Duration
+	slices          int
+	randomizeFactor float64 // Fraction of a slice's size and interval that is randomized, 0 to 1
+	random          *rand.Rand
+	randomMutex     sync.Mutex
+}
+
+// twapSlice is one child order of a TWAP schedule
+type twapSlice struct {
+	offset   time.Duration // Time from the start of the window
+	quantity int
+}
+
+// NewTWAPAlgorithm creates a new TWAP algorithm working orders over window in slices
+func NewTWAPAlgorithm(window time.Duration, slices int, randomizeFactor float64) (*TWAPAlgorithm, error) {
+	if window <= 0 {
+		return nil, errors.New("window must be positive")
+	}
+
+	if slices < 1 {
+		return nil, errors.New("at least one slice is required")
+	}
+
+	if randomizeFactor < 0 || randomizeFactor > 1 {
+		return nil, fmt.Errorf("randomize factor must be between 0 and 1, got %f", randomizeFactor)
+	}
+
+	return &TWAPAlgorithm{
+		window:          window,
+		slices:          slices,
+		randomizeFactor: randomizeFactor,
+		random:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Execute implements the ExecutionAlgorithm interface. It returns once every slice
+// has been placed or the context is done, with the parent order as it then stands.
+func (a *TWAPAlgorithm) Execute(ctx context.Context, engine *OrderExecutionEngine, request *OrderRequest) (*OrderResponse, error) {
+	if request.Quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+
+	lotSize := request.lotSize()
+	if request.Quantity%lotSize != 0 {
+		return nil, fmt.Errorf("quantity %d is not a whole number of lots of %d", request.Quantity, lotSize)
+	}
+
+	// Create the parent order
+	parent := engine.createParentOrder(request, a.Name())
+	startTime := parent.PlacedAt
+
+	// Place each slice at its scheduled time
+	schedule := a.schedule(request.Quantity, lotSize)
+	placed := 0
+	cancelled := false
+	for i, slice := range schedule {
+		select {
+		case <-ctx.Done():
+			cancelled = true
+		case <-time.After(time.Until(startTime.Add(slice.offset))):
+		}
+		if cancelled {
+			break
+		}
+
+		// Execute the slice
+		tag := fmt.Sprintf("twap_slice_%d_of_%d", i+1, len(schedule))
+		response, err := engine.ExecuteOrder(ctx, sliceRequest(request, slice.quantity, tag))
+		if err != nil {
+			log.Printf("Error executing TWAP slice %d of order %s: %v", i+1, parent.ID, err)
+			continue
+		}
+		if !response.Status || response.Order == nil {
+			log.Printf("TWAP slice %d of order %s was rejected: %s", i+1, parent.ID, response.Error)
+			continue
+		}
+
+		if err := engine.addChildOrder(parent.ID, response.Order); err != nil {
+			return nil, err
+		}
+		placed++
+	}
+
+	return engine.finishParentOrder(parent, placed, len(schedule), cancelled), nil
+}
+
+// schedule splits quantity into slices over the window. Each slice gets an equal
+// share of the window and is placed at a random point in the first randomizeFactor
+// of it. Slices are whole lots and vary by up to randomizeFactor of the average
+// slice, with what rounding leaves off one slice carried into the next.
+func (a *TWAPAlgorithm) schedule(quantity, lotSize int) []twapSlice {
+	lots := quantity / lotSize
+	slices := a.slices
+	if slices > lots {
+		slices = lots
+	}
+	interval := a.window / time.Duration(slices)
+	average := float64(lots) / float64(slices)
+
+	a.randomMutex.Lock()
+	defer a.randomMutex.Unlock()
+
+	schedule := make([]twapSlice, slices)
+	remaining := lots
+	for i := range schedule {
+		offset := time.Duration(i) * interval
+		if a.randomizeFactor > 0 {
+			offset += time.Duration(a.random.Float64() * a.randomizeFactor * float64(interval))
+		}
+
+		// Slices are sized in lots against the running total, so the last slice takes
+		// whatever is left, and every slice gets at least one lot
+		size := remaining
+		if i < slices-1 {
+			size = int(average*float64(i+1)+0.5) - (lots - remaining)
+			if a.randomizeFactor > 0 {
+				size += int((a.random.Float64()*2 - 1) * a.randomizeFactor * average)
+			}
+			size = max(1, min(size, remaining-(slices-1-i)))
+		}
+
+		schedule[i] = twapSlice{offset: offset, quantity: size * lotSize}
+		remaining -= size
+	}
+
+	return schedule
+}
+
+// Name returns the algorithm name
+func (a *TWAPAlgorithm) Name() string {
+	return "TWAP"
+}
+
+// Description returns the algorithm description
+func (a *TWAPAlgorithm) Description() string {
+	return fmt.Sprintf("Time-Weighted Average Price algorithm with %d slices over %v, randomized by %.0f%%", a.slices, a.window, a.randomizeFactor*100)
+}
@@ -0,0 +1,149 @@
+package orderexecution
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestTWAPAlgorithm tests slicing an order over a window as one parent order
+func TestTWAPAlgorithm(t *testing.T) {
+	// Create an engine with a mock broker
+	mockBroker := NewMockBrokerAdapter()
+	smartRouter := NewDefaultSmartRouter(BestPrice)
+	smartRouter.RegisterBroker("MOCK", mockBroker)
+	engine := NewOrderExecutionEngine(smartRouter)
+	engine.RegisterBroker("NSE", mockBroker)
+	
+	// Work the order in 4 randomized slices over 200ms
+	twap, err := NewTWAPAlgorithm(200*time.Millisecond, 4, 0.5)
+	if err != nil {
+		t.Errorf("Error creating TWAP algorithm: %v", err)
+		return
+	}
+	
+	request := &OrderRequest{
+		Symbol:          "RELIANCE-EQ",
+		Quantity:        1000,
+		Price:           2500.0,
+		OrderType:       Limit,
+		TransactionType: Buy,
+		Validity:        Day,
+		Exchange:        "NSE",
+		Product:         Normal,
+	}
+	
+	start := time.Now()
+	response, err := twap.Execute(context.Background(), engine, request)
+	if err != nil {
+		t.Errorf("Error executing TWAP order: %v", err)
+		return
+	}
+	
+	// The slices are spread over the window
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected the slices to take about 200ms, took %v", elapsed)
+	}
+	
+	// The slices add up to the parent order
+	parent := response.Order
+	if parent.Algorithm != "TWAP" || len(parent.ChildOrderIDs) != 4 {
+		t.Errorf("Expected a TWAP parent order with 4 slices, got %s with %d", parent.Algorithm, len(parent.ChildOrderIDs))
+		return
+	}
+	
+	total := 0
+	for _, childID := range parent.ChildOrderIDs {
+		child, err := engine.GetOrder(childID)
+		if err != nil {
+			t.Errorf("Error getting slice %s: %v", childID, err)
+			return
+		}
+		if child.ParentOrderID != parent.ID {
+			t.Errorf("Expected slice %s to belong to %s, got %s", childID, parent.ID, child.ParentOrderID)
+		}
+		total += child.Quantity
+	}
+	if total != request.Quantity {
+		t.Errorf("Expected slices totalling %d, got %d", request.Quantity, total)
+	}
+	
+	// Fills of the slices are aggregated onto the parent
+	ctx := context.Background()
+	mockBroker.SimulateOrderStatusChange(parent.ChildOrderIDs[0], Executed)
+	engine.SyncOrderStatus(ctx, parent.ChildOrderIDs[0])
+	tracked, _ := engine.GetOrder(parent.ID)
+	if tracked.Status != PartiallyExecuted || tracked.FilledQuantity == 0 {
+		t.Errorf("Expected a partially executed parent, got %s with %d filled", tracked.Status, tracked.FilledQuantity)
+	}
+	
+	for _, childID := range parent.ChildOrderIDs[1:] {
+		mockBroker.SimulateOrderStatusChange(childID, Executed)
+	}
+	engine.SyncAllOrders(ctx)
+	tracked, _ = engine.GetOrder(parent.ID)
+	if tracked.Status != Executed || tracked.FilledQuantity != request.Quantity || tracked.AveragePrice != 2500.0 {
+		t.Errorf("Expected an executed parent at 2500, got %s with %d filled at %f", tracked.Status, tracked.FilledQuantity, tracked.AveragePrice)
+	}
+	
+	// Parent orders cannot be cancelled at the broker
+	if _, err := engine.CancelOrder(ctx, parent.ID); err == nil {
+		t.Errorf("Expected an error cancelling a parent order")
+	}
+}
+
+// TestTWAPSchedule tests splitting orders into whole lots spread over the window
+func TestTWAPSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity int
+		lotSize  int
+		slices   int
+		expected []int
+	}{
+		{"Equal slices", 1000, 1, 4, []int{250, 250, 250, 250}},
+		{"Rounding carried into the next slice", 10, 1, 4, []int{3, 2, 3, 2}},
+		{"Whole lots", 1000, 50, 3, []int{350, 300, 350}},
+		{"Fewer lots than slices", 100, 50, 4, []int{50, 50}},
+	}
+	
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			twap, err := NewTWAPAlgorithm(time.Minute, test.slices, 0)
+			if err != nil {
+				t.Fatalf("Error creating TWAP algorithm: %v", err)
+			}
+			
+			schedule := twap.schedule(test.quantity, test.lotSize)
+			if len(schedule) != len(test.expected) {
+				t.Fatalf("Expected %d slices, got %d", len(test.expected), len(schedule))
+			}
+			for i, slice := range schedule {
+				if slice.quantity != test.expected[i] {
+					t.Errorf("Expected slice %d of %d, got %d", i+1, test.expected[i], slice.quantity)
+				}
+			}
+		})
+	}
+	
+	// Randomized slices are still whole lots adding up to the order
+	twap, _ := NewTWAPAlgorithm(time.Minute, 7, 1)
+	for i := 0; i < 100; i++ {
+		total := 0
+		for _, slice := range twap.schedule(5000, 50) {
+			if slice.quantity <= 0 || slice.quantity%50 != 0 {
+				t.Fatalf("Expected slices of whole lots of 50, got %d", slice.quantity)
+			}
+			total += slice.quantity
+		}
+		if total != 5000 {
+			t.Fatalf("Expected slices totalling 5000, got %d", total)
+		}
+	}
+	
+	// Orders must be whole lots
+	request := &OrderRequest{Symbol: "NIFTY-FUT", Quantity: 120, LotSize: 50, Exchange: "NSE"}
+	if _, err := twap.Execute(context.Background(), nil, request); err == nil {
+		t.Errorf("Expected an error for an order that is not whole lots")
+	}
+}
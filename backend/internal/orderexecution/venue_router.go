@@ -0,0 +1,378 @@
+package orderexecution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// VenueHealth represents the current health of a broker connection
+type VenueHealth string
+
+// Venue health states
+const (
+	// VenueHealthy venues are routed to normally
+	VenueHealthy VenueHealth = "HEALTHY"
+
+	// VenueDegraded venues are only routed to when no healthy venue can take the order
+	VenueDegraded VenueHealth = "DEGRADED"
+
+	// VenueDown venues are never routed to
+	VenueDown VenueHealth = "DOWN"
+)
+
+// Venue is a broker through which orders for one or more exchanges can be placed
+type Venue struct {
+	Name            string
+	Exchanges       []string
+	Priority        int // Lower is preferred
+	Health          VenueHealth
+	AvailableMargin float64
+	MarginKnown     bool    // Whether the broker has reported its available margin yet
+	FillRate        float64 // Filled quantity over requested quantity
+	AverageSlippage float64 // Exponential moving average of slippage, as a fraction of the order price
+	RequestedVolume int
+	FilledVolume    int
+	AverageLatency  time.Duration // Exponential moving average of order placement round trips
+	RejectRate      float64       // Orders rejected or failed over orders placed
+	PlacedOrders    int
+	RejectedOrders  int
+	UpdatedAt       time.Time
+}
+
+// venue is a registered venue and its broker
+type venue struct {
+	Venue
+	broker BrokerAdapter
+}
+
+// VenueRouter routes each order to one of the brokers connected for its exchange.
+// Brokers that are down or lack the margin for the order are skipped, and the rest
+// are ranked by health, configured priority, then historical execution quality.
+type VenueRouter struct {
+	venues map[string]*venue
+	mutex  sync.RWMutex
+}
+
+// NewVenueRouter creates a new venue router
+func NewVenueRouter() *VenueRouter {
+	return &VenueRouter{
+		venues: make(map[string]*venue),
+	}
+}
+
+// AddVenue registers a broker for the given exchanges
+func (r *VenueRouter) AddVenue(name string, broker BrokerAdapter, exchanges []string, priority int) error {
+	if name == "" {
+		return errors.New("venue name cannot be empty")
+	}
+
+	if broker == nil {
+		return errors.New("broker cannot be nil")
+	}
+
+	if len(exchanges) == 0 {
+		return fmt.Errorf("venue %s must serve at least one exchange", name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.venues[name]; exists {
+		return fmt.Errorf("venue %s already registered", name)
+	}
+
+	r.venues[name] = &venue{
+		Venue: Venue{
+			Name:      name,
+			Exchanges: append([]string(nil), exchanges...),
+			Priority:  priority,
+			Health:    VenueHealthy,
+			FillRate:  1.0, // Start with optimistic fill rate
+			UpdatedAt: time.Now(),
+		},
+		broker: broker,
+	}
+
+	return nil
+}
+
+// RemoveVenue unregisters a broker
+func (r *VenueRouter) RemoveVenue(name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.venues[name]; !exists {
+		return fmt.Errorf("venue %s not found", name)
+	}
+
+	delete(r.venues, name)
+	return nil
+}
+
+// SetPriority changes the configured priority of a venue
+func (r *VenueRouter) SetPriority(name string, priority int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, exists := r.venues[name]
+	if !exists {
+		return fmt.Errorf("venue %s not found", name)
+	}
+
+	v.Priority = priority
+	v.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetHealth updates the health of a venue, typically from the broker's connection
+// status or heartbeat
+func (r *VenueRouter) SetHealth(name string, health VenueHealth) error {
+	switch health {
+	case VenueHealthy, VenueDegraded, VenueDown:
+	default:
+		return fmt.Errorf("unknown venue health: %s", health)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, exists := r.venues[name]
+	if !exists {
+		return fmt.Errorf("venue %s not found", name)
+	}
+
+	v.Health = health
+	v.UpdatedAt = time.Now()
+	return nil
+}
+
+// UpdateAvailableMargin updates the margin available for new orders at a venue
+func (r *VenueRouter) UpdateAvailableMargin(name string, margin float64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, exists := r.venues[name]
+	if !exists {
+		return fmt.Errorf("venue %s not found", name)
+	}
+
+	v.AvailableMargin = margin
+	v.MarginKnown = true
+	v.UpdatedAt = time.Now()
+	return nil
+}
+
+// RecordFill records how much of an order a venue filled and the slippage of the
+// fill as a fraction of the order price
+func (r *VenueRouter) RecordFill(name string, quantity, filledQuantity int, slippage float64) error {
+	return r.recordFill(name, quantity, filledQuantity, slippage, true)
+}
+
+// recordFill records a fill, its slippage only when priced: fills of orders placed
+// without a price have nothing to measure it from
+func (r *VenueRouter) recordFill(name string, quantity, filledQuantity int, slippage float64, priced bool) error {
+	if quantity <= 0 || filledQuantity < 0 || filledQuantity > quantity {
+		return fmt.Errorf("invalid fill of %d out of %d", filledQuantity, quantity)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, exists := r.venues[name]
+	if !exists {
+		return fmt.Errorf("venue %s not found", name)
+	}
+
+	// Update slippage (exponential moving average), only filled quantity has any
+	if filledQuantity > 0 && priced {
+		if v.FilledVolume == 0 {
+			v.AverageSlippage = slippage
+		} else {
+			alpha := 0.2 // Weight for new value
+			v.AverageSlippage = v.AverageSlippage*(1-alpha) + slippage*alpha
+		}
+	}
+
+	// Update fill rate
+	v.RequestedVolume += quantity
+	v.FilledVolume += filledQuantity
+	v.FillRate = float64(v.FilledVolume) / float64(v.RequestedVolume)
+	v.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// RecordOrderResult records how long a venue took to answer an order placement and
+// whether it rejected the order, or failed to place it
+func (r *VenueRouter) RecordOrderResult(name string, latency time.Duration, rejected bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, exists := r.venues[name]
+	if !exists {
+		return fmt.Errorf("venue %s not found", name)
+	}
+
+	// Update latency (exponential moving average)
+	if v.PlacedOrders == 0 {
+		v.AverageLatency = latency
+	} else {
+		alpha := 0.2 // Weight for new value
+		v.AverageLatency = time.Duration(float64(v.AverageLatency)*(1-alpha) + float64(latency)*alpha)
+	}
+
+	// Update reject rate
+	v.PlacedOrders++
+	if rejected {
+		v.RejectedOrders++
+	}
+	v.RejectRate = float64(v.RejectedOrders) / float64(v.PlacedOrders)
+	v.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// GetVenues returns the state of all venues, in routing preference order when
+// every one of them could take an order
+func (r *VenueRouter) GetVenues() []Venue {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	candidates := make([]*venue, 0, len(r.venues))
+	for _, v := range r.venues {
+		candidates = append(candidates, v)
+	}
+	rankVenues(candidates)
+
+	venues := make([]Venue, len(candidates))
+	for i, v := range candidates {
+		venues[i] = v.Venue
+		venues[i].Exchanges = append([]string(nil), v.Exchanges...)
+	}
+
+	return venues
+}
+
+// RouteOrder routes an order to the best venue for its exchange
+func (r *VenueRouter) RouteOrder(ctx context.Context, request *OrderRequest) (BrokerAdapter, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	v, err := r.selectVenue(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.broker, nil
+}
+
+// GetRoutingDecision returns the name of the venue an order would be routed to
+func (r *VenueRouter) GetRoutingDecision(request *OrderRequest) (string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	v, err := r.selectVenue(request)
+	if err != nil {
+		return "", err
+	}
+
+	return v.Name, nil
+}
+
+// selectVenue picks the venue for an order. The router's lock must be held.
+func (r *VenueRouter) selectVenue(request *OrderRequest) (*venue, error) {
+	if request == nil {
+		return nil, errors.New("order request cannot be nil")
+	}
+
+	// Venues whose margin is unknown are given the benefit of the doubt, the broker
+	// rejects the order if it cannot be margined
+	required := requiredMargin(request)
+	var candidates []*venue
+	for _, v := range r.venues {
+		if v.Health == VenueDown || !v.serves(request.Exchange) {
+			continue
+		}
+		if v.MarginKnown && (v.AvailableMargin <= 0 || v.AvailableMargin < required) {
+			continue
+		}
+		candidates = append(candidates, v)
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no available broker for exchange %s", request.Exchange)
+	}
+
+	rankVenues(candidates)
+	return candidates[0], nil
+}
+
+// serves reports whether a venue can place orders on an exchange
+func (v *venue) serves(exchange string) bool {
+	for _, e := range v.Exchanges {
+		if e == exchange {
+			return true
+		}
+	}
+	return false
+}
+
+// rankVenues sorts venues healthiest first, then by priority, reject rate, fill
+// rate, slippage, latency and available margin, falling back to the name for a
+// deterministic order
+func rankVenues(venues []*venue) {
+	sort.Slice(venues, func(i, j int) bool {
+		a, b := venues[i], venues[j]
+		if healthRank(a.Health) != healthRank(b.Health) {
+			return healthRank(a.Health) < healthRank(b.Health)
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if a.RejectRate != b.RejectRate {
+			return a.RejectRate < b.RejectRate
+		}
+		if a.FillRate != b.FillRate {
+			return a.FillRate > b.FillRate
+		}
+		if a.AverageSlippage != b.AverageSlippage {
+			return a.AverageSlippage < b.AverageSlippage
+		}
+		if a.AverageLatency != b.AverageLatency {
+			return a.AverageLatency < b.AverageLatency
+		}
+		if a.AvailableMargin != b.AvailableMargin {
+			return a.AvailableMargin > b.AvailableMargin
+		}
+		return a.Name < b.Name
+	})
+}
+
+// healthRank orders venue health from best to worst
+func healthRank(health VenueHealth) int {
+	switch health {
+	case VenueHealthy:
+		return 0
+	case VenueDegraded:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// requiredMargin estimates the margin an order blocks. Market orders carry no price
+// so only need some margin to be available.
+func requiredMargin(request *OrderRequest) float64 {
+	price := request.Price
+	if price <= 0 {
+		price = request.TriggerPrice
+	}
+	if price <= 0 {
+		return 0
+	}
+	return price * float64(request.Quantity)
+}
@@ -0,0 +1,65 @@
+package orderexecution
+
+import (
+	"context"
+	"testing"
+)
+
+// TestVenueRouter tests routing across brokers connected for the same exchange
+func TestVenueRouter(t *testing.T) {
+	// Create a venue router with two brokers for NSE and one for MCX
+	router := NewVenueRouter()
+	router.AddVenue("MOCK1", NewMockBrokerAdapter(), []string{"NSE"}, 1)
+	router.AddVenue("MOCK2", NewMockBrokerAdapter(), []string{"NSE", "BSE"}, 2)
+	router.AddVenue("MOCK3", NewMockBrokerAdapter(), []string{"MCX"}, 1)
+	
+	request := &OrderRequest{
+		Symbol:          "RELIANCE-EQ",
+		Quantity:        100,
+		Price:           2500.0,
+		OrderType:       Limit,
+		TransactionType: Buy,
+		Validity:        Day,
+		Exchange:        "NSE",
+		Product:         Normal,
+	}
+	
+	// The configured priority decides between healthy brokers
+	if name, err := router.GetRoutingDecision(request); err != nil || name != "MOCK1" {
+		t.Errorf("Expected MOCK1, got %s (%v)", name, err)
+	}
+	
+	// Brokers without the margin for the order are skipped
+	router.UpdateAvailableMargin("MOCK1", 100000)
+	if name, err := router.GetRoutingDecision(request); err != nil || name != "MOCK2" {
+		t.Errorf("Expected MOCK2 when MOCK1 lacks margin, got %s (%v)", name, err)
+	}
+	router.UpdateAvailableMargin("MOCK1", 1000000)
+	
+	// Fill quality decides between brokers of the same priority
+	router.SetPriority("MOCK2", 1)
+	router.RecordFill("MOCK1", 100, 50, 0.001)
+	router.RecordFill("MOCK2", 100, 100, 0.001)
+	if name, err := router.GetRoutingDecision(request); err != nil || name != "MOCK2" {
+		t.Errorf("Expected MOCK2 for its better fill rate, got %s (%v)", name, err)
+	}
+	
+	// Degraded brokers are only used when no healthy one can take the order
+	router.SetHealth("MOCK2", VenueDegraded)
+	if name, err := router.GetRoutingDecision(request); err != nil || name != "MOCK1" {
+		t.Errorf("Expected MOCK1 when MOCK2 is degraded, got %s (%v)", name, err)
+	}
+	
+	// Brokers that are down are never used
+	router.SetHealth("MOCK1", VenueDown)
+	router.SetHealth("MOCK2", VenueDown)
+	if _, err := router.RouteOrder(context.Background(), request); err == nil {
+		t.Errorf("Expected an error when every NSE broker is down")
+	}
+	
+	// Orders are only routed to brokers for their exchange
+	request.Exchange = "MCX"
+	if name, err := router.GetRoutingDecision(request); err != nil || name != "MOCK3" {
+		t.Errorf("Expected MOCK3 for MCX, got %s (%v)", name, err)
+	}
+}
@@ -0,0 +1,296 @@
+package orderexecution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// VWAPExecution tracks how a VWAP order is doing against the market VWAP since it
+// started. DeviationBps is positive when the order is filling worse than the market.
+type VWAPExecution struct {
+	ParentOrderID  string
+	Symbol         string
+	MarketVWAP     float64
+	MarketVolume   int // Volume traded in the market since the order started
+	FilledQuantity int
+	AveragePrice   float64
+	DeviationBps   float64
+	CappedQuantity int // Quantity behind the profile because of the participation cap, carried to later slices
+	UpdatedAt      time.Time
+}
+
+// vwapTracking is the execution of a VWAP order and the parent order it tracks
+type vwapTracking struct {
+	VWAPExecution
+	engine *OrderExecutionEngine
+	parent *Order
+}
+
+// VWAPAlgorithm implements Volume-Weighted Average Price execution. The window is
+// divided into buckets sized by the symbol's historical intraday volume profile, each
+// bucket's slice kept within a participation rate of the volume the market traded in
+// the previous bucket, and the order's fills tracked against the market VWAP.
+type VWAPAlgorithm struct {
+	window           time.Duration
+	maxParticipation float64                                   // Largest fraction of market volume to trade, 0 for no cap
+	profileFunc      func(symbol string) ([]float64, error)    // Historical share of volume in each bucket of the window
+	marketDataFunc   func(symbol string) (float64, int, error) // Last price and cumulative volume traded today
+	executions       map[string]*vwapTracking
+	listeners        []func(VWAPExecution)
+	mutex            sync.RWMutex
+}
+
+// NewVWAPAlgorithm creates a new VWAP algorithm working orders over window
+func NewVWAPAlgorithm(window time.Duration, maxParticipation float64, profileFunc func(symbol string) ([]float64, error), marketDataFunc func(symbol string) (float64, int, error)) (*VWAPAlgorithm, error) {
+	if window <= 0 {
+		return nil, errors.New("window must be positive")
+	}
+
+	if maxParticipation < 0 || maxParticipation > 1 {
+		return nil, fmt.Errorf("participation rate must be between 0 and 1, got %f", maxParticipation)
+	}
+
+	if profileFunc == nil || marketDataFunc == nil {
+		return nil, errors.New("volume profile and market data functions are required")
+	}
+
+	return &VWAPAlgorithm{
+		window:           window,
+		maxParticipation: maxParticipation,
+		profileFunc:      profileFunc,
+		marketDataFunc:   marketDataFunc,
+		executions:       make(map[string]*vwapTracking),
+	}, nil
+}
+
+// AddExecutionListener registers a listener called with every update of an order's
+// deviation from the market VWAP
+func (a *VWAPAlgorithm) AddExecutionListener(listener func(VWAPExecution)) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.listeners = append(a.listeners, listener)
+}
+
+// GetExecution returns the latest deviation tracking of a VWAP parent order
+func (a *VWAPAlgorithm) GetExecution(parentOrderID string) (VWAPExecution, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tracking, exists := a.executions[parentOrderID]
+	if !exists {
+		return VWAPExecution{}, fmt.Errorf("VWAP execution not found: %s", parentOrderID)
+	}
+
+	tracking.refreshFills()
+	return tracking.VWAPExecution, nil
+}
+
+// Execute implements the ExecutionAlgorithm interface. It returns once every bucket
+// has passed or the context is done, with the parent order as it then stands.
+func (a *VWAPAlgorithm) Execute(ctx context.Context, engine *OrderExecutionEngine, request *OrderRequest) (*OrderResponse, error) {
+	if request.Quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+
+	lotSize := request.lotSize()
+	if request.Quantity%lotSize != 0 {
+		return nil, fmt.Errorf("quantity %d is not a whole number of lots of %d", request.Quantity, lotSize)
+	}
+
+	// Get the volume profile, falling back to equal buckets when it is not available
+	profile, err := a.profileFunc(request.Symbol)
+	if err == nil {
+		profile, err = normalizeProfile(profile)
+	}
+	if err != nil {
+		log.Printf("Using a flat volume profile for %s: %v", request.Symbol, err)
+		profile = []float64{0.2, 0.2, 0.2, 0.2, 0.2}
+	}
+	interval := a.window / time.Duration(len(profile))
+
+	// The market's volume so far is the baseline of the order's VWAP
+	lastPrice, lastVolume, err := a.marketDataFunc(request.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("error getting initial market data: %w", err)
+	}
+
+	// Create the parent order
+	parent := engine.createParentOrder(request, a.Name())
+	startTime := parent.PlacedAt
+	tracking := &vwapTracking{
+		VWAPExecution: VWAPExecution{
+			ParentOrderID: parent.ID,
+			Symbol:        request.Symbol,
+			UpdatedAt:     startTime,
+		},
+		engine: engine,
+		parent: parent,
+	}
+	a.mutex.Lock()
+	a.executions[parent.ID] = tracking
+	a.mutex.Unlock()
+
+	// Place each bucket's slice at the start of the bucket
+	marketValue := 0.0
+	marketVolume := 0
+	bucketVolume := -1 // Unknown until a bucket has passed
+	target := 0.0
+	scheduled := 0
+	placed := 0
+	cancelled := false
+	for i, share := range profile {
+		select {
+		case <-ctx.Done():
+			cancelled = true
+		case <-time.After(time.Until(startTime.Add(time.Duration(i) * interval))):
+		}
+		if cancelled {
+			break
+		}
+
+		// Update the market VWAP with the volume traded since the last bucket, valued
+		// at the average of the prices either side of it
+		if i > 0 {
+			price, volume, err := a.marketDataFunc(request.Symbol)
+			if err != nil {
+				log.Printf("Error getting market data for VWAP order %s: %v", parent.ID, err)
+				bucketVolume = -1
+			} else {
+				bucketVolume = max(0, volume-lastVolume)
+				marketValue += float64(bucketVolume) * (price + lastPrice) / 2
+				marketVolume += bucketVolume
+				lastPrice, lastVolume = price, volume
+			}
+		}
+
+		// Slice the whole lots needed to keep up with the profile, within the
+		// participation cap. What rounding leaves off is carried into later buckets.
+		target += share * float64(request.Quantity)
+		quantity := min(roundToLots(target, lotSize), request.Quantity) - scheduled
+		if a.maxParticipation > 0 && bucketVolume >= 0 {
+			limit := int(a.maxParticipation * float64(bucketVolume))
+			quantity = min(quantity, limit-limit%lotSize)
+		}
+		if quantity > 0 {
+			scheduled += quantity
+			tag := fmt.Sprintf("vwap_slice_%d_of_%d", i+1, len(profile))
+			if err := a.executeSlice(ctx, engine, parent, sliceRequest(request, quantity, tag)); err != nil {
+				log.Printf("Error executing VWAP slice %d of order %s: %v", i+1, parent.ID, err)
+			} else {
+				placed++
+			}
+		}
+
+		a.updateExecution(tracking, marketValue, marketVolume, roundToLots(target, lotSize)-scheduled)
+	}
+
+	// Account for the market's volume during the last bucket
+	if !cancelled {
+		if price, volume, err := a.marketDataFunc(request.Symbol); err == nil && volume > lastVolume {
+			marketValue += float64(volume-lastVolume) * (price + lastPrice) / 2
+			marketVolume += volume - lastVolume
+		}
+	}
+	a.updateExecution(tracking, marketValue, marketVolume, roundToLots(target, lotSize)-scheduled)
+
+	if scheduled < request.Quantity && !cancelled {
+		log.Printf("VWAP order %s placed %d of %d within its participation cap", parent.ID, scheduled, request.Quantity)
+	}
+
+	return engine.finishParentOrder(parent, placed, len(profile), cancelled), nil
+}
+
+// roundToLots rounds quantity to the nearest whole number of lots
+func roundToLots(quantity float64, lotSize int) int {
+	return int(quantity/float64(lotSize)+0.5) * lotSize
+}
+
+// executeSlice places one slice of a VWAP order and links it to the parent order
+func (a *VWAPAlgorithm) executeSlice(ctx context.Context, engine *OrderExecutionEngine, parent *Order, slice *OrderRequest) error {
+	response, err := engine.ExecuteOrder(ctx, slice)
+	if err != nil {
+		return err
+	}
+
+	if !response.Status || response.Order == nil {
+		return fmt.Errorf("slice rejected: %s", response.Error)
+	}
+
+	return engine.addChildOrder(parent.ID, response.Order)
+}
+
+// updateExecution records the market's VWAP and volume since an order started and
+// notifies the listeners of the order's deviation from it
+func (a *VWAPAlgorithm) updateExecution(tracking *vwapTracking, marketValue float64, marketVolume, capped int) {
+	a.mutex.Lock()
+	tracking.MarketVolume = marketVolume
+	tracking.CappedQuantity = max(0, capped)
+	if marketVolume > 0 {
+		tracking.MarketVWAP = marketValue / float64(marketVolume)
+	}
+	tracking.refreshFills()
+	update := tracking.VWAPExecution
+	listeners := a.listeners
+	a.mutex.Unlock()
+
+	for _, listener := range listeners {
+		go listener(update)
+	}
+}
+
+// refreshFills updates an order's fills and their deviation from the market VWAP.
+// Slices keep filling after the algorithm has placed them, so this is also done
+// whenever the execution is read. The algorithm's lock must be held.
+func (t *vwapTracking) refreshFills() {
+	t.engine.ordersMutex.RLock()
+	t.FilledQuantity, t.AveragePrice = t.parent.FilledQuantity, t.parent.AveragePrice
+	t.engine.ordersMutex.RUnlock()
+
+	t.DeviationBps = 0
+	if t.FilledQuantity > 0 && t.MarketVWAP > 0 {
+		t.DeviationBps = (t.AveragePrice - t.MarketVWAP) / t.MarketVWAP * 10000
+		if t.parent.TransactionType == Sell {
+			t.DeviationBps = -t.DeviationBps
+		}
+	}
+	t.UpdatedAt = time.Now()
+}
+
+// Name returns the algorithm name
+func (a *VWAPAlgorithm) Name() string {
+	return "VWAP"
+}
+
+// Description returns the algorithm description
+func (a *VWAPAlgorithm) Description() string {
+	if a.maxParticipation == 0 {
+		return fmt.Sprintf("Volume-Weighted Average Price algorithm over %v", a.window)
+	}
+	return fmt.Sprintf("Volume-Weighted Average Price algorithm over %v, participating in at most %.0f%% of volume", a.window, a.maxParticipation*100)
+}
+
+// normalizeProfile scales a volume profile so its shares add up to one
+func normalizeProfile(profile []float64) ([]float64, error) {
+	total := 0.0
+	for _, share := range profile {
+		if share < 0 {
+			return nil, errors.New("volume profile cannot have negative shares")
+		}
+		total += share
+	}
+
+	if total == 0 {
+		return nil, errors.New("volume profile is empty")
+	}
+
+	normalized := make([]float64, len(profile))
+	for i, share := range profile {
+		normalized[i] = share / total
+	}
+
+	return normalized, nil
+}
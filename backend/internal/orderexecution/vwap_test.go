@@ -0,0 +1,155 @@
+package orderexecution
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestVWAPAlgorithm tests slicing an order by volume profile within a participation cap
+func TestVWAPAlgorithm(t *testing.T) {
+	// Create an engine with a mock broker
+	mockBroker := NewMockBrokerAdapter()
+	smartRouter := NewDefaultSmartRouter(BestPrice)
+	smartRouter.RegisterBroker("MOCK", mockBroker)
+	engine := NewOrderExecutionEngine(smartRouter)
+	engine.RegisterBroker("NSE", mockBroker)
+	
+	// The market trades 1000 a bucket, rising 10 each time it is looked at
+	var marketMutex sync.Mutex
+	price, volume := 2490.0, 0
+	marketData := func(symbol string) (float64, int, error) {
+		marketMutex.Lock()
+		defer marketMutex.Unlock()
+		price += 10
+		volume += 1000
+		return price, volume, nil
+	}
+	profile := func(symbol string) ([]float64, error) {
+		return []float64{5, 3, 2}, nil
+	}
+	
+	// Work the order over 150ms, taking at most 10% of the volume
+	vwap, err := NewVWAPAlgorithm(150*time.Millisecond, 0.1, profile, marketData)
+	if err != nil {
+		t.Errorf("Error creating VWAP algorithm: %v", err)
+		return
+	}
+	
+	request := &OrderRequest{
+		Symbol:          "RELIANCE-EQ",
+		Quantity:        1000,
+		Price:           2500.0,
+		OrderType:       Limit,
+		TransactionType: Buy,
+		Validity:        Day,
+		Exchange:        "NSE",
+		Product:         Normal,
+	}
+	
+	response, err := vwap.Execute(context.Background(), engine, request)
+	if err != nil {
+		t.Errorf("Error executing VWAP order: %v", err)
+		return
+	}
+	
+	// The first slice follows the profile, the others are capped at 100
+	parent := response.Order
+	expected := []int{500, 100, 100}
+	if len(parent.ChildOrderIDs) != len(expected) {
+		t.Errorf("Expected %d slices, got %d", len(expected), len(parent.ChildOrderIDs))
+		return
+	}
+	for i, childID := range parent.ChildOrderIDs {
+		child, _ := engine.GetOrder(childID)
+		if child.Quantity != expected[i] {
+			t.Errorf("Expected slice %d of %d, got %d", i+1, expected[i], child.Quantity)
+		}
+		mockBroker.SimulateOrderStatusChange(childID, Executed)
+	}
+	
+	// Fills are tracked against the market VWAP, 2515 over the three buckets
+	engine.SyncAllOrders(context.Background())
+	execution, err := vwap.GetExecution(parent.ID)
+	if err != nil {
+		t.Errorf("Error getting VWAP execution: %v", err)
+		return
+	}
+	if execution.MarketVolume != 3000 || execution.CappedQuantity != 300 || execution.FilledQuantity != 700 {
+		t.Errorf("Expected 700 filled of 3000 traded with 300 capped, got %d of %d with %d capped", execution.FilledQuantity, execution.MarketVolume, execution.CappedQuantity)
+	}
+	if math.Abs(execution.DeviationBps-(2500.0-2515.0)/2515.0*10000) > 1e-6 {
+		t.Errorf("Expected a deviation of %f bps, got %f", (2500.0-2515.0)/2515.0*10000, execution.DeviationBps)
+	}
+}
+
+// TestVWAPAlgorithmLots tests that VWAP slices are whole lots
+func TestVWAPAlgorithmLots(t *testing.T) {
+	mockBroker := NewMockBrokerAdapter()
+	smartRouter := NewDefaultSmartRouter(BestPrice)
+	smartRouter.RegisterBroker("MOCK", mockBroker)
+	engine := NewOrderExecutionEngine(smartRouter)
+	engine.RegisterBroker("NSE", mockBroker)
+	
+	// The market trades 1000 a bucket
+	var marketMutex sync.Mutex
+	volume := 0
+	marketData := func(symbol string) (float64, int, error) {
+		marketMutex.Lock()
+		defer marketMutex.Unlock()
+		volume += 1000
+		return 100.0, volume, nil
+	}
+	profile := func(symbol string) ([]float64, error) {
+		return []float64{1, 1, 1}, nil
+	}
+	
+	// Taking at most 12% of the volume caps the later buckets at 2 lots of 50
+	vwap, err := NewVWAPAlgorithm(60*time.Millisecond, 0.12, profile, marketData)
+	if err != nil {
+		t.Fatalf("Error creating VWAP algorithm: %v", err)
+	}
+	
+	request := &OrderRequest{
+		Symbol:          "NIFTY-FUT",
+		Quantity:        350,
+		LotSize:         50,
+		Price:           100.0,
+		OrderType:       Limit,
+		TransactionType: Buy,
+		Validity:        Day,
+		Exchange:        "NSE",
+		Product:         Normal,
+	}
+	
+	response, err := vwap.Execute(context.Background(), engine, request)
+	if err != nil {
+		t.Fatalf("Error executing VWAP order: %v", err)
+	}
+	
+	// A third of 7 lots rounds to 2, then the cap holds each bucket to 2 lots
+	expected := []int{100, 100, 100}
+	parent := response.Order
+	if len(parent.ChildOrderIDs) != len(expected) {
+		t.Fatalf("Expected %d slices, got %d", len(expected), len(parent.ChildOrderIDs))
+	}
+	for i, childID := range parent.ChildOrderIDs {
+		child, _ := engine.GetOrder(childID)
+		if child.Quantity != expected[i] {
+			t.Errorf("Expected slice %d of %d, got %d", i+1, expected[i], child.Quantity)
+		}
+	}
+	
+	execution, _ := vwap.GetExecution(parent.ID)
+	if execution.CappedQuantity != 50 {
+		t.Errorf("Expected 50 capped, got %d", execution.CappedQuantity)
+	}
+	
+	// Orders must be whole lots
+	request.Quantity = 120
+	if _, err := vwap.Execute(context.Background(), engine, request); err == nil {
+		t.Errorf("Expected an error for an order that is not whole lots")
+	}
+}
@@ -0,0 +1,654 @@
+package portfolioanalytics
+
+import (
+        "encoding/json"
+        "fmt"
+        "net/http"
+        "time"
+
+        "github.com/gorilla/mux"
+
+        "trading_platform/backend/internal/auth"
+        "trading_platform/backend/internal/models"
+)
+
+// RegisterRoutes registers API routes
+func (c *Controller) RegisterRoutes(router *mux.Router) {
+        // Performance endpoints
+        router.HandleFunc("/api/v1/portfolios/{id}/performance/attribution", c.GetPerformanceAttribution).Methods("GET")
+        router.HandleFunc("/api/v1/portfolios/{id}/performance/drawdowns", c.GetDrawdowns).Methods("GET")
+        router.Handle("/api/v1/portfolios/{id}/performance/equity", auth.AuthMiddleware(http.HandlerFunc(c.GetEquityCurve))).Methods("GET")
+
+        // Risk endpoints
+        router.HandleFunc("/api/v1/portfolios/{id}/risk/correlations", c.GetCorrelations).Methods("GET")
+        router.Handle("/api/v1/portfolios/{id}/risk", auth.AuthMiddleware(http.HandlerFunc(c.GetRisk))).Methods("GET")
+        router.Handle("/api/v1/portfolios/{id}/what-if", auth.AuthMiddleware(http.HandlerFunc(c.WhatIf))).Methods("POST")
+        router.Handle("/api/v1/portfolios/{id}/limits", auth.AuthMiddleware(http.HandlerFunc(c.GetExposureLimits))).Methods("GET")
+        router.Handle("/api/v1/portfolios/{id}/limits", auth.AuthMiddleware(http.HandlerFunc(c.SetExposureLimits))).Methods("PUT")
+        router.Handle("/api/v1/portfolios/{id}/alerts", auth.AuthMiddleware(http.HandlerFunc(c.GetAlerts))).Methods("GET")
+        router.Handle("/api/v1/portfolios/{id}/alerts/{alertId}/acknowledge", auth.AuthMiddleware(http.HandlerFunc(c.AcknowledgeAlert))).Methods("POST")
+        router.Handle("/api/v1/risk/limits", auth.AuthMiddleware(http.HandlerFunc(c.GetRiskLimits))).Methods("GET")
+        router.Handle("/api/v1/risk/limits", auth.AuthMiddleware(http.HandlerFunc(c.SetRiskLimits))).Methods("PUT")
+        router.Handle("/api/v1/risk/risk-free-rate", auth.AuthMiddleware(http.HandlerFunc(c.SetRiskFreeRate))).Methods("PUT")
+
+        // Account endpoints
+        router.Handle("/api/v1/accounts/{id}/exposures", auth.AuthMiddleware(http.HandlerFunc(c.GetAccountExposures))).Methods("GET")
+        router.Handle("/api/v1/accounts/{id}/tax-lots", auth.AuthMiddleware(http.HandlerFunc(c.GetTaxLots))).Methods("GET")
+        router.Handle("/api/v1/accounts/{id}/tax-lots/method", auth.AuthMiddleware(http.HandlerFunc(c.SetLotMatchingMethod))).Methods("PUT")
+        router.Handle("/api/v1/accounts/{id}/consolidated", auth.AuthMiddleware(http.HandlerFunc(c.GetConsolidatedView))).Methods("GET")
+        router.Handle("/api/v1/accounts/{id}/consolidated/brokers/{broker}", auth.AuthMiddleware(http.HandlerFunc(c.GetBrokerAccountView))).Methods("GET")
+        router.Handle("/api/v1/accounts/{id}/consolidated/portfolios/{portfolio}", auth.AuthMiddleware(http.HandlerFunc(c.GetConsolidatedPortfolio))).Methods("GET")
+}
+
+// GetPerformanceAttribution handles requests for a portfolio's P&L by strategy, symbol and sector
+func (c *Controller) GetPerformanceAttribution(w http.ResponseWriter, r *http.Request) {
+        // Get portfolio ID from URL
+        vars := mux.Vars(r)
+        portfolioID := vars["id"]
+
+        // Parse from and to dates
+        from, to, err := parseDateRange(r)
+        if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+        }
+
+        // Get attribution
+        attribution, err := c.service.GetPerformanceAttribution(r.Context(), portfolioID, from, to)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting performance attribution: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status":      "success",
+                "attribution": attribution,
+        })
+}
+
+// GetDrawdowns handles requests for a portfolio's drawdowns and underwater curve
+func (c *Controller) GetDrawdowns(w http.ResponseWriter, r *http.Request) {
+        // Get portfolio ID from URL
+        vars := mux.Vars(r)
+        portfolioID := vars["id"]
+
+        // Parse from and to dates
+        from, to, err := parseDateRange(r)
+        if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+        }
+
+        // Get drawdown analysis
+        analysis, err := c.service.GetDrawdownAnalysis(r.Context(), portfolioID, from, to)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting drawdowns: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status":    "success",
+                "drawdowns": analysis,
+        })
+}
+
+// GetCorrelations handles requests for the correlations between a portfolio's positions
+func (c *Controller) GetCorrelations(w http.ResponseWriter, r *http.Request) {
+        // Get portfolio ID from URL
+        vars := mux.Vars(r)
+        portfolioID := vars["id"]
+
+        // Get correlation analysis
+        analysis, err := c.service.GetCorrelationAnalysis(r.Context(), portfolioID)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting correlations: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status":       "success",
+                "correlations": analysis,
+        })
+}
+
+// GetRisk handles requests for a portfolio's risk metrics
+func (c *Controller) GetRisk(w http.ResponseWriter, r *http.Request) {
+        // Get portfolio ID from URL
+        vars := mux.Vars(r)
+        portfolioID := vars["id"]
+
+        // Check the user may see the portfolio
+        portfolio, err := c.service.GetPortfolio(r.Context(), portfolioID)
+        if err != nil {
+                http.Error(w, "Portfolio not found", http.StatusNotFound)
+                return
+        }
+
+        if status, ok := authorize(r, portfolio.UserID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Get risk metrics
+        metrics, err := c.service.GetRiskMetrics(r.Context(), portfolioID)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting risk metrics: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+                "risk":   metrics,
+        })
+}
+
+// WhatIf handles requests to project a portfolio's greeks, margin, VaR and stress
+// P&L after hypothetical changes to its positions. Nothing is persisted.
+func (c *Controller) WhatIf(w http.ResponseWriter, r *http.Request) {
+        // Get portfolio ID from URL
+        vars := mux.Vars(r)
+        portfolioID := vars["id"]
+
+        // Check the user may see the portfolio
+        portfolio, err := c.service.GetPortfolio(r.Context(), portfolioID)
+        if err != nil {
+                http.Error(w, "Portfolio not found", http.StatusNotFound)
+                return
+        }
+
+        if status, ok := authorize(r, portfolio.UserID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Parse request body
+        var request struct {
+                Changes []WhatIfChange
+        }
+        if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+                http.Error(w, "Invalid request payload", http.StatusBadRequest)
+                return
+        }
+
+        // Run the analysis
+        analysis, err := c.service.WhatIf(r.Context(), portfolioID, request.Changes)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error running what-if analysis: %v", err), http.StatusBadRequest)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+                "whatIf": analysis,
+        })
+}
+
+// GetEquityCurve handles requests for a portfolio's daily equity snapshots
+func (c *Controller) GetEquityCurve(w http.ResponseWriter, r *http.Request) {
+        // Get portfolio ID from URL
+        vars := mux.Vars(r)
+        portfolioID := vars["id"]
+
+        if status, ok := c.authorizePortfolio(r, portfolioID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Get equity curve
+        curve, err := c.service.GetEquityCurve(r.Context(), portfolioID)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting equity curve: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+                "equity": curve,
+        })
+}
+
+// GetExposureLimits handles requests for a portfolio's exposure limits and their utilization
+func (c *Controller) GetExposureLimits(w http.ResponseWriter, r *http.Request) {
+        // Get portfolio ID from URL
+        vars := mux.Vars(r)
+        portfolioID := vars["id"]
+
+        if status, ok := c.authorizePortfolio(r, portfolioID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Get exposure limits
+        report, err := c.service.GetExposureLimits(r.Context(), portfolioID)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting exposure limits: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+                "limits": report,
+        })
+}
+
+// SetExposureLimits handles requests to set the exposure limits a portfolio is
+// alerted on
+func (c *Controller) SetExposureLimits(w http.ResponseWriter, r *http.Request) {
+        // Get portfolio ID from URL
+        vars := mux.Vars(r)
+        portfolioID := vars["id"]
+
+        if status, ok := c.authorizePortfolio(r, portfolioID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Parse request body
+        var limits ExposureLimits
+        if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+                http.Error(w, "Invalid request payload", http.StatusBadRequest)
+                return
+        }
+
+        if err := c.service.SetExposureLimits(r.Context(), portfolioID, limits); err != nil {
+                http.Error(w, fmt.Sprintf("Error setting exposure limits: %v", err), http.StatusBadRequest)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+        })
+}
+
+// GetAlerts handles requests for the alerts raised for a portfolio
+func (c *Controller) GetAlerts(w http.ResponseWriter, r *http.Request) {
+        // Get portfolio ID from URL
+        vars := mux.Vars(r)
+        portfolioID := vars["id"]
+
+        if status, ok := c.authorizePortfolio(r, portfolioID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Get alerts
+        alerts, err := c.service.GetAlerts(r.Context(), portfolioID)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting alerts: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+                "alerts": alerts,
+        })
+}
+
+// AcknowledgeAlert handles requests to mark one of a portfolio's alerts as seen
+func (c *Controller) AcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+        // Get portfolio and alert IDs from URL
+        vars := mux.Vars(r)
+        portfolioID := vars["id"]
+        alertID := vars["alertId"]
+
+        if status, ok := c.authorizePortfolio(r, portfolioID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        if err := c.service.AcknowledgeAlert(r.Context(), portfolioID, alertID); err != nil {
+                http.Error(w, fmt.Sprintf("Error acknowledging alert: %v", err), http.StatusNotFound)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+        })
+}
+
+// GetAccountExposures handles requests for the combined exposure of an account's portfolios
+func (c *Controller) GetAccountExposures(w http.ResponseWriter, r *http.Request) {
+        // Get account ID from URL
+        vars := mux.Vars(r)
+        accountID := vars["id"]
+
+        if status, ok := authorize(r, accountID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Get exposures
+        exposure, err := c.service.GetAccountExposure(r.Context(), accountID)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting exposures: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status":    "success",
+                "exposures": exposure,
+        })
+}
+
+// GetTaxLots handles requests for an account's open tax lots and realized gains
+func (c *Controller) GetTaxLots(w http.ResponseWriter, r *http.Request) {
+        // Get account ID from URL
+        vars := mux.Vars(r)
+        accountID := vars["id"]
+
+        if status, ok := authorize(r, accountID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Parse from and to dates
+        from, to, err := parseDateRange(r)
+        if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+        }
+
+        // Get tax lots
+        report, err := c.service.GetTaxLotReport(r.Context(), accountID, from, to)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting tax lots: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status":  "success",
+                "taxLots": report,
+        })
+}
+
+// GetConsolidatedView handles requests for the combined P&L, exposure and greeks of a
+// user's portfolios. The environment query parameter selects LIVE or SIM portfolios.
+func (c *Controller) GetConsolidatedView(w http.ResponseWriter, r *http.Request) {
+        // Get user ID from URL
+        vars := mux.Vars(r)
+        userID := vars["id"]
+
+        if status, ok := authorize(r, userID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Get consolidated view
+        view, err := c.service.GetConsolidatedView(r.Context(), userID, r.URL.Query().Get("environment"))
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting consolidated view: %v", err), http.StatusBadRequest)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status":       "success",
+                "consolidated": view,
+        })
+}
+
+// GetBrokerAccountView handles requests drilling a user's consolidated view down to
+// one broker account
+func (c *Controller) GetBrokerAccountView(w http.ResponseWriter, r *http.Request) {
+        // Get user and broker account IDs from URL
+        vars := mux.Vars(r)
+        userID := vars["id"]
+        brokerAccount := vars["broker"]
+
+        if status, ok := authorize(r, userID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Get consolidated view
+        view, err := c.service.GetBrokerAccountView(r.Context(), userID, r.URL.Query().Get("environment"), brokerAccount)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting consolidated view: %v", err), http.StatusNotFound)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status":       "success",
+                "consolidated": view,
+        })
+}
+
+// GetConsolidatedPortfolio handles requests drilling a user's consolidated view down
+// to one portfolio
+func (c *Controller) GetConsolidatedPortfolio(w http.ResponseWriter, r *http.Request) {
+        // Get user and portfolio IDs from URL
+        vars := mux.Vars(r)
+        userID := vars["id"]
+        portfolioID := vars["portfolio"]
+
+        if status, ok := authorize(r, userID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Get consolidated view
+        view, err := c.service.GetConsolidatedPortfolio(r.Context(), userID, portfolioID)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting consolidated view: %v", err), http.StatusNotFound)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status":       "success",
+                "consolidated": view,
+        })
+}
+
+// SetLotMatchingMethod handles requests to set how an account's sales are matched to its tax lots
+func (c *Controller) SetLotMatchingMethod(w http.ResponseWriter, r *http.Request) {
+        // Get account ID from URL
+        vars := mux.Vars(r)
+        accountID := vars["id"]
+
+        if status, ok := authorize(r, accountID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Parse request body
+        var request struct {
+                Method string
+        }
+        if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+                http.Error(w, "Invalid request payload", http.StatusBadRequest)
+                return
+        }
+
+        if err := c.service.SetLotMatchingMethod(r.Context(), accountID, request.Method); err != nil {
+                http.Error(w, fmt.Sprintf("Error setting lot matching method: %v", err), http.StatusBadRequest)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+                "method": request.Method,
+        })
+}
+
+// GetRiskLimits handles requests for the user's risk limits and their utilization.
+// Admins may ask for another account's with the account_id query parameter.
+func (c *Controller) GetRiskLimits(w http.ResponseWriter, r *http.Request) {
+        accountID := r.URL.Query().Get("account_id")
+        if accountID == "" {
+                accountID = auth.GetUserIDFromContext(r.Context())
+        }
+
+        if status, ok := authorize(r, accountID); !ok {
+                http.Error(w, http.StatusText(status), status)
+                return
+        }
+
+        // Get risk limits
+        report, err := c.service.GetRiskLimits(r.Context(), accountID)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting risk limits: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+                "limits": report,
+        })
+}
+
+// SetRiskLimits handles requests to set an account's risk limits. Only admins may
+// set limits, so users cannot raise their own.
+func (c *Controller) SetRiskLimits(w http.ResponseWriter, r *http.Request) {
+        if auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+                http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+                return
+        }
+
+        // Parse request body
+        var request struct {
+                AccountID string
+                Limits    RiskLimits
+        }
+        if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+                http.Error(w, "Invalid request payload", http.StatusBadRequest)
+                return
+        }
+
+        if err := c.service.SetRiskLimits(r.Context(), request.AccountID, request.Limits); err != nil {
+                http.Error(w, fmt.Sprintf("Error setting risk limits: %v", err), http.StatusBadRequest)
+                return
+        }
+
+        // Return the limits with their utilization
+        report, err := c.service.GetRiskLimits(r.Context(), request.AccountID)
+        if err != nil {
+                http.Error(w, fmt.Sprintf("Error getting risk limits: %v", err), http.StatusInternalServerError)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+                "limits": report,
+        })
+}
+
+// SetRiskFreeRate handles requests to set the annual rate Sharpe and Sortino ratios
+// are measured against. The rate applies to every portfolio, so only admins may set it.
+func (c *Controller) SetRiskFreeRate(w http.ResponseWriter, r *http.Request) {
+        if auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+                http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+                return
+        }
+
+        // Parse request body
+        var request struct {
+                Rate float64
+        }
+        if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+                http.Error(w, "Invalid request payload", http.StatusBadRequest)
+                return
+        }
+
+        if err := c.service.SetRiskFreeRate(r.Context(), request.Rate); err != nil {
+                http.Error(w, fmt.Sprintf("Error setting risk-free rate: %v", err), http.StatusBadRequest)
+                return
+        }
+
+        // Return response
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]interface{}{
+                "status": "success",
+        })
+}
+
+// authorize checks the authenticated user may see an account's data, returning the
+// status to respond with when they may not. Admins may see every account.
+func authorize(r *http.Request, accountID string) (int, bool) {
+        userID := auth.GetUserIDFromContext(r.Context())
+        if userID == "" {
+                return http.StatusUnauthorized, false
+        }
+
+        if userID != accountID && auth.GetRoleFromContext(r.Context()) != string(models.UserRoleAdmin) {
+                return http.StatusForbidden, false
+        }
+
+        return http.StatusOK, true
+}
+
+// authorizePortfolio checks the authenticated user may see a portfolio, returning the
+// status to respond with when they may not
+func (c *Controller) authorizePortfolio(r *http.Request, portfolioID string) (int, bool) {
+        portfolio, err := c.service.GetPortfolio(r.Context(), portfolioID)
+        if err != nil {
+                return http.StatusNotFound, false
+        }
+
+        return authorize(r, portfolio.UserID)
+}
+
+// parseDateRange parses the from and to query parameters, both YYYY-MM-DD and
+// inclusive, defaulting to the 30 days up to and including today. The returned end
+// is midnight after the to date.
+func parseDateRange(r *http.Request) (time.Time, time.Time, error) {
+        fromStr := r.URL.Query().Get("from")
+        toStr := r.URL.Query().Get("to")
+
+        // Default to today
+        to := time.Now().UTC().Truncate(24 * time.Hour)
+        if toStr != "" {
+                parsed, err := time.Parse("2006-01-02", toStr)
+                if err != nil {
+                        return time.Time{}, time.Time{}, fmt.Errorf("invalid to date: %s", toStr)
+                }
+                to = parsed
+        }
+        to = to.AddDate(0, 0, 1)
+
+        // Default to 30 days before the end
+        from := to.AddDate(0, 0, -30)
+        if fromStr != "" {
+                parsed, err := time.Parse("2006-01-02", fromStr)
+                if err != nil {
+                        return time.Time{}, time.Time{}, fmt.Errorf("invalid from date: %s", fromStr)
+                }
+                from = parsed
+        }
+
+        return from, to, nil
+}
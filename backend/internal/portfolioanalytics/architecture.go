@@ -5,6 +5,7 @@ import (
         "database/sql"
         "encoding/json"
         "errors"
+        "sync"
         "time"
 )
 
@@ -77,12 +78,33 @@ type Service interface {
         // Analytics operations
         GetPerformanceMetrics(ctx context.Context, portfolioID string) (*PerformanceMetrics, error)
         GetRiskMetrics(ctx context.Context, portfolioID string) (*RiskMetrics, error)
+        GetCorrelationAnalysis(ctx context.Context, portfolioID string) (*CorrelationAnalysis, error)
+        GetPerformanceAttribution(ctx context.Context, portfolioID string, startDate, endDate time.Time) (*PerformanceAttribution, error)
+        GetDrawdownAnalysis(ctx context.Context, portfolioID string, startDate, endDate time.Time) (*DrawdownAnalysis, error)
         GetHistoricalPerformance(ctx context.Context, portfolioID string, startDate, endDate time.Time, interval string) (map[time.Time]*PerformanceMetrics, error)
         GetHistoricalRisk(ctx context.Context, portfolioID string, startDate, endDate time.Time, interval string) (map[time.Time]*RiskMetrics, error)
+        GetAccountExposure(ctx context.Context, accountID string) (*AccountExposure, error)
+        GetRiskLimits(ctx context.Context, accountID string) (*RiskLimitReport, error)
+        SetRiskLimits(ctx context.Context, accountID string, limits RiskLimits) error
+        WhatIf(ctx context.Context, portfolioID string, changes []WhatIfChange) (*WhatIfAnalysis, error)
+        GetTaxLotReport(ctx context.Context, accountID string, startDate, endDate time.Time) (*TaxLotReport, error)
+        SetLotMatchingMethod(ctx context.Context, accountID, method string) error
+        GetConsolidatedView(ctx context.Context, userID, environment string) (*ConsolidatedView, error)
+        GetBrokerAccountView(ctx context.Context, userID, environment, brokerAccount string) (*ConsolidatedView, error)
+        GetConsolidatedPortfolio(ctx context.Context, userID, portfolioID string) (*ConsolidatedView, error)
+        GetExposureLimits(ctx context.Context, portfolioID string) (*ExposureLimitReport, error)
+        SetExposureLimits(ctx context.Context, portfolioID string, limits ExposureLimits) error
+        GetAlerts(ctx context.Context, portfolioID string) ([]Alert, error)
+        AcknowledgeAlert(ctx context.Context, portfolioID, alertID string) error
+        GetEquityCurve(ctx context.Context, portfolioID string) ([]EquitySnapshot, error)
+        SetRiskFreeRate(ctx context.Context, rate float64) error
         
         // Real-time operations
         SubscribeToUpdates(portfolioID string, callback func(interface{})) (string, error)
         UnsubscribeFromUpdates(subscriptionID string) error
+        GetGreeks(ctx context.Context, scope, id string) (*AggregateGreeks, error)
+        SubscribeToGreeks(scope, id string, callback func(interface{})) (string, error)
+        UnsubscribeFromGreeks(subscriptionID string) error
         
         // Batch operations
         AnalyzeMultiplePortfolios(ctx context.Context, portfolioIDs []string) (map[string]*PortfolioAnalysis, error)
@@ -125,16 +147,21 @@ type Alert struct {
 type ServiceImpl struct {
         repository Repository
         engine     *PortfolioAnalyticsEngine
-        subscribers map[string]map[string]func(interface{})
+        subscribers map[string]map[string]func(interface{}) // Callbacks by portfolio and subscription ID
+        mutex       sync.Mutex
+        nextID      int
 }
 
 // NewService creates a new portfolio analytics service
 func NewService(repository Repository, engine *PortfolioAnalyticsEngine) Service {
-        return &ServiceImpl{
+        s := &ServiceImpl{
                 repository:  repository,
                 engine:      engine,
                 subscribers: make(map[string]map[string]func(interface{})),
         }
+        engine.AddMetricsListener(s.publishUpdate)
+
+        return s
 }
 
 // Implementation of Service interface methods for ServiceImpl
@@ -183,6 +210,7 @@ const (
                 description TEXT,
                 user_id VARCHAR(36) NOT NULL,
                 strategy_id VARCHAR(36),
+                benchmark VARCHAR(50),
                 tags JSONB,
                 created_at TIMESTAMP NOT NULL,
                 updated_at TIMESTAMP NOT NULL
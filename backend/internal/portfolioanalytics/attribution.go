@@ -0,0 +1,230 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "sort"
+        "time"
+)
+
+// unassignedStrategy is the strategy of positions opened outside any strategy
+const unassignedStrategy = "UNASSIGNED"
+
+// errNoOptionHistory is returned when an option would have to be valued at a past
+// close, since daily prices are only available for underlyings
+var errNoOptionHistory = errors.New("no historical prices for options")
+
+// PerformanceAttribution decomposes a portfolio's P&L over a date range into the
+// contributions of its strategies, symbols and sectors. Options held across the
+// start of the range or past its end, before today, cannot be valued at those
+// dates and are left out, listed in Unattributed.
+type PerformanceAttribution struct {
+        PortfolioID  string
+        StartDate    time.Time
+        EndDate      time.Time
+        TotalPnL     float64
+        ByStrategy   map[string]float64
+        BySymbol     map[string]float64
+        BySector     map[string]float64
+        Unattributed []string // IDs of the positions left out
+        UpdatedAt    time.Time
+}
+
+// GetPerformanceAttribution returns the contributions to a portfolio's P&L between
+// startDate and endDate. Results are cached until the portfolio's positions change.
+func (e *PortfolioAnalyticsEngine) GetPerformanceAttribution(portfolioID string, startDate, endDate time.Time) (*PerformanceAttribution, error) {
+        if !endDate.After(startDate) {
+                return nil, errors.New("end date must be after start date")
+        }
+
+        key := startDate.UTC().Format(time.RFC3339) + "/" + endDate.UTC().Format(time.RFC3339)
+
+        // Prices are fetched without holding the engine's lock, from copies of the
+        // positions taken under it
+        e.mutex.RLock()
+        portfolio, exists := e.portfolios[portfolioID]
+        if !exists {
+                e.mutex.RUnlock()
+                return nil, fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+        if attribution, exists := e.attributionCache[portfolioID][key]; exists && attribution.UpdatedAt.After(time.Now().Add(-1*time.Hour)) {
+                e.mutex.RUnlock()
+                return attribution, nil
+        }
+        held := e.positions[portfolioID]
+        positions := make([]Position, len(held))
+        for i, position := range held {
+                positions[i] = *position
+        }
+        portfolioStrategyID := portfolio.StrategyID
+        referenceData := e.referenceData
+        e.mutex.RUnlock()
+
+        attribution := &PerformanceAttribution{
+                PortfolioID: portfolioID,
+                StartDate:   startDate,
+                EndDate:     endDate,
+                ByStrategy:  make(map[string]float64),
+                BySymbol:    make(map[string]float64),
+                BySector:    make(map[string]float64),
+        }
+
+        ctx := context.Background()
+        history := &priceHistory{
+                startDate: startDate.AddDate(0, 0, -7), // Covers holidays just before the range
+                endDate:   endDate,
+                prices:    make(map[string][]datedPrice),
+        }
+
+        for i := range positions {
+                position := &positions[i]
+                pnl, err := e.pnlBetween(ctx, position, startDate, endDate, history)
+                if errors.Is(err, errNoOptionHistory) {
+                        attribution.Unattributed = append(attribution.Unattributed, position.ID)
+                        continue
+                }
+                if err != nil {
+                        return nil, err
+                }
+                if pnl == 0 {
+                        continue
+                }
+
+                strategyID := position.StrategyID
+                if strategyID == "" {
+                        strategyID = portfolioStrategyID
+                }
+                if strategyID == "" {
+                        strategyID = unassignedStrategy
+                }
+
+                attribution.TotalPnL += pnl
+                attribution.ByStrategy[strategyID] += pnl
+                attribution.BySymbol[position.Symbol] += pnl
+                attribution.BySector[referenceData.Classify(position.Symbol).Sector] += pnl
+        }
+
+        attribution.UpdatedAt = time.Now()
+
+        // Positions that changed meanwhile have invalidated the cache, and the
+        // attribution of the ones copied must not be cached in its place
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        if samePositions(held, e.positions[portfolioID]) && referenceData == e.referenceData {
+                if e.attributionCache[portfolioID] == nil {
+                        e.attributionCache[portfolioID] = make(map[string]*PerformanceAttribution)
+                }
+                e.attributionCache[portfolioID][key] = attribution
+        }
+
+        return attribution, nil
+}
+
+// samePositions reports whether two lists hold the same positions in the same order
+func samePositions(a, b []*Position) bool {
+        if len(a) != len(b) {
+                return false
+        }
+        for i := range a {
+                if a[i] != b[i] {
+                        return false
+                }
+        }
+        return true
+}
+
+// priceHistory holds the daily closing prices of symbols over a date range
+type priceHistory struct {
+        startDate time.Time
+        endDate   time.Time
+        prices    map[string][]datedPrice // Oldest first
+}
+
+// datedPrice is a daily closing price
+type datedPrice struct {
+        date  time.Time
+        price float64
+}
+
+// pnlBetween returns the P&L a position made between startDate and endDate: the
+// change in its value over the part of the range it was held. The position is
+// valued at its entry and exit prices when it was opened or closed within the range
+// and at its current price when the range runs to now; otherwise at the last close
+// before the date, so that a range ending at midnight includes that day's close.
+// Options needing such a close return errNoOptionHistory.
+func (e *PortfolioAnalyticsEngine) pnlBetween(ctx context.Context, position *Position, startDate, endDate time.Time, history *priceHistory) (float64, error) {
+        if position.EntryTime.After(endDate) || (position.ExitTime != nil && position.ExitTime.Before(startDate)) {
+                return 0, nil
+        }
+
+        startPrice := position.EntryPrice
+        if position.EntryTime.Before(startDate) {
+                price, err := e.closeBefore(ctx, position, startDate, history)
+                if err != nil {
+                        return 0, err
+                }
+                startPrice = price
+        }
+
+        var endPrice float64
+        switch {
+        case position.ExitTime != nil && position.ExitPrice != nil && !position.ExitTime.After(endDate):
+                endPrice = *position.ExitPrice
+        case !endDate.Before(time.Now().Truncate(24 * time.Hour)):
+                endPrice = position.CurrentPrice
+        default:
+                price, err := e.closeBefore(ctx, position, endDate, history)
+                if err != nil {
+                        return 0, err
+                }
+                endPrice = price
+        }
+
+        pnl := float64(position.Quantity) * (endPrice - startPrice)
+        if position.TransactionType == "SELL" {
+                pnl = -pnl
+        }
+
+        return pnl, nil
+}
+
+// closeBefore returns the last closing price of a position's instrument dated
+// before date, fetching the symbol's prices into history on first use. Options
+// return errNoOptionHistory, since daily prices are only available for their
+// underlying.
+func (e *PortfolioAnalyticsEngine) closeBefore(ctx context.Context, position *Position, date time.Time, history *priceHistory) (float64, error) {
+        if position.OptionType != nil {
+                return 0, errNoOptionHistory
+        }
+        if e.dataProvider == nil {
+                return position.EntryPrice, nil
+        }
+
+        prices, fetched := history.prices[position.Symbol]
+        if !fetched {
+                byDate, err := e.dataProvider.GetHistoricalPrices(ctx, position.Symbol, position.Exchange, history.startDate, history.endDate, "1d")
+                if err != nil {
+                        return 0, fmt.Errorf("failed to get historical prices for %s: %w", position.Symbol, err)
+                }
+
+                prices = make([]datedPrice, 0, len(byDate))
+                for day, price := range byDate {
+                        prices = append(prices, datedPrice{date: day, price: price})
+                }
+                sort.Slice(prices, func(i, j int) bool {
+                        return prices[i].date.Before(prices[j].date)
+                })
+                history.prices[position.Symbol] = prices
+        }
+
+        i := sort.Search(len(prices), func(i int) bool {
+                return !prices[i].date.Before(date)
+        })
+        if i == 0 {
+                return position.EntryPrice, nil
+        }
+
+        return prices[i-1].price, nil
+}
@@ -0,0 +1,73 @@
+package portfolioanalytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerformanceAttribution(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 3, 1+i, 0, 0, 0, 0, time.UTC) }
+	closes := map[time.Time]float64{day(0): 100, day(1): 110, day(2): 120, day(3): 130}
+	exit := 125.0
+	call := "CE"
+
+	engine := NewPortfolioAnalyticsEngine(&stubDataProvider{prices: map[string]map[time.Time]float64{"A": closes}}, 1)
+	require.NoError(t, engine.AddPortfolio(&Portfolio{ID: "portfolio1", StrategyID: "strategy1", Positions: []*Position{
+		// Held across the range, valued at the closes before its start and end
+		{ID: "held", Symbol: "A", Quantity: 10, EntryPrice: 90, EntryTime: day(-5), TransactionType: "BUY"},
+		// Sold short within the range and covered at its exit price
+		{ID: "short", Symbol: "A", StrategyID: "strategy2", Quantity: 5, EntryPrice: 115, EntryTime: day(1).Add(time.Hour), ExitTime: timePointer(day(2).Add(time.Hour)), ExitPrice: &exit, TransactionType: "SELL"},
+		// An option opened before the range has no close to value it at
+		{ID: "option", Symbol: "A24MAR100CE", Quantity: 50, EntryPrice: 5, EntryTime: day(-5), OptionType: &call, TransactionType: "BUY"},
+	}}))
+
+	attribution, err := engine.GetPerformanceAttribution("portfolio1", day(1), day(3))
+	require.NoError(t, err)
+
+	// 10 x (120 - 100) long and 5 x (115 - 125) short
+	assert.InDelta(t, 150, attribution.TotalPnL, 1e-9)
+	assert.InDelta(t, 200, attribution.ByStrategy["strategy1"], 1e-9)
+	assert.InDelta(t, -50, attribution.ByStrategy["strategy2"], 1e-9)
+	assert.InDelta(t, 150, attribution.BySymbol["A"], 1e-9)
+	assert.Equal(t, []string{"option"}, attribution.Unattributed)
+
+	// Cached until the positions change
+	cached, err := engine.GetPerformanceAttribution("portfolio1", day(1), day(3))
+	require.NoError(t, err)
+	assert.Same(t, attribution, cached)
+
+	require.NoError(t, engine.DeletePosition("portfolio1", "short"))
+	recalculated, err := engine.GetPerformanceAttribution("portfolio1", day(1), day(3))
+	require.NoError(t, err)
+	assert.InDelta(t, 200, recalculated.TotalPnL, 1e-9)
+
+	_, err = engine.GetPerformanceAttribution("portfolio1", day(3), day(1))
+	assert.Error(t, err)
+	_, err = engine.GetPerformanceAttribution("missing", day(1), day(3))
+	assert.Error(t, err)
+}
+
+func TestSamePositions(t *testing.T) {
+	a, b := &Position{ID: "a"}, &Position{ID: "b"}
+
+	tests := []struct {
+		name  string
+		x, y  []*Position
+		equal bool
+	}{
+		{"Same", []*Position{a, b}, []*Position{a, b}, true},
+		{"Both empty", nil, []*Position{}, true},
+		{"Reordered", []*Position{a, b}, []*Position{b, a}, false},
+		{"Added", []*Position{a}, []*Position{a, b}, false},
+		{"Replaced by a copy", []*Position{a}, []*Position{{ID: "a"}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.equal, samePositions(test.x, test.y))
+		})
+	}
+}
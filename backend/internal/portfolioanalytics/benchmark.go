@@ -0,0 +1,157 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "fmt"
+        "math"
+        "sort"
+        "time"
+)
+
+// BenchmarkComparison compares a portfolio's daily returns with those of its benchmark index
+type BenchmarkComparison struct {
+        Benchmark        string
+        Alpha            float64 // Annualized Jensen's alpha, in percent
+        Beta             float64
+        TrackingError    float64 // Annualized, in percent
+        InformationRatio float64
+        UpCapture        float64            // Portfolio's share of the benchmark's gains, in percent
+        DownCapture      float64            // Portfolio's share of the benchmark's losses, in percent
+        PortfolioReturn  float64            // Cumulative, in percent
+        BenchmarkReturn  float64            // Cumulative, in percent
+        RelativeReturn   float64            // PortfolioReturn less BenchmarkReturn
+        RelativeSeries   map[string]float64 // RelativeReturn by date
+}
+
+// SetBenchmark sets the index a portfolio's performance is compared against. An
+// empty benchmark stops the comparison.
+func (e *PortfolioAnalyticsEngine) SetBenchmark(portfolioID, benchmark string) error {
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        portfolio, exists := e.portfolios[portfolioID]
+        if !exists {
+                return fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+
+        portfolio.Benchmark = benchmark
+
+        // Invalidate cache
+        delete(e.performanceCache, portfolioID)
+
+        return nil
+}
+
+// compareToBenchmark compares capital plus the cumulative P&L of curve with the
+// benchmark index from the first date the index has a price for
+func (e *PortfolioAnalyticsEngine) compareToBenchmark(ctx context.Context, benchmark string, curve []equityPoint, capital float64) (*BenchmarkComparison, error) {
+        comparison := &BenchmarkComparison{
+                Benchmark:      benchmark,
+                RelativeSeries: make(map[string]float64),
+        }
+        if len(curve) < 2 || capital <= 0 || e.dataProvider == nil {
+                return comparison, nil
+        }
+
+        startDate := curve[0].date.AddDate(0, 0, -7) // Covers holidays just before the curve
+        endDate := curve[len(curve)-1].date.AddDate(0, 0, 1)
+
+        prices, err := e.dataProvider.GetHistoricalPrices(ctx, benchmark, e.riskConfig.IndexExchange, startDate, endDate, "1d")
+        if err != nil {
+                return nil, fmt.Errorf("failed to get historical prices for %s: %w", benchmark, err)
+        }
+
+        dates := make([]time.Time, 0, len(prices))
+        for date := range prices {
+                dates = append(dates, date)
+        }
+        sort.Slice(dates, func(i, j int) bool {
+                return dates[i].Before(dates[j])
+        })
+
+        // Align the benchmark's last close of each day with the curve
+        var points []equityPoint
+        var levels []float64
+        for _, point := range curve {
+                i := sort.Search(len(dates), func(i int) bool {
+                        return !dates[i].Before(point.date.AddDate(0, 0, 1))
+                })
+                if i == 0 || prices[dates[i-1]] <= 0 {
+                        continue
+                }
+
+                points = append(points, point)
+                levels = append(levels, prices[dates[i-1]])
+        }
+
+        if len(points) < 2 {
+                return comparison, nil
+        }
+
+        startEquity := capital + points[0].pnl
+        for i, point := range points {
+                portfolioReturn := ((capital+point.pnl)/startEquity - 1) * 100
+                benchmarkReturn := (levels[i]/levels[0] - 1) * 100
+
+                comparison.PortfolioReturn = portfolioReturn
+                comparison.BenchmarkReturn = benchmarkReturn
+                comparison.RelativeReturn = portfolioReturn - benchmarkReturn
+                comparison.RelativeSeries[point.date.Format("2006-01-02")] = comparison.RelativeReturn
+        }
+
+        // The first return is always zero
+        portfolioReturns := dailyReturns(points, capital)[1:]
+        benchmarkReturns := make([]float64, len(portfolioReturns))
+        activeReturns := make([]float64, len(portfolioReturns))
+        for i := range benchmarkReturns {
+                benchmarkReturns[i] = levels[i+1]/levels[i] - 1
+                activeReturns[i] = portfolioReturns[i] - benchmarkReturns[i]
+        }
+
+        if variance := sampleCovariance(benchmarkReturns, benchmarkReturns); variance > 0 {
+                comparison.Beta = sampleCovariance(portfolioReturns, benchmarkReturns) / variance
+        }
+
+        dailyRiskFree := e.riskConfig.RiskFreeRate / tradingDaysPerYear
+        excessPortfolio := mean(portfolioReturns) - dailyRiskFree
+        excessBenchmark := mean(benchmarkReturns) - dailyRiskFree
+        comparison.Alpha = (excessPortfolio - comparison.Beta*excessBenchmark) * tradingDaysPerYear * 100
+
+        trackingError := math.Sqrt(sampleCovariance(activeReturns, activeReturns) * tradingDaysPerYear)
+        comparison.TrackingError = trackingError * 100
+        if trackingError > 0 {
+                comparison.InformationRatio = mean(activeReturns) * tradingDaysPerYear / trackingError
+        }
+
+        var upPortfolio, upBenchmark, downPortfolio, downBenchmark float64
+        for i, benchmarkReturn := range benchmarkReturns {
+                if benchmarkReturn > 0 {
+                        upPortfolio += portfolioReturns[i]
+                        upBenchmark += benchmarkReturn
+                } else if benchmarkReturn < 0 {
+                        downPortfolio += portfolioReturns[i]
+                        downBenchmark += benchmarkReturn
+                }
+        }
+        if upBenchmark != 0 {
+                comparison.UpCapture = upPortfolio / upBenchmark * 100
+        }
+        if downBenchmark != 0 {
+                comparison.DownCapture = downPortfolio / downBenchmark * 100
+        }
+
+        return comparison, nil
+}
+
+// mean returns the arithmetic mean of values
+func mean(values []float64) float64 {
+        if len(values) == 0 {
+                return 0
+        }
+
+        sum := 0.0
+        for _, value := range values {
+                sum += value
+        }
+        return sum / float64(len(values))
+}
@@ -0,0 +1,208 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "time"
+
+        "github.com/go-redis/redis/v8"
+        "github.com/trading-platform/backend/internal/messagequeue"
+)
+
+// AnalyticsCacheStore persists computed metrics so they survive restarts. Load
+// methods return nil without an error when nothing is stored for the portfolio.
+type AnalyticsCacheStore interface {
+        SavePerformanceMetrics(ctx context.Context, portfolioID string, metrics *PerformanceMetrics) error
+        LoadPerformanceMetrics(ctx context.Context, portfolioID string) (*PerformanceMetrics, error)
+        SaveRiskMetrics(ctx context.Context, portfolioID string, metrics *RiskMetrics) error
+        LoadRiskMetrics(ctx context.Context, portfolioID string) (*RiskMetrics, error)
+        DeleteMetrics(ctx context.Context, portfolioID string) error
+}
+
+// RedisAnalyticsCacheStore is an AnalyticsCacheStore backed by Redis. Metrics keep
+// their UpdatedAt timestamp, so the engine applies the same freshness check to them
+// as to metrics it calculated itself.
+type RedisAnalyticsCacheStore struct {
+        redis      *messagequeue.RedisClient
+        prefix     string
+        expiration time.Duration
+}
+
+// NewRedisAnalyticsCacheStore creates a Redis-backed cache store whose entries expire
+// after expiration, or never when it is zero
+func NewRedisAnalyticsCacheStore(redis *messagequeue.RedisClient, expiration time.Duration) *RedisAnalyticsCacheStore {
+        return &RedisAnalyticsCacheStore{
+                redis:      redis,
+                prefix:     "analytics",
+                expiration: expiration,
+        }
+}
+
+// performanceKey returns the Redis key holding a portfolio's performance metrics
+func (s *RedisAnalyticsCacheStore) performanceKey(portfolioID string) string {
+        return fmt.Sprintf("%s:performance:%s", s.prefix, portfolioID)
+}
+
+// riskKey returns the Redis key holding a portfolio's risk metrics
+func (s *RedisAnalyticsCacheStore) riskKey(portfolioID string) string {
+        return fmt.Sprintf("%s:risk:%s", s.prefix, portfolioID)
+}
+
+// SavePerformanceMetrics implements AnalyticsCacheStore
+func (s *RedisAnalyticsCacheStore) SavePerformanceMetrics(ctx context.Context, portfolioID string, metrics *PerformanceMetrics) error {
+        return s.redis.Set(ctx, s.performanceKey(portfolioID), metrics, s.expiration)
+}
+
+// LoadPerformanceMetrics implements AnalyticsCacheStore
+func (s *RedisAnalyticsCacheStore) LoadPerformanceMetrics(ctx context.Context, portfolioID string) (*PerformanceMetrics, error) {
+        var metrics PerformanceMetrics
+        if err := s.redis.Get(ctx, s.performanceKey(portfolioID), &metrics); err != nil {
+                if errors.Is(err, redis.Nil) {
+                        return nil, nil
+                }
+                return nil, fmt.Errorf("failed to load performance metrics for %s: %w", portfolioID, err)
+        }
+
+        return &metrics, nil
+}
+
+// SaveRiskMetrics implements AnalyticsCacheStore
+func (s *RedisAnalyticsCacheStore) SaveRiskMetrics(ctx context.Context, portfolioID string, metrics *RiskMetrics) error {
+        return s.redis.Set(ctx, s.riskKey(portfolioID), metrics, s.expiration)
+}
+
+// LoadRiskMetrics implements AnalyticsCacheStore
+func (s *RedisAnalyticsCacheStore) LoadRiskMetrics(ctx context.Context, portfolioID string) (*RiskMetrics, error) {
+        var metrics RiskMetrics
+        if err := s.redis.Get(ctx, s.riskKey(portfolioID), &metrics); err != nil {
+                if errors.Is(err, redis.Nil) {
+                        return nil, nil
+                }
+                return nil, fmt.Errorf("failed to load risk metrics for %s: %w", portfolioID, err)
+        }
+
+        return &metrics, nil
+}
+
+// DeleteMetrics implements AnalyticsCacheStore
+func (s *RedisAnalyticsCacheStore) DeleteMetrics(ctx context.Context, portfolioID string) error {
+        if err := s.redis.Delete(ctx, s.performanceKey(portfolioID)); err != nil {
+                return err
+        }
+        return s.redis.Delete(ctx, s.riskKey(portfolioID))
+}
+
+// SetCacheStore makes the engine persist the metrics it calculates to store and fall
+// back to it on cache misses. A nil store keeps metrics in memory only.
+func (e *PortfolioAnalyticsEngine) SetCacheStore(store AnalyticsCacheStore) {
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        e.cacheStore = store
+}
+
+// WarmCache loads the persisted metrics of every portfolio in the engine, typically
+// once portfolios have been loaded at startup, and returns how many were loaded
+func (e *PortfolioAnalyticsEngine) WarmCache(ctx context.Context) (int, error) {
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        if e.cacheStore == nil {
+                return 0, nil
+        }
+
+        loaded := 0
+        for portfolioID, portfolio := range e.portfolios {
+                performance, err := e.cacheStore.LoadPerformanceMetrics(ctx, portfolioID)
+                if err != nil {
+                        return loaded, err
+                }
+                if performance != nil {
+                        e.performanceCache[portfolioID] = performance
+                        portfolio.PerformanceCache = performance
+                        loaded++
+                }
+
+                risk, err := e.cacheStore.LoadRiskMetrics(ctx, portfolioID)
+                if err != nil {
+                        return loaded, err
+                }
+                if risk != nil {
+                        e.riskCache[portfolioID] = risk
+                        portfolio.RiskCache = risk
+                        loaded++
+                }
+        }
+
+        return loaded, nil
+}
+
+// AddInvalidationHook registers a hook called with a portfolio's ID whenever its
+// cached metrics are invalidated. Hooks run with the engine locked and must not
+// call back into it.
+func (e *PortfolioAnalyticsEngine) AddInvalidationHook(hook func(portfolioID string)) {
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        e.cacheHooks = append(e.cacheHooks, hook)
+}
+
+// InvalidateCache discards a portfolio's cached and persisted metrics
+func (e *PortfolioAnalyticsEngine) InvalidateCache(portfolioID string) error {
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        return e.invalidateCache(portfolioID)
+}
+
+// invalidateCache discards a portfolio's persisted and cached metrics and runs the
+// invalidation hooks. The persisted metrics go first, so that nothing changes when
+// they cannot be deleted. The engine's lock must be held.
+func (e *PortfolioAnalyticsEngine) invalidateCache(portfolioID string) error {
+        if e.cacheStore != nil {
+                if err := e.cacheStore.DeleteMetrics(context.Background(), portfolioID); err != nil {
+                        return fmt.Errorf("failed to delete persisted metrics for %s: %w", portfolioID, err)
+                }
+        }
+
+        delete(e.performanceCache, portfolioID)
+        delete(e.riskCache, portfolioID)
+        delete(e.attributionCache, portfolioID)
+
+        for _, hook := range e.cacheHooks {
+                hook(portfolioID)
+        }
+
+        return nil
+}
+
+// persistedPerformanceMetrics returns a portfolio's persisted performance metrics
+// when they are still fresh
+func (e *PortfolioAnalyticsEngine) persistedPerformanceMetrics(portfolioID string) *PerformanceMetrics {
+        if e.cacheStore == nil {
+                return nil
+        }
+
+        metrics, err := e.cacheStore.LoadPerformanceMetrics(context.Background(), portfolioID)
+        if err != nil || metrics == nil || !metrics.UpdatedAt.After(time.Now().Add(-1*time.Hour)) {
+                return nil
+        }
+
+        return metrics
+}
+
+// persistedRiskMetrics returns a portfolio's persisted risk metrics when they are
+// still fresh
+func (e *PortfolioAnalyticsEngine) persistedRiskMetrics(portfolioID string) *RiskMetrics {
+        if e.cacheStore == nil {
+                return nil
+        }
+
+        metrics, err := e.cacheStore.LoadRiskMetrics(context.Background(), portfolioID)
+        if err != nil || metrics == nil || !metrics.UpdatedAt.After(time.Now().Add(-1*time.Hour)) {
+                return nil
+        }
+
+        return metrics
+}
@@ -0,0 +1,97 @@
+package portfolioanalytics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingCacheStore stores nothing and fails to delete when deleteErr is set
+type failingCacheStore struct {
+	deleteErr error
+}
+
+func (s *failingCacheStore) SavePerformanceMetrics(ctx context.Context, portfolioID string, metrics *PerformanceMetrics) error {
+	return nil
+}
+
+func (s *failingCacheStore) LoadPerformanceMetrics(ctx context.Context, portfolioID string) (*PerformanceMetrics, error) {
+	return nil, nil
+}
+
+func (s *failingCacheStore) SaveRiskMetrics(ctx context.Context, portfolioID string, metrics *RiskMetrics) error {
+	return nil
+}
+
+func (s *failingCacheStore) LoadRiskMetrics(ctx context.Context, portfolioID string) (*RiskMetrics, error) {
+	return nil, nil
+}
+
+func (s *failingCacheStore) DeleteMetrics(ctx context.Context, portfolioID string) error {
+	return s.deleteErr
+}
+
+func TestInvalidateCacheFailureLeavesStateUnchanged(t *testing.T) {
+	store := &failingCacheStore{}
+	engine := NewPortfolioAnalyticsEngine(&stubDataProvider{prices: map[string]map[time.Time]float64{
+		"A": dailyCloses(100, []float64{0.01, -0.02, 0.015, -0.005}),
+	}}, 1)
+	engine.SetCacheStore(store)
+	require.NoError(t, engine.AddPortfolio(&Portfolio{ID: "portfolio1", Positions: []*Position{
+		{ID: "1", Symbol: "A", Quantity: 10, EntryPrice: 100, CurrentPrice: 110, EntryTime: time.Now().AddDate(0, 0, -5), TransactionType: "BUY"},
+	}}))
+
+	metrics, err := engine.CalculatePerformanceMetrics("portfolio1")
+	require.NoError(t, err)
+
+	invalidated := 0
+	engine.AddInvalidationHook(func(portfolioID string) { invalidated++ })
+	store.deleteErr = errors.New("redis unavailable")
+
+	assert.Error(t, engine.AddPosition("portfolio1", &Position{ID: "2", Symbol: "B", Quantity: 1, TransactionType: "BUY"}))
+	assert.Error(t, engine.UpdatePosition(&Position{ID: "1", PortfolioID: "portfolio1", Symbol: "A", Quantity: 20, TransactionType: "BUY"}))
+	assert.Error(t, engine.DeletePosition("portfolio1", "1"))
+	assert.Error(t, engine.DeletePortfolio("portfolio1"))
+
+	portfolio, err := engine.GetPortfolio("portfolio1")
+	require.NoError(t, err)
+	if assert.Len(t, portfolio.Positions, 1) {
+		assert.Equal(t, 10, portfolio.Positions[0].Quantity)
+	}
+	assert.Zero(t, invalidated)
+
+	cached, err := engine.CalculatePerformanceMetrics("portfolio1")
+	require.NoError(t, err)
+	assert.Same(t, metrics, cached)
+
+	store.deleteErr = nil
+	require.NoError(t, engine.DeletePosition("portfolio1", "1"))
+	assert.Equal(t, 1, invalidated)
+}
+
+func TestConcurrentMetricsCalculation(t *testing.T) {
+	engine := NewPortfolioAnalyticsEngine(&stubDataProvider{prices: map[string]map[time.Time]float64{
+		"A": dailyCloses(100, []float64{0.01, -0.02, 0.015, -0.005}),
+	}}, 1)
+	require.NoError(t, engine.AddPortfolio(&Portfolio{ID: "portfolio1", Positions: []*Position{
+		{ID: "1", Symbol: "A", Quantity: 10, EntryPrice: 100, CurrentPrice: 110, EntryTime: time.Now().AddDate(0, 0, -5), TransactionType: "BUY"},
+	}}))
+
+	// Run under the race detector, concurrent cache writes fail the test
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = engine.CalculatePerformanceMetrics("portfolio1")
+			_, _ = engine.CalculateRiskMetrics("portfolio1")
+			_ = engine.InvalidateCache("portfolio1")
+		}()
+	}
+	wg.Wait()
+}
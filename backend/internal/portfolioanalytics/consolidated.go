@@ -0,0 +1,223 @@
+package portfolioanalytics
+
+import (
+        "fmt"
+        "math"
+        "sort"
+        "time"
+)
+
+const (
+        // EnvironmentLive is the environment of portfolios trading with real money
+        EnvironmentLive = "LIVE"
+
+        // EnvironmentSIM is the environment of simulation and paper trading portfolios
+        EnvironmentSIM = "SIM"
+
+        // GreeksScopeBrokerAccount aggregates the greeks of a user's portfolios at one
+        // broker account. Only consolidated views report it.
+        GreeksScopeBrokerAccount = "BROKER_ACCOUNT"
+)
+
+// unassignedBrokerAccount is the broker account of portfolios not linked to one
+const unassignedBrokerAccount = "UNASSIGNED"
+
+// ConsolidatedSummary is the combined P&L, exposure and greeks of a group of
+// portfolios: a whole view, one broker account or one portfolio
+type ConsolidatedSummary struct {
+        ID            string
+        PortfolioIDs  []string
+        RealizedPnL   float64
+        UnrealizedPnL float64
+        TotalPnL      float64
+        GrossExposure float64
+        NetExposure   float64
+        Greeks        *AggregateGreeks
+}
+
+// ConsolidatedView combines a user's portfolios across broker accounts. Live and
+// simulated portfolios are never combined, each environment has its own view.
+type ConsolidatedView struct {
+        UserID         string
+        Environment    string
+        Total          *ConsolidatedSummary
+        BrokerAccounts []*ConsolidatedSummary // Sorted by ID
+        Portfolios     []*ConsolidatedSummary // Sorted by ID
+        BySymbol       map[string]float64     // Net exposure
+        UpdatedAt      time.Time
+}
+
+// GetConsolidatedView returns the combined view of a user's portfolios in an
+// environment, LIVE when empty
+func (e *PortfolioAnalyticsEngine) GetConsolidatedView(userID, environment string) (*ConsolidatedView, error) {
+        environment, err := normalizeEnvironment(environment)
+        if err != nil {
+                return nil, err
+        }
+
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        return e.consolidate(userID, environment, func(*Portfolio) bool { return true }), nil
+}
+
+// GetBrokerAccountView drills a user's consolidated view down to one broker account
+func (e *PortfolioAnalyticsEngine) GetBrokerAccountView(userID, environment, brokerAccount string) (*ConsolidatedView, error) {
+        environment, err := normalizeEnvironment(environment)
+        if err != nil {
+                return nil, err
+        }
+
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        view := e.consolidate(userID, environment, func(portfolio *Portfolio) bool {
+                return brokerAccountOf(portfolio) == brokerAccount
+        })
+        if len(view.Portfolios) == 0 {
+                return nil, fmt.Errorf("broker account with ID %s not found", brokerAccount)
+        }
+
+        return view, nil
+}
+
+// GetConsolidatedPortfolio drills a user's consolidated view down to one portfolio
+func (e *PortfolioAnalyticsEngine) GetConsolidatedPortfolio(userID, portfolioID string) (*ConsolidatedView, error) {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        portfolio, exists := e.portfolios[portfolioID]
+        if !exists || portfolio.UserID != userID {
+                return nil, fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+
+        environment, err := normalizeEnvironment(portfolio.Environment)
+        if err != nil {
+                return nil, err
+        }
+
+        return e.consolidate(userID, environment, func(candidate *Portfolio) bool {
+                return candidate.ID == portfolioID
+        }), nil
+}
+
+// consolidate combines the user's portfolios in an environment that include accepts.
+// The engine's lock must be held.
+func (e *PortfolioAnalyticsEngine) consolidate(userID, environment string, include func(*Portfolio) bool) *ConsolidatedView {
+        view := &ConsolidatedView{
+                UserID:      userID,
+                Environment: environment,
+                Total:       newConsolidatedSummary(GreeksScopeAccount, userID),
+                BySymbol:    make(map[string]float64),
+                UpdatedAt:   time.Now(),
+        }
+
+        brokerAccountByPortfolio := make(map[string]string)
+        for portfolioID, portfolio := range e.portfolios {
+                if portfolio.UserID != userID || !include(portfolio) {
+                        continue
+                }
+                if current, err := normalizeEnvironment(portfolio.Environment); err != nil || current != environment {
+                        continue
+                }
+
+                summary := newConsolidatedSummary(GreeksScopePortfolio, portfolioID)
+                for _, position := range e.positions[portfolioID] {
+                        quantity := float64(position.Quantity)
+                        if position.TransactionType == "SELL" {
+                                quantity = -quantity
+                        }
+
+                        if position.ExitTime != nil {
+                                if position.ExitPrice != nil {
+                                        summary.RealizedPnL += quantity * (*position.ExitPrice - position.EntryPrice)
+                                }
+                                continue
+                        }
+
+                        value := quantity * position.CurrentPrice
+                        summary.UnrealizedPnL += quantity * (position.CurrentPrice - position.EntryPrice)
+                        summary.NetExposure += value
+                        summary.GrossExposure += math.Abs(value)
+                        view.BySymbol[position.Symbol] += value
+                }
+                summary.TotalPnL = summary.RealizedPnL + summary.UnrealizedPnL
+                summary.Greeks = e.greeks.portfolioGreeks(portfolioID)
+
+                view.Portfolios = append(view.Portfolios, summary)
+                brokerAccountByPortfolio[portfolioID] = brokerAccountOf(portfolio)
+        }
+
+        sort.Slice(view.Portfolios, func(i, j int) bool {
+                return view.Portfolios[i].ID < view.Portfolios[j].ID
+        })
+
+        // Roll portfolios up in order so every summary lists its portfolios sorted
+        brokerAccounts := make(map[string]*ConsolidatedSummary)
+        for _, summary := range view.Portfolios {
+                brokerAccount := brokerAccountByPortfolio[summary.ID]
+                if _, exists := brokerAccounts[brokerAccount]; !exists {
+                        brokerAccounts[brokerAccount] = newConsolidatedSummary(GreeksScopeBrokerAccount, brokerAccount)
+                        view.BrokerAccounts = append(view.BrokerAccounts, brokerAccounts[brokerAccount])
+                }
+                brokerAccounts[brokerAccount].add(summary)
+                view.Total.add(summary)
+        }
+
+        sort.Slice(view.BrokerAccounts, func(i, j int) bool {
+                return view.BrokerAccounts[i].ID < view.BrokerAccounts[j].ID
+        })
+
+        return view
+}
+
+// newConsolidatedSummary creates an empty summary with greeks of the given scope
+func newConsolidatedSummary(scope, id string) *ConsolidatedSummary {
+        return &ConsolidatedSummary{
+                ID: id,
+                Greeks: &AggregateGreeks{
+                        Scope:         scope,
+                        ID:            id,
+                        DeltaBySymbol: make(map[string]float64),
+                        UpdatedAt:     time.Now(),
+                },
+        }
+}
+
+// add combines a portfolio's summary into s
+func (s *ConsolidatedSummary) add(portfolio *ConsolidatedSummary) {
+        s.PortfolioIDs = append(s.PortfolioIDs, portfolio.ID)
+        s.RealizedPnL += portfolio.RealizedPnL
+        s.UnrealizedPnL += portfolio.UnrealizedPnL
+        s.TotalPnL += portfolio.TotalPnL
+        s.GrossExposure += portfolio.GrossExposure
+        s.NetExposure += portfolio.NetExposure
+        s.Greeks.Delta += portfolio.Greeks.Delta
+        s.Greeks.Gamma += portfolio.Greeks.Gamma
+        s.Greeks.Theta += portfolio.Greeks.Theta
+        s.Greeks.Vega += portfolio.Greeks.Vega
+        for symbol, delta := range portfolio.Greeks.DeltaBySymbol {
+                s.Greeks.DeltaBySymbol[symbol] += delta
+        }
+}
+
+// brokerAccountOf returns the broker account a portfolio trades through
+func brokerAccountOf(portfolio *Portfolio) string {
+        if portfolio.BrokerAccount == "" {
+                return unassignedBrokerAccount
+        }
+
+        return portfolio.BrokerAccount
+}
+
+// normalizeEnvironment validates an environment, defaulting to LIVE when empty
+func normalizeEnvironment(environment string) (string, error) {
+        switch environment {
+        case "":
+                return EnvironmentLive, nil
+        case EnvironmentLive, EnvironmentSIM:
+                return environment, nil
+        default:
+                return "", fmt.Errorf("unknown environment: %s", environment)
+        }
+}
@@ -0,0 +1,200 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "fmt"
+        "math"
+        "sort"
+        "time"
+)
+
+// CorrelationAnalysis describes how the open positions of a portfolio move together
+type CorrelationAnalysis struct {
+        PortfolioID          string
+        Symbols              []string
+        CorrelationMatrix    map[string]map[string]float64
+        AverageCorrelation   float64    // Mean pairwise correlation
+        DiversificationRatio float64    // Sum of the volatilities of the exposures over the portfolio volatility
+        Clusters             [][]string // Symbols whose returns move together
+        Observations         int        // Daily returns the analysis is based on
+        UpdatedAt            time.Time
+}
+
+// GetCorrelationAnalysis computes the correlations, diversification ratio and
+// clusters of a portfolio's open positions from their daily returns
+func (e *PortfolioAnalyticsEngine) GetCorrelationAnalysis(portfolioID string) (*CorrelationAnalysis, error) {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        if _, exists := e.portfolios[portfolioID]; !exists {
+                return nil, fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+
+        analysis, err := e.calculateCorrelations(e.positions[portfolioID])
+        if err != nil {
+                return nil, err
+        }
+
+        analysis.PortfolioID = portfolioID
+        return analysis, nil
+}
+
+// calculateCorrelations analyses the open positions over the lookback period of the
+// risk configuration. Symbols are listed in alphabetical order.
+func (e *PortfolioAnalyticsEngine) calculateCorrelations(positions []*Position) (*CorrelationAnalysis, error) {
+        exposures, exchanges := openExposures(positions)
+
+        analysis := &CorrelationAnalysis{
+                Symbols:           make([]string, 0, len(exposures)),
+                CorrelationMatrix: make(map[string]map[string]float64),
+                UpdatedAt:         time.Now(),
+        }
+
+        for symbol := range exposures {
+                analysis.Symbols = append(analysis.Symbols, symbol)
+        }
+        sort.Strings(analysis.Symbols)
+
+        if len(exposures) == 0 || e.dataProvider == nil {
+                return analysis, nil
+        }
+
+        endDate := time.Now()
+        startDate := endDate.AddDate(0, 0, -e.riskConfig.LookbackDays)
+
+        dates, returns, err := e.historicalReturns(context.Background(), exchanges, startDate, endDate)
+        if err != nil {
+                return nil, err
+        }
+
+        // Too little history to estimate anything
+        analysis.Observations = len(dates)
+        if len(dates) < 2 {
+                return analysis, nil
+        }
+
+        symbols := analysis.Symbols
+        covariance := make([][]float64, len(symbols))
+        for i, a := range symbols {
+                covariance[i] = make([]float64, len(symbols))
+                for j, b := range symbols {
+                        covariance[i][j] = sampleCovariance(returns[a], returns[b])
+                }
+        }
+
+        correlations := make([][]float64, len(symbols))
+        for i, a := range symbols {
+                correlations[i] = make([]float64, len(symbols))
+                analysis.CorrelationMatrix[a] = make(map[string]float64, len(symbols))
+
+                for j, b := range symbols {
+                        correlation := 0.0
+                        if i == j {
+                                correlation = 1
+                        } else if deviation := math.Sqrt(covariance[i][i] * covariance[j][j]); deviation > 0 {
+                                correlation = covariance[i][j] / deviation
+                        }
+
+                        correlations[i][j] = correlation
+                        analysis.CorrelationMatrix[a][b] = correlation
+                }
+        }
+
+        pairs := 0
+        for i := range symbols {
+                for j := i + 1; j < len(symbols); j++ {
+                        analysis.AverageCorrelation += correlations[i][j]
+                        pairs++
+                }
+        }
+        if pairs > 0 {
+                analysis.AverageCorrelation /= float64(pairs)
+        }
+
+        // The ratio is 1 for a single position and grows as positions offset each other
+        var standalone, variance float64
+        for i, a := range symbols {
+                standalone += math.Abs(exposures[a]) * math.Sqrt(covariance[i][i])
+                for j, b := range symbols {
+                        variance += exposures[a] * exposures[b] * covariance[i][j]
+                }
+        }
+        if variance > 0 {
+                analysis.DiversificationRatio = standalone / math.Sqrt(variance)
+        }
+
+        analysis.Clusters = correlationClusters(symbols, correlations, e.riskConfig.ClusterCorrelation)
+
+        return analysis, nil
+}
+
+// correlationClusters groups symbols by average-linkage hierarchical clustering,
+// merging the two most correlated clusters until no two clusters have an average
+// correlation of at least threshold. Clusters are ordered by their first symbol.
+func correlationClusters(symbols []string, correlations [][]float64, threshold float64) [][]string {
+        clusters := make([][]int, len(symbols))
+        for i := range symbols {
+                clusters[i] = []int{i}
+        }
+
+        for len(clusters) > 1 {
+                bestA, bestB, best := -1, -1, threshold
+                for a := range clusters {
+                        for b := a + 1; b < len(clusters); b++ {
+                                linkage := 0.0
+                                for _, i := range clusters[a] {
+                                        for _, j := range clusters[b] {
+                                                linkage += correlations[i][j]
+                                        }
+                                }
+                                linkage /= float64(len(clusters[a]) * len(clusters[b]))
+
+                                if linkage >= best && (bestA == -1 || linkage > best) {
+                                        bestA, bestB, best = a, b, linkage
+                                }
+                        }
+                }
+
+                if bestA == -1 {
+                        break
+                }
+
+                clusters[bestA] = append(clusters[bestA], clusters[bestB]...)
+                clusters = append(clusters[:bestB], clusters[bestB+1:]...)
+        }
+
+        grouped := make([][]string, len(clusters))
+        for i, cluster := range clusters {
+                sort.Ints(cluster)
+                for _, index := range cluster {
+                        grouped[i] = append(grouped[i], symbols[index])
+                }
+        }
+        sort.Slice(grouped, func(i, j int) bool {
+                return grouped[i][0] < grouped[j][0]
+        })
+
+        return grouped
+}
+
+// sampleCovariance returns the sample covariance of two equally long series
+func sampleCovariance(a, b []float64) float64 {
+        if len(a) < 2 || len(a) != len(b) {
+                return 0
+        }
+
+        var meanA, meanB float64
+        for i := range a {
+                meanA += a[i]
+                meanB += b[i]
+        }
+        meanA /= float64(len(a))
+        meanB /= float64(len(b))
+
+        covariance := 0.0
+        for i := range a {
+                covariance += (a[i] - meanA) * (b[i] - meanB)
+        }
+
+        return covariance / float64(len(a)-1)
+}
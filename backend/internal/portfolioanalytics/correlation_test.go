@@ -0,0 +1,165 @@
+package portfolioanalytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubDataProvider serves fixed daily closes by symbol
+type stubDataProvider struct {
+	prices map[string]map[time.Time]float64
+}
+
+func (p *stubDataProvider) GetCurrentPrice(ctx context.Context, symbol string, exchange string) (float64, error) {
+	return 0, errors.New("no current price")
+}
+
+func (p *stubDataProvider) GetHistoricalPrices(ctx context.Context, symbol string, exchange string, startDate time.Time, endDate time.Time, interval string) (map[time.Time]float64, error) {
+	closes, exists := p.prices[symbol]
+	if !exists {
+		return nil, errors.New("no history for " + symbol)
+	}
+	prices := make(map[time.Time]float64)
+	for date, price := range closes {
+		if !date.Before(startDate) && !date.After(endDate) {
+			prices[date] = price
+		}
+	}
+	return prices, nil
+}
+
+func (p *stubDataProvider) GetOptionChain(ctx context.Context, symbol string, exchange string, expiryDate time.Time) ([]*OptionData, error) {
+	return nil, nil
+}
+
+func (p *stubDataProvider) GetGreeks(ctx context.Context, symbol string, exchange string, strikePrice float64, expiryDate time.Time, optionType string) (*Greeks, error) {
+	return nil, nil
+}
+
+func (p *stubDataProvider) GetMarketIndices(ctx context.Context) (map[string]float64, error) {
+	return nil, nil
+}
+
+func (p *stubDataProvider) GetVolatilityIndex(ctx context.Context, symbol string) (float64, error) {
+	return 0, nil
+}
+
+// dailyCloses returns closes over the days up to yesterday starting from a price
+// and compounding the daily returns
+func dailyCloses(start float64, returns []float64) map[time.Time]float64 {
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -len(returns)-1)
+	closes := map[time.Time]float64{day: start}
+	for i, dailyReturn := range returns {
+		start *= 1 + dailyReturn
+		closes[day.AddDate(0, 0, i+1)] = start
+	}
+	return closes
+}
+
+func TestSampleCovariance(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b       []float64
+		covariance float64
+	}{
+		{"Same series", []float64{1, 2, 3}, []float64{1, 2, 3}, 1},
+		{"Opposite series", []float64{1, 2, 3}, []float64{3, 2, 1}, -1},
+		{"Scaled series", []float64{1, 2, 3, 4}, []float64{2, 4, 6, 8}, 10.0 / 3},
+		{"Constant series", []float64{1, 2, 3}, []float64{5, 5, 5}, 0},
+		{"Single observation", []float64{1}, []float64{1}, 0},
+		{"Unequal lengths", []float64{1, 2, 3}, []float64{1, 2}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.InDelta(t, test.covariance, sampleCovariance(test.a, test.b), 1e-12)
+		})
+	}
+}
+
+func TestCorrelationClusters(t *testing.T) {
+	symbols := []string{"A", "B", "C"}
+
+	tests := []struct {
+		name         string
+		correlations [][]float64
+		threshold    float64
+		clusters     [][]string
+	}{
+		{
+			name:         "One correlated pair",
+			correlations: [][]float64{{1, 0.9, 0.1}, {0.9, 1, 0.2}, {0.1, 0.2, 1}},
+			threshold:    0.7,
+			clusters:     [][]string{{"A", "B"}, {"C"}},
+		},
+		{
+			// The pair links to C by the average of 0.1 and 0.2
+			name:         "Low threshold",
+			correlations: [][]float64{{1, 0.9, 0.1}, {0.9, 1, 0.2}, {0.1, 0.2, 1}},
+			threshold:    0.15,
+			clusters:     [][]string{{"A", "B", "C"}},
+		},
+		{
+			name:         "High threshold",
+			correlations: [][]float64{{1, 0.9, 0.1}, {0.9, 1, 0.2}, {0.1, 0.2, 1}},
+			threshold:    0.95,
+			clusters:     [][]string{{"A"}, {"B"}, {"C"}},
+		},
+		{
+			name:         "Ordered by first symbol",
+			correlations: [][]float64{{1, 0.1, 0.2}, {0.1, 1, 0.8}, {0.2, 0.8, 1}},
+			threshold:    0.7,
+			clusters:     [][]string{{"A"}, {"B", "C"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.clusters, correlationClusters(symbols, test.correlations, test.threshold))
+		})
+	}
+}
+
+func TestCorrelationAnalysis(t *testing.T) {
+	returns := []float64{0.01, -0.02, 0.015, -0.005, 0.03, -0.01}
+	doubled := make([]float64, len(returns))
+	inverse := make([]float64, len(returns))
+	for i, dailyReturn := range returns {
+		doubled[i] = 2 * dailyReturn
+		inverse[i] = -dailyReturn
+	}
+
+	// B moves twice as much as A and C the opposite way, each with 1000 of exposure
+	engine := NewPortfolioAnalyticsEngine(&stubDataProvider{prices: map[string]map[time.Time]float64{
+		"A": dailyCloses(100, returns),
+		"B": dailyCloses(50, doubled),
+		"C": dailyCloses(200, inverse),
+	}}, 1)
+	require.NoError(t, engine.AddPortfolio(&Portfolio{ID: "portfolio1", Positions: []*Position{
+		{ID: "1", Symbol: "A", Quantity: 10, CurrentPrice: 100, TransactionType: "BUY"},
+		{ID: "2", Symbol: "B", Quantity: 20, CurrentPrice: 50, TransactionType: "BUY"},
+		{ID: "3", Symbol: "C", Quantity: 5, CurrentPrice: 200, TransactionType: "BUY"},
+	}}))
+
+	analysis, err := engine.GetCorrelationAnalysis("portfolio1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A", "B", "C"}, analysis.Symbols)
+	assert.Equal(t, len(returns), analysis.Observations)
+	assert.InDelta(t, 1, analysis.CorrelationMatrix["A"]["B"], 1e-9)
+	assert.InDelta(t, -1, analysis.CorrelationMatrix["A"]["C"], 1e-9)
+	assert.InDelta(t, -1, analysis.CorrelationMatrix["C"]["B"], 1e-9)
+	assert.InDelta(t, -1.0/3, analysis.AverageCorrelation, 1e-9)
+
+	// Volatilities of 1, 2 and 1 add up to 4 on their own, but C offsets half of
+	// the other two together
+	assert.InDelta(t, 2, analysis.DiversificationRatio, 1e-6)
+	assert.Equal(t, [][]string{{"A", "B"}, {"C"}}, analysis.Clusters)
+
+	_, err = engine.GetCorrelationAnalysis("missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,240 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "math"
+        "time"
+)
+
+// DrawdownPoint is the portfolio's equity and drawdown at the close of a day
+type DrawdownPoint struct {
+        Date     time.Time
+        Equity   float64 // Capital plus cumulative P&L
+        Drawdown float64 // Percent below the running peak, zero or negative
+}
+
+// DrawdownAnalysis describes the falls of a portfolio's equity from its peaks
+type DrawdownAnalysis struct {
+        PortfolioID         string
+        MaxDrawdown         float64    // Largest fall from a peak, in percent
+        PeakDate            time.Time  // Peak the maximum drawdown fell from
+        TroughDate          time.Time  // Bottom of the maximum drawdown
+        RecoveryDate        *time.Time // When equity regained the peak, nil while under water
+        RecoveryDays        int        // Days from the trough to the recovery, -1 while under water
+        LongestDrawdownDays int        // Longest time spent below a previous peak
+        CurrentDrawdown     float64
+        Underwater          []DrawdownPoint
+}
+
+// equityPoint is the cumulative P&L of a portfolio at the close of a day
+type equityPoint struct {
+        date time.Time
+        pnl  float64
+}
+
+// GetDrawdownAnalysis returns the drawdowns and underwater curve of a portfolio
+// over the trading days from startDate up to endDate
+func (e *PortfolioAnalyticsEngine) GetDrawdownAnalysis(portfolioID string, startDate, endDate time.Time) (*DrawdownAnalysis, error) {
+        if !endDate.After(startDate) {
+                return nil, errors.New("end date must be after start date")
+        }
+
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        if _, exists := e.portfolios[portfolioID]; !exists {
+                return nil, fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+
+        positions := e.positions[portfolioID]
+        curve, err := e.equityCurve(context.Background(), positions, startDate, endDate)
+        if err != nil {
+                return nil, err
+        }
+
+        analysis := analyzeDrawdowns(curve, capitalOf(positions))
+        analysis.PortfolioID = portfolioID
+
+        return analysis, nil
+}
+
+// equityCurve returns the cumulative P&L of positions at the close of each weekday
+// from startDate up to endDate, valued as in pnlBetween
+func (e *PortfolioAnalyticsEngine) equityCurve(ctx context.Context, positions []*Position, startDate, endDate time.Time) ([]equityPoint, error) {
+        history := &priceHistory{
+                startDate: startDate.AddDate(0, 0, -7), // Covers holidays just before the range
+                endDate:   endDate,
+                prices:    make(map[string][]datedPrice),
+        }
+
+        var curve []equityPoint
+        for day := startDate.UTC().Truncate(24 * time.Hour); day.Before(endDate); day = day.AddDate(0, 0, 1) {
+                if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+                        continue
+                }
+
+                // Positions are valued at the end of the day
+                dayEnd := day.AddDate(0, 0, 1)
+                if dayEnd.After(endDate) {
+                        dayEnd = endDate
+                }
+
+                pnl := 0.0
+                for _, position := range positions {
+                        positionPnL, err := e.pnlBetween(ctx, position, time.Time{}, dayEnd, history)
+                        if errors.Is(err, errNoOptionHistory) {
+                                // Options are only valued at their entry and exit and today
+                                continue
+                        }
+                        if err != nil {
+                                return nil, err
+                        }
+                        pnl += positionPnL
+                }
+
+                curve = append(curve, equityPoint{date: day, pnl: pnl})
+        }
+
+        return curve, nil
+}
+
+// capitalOf returns the capital committed to positions at their entry prices
+func capitalOf(positions []*Position) float64 {
+        capital := 0.0
+        for _, position := range positions {
+                capital += math.Abs(float64(position.Quantity) * position.EntryPrice)
+        }
+        return capital
+}
+
+// analyzeDrawdowns measures the drawdowns of capital plus the cumulative P&L of curve
+func analyzeDrawdowns(curve []equityPoint, capital float64) *DrawdownAnalysis {
+        analysis := &DrawdownAnalysis{
+                RecoveryDays: -1,
+                Underwater:   make([]DrawdownPoint, 0, len(curve)),
+        }
+        if len(curve) == 0 || capital <= 0 {
+                analysis.RecoveryDays = 0
+                return analysis
+        }
+
+        peak := capital + curve[0].pnl
+        peakDate := curve[0].date
+        unrecoveredPeak := 0.0 // Peak of the maximum drawdown until equity regains it
+
+        for _, point := range curve {
+                equity := capital + point.pnl
+                if equity >= peak {
+                        peak, peakDate = equity, point.date
+                } else if days := daysBetween(peakDate, point.date); days > analysis.LongestDrawdownDays {
+                        analysis.LongestDrawdownDays = days
+                }
+
+                if unrecoveredPeak > 0 && equity >= unrecoveredPeak {
+                        recoveryDate := point.date
+                        analysis.RecoveryDate = &recoveryDate
+                        analysis.RecoveryDays = daysBetween(analysis.TroughDate, recoveryDate)
+                        unrecoveredPeak = 0
+                }
+
+                drawdown := 0.0
+                if peak > 0 {
+                        drawdown = (equity - peak) / peak * 100
+                }
+
+                if drawdown < -analysis.MaxDrawdown {
+                        analysis.MaxDrawdown = -drawdown
+                        analysis.PeakDate = peakDate
+                        analysis.TroughDate = point.date
+                        analysis.RecoveryDate = nil
+                        analysis.RecoveryDays = -1
+                        unrecoveredPeak = peak
+                }
+
+                analysis.Underwater = append(analysis.Underwater, DrawdownPoint{
+                        Date:     point.date,
+                        Equity:   equity,
+                        Drawdown: drawdown,
+                })
+        }
+
+        analysis.CurrentDrawdown = -analysis.Underwater[len(analysis.Underwater)-1].Drawdown
+        if analysis.MaxDrawdown == 0 {
+                analysis.RecoveryDays = 0
+        }
+
+        return analysis
+}
+
+// daysBetween returns the whole days from one date to a later one
+func daysBetween(from, to time.Time) int {
+        return int(to.Sub(from).Hours() / 24)
+}
+
+// performanceHistory is the daily P&L history of a portfolio since its first entry
+type performanceHistory struct {
+        dailyPnL      map[string]float64 // By date, YYYY-MM-DD
+        cumulativePnL map[string]float64
+        drawdowns     *DrawdownAnalysis
+
+        cagr          float64 // In percent
+        volatility    float64 // Annualized, in percent
+        sharpeRatio   float64
+        sortinoRatio  float64
+        rolling       map[string]float64            // Latest rolling metrics
+        rollingSeries map[string]map[string]float64 // Rolling metrics by date
+        benchmark     *BenchmarkComparison
+}
+
+// calculatePerformanceHistory values positions at the close of every weekday from
+// the earliest entry up to and including today, comparing them with benchmark
+// unless it is empty
+func (e *PortfolioAnalyticsEngine) calculatePerformanceHistory(positions []*Position, benchmark string) (*performanceHistory, error) {
+        history := &performanceHistory{
+                dailyPnL:      make(map[string]float64),
+                cumulativePnL: make(map[string]float64),
+        }
+
+        var startDate time.Time
+        for _, position := range positions {
+                if startDate.IsZero() || position.EntryTime.Before(startDate) {
+                        startDate = position.EntryTime
+                }
+        }
+        endDate := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+
+        curve, err := e.equityCurve(context.Background(), positions, startDate, endDate)
+        if err != nil {
+                return nil, err
+        }
+
+        previous := 0.0
+        for _, point := range curve {
+                date := point.date.Format("2006-01-02")
+                history.dailyPnL[date] = point.pnl - previous
+                history.cumulativePnL[date] = point.pnl
+                previous = point.pnl
+        }
+
+        capital := capitalOf(positions)
+        history.drawdowns = analyzeDrawdowns(curve, capital)
+
+        if len(curve) > 1 {
+                // The first return is always zero
+                returns := dailyReturns(curve, capital)[1:]
+                history.cagr = compoundAnnualGrowth(returns, curve[0].date, curve[len(curve)-1].date)
+                history.volatility, history.sharpeRatio, history.sortinoRatio = returnStatistics(returns, e.riskConfig.RiskFreeRate)
+        }
+        history.rolling, history.rollingSeries = rollingPerformance(curve, capital, e.riskConfig.RiskFreeRate)
+
+        if benchmark != "" {
+                history.benchmark, err = e.compareToBenchmark(context.Background(), benchmark, curve, capital)
+                if err != nil {
+                        return nil, err
+                }
+        }
+
+        return history, nil
+}
@@ -0,0 +1,116 @@
+package portfolioanalytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeDrawdowns(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC) }
+	curve := func(pnl ...float64) []equityPoint {
+		points := make([]equityPoint, len(pnl))
+		for i, value := range pnl {
+			points[i] = equityPoint{date: day(i), pnl: value}
+		}
+		return points
+	}
+
+	tests := []struct {
+		name                string
+		curve               []equityPoint
+		capital             float64
+		maxDrawdown         float64
+		peak                time.Time
+		trough              time.Time
+		recovery            *time.Time
+		recoveryDays        int
+		longestDrawdownDays int
+		currentDrawdown     float64
+	}{
+		{
+			// Equity peaks at 1100 on day 1, bottoms at 900 on day 3 and makes a
+			// new peak of 1120 on day 5
+			name:                "Recovered",
+			curve:               curve(0, 100, 50, -100, 50, 120, 110),
+			capital:             1000,
+			maxDrawdown:         200.0 / 1100 * 100,
+			peak:                day(1),
+			trough:              day(3),
+			recovery:            timePointer(day(5)),
+			recoveryDays:        2,
+			longestDrawdownDays: 3,
+			currentDrawdown:     10.0 / 1120 * 100,
+		},
+		{
+			name:                "Still under water",
+			curve:               curve(0, 100, 50, -100, 50),
+			capital:             1000,
+			maxDrawdown:         200.0 / 1100 * 100,
+			peak:                day(1),
+			trough:              day(3),
+			recoveryDays:        -1,
+			longestDrawdownDays: 3,
+			currentDrawdown:     50.0 / 1100 * 100,
+		},
+		{
+			// A deeper drawdown replaces a recovered one
+			name:                "Deeper after recovery",
+			curve:               curve(0, -100, 0, 50, -250),
+			capital:             1000,
+			maxDrawdown:         300.0 / 1050 * 100,
+			peak:                day(3),
+			trough:              day(4),
+			recoveryDays:        -1,
+			longestDrawdownDays: 1,
+			currentDrawdown:     300.0 / 1050 * 100,
+		},
+		{
+			name:         "Only rising",
+			curve:        curve(0, 10, 20),
+			capital:      1000,
+			recoveryDays: 0,
+		},
+		{
+			name:         "No history",
+			capital:      1000,
+			recoveryDays: 0,
+		},
+		{
+			name:         "No capital",
+			curve:        curve(0, -10),
+			recoveryDays: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			analysis := analyzeDrawdowns(test.curve, test.capital)
+			assert.InDelta(t, test.maxDrawdown, analysis.MaxDrawdown, 1e-9)
+			assert.Equal(t, test.peak, analysis.PeakDate)
+			assert.Equal(t, test.trough, analysis.TroughDate)
+			assert.Equal(t, test.recovery, analysis.RecoveryDate)
+			assert.Equal(t, test.recoveryDays, analysis.RecoveryDays)
+			assert.Equal(t, test.longestDrawdownDays, analysis.LongestDrawdownDays)
+			assert.InDelta(t, test.currentDrawdown, analysis.CurrentDrawdown, 1e-9)
+		})
+	}
+}
+
+func TestAnalyzeDrawdownsUnderwaterCurve(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	curve := []equityPoint{{start, 0}, {start.AddDate(0, 0, 1), -100}, {start.AddDate(0, 0, 2), 100}}
+
+	analysis := analyzeDrawdowns(curve, 1000)
+	if assert.Len(t, analysis.Underwater, 3) {
+		assert.Equal(t, DrawdownPoint{Date: start, Equity: 1000, Drawdown: 0}, analysis.Underwater[0])
+		assert.Equal(t, 900.0, analysis.Underwater[1].Equity)
+		assert.InDelta(t, -10, analysis.Underwater[1].Drawdown, 1e-9)
+		assert.Equal(t, 0.0, analysis.Underwater[2].Drawdown)
+	}
+}
+
+func timePointer(t time.Time) *time.Time {
+	return &t
+}
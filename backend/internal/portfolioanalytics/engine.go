@@ -7,6 +7,9 @@ import (
         "math"
         "sync"
         "time"
+
+        "trading-platform/backend/internal/errorreporting"
+        "trading-platform/backend/internal/metrics"
 )
 
 // PortfolioAnalyticsEngine is the main engine for portfolio analytics
@@ -21,6 +24,19 @@ type PortfolioAnalyticsEngine struct {
         workers          int
         isRunning        bool
         stopChan         chan struct{}
+        riskConfig       RiskConfig
+        referenceData    *ReferenceDataService
+        attributionCache map[string]map[string]*PerformanceAttribution
+        greeks           *GreeksAggregator
+        cacheStore       AnalyticsCacheStore
+        cacheHooks       []func(portfolioID string)
+        riskLimits       map[string]RiskLimits
+        lotMethods       map[string]string // Lot matching method by account
+        exposureLimits   map[string]ExposureLimits
+        alerts           *limitAlerts
+        equityCurves     map[string][]*EquitySnapshot // Daily snapshots by portfolio, oldest first
+        equityStore      EquitySnapshotStore
+        metricsListeners []func(*MetricsUpdate)
 }
 
 // Portfolio represents a collection of positions
@@ -34,6 +50,9 @@ type Portfolio struct {
         UpdatedAt        time.Time
         StrategyID       string
         UserID           string
+        BrokerAccount    string // Broker account the portfolio trades through
+        Environment      string // "LIVE" or "SIM", LIVE when empty
+        Benchmark        string // Index performance is compared against, none when empty
         PerformanceCache *PerformanceMetrics
         RiskCache        *RiskMetrics
 }
@@ -80,7 +99,9 @@ type PerformanceMetrics struct {
         Volatility          float64
         SharpeRatio         float64
         SortinoRatio        float64
-        MaxDrawdown         float64
+        MaxDrawdown         float64 // Largest fall of equity from a peak, in percent
+        DrawdownDays        int     // Longest time spent below a previous peak
+        RecoveryDays        int     // Days from the deepest trough back to its peak, -1 while under water
         WinRate             float64
         ProfitFactor        float64
         AverageWin          float64
@@ -89,30 +110,40 @@ type PerformanceMetrics struct {
         ReturnOnCapital     float64
         DailyPnL            map[string]float64
         CumulativePnL       map[string]float64
-        RollingPerformance  map[string]float64
+        UnderwaterCurve     map[string]float64            // Drawdown in percent by date
+        RollingPerformance  map[string]float64            // Latest 30 and 90 day Sharpe, Sortino and volatility
+        RollingSeries       map[string]map[string]float64 // RollingPerformance metrics by date
         PerformanceBySymbol map[string]float64
+        Benchmark           *BenchmarkComparison // Nil when the portfolio has no benchmark
         UpdatedAt           time.Time
 }
 
 // RiskMetrics represents risk metrics for a portfolio
 type RiskMetrics struct {
-        ValueAtRisk         float64
-        ConditionalVaR      float64
-        BetaToMarket        float64
-        PortfolioVolatility float64
-        CorrelationMatrix   map[string]map[string]float64
-        StressTestResults   map[string]float64
-        SectorExposure      map[string]float64
-        AssetClassExposure  map[string]float64
-        ConcentrationRisk   float64
-        LiquidityRisk       float64
-        OptionExposure      map[string]float64
-        DeltaExposure       float64
-        GammaExposure       float64
-        ThetaExposure       float64
-        VegaExposure        float64
-        RhoExposure         float64
-        UpdatedAt           time.Time
+        ValueAtRisk          float64 // Loss not exceeded at VaRConfidence over VaRHorizonDays
+        ConditionalVaR       float64 // Expected loss when the loss exceeds ValueAtRisk
+        VaRMethod            string
+        VaRConfidence        float64
+        VaRHorizonDays       int
+        BetaToMarket         float64
+        FactorExposures      map[string]float64 // Sensitivity to each factor beyond the market
+        PortfolioVolatility  float64
+        CorrelationMatrix    map[string]map[string]float64
+        DiversificationRatio float64
+        StressTestResults    map[string]float64            // P&L by stress scenario
+        StressTestImpacts    map[string]map[string]float64 // P&L by stress scenario and position ID
+        SectorExposure       map[string]float64
+        IndustryExposure     map[string]float64
+        AssetClassExposure   map[string]float64
+        ConcentrationRisk    float64
+        LiquidityRisk        float64
+        OptionExposure       map[string]float64
+        DeltaExposure        float64
+        GammaExposure        float64
+        ThetaExposure        float64
+        VegaExposure         float64
+        RhoExposure          float64
+        UpdatedAt            time.Time
 }
 
 // AnalyticsTask represents a task for the analytics engine
@@ -143,7 +174,7 @@ type OptionData struct {
         AskPrice    float64
         Volume      int
         OpenInterest int
-        ImpliedVolatility float64
+        ImpliedVolatility float64 // In percent
         Delta       float64
         Gamma       float64
         Theta       float64
@@ -153,7 +184,7 @@ type OptionData struct {
 
 // NewPortfolioAnalyticsEngine creates a new portfolio analytics engine
 func NewPortfolioAnalyticsEngine(dataProvider DataProvider, workers int) *PortfolioAnalyticsEngine {
-        return &PortfolioAnalyticsEngine{
+        e := &PortfolioAnalyticsEngine{
                 portfolios:       make(map[string]*Portfolio),
                 positions:        make(map[string][]*Position),
                 performanceCache: make(map[string]*PerformanceMetrics),
@@ -162,7 +193,40 @@ func NewPortfolioAnalyticsEngine(dataProvider DataProvider, workers int) *Portfo
                 calculationQueue: make(chan *AnalyticsTask, 1000),
                 workers:          workers,
                 stopChan:         make(chan struct{}),
+                riskConfig:       DefaultRiskConfig(),
+                referenceData:    NewReferenceDataService(),
+                attributionCache: make(map[string]map[string]*PerformanceAttribution),
+                riskLimits:       make(map[string]RiskLimits),
+                lotMethods:       make(map[string]string),
+                exposureLimits:   make(map[string]ExposureLimits),
+                alerts:           newLimitAlerts(),
+                equityCurves:     make(map[string][]*EquitySnapshot),
+        }
+        e.greeks = NewGreeksAggregator(e)
+
+        return e
+}
+
+// Greeks returns the aggregator that keeps portfolio and account greeks current with ticks
+func (e *PortfolioAnalyticsEngine) Greeks() *GreeksAggregator {
+        return e.greeks
+}
+
+// SetRiskConfig sets how risk metrics are calculated
+func (e *PortfolioAnalyticsEngine) SetRiskConfig(config RiskConfig) error {
+        if err := config.Validate(); err != nil {
+                return err
         }
+
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        e.riskConfig = config
+
+        // Cached metrics were calculated with the old configuration
+        e.riskCache = make(map[string]*RiskMetrics)
+
+        return nil
 }
 
 // Start starts the portfolio analytics engine
@@ -205,21 +269,9 @@ func (e *PortfolioAnalyticsEngine) worker() {
                 case <-e.stopChan:
                         return
                 case task := <-e.calculationQueue:
-                        var result interface{}
-                        var err error
-
-                        switch task.TaskType {
-                        case "performance":
-                                result, err = e.calculatePerformanceMetrics(task.PortfolioID)
-                        case "risk":
-                                result, err = e.calculateRiskMetrics(task.PortfolioID)
-                        case "update_prices":
-                                err = e.updatePositionPrices(task.PortfolioID)
-                                result = nil
-                        case "update_greeks":
-                                err = e.updatePositionGreeks(task.PortfolioID)
-                                result = nil
-                        }
+                        start := time.Now()
+                        result, err := e.runTask(task)
+                        metrics.ObserveQueueTask(analyticsQueue, task.TaskType, start, err)
 
                         if task.Callback != nil {
                                 task.Callback(result, err)
@@ -228,6 +280,35 @@ func (e *PortfolioAnalyticsEngine) worker() {
         }
 }
 
+// runTask runs a task of the calculation queue, reporting its errors. Panics are
+// recovered from and reported too, failing the task rather than the worker.
+func (e *PortfolioAnalyticsEngine) runTask(task *AnalyticsTask) (result interface{}, err error) {
+        defer errorreporting.Recover(context.Background(), "analytics", &err)
+
+        // Tasks update positions and caches, so they must not run concurrently
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        switch task.TaskType {
+        case "performance":
+                result, err = e.calculatePerformanceMetrics(task.PortfolioID)
+        case "risk":
+                result, err = e.calculateRiskMetrics(task.PortfolioID)
+        case "update_prices":
+                err = e.updatePositionPrices(task.PortfolioID)
+        case "update_greeks":
+                err = e.updatePositionGreeks(task.PortfolioID)
+        case "snapshot_equity":
+                result, err = e.snapshotEquity(task.PortfolioID)
+        }
+
+        if err != nil {
+                errorreporting.CaptureError(context.Background(), "analytics",
+                        fmt.Errorf("%s task for portfolio %s: %w", task.TaskType, task.PortfolioID, err))
+        }
+        return result, err
+}
+
 // AddPortfolio adds a portfolio to the engine
 func (e *PortfolioAnalyticsEngine) AddPortfolio(portfolio *Portfolio) error {
         e.mutex.Lock()
@@ -273,6 +354,11 @@ func (e *PortfolioAnalyticsEngine) UpdatePortfolio(portfolio *Portfolio) error {
                 return fmt.Errorf("portfolio with ID %s not found", portfolio.ID)
         }
 
+        // Invalidate cache, leaving the portfolio as it was when that fails
+        if err := e.invalidateCache(portfolio.ID); err != nil {
+                return err
+        }
+
         e.portfolios[portfolio.ID] = portfolio
         e.positions[portfolio.ID] = portfolio.Positions
 
@@ -288,10 +374,16 @@ func (e *PortfolioAnalyticsEngine) DeletePortfolio(portfolioID string) error {
                 return fmt.Errorf("portfolio with ID %s not found", portfolioID)
         }
 
+        if err := e.invalidateCache(portfolioID); err != nil {
+                return err
+        }
+        if err := e.forgetEquityCurve(portfolioID); err != nil {
+                return err
+        }
+
         delete(e.portfolios, portfolioID)
         delete(e.positions, portfolioID)
-        delete(e.performanceCache, portfolioID)
-        delete(e.riskCache, portfolioID)
+        e.forgetAlerts(portfolioID)
 
         return nil
 }
@@ -306,14 +398,15 @@ func (e *PortfolioAnalyticsEngine) AddPosition(portfolioID string, position *Pos
                 return fmt.Errorf("portfolio with ID %s not found", portfolioID)
         }
 
+        // Invalidate cache, leaving the portfolio as it was when that fails
+        if err := e.invalidateCache(portfolioID); err != nil {
+                return err
+        }
+
         position.PortfolioID = portfolioID
         portfolio.Positions = append(portfolio.Positions, position)
         e.positions[portfolioID] = portfolio.Positions
 
-        // Invalidate cache
-        delete(e.performanceCache, portfolioID)
-        delete(e.riskCache, portfolioID)
-
         return nil
 }
 
@@ -328,24 +421,25 @@ func (e *PortfolioAnalyticsEngine) UpdatePosition(position *Position) error {
                 return fmt.Errorf("portfolio with ID %s not found", portfolioID)
         }
 
-        found := false
+        index := -1
         for i, p := range portfolio.Positions {
                 if p.ID == position.ID {
-                        portfolio.Positions[i] = position
-                        found = true
+                        index = i
                         break
                 }
         }
 
-        if !found {
+        if index == -1 {
                 return fmt.Errorf("position with ID %s not found in portfolio %s", position.ID, portfolioID)
         }
 
-        e.positions[portfolioID] = portfolio.Positions
+        // Invalidate cache, leaving the portfolio as it was when that fails
+        if err := e.invalidateCache(portfolioID); err != nil {
+                return err
+        }
 
-        // Invalidate cache
-        delete(e.performanceCache, portfolioID)
-        delete(e.riskCache, portfolioID)
+        portfolio.Positions[index] = position
+        e.positions[portfolioID] = portfolio.Positions
 
         return nil
 }
@@ -374,26 +468,34 @@ func (e *PortfolioAnalyticsEngine) DeletePosition(portfolioID string, positionID
                 return fmt.Errorf("position with ID %s not found in portfolio %s", positionID, portfolioID)
         }
 
+        // Invalidate cache, leaving the portfolio as it was when that fails
+        if err := e.invalidateCache(portfolioID); err != nil {
+                return err
+        }
+
         portfolio.Positions = newPositions
         e.positions[portfolioID] = newPositions
 
-        // Invalidate cache
-        delete(e.performanceCache, portfolioID)
-        delete(e.riskCache, portfolioID)
-
         return nil
 }
 
 // CalculatePerformanceMetrics calculates performance metrics for a portfolio
 func (e *PortfolioAnalyticsEngine) CalculatePerformanceMetrics(portfolioID string) (*PerformanceMetrics, error) {
-        e.mutex.RLock()
-        defer e.mutex.RUnlock()
+        // Metrics loaded or calculated here are cached, which takes the write lock
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
 
         // Check cache first
         if metrics, exists := e.performanceCache[portfolioID]; exists && metrics.UpdatedAt.After(time.Now().Add(-1*time.Hour)) {
                 return metrics, nil
         }
 
+        // Then metrics persisted before a restart
+        if metrics := e.persistedPerformanceMetrics(portfolioID); metrics != nil {
+                e.performanceCache[portfolioID] = metrics
+                return metrics, nil
+        }
+
         return e.calculatePerformanceMetrics(portfolioID)
 }
 
@@ -473,37 +575,92 @@ func (e *PortfolioAnalyticsEngine) calculatePerformanceMetrics(portfolioID strin
                 profitFactor = totalWin / totalLoss
         }
 
+        history, err := e.calculatePerformanceHistory(positions, portfolio.Benchmark)
+        if err != nil {
+                return nil, err
+        }
+
+        // Stored equity snapshots are preferred over the curve rebuilt from price history
+        if snapshots := e.equityCurves[portfolioID]; len(snapshots) > 2 {
+                returns := snapshotReturns(snapshots)
+                history.cagr = compoundAnnualGrowth(returns, snapshots[0].Date, snapshots[len(snapshots)-1].Date)
+                history.volatility, history.sharpeRatio, history.sortinoRatio = returnStatistics(returns, e.riskConfig.RiskFreeRate)
+        }
+
+        underwaterCurve := make(map[string]float64, len(history.drawdowns.Underwater))
+        for _, point := range history.drawdowns.Underwater {
+                underwaterCurve[point.Date.Format("2006-01-02")] = point.Drawdown
+        }
+
         // Create performance metrics
         metrics := &PerformanceMetrics{
                 TotalPnL:        totalPnL,
                 RealizedPnL:     realizedPnL,
                 UnrealizedPnL:   unrealizedPnL,
                 PnLPercentage:   pnlPercentage,
+                CAGR:            history.cagr,
+                Volatility:      history.volatility,
+                SharpeRatio:     history.sharpeRatio,
+                SortinoRatio:    history.sortinoRatio,
+                MaxDrawdown:     history.drawdowns.MaxDrawdown,
+                DrawdownDays:    history.drawdowns.LongestDrawdownDays,
+                RecoveryDays:    history.drawdowns.RecoveryDays,
                 WinRate:         winRate,
                 ProfitFactor:    profitFactor,
                 AverageWin:      averageWin,
                 AverageLoss:     averageLoss,
                 ReturnOnCapital: pnlPercentage,
-                UpdatedAt:       time.Now(),
+                DailyPnL:        history.dailyPnL,
+                CumulativePnL:   history.cumulativePnL,
+                UnderwaterCurve: underwaterCurve,
+
+                RollingPerformance: history.rolling,
+                RollingSeries:      history.rollingSeries,
+                Benchmark:          history.benchmark,
+                UpdatedAt:          time.Now(),
         }
 
         // Cache the metrics
         e.performanceCache[portfolioID] = metrics
         portfolio.PerformanceCache = metrics
 
+        // A failed write only costs a recalculation after a restart
+        if e.cacheStore != nil {
+                e.cacheStore.SavePerformanceMetrics(context.Background(), portfolioID, metrics)
+        }
+
+        e.publishMetrics(&MetricsUpdate{
+                PortfolioID: portfolioID,
+                Performance: metrics,
+                UpdatedAt:   metrics.UpdatedAt,
+        })
+
         return metrics, nil
 }
 
 // CalculateRiskMetrics calculates risk metrics for a portfolio
 func (e *PortfolioAnalyticsEngine) CalculateRiskMetrics(portfolioID string) (*RiskMetrics, error) {
-        e.mutex.RLock()
-        defer e.mutex.RUnlock()
+        // riskMetrics caches what it loads or calculates, which takes the write lock
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
 
+        return e.riskMetrics(portfolioID)
+}
+
+// riskMetrics returns a portfolio's cached risk metrics while they are fresh and
+// calculates them otherwise. The engine's lock must be held.
+func (e *PortfolioAnalyticsEngine) riskMetrics(portfolioID string) (*RiskMetrics, error) {
         // Check cache first
         if metrics, exists := e.riskCache[portfolioID]; exists && metrics.UpdatedAt.After(time.Now().Add(-1*time.Hour)) {
                 return metrics, nil
         }
 
+        // Then metrics persisted before a restart
+        if metrics := e.persistedRiskMetrics(portfolioID); metrics != nil {
+                e.riskCache[portfolioID] = metrics
+                return metrics, nil
+        }
+
         return e.calculateRiskMetrics(portfolioID)
 }
 
@@ -514,6 +671,9 @@ func (e *PortfolioAnalyticsEngine) calculateRiskMetrics(portfolioID string) (*Ri
                 return nil, fmt.Errorf("portfolio with ID %s not found", portfolioID)
         }
 
+        // Exposure limits are checked on every recalculation
+        e.checkExposureLimits(portfolioID)
+
         positions := e.positions[portfolioID]
         if len(positions) == 0 {
                 return &RiskMetrics{
@@ -525,6 +685,7 @@ func (e *PortfolioAnalyticsEngine) calculateRiskMetrics(portfolioID string) (*Ri
         // This is a simplified implementation
         var deltaExposure, gammaExposure, thetaExposure, vegaExposure, rhoExposure float64
         sectorExposure := make(map[string]float64)
+        industryExposure := make(map[string]float64)
         assetClassExposure := make(map[string]float64)
         optionExposure := make(map[string]float64)
 
@@ -554,28 +715,79 @@ func (e *PortfolioAnalyticsEngine) calculateRiskMetrics(portfolioID string) (*Ri
                 }
                 assetClassExposure[assetClass] += value
 
-                // Sector exposure (simplified)
-                sector := "Unknown" // In a real implementation, this would be determined from a sector database
-                sectorExposure[sector] += value
+                // Sector and industry exposure
+                classification := e.referenceData.Classify(position.Symbol)
+                sectorExposure[classification.Sector] += value
+                industryExposure[classification.Industry] += value
+        }
+
+        valueAtRisk, err := e.calculateValueAtRisk(positions)
+        if err != nil {
+                return nil, err
+        }
+
+        correlations, err := e.calculateCorrelations(positions)
+        if err != nil {
+                return nil, err
+        }
+
+        marketExposure, err := e.calculateMarketExposure(positions)
+        if err != nil {
+                return nil, err
+        }
+
+        stressResults, err := e.stressTest(context.Background(), positions, e.stressScenarios())
+        if err != nil {
+                return nil, err
+        }
+
+        stressTestResults := make(map[string]float64, len(stressResults))
+        stressTestImpacts := make(map[string]map[string]float64, len(stressResults))
+        for _, result := range stressResults {
+                stressTestResults[result.Scenario] = result.PnL
+                stressTestImpacts[result.Scenario] = result.PositionImpacts
         }
 
         // Create risk metrics
         metrics := &RiskMetrics{
-                SectorExposure:     sectorExposure,
-                AssetClassExposure: assetClassExposure,
-                OptionExposure:     optionExposure,
-                DeltaExposure:      deltaExposure,
-                GammaExposure:      gammaExposure,
-                ThetaExposure:      thetaExposure,
-                VegaExposure:       vegaExposure,
-                RhoExposure:        rhoExposure,
-                UpdatedAt:          time.Now(),
+                ValueAtRisk:          valueAtRisk.ValueAtRisk,
+                ConditionalVaR:       valueAtRisk.ConditionalVaR,
+                VaRMethod:            e.riskConfig.VaRMethod,
+                VaRConfidence:        e.riskConfig.ConfidenceLevel,
+                VaRHorizonDays:       e.riskConfig.HorizonDays,
+                BetaToMarket:         marketExposure.BetaToMarket,
+                FactorExposures:      marketExposure.FactorExposures,
+                CorrelationMatrix:    correlations.CorrelationMatrix,
+                DiversificationRatio: correlations.DiversificationRatio,
+                StressTestResults:    stressTestResults,
+                StressTestImpacts:    stressTestImpacts,
+                SectorExposure:       sectorExposure,
+                IndustryExposure:     industryExposure,
+                AssetClassExposure:   assetClassExposure,
+                OptionExposure:       optionExposure,
+                DeltaExposure:        deltaExposure,
+                GammaExposure:        gammaExposure,
+                ThetaExposure:        thetaExposure,
+                VegaExposure:         vegaExposure,
+                RhoExposure:          rhoExposure,
+                UpdatedAt:            time.Now(),
         }
 
         // Cache the metrics
         e.riskCache[portfolioID] = metrics
         portfolio.RiskCache = metrics
 
+        // A failed write only costs a recalculation after a restart
+        if e.cacheStore != nil {
+                e.cacheStore.SaveRiskMetrics(context.Background(), portfolioID, metrics)
+        }
+
+        e.publishMetrics(&MetricsUpdate{
+                PortfolioID: portfolioID,
+                Risk:        metrics,
+                UpdatedAt:   metrics.UpdatedAt,
+        })
+
         return metrics, nil
 }
 
@@ -589,6 +801,8 @@ func (e *PortfolioAnalyticsEngine) updatePositionPrices(portfolioID string) erro
         positions := e.positions[portfolioID]
         ctx := context.Background()
 
+        // Every price is fetched before any is applied, so a failure changes nothing
+        prices := make(map[int]float64, len(positions))
         for i, position := range positions {
                 if position.ExitTime != nil {
                         // Skip closed positions
@@ -600,16 +814,19 @@ func (e *PortfolioAnalyticsEngine) updatePositionPrices(portfolioID string) erro
                         return fmt.Errorf("failed to get current price for %s: %w", position.Symbol, err)
                 }
 
-                positions[i].CurrentPrice = price
+                prices[i] = price
+        }
+
+        if err := e.invalidateCache(portfolioID); err != nil {
+                return err
         }
 
+        for i, price := range prices {
+                positions[i].CurrentPrice = price
+        }
         portfolio.Positions = positions
         e.positions[portfolioID] = positions
 
-        // Invalidate cache
-        delete(e.performanceCache, portfolioID)
-        delete(e.riskCache, portfolioID)
-
         return nil
 }
 
@@ -651,6 +868,9 @@ func (e *PortfolioAnalyticsEngine) updatePositionGreeks(portfolioID string) erro
         return nil
 }
 
+// analyticsQueue names the calculation queue in metrics
+const analyticsQueue = "analytics"
+
 // QueueTask queues a task for asynchronous processing
 func (e *PortfolioAnalyticsEngine) QueueTask(taskType string, portfolioID string, callback func(interface{}, error)) error {
         e.mutex.RLock()
@@ -670,6 +890,104 @@ func (e *PortfolioAnalyticsEngine) QueueTask(taskType string, portfolioID string
         case e.calculationQueue <- task:
                 return nil
         default:
+                metrics.QueueRejection(analyticsQueue)
                 return errors.New("calculation queue is full")
         }
 }
+
+// QueueDepth returns the number of tasks waiting in the calculation queue
+func (e *PortfolioAnalyticsEngine) QueueDepth() int {
+        return len(e.calculationQueue)
+}
+
+// LivePnLByUser returns the realized and unrealized P&L of the live portfolios of
+// each user, SIM portfolios being left out
+func (e *PortfolioAnalyticsEngine) LivePnLByUser() map[string]float64 {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        pnlByUser := make(map[string]float64)
+        for portfolioID, portfolio := range e.portfolios {
+                if portfolio.Environment == "SIM" {
+                        continue
+                }
+                pnl := 0.0
+                for _, position := range e.positions[portfolioID] {
+                        pnl += positionPnL(position)
+                }
+                pnlByUser[portfolio.UserID] += pnl
+        }
+        return pnlByUser
+}
+
+// ActiveStrategies returns the number of strategies trading, with open positions
+// in live portfolios
+func (e *PortfolioAnalyticsEngine) ActiveStrategies() int {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        strategies := make(map[string]bool)
+        for portfolioID, portfolio := range e.portfolios {
+                if portfolio.Environment == "SIM" {
+                        continue
+                }
+                for _, position := range e.positions[portfolioID] {
+                        if position.ExitTime != nil {
+                                continue
+                        }
+                        strategyID := position.StrategyID
+                        if strategyID == "" {
+                                strategyID = portfolio.StrategyID
+                        }
+                        if strategyID != "" {
+                                strategies[strategyID] = true
+                        }
+                }
+        }
+        return len(strategies)
+}
+
+// positionPnL returns the P&L of a position, realized at its exit price once
+// closed and unrealized at its current price while open
+func positionPnL(position *Position) float64 {
+        price := position.CurrentPrice
+        if position.ExitTime != nil && position.ExitPrice != nil {
+                price = *position.ExitPrice
+        }
+        pnl := float64(position.Quantity) * (price - position.EntryPrice)
+        if position.TransactionType == "SELL" {
+                pnl = -pnl
+        }
+        return pnl
+}
+
+// EngineStats reports the queue and cache sizes of the engine
+type EngineStats struct {
+        Running           bool `json:"running"`
+        Workers           int  `json:"workers"`
+        QueuedTasks       int  `json:"queuedTasks"`
+        QueueCapacity     int  `json:"queueCapacity"`
+        Portfolios        int  `json:"portfolios"`
+        PerformanceCached int  `json:"performanceCached"` // Portfolios with cached performance metrics
+        RiskCached        int  `json:"riskCached"`
+        AttributionCached int  `json:"attributionCached"`
+        EquityCurves      int  `json:"equityCurves"`
+}
+
+// Stats returns the queue and cache sizes of the engine
+func (e *PortfolioAnalyticsEngine) Stats() EngineStats {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        return EngineStats{
+                Running:           e.isRunning,
+                Workers:           e.workers,
+                QueuedTasks:       len(e.calculationQueue),
+                QueueCapacity:     cap(e.calculationQueue),
+                Portfolios:        len(e.portfolios),
+                PerformanceCached: len(e.performanceCache),
+                RiskCached:        len(e.riskCache),
+                AttributionCached: len(e.attributionCache),
+                EquityCurves:      len(e.equityCurves),
+        }
+}
@@ -0,0 +1,260 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "math"
+        "sort"
+        "time"
+
+        "github.com/go-redis/redis/v8"
+        "github.com/trading-platform/backend/internal/messagequeue"
+)
+
+// EquitySnapshot is a portfolio's equity at the close of a day. Returns are measured
+// on the change in P&L, so capital added or withdrawn between snapshots is not
+// mistaken for performance.
+type EquitySnapshot struct {
+        PortfolioID string
+        Date        time.Time // Midnight UTC of the day
+        Capital     float64   // Capital committed to positions at their entry prices
+        PnL         float64   // Cumulative realized and unrealized P&L
+        Equity      float64   // Capital plus PnL
+}
+
+// EquitySnapshotStore persists the daily equity snapshots of portfolios, the series
+// CAGR, volatility and the Sharpe and Sortino ratios are calculated from
+type EquitySnapshotStore interface {
+        SaveEquitySnapshot(ctx context.Context, snapshot *EquitySnapshot) error
+        LoadEquitySnapshots(ctx context.Context, portfolioID string) ([]*EquitySnapshot, error) // Oldest first
+        DeleteEquitySnapshots(ctx context.Context, portfolioID string) error
+}
+
+// RedisEquitySnapshotStore is an EquitySnapshotStore keeping each portfolio's
+// snapshots under one Redis key. Snapshots never expire.
+type RedisEquitySnapshotStore struct {
+        redis  *messagequeue.RedisClient
+        prefix string
+}
+
+// NewRedisEquitySnapshotStore creates a Redis-backed equity snapshot store
+func NewRedisEquitySnapshotStore(redis *messagequeue.RedisClient) *RedisEquitySnapshotStore {
+        return &RedisEquitySnapshotStore{
+                redis:  redis,
+                prefix: "analytics",
+        }
+}
+
+// equityKey returns the Redis key holding a portfolio's equity snapshots
+func (s *RedisEquitySnapshotStore) equityKey(portfolioID string) string {
+        return fmt.Sprintf("%s:equity:%s", s.prefix, portfolioID)
+}
+
+// SaveEquitySnapshot implements EquitySnapshotStore, replacing any snapshot of the
+// same day
+func (s *RedisEquitySnapshotStore) SaveEquitySnapshot(ctx context.Context, snapshot *EquitySnapshot) error {
+        snapshots, err := s.LoadEquitySnapshots(ctx, snapshot.PortfolioID)
+        if err != nil {
+                return err
+        }
+
+        return s.redis.Set(ctx, s.equityKey(snapshot.PortfolioID), withSnapshot(snapshots, snapshot), 0)
+}
+
+// LoadEquitySnapshots implements EquitySnapshotStore
+func (s *RedisEquitySnapshotStore) LoadEquitySnapshots(ctx context.Context, portfolioID string) ([]*EquitySnapshot, error) {
+        var snapshots []*EquitySnapshot
+        if err := s.redis.Get(ctx, s.equityKey(portfolioID), &snapshots); err != nil {
+                if errors.Is(err, redis.Nil) {
+                        return nil, nil
+                }
+                return nil, fmt.Errorf("failed to load equity snapshots for %s: %w", portfolioID, err)
+        }
+
+        return snapshots, nil
+}
+
+// DeleteEquitySnapshots implements EquitySnapshotStore
+func (s *RedisEquitySnapshotStore) DeleteEquitySnapshots(ctx context.Context, portfolioID string) error {
+        return s.redis.Delete(ctx, s.equityKey(portfolioID))
+}
+
+// SetEquityStore sets where equity snapshots are persisted
+func (e *PortfolioAnalyticsEngine) SetEquityStore(store EquitySnapshotStore) {
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        e.equityStore = store
+}
+
+// LoadEquityCurves loads the persisted equity snapshots of every portfolio in the
+// engine, typically once portfolios have been loaded at startup, and returns how
+// many were loaded
+func (e *PortfolioAnalyticsEngine) LoadEquityCurves(ctx context.Context) (int, error) {
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        if e.equityStore == nil {
+                return 0, nil
+        }
+
+        loaded := 0
+        for portfolioID := range e.portfolios {
+                snapshots, err := e.equityStore.LoadEquitySnapshots(ctx, portfolioID)
+                if err != nil {
+                        return loaded, err
+                }
+
+                e.equityCurves[portfolioID] = snapshots
+                loaded += len(snapshots)
+        }
+
+        return loaded, nil
+}
+
+// GetEquityCurve returns a portfolio's daily equity snapshots, oldest first
+func (e *PortfolioAnalyticsEngine) GetEquityCurve(portfolioID string) ([]EquitySnapshot, error) {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        if _, exists := e.portfolios[portfolioID]; !exists {
+                return nil, fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+
+        curve := make([]EquitySnapshot, 0, len(e.equityCurves[portfolioID]))
+        for _, snapshot := range e.equityCurves[portfolioID] {
+                curve = append(curve, *snapshot)
+        }
+
+        return curve, nil
+}
+
+// SetRiskFreeRate sets the annual rate Sharpe and Sortino ratios are measured
+// against and options are priced with
+func (e *PortfolioAnalyticsEngine) SetRiskFreeRate(rate float64) error {
+        if rate <= -1 || rate >= 1 {
+                return errors.New("risk-free rate must be between -1 and 1")
+        }
+
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        // Every cached metric was calculated with the old rate
+        for portfolioID := range e.portfolios {
+                if err := e.invalidateCache(portfolioID); err != nil {
+                        return err
+                }
+        }
+
+        e.riskConfig.RiskFreeRate = rate
+
+        return nil
+}
+
+// snapshotEquity records a portfolio's equity at current prices as today's
+// snapshot, replacing an earlier snapshot of the same day. The engine's lock must
+// be held.
+func (e *PortfolioAnalyticsEngine) snapshotEquity(portfolioID string) (*EquitySnapshot, error) {
+        if _, exists := e.portfolios[portfolioID]; !exists {
+                return nil, fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+
+        positions := e.positions[portfolioID]
+        snapshot := &EquitySnapshot{
+                PortfolioID: portfolioID,
+                Date:        time.Now().UTC().Truncate(24 * time.Hour),
+                Capital:     capitalOf(positions),
+        }
+
+        for _, position := range positions {
+                price := position.CurrentPrice
+                if position.ExitTime != nil && position.ExitPrice != nil {
+                        price = *position.ExitPrice
+                }
+
+                pnl := float64(position.Quantity) * (price - position.EntryPrice)
+                if position.TransactionType == "SELL" {
+                        pnl = -pnl
+                }
+                snapshot.PnL += pnl
+        }
+        snapshot.Equity = snapshot.Capital + snapshot.PnL
+
+        e.equityCurves[portfolioID] = withSnapshot(e.equityCurves[portfolioID], snapshot)
+
+        if e.equityStore != nil {
+                if err := e.equityStore.SaveEquitySnapshot(context.Background(), snapshot); err != nil {
+                        return nil, fmt.Errorf("failed to save equity snapshot for %s: %w", portfolioID, err)
+                }
+        }
+
+        return snapshot, nil
+}
+
+// forgetEquityCurve drops the equity snapshots of a deleted portfolio. The engine's
+// lock must be held.
+func (e *PortfolioAnalyticsEngine) forgetEquityCurve(portfolioID string) error {
+        delete(e.equityCurves, portfolioID)
+
+        if e.equityStore == nil {
+                return nil
+        }
+
+        if err := e.equityStore.DeleteEquitySnapshots(context.Background(), portfolioID); err != nil {
+                return fmt.Errorf("failed to delete equity snapshots for %s: %w", portfolioID, err)
+        }
+
+        return nil
+}
+
+// withSnapshot returns snapshots with snapshot added, replacing any snapshot of the
+// same day and keeping them oldest first
+func withSnapshot(snapshots []*EquitySnapshot, snapshot *EquitySnapshot) []*EquitySnapshot {
+        updated := make([]*EquitySnapshot, 0, len(snapshots)+1)
+        for _, existing := range snapshots {
+                if !existing.Date.Equal(snapshot.Date) {
+                        updated = append(updated, existing)
+                }
+        }
+        updated = append(updated, snapshot)
+
+        sort.Slice(updated, func(i, j int) bool {
+                return updated[i].Date.Before(updated[j].Date)
+        })
+
+        return updated
+}
+
+// snapshotReturns returns the day-on-day returns between consecutive snapshots
+func snapshotReturns(snapshots []*EquitySnapshot) []float64 {
+        returns := make([]float64, 0, len(snapshots))
+        for i := 1; i < len(snapshots); i++ {
+                dailyReturn := 0.0
+                if previous := snapshots[i-1].Equity; previous > 0 {
+                        dailyReturn = (snapshots[i].PnL - snapshots[i-1].PnL) / previous
+                }
+                returns = append(returns, dailyReturn)
+        }
+        return returns
+}
+
+// compoundAnnualGrowth returns the annualized growth, in percent, of compounding
+// returns from one date to a later one. It is zero over less than a day, and -100
+// when the returns wiped out the equity.
+func compoundAnnualGrowth(returns []float64, from, to time.Time) float64 {
+        days := to.Sub(from).Hours() / 24
+        if days < 1 {
+                return 0
+        }
+
+        growth := 1.0
+        for _, dailyReturn := range returns {
+                growth *= 1 + dailyReturn
+        }
+        if growth <= 0 {
+                return -100
+        }
+
+        return (math.Pow(growth, 365/days) - 1) * 100
+}
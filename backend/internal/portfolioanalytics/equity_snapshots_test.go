@@ -0,0 +1,124 @@
+package portfolioanalytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompoundAnnualGrowth(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		returns []float64
+		days    int
+		cagr    float64
+	}{
+		{"Doubled in a year", []float64{0.5, 1.0 / 3}, 365, 100},
+		{"Halved in a year", []float64{-0.5}, 365, -50},
+		{"10% over two years", []float64{0.1}, 730, 4.880884817015163},
+		{"Flat", []float64{0.1, -1.0 / 11}, 365, 0},
+		{"Wiped out", []float64{0.1, -1}, 365, -100},
+		{"Wiped out past zero", []float64{-1.5}, 30, -100},
+		{"Less than a day", []float64{0.1}, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			to := from.AddDate(0, 0, test.days)
+			assert.InDelta(t, test.cagr, compoundAnnualGrowth(test.returns, from, to), 1e-9)
+		})
+	}
+}
+
+func TestSnapshotReturns(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Capital of 500 added on the second day is not a return
+	snapshots := []*EquitySnapshot{
+		{Date: day, Capital: 1000, PnL: 0, Equity: 1000},
+		{Date: day.AddDate(0, 0, 1), Capital: 1500, PnL: 0, Equity: 1500},
+		{Date: day.AddDate(0, 0, 2), Capital: 1500, PnL: 150, Equity: 1650},
+		{Date: day.AddDate(0, 0, 3), Capital: 1500, PnL: -15, Equity: 1485},
+	}
+
+	returns := snapshotReturns(snapshots)
+	require.Len(t, returns, 3)
+	assert.InDelta(t, 0, returns[0], 1e-12)
+	assert.InDelta(t, 0.1, returns[1], 1e-12)
+	assert.InDelta(t, -0.1, returns[2], 1e-12)
+}
+
+func TestReturnStatistics(t *testing.T) {
+	// Both series have a sample standard deviation of sqrt(0.0002)
+	tests := []struct {
+		name         string
+		returns      []float64
+		riskFreeRate float64
+		volatility   float64
+		sharpe       float64
+		sortino      float64
+	}{
+		{"No mean return", []float64{0.01, -0.01}, 0, 22.44994432064365, 0, 0},
+		{"No losing days", []float64{0.02, 0}, 0, 22.44994432064365, 11.224972160321826, 0},
+		{"Against the risk-free rate", []float64{0.02, 0}, 0.252, 22.44994432064365, 10.102474944289641, 202.04949888579284},
+		{"Too little history", []float64{0.01}, 0, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			volatility, sharpe, sortino := returnStatistics(test.returns, test.riskFreeRate)
+			assert.InDelta(t, test.volatility, volatility, 1e-9)
+			assert.InDelta(t, test.sharpe, sharpe, 1e-9)
+			assert.InDelta(t, test.sortino, sortino, 1e-9)
+		})
+	}
+}
+
+func TestPerformanceMetricsFromEquitySnapshots(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	yearAgo := today.Add(-365 * 24 * time.Hour)
+
+	tests := []struct {
+		name      string
+		snapshots []*EquitySnapshot
+		cagr      float64
+	}{
+		{
+			name: "Grown 10% in a year",
+			snapshots: []*EquitySnapshot{
+				{Date: yearAgo, Capital: 1000, Equity: 1000},
+				{Date: yearAgo.AddDate(0, 0, 180), Capital: 1000, PnL: 50, Equity: 1050},
+				{Date: today, Capital: 1000, PnL: 100, Equity: 1100},
+			},
+			cagr: 10,
+		},
+		{
+			name: "Wiped out",
+			snapshots: []*EquitySnapshot{
+				{Date: today.AddDate(0, 0, -2), Capital: 1000, Equity: 1000},
+				{Date: today.AddDate(0, 0, -1), Capital: 1000, PnL: 100, Equity: 1100},
+				{Date: today, Capital: 1000, PnL: -1000, Equity: 0},
+			},
+			cagr: -100,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine := NewPortfolioAnalyticsEngine(nil, 1)
+			require.NoError(t, engine.AddPortfolio(&Portfolio{
+				ID:        "portfolio1",
+				Positions: []*Position{{ID: "reliance", Symbol: "RELIANCE", Quantity: 10, EntryPrice: 100, CurrentPrice: 110, EntryTime: yearAgo, TransactionType: "BUY"}},
+			}))
+			engine.equityCurves["portfolio1"] = test.snapshots
+
+			metrics, err := engine.CalculatePerformanceMetrics("portfolio1")
+			require.NoError(t, err)
+			assert.InEpsilon(t, test.cagr, metrics.CAGR, 1e-9)
+		})
+	}
+}
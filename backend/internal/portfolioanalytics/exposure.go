@@ -0,0 +1,98 @@
+package portfolioanalytics
+
+import (
+        "math"
+        "sort"
+        "time"
+)
+
+// AccountExposure is the exposure of all of an account's portfolios combined.
+// Exposures are the signed market value of open positions, negative when short.
+type AccountExposure struct {
+        AccountID     string
+        PortfolioIDs  []string
+        GrossExposure float64
+        NetExposure   float64
+        LongExposure  float64
+        ShortExposure float64 // Negative
+        BySymbol      map[string]float64
+        BySector      map[string]float64
+        ByIndustry    map[string]float64
+        ByAssetClass  map[string]float64
+        Greeks        *AggregateGreeks
+        UpdatedAt     time.Time
+}
+
+// GetAccountExposure returns the combined exposure of an account's portfolios
+func (e *PortfolioAnalyticsEngine) GetAccountExposure(accountID string) (*AccountExposure, error) {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        return e.accountExposure(accountID), nil
+}
+
+// accountExposure is the internal implementation of GetAccountExposure. The engine's
+// lock must be held.
+func (e *PortfolioAnalyticsEngine) accountExposure(accountID string) *AccountExposure {
+        exposure := &AccountExposure{
+                AccountID:    accountID,
+                BySymbol:     make(map[string]float64),
+                BySector:     make(map[string]float64),
+                ByIndustry:   make(map[string]float64),
+                ByAssetClass: make(map[string]float64),
+                Greeks:       e.greeks.accountGreeks(accountID),
+                UpdatedAt:    time.Now(),
+        }
+
+        for portfolioID, portfolio := range e.portfolios {
+                if portfolio.UserID != accountID {
+                        continue
+                }
+                exposure.PortfolioIDs = append(exposure.PortfolioIDs, portfolioID)
+
+                for _, position := range e.positions[portfolioID] {
+                        if position.ExitTime != nil {
+                                continue
+                        }
+
+                        value := float64(position.Quantity) * position.CurrentPrice
+                        if position.TransactionType == "SELL" {
+                                value = -value
+                                exposure.ShortExposure += value
+                        } else {
+                                exposure.LongExposure += value
+                        }
+
+                        assetClass := "Equity"
+                        if position.OptionType != nil {
+                                assetClass = "Options"
+                        }
+
+                        classification := e.referenceData.Classify(position.Symbol)
+                        exposure.BySymbol[position.Symbol] += value
+                        exposure.BySector[classification.Sector] += value
+                        exposure.ByIndustry[classification.Industry] += value
+                        exposure.ByAssetClass[assetClass] += value
+                }
+        }
+        sort.Strings(exposure.PortfolioIDs)
+
+        exposure.NetExposure = exposure.LongExposure + exposure.ShortExposure
+        exposure.GrossExposure = exposure.LongExposure - exposure.ShortExposure
+
+        return exposure
+}
+
+// largestConcentration returns the largest share of gross exposure held in one symbol
+func (a *AccountExposure) largestConcentration() float64 {
+        if a.GrossExposure == 0 {
+                return 0
+        }
+
+        largest := 0.0
+        for _, value := range a.BySymbol {
+                largest = math.Max(largest, math.Abs(value))
+        }
+
+        return largest / a.GrossExposure
+}
@@ -0,0 +1,284 @@
+package portfolioanalytics
+
+import (
+        "errors"
+        "fmt"
+        "math"
+        "sync"
+        "time"
+)
+
+// Exposure limit names beyond the account risk limits, which also name the delta and
+// single-name concentration limits
+const (
+        RiskLimitVega                = "VEGA"
+        RiskLimitSectorConcentration = "SECTOR_CONCENTRATION"
+)
+
+const (
+        // AlertTypeLimitBreach is raised when a portfolio exceeds one of its exposure limits
+        AlertTypeLimitBreach = "LIMIT_BREACH"
+
+        // AlertSeverityCritical is the severity of alerts that need action
+        AlertSeverityCritical = "CRITICAL"
+
+        // maxAlertsPerPortfolio bounds the alert history kept for each portfolio
+        maxAlertsPerPortfolio = 100
+)
+
+// ExposureLimits are the limits a portfolio's exposure is held to. They are checked
+// every time the portfolio's risk is recalculated. A zero limit is not enforced.
+type ExposureLimits struct {
+        MaxDelta               float64 // Absolute net delta in any one underlying
+        MaxVega                float64 // Absolute net vega, money per volatility point
+        MaxSectorConcentration float64 // Largest share of gross exposure in one sector, a fraction
+        MaxConcentration       float64 // Largest share of gross exposure in one symbol, a fraction
+}
+
+// Validate checks the exposure limits
+func (l ExposureLimits) Validate() error {
+        if l.MaxDelta < 0 || l.MaxVega < 0 || l.MaxSectorConcentration < 0 || l.MaxConcentration < 0 {
+                return errors.New("exposure limits must not be negative")
+        }
+
+        if l.MaxSectorConcentration > 1 || l.MaxConcentration > 1 {
+                return errors.New("concentration limits must be at most 1")
+        }
+
+        return nil
+}
+
+// ExposureLimitReport compares a portfolio's exposure to its limits
+type ExposureLimitReport struct {
+        PortfolioID string
+        Limits      ExposureLimits
+        Status      []RiskLimitStatus // Enforced limits only
+        Breached    bool
+        UpdatedAt   time.Time
+}
+
+// limitAlerts tracks which exposure limits are breached and the alerts raised for them
+type limitAlerts struct {
+        mutex    sync.Mutex
+        breached map[string]map[string]bool // Breached limits by portfolio ID
+        alerts   map[string][]*Alert        // By portfolio ID, oldest first
+        handlers []func(Alert)
+        nextID   int
+}
+
+// newLimitAlerts creates an empty alert tracker
+func newLimitAlerts() *limitAlerts {
+        return &limitAlerts{
+                breached: make(map[string]map[string]bool),
+                alerts:   make(map[string][]*Alert),
+        }
+}
+
+// SetExposureLimits sets the exposure limits of a portfolio
+func (e *PortfolioAnalyticsEngine) SetExposureLimits(portfolioID string, limits ExposureLimits) error {
+        if err := limits.Validate(); err != nil {
+                return err
+        }
+
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        if _, exists := e.portfolios[portfolioID]; !exists {
+                return fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+
+        e.exposureLimits[portfolioID] = limits
+        return nil
+}
+
+// GetExposureLimits returns a portfolio's exposure limits and how much of each is in use
+func (e *PortfolioAnalyticsEngine) GetExposureLimits(portfolioID string) (*ExposureLimitReport, error) {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        if _, exists := e.portfolios[portfolioID]; !exists {
+                return nil, fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+
+        return e.exposureLimitReport(portfolioID), nil
+}
+
+// AddAlertHandler registers a handler for the alerts raised when limits are breached.
+// Handlers run on their own goroutine.
+func (e *PortfolioAnalyticsEngine) AddAlertHandler(handler func(Alert)) {
+        e.alerts.mutex.Lock()
+        defer e.alerts.mutex.Unlock()
+
+        e.alerts.handlers = append(e.alerts.handlers, handler)
+}
+
+// GetAlerts returns the alerts raised for a portfolio, oldest first
+func (e *PortfolioAnalyticsEngine) GetAlerts(portfolioID string) []Alert {
+        e.alerts.mutex.Lock()
+        defer e.alerts.mutex.Unlock()
+
+        alerts := make([]Alert, 0, len(e.alerts.alerts[portfolioID]))
+        for _, alert := range e.alerts.alerts[portfolioID] {
+                alerts = append(alerts, *alert)
+        }
+
+        return alerts
+}
+
+// AcknowledgeAlert marks one of a portfolio's alerts as seen
+func (e *PortfolioAnalyticsEngine) AcknowledgeAlert(portfolioID, alertID string) error {
+        e.alerts.mutex.Lock()
+        defer e.alerts.mutex.Unlock()
+
+        for _, alert := range e.alerts.alerts[portfolioID] {
+                if alert.ID == alertID {
+                        alert.Acknowledged = true
+                        return nil
+                }
+        }
+
+        return fmt.Errorf("alert with ID %s not found", alertID)
+}
+
+// checkExposureLimits raises an alert for every limit the portfolio has newly
+// breached. A limit stays breached, without further alerts, until the portfolio is
+// back within it. The engine's lock must be held.
+func (e *PortfolioAnalyticsEngine) checkExposureLimits(portfolioID string) {
+        report := e.exposureLimitReport(portfolioID)
+
+        e.alerts.mutex.Lock()
+        defer e.alerts.mutex.Unlock()
+
+        breached := make(map[string]bool)
+        var raised []Alert
+        for _, status := range report.Status {
+                if !status.Breached {
+                        continue
+                }
+
+                breached[status.Limit] = true
+                if e.alerts.breached[portfolioID][status.Limit] {
+                        continue
+                }
+
+                e.alerts.nextID++
+                alert := &Alert{
+                        ID:          fmt.Sprintf("alert-%d", e.alerts.nextID),
+                        PortfolioID: portfolioID,
+                        Type:        AlertTypeLimitBreach,
+                        Severity:    AlertSeverityCritical,
+                        Message:     fmt.Sprintf("%s limit of %.4g breached at %.4g", status.Limit, status.Value, status.Current),
+                        Timestamp:   report.UpdatedAt,
+                        Metadata: map[string]interface{}{
+                                "limit":   status.Limit,
+                                "value":   status.Value,
+                                "current": status.Current,
+                        },
+                }
+
+                alerts := append(e.alerts.alerts[portfolioID], alert)
+                if len(alerts) > maxAlertsPerPortfolio {
+                        alerts = alerts[len(alerts)-maxAlertsPerPortfolio:]
+                }
+                e.alerts.alerts[portfolioID] = alerts
+                raised = append(raised, *alert)
+        }
+        e.alerts.breached[portfolioID] = breached
+
+        for _, alert := range raised {
+                for _, handler := range e.alerts.handlers {
+                        go handler(alert)
+                }
+        }
+}
+
+// forgetAlerts drops the limits and alerts of a deleted portfolio. The engine's lock
+// must be held.
+func (e *PortfolioAnalyticsEngine) forgetAlerts(portfolioID string) {
+        delete(e.exposureLimits, portfolioID)
+
+        e.alerts.mutex.Lock()
+        defer e.alerts.mutex.Unlock()
+
+        delete(e.alerts.breached, portfolioID)
+        delete(e.alerts.alerts, portfolioID)
+}
+
+// exposureLimitReport is the internal implementation of GetExposureLimits. The
+// engine's lock must be held.
+func (e *PortfolioAnalyticsEngine) exposureLimitReport(portfolioID string) *ExposureLimitReport {
+        limits := e.exposureLimits[portfolioID]
+        report := &ExposureLimitReport{
+                PortfolioID: portfolioID,
+                Limits:      limits,
+                UpdatedAt:   time.Now(),
+        }
+
+        // Greeks are only needed, and only priced, when they are limited
+        delta, vega := 0.0, 0.0
+        if limits.MaxDelta > 0 || limits.MaxVega > 0 {
+                greeks := e.greeks.portfolioGreeks(portfolioID)
+                for _, symbolDelta := range greeks.DeltaBySymbol {
+                        delta = math.Max(delta, math.Abs(symbolDelta))
+                }
+                vega = math.Abs(greeks.Vega)
+        }
+
+        // Concentrations are shares of gross exposure
+        gross := 0.0
+        bySymbol := make(map[string]float64)
+        bySector := make(map[string]float64)
+        for _, position := range e.positions[portfolioID] {
+                if position.ExitTime != nil {
+                        continue
+                }
+
+                value := math.Abs(float64(position.Quantity) * position.CurrentPrice)
+                gross += value
+                bySymbol[position.Symbol] += value
+                bySector[e.referenceData.Classify(position.Symbol).Sector] += value
+        }
+
+        checks := []struct {
+                limit   string
+                value   float64
+                current float64
+        }{
+                {RiskLimitDelta, limits.MaxDelta, delta},
+                {RiskLimitVega, limits.MaxVega, vega},
+                {RiskLimitSectorConcentration, limits.MaxSectorConcentration, largestShare(bySector, gross)},
+                {RiskLimitConcentration, limits.MaxConcentration, largestShare(bySymbol, gross)},
+        }
+
+        for _, check := range checks {
+                if check.value <= 0 {
+                        continue
+                }
+
+                status := RiskLimitStatus{
+                        Limit:       check.limit,
+                        Value:       check.value,
+                        Current:     check.current,
+                        Utilization: check.current / check.value,
+                        Breached:    check.current > check.value,
+                }
+                report.Status = append(report.Status, status)
+                report.Breached = report.Breached || status.Breached
+        }
+
+        return report
+}
+
+// largestShare returns the largest of values as a share of total
+func largestShare(values map[string]float64, total float64) float64 {
+        if total == 0 {
+                return 0
+        }
+
+        largest := 0.0
+        for _, value := range values {
+                largest = math.Max(largest, value)
+        }
+
+        return largest / total
+}
@@ -0,0 +1,128 @@
+package portfolioanalytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExposureLimitsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits ExposureLimits
+		valid  bool
+	}{
+		{"No limits", ExposureLimits{}, true},
+		{"All limits", ExposureLimits{MaxDelta: 100, MaxVega: 50, MaxSectorConcentration: 0.4, MaxConcentration: 0.2}, true},
+		{"Negative delta", ExposureLimits{MaxDelta: -1}, false},
+		{"Concentration above one", ExposureLimits{MaxConcentration: 1.5}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.limits.Validate()
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestExposureLimitBreaches(t *testing.T) {
+	engine := NewPortfolioAnalyticsEngine(nil, 1)
+	require.NoError(t, engine.referenceData.Set([]Classification{
+		{Symbol: "HDFCBANK", Sector: "Financials"},
+		{Symbol: "ICICIBANK", Sector: "Financials"},
+		{Symbol: "INFY", Sector: "Information Technology"},
+	}))
+
+	position := func(symbol string, quantity int) *Position {
+		return &Position{ID: symbol, PortfolioID: "portfolio1", Symbol: symbol, Quantity: quantity, CurrentPrice: 10, TransactionType: "BUY"}
+	}
+	require.NoError(t, engine.AddPortfolio(&Portfolio{
+		ID:        "portfolio1",
+		Positions: []*Position{position("HDFCBANK", 60), position("ICICIBANK", 20), position("INFY", 20)},
+	}))
+	require.NoError(t, engine.SetExposureLimits("portfolio1", ExposureLimits{MaxDelta: 100, MaxSectorConcentration: 0.9, MaxConcentration: 0.5}))
+
+	raised := make(chan Alert, 10)
+	engine.AddAlertHandler(func(alert Alert) {
+		raised <- alert
+	})
+
+	// recalculate updates a position and recalculates the portfolio's risk
+	recalculate := func(symbol string, quantity int) {
+		require.NoError(t, engine.UpdatePosition(position(symbol, quantity)))
+		_, err := engine.CalculateRiskMetrics("portfolio1")
+		require.NoError(t, err)
+	}
+	limitsOf := func(alerts []Alert) []string {
+		var limits []string
+		for _, alert := range alerts {
+			limits = append(limits, alert.Metadata["limit"].(string))
+		}
+		return limits
+	}
+
+	// HDFCBANK is 60% of a gross exposure of 1000 and the financials 80%
+	report, err := engine.GetExposureLimits("portfolio1")
+	require.NoError(t, err)
+	require.Len(t, report.Status, 3)
+	assert.True(t, report.Breached)
+	for _, status := range report.Status {
+		switch status.Limit {
+		case RiskLimitDelta:
+			assert.InDelta(t, 60, status.Current, 1e-9)
+			assert.False(t, status.Breached)
+		case RiskLimitSectorConcentration:
+			assert.InDelta(t, 0.8, status.Current, 1e-9)
+			assert.False(t, status.Breached)
+		case RiskLimitConcentration:
+			assert.InDelta(t, 0.6, status.Current, 1e-9)
+			assert.InDelta(t, 1.2, status.Utilization, 1e-9)
+			assert.True(t, status.Breached)
+		}
+	}
+
+	_, err = engine.CalculateRiskMetrics("portfolio1")
+	require.NoError(t, err)
+	alerts := engine.GetAlerts("portfolio1")
+	require.Len(t, alerts, 1)
+	assert.Equal(t, AlertTypeLimitBreach, alerts[0].Type)
+	assert.Equal(t, AlertSeverityCritical, alerts[0].Severity)
+	assert.Equal(t, []string{RiskLimitConcentration}, limitsOf(alerts))
+
+	select {
+	case alert := <-raised:
+		assert.Equal(t, alerts[0].ID, alert.ID)
+	case <-time.After(time.Second):
+		t.Fatal("alert handler not called")
+	}
+
+	// An ongoing breach is not alerted again
+	recalculate("HDFCBANK", 70)
+	assert.Len(t, engine.GetAlerts("portfolio1"), 1)
+
+	// A second limit breached during an ongoing breach is alerted alone
+	recalculate("HDFCBANK", 120)
+	assert.Equal(t, []string{RiskLimitConcentration, RiskLimitDelta}, limitsOf(engine.GetAlerts("portfolio1")))
+
+	// Back within the limits nothing is alerted
+	recalculate("HDFCBANK", 30)
+	assert.Len(t, engine.GetAlerts("portfolio1"), 2)
+	report, err = engine.GetExposureLimits("portfolio1")
+	require.NoError(t, err)
+	assert.False(t, report.Breached)
+
+	// A limit breached again is alerted again
+	recalculate("HDFCBANK", 60)
+	assert.Equal(t, []string{RiskLimitConcentration, RiskLimitDelta, RiskLimitConcentration}, limitsOf(engine.GetAlerts("portfolio1")))
+
+	require.NoError(t, engine.AcknowledgeAlert("portfolio1", alerts[0].ID))
+	assert.True(t, engine.GetAlerts("portfolio1")[0].Acknowledged)
+	assert.Error(t, engine.AcknowledgeAlert("portfolio1", "alert-0"))
+}
@@ -0,0 +1,189 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "errors"
+        "math"
+        "time"
+)
+
+// FactorDefinition defines a factor as the return of a long index less that of a
+// short index, e.g. small caps over large caps for size
+type FactorDefinition struct {
+        Name       string
+        LongIndex  string
+        ShortIndex string
+}
+
+// Validate validates the factor definition
+func (f *FactorDefinition) Validate() error {
+        if f.Name == "" {
+                return errors.New("factor name is required")
+        }
+
+        if f.LongIndex == "" || f.ShortIndex == "" {
+                return errors.New("long and short indices are required for factor " + f.Name)
+        }
+
+        if f.LongIndex == f.ShortIndex {
+                return errors.New("long and short indices must differ for factor " + f.Name)
+        }
+
+        return nil
+}
+
+// DefaultFactors returns the size and momentum factors on NSE indices
+func DefaultFactors() []FactorDefinition {
+        return []FactorDefinition{
+                {Name: "SIZE", LongIndex: "NIFTY SMALLCAP 100", ShortIndex: "NIFTY 50"},
+                {Name: "MOMENTUM", LongIndex: "NIFTY200 MOMENTUM 30", ShortIndex: "NIFTY 200"},
+        }
+}
+
+// marketExposure is the sensitivity of a portfolio to the market and to factors
+type marketExposure struct {
+        BetaToMarket    float64
+        FactorExposures map[string]float64
+}
+
+// calculateMarketExposure regresses the daily returns of the open positions,
+// weighted by their share of gross exposure, on the returns of the market index.
+// BetaToMarket is the slope of that regression; factor exposures are the slopes of
+// a regression on the market and every factor together, so that they measure tilts
+// beyond what the market explains.
+func (e *PortfolioAnalyticsEngine) calculateMarketExposure(positions []*Position) (marketExposure, error) {
+        result := marketExposure{FactorExposures: make(map[string]float64)}
+
+        exposures, exchanges := openExposures(positions)
+        if len(exposures) == 0 || e.dataProvider == nil || e.riskConfig.MarketIndex == "" {
+                return result, nil
+        }
+
+        gross := 0.0
+        for _, exposure := range exposures {
+                gross += math.Abs(exposure)
+        }
+        if gross == 0 {
+                return result, nil
+        }
+
+        endDate := time.Now()
+        startDate := endDate.AddDate(0, 0, -e.riskConfig.LookbackDays)
+
+        factors := e.riskConfig.Factors
+        dates, returns, err := e.historicalReturns(context.Background(), e.indexSeries(exchanges, factors), startDate, endDate)
+        if err != nil && len(factors) > 0 {
+                // Factor indices may have no history, in which case only the beta is measured
+                factors = nil
+                dates, returns, err = e.historicalReturns(context.Background(), e.indexSeries(exchanges, nil), startDate, endDate)
+        }
+        if err != nil {
+                return result, err
+        }
+
+        // Too little history to estimate anything
+        if len(dates) <= len(factors)+1 {
+                return result, nil
+        }
+
+        portfolio := make([]float64, len(dates))
+        for symbol, exposure := range exposures {
+                for i, dailyReturn := range returns[symbol] {
+                        portfolio[i] += exposure / gross * dailyReturn
+                }
+        }
+
+        market := returns[e.riskConfig.MarketIndex]
+        if variance := sampleCovariance(market, market); variance > 0 {
+                result.BetaToMarket = sampleCovariance(portfolio, market) / variance
+        }
+
+        if len(factors) == 0 {
+                return result, nil
+        }
+
+        regressors := [][]float64{market}
+        for _, factor := range factors {
+                spread := make([]float64, len(dates))
+                for i := range dates {
+                        spread[i] = returns[factor.LongIndex][i] - returns[factor.ShortIndex][i]
+                }
+                regressors = append(regressors, spread)
+        }
+
+        slopes, ok := regressionSlopes(portfolio, regressors)
+        if !ok {
+                return result, nil
+        }
+
+        for i, factor := range e.riskConfig.Factors {
+                result.FactorExposures[factor.Name] = slopes[i+1]
+        }
+
+        return result, nil
+}
+
+// indexSeries adds the market index and the indices of factors to the symbols to
+// fetch, so that all their returns are aligned on the same dates
+func (e *PortfolioAnalyticsEngine) indexSeries(exchanges map[string]string, factors []FactorDefinition) map[string]string {
+        series := make(map[string]string, len(exchanges)+2*len(factors)+1)
+        for symbol, exchange := range exchanges {
+                series[symbol] = exchange
+        }
+
+        series[e.riskConfig.MarketIndex] = e.riskConfig.IndexExchange
+        for _, factor := range factors {
+                series[factor.LongIndex] = e.riskConfig.IndexExchange
+                series[factor.ShortIndex] = e.riskConfig.IndexExchange
+        }
+
+        return series
+}
+
+// regressionSlopes returns the slopes of an ordinary least squares regression of y
+// on the regressors with an intercept, or false when the regressors are collinear
+func regressionSlopes(y []float64, regressors [][]float64) ([]float64, bool) {
+        // With demeaned series the normal equations reduce to the covariance matrix
+        n := len(regressors)
+        system := make([][]float64, n)
+        for i := range regressors {
+                system[i] = make([]float64, n+1)
+                for j := range regressors {
+                        system[i][j] = sampleCovariance(regressors[i], regressors[j])
+                }
+                system[i][n] = sampleCovariance(regressors[i], y)
+        }
+
+        // Gaussian elimination with partial pivoting
+        for column := 0; column < n; column++ {
+                pivot := column
+                for row := column + 1; row < n; row++ {
+                        if math.Abs(system[row][column]) > math.Abs(system[pivot][column]) {
+                                pivot = row
+                        }
+                }
+
+                if math.Abs(system[pivot][column]) < 1e-18 {
+                        return nil, false
+                }
+                system[column], system[pivot] = system[pivot], system[column]
+
+                for row := column + 1; row < n; row++ {
+                        factor := system[row][column] / system[column][column]
+                        for k := column; k <= n; k++ {
+                                system[row][k] -= factor * system[column][k]
+                        }
+                }
+        }
+
+        slopes := make([]float64, n)
+        for row := n - 1; row >= 0; row-- {
+                value := system[row][n]
+                for k := row + 1; k < n; k++ {
+                        value -= system[row][k] * slopes[k]
+                }
+                slopes[row] = value / system[row][row]
+        }
+
+        return slopes, true
+}
@@ -0,0 +1,122 @@
+package portfolioanalytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegressionSlopes(t *testing.T) {
+	x1 := []float64{1, 2, 3, 4, 5}
+	x2 := []float64{2, -1, 0, 3, 1}
+
+	// y = 0.1 + 2 x1 + 0.5 x2
+	y := make([]float64, len(x1))
+	for i := range y {
+		y[i] = 0.1 + 2*x1[i] + 0.5*x2[i]
+	}
+
+	slopes, ok := regressionSlopes(y, [][]float64{x1, x2})
+	require.True(t, ok)
+	assert.InDelta(t, 2, slopes[0], 1e-9)
+	assert.InDelta(t, 0.5, slopes[1], 1e-9)
+
+	// A regressor that is a multiple of another cannot be told apart from it
+	_, ok = regressionSlopes(y, [][]float64{x1, {2, 4, 6, 8, 10}})
+	assert.False(t, ok)
+}
+
+func TestCalculateMarketExposure(t *testing.T) {
+	market := []float64{0.01, -0.02, 0.015, 0.005, -0.01, 0.02}
+	small := []float64{0.02, -0.01, 0.03, -0.005, 0, 0.01}
+
+	scaled := func(returns []float64, scale float64) []float64 {
+		result := make([]float64, len(returns))
+		for i, dailyReturn := range returns {
+			result[i] = scale * dailyReturn
+		}
+		return result
+	}
+
+	// Half the market and half small caps, a tilt of 0.5 to size over the market
+	tilted := make([]float64, len(market))
+	for i := range market {
+		tilted[i] = market[i] + 0.5*(small[i]-market[i])
+	}
+
+	provider := &stubDataProvider{prices: map[string]map[time.Time]float64{
+		"NIFTY 50":       dailyCloses(20000, market),
+		"NIFTY SMALLCAP": dailyCloses(15000, small),
+		"INDEXFUND":      dailyCloses(200, market),
+		"LEVERAGED":      dailyCloses(50, scaled(market, 2)),
+		"CASH":           dailyCloses(100, make([]float64, len(market))),
+		"TILTED":         dailyCloses(100, tilted),
+	}}
+
+	size := []FactorDefinition{{Name: "SIZE", LongIndex: "NIFTY SMALLCAP", ShortIndex: "NIFTY 50"}}
+
+	tests := []struct {
+		name      string
+		factors   []FactorDefinition
+		positions []*Position
+		beta      float64
+		exposures map[string]float64
+	}{
+		{
+			name:      "Copies the index",
+			positions: []*Position{{Symbol: "INDEXFUND", Quantity: 10, CurrentPrice: 200, TransactionType: "BUY"}},
+			beta:      1,
+		},
+		{
+			name:      "Twice the index",
+			positions: []*Position{{Symbol: "LEVERAGED", Quantity: 10, CurrentPrice: 50, TransactionType: "BUY"}},
+			beta:      2,
+		},
+		{
+			name:      "Short the index",
+			positions: []*Position{{Symbol: "INDEXFUND", Quantity: 10, CurrentPrice: 200, TransactionType: "SELL"}},
+			beta:      -1,
+		},
+		{
+			name: "Half in the index",
+			positions: []*Position{
+				{Symbol: "INDEXFUND", Quantity: 10, CurrentPrice: 200, TransactionType: "BUY"},
+				{Symbol: "CASH", Quantity: 20, CurrentPrice: 100, TransactionType: "BUY"},
+			},
+			beta: 0.5,
+		},
+		{
+			name:      "Tilted to size",
+			factors:   size,
+			positions: []*Position{{Symbol: "TILTED", Quantity: 10, CurrentPrice: 100, TransactionType: "BUY"}},
+			beta:      0.5 + 0.5*sampleCovariance(small, market)/sampleCovariance(market, market),
+			exposures: map[string]float64{"SIZE": 0.5},
+		},
+		{
+			name:      "Factor indices without history",
+			factors:   []FactorDefinition{{Name: "MOMENTUM", LongIndex: "NIFTY200 MOMENTUM 30", ShortIndex: "NIFTY 200"}},
+			positions: []*Position{{Symbol: "INDEXFUND", Quantity: 10, CurrentPrice: 200, TransactionType: "BUY"}},
+			beta:      1,
+			exposures: map[string]float64{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine := NewPortfolioAnalyticsEngine(provider, 1)
+			config := DefaultRiskConfig()
+			config.Factors = test.factors
+			require.NoError(t, engine.SetRiskConfig(config))
+
+			exposure, err := engine.calculateMarketExposure(test.positions)
+			require.NoError(t, err)
+			assert.InDelta(t, test.beta, exposure.BetaToMarket, 1e-9)
+			for name, expected := range test.exposures {
+				assert.InDelta(t, expected, exposure.FactorExposures[name], 1e-9, name)
+			}
+			assert.Len(t, exposure.FactorExposures, len(test.exposures))
+		})
+	}
+}
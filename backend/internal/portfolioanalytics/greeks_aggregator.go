@@ -0,0 +1,347 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "sort"
+        "sync"
+        "time"
+)
+
+const (
+        // GreeksScopePortfolio aggregates the greeks of a single portfolio
+        GreeksScopePortfolio = "PORTFOLIO"
+
+        // GreeksScopeAccount aggregates the greeks of every portfolio of a user
+        GreeksScopeAccount = "ACCOUNT"
+)
+
+// AggregateGreeks is the net greeks of a portfolio or account. Delta and gamma are in
+// units of the underlying, theta in money per day and vega in money per volatility point.
+type AggregateGreeks struct {
+        Scope         string
+        ID            string
+        Delta         float64
+        Gamma         float64
+        Theta         float64
+        Vega          float64
+        DeltaBySymbol map[string]float64 // Delta by underlying, what a hedge has to offset
+        UpdatedAt     time.Time
+}
+
+// greeksSubscription is a callback for the greeks of one portfolio or account
+type greeksSubscription struct {
+        scope    string
+        id       string
+        callback func(*AggregateGreeks)
+}
+
+// GreeksAggregator keeps portfolio and account greeks current with the market. Option
+// positions are repriced from their underlying's price on every tick instead of
+// waiting for the hourly risk calculation, and subscribers are pushed the new totals.
+type GreeksAggregator struct {
+        engine        *PortfolioAnalyticsEngine
+        mutex         sync.Mutex
+        prices        map[string]float64 // Last price by underlying symbol
+        volatilities  map[string]float64 // Implied volatility by position ID, looked up once
+        subscriptions map[string]*greeksSubscription
+        nextID        int
+}
+
+// NewGreeksAggregator creates a greeks aggregator over the engine's portfolios
+func NewGreeksAggregator(engine *PortfolioAnalyticsEngine) *GreeksAggregator {
+        return &GreeksAggregator{
+                engine:        engine,
+                prices:        make(map[string]float64),
+                volatilities:  make(map[string]float64),
+                subscriptions: make(map[string]*greeksSubscription),
+        }
+}
+
+// Symbols returns the underlyings of all open positions, the symbols whose ticks
+// should be fed to OnTick
+func (a *GreeksAggregator) Symbols() []string {
+        a.engine.mutex.RLock()
+        defer a.engine.mutex.RUnlock()
+
+        seen := make(map[string]bool)
+        var symbols []string
+        for _, positions := range a.engine.positions {
+                for _, position := range positions {
+                        if position.ExitTime == nil && !seen[position.Symbol] {
+                                seen[position.Symbol] = true
+                                symbols = append(symbols, position.Symbol)
+                        }
+                }
+        }
+        sort.Strings(symbols)
+
+        return symbols
+}
+
+// OnTick reprices the positions on symbol at price and pushes the greeks of every
+// portfolio holding it, and of those portfolios' accounts, to their subscribers
+func (a *GreeksAggregator) OnTick(symbol string, price float64) error {
+        if price <= 0 {
+                return fmt.Errorf("invalid price %f for %s", price, symbol)
+        }
+
+        a.mutex.Lock()
+        a.prices[symbol] = price
+        a.mutex.Unlock()
+
+        a.engine.mutex.RLock()
+        var updates []*AggregateGreeks
+        users := make(map[string]bool)
+        for portfolioID, positions := range a.engine.positions {
+                if !holds(positions, symbol) {
+                        continue
+                }
+
+                updates = append(updates, a.portfolioGreeks(portfolioID))
+                if userID := a.engine.portfolios[portfolioID].UserID; userID != "" {
+                        users[userID] = true
+                }
+        }
+        for userID := range users {
+                updates = append(updates, a.accountGreeks(userID))
+        }
+        a.engine.mutex.RUnlock()
+
+        a.publish(updates)
+        return nil
+}
+
+// GetGreeks returns the current greeks of a portfolio or account
+func (a *GreeksAggregator) GetGreeks(scope, id string) (*AggregateGreeks, error) {
+        a.engine.mutex.RLock()
+        defer a.engine.mutex.RUnlock()
+
+        switch scope {
+        case GreeksScopePortfolio:
+                if _, exists := a.engine.portfolios[id]; !exists {
+                        return nil, fmt.Errorf("portfolio with ID %s not found", id)
+                }
+                return a.portfolioGreeks(id), nil
+        case GreeksScopeAccount:
+                return a.accountGreeks(id), nil
+        }
+
+        return nil, fmt.Errorf("unknown greeks scope: %s", scope)
+}
+
+// HedgeQuantity returns the units of an underlying to buy, or sell when negative,
+// to bring a portfolio's delta in it to deltaTarget
+func (a *GreeksAggregator) HedgeQuantity(portfolioID, symbol string, deltaTarget float64) (float64, error) {
+        greeks, err := a.GetGreeks(GreeksScopePortfolio, portfolioID)
+        if err != nil {
+                return 0, err
+        }
+
+        return deltaTarget - greeks.DeltaBySymbol[symbol], nil
+}
+
+// Subscribe registers a callback for every update of a portfolio's or account's greeks
+func (a *GreeksAggregator) Subscribe(scope, id string, callback func(*AggregateGreeks)) (string, error) {
+        if scope != GreeksScopePortfolio && scope != GreeksScopeAccount {
+                return "", fmt.Errorf("unknown greeks scope: %s", scope)
+        }
+
+        if id == "" {
+                return "", errors.New("ID cannot be empty")
+        }
+
+        if callback == nil {
+                return "", errors.New("callback cannot be nil")
+        }
+
+        a.mutex.Lock()
+        defer a.mutex.Unlock()
+
+        a.nextID++
+        subscriptionID := fmt.Sprintf("greeks-%d", a.nextID)
+        a.subscriptions[subscriptionID] = &greeksSubscription{
+                scope:    scope,
+                id:       id,
+                callback: callback,
+        }
+
+        return subscriptionID, nil
+}
+
+// Unsubscribe removes a subscription
+func (a *GreeksAggregator) Unsubscribe(subscriptionID string) error {
+        a.mutex.Lock()
+        defer a.mutex.Unlock()
+
+        if _, exists := a.subscriptions[subscriptionID]; !exists {
+                return fmt.Errorf("subscription with ID %s not found", subscriptionID)
+        }
+
+        delete(a.subscriptions, subscriptionID)
+        return nil
+}
+
+// publish calls the callbacks subscribed to each update
+func (a *GreeksAggregator) publish(updates []*AggregateGreeks) {
+        var callbacks []func()
+
+        a.mutex.Lock()
+        for _, update := range updates {
+                for _, subscription := range a.subscriptions {
+                        if subscription.scope == update.Scope && subscription.id == update.ID {
+                                callback, update := subscription.callback, update
+                                callbacks = append(callbacks, func() { callback(update) })
+                        }
+                }
+        }
+        a.mutex.Unlock()
+
+        // Callbacks run without the lock so they can subscribe and unsubscribe
+        for _, call := range callbacks {
+                call()
+        }
+}
+
+// accountGreeks sums the greeks of a user's portfolios. The engine's lock must be held.
+func (a *GreeksAggregator) accountGreeks(userID string) *AggregateGreeks {
+        account := &AggregateGreeks{
+                Scope:         GreeksScopeAccount,
+                ID:            userID,
+                DeltaBySymbol: make(map[string]float64),
+                UpdatedAt:     time.Now(),
+        }
+
+        for portfolioID, portfolio := range a.engine.portfolios {
+                if portfolio.UserID != userID {
+                        continue
+                }
+
+                greeks := a.portfolioGreeks(portfolioID)
+                account.Delta += greeks.Delta
+                account.Gamma += greeks.Gamma
+                account.Theta += greeks.Theta
+                account.Vega += greeks.Vega
+                for symbol, delta := range greeks.DeltaBySymbol {
+                        account.DeltaBySymbol[symbol] += delta
+                }
+        }
+
+        return account
+}
+
+// portfolioGreeks sums the greeks of a portfolio's open positions. The engine's lock
+// must be held.
+func (a *GreeksAggregator) portfolioGreeks(portfolioID string) *AggregateGreeks {
+        return a.positionsGreeks(GreeksScopePortfolio, portfolioID, a.engine.positions[portfolioID], nil)
+}
+
+// positionsGreeks sums the greeks of open positions. Implied volatilities looked up
+// for transient positions, which are not held in the engine, are not remembered.
+// The engine's lock must be held.
+func (a *GreeksAggregator) positionsGreeks(scope, id string, positions []*Position, transient map[*Position]bool) *AggregateGreeks {
+        total := &AggregateGreeks{
+                Scope:         scope,
+                ID:            id,
+                DeltaBySymbol: make(map[string]float64),
+                UpdatedAt:     time.Now(),
+        }
+
+        ctx := context.Background()
+        for _, position := range positions {
+                if position.ExitTime != nil {
+                        continue
+                }
+
+                quantity := float64(position.Quantity)
+                if position.TransactionType == "SELL" {
+                        quantity = -quantity
+                }
+
+                greeks := a.unitGreeks(ctx, position, !transient[position])
+                total.Delta += quantity * greeks.Delta
+                total.Gamma += quantity * greeks.Gamma
+                total.Theta += quantity * greeks.Theta
+                total.Vega += quantity * greeks.Vega
+                total.DeltaBySymbol[position.Symbol] += quantity * greeks.Delta
+        }
+
+        return total
+}
+
+// unitGreeks returns the greeks of one unit of a position. Options are priced off the
+// last tick of their underlying and keep their stored greeks when they cannot be.
+func (a *GreeksAggregator) unitGreeks(ctx context.Context, position *Position, remember bool) *Greeks {
+        if position.OptionType == nil || position.StrikePrice == nil || position.ExpiryDate == nil {
+                return &Greeks{Delta: 1}
+        }
+
+        stored := position.Greeks
+        if stored == nil {
+                stored = &Greeks{}
+        }
+
+        spot, volatility, ok := a.pricingInputs(ctx, position, remember)
+        if !ok {
+                return stored
+        }
+
+        years := position.ExpiryDate.Sub(time.Now()).Hours() / 24 / 365
+        return blackScholesGreeks(*position.OptionType, spot, *position.StrikePrice, years, a.engine.riskConfig.RiskFreeRate, volatility)
+}
+
+// pricingInputs returns the underlying price and implied volatility of an option
+// position, looking up whichever has not been seen yet. The implied volatility is
+// only remembered when remember is set.
+func (a *GreeksAggregator) pricingInputs(ctx context.Context, position *Position, remember bool) (float64, float64, bool) {
+        a.mutex.Lock()
+        spot, hasSpot := a.prices[position.Symbol]
+        volatility, hasVolatility := a.volatilities[position.ID]
+        hasVolatility = hasVolatility && remember
+        a.mutex.Unlock()
+
+        if a.engine.dataProvider == nil && (!hasSpot || !hasVolatility) {
+                return 0, 0, false
+        }
+
+        if !hasSpot {
+                price, err := a.engine.dataProvider.GetCurrentPrice(ctx, position.Symbol, position.Exchange)
+                if err != nil || price <= 0 {
+                        return 0, 0, false
+                }
+                spot = price
+
+                a.mutex.Lock()
+                if _, exists := a.prices[position.Symbol]; !exists {
+                        a.prices[position.Symbol] = spot
+                }
+                a.mutex.Unlock()
+        }
+
+        if !hasVolatility {
+                implied, err := a.engine.impliedVolatility(ctx, position)
+                if err != nil {
+                        return 0, 0, false
+                }
+                volatility = implied
+
+                if remember {
+                        a.mutex.Lock()
+                        a.volatilities[position.ID] = volatility
+                        a.mutex.Unlock()
+                }
+        }
+
+        return spot, volatility, volatility > 0
+}
+
+// holds reports whether any open position is on symbol
+func holds(positions []*Position, symbol string) bool {
+        for _, position := range positions {
+                if position.ExitTime == nil && position.Symbol == symbol {
+                        return true
+                }
+        }
+        return false
+}
@@ -0,0 +1,30 @@
+package portfolioanalytics
+
+import "time"
+
+// MetricsUpdate carries the metrics of a portfolio the engine has just recalculated.
+// Only the metrics that were recalculated are set.
+type MetricsUpdate struct {
+        PortfolioID string
+        Performance *PerformanceMetrics
+        Risk        *RiskMetrics
+        UpdatedAt   time.Time
+}
+
+// AddMetricsListener registers a listener called with every metrics recalculation.
+// Listeners run on their own goroutine, so they may call back into the engine, and
+// use UpdatedAt to drop updates arriving out of order.
+func (e *PortfolioAnalyticsEngine) AddMetricsListener(listener func(*MetricsUpdate)) {
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        e.metricsListeners = append(e.metricsListeners, listener)
+}
+
+// publishMetrics passes recalculated metrics to the listeners. The engine's lock
+// must be held.
+func (e *PortfolioAnalyticsEngine) publishMetrics(update *MetricsUpdate) {
+        for _, listener := range e.metricsListeners {
+                go listener(update)
+        }
+}
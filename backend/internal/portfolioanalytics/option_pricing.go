@@ -0,0 +1,96 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "fmt"
+        "math"
+        "time"
+)
+
+// blackScholesPrice returns the Black-Scholes price of a European option. optionType
+// is CE or PE, years the time to expiry, and rate and volatility annual fractions.
+func blackScholesPrice(optionType string, spot, strike, years, rate, volatility float64) float64 {
+        if years <= 0 || volatility <= 0 {
+                if optionType == "PE" {
+                        return math.Max(strike-spot, 0)
+                }
+                return math.Max(spot-strike, 0)
+        }
+
+        deviation := volatility * math.Sqrt(years)
+        d1 := (math.Log(spot/strike) + (rate+volatility*volatility/2)*years) / deviation
+        d2 := d1 - deviation
+        discounted := strike * math.Exp(-rate*years)
+
+        if optionType == "PE" {
+                return discounted*normalCDF(-d2) - spot*normalCDF(-d1)
+        }
+        return spot*normalCDF(d1) - discounted*normalCDF(d2)
+}
+
+// normalCDF returns the standard normal cumulative distribution function at x
+func normalCDF(x float64) float64 {
+        return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// blackScholesGreeks returns the Black-Scholes greeks of one unit of a European option.
+// Theta is per calendar day, and vega and rho per percentage point of volatility and rate.
+func blackScholesGreeks(optionType string, spot, strike, years, rate, volatility float64) *Greeks {
+        greeks := &Greeks{UpdatedAt: time.Now()}
+
+        if years <= 0 || volatility <= 0 {
+                // At expiry the option moves one for one with the underlying when in the money
+                if optionType == "PE" && spot < strike {
+                        greeks.Delta = -1
+                } else if optionType != "PE" && spot > strike {
+                        greeks.Delta = 1
+                }
+                return greeks
+        }
+
+        deviation := volatility * math.Sqrt(years)
+        d1 := (math.Log(spot/strike) + (rate+volatility*volatility/2)*years) / deviation
+        d2 := d1 - deviation
+        discounted := strike * math.Exp(-rate*years)
+        density := math.Exp(-d1*d1/2) / math.Sqrt(2*math.Pi)
+        decay := -spot * density * volatility / (2 * math.Sqrt(years))
+
+        greeks.Gamma = density / (spot * deviation)
+        greeks.Vega = spot * density * math.Sqrt(years) / 100
+
+        if optionType == "PE" {
+                greeks.Delta = normalCDF(d1) - 1
+                greeks.Theta = (decay + rate*discounted*normalCDF(-d2)) / 365
+                greeks.Rho = -discounted * years * normalCDF(-d2) / 100
+        } else {
+                greeks.Delta = normalCDF(d1)
+                greeks.Theta = (decay - rate*discounted*normalCDF(d2)) / 365
+                greeks.Rho = discounted * years * normalCDF(d2) / 100
+        }
+
+        return greeks
+}
+
+// impliedVolatility returns an option position's implied volatility as an annual
+// fraction, taken from its option chain and falling back to the volatility index.
+// It is zero when neither is available.
+func (e *PortfolioAnalyticsEngine) impliedVolatility(ctx context.Context, position *Position) (float64, error) {
+        chain, err := e.dataProvider.GetOptionChain(ctx, position.Symbol, position.Exchange, *position.ExpiryDate)
+        if err != nil {
+                return 0, fmt.Errorf("failed to get option chain for %s: %w", position.Symbol, err)
+        }
+
+        for _, option := range chain {
+                if option.StrikePrice == *position.StrikePrice && option.OptionType == *position.OptionType && option.ImpliedVolatility > 0 {
+                        return option.ImpliedVolatility / 100, nil
+                }
+        }
+
+        // Fall back to the volatility index when the chain has no implied volatility
+        index, err := e.dataProvider.GetVolatilityIndex(ctx, position.Symbol)
+        if err == nil && index > 0 {
+                return index / 100, nil
+        }
+
+        return 0, nil
+}
@@ -0,0 +1,157 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "encoding/csv"
+        "errors"
+        "fmt"
+        "io"
+        "strings"
+        "sync"
+)
+
+const (
+        // unknownSector is the sector of symbols without a sector classification
+        unknownSector = "Unknown"
+
+        // unknownIndustry is the industry of symbols without an industry classification
+        unknownIndustry = "Unknown"
+)
+
+// Classification is the sector and industry a symbol belongs to
+type Classification struct {
+        Symbol   string
+        Sector   string
+        Industry string
+}
+
+// ClassificationProvider supplies symbol classifications, e.g. from an exchange or data vendor
+type ClassificationProvider interface {
+        GetClassifications(ctx context.Context) ([]Classification, error)
+}
+
+// ReferenceDataService maps symbols to their sector and industry
+type ReferenceDataService struct {
+        classifications map[string]Classification
+        mutex           sync.RWMutex
+}
+
+// NewReferenceDataService creates a new reference data service with no classifications
+func NewReferenceDataService() *ReferenceDataService {
+        return &ReferenceDataService{
+                classifications: make(map[string]Classification),
+        }
+}
+
+// LoadCSV loads classifications from CSV with a header row naming the symbol,
+// sector and, optionally, industry columns in any order. Loaded classifications
+// replace those already known for the same symbols.
+func (s *ReferenceDataService) LoadCSV(r io.Reader) error {
+        reader := csv.NewReader(r)
+        reader.TrimLeadingSpace = true
+
+        header, err := reader.Read()
+        if err != nil {
+                return fmt.Errorf("failed to read CSV header: %w", err)
+        }
+
+        columns := map[string]int{"symbol": -1, "sector": -1, "industry": -1}
+        for i, name := range header {
+                if _, exists := columns[strings.ToLower(strings.TrimSpace(name))]; exists {
+                        columns[strings.ToLower(strings.TrimSpace(name))] = i
+                }
+        }
+
+        if columns["symbol"] == -1 || columns["sector"] == -1 {
+                return errors.New("CSV must have symbol and sector columns")
+        }
+
+        var classifications []Classification
+        for {
+                record, err := reader.Read()
+                if err == io.EOF {
+                        break
+                }
+                if err != nil {
+                        return fmt.Errorf("failed to read CSV: %w", err)
+                }
+
+                classification := Classification{
+                        Symbol: strings.TrimSpace(record[columns["symbol"]]),
+                        Sector: strings.TrimSpace(record[columns["sector"]]),
+                }
+                if columns["industry"] != -1 {
+                        classification.Industry = strings.TrimSpace(record[columns["industry"]])
+                }
+
+                classifications = append(classifications, classification)
+        }
+
+        return s.Set(classifications)
+}
+
+// LoadFromProvider loads classifications from a provider. Loaded classifications
+// replace those already known for the same symbols.
+func (s *ReferenceDataService) LoadFromProvider(ctx context.Context, provider ClassificationProvider) error {
+        classifications, err := provider.GetClassifications(ctx)
+        if err != nil {
+                return fmt.Errorf("failed to get classifications: %w", err)
+        }
+
+        return s.Set(classifications)
+}
+
+// Set adds or replaces the classifications of symbols
+func (s *ReferenceDataService) Set(classifications []Classification) error {
+        for _, classification := range classifications {
+                if classification.Symbol == "" {
+                        return errors.New("symbol is required")
+                }
+        }
+
+        s.mutex.Lock()
+        defer s.mutex.Unlock()
+
+        for _, classification := range classifications {
+                s.classifications[classification.Symbol] = classification
+        }
+
+        return nil
+}
+
+// Classify returns the classification of a symbol, with unknownSector and
+// unknownIndustry for anything not known
+func (s *ReferenceDataService) Classify(symbol string) Classification {
+        s.mutex.RLock()
+        defer s.mutex.RUnlock()
+
+        classification, exists := s.classifications[symbol]
+        if !exists {
+                classification.Symbol = symbol
+        }
+
+        if classification.Sector == "" {
+                classification.Sector = unknownSector
+        }
+        if classification.Industry == "" {
+                classification.Industry = unknownIndustry
+        }
+
+        return classification
+}
+
+// SetReferenceData sets the service classifying symbols for sector and industry
+// exposure and attribution. Without one every symbol is unclassified.
+func (e *PortfolioAnalyticsEngine) SetReferenceData(referenceData *ReferenceDataService) {
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        if referenceData == nil {
+                referenceData = NewReferenceDataService()
+        }
+        e.referenceData = referenceData
+
+        // Sector breakdowns of every portfolio are stale
+        e.riskCache = make(map[string]*RiskMetrics)
+        e.attributionCache = make(map[string]map[string]*PerformanceAttribution)
+}
@@ -0,0 +1,87 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "fmt"
+        "sort"
+        "time"
+)
+
+// openExposures returns the signed market value of the open positions by symbol,
+// negative for short positions, and the exchange each symbol trades on
+func openExposures(positions []*Position) (map[string]float64, map[string]string) {
+        exposures := make(map[string]float64)
+        exchanges := make(map[string]string)
+
+        for _, position := range positions {
+                if position.ExitTime != nil {
+                        // Skip closed positions
+                        continue
+                }
+
+                value := float64(position.Quantity) * position.CurrentPrice
+                if position.TransactionType == "SELL" {
+                        value = -value
+                }
+
+                exposures[position.Symbol] += value
+                exchanges[position.Symbol] = position.Exchange
+        }
+
+        return exposures, exchanges
+}
+
+// historicalReturns fetches daily prices for each symbol between startDate and endDate
+// and returns the daily returns on the dates every symbol has a return for, oldest first
+func (e *PortfolioAnalyticsEngine) historicalReturns(ctx context.Context, exchanges map[string]string, startDate, endDate time.Time) ([]time.Time, map[string][]float64, error) {
+        returnsByDate := make(map[string]map[time.Time]float64)
+        dateCounts := make(map[time.Time]int)
+
+        for symbol, exchange := range exchanges {
+                prices, err := e.dataProvider.GetHistoricalPrices(ctx, symbol, exchange, startDate, endDate, "1d")
+                if err != nil {
+                        return nil, nil, fmt.Errorf("failed to get historical prices for %s: %w", symbol, err)
+                }
+
+                dates := make([]time.Time, 0, len(prices))
+                for date := range prices {
+                        dates = append(dates, date)
+                }
+                sort.Slice(dates, func(i, j int) bool {
+                        return dates[i].Before(dates[j])
+                })
+
+                returns := make(map[time.Time]float64)
+                for i := 1; i < len(dates); i++ {
+                        previous := prices[dates[i-1]]
+                        if previous <= 0 {
+                                continue
+                        }
+
+                        returns[dates[i]] = prices[dates[i]]/previous - 1
+                        dateCounts[dates[i]]++
+                }
+                returnsByDate[symbol] = returns
+        }
+
+        var dates []time.Time
+        for date, count := range dateCounts {
+                if count == len(exchanges) {
+                        dates = append(dates, date)
+                }
+        }
+        sort.Slice(dates, func(i, j int) bool {
+                return dates[i].Before(dates[j])
+        })
+
+        returns := make(map[string][]float64, len(returnsByDate))
+        for symbol, byDate := range returnsByDate {
+                series := make([]float64, len(dates))
+                for i, date := range dates {
+                        series[i] = byDate[date]
+                }
+                returns[symbol] = series
+        }
+
+        return dates, returns, nil
+}
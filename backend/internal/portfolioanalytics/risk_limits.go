@@ -0,0 +1,132 @@
+package portfolioanalytics
+
+import (
+        "errors"
+        "math"
+        "time"
+)
+
+// Risk limit names
+const (
+        RiskLimitGrossExposure = "GROSS_EXPOSURE"
+        RiskLimitValueAtRisk   = "VALUE_AT_RISK"
+        RiskLimitDelta         = "DELTA"
+        RiskLimitConcentration = "CONCENTRATION"
+)
+
+// RiskLimits are the limits an account's combined risk is held to. A zero limit is
+// not enforced.
+type RiskLimits struct {
+        MaxGrossExposure float64
+        MaxValueAtRisk   float64 // Sum of the portfolios' value at risk
+        MaxDelta         float64 // Absolute net delta in any one underlying
+        MaxConcentration float64 // Largest share of gross exposure in one symbol, a fraction
+}
+
+// Validate checks the risk limits
+func (l RiskLimits) Validate() error {
+        if l.MaxGrossExposure < 0 || l.MaxValueAtRisk < 0 || l.MaxDelta < 0 || l.MaxConcentration < 0 {
+                return errors.New("risk limits must not be negative")
+        }
+
+        if l.MaxConcentration > 1 {
+                return errors.New("concentration limit must be at most 1")
+        }
+
+        return nil
+}
+
+// RiskLimitStatus is how much of one risk limit is in use
+type RiskLimitStatus struct {
+        Limit       string
+        Value       float64
+        Current     float64
+        Utilization float64 // Current as a fraction of Value
+        Breached    bool
+}
+
+// RiskLimitReport compares an account's risk to its limits
+type RiskLimitReport struct {
+        AccountID string
+        Limits    RiskLimits
+        Status    []RiskLimitStatus // Enforced limits only
+        Breached  bool
+        UpdatedAt time.Time
+}
+
+// SetRiskLimits sets the risk limits of an account
+func (e *PortfolioAnalyticsEngine) SetRiskLimits(accountID string, limits RiskLimits) error {
+        if accountID == "" {
+                return errors.New("account ID cannot be empty")
+        }
+
+        if err := limits.Validate(); err != nil {
+                return err
+        }
+
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        e.riskLimits[accountID] = limits
+        return nil
+}
+
+// GetRiskLimits returns an account's risk limits and how much of each is in use
+func (e *PortfolioAnalyticsEngine) GetRiskLimits(accountID string) (*RiskLimitReport, error) {
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        limits := e.riskLimits[accountID]
+        report := &RiskLimitReport{
+                AccountID: accountID,
+                Limits:    limits,
+                UpdatedAt: time.Now(),
+        }
+
+        exposure := e.accountExposure(accountID)
+
+        valueAtRisk := 0.0
+        if limits.MaxValueAtRisk > 0 {
+                for _, portfolioID := range exposure.PortfolioIDs {
+                        metrics, err := e.riskMetrics(portfolioID)
+                        if err != nil {
+                                return nil, err
+                        }
+                        valueAtRisk += metrics.ValueAtRisk
+                }
+        }
+
+        delta := 0.0
+        for _, symbolDelta := range exposure.Greeks.DeltaBySymbol {
+                delta = math.Max(delta, math.Abs(symbolDelta))
+        }
+
+        checks := []struct {
+                limit   string
+                value   float64
+                current float64
+        }{
+                {RiskLimitGrossExposure, limits.MaxGrossExposure, exposure.GrossExposure},
+                {RiskLimitValueAtRisk, limits.MaxValueAtRisk, valueAtRisk},
+                {RiskLimitDelta, limits.MaxDelta, delta},
+                {RiskLimitConcentration, limits.MaxConcentration, exposure.largestConcentration()},
+        }
+
+        for _, check := range checks {
+                if check.value <= 0 {
+                        continue
+                }
+
+                status := RiskLimitStatus{
+                        Limit:       check.limit,
+                        Value:       check.value,
+                        Current:     check.current,
+                        Utilization: check.current / check.value,
+                        Breached:    check.current > check.value,
+                }
+                report.Status = append(report.Status, status)
+                report.Breached = report.Breached || status.Breached
+        }
+
+        return report, nil
+}
@@ -0,0 +1,96 @@
+package portfolioanalytics
+
+import (
+        "fmt"
+        "math"
+)
+
+// tradingDaysPerYear annualizes daily statistics
+const tradingDaysPerYear = 252
+
+// rollingWindows are the lengths, in trading days, of the rolling performance windows
+var rollingWindows = []int{30, 90}
+
+// dailyReturns returns the day-on-day returns of capital plus the cumulative P&L
+// of curve. Each return belongs to the date of the same index in curve; the first
+// is always zero.
+func dailyReturns(curve []equityPoint, capital float64) []float64 {
+        returns := make([]float64, len(curve))
+        for i := 1; i < len(curve); i++ {
+                if previous := capital + curve[i-1].pnl; previous > 0 {
+                        returns[i] = (curve[i].pnl - curve[i-1].pnl) / previous
+                }
+        }
+        return returns
+}
+
+// returnStatistics returns the annualized volatility, in percent, and the
+// annualized Sharpe and Sortino ratios of daily returns against an annual risk-free
+// rate. Ratios are zero when the returns do not vary.
+func returnStatistics(returns []float64, riskFreeRate float64) (volatility, sharpe, sortino float64) {
+        if len(returns) < 2 {
+                return 0, 0, 0
+        }
+
+        dailyRiskFree := riskFreeRate / tradingDaysPerYear
+        var meanExcess, downside float64
+        for _, dailyReturn := range returns {
+                excess := dailyReturn - dailyRiskFree
+                meanExcess += excess
+                if excess < 0 {
+                        downside += excess * excess
+                }
+        }
+        meanExcess /= float64(len(returns))
+        downside = math.Sqrt(downside / float64(len(returns)))
+
+        deviation := math.Sqrt(sampleCovariance(returns, returns))
+        annualization := math.Sqrt(tradingDaysPerYear)
+
+        volatility = deviation * annualization * 100
+        if deviation > 0 {
+                sharpe = meanExcess / deviation * annualization
+        }
+        if downside > 0 {
+                sortino = meanExcess / downside * annualization
+        }
+
+        return volatility, sharpe, sortino
+}
+
+// rollingPerformance computes the Sharpe ratio, Sortino ratio and volatility of
+// every rolling window ending on each date of curve with a full window of returns.
+// It returns the values of the latest windows and the series by metric and date,
+// both keyed as SHARPE_30D, SORTINO_30D, VOLATILITY_30D and so on.
+func rollingPerformance(curve []equityPoint, capital, riskFreeRate float64) (map[string]float64, map[string]map[string]float64) {
+        latest := make(map[string]float64)
+        series := make(map[string]map[string]float64)
+
+        returns := dailyReturns(curve, capital)
+
+        for _, window := range rollingWindows {
+                sharpeKey := fmt.Sprintf("SHARPE_%dD", window)
+                sortinoKey := fmt.Sprintf("SORTINO_%dD", window)
+                volatilityKey := fmt.Sprintf("VOLATILITY_%dD", window)
+
+                series[sharpeKey] = make(map[string]float64)
+                series[sortinoKey] = make(map[string]float64)
+                series[volatilityKey] = make(map[string]float64)
+
+                // Windows start after the first return, which is always zero
+                for end := window + 1; end <= len(returns); end++ {
+                        volatility, sharpe, sortino := returnStatistics(returns[end-window:end], riskFreeRate)
+                        date := curve[end-1].date.Format("2006-01-02")
+
+                        series[sharpeKey][date] = sharpe
+                        series[sortinoKey][date] = sortino
+                        series[volatilityKey][date] = volatility
+
+                        latest[sharpeKey] = sharpe
+                        latest[sortinoKey] = sortino
+                        latest[volatilityKey] = volatility
+                }
+        }
+
+        return latest, series
+}
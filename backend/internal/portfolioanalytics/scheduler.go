@@ -0,0 +1,215 @@
+package portfolioanalytics
+
+import (
+        "errors"
+        "fmt"
+        "sort"
+        "sync"
+        "time"
+
+        "github.com/robfig/cron/v3"
+)
+
+// Cadences at which scheduled analytics tasks run
+const (
+        CadenceRealtime = "REALTIME"
+        CadenceMinute   = "MINUTE"
+        CadenceEndOfDay = "EOD"
+)
+
+// scheduledTasks is the order tasks are queued in on a tick shared by several of
+// them, so equity is snapshot and metrics are calculated from freshly updated
+// prices and greeks
+var scheduledTasks = []string{"update_prices", "update_greeks", "snapshot_equity", "risk", "performance"}
+
+// SchedulerConfig configures how often each analytics task is run for active portfolios
+type SchedulerConfig struct {
+        Cadences         map[string]string // Cadence by task type; task types left out are not scheduled
+        RealtimeInterval time.Duration     // Interval of the REALTIME cadence
+        EndOfDayTime     string            // Time of the EOD cadence on weekdays, HH:MM
+        Timezone         string            // Timezone of EndOfDayTime
+}
+
+// DefaultSchedulerConfig returns the default schedule: greeks in real time, prices
+// and risk every minute, and equity snapshots and performance after the close
+func DefaultSchedulerConfig() SchedulerConfig {
+        return SchedulerConfig{
+                Cadences: map[string]string{
+                        "update_prices":   CadenceMinute,
+                        "update_greeks":   CadenceRealtime,
+                        "snapshot_equity": CadenceEndOfDay,
+                        "risk":            CadenceMinute,
+                        "performance":     CadenceEndOfDay,
+                },
+                RealtimeInterval: 5 * time.Second,
+                EndOfDayTime:     "15:45",
+                Timezone:         "Asia/Kolkata",
+        }
+}
+
+// Validate checks the scheduler configuration
+func (c SchedulerConfig) Validate() error {
+        for taskType, cadence := range c.Cadences {
+                known := false
+                for _, scheduled := range scheduledTasks {
+                        if taskType == scheduled {
+                                known = true
+                                break
+                        }
+                }
+                if !known {
+                        return fmt.Errorf("unknown task type: %s", taskType)
+                }
+
+                switch cadence {
+                case CadenceRealtime, CadenceMinute, CadenceEndOfDay:
+                default:
+                        return fmt.Errorf("unknown cadence %s for %s", cadence, taskType)
+                }
+        }
+
+        if c.RealtimeInterval <= 0 || c.RealtimeInterval > time.Minute {
+                return errors.New("realtime interval must be positive and at most a minute")
+        }
+
+        if _, err := time.Parse("15:04", c.EndOfDayTime); err != nil {
+                return fmt.Errorf("invalid end of day time: %s", c.EndOfDayTime)
+        }
+
+        if _, err := time.LoadLocation(c.Timezone); err != nil {
+                return fmt.Errorf("invalid timezone: %s", c.Timezone)
+        }
+
+        return nil
+}
+
+// AnalyticsScheduler queues analytics tasks for every active portfolio at their
+// configured cadences, keeping prices, greeks and metrics current without callers
+// having to request them
+type AnalyticsScheduler struct {
+        engine    *PortfolioAnalyticsEngine
+        config    SchedulerConfig
+        location  *time.Location
+        scheduler *cron.Cron
+        mutex     sync.Mutex
+        isRunning bool
+        dropped   int
+}
+
+// NewAnalyticsScheduler creates a scheduler for the engine's portfolios
+func NewAnalyticsScheduler(engine *PortfolioAnalyticsEngine, config SchedulerConfig) (*AnalyticsScheduler, error) {
+        if err := config.Validate(); err != nil {
+                return nil, err
+        }
+
+        // Validate has already checked the timezone
+        location, _ := time.LoadLocation(config.Timezone)
+
+        return &AnalyticsScheduler{
+                engine:   engine,
+                config:   config,
+                location: location,
+        }, nil
+}
+
+// Start starts queuing tasks. The engine must be running for them to be processed.
+func (s *AnalyticsScheduler) Start() error {
+        s.mutex.Lock()
+        defer s.mutex.Unlock()
+
+        if s.isRunning {
+                return errors.New("analytics scheduler is already running")
+        }
+
+        // Validate has already checked the time
+        closing, _ := time.Parse("15:04", s.config.EndOfDayTime)
+        specs := map[string]string{
+                CadenceRealtime: fmt.Sprintf("@every %s", s.config.RealtimeInterval),
+                CadenceMinute:   "@every 1m",
+                CadenceEndOfDay: fmt.Sprintf("%d %d * * 1-5", closing.Minute(), closing.Hour()),
+        }
+
+        s.scheduler = cron.New(cron.WithLocation(s.location))
+        for cadence, spec := range specs {
+                cadence := cadence
+                if _, err := s.scheduler.AddFunc(spec, func() { s.RunCadence(cadence) }); err != nil {
+                        return fmt.Errorf("failed to schedule %s tasks: %w", cadence, err)
+                }
+        }
+
+        s.scheduler.Start()
+        s.isRunning = true
+
+        return nil
+}
+
+// Stop stops queuing tasks; tasks already queued are still processed
+func (s *AnalyticsScheduler) Stop() {
+        s.mutex.Lock()
+        defer s.mutex.Unlock()
+
+        if !s.isRunning {
+                return
+        }
+
+        s.scheduler.Stop()
+        s.isRunning = false
+}
+
+// RunCadence queues the tasks of a cadence for every active portfolio right away
+// and returns how many were queued. Tasks that do not fit in the engine's queue are
+// dropped, to be picked up again on the next run.
+func (s *AnalyticsScheduler) RunCadence(cadence string) int {
+        var taskTypes []string
+        for _, taskType := range scheduledTasks {
+                if s.config.Cadences[taskType] == cadence {
+                        taskTypes = append(taskTypes, taskType)
+                }
+        }
+
+        if len(taskTypes) == 0 {
+                return 0
+        }
+
+        queued := 0
+        for _, portfolioID := range s.engine.activePortfolios() {
+                for _, taskType := range taskTypes {
+                        if err := s.engine.QueueTask(taskType, portfolioID, nil); err != nil {
+                                s.mutex.Lock()
+                                s.dropped++
+                                s.mutex.Unlock()
+                                continue
+                        }
+                        queued++
+                }
+        }
+
+        return queued
+}
+
+// Dropped returns how many tasks could not be queued since the scheduler was created
+func (s *AnalyticsScheduler) Dropped() int {
+        s.mutex.Lock()
+        defer s.mutex.Unlock()
+
+        return s.dropped
+}
+
+// activePortfolios returns the IDs of portfolios with at least one open position
+func (e *PortfolioAnalyticsEngine) activePortfolios() []string {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        var portfolioIDs []string
+        for portfolioID, positions := range e.positions {
+                for _, position := range positions {
+                        if position.ExitTime == nil {
+                                portfolioIDs = append(portfolioIDs, portfolioID)
+                                break
+                        }
+                }
+        }
+        sort.Strings(portfolioIDs)
+
+        return portfolioIDs
+}
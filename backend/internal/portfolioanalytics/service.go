@@ -0,0 +1,195 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "time"
+)
+
+// GetPortfolio returns a portfolio
+func (s *ServiceImpl) GetPortfolio(ctx context.Context, portfolioID string) (*Portfolio, error) {
+        return s.engine.GetPortfolio(portfolioID)
+}
+
+// GetRiskMetrics returns the risk metrics of a portfolio
+func (s *ServiceImpl) GetRiskMetrics(ctx context.Context, portfolioID string) (*RiskMetrics, error) {
+        return s.engine.CalculateRiskMetrics(portfolioID)
+}
+
+// GetPerformanceAttribution returns a portfolio's P&L by strategy, symbol and sector over a date range
+func (s *ServiceImpl) GetPerformanceAttribution(ctx context.Context, portfolioID string, startDate, endDate time.Time) (*PerformanceAttribution, error) {
+        return s.engine.GetPerformanceAttribution(portfolioID, startDate, endDate)
+}
+
+// GetDrawdownAnalysis returns a portfolio's drawdowns and underwater curve over a date range
+func (s *ServiceImpl) GetDrawdownAnalysis(ctx context.Context, portfolioID string, startDate, endDate time.Time) (*DrawdownAnalysis, error) {
+        return s.engine.GetDrawdownAnalysis(portfolioID, startDate, endDate)
+}
+
+// GetCorrelationAnalysis returns the correlation analysis of a portfolio
+func (s *ServiceImpl) GetCorrelationAnalysis(ctx context.Context, portfolioID string) (*CorrelationAnalysis, error) {
+        return s.engine.GetCorrelationAnalysis(portfolioID)
+}
+
+// GetAccountExposure returns the combined exposure of an account's portfolios
+func (s *ServiceImpl) GetAccountExposure(ctx context.Context, accountID string) (*AccountExposure, error) {
+        return s.engine.GetAccountExposure(accountID)
+}
+
+// GetRiskLimits returns an account's risk limits and their utilization
+func (s *ServiceImpl) GetRiskLimits(ctx context.Context, accountID string) (*RiskLimitReport, error) {
+        return s.engine.GetRiskLimits(accountID)
+}
+
+// SetRiskLimits sets an account's risk limits
+func (s *ServiceImpl) SetRiskLimits(ctx context.Context, accountID string, limits RiskLimits) error {
+        return s.engine.SetRiskLimits(accountID, limits)
+}
+
+// WhatIf projects a portfolio's risk after hypothetical changes without making them
+func (s *ServiceImpl) WhatIf(ctx context.Context, portfolioID string, changes []WhatIfChange) (*WhatIfAnalysis, error) {
+        return s.engine.WhatIf(portfolioID, changes)
+}
+
+// GetTaxLotReport returns an account's open tax lots and the gains it realized over a date range
+func (s *ServiceImpl) GetTaxLotReport(ctx context.Context, accountID string, startDate, endDate time.Time) (*TaxLotReport, error) {
+        return s.engine.GetTaxLotReport(accountID, startDate, endDate)
+}
+
+// SetLotMatchingMethod sets how an account's sales are matched to its tax lots
+func (s *ServiceImpl) SetLotMatchingMethod(ctx context.Context, accountID, method string) error {
+        return s.engine.SetLotMatchingMethod(accountID, method)
+}
+
+// GetConsolidatedView returns the combined view of a user's portfolios in an environment
+func (s *ServiceImpl) GetConsolidatedView(ctx context.Context, userID, environment string) (*ConsolidatedView, error) {
+        return s.engine.GetConsolidatedView(userID, environment)
+}
+
+// GetBrokerAccountView returns a user's consolidated view of one broker account
+func (s *ServiceImpl) GetBrokerAccountView(ctx context.Context, userID, environment, brokerAccount string) (*ConsolidatedView, error) {
+        return s.engine.GetBrokerAccountView(userID, environment, brokerAccount)
+}
+
+// GetConsolidatedPortfolio returns a user's consolidated view of one portfolio
+func (s *ServiceImpl) GetConsolidatedPortfolio(ctx context.Context, userID, portfolioID string) (*ConsolidatedView, error) {
+        return s.engine.GetConsolidatedPortfolio(userID, portfolioID)
+}
+
+// GetExposureLimits returns a portfolio's exposure limits and their utilization
+func (s *ServiceImpl) GetExposureLimits(ctx context.Context, portfolioID string) (*ExposureLimitReport, error) {
+        return s.engine.GetExposureLimits(portfolioID)
+}
+
+// SetExposureLimits sets the exposure limits a portfolio is alerted on
+func (s *ServiceImpl) SetExposureLimits(ctx context.Context, portfolioID string, limits ExposureLimits) error {
+        return s.engine.SetExposureLimits(portfolioID, limits)
+}
+
+// GetAlerts returns the alerts raised for a portfolio, oldest first
+func (s *ServiceImpl) GetAlerts(ctx context.Context, portfolioID string) ([]Alert, error) {
+        return s.engine.GetAlerts(portfolioID), nil
+}
+
+// AcknowledgeAlert marks one of a portfolio's alerts as seen
+func (s *ServiceImpl) AcknowledgeAlert(ctx context.Context, portfolioID, alertID string) error {
+        return s.engine.AcknowledgeAlert(portfolioID, alertID)
+}
+
+// GetEquityCurve returns a portfolio's daily equity snapshots, oldest first
+func (s *ServiceImpl) GetEquityCurve(ctx context.Context, portfolioID string) ([]EquitySnapshot, error) {
+        return s.engine.GetEquityCurve(portfolioID)
+}
+
+// SetRiskFreeRate sets the annual rate Sharpe and Sortino ratios are measured against
+func (s *ServiceImpl) SetRiskFreeRate(ctx context.Context, rate float64) error {
+        return s.engine.SetRiskFreeRate(rate)
+}
+
+// SubscribeToUpdates registers a callback for a portfolio's metrics. Every time the
+// engine recalculates them the callback is passed the MetricsUpdate as JSON.
+func (s *ServiceImpl) SubscribeToUpdates(portfolioID string, callback func(interface{})) (string, error) {
+        if portfolioID == "" {
+                return "", errors.New("portfolio ID cannot be empty")
+        }
+
+        if callback == nil {
+                return "", errors.New("callback cannot be nil")
+        }
+
+        s.mutex.Lock()
+        defer s.mutex.Unlock()
+
+        if s.subscribers[portfolioID] == nil {
+                s.subscribers[portfolioID] = make(map[string]func(interface{}))
+        }
+
+        s.nextID++
+        subscriptionID := fmt.Sprintf("metrics-%d", s.nextID)
+        s.subscribers[portfolioID][subscriptionID] = callback
+
+        return subscriptionID, nil
+}
+
+// UnsubscribeFromUpdates removes a metrics subscription
+func (s *ServiceImpl) UnsubscribeFromUpdates(subscriptionID string) error {
+        s.mutex.Lock()
+        defer s.mutex.Unlock()
+
+        for portfolioID, callbacks := range s.subscribers {
+                if _, exists := callbacks[subscriptionID]; !exists {
+                        continue
+                }
+
+                delete(callbacks, subscriptionID)
+                if len(callbacks) == 0 {
+                        delete(s.subscribers, portfolioID)
+                }
+                return nil
+        }
+
+        return fmt.Errorf("subscription with ID %s not found", subscriptionID)
+}
+
+// publishUpdate passes recalculated metrics to the portfolio's subscribers
+func (s *ServiceImpl) publishUpdate(update *MetricsUpdate) {
+        s.mutex.Lock()
+        callbacks := make([]func(interface{}), 0, len(s.subscribers[update.PortfolioID]))
+        for _, callback := range s.subscribers[update.PortfolioID] {
+                callbacks = append(callbacks, callback)
+        }
+        s.mutex.Unlock()
+
+        if len(callbacks) == 0 {
+                return
+        }
+
+        data, err := json.Marshal(update)
+        if err != nil {
+                return
+        }
+
+        // Callbacks run without the lock so they can subscribe and unsubscribe
+        for _, callback := range callbacks {
+                callback(json.RawMessage(data))
+        }
+}
+
+// GetGreeks returns the current greeks of a portfolio or account
+func (s *ServiceImpl) GetGreeks(ctx context.Context, scope, id string) (*AggregateGreeks, error) {
+        return s.engine.Greeks().GetGreeks(scope, id)
+}
+
+// SubscribeToGreeks subscribes to the greeks of a portfolio or account, updated on every tick of their underlyings
+func (s *ServiceImpl) SubscribeToGreeks(scope, id string, callback func(interface{})) (string, error) {
+        return s.engine.Greeks().Subscribe(scope, id, func(greeks *AggregateGreeks) {
+                callback(greeks)
+        })
+}
+
+// UnsubscribeFromGreeks removes a greeks subscription
+func (s *ServiceImpl) UnsubscribeFromGreeks(subscriptionID string) error {
+        return s.engine.Greeks().Unsubscribe(subscriptionID)
+}
@@ -0,0 +1,199 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "time"
+)
+
+// StressScenario is a set of market shocks applied to a portfolio at once
+type StressScenario struct {
+        Name            string
+        PriceShock      float64            // Relative move of every underlying, e.g. -0.05 for a 5% fall
+        SymbolShocks    map[string]float64 // Relative moves of specific underlyings, overriding PriceShock
+        VolatilityShock float64            // Relative change in implied volatility, e.g. 0.2 for IV up 20%
+        RateShock       float64            // Absolute change in the risk-free rate, e.g. 0.01 for +100bp
+}
+
+// Validate validates the stress scenario
+func (s *StressScenario) Validate() error {
+        if s.Name == "" {
+                return errors.New("scenario name is required")
+        }
+
+        if s.PriceShock <= -1 || s.VolatilityShock <= -1 {
+                return errors.New("price and volatility shocks must be greater than -100%")
+        }
+
+        for symbol, shock := range s.SymbolShocks {
+                if shock <= -1 {
+                        return errors.New("price shock must be greater than -100% for " + symbol)
+                }
+        }
+
+        return nil
+}
+
+// StressTestResult is the outcome of one stress scenario
+type StressTestResult struct {
+        Scenario        string
+        PnL             float64
+        PositionImpacts map[string]float64 // P&L by position ID
+}
+
+// DefaultStressScenarios returns the predefined index, implied volatility and rate shocks
+func DefaultStressScenarios() []StressScenario {
+        return []StressScenario{
+                {Name: "INDEX_UP_5", PriceShock: 0.05},
+                {Name: "INDEX_DOWN_5", PriceShock: -0.05},
+                {Name: "INDEX_UP_10", PriceShock: 0.10},
+                {Name: "INDEX_DOWN_10", PriceShock: -0.10},
+                {Name: "IV_UP_20", VolatilityShock: 0.20},
+                {Name: "IV_DOWN_20", VolatilityShock: -0.20},
+                {Name: "RATES_UP_100BP", RateShock: 0.01},
+                {Name: "RATES_DOWN_100BP", RateShock: -0.01},
+                {Name: "CRASH", PriceShock: -0.10, VolatilityShock: 0.50},
+        }
+}
+
+// stressPosition holds what is needed to revalue an open position under a scenario
+type stressPosition struct {
+        position   *Position
+        quantity   float64 // Negative for short positions
+        isOption   bool
+        spot       float64 // Underlying price, 0 when unknown
+        years      float64 // Time to expiry
+        volatility float64 // Implied volatility as a fraction, 0 when unknown
+}
+
+// RunStressTest applies scenarios to the open positions of a portfolio and returns
+// the P&L of each scenario by position. Without scenarios, the predefined scenarios
+// and those of the risk configuration are run.
+func (e *PortfolioAnalyticsEngine) RunStressTest(portfolioID string, scenarios []StressScenario) ([]*StressTestResult, error) {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        if _, exists := e.portfolios[portfolioID]; !exists {
+                return nil, fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+
+        if len(scenarios) == 0 {
+                scenarios = e.stressScenarios()
+        }
+
+        for _, scenario := range scenarios {
+                if err := scenario.Validate(); err != nil {
+                        return nil, err
+                }
+        }
+
+        return e.stressTest(context.Background(), e.positions[portfolioID], scenarios)
+}
+
+// stressScenarios returns the predefined scenarios followed by the configured ones
+func (e *PortfolioAnalyticsEngine) stressScenarios() []StressScenario {
+        return append(DefaultStressScenarios(), e.riskConfig.StressScenarios...)
+}
+
+// stressTest revalues the open positions under each scenario. Options are repriced
+// with Black-Scholes when their underlying price and implied volatility are known,
+// with a delta-gamma-rho approximation from their greeks otherwise, and moved with
+// their underlying when neither is available.
+func (e *PortfolioAnalyticsEngine) stressTest(ctx context.Context, positions []*Position, scenarios []StressScenario) ([]*StressTestResult, error) {
+        var held []*stressPosition
+        for _, position := range positions {
+                if position.ExitTime != nil {
+                        // Skip closed positions
+                        continue
+                }
+
+                stressed, err := e.newStressPosition(ctx, position)
+                if err != nil {
+                        return nil, err
+                }
+                held = append(held, stressed)
+        }
+
+        results := make([]*StressTestResult, 0, len(scenarios))
+        for _, scenario := range scenarios {
+                result := &StressTestResult{
+                        Scenario:        scenario.Name,
+                        PositionImpacts: make(map[string]float64, len(held)),
+                }
+
+                for _, stressed := range held {
+                        impact := stressed.impact(scenario, e.riskConfig.RiskFreeRate)
+                        result.PositionImpacts[stressed.position.ID] += impact
+                        result.PnL += impact
+                }
+
+                results = append(results, result)
+        }
+
+        return results, nil
+}
+
+// newStressPosition looks up the market data needed to revalue a position
+func (e *PortfolioAnalyticsEngine) newStressPosition(ctx context.Context, position *Position) (*stressPosition, error) {
+        stressed := &stressPosition{
+                position: position,
+                quantity: float64(position.Quantity),
+        }
+        if position.TransactionType == "SELL" {
+                stressed.quantity = -stressed.quantity
+        }
+
+        if position.OptionType == nil || position.StrikePrice == nil || position.ExpiryDate == nil {
+                return stressed, nil
+        }
+
+        stressed.isOption = true
+        stressed.years = position.ExpiryDate.Sub(time.Now()).Hours() / 24 / 365
+        if e.dataProvider == nil {
+                return stressed, nil
+        }
+
+        // Option positions are held against their underlying's symbol
+        spot, err := e.dataProvider.GetCurrentPrice(ctx, position.Symbol, position.Exchange)
+        if err != nil {
+                return nil, fmt.Errorf("failed to get underlying price for %s: %w", position.Symbol, err)
+        }
+        stressed.spot = spot
+
+        volatility, err := e.impliedVolatility(ctx, position)
+        if err != nil {
+                return nil, err
+        }
+        stressed.volatility = volatility
+
+        return stressed, nil
+}
+
+// impact returns the P&L of the position under a scenario
+func (p *stressPosition) impact(scenario StressScenario, rate float64) float64 {
+        shock := scenario.PriceShock
+        if symbolShock, exists := scenario.SymbolShocks[p.position.Symbol]; exists {
+                shock = symbolShock
+        }
+
+        if !p.isOption {
+                return p.quantity * p.position.CurrentPrice * shock
+        }
+
+        option := p.position
+        if p.spot > 0 && p.volatility > 0 && p.years > 0 {
+                before := blackScholesPrice(*option.OptionType, p.spot, *option.StrikePrice, p.years, rate, p.volatility)
+                after := blackScholesPrice(*option.OptionType, p.spot*(1+shock), *option.StrikePrice, p.years, rate+scenario.RateShock, p.volatility*(1+scenario.VolatilityShock))
+                return p.quantity * (after - before)
+        }
+
+        if option.Greeks != nil && p.spot > 0 {
+                // Greeks are per unit, with rho per percentage point of rates
+                move := p.spot * shock
+                change := option.Greeks.Delta*move + option.Greeks.Gamma*move*move/2 + option.Greeks.Rho*scenario.RateShock*100
+                return p.quantity * change
+        }
+
+        return p.quantity * option.CurrentPrice * shock
+}
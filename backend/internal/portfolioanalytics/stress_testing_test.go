@@ -0,0 +1,208 @@
+package portfolioanalytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// spotDataProvider serves a current price for every underlying and a volatility index
+type spotDataProvider struct {
+	stubDataProvider
+	spot            float64
+	volatilityIndex float64
+}
+
+func (p *spotDataProvider) GetCurrentPrice(ctx context.Context, symbol string, exchange string) (float64, error) {
+	return p.spot, nil
+}
+
+func (p *spotDataProvider) GetVolatilityIndex(ctx context.Context, symbol string) (float64, error) {
+	return p.volatilityIndex, nil
+}
+
+// stressPnL returns the P&L of each scenario by name
+func stressPnL(results []*StressTestResult) map[string]float64 {
+	pnl := make(map[string]float64, len(results))
+	for _, result := range results {
+		pnl[result.Scenario] = result.PnL
+	}
+	return pnl
+}
+
+func TestRunStressTestPredefinedScenarios(t *testing.T) {
+	exitTime := time.Now()
+	engine := NewPortfolioAnalyticsEngine(nil, 1)
+	require.NoError(t, engine.AddPortfolio(&Portfolio{
+		ID: "portfolio1",
+		Positions: []*Position{
+			{ID: "long", Symbol: "RELIANCE", Quantity: 10, CurrentPrice: 100, TransactionType: "BUY"},
+			{ID: "short", Symbol: "INFY", Quantity: 2, CurrentPrice: 200, TransactionType: "SELL"},
+			{ID: "closed", Symbol: "TCS", Quantity: 100, CurrentPrice: 100, TransactionType: "BUY", ExitTime: &exitTime},
+		},
+	}))
+
+	results, err := engine.RunStressTest("portfolio1", nil)
+	require.NoError(t, err)
+	require.Len(t, results, len(DefaultStressScenarios()))
+
+	// A move of the index makes 1000 of longs and loses on 400 of shorts
+	tests := []struct {
+		scenario string
+		pnl      float64
+	}{
+		{"INDEX_UP_5", 30},
+		{"INDEX_DOWN_5", -30},
+		{"INDEX_UP_10", 60},
+		{"INDEX_DOWN_10", -60},
+		{"IV_UP_20", 0}, // No options to reprice
+		{"IV_DOWN_20", 0},
+		{"RATES_UP_100BP", 0},
+		{"RATES_DOWN_100BP", 0},
+		{"CRASH", -60},
+	}
+
+	pnl := stressPnL(results)
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			assert.InDelta(t, test.pnl, pnl[test.scenario], 1e-9)
+		})
+	}
+
+	// Closed positions are not stressed
+	for _, result := range results {
+		assert.NotContains(t, result.PositionImpacts, "closed")
+	}
+	assert.InDelta(t, -100, results[3].PositionImpacts["long"], 1e-9)
+	assert.InDelta(t, 40, results[3].PositionImpacts["short"], 1e-9)
+}
+
+func TestRunStressTestUserScenarios(t *testing.T) {
+	engine := NewPortfolioAnalyticsEngine(nil, 1)
+	require.NoError(t, engine.AddPortfolio(&Portfolio{
+		ID: "portfolio1",
+		Positions: []*Position{
+			{ID: "bank", Symbol: "HDFCBANK", Quantity: 10, CurrentPrice: 100, TransactionType: "BUY"},
+			{ID: "energy", Symbol: "RELIANCE", Quantity: 10, CurrentPrice: 100, TransactionType: "BUY"},
+		},
+	}))
+
+	// The bank falls 20% and the rest of the market 2%
+	banksDown := StressScenario{Name: "BANKS_DOWN", PriceShock: -0.02, SymbolShocks: map[string]float64{"HDFCBANK": -0.20}}
+
+	t.Run("Configured scenarios run after the predefined ones", func(t *testing.T) {
+		config := DefaultRiskConfig()
+		config.StressScenarios = []StressScenario{banksDown}
+		require.NoError(t, engine.SetRiskConfig(config))
+
+		results, err := engine.RunStressTest("portfolio1", nil)
+		require.NoError(t, err)
+		require.Len(t, results, len(DefaultStressScenarios())+1)
+
+		result := results[len(results)-1]
+		assert.Equal(t, "BANKS_DOWN", result.Scenario)
+		assert.InDelta(t, -220, result.PnL, 1e-9)
+		assert.InDelta(t, -200, result.PositionImpacts["bank"], 1e-9)
+		assert.InDelta(t, -20, result.PositionImpacts["energy"], 1e-9)
+	})
+
+	t.Run("Requested scenarios run alone", func(t *testing.T) {
+		results, err := engine.RunStressTest("portfolio1", []StressScenario{banksDown, {Name: "BANKS_UP", SymbolShocks: map[string]float64{"HDFCBANK": 0.10}}})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]float64{"BANKS_DOWN": -220, "BANKS_UP": 100}, stressPnL(results))
+	})
+
+	t.Run("Invalid scenario", func(t *testing.T) {
+		_, err := engine.RunStressTest("portfolio1", []StressScenario{{Name: "WIPEOUT", SymbolShocks: map[string]float64{"HDFCBANK": -1}}})
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown portfolio", func(t *testing.T) {
+		_, err := engine.RunStressTest("portfolio2", nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestRunStressTestOptions(t *testing.T) {
+	call := "CE"
+	strike := 100.0
+	expiry := time.Now().AddDate(0, 0, 365)
+
+	tests := []struct {
+		name     string
+		provider *spotDataProvider
+		greeks   *Greeks
+		pnl      map[string]float64
+	}{
+		{
+			// An at-the-money call a year from expiry at 20% volatility and 6.5% rates
+			name:     "Repriced with Black-Scholes",
+			provider: &spotDataProvider{spot: 100, volatilityIndex: 20},
+			pnl: map[string]float64{
+				"INDEX_DOWN_10":  50 * -5.65269278474846,
+				"IV_UP_20":       50 * 1.4693009897304279,
+				"RATES_UP_100BP": 50 * 0.5582079949211476,
+				"CRASH":          50 * -2.0669129803540116,
+			},
+		},
+		{
+			// Without implied volatility: delta 0.5, gamma 0.01 and rho 0.2 per point
+			name:     "Approximated from greeks",
+			provider: &spotDataProvider{spot: 100},
+			greeks:   &Greeks{Delta: 0.5, Gamma: 0.01, Rho: 0.2},
+			pnl: map[string]float64{
+				"INDEX_DOWN_10":  50 * (0.5*-10 + 0.01*100/2),
+				"IV_UP_20":       0,
+				"RATES_UP_100BP": 50 * 0.2,
+				"CRASH":          50 * (0.5*-10 + 0.01*100/2),
+			},
+		},
+		{
+			// Without an underlying price the premium moves with the underlying
+			name:     "Moved with the underlying",
+			provider: nil,
+			greeks:   &Greeks{Delta: 0.5},
+			pnl: map[string]float64{
+				"INDEX_DOWN_10":  50 * 12 * -0.10,
+				"IV_UP_20":       0,
+				"RATES_UP_100BP": 0,
+				"CRASH":          50 * 12 * -0.10,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var provider DataProvider
+			if test.provider != nil {
+				provider = test.provider
+			}
+			engine := NewPortfolioAnalyticsEngine(provider, 1)
+			require.NoError(t, engine.AddPortfolio(&Portfolio{
+				ID: "portfolio1",
+				Positions: []*Position{{
+					ID:              "option",
+					Symbol:          "NIFTY",
+					Quantity:        50,
+					CurrentPrice:    12,
+					TransactionType: "BUY",
+					OptionType:      &call,
+					StrikePrice:     &strike,
+					ExpiryDate:      &expiry,
+					Greeks:          test.greeks,
+				}},
+			}))
+
+			results, err := engine.RunStressTest("portfolio1", nil)
+			require.NoError(t, err)
+
+			pnl := stressPnL(results)
+			for scenario, expected := range test.pnl {
+				assert.InDelta(t, expected, pnl[scenario], 1e-4, scenario)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package portfolioanalytics
+
+import (
+        "errors"
+        "fmt"
+        "sort"
+        "time"
+)
+
+// Lot matching methods
+const (
+        LotMatchingFIFO    = "FIFO"
+        LotMatchingLIFO    = "LIFO"
+        LotMatchingAverage = "AVERAGE"
+)
+
+// longTermHoldingDays is the holding period beyond which a gain is long term
+const longTermHoldingDays = 365
+
+// TaxLot is the quantity of an instrument acquired, or sold short, when a position was opened
+type TaxLot struct {
+        ID                string // ID of the position that opened the lot
+        PortfolioID       string
+        Symbol            string
+        Instrument        string // Symbol, with expiry, strike and option type for derivatives
+        Short             bool
+        Quantity          int
+        RemainingQuantity int
+        CostBasis         float64 // Per unit; the sale price for short lots
+        AcquiredAt        time.Time
+        UnrealizedGain    float64 // Of the remaining quantity at the instrument's current price
+}
+
+// RealizedGain is the gain realized by closing all or part of a tax lot
+type RealizedGain struct {
+        LotID       string
+        ClosedBy    string // ID of the position whose trade closed the lot
+        Symbol      string
+        Instrument  string
+        Short       bool
+        Quantity    int
+        CostBasis   float64 // Total
+        Proceeds    float64 // Total
+        Gain        float64
+        AcquiredAt  time.Time
+        ClosedAt    time.Time
+        HoldingDays int
+        LongTerm    bool
+}
+
+// TaxLotReport lists an account's open tax lots and the gains it realized over a date range
+type TaxLotReport struct {
+        AccountID     string
+        Method        string
+        StartDate     time.Time
+        EndDate       time.Time
+        OpenLots      []*TaxLot
+        RealizedGains []*RealizedGain
+        ShortTermGain float64
+        LongTermGain  float64
+        TotalGain     float64
+        UpdatedAt     time.Time
+}
+
+// lotTransaction is a trade that opens or closes tax lots
+type lotTransaction struct {
+        position *Position
+        sell     bool
+        price    float64
+        time     time.Time
+        closing  bool // Whether this is the trade closing position
+}
+
+// lotBook holds the open lots of one instrument, oldest first. All open lots are
+// long or all short, as a trade closes opposite lots before opening new ones.
+type lotBook struct {
+        method string
+        lots   []*TaxLot
+}
+
+// SetLotMatchingMethod sets how an account's sales are matched to its tax lots
+func (e *PortfolioAnalyticsEngine) SetLotMatchingMethod(accountID, method string) error {
+        if accountID == "" {
+                return errors.New("account ID cannot be empty")
+        }
+
+        switch method {
+        case LotMatchingFIFO, LotMatchingLIFO, LotMatchingAverage:
+        default:
+                return fmt.Errorf("unknown lot matching method: %s", method)
+        }
+
+        e.mutex.Lock()
+        defer e.mutex.Unlock()
+
+        e.lotMethods[accountID] = method
+        return nil
+}
+
+// GetTaxLotReport replays the trades of an account's positions through its lot
+// matching method, FIFO unless set otherwise, and reports the lots still open and
+// the gains realized between startDate and endDate
+func (e *PortfolioAnalyticsEngine) GetTaxLotReport(accountID string, startDate, endDate time.Time) (*TaxLotReport, error) {
+        if !endDate.After(startDate) {
+                return nil, errors.New("end date must be after start date")
+        }
+
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        method := e.lotMethods[accountID]
+        if method == "" {
+                method = LotMatchingFIFO
+        }
+
+        report := &TaxLotReport{
+                AccountID: accountID,
+                Method:    method,
+                StartDate: startDate,
+                EndDate:   endDate,
+                UpdatedAt: time.Now(),
+        }
+
+        var transactions []lotTransaction
+        prices := make(map[string]float64) // Current price by instrument
+        for portfolioID, portfolio := range e.portfolios {
+                if portfolio.UserID != accountID {
+                        continue
+                }
+
+                for _, position := range e.positions[portfolioID] {
+                        if position.ExitTime == nil && position.CurrentPrice > 0 {
+                                prices[instrumentKey(position)] = position.CurrentPrice
+                        }
+
+                        transactions = append(transactions, lotTransaction{
+                                position: position,
+                                sell:     position.TransactionType == "SELL",
+                                price:    position.EntryPrice,
+                                time:     position.EntryTime,
+                        })
+
+                        if position.ExitTime != nil && position.ExitPrice != nil {
+                                transactions = append(transactions, lotTransaction{
+                                        position: position,
+                                        sell:     position.TransactionType != "SELL",
+                                        price:    *position.ExitPrice,
+                                        time:     *position.ExitTime,
+                                        closing:  true,
+                                })
+                        }
+                }
+        }
+
+        // Replay trades in time order, opening trades first when times are equal
+        sort.SliceStable(transactions, func(i, j int) bool {
+                if !transactions[i].time.Equal(transactions[j].time) {
+                        return transactions[i].time.Before(transactions[j].time)
+                }
+                if transactions[i].closing != transactions[j].closing {
+                        return !transactions[i].closing
+                }
+                return transactions[i].position.ID < transactions[j].position.ID
+        })
+
+        books := make(map[string]*lotBook)
+        for _, transaction := range transactions {
+                instrument := instrumentKey(transaction.position)
+                book, exists := books[instrument]
+                if !exists {
+                        book = &lotBook{method: method}
+                        books[instrument] = book
+                }
+
+                for _, gain := range book.apply(transaction, instrument) {
+                        if gain.ClosedAt.Before(startDate) || !gain.ClosedAt.Before(endDate) {
+                                continue
+                        }
+
+                        report.RealizedGains = append(report.RealizedGains, gain)
+                        if gain.LongTerm {
+                                report.LongTermGain += gain.Gain
+                        } else {
+                                report.ShortTermGain += gain.Gain
+                        }
+                }
+        }
+        report.TotalGain = report.ShortTermGain + report.LongTermGain
+
+        instruments := make([]string, 0, len(books))
+        for instrument := range books {
+                instruments = append(instruments, instrument)
+        }
+        sort.Strings(instruments)
+
+        for _, instrument := range instruments {
+                for _, lot := range books[instrument].lots {
+                        if price, exists := prices[instrument]; exists {
+                                lot.UnrealizedGain = lot.unrealizedGain(price)
+                        }
+                        report.OpenLots = append(report.OpenLots, lot)
+                }
+        }
+
+        return report, nil
+}
+
+// apply matches a trade against the book's opposite lots and opens a lot with the
+// quantity left over, returning the gains realized
+func (b *lotBook) apply(transaction lotTransaction, instrument string) []*RealizedGain {
+        position := transaction.position
+        remaining := position.Quantity
+
+        // Average cost matches lots in FIFO order for holding periods but uses their
+        // average cost as the basis
+        average := 0.0
+        if b.method == LotMatchingAverage {
+                quantity := 0
+                for _, lot := range b.lots {
+                        average += lot.CostBasis * float64(lot.RemainingQuantity)
+                        quantity += lot.RemainingQuantity
+                }
+                if quantity > 0 {
+                        average /= float64(quantity)
+                }
+        }
+
+        var gains []*RealizedGain
+        for remaining > 0 && len(b.lots) > 0 && b.lots[0].Short != transaction.sell {
+                index := 0
+                if b.method == LotMatchingLIFO {
+                        index = len(b.lots) - 1
+                }
+                lot := b.lots[index]
+
+                quantity := lot.RemainingQuantity
+                if remaining < quantity {
+                        quantity = remaining
+                }
+
+                basis := lot.CostBasis
+                if b.method == LotMatchingAverage {
+                        basis = average
+                }
+
+                gain := &RealizedGain{
+                        LotID:       lot.ID,
+                        ClosedBy:    position.ID,
+                        Symbol:      lot.Symbol,
+                        Instrument:  instrument,
+                        Short:       lot.Short,
+                        Quantity:    quantity,
+                        CostBasis:   basis * float64(quantity),
+                        Proceeds:    transaction.price * float64(quantity),
+                        AcquiredAt:  lot.AcquiredAt,
+                        ClosedAt:    transaction.time,
+                        HoldingDays: int(transaction.time.Sub(lot.AcquiredAt).Hours() / 24),
+                }
+                if lot.Short {
+                        // Short lots were sold when opened and are bought back now
+                        gain.CostBasis, gain.Proceeds = gain.Proceeds, gain.CostBasis
+                }
+                gain.Gain = gain.Proceeds - gain.CostBasis
+                gain.LongTerm = gain.HoldingDays > longTermHoldingDays
+                gains = append(gains, gain)
+
+                lot.RemainingQuantity -= quantity
+                if lot.RemainingQuantity == 0 {
+                        b.lots = append(b.lots[:index], b.lots[index+1:]...)
+                }
+                remaining -= quantity
+        }
+
+        if remaining > 0 {
+                lot := &TaxLot{
+                        ID:                position.ID,
+                        PortfolioID:       position.PortfolioID,
+                        Symbol:            position.Symbol,
+                        Instrument:        instrument,
+                        Short:             transaction.sell,
+                        Quantity:          remaining,
+                        RemainingQuantity: remaining,
+                        CostBasis:         transaction.price,
+                        AcquiredAt:        transaction.time,
+                }
+                b.lots = append(b.lots, lot)
+        }
+
+        return gains
+}
+
+// unrealizedGain returns the gain on the lot's remaining quantity at price
+func (l *TaxLot) unrealizedGain(price float64) float64 {
+        gain := (price - l.CostBasis) * float64(l.RemainingQuantity)
+        if l.Short {
+                return -gain
+        }
+        return gain
+}
+
+// instrumentKey identifies the instrument a position is in, distinguishing the
+// contracts of derivatives on the same underlying
+func instrumentKey(position *Position) string {
+        key := position.Symbol
+        if position.ExpiryDate != nil {
+                key += " " + position.ExpiryDate.Format("2006-01-02")
+        }
+        if position.StrikePrice != nil && position.OptionType != nil {
+                key += fmt.Sprintf(" %g %s", *position.StrikePrice, *position.OptionType)
+        }
+        return key
+}
@@ -0,0 +1,243 @@
+package portfolioanalytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLotBookApply(t *testing.T) {
+	day := func(i int) time.Time { return time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC).AddDate(0, 0, i) }
+	long := func(id string, quantity int, basis float64, acquired time.Time) *TaxLot {
+		return &TaxLot{ID: id, Symbol: "X", Quantity: quantity, RemainingQuantity: quantity, CostBasis: basis, AcquiredAt: acquired}
+	}
+	short := func(id string, quantity int, basis float64, acquired time.Time) *TaxLot {
+		lot := long(id, quantity, basis, acquired)
+		lot.Short = true
+		return lot
+	}
+	trade := func(id string, sell bool, quantity int, price float64, at time.Time) lotTransaction {
+		return lotTransaction{position: &Position{ID: id, Symbol: "X", Quantity: quantity}, sell: sell, price: price, time: at}
+	}
+
+	type gain struct {
+		lotID     string
+		quantity  int
+		costBasis float64
+		proceeds  float64
+		longTerm  bool
+	}
+	type remainder struct {
+		id       string
+		short    bool
+		quantity int
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		lots   []*TaxLot
+		trade  lotTransaction
+		gains  []gain
+		open   []remainder
+	}{
+		{
+			name:   "FIFO consumes part of the oldest lot",
+			method: LotMatchingFIFO,
+			lots:   []*TaxLot{long("b1", 10, 100, day(0)), long("b2", 10, 120, day(10))},
+			trade:  trade("s", true, 4, 130, day(20)),
+			gains:  []gain{{"b1", 4, 400, 520, false}},
+			open:   []remainder{{"b1", false, 6}, {"b2", false, 10}},
+		},
+		{
+			name:   "FIFO spans lots",
+			method: LotMatchingFIFO,
+			lots:   []*TaxLot{long("b1", 10, 100, day(0)), long("b2", 10, 120, day(10))},
+			trade:  trade("s", true, 15, 130, day(20)),
+			gains:  []gain{{"b1", 10, 1000, 1300, false}, {"b2", 5, 600, 650, false}},
+			open:   []remainder{{"b2", false, 5}},
+		},
+		{
+			name:   "LIFO consumes the newest lot first",
+			method: LotMatchingLIFO,
+			lots:   []*TaxLot{long("b1", 10, 100, day(0)), long("b2", 10, 120, day(10))},
+			trade:  trade("s", true, 15, 130, day(20)),
+			gains:  []gain{{"b2", 10, 1200, 1300, false}, {"b1", 5, 500, 650, false}},
+			open:   []remainder{{"b1", false, 5}},
+		},
+		{
+			// The average cost of 20 units is 110, while holding periods follow FIFO
+			name:   "Average cost basis",
+			method: LotMatchingAverage,
+			lots:   []*TaxLot{long("b1", 10, 100, day(0)), long("b2", 10, 120, day(10))},
+			trade:  trade("s", true, 15, 130, day(20)),
+			gains:  []gain{{"b1", 10, 1100, 1300, false}, {"b2", 5, 550, 650, false}},
+			open:   []remainder{{"b2", false, 5}},
+		},
+		{
+			name:   "Selling more than is held opens a short lot",
+			method: LotMatchingFIFO,
+			lots:   []*TaxLot{long("b1", 10, 100, day(0))},
+			trade:  trade("s", true, 15, 130, day(20)),
+			gains:  []gain{{"b1", 10, 1000, 1300, false}},
+			open:   []remainder{{"s", true, 5}},
+		},
+		{
+			// Short lots were sold when opened, so buying back costs the trade price
+			name:   "Buying covers a short lot",
+			method: LotMatchingFIFO,
+			lots:   []*TaxLot{short("s", 5, 50, day(0))},
+			trade:  trade("b", false, 3, 40, day(5)),
+			gains:  []gain{{"s", 3, 120, 150, false}},
+			open:   []remainder{{"s", true, 2}},
+		},
+		{
+			name:   "Buying with no lots opens a long lot",
+			method: LotMatchingFIFO,
+			trade:  trade("b", false, 10, 100, day(0)),
+			open:   []remainder{{"b", false, 10}},
+		},
+		{
+			name:   "Buying adds to long lots",
+			method: LotMatchingFIFO,
+			lots:   []*TaxLot{long("b1", 10, 100, day(0))},
+			trade:  trade("b2", false, 5, 110, day(1)),
+			open:   []remainder{{"b1", false, 10}, {"b2", false, 5}},
+		},
+		{
+			name:   "Held for a year is short term",
+			method: LotMatchingFIFO,
+			lots:   []*TaxLot{long("b1", 10, 100, day(0))},
+			trade:  trade("s", true, 10, 130, day(0).AddDate(0, 0, longTermHoldingDays)),
+			gains:  []gain{{"b1", 10, 1000, 1300, false}},
+		},
+		{
+			name:   "Held for more than a year is long term",
+			method: LotMatchingFIFO,
+			lots:   []*TaxLot{long("b1", 10, 100, day(0))},
+			trade:  trade("s", true, 10, 130, day(0).AddDate(0, 0, longTermHoldingDays+1)),
+			gains:  []gain{{"b1", 10, 1000, 1300, true}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			book := &lotBook{method: test.method, lots: test.lots}
+			realized := book.apply(test.trade, "X")
+
+			if assert.Len(t, realized, len(test.gains)) {
+				for i, expected := range test.gains {
+					assert.Equal(t, expected.lotID, realized[i].LotID)
+					assert.Equal(t, test.trade.position.ID, realized[i].ClosedBy)
+					assert.Equal(t, expected.quantity, realized[i].Quantity)
+					assert.InDelta(t, expected.costBasis, realized[i].CostBasis, 1e-9)
+					assert.InDelta(t, expected.proceeds, realized[i].Proceeds, 1e-9)
+					assert.InDelta(t, expected.proceeds-expected.costBasis, realized[i].Gain, 1e-9)
+					assert.Equal(t, expected.longTerm, realized[i].LongTerm)
+				}
+			}
+
+			var open []remainder
+			for _, lot := range book.lots {
+				open = append(open, remainder{lot.ID, lot.Short, lot.RemainingQuantity})
+			}
+			assert.Equal(t, test.open, open)
+		})
+	}
+}
+
+func TestTaxLotReport(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 0, 0, 0, time.UTC)
+	}
+	cover := 40.0
+
+	engine := NewPortfolioAnalyticsEngine(nil, 1)
+	require.NoError(t, engine.AddPortfolio(&Portfolio{ID: "portfolio1", UserID: "user1", Positions: []*Position{
+		{ID: "b1", Symbol: "X", Quantity: 10, EntryPrice: 100, EntryTime: date(2024, 1, 1), TransactionType: "BUY", CurrentPrice: 150},
+		{ID: "b2", Symbol: "X", Quantity: 10, EntryPrice: 120, EntryTime: date(2025, 6, 1), TransactionType: "BUY", CurrentPrice: 150},
+	}}))
+	// Lots are matched across the account's portfolios
+	require.NoError(t, engine.AddPortfolio(&Portfolio{ID: "portfolio2", UserID: "user1", Positions: []*Position{
+		{ID: "s1", Symbol: "X", Quantity: 15, EntryPrice: 130, EntryTime: date(2025, 7, 1), TransactionType: "SELL", CurrentPrice: 150},
+		{ID: "y", Symbol: "Y", Quantity: 5, EntryPrice: 50, EntryTime: date(2025, 7, 2), TransactionType: "SELL", ExitPrice: &cover, ExitTime: timePointer(date(2025, 7, 10))},
+	}}))
+	require.NoError(t, engine.AddPortfolio(&Portfolio{ID: "portfolio3", UserID: "user2", Positions: []*Position{
+		{ID: "o", Symbol: "X", Quantity: 1, EntryPrice: 1, EntryTime: date(2025, 1, 1), TransactionType: "BUY"},
+	}}))
+
+	from, to := date(2025, 1, 1), date(2026, 1, 1)
+
+	tests := []struct {
+		method        string
+		longTermGain  float64
+		shortTermGain float64
+		openLot       string
+	}{
+		// 10 of b1 held 547 days gain 300, 5 of b2 gain 50 and the short Y gains 50
+		{LotMatchingFIFO, 300, 100, "b2"},
+		// 10 of b2 gain 100 and 5 of b1 gain 150
+		{LotMatchingLIFO, 150, 150, "b1"},
+		// Both at the average cost of 110, 10 of b1 gain 200 and 5 of b2 gain 100
+		{LotMatchingAverage, 200, 150, "b2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			require.NoError(t, engine.SetLotMatchingMethod("user1", test.method))
+
+			report, err := engine.GetTaxLotReport("user1", from, to)
+			require.NoError(t, err)
+			assert.Equal(t, test.method, report.Method)
+			assert.InDelta(t, test.longTermGain, report.LongTermGain, 1e-9)
+			assert.InDelta(t, test.shortTermGain, report.ShortTermGain, 1e-9)
+			assert.InDelta(t, test.longTermGain+test.shortTermGain, report.TotalGain, 1e-9)
+
+			if assert.Len(t, report.OpenLots, 1) {
+				assert.Equal(t, test.openLot, report.OpenLots[0].ID)
+				assert.Equal(t, 5, report.OpenLots[0].RemainingQuantity)
+			}
+		})
+	}
+
+	t.Run("Short lots", func(t *testing.T) {
+		require.NoError(t, engine.SetLotMatchingMethod("user1", LotMatchingFIFO))
+		require.NoError(t, engine.AddPosition("portfolio2", &Position{ID: "s2", Symbol: "X", Quantity: 10, EntryPrice: 140, EntryTime: date(2025, 8, 1), TransactionType: "SELL", CurrentPrice: 150}))
+
+		report, err := engine.GetTaxLotReport("user1", from, to)
+		require.NoError(t, err)
+
+		// s2 sells the 5 of b2 left and goes short 5, losing 10 each at 150
+		if assert.Len(t, report.OpenLots, 1) {
+			assert.Equal(t, "s2", report.OpenLots[0].ID)
+			assert.True(t, report.OpenLots[0].Short)
+			assert.Equal(t, 5, report.OpenLots[0].RemainingQuantity)
+			assert.InDelta(t, -50, report.OpenLots[0].UnrealizedGain, 1e-9)
+		}
+
+		var covered *RealizedGain
+		for _, gain := range report.RealizedGains {
+			if gain.Symbol == "Y" {
+				covered = gain
+			}
+		}
+		if assert.NotNil(t, covered) {
+			assert.True(t, covered.Short)
+			assert.Equal(t, "y", covered.ClosedBy)
+			assert.InDelta(t, 200, covered.CostBasis, 1e-9)
+			assert.InDelta(t, 250, covered.Proceeds, 1e-9)
+		}
+
+		// Only the cover of Y and the sale of b2 fall after the first sale
+		report, err = engine.GetTaxLotReport("user1", date(2025, 7, 5), to)
+		require.NoError(t, err)
+		assert.Len(t, report.RealizedGains, 2)
+	})
+
+	assert.Error(t, engine.SetLotMatchingMethod("user1", "HIFO"))
+	assert.Error(t, engine.SetLotMatchingMethod("", LotMatchingFIFO))
+	_, err := engine.GetTaxLotReport("user1", to, from)
+	assert.Error(t, err)
+}
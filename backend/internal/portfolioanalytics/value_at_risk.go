@@ -0,0 +1,202 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "errors"
+        "math"
+        "sort"
+        "time"
+)
+
+// Value-at-risk methods
+const (
+        VaRMethodHistorical = "HISTORICAL" // Quantile of the P&L the current positions would have made historically
+        VaRMethodParametric = "PARAMETRIC" // Assumes normally distributed daily P&L
+)
+
+// RiskConfig configures how risk metrics are calculated
+type RiskConfig struct {
+        VaRMethod       string  // HISTORICAL or PARAMETRIC
+        ConfidenceLevel float64 // e.g. 0.95 or 0.99
+        HorizonDays     int     // Daily VaR is scaled to the horizon by the square root of time
+        LookbackDays    int     // Calendar days of price history used
+        RiskFreeRate    float64 // Annual rate options are priced with and Sharpe and Sortino ratios measured against
+
+        // Minimum average correlation at which symbols are grouped into one cluster
+        ClusterCorrelation float64
+
+        MarketIndex   string             // Index BetaToMarket is measured against, none when empty
+        IndexExchange string             // Exchange of the market and factor indices
+        Factors       []FactorDefinition // Factors exposures are measured to
+
+        StressScenarios []StressScenario // User-defined scenarios run alongside the predefined ones
+}
+
+// DefaultRiskConfig returns a one-day 95% historical VaR over a year of prices
+func DefaultRiskConfig() RiskConfig {
+        return RiskConfig{
+                VaRMethod:       VaRMethodHistorical,
+                ConfidenceLevel: 0.95,
+                HorizonDays:     1,
+                LookbackDays:    365,
+                RiskFreeRate:    0.065,
+
+                ClusterCorrelation: 0.7,
+
+                MarketIndex:   "NIFTY 50",
+                IndexExchange: "NSE",
+                Factors:       DefaultFactors(),
+        }
+}
+
+// Validate validates the risk configuration
+func (c *RiskConfig) Validate() error {
+        if c.VaRMethod != VaRMethodHistorical && c.VaRMethod != VaRMethodParametric {
+                return errors.New("invalid VaR method")
+        }
+
+        if c.ConfidenceLevel <= 0 || c.ConfidenceLevel >= 1 {
+                return errors.New("confidence level must be between 0 and 1")
+        }
+
+        if c.HorizonDays <= 0 {
+                return errors.New("horizon days must be greater than zero")
+        }
+
+        if c.LookbackDays <= 1 {
+                return errors.New("lookback days must be greater than one")
+        }
+
+        if c.ClusterCorrelation <= -1 || c.ClusterCorrelation > 1 {
+                return errors.New("cluster correlation must be greater than -1 and at most 1")
+        }
+
+        factors := make(map[string]bool)
+        for _, factor := range c.Factors {
+                if err := factor.Validate(); err != nil {
+                        return err
+                }
+
+                if factors[factor.Name] {
+                        return errors.New("duplicate factor: " + factor.Name)
+                }
+                factors[factor.Name] = true
+        }
+
+        names := make(map[string]bool)
+        for _, scenario := range c.StressScenarios {
+                if err := scenario.Validate(); err != nil {
+                        return err
+                }
+
+                if names[scenario.Name] {
+                        return errors.New("duplicate stress scenario: " + scenario.Name)
+                }
+                names[scenario.Name] = true
+        }
+
+        return nil
+}
+
+// valueAtRisk holds a VaR estimate and its conditional VaR
+type valueAtRisk struct {
+        ValueAtRisk    float64
+        ConditionalVaR float64
+}
+
+// calculateValueAtRisk estimates the VaR of the open positions by applying the daily
+// returns of their symbols over the lookback period to today's position values
+func (e *PortfolioAnalyticsEngine) calculateValueAtRisk(positions []*Position) (valueAtRisk, error) {
+        exposures, exchanges := openExposures(positions)
+        if len(exposures) == 0 || e.dataProvider == nil {
+                return valueAtRisk{}, nil
+        }
+
+        endDate := time.Now()
+        startDate := endDate.AddDate(0, 0, -e.riskConfig.LookbackDays)
+
+        dates, returns, err := e.historicalReturns(context.Background(), exchanges, startDate, endDate)
+        if err != nil {
+                return valueAtRisk{}, err
+        }
+
+        // Too little history to estimate anything
+        if len(dates) < 2 {
+                return valueAtRisk{}, nil
+        }
+
+        pnl := make([]float64, len(dates))
+        for symbol, exposure := range exposures {
+                for i, dailyReturn := range returns[symbol] {
+                        pnl[i] += exposure * dailyReturn
+                }
+        }
+
+        var result valueAtRisk
+        if e.riskConfig.VaRMethod == VaRMethodParametric {
+                result.ValueAtRisk, result.ConditionalVaR = ParametricVaR(pnl, e.riskConfig.ConfidenceLevel, e.riskConfig.HorizonDays)
+        } else {
+                result.ValueAtRisk, result.ConditionalVaR = HistoricalVaR(pnl, e.riskConfig.ConfidenceLevel, e.riskConfig.HorizonDays)
+        }
+
+        return result, nil
+}
+
+// HistoricalVaR returns the value at risk and conditional VaR of a series of daily
+// P&L at a confidence level, as positive losses scaled to the horizon by the square
+// root of time. A series whose tail is still profitable has no value at risk.
+func HistoricalVaR(pnl []float64, confidence float64, horizonDays int) (float64, float64) {
+        if len(pnl) == 0 {
+                return 0, 0
+        }
+
+        losses := make([]float64, len(pnl))
+        for i, value := range pnl {
+                losses[i] = -value
+        }
+        sort.Float64s(losses)
+
+        index := int(math.Ceil(confidence*float64(len(losses)))) - 1
+        if index < 0 {
+                index = 0
+        }
+
+        tail := 0.0
+        for _, loss := range losses[index:] {
+                tail += loss
+        }
+        tail /= float64(len(losses) - index)
+
+        scale := math.Sqrt(float64(horizonDays))
+        return math.Max(0, losses[index]*scale), math.Max(0, tail*scale)
+}
+
+// ParametricVaR returns the value at risk and conditional VaR of a series of daily
+// P&L assuming it is normally distributed, as positive losses over the horizon
+func ParametricVaR(pnl []float64, confidence float64, horizonDays int) (float64, float64) {
+        if len(pnl) < 2 {
+                return 0, 0
+        }
+
+        mean := 0.0
+        for _, value := range pnl {
+                mean += value
+        }
+        mean /= float64(len(pnl))
+
+        variance := 0.0
+        for _, value := range pnl {
+                variance += (value - mean) * (value - mean)
+        }
+        deviation := math.Sqrt(variance / float64(len(pnl)-1))
+
+        horizon := float64(horizonDays)
+        spread := deviation * math.Sqrt(horizon)
+        z := math.Sqrt2 * math.Erfinv(2*confidence-1)
+        density := math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+
+        valueAtRisk := z*spread - mean*horizon
+        conditionalVaR := spread*density/(1-confidence) - mean*horizon
+
+        return math.Max(0, valueAtRisk), math.Max(0, conditionalVaR)
+}
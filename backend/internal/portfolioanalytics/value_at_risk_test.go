@@ -0,0 +1,66 @@
+package portfolioanalytics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoricalVaR(t *testing.T) {
+	// Ten days of P&L from -50 to +40, so losses from -40 to 50
+	pnl := []float64{-50, -40, -30, -20, -10, 0, 10, 20, 30, 40}
+
+	tests := []struct {
+		name           string
+		pnl            []float64
+		confidence     float64
+		horizonDays    int
+		valueAtRisk    float64
+		conditionalVaR float64
+	}{
+		{"90% one day", pnl, 0.90, 1, 40, 45},                     // Ninth smallest loss, mean of the last two
+		{"95% one day", pnl, 0.95, 1, 50, 50},                     // Largest loss
+		{"90% four days", pnl, 0.90, 4, 80, 90},                   // Scaled by the square root of four
+		{"Profitable tail", []float64{10, 20, 30}, 0.95, 1, 0, 0}, // No losses to risk
+		{"No history", nil, 0.95, 1, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			valueAtRisk, conditionalVaR := HistoricalVaR(test.pnl, test.confidence, test.horizonDays)
+			assert.InDelta(t, test.valueAtRisk, valueAtRisk, 1e-9)
+			assert.InDelta(t, test.conditionalVaR, conditionalVaR, 1e-9)
+		})
+	}
+}
+
+func TestParametricVaR(t *testing.T) {
+	// Both series have a sample standard deviation of sqrt(200)
+	flat := []float64{-10, 10}
+	rising := []float64{0, 20}
+
+	tests := []struct {
+		name           string
+		pnl            []float64
+		confidence     float64
+		horizonDays    int
+		valueAtRisk    float64
+		conditionalVaR float64
+	}{
+		{"95% zero mean", flat, 0.95, 1, 23.261743073533466, 29.17116427657689},
+		{"99% zero mean", flat, 0.99, 1, 32.899527142663736, 37.69182097042673},
+		{"95% positive mean", rising, 0.95, 1, 13.261743073533466, 19.17116427657689},
+		{"95% positive mean four days", rising, 0.95, 4, 6.523486147066933, 18.342328553153777},
+		{"99% positive mean four days", rising, 0.99, 4, 25.799054285327472, 35.383641940853465},
+		{"Mean outweighing the spread", []float64{100, 101}, 0.95, 1, 0, 0},
+		{"Too little history", []float64{-10}, 0.95, 1, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			valueAtRisk, conditionalVaR := ParametricVaR(test.pnl, test.confidence, test.horizonDays)
+			assert.InDelta(t, test.valueAtRisk, valueAtRisk, 1e-6)
+			assert.InDelta(t, test.conditionalVaR, conditionalVaR, 1e-6)
+		})
+	}
+}
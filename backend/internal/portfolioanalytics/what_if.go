@@ -0,0 +1,303 @@
+package portfolioanalytics
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "math"
+        "time"
+)
+
+// What-if change actions
+const (
+        WhatIfAdd    = "ADD"
+        WhatIfModify = "MODIFY"
+        WhatIfClose  = "CLOSE"
+)
+
+const (
+        // marginPriceScan is the largest underlying move margin is scanned over
+        marginPriceScan = 0.10
+
+        // marginVolatilityScan is the relative implied volatility change margin is scanned over
+        marginVolatilityScan = 0.25
+)
+
+// WhatIfChange is a hypothetical change to a portfolio
+type WhatIfChange struct {
+        Action     string    // ADD, MODIFY or CLOSE
+        Position   *Position // Position to add. Without a current price it is valued at the market.
+        PositionID string    // Position to modify or close
+        Quantity   int       // New quantity of the modified position
+}
+
+// Validate validates the change
+func (c *WhatIfChange) Validate() error {
+        switch c.Action {
+        case WhatIfAdd:
+                if c.Position == nil {
+                        return errors.New("position is required to add a position")
+                }
+
+                if c.Position.Symbol == "" || c.Position.Quantity <= 0 {
+                        return errors.New("position needs a symbol and a positive quantity")
+                }
+
+                if c.Position.TransactionType != "BUY" && c.Position.TransactionType != "SELL" {
+                        return fmt.Errorf("invalid transaction type: %s", c.Position.TransactionType)
+                }
+
+                isOption := c.Position.OptionType != nil || c.Position.StrikePrice != nil
+                if isOption && (c.Position.OptionType == nil || c.Position.StrikePrice == nil || c.Position.ExpiryDate == nil) {
+                        return errors.New("options need an option type, strike price and expiry date")
+                }
+        case WhatIfModify:
+                if c.PositionID == "" || c.Quantity <= 0 {
+                        return errors.New("position ID and a positive quantity are required to modify a position")
+                }
+        case WhatIfClose:
+                if c.PositionID == "" {
+                        return errors.New("position ID is required to close a position")
+                }
+        default:
+                return fmt.Errorf("unknown what-if action: %s", c.Action)
+        }
+
+        return nil
+}
+
+// WhatIfProjection is the risk of a portfolio's open positions
+type WhatIfProjection struct {
+        Greeks         *AggregateGreeks
+        Margin         float64 // Estimated margin requirement
+        ValueAtRisk    float64
+        ConditionalVaR float64
+        Scenarios      map[string]float64 // P&L by stress scenario
+}
+
+// WhatIfAnalysis compares a portfolio's risk before and after hypothetical changes
+type WhatIfAnalysis struct {
+        PortfolioID string
+        Changes     []WhatIfChange
+        Current     *WhatIfProjection
+        Projected   *WhatIfProjection
+        UpdatedAt   time.Time
+}
+
+// WhatIf projects a portfolio's greeks, margin, VaR and stress P&L after applying
+// changes to its positions. Nothing is changed in the portfolio itself.
+func (e *PortfolioAnalyticsEngine) WhatIf(portfolioID string, changes []WhatIfChange) (*WhatIfAnalysis, error) {
+        e.mutex.RLock()
+        defer e.mutex.RUnlock()
+
+        if _, exists := e.portfolios[portfolioID]; !exists {
+                return nil, fmt.Errorf("portfolio with ID %s not found", portfolioID)
+        }
+
+        if len(changes) == 0 {
+                return nil, errors.New("at least one change is required")
+        }
+
+        for i := range changes {
+                if err := changes[i].Validate(); err != nil {
+                        return nil, err
+                }
+        }
+
+        ctx := context.Background()
+        positions := e.positions[portfolioID]
+
+        projected, transient, err := e.applyWhatIf(ctx, positions, changes)
+        if err != nil {
+                return nil, err
+        }
+
+        current, err := e.projectRisk(ctx, portfolioID, positions, nil)
+        if err != nil {
+                return nil, err
+        }
+
+        after, err := e.projectRisk(ctx, portfolioID, projected, transient)
+        if err != nil {
+                return nil, err
+        }
+
+        return &WhatIfAnalysis{
+                PortfolioID: portfolioID,
+                Changes:     changes,
+                Current:     current,
+                Projected:   after,
+                UpdatedAt:   time.Now(),
+        }, nil
+}
+
+// applyWhatIf returns positions with changes applied, along with the positions that
+// were added. Positions are copied rather than modified.
+func (e *PortfolioAnalyticsEngine) applyWhatIf(ctx context.Context, positions []*Position, changes []WhatIfChange) ([]*Position, map[*Position]bool, error) {
+        projected := append([]*Position(nil), positions...)
+        transient := make(map[*Position]bool)
+
+        for i, change := range changes {
+                if change.Action == WhatIfAdd {
+                        added := *change.Position
+                        if added.ID == "" {
+                                added.ID = fmt.Sprintf("WHAT_IF_%d", i+1)
+                        }
+                        added.ExitTime = nil
+
+                        if added.CurrentPrice <= 0 {
+                                price, err := e.marketPrice(ctx, &added)
+                                if err != nil {
+                                        return nil, nil, err
+                                }
+                                added.CurrentPrice = price
+                        }
+
+                        if added.EntryPrice <= 0 {
+                                added.EntryPrice = added.CurrentPrice
+                        }
+
+                        projected = append(projected, &added)
+                        transient[&added] = true
+                        continue
+                }
+
+                found := false
+                for j, position := range projected {
+                        if position.ID != change.PositionID || position.ExitTime != nil {
+                                continue
+                        }
+                        found = true
+
+                        if change.Action == WhatIfClose {
+                                projected = append(projected[:j:j], projected[j+1:]...)
+                        } else {
+                                modified := *position
+                                modified.Quantity = change.Quantity
+                                projected[j] = &modified
+                        }
+                        break
+                }
+
+                if !found {
+                        return nil, nil, fmt.Errorf("open position with ID %s not found", change.PositionID)
+                }
+        }
+
+        return projected, transient, nil
+}
+
+// marketPrice returns what one unit of a position is worth at the market: the
+// Black-Scholes price for options and the last price otherwise
+func (e *PortfolioAnalyticsEngine) marketPrice(ctx context.Context, position *Position) (float64, error) {
+        if e.dataProvider == nil {
+                return 0, errors.New("no market data to price the position")
+        }
+
+        price, err := e.dataProvider.GetCurrentPrice(ctx, position.Symbol, position.Exchange)
+        if err != nil {
+                return 0, fmt.Errorf("failed to get current price for %s: %w", position.Symbol, err)
+        }
+
+        if position.OptionType == nil {
+                return price, nil
+        }
+
+        // Option positions are held against their underlying's symbol
+        volatility, err := e.impliedVolatility(ctx, position)
+        if err != nil {
+                return 0, err
+        }
+
+        years := position.ExpiryDate.Sub(time.Now()).Hours() / 24 / 365
+        return blackScholesPrice(*position.OptionType, price, *position.StrikePrice, years, e.riskConfig.RiskFreeRate, volatility), nil
+}
+
+// projectRisk calculates the risk of a set of positions
+func (e *PortfolioAnalyticsEngine) projectRisk(ctx context.Context, portfolioID string, positions []*Position, transient map[*Position]bool) (*WhatIfProjection, error) {
+        valueAtRisk, err := e.calculateValueAtRisk(positions)
+        if err != nil {
+                return nil, err
+        }
+
+        margin, err := e.estimateMargin(ctx, positions)
+        if err != nil {
+                return nil, err
+        }
+
+        results, err := e.stressTest(ctx, positions, e.stressScenarios())
+        if err != nil {
+                return nil, err
+        }
+
+        scenarios := make(map[string]float64, len(results))
+        for _, result := range results {
+                scenarios[result.Scenario] = result.PnL
+        }
+
+        return &WhatIfProjection{
+                Greeks:         e.greeks.positionsGreeks(GreeksScopePortfolio, portfolioID, positions, transient),
+                Margin:         margin,
+                ValueAtRisk:    valueAtRisk.ValueAtRisk,
+                ConditionalVaR: valueAtRisk.ConditionalVaR,
+                Scenarios:      scenarios,
+        }, nil
+}
+
+// estimateMargin approximates the margin positions need the way SPAN does: futures
+// and short options are charged their worst combined loss over a grid of price and
+// implied volatility moves, long options their premium and other positions their
+// full value. Long options are not offset against short ones.
+func (e *PortfolioAnalyticsEngine) estimateMargin(ctx context.Context, positions []*Position) (float64, error) {
+        margin := 0.0
+        var scanned []*Position
+
+        for _, position := range positions {
+                if position.ExitTime != nil {
+                        continue
+                }
+
+                switch {
+                case position.OptionType != nil && position.TransactionType != "SELL":
+                        margin += float64(position.Quantity) * position.CurrentPrice
+                case position.OptionType != nil || position.ExpiryDate != nil:
+                        scanned = append(scanned, position)
+                default:
+                        margin += float64(position.Quantity) * position.CurrentPrice
+                }
+        }
+
+        if len(scanned) == 0 {
+                return margin, nil
+        }
+
+        results, err := e.stressTest(ctx, scanned, marginScenarios())
+        if err != nil {
+                return 0, err
+        }
+
+        worst := 0.0
+        for _, result := range results {
+                worst = math.Min(worst, result.PnL)
+        }
+
+        return margin - worst, nil
+}
+
+// marginScenarios returns the price and implied volatility moves margin is scanned
+// over: the underlying moving up and down by thirds of the price scan range, each
+// with implied volatility up and down by the volatility scan
+func marginScenarios() []StressScenario {
+        var scenarios []StressScenario
+        for _, fraction := range []float64{-1, -2.0 / 3, -1.0 / 3, 0, 1.0 / 3, 2.0 / 3, 1} {
+                for _, direction := range []float64{-1, 1} {
+                        scenarios = append(scenarios, StressScenario{
+                                Name:            fmt.Sprintf("SCAN_%+.1f_%+.0f", fraction*marginPriceScan*100, direction*marginVolatilityScan*100),
+                                PriceShock:      fraction * marginPriceScan,
+                                VolatilityShock: direction * marginVolatilityScan,
+                        })
+                }
+        }
+
+        return scenarios
+}
@@ -0,0 +1,159 @@
+package portfolioanalytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWhatIfEngine returns an engine with a portfolio holding 10 RELIANCE at 100,
+// whose worst day in its history is a 5% fall
+func newWhatIfEngine(t *testing.T) *PortfolioAnalyticsEngine {
+	provider := &spotDataProvider{
+		stubDataProvider: stubDataProvider{prices: map[string]map[time.Time]float64{
+			"RELIANCE": dailyCloses(100, []float64{-0.05, 0.02, 0.01, -0.01, 0.03}),
+			"NIFTY":    dailyCloses(100, []float64{-0.02, 0.01, 0.01, -0.01, 0.02}),
+		}},
+		spot:            100,
+		volatilityIndex: 20,
+	}
+
+	engine := NewPortfolioAnalyticsEngine(provider, 1)
+	require.NoError(t, engine.AddPortfolio(&Portfolio{
+		ID: "portfolio1",
+		Positions: []*Position{
+			{ID: "reliance", Symbol: "RELIANCE", Quantity: 10, EntryPrice: 90, CurrentPrice: 100, TransactionType: "BUY"},
+		},
+	}))
+
+	return engine
+}
+
+func TestWhatIf(t *testing.T) {
+	tests := []struct {
+		name        string
+		change      WhatIfChange
+		valueAtRisk float64
+		delta       float64
+		margin      float64
+		indexDown   float64 // P&L of the index falling 10%
+	}{
+		{
+			name:        "Add to the position at the market",
+			change:      WhatIfChange{Action: WhatIfAdd, Position: &Position{Symbol: "RELIANCE", Quantity: 10, TransactionType: "BUY"}},
+			valueAtRisk: 100,
+			delta:       20,
+			margin:      2000,
+			indexDown:   -200,
+		},
+		{
+			name:        "Hedge the position",
+			change:      WhatIfChange{Action: WhatIfAdd, Position: &Position{Symbol: "RELIANCE", Quantity: 5, CurrentPrice: 100, TransactionType: "SELL"}},
+			valueAtRisk: 25,
+			delta:       5,
+			margin:      1500,
+			indexDown:   -50,
+		},
+		{
+			name:        "Modify the quantity",
+			change:      WhatIfChange{Action: WhatIfModify, PositionID: "reliance", Quantity: 30},
+			valueAtRisk: 150,
+			delta:       30,
+			margin:      3000,
+			indexDown:   -300,
+		},
+		{
+			name:   "Close the position",
+			change: WhatIfChange{Action: WhatIfClose, PositionID: "reliance"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine := newWhatIfEngine(t)
+
+			analysis, err := engine.WhatIf("portfolio1", []WhatIfChange{test.change})
+			require.NoError(t, err)
+
+			// The current portfolio loses 50 on its worst day
+			assert.InDelta(t, 50, analysis.Current.ValueAtRisk, 1e-9)
+			assert.InDelta(t, 10, analysis.Current.Greeks.Delta, 1e-9)
+			assert.InDelta(t, 1000, analysis.Current.Margin, 1e-9)
+			assert.InDelta(t, -100, analysis.Current.Scenarios["INDEX_DOWN_10"], 1e-9)
+
+			assert.InDelta(t, test.valueAtRisk, analysis.Projected.ValueAtRisk, 1e-9)
+			assert.InDelta(t, test.delta, analysis.Projected.Greeks.Delta, 1e-9)
+			assert.InDelta(t, test.margin, analysis.Projected.Margin, 1e-9)
+			assert.InDelta(t, test.indexDown, analysis.Projected.Scenarios["INDEX_DOWN_10"], 1e-9)
+
+			// The portfolio itself is left as it was
+			positions := engine.positions["portfolio1"]
+			require.Len(t, positions, 1)
+			assert.Equal(t, "reliance", positions[0].ID)
+			assert.Equal(t, 10, positions[0].Quantity)
+			assert.Nil(t, positions[0].ExitTime)
+
+			portfolio, err := engine.GetPortfolio("portfolio1")
+			require.NoError(t, err)
+			assert.Len(t, portfolio.Positions, 1)
+
+			results, err := engine.RunStressTest("portfolio1", nil)
+			require.NoError(t, err)
+			assert.InDelta(t, -100, stressPnL(results)["INDEX_DOWN_10"], 1e-9)
+		})
+	}
+}
+
+func TestWhatIfAddOption(t *testing.T) {
+	engine := newWhatIfEngine(t)
+
+	call := "CE"
+	strike := 100.0
+	expiry := time.Now().AddDate(0, 0, 365)
+
+	// An at-the-money call a year from expiry at 20% volatility, worth 11.26 with a delta of 0.66
+	analysis, err := engine.WhatIf("portfolio1", []WhatIfChange{{
+		Action:   WhatIfAdd,
+		Position: &Position{Symbol: "NIFTY", Quantity: 50, TransactionType: "BUY", OptionType: &call, StrikePrice: &strike, ExpiryDate: &expiry},
+	}})
+	require.NoError(t, err)
+
+	assert.InDelta(t, 10+33.22908313149152, analysis.Projected.Greeks.Delta, 1e-4)
+	assert.InDelta(t, 33.22908313149152, analysis.Projected.Greeks.DeltaBySymbol["NIFTY"], 1e-4)
+	assert.InDelta(t, 1000+50*11.26392159934261, analysis.Projected.Margin, 1e-3)
+	assert.Greater(t, analysis.Projected.Scenarios["IV_UP_20"], 0.0)
+
+	// The hypothetical option is not remembered by the greeks aggregator
+	engine.greeks.mutex.Lock()
+	assert.NotContains(t, engine.greeks.volatilities, "WHAT_IF_1")
+	engine.greeks.mutex.Unlock()
+	assert.Len(t, engine.positions["portfolio1"], 1)
+}
+
+func TestWhatIfErrors(t *testing.T) {
+	engine := newWhatIfEngine(t)
+
+	tests := []struct {
+		name        string
+		portfolioID string
+		changes     []WhatIfChange
+	}{
+		{"Unknown portfolio", "portfolio2", []WhatIfChange{{Action: WhatIfClose, PositionID: "reliance"}}},
+		{"No changes", "portfolio1", nil},
+		{"Unknown action", "portfolio1", []WhatIfChange{{Action: "HOLD", PositionID: "reliance"}}},
+		{"Unknown position", "portfolio1", []WhatIfChange{{Action: WhatIfClose, PositionID: "infy"}}},
+		{"Closed twice", "portfolio1", []WhatIfChange{{Action: WhatIfClose, PositionID: "reliance"}, {Action: WhatIfClose, PositionID: "reliance"}}},
+		{"Option without expiry", "portfolio1", []WhatIfChange{{Action: WhatIfAdd, Position: &Position{Symbol: "NIFTY", Quantity: 50, TransactionType: "BUY", StrikePrice: new(float64)}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := engine.WhatIf(test.portfolioID, test.changes)
+			assert.Error(t, err)
+		})
+	}
+
+	assert.Len(t, engine.positions["portfolio1"], 1)
+}
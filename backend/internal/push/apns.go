@@ -0,0 +1,172 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// APNsProductionEndpoint is the APNs API of apps from the App Store
+	APNsProductionEndpoint = "https://api.push.apple.com"
+
+	// APNsSandboxEndpoint is the APNs API of development builds
+	APNsSandboxEndpoint = "https://api.sandbox.push.apple.com"
+
+	// apnsTokenLifetime is how long a provider token is used. APNs rejects tokens
+	// older than an hour, and more than one new token every 20 minutes.
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// APNsConfig configures pushes through the Apple Push Notification service, with
+// token-based authentication
+type APNsConfig struct {
+	KeyID      string        // Of the signing key
+	TeamID     string        // Of the Apple developer account
+	Topic      string        // Bundle ID of the app
+	PrivateKey []byte        // Signing key, the .p8 file downloaded from Apple
+	Sandbox    bool          // Pushes to development builds
+	Endpoint   string        // Defaults to the production or sandbox endpoint
+	Timeout    time.Duration // Defaults to DefaultTimeout
+}
+
+// APNsProvider pushes messages to iOS devices through APNs. APNs requires
+// HTTP/2, which the HTTP client negotiates over TLS.
+type APNsProvider struct {
+	config   APNsConfig
+	key      *ecdsa.PrivateKey
+	client   *http.Client
+	token    string
+	issuedAt time.Time
+	mutex    sync.Mutex
+}
+
+// NewAPNsProvider creates a new APNsProvider
+func NewAPNsProvider(config APNsConfig) (*APNsProvider, error) {
+	if config.KeyID == "" || config.TeamID == "" || config.Topic == "" {
+		return nil, errors.New("APNs key ID, team ID and topic are required")
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(config.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APNs private key: %w", err)
+	}
+
+	if config.Endpoint == "" {
+		config.Endpoint = APNsProductionEndpoint
+		if config.Sandbox {
+			config.Endpoint = APNsSandboxEndpoint
+		}
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	return &APNsProvider{
+		config: config,
+		key:    key,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// Push pushes a message to a device. It implements Provider.
+func (p *APNsProvider) Push(ctx context.Context, token string, message *Message) error {
+	providerToken, err := p.providerToken()
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{
+				"title": message.Title,
+				"body":  message.Body,
+			},
+			"sound": "default",
+		},
+	}
+	for key, value := range message.Data {
+		if key != "aps" {
+			payload[key] = value
+		}
+	}
+	if message.Link != "" {
+		payload["link"] = message.Link
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/3/device/%s", strings.TrimRight(p.config.Endpoint, "/"), url.PathEscape(token))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+providerToken)
+	req.Header.Set("apns-topic", p.config.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var response struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&response)
+
+	switch {
+	case resp.StatusCode == http.StatusGone, response.Reason == "BadDeviceToken", response.Reason == "Unregistered":
+		return ErrUnregistered
+	case response.Reason == "ExpiredProviderToken":
+		// A new provider token is signed next time
+		p.mutex.Lock()
+		p.token = ""
+		p.mutex.Unlock()
+	}
+	return fmt.Errorf("APNs responded with status %d: %s", resp.StatusCode, response.Reason)
+}
+
+// providerToken returns the token authenticating pushes, signing a new one when
+// the last is too old
+func (p *APNsProvider) providerToken() (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	if p.token != "" && now.Sub(p.issuedAt) < apnsTokenLifetime {
+		return p.token, nil
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": p.config.TeamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = p.config.KeyID
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign APNs provider token: %w", err)
+	}
+
+	p.token = signed
+	p.issuedAt = now
+	return p.token, nil
+}
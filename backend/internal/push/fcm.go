@@ -0,0 +1,236 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// DefaultFCMEndpoint is the FCM HTTP v1 API
+	DefaultFCMEndpoint = "https://fcm.googleapis.com"
+
+	// DefaultTimeout limits how long a push may take
+	DefaultTimeout = 10 * time.Second
+
+	// fcmScope is the OAuth scope of access tokens to send messages with
+	fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+	// defaultTokenURI is Google's OAuth token endpoint, for service accounts not
+	// naming one
+	defaultTokenURI = "https://oauth2.googleapis.com/token"
+
+	// maxResponseSize bounds the error responses read
+	maxResponseSize = 64 * 1024
+)
+
+// FCMConfig configures pushes through Firebase Cloud Messaging
+type FCMConfig struct {
+	ProjectID   string        // Defaults to the service account's project
+	Credentials []byte        // Service account key, as downloaded from the Firebase console
+	Endpoint    string        // Defaults to DefaultFCMEndpoint
+	Timeout     time.Duration // Defaults to DefaultTimeout
+}
+
+// serviceAccount is the part of a Google service account key used
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCMProvider pushes messages to Android devices through the FCM HTTP v1 API,
+// authenticating with OAuth access tokens of a service account
+type FCMProvider struct {
+	config      FCMConfig
+	account     serviceAccount
+	key         *rsa.PrivateKey
+	client      *http.Client
+	accessToken string
+	expiresAt   time.Time
+	mutex       sync.Mutex
+}
+
+// NewFCMProvider creates a new FCMProvider
+func NewFCMProvider(config FCMConfig) (*FCMProvider, error) {
+	var account serviceAccount
+	if err := json.Unmarshal(config.Credentials, &account); err != nil {
+		return nil, fmt.Errorf("invalid FCM service account key: %w", err)
+	}
+	if account.ClientEmail == "" {
+		return nil, errors.New("FCM service account key has no client email")
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid FCM service account private key: %w", err)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = defaultTokenURI
+	}
+
+	if config.ProjectID == "" {
+		config.ProjectID = account.ProjectID
+	}
+	if config.ProjectID == "" {
+		return nil, errors.New("FCM project ID is required")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultFCMEndpoint
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	return &FCMProvider{
+		config:  config,
+		account: account,
+		key:     key,
+		client:  &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// Push pushes a message to a device. It implements Provider.
+func (p *FCMProvider) Push(ctx context.Context, token string, message *Message) error {
+	accessToken, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	data := make(map[string]string, len(message.Data)+1)
+	for key, value := range message.Data {
+		data[key] = value
+	}
+	if message.Link != "" {
+		data["link"] = message.Link
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token": token,
+			"notification": map[string]string{
+				"title": message.Title,
+				"body":  message.Body,
+			},
+			"data": data,
+			"android": map[string]string{
+				"priority": "HIGH",
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", strings.TrimRight(p.config.Endpoint, "/"), url.PathEscape(p.config.ProjectID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var response struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&response)
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		// The access token was revoked, a new one is requested next time
+		p.mutex.Lock()
+		p.accessToken = ""
+		p.mutex.Unlock()
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrUnregistered
+	}
+	for _, detail := range response.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" {
+			return ErrUnregistered
+		}
+	}
+	return fmt.Errorf("FCM responded with status %d: %s %s", resp.StatusCode, response.Error.Status, response.Error.Message)
+}
+
+// token returns an access token of the service account, requesting a new one
+// when the last has expired
+func (p *FCMProvider) token(ctx context.Context) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	if p.accessToken != "" && now.Before(p.expiresAt) {
+		return p.accessToken, nil
+	}
+
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   p.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   p.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign FCM token request: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request FCM access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("FCM access token request responded with status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&response); err != nil {
+		return "", fmt.Errorf("failed to decode FCM access token: %w", err)
+	}
+	if response.AccessToken == "" {
+		return "", errors.New("FCM access token response has no token")
+	}
+
+	// Renewed a minute early, so that tokens do not expire in flight
+	p.accessToken = response.AccessToken
+	p.expiresAt = now.Add(time.Duration(response.ExpiresIn)*time.Second - time.Minute)
+	return p.accessToken, nil
+}
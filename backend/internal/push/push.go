@@ -0,0 +1,96 @@
+// Package push delivers notifications to users' mobile devices, through Firebase
+// Cloud Messaging for Android and the Apple Push Notification service for iOS, so
+// that fills, stop-outs and risk alerts reach users away from the terminal. It is
+// the push channel of the alert service.
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Platform is the operating system of a device, which decides the service pushes
+// to it go through
+type Platform string
+
+const (
+	PlatformAndroid Platform = "ANDROID" // Through FCM
+	PlatformIOS     Platform = "IOS"     // Through APNs
+)
+
+// maxTokenLength bounds the device tokens accepted, FCM's being the longest
+const maxTokenLength = 4096
+
+var (
+	// ErrDeviceNotFound is returned when a device is not registered
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrInvalidDevice is returned for device registrations that are incomplete
+	ErrInvalidDevice = errors.New("invalid device")
+	// ErrUnregistered is returned by providers for device tokens that are no longer
+	// valid, such as after the app was uninstalled. Devices with such tokens are
+	// removed.
+	ErrUnregistered = errors.New("device token is no longer registered")
+	// ErrNoDevices is returned when pushing to a user without devices
+	ErrNoDevices = errors.New("user has no devices registered")
+)
+
+// Device is a mobile device a user registered for push notifications
+type Device struct {
+	ID         string    `json:"id" bson:"_id"`
+	UserID     string    `json:"userId" bson:"userId"`
+	Platform   Platform  `json:"platform" bson:"platform"`
+	Token      string    `json:"-" bson:"token"` // Issued to the app by FCM or APNs
+	Name       string    `json:"name,omitempty" bson:"name,omitempty"`
+	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt" bson:"updatedAt"`
+	LastPushAt time.Time `json:"lastPushAt,omitempty" bson:"lastPushAt,omitempty"`
+}
+
+// Request registers a device
+type Request struct {
+	Platform Platform `json:"platform"`
+	Token    string   `json:"token"`
+	Name     string   `json:"name,omitempty"`
+}
+
+// Validate checks a device registration is complete
+func (r *Request) Validate() error {
+	switch r.Platform {
+	case PlatformAndroid, PlatformIOS:
+	default:
+		return fmt.Errorf("%w: platform must be %s or %s", ErrInvalidDevice, PlatformAndroid, PlatformIOS)
+	}
+	token := strings.TrimSpace(r.Token)
+	if token == "" {
+		return fmt.Errorf("%w: token is required", ErrInvalidDevice)
+	}
+	if len(token) > maxTokenLength {
+		return fmt.Errorf("%w: token is too long", ErrInvalidDevice)
+	}
+	return nil
+}
+
+// Message is a notification pushed to a device
+type Message struct {
+	Title string
+	Body  string
+	Link  string            // Opened when the notification is tapped
+	Data  map[string]string // Passed to the app
+}
+
+// Provider pushes messages to devices of a platform
+type Provider interface {
+	Push(ctx context.Context, token string, message *Message) error
+}
+
+// Store keeps the devices users registered
+type Store interface {
+	SaveDevice(device *Device) error
+	GetDevice(id string) (*Device, error)
+	GetDeviceByToken(token string) (*Device, error)
+	ListDevices(userID string) ([]Device, error)
+	DeleteDevice(id string) error
+}
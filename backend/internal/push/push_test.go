@@ -0,0 +1,246 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/alerts"
+)
+
+// recordingProvider records the messages pushed through it, failing for the
+// tokens it is given errors of
+type recordingProvider struct {
+	pushed map[string][]Message
+	errs   map[string]error
+	mutex  sync.Mutex
+}
+
+func (p *recordingProvider) Push(ctx context.Context, token string, message *Message) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err, exists := p.errs[token]; exists {
+		return err
+	}
+	if p.pushed == nil {
+		p.pushed = make(map[string][]Message)
+	}
+	p.pushed[token] = append(p.pushed[token], *message)
+	return nil
+}
+
+func TestRegisterDevice(t *testing.T) {
+	service := NewService(NewMemoryStore(), nil)
+
+	device, err := service.RegisterDevice("user1", Request{Platform: PlatformAndroid, Token: " token1 ", Name: "Pixel"})
+	require.NoError(t, err)
+	assert.Equal(t, "token1", device.Token)
+
+	for _, request := range []Request{
+		{Platform: "WINDOWS", Token: "token2"},
+		{Platform: PlatformIOS},
+		{Platform: PlatformIOS, Token: strings.Repeat("a", maxTokenLength+1)},
+	} {
+		_, err := service.RegisterDevice("user1", request)
+		assert.ErrorIs(t, err, ErrInvalidDevice)
+	}
+
+	// Tokens registered again move to the user registering them
+	moved, err := service.RegisterDevice("user2", Request{Platform: PlatformAndroid, Token: "token1"})
+	require.NoError(t, err)
+	assert.Equal(t, device.ID, moved.ID)
+
+	devices, err := service.ListDevices("user1")
+	require.NoError(t, err)
+	assert.Empty(t, devices)
+	devices, err = service.ListDevices("user2")
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, "user2", devices[0].UserID)
+
+	// Tokens are not exposed
+	encoded, err := json.Marshal(devices[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), "token1")
+
+	require.NoError(t, service.UnregisterDevice(device.ID))
+	assert.ErrorIs(t, service.UnregisterDevice(device.ID), ErrDeviceNotFound)
+}
+
+func TestSend(t *testing.T) {
+	android := &recordingProvider{errs: map[string]error{"stale": ErrUnregistered}}
+	ios := &recordingProvider{errs: map[string]error{"broken": errors.New("APNs unavailable")}}
+	service := NewService(NewMemoryStore(), map[Platform]Provider{PlatformAndroid: android, PlatformIOS: ios})
+
+	alert := &alerts.Alert{ID: "alert1", UserID: "user1", RuleName: "Stop-loss hit", Message: "SELL 50/50 NIFTY on NFO at 98.00", Link: "/orders/order1"}
+	assert.ErrorIs(t, service.Send(context.Background(), alert), ErrNoDevices)
+
+	for _, request := range []Request{
+		{Platform: PlatformAndroid, Token: "phone"},
+		{Platform: PlatformAndroid, Token: "stale"},
+		{Platform: PlatformIOS, Token: "tablet"},
+	} {
+		_, err := service.RegisterDevice("user1", request)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, service.Send(context.Background(), alert))
+	require.Len(t, android.pushed["phone"], 1)
+	assert.Equal(t, Message{Title: "Stop-loss hit", Body: "SELL 50/50 NIFTY on NFO at 98.00", Link: "/orders/order1", Data: map[string]string{"alertId": "alert1"}}, android.pushed["phone"][0])
+	assert.Len(t, ios.pushed["tablet"], 1)
+
+	// Devices whose token is no longer registered are removed
+	devices, err := service.ListDevices("user1")
+	require.NoError(t, err)
+	assert.Len(t, devices, 2)
+	assert.False(t, devices[0].LastPushAt.IsZero())
+
+	// Pushes fail when no device could be pushed to
+	service = NewService(NewMemoryStore(), map[Platform]Provider{PlatformIOS: ios})
+	_, err = service.RegisterDevice("user1", Request{Platform: PlatformIOS, Token: "broken"})
+	require.NoError(t, err)
+	_, err = service.RegisterDevice("user1", Request{Platform: PlatformAndroid, Token: "phone"})
+	require.NoError(t, err)
+	err = service.Send(context.Background(), alert)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "APNs unavailable")
+	assert.Contains(t, err.Error(), "ANDROID: platform not configured")
+}
+
+func TestFCMProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	var tokenRequests int
+	var message map[string]map[string]interface{}
+	var mutex sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		switch r.URL.Path {
+		case "/token":
+			tokenRequests++
+			assertion, err := jwt.Parse(r.FormValue("assertion"), func(token *jwt.Token) (interface{}, error) {
+				return &key.PublicKey, nil
+			})
+			if err != nil || assertion.Claims.(jwt.MapClaims)["scope"] != fcmScope {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "access1", "expires_in": 3600})
+		case "/v1/projects/project1/messages:send":
+			if r.Header.Get("Authorization") != "Bearer access1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&message)
+			if message["message"]["token"] == "stale" {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"status": "NOT_FOUND", "details": []map[string]string{{"errorCode": "UNREGISTERED"}}}})
+				return
+			}
+			w.Write([]byte(`{"name":"projects/project1/messages/1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	credentials, err := json.Marshal(map[string]string{
+		"project_id":   "project1",
+		"client_email": "push@project1.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    server.URL + "/token",
+	})
+	require.NoError(t, err)
+
+	_, err = NewFCMProvider(FCMConfig{Credentials: []byte(`{}`)})
+	assert.Error(t, err)
+
+	provider, err := NewFCMProvider(FCMConfig{Credentials: credentials, Endpoint: server.URL})
+	require.NoError(t, err)
+
+	push := &Message{Title: "Order filled", Body: "BUY 50/50 NIFTY", Link: "/orders/order1", Data: map[string]string{"alertId": "alert1"}}
+	require.NoError(t, provider.Push(context.Background(), "phone", push))
+	require.NoError(t, provider.Push(context.Background(), "phone", push))
+	assert.Equal(t, 1, tokenRequests)
+	assert.Equal(t, map[string]interface{}{"title": "Order filled", "body": "BUY 50/50 NIFTY"}, message["message"]["notification"])
+	assert.Equal(t, map[string]interface{}{"alertId": "alert1", "link": "/orders/order1"}, message["message"]["data"])
+
+	assert.ErrorIs(t, provider.Push(context.Background(), "stale", push), ErrUnregistered)
+}
+
+func TestAPNsProvider(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	var headers http.Header
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		token, err := jwt.Parse(strings.TrimPrefix(r.Header.Get("Authorization"), "bearer "), func(token *jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		})
+		if err != nil || token.Header["kid"] != "KEY123" || token.Claims.(jwt.MapClaims)["iss"] != "TEAM123" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"reason":"InvalidProviderToken"}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		switch r.URL.Path {
+		case "/3/device/tablet":
+			w.WriteHeader(http.StatusOK)
+		case "/3/device/uninstalled":
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+		}
+	}))
+	defer server.Close()
+
+	_, err = NewAPNsProvider(APNsConfig{KeyID: "KEY123", TeamID: "TEAM123", Topic: "com.example.trading"})
+	assert.Error(t, err)
+
+	provider, err := NewAPNsProvider(APNsConfig{
+		KeyID:      "KEY123",
+		TeamID:     "TEAM123",
+		Topic:      "com.example.trading",
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		Endpoint:   server.URL,
+	})
+	require.NoError(t, err)
+
+	push := &Message{Title: "Circuit breaker tripped", Body: "Trading paused", Link: "/risk", Data: map[string]string{"alertId": "alert1"}}
+	require.NoError(t, provider.Push(context.Background(), "tablet", push))
+	assert.Equal(t, "com.example.trading", headers.Get("apns-topic"))
+	assert.Equal(t, "alert", headers.Get("apns-push-type"))
+	assert.Equal(t, map[string]interface{}{"title": "Circuit breaker tripped", "body": "Trading paused"}, payload["aps"].(map[string]interface{})["alert"])
+	assert.Equal(t, "/risk", payload["link"])
+	assert.Equal(t, "alert1", payload["alertId"])
+
+	assert.ErrorIs(t, provider.Push(context.Background(), "uninstalled", push), ErrUnregistered)
+	assert.ErrorIs(t, provider.Push(context.Background(), "malformed", push), ErrUnregistered)
+}
@@ -0,0 +1,147 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/alerts"
+)
+
+// Service registers users' devices and pushes notifications to them. It is the
+// alert service's sender of the push channel.
+type Service struct {
+	store     Store
+	providers map[Platform]Provider
+}
+
+// NewService creates a new Service. Devices of platforms without a provider can
+// be registered but are not pushed to.
+func NewService(store Store, providers map[Platform]Provider) *Service {
+	registered := make(map[Platform]Provider, len(providers))
+	for platform, provider := range providers {
+		if provider != nil {
+			registered[platform] = provider
+		}
+	}
+
+	return &Service{
+		store:     store,
+		providers: registered,
+	}
+}
+
+// RegisterDevice registers a device of a user. A token already registered moves
+// to the user, devices changing hands as users log in and out of the app.
+func (s *Service) RegisterDevice(userID string, request Request) (*Device, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(request.Token)
+
+	now := time.Now()
+	device, err := s.store.GetDeviceByToken(token)
+	if errors.Is(err, ErrDeviceNotFound) {
+		device = &Device{
+			ID:        uuid.New().String(),
+			Token:     token,
+			CreatedAt: now,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	device.UserID = userID
+	device.Platform = request.Platform
+	device.Name = request.Name
+	device.UpdatedAt = now
+
+	if err := s.store.SaveDevice(device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// GetDevice returns a device by ID
+func (s *Service) GetDevice(id string) (*Device, error) {
+	return s.store.GetDevice(id)
+}
+
+// ListDevices returns the devices of a user
+func (s *Service) ListDevices(userID string) ([]Device, error) {
+	return s.store.ListDevices(userID)
+}
+
+// UnregisterDevice removes a device, which is no longer pushed to
+func (s *Service) UnregisterDevice(id string) error {
+	return s.store.DeleteDevice(id)
+}
+
+// Push pushes a message to every device of a user, removing those whose token is
+// no longer registered. It fails when no device could be pushed to.
+func (s *Service) Push(ctx context.Context, userID string, message *Message) error {
+	devices, err := s.store.ListDevices(userID)
+	if err != nil {
+		return fmt.Errorf("failed to list devices: %w", err)
+	}
+	if len(devices) == 0 {
+		return ErrNoDevices
+	}
+
+	var errs []string
+	delivered := 0
+	for i := range devices {
+		device := &devices[i]
+		provider, exists := s.providers[device.Platform]
+		if !exists {
+			errs = append(errs, fmt.Sprintf("%s: platform not configured", device.Platform))
+			continue
+		}
+
+		err := provider.Push(ctx, device.Token, message)
+		if errors.Is(err, ErrUnregistered) {
+			log.Printf("Removing device %s of user %s, its token is no longer registered", device.ID, userID)
+			if err := s.store.DeleteDevice(device.ID); err != nil && !errors.Is(err, ErrDeviceNotFound) {
+				log.Printf("Error removing device %s: %v", device.ID, err)
+			}
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("device %s: %v", device.ID, err))
+			continue
+		}
+
+		delivered++
+		device.LastPushAt = time.Now()
+		if err := s.store.SaveDevice(device); err != nil {
+			log.Printf("Error saving device %s: %v", device.ID, err)
+		}
+	}
+
+	if delivered == 0 {
+		if len(errs) == 0 {
+			return ErrNoDevices
+		}
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Send pushes an alert to the devices of its user. It implements alerts.Sender.
+func (s *Service) Send(ctx context.Context, alert *alerts.Alert) error {
+	message := &Message{
+		Title: alert.RuleName,
+		Body:  alert.Message,
+		Link:  alert.Link,
+		Data: map[string]string{
+			"alertId": alert.ID,
+		},
+	}
+	if alert.RuleID != "" {
+		message.Data["ruleId"] = alert.RuleID
+	}
+	return s.Push(ctx, alert.UserID, message)
+}
@@ -0,0 +1,167 @@
+package push
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MemoryStore keeps devices in memory
+type MemoryStore struct {
+	devices map[string]Device
+	mutex   sync.RWMutex
+}
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		devices: make(map[string]Device),
+	}
+}
+
+// SaveDevice adds or replaces a device
+func (s *MemoryStore) SaveDevice(device *Device) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.devices[device.ID] = *device
+	return nil
+}
+
+// GetDevice returns a device by ID
+func (s *MemoryStore) GetDevice(id string) (*Device, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	device, exists := s.devices[id]
+	if !exists {
+		return nil, ErrDeviceNotFound
+	}
+	return &device, nil
+}
+
+// GetDeviceByToken returns the device registered with a token
+func (s *MemoryStore) GetDeviceByToken(token string) (*Device, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, device := range s.devices {
+		if device.Token == token {
+			return &device, nil
+		}
+	}
+	return nil, ErrDeviceNotFound
+}
+
+// ListDevices returns the devices of a user, oldest first
+func (s *MemoryStore) ListDevices(userID string) ([]Device, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	devices := make([]Device, 0)
+	for _, device := range s.devices {
+		if device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].CreatedAt.Before(devices[j].CreatedAt)
+	})
+	return devices, nil
+}
+
+// DeleteDevice removes a device
+func (s *MemoryStore) DeleteDevice(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.devices[id]; !exists {
+		return ErrDeviceNotFound
+	}
+	delete(s.devices, id)
+	return nil
+}
+
+// MongoStore keeps devices in a MongoDB collection
+type MongoStore struct {
+	devices *mongo.Collection
+}
+
+// NewMongoStore creates a new MongoStore
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		devices: db.Collection("push_devices"),
+	}
+}
+
+// SaveDevice adds or replaces a device
+func (s *MongoStore) SaveDevice(device *Device) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.devices.ReplaceOne(ctx, bson.M{"_id": device.ID}, device, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetDevice returns a device by ID
+func (s *MongoStore) GetDevice(id string) (*Device, error) {
+	return s.findOne(bson.M{"_id": id})
+}
+
+// GetDeviceByToken returns the device registered with a token
+func (s *MongoStore) GetDeviceByToken(token string) (*Device, error) {
+	return s.findOne(bson.M{"token": token})
+}
+
+// findOne returns the device matching a query
+func (s *MongoStore) findOne(query bson.M) (*Device, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var device Device
+	err := s.devices.FindOne(ctx, query).Decode(&device)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// ListDevices returns the devices of a user, oldest first
+func (s *MongoStore) ListDevices(userID string) ([]Device, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.devices.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	devices := make([]Device, 0)
+	if err := cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// DeleteDevice removes a device
+func (s *MongoStore) DeleteDevice(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.devices.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/context"
+)
+
+// OrderTransitionRepository defines the interface for order status transition data operations
+type OrderTransitionRepository interface {
+	Create(transition *models.OrderTransition) (*models.OrderTransition, error)
+	GetByOrderID(orderID string) ([]models.OrderTransition, error)
+}
+
+// MongoOrderTransitionRepository implements OrderTransitionRepository using MongoDB
+type MongoOrderTransitionRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoOrderTransitionRepository creates a new MongoOrderTransitionRepository
+func NewMongoOrderTransitionRepository(db *mongo.Database) OrderTransitionRepository {
+	return &MongoOrderTransitionRepository{
+		collection: db.Collection("order_transitions"),
+	}
+}
+
+// Create adds a new transition to the database
+func (r *MongoOrderTransitionRepository) Create(transition *models.OrderTransition) (*models.OrderTransition, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Generate a new ID if not provided
+	if transition.ID == "" {
+		transition.ID = primitive.NewObjectID().Hex()
+	}
+
+	// Insert the transition
+	_, err := r.collection.InsertOne(ctx, transition)
+	if err != nil {
+		return nil, err
+	}
+
+	return transition, nil
+}
+
+// GetByOrderID retrieves the transitions of an order, oldest first
+func (r *MongoOrderTransitionRepository) GetByOrderID(orderID string) ([]models.OrderTransition, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.M{"timestamp": 1})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"orderId": orderID}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var transitions []models.OrderTransition
+	if err := cursor.All(ctx, &transitions); err != nil {
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+// MemoryOrderTransitionRepository implements OrderTransitionRepository in memory
+type MemoryOrderTransitionRepository struct {
+	transitions map[string][]models.OrderTransition
+	mutex       sync.RWMutex
+}
+
+// NewMemoryOrderTransitionRepository creates a new MemoryOrderTransitionRepository
+func NewMemoryOrderTransitionRepository() OrderTransitionRepository {
+	return &MemoryOrderTransitionRepository{
+		transitions: make(map[string][]models.OrderTransition),
+	}
+}
+
+// Create adds a new transition
+func (r *MemoryOrderTransitionRepository) Create(transition *models.OrderTransition) (*models.OrderTransition, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Generate a new ID if not provided
+	if transition.ID == "" {
+		transition.ID = primitive.NewObjectID().Hex()
+	}
+
+	r.transitions[transition.OrderID] = append(r.transitions[transition.OrderID], *transition)
+	return transition, nil
+}
+
+// GetByOrderID retrieves the transitions of an order, oldest first
+func (r *MemoryOrderTransitionRepository) GetByOrderID(orderID string) ([]models.OrderTransition, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return append([]models.OrderTransition(nil), r.transitions[orderID]...), nil
+}
@@ -18,6 +18,7 @@ type StrategyRepository interface {
 	GetByID(id string) (*models.Strategy, error)
 	GetByUser(userID string) ([]models.Strategy, error)
 	GetByTag(tag string) ([]models.Strategy, error)
+	GetScheduled() ([]models.Strategy, error)
 	Update(strategy *models.Strategy) (*models.Strategy, error)
 	Delete(id string) error
 	
@@ -119,6 +120,28 @@ func (r *MongoStrategyRepository) GetByTag(tag string) ([]models.Strategy, error
 	return strategies, nil
 }
 
+// GetScheduled retrieves the strategies with run days, which only run on their run
+// days and hours
+func (r *MongoStrategyRepository) GetScheduled() ([]models.Strategy, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var strategies []models.Strategy
+	filter := bson.M{"runOnDays.0": bson.M{"$exists": true}}
+
+	cursor, err := r.db.Collection("strategies").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &strategies); err != nil {
+		return nil, err
+	}
+
+	return strategies, nil
+}
+
 // Update updates an existing strategy
 func (r *MongoStrategyRepository) Update(strategy *models.Strategy) (*models.Strategy, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -53,6 +53,7 @@ type UserRepository interface {
 	GetUserNotificationSettings(userID string) (*models.UserNotificationSettings, error)
 	CreateUserNotificationSettings(settings *models.UserNotificationSettings) (*models.UserNotificationSettings, error)
 	UpdateUserNotificationSettings(settings *models.UserNotificationSettings) (*models.UserNotificationSettings, error)
+	GetDailySummarySubscribers() ([]models.UserNotificationSettings, error)
 }
 
 // MongoUserRepository implements UserRepository using MongoDB
@@ -542,3 +543,24 @@ func (r *MongoUserRepository) UpdateUserNotificationSettings(settings *models.Us
 
 	return settings, nil
 }
+
+// GetDailySummarySubscribers retrieves the notification settings of the users
+// opted in to daily summary emails
+func (r *MongoUserRepository) GetDailySummarySubscribers() ([]models.UserNotificationSettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"dailySummaryEmails": true}
+	cursor, err := r.db.Collection("user_notification_settings").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var subscribers []models.UserNotificationSettings
+	if err := cursor.All(ctx, &subscribers); err != nil {
+		return nil, err
+	}
+
+	return subscribers, nil
+}
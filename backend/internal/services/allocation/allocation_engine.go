@@ -0,0 +1,473 @@
+package allocation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/broker/common"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/repositories"
+)
+
+var (
+	// ErrGroupNotFound is returned for allocation groups that do not exist
+	ErrGroupNotFound = errors.New("allocation group not found")
+	// ErrOrderNotFound is returned for orders that were not placed for allocation
+	ErrOrderNotFound = errors.New("allocated order not found")
+	// ErrAccessDenied is returned when a dealer uses another dealer's group
+	ErrAccessDenied = errors.New("allocation group belongs to another dealer")
+)
+
+// ClientRatio is the share of a dealer order's fills allocated to a client account
+type ClientRatio struct {
+	ClientID string  `json:"clientId"` // Client code at the broker
+	UserID   string  `json:"userId"`   // Platform user owning the client account
+	Ratio    float64 `json:"ratio"`
+}
+
+// AllocationGroup is a set of client accounts a dealer trades for together
+type AllocationGroup struct {
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	DealerUserID string        `json:"dealerUserId"`
+	AccountID    string        `json:"accountId"` // Broker account the block orders are placed into
+	Clients      []ClientRatio `json:"clients"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+}
+
+// Validate validates the allocation group
+func (g *AllocationGroup) Validate() error {
+	if g.DealerUserID == "" {
+		return errors.New("dealer user ID is required")
+	}
+	if g.AccountID == "" {
+		return errors.New("account ID is required")
+	}
+	if len(g.Clients) == 0 {
+		return errors.New("at least one client is required")
+	}
+
+	seen := make(map[string]bool)
+	for _, client := range g.Clients {
+		if client.ClientID == "" || client.UserID == "" {
+			return errors.New("client ID and user ID are required for every client")
+		}
+		if client.Ratio <= 0 {
+			return fmt.Errorf("ratio of client %s must be greater than zero", client.ClientID)
+		}
+		if seen[client.ClientID] {
+			return fmt.Errorf("client %s is listed more than once", client.ClientID)
+		}
+		seen[client.ClientID] = true
+	}
+
+	return nil
+}
+
+// Instrument describes what a dealer order trades, for the positions its fills
+// generate
+type Instrument struct {
+	Symbol         string                `json:"symbol"`
+	Exchange       string                `json:"exchange"`
+	InstrumentType models.InstrumentType `json:"instrumentType"`
+	OptionType     models.OptionType     `json:"optionType,omitempty"`
+	StrikePrice    float64               `json:"strikePrice,omitempty"`
+	Expiry         time.Time             `json:"expiry,omitempty"`
+	LotSize        int                   `json:"lotSize"` // Fills are allocated in whole lots, 1 when not set
+}
+
+// Fill is an execution of a dealer order reported by the broker
+type Fill struct {
+	OrderID   string    `json:"orderId"`
+	TradeID   string    `json:"tradeId"`
+	Quantity  int       `json:"quantity"`
+	Price     float64   `json:"price"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ClientTrade is the part of a fill allocated to a client account
+type ClientTrade struct {
+	ID         string                `json:"id"`
+	OrderID    string                `json:"orderId"`
+	TradeID    string                `json:"tradeId"`
+	ClientID   string                `json:"clientId"`
+	UserID     string                `json:"userId"`
+	Symbol     string                `json:"symbol"`
+	Exchange   string                `json:"exchange"`
+	Direction  models.OrderDirection `json:"direction"`
+	Quantity   int                   `json:"quantity"`
+	Price      float64               `json:"price"`
+	PositionID string                `json:"positionId,omitempty"`
+	Timestamp  time.Time             `json:"timestamp"`
+}
+
+// ClientAllocation is what a client account was allocated of a dealer order
+type ClientAllocation struct {
+	ClientRatio
+	Quantity     int     `json:"quantity"`
+	AveragePrice float64 `json:"averagePrice"`
+	PositionID   string  `json:"positionId,omitempty"`
+}
+
+// OrderAllocation is the allocation of a dealer order's fills across the
+// client accounts of its group
+type OrderAllocation struct {
+	OrderID        string                `json:"orderId"`
+	GroupID        string                `json:"groupId"`
+	DealerUserID   string                `json:"dealerUserId"`
+	Instrument     Instrument            `json:"instrument"`
+	Direction      models.OrderDirection `json:"direction"`
+	ProductType    models.ProductType    `json:"productType"`
+	OrderQuantity  int                   `json:"orderQuantity"`
+	FilledQuantity int                   `json:"filledQuantity"`
+	AveragePrice   float64               `json:"averagePrice"`
+	Clients        []ClientAllocation    `json:"clients"`
+	Trades         []ClientTrade         `json:"trades"`
+	CreatedAt      time.Time             `json:"createdAt"`
+}
+
+// DealerOrderPlacer places orders on behalf of clients, as the broker manager does
+type DealerOrderPlacer interface {
+	PlaceDealerOrder(dealerUserID string, targetClientID string, order *common.Order) (*common.OrderResponse, error)
+}
+
+// AllocationEngine defines the interface for post-trade allocation of dealer orders
+type AllocationEngine interface {
+	// SaveGroup creates or replaces an allocation group. Orders already placed keep
+	// the ratios they were placed with.
+	SaveGroup(group *AllocationGroup) (*AllocationGroup, error)
+	GetGroup(id string) (*AllocationGroup, error)
+	GetGroups(dealerUserID string) ([]AllocationGroup, error)
+
+	// PlaceOrder places a block order into the group's account and allocates its
+	// fills across the group's clients
+	PlaceOrder(dealerUserID, groupID string, order *common.Order, instrument Instrument) (*OrderAllocation, error)
+
+	// AllocateFill allocates a fill of a placed order, returning the client trades
+	// it generated. Fills already allocated are not allocated again.
+	AllocateFill(fill Fill) ([]ClientTrade, error)
+
+	GetAllocation(orderID string) (*OrderAllocation, error)
+}
+
+// AllocationEngineImpl implements the AllocationEngine interface
+type AllocationEngineImpl struct {
+	placer       DealerOrderPlacer
+	positionRepo repositories.PositionRepository
+	groups       map[string]*AllocationGroup
+	allocations  map[string]*OrderAllocation
+	allocated    map[string]bool // Trade IDs of allocated fills
+	mutex        sync.Mutex
+}
+
+// NewAllocationEngine creates a new AllocationEngine
+func NewAllocationEngine(placer DealerOrderPlacer, positionRepo repositories.PositionRepository) AllocationEngine {
+	return &AllocationEngineImpl{
+		placer:       placer,
+		positionRepo: positionRepo,
+		groups:       make(map[string]*AllocationGroup),
+		allocations:  make(map[string]*OrderAllocation),
+		allocated:    make(map[string]bool),
+	}
+}
+
+// SaveGroup creates or replaces an allocation group
+func (e *AllocationEngineImpl) SaveGroup(group *AllocationGroup) (*AllocationGroup, error) {
+	if err := group.Validate(); err != nil {
+		return nil, err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	now := time.Now()
+	saved := *group
+	saved.Clients = append([]ClientRatio(nil), group.Clients...)
+	if saved.ID == "" {
+		saved.ID = uuid.New().String()
+		saved.CreatedAt = now
+	} else if existing, exists := e.groups[saved.ID]; exists {
+		if existing.DealerUserID != saved.DealerUserID {
+			return nil, ErrAccessDenied
+		}
+		saved.CreatedAt = existing.CreatedAt
+	}
+	saved.UpdatedAt = now
+	e.groups[saved.ID] = &saved
+
+	result := saved
+	return &result, nil
+}
+
+// GetGroup retrieves an allocation group by ID
+func (e *AllocationEngineImpl) GetGroup(id string) (*AllocationGroup, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	group, exists := e.groups[id]
+	if !exists {
+		return nil, ErrGroupNotFound
+	}
+
+	result := *group
+	return &result, nil
+}
+
+// GetGroups retrieves the allocation groups of a dealer
+func (e *AllocationEngineImpl) GetGroups(dealerUserID string) ([]AllocationGroup, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var groups []AllocationGroup
+	for _, group := range e.groups {
+		if group.DealerUserID == dealerUserID {
+			groups = append(groups, *group)
+		}
+	}
+
+	return groups, nil
+}
+
+// PlaceOrder places a block order and registers it for allocation
+func (e *AllocationEngineImpl) PlaceOrder(dealerUserID, groupID string, order *common.Order, instrument Instrument) (*OrderAllocation, error) {
+	if order == nil {
+		return nil, errors.New("order is required")
+	}
+	if instrument.Symbol == "" || instrument.Exchange == "" || instrument.InstrumentType == "" {
+		return nil, errors.New("instrument symbol, exchange and type are required")
+	}
+	if instrument.LotSize <= 0 {
+		instrument.LotSize = 1
+	}
+	if order.OrderQuantity <= 0 || order.OrderQuantity%instrument.LotSize != 0 {
+		return nil, fmt.Errorf("order quantity must be a positive multiple of the lot size %d", instrument.LotSize)
+	}
+
+	group, err := e.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group.DealerUserID != dealerUserID {
+		return nil, ErrAccessDenied
+	}
+
+	response, err := e.placer.PlaceDealerOrder(dealerUserID, group.AccountID, order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to place dealer order: %w", err)
+	}
+
+	allocation := &OrderAllocation{
+		OrderID:       response.OrderID,
+		GroupID:       group.ID,
+		DealerUserID:  dealerUserID,
+		Instrument:    instrument,
+		Direction:     orderDirection(order.OrderSide),
+		ProductType:   models.ProductType(order.ProductType),
+		OrderQuantity: order.OrderQuantity,
+		CreatedAt:     time.Now(),
+	}
+	for _, client := range group.Clients {
+		allocation.Clients = append(allocation.Clients, ClientAllocation{ClientRatio: client})
+	}
+
+	e.mutex.Lock()
+	e.allocations[allocation.OrderID] = allocation
+	e.mutex.Unlock()
+
+	return copyAllocation(allocation), nil
+}
+
+// AllocateFill allocates a fill across the clients of its order, lot by lot, each
+// lot going to the client furthest below its share of the order's fills so far.
+// Allocating cumulatively keeps every client within a lot of its ratio however
+// the order is filled.
+func (e *AllocationEngineImpl) AllocateFill(fill Fill) ([]ClientTrade, error) {
+	if fill.TradeID == "" {
+		return nil, errors.New("trade ID is required")
+	}
+	if fill.Price <= 0 {
+		return nil, errors.New("fill price must be greater than zero")
+	}
+	if fill.Timestamp.IsZero() {
+		fill.Timestamp = time.Now()
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	allocation, exists := e.allocations[fill.OrderID]
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+	if e.allocated[fill.TradeID] {
+		return nil, nil
+	}
+
+	lotSize := allocation.Instrument.LotSize
+	if fill.Quantity <= 0 || fill.Quantity%lotSize != 0 {
+		return nil, fmt.Errorf("fill quantity must be a positive multiple of the lot size %d", lotSize)
+	}
+	if allocation.FilledQuantity+fill.Quantity > allocation.OrderQuantity {
+		return nil, fmt.Errorf("fill of %d exceeds the unfilled quantity %d of order %s",
+			fill.Quantity, allocation.OrderQuantity-allocation.FilledQuantity, fill.OrderID)
+	}
+
+	// Allocate lot by lot
+	var totalRatio float64
+	for _, client := range allocation.Clients {
+		totalRatio += client.Ratio
+	}
+	lots := make([]int, len(allocation.Clients))
+	allocatedLots := allocation.FilledQuantity / lotSize
+	for i := 0; i < fill.Quantity/lotSize; i++ {
+		allocatedLots++
+		best, bestDeficit := 0, 0.0
+		for j, client := range allocation.Clients {
+			held := client.Quantity/lotSize + lots[j]
+			deficit := client.Ratio/totalRatio*float64(allocatedLots) - float64(held)
+			if j == 0 || deficit > bestDeficit {
+				best, bestDeficit = j, deficit
+			}
+		}
+		lots[best]++
+	}
+
+	// Record the client trades
+	var trades []ClientTrade
+	for j := range allocation.Clients {
+		if lots[j] == 0 {
+			continue
+		}
+		client := &allocation.Clients[j]
+		quantity := lots[j] * lotSize
+
+		client.AveragePrice = averagePrice(client.AveragePrice, client.Quantity, fill.Price, quantity)
+		client.Quantity += quantity
+
+		trade := ClientTrade{
+			ID:        uuid.New().String(),
+			OrderID:   allocation.OrderID,
+			TradeID:   fill.TradeID,
+			ClientID:  client.ClientID,
+			UserID:    client.UserID,
+			Symbol:    allocation.Instrument.Symbol,
+			Exchange:  allocation.Instrument.Exchange,
+			Direction: allocation.Direction,
+			Quantity:  quantity,
+			Price:     fill.Price,
+			Timestamp: fill.Timestamp,
+		}
+
+		// The trade has been allocated, so a failure to update its position is
+		// logged rather than returned
+		positionID, err := e.recordPosition(allocation, client, fill.Timestamp)
+		if err != nil {
+			log.Printf("Error recording position of client %s for order %s: %v", client.ClientID, allocation.OrderID, err)
+		}
+		trade.PositionID = positionID
+
+		trades = append(trades, trade)
+	}
+
+	allocation.AveragePrice = averagePrice(allocation.AveragePrice, allocation.FilledQuantity, fill.Price, fill.Quantity)
+	allocation.FilledQuantity += fill.Quantity
+	allocation.Trades = append(allocation.Trades, trades...)
+	e.allocated[fill.TradeID] = true
+
+	return trades, nil
+}
+
+// GetAllocation retrieves the allocation of a dealer order
+func (e *AllocationEngineImpl) GetAllocation(orderID string) (*OrderAllocation, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	allocation, exists := e.allocations[orderID]
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+
+	return copyAllocation(allocation), nil
+}
+
+// recordPosition creates the client's position in the order on its first trade
+// and updates it on later ones
+func (e *AllocationEngineImpl) recordPosition(allocation *OrderAllocation, client *ClientAllocation, timestamp time.Time) (string, error) {
+	if client.PositionID != "" {
+		position, err := e.positionRepo.GetByID(client.PositionID)
+		if err != nil {
+			return client.PositionID, err
+		}
+		position.Quantity = client.Quantity
+		position.EntryPrice = client.AveragePrice
+		position.UpdatedAt = timestamp
+		_, err = e.positionRepo.Update(position)
+		return client.PositionID, err
+	}
+
+	direction := models.PositionDirectionLong
+	if allocation.Direction == models.OrderDirectionSell {
+		direction = models.PositionDirectionShort
+	}
+
+	position := &models.Position{
+		UserID:         client.UserID,
+		OrderID:        allocation.OrderID,
+		Symbol:         allocation.Instrument.Symbol,
+		Exchange:       allocation.Instrument.Exchange,
+		Direction:      direction,
+		EntryPrice:     client.AveragePrice,
+		Quantity:       client.Quantity,
+		Status:         models.PositionStatusOpen,
+		ProductType:    allocation.ProductType,
+		InstrumentType: allocation.Instrument.InstrumentType,
+		OptionType:     allocation.Instrument.OptionType,
+		StrikePrice:    allocation.Instrument.StrikePrice,
+		Expiry:         allocation.Instrument.Expiry,
+		Tags:           []string{"allocation", "client:" + client.ClientID},
+		CreatedAt:      timestamp,
+		UpdatedAt:      timestamp,
+	}
+	if err := position.Validate(); err != nil {
+		return "", err
+	}
+
+	created, err := e.positionRepo.Create(position)
+	if err != nil {
+		return "", err
+	}
+	client.PositionID = created.ID
+
+	return created.ID, nil
+}
+
+// orderDirection converts a broker order side to an order direction
+func orderDirection(side string) models.OrderDirection {
+	if strings.EqualFold(side, string(models.OrderDirectionSell)) {
+		return models.OrderDirectionSell
+	}
+	return models.OrderDirectionBuy
+}
+
+// averagePrice returns the average price of a quantity after adding to it
+func averagePrice(price float64, quantity int, addedPrice float64, addedQuantity int) float64 {
+	total := quantity + addedQuantity
+	if total == 0 {
+		return 0
+	}
+	return (price*float64(quantity) + addedPrice*float64(addedQuantity)) / float64(total)
+}
+
+// copyAllocation returns a copy of an allocation safe to use outside the lock
+func copyAllocation(allocation *OrderAllocation) *OrderAllocation {
+	result := *allocation
+	result.Clients = append([]ClientAllocation(nil), allocation.Clients...)
+	result.Trades = append([]ClientTrade(nil), allocation.Trades...)
+	return &result
+}
@@ -0,0 +1,206 @@
+package allocation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/broker/common"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockDealerOrderPlacer is a mock implementation of the DealerOrderPlacer interface
+type MockDealerOrderPlacer struct {
+	mock.Mock
+}
+
+func (m *MockDealerOrderPlacer) PlaceDealerOrder(dealerUserID string, targetClientID string, order *common.Order) (*common.OrderResponse, error) {
+	args := m.Called(dealerUserID, targetClientID, order)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.OrderResponse), args.Error(1)
+}
+
+// memoryPositionRepository keeps positions in memory for the tests
+type memoryPositionRepository struct {
+	positions map[string]*models.Position
+}
+
+func newMemoryPositionRepository() *memoryPositionRepository {
+	return &memoryPositionRepository{positions: make(map[string]*models.Position)}
+}
+
+func (r *memoryPositionRepository) Create(position *models.Position) (*models.Position, error) {
+	position.ID = fmt.Sprintf("position%d", len(r.positions)+1)
+	stored := *position
+	r.positions[position.ID] = &stored
+	return position, nil
+}
+
+func (r *memoryPositionRepository) GetByID(id string) (*models.Position, error) {
+	position, exists := r.positions[id]
+	if !exists {
+		return nil, errors.New("position not found")
+	}
+	result := *position
+	return &result, nil
+}
+
+func (r *memoryPositionRepository) GetAll(filter models.PositionFilter, offset, limit int) ([]models.Position, int, error) {
+	var positions []models.Position
+	for _, position := range r.positions {
+		positions = append(positions, *position)
+	}
+	return positions, len(positions), nil
+}
+
+func (r *memoryPositionRepository) Update(position *models.Position) (*models.Position, error) {
+	stored := *position
+	r.positions[position.ID] = &stored
+	return position, nil
+}
+
+func (r *memoryPositionRepository) Delete(id string) error {
+	delete(r.positions, id)
+	return nil
+}
+
+// dealerGroup returns a group of three clients allocated 50:30:20
+func dealerGroup() *AllocationGroup {
+	return &AllocationGroup{
+		Name:         "HNI pool",
+		DealerUserID: "dealer1",
+		AccountID:    "PRO01",
+		Clients: []ClientRatio{
+			{ClientID: "C1", UserID: "user1", Ratio: 5},
+			{ClientID: "C2", UserID: "user2", Ratio: 3},
+			{ClientID: "C3", UserID: "user3", Ratio: 2},
+		},
+	}
+}
+
+func TestSaveGroup(t *testing.T) {
+	engine := NewAllocationEngine(new(MockDealerOrderPlacer), newMemoryPositionRepository())
+
+	group, err := engine.SaveGroup(dealerGroup())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, group.ID)
+
+	groups, err := engine.GetGroups("dealer1")
+	assert.NoError(t, err)
+	assert.Len(t, groups, 1)
+
+	// Test invalid ratios and duplicate clients
+	invalid := dealerGroup()
+	invalid.Clients[1].Ratio = 0
+	_, err = engine.SaveGroup(invalid)
+	assert.Error(t, err)
+
+	invalid = dealerGroup()
+	invalid.Clients[2].ClientID = "C1"
+	_, err = engine.SaveGroup(invalid)
+	assert.Error(t, err)
+
+	// Test another dealer cannot replace the group
+	other := dealerGroup()
+	other.ID = group.ID
+	other.DealerUserID = "dealer2"
+	_, err = engine.SaveGroup(other)
+	assert.Equal(t, ErrAccessDenied, err)
+
+	_, err = engine.GetGroup("missing")
+	assert.Equal(t, ErrGroupNotFound, err)
+}
+
+func TestAllocateFill(t *testing.T) {
+	placer := new(MockDealerOrderPlacer)
+	positions := newMemoryPositionRepository()
+	engine := NewAllocationEngine(placer, positions)
+
+	group, err := engine.SaveGroup(dealerGroup())
+	assert.NoError(t, err)
+
+	order := &common.Order{
+		ExchangeSegment:      "NSEFO",
+		ExchangeInstrumentID: "35001",
+		ProductType:          "NRML",
+		OrderType:            "LIMIT",
+		OrderSide:            "SELL",
+		OrderQuantity:        500,
+		LimitPrice:           120,
+	}
+	instrument := Instrument{
+		Symbol:         "NIFTY24DECFUT",
+		Exchange:       "NFO",
+		InstrumentType: models.InstrumentTypeFuture,
+		LotSize:        50,
+	}
+	placer.On("PlaceDealerOrder", "dealer1", "PRO01", order).Return(&common.OrderResponse{OrderID: "order123"}, nil)
+
+	// Test the order must be in whole lots and placed by the group's dealer
+	_, err = engine.PlaceOrder("dealer1", group.ID, &common.Order{OrderQuantity: 120}, instrument)
+	assert.Error(t, err)
+	_, err = engine.PlaceOrder("dealer2", group.ID, order, instrument)
+	assert.Equal(t, ErrAccessDenied, err)
+
+	allocation, err := engine.PlaceOrder("dealer1", group.ID, order, instrument)
+	assert.NoError(t, err)
+	assert.Equal(t, "order123", allocation.OrderID)
+	assert.Equal(t, models.OrderDirectionSell, allocation.Direction)
+	assert.Len(t, allocation.Clients, 3)
+
+	// A first partial fill of 3 lots goes to the clients furthest below their share
+	trades, err := engine.AllocateFill(Fill{OrderID: "order123", TradeID: "trade1", Quantity: 150, Price: 120})
+	assert.NoError(t, err)
+	quantities := make(map[string]int)
+	for _, trade := range trades {
+		quantities[trade.ClientID] = trade.Quantity
+		assert.Equal(t, "user"+trade.ClientID[1:], trade.UserID)
+		assert.Equal(t, models.OrderDirectionSell, trade.Direction)
+		assert.NotEmpty(t, trade.PositionID)
+	}
+	assert.Equal(t, map[string]int{"C1": 50, "C2": 50, "C3": 50}, quantities)
+
+	// Test a fill is not allocated twice
+	trades, err = engine.AllocateFill(Fill{OrderID: "order123", TradeID: "trade1", Quantity: 150, Price: 120})
+	assert.NoError(t, err)
+	assert.Empty(t, trades)
+
+	// Test fills in part lots and beyond the order are rejected
+	_, err = engine.AllocateFill(Fill{OrderID: "order123", TradeID: "trade2", Quantity: 75, Price: 121})
+	assert.Error(t, err)
+	_, err = engine.AllocateFill(Fill{OrderID: "order123", TradeID: "trade2", Quantity: 400, Price: 121})
+	assert.Error(t, err)
+	_, err = engine.AllocateFill(Fill{OrderID: "missing", TradeID: "trade2", Quantity: 50, Price: 121})
+	assert.Equal(t, ErrOrderNotFound, err)
+
+	// Once filled, every client holds exactly its ratio
+	_, err = engine.AllocateFill(Fill{OrderID: "order123", TradeID: "trade2", Quantity: 350, Price: 124})
+	assert.NoError(t, err)
+
+	allocation, err = engine.GetAllocation("order123")
+	assert.NoError(t, err)
+	assert.Equal(t, 500, allocation.FilledQuantity)
+	assert.InDelta(t, 122.8, allocation.AveragePrice, 0.001)
+	assert.Equal(t, 250, allocation.Clients[0].Quantity)
+	assert.Equal(t, 150, allocation.Clients[1].Quantity)
+	assert.Equal(t, 100, allocation.Clients[2].Quantity)
+	assert.InDelta(t, 123.2, allocation.Clients[0].AveragePrice, 0.001)
+	assert.InDelta(t, 122.667, allocation.Clients[1].AveragePrice, 0.001)
+	assert.InDelta(t, 122, allocation.Clients[2].AveragePrice, 0.001)
+
+	// Test each client has one short position at its average price
+	assert.Len(t, positions.positions, 3)
+	position, err := positions.GetByID(allocation.Clients[0].PositionID)
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", position.UserID)
+	assert.Equal(t, "order123", position.OrderID)
+	assert.Equal(t, models.PositionDirectionShort, position.Direction)
+	assert.Equal(t, 250, position.Quantity)
+	assert.InDelta(t, 123.2, position.EntryPrice, 0.001)
+
+	placer.AssertExpectations(t)
+}
@@ -0,0 +1,333 @@
+// Package brokersession watches the broker sessions of users' accounts, alerting
+// users before and when they expire, with a link to log in again. XTS requires a
+// login every day, its tokens being invalidated at a fixed time whatever their
+// expiry.
+package brokersession
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/alerts"
+	"github.com/trading-platform/backend/internal/broker/common"
+)
+
+const (
+	// DefaultInterval is how often sessions are checked
+	DefaultInterval = time.Minute
+
+	// DefaultWarnBefore is how long before their expiry users are warned of it
+	DefaultWarnBefore = 30 * time.Minute
+
+	// DefaultReauthURL is the link users follow to log in to a broker account
+	// again, {clientId} being replaced with the account's client ID
+	DefaultReauthURL = "/brokers/login?clientId={clientId}"
+
+	// clockFormat is the format of the daily expiry time
+	clockFormat = "15:04"
+)
+
+// Status is the health of a broker session
+type Status string
+
+const (
+	StatusHealthy   Status = "HEALTHY"
+	StatusExpiring  Status = "EXPIRING"   // Expires within the warning period
+	StatusExpired   Status = "EXPIRED"    // Expired without the user logging in again
+	StatusLoggedOut Status = "LOGGED_OUT" // Logged out since last checked
+)
+
+// SessionSource lists the broker sessions logged in, as the broker manager does
+type SessionSource interface {
+	Sessions() []common.SessionInfo
+}
+
+// Notifier delivers notifications to users over channels, as the alert service
+// does
+type Notifier interface {
+	Notify(userID, name, message, link string, channels []alerts.Channel) alerts.Alert
+}
+
+// Config configures the session monitor
+type Config struct {
+	Interval    time.Duration    // Between checks, defaults to DefaultInterval
+	WarnBefore  time.Duration    // Defaults to DefaultWarnBefore
+	DailyExpiry string           // HH:MM at which XTS sessions end every day, none when empty
+	Location    *time.Location   // Of the daily expiry, defaults to local time
+	ReauthURL   string           // Defaults to DefaultReauthURL
+	Channels    []alerts.Channel // Users are notified over, defaults to in-app and email
+}
+
+// Health is the state of a broker account's session
+type Health struct {
+	ClientID  string            `json:"clientId"`
+	UserID    string            `json:"userId"`
+	Broker    common.BrokerType `json:"broker,omitempty"`
+	Status    Status            `json:"status"`
+	LoginAt   time.Time         `json:"loginAt,omitempty"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+	ExpiresIn int64             `json:"expiresIn"` // Seconds, zero once expired
+	ReauthURL string            `json:"reauthUrl,omitempty"`
+	CheckedAt time.Time         `json:"checkedAt"`
+}
+
+// RunResult is the outcome of one check of every session
+type RunResult struct {
+	Time     time.Time `json:"time"`
+	Accounts []Health  `json:"accounts"`
+	Notified []Health  `json:"notified"` // Accounts whose users were notified
+}
+
+// tracked is what the monitor knows of an account's session
+type tracked struct {
+	health   Health
+	notified Status // Status the user was last notified of, for this session
+}
+
+// Monitor checks the broker sessions of users' accounts every interval, notifying
+// users once when a session is about to expire and once when it has expired. A
+// new login starts a new session, of which users are notified again.
+type Monitor struct {
+	source   SessionSource
+	notifier Notifier
+	config   Config
+	accounts map[string]*tracked // By client ID
+	stop     chan struct{}
+	mutex    sync.Mutex
+}
+
+// NewMonitor creates a new Monitor. Without a notifier session health is tracked
+// but users are not notified.
+func NewMonitor(source SessionSource, notifier Notifier, config Config) (*Monitor, error) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.WarnBefore <= 0 {
+		config.WarnBefore = DefaultWarnBefore
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.ReauthURL == "" {
+		config.ReauthURL = DefaultReauthURL
+	}
+	if len(config.Channels) == 0 {
+		config.Channels = []alerts.Channel{alerts.ChannelInApp, alerts.ChannelEmail}
+	}
+	if config.DailyExpiry != "" {
+		if _, err := time.Parse(clockFormat, config.DailyExpiry); err != nil {
+			return nil, fmt.Errorf("daily expiry must be HH:MM: %w", err)
+		}
+	}
+
+	return &Monitor{
+		source:   source,
+		notifier: notifier,
+		config:   config,
+		accounts: make(map[string]*tracked),
+	}, nil
+}
+
+// Start checks sessions every interval until the monitor is stopped
+func (m *Monitor) Start() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		return errors.New("broker session monitor is already running")
+	}
+	m.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				m.Run(now)
+			}
+		}
+	}(m.stop)
+
+	return nil
+}
+
+// Stop stops the monitor
+func (m *Monitor) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+// Run checks every session as of now, notifying the users of those that started
+// expiring or expired since they were last notified
+func (m *Monitor) Run(now time.Time) *RunResult {
+	sessions := m.source.Sessions()
+
+	m.mutex.Lock()
+	seen := make(map[string]bool, len(sessions))
+	var due []Health
+	for _, session := range sessions {
+		seen[session.ClientID] = true
+
+		account, exists := m.accounts[session.ClientID]
+		if !exists || !account.health.LoginAt.Equal(session.LoginAt) || account.health.UserID != session.UserID {
+			// A new session, of which the user has not been notified
+			account = &tracked{}
+			m.accounts[session.ClientID] = account
+		}
+		account.health = m.check(session, now)
+
+		status := account.health.Status
+		if (status == StatusExpiring || status == StatusExpired) && account.notified != status {
+			account.notified = status
+			due = append(due, account.health)
+		}
+	}
+
+	// Sessions no longer listed were logged out, which users do deliberately
+	for clientID, account := range m.accounts {
+		if !seen[clientID] {
+			account.health.Status = StatusLoggedOut
+			account.health.ExpiresIn = 0
+			account.health.CheckedAt = now
+		}
+	}
+	accounts := m.list("")
+	m.mutex.Unlock()
+
+	result := &RunResult{
+		Time:     now,
+		Accounts: accounts,
+		Notified: []Health{},
+	}
+	if m.notifier == nil {
+		return result
+	}
+	for _, health := range due {
+		m.notify(health)
+		result.Notified = append(result.Notified, health)
+	}
+
+	return result
+}
+
+// Health returns the session health of a user's broker accounts as last checked,
+// or of every account when userID is empty
+func (m *Monitor) Health(userID string) []Health {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.list(userID)
+}
+
+// list returns the session health of a user's accounts, or of every account when
+// userID is empty, by client ID. The caller holds the mutex.
+func (m *Monitor) list(userID string) []Health {
+	accounts := []Health{}
+	for _, account := range m.accounts {
+		if userID == "" || account.health.UserID == userID {
+			accounts = append(accounts, account.health)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].ClientID < accounts[j].ClientID
+	})
+	return accounts
+}
+
+// check returns the health of a session as of now
+func (m *Monitor) check(session common.SessionInfo, now time.Time) Health {
+	health := Health{
+		ClientID:  session.ClientID,
+		UserID:    session.UserID,
+		Broker:    session.Broker,
+		LoginAt:   session.LoginAt,
+		ExpiresAt: m.expiry(session),
+		ReauthURL: m.reauthURL(session.ClientID),
+		CheckedAt: now,
+	}
+
+	remaining := health.ExpiresAt.Sub(now)
+	switch {
+	case remaining <= 0:
+		health.Status = StatusExpired
+	case remaining <= m.config.WarnBefore:
+		health.Status = StatusExpiring
+	default:
+		health.Status = StatusHealthy
+	}
+	if remaining > 0 {
+		health.ExpiresIn = int64(remaining / time.Second)
+	}
+
+	return health
+}
+
+// expiry returns when a session ends: at its token's expiry, or for XTS sessions
+// at the first daily expiry after the login when that is earlier
+func (m *Monitor) expiry(session common.SessionInfo) time.Time {
+	expiresAt := session.ExpiresAt
+	if m.config.DailyExpiry == "" || session.LoginAt.IsZero() || !isXTS(session.Broker) {
+		return expiresAt
+	}
+
+	clock, _ := time.Parse(clockFormat, m.config.DailyExpiry)
+	login := session.LoginAt.In(m.config.Location)
+	daily := time.Date(login.Year(), login.Month(), login.Day(), clock.Hour(), clock.Minute(), 0, 0, m.config.Location)
+	if !daily.After(login) {
+		daily = daily.AddDate(0, 0, 1)
+	}
+	if expiresAt.IsZero() || daily.Before(expiresAt) {
+		return daily
+	}
+	return expiresAt
+}
+
+// reauthURL returns the link to log in to an account again
+func (m *Monitor) reauthURL(clientID string) string {
+	return strings.ReplaceAll(m.config.ReauthURL, "{clientId}", url.QueryEscape(clientID))
+}
+
+// notify notifies a user that their account's session is about to expire or has
+// expired
+func (m *Monitor) notify(health Health) {
+	broker := string(health.Broker)
+	if broker == "" {
+		broker = "broker"
+	}
+
+	var name, message string
+	if health.Status == StatusExpired {
+		name = "Broker session expired"
+		message = fmt.Sprintf("Your %s session for account %s expired at %s. Orders cannot be placed until you log in again.",
+			broker, health.ClientID, health.ExpiresAt.In(m.config.Location).Format("15:04 MST"))
+	} else {
+		name = "Broker session expiring"
+		message = fmt.Sprintf("Your %s session for account %s expires at %s, in %d minutes. Log in again to keep trading.",
+			broker, health.ClientID, health.ExpiresAt.In(m.config.Location).Format("15:04 MST"), (health.ExpiresIn+59)/60)
+	}
+
+	alert := m.notifier.Notify(health.UserID, name, message, health.ReauthURL, m.config.Channels)
+	if len(alert.Errors) > 0 {
+		log.Printf("Error notifying user %s of broker session %s: %s", health.UserID, health.ClientID, strings.Join(alert.Errors, "; "))
+	}
+}
+
+// isXTS reports whether a broker is XTS, whose sessions end every day
+func isXTS(broker common.BrokerType) bool {
+	return broker == common.BrokerTypeXTSPro || broker == common.BrokerTypeXTSClient
+}
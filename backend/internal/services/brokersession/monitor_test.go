@@ -0,0 +1,133 @@
+package brokersession
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/alerts"
+	"github.com/trading-platform/backend/internal/broker/common"
+)
+
+// stubSessions is a session source listing fixed sessions
+type stubSessions struct {
+	sessions []common.SessionInfo
+}
+
+func (s *stubSessions) Sessions() []common.SessionInfo {
+	return s.sessions
+}
+
+// recordingNotifier records the notifications it is asked to deliver
+type recordingNotifier struct {
+	alerts []alerts.Alert
+	mutex  sync.Mutex
+}
+
+func (n *recordingNotifier) Notify(userID, name, message, link string, channels []alerts.Channel) alerts.Alert {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	alert := alerts.Alert{UserID: userID, RuleName: name, Message: message, Link: link, Channels: channels}
+	n.alerts = append(n.alerts, alert)
+	return alert
+}
+
+func TestRun(t *testing.T) {
+	login := time.Date(2024, 3, 14, 9, 0, 0, 0, time.UTC)
+	source := &stubSessions{sessions: []common.SessionInfo{
+		{ClientID: "client1", UserID: "user1", Broker: common.BrokerTypeXTSPro, LoginAt: login, ExpiresAt: login.Add(8 * time.Hour)},
+		{ClientID: "client2", UserID: "user2", Broker: common.BrokerTypeZerodha, LoginAt: login, ExpiresAt: login.Add(24 * time.Hour)},
+	}}
+	notifier := &recordingNotifier{}
+
+	monitor, err := NewMonitor(source, notifier, Config{Location: time.UTC, ReauthURL: "https://app.example.com/brokers/login?clientId={clientId}"})
+	require.NoError(t, err)
+
+	result := monitor.Run(login.Add(time.Hour))
+	require.Len(t, result.Accounts, 2)
+	assert.Equal(t, StatusHealthy, result.Accounts[0].Status)
+	assert.Equal(t, int64(7*3600), result.Accounts[0].ExpiresIn)
+	assert.Equal(t, "https://app.example.com/brokers/login?clientId=client1", result.Accounts[0].ReauthURL)
+	assert.Empty(t, result.Notified)
+
+	// Users are warned once of sessions about to expire
+	result = monitor.Run(login.Add(7*time.Hour + 40*time.Minute))
+	require.Len(t, result.Notified, 1)
+	assert.Equal(t, StatusExpiring, result.Notified[0].Status)
+	monitor.Run(login.Add(7*time.Hour + 50*time.Minute))
+	require.Len(t, notifier.alerts, 1)
+	assert.Equal(t, "user1", notifier.alerts[0].UserID)
+	assert.Equal(t, "Broker session expiring", notifier.alerts[0].RuleName)
+	assert.Contains(t, notifier.alerts[0].Message, "expires at 17:00 UTC, in 20 minutes")
+	assert.Equal(t, "https://app.example.com/brokers/login?clientId=client1", notifier.alerts[0].Link)
+	assert.Equal(t, []alerts.Channel{alerts.ChannelInApp, alerts.ChannelEmail}, notifier.alerts[0].Channels)
+
+	// And once when they expired
+	result = monitor.Run(login.Add(8 * time.Hour))
+	require.Len(t, result.Notified, 1)
+	assert.Equal(t, StatusExpired, result.Notified[0].Status)
+	assert.Equal(t, int64(0), result.Notified[0].ExpiresIn)
+	monitor.Run(login.Add(9 * time.Hour))
+	require.Len(t, notifier.alerts, 2)
+	assert.Equal(t, "Broker session expired", notifier.alerts[1].RuleName)
+
+	// Logging in again starts a new session
+	relogin := login.Add(9 * time.Hour)
+	source.sessions[0].LoginAt = relogin
+	source.sessions[0].ExpiresAt = relogin.Add(10 * time.Minute)
+	monitor.Run(relogin)
+	require.Len(t, notifier.alerts, 3)
+	assert.Equal(t, "Broker session expiring", notifier.alerts[2].RuleName)
+
+	// Sessions logged out are reported without notifying users
+	source.sessions = source.sessions[1:]
+	monitor.Run(relogin.Add(time.Minute))
+	assert.Len(t, notifier.alerts, 3)
+
+	health := monitor.Health("user1")
+	require.Len(t, health, 1)
+	assert.Equal(t, StatusLoggedOut, health[0].Status)
+	assert.Len(t, monitor.Health(""), 2)
+	assert.Empty(t, monitor.Health("user3"))
+}
+
+func TestDailyExpiry(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	_, err = NewMonitor(&stubSessions{}, nil, Config{DailyExpiry: "8am"})
+	assert.Error(t, err)
+
+	monitor, err := NewMonitor(&stubSessions{}, nil, Config{DailyExpiry: "08:00", Location: kolkata})
+	require.NoError(t, err)
+
+	// XTS sessions end at the first daily expiry after the login
+	login := time.Date(2024, 3, 14, 9, 15, 0, 0, kolkata)
+	session := common.SessionInfo{ClientID: "client1", Broker: common.BrokerTypeXTSClient, LoginAt: login, ExpiresAt: login.Add(48 * time.Hour)}
+	assert.Equal(t, time.Date(2024, 3, 15, 8, 0, 0, 0, kolkata), monitor.expiry(session).In(kolkata))
+
+	login = time.Date(2024, 3, 14, 7, 0, 0, 0, kolkata)
+	session.LoginAt = login
+	assert.Equal(t, time.Date(2024, 3, 14, 8, 0, 0, 0, kolkata), monitor.expiry(session).In(kolkata))
+
+	// Unless their token expires earlier
+	session.ExpiresAt = login.Add(30 * time.Minute)
+	assert.Equal(t, session.ExpiresAt, monitor.expiry(session))
+
+	// Other brokers' sessions end at their token's expiry
+	session.Broker = common.BrokerTypeZerodha
+	session.ExpiresAt = login.Add(48 * time.Hour)
+	assert.Equal(t, session.ExpiresAt, monitor.expiry(session))
+
+	// Health is tracked without a notifier
+	source := &stubSessions{sessions: []common.SessionInfo{{ClientID: "client1", UserID: "user1", Broker: common.BrokerTypeXTSPro, LoginAt: login, ExpiresAt: login.Add(24 * time.Hour)}}}
+	monitor, err = NewMonitor(source, nil, Config{DailyExpiry: "08:00", Location: kolkata})
+	require.NoError(t, err)
+	result := monitor.Run(login.Add(45 * time.Minute))
+	require.Len(t, result.Accounts, 1)
+	assert.Equal(t, StatusExpiring, result.Accounts[0].Status)
+	assert.Empty(t, result.Notified)
+}
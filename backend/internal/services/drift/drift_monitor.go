@@ -0,0 +1,601 @@
+// Package drift tracks live strategies against the backtest they were promoted
+// from. The monitor backtests each live strategy linked to a backtest over the
+// period it has been trading live, with the backtest's settings, and compares
+// the fills and P&L the backtest expected with those realized live. Users are
+// alerted when the slippage, win rate or P&L per trade drift beyond thresholds,
+// a sign of a strategy overfit to its backtest or of costs it did not model.
+package drift
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/alerts"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+const (
+	// DefaultInterval is how often live strategies are compared with their
+	// backtests, each comparison running a backtest
+	DefaultInterval = 24 * time.Hour
+
+	// DefaultPeriod is the trailing period compared, shorter when the strategy
+	// has been live for less
+	DefaultPeriod = 30 * 24 * time.Hour
+
+	// DefaultMinTrades is the number of live trades needed before win rates and
+	// P&L per trade are compared
+	DefaultMinTrades = 10
+
+	// DefaultMaxSlippageDelta is the extra slippage live fills may cost over the
+	// backtest's, in basis points of the traded notional
+	DefaultMaxSlippageDelta = 20.0
+
+	// DefaultMaxWinRateDelta is how far the live win rate may be from the
+	// backtest's, in percentage points
+	DefaultMaxWinRateDelta = 15.0
+
+	// pageSize is the number of orders or positions listed at a time
+	pageSize = 500
+)
+
+var (
+	// ErrStrategyNotFound is returned when the strategy does not exist
+	ErrStrategyNotFound = errors.New("strategy not found")
+
+	// ErrAccessDenied is returned when the strategy belongs to another user
+	ErrAccessDenied = errors.New("access denied")
+
+	// ErrNotTracked is returned for strategies that are not live or not linked to
+	// the backtest they were promoted from
+	ErrNotTracked = errors.New("strategy is not a live strategy promoted from a backtest")
+)
+
+// Metric is a measure compared between a backtest and live trading
+type Metric string
+
+const (
+	MetricSlippage   Metric = "SLIPPAGE"
+	MetricWinRate    Metric = "WIN_RATE"
+	MetricAveragePnL Metric = "AVERAGE_PNL"
+)
+
+// StrategyProvider looks up strategies, as the strategy repository does
+type StrategyProvider interface {
+	GetByID(id string) (*models.Strategy, error)
+}
+
+// OrderProvider lists orders, as the order repository does
+type OrderProvider interface {
+	GetAll(filter models.OrderFilter, offset, limit int) ([]models.Order, int, error)
+}
+
+// PositionProvider lists positions, as the position repository does
+type PositionProvider interface {
+	GetAll(filter models.PositionFilter, offset, limit int) ([]models.Position, int, error)
+}
+
+// Backtester looks up backtest sessions and runs strategy backtests, as the
+// backtest service does
+type Backtester interface {
+	GetBacktestSession(sessionID string) (*models.BacktestSession, error)
+	RunStrategyBacktest(session *models.BacktestSession, marketSettings *models.MarketSettings) (*models.StrategyBacktestReport, error)
+}
+
+// Notifier delivers notifications to users over channels, as the alert service
+// does
+type Notifier interface {
+	Notify(userID, name, message, link string, channels []alerts.Channel) alerts.Alert
+}
+
+// Config configures the drift monitor
+type Config struct {
+	Interval           time.Duration          // Between runs, defaults to DefaultInterval
+	Period             time.Duration          // Trailing period compared, defaults to DefaultPeriod
+	MinTrades          int                    // Defaults to DefaultMinTrades
+	MaxSlippageDelta   float64                // Basis points, defaults to DefaultMaxSlippageDelta
+	MaxWinRateDelta    float64                // Percentage points, defaults to DefaultMaxWinRateDelta
+	MaxAveragePnLDelta float64                // P&L per trade, not checked when zero
+	MarketSettings     *models.MarketSettings // Of the backtests, the backtester's defaults when nil
+	Channels           []alerts.Channel       // Users are alerted over, defaults to in-app and email
+}
+
+// Metrics are the results of a strategy over a period, backtested or live
+type Metrics struct {
+	Trades         int     `json:"trades"` // Closed positions
+	WinningTrades  int     `json:"winningTrades"`
+	WinRate        float64 `json:"winRate"` // Percent
+	PnL            float64 `json:"pnl"`
+	AveragePnL     float64 `json:"averagePnL"` // Per trade
+	Fills          int     `json:"fills"`      // Filled orders
+	FilledQuantity int     `json:"filledQuantity"`
+	Slippage       float64 `json:"slippage"`    // Cost of adverse fills
+	SlippageBps    float64 `json:"slippageBps"` // Basis points of the traded notional
+}
+
+// Breach is a metric that drifted beyond its threshold
+type Breach struct {
+	Metric    Metric  `json:"metric"`
+	Delta     float64 `json:"delta"`
+	Threshold float64 `json:"threshold"`
+}
+
+// Report compares the live results of a strategy with those its backtest
+// expected over the same period
+type Report struct {
+	StrategyID        string    `json:"strategyId"`
+	UserID            string    `json:"userId"`
+	BacktestSessionID string    `json:"backtestSessionId"`
+	From              time.Time `json:"from"`
+	To                time.Time `json:"to"`
+	Backtest          Metrics   `json:"backtest"`
+	Live              Metrics   `json:"live"`
+	SlippageDelta     float64   `json:"slippageDelta"`   // Live minus backtest, in basis points
+	WinRateDelta      float64   `json:"winRateDelta"`    // Live minus backtest, in percentage points
+	AveragePnLDelta   float64   `json:"averagePnLDelta"` // Live minus backtest
+	Breaches          []Breach  `json:"breaches"`
+	Diverged          bool      `json:"diverged"`
+	CheckedAt         time.Time `json:"checkedAt"`
+}
+
+// RunResult is the outcome of one run of the drift monitor
+type RunResult struct {
+	Time     time.Time `json:"time"`
+	Reports  []Report  `json:"reports"`
+	Notified []Report  `json:"notified"` // Strategies whose users were alerted
+	Errors   []string  `json:"errors,omitempty"`
+}
+
+// tracked is what the monitor knows of a strategy
+type tracked struct {
+	report   Report
+	notified bool // The user was alerted of the drift, until it is back within thresholds
+}
+
+// Monitor compares live strategies with their backtests every interval. Only
+// strategies that traded during the period are compared. Users are alerted once
+// when a strategy drifts, and again only after it has come back within its
+// thresholds. Slippage only drifts when live fills cost more than the backtest
+// assumed, while the win rate and P&L per trade drift either way, a live
+// strategy doing much better than its backtest being as suspect as one doing
+// worse.
+type Monitor struct {
+	strategies StrategyProvider
+	orders     OrderProvider
+	positions  PositionProvider
+	backtester Backtester
+	notifier   Notifier
+	config     Config
+	tracked    map[string]*tracked // By strategy ID
+	stop       chan struct{}
+	mutex      sync.Mutex
+}
+
+// NewMonitor creates a new Monitor. Without a notifier drift is reported but
+// users are not alerted.
+func NewMonitor(
+	strategies StrategyProvider,
+	orders OrderProvider,
+	positions PositionProvider,
+	backtester Backtester,
+	notifier Notifier,
+	config Config,
+) *Monitor {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Period <= 0 {
+		config.Period = DefaultPeriod
+	}
+	if config.MinTrades <= 0 {
+		config.MinTrades = DefaultMinTrades
+	}
+	if config.MaxSlippageDelta <= 0 {
+		config.MaxSlippageDelta = DefaultMaxSlippageDelta
+	}
+	if config.MaxWinRateDelta <= 0 {
+		config.MaxWinRateDelta = DefaultMaxWinRateDelta
+	}
+	if len(config.Channels) == 0 {
+		config.Channels = []alerts.Channel{alerts.ChannelInApp, alerts.ChannelEmail}
+	}
+
+	return &Monitor{
+		strategies: strategies,
+		orders:     orders,
+		positions:  positions,
+		backtester: backtester,
+		notifier:   notifier,
+		config:     config,
+		tracked:    make(map[string]*tracked),
+	}
+}
+
+// Start runs the monitor every interval until it is stopped
+func (m *Monitor) Start() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		return errors.New("drift monitor is already running")
+	}
+	m.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := m.Run(now)
+				if err != nil {
+					log.Printf("Error running drift monitor: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Drift monitor error: %s", message)
+				}
+			}
+		}
+	}(m.stop)
+
+	return nil
+}
+
+// Stop stops the monitor
+func (m *Monitor) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+// Run compares every live strategy promoted from a backtest that traded during
+// the period ending at now with its backtest, alerting the users of those that
+// started drifting
+func (m *Monitor) Run(now time.Time) (*RunResult, error) {
+	result := &RunResult{
+		Time:     now,
+		Reports:  []Report{},
+		Notified: []Report{},
+	}
+
+	positions, err := listPositions(m.positions, models.PositionFilter{FromDate: now.Add(-m.config.Period), ToDate: now})
+	if err != nil {
+		return nil, err
+	}
+	strategyIDs := make(map[string]bool)
+	for _, position := range positions {
+		if position.StrategyID != "" {
+			strategyIDs[position.StrategyID] = true
+		}
+	}
+
+	for strategyID := range strategyIDs {
+		strategy, err := m.strategies.GetByID(strategyID)
+		if err != nil || strategy == nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("strategy %s: %v", strategyID, ErrStrategyNotFound))
+			continue
+		}
+		if !isTracked(strategy) {
+			continue
+		}
+
+		report, err := m.compare(strategy, now)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("strategy %s: %v", strategyID, err))
+			continue
+		}
+		result.Reports = append(result.Reports, *report)
+
+		if m.record(report) && m.notifier != nil {
+			m.notify(report)
+			result.Notified = append(result.Notified, *report)
+		}
+	}
+	sortReports(result.Reports)
+	sortReports(result.Notified)
+
+	return result, nil
+}
+
+// Check compares a live strategy with its backtest now, checking it belongs to
+// userID unless empty, for admins. The user is alerted if it started drifting.
+func (m *Monitor) Check(strategyID, userID string) (*Report, error) {
+	strategy, err := m.strategies.GetByID(strategyID)
+	if err != nil || strategy == nil {
+		return nil, ErrStrategyNotFound
+	}
+	if userID != "" && strategy.UserID != userID {
+		return nil, ErrAccessDenied
+	}
+	if !isTracked(strategy) {
+		return nil, ErrNotTracked
+	}
+
+	report, err := m.compare(strategy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if m.record(report) && m.notifier != nil {
+		m.notify(report)
+	}
+
+	return report, nil
+}
+
+// Reports returns the latest report of a user's strategies, or of every strategy
+// when userID is empty, by strategy ID
+func (m *Monitor) Reports(userID string) []Report {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	reports := []Report{}
+	for _, strategy := range m.tracked {
+		if userID == "" || strategy.report.UserID == userID {
+			reports = append(reports, strategy.report)
+		}
+	}
+	sortReports(reports)
+	return reports
+}
+
+// compare backtests a strategy over the period ending at now and compares the
+// results with its live ones
+func (m *Monitor) compare(strategy *models.Strategy, now time.Time) (*Report, error) {
+	// The period starts when the strategy went live, at most the period back
+	from := strategy.PromotedAt
+	if from.IsZero() {
+		from = strategy.CreatedAt
+	}
+	if start := now.Add(-m.config.Period); from.Before(start) {
+		from = start
+	}
+
+	session, err := m.backtester.GetBacktestSession(strategy.BacktestSessionID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving backtest %s: %v", strategy.BacktestSessionID, err)
+	}
+
+	// Rerun the backtest's settings over the live period, all of it in sample
+	rerun := *session
+	rerun.ID = ""
+	rerun.Name = fmt.Sprintf("%s drift check %s", session.Name, now.Format("2006-01-02"))
+	rerun.StartDate = from
+	rerun.EndDate = now
+	rerun.Status = ""
+	rerun.CompletedAt = nil
+	rerun.HoldoutPercent = 0
+	rerun.CreatedAt = now
+	backtest, err := m.backtester.RunStrategyBacktest(&rerun, m.config.MarketSettings)
+	if err != nil {
+		return nil, fmt.Errorf("error backtesting %s to %s: %v", from.Format(time.RFC3339), now.Format(time.RFC3339), err)
+	}
+
+	live, err := m.liveMetrics(strategy.ID, from, now)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		StrategyID:        strategy.ID,
+		UserID:            strategy.UserID,
+		BacktestSessionID: strategy.BacktestSessionID,
+		From:              from,
+		To:                now,
+		Backtest:          backtestMetrics(backtest),
+		Live:              *live,
+		Breaches:          []Breach{},
+		CheckedAt:         now,
+	}
+	report.SlippageDelta = report.Live.SlippageBps - report.Backtest.SlippageBps
+	report.WinRateDelta = report.Live.WinRate - report.Backtest.WinRate
+	report.AveragePnLDelta = report.Live.AveragePnL - report.Backtest.AveragePnL
+
+	if report.Live.Fills > 0 && report.Backtest.Fills > 0 && report.SlippageDelta > m.config.MaxSlippageDelta {
+		report.Breaches = append(report.Breaches, Breach{Metric: MetricSlippage, Delta: report.SlippageDelta, Threshold: m.config.MaxSlippageDelta})
+	}
+	if report.Live.Trades >= m.config.MinTrades && report.Backtest.Trades > 0 {
+		if math.Abs(report.WinRateDelta) > m.config.MaxWinRateDelta {
+			report.Breaches = append(report.Breaches, Breach{Metric: MetricWinRate, Delta: report.WinRateDelta, Threshold: m.config.MaxWinRateDelta})
+		}
+		if m.config.MaxAveragePnLDelta > 0 && math.Abs(report.AveragePnLDelta) > m.config.MaxAveragePnLDelta {
+			report.Breaches = append(report.Breaches, Breach{Metric: MetricAveragePnL, Delta: report.AveragePnLDelta, Threshold: m.config.MaxAveragePnLDelta})
+		}
+	}
+	report.Diverged = len(report.Breaches) > 0
+
+	return report, nil
+}
+
+// liveMetrics returns the results of a strategy's live orders and of its
+// positions closed between from and to
+func (m *Monitor) liveMetrics(strategyID string, from, to time.Time) (*Metrics, error) {
+	orders, err := listOrders(m.orders, models.OrderFilter{StrategyID: strategyID, FromDate: from, ToDate: to})
+	if err != nil {
+		return nil, fmt.Errorf("error listing live orders: %v", err)
+	}
+	positions, err := listPositions(m.positions, models.PositionFilter{StrategyID: strategyID, Status: models.PositionStatusClosed, FromDate: from, ToDate: to})
+	if err != nil {
+		return nil, fmt.Errorf("error listing live positions: %v", err)
+	}
+
+	metrics := &Metrics{}
+	var notional float64
+	for _, order := range orders {
+		if order.FilledQuantity <= 0 {
+			continue
+		}
+
+		// Slippage as reported, otherwise the adverse difference between the
+		// average fill price and the order price
+		slippage := order.Slippage
+		if slippage == 0 && order.Price > 0 && order.AveragePrice > 0 {
+			slippage = order.AveragePrice - order.Price
+			if order.Direction == models.OrderDirectionSell {
+				slippage = -slippage
+			}
+		}
+
+		metrics.Fills++
+		metrics.FilledQuantity += order.FilledQuantity
+		metrics.Slippage += slippage * float64(order.FilledQuantity)
+		notional += order.AveragePrice * float64(order.FilledQuantity)
+	}
+	if notional > 0 {
+		metrics.SlippageBps = metrics.Slippage / notional * 10000
+	}
+
+	for _, position := range positions {
+		metrics.Trades++
+		metrics.PnL += position.RealizedPnL
+		if position.RealizedPnL > 0 {
+			metrics.WinningTrades++
+		}
+	}
+	setRates(metrics)
+
+	return metrics, nil
+}
+
+// record keeps the latest report of a strategy, reporting whether its user is
+// due an alert: it drifted and they were not alerted since it last was within
+// thresholds
+func (m *Monitor) record(report *Report) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	strategy, exists := m.tracked[report.StrategyID]
+	if !exists {
+		strategy = &tracked{}
+		m.tracked[report.StrategyID] = strategy
+	}
+	strategy.report = *report
+
+	if !report.Diverged {
+		strategy.notified = false
+		return false
+	}
+	if strategy.notified {
+		return false
+	}
+	strategy.notified = true
+	return true
+}
+
+// notify alerts a user that their strategy drifted from its backtest
+func (m *Monitor) notify(report *Report) {
+	details := make([]string, 0, len(report.Breaches))
+	for _, breach := range report.Breaches {
+		switch breach.Metric {
+		case MetricSlippage:
+			details = append(details, fmt.Sprintf("slippage %.1f bps live against %.1f bps backtested", report.Live.SlippageBps, report.Backtest.SlippageBps))
+		case MetricWinRate:
+			details = append(details, fmt.Sprintf("win rate %.1f%% live against %.1f%% backtested", report.Live.WinRate, report.Backtest.WinRate))
+		case MetricAveragePnL:
+			details = append(details, fmt.Sprintf("P&L per trade %.2f live against %.2f backtested", report.Live.AveragePnL, report.Backtest.AveragePnL))
+		}
+	}
+
+	message := fmt.Sprintf("Your strategy %s has drifted from its backtest since %s: %s.",
+		report.StrategyID, report.From.Format("2006-01-02"), strings.Join(details, "; "))
+	link := fmt.Sprintf("/strategies/%s/backtest-drift", report.StrategyID)
+
+	alert := m.notifier.Notify(report.UserID, "Strategy drifted from backtest", message, link, m.config.Channels)
+	if len(alert.Errors) > 0 {
+		log.Printf("Error notifying user %s of drift of strategy %s: %s", report.UserID, report.StrategyID, strings.Join(alert.Errors, "; "))
+	}
+}
+
+// backtestMetrics returns the results of a strategy backtest
+func backtestMetrics(backtest *models.StrategyBacktestReport) Metrics {
+	metrics := Metrics{
+		Trades:        backtest.TotalTrades,
+		WinningTrades: backtest.WinningTrades,
+		PnL:           backtest.NetPnL,
+	}
+
+	var notional float64
+	for _, trade := range backtest.Trades {
+		// Trades hold one entry per fill with cumulative totals, so partial fills
+		// are counted through the order's final EXECUTED entry
+		if trade.Status != models.OrderStatusExecuted || trade.FilledQuantity <= 0 {
+			continue
+		}
+
+		metrics.Fills++
+		metrics.FilledQuantity += trade.FilledQuantity
+		metrics.Slippage += trade.Slippage * float64(trade.FilledQuantity)
+		notional += trade.AveragePrice * float64(trade.FilledQuantity)
+	}
+	if notional > 0 {
+		metrics.SlippageBps = metrics.Slippage / notional * 10000
+	}
+	setRates(&metrics)
+
+	return metrics
+}
+
+// setRates sets the win rate and P&L per trade of metrics
+func setRates(metrics *Metrics) {
+	if metrics.Trades == 0 {
+		return
+	}
+	metrics.WinRate = float64(metrics.WinningTrades) / float64(metrics.Trades) * 100
+	metrics.AveragePnL = metrics.PnL / float64(metrics.Trades)
+}
+
+// isTracked reports whether a strategy is live and linked to the backtest it was
+// promoted from
+func isTracked(strategy *models.Strategy) bool {
+	return strategy.IsLive() && strategy.BacktestSessionID != ""
+}
+
+// listOrders returns every order matching filter
+func listOrders(provider OrderProvider, filter models.OrderFilter) ([]models.Order, error) {
+	var orders []models.Order
+	for offset := 0; ; offset += pageSize {
+		batch, total, err := provider.GetAll(filter, offset, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, batch...)
+		if len(batch) == 0 || offset+pageSize >= total {
+			return orders, nil
+		}
+	}
+}
+
+// listPositions returns every position matching filter
+func listPositions(provider PositionProvider, filter models.PositionFilter) ([]models.Position, error) {
+	var positions []models.Position
+	for offset := 0; ; offset += pageSize {
+		batch, total, err := provider.GetAll(filter, offset, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, batch...)
+		if len(batch) == 0 || offset+pageSize >= total {
+			return positions, nil
+		}
+	}
+}
+
+// sortReports sorts reports by strategy ID
+func sortReports(reports []Report) {
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].StrategyID < reports[j].StrategyID
+	})
+}
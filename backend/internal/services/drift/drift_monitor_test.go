@@ -0,0 +1,245 @@
+package drift
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/alerts"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// stubStrategies is a strategy provider of strategies by ID
+type stubStrategies map[string]*models.Strategy
+
+func (s stubStrategies) GetByID(id string) (*models.Strategy, error) {
+	strategy, exists := s[id]
+	if !exists {
+		return nil, errors.New("strategy not found")
+	}
+	return strategy, nil
+}
+
+// stubOrders is an order provider filtering orders by strategy
+type stubOrders struct {
+	orders []models.Order
+}
+
+func (s *stubOrders) GetAll(filter models.OrderFilter, offset, limit int) ([]models.Order, int, error) {
+	var matching []models.Order
+	for _, order := range s.orders {
+		if filter.StrategyID == "" || order.StrategyID == filter.StrategyID {
+			matching = append(matching, order)
+		}
+	}
+	start, end := bounds(len(matching), offset, limit)
+	return matching[start:end], len(matching), nil
+}
+
+// stubPositions is a position provider filtering positions by strategy and status
+type stubPositions struct {
+	positions []models.Position
+}
+
+func (s *stubPositions) GetAll(filter models.PositionFilter, offset, limit int) ([]models.Position, int, error) {
+	var matching []models.Position
+	for _, position := range s.positions {
+		if (filter.StrategyID == "" || position.StrategyID == filter.StrategyID) && (filter.Status == "" || position.Status == filter.Status) {
+			matching = append(matching, position)
+		}
+	}
+	start, end := bounds(len(matching), offset, limit)
+	return matching[start:end], len(matching), nil
+}
+
+// stubBacktester returns a fixed report, recording the sessions it runs
+type stubBacktester struct {
+	report *models.StrategyBacktestReport
+	runs   []models.BacktestSession
+}
+
+func (b *stubBacktester) GetBacktestSession(sessionID string) (*models.BacktestSession, error) {
+	if sessionID != "bt1" {
+		return nil, errors.New("backtest session not found")
+	}
+	return &models.BacktestSession{
+		ID:             sessionID,
+		Name:           "Breakout",
+		StartDate:      time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:        time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+		Symbols:        []string{"NIFTY"},
+		Timeframe:      "5m",
+		Status:         "COMPLETED",
+		GoStrategyName: "breakout",
+		HoldoutPercent: 20,
+	}, nil
+}
+
+func (b *stubBacktester) RunStrategyBacktest(session *models.BacktestSession, marketSettings *models.MarketSettings) (*models.StrategyBacktestReport, error) {
+	b.runs = append(b.runs, *session)
+	return b.report, nil
+}
+
+// recordingNotifier records the notifications it is asked to deliver
+type recordingNotifier struct {
+	alerts []alerts.Alert
+	mutex  sync.Mutex
+}
+
+func (n *recordingNotifier) Notify(userID, name, message, link string, channels []alerts.Channel) alerts.Alert {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	alert := alerts.Alert{UserID: userID, RuleName: name, Message: message, Link: link, Channels: channels}
+	n.alerts = append(n.alerts, alert)
+	return alert
+}
+
+// bounds returns the bounds of a page of n items
+func bounds(n, offset, limit int) (int, int) {
+	if offset > n {
+		offset = n
+	}
+	end := offset + limit
+	if end > n {
+		end = n
+	}
+	return offset, end
+}
+
+// closedPositions returns count positions of a strategy closed with wins of them
+// winning
+func closedPositions(strategyID string, count, wins int) []models.Position {
+	positions := make([]models.Position, 0, count)
+	for i := 0; i < count; i++ {
+		pnl := -50.0
+		if i < wins {
+			pnl = 100
+		}
+		positions = append(positions, models.Position{StrategyID: strategyID, Status: models.PositionStatusClosed, RealizedPnL: pnl})
+	}
+	return positions
+}
+
+func TestRun(t *testing.T) {
+	now := time.Date(2024, 3, 14, 16, 0, 0, 0, time.UTC)
+	promotedAt := now.AddDate(0, 0, -10)
+	strategies := stubStrategies{
+		"live1":  {ID: "live1", UserID: "user1", Environment: models.EnvironmentLive, BacktestSessionID: "bt1", PromotedAt: promotedAt},
+		"paper1": {ID: "paper1", UserID: "user1", BacktestSessionID: "bt1"},
+	}
+	orders := &stubOrders{orders: []models.Order{
+		{StrategyID: "live1", FilledQuantity: 100, AveragePrice: 100, Slippage: 0.1},
+		{StrategyID: "live1", Status: models.OrderStatusPending},
+	}}
+	positions := &stubPositions{positions: append(closedPositions("live1", 10, 3), closedPositions("paper1", 5, 5)...)}
+	backtester := &stubBacktester{report: &models.StrategyBacktestReport{
+		TotalTrades:   20,
+		WinningTrades: 12,
+		NetPnL:        2000,
+		Trades: []models.SimulationOrder{
+			{Order: models.Order{Status: models.OrderStatusExecuted, FilledQuantity: 100, AveragePrice: 100, Slippage: 0.05}},
+			{Order: models.Order{Status: models.OrderStatusPartial, FilledQuantity: 50, AveragePrice: 100, Slippage: 0.05}},
+		},
+	}}
+	notifier := &recordingNotifier{}
+	monitor := NewMonitor(strategies, orders, positions, backtester, notifier, Config{})
+
+	// The backtest is rerun over the period the strategy has been live
+	result, err := monitor.Run(now)
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	require.Len(t, backtester.runs, 1)
+	assert.Equal(t, promotedAt, backtester.runs[0].StartDate)
+	assert.Equal(t, now, backtester.runs[0].EndDate)
+	assert.Empty(t, backtester.runs[0].ID)
+	assert.Empty(t, backtester.runs[0].Status)
+	assert.Zero(t, backtester.runs[0].HoldoutPercent)
+	assert.Equal(t, "breakout", backtester.runs[0].GoStrategyName)
+
+	require.Len(t, result.Reports, 1)
+	report := result.Reports[0]
+	assert.Equal(t, "live1", report.StrategyID)
+	assert.Equal(t, 60.0, report.Backtest.WinRate)
+	assert.Equal(t, 100.0, report.Backtest.AveragePnL)
+	assert.InDelta(t, 5.0, report.Backtest.SlippageBps, 1e-9)
+	assert.Equal(t, 10, report.Live.Trades)
+	assert.Equal(t, 30.0, report.Live.WinRate)
+	assert.Equal(t, -5.0, report.Live.AveragePnL)
+	assert.Equal(t, 1, report.Live.Fills)
+	assert.InDelta(t, 10.0, report.Live.SlippageBps, 1e-9)
+	assert.InDelta(t, 5.0, report.SlippageDelta, 1e-9)
+	assert.Equal(t, -30.0, report.WinRateDelta)
+	assert.Equal(t, -105.0, report.AveragePnLDelta)
+
+	// The win rate drifted, of which the user is alerted once
+	assert.True(t, report.Diverged)
+	assert.Equal(t, []Breach{{Metric: MetricWinRate, Delta: -30, Threshold: DefaultMaxWinRateDelta}}, report.Breaches)
+	require.Len(t, result.Notified, 1)
+	require.Len(t, notifier.alerts, 1)
+	assert.Equal(t, "user1", notifier.alerts[0].UserID)
+	assert.Equal(t, "Strategy drifted from backtest", notifier.alerts[0].RuleName)
+	assert.Contains(t, notifier.alerts[0].Message, "win rate 30.0% live against 60.0% backtested")
+	assert.Equal(t, "/strategies/live1/backtest-drift", notifier.alerts[0].Link)
+
+	result, err = monitor.Run(now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, result.Notified)
+	assert.Len(t, notifier.alerts, 1)
+
+	// Back within thresholds, the strategy is alerted of again when it drifts
+	positions.positions = closedPositions("live1", 10, 6)
+	result, err = monitor.Run(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, result.Reports, 1)
+	assert.False(t, result.Reports[0].Diverged)
+	assert.Empty(t, result.Notified)
+
+	// Adverse fills drift the slippage
+	orders.orders = append(orders.orders, models.Order{StrategyID: "live1", Direction: models.OrderDirectionSell, FilledQuantity: 100, Price: 100, AveragePrice: 99.5})
+	result, err = monitor.Run(now.Add(3 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, result.Notified, 1)
+	require.Len(t, result.Notified[0].Breaches, 1)
+	assert.Equal(t, MetricSlippage, result.Notified[0].Breaches[0].Metric)
+	assert.InDelta(t, 6000.0/199.5-5, result.Notified[0].SlippageDelta, 1e-9)
+	require.Len(t, notifier.alerts, 2)
+	assert.Contains(t, notifier.alerts[1].Message, "slippage 30.1 bps live against 5.0 bps backtested")
+
+	assert.Len(t, monitor.Reports("user1"), 1)
+	assert.Empty(t, monitor.Reports("user2"))
+}
+
+func TestCheck(t *testing.T) {
+	strategies := stubStrategies{
+		"live1":  {ID: "live1", UserID: "user1", Environment: models.EnvironmentLive, BacktestSessionID: "bt1", CreatedAt: time.Now().AddDate(0, 0, -3)},
+		"live2":  {ID: "live2", UserID: "user1", Environment: models.EnvironmentLive},
+		"paper1": {ID: "paper1", UserID: "user1", BacktestSessionID: "bt1"},
+	}
+	backtester := &stubBacktester{report: &models.StrategyBacktestReport{TotalTrades: 4, WinningTrades: 2}}
+	monitor := NewMonitor(strategies, &stubOrders{}, &stubPositions{positions: closedPositions("live1", 3, 0)}, backtester, nil, Config{})
+
+	// Too few live trades to compare win rates
+	report, err := monitor.Check("live1", "user1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Live.Trades)
+	assert.Equal(t, -50.0, report.WinRateDelta)
+	assert.False(t, report.Diverged)
+	assert.Equal(t, strategies["live1"].CreatedAt, report.From)
+
+	// Admins check anyone's strategies
+	_, err = monitor.Check("live1", "")
+	assert.NoError(t, err)
+
+	_, err = monitor.Check("live1", "user2")
+	assert.ErrorIs(t, err, ErrAccessDenied)
+	_, err = monitor.Check("missing", "user1")
+	assert.ErrorIs(t, err, ErrStrategyNotFound)
+	_, err = monitor.Check("live2", "user1")
+	assert.ErrorIs(t, err, ErrNotTracked)
+	_, err = monitor.Check("paper1", "user1")
+	assert.ErrorIs(t, err, ErrNotTracked)
+}
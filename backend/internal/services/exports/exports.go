@@ -0,0 +1,629 @@
+// Package exports streams users' orders, trades and positions out as CSV or XLSX
+// files a page at a time, so that large result sets are never held in memory.
+// Exports too large to stream in a request are generated in the background and
+// users are notified when they are ready to download.
+package exports
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/alerts"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+const (
+	// DefaultPageSize is the number of orders or positions read and written at a
+	// time
+	DefaultPageSize = 500
+
+	// DefaultStreamLimit is the number of rows above which exports are generated in
+	// the background rather than streamed
+	DefaultStreamLimit = 50000
+
+	// DefaultRetention is how long the files of background exports are kept
+	DefaultRetention = 24 * time.Hour
+
+	// dateFormat is the format of export periods
+	dateFormat = "2006-01-02"
+)
+
+var (
+	// ErrInvalidDataset is returned for datasets other than orders, trades and
+	// positions
+	ErrInvalidDataset = errors.New("dataset must be orders, trades or positions")
+	// ErrInvalidFormat is returned for formats other than CSV and XLSX
+	ErrInvalidFormat = errors.New("format must be csv or xlsx")
+	// ErrInvalidDate is returned for period dates other than YYYY-MM-DD
+	ErrInvalidDate = errors.New("dates must be YYYY-MM-DD")
+	// ErrInvalidPeriod is returned for periods ending before they start
+	ErrInvalidPeriod = errors.New("from date cannot be after to date")
+	// ErrJobNotFound is returned for background exports that do not exist or are
+	// no longer kept
+	ErrJobNotFound = errors.New("export not found")
+	// ErrJobNotReady is returned for downloads of background exports that have not
+	// completed
+	ErrJobNotReady = errors.New("export is not ready")
+)
+
+// Dataset is what is exported
+type Dataset string
+
+const (
+	DatasetOrders    Dataset = "orders"
+	DatasetTrades    Dataset = "trades" // Filled orders, at the price they filled at
+	DatasetPositions Dataset = "positions"
+)
+
+// Format is the file format of an export
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// ContentType returns the content type of files of a format
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// JobStatus is the status of a background export
+type JobStatus string
+
+const (
+	JobStatusRunning   JobStatus = "RUNNING"
+	JobStatusCompleted JobStatus = "COMPLETED"
+	JobStatusFailed    JobStatus = "FAILED"
+)
+
+// OrderProvider finds orders, as the order service does
+type OrderProvider interface {
+	GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error)
+}
+
+// PositionProvider finds positions, as the position service does
+type PositionProvider interface {
+	GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error)
+}
+
+// Notifier delivers notifications to users over channels, as the alert service
+// does
+type Notifier interface {
+	Notify(userID, name, message, link string, channels []alerts.Channel) alerts.Alert
+}
+
+// Filter narrows the rows exported. Empty fields do not filter.
+type Filter struct {
+	Symbol      string `json:"symbol,omitempty"`
+	Status      string `json:"status,omitempty"`    // Of orders or positions
+	Direction   string `json:"direction,omitempty"` // BUY or SELL for orders and trades, LONG or SHORT for positions
+	PortfolioID string `json:"portfolioId,omitempty"`
+	StrategyID  string `json:"strategyId,omitempty"`
+	From        string `json:"from,omitempty"` // YYYY-MM-DD, both included
+	To          string `json:"to,omitempty"`
+}
+
+// Request is an export of a user's rows of a dataset
+type Request struct {
+	UserID  string  `json:"userId"`
+	Dataset Dataset `json:"dataset"`
+	Format  Format  `json:"format"`
+	Filter  Filter  `json:"filter"`
+}
+
+// FileName returns the name of the file a request is exported to
+func (r Request) FileName() string {
+	name := string(r.Dataset)
+	if r.Filter.From != "" {
+		name += "-from-" + r.Filter.From
+	}
+	if r.Filter.To != "" {
+		name += "-to-" + r.Filter.To
+	}
+	return name + "." + string(r.Format)
+}
+
+// Job is an export generated in the background
+type Job struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"userId"`
+	Dataset     Dataset   `json:"dataset"`
+	Format      Format    `json:"format"`
+	Filter      Filter    `json:"filter"`
+	Status      JobStatus `json:"status"`
+	FileName    string    `json:"fileName"`
+	Rows        int       `json:"rows"`
+	Size        int64     `json:"size"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	CompletedAt time.Time `json:"completedAt,omitempty"`
+	path        string
+}
+
+// Config configures the export service
+type Config struct {
+	Location    *time.Location   // Time zone of export periods and times, defaults to the local time zone
+	PageSize    int              // Defaults to DefaultPageSize
+	StreamLimit int              // Defaults to DefaultStreamLimit
+	Directory   string           // Background exports are written to, defaults to a directory in the system's temporary directory
+	Retention   time.Duration    // Defaults to DefaultRetention
+	Channels    []alerts.Channel // Users are notified of background exports over, defaults to in-app and email
+}
+
+// Service exports orders, trades and positions
+type Service struct {
+	orders    OrderProvider
+	positions PositionProvider
+	notifier  Notifier
+	config    Config
+	jobs      map[string]*Job
+	mutex     sync.Mutex
+}
+
+// NewService creates a new Service. Without a notifier users are not notified of
+// background exports and have to poll them.
+func NewService(orders OrderProvider, positions PositionProvider, notifier Notifier, config Config) *Service {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = DefaultPageSize
+	}
+	if config.StreamLimit <= 0 {
+		config.StreamLimit = DefaultStreamLimit
+	}
+	if config.Directory == "" {
+		config.Directory = filepath.Join(os.TempDir(), "trading-platform-exports")
+	}
+	if config.Retention <= 0 {
+		config.Retention = DefaultRetention
+	}
+	if len(config.Channels) == 0 {
+		config.Channels = []alerts.Channel{alerts.ChannelInApp, alerts.ChannelEmail}
+	}
+
+	return &Service{
+		orders:    orders,
+		positions: positions,
+		notifier:  notifier,
+		config:    config,
+		jobs:      make(map[string]*Job),
+	}
+}
+
+// Background reports whether an export has more rows than can be streamed in a
+// request, and is to be generated in the background
+func (s *Service) Background(request Request) (bool, error) {
+	if err := validate(request); err != nil {
+		return false, err
+	}
+	from, to, err := s.period(request.Filter)
+	if err != nil {
+		return false, err
+	}
+
+	var total int
+	if request.Dataset == DatasetPositions {
+		_, total, err = s.positions.GetPositions(positionFilter(request, from, to), 1, 1)
+	} else {
+		_, total, err = s.orders.GetOrders(orderFilter(request, from, to), 1, 1)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to count %s: %w", request.Dataset, err)
+	}
+
+	return total > s.config.StreamLimit, nil
+}
+
+// Export streams an export to w a page at a time, returning the number of rows
+// written
+func (s *Service) Export(w io.Writer, request Request) (int, error) {
+	if err := validate(request); err != nil {
+		return 0, err
+	}
+	from, to, err := s.period(request.Filter)
+	if err != nil {
+		return 0, err
+	}
+
+	sheet := strings.ToUpper(string(request.Dataset[:1])) + string(request.Dataset[1:])
+	writer, err := newRowWriter(request.Format, w, sheet)
+	if err != nil {
+		return 0, err
+	}
+
+	var rows int
+	switch request.Dataset {
+	case DatasetOrders:
+		err = writer.header(orderColumns)
+		if err == nil {
+			rows, err = s.writeOrders(writer, orderFilter(request, from, to), false)
+		}
+	case DatasetTrades:
+		err = writer.header(tradeColumns)
+		if err == nil {
+			rows, err = s.writeOrders(writer, orderFilter(request, from, to), true)
+		}
+	case DatasetPositions:
+		err = writer.header(positionColumns)
+		if err == nil {
+			rows, err = s.writePositions(writer, positionFilter(request, from, to))
+		}
+	}
+	if err != nil {
+		return rows, err
+	}
+
+	return rows, writer.close()
+}
+
+// Start generates an export in the background, notifying the user when it is
+// ready to download or has failed
+func (s *Service) Start(request Request) (*Job, error) {
+	if err := validate(request); err != nil {
+		return nil, err
+	}
+	if _, _, err := s.period(request.Filter); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.config.Directory, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	s.prune(time.Now())
+
+	job := &Job{
+		ID:        uuid.New().String(),
+		UserID:    request.UserID,
+		Dataset:   request.Dataset,
+		Format:    request.Format,
+		Filter:    request.Filter,
+		Status:    JobStatusRunning,
+		FileName:  request.FileName(),
+		CreatedAt: time.Now(),
+	}
+	job.path = filepath.Join(s.config.Directory, job.ID+"."+string(request.Format))
+
+	s.mutex.Lock()
+	s.jobs[job.ID] = job
+	copied := *job
+	s.mutex.Unlock()
+
+	log.Printf("Exporting %s of user %s in the background as %s", request.Dataset, request.UserID, job.ID)
+	go s.run(job, request)
+
+	return &copied, nil
+}
+
+// Jobs returns the background exports of a user, newest first
+func (s *Service) Jobs(userID string) []Job {
+	s.prune(time.Now())
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	jobs := []Job{}
+	for _, job := range s.jobs {
+		if job.UserID == userID {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+
+	return jobs
+}
+
+// Job returns a background export
+func (s *Service) Job(id string) (*Job, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// Open opens the file of a completed background export to download
+func (s *Service) Open(id string) (io.ReadCloser, *Job, error) {
+	job, err := s.Job(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != JobStatusCompleted {
+		return nil, job, ErrJobNotReady
+	}
+
+	file, err := os.Open(job.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, job, ErrJobNotFound
+		}
+		return nil, job, fmt.Errorf("failed to open export: %w", err)
+	}
+	return file, job, nil
+}
+
+// run generates a background export to its file
+func (s *Service) run(job *Job, request Request) {
+	rows, size, err := s.write(job.path, request)
+
+	s.mutex.Lock()
+	job.Rows = rows
+	job.Size = size
+	job.CompletedAt = time.Now()
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = JobStatusCompleted
+	}
+	s.mutex.Unlock()
+
+	if err != nil {
+		log.Printf("Error exporting %s of user %s as %s: %v", request.Dataset, request.UserID, job.ID, err)
+		os.Remove(job.path)
+	}
+
+	if s.notifier == nil {
+		return
+	}
+	if err != nil {
+		message := fmt.Sprintf("Your export of %s failed: %v", request.Dataset, err)
+		s.notifier.Notify(job.UserID, "Export failed", message, "", s.config.Channels)
+		return
+	}
+	message := fmt.Sprintf("Your export of %d %s is ready to download as %s", rows, request.Dataset, job.FileName)
+	s.notifier.Notify(job.UserID, "Export ready", message, "/api/exports/jobs/"+job.ID+"/download", s.config.Channels)
+}
+
+// write writes an export to a file, returning the number of rows and the size of
+// the file
+func (s *Service) write(path string, request Request) (int, int64, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	rows, err := s.Export(file, request)
+	if err != nil {
+		return rows, 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return rows, 0, err
+	}
+	return rows, info.Size(), nil
+}
+
+// prune forgets background exports completed longer than the retention ago and
+// removes their files
+func (s *Service) prune(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, job := range s.jobs {
+		if job.Status != JobStatusRunning && now.Sub(job.CompletedAt) > s.config.Retention {
+			os.Remove(job.path)
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// writeOrders writes the rows of orders matching filter a page at a time, only
+// those that filled when trades is set
+func (s *Service) writeOrders(writer rowWriter, filter models.OrderFilter, trades bool) (int, error) {
+	rows := 0
+	for page := 1; ; page++ {
+		orders, total, err := s.orders.GetOrders(filter, page, s.config.PageSize)
+		if err != nil {
+			return rows, fmt.Errorf("failed to get orders: %w", err)
+		}
+
+		for _, order := range orders {
+			var values []interface{}
+			if trades {
+				if order.FilledQuantity <= 0 {
+					continue
+				}
+				values = s.tradeRow(order)
+			} else {
+				values = s.orderRow(order)
+			}
+			if err := writer.row(values); err != nil {
+				return rows, err
+			}
+			rows++
+		}
+		if err := writer.flush(); err != nil {
+			return rows, err
+		}
+
+		if len(orders) == 0 || page*s.config.PageSize >= total {
+			return rows, nil
+		}
+	}
+}
+
+// writePositions writes the rows of positions matching filter a page at a time
+func (s *Service) writePositions(writer rowWriter, filter models.PositionFilter) (int, error) {
+	rows := 0
+	for page := 1; ; page++ {
+		positions, total, err := s.positions.GetPositions(filter, page, s.config.PageSize)
+		if err != nil {
+			return rows, fmt.Errorf("failed to get positions: %w", err)
+		}
+
+		for _, position := range positions {
+			if err := writer.row(s.positionRow(position)); err != nil {
+				return rows, err
+			}
+			rows++
+		}
+		if err := writer.flush(); err != nil {
+			return rows, err
+		}
+
+		if len(positions) == 0 || page*s.config.PageSize >= total {
+			return rows, nil
+		}
+	}
+}
+
+// Columns of each dataset
+var (
+	orderColumns = []string{
+		"Order ID", "Created At", "Updated At", "Symbol", "Exchange", "Direction", "Order Type", "Product Type",
+		"Instrument Type", "Option Type", "Strike Price", "Expiry", "Quantity", "Filled Quantity", "Price",
+		"Trigger Price", "Average Price", "Status", "Portfolio ID", "Strategy ID", "Leg ID", "Broker Order ID", "Error",
+	}
+	tradeColumns = []string{
+		"Executed At", "Order ID", "Symbol", "Exchange", "Direction", "Product Type", "Quantity", "Price", "Value",
+		"Portfolio ID", "Strategy ID", "Broker Order ID",
+	}
+	positionColumns = []string{
+		"Position ID", "Opened At", "Updated At", "Symbol", "Exchange", "Direction", "Product Type", "Instrument Type",
+		"Option Type", "Strike Price", "Expiry", "Quantity", "Exit Quantity", "Entry Price", "Exit Price",
+		"Unrealized P&L", "Realized P&L", "Status", "Portfolio ID", "Strategy ID",
+	}
+)
+
+// orderRow returns the row of an order
+func (s *Service) orderRow(order models.Order) []interface{} {
+	return []interface{}{
+		order.ID, s.at(order.CreatedAt), s.at(order.UpdatedAt), order.Symbol, order.Exchange, string(order.Direction),
+		string(order.OrderType), string(order.ProductType), string(order.InstrumentType), string(order.OptionType),
+		order.StrikePrice, s.date(order.Expiry), order.Quantity, order.FilledQuantity, order.Price,
+		order.TriggerPrice, order.AveragePrice, string(order.Status), order.PortfolioID, order.StrategyID,
+		order.LegID, order.BrokerOrderID, order.ErrorMessage,
+	}
+}
+
+// tradeRow returns the row of a filled order, priced at its average price or, when
+// it has none, its price
+func (s *Service) tradeRow(order models.Order) []interface{} {
+	executed := order.ExecutionTime
+	if executed.IsZero() {
+		executed = order.UpdatedAt
+	}
+	price := order.AveragePrice
+	if price == 0 {
+		price = order.Price
+	}
+
+	return []interface{}{
+		s.at(executed), order.ID, order.Symbol, order.Exchange, string(order.Direction), string(order.ProductType),
+		order.FilledQuantity, price, float64(order.FilledQuantity) * price, order.PortfolioID, order.StrategyID,
+		order.BrokerOrderID,
+	}
+}
+
+// positionRow returns the row of a position
+func (s *Service) positionRow(position models.Position) []interface{} {
+	return []interface{}{
+		position.ID, s.at(position.CreatedAt), s.at(position.UpdatedAt), position.Symbol, position.Exchange,
+		string(position.Direction), string(position.ProductType), string(position.InstrumentType),
+		string(position.OptionType), position.StrikePrice, s.date(position.Expiry), position.Quantity,
+		position.ExitQuantity, position.EntryPrice, position.ExitPrice, position.UnrealizedPnL, position.RealizedPnL,
+		string(position.Status), position.PortfolioID, position.StrategyID,
+	}
+}
+
+// at returns a time in the service's time zone
+func (s *Service) at(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.In(s.config.Location)
+}
+
+// date formats the date of a time, empty for zero times
+func (s *Service) date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(s.config.Location).Format(dateFormat)
+}
+
+// period parses the period of a filter, returning when it starts and the start of
+// the day after it ends, zero when either is not given
+func (s *Service) period(filter Filter) (time.Time, time.Time, error) {
+	var from, to time.Time
+	if filter.From != "" {
+		parsed, err := time.ParseInLocation(dateFormat, filter.From, s.config.Location)
+		if err != nil {
+			return from, to, ErrInvalidDate
+		}
+		from = parsed
+	}
+	if filter.To != "" {
+		parsed, err := time.ParseInLocation(dateFormat, filter.To, s.config.Location)
+		if err != nil {
+			return from, to, ErrInvalidDate
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, ErrInvalidPeriod
+	}
+	return from, to, nil
+}
+
+// validate checks the dataset and format of a request
+func validate(request Request) error {
+	switch request.Dataset {
+	case DatasetOrders, DatasetTrades, DatasetPositions:
+	default:
+		return ErrInvalidDataset
+	}
+	if request.Format != FormatCSV && request.Format != FormatXLSX {
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
+// orderFilter returns the filter of orders of a request
+func orderFilter(request Request, from, to time.Time) models.OrderFilter {
+	return models.OrderFilter{
+		UserID:      request.UserID,
+		Symbol:      request.Filter.Symbol,
+		Status:      models.OrderStatus(strings.ToUpper(request.Filter.Status)),
+		Direction:   models.OrderDirection(strings.ToUpper(request.Filter.Direction)),
+		PortfolioID: request.Filter.PortfolioID,
+		StrategyID:  request.Filter.StrategyID,
+		FromDate:    from,
+		ToDate:      to,
+	}
+}
+
+// positionFilter returns the filter of positions of a request
+func positionFilter(request Request, from, to time.Time) models.PositionFilter {
+	return models.PositionFilter{
+		UserID:      request.UserID,
+		Symbol:      request.Filter.Symbol,
+		Status:      models.PositionStatus(strings.ToUpper(request.Filter.Status)),
+		Direction:   models.PositionDirection(strings.ToUpper(request.Filter.Direction)),
+		PortfolioID: request.Filter.PortfolioID,
+		StrategyID:  request.Filter.StrategyID,
+		FromDate:    from,
+		ToDate:      to,
+	}
+}
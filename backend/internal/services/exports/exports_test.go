@@ -0,0 +1,277 @@
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/alerts"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// stubOrders pages through fixed orders of the user and symbol filtered on,
+// counting the pages read
+type stubOrders struct {
+	orders []models.Order
+	pages  int
+	err    error
+}
+
+func (s *stubOrders) GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error) {
+	if s.err != nil {
+		return nil, 0, s.err
+	}
+	var matching []models.Order
+	for _, order := range s.orders {
+		if order.UserID != filter.UserID || (filter.Symbol != "" && order.Symbol != filter.Symbol) {
+			continue
+		}
+		if (!filter.FromDate.IsZero() && order.CreatedAt.Before(filter.FromDate)) || (!filter.ToDate.IsZero() && !order.CreatedAt.Before(filter.ToDate)) {
+			continue
+		}
+		matching = append(matching, order)
+	}
+	s.pages++
+
+	start := (page - 1) * limit
+	if start >= len(matching) {
+		return nil, len(matching), nil
+	}
+	end := start + limit
+	if end > len(matching) {
+		end = len(matching)
+	}
+	return matching[start:end], len(matching), nil
+}
+
+// stubPositions returns fixed positions of the user and direction filtered on
+type stubPositions struct {
+	positions []models.Position
+}
+
+func (s *stubPositions) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	var positions []models.Position
+	for _, position := range s.positions {
+		if position.UserID == filter.UserID && (filter.Direction == "" || position.Direction == filter.Direction) {
+			positions = append(positions, position)
+		}
+	}
+	return positions, len(positions), nil
+}
+
+// recordingNotifier records the notifications delivered
+type recordingNotifier struct {
+	notifications []alerts.Alert
+	mutex         sync.Mutex
+}
+
+func (n *recordingNotifier) Notify(userID, name, message, link string, channels []alerts.Channel) alerts.Alert {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	alert := alerts.Alert{UserID: userID, RuleName: name, Message: message, Link: link}
+	n.notifications = append(n.notifications, alert)
+	return alert
+}
+
+func (n *recordingNotifier) all() []alerts.Alert {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return append([]alerts.Alert(nil), n.notifications...)
+}
+
+func testOrders() *stubOrders {
+	day := time.Date(2024, 3, 12, 9, 15, 0, 0, time.UTC)
+	orders := &stubOrders{}
+	for i := 0; i < 5; i++ {
+		orders.orders = append(orders.orders, models.Order{
+			ID:             "order" + string(rune('1'+i)),
+			UserID:         "user1",
+			Symbol:         "NIFTY",
+			Exchange:       "NSE",
+			Direction:      models.OrderDirectionBuy,
+			Quantity:       50,
+			FilledQuantity: 50 * (i % 2), // Every other order filled
+			Price:          100,
+			AveragePrice:   100.5 * float64(i%2),
+			Status:         models.OrderStatusExecuted,
+			CreatedAt:      day.AddDate(0, 0, i),
+			UpdatedAt:      day.AddDate(0, 0, i),
+		})
+	}
+	orders.orders = append(orders.orders, models.Order{ID: "other", UserID: "user2", Symbol: "NIFTY", CreatedAt: day})
+	return orders
+}
+
+func readCSV(t *testing.T, data []byte) [][]string {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestExportOrders(t *testing.T) {
+	orders := testOrders()
+	service := NewService(orders, &stubPositions{}, nil, Config{Location: time.UTC, PageSize: 2})
+
+	var out bytes.Buffer
+	rows, err := service.Export(&out, Request{UserID: "user1", Dataset: DatasetOrders, Format: FormatCSV})
+	require.NoError(t, err)
+	assert.Equal(t, 5, rows)
+	assert.Equal(t, 3, orders.pages) // Read two at a time
+
+	records := readCSV(t, out.Bytes())
+	require.Len(t, records, 6)
+	assert.Equal(t, orderColumns, records[0])
+	assert.Equal(t, "order1", records[1][0])
+	assert.Equal(t, "2024-03-12T09:15:00Z", records[1][1])
+	assert.Equal(t, "NIFTY", records[1][3])
+
+	// Periods include both dates
+	out.Reset()
+	rows, err = service.Export(&out, Request{UserID: "user1", Dataset: DatasetOrders, Format: FormatCSV, Filter: Filter{From: "2024-03-13", To: "2024-03-14"}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, rows)
+	records = readCSV(t, out.Bytes())
+	assert.Equal(t, "order2", records[1][0])
+	assert.Equal(t, "order3", records[2][0])
+}
+
+func TestExportTradesAndPositions(t *testing.T) {
+	positions := &stubPositions{positions: []models.Position{
+		{ID: "position1", UserID: "user1", Symbol: "NIFTY", Direction: models.PositionDirectionLong, Quantity: 50, EntryPrice: 100, RealizedPnL: 250.5, Status: models.PositionStatusClosed},
+		{ID: "position2", UserID: "user1", Symbol: "BANKNIFTY", Direction: models.PositionDirectionShort, Quantity: 15, EntryPrice: 300, Status: models.PositionStatusOpen},
+	}}
+	service := NewService(testOrders(), positions, nil, Config{Location: time.UTC, PageSize: 2})
+
+	// Trades are the orders that filled
+	var out bytes.Buffer
+	rows, err := service.Export(&out, Request{UserID: "user1", Dataset: DatasetTrades, Format: FormatCSV})
+	require.NoError(t, err)
+	assert.Equal(t, 2, rows)
+	records := readCSV(t, out.Bytes())
+	assert.Equal(t, tradeColumns, records[0])
+	assert.Equal(t, []string{"order2", "50", "100.5", "5025"}, []string{records[1][1], records[1][6], records[1][7], records[1][8]})
+
+	out.Reset()
+	rows, err = service.Export(&out, Request{UserID: "user1", Dataset: DatasetPositions, Format: FormatCSV, Filter: Filter{Direction: "short"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, rows)
+	records = readCSV(t, out.Bytes())
+	assert.Equal(t, "position2", records[1][0])
+}
+
+func TestExportXLSX(t *testing.T) {
+	service := NewService(testOrders(), &stubPositions{}, nil, Config{Location: time.UTC, PageSize: 2})
+
+	var out bytes.Buffer
+	rows, err := service.Export(&out, Request{UserID: "user1", Dataset: DatasetTrades, Format: FormatXLSX})
+	require.NoError(t, err)
+	assert.Equal(t, 2, rows)
+
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+	parts := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		reader.Close()
+		parts[file.Name] = string(content)
+	}
+
+	assert.Contains(t, parts, "[Content_Types].xml")
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="Trades"`)
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Equal(t, 3, strings.Count(sheet, "<row>"))
+	assert.Contains(t, sheet, `<t xml:space="preserve">Executed At</t>`)
+	assert.Contains(t, sheet, "<c><v>5025</v></c>")
+	assert.True(t, strings.HasSuffix(sheet, "</sheetData></worksheet>"))
+}
+
+func TestExportInvalidRequests(t *testing.T) {
+	service := NewService(testOrders(), &stubPositions{}, nil, Config{Location: time.UTC})
+
+	_, err := service.Export(io.Discard, Request{UserID: "user1", Dataset: "fills", Format: FormatCSV})
+	assert.Equal(t, ErrInvalidDataset, err)
+	_, err = service.Export(io.Discard, Request{UserID: "user1", Dataset: DatasetOrders, Format: "pdf"})
+	assert.Equal(t, ErrInvalidFormat, err)
+	_, err = service.Export(io.Discard, Request{UserID: "user1", Dataset: DatasetOrders, Format: FormatCSV, Filter: Filter{From: "03/12/2024"}})
+	assert.Equal(t, ErrInvalidDate, err)
+	_, err = service.Background(Request{UserID: "user1", Dataset: DatasetOrders, Format: FormatCSV, Filter: Filter{From: "2024-03-14", To: "2024-03-13"}})
+	assert.Equal(t, ErrInvalidPeriod, err)
+}
+
+func TestBackgroundExport(t *testing.T) {
+	notifier := &recordingNotifier{}
+	service := NewService(testOrders(), &stubPositions{}, notifier, Config{Location: time.UTC, PageSize: 2, StreamLimit: 4, Directory: t.TempDir()})
+
+	// Exports of more rows than the stream limit go to the background
+	request := Request{UserID: "user1", Dataset: DatasetOrders, Format: FormatCSV}
+	background, err := service.Background(request)
+	require.NoError(t, err)
+	assert.True(t, background)
+
+	background, err = service.Background(Request{UserID: "user1", Dataset: DatasetOrders, Format: FormatCSV, Filter: Filter{To: "2024-03-13"}})
+	require.NoError(t, err)
+	assert.False(t, background)
+
+	job, err := service.Start(request)
+	require.NoError(t, err)
+	assert.Equal(t, "orders.csv", job.FileName)
+
+	// Users are notified once it is ready to download
+	assert.Eventually(t, func() bool { return len(notifier.all()) == 1 }, time.Second, 10*time.Millisecond)
+
+	file, completed, err := service.Open(job.ID)
+	require.NoError(t, err)
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, 5, completed.Rows)
+	assert.Equal(t, int64(len(data)), completed.Size)
+	assert.Len(t, readCSV(t, data), 6)
+
+	notifications := notifier.all()
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "user1", notifications[0].UserID)
+	assert.Equal(t, "/api/exports/jobs/"+job.ID+"/download", notifications[0].Link)
+
+	jobs := service.Jobs("user1")
+	require.Len(t, jobs, 1)
+	assert.Empty(t, service.Jobs("user2"))
+
+	// Completed exports are forgotten after the retention
+	service.prune(time.Now().Add(DefaultRetention + time.Minute))
+	_, err = service.Job(job.ID)
+	assert.Equal(t, ErrJobNotFound, err)
+}
+
+func TestBackgroundExportFailure(t *testing.T) {
+	notifier := &recordingNotifier{}
+	orders := &stubOrders{err: errors.New("database unavailable")}
+	service := NewService(orders, &stubPositions{}, notifier, Config{Location: time.UTC, Directory: t.TempDir()})
+
+	job, err := service.Start(Request{UserID: "user1", Dataset: DatasetOrders, Format: FormatXLSX})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(notifier.all()) == 1 }, time.Second, 10*time.Millisecond)
+
+	failed, err := service.Job(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusFailed, failed.Status)
+	_, _, err = service.Open(job.ID)
+	assert.Equal(t, ErrJobNotReady, err)
+
+	notifications := notifier.all()
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "Export failed", notifications[0].RuleName)
+}
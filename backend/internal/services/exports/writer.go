@@ -0,0 +1,223 @@
+package exports
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rowWriter writes the rows of an export as they are read, flushing each page so
+// that exports never have to be held in memory
+type rowWriter interface {
+	header(titles []string) error
+	row(values []interface{}) error
+	flush() error
+	close() error
+}
+
+// newRowWriter creates a writer of a format to w, the sheet of spreadsheets being
+// named sheet
+func newRowWriter(format Format, w io.Writer, sheet string) (rowWriter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{out: w, writer: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w, sheet)
+	default:
+		return nil, ErrInvalidFormat
+	}
+}
+
+// flushOut flushes w through to the client when it is a streamed HTTP response
+func flushOut(w io.Writer) {
+	if flusher, ok := w.(interface{ Flush() }); ok {
+		flusher.Flush()
+	}
+}
+
+// csvWriter writes rows as CSV
+type csvWriter struct {
+	out    io.Writer
+	writer *csv.Writer
+	record []string
+}
+
+func (c *csvWriter) header(titles []string) error {
+	return c.writer.Write(titles)
+}
+
+func (c *csvWriter) row(values []interface{}) error {
+	c.record = c.record[:0]
+	for _, value := range values {
+		c.record = append(c.record, formatValue(value))
+	}
+	return c.writer.Write(c.record)
+}
+
+func (c *csvWriter) flush() error {
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		return err
+	}
+	flushOut(c.out)
+	return nil
+}
+
+func (c *csvWriter) close() error {
+	return c.flush()
+}
+
+// Parts of a workbook with a single worksheet, written ahead of the worksheet
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// xlsxWriter writes rows as an XLSX workbook. The worksheet is the last part of
+// the zip archive so its rows are compressed out as they are written, with inline
+// strings rather than a shared string table that would have to be written after
+// every row was seen.
+type xlsxWriter struct {
+	out     io.Writer
+	archive *zip.Writer
+	sheet   *bufio.Writer
+}
+
+// newXLSXWriter writes the parts of the workbook ahead of its worksheet and
+// starts the worksheet
+func newXLSXWriter(w io.Writer, sheet string) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+
+	escaped, err := escapeXML(sheet)
+	if err != nil {
+		return nil, err
+	}
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRelationships},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, escaped)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelationships},
+	}
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x := &xlsxWriter{out: w, archive: archive, sheet: bufio.NewWriter(file)}
+	if _, err := x.sheet.WriteString(xlsxSheetStart); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xlsxWriter) header(titles []string) error {
+	values := make([]interface{}, len(titles))
+	for i, title := range titles {
+		values[i] = title
+	}
+	return x.row(values)
+}
+
+func (x *xlsxWriter) row(values []interface{}) error {
+	x.sheet.WriteString("<row>")
+	for _, value := range values {
+		switch number := value.(type) {
+		case int:
+			x.sheet.WriteString("<c><v>" + strconv.Itoa(number) + "</v></c>")
+		case float64:
+			x.sheet.WriteString("<c><v>" + strconv.FormatFloat(number, 'f', -1, 64) + "</v></c>")
+		default:
+			text := formatValue(value)
+			if text == "" {
+				x.sheet.WriteString("<c/>")
+				continue
+			}
+			escaped, err := escapeXML(text)
+			if err != nil {
+				return err
+			}
+			x.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">` + escaped + "</t></is></c>")
+		}
+	}
+	_, err := x.sheet.WriteString("</row>")
+	return err
+}
+
+func (x *xlsxWriter) flush() error {
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	if err := x.archive.Flush(); err != nil {
+		return err
+	}
+	flushOut(x.out)
+	return nil
+}
+
+func (x *xlsxWriter) close() error {
+	if _, err := x.sheet.WriteString(xlsxSheetEnd); err != nil {
+		return err
+	}
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	if err := x.archive.Close(); err != nil {
+		return err
+	}
+	flushOut(x.out)
+	return nil
+}
+
+// formatValue formats a value of a row as text
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeXML escapes text for XML
+func escapeXML(text string) (string, error) {
+	var escaped strings.Builder
+	if err := xml.EscapeText(&escaped, []byte(text)); err != nil {
+		return "", err
+	}
+	return escaped.String(), nil
+}
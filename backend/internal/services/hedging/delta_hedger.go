@@ -0,0 +1,595 @@
+package hedging
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+var (
+	// ErrPortfolioNotFound is returned for portfolios that do not exist
+	ErrPortfolioNotFound = errors.New("portfolio not found")
+
+	// ErrHedgeNotFound is returned for portfolios that are not being hedged
+	ErrHedgeNotFound = errors.New("portfolio is not being hedged")
+
+	// ErrAccessDenied is returned for portfolios of another user
+	ErrAccessDenied = errors.New("access denied")
+
+	// ErrNoHedgeContract is returned when no contract satisfies a portfolio's
+	// dynamic hedge settings
+	ErrNoHedgeContract = errors.New("no contract satisfies the hedge settings")
+)
+
+// DefaultInterval is how often the hedger re-evaluates hedged portfolios
+const DefaultInterval = 30 * time.Second
+
+// pageSize is the number of positions fetched at a time
+const pageSize = 100
+
+// hedgeTag tags the orders the hedger places
+const hedgeTag = "delta-hedge"
+
+// Unsatisfied hedge actions of portfolios, for when no option satisfies the
+// optional hedge settings
+const (
+	// UnsatisfiedActionIgnoreField relaxes the max premium and min OI
+	UnsatisfiedActionIgnoreField = "IgnoreField"
+
+	// UnsatisfiedActionSkipLeg leaves the portfolio unhedged until the next run
+	UnsatisfiedActionSkipLeg = "SkipLeg"
+
+	// UnsatisfiedActionStopExecution halts hedging the portfolio
+	UnsatisfiedActionStopExecution = "StopExecution"
+)
+
+// Instrument is what a portfolio is hedged with
+type Instrument string
+
+const (
+	InstrumentFuture Instrument = "FUTURE"
+	InstrumentOption Instrument = "OPTION"
+)
+
+// HedgeStatus is the state of a hedged portfolio
+type HedgeStatus string
+
+const (
+	HedgeStatusActive HedgeStatus = "ACTIVE"
+	HedgeStatusHalted HedgeStatus = "HALTED" // By an unsatisfied hedge, until hedging is enabled again
+)
+
+// PortfolioProvider looks up portfolios
+type PortfolioProvider interface {
+	GetByID(id string) (*models.Portfolio, error)
+}
+
+// Contract is a future or option a portfolio can be hedged with
+type Contract struct {
+	Symbol         string                `json:"symbol"`
+	InstrumentType models.InstrumentType `json:"instrumentType"`
+	OptionType     models.OptionType     `json:"optionType,omitempty"`
+	StrikePrice    float64               `json:"strikePrice,omitempty"`
+	Expiry         time.Time             `json:"expiry"`
+	LastPrice      float64               `json:"lastPrice"`
+	OpenInterest   int                   `json:"openInterest"`
+	Delta          float64               `json:"delta"` // Per unit, 1 for futures
+	LotSize        int                   `json:"lotSize"`
+}
+
+// MarketData provides the underlying price and the contracts hedges are chosen from
+type MarketData interface {
+	GetLastPrice(symbol, exchange string) (float64, error)
+	GetFuture(underlying, exchange string, expiry time.Time) (*Contract, error)
+	GetOptionChain(underlying, exchange string, expiry time.Time) ([]Contract, error)
+}
+
+// Settings are how a portfolio is hedged
+type Settings struct {
+	Instrument Instrument `json:"instrument"` // Defaults to options when the portfolio has a max hedge distance, futures otherwise
+	Band       float64    `json:"band"`       // Delta either side of the target left unhedged
+}
+
+// Hedge is the state of a portfolio's delta hedging
+type Hedge struct {
+	PortfolioID  string      `json:"portfolioId"`
+	UserID       string      `json:"userId"`
+	Settings     Settings    `json:"settings"`
+	Status       HedgeStatus `json:"status"`
+	Delta        float64     `json:"delta"`       // Of the open positions at the last run
+	DeltaTarget  float64     `json:"deltaTarget"` // Of the portfolio
+	LastOrderID  string      `json:"lastOrderId,omitempty"`
+	LastHedgedAt *time.Time  `json:"lastHedgedAt,omitempty"`
+	Reason       string      `json:"reason,omitempty"` // Why the last hedge was skipped or hedging halted
+	EnabledAt    time.Time   `json:"enabledAt"`
+}
+
+// HedgeOrder is a hedge order placed by the hedger
+type HedgeOrder struct {
+	PortfolioID string                `json:"portfolioId"`
+	OrderID     string                `json:"orderId"`
+	Symbol      string                `json:"symbol"`
+	Direction   models.OrderDirection `json:"direction"`
+	Quantity    int                   `json:"quantity"`
+	Delta       float64               `json:"delta"`   // Added to the portfolio once filled
+	Relaxed     bool                  `json:"relaxed"` // Chosen ignoring the max premium and min OI
+}
+
+// RunResult is the outcome of one run of the hedger
+type RunResult struct {
+	Time    time.Time    `json:"time"`
+	Orders  []HedgeOrder `json:"orders"`
+	Skipped []Hedge      `json:"skipped"` // Portfolios left unhedged or halted for want of a contract
+	Errors  []string     `json:"errors,omitempty"`
+}
+
+// DeltaHedger keeps the aggregate delta of hedged portfolios' open positions at
+// their delta target. When the delta drifts by a lot or more from the target, the
+// hedger buys futures, or far out of the money options within the portfolio's
+// hedge distance from ATM, max hedge premium and min hedge OI, to bring it back.
+// The portfolio's unsatisfied hedge action decides what happens when no option
+// qualifies.
+type DeltaHedger struct {
+	portfolios      PortfolioProvider
+	positionService position.PositionService
+	orderService    services.OrderService
+	market          MarketData
+	interval        time.Duration
+	hedges          map[string]*Hedge // By portfolio
+	stop            chan struct{}
+	mutex           sync.Mutex
+}
+
+// NewDeltaHedger creates a new DeltaHedger running every interval, DefaultInterval
+// when not set
+func NewDeltaHedger(
+	portfolios PortfolioProvider,
+	positionService position.PositionService,
+	orderService services.OrderService,
+	market MarketData,
+	interval time.Duration,
+) *DeltaHedger {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &DeltaHedger{
+		portfolios:      portfolios,
+		positionService: positionService,
+		orderService:    orderService,
+		market:          market,
+		interval:        interval,
+		hedges:          make(map[string]*Hedge),
+	}
+}
+
+// Enable starts hedging a portfolio of the user, or restarts hedging a halted one.
+// Admins, with an empty user ID, may hedge anyone's.
+func (h *DeltaHedger) Enable(portfolioID string, settings Settings, userID string) (*Hedge, error) {
+	if portfolioID == "" {
+		return nil, errors.New("portfolio ID is required")
+	}
+	if settings.Band < 0 {
+		return nil, errors.New("band cannot be negative")
+	}
+
+	portfolio, err := h.portfolios.GetByID(portfolioID)
+	if err != nil || portfolio == nil {
+		return nil, ErrPortfolioNotFound
+	}
+	if userID != "" && portfolio.UserID != userID {
+		return nil, ErrAccessDenied
+	}
+
+	switch settings.Instrument {
+	case InstrumentFuture, InstrumentOption:
+	case "":
+		settings.Instrument = InstrumentFuture
+		if portfolio.MaxHedgeDistance > 0 {
+			settings.Instrument = InstrumentOption
+		}
+	default:
+		return nil, errors.New("invalid hedge instrument")
+	}
+	if settings.Instrument == InstrumentOption && portfolio.MaxHedgeDistance < portfolio.MinHedgeDistance {
+		return nil, errors.New("max hedge distance cannot be less than the min hedge distance")
+	}
+
+	hedge := &Hedge{
+		PortfolioID: portfolioID,
+		UserID:      portfolio.UserID,
+		Settings:    settings,
+		Status:      HedgeStatusActive,
+		DeltaTarget: portfolio.DeltaTarget,
+		EnabledAt:   time.Now(),
+	}
+
+	h.mutex.Lock()
+	h.hedges[portfolioID] = hedge
+	result := *hedge
+	h.mutex.Unlock()
+
+	log.Printf("Delta hedging of portfolio %s enabled with %s", portfolioID, settings.Instrument)
+
+	return &result, nil
+}
+
+// Disable stops hedging a portfolio of the user, leaving its hedges in place
+func (h *DeltaHedger) Disable(portfolioID string, userID string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	hedge, exists := h.hedges[portfolioID]
+	if !exists {
+		return ErrHedgeNotFound
+	}
+	if userID != "" && hedge.UserID != userID {
+		return ErrAccessDenied
+	}
+	delete(h.hedges, portfolioID)
+
+	return nil
+}
+
+// GetHedge returns the state of the hedging of a portfolio of the user
+func (h *DeltaHedger) GetHedge(portfolioID string, userID string) (*Hedge, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	hedge, exists := h.hedges[portfolioID]
+	if !exists {
+		return nil, ErrHedgeNotFound
+	}
+	if userID != "" && hedge.UserID != userID {
+		return nil, ErrAccessDenied
+	}
+
+	result := *hedge
+	return &result, nil
+}
+
+// Start runs the hedger every interval until it is stopped
+func (h *DeltaHedger) Start() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.stop != nil {
+		return errors.New("delta hedger is already running")
+	}
+	h.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result := h.Run(now)
+				for _, message := range result.Errors {
+					log.Printf("Delta hedger error: %s", message)
+				}
+			}
+		}
+	}(h.stop)
+
+	return nil
+}
+
+// Stop stops the hedger
+func (h *DeltaHedger) Stop() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.stop != nil {
+		close(h.stop)
+		h.stop = nil
+	}
+}
+
+// Run hedges every active hedged portfolio whose delta has drifted from its target
+func (h *DeltaHedger) Run(now time.Time) *RunResult {
+	result := &RunResult{
+		Time: now,
+	}
+
+	h.mutex.Lock()
+	portfolioIDs := make([]string, 0, len(h.hedges))
+	for portfolioID, hedge := range h.hedges {
+		if hedge.Status == HedgeStatusActive {
+			portfolioIDs = append(portfolioIDs, portfolioID)
+		}
+	}
+	h.mutex.Unlock()
+	sort.Strings(portfolioIDs)
+
+	for _, portfolioID := range portfolioIDs {
+		order, skipped, err := h.hedge(portfolioID, now)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("portfolio %s: %v", portfolioID, err))
+			continue
+		}
+		if order != nil {
+			result.Orders = append(result.Orders, *order)
+		}
+		if skipped != nil {
+			result.Skipped = append(result.Skipped, *skipped)
+		}
+	}
+
+	return result
+}
+
+// hedge brings a portfolio's delta back to its target, returning the order placed,
+// or the hedge's state when no contract qualified
+func (h *DeltaHedger) hedge(portfolioID string, now time.Time) (*HedgeOrder, *Hedge, error) {
+	h.mutex.Lock()
+	hedge, exists := h.hedges[portfolioID]
+	if !exists {
+		h.mutex.Unlock()
+		return nil, nil, nil
+	}
+	settings := hedge.Settings
+	lastOrderID := hedge.LastOrderID
+	h.mutex.Unlock()
+
+	// Positions only reflect a hedge once it fills, so nothing more is placed
+	// while the last hedge is working
+	if lastOrderID != "" {
+		lastOrder, err := h.orderService.GetOrderByID(lastOrderID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get hedge order %s: %w", lastOrderID, err)
+		}
+		switch lastOrder.Status {
+		case models.OrderStatusNew, models.OrderStatusPending, models.OrderStatusPartial:
+			return nil, nil, nil
+		}
+	}
+
+	portfolio, err := h.portfolios.GetByID(portfolioID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get portfolio: %w", err)
+	}
+	delta, err := h.portfolioDelta(portfolioID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to calculate delta: %w", err)
+	}
+
+	h.mutex.Lock()
+	hedge.Delta = delta
+	hedge.DeltaTarget = portfolio.DeltaTarget
+	h.mutex.Unlock()
+
+	// The delta to add is the opposite of the drift from the target
+	drift := delta - portfolio.DeltaTarget
+	if math.Abs(drift) <= settings.Band {
+		return nil, nil, nil
+	}
+
+	var contract *Contract
+	relaxed := false
+	if settings.Instrument == InstrumentOption {
+		contract, relaxed, err = h.selectOption(portfolio, drift)
+	} else {
+		contract, err = h.market.GetFuture(portfolio.Symbol, portfolio.Exchange, portfolio.Expiry)
+	}
+	if errors.Is(err, ErrNoHedgeContract) {
+		return nil, h.unsatisfied(portfolio, hedge, drift), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lotSize := contract.LotSize
+	if lotSize <= 0 {
+		lotSize = portfolioLotSize(portfolio)
+	}
+	lots := int(math.Round(math.Abs(drift) / (math.Abs(contract.Delta) * float64(lotSize))))
+	if lots == 0 {
+		return nil, nil, nil
+	}
+	quantity := lots * lotSize
+
+	// Options are bought, calls adding delta and puts taking it away. Futures are
+	// bought or sold.
+	direction := models.OrderDirectionBuy
+	if contract.InstrumentType == models.InstrumentTypeFuture && drift > 0 {
+		direction = models.OrderDirectionSell
+	}
+	hedgeDelta := -math.Copysign(math.Abs(contract.Delta)*float64(quantity), drift)
+
+	productType := portfolio.ProductType
+	if productType == "" {
+		productType = models.ProductTypeNRML
+	}
+	order := &models.Order{
+		UserID:         portfolio.UserID,
+		Symbol:         contract.Symbol,
+		Exchange:       portfolio.Exchange,
+		OrderType:      models.OrderTypeMarket,
+		Direction:      direction,
+		Quantity:       quantity,
+		ProductType:    productType,
+		InstrumentType: contract.InstrumentType,
+		OptionType:     contract.OptionType,
+		StrikePrice:    contract.StrikePrice,
+		Expiry:         contract.Expiry,
+		PortfolioID:    portfolio.ID,
+		StrategyID:     portfolio.StrategyID,
+		Tags:           []string{hedgeTag},
+		Notes:          fmt.Sprintf("Delta hedge of %.2f against a target of %.2f", delta, portfolio.DeltaTarget),
+	}
+	created, err := h.orderService.CreateOrder(order)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to place hedge order: %w", err)
+	}
+
+	hedgedAt := now
+	h.mutex.Lock()
+	hedge.LastOrderID = created.ID
+	hedge.LastHedgedAt = &hedgedAt
+	hedge.Reason = ""
+	h.mutex.Unlock()
+
+	log.Printf("Delta hedge of portfolio %s: %s %d %s for a delta of %.2f", portfolio.ID, direction, quantity, contract.Symbol, hedgeDelta)
+
+	return &HedgeOrder{
+		PortfolioID: portfolio.ID,
+		OrderID:     created.ID,
+		Symbol:      contract.Symbol,
+		Direction:   direction,
+		Quantity:    quantity,
+		Delta:       hedgeDelta,
+		Relaxed:     relaxed,
+	}, nil, nil
+}
+
+// unsatisfied applies the portfolio's unsatisfied hedge action to a hedge no
+// contract qualified for, returning the hedge's state
+func (h *DeltaHedger) unsatisfied(portfolio *models.Portfolio, hedge *Hedge, drift float64) *Hedge {
+	reason := fmt.Sprintf("no option within %d to %d strikes of ATM satisfies the hedge settings for a delta of %.2f", portfolio.MinHedgeDistance, portfolio.MaxHedgeDistance, -drift)
+	if hedge.Settings.Instrument == InstrumentFuture {
+		reason = fmt.Sprintf("no future of %s to hedge a delta of %.2f with", portfolio.Symbol, -drift)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	hedge.Reason = reason
+	if portfolio.UnsatisfiedHedgeAction == UnsatisfiedActionStopExecution {
+		hedge.Status = HedgeStatusHalted
+		log.Printf("Delta hedging of portfolio %s halted: %s", portfolio.ID, reason)
+	}
+
+	result := *hedge
+	return &result
+}
+
+// selectOption returns the option closest to ATM within the portfolio's hedge
+// distance that satisfies its max premium and min OI, and whose delta offsets the
+// drift when bought. The max premium and min OI are ignored, reported as relaxed,
+// when no option satisfies them and the unsatisfied hedge action is to ignore them.
+func (h *DeltaHedger) selectOption(portfolio *models.Portfolio, drift float64) (*Contract, bool, error) {
+	price, err := h.underlyingPrice(portfolio)
+	if err != nil {
+		return nil, false, err
+	}
+	chain, err := h.market.GetOptionChain(portfolio.Symbol, portfolio.Exchange, portfolio.Expiry)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get option chain: %w", err)
+	}
+
+	// Puts take delta away, calls add it
+	optionType := models.OptionTypeCall
+	if drift > 0 {
+		optionType = models.OptionTypePut
+	}
+	step := portfolio.StrikeStep
+	if step <= 0 {
+		return nil, false, errors.New("portfolio has no strike step")
+	}
+	atm := math.Round(price/step) * step
+
+	var candidates []Contract
+	for _, contract := range chain {
+		if contract.OptionType != optionType || contract.Delta == 0 {
+			continue
+		}
+		// Distance is counted in strikes out of the money
+		distance := (contract.StrikePrice - atm) / step
+		if optionType == models.OptionTypePut {
+			distance = -distance
+		}
+		if distance < float64(portfolio.MinHedgeDistance) || distance > float64(portfolio.MaxHedgeDistance) {
+			continue
+		}
+		candidates = append(candidates, contract)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return math.Abs(candidates[i].StrikePrice-atm) < math.Abs(candidates[j].StrikePrice-atm)
+	})
+
+	for _, contract := range candidates {
+		if portfolio.MaxHedgePremium > 0 && contract.LastPrice > portfolio.MaxHedgePremium {
+			continue
+		}
+		if portfolio.MinHedgeOI > 0 && contract.OpenInterest < portfolio.MinHedgeOI {
+			continue
+		}
+		result := contract
+		return &result, false, nil
+	}
+
+	// The unsatisfied hedge action defaults to ignoring the optional fields
+	action := portfolio.UnsatisfiedHedgeAction
+	if (action == "" || action == UnsatisfiedActionIgnoreField) && len(candidates) > 0 {
+		result := candidates[0]
+		return &result, true, nil
+	}
+
+	return nil, false, ErrNoHedgeContract
+}
+
+// underlyingPrice returns the price ATM is measured from, the spot or the future
+// as the portfolio's underlying reference says
+func (h *DeltaHedger) underlyingPrice(portfolio *models.Portfolio) (float64, error) {
+	if portfolio.UnderlyingRef == models.UnderlyingReferenceSpot {
+		price, err := h.market.GetLastPrice(portfolio.Symbol, portfolio.Exchange)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get price of %s: %w", portfolio.Symbol, err)
+		}
+		return price, nil
+	}
+
+	future, err := h.market.GetFuture(portfolio.Symbol, portfolio.Exchange, portfolio.Expiry)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get future of %s: %w", portfolio.Symbol, err)
+	}
+	return future.LastPrice, nil
+}
+
+// portfolioDelta returns the aggregate delta of a portfolio's open positions
+func (h *DeltaHedger) portfolioDelta(portfolioID string) (float64, error) {
+	var delta float64
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial} {
+		filter := models.PositionFilter{PortfolioID: portfolioID, Status: status}
+		for page := 1; ; page++ {
+			positions, total, err := h.positionService.GetPositions(filter, page, pageSize)
+			if err != nil {
+				return 0, err
+			}
+			for i := range positions {
+				greeks, err := h.positionService.CalculateGreeks(&positions[i])
+				if err != nil {
+					return 0, fmt.Errorf("position %s: %w", positions[i].ID, err)
+				}
+				delta += greeks.Delta
+			}
+			if len(positions) == 0 || page*pageSize >= total {
+				break
+			}
+		}
+	}
+
+	return delta, nil
+}
+
+// portfolioLotSize returns the lot size of a portfolio's legs, 1 when not set
+func portfolioLotSize(portfolio *models.Portfolio) int {
+	for _, leg := range portfolio.Legs {
+		if leg.LotSize > 0 {
+			return leg.LotSize
+		}
+	}
+	return 1
+}
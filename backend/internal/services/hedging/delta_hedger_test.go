@@ -0,0 +1,339 @@
+package hedging
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockPortfolioProvider is a mock implementation of the PortfolioProvider interface
+type MockPortfolioProvider struct {
+	mock.Mock
+}
+
+func (m *MockPortfolioProvider) GetByID(id string) (*models.Portfolio, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Portfolio), args.Error(1)
+}
+
+// MockPositionService is a mock implementation of the PositionService interface.
+// GetPositions filters the positions it is given by portfolio and status, and
+// CalculateGreeks returns the Greeks positions are given.
+type MockPositionService struct {
+	mock.Mock
+	positions []models.Position
+	mutex     sync.Mutex
+}
+
+func (m *MockPositionService) setPositions(positions []models.Position) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.positions = positions
+}
+
+func (m *MockPositionService) CreatePositionFromOrder(order *models.Order) (*models.Position, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositionByID(id string) (*models.Position, error) {
+	args := m.Called(id)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var matched []models.Position
+	for _, p := range m.positions {
+		if (filter.PortfolioID != "" && p.PortfolioID != filter.PortfolioID) || (filter.Status != "" && p.Status != filter.Status) {
+			continue
+		}
+		matched = append(matched, p)
+	}
+	return matched, len(matched), nil
+}
+
+func (m *MockPositionService) UpdatePosition(position *models.Position) (*models.Position, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) ClosePosition(id string, exitPrice float64, exitQuantity int) (*models.Position, error) {
+	args := m.Called(id, exitPrice, exitQuantity)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) CalculatePnL(position *models.Position) (float64, error) {
+	args := m.Called(position)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateGreeks(position *models.Position) (*models.Greeks, error) {
+	greeks := position.Greeks
+	return &greeks, nil
+}
+
+func (m *MockPositionService) CalculateExposure(positions []models.Position) (float64, error) {
+	args := m.Called(positions)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) AggregatePositions(positions []models.Position, groupBy string) (map[string]models.AggregatedPosition, error) {
+	args := m.Called(positions, groupBy)
+	return args.Get(0).(map[string]models.AggregatedPosition), args.Error(1)
+}
+
+// MockOrderService is a mock implementation of the OrderService interface
+type MockOrderService struct {
+	mock.Mock
+}
+
+func (m *MockOrderService) CreateOrder(order *models.Order) (*models.Order, error) {
+	args := m.Called(order)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderByID(id string) (*models.Order, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error) {
+	args := m.Called(filter, page, limit)
+	return args.Get(0).([]models.Order), args.Int(1), args.Error(2)
+}
+
+func (m *MockOrderService) UpdateOrder(order *models.Order) (*models.Order, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) CancelOrder(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockOrderService) TransitionOrder(id string, status models.OrderStatus, filledQuantity int, source models.TransitionSource, reason string) (*models.Order, error) {
+	args := m.Called(id, status, filledQuantity, source, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderTimeline(id string) ([]models.OrderTransition, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OrderTransition), args.Error(1)
+}
+
+// MockMarketData is a mock implementation of the MarketData interface
+type MockMarketData struct {
+	mock.Mock
+}
+
+func (m *MockMarketData) GetLastPrice(symbol, exchange string) (float64, error) {
+	args := m.Called(symbol, exchange)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockMarketData) GetFuture(underlying, exchange string, expiry time.Time) (*Contract, error) {
+	args := m.Called(underlying, exchange, expiry)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Contract), args.Error(1)
+}
+
+func (m *MockMarketData) GetOptionChain(underlying, exchange string, expiry time.Time) ([]Contract, error) {
+	args := m.Called(underlying, exchange, expiry)
+	return args.Get(0).([]Contract), args.Error(1)
+}
+
+var expiry = time.Date(2024, 6, 27, 15, 30, 0, 0, time.UTC)
+
+// niftyPortfolio returns a delta neutral NIFTY portfolio of user1
+func niftyPortfolio() *models.Portfolio {
+	return &models.Portfolio{
+		ID:            "portfolio1",
+		UserID:        "user1",
+		StrategyID:    "strategy1",
+		Exchange:      "NFO",
+		Symbol:        "NIFTY",
+		Expiry:        expiry,
+		UnderlyingRef: models.UnderlyingReferenceFuture,
+		StrikeStep:    50,
+		ProductType:   models.ProductTypeNRML,
+		Legs:          []models.Leg{{ID: 1, LotSize: 50}},
+	}
+}
+
+// portfolioPosition returns an open position of portfolio1 with the given delta
+func portfolioPosition(id string, delta float64) models.Position {
+	return models.Position{ID: id, UserID: "user1", PortfolioID: "portfolio1", Exchange: "NFO", Status: models.PositionStatusOpen, Greeks: models.Greeks{Delta: delta}}
+}
+
+// niftyPuts returns NIFTY puts 1, 2, 4 and 8 strikes below 20000, and a call
+func niftyPuts() []Contract {
+	return []Contract{
+		{Symbol: "NIFTY24JUN19950PE", InstrumentType: models.InstrumentTypeOption, OptionType: models.OptionTypePut, StrikePrice: 19950, Expiry: expiry, LastPrice: 90, OpenInterest: 90000, Delta: -0.45, LotSize: 50},
+		{Symbol: "NIFTY24JUN19900PE", InstrumentType: models.InstrumentTypeOption, OptionType: models.OptionTypePut, StrikePrice: 19900, Expiry: expiry, LastPrice: 60, OpenInterest: 800, Delta: -0.4, LotSize: 50},
+		{Symbol: "NIFTY24JUN19800PE", InstrumentType: models.InstrumentTypeOption, OptionType: models.OptionTypePut, StrikePrice: 19800, Expiry: expiry, LastPrice: 25, OpenInterest: 5000, Delta: -0.25, LotSize: 50},
+		{Symbol: "NIFTY24JUN19600PE", InstrumentType: models.InstrumentTypeOption, OptionType: models.OptionTypePut, StrikePrice: 19600, Expiry: expiry, LastPrice: 8, OpenInterest: 20000, Delta: -0.1, LotSize: 50},
+		{Symbol: "NIFTY24JUN20200CE", InstrumentType: models.InstrumentTypeOption, OptionType: models.OptionTypeCall, StrikePrice: 20200, Expiry: expiry, LastPrice: 30, OpenInterest: 50000, Delta: 0.3, LotSize: 50},
+	}
+}
+
+func TestDeltaHedgerFutures(t *testing.T) {
+	mockPortfolios := new(MockPortfolioProvider)
+	mockPositions := new(MockPositionService)
+	mockOrders := new(MockOrderService)
+	mockMarket := new(MockMarketData)
+	hedger := NewDeltaHedger(mockPortfolios, mockPositions, mockOrders, mockMarket, 0)
+
+	mockPortfolios.On("GetByID", "portfolio1").Return(niftyPortfolio(), nil)
+	mockMarket.On("GetFuture", "NIFTY", "NFO", expiry).Return(&Contract{Symbol: "NIFTY24JUNFUT", InstrumentType: models.InstrumentTypeFuture, Expiry: expiry, LastPrice: 20010, Delta: 1, LotSize: 50}, nil)
+
+	// Portfolios without hedge distances are hedged with futures
+	_, err := hedger.Enable("portfolio1", Settings{Band: 20}, "user2")
+	assert.True(t, errors.Is(err, ErrAccessDenied))
+	hedge, err := hedger.Enable("portfolio1", Settings{Band: 20}, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, InstrumentFuture, hedge.Settings.Instrument)
+	assert.Equal(t, HedgeStatusActive, hedge.Status)
+
+	// A delta within the band is left alone
+	mockPositions.setPositions([]models.Position{portfolioPosition("position1", 15)})
+	result := hedger.Run(time.Now())
+	assert.Empty(t, result.Orders)
+	assert.Empty(t, result.Errors)
+
+	// A long delta of 130 is hedged by selling 3 lots
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "NIFTY24JUNFUT" && order.Direction == models.OrderDirectionSell && order.Quantity == 150 &&
+			order.PortfolioID == "portfolio1" && order.UserID == "user1" && order.Tags[0] == hedgeTag
+	})).Return(&models.Order{ID: "order1", Status: models.OrderStatusNew}, nil).Once()
+
+	mockPositions.setPositions([]models.Position{portfolioPosition("position1", 130)})
+	result = hedger.Run(time.Now())
+	assert.Empty(t, result.Errors)
+	assert.Len(t, result.Orders, 1)
+	assert.Equal(t, -150.0, result.Orders[0].Delta)
+
+	// Nothing more is placed while the hedge is working
+	mockOrders.On("GetOrderByID", "order1").Return(&models.Order{ID: "order1", Status: models.OrderStatusPending}, nil).Once()
+	result = hedger.Run(time.Now())
+	assert.Empty(t, result.Orders)
+
+	// Once filled the portfolio is within a lot of neutral
+	mockOrders.On("GetOrderByID", "order1").Return(&models.Order{ID: "order1", Status: models.OrderStatusExecuted}, nil)
+	mockPositions.setPositions([]models.Position{portfolioPosition("position1", 130), portfolioPosition("position2", -150)})
+	result = hedger.Run(time.Now())
+	assert.Empty(t, result.Orders)
+	assert.Empty(t, result.Errors)
+
+	_, err = hedger.GetHedge("portfolio1", "user2")
+	assert.True(t, errors.Is(err, ErrAccessDenied))
+	hedge, err = hedger.GetHedge("portfolio1", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, -20.0, hedge.Delta)
+	assert.Equal(t, "order1", hedge.LastOrderID)
+
+	// Disabled portfolios are no longer hedged
+	assert.NoError(t, hedger.Disable("portfolio1", "user1"))
+	_, err = hedger.GetHedge("portfolio1", "user1")
+	assert.True(t, errors.Is(err, ErrHedgeNotFound))
+	assert.True(t, errors.Is(hedger.Disable("portfolio1", "user1"), ErrHedgeNotFound))
+
+	mockOrders.AssertNumberOfCalls(t, "CreateOrder", 1)
+}
+
+func TestDeltaHedgerOptions(t *testing.T) {
+	mockPortfolios := new(MockPortfolioProvider)
+	mockPositions := new(MockPositionService)
+	mockOrders := new(MockOrderService)
+	mockMarket := new(MockMarketData)
+	hedger := NewDeltaHedger(mockPortfolios, mockPositions, mockOrders, mockMarket, 0)
+
+	portfolio := niftyPortfolio()
+	portfolio.MinHedgeDistance = 2
+	portfolio.MaxHedgeDistance = 6
+	portfolio.MaxHedgePremium = 30
+	portfolio.MinHedgeOI = 1000
+	mockPortfolios.On("GetByID", "portfolio1").Return(portfolio, nil)
+	mockMarket.On("GetFuture", "NIFTY", "NFO", expiry).Return(&Contract{Symbol: "NIFTY24JUNFUT", InstrumentType: models.InstrumentTypeFuture, Expiry: expiry, LastPrice: 20010, Delta: 1, LotSize: 50}, nil)
+	mockMarket.On("GetOptionChain", "NIFTY", "NFO", expiry).Return(niftyPuts(), nil)
+	mockPositions.setPositions([]models.Position{portfolioPosition("position1", 200)})
+
+	_, err := hedger.Enable("portfolio1", Settings{Instrument: "SWAP"}, "user1")
+	assert.Error(t, err)
+	hedge, err := hedger.Enable("portfolio1", Settings{}, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, InstrumentOption, hedge.Settings.Instrument)
+
+	// The closest put within the hedge distance, max premium and min OI is bought
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "NIFTY24JUN19800PE" && order.Direction == models.OrderDirectionBuy && order.Quantity == 800 &&
+			order.InstrumentType == models.InstrumentTypeOption && order.OptionType == models.OptionTypePut && order.StrikePrice == 19800
+	})).Return(&models.Order{ID: "order1", Status: models.OrderStatusNew}, nil).Once()
+
+	result := hedger.Run(time.Now())
+	assert.Empty(t, result.Errors)
+	assert.Len(t, result.Orders, 1)
+	assert.False(t, result.Orders[0].Relaxed)
+	assert.Equal(t, -200.0, result.Orders[0].Delta)
+	mockOrders.On("GetOrderByID", "order1").Return(&models.Order{ID: "order1", Status: models.OrderStatusRejected}, nil)
+
+	// Ignoring the optional fields when none satisfies them picks the closest put
+	portfolio.MaxHedgePremium = 10
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "NIFTY24JUN19900PE" && order.Quantity == 500
+	})).Return(&models.Order{ID: "order2", Status: models.OrderStatusNew}, nil).Once()
+
+	result = hedger.Run(time.Now())
+	assert.Empty(t, result.Errors)
+	assert.Len(t, result.Orders, 1)
+	assert.True(t, result.Orders[0].Relaxed)
+	mockOrders.On("GetOrderByID", "order2").Return(&models.Order{ID: "order2", Status: models.OrderStatusCancelled}, nil)
+
+	// Skipping leaves the portfolio unhedged until the next run
+	portfolio.UnsatisfiedHedgeAction = UnsatisfiedActionSkipLeg
+	result = hedger.Run(time.Now())
+	assert.Empty(t, result.Orders)
+	assert.Len(t, result.Skipped, 1)
+	assert.Equal(t, HedgeStatusActive, result.Skipped[0].Status)
+	assert.NotEmpty(t, result.Skipped[0].Reason)
+
+	// Stopping halts hedging until it is enabled again
+	portfolio.UnsatisfiedHedgeAction = UnsatisfiedActionStopExecution
+	result = hedger.Run(time.Now())
+	assert.Len(t, result.Skipped, 1)
+	assert.Equal(t, HedgeStatusHalted, result.Skipped[0].Status)
+
+	result = hedger.Run(time.Now())
+	assert.Empty(t, result.Skipped)
+
+	hedge, err = hedger.Enable("portfolio1", Settings{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, HedgeStatusActive, hedge.Status)
+
+	mockOrders.AssertNumberOfCalls(t, "CreateOrder", 2)
+}
@@ -0,0 +1,468 @@
+package killswitch
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// Scope represents what a kill switch acts on
+type Scope string
+
+const (
+	ScopeUser     Scope = "USER"
+	ScopeStrategy Scope = "STRATEGY"
+	ScopePlatform Scope = "PLATFORM"
+)
+
+// AuditAction represents a step of a kill switch recorded in the audit log
+type AuditAction string
+
+const (
+	AuditActionRequested          AuditAction = "KILL_REQUESTED"
+	AuditActionConfirmed          AuditAction = "KILL_CONFIRMED"
+	AuditActionConfirmationDenied AuditAction = "CONFIRMATION_DENIED"
+	AuditActionTriggered          AuditAction = "KILL_TRIGGERED"
+	AuditActionOrderCancelled     AuditAction = "ORDER_CANCELLED"
+	AuditActionPositionFlattened  AuditAction = "POSITION_FLATTENED"
+	AuditActionCompleted          AuditAction = "KILL_COMPLETED"
+)
+
+// DefaultConfirmationTTL is how long a kill request waits for its confirmation
+const DefaultConfirmationTTL = 60 * time.Second
+
+// pageSize is the number of orders or positions fetched at a time
+const pageSize = 100
+
+// KillRequest describes the orders and positions a kill switch acts on
+type KillRequest struct {
+	Scope            Scope  `json:"scope"`
+	UserID           string `json:"userId,omitempty"`     // Required for the user scope, limits the strategy scope to one user
+	StrategyID       string `json:"strategyId,omitempty"` // Required for the strategy scope
+	FlattenPositions bool   `json:"flattenPositions"`
+	Reason           string `json:"reason"`
+}
+
+// Confirmation is a kill request waiting to be confirmed with its token
+type Confirmation struct {
+	Token       string      `json:"token"`
+	Request     KillRequest `json:"request"`
+	RequestedBy string      `json:"requestedBy"`
+	ExpiresAt   time.Time   `json:"expiresAt"`
+}
+
+// KillResult is the outcome of a kill switch
+type KillResult struct {
+	Request         KillRequest `json:"request"`
+	TriggeredBy     string      `json:"triggeredBy"`
+	CancelledOrders []string    `json:"cancelledOrders"`
+	FlattenOrders   []string    `json:"flattenOrders"` // Orders placed to close positions
+	Errors          []string    `json:"errors,omitempty"`
+	ExecutedAt      time.Time   `json:"executedAt"`
+}
+
+// AuditEntry is one step of a kill switch in the audit log
+type AuditEntry struct {
+	ID         string      `json:"id"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Action     AuditAction `json:"action"`
+	Scope      Scope       `json:"scope"`
+	UserID     string      `json:"userId,omitempty"`
+	StrategyID string      `json:"strategyId,omitempty"`
+	Actor      string      `json:"actor"`
+	OrderID    string      `json:"orderId,omitempty"`
+	PositionID string      `json:"positionId,omitempty"`
+	Details    string      `json:"details,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// AuditLogger records the audit log of kill switches
+type AuditLogger interface {
+	Log(entry AuditEntry) error
+	GetEntries(userID string) ([]AuditEntry, error)
+}
+
+// KillSwitchService defines the interface for kill switch operations
+type KillSwitchService interface {
+	// RequestKill validates a kill request and returns the token confirming it
+	RequestKill(request KillRequest, requestedBy string) (*Confirmation, error)
+
+	// ConfirmKill executes a requested kill, the token being usable once by the
+	// user who requested it before it expires
+	ConfirmKill(token string, confirmedBy string) (*KillResult, error)
+
+	// Kill executes a kill without confirmation, for internal triggers such as
+	// risk limit breaches
+	Kill(request KillRequest, triggeredBy string) (*KillResult, error)
+
+	// GetAuditLog returns the audit log of a user's kill switches, or of all of
+	// them when userID is empty
+	GetAuditLog(userID string) ([]AuditEntry, error)
+}
+
+// KillSwitchServiceImpl implements the KillSwitchService interface
+type KillSwitchServiceImpl struct {
+	orderService    services.OrderService
+	positionService position.PositionService
+	auditLogger     AuditLogger
+	confirmationTTL time.Duration
+	pending         map[string]*Confirmation
+	mutex           sync.Mutex
+}
+
+// NewKillSwitchService creates a new KillSwitchService, keeping the audit log in
+// memory when no audit logger is given
+func NewKillSwitchService(
+	orderService services.OrderService,
+	positionService position.PositionService,
+	auditLogger AuditLogger,
+) KillSwitchService {
+	if auditLogger == nil {
+		auditLogger = NewMemoryAuditLogger()
+	}
+
+	return &KillSwitchServiceImpl{
+		orderService:    orderService,
+		positionService: positionService,
+		auditLogger:     auditLogger,
+		confirmationTTL: DefaultConfirmationTTL,
+		pending:         make(map[string]*Confirmation),
+	}
+}
+
+// RequestKill validates a kill request and returns the token confirming it
+func (s *KillSwitchServiceImpl) RequestKill(request KillRequest, requestedBy string) (*Confirmation, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	if requestedBy == "" {
+		return nil, errors.New("requesting user is required")
+	}
+
+	confirmation := &Confirmation{
+		Token:       uuid.New().String(),
+		Request:     request,
+		RequestedBy: requestedBy,
+		ExpiresAt:   time.Now().Add(s.confirmationTTL),
+	}
+
+	s.mutex.Lock()
+	// Drop expired requests
+	for token, pending := range s.pending {
+		if time.Now().After(pending.ExpiresAt) {
+			delete(s.pending, token)
+		}
+	}
+	s.pending[confirmation.Token] = confirmation
+	s.mutex.Unlock()
+
+	s.audit(AuditActionRequested, request, requestedBy, func(entry *AuditEntry) {
+		entry.Details = fmt.Sprintf("flatten positions: %t, reason: %s", request.FlattenPositions, request.Reason)
+	})
+
+	return confirmation, nil
+}
+
+// ConfirmKill executes a requested kill
+func (s *KillSwitchServiceImpl) ConfirmKill(token string, confirmedBy string) (*KillResult, error) {
+	if token == "" {
+		return nil, errors.New("confirmation token is required")
+	}
+
+	s.mutex.Lock()
+	confirmation, exists := s.pending[token]
+	if exists && confirmation.RequestedBy == confirmedBy {
+		delete(s.pending, token)
+	}
+	s.mutex.Unlock()
+
+	// Denied confirmations are audited too, they may be attempts to kill someone else
+	var err error
+	switch {
+	case !exists:
+		err = errors.New("confirmation token not found")
+	case confirmation.RequestedBy != confirmedBy:
+		err = errors.New("kill must be confirmed by the user who requested it")
+	case time.Now().After(confirmation.ExpiresAt):
+		err = errors.New("confirmation token has expired")
+	}
+	if err != nil {
+		var request KillRequest
+		if exists {
+			request = confirmation.Request
+		}
+		s.audit(AuditActionConfirmationDenied, request, confirmedBy, func(entry *AuditEntry) {
+			entry.Error = err.Error()
+		})
+		return nil, err
+	}
+
+	s.audit(AuditActionConfirmed, confirmation.Request, confirmedBy, nil)
+	return s.execute(confirmation.Request, confirmedBy), nil
+}
+
+// Kill executes a kill without confirmation
+func (s *KillSwitchServiceImpl) Kill(request KillRequest, triggeredBy string) (*KillResult, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	if triggeredBy == "" {
+		return nil, errors.New("trigger is required")
+	}
+
+	s.audit(AuditActionTriggered, request, triggeredBy, func(entry *AuditEntry) {
+		entry.Details = fmt.Sprintf("flatten positions: %t, reason: %s", request.FlattenPositions, request.Reason)
+	})
+	return s.execute(request, triggeredBy), nil
+}
+
+// GetAuditLog returns the audit log of a user's kill switches
+func (s *KillSwitchServiceImpl) GetAuditLog(userID string) ([]AuditEntry, error) {
+	return s.auditLogger.GetEntries(userID)
+}
+
+// execute cancels the open orders in the scope of a kill and closes its positions
+// if asked to. It carries on past individual failures, which are in the result.
+func (s *KillSwitchServiceImpl) execute(request KillRequest, actor string) *KillResult {
+	result := &KillResult{
+		Request:         request,
+		TriggeredBy:     actor,
+		CancelledOrders: []string{},
+		FlattenOrders:   []string{},
+		ExecutedAt:      time.Now(),
+	}
+
+	// Cancel open orders first so none of them fill while positions are closed
+	orders, err := s.openOrders(request)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("error listing open orders: %v", err))
+	}
+	for _, order := range orders {
+		err := s.orderService.CancelOrder(order.ID)
+		s.audit(AuditActionOrderCancelled, request, actor, func(entry *AuditEntry) {
+			entry.UserID = order.UserID
+			entry.StrategyID = order.StrategyID
+			entry.OrderID = order.ID
+			if err != nil {
+				entry.Error = err.Error()
+			}
+		})
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("error cancelling order %s: %v", order.ID, err))
+			continue
+		}
+		result.CancelledOrders = append(result.CancelledOrders, order.ID)
+	}
+
+	// Close open positions with market orders
+	if request.FlattenPositions {
+		positions, err := s.openPositions(request)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("error listing open positions: %v", err))
+		}
+		for _, openPosition := range positions {
+			order, err := s.flattenPosition(openPosition, request.Reason)
+			s.audit(AuditActionPositionFlattened, request, actor, func(entry *AuditEntry) {
+				entry.UserID = openPosition.UserID
+				entry.StrategyID = openPosition.StrategyID
+				entry.PositionID = openPosition.ID
+				if order != nil {
+					entry.OrderID = order.ID
+				}
+				if err != nil {
+					entry.Error = err.Error()
+				}
+			})
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("error flattening position %s: %v", openPosition.ID, err))
+				continue
+			}
+			if order != nil {
+				result.FlattenOrders = append(result.FlattenOrders, order.ID)
+			}
+		}
+	}
+
+	s.audit(AuditActionCompleted, request, actor, func(entry *AuditEntry) {
+		entry.Details = fmt.Sprintf("%d orders cancelled, %d positions flattened, %d errors",
+			len(result.CancelledOrders), len(result.FlattenOrders), len(result.Errors))
+	})
+
+	return result
+}
+
+// openOrders returns the pending and partially filled orders in the scope of a kill
+func (s *KillSwitchServiceImpl) openOrders(request KillRequest) ([]models.Order, error) {
+	var orders []models.Order
+	for _, status := range []models.OrderStatus{models.OrderStatusPending, models.OrderStatusPartial} {
+		filter := models.OrderFilter{
+			UserID:     request.UserID,
+			StrategyID: request.StrategyID,
+			Status:     status,
+		}
+
+		// Collect every page before anything is cancelled, cancelling moves orders
+		// out of the filter and would shift the pages
+		for page := 1; ; page++ {
+			batch, total, err := s.orderService.GetOrders(filter, page, pageSize)
+			if err != nil {
+				return orders, err
+			}
+			orders = append(orders, batch...)
+			if len(batch) == 0 || page*pageSize >= total {
+				break
+			}
+		}
+	}
+
+	return orders, nil
+}
+
+// openPositions returns the open and partially closed positions in the scope of a kill
+func (s *KillSwitchServiceImpl) openPositions(request KillRequest) ([]models.Position, error) {
+	var positions []models.Position
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial} {
+		filter := models.PositionFilter{
+			UserID:     request.UserID,
+			StrategyID: request.StrategyID,
+			Status:     status,
+		}
+
+		for page := 1; ; page++ {
+			batch, total, err := s.positionService.GetPositions(filter, page, pageSize)
+			if err != nil {
+				return positions, err
+			}
+			positions = append(positions, batch...)
+			if len(batch) == 0 || page*pageSize >= total {
+				break
+			}
+		}
+	}
+
+	return positions, nil
+}
+
+// flattenPosition places a market order closing what is left of a position. It
+// returns no order when nothing is left.
+func (s *KillSwitchServiceImpl) flattenPosition(openPosition models.Position, reason string) (*models.Order, error) {
+	quantity := openPosition.Quantity - openPosition.ExitQuantity
+	if quantity <= 0 {
+		return nil, nil
+	}
+
+	direction := models.OrderDirectionSell
+	if openPosition.Direction == models.PositionDirectionShort {
+		direction = models.OrderDirectionBuy
+	}
+
+	return s.orderService.CreateOrder(&models.Order{
+		UserID:         openPosition.UserID,
+		Symbol:         openPosition.Symbol,
+		Exchange:       openPosition.Exchange,
+		OrderType:      models.OrderTypeMarket,
+		Direction:      direction,
+		Quantity:       quantity,
+		ProductType:    openPosition.ProductType,
+		InstrumentType: openPosition.InstrumentType,
+		OptionType:     openPosition.OptionType,
+		StrikePrice:    openPosition.StrikePrice,
+		Expiry:         openPosition.Expiry,
+		PortfolioID:    openPosition.PortfolioID,
+		StrategyID:     openPosition.StrategyID,
+		Tags:           []string{"kill_switch"},
+		Notes:          reason,
+	})
+}
+
+// audit records a step of a kill switch. The audit log failing does not stop the
+// kill, but is logged.
+func (s *KillSwitchServiceImpl) audit(action AuditAction, request KillRequest, actor string, update func(entry *AuditEntry)) {
+	entry := AuditEntry{
+		ID:         uuid.New().String(),
+		Timestamp:  time.Now(),
+		Action:     action,
+		Scope:      request.Scope,
+		UserID:     request.UserID,
+		StrategyID: request.StrategyID,
+		Actor:      actor,
+	}
+	if update != nil {
+		update(&entry)
+	}
+
+	if err := s.auditLogger.Log(entry); err != nil {
+		log.Printf("Error writing kill switch audit entry %s %s: %v", entry.Action, entry.ID, err)
+	}
+}
+
+// Validate validates the kill request
+func (r *KillRequest) Validate() error {
+	switch r.Scope {
+	case ScopeUser:
+		if r.UserID == "" {
+			return errors.New("user ID is required for a user kill switch")
+		}
+	case ScopeStrategy:
+		if r.StrategyID == "" {
+			return errors.New("strategy ID is required for a strategy kill switch")
+		}
+	case ScopePlatform:
+		if r.UserID != "" || r.StrategyID != "" {
+			return errors.New("platform kill switch cannot be limited to a user or strategy")
+		}
+	default:
+		return errors.New("invalid kill switch scope")
+	}
+
+	if r.Reason == "" {
+		return errors.New("reason is required")
+	}
+
+	return nil
+}
+
+// MemoryAuditLogger keeps the audit log in memory and writes every entry to the
+// standard log
+type MemoryAuditLogger struct {
+	entries []AuditEntry
+	mutex   sync.RWMutex
+}
+
+// NewMemoryAuditLogger creates a new MemoryAuditLogger
+func NewMemoryAuditLogger() *MemoryAuditLogger {
+	return &MemoryAuditLogger{}
+}
+
+// Log records an audit entry
+func (l *MemoryAuditLogger) Log(entry AuditEntry) error {
+	log.Printf("Kill switch audit: %s scope=%s user=%s strategy=%s actor=%s order=%s position=%s %s %s",
+		entry.Action, entry.Scope, entry.UserID, entry.StrategyID, entry.Actor, entry.OrderID, entry.PositionID, entry.Details, entry.Error)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// GetEntries returns the audit entries of a user, or all of them when userID is empty
+func (l *MemoryAuditLogger) GetEntries(userID string) ([]AuditEntry, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	entries := make([]AuditEntry, 0, len(l.entries))
+	for _, entry := range l.entries {
+		if userID == "" || entry.UserID == userID || entry.Actor == userID {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
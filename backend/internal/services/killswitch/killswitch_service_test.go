@@ -0,0 +1,259 @@
+package killswitch
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockOrderService is a mock implementation of the OrderService interface
+type MockOrderService struct {
+	mock.Mock
+}
+
+func (m *MockOrderService) CreateOrder(order *models.Order) (*models.Order, error) {
+	args := m.Called(order)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderByID(id string) (*models.Order, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error) {
+	args := m.Called(filter, page, limit)
+	return args.Get(0).([]models.Order), args.Int(1), args.Error(2)
+}
+
+func (m *MockOrderService) UpdateOrder(order *models.Order) (*models.Order, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) CancelOrder(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockOrderService) TransitionOrder(id string, status models.OrderStatus, filledQuantity int, source models.TransitionSource, reason string) (*models.Order, error) {
+	args := m.Called(id, status, filledQuantity, source, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderTimeline(id string) ([]models.OrderTransition, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OrderTransition), args.Error(1)
+}
+
+// MockPositionService is a mock implementation of the PositionService interface
+type MockPositionService struct {
+	mock.Mock
+}
+
+func (m *MockPositionService) CreatePositionFromOrder(order *models.Order) (*models.Position, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositionByID(id string) (*models.Position, error) {
+	args := m.Called(id)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	args := m.Called(filter, page, limit)
+	return args.Get(0).([]models.Position), args.Int(1), args.Error(2)
+}
+
+func (m *MockPositionService) UpdatePosition(position *models.Position) (*models.Position, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) ClosePosition(id string, exitPrice float64, exitQuantity int) (*models.Position, error) {
+	args := m.Called(id, exitPrice, exitQuantity)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) CalculatePnL(position *models.Position) (float64, error) {
+	args := m.Called(position)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateGreeks(position *models.Position) (*models.Greeks, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Greeks), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateExposure(positions []models.Position) (float64, error) {
+	args := m.Called(positions)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) AggregatePositions(positions []models.Position, groupBy string) (map[string]models.AggregatedPosition, error) {
+	args := m.Called(positions, groupBy)
+	return args.Get(0).(map[string]models.AggregatedPosition), args.Error(1)
+}
+
+func TestRequestKill(t *testing.T) {
+	service := NewKillSwitchService(new(MockOrderService), new(MockPositionService), nil)
+
+	// Test successful request
+	confirmation, err := service.RequestKill(KillRequest{Scope: ScopeUser, UserID: "user123", Reason: "runaway strategy"}, "user123")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, confirmation.Token)
+	assert.True(t, confirmation.ExpiresAt.After(time.Now()))
+
+	// Test invalid requests
+	_, err = service.RequestKill(KillRequest{Scope: ScopeUser, Reason: "runaway strategy"}, "user123")
+	assert.Error(t, err)
+	_, err = service.RequestKill(KillRequest{Scope: ScopeStrategy, UserID: "user123", Reason: "runaway strategy"}, "user123")
+	assert.Error(t, err)
+	_, err = service.RequestKill(KillRequest{Scope: ScopePlatform, UserID: "user123", Reason: "exchange outage"}, "admin1")
+	assert.Error(t, err)
+	_, err = service.RequestKill(KillRequest{Scope: ScopePlatform}, "admin1")
+	assert.Error(t, err)
+
+	// The request is audited
+	entries, err := service.GetAuditLog("user123")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, AuditActionRequested, entries[0].Action)
+}
+
+func TestConfirmKill(t *testing.T) {
+	mockOrders := new(MockOrderService)
+	mockPositions := new(MockPositionService)
+
+	// Two pages of pending orders, one partially filled order and one open long position
+	firstPage := make([]models.Order, pageSize)
+	for i := range firstPage {
+		firstPage[i] = models.Order{ID: fmt.Sprintf("pending%d", i), UserID: "user123"}
+	}
+	pendingFilter := models.OrderFilter{UserID: "user123", Status: models.OrderStatusPending}
+	partialFilter := models.OrderFilter{UserID: "user123", Status: models.OrderStatusPartial}
+	mockOrders.On("GetOrders", pendingFilter, 1, pageSize).Return(firstPage, pageSize+1, nil)
+	mockOrders.On("GetOrders", pendingFilter, 2, pageSize).Return([]models.Order{{ID: "pendingLast", UserID: "user123"}}, pageSize+1, nil)
+	mockOrders.On("GetOrders", partialFilter, 1, pageSize).Return([]models.Order{{ID: "partial1", UserID: "user123"}}, 1, nil)
+	mockOrders.On("CancelOrder", "partial1").Return(errors.New("order already filled"))
+	mockOrders.On("CancelOrder", mock.Anything).Return(nil)
+
+	position := models.Position{
+		ID:             "position1",
+		UserID:         "user123",
+		Symbol:         "NIFTY",
+		Exchange:       "NSE",
+		Direction:      models.PositionDirectionLong,
+		Quantity:       50,
+		ExitQuantity:   20,
+		Status:         models.PositionStatusPartial,
+		ProductType:    models.ProductTypeMIS,
+		InstrumentType: models.InstrumentTypeFuture,
+	}
+	mockPositions.On("GetPositions", models.PositionFilter{UserID: "user123", Status: models.PositionStatusOpen}, 1, pageSize).Return([]models.Position{}, 0, nil)
+	mockPositions.On("GetPositions", models.PositionFilter{UserID: "user123", Status: models.PositionStatusPartial}, 1, pageSize).Return([]models.Position{position}, 1, nil)
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "NIFTY" && order.Direction == models.OrderDirectionSell &&
+			order.OrderType == models.OrderTypeMarket && order.Quantity == 30
+	})).Return(&models.Order{ID: "flatten1"}, nil)
+
+	service := NewKillSwitchService(mockOrders, mockPositions, nil)
+	confirmation, err := service.RequestKill(KillRequest{
+		Scope:            ScopeUser,
+		UserID:           "user123",
+		FlattenPositions: true,
+		Reason:           "runaway strategy",
+	}, "user123")
+	assert.NoError(t, err)
+
+	// Test confirmation by another user
+	_, err = service.ConfirmKill(confirmation.Token, "user456")
+	assert.Error(t, err)
+
+	// Test successful confirmation
+	result, err := service.ConfirmKill(confirmation.Token, "user123")
+	assert.NoError(t, err)
+	assert.Len(t, result.CancelledOrders, pageSize+1)
+	assert.Equal(t, []string{"flatten1"}, result.FlattenOrders)
+	assert.Len(t, result.Errors, 1)
+
+	// Test the token cannot be used twice
+	_, err = service.ConfirmKill(confirmation.Token, "user123")
+	assert.Error(t, err)
+
+	// Every step is audited
+	entries, err := service.GetAuditLog("")
+	assert.NoError(t, err)
+	counts := make(map[AuditAction]int)
+	for _, entry := range entries {
+		counts[entry.Action]++
+	}
+	assert.Equal(t, 1, counts[AuditActionRequested])
+	assert.Equal(t, 2, counts[AuditActionConfirmationDenied])
+	assert.Equal(t, 1, counts[AuditActionConfirmed])
+	assert.Equal(t, pageSize+2, counts[AuditActionOrderCancelled])
+	assert.Equal(t, 1, counts[AuditActionPositionFlattened])
+	assert.Equal(t, 1, counts[AuditActionCompleted])
+
+	mockOrders.AssertExpectations(t)
+	mockPositions.AssertExpectations(t)
+}
+
+func TestConfirmKillExpired(t *testing.T) {
+	service := NewKillSwitchService(new(MockOrderService), new(MockPositionService), nil).(*KillSwitchServiceImpl)
+	service.confirmationTTL = -time.Second
+
+	confirmation, err := service.RequestKill(KillRequest{Scope: ScopeStrategy, StrategyID: "strategy123", Reason: "bad fills"}, "user123")
+	assert.NoError(t, err)
+
+	_, err = service.ConfirmKill(confirmation.Token, "user123")
+	assert.EqualError(t, err, "confirmation token has expired")
+}
+
+func TestKill(t *testing.T) {
+	mockOrders := new(MockOrderService)
+	mockPositions := new(MockPositionService)
+
+	// A platform kill is not limited to a user or strategy, and leaves positions alone
+	mockOrders.On("GetOrders", models.OrderFilter{Status: models.OrderStatusPending}, 1, pageSize).Return([]models.Order{
+		{ID: "order1", UserID: "user123"},
+		{ID: "order2", UserID: "user456"},
+	}, 2, nil)
+	mockOrders.On("GetOrders", models.OrderFilter{Status: models.OrderStatusPartial}, 1, pageSize).Return([]models.Order{}, 0, nil)
+	mockOrders.On("CancelOrder", "order1").Return(nil)
+	mockOrders.On("CancelOrder", "order2").Return(nil)
+
+	service := NewKillSwitchService(mockOrders, mockPositions, nil)
+	result, err := service.Kill(KillRequest{Scope: ScopePlatform, Reason: "exchange outage"}, "risk-monitor")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"order1", "order2"}, result.CancelledOrders)
+	assert.Empty(t, result.FlattenOrders)
+	assert.Empty(t, result.Errors)
+
+	// Users see the cancellation of their own orders
+	entries, err := service.GetAuditLog("user456")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "order2", entries[0].OrderID)
+
+	mockOrders.AssertExpectations(t)
+	mockPositions.AssertNotCalled(t, "GetPositions", mock.Anything, mock.Anything, mock.Anything)
+}
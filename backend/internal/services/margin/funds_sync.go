@@ -0,0 +1,453 @@
+package margin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/broker/common"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/position"
+	"github.com/trading-platform/backend/internal/services/risk"
+)
+
+const (
+	// DefaultSyncInterval is how often broker funds are synchronized
+	DefaultSyncInterval = time.Minute
+
+	// DefaultDivergenceTolerance is the fraction the local margin estimate may differ
+	// from the margin the brokers report utilized before it is alerted on
+	DefaultDivergenceTolerance = 0.10
+
+	// DefaultMinDivergence is the difference between the local estimate and the
+	// brokers' utilized margin that is never alerted on
+	DefaultMinDivergence = 1000.0
+
+	// DefaultHistorySize is the number of snapshots kept of each account, a day's
+	// worth at the default interval
+	DefaultHistorySize = 1440
+)
+
+// DefaultUtilizationThresholds are the percentages of funds utilized alerted on
+// when an account crosses them
+var DefaultUtilizationThresholds = []float64{75, 90}
+
+// AccountProvider retrieves the broker accounts users have connected
+type AccountProvider interface {
+	GetUserApiKeys(userID string) ([]models.UserApiKey, error)
+}
+
+// BrokerFundsClient queries the margin of a user's broker account
+type BrokerFundsClient interface {
+	GetAccountMargin(userID, accountID string) (*common.AccountMargin, error)
+}
+
+// MarginCalculator estimates the margin of a user's open positions locally, as the
+// internal engine of the margin estimator does
+type MarginCalculator interface {
+	CurrentMargin(userID string) (float64, error)
+}
+
+// SyncConfig configures the funds synchronizer
+type SyncConfig struct {
+	Interval              time.Duration // Between runs, defaults to DefaultSyncInterval
+	UtilizationThresholds []float64     // Percentages of funds utilized, defaults to DefaultUtilizationThresholds
+	DivergenceTolerance   float64       // Fraction of the larger margin, defaults to DefaultDivergenceTolerance
+	MinDivergence         float64       // Smallest difference alerted on, defaults to DefaultMinDivergence
+	HistorySize           int           // Snapshots kept of each account, defaults to DefaultHistorySize
+}
+
+// FundsSnapshot is the margin a broker reported for an account
+type FundsSnapshot struct {
+	UserID      string    `json:"userId"`
+	AccountID   string    `json:"accountId"`
+	Name        string    `json:"name"`
+	Broker      string    `json:"broker"`
+	Available   float64   `json:"available"`
+	Utilized    float64   `json:"utilized"`
+	Funds       float64   `json:"funds"`       // Margin available and utilized
+	Utilization float64   `json:"utilization"` // Percentage of the funds utilized
+	Error       string    `json:"error,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// UserFunds is the margin of a user across their active broker accounts as last
+// synchronized, and how it compares with the local estimate
+type UserFunds struct {
+	UserID        string          `json:"userId"`
+	Available     float64         `json:"available"`
+	Utilized      float64         `json:"utilized"`
+	Funds         float64         `json:"funds"`
+	Utilization   float64         `json:"utilization"`
+	LocalEstimate *float64        `json:"localEstimate,omitempty"` // Margin of the open positions by the internal engine
+	Divergence    float64         `json:"divergence"`              // Utilized margin less the local estimate
+	Diverged      bool            `json:"diverged"`
+	Accounts      []FundsSnapshot `json:"accounts"`
+	Errors        []string        `json:"errors,omitempty"`
+	Time          time.Time       `json:"time"`
+}
+
+// SyncRunResult is the outcome of one run of the funds synchronizer
+type SyncRunResult struct {
+	Time   time.Time           `json:"time"`
+	Users  []UserFunds         `json:"users"`
+	Alerts []risk.Notification `json:"alerts,omitempty"`
+	Errors []string            `json:"errors,omitempty"`
+}
+
+// FundsSynchronizer keeps the margin of users' broker accounts in sync. Every run
+// it pulls the margin of the active accounts of each user with open positions, or
+// synchronized before, and keeps a history of snapshots of each account. Users are
+// alerted when an account's utilization crosses a threshold, and when the margin
+// the brokers report utilized diverges from the internal engine's estimate of
+// their open positions. Alerts are sent once, until the condition clears.
+type FundsSynchronizer struct {
+	positionService position.PositionService
+	accounts        AccountProvider
+	broker          BrokerFundsClient
+	estimator       MarginCalculator
+	notifier        risk.Notifier
+	config          SyncConfig
+	latest          map[string]*UserFunds      // By user
+	history         map[string][]FundsSnapshot // By account
+	levels          map[string]int             // Thresholds crossed, by account
+	diverged        map[string]bool            // By user
+	stop            chan struct{}
+	mutex           sync.Mutex
+}
+
+// NewFundsSynchronizer creates a new FundsSynchronizer. The estimator and notifier
+// are optional, without them margin is not compared and users are not alerted.
+func NewFundsSynchronizer(
+	positionService position.PositionService,
+	accounts AccountProvider,
+	broker BrokerFundsClient,
+	estimator MarginCalculator,
+	notifier risk.Notifier,
+	config SyncConfig,
+) *FundsSynchronizer {
+	if config.Interval <= 0 {
+		config.Interval = DefaultSyncInterval
+	}
+	if len(config.UtilizationThresholds) == 0 {
+		config.UtilizationThresholds = DefaultUtilizationThresholds
+	}
+	config.UtilizationThresholds = append([]float64{}, config.UtilizationThresholds...)
+	sort.Float64s(config.UtilizationThresholds)
+	if config.DivergenceTolerance <= 0 {
+		config.DivergenceTolerance = DefaultDivergenceTolerance
+	}
+	if config.MinDivergence <= 0 {
+		config.MinDivergence = DefaultMinDivergence
+	}
+	if config.HistorySize <= 0 {
+		config.HistorySize = DefaultHistorySize
+	}
+
+	return &FundsSynchronizer{
+		positionService: positionService,
+		accounts:        accounts,
+		broker:          broker,
+		estimator:       estimator,
+		notifier:        notifier,
+		config:          config,
+		latest:          make(map[string]*UserFunds),
+		history:         make(map[string][]FundsSnapshot),
+		levels:          make(map[string]int),
+		diverged:        make(map[string]bool),
+	}
+}
+
+// Start runs the synchronizer every interval until it is stopped
+func (s *FundsSynchronizer) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stop != nil {
+		return errors.New("funds synchronizer is already running")
+	}
+	s.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := s.Run(now)
+				if err != nil {
+					log.Printf("Error running funds synchronizer: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Funds synchronizer error: %s", message)
+				}
+			}
+		}
+	}(s.stop)
+
+	return nil
+}
+
+// Stop stops the synchronizer
+func (s *FundsSynchronizer) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// Run synchronizes every user with open positions, and every user synchronized
+// before, at now
+func (s *FundsSynchronizer) Run(now time.Time) (*SyncRunResult, error) {
+	result := &SyncRunResult{
+		Time:  now,
+		Users: []UserFunds{},
+	}
+
+	userIDs := make(map[string]bool)
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial} {
+		filter := models.PositionFilter{Status: status}
+		for page := 1; ; page++ {
+			batch, total, err := s.positionService.GetPositions(filter, page, pageSize)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get open positions: %w", err)
+			}
+			for _, openPosition := range batch {
+				userIDs[openPosition.UserID] = true
+			}
+			if len(batch) == 0 || page*pageSize >= total {
+				break
+			}
+		}
+	}
+	s.mutex.Lock()
+	for userID := range s.latest {
+		userIDs[userID] = true
+	}
+	s.mutex.Unlock()
+
+	for userID := range userIDs {
+		funds, alerts, err := s.sync(userID, now)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("user %s: %v", userID, err))
+			continue
+		}
+		result.Users = append(result.Users, *funds)
+		result.Alerts = append(result.Alerts, alerts...)
+		for _, message := range funds.Errors {
+			result.Errors = append(result.Errors, fmt.Sprintf("user %s: %s", userID, message))
+		}
+	}
+
+	return result, nil
+}
+
+// Sync synchronizes a user's funds now
+func (s *FundsSynchronizer) Sync(userID string) (*UserFunds, error) {
+	if userID == "" {
+		return nil, errors.New("user ID is required")
+	}
+
+	funds, _, err := s.sync(userID, time.Now())
+	return funds, err
+}
+
+// GetUserFunds returns a user's funds as last synchronized, synchronizing them when
+// they have not been yet
+func (s *FundsSynchronizer) GetUserFunds(userID string) (*UserFunds, error) {
+	s.mutex.Lock()
+	funds, exists := s.latest[userID]
+	s.mutex.Unlock()
+	if exists {
+		return funds, nil
+	}
+
+	return s.Sync(userID)
+}
+
+// GetFunds returns the funds a user can use as margin across their broker accounts,
+// making the synchronizer a funds provider of the margin estimator
+func (s *FundsSynchronizer) GetFunds(userID string) (float64, error) {
+	funds, err := s.GetUserFunds(userID)
+	if err != nil {
+		return 0, err
+	}
+	for _, account := range funds.Accounts {
+		if account.Error == "" {
+			return funds.Funds, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no broker account of user %s reported funds", userID)
+}
+
+// GetHistory returns the snapshots of a user's broker accounts since a time, oldest
+// first. An empty account ID returns those of every account.
+func (s *FundsSynchronizer) GetHistory(userID, accountID string, since time.Time) []FundsSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshots := []FundsSnapshot{}
+	for _, history := range s.history {
+		for _, snapshot := range history {
+			if snapshot.UserID != userID || (accountID != "" && snapshot.AccountID != accountID) || snapshot.Time.Before(since) {
+				continue
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+
+	return snapshots
+}
+
+// sync pulls the margin of a user's active broker accounts, records the snapshots
+// and alerts the user on thresholds crossed and on divergence from the local
+// estimate
+func (s *FundsSynchronizer) sync(userID string, now time.Time) (*UserFunds, []risk.Notification, error) {
+	keys, err := s.accounts.GetUserApiKeys(userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get broker accounts: %w", err)
+	}
+
+	funds := &UserFunds{
+		UserID:   userID,
+		Accounts: []FundsSnapshot{},
+		Time:     now,
+	}
+	complete := true
+	for _, key := range keys {
+		if !key.IsActive {
+			continue
+		}
+
+		snapshot := FundsSnapshot{
+			UserID:    userID,
+			AccountID: key.ID,
+			Name:      key.Name,
+			Broker:    key.Broker,
+			Time:      now,
+		}
+		margin, err := s.broker.GetAccountMargin(userID, key.ID)
+		if err != nil {
+			log.Printf("Error getting margin of broker account %s of user %s: %v", key.ID, userID, err)
+			snapshot.Error = err.Error()
+			funds.Errors = append(funds.Errors, fmt.Sprintf("account %s: %v", key.ID, err))
+			complete = false
+		} else {
+			snapshot.Available = margin.Available
+			snapshot.Utilized = margin.Utilized
+			snapshot.Funds = margin.Available + margin.Utilized
+			snapshot.Utilization = utilization(snapshot.Utilized, snapshot.Funds)
+			funds.Available += snapshot.Available
+			funds.Utilized += snapshot.Utilized
+			funds.Funds += snapshot.Funds
+		}
+		funds.Accounts = append(funds.Accounts, snapshot)
+	}
+	funds.Utilization = utilization(funds.Utilized, funds.Funds)
+
+	if s.estimator != nil {
+		estimate, err := s.estimator.CurrentMargin(userID)
+		if err != nil {
+			funds.Errors = append(funds.Errors, fmt.Sprintf("local estimate: %v", err))
+		} else {
+			funds.LocalEstimate = &estimate
+			funds.Divergence = funds.Utilized - estimate
+			funds.Diverged = complete && math.Abs(funds.Divergence) > math.Max(s.config.MinDivergence, s.config.DivergenceTolerance*math.Max(funds.Utilized, estimate))
+		}
+	}
+
+	alerts := s.record(funds, now)
+
+	if s.notifier != nil {
+		for _, alert := range alerts {
+			if err := s.notifier.Notify(alert); err != nil {
+				log.Printf("Error notifying user %s of %s: %v", userID, alert.Type, err)
+				funds.Errors = append(funds.Errors, fmt.Sprintf("failed to notify: %v", err))
+			}
+		}
+	}
+
+	return funds, alerts, nil
+}
+
+// record stores a user's funds and their snapshots, returning the alerts of the
+// thresholds newly crossed and of a new divergence
+func (s *FundsSynchronizer) record(funds *UserFunds, now time.Time) []risk.Notification {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var alerts []risk.Notification
+	for _, snapshot := range funds.Accounts {
+		key := funds.UserID + ":" + snapshot.AccountID
+		history := append(s.history[key], snapshot)
+		if len(history) > s.config.HistorySize {
+			history = history[len(history)-s.config.HistorySize:]
+		}
+		s.history[key] = history
+
+		if snapshot.Error != "" {
+			continue
+		}
+		level := 0
+		for _, threshold := range s.config.UtilizationThresholds {
+			if snapshot.Utilization >= threshold {
+				level++
+			}
+		}
+		if level > s.levels[key] {
+			alerts = append(alerts, risk.Notification{
+				UserID:  funds.UserID,
+				Type:    risk.NotificationMarginUtilization,
+				Message: fmt.Sprintf("Margin utilization of %s account %s is %.1f%%, past %.0f%%", snapshot.Broker, snapshot.Name, snapshot.Utilization, s.config.UtilizationThresholds[level-1]),
+				Time:    now,
+			})
+		}
+		s.levels[key] = level
+	}
+
+	// Divergence is only known when every account could be queried
+	comparable := funds.LocalEstimate != nil
+	for _, snapshot := range funds.Accounts {
+		if snapshot.Error != "" {
+			comparable = false
+		}
+	}
+	if comparable {
+		if funds.Diverged && !s.diverged[funds.UserID] {
+			alerts = append(alerts, risk.Notification{
+				UserID:  funds.UserID,
+				Type:    risk.NotificationMarginDivergence,
+				Message: fmt.Sprintf("Brokers report %.2f margin utilized against a local estimate of %.2f", funds.Utilized, *funds.LocalEstimate),
+				Time:    now,
+			})
+		}
+		s.diverged[funds.UserID] = funds.Diverged
+	}
+
+	latest := *funds
+	s.latest[funds.UserID] = &latest
+	return alerts
+}
+
+// utilization returns the percentage of funds utilized, none without funds
+func utilization(utilized, funds float64) float64 {
+	if funds <= 0 {
+		return 0
+	}
+	return utilized / funds * 100
+}
@@ -0,0 +1,170 @@
+package margin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/broker/common"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/risk"
+)
+
+// MockAccountProvider is a mock implementation of the AccountProvider interface
+type MockAccountProvider struct {
+	mock.Mock
+}
+
+func (m *MockAccountProvider) GetUserApiKeys(userID string) ([]models.UserApiKey, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.UserApiKey), args.Error(1)
+}
+
+// MockBrokerFundsClient is a mock implementation of the BrokerFundsClient interface
+type MockBrokerFundsClient struct {
+	mock.Mock
+}
+
+func (m *MockBrokerFundsClient) GetAccountMargin(userID, accountID string) (*common.AccountMargin, error) {
+	args := m.Called(userID, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.AccountMargin), args.Error(1)
+}
+
+// MockMarginCalculator is a mock implementation of the MarginCalculator interface
+type MockMarginCalculator struct {
+	mock.Mock
+}
+
+func (m *MockMarginCalculator) CurrentMargin(userID string) (float64, error) {
+	args := m.Called(userID)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+// MockNotifier is a mock implementation of the Notifier interface
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(notification risk.Notification) error {
+	args := m.Called(notification)
+	return args.Error(0)
+}
+
+// brokerAccounts returns the active Zerodha and XTS accounts of user1, and an
+// inactive one
+func brokerAccounts() []models.UserApiKey {
+	return []models.UserApiKey{
+		{ID: "account1", UserID: "user1", Name: "Main", Broker: "ZERODHA", IsActive: true},
+		{ID: "account2", UserID: "user1", Name: "Options", Broker: "XTS", IsActive: true},
+		{ID: "account3", UserID: "user1", Name: "Old", Broker: "ZERODHA"},
+	}
+}
+
+// accountMargin returns the margin a broker reports for an account
+func accountMargin(available, utilized float64) *common.AccountMargin {
+	return &common.AccountMargin{Available: available, Utilized: utilized}
+}
+
+func TestFundsSynchronizerRun(t *testing.T) {
+	mockPositions := &MockPositionService{positions: []models.Position{
+		{ID: "position1", UserID: "user1", Status: models.PositionStatusOpen},
+	}}
+	mockAccounts := new(MockAccountProvider)
+	mockBroker := new(MockBrokerFundsClient)
+	mockEstimator := new(MockMarginCalculator)
+	mockNotifier := new(MockNotifier)
+	synchronizer := NewFundsSynchronizer(mockPositions, mockAccounts, mockBroker, mockEstimator, mockNotifier, SyncConfig{})
+
+	mockAccounts.On("GetUserApiKeys", "user1").Return(brokerAccounts(), nil)
+	mockEstimator.On("CurrentMargin", "user1").Return(130000.0, nil)
+	mockNotifier.On("Notify", mock.Anything).Return(nil)
+
+	// 60% and 40% utilized, in line with the local estimate
+	mockBroker.On("GetAccountMargin", "user1", "account1").Return(accountMargin(40000, 60000), nil).Once()
+	mockBroker.On("GetAccountMargin", "user1", "account2").Return(accountMargin(120000, 80000), nil).Once()
+
+	start := time.Now()
+	result, err := synchronizer.Run(start)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Empty(t, result.Alerts)
+	assert.Len(t, result.Users, 1)
+	funds := result.Users[0]
+	assert.Len(t, funds.Accounts, 2)
+	assert.Equal(t, 300000.0, funds.Funds)
+	assert.Equal(t, 140000.0, funds.Utilized)
+	assert.Equal(t, 10000.0, funds.Divergence)
+	assert.False(t, funds.Diverged)
+
+	// Account1 crosses both thresholds at once, and the brokers now report far more
+	// margin utilized than estimated
+	mockBroker.On("GetAccountMargin", "user1", "account1").Return(accountMargin(5000, 95000), nil).Once()
+	mockBroker.On("GetAccountMargin", "user1", "account2").Return(accountMargin(120000, 80000), nil).Once()
+
+	result, err = synchronizer.Run(start.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, result.Alerts, 2)
+	assert.Equal(t, risk.NotificationMarginUtilization, result.Alerts[0].Type)
+	assert.Contains(t, result.Alerts[0].Message, "past 90%")
+	assert.Equal(t, risk.NotificationMarginDivergence, result.Alerts[1].Type)
+	assert.True(t, result.Users[0].Diverged)
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 2)
+
+	// Alerts are not repeated while the conditions hold, and accounts that cannot be
+	// queried keep their history
+	mockBroker.On("GetAccountMargin", "user1", "account1").Return(accountMargin(4000, 96000), nil).Once()
+	mockBroker.On("GetAccountMargin", "user1", "account2").Return(nil, errors.New("session expired")).Once()
+
+	result, err = synchronizer.Run(start.Add(2 * time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, result.Alerts)
+	assert.Len(t, result.Errors, 1)
+	assert.False(t, result.Users[0].Diverged)
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 2)
+
+	assert.Len(t, synchronizer.GetHistory("user1", "", start), 6)
+	history := synchronizer.GetHistory("user1", "account2", start.Add(time.Minute))
+	assert.Len(t, history, 2)
+	assert.Equal(t, "session expired", history[1].Error)
+
+	latest, err := synchronizer.GetUserFunds("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 100000.0, latest.Funds)
+	total, err := synchronizer.GetFunds("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 100000.0, total)
+}
+
+func TestFundsSynchronizerGetFunds(t *testing.T) {
+	mockAccounts := new(MockAccountProvider)
+	mockBroker := new(MockBrokerFundsClient)
+	synchronizer := NewFundsSynchronizer(new(MockPositionService), mockAccounts, mockBroker, nil, nil, SyncConfig{})
+
+	// Users are synchronized the first time their funds are asked for
+	mockAccounts.On("GetUserApiKeys", "user1").Return(brokerAccounts()[:1], nil)
+	mockBroker.On("GetAccountMargin", "user1", "account1").Return(accountMargin(250000, 50000), nil).Once()
+
+	funds, err := synchronizer.GetFunds("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 300000.0, funds)
+	_, err = synchronizer.GetFunds("user1")
+	assert.NoError(t, err)
+	mockBroker.AssertNumberOfCalls(t, "GetAccountMargin", 1)
+
+	// Without any broker reporting funds there are none to estimate against
+	mockAccounts.On("GetUserApiKeys", "user2").Return([]models.UserApiKey{}, nil)
+	_, err = synchronizer.GetFunds("user2")
+	assert.Error(t, err)
+
+	mockAccounts.On("GetUserApiKeys", "user3").Return(nil, errors.New("database unavailable"))
+	_, err = synchronizer.Sync("user3")
+	assert.Error(t, err)
+}
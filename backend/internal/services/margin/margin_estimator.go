@@ -0,0 +1,605 @@
+package margin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/trading-platform/backend/internal/broker/common"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// ErrInsufficientMargin is returned for orders that would take a user's margin past
+// their funds
+var ErrInsufficientMargin = errors.New("insufficient margin")
+
+// Default parameters of the internal margin engine
+const (
+	DefaultPriceScanRange      = 0.06 // Underlying moves of up to 6%
+	DefaultVolatilityScanRange = 0.25 // Volatility up or down by a quarter
+	DefaultVolatility          = 0.20
+	DefaultRiskFreeRate        = 0.07
+	DefaultExposureRate        = 0.02
+	DefaultEquityIntradayRate  = 0.20
+)
+
+// pageSize is the number of positions fetched at a time
+const pageSize = 100
+
+// daysPerYear converts times to expiry to the years option prices take
+const daysPerYear = 365.0
+
+// Source is where an estimate comes from
+type Source string
+
+const (
+	SourceBroker   Source = "BROKER"
+	SourceInternal Source = "INTERNAL"
+)
+
+// BrokerMarginProvider is a broker's margin calculator, as the broker manager
+// provides for brokers that have one
+type BrokerMarginProvider interface {
+	GetBasketMargin(userID string, orders []*common.Order) (*common.BasketMargin, error)
+}
+
+// QuoteProvider looks up the last traded price of an instrument, used for the price
+// of the underlyings of derivatives
+type QuoteProvider interface {
+	GetLastPrice(symbol, exchange string) (float64, error)
+}
+
+// FundsProvider looks up the funds a user can use as margin
+type FundsProvider interface {
+	GetFunds(userID string) (float64, error)
+}
+
+// Config configures the internal margin engine
+type Config struct {
+	PriceScanRange      float64 // Largest underlying move scanned as a fraction of its price, defaults to DefaultPriceScanRange
+	VolatilityScanRange float64 // Relative volatility change scanned, defaults to DefaultVolatilityScanRange
+	Volatility          float64 // Annual volatility options are priced at, defaults to DefaultVolatility
+	RiskFreeRate        float64 // Annual rate options are priced at, defaults to DefaultRiskFreeRate
+	ExposureRate        float64 // Of the value of futures and short options, defaults to DefaultExposureRate
+	EquityIntradayRate  float64 // Of the value of intraday stock orders, defaults to DefaultEquityIntradayRate
+}
+
+// Leg is an order of a basket. Underlying is what a derivative is on, the leading
+// letters of its symbol when not given, as NIFTY of NIFTY24JUN22000CE.
+type Leg struct {
+	models.Order
+	Underlying string `json:"underlying,omitempty"`
+}
+
+// LegMargin is the margin of a leg on its own
+type LegMargin struct {
+	Symbol    string                `json:"symbol"`
+	Direction models.OrderDirection `json:"direction"`
+	Quantity  int                   `json:"quantity"`
+	Margin    float64               `json:"margin"`
+}
+
+// Estimate is the margin a basket of orders requires. The breakdown, current margin
+// and leg margins are only known to the internal engine.
+type Estimate struct {
+	Source         Source      `json:"source"`
+	RequiredMargin float64     `json:"requiredMargin"` // Of the basket on its own, hedges within it offsetting
+	SpanMargin     float64     `json:"spanMargin,omitempty"`
+	ExposureMargin float64     `json:"exposureMargin,omitempty"`
+	PremiumMargin  float64     `json:"premiumMargin,omitempty"` // Premium of bought options
+	EquityMargin   float64     `json:"equityMargin,omitempty"`
+	CurrentMargin  float64     `json:"currentMargin,omitempty"` // Of the open positions
+	MarginAfter    float64     `json:"marginAfter"`             // Of the open positions and the basket together
+	Funds          *float64    `json:"funds,omitempty"`
+	Shortfall      float64     `json:"shortfall,omitempty"` // Margin after execution past the funds
+	Legs           []LegMargin `json:"legs,omitempty"`
+	BrokerError    string      `json:"brokerError,omitempty"` // Why the broker's calculator was not used
+	Time           time.Time   `json:"time"`
+}
+
+// MarginEstimator estimates the margin of proposed orders. The broker's margin
+// calculator is used where the user's broker has one, an internal SPAN-like engine
+// otherwise: derivatives are charged the worst loss of each underlying over a scan
+// of price and volatility scenarios less the value of its bought options, plus an
+// exposure margin on futures and sold options, bought options their premium, and
+// stocks a fraction of their value.
+type MarginEstimator struct {
+	positionService position.PositionService
+	broker          BrokerMarginProvider
+	quotes          QuoteProvider
+	funds           FundsProvider
+	config          Config
+}
+
+// NewMarginEstimator creates a new MarginEstimator. Broker and funds are optional,
+// without funds estimates carry no shortfall and orders are not checked.
+func NewMarginEstimator(
+	positionService position.PositionService,
+	broker BrokerMarginProvider,
+	quotes QuoteProvider,
+	funds FundsProvider,
+	config Config,
+) *MarginEstimator {
+	if config.PriceScanRange <= 0 {
+		config.PriceScanRange = DefaultPriceScanRange
+	}
+	if config.VolatilityScanRange <= 0 {
+		config.VolatilityScanRange = DefaultVolatilityScanRange
+	}
+	if config.Volatility <= 0 {
+		config.Volatility = DefaultVolatility
+	}
+	if config.RiskFreeRate <= 0 {
+		config.RiskFreeRate = DefaultRiskFreeRate
+	}
+	if config.ExposureRate <= 0 {
+		config.ExposureRate = DefaultExposureRate
+	}
+	if config.EquityIntradayRate <= 0 {
+		config.EquityIntradayRate = DefaultEquityIntradayRate
+	}
+
+	return &MarginEstimator{
+		positionService: positionService,
+		broker:          broker,
+		quotes:          quotes,
+		funds:           funds,
+		config:          config,
+	}
+}
+
+// Estimate returns the margin a user's basket of orders requires and the user's
+// margin once it executes
+func (e *MarginEstimator) Estimate(userID string, legs []Leg) (*Estimate, error) {
+	if userID == "" {
+		return nil, errors.New("user ID is required")
+	}
+	if len(legs) == 0 {
+		return nil, errors.New("at least one order is required")
+	}
+	legs = append([]Leg{}, legs...)
+	for i := range legs {
+		legs[i].UserID = userID
+		if legs[i].Status == "" {
+			legs[i].Status = models.OrderStatusNew
+		}
+		if err := legs[i].Validate(); err != nil {
+			return nil, fmt.Errorf("order %d: %w", i+1, err)
+		}
+	}
+
+	now := time.Now()
+	var estimate *Estimate
+	var brokerError string
+	if e.broker != nil {
+		brokerMargin, err := e.broker.GetBasketMargin(userID, brokerOrders(legs))
+		if err == nil {
+			estimate = &Estimate{
+				Source:         SourceBroker,
+				RequiredMargin: brokerMargin.Initial,
+				MarginAfter:    brokerMargin.Final,
+			}
+		} else {
+			// Fall back to the internal engine, the broker being unavailable not
+			// being a reason to refuse an estimate
+			log.Printf("Error getting basket margin of user %s from the broker: %v", userID, err)
+			brokerError = err.Error()
+		}
+	}
+
+	if estimate == nil {
+		var err error
+		estimate, err = e.estimate(userID, legs, now)
+		if err != nil {
+			return nil, err
+		}
+		estimate.BrokerError = brokerError
+	}
+	estimate.Time = now
+
+	if e.funds != nil {
+		funds, err := e.funds.GetFunds(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get funds: %w", err)
+		}
+		estimate.Funds = &funds
+		estimate.Shortfall = math.Max(estimate.MarginAfter-funds, 0)
+	}
+
+	return estimate, nil
+}
+
+// CheckOrder returns ErrInsufficientMargin for an order that would take its user's
+// margin past their funds. Orders that only reduce an open position are always
+// allowed, as are all orders when no funds provider is configured.
+func (e *MarginEstimator) CheckOrder(order *models.Order) error {
+	if e.funds == nil || order == nil || order.UserID == "" {
+		return nil
+	}
+
+	positions, err := e.openPositions(order.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to check positions of user %s: %w", order.UserID, err)
+	}
+	if reducesPosition(positions, order) {
+		return nil
+	}
+
+	estimate, err := e.Estimate(order.UserID, []Leg{{Order: *order}})
+	if err != nil {
+		return err
+	}
+	if estimate.Shortfall > 0 {
+		return fmt.Errorf("%w: %.2f required against funds of %.2f", ErrInsufficientMargin, estimate.MarginAfter, *estimate.Funds)
+	}
+
+	return nil
+}
+
+// CurrentMargin returns the internal engine's margin of a user's open positions
+func (e *MarginEstimator) CurrentMargin(userID string) (float64, error) {
+	positions, err := e.openPositions(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	var held []exposure
+	for _, openPosition := range positions {
+		held = append(held, positionExposure(openPosition))
+	}
+
+	spots, err := e.spots(held)
+	if err != nil {
+		return 0, err
+	}
+
+	return e.margin(held, spots, time.Now(), false).total(), nil
+}
+
+// exposure is a signed quantity of an instrument, long being positive
+type exposure struct {
+	underlying     string
+	exchange       string
+	symbol         string
+	instrumentType models.InstrumentType
+	productType    models.ProductType
+	optionType     models.OptionType
+	strike         float64
+	expiry         time.Time
+	quantity       int
+	price          float64 // Order or entry price
+}
+
+// breakdown is the internal engine's margin of a set of exposures
+type breakdown struct {
+	span     float64
+	exposure float64
+	premium  float64
+	equity   float64
+}
+
+func (b breakdown) total() float64 {
+	return b.span + b.exposure + b.premium + b.equity
+}
+
+// estimate estimates a basket with the internal engine
+func (e *MarginEstimator) estimate(userID string, legs []Leg, now time.Time) (*Estimate, error) {
+	positions, err := e.openPositions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	var held []exposure
+	for _, openPosition := range positions {
+		held = append(held, positionExposure(openPosition))
+	}
+	var ordered []exposure
+	for _, leg := range legs {
+		ordered = append(ordered, legExposure(leg))
+	}
+
+	spots, err := e.spots(append(append([]exposure{}, held...), ordered...))
+	if err != nil {
+		return nil, err
+	}
+
+	required := e.margin(ordered, spots, now, true)
+	current := e.margin(held, spots, now, false)
+	after := e.margin(append(append([]exposure{}, held...), ordered...), spots, now, false)
+	after.premium = required.premium
+
+	estimate := &Estimate{
+		Source:         SourceInternal,
+		RequiredMargin: required.total(),
+		SpanMargin:     required.span,
+		ExposureMargin: required.exposure,
+		PremiumMargin:  required.premium,
+		EquityMargin:   required.equity,
+		CurrentMargin:  current.total(),
+		MarginAfter:    after.total(),
+	}
+	for i, leg := range legs {
+		estimate.Legs = append(estimate.Legs, LegMargin{
+			Symbol:    leg.Symbol,
+			Direction: leg.Direction,
+			Quantity:  leg.Quantity,
+			Margin:    e.margin(ordered[i:i+1], spots, now, true).total(),
+		})
+	}
+
+	return estimate, nil
+}
+
+// margin returns the internal engine's margin of exposures. Premium is only charged
+// for orders, that of open positions having been paid.
+func (e *MarginEstimator) margin(exposures []exposure, spots map[string]float64, now time.Time, chargePremium bool) breakdown {
+	var result breakdown
+
+	byUnderlying := make(map[string][]exposure)
+	for _, item := range exposures {
+		switch item.instrumentType {
+		case models.InstrumentTypeStock:
+			rate := 1.0
+			if item.productType == models.ProductTypeMIS {
+				rate = e.config.EquityIntradayRate
+			}
+			result.equity += rate * item.price * math.Abs(float64(item.quantity))
+		default:
+			byUnderlying[item.underlying] = append(byUnderlying[item.underlying], item)
+			if item.instrumentType == models.InstrumentTypeFuture || item.quantity < 0 {
+				result.exposure += e.config.ExposureRate * spots[item.underlying] * math.Abs(float64(item.quantity))
+			}
+			if chargePremium && item.instrumentType == models.InstrumentTypeOption && item.quantity > 0 {
+				result.premium += item.price * float64(item.quantity)
+			}
+		}
+	}
+
+	for underlying, items := range byUnderlying {
+		// Bought options cover their own losses, offsetting those of the rest
+		longOptions := 0.0
+		for _, item := range items {
+			if item.instrumentType == models.InstrumentTypeOption && item.quantity > 0 {
+				longOptions += e.value([]exposure{item}, spots[underlying], e.config.Volatility, now)
+			}
+		}
+		result.span += math.Max(e.scanLoss(items, spots[underlying], now)-longOptions, 0)
+	}
+
+	return result
+}
+
+// scanLoss returns the worst loss of an underlying's exposures over the price and
+// volatility scenarios, none when every scenario gains
+func (e *MarginEstimator) scanLoss(items []exposure, spot float64, now time.Time) float64 {
+	baseValue := e.value(items, spot, e.config.Volatility, now)
+
+	worst := 0.0
+	for _, move := range []float64{-1, -2.0 / 3, -1.0 / 3, 0, 1.0 / 3, 2.0 / 3, 1} {
+		scenarioSpot := spot * (1 + move*e.config.PriceScanRange)
+		for _, volatilityMove := range []float64{-1, 1} {
+			volatility := e.config.Volatility * (1 + volatilityMove*e.config.VolatilityScanRange)
+			loss := baseValue - e.value(items, scenarioSpot, volatility, now)
+			worst = math.Max(worst, loss)
+		}
+	}
+
+	return worst
+}
+
+// value returns the value of derivative exposures with the underlying at spot
+func (e *MarginEstimator) value(items []exposure, spot, volatility float64, now time.Time) float64 {
+	total := 0.0
+	for _, item := range items {
+		unitValue := spot
+		if item.instrumentType == models.InstrumentTypeOption {
+			years := item.expiry.Sub(now).Hours() / 24 / daysPerYear
+			unitValue = blackScholesPrice(item.optionType, spot, item.strike, years, e.config.RiskFreeRate, volatility)
+		}
+		total += unitValue * float64(item.quantity)
+	}
+	return total
+}
+
+// spots returns the price of the underlying of each derivative: its last price, else
+// the price of a future or the underlying stock among the exposures
+func (e *MarginEstimator) spots(exposures []exposure) (map[string]float64, error) {
+	spots := make(map[string]float64)
+	for _, item := range exposures {
+		if item.instrumentType == models.InstrumentTypeOption {
+			continue
+		}
+		if _, exists := spots[item.underlying]; !exists && item.price > 0 {
+			spots[item.underlying] = item.price
+		}
+	}
+
+	underlyings := make(map[string]string)
+	for _, item := range exposures {
+		if item.instrumentType != models.InstrumentTypeStock {
+			underlyings[item.underlying] = item.exchange
+		}
+	}
+	for underlying, exchange := range underlyings {
+		if e.quotes != nil {
+			if price, err := e.quotes.GetLastPrice(underlying, exchange); err == nil && price > 0 {
+				spots[underlying] = price
+				continue
+			}
+		}
+		if spots[underlying] <= 0 {
+			return nil, fmt.Errorf("no price for the underlying %s", underlying)
+		}
+	}
+
+	return spots, nil
+}
+
+// openPositions returns a user's open and partially closed positions
+func (e *MarginEstimator) openPositions(userID string) ([]models.Position, error) {
+	var positions []models.Position
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial} {
+		filter := models.PositionFilter{UserID: userID, Status: status}
+		for page := 1; ; page++ {
+			batch, total, err := e.positionService.GetPositions(filter, page, pageSize)
+			if err != nil {
+				return nil, err
+			}
+			positions = append(positions, batch...)
+			if len(batch) == 0 || page*pageSize >= total {
+				break
+			}
+		}
+	}
+
+	return positions, nil
+}
+
+// positionExposure returns the exposure of the remaining quantity of a position
+func positionExposure(openPosition models.Position) exposure {
+	quantity := openPosition.RemainingQuantity()
+	if openPosition.Direction == models.PositionDirectionShort {
+		quantity = -quantity
+	}
+
+	return exposure{
+		underlying:     underlyingOf(openPosition.Symbol, openPosition.InstrumentType),
+		exchange:       openPosition.Exchange,
+		symbol:         openPosition.Symbol,
+		instrumentType: openPosition.InstrumentType,
+		productType:    openPosition.ProductType,
+		optionType:     openPosition.OptionType,
+		strike:         openPosition.StrikePrice,
+		expiry:         openPosition.Expiry,
+		quantity:       quantity,
+		price:          openPosition.EntryPrice,
+	}
+}
+
+// legExposure returns the exposure of a basket leg once filled
+func legExposure(leg Leg) exposure {
+	quantity := leg.Quantity
+	if leg.Direction == models.OrderDirectionSell {
+		quantity = -quantity
+	}
+	underlying := leg.Underlying
+	if underlying == "" {
+		underlying = underlyingOf(leg.Symbol, leg.InstrumentType)
+	}
+
+	return exposure{
+		underlying:     underlying,
+		exchange:       leg.Exchange,
+		symbol:         leg.Symbol,
+		instrumentType: leg.InstrumentType,
+		productType:    leg.ProductType,
+		optionType:     leg.OptionType,
+		strike:         leg.StrikePrice,
+		expiry:         leg.Expiry,
+		quantity:       quantity,
+		price:          leg.Price,
+	}
+}
+
+// underlyingOf returns the underlying of an instrument: a stock itself, and the
+// leading letters of the symbol of a derivative
+func underlyingOf(symbol string, instrumentType models.InstrumentType) string {
+	if instrumentType == models.InstrumentTypeStock {
+		return symbol
+	}
+	if i := strings.IndexFunc(symbol, unicode.IsDigit); i > 0 {
+		return symbol[:i]
+	}
+	return symbol
+}
+
+// reducesPosition reports whether an order only reduces an open position in its
+// instrument, as exits and square-offs do
+func reducesPosition(positions []models.Position, order *models.Order) bool {
+	for _, openPosition := range positions {
+		if openPosition.Symbol != order.Symbol || openPosition.Exchange != order.Exchange {
+			continue
+		}
+		exit := (openPosition.Direction == models.PositionDirectionLong && order.Direction == models.OrderDirectionSell) ||
+			(openPosition.Direction == models.PositionDirectionShort && order.Direction == models.OrderDirectionBuy)
+		if exit && order.Quantity <= openPosition.RemainingQuantity() {
+			return true
+		}
+	}
+	return false
+}
+
+// exchangeSegments maps exchanges to the exchange segments of broker orders
+var exchangeSegments = map[string]string{
+	"NSE": "NSECM",
+	"BSE": "BSECM",
+	"NFO": "NSEFO",
+	"BFO": "BSEFO",
+	"CDS": "NSECD",
+	"MCX": "MCXFO",
+}
+
+// brokerOrders converts basket legs to broker orders
+func brokerOrders(legs []Leg) []*common.Order {
+	orders := make([]*common.Order, len(legs))
+	for i, leg := range legs {
+		segment, exists := exchangeSegments[leg.Exchange]
+		if !exists {
+			segment = leg.Exchange
+		}
+		orderType := string(leg.OrderType)
+		if leg.OrderType == models.OrderTypeSLLimit {
+			orderType = "SL"
+		}
+
+		orders[i] = &common.Order{
+			ExchangeSegment: segment,
+			TradingSymbol:   leg.Symbol,
+			ProductType:     string(leg.ProductType),
+			OrderType:       orderType,
+			OrderSide:       string(leg.Direction),
+			OrderQuantity:   leg.Quantity,
+			LimitPrice:      leg.Price,
+			StopPrice:       leg.TriggerPrice,
+		}
+	}
+
+	// Brokers charge hedged baskets less when the hedges come first
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orders[i].OrderSide == string(models.OrderDirectionBuy) && orders[j].OrderSide != string(models.OrderDirectionBuy)
+	})
+
+	return orders
+}
+
+// blackScholesPrice returns the Black-Scholes price of a European option, its
+// intrinsic value at expiry
+func blackScholesPrice(optionType models.OptionType, spot, strike, years, rate, volatility float64) float64 {
+	if years <= 0 || volatility <= 0 {
+		if optionType == models.OptionTypePut {
+			return math.Max(strike-spot, 0)
+		}
+		return math.Max(spot-strike, 0)
+	}
+
+	deviation := volatility * math.Sqrt(years)
+	d1 := (math.Log(spot/strike) + (rate+volatility*volatility/2)*years) / deviation
+	d2 := d1 - deviation
+	discounted := strike * math.Exp(-rate*years)
+
+	if optionType == models.OptionTypePut {
+		return discounted*normalCDF(-d2) - spot*normalCDF(-d1)
+	}
+	return spot*normalCDF(d1) - discounted*normalCDF(d2)
+}
+
+// normalCDF returns the standard normal cumulative distribution function at x
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
@@ -0,0 +1,268 @@
+package margin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/broker/common"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockPositionService is a mock implementation of the PositionService interface.
+// GetPositions filters the positions it is given by user and status.
+type MockPositionService struct {
+	mock.Mock
+	positions []models.Position
+}
+
+func (m *MockPositionService) CreatePositionFromOrder(order *models.Order) (*models.Position, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositionByID(id string) (*models.Position, error) {
+	args := m.Called(id)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	var matched []models.Position
+	for _, p := range m.positions {
+		if (filter.UserID != "" && p.UserID != filter.UserID) || (filter.Status != "" && p.Status != filter.Status) {
+			continue
+		}
+		matched = append(matched, p)
+	}
+	return matched, len(matched), nil
+}
+
+func (m *MockPositionService) UpdatePosition(position *models.Position) (*models.Position, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) ClosePosition(id string, exitPrice float64, exitQuantity int) (*models.Position, error) {
+	args := m.Called(id, exitPrice, exitQuantity)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) CalculatePnL(position *models.Position) (float64, error) {
+	args := m.Called(position)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateGreeks(position *models.Position) (*models.Greeks, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Greeks), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateExposure(positions []models.Position) (float64, error) {
+	args := m.Called(positions)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) AggregatePositions(positions []models.Position, groupBy string) (map[string]models.AggregatedPosition, error) {
+	args := m.Called(positions, groupBy)
+	return args.Get(0).(map[string]models.AggregatedPosition), args.Error(1)
+}
+
+// MockBrokerMarginProvider is a mock implementation of the BrokerMarginProvider interface
+type MockBrokerMarginProvider struct {
+	mock.Mock
+}
+
+func (m *MockBrokerMarginProvider) GetBasketMargin(userID string, orders []*common.Order) (*common.BasketMargin, error) {
+	args := m.Called(userID, orders)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.BasketMargin), args.Error(1)
+}
+
+// MockQuoteProvider is a mock implementation of the QuoteProvider interface
+type MockQuoteProvider struct {
+	mock.Mock
+}
+
+func (m *MockQuoteProvider) GetLastPrice(symbol, exchange string) (float64, error) {
+	args := m.Called(symbol, exchange)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+// MockFundsProvider is a mock implementation of the FundsProvider interface
+type MockFundsProvider struct {
+	mock.Mock
+}
+
+func (m *MockFundsProvider) GetFunds(userID string) (float64, error) {
+	args := m.Called(userID)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+// niftyFuture returns an order for a lot of NIFTY futures
+func niftyFuture(direction models.OrderDirection) Leg {
+	return Leg{Order: models.Order{
+		Symbol:         "NIFTY24JUNFUT",
+		Exchange:       "NFO",
+		OrderType:      models.OrderTypeLimit,
+		Direction:      direction,
+		Quantity:       50,
+		Price:          20000,
+		ProductType:    models.ProductTypeNRML,
+		InstrumentType: models.InstrumentTypeFuture,
+	}}
+}
+
+// niftyOption returns an order for a lot of NIFTY options expiring in a month
+func niftyOption(direction models.OrderDirection, optionType models.OptionType, strike, premium float64) Leg {
+	return Leg{Order: models.Order{
+		Symbol:         "NIFTY24JUN" + map[float64]string{20000: "20000", 20500: "20500", 19500: "19500"}[strike] + string(optionType),
+		Exchange:       "NFO",
+		OrderType:      models.OrderTypeLimit,
+		Direction:      direction,
+		Quantity:       50,
+		Price:          premium,
+		ProductType:    models.ProductTypeNRML,
+		InstrumentType: models.InstrumentTypeOption,
+		OptionType:     optionType,
+		StrikePrice:    strike,
+		Expiry:         time.Now().AddDate(0, 1, 0),
+	}}
+}
+
+func TestEstimateInternal(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockQuotes := new(MockQuoteProvider)
+	estimator := NewMarginEstimator(mockPositions, nil, mockQuotes, nil, Config{})
+	mockQuotes.On("GetLastPrice", "NIFTY", "NFO").Return(20000.0, nil)
+
+	// A future is charged its worst move and exposure on its value
+	estimate, err := estimator.Estimate("user1", []Leg{niftyFuture(models.OrderDirectionBuy)})
+	assert.NoError(t, err)
+	assert.Equal(t, SourceInternal, estimate.Source)
+	assert.InDelta(t, 60000, estimate.SpanMargin, 0.01)
+	assert.InDelta(t, 20000, estimate.ExposureMargin, 0.01)
+	assert.InDelta(t, 80000, estimate.RequiredMargin, 0.01)
+	assert.Equal(t, 0.0, estimate.CurrentMargin)
+	assert.InDelta(t, 80000, estimate.MarginAfter, 0.01)
+
+	// A bought option only needs its premium
+	estimate, err = estimator.Estimate("user1", []Leg{niftyOption(models.OrderDirectionBuy, models.OptionTypeCall, 20500, 150)})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, estimate.SpanMargin)
+	assert.InDelta(t, 7500, estimate.RequiredMargin, 0.01)
+
+	// Buying a call above a sold one caps the loss
+	sold, err := estimator.Estimate("user1", []Leg{niftyOption(models.OrderDirectionSell, models.OptionTypeCall, 20000, 450)})
+	assert.NoError(t, err)
+	spread, err := estimator.Estimate("user1", []Leg{
+		niftyOption(models.OrderDirectionSell, models.OptionTypeCall, 20000, 450),
+		niftyOption(models.OrderDirectionBuy, models.OptionTypeCall, 20500, 150),
+	})
+	assert.NoError(t, err)
+	assert.Less(t, spread.SpanMargin, sold.SpanMargin)
+	assert.LessOrEqual(t, spread.SpanMargin, 500.0*50)
+	assert.Len(t, spread.Legs, 2)
+	assert.InDelta(t, sold.RequiredMargin, spread.Legs[0].Margin, 0.01)
+
+	// Orders are validated
+	invalid := niftyFuture(models.OrderDirectionBuy)
+	invalid.Quantity = 0
+	_, err = estimator.Estimate("user1", []Leg{invalid})
+	assert.Error(t, err)
+}
+
+func TestEstimateWithPositions(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	estimator := NewMarginEstimator(mockPositions, nil, nil, nil, Config{})
+
+	// Without quotes the underlying is priced from the futures held
+	mockPositions.positions = []models.Position{
+		{ID: "position1", UserID: "user1", Symbol: "NIFTY24JUNFUT", Exchange: "NFO", Direction: models.PositionDirectionLong, EntryPrice: 20000, Quantity: 50, Status: models.PositionStatusOpen, ProductType: models.ProductTypeNRML, InstrumentType: models.InstrumentTypeFuture},
+	}
+
+	// A protective put lowers the margin of the book
+	estimate, err := estimator.Estimate("user1", []Leg{niftyOption(models.OrderDirectionBuy, models.OptionTypePut, 19500, 120)})
+	assert.NoError(t, err)
+	assert.InDelta(t, 80000, estimate.CurrentMargin, 0.01)
+	assert.Less(t, estimate.MarginAfter, estimate.CurrentMargin+estimate.RequiredMargin)
+	assert.Less(t, estimate.MarginAfter-estimate.PremiumMargin, estimate.CurrentMargin)
+
+	current, err := estimator.CurrentMargin("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, estimate.CurrentMargin, current)
+
+	// Options on an underlying without a price cannot be estimated
+	option := niftyOption(models.OrderDirectionSell, models.OptionTypeCall, 20000, 450)
+	option.Underlying = "BANKNIFTY"
+	_, err = estimator.Estimate("user1", []Leg{option})
+	assert.Error(t, err)
+}
+
+func TestEstimateBroker(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockBroker := new(MockBrokerMarginProvider)
+	estimator := NewMarginEstimator(mockPositions, mockBroker, nil, nil, Config{})
+
+	// The broker's calculator is used where available, hedges first
+	mockBroker.On("GetBasketMargin", "user1", mock.MatchedBy(func(orders []*common.Order) bool {
+		return len(orders) == 2 && orders[0].OrderSide == "BUY" && orders[0].ExchangeSegment == "NSEFO"
+	})).Return(&common.BasketMargin{Initial: 42000, Final: 40000}, nil).Once()
+
+	basket := []Leg{
+		niftyFuture(models.OrderDirectionSell),
+		niftyOption(models.OrderDirectionBuy, models.OptionTypeCall, 20500, 150),
+	}
+	estimate, err := estimator.Estimate("user1", basket)
+	assert.NoError(t, err)
+	assert.Equal(t, SourceBroker, estimate.Source)
+	assert.Equal(t, 42000.0, estimate.RequiredMargin)
+	assert.Equal(t, 40000.0, estimate.MarginAfter)
+
+	// The internal engine is used when the broker fails
+	mockBroker.On("GetBasketMargin", "user1", mock.Anything).Return(nil, errors.New("margin calculation not supported by this broker"))
+	estimate, err = estimator.Estimate("user1", []Leg{niftyFuture(models.OrderDirectionBuy)})
+	assert.NoError(t, err)
+	assert.Equal(t, SourceInternal, estimate.Source)
+	assert.Equal(t, "margin calculation not supported by this broker", estimate.BrokerError)
+	assert.InDelta(t, 80000, estimate.RequiredMargin, 0.01)
+}
+
+func TestCheckOrder(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockQuotes := new(MockQuoteProvider)
+	mockFunds := new(MockFundsProvider)
+	estimator := NewMarginEstimator(mockPositions, nil, mockQuotes, mockFunds, Config{})
+	mockQuotes.On("GetLastPrice", "NIFTY", "NFO").Return(20000.0, nil)
+	mockFunds.On("GetFunds", "user1").Return(100000.0, nil)
+
+	order := niftyFuture(models.OrderDirectionBuy).Order
+	order.UserID = "user1"
+	assert.NoError(t, estimator.CheckOrder(&order))
+
+	estimate, err := estimator.Estimate("user1", []Leg{{Order: order}})
+	assert.NoError(t, err)
+	assert.Equal(t, 100000.0, *estimate.Funds)
+	assert.Equal(t, 0.0, estimate.Shortfall)
+
+	// Two lots need more than the funds
+	order.Quantity = 100
+	err = estimator.CheckOrder(&order)
+	assert.True(t, errors.Is(err, ErrInsufficientMargin))
+
+	// Exits are allowed however short the funds
+	mockPositions.positions = []models.Position{
+		{ID: "position1", UserID: "user1", Symbol: "NIFTY24JUNFUT", Exchange: "NFO", Direction: models.PositionDirectionLong, EntryPrice: 20000, Quantity: 150, Status: models.PositionStatusOpen, ProductType: models.ProductTypeNRML, InstrumentType: models.InstrumentTypeFuture},
+	}
+	order.Direction = models.OrderDirectionSell
+	assert.NoError(t, estimator.CheckOrder(&order))
+}
+
+func TestUnderlyingOf(t *testing.T) {
+	assert.Equal(t, "NIFTY", underlyingOf("NIFTY24JUN22000CE", models.InstrumentTypeOption))
+	assert.Equal(t, "BANKNIFTY", underlyingOf("BANKNIFTY24JUNFUT", models.InstrumentTypeFuture))
+	assert.Equal(t, "M&M", underlyingOf("M&M", models.InstrumentTypeStock))
+}
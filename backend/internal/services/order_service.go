@@ -1,9 +1,13 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"log"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/messagequeue"
 	"github.com/trading-platform/backend/internal/models"
 	"github.com/trading-platform/backend/internal/repositories"
 )
@@ -15,32 +19,86 @@ type OrderService interface {
 	GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error)
 	UpdateOrder(order *models.Order) (*models.Order, error)
 	CancelOrder(id string) error
+	TransitionOrder(id string, status models.OrderStatus, filledQuantity int, source models.TransitionSource, reason string) (*models.Order, error)
+	GetOrderTimeline(id string) ([]models.OrderTransition, error)
 }
 
-// OrderServiceImpl implements the OrderService interface
+// OrderEventPublisher publishes order lifecycle events for analytics, notifications
+// and other consumers. The message service publishes them to RabbitMQ, and a
+// messagequeue.BrokerEventPublisher to brokers such as NATS.
+type OrderEventPublisher interface {
+	PublishOrderEvent(ctx context.Context, msgType messagequeue.MessageType, data interface{}) error
+}
+
+// OrderEventPublishers publishes order events to several publishers, such as the
+// message service and users' webhooks. Every publisher is published to, the first
+// error being returned.
+type OrderEventPublishers []OrderEventPublisher
+
+// PublishOrderEvent publishes an order event to every publisher
+func (p OrderEventPublishers) PublishOrderEvent(ctx context.Context, msgType messagequeue.MessageType, data interface{}) error {
+	var firstErr error
+	for _, publisher := range p {
+		if err := publisher.PublishOrderEvent(ctx, msgType, data); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// orderEventTypes maps the status an order moves to to the event published for it
+var orderEventTypes = map[models.OrderStatus]messagequeue.MessageType{
+	models.OrderStatusNew:       messagequeue.OrderNew,
+	models.OrderStatusPending:   messagequeue.OrderAcked,
+	models.OrderStatusPartial:   messagequeue.OrderFill,
+	models.OrderStatusExecuted:  messagequeue.OrderFill,
+	models.OrderStatusCancelled: messagequeue.OrderCancel,
+	models.OrderStatusRejected:  messagequeue.OrderReject,
+}
+
+// eventPublishTimeout limits how long publishing an order event may take
+const eventPublishTimeout = 5 * time.Second
+
+// OrderServiceImpl implements the OrderService interface. Every change of an order's
+// status goes through the order state machine, is recorded as a transition and is
+// published as an order event.
 type OrderServiceImpl struct {
-	orderRepo repositories.OrderRepository
+	orderRepo      repositories.OrderRepository
+	transitionRepo repositories.OrderTransitionRepository
+	events         OrderEventPublisher
 }
 
-// NewOrderService creates a new OrderService
-func NewOrderService(orderRepo repositories.OrderRepository) OrderService {
+// NewOrderService creates a new OrderService, keeping transitions in memory when
+// transitionRepo is nil and publishing no events when events is nil
+func NewOrderService(orderRepo repositories.OrderRepository, transitionRepo repositories.OrderTransitionRepository, events OrderEventPublisher) OrderService {
+	if transitionRepo == nil {
+		transitionRepo = repositories.NewMemoryOrderTransitionRepository()
+	}
+
 	return &OrderServiceImpl{
-		orderRepo: orderRepo,
+		orderRepo:      orderRepo,
+		transitionRepo: transitionRepo,
+		events:         events,
 	}
 }
 
 // CreateOrder creates a new order
 func (s *OrderServiceImpl) CreateOrder(order *models.Order) (*models.Order, error) {
+	// Set initial values
+	order.Status = models.OrderStatusNew
+	order.FilledQuantity = 0
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = time.Now()
+
 	// Validate the order
 	if err := order.Validate(); err != nil {
 		return nil, err
 	}
 
-	// Set initial values
-	order.Status = models.OrderStatusPending
-	order.FilledQuantity = 0
-	order.CreatedAt = time.Now()
-	order.UpdatedAt = time.Now()
+	// A valid order is accepted for execution
+	if err := order.TransitionTo(models.OrderStatusPending, 0); err != nil {
+		return nil, err
+	}
 
 	// Create the order
 	createdOrder, err := s.orderRepo.Create(order)
@@ -48,6 +106,9 @@ func (s *OrderServiceImpl) CreateOrder(order *models.Order) (*models.Order, erro
 		return nil, err
 	}
 
+	s.recordTransition(createdOrder, "", models.OrderStatusNew, models.TransitionSourceUser, "order created")
+	s.recordTransition(createdOrder, models.OrderStatusNew, models.OrderStatusPending, models.TransitionSourceSystem, "order accepted")
+
 	return createdOrder, nil
 }
 
@@ -109,6 +170,16 @@ func (s *OrderServiceImpl) UpdateOrder(order *models.Order) (*models.Order, erro
 		return nil, errors.New("cancelled or rejected orders cannot be updated")
 	}
 
+	// A change of status must be one the state machine allows
+	statusChanged := order.Status != existingOrder.Status
+	if statusChanged {
+		status, filledQuantity := order.Status, order.FilledQuantity
+		order.Status, order.FilledQuantity = existingOrder.Status, existingOrder.FilledQuantity
+		if err := order.TransitionTo(status, filledQuantity); err != nil {
+			return nil, err
+		}
+	}
+
 	// Preserve certain fields from the existing order
 	order.CreatedAt = existingOrder.CreatedAt
 	order.UpdatedAt = time.Now()
@@ -119,6 +190,10 @@ func (s *OrderServiceImpl) UpdateOrder(order *models.Order) (*models.Order, erro
 		return nil, err
 	}
 
+	if statusChanged {
+		s.recordTransition(updatedOrder, existingOrder.Status, order.Status, models.TransitionSourceUser, "order updated")
+	}
+
 	return updatedOrder, nil
 }
 
@@ -135,14 +210,15 @@ func (s *OrderServiceImpl) CancelOrder(id string) error {
 	}
 
 	// Check if order can be cancelled
-	if existingOrder.Status != models.OrderStatusPending && 
-	   existingOrder.Status != models.OrderStatusPartial {
+	if !existingOrder.CanTransitionTo(models.OrderStatusCancelled) {
 		return errors.New("only pending or partially filled orders can be cancelled")
 	}
 
 	// Update order status
-	existingOrder.Status = models.OrderStatusCancelled
-	existingOrder.UpdatedAt = time.Now()
+	fromStatus := existingOrder.Status
+	if err := existingOrder.TransitionTo(models.OrderStatusCancelled, existingOrder.FilledQuantity); err != nil {
+		return err
+	}
 
 	// Save the updated order
 	_, err = s.orderRepo.Update(existingOrder)
@@ -150,5 +226,129 @@ func (s *OrderServiceImpl) CancelOrder(id string) error {
 		return err
 	}
 
+	s.recordTransition(existingOrder, fromStatus, models.OrderStatusCancelled, models.TransitionSourceUser, "order cancelled")
+
 	return nil
 }
+
+// TransitionOrder moves an order to a new status, such as a fill or rejection
+// reported by the broker, rejecting moves the state machine does not allow
+func (s *OrderServiceImpl) TransitionOrder(id string, status models.OrderStatus, filledQuantity int, source models.TransitionSource, reason string) (*models.Order, error) {
+	if id == "" {
+		return nil, errors.New("order ID is required")
+	}
+
+	switch source {
+	case models.TransitionSourceUser, models.TransitionSourceBroker, models.TransitionSourceSystem:
+		// Valid sources
+	default:
+		return nil, errors.New("invalid transition source")
+	}
+
+	// Check if order exists
+	existingOrder, err := s.orderRepo.GetByID(id)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+
+	// Update order status
+	fromStatus := existingOrder.Status
+	if err := existingOrder.TransitionTo(status, filledQuantity); err != nil {
+		return nil, err
+	}
+	if status == models.OrderStatusRejected {
+		existingOrder.ErrorMessage = reason
+	}
+
+	// Save the updated order
+	updatedOrder, err := s.orderRepo.Update(existingOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordTransition(existingOrder, fromStatus, existingOrder.Status, source, reason)
+
+	return updatedOrder, nil
+}
+
+// GetOrderTimeline retrieves the status transitions of an order, oldest first
+func (s *OrderServiceImpl) GetOrderTimeline(id string) ([]models.OrderTransition, error) {
+	if id == "" {
+		return nil, errors.New("order ID is required")
+	}
+
+	// Check if order exists
+	if _, err := s.orderRepo.GetByID(id); err != nil {
+		return nil, errors.New("order not found")
+	}
+
+	return s.transitionRepo.GetByOrderID(id)
+}
+
+// recordTransition records and publishes a change of an order's status. The order
+// has already been saved, so failures are logged rather than returned.
+func (s *OrderServiceImpl) recordTransition(order *models.Order, from, to models.OrderStatus, source models.TransitionSource, reason string) {
+	transition := &models.OrderTransition{
+		OrderID:        order.ID,
+		FromStatus:     from,
+		ToStatus:       to,
+		Source:         source,
+		FilledQuantity: order.FilledQuantity,
+		Reason:         reason,
+		Timestamp:      time.Now(),
+	}
+
+	if _, err := s.transitionRepo.Create(transition); err != nil {
+		log.Printf("Error recording transition of order %s from %s to %s: %v", order.ID, from, to, err)
+	}
+
+	s.publishEvent(order, transition)
+}
+
+// publishEvent publishes the order event of a transition
+func (s *OrderServiceImpl) publishEvent(order *models.Order, transition *models.OrderTransition) {
+	if s.events == nil {
+		return
+	}
+
+	msgType, exists := orderEventTypes[transition.ToStatus]
+	if !exists {
+		return
+	}
+
+	// The transition's ID identifies the event, when it was recorded
+	eventID := transition.ID
+	if eventID == "" {
+		eventID = uuid.New().String()
+	}
+
+	event := messagequeue.OrderEvent{
+		ID:             eventID,
+		Type:           msgType,
+		OrderID:        order.ID,
+		UserID:         order.UserID,
+		StrategyID:     order.StrategyID,
+		PortfolioID:    order.PortfolioID,
+		BrokerOrderID:  order.BrokerOrderID,
+		Symbol:         order.Symbol,
+		Exchange:       order.Exchange,
+		Direction:      string(order.Direction),
+		OrderType:      string(order.OrderType),
+		Quantity:       order.Quantity,
+		FilledQuantity: transition.FilledQuantity,
+		Price:          order.Price,
+		AveragePrice:   order.AveragePrice,
+		Status:         string(transition.ToStatus),
+		PreviousStatus: string(transition.FromStatus),
+		Source:         string(transition.Source),
+		Reason:         transition.Reason,
+		Timestamp:      transition.Timestamp,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventPublishTimeout)
+	defer cancel()
+
+	if err := s.events.PublishOrderEvent(ctx, msgType, event); err != nil {
+		log.Printf("Error publishing %s event of order %s: %v", msgType, order.ID, err)
+	}
+}
@@ -1,12 +1,14 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/messagequeue"
 	"github.com/trading-platform/backend/internal/models"
 	"github.com/trading-platform/backend/internal/repositories"
 )
@@ -44,6 +46,16 @@ func (m *MockOrderRepository) Delete(id string) error {
 	return args.Error(0)
 }
 
+// MockOrderEventPublisher is a mock implementation of the OrderEventPublisher interface
+type MockOrderEventPublisher struct {
+	mock.Mock
+}
+
+func (m *MockOrderEventPublisher) PublishOrderEvent(ctx context.Context, msgType messagequeue.MessageType, data interface{}) error {
+	args := m.Called(msgType, data)
+	return args.Error(0)
+}
+
 func TestCreateOrder(t *testing.T) {
 	// Create a mock repository
 	mockRepo := new(MockOrderRepository)
@@ -68,7 +80,7 @@ func TestCreateOrder(t *testing.T) {
 	mockRepo.On("Create", mock.AnythingOfType("*models.Order")).Return(order, nil)
 	
 	// Create the service with the mock repository
-	service := NewOrderService(mockRepo)
+	service := NewOrderService(mockRepo, nil, nil)
 	
 	// Call the service method
 	createdOrder, err := service.CreateOrder(order)
@@ -107,7 +119,7 @@ func TestGetOrderByID(t *testing.T) {
 	mockRepo.On("GetByID", "nonexistent").Return(nil, errors.New("order not found"))
 	
 	// Create the service with the mock repository
-	service := NewOrderService(mockRepo)
+	service := NewOrderService(mockRepo, nil, nil)
 	
 	// Test successful retrieval
 	retrievedOrder, err := service.GetOrderByID("order123")
@@ -168,7 +180,7 @@ func TestGetOrders(t *testing.T) {
 	mockRepo.On("GetAll", mock.AnythingOfType("models.OrderFilter"), 50, 50).Return([]models.Order{}, 2, nil)
 	
 	// Create the service with the mock repository
-	service := NewOrderService(mockRepo)
+	service := NewOrderService(mockRepo, nil, nil)
 	
 	// Test successful retrieval with default pagination
 	filter := models.OrderFilter{UserID: "user123"}
@@ -256,7 +268,7 @@ func TestUpdateOrder(t *testing.T) {
 	mockRepo.On("Update", mock.AnythingOfType("*models.Order")).Return(updatedOrder, nil)
 	
 	// Create the service with the mock repository
-	service := NewOrderService(mockRepo)
+	service := NewOrderService(mockRepo, nil, nil)
 	
 	// Test successful update
 	result, err := service.UpdateOrder(updatedOrder)
@@ -322,7 +334,7 @@ func TestCancelOrder(t *testing.T) {
 	})).Return(pendingOrder, nil)
 	
 	// Create the service with the mock repository
-	service := NewOrderService(mockRepo)
+	service := NewOrderService(mockRepo, nil, nil)
 	
 	// Test successful cancellation
 	err := service.CancelOrder("order123")
@@ -346,3 +358,186 @@ func TestCancelOrder(t *testing.T) {
 	// Verify that the mock repository was called
 	mockRepo.AssertExpectations(t)
 }
+
+func TestTransitionOrder(t *testing.T) {
+	// Create a mock repository
+	mockRepo := new(MockOrderRepository)
+	
+	// Create a sample order
+	pendingOrder := &models.Order{
+		ID:             "order123",
+		UserID:         "user123",
+		Symbol:         "NIFTY",
+		Exchange:       "NSE",
+		OrderType:      models.OrderTypeLimit,
+		Direction:      models.OrderDirectionBuy,
+		Quantity:       10,
+		Price:          500.50,
+		Status:         models.OrderStatusPending,
+		ProductType:    models.ProductTypeMIS,
+		InstrumentType: models.InstrumentTypeFuture,
+	}
+	
+	// Set up the mock repository expectations
+	mockRepo.On("GetByID", "order123").Return(pendingOrder, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*models.Order")).Return(pendingOrder, nil)
+	
+	// Create the service with the mock repository
+	service := NewOrderService(mockRepo, nil, nil)
+	
+	// Test partial fill reported by the broker
+	result, err := service.TransitionOrder("order123", models.OrderStatusPartial, 4, models.TransitionSourceBroker, "partial fill")
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderStatusPartial, result.Status)
+	assert.Equal(t, 4, result.FilledQuantity)
+	
+	// Test a fill cannot be undone
+	_, err = service.TransitionOrder("order123", models.OrderStatusPartial, 2, models.TransitionSourceBroker, "partial fill")
+	assert.Error(t, err)
+	
+	// Test complete fill
+	result, err = service.TransitionOrder("order123", models.OrderStatusFilled, 0, models.TransitionSourceBroker, "filled")
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderStatusExecuted, result.Status)
+	assert.Equal(t, 10, result.FilledQuantity)
+	
+	// Test invalid transitions out of a final status
+	_, err = service.TransitionOrder("order123", models.OrderStatusCancelled, 10, models.TransitionSourceUser, "cancel")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid order status transition from EXECUTED to CANCELLED")
+	
+	// Test invalid source
+	_, err = service.TransitionOrder("order123", models.OrderStatusRejected, 0, "", "rejected")
+	assert.Error(t, err)
+	
+	// Verify that the mock repository was called
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetOrderTimeline(t *testing.T) {
+	// Create a mock repository
+	mockRepo := new(MockOrderRepository)
+	
+	// Create a sample order
+	order := &models.Order{
+		UserID:         "user123",
+		Symbol:         "NIFTY",
+		Exchange:       "NSE",
+		OrderType:      models.OrderTypeMarket,
+		Direction:      models.OrderDirectionSell,
+		Quantity:       10,
+		ProductType:    models.ProductTypeMIS,
+		InstrumentType: models.InstrumentTypeFuture,
+	}
+	
+	// Set up the mock repository expectations
+	mockRepo.On("Create", mock.AnythingOfType("*models.Order")).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.Order).ID = "order123"
+	}).Return(order, nil)
+	mockRepo.On("GetByID", "order123").Return(order, nil)
+	mockRepo.On("GetByID", "nonexistent").Return(nil, errors.New("order not found"))
+	mockRepo.On("Update", mock.AnythingOfType("*models.Order")).Return(order, nil)
+	
+	// Create the service with an in-memory transition repository
+	service := NewOrderService(mockRepo, repositories.NewMemoryOrderTransitionRepository(), nil)
+	
+	// Create, reject and then try to cancel the order
+	_, err := service.CreateOrder(order)
+	assert.NoError(t, err)
+	_, err = service.TransitionOrder("order123", models.OrderStatusRejected, 0, models.TransitionSourceBroker, "insufficient margin")
+	assert.NoError(t, err)
+	assert.Equal(t, "insufficient margin", order.ErrorMessage)
+	err = service.CancelOrder("order123")
+	assert.Error(t, err)
+	
+	// Test the timeline records every transition with its source
+	timeline, err := service.GetOrderTimeline("order123")
+	assert.NoError(t, err)
+	assert.Len(t, timeline, 3)
+	assert.Equal(t, models.OrderStatus(""), timeline[0].FromStatus)
+	assert.Equal(t, models.OrderStatusNew, timeline[0].ToStatus)
+	assert.Equal(t, models.TransitionSourceUser, timeline[0].Source)
+	assert.Equal(t, models.OrderStatusPending, timeline[1].ToStatus)
+	assert.Equal(t, models.TransitionSourceSystem, timeline[1].Source)
+	assert.Equal(t, models.OrderStatusPending, timeline[2].FromStatus)
+	assert.Equal(t, models.OrderStatusRejected, timeline[2].ToStatus)
+	assert.Equal(t, models.TransitionSourceBroker, timeline[2].Source)
+	
+	// Test timeline of non-existent order
+	_, err = service.GetOrderTimeline("nonexistent")
+	assert.Error(t, err)
+	
+	// Verify that the mock repository was called
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrderEvents(t *testing.T) {
+	// Create a mock repository and event publisher
+	mockRepo := new(MockOrderRepository)
+	mockEvents := new(MockOrderEventPublisher)
+	
+	// Create a sample order
+	order := &models.Order{
+		UserID:         "user123",
+		StrategyID:     "strategy123",
+		Symbol:         "NIFTY",
+		Exchange:       "NSE",
+		OrderType:      models.OrderTypeLimit,
+		Direction:      models.OrderDirectionBuy,
+		Quantity:       10,
+		Price:          500.50,
+		ProductType:    models.ProductTypeMIS,
+		InstrumentType: models.InstrumentTypeFuture,
+	}
+	
+	// Set up the mock expectations
+	mockRepo.On("Create", mock.AnythingOfType("*models.Order")).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.Order).ID = "order123"
+	}).Return(order, nil)
+	mockRepo.On("GetByID", "order123").Return(order, nil)
+	mockRepo.On("Update", mock.AnythingOfType("*models.Order")).Return(order, nil)
+	mockEvents.On("PublishOrderEvent", mock.Anything, mock.Anything).Return(nil).Once()
+	mockEvents.On("PublishOrderEvent", mock.Anything, mock.Anything).Return(errors.New("broker unavailable"))
+	
+	// Create the service with the mock event publisher
+	service := NewOrderService(mockRepo, nil, mockEvents)
+	
+	// Create, partially fill and cancel the order
+	_, err := service.CreateOrder(order)
+	assert.NoError(t, err)
+	_, err = service.TransitionOrder("order123", models.OrderStatusPartial, 4, models.TransitionSourceBroker, "partial fill")
+	assert.NoError(t, err, "publish failures must not fail the order")
+	err = service.CancelOrder("order123")
+	assert.NoError(t, err)
+	
+	// Test an event was published for every transition
+	var types []messagequeue.MessageType
+	var events []messagequeue.OrderEvent
+	for _, call := range mockEvents.Calls {
+		types = append(types, call.Arguments.Get(0).(messagequeue.MessageType))
+		events = append(events, call.Arguments.Get(1).(messagequeue.OrderEvent))
+	}
+	assert.Equal(t, []messagequeue.MessageType{
+		messagequeue.OrderNew,
+		messagequeue.OrderAcked,
+		messagequeue.OrderFill,
+		messagequeue.OrderCancel,
+	}, types)
+	
+	// Test the fill event carries the order and the transition
+	fill := events[2]
+	assert.NotEmpty(t, fill.ID)
+	assert.Equal(t, messagequeue.OrderFill, fill.Type)
+	assert.Equal(t, "order123", fill.OrderID)
+	assert.Equal(t, "user123", fill.UserID)
+	assert.Equal(t, "strategy123", fill.StrategyID)
+	assert.Equal(t, 4, fill.FilledQuantity)
+	assert.Equal(t, string(models.OrderStatusPending), fill.PreviousStatus)
+	assert.Equal(t, string(models.OrderStatusPartial), fill.Status)
+	assert.Equal(t, string(models.TransitionSourceBroker), fill.Source)
+	assert.NotEqual(t, events[0].ID, events[1].ID)
+	
+	// Verify that the mocks were called
+	mockRepo.AssertExpectations(t)
+	mockEvents.AssertExpectations(t)
+}
@@ -0,0 +1,607 @@
+// Package premium runs portfolios on the combined premium of their legs: entering
+// portfolios in combined premium execution mode once their premium has moved as
+// far as they wait for, and exiting portfolios at a combined premium target or
+// stop loss.
+package premium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/orderexecution"
+)
+
+const (
+	// DefaultInterval is how often the monitor checks combined premiums
+	DefaultInterval = time.Second
+
+	// DefaultEntryTimeout limits how long placing a portfolio may take
+	DefaultEntryTimeout = 30 * time.Second
+
+	// pageSize is the number of portfolios and positions fetched at a time
+	pageSize = 100
+)
+
+// Reasons portfolios are exited for
+const (
+	ExitReasonTarget   = "TARGET"
+	ExitReasonStopLoss = "STOP_LOSS"
+)
+
+// PortfolioStore finds and updates portfolios, as the portfolio repository does
+type PortfolioStore interface {
+	Find(filter models.PortfolioFilter, page, limit int) ([]*models.Portfolio, int, error)
+	Update(portfolio *models.Portfolio) error
+}
+
+// StrategyProvider looks up the strategy a portfolio runs under
+type StrategyProvider interface {
+	GetByID(id string) (*models.Strategy, error)
+}
+
+// BasketExecutor places the legs of a portfolio, as orderexecution.BasketExecutor
+// does
+type BasketExecutor interface {
+	Execute(ctx context.Context, portfolio *models.Portfolio) (*orderexecution.BasketResult, error)
+}
+
+// QuoteProvider looks up the last traded price of a leg's instrument
+type QuoteProvider interface {
+	GetLastPrice(symbol, exchange string) (float64, error)
+}
+
+// PositionProvider finds positions, as the position service does
+type PositionProvider interface {
+	GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error)
+}
+
+// PositionExiter exits positions with their portfolios' exit settings, as
+// squareoff.SquareOffScheduler does, returning the IDs of the exit orders placed
+// and why the others failed
+type PositionExiter interface {
+	ExitPositions(positions []models.Position) ([]string, []string)
+}
+
+// Calendar knows the days exchanges are open, as squareoff.HolidayCalendar does
+type Calendar interface {
+	IsTradingDay(exchange string, date time.Time) bool
+}
+
+// weekdays is the calendar of exchanges open every weekday
+type weekdays struct{}
+
+func (weekdays) IsTradingDay(exchange string, date time.Time) bool {
+	return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday
+}
+
+// Config configures the combined premium monitor
+type Config struct {
+	Location     *time.Location // Time zone of run days and hours, defaults to the local time zone
+	Interval     time.Duration  // Between runs, defaults to DefaultInterval
+	EntryTimeout time.Duration  // Of placing each portfolio, defaults to DefaultEntryTimeout
+}
+
+// Entry is a portfolio placed by the monitor on its combined premium
+type Entry struct {
+	PortfolioID string  `json:"portfolioId"`
+	UserID      string  `json:"userId"`
+	Version     int     `json:"version,omitempty"` // Configuration version of the portfolio placed
+	Premium     float64 `json:"premium"`
+	Reference   float64 `json:"reference"` // Premium the move waited for was measured from
+	Placed      bool    `json:"placed"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// Exit is a portfolio whose open positions the monitor exited at its combined
+// premium target or stop loss
+type Exit struct {
+	PortfolioID string   `json:"portfolioId"`
+	UserID      string   `json:"userId"`
+	Reason      string   `json:"reason"`
+	Premium     float64  `json:"premium"`
+	Threshold   float64  `json:"threshold"`
+	OrderIDs    []string `json:"orderIds,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// RunResult is the outcome of one run of the monitor
+type RunResult struct {
+	Time    time.Time `json:"time"`
+	Entries []Entry   `json:"entries,omitempty"`
+	Exits   []Exit    `json:"exits,omitempty"`
+	Errors  []string  `json:"errors,omitempty"`
+}
+
+// watch is what the monitor tracks of a portfolio through a day
+type watch struct {
+	date       string
+	checkedAt  time.Time
+	reference  float64
+	referenced bool
+	entered    bool
+	exited     bool
+}
+
+// CombinedPremiumMonitor tracks the combined premium of portfolios' legs: the
+// prices of the legs sold less those of the legs bought, weighted by their lots
+// relative to the smallest leg, and taken as a positive amount whether the
+// portfolio is a net credit or a net debit.
+//
+// Active portfolios in combined premium execution mode are placed once a day
+// within their window, provided their strategy is active, when their premium has
+// moved by their combined wait and trade percentage, up when positive and down
+// when negative, from the premium first seen that day, right away when it is zero.
+// Trailing the wait and trade moves the premium the move is measured from along
+// with the premium while it moves the other way.
+//
+// The open positions of active portfolios with a combined premium target or stop
+// loss are exited once the premium reaches it: for net credits a target is reached
+// when the premium decays to it and a stop loss when it rises to it, the other way
+// around for net debits.
+//
+// Premiums are checked as the portfolio's combined monitoring type says: every run
+// when real time, on the first run of every minute on minute close, and every
+// monitoring interval on interval.
+type CombinedPremiumMonitor struct {
+	portfolios PortfolioStore
+	strategies StrategyProvider
+	executor   BasketExecutor
+	quotes     QuoteProvider
+	positions  PositionProvider
+	exiter     PositionExiter
+	calendar   Calendar
+	config     Config
+	watches    map[string]*watch // By portfolio
+	lastRun    *RunResult
+	stop       chan struct{}
+	mutex      sync.Mutex
+}
+
+// NewCombinedPremiumMonitor creates a new CombinedPremiumMonitor. Without a
+// calendar only weekends are treated as closed.
+func NewCombinedPremiumMonitor(
+	portfolios PortfolioStore,
+	strategies StrategyProvider,
+	executor BasketExecutor,
+	quotes QuoteProvider,
+	positions PositionProvider,
+	exiter PositionExiter,
+	calendar Calendar,
+	config Config,
+) *CombinedPremiumMonitor {
+	if calendar == nil {
+		calendar = weekdays{}
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.EntryTimeout <= 0 {
+		config.EntryTimeout = DefaultEntryTimeout
+	}
+
+	return &CombinedPremiumMonitor{
+		portfolios: portfolios,
+		strategies: strategies,
+		executor:   executor,
+		quotes:     quotes,
+		positions:  positions,
+		exiter:     exiter,
+		calendar:   calendar,
+		config:     config,
+		watches:    make(map[string]*watch),
+	}
+}
+
+// Start runs the monitor every interval until it is stopped
+func (m *CombinedPremiumMonitor) Start() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		return errors.New("combined premium monitor is already running")
+	}
+	m.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := m.Run(now)
+				if err != nil {
+					log.Printf("Error running combined premium monitor: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Combined premium monitor error: %s", message)
+				}
+			}
+		}
+	}(m.stop)
+
+	return nil
+}
+
+// Stop stops the monitor
+func (m *CombinedPremiumMonitor) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+// GetLastRun returns the outcome of the last run, nil before the first
+func (m *CombinedPremiumMonitor) GetLastRun() *RunResult {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.lastRun
+}
+
+// Run checks the combined premium of the active portfolios due a check at now,
+// placing and exiting those whose premium reached their thresholds
+func (m *CombinedPremiumMonitor) Run(now time.Time) (*RunResult, error) {
+	now = now.In(m.config.Location)
+	result := &RunResult{
+		Time: now,
+	}
+
+	// Strategies are looked up once a run
+	strategies := make(map[string]*models.Strategy)
+
+	filter := models.PortfolioFilter{Status: models.PortfolioStatusActive}
+	for page := 1; ; page++ {
+		portfolios, total, err := m.portfolios.Find(filter, page, pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get active portfolios: %w", err)
+		}
+
+		for _, portfolio := range portfolios {
+			m.check(portfolio, now, strategies, result)
+		}
+
+		if len(portfolios) == 0 || page*pageSize >= total {
+			break
+		}
+	}
+
+	m.mutex.Lock()
+	m.lastRun = result
+	m.mutex.Unlock()
+
+	return result, nil
+}
+
+// check checks the combined premium of a portfolio waiting to be placed on it or
+// exited at it
+func (m *CombinedPremiumMonitor) check(portfolio *models.Portfolio, now time.Time, strategies map[string]*models.Strategy, result *RunResult) {
+	state := m.watch(portfolio, now)
+
+	entering := m.waitsForEntry(portfolio, state, now, strategies, result)
+	var open []models.Position
+	if !entering && exitsOnPremium(portfolio) && !state.exited && !sameDay(portfolio.ExecutionEndTime, now, m.config.Location) {
+		var err error
+		open, err = m.openPositions(portfolio.ID)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("positions of portfolio %s: %v", portfolio.ID, err))
+			return
+		}
+	}
+	if !entering && len(open) == 0 {
+		return
+	}
+
+	if !m.due(portfolio, state, now) {
+		return
+	}
+	net, err := m.netPremium(portfolio)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("premium of portfolio %s: %v", portfolio.ID, err))
+		return
+	}
+	premium := math.Abs(net)
+
+	if entering {
+		if m.moved(portfolio, state, premium) {
+			result.Entries = append(result.Entries, m.enter(portfolio, state, premium, now, result))
+		}
+		return
+	}
+
+	reason, threshold, reached := exitThreshold(portfolio, net, premium)
+	if reached {
+		result.Exits = append(result.Exits, m.exit(portfolio, state, open, reason, premium, threshold, now, result))
+	}
+}
+
+// watch returns what the monitor tracks of a portfolio on the day of now
+func (m *CombinedPremiumMonitor) watch(portfolio *models.Portfolio, now time.Time) *watch {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	date := now.Format("2006-01-02")
+	state, exists := m.watches[portfolio.ID]
+	if !exists || state.date != date {
+		state = &watch{date: date}
+		m.watches[portfolio.ID] = state
+	}
+	return state
+}
+
+// waitsForEntry checks if a portfolio in combined premium execution mode is within
+// its window and not yet placed today, with its strategy active
+func (m *CombinedPremiumMonitor) waitsForEntry(portfolio *models.Portfolio, state *watch, now time.Time, strategies map[string]*models.Strategy, result *RunResult) bool {
+	if portfolio.ExecutionMode != models.ExecutionModeCombinedPremium || state.entered {
+		return false
+	}
+	if sameDay(portfolio.ExecutionStartTime, now, m.config.Location) {
+		return false
+	}
+	if !portfolio.RunsAt(now) || !m.calendar.IsTradingDay(portfolio.Exchange, now) {
+		return false
+	}
+
+	if portfolio.StrategyID != "" {
+		strategy, exists := strategies[portfolio.StrategyID]
+		if !exists {
+			var err error
+			strategy, err = m.strategies.GetByID(portfolio.StrategyID)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("strategy %s of portfolio %s: %v", portfolio.StrategyID, portfolio.ID, err))
+			}
+			strategies[portfolio.StrategyID] = strategy
+		}
+		if strategy == nil || strategy.Status != models.StrategyStatusActive {
+			return false
+		}
+	}
+
+	return true
+}
+
+// due checks if a portfolio's combined premium is due a check at now, as its
+// combined monitoring type says
+func (m *CombinedPremiumMonitor) due(portfolio *models.Portfolio, state *watch, now time.Time) bool {
+	last := state.checkedAt
+	if !last.IsZero() {
+		switch portfolio.CombinedMonitoringType {
+		case models.MonitoringTypeMinuteClose:
+			if now.Truncate(time.Minute).Equal(last.Truncate(time.Minute)) {
+				return false
+			}
+		case models.MonitoringTypeInterval:
+			if now.Sub(last) < time.Duration(portfolio.MonitoringInterval)*time.Second {
+				return false
+			}
+		}
+	}
+
+	state.checkedAt = now
+	return true
+}
+
+// netPremium returns the prices of a portfolio's sold legs less those of its
+// bought legs, weighted by their lots relative to its smallest leg
+func (m *CombinedPremiumMonitor) netPremium(portfolio *models.Portfolio) (float64, error) {
+	if len(portfolio.Legs) == 0 {
+		return 0, errors.New("portfolio has no legs")
+	}
+
+	minLots := 0
+	for _, leg := range portfolio.Legs {
+		if leg.Lots > 0 && (minLots == 0 || leg.Lots < minLots) {
+			minLots = leg.Lots
+		}
+	}
+
+	net := 0.0
+	for _, leg := range portfolio.Legs {
+		exchange := leg.Exchange
+		if exchange == "" {
+			exchange = portfolio.Exchange
+		}
+		price, err := m.quotes.GetLastPrice(leg.Symbol, exchange)
+		if err != nil {
+			return 0, fmt.Errorf("price of leg %d: %w", leg.ID, err)
+		}
+
+		weight := 1.0
+		if leg.Lots > 0 {
+			weight = float64(leg.Lots) / float64(minLots)
+		}
+		if leg.BuySell == string(models.OrderDirectionBuy) {
+			weight = -weight
+		}
+		net += weight * price
+	}
+
+	return net, nil
+}
+
+// moved checks if a portfolio's premium has moved by its combined wait and trade
+// percentage from the premium it is measured from, which is the first premium seen
+// today, trailing the premium while it moves the other way when the portfolio
+// trails its wait and trade
+func (m *CombinedPremiumMonitor) moved(portfolio *models.Portfolio, state *watch, premium float64) bool {
+	wait := portfolio.CombinedWaitAndTrade
+	if wait == 0 {
+		state.reference = premium
+		return true
+	}
+
+	if !state.referenced {
+		state.reference, state.referenced = premium, true
+		return false
+	}
+	if portfolio.TrailWaitAndTrade && ((wait > 0 && premium < state.reference) || (wait < 0 && premium > state.reference)) {
+		state.reference = premium
+	}
+	if state.reference == 0 {
+		return false
+	}
+
+	change := percentChange(state.reference, premium)
+	if wait > 0 {
+		return change >= wait
+	}
+	return change <= wait
+}
+
+// enter places a portfolio once for the day, whatever the outcome, so that legs
+// already placed are not placed again
+func (m *CombinedPremiumMonitor) enter(portfolio *models.Portfolio, state *watch, premium float64, now time.Time, result *RunResult) Entry {
+	state.entered = true
+
+	entry := Entry{
+		PortfolioID: portfolio.ID,
+		UserID:      portfolio.UserID,
+		Version:     portfolio.Version,
+		Premium:     premium,
+		Reference:   state.reference,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.EntryTimeout)
+	basket, err := m.executor.Execute(ctx, portfolio)
+	cancel()
+
+	switch {
+	case err != nil:
+		entry.Error = err.Error()
+	case !basket.Status:
+		entry.Error = basket.Error
+	default:
+		entry.Placed = true
+	}
+
+	at := fmt.Sprintf("combined premium %.2f, %+.2f%% from %.2f", premium, percentChange(state.reference, premium), state.reference)
+	portfolio.ExecutionStartTime = now
+	if entry.Placed {
+		portfolio.AddExecutionLog("Entered at " + at)
+		log.Printf("Entered portfolio %s of user %s at %s", portfolio.ID, portfolio.UserID, at)
+	} else {
+		portfolio.AddExecutionLog("Entry at " + at + " failed: " + entry.Error)
+		log.Printf("Portfolio %s of user %s failed to be entered at %s: %s", portfolio.ID, portfolio.UserID, at, entry.Error)
+	}
+	if err := m.portfolios.Update(portfolio); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("portfolio %s: %v", portfolio.ID, err))
+	}
+
+	return entry
+}
+
+// exit exits the open positions of a portfolio whose premium reached its target or
+// stop loss. Once any exit order is placed the portfolio is not exited again
+// today, so that positions whose exit is still pending are not exited twice.
+func (m *CombinedPremiumMonitor) exit(portfolio *models.Portfolio, state *watch, open []models.Position, reason string, premium, threshold float64, now time.Time, result *RunResult) Exit {
+	exit := Exit{
+		PortfolioID: portfolio.ID,
+		UserID:      portfolio.UserID,
+		Reason:      reason,
+		Premium:     premium,
+		Threshold:   threshold,
+	}
+	exit.OrderIDs, exit.Errors = m.exiter.ExitPositions(open)
+	if len(exit.OrderIDs) == 0 {
+		log.Printf("Exit of portfolio %s of user %s at combined premium %.2f failed: %v", portfolio.ID, portfolio.UserID, premium, exit.Errors)
+		return exit
+	}
+	state.exited = true
+
+	message := fmt.Sprintf("Exited at combined premium %.2f, reaching its %s of %.2f", premium, reasonName(reason), threshold)
+	portfolio.ExecutionEndTime = now
+	portfolio.AddExecutionLog(message)
+	log.Printf("%s: portfolio %s of user %s", message, portfolio.ID, portfolio.UserID)
+	if err := m.portfolios.Update(portfolio); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("portfolio %s: %v", portfolio.ID, err))
+	}
+
+	return exit
+}
+
+// openPositions returns the open and partially closed positions of a portfolio
+func (m *CombinedPremiumMonitor) openPositions(portfolioID string) ([]models.Position, error) {
+	var positions []models.Position
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial} {
+		filter := models.PositionFilter{
+			PortfolioID: portfolioID,
+			Status:      status,
+		}
+
+		for page := 1; ; page++ {
+			batch, total, err := m.positions.GetPositions(filter, page, pageSize)
+			if err != nil {
+				return positions, err
+			}
+			positions = append(positions, batch...)
+			if len(batch) == 0 || page*pageSize >= total {
+				break
+			}
+		}
+	}
+
+	return positions, nil
+}
+
+// exitsOnPremium checks if a portfolio has a combined premium target or stop loss
+func exitsOnPremium(portfolio *models.Portfolio) bool {
+	return (portfolio.TargetType == models.TargetTypeCombinedPremium && portfolio.TargetValue > 0) ||
+		(portfolio.StopLossType == models.StopLossTypeCombinedPremium && portfolio.StopLossValue > 0)
+}
+
+// exitThreshold returns which of a portfolio's combined premium target and stop
+// loss its premium reached, if any, and its value. Net credits, with a positive
+// net premium, profit as the premium decays and net debits as it rises.
+func exitThreshold(portfolio *models.Portfolio, net, premium float64) (string, float64, bool) {
+	credit := net >= 0
+
+	if portfolio.StopLossType == models.StopLossTypeCombinedPremium && portfolio.StopLossValue > 0 {
+		threshold := portfolio.StopLossValue
+		if (credit && premium >= threshold) || (!credit && premium <= threshold) {
+			return ExitReasonStopLoss, threshold, true
+		}
+	}
+	if portfolio.TargetType == models.TargetTypeCombinedPremium && portfolio.TargetValue > 0 {
+		threshold := portfolio.TargetValue
+		if (credit && premium <= threshold) || (!credit && premium >= threshold) {
+			return ExitReasonTarget, threshold, true
+		}
+	}
+
+	return "", 0, false
+}
+
+// reasonName returns how an exit reason reads in execution logs
+func reasonName(reason string) string {
+	if reason == ExitReasonStopLoss {
+		return "stop loss"
+	}
+	return "target"
+}
+
+// percentChange returns the change from reference to value in percent
+func percentChange(reference, value float64) float64 {
+	if reference == 0 {
+		return 0
+	}
+	return (value - reference) / reference * 100
+}
+
+// sameDay checks if a time is set and on the day of now in location
+func sameDay(at, now time.Time, location *time.Location) bool {
+	return !at.IsZero() && at.In(location).Format("2006-01-02") == now.Format("2006-01-02")
+}
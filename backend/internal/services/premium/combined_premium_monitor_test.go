@@ -0,0 +1,276 @@
+package premium
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/orderexecution"
+)
+
+// stubPortfolios is a portfolio store of portfolios by ID
+type stubPortfolios map[string]*models.Portfolio
+
+func (s stubPortfolios) Find(filter models.PortfolioFilter, page, limit int) ([]*models.Portfolio, int, error) {
+	var portfolios []*models.Portfolio
+	for _, portfolio := range s {
+		if portfolio.Status == filter.Status {
+			copied := *portfolio
+			portfolios = append(portfolios, &copied)
+		}
+	}
+	return portfolios, len(portfolios), nil
+}
+
+func (s stubPortfolios) Update(portfolio *models.Portfolio) error {
+	copied := *portfolio
+	s[portfolio.ID] = &copied
+	return nil
+}
+
+// stubStrategies is a strategy provider of strategies by ID
+type stubStrategies map[string]*models.Strategy
+
+func (s stubStrategies) GetByID(id string) (*models.Strategy, error) {
+	strategy, exists := s[id]
+	if !exists {
+		return nil, errors.New("strategy not found")
+	}
+	return strategy, nil
+}
+
+// recordingExecutor records the portfolios it places
+type recordingExecutor struct {
+	placed []string
+	mutex  sync.Mutex
+}
+
+func (e *recordingExecutor) Execute(ctx context.Context, portfolio *models.Portfolio) (*orderexecution.BasketResult, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.placed = append(e.placed, portfolio.ID)
+	return &orderexecution.BasketResult{PortfolioID: portfolio.ID, Status: true}, nil
+}
+
+// stubQuotes is a quote provider of last prices by symbol, counting lookups
+type stubQuotes struct {
+	prices  map[string]float64
+	lookups int
+}
+
+func (q *stubQuotes) GetLastPrice(symbol, exchange string) (float64, error) {
+	q.lookups++
+	price, exists := q.prices[symbol]
+	if !exists {
+		return 0, errors.New("no quote for " + symbol)
+	}
+	return price, nil
+}
+
+// stubPositions is a position provider filtering positions by portfolio and status
+type stubPositions struct {
+	positions []models.Position
+}
+
+func (s *stubPositions) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	var matching []models.Position
+	for _, position := range s.positions {
+		if position.PortfolioID == filter.PortfolioID && position.Status == filter.Status {
+			matching = append(matching, position)
+		}
+	}
+	return matching, len(matching), nil
+}
+
+// recordingExiter records the positions it exits
+type recordingExiter struct {
+	exited []string
+}
+
+func (e *recordingExiter) ExitPositions(positions []models.Position) ([]string, []string) {
+	var orderIDs []string
+	for _, position := range positions {
+		e.exited = append(e.exited, position.ID)
+		orderIDs = append(orderIDs, "exit-"+position.ID)
+	}
+	return orderIDs, nil
+}
+
+// premiumPortfolio returns an active portfolio of two legs, sold when sell is set
+func premiumPortfolio(id string, mode models.ExecutionMode, sell bool, call, put string) *models.Portfolio {
+	direction := string(models.OrderDirectionBuy)
+	if sell {
+		direction = string(models.OrderDirectionSell)
+	}
+	return &models.Portfolio{
+		ID:                     id,
+		UserID:                 "user1",
+		Status:                 models.PortfolioStatusActive,
+		Exchange:               "NFO",
+		ExecutionMode:          mode,
+		RunOnDays:              []string{"MONDAY"},
+		StartTime:              "09:20:00",
+		EndTime:                "15:00:00",
+		CombinedMonitoringType: models.MonitoringTypeRealtime,
+		Legs: []models.Leg{
+			{ID: 1, Symbol: call, BuySell: direction, Lots: 1},
+			{ID: 2, Symbol: put, BuySell: direction, Lots: 1},
+		},
+	}
+}
+
+func TestCombinedPremiumEntry(t *testing.T) {
+	portfolios := stubPortfolios{
+		"straddle":  premiumPortfolio("straddle", models.ExecutionModeCombinedPremium, true, "NIFTY18000CE", "NIFTY18000PE"),
+		"immediate": premiumPortfolio("immediate", models.ExecutionModeCombinedPremium, true, "NIFTY18000CE", "NIFTY18000PE"),
+		"paused":    premiumPortfolio("paused", models.ExecutionModeCombinedPremium, true, "NIFTY18000CE", "NIFTY18000PE"),
+		"timed":     premiumPortfolio("timed", models.ExecutionModeTime, true, "NIFTY18000CE", "NIFTY18000PE"),
+	}
+	portfolios["straddle"].CombinedWaitAndTrade = 10
+	portfolios["straddle"].TrailWaitAndTrade = true
+	portfolios["paused"].StrategyID = "strategy1"
+	strategies := stubStrategies{"strategy1": {ID: "strategy1", Status: models.StrategyStatusPaused}}
+	executor := &recordingExecutor{}
+	quotes := &stubQuotes{prices: map[string]float64{"NIFTY18000CE": 100, "NIFTY18000PE": 100}}
+	monitor := NewCombinedPremiumMonitor(portfolios, strategies, executor, quotes, &stubPositions{}, &recordingExiter{}, nil, Config{Location: time.UTC})
+
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+
+	// Nothing is checked before the window opens
+	result, err := monitor.Run(monday.Add(9*time.Hour + 15*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, result.Entries)
+	assert.Zero(t, quotes.lookups)
+
+	// Without a wait the portfolio is placed right away, while the premium the move
+	// is measured from is taken for the other
+	result, err = monitor.Run(monday.Add(9*time.Hour + 20*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, []Entry{{PortfolioID: "immediate", UserID: "user1", Premium: 200, Reference: 200, Placed: true}}, result.Entries)
+	assert.False(t, portfolios["immediate"].ExecutionStartTime.IsZero())
+
+	// The premium the move is measured from trails the premium down
+	quotes.prices["NIFTY18000PE"] = 90
+	result, err = monitor.Run(monday.Add(9*time.Hour + 21*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, result.Entries)
+
+	quotes.prices["NIFTY18000PE"] = 105
+	result, err = monitor.Run(monday.Add(9*time.Hour + 22*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, result.Entries)
+
+	quotes.prices["NIFTY18000PE"] = 110
+	result, err = monitor.Run(monday.Add(9*time.Hour + 23*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 1)
+	assert.Equal(t, Entry{PortfolioID: "straddle", UserID: "user1", Premium: 210, Reference: 190, Placed: true}, result.Entries[0])
+	assert.Len(t, portfolios["straddle"].ExecutionLogs, 1)
+	assert.Contains(t, portfolios["straddle"].ExecutionLogs[0], "Entered at combined premium 210.00, +10.53% from 190.00")
+
+	// Portfolios are placed once a day
+	result, err = monitor.Run(monday.Add(9*time.Hour + 24*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, result.Entries)
+	assert.ElementsMatch(t, []string{"immediate", "straddle"}, executor.placed)
+}
+
+func TestCombinedPremiumExit(t *testing.T) {
+	portfolios := stubPortfolios{
+		"credit": premiumPortfolio("credit", models.ExecutionModeTime, true, "NIFTY18000CE", "NIFTY18000PE"),
+		"debit":  premiumPortfolio("debit", models.ExecutionModeTime, false, "NIFTY18500CE", "NIFTY17500PE"),
+		"flat":   premiumPortfolio("flat", models.ExecutionModeTime, true, "BANKNIFTY44000CE", "BANKNIFTY44000PE"),
+	}
+	portfolios["credit"].TargetType = models.TargetTypeCombinedPremium
+	portfolios["credit"].TargetValue = 100
+	portfolios["credit"].StopLossType = models.StopLossTypeCombinedPremium
+	portfolios["credit"].StopLossValue = 300
+	portfolios["credit"].CombinedMonitoringType = models.MonitoringTypeMinuteClose
+	portfolios["debit"].StopLossType = models.StopLossTypeCombinedPremium
+	portfolios["debit"].StopLossValue = 50
+	portfolios["debit"].CombinedMonitoringType = models.MonitoringTypeInterval
+	portfolios["debit"].MonitoringInterval = 60
+	portfolios["flat"].StopLossType = models.StopLossTypeCombinedPremium
+	portfolios["flat"].StopLossValue = 500
+	positions := &stubPositions{positions: []models.Position{
+		{ID: "position1", PortfolioID: "credit", Status: models.PositionStatusOpen},
+		{ID: "position2", PortfolioID: "credit", Status: models.PositionStatusPartial},
+		{ID: "position3", PortfolioID: "debit", Status: models.PositionStatusOpen},
+		{ID: "position4", PortfolioID: "flat", Status: models.PositionStatusClosed},
+	}}
+	quotes := &stubQuotes{prices: map[string]float64{"NIFTY18000CE": 100, "NIFTY18000PE": 100, "NIFTY18500CE": 30, "NIFTY17500PE": 30}}
+	exiter := &recordingExiter{}
+	monitor := NewCombinedPremiumMonitor(portfolios, stubStrategies{}, &recordingExecutor{}, quotes, positions, exiter, nil, Config{Location: time.UTC})
+
+	now := time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+
+	// Within thresholds nothing is exited, and portfolios without open positions
+	// are not priced
+	result, err := monitor.Run(now)
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Empty(t, result.Exits)
+	assert.Equal(t, 4, quotes.lookups)
+
+	// The credit decays to its target, but is checked on the next minute only, and
+	// the debit is checked every minute
+	quotes.prices["NIFTY18000PE"] = 0
+	quotes.prices["NIFTY17500PE"] = 10
+	result, err = monitor.Run(now.Add(30 * time.Second))
+	require.NoError(t, err)
+	assert.Empty(t, result.Exits)
+
+	result, err = monitor.Run(now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, result.Exits, 2)
+	exits := map[string]Exit{}
+	for _, exit := range result.Exits {
+		exits[exit.PortfolioID] = exit
+	}
+	assert.Equal(t, Exit{PortfolioID: "credit", UserID: "user1", Reason: ExitReasonTarget, Premium: 100, Threshold: 100, OrderIDs: []string{"exit-position1", "exit-position2"}}, exits["credit"])
+	assert.Equal(t, Exit{PortfolioID: "debit", UserID: "user1", Reason: ExitReasonStopLoss, Premium: 40, Threshold: 50, OrderIDs: []string{"exit-position3"}}, exits["debit"])
+	assert.Contains(t, portfolios["credit"].ExecutionLogs[0], "Exited at combined premium 100.00, reaching its target of 100.00")
+	assert.False(t, portfolios["debit"].ExecutionEndTime.IsZero())
+
+	// Positions whose exit is pending are not exited again
+	result, err = monitor.Run(now.Add(3 * time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, result.Exits)
+	assert.Equal(t, []string{"position1", "position2", "position3"}, exiter.exited)
+}
+
+func TestExitThreshold(t *testing.T) {
+	portfolio := &models.Portfolio{
+		TargetType:    models.TargetTypeCombinedPremium,
+		TargetValue:   80,
+		StopLossType:  models.StopLossTypeCombinedPremium,
+		StopLossValue: 150,
+	}
+
+	// Net credits profit as the premium decays
+	reason, threshold, reached := exitThreshold(portfolio, 160, 160)
+	assert.True(t, reached)
+	assert.Equal(t, ExitReasonStopLoss, reason)
+	assert.Equal(t, 150.0, threshold)
+	_, _, reached = exitThreshold(portfolio, 100, 100)
+	assert.False(t, reached)
+	reason, _, _ = exitThreshold(portfolio, 75, 75)
+	assert.Equal(t, ExitReasonTarget, reason)
+
+	// Net debits profit as it rises
+	portfolio.TargetValue = 200
+	portfolio.StopLossValue = 50
+	reason, _, _ = exitThreshold(portfolio, -210, 210)
+	assert.Equal(t, ExitReasonTarget, reason)
+	reason, _, _ = exitThreshold(portfolio, -40, 40)
+	assert.Equal(t, ExitReasonStopLoss, reason)
+	_, _, reached = exitThreshold(portfolio, -100, 100)
+	assert.False(t, reached)
+}
@@ -0,0 +1,399 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/repositories"
+)
+
+// CheckName identifies an item of the promotion checklist
+type CheckName string
+
+const (
+	CheckPaperStrategy CheckName = "PAPER_STRATEGY"
+	CheckTrackRecord   CheckName = "TRACK_RECORD"
+	CheckRiskLimits    CheckName = "RISK_LIMITS"
+	CheckBrokerMapped  CheckName = "BROKER_MAPPED"
+)
+
+var (
+	// ErrStrategyNotFound is returned when the strategy does not exist
+	ErrStrategyNotFound = errors.New("strategy not found")
+
+	// ErrAccessDenied is returned when the strategy belongs to another user
+	ErrAccessDenied = errors.New("access denied")
+)
+
+// Requirements is the paper track record a strategy needs before going live
+type Requirements struct {
+	MinPaperDays   int     `json:"minPaperDays"`
+	MinPaperTrades int     `json:"minPaperTrades"`
+	MinWinRate     float64 `json:"minWinRate"` // Percent, not checked when zero
+}
+
+// DefaultRequirements are two weeks of paper trading with at least twenty trades
+var DefaultRequirements = Requirements{
+	MinPaperDays:   14,
+	MinPaperTrades: 20,
+}
+
+// Check is the outcome of one item of the promotion checklist
+type Check struct {
+	Name    CheckName `json:"name"`
+	Passed  bool      `json:"passed"`
+	Message string    `json:"message"`
+}
+
+// Result is the outcome of the promotion checklist of a paper strategy, with the
+// live strategy when it was promoted
+type Result struct {
+	PaperStrategyID  string                      `json:"paperStrategyId"`
+	BrokerName       string                      `json:"brokerName"`
+	Checks           []Check                     `json:"checks"`
+	Passed           bool                        `json:"passed"`
+	PaperPerformance *models.StrategyPerformance `json:"paperPerformance,omitempty"`
+	LiveStrategy     *models.Strategy            `json:"liveStrategy,omitempty"`
+}
+
+// Drift compares the results of a live strategy with the paper strategy it was
+// promoted from. Results are compared per trade, as the paper strategy has traded
+// for longer.
+type Drift struct {
+	PaperStrategyID  string                      `json:"paperStrategyId"`
+	LiveStrategyID   string                      `json:"liveStrategyId"`
+	Paper            *models.StrategyPerformance `json:"paper"`
+	Live             *models.StrategyPerformance `json:"live"`
+	PaperAveragePnL  float64                     `json:"paperAveragePnL"`
+	LiveAveragePnL   float64                     `json:"liveAveragePnL"`
+	AveragePnLDrift  float64                     `json:"averagePnLDrift"`  // Live minus paper
+	WinRateDrift     float64                     `json:"winRateDrift"`     // Live minus paper, in percentage points
+	MaxDrawdownDrift float64                     `json:"maxDrawdownDrift"` // Live minus paper
+	ComparedAt       time.Time                   `json:"comparedAt"`
+}
+
+// PerformanceProvider calculates the performance of strategies
+type PerformanceProvider interface {
+	GetStrategyPerformance(strategyID string) (*models.StrategyPerformance, error)
+}
+
+// BrokerAccountProvider retrieves the broker accounts users have connected
+type BrokerAccountProvider interface {
+	GetUserApiKeys(userID string) ([]models.UserApiKey, error)
+}
+
+// PromotionService defines the interface for promoting paper strategies to live
+type PromotionService interface {
+	// CheckPromotion runs the promotion checklist of a paper strategy without
+	// promoting it. An empty userID skips the ownership check, for admins.
+	CheckPromotion(strategyID, brokerName, userID string) (*Result, error)
+
+	// PromoteStrategy clones a paper strategy passing the checklist into a live
+	// strategy trading through brokerName, linked to the paper strategy. The live
+	// strategy starts as a draft and its schedule, if any, disabled.
+	PromoteStrategy(strategyID, brokerName, userID string) (*Result, error)
+
+	// GetDrift compares a live strategy with the paper strategy it was promoted
+	// from, given either of them
+	GetDrift(strategyID, userID string) (*Drift, error)
+}
+
+// PromotionServiceImpl implements the PromotionService interface
+type PromotionServiceImpl struct {
+	strategyRepo repositories.StrategyRepository
+	performance  PerformanceProvider
+	accounts     BrokerAccountProvider
+	requirements Requirements
+	mutex        sync.Mutex
+}
+
+// NewPromotionService creates a new PromotionService
+func NewPromotionService(
+	strategyRepo repositories.StrategyRepository,
+	performance PerformanceProvider,
+	accounts BrokerAccountProvider,
+	requirements Requirements,
+) PromotionService {
+	return &PromotionServiceImpl{
+		strategyRepo: strategyRepo,
+		performance:  performance,
+		accounts:     accounts,
+		requirements: requirements,
+	}
+}
+
+// CheckPromotion runs the promotion checklist of a paper strategy
+func (s *PromotionServiceImpl) CheckPromotion(strategyID, brokerName, userID string) (*Result, error) {
+	paper, err := s.getStrategy(strategyID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.check(paper, brokerName), nil
+}
+
+// PromoteStrategy promotes a paper strategy passing the checklist to live
+func (s *PromotionServiceImpl) PromoteStrategy(strategyID, brokerName, userID string) (*Result, error) {
+	// One promotion at a time, so a strategy cannot be promoted twice
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	paper, err := s.getStrategy(strategyID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.check(paper, brokerName)
+	if !result.Passed {
+		return result, nil
+	}
+
+	// Clone the paper strategy's configuration
+	now := time.Now()
+	live := *paper
+	live.ID = ""
+	live.Status = models.StrategyStatusDraft
+	live.Environment = models.EnvironmentLive
+	live.BrokerName = brokerName
+	live.PaperStrategyID = paper.ID
+	live.LiveStrategyID = ""
+	live.PromotedAt = now
+	live.CreatedAt = now
+	live.UpdatedAt = now
+	live.LastExecutedAt = time.Time{}
+	live.EntryConditions = append([]models.Condition(nil), paper.EntryConditions...)
+	live.ExitConditions = append([]models.Condition(nil), paper.ExitConditions...)
+	live.Instruments = append([]string(nil), paper.Instruments...)
+	live.Tags = append([]string(nil), paper.Tags...)
+
+	createdLive, err := s.strategyRepo.Create(&live)
+	if err != nil {
+		return nil, err
+	}
+	result.LiveStrategy = createdLive
+
+	// Clone the schedule disabled, the live strategy only trading once started
+	if schedule, err := s.strategyRepo.GetSchedule(paper.ID); err == nil && schedule != nil {
+		liveSchedule := *schedule
+		liveSchedule.StrategyID = createdLive.ID
+		liveSchedule.DaysOfWeek = append([]int(nil), schedule.DaysOfWeek...)
+		liveSchedule.Enabled = false
+		liveSchedule.CreatedAt = now
+		liveSchedule.UpdatedAt = now
+		if err := s.strategyRepo.SaveSchedule(&liveSchedule); err != nil {
+			log.Printf("Error cloning schedule of strategy %s to %s: %v", paper.ID, createdLive.ID, err)
+		}
+	}
+
+	// Link the paper strategy to the live one. The live strategy already links
+	// back, so a failure here is logged rather than undoing the promotion.
+	paper.LiveStrategyID = createdLive.ID
+	paper.PromotedAt = now
+	paper.UpdatedAt = now
+	if _, err := s.strategyRepo.Update(paper); err != nil {
+		log.Printf("Error linking strategy %s to live strategy %s: %v", paper.ID, createdLive.ID, err)
+	}
+
+	return result, nil
+}
+
+// GetDrift compares a live strategy with the paper strategy it was promoted from
+func (s *PromotionServiceImpl) GetDrift(strategyID, userID string) (*Drift, error) {
+	strategy, err := s.getStrategy(strategyID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	paperID, liveID := strategy.PaperStrategyID, strategy.ID
+	if !strategy.IsLive() {
+		if strategy.LiveStrategyID == "" {
+			return nil, errors.New("strategy has not been promoted to live")
+		}
+		paperID, liveID = strategy.ID, strategy.LiveStrategyID
+	} else if paperID == "" {
+		return nil, errors.New("strategy was not promoted from a paper strategy")
+	}
+
+	paper, err := s.performance.GetStrategyPerformance(paperID)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating paper performance: %v", err)
+	}
+	live, err := s.performance.GetStrategyPerformance(liveID)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating live performance: %v", err)
+	}
+
+	drift := &Drift{
+		PaperStrategyID:  paperID,
+		LiveStrategyID:   liveID,
+		Paper:            paper,
+		Live:             live,
+		PaperAveragePnL:  averagePnL(paper),
+		LiveAveragePnL:   averagePnL(live),
+		WinRateDrift:     live.WinRate - paper.WinRate,
+		MaxDrawdownDrift: live.MaxDrawdown - paper.MaxDrawdown,
+		ComparedAt:       time.Now(),
+	}
+	drift.AveragePnLDrift = drift.LiveAveragePnL - drift.PaperAveragePnL
+
+	return drift, nil
+}
+
+// check runs the promotion checklist of a strategy
+func (s *PromotionServiceImpl) check(paper *models.Strategy, brokerName string) *Result {
+	result := &Result{
+		PaperStrategyID: paper.ID,
+		BrokerName:      brokerName,
+	}
+
+	result.Checks = append(result.Checks, checkPaperStrategy(paper))
+
+	performance, err := s.performance.GetStrategyPerformance(paper.ID)
+	if err != nil {
+		result.Checks = append(result.Checks, Check{
+			Name:    CheckTrackRecord,
+			Message: fmt.Sprintf("error calculating paper performance: %v", err),
+		})
+	} else {
+		result.PaperPerformance = performance
+		result.Checks = append(result.Checks, s.checkTrackRecord(performance))
+	}
+
+	result.Checks = append(result.Checks, checkRiskLimits(paper.RiskParameters))
+	result.Checks = append(result.Checks, s.checkBrokerMapped(paper.UserID, brokerName))
+
+	result.Passed = true
+	for _, check := range result.Checks {
+		if !check.Passed {
+			result.Passed = false
+		}
+	}
+
+	return result
+}
+
+// checkPaperStrategy checks the strategy is paper trading and not yet promoted
+func checkPaperStrategy(strategy *models.Strategy) Check {
+	check := Check{Name: CheckPaperStrategy}
+
+	switch {
+	case strategy.IsLive():
+		check.Message = "strategy is already live"
+	case strategy.LiveStrategyID != "":
+		check.Message = fmt.Sprintf("strategy was already promoted to live strategy %s", strategy.LiveStrategyID)
+	default:
+		check.Passed = true
+		check.Message = "strategy is paper trading"
+	}
+
+	return check
+}
+
+// checkTrackRecord checks the strategy has paper traded long and often enough
+func (s *PromotionServiceImpl) checkTrackRecord(performance *models.StrategyPerformance) Check {
+	check := Check{Name: CheckTrackRecord}
+
+	days := int(performance.EndDate.Sub(performance.StartDate).Hours() / 24)
+	switch {
+	case days < s.requirements.MinPaperDays:
+		check.Message = fmt.Sprintf("paper traded for %d days, %d required", days, s.requirements.MinPaperDays)
+	case performance.TotalTrades < s.requirements.MinPaperTrades:
+		check.Message = fmt.Sprintf("%d paper trades, %d required", performance.TotalTrades, s.requirements.MinPaperTrades)
+	case s.requirements.MinWinRate > 0 && performance.WinRate < s.requirements.MinWinRate:
+		check.Message = fmt.Sprintf("paper win rate %.2f%%, %.2f%% required", performance.WinRate, s.requirements.MinWinRate)
+	default:
+		check.Passed = true
+		check.Message = fmt.Sprintf("%d paper trades over %d days", performance.TotalTrades, days)
+	}
+
+	return check
+}
+
+// checkRiskLimits checks the strategy's position size and loss limits are set
+func checkRiskLimits(risk models.RiskParameters) Check {
+	check := Check{Name: CheckRiskLimits}
+
+	var missing []string
+	if risk.MaxPositionSize <= 0 {
+		missing = append(missing, "max position size")
+	}
+	if risk.MaxLoss <= 0 {
+		missing = append(missing, "max loss")
+	}
+	if risk.MaxDailyLoss <= 0 {
+		missing = append(missing, "max daily loss")
+	}
+
+	if len(missing) > 0 {
+		check.Message = "risk limits not set: " + strings.Join(missing, ", ")
+		return check
+	}
+
+	check.Passed = true
+	check.Message = "risk limits are set"
+	return check
+}
+
+// checkBrokerMapped checks the user has an active account with the broker
+func (s *PromotionServiceImpl) checkBrokerMapped(userID, brokerName string) Check {
+	check := Check{Name: CheckBrokerMapped}
+
+	if brokerName == "" {
+		check.Message = "no broker given"
+		return check
+	}
+
+	keys, err := s.accounts.GetUserApiKeys(userID)
+	if err != nil {
+		check.Message = fmt.Sprintf("error retrieving broker accounts: %v", err)
+		return check
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		if !strings.EqualFold(key.Broker, brokerName) || !key.IsActive {
+			continue
+		}
+		if !key.ExpiresAt.IsZero() && key.ExpiresAt.Before(now) {
+			continue
+		}
+
+		check.Passed = true
+		check.Message = fmt.Sprintf("broker account %s is active", key.Name)
+		return check
+	}
+
+	check.Message = fmt.Sprintf("no active %s account", brokerName)
+	return check
+}
+
+// getStrategy retrieves a strategy, checking it belongs to userID unless empty
+func (s *PromotionServiceImpl) getStrategy(strategyID, userID string) (*models.Strategy, error) {
+	if strategyID == "" {
+		return nil, errors.New("strategy ID cannot be empty")
+	}
+
+	strategy, err := s.strategyRepo.GetByID(strategyID)
+	if err != nil || strategy == nil {
+		return nil, ErrStrategyNotFound
+	}
+
+	if userID != "" && strategy.UserID != userID {
+		return nil, ErrAccessDenied
+	}
+
+	return strategy, nil
+}
+
+// averagePnL returns the P&L per trade of a strategy
+func averagePnL(performance *models.StrategyPerformance) float64 {
+	if performance.TotalTrades == 0 {
+		return 0
+	}
+	return performance.TotalPnL / float64(performance.TotalTrades)
+}
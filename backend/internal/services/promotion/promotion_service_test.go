@@ -0,0 +1,266 @@
+package promotion
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockStrategyRepository is a mock implementation of the StrategyRepository interface
+type MockStrategyRepository struct {
+	mock.Mock
+}
+
+func (m *MockStrategyRepository) Create(strategy *models.Strategy) (*models.Strategy, error) {
+	args := m.Called(strategy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Strategy), args.Error(1)
+}
+
+func (m *MockStrategyRepository) GetByID(id string) (*models.Strategy, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Strategy), args.Error(1)
+}
+
+func (m *MockStrategyRepository) GetByUser(userID string) ([]models.Strategy, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.Strategy), args.Error(1)
+}
+
+func (m *MockStrategyRepository) GetByTag(tag string) ([]models.Strategy, error) {
+	args := m.Called(tag)
+	return args.Get(0).([]models.Strategy), args.Error(1)
+}
+
+func (m *MockStrategyRepository) GetScheduled() ([]models.Strategy, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Strategy), args.Error(1)
+}
+
+func (m *MockStrategyRepository) Update(strategy *models.Strategy) (*models.Strategy, error) {
+	args := m.Called(strategy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Strategy), args.Error(1)
+}
+
+func (m *MockStrategyRepository) Delete(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockStrategyRepository) SaveSchedule(schedule *models.StrategySchedule) error {
+	args := m.Called(schedule)
+	return args.Error(0)
+}
+
+func (m *MockStrategyRepository) GetSchedule(strategyID string) (*models.StrategySchedule, error) {
+	args := m.Called(strategyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StrategySchedule), args.Error(1)
+}
+
+func (m *MockStrategyRepository) DeleteSchedule(strategyID string) error {
+	args := m.Called(strategyID)
+	return args.Error(0)
+}
+
+// MockPerformanceProvider is a mock implementation of the PerformanceProvider interface
+type MockPerformanceProvider struct {
+	mock.Mock
+}
+
+func (m *MockPerformanceProvider) GetStrategyPerformance(strategyID string) (*models.StrategyPerformance, error) {
+	args := m.Called(strategyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StrategyPerformance), args.Error(1)
+}
+
+// MockBrokerAccountProvider is a mock implementation of the BrokerAccountProvider interface
+type MockBrokerAccountProvider struct {
+	mock.Mock
+}
+
+func (m *MockBrokerAccountProvider) GetUserApiKeys(userID string) ([]models.UserApiKey, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.UserApiKey), args.Error(1)
+}
+
+// paperStrategy returns a paper strategy ready for promotion
+func paperStrategy() *models.Strategy {
+	return &models.Strategy{
+		ID:          "strategy123",
+		Name:        "Short Straddle",
+		UserID:      "user123",
+		Type:        models.StrategyTypeAutomated,
+		Status:      models.StrategyStatusActive,
+		Instruments: []string{"NIFTY"},
+		Tags:        []string{"straddle"},
+		RiskParameters: models.RiskParameters{
+			MaxPositionSize: 100,
+			MaxLoss:         10000,
+			MaxDailyLoss:    5000,
+		},
+		CreatedAt: time.Now().AddDate(0, 0, -30),
+	}
+}
+
+// paperPerformance returns a paper track record of 40 trades over 30 days
+func paperPerformance() *models.StrategyPerformance {
+	return &models.StrategyPerformance{
+		StrategyID:  "strategy123",
+		TotalPnL:    20000,
+		TotalTrades: 40,
+		WinRate:     60,
+		MaxDrawdown: -3000,
+		StartDate:   time.Now().AddDate(0, 0, -30),
+		EndDate:     time.Now(),
+	}
+}
+
+func TestCheckPromotion(t *testing.T) {
+	mockRepo := new(MockStrategyRepository)
+	mockPerformance := new(MockPerformanceProvider)
+	mockAccounts := new(MockBrokerAccountProvider)
+	service := NewPromotionService(mockRepo, mockPerformance, mockAccounts, DefaultRequirements)
+
+	// A young strategy without loss limits or a broker account fails the checklist
+	strategy := paperStrategy()
+	strategy.RiskParameters.MaxDailyLoss = 0
+	performance := paperPerformance()
+	performance.StartDate = time.Now().AddDate(0, 0, -5)
+	mockRepo.On("GetByID", "strategy123").Return(strategy, nil)
+	mockPerformance.On("GetStrategyPerformance", "strategy123").Return(performance, nil)
+	mockAccounts.On("GetUserApiKeys", "user123").Return([]models.UserApiKey{
+		{Name: "Zerodha", Broker: "ZERODHA", IsActive: true},
+		{Name: "Old XTS", Broker: "XTS", IsActive: true, ExpiresAt: time.Now().Add(-time.Hour)},
+	}, nil)
+
+	result, err := service.CheckPromotion("strategy123", "XTS", "user123")
+	assert.NoError(t, err)
+	assert.False(t, result.Passed)
+	assert.Len(t, result.Checks, 4)
+	passed := make(map[CheckName]bool)
+	for _, check := range result.Checks {
+		passed[check.Name] = check.Passed
+	}
+	assert.Equal(t, map[CheckName]bool{
+		CheckPaperStrategy: true,
+		CheckTrackRecord:   false,
+		CheckRiskLimits:    false,
+		CheckBrokerMapped:  false,
+	}, passed)
+
+	// Broker names are matched regardless of case
+	result, err = service.CheckPromotion("strategy123", "zerodha", "user123")
+	assert.NoError(t, err)
+	assert.True(t, result.Checks[3].Passed)
+
+	// Test another user's strategy
+	_, err = service.CheckPromotion("strategy123", "ZERODHA", "user456")
+	assert.Equal(t, ErrAccessDenied, err)
+
+	// Test a missing strategy
+	mockRepo.On("GetByID", "missing").Return(nil, errors.New("strategy not found"))
+	_, err = service.CheckPromotion("missing", "ZERODHA", "")
+	assert.Equal(t, ErrStrategyNotFound, err)
+
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestPromoteStrategy(t *testing.T) {
+	mockRepo := new(MockStrategyRepository)
+	mockPerformance := new(MockPerformanceProvider)
+	mockAccounts := new(MockBrokerAccountProvider)
+	service := NewPromotionService(mockRepo, mockPerformance, mockAccounts, DefaultRequirements)
+
+	paper := paperStrategy()
+	mockRepo.On("GetByID", "strategy123").Return(paper, nil)
+	mockPerformance.On("GetStrategyPerformance", "strategy123").Return(paperPerformance(), nil)
+	mockAccounts.On("GetUserApiKeys", "user123").Return([]models.UserApiKey{{Name: "Zerodha", Broker: "ZERODHA", IsActive: true}}, nil)
+	mockRepo.On("GetSchedule", "strategy123").Return(&models.StrategySchedule{
+		StrategyID: "strategy123",
+		Frequency:  models.ScheduleFrequencyWeekly,
+		DaysOfWeek: []int{1, 3, 5},
+		Enabled:    true,
+	}, nil)
+
+	// The live strategy is a draft clone linked to the paper strategy
+	mockRepo.On("Create", mock.MatchedBy(func(strategy *models.Strategy) bool {
+		return strategy.ID == "" && strategy.IsLive() && strategy.BrokerName == "ZERODHA" &&
+			strategy.PaperStrategyID == "strategy123" && strategy.Status == models.StrategyStatusDraft &&
+			strategy.Name == "Short Straddle" && strategy.RiskParameters.MaxDailyLoss == 5000
+	})).Return(&models.Strategy{ID: "live123", Environment: models.EnvironmentLive, PaperStrategyID: "strategy123"}, nil)
+	mockRepo.On("SaveSchedule", mock.MatchedBy(func(schedule *models.StrategySchedule) bool {
+		return schedule.StrategyID == "live123" && !schedule.Enabled && len(schedule.DaysOfWeek) == 3
+	})).Return(nil)
+	mockRepo.On("Update", mock.MatchedBy(func(strategy *models.Strategy) bool {
+		return strategy.ID == "strategy123" && strategy.LiveStrategyID == "live123"
+	})).Return(paper, nil)
+
+	result, err := service.PromoteStrategy("strategy123", "ZERODHA", "user123")
+	assert.NoError(t, err)
+	assert.True(t, result.Passed)
+	assert.Equal(t, "live123", result.LiveStrategy.ID)
+	assert.Equal(t, "live123", paper.LiveStrategyID)
+	assert.False(t, paper.IsLive())
+	mockRepo.AssertExpectations(t)
+
+	// A promoted strategy cannot be promoted again
+	result, err = service.PromoteStrategy("strategy123", "ZERODHA", "user123")
+	assert.NoError(t, err)
+	assert.False(t, result.Passed)
+	assert.False(t, result.Checks[0].Passed)
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestGetDrift(t *testing.T) {
+	mockRepo := new(MockStrategyRepository)
+	mockPerformance := new(MockPerformanceProvider)
+	service := NewPromotionService(mockRepo, mockPerformance, new(MockBrokerAccountProvider), DefaultRequirements)
+
+	paper := paperStrategy()
+	paper.LiveStrategyID = "live123"
+	mockRepo.On("GetByID", "strategy123").Return(paper, nil)
+	mockRepo.On("GetByID", "live123").Return(&models.Strategy{ID: "live123", UserID: "user123", Environment: models.EnvironmentLive, PaperStrategyID: "strategy123"}, nil)
+	mockPerformance.On("GetStrategyPerformance", "strategy123").Return(paperPerformance(), nil)
+	mockPerformance.On("GetStrategyPerformance", "live123").Return(&models.StrategyPerformance{
+		StrategyID:  "live123",
+		TotalPnL:    3000,
+		TotalTrades: 10,
+		WinRate:     50,
+		MaxDrawdown: -4000,
+	}, nil)
+
+	// The drift is the same from either strategy
+	for _, strategyID := range []string{"strategy123", "live123"} {
+		drift, err := service.GetDrift(strategyID, "user123")
+		assert.NoError(t, err)
+		assert.Equal(t, "strategy123", drift.PaperStrategyID)
+		assert.Equal(t, "live123", drift.LiveStrategyID)
+		assert.Equal(t, 500.0, drift.PaperAveragePnL)
+		assert.Equal(t, 300.0, drift.LiveAveragePnL)
+		assert.Equal(t, -200.0, drift.AveragePnLDrift)
+		assert.Equal(t, -10.0, drift.WinRateDrift)
+		assert.Equal(t, -1000.0, drift.MaxDrawdownDrift)
+	}
+
+	// Test a strategy that was never promoted
+	mockRepo.On("GetByID", "strategy456").Return(&models.Strategy{ID: "strategy456", UserID: "user123"}, nil)
+	_, err := service.GetDrift("strategy456", "user123")
+	assert.Error(t, err)
+}
@@ -0,0 +1,279 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A4 page layout of reports, in points
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	pageMargin   = 40.0
+	headerHeight = 36.0
+	footerHeight = 24.0
+	contentWidth = pageWidth - 2*pageMargin
+	chartHeight  = 140.0
+)
+
+// rgb is a color with components from 0 to 1
+type rgb [3]float64
+
+// parseColor parses a #RRGGBB color, returning fallback when it is not one
+func parseColor(hex string, fallback rgb) rgb {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return fallback
+	}
+
+	var color rgb
+	for i := range color {
+		component, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return fallback
+		}
+		color[i] = float64(component) / 255
+	}
+	return color
+}
+
+// column is a column of a table, its offset from the left margin
+type column struct {
+	title  string
+	offset float64
+}
+
+// document lays out a branded report top to bottom over as many pages as needed:
+// each page has a band in the brand's color with the brand's name and the report's
+// title, and a footer with the brand's footer and the page number. It uses the
+// standard Helvetica fonts so no fonts have to be embedded.
+type document struct {
+	brand Branding
+	color rgb
+	title string
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// newDocument creates a new document with one empty page
+func newDocument(brand Branding, title string) *document {
+	d := &document{
+		brand: brand,
+		color: parseColor(brand.Color, rgb{0.12, 0.31, 0.47}),
+		title: title,
+	}
+	d.newPage()
+	return d
+}
+
+// page returns the content of the current page
+func (d *document) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// newPage starts a new page below its header band
+func (d *document) newPage() {
+	page := &bytes.Buffer{}
+	d.pages = append(d.pages, page)
+
+	fmt.Fprintf(page, "%.3f %.3f %.3f rg 0 %.2f %.2f %.2f re f\n", d.color[0], d.color[1], d.color[2], pageHeight-headerHeight, pageWidth, headerHeight)
+	fmt.Fprintf(page, "1 g BT /F2 14 Tf %.2f %.2f Td (%s) Tj ET\n", pageMargin, pageHeight-headerHeight+13, pdfEscape(d.brand.Name))
+	fmt.Fprintf(page, "BT /F1 10 Tf %.2f %.2f Td (%s) Tj ET 0 g\n", pageWidth-pageMargin-textWidth(d.title, 10), pageHeight-headerHeight+14, pdfEscape(d.title))
+
+	d.y = pageHeight - headerHeight - 20
+}
+
+// reserve starts a new page unless height points fit on the current one,
+// reporting whether it did
+func (d *document) reserve(height float64) bool {
+	if d.y-height < pageMargin+footerHeight {
+		d.newPage()
+		return true
+	}
+	return false
+}
+
+// space adds vertical space
+func (d *document) space(height float64) {
+	d.y -= height
+}
+
+// heading writes a section heading in the brand's color
+func (d *document) heading(text string) {
+	d.reserve(40)
+	d.y -= 20
+	fmt.Fprintf(d.page(), "%.3f %.3f %.3f rg BT /F2 13 Tf %.2f %.2f Td (%s) Tj ET 0 g\n", d.color[0], d.color[1], d.color[2], pageMargin, d.y, pdfEscape(text))
+	d.y -= 6
+}
+
+// line writes a line of text
+func (d *document) line(text string, size float64) {
+	d.row([]string{text}, []float64{0}, size, false)
+}
+
+// row writes a row of text with each cell at its offset, in bold when bold is set
+func (d *document) row(cells []string, offsets []float64, size float64, bold bool) {
+	lineHeight := size * 1.5
+	d.reserve(lineHeight)
+	d.y -= lineHeight
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	for i, cell := range cells {
+		fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, pageMargin+offsets[i], d.y, pdfEscape(cell))
+	}
+}
+
+// keyValues writes pairs of labels and values as two columns
+func (d *document) keyValues(pairs [][2]string) {
+	for _, pair := range pairs {
+		d.row([]string{pair[0], pair[1]}, []float64{0, 200}, 10, false)
+	}
+	d.space(6)
+}
+
+// table writes rows under a shaded header row, repeating the header on every page
+// the table runs onto
+func (d *document) table(columns []column, rows [][]string) {
+	offsets := make([]float64, len(columns))
+	titles := make([]string, len(columns))
+	for i, column := range columns {
+		offsets[i] = column.offset
+		titles[i] = column.title
+	}
+
+	header := func() {
+		d.reserve(30)
+		fmt.Fprintf(d.page(), "0.93 g %.2f %.2f %.2f 14 re f 0 g\n", pageMargin-4, d.y-17, contentWidth+8)
+		d.row(titles, offsets, 9, true)
+		d.space(2)
+	}
+
+	header()
+	if len(rows) == 0 {
+		d.line("None", 9)
+	}
+	for _, cells := range rows {
+		if d.reserve(9 * 1.5) {
+			header()
+		}
+		d.row(cells, offsets, 9, false)
+	}
+	d.space(8)
+}
+
+// chart draws values as a line chart in the brand's color inside a bordered box,
+// with the highest and lowest values labelled
+func (d *document) chart(values []float64) {
+	d.reserve(chartHeight + 16)
+	top := d.y - 6
+	bottom := top - chartHeight
+
+	page := d.page()
+	fmt.Fprintf(page, "0.8 G 0.5 w %.2f %.2f %.2f %.2f re S\n", pageMargin, bottom, contentWidth, chartHeight)
+
+	if len(values) > 1 {
+		low, high := values[0], values[0]
+		for _, value := range values {
+			low = math.Min(low, value)
+			high = math.Max(high, value)
+		}
+		span := high - low
+		if span == 0 {
+			span = 1
+		}
+		step := contentWidth / float64(len(values)-1)
+
+		fmt.Fprintf(page, "%.3f %.3f %.3f RG 1.2 w\n", d.color[0], d.color[1], d.color[2])
+		for i, value := range values {
+			operator := "l"
+			if i == 0 {
+				operator = "m"
+			}
+			fmt.Fprintf(page, "%.2f %.2f %s\n", pageMargin+float64(i)*step, bottom+(value-low)/span*chartHeight, operator)
+		}
+		page.WriteString("S 0 G\n")
+
+		fmt.Fprintf(page, "0.4 g BT /F1 7 Tf %.2f %.2f Td (%s) Tj ET\n", pageMargin+3, top-9, formatAmount(high))
+		fmt.Fprintf(page, "BT /F1 7 Tf %.2f %.2f Td (%s) Tj ET 0 g\n", pageMargin+3, bottom+3, formatAmount(low))
+	} else {
+		fmt.Fprintf(page, "0.4 g BT /F1 9 Tf %.2f %.2f Td (Not enough data to chart) Tj ET 0 g\n", pageMargin+contentWidth/2-60, bottom+chartHeight/2)
+	}
+
+	d.y = bottom - 10
+}
+
+// bytes assembles the PDF file, adding the footer of every page now that the
+// number of pages is known
+func (d *document) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page then takes a page
+	// and a content object
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range d.pages {
+		content := page.String()
+		content += fmt.Sprintf("0.8 G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", pageMargin, pageMargin+12, pageWidth-pageMargin, pageMargin+12)
+		content += fmt.Sprintf("0.4 g BT /F1 8 Tf %.2f %.2f Td (%s) Tj ET\n", pageMargin, pageMargin, pdfEscape(d.brand.Footer))
+		number := fmt.Sprintf("Page %d of %d", i+1, len(d.pages))
+		content += fmt.Sprintf("BT /F1 8 Tf %.2f %.2f Td (%s) Tj ET 0 g\n", pageWidth-pageMargin-textWidth(number, 8), pageMargin, number)
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 6+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// textWidth estimates the width of text in Helvetica at size, taking every
+// character as an average width, which is close enough to right-align short labels
+func textWidth(text string, size float64) float64 {
+	return float64(len(text)) * size * 0.5
+}
+
+// pdfEscape escapes text for a PDF string literal, replacing characters the
+// standard fonts cannot show
+func pdfEscape(text string) string {
+	var builder strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			builder.WriteByte('\\')
+			builder.WriteRune(r)
+		case r < 32 || r > 126:
+			builder.WriteByte('?')
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
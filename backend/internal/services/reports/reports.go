@@ -0,0 +1,804 @@
+// Package reports renders users' trade statements, portfolio summaries and
+// strategy performance into branded PDF reports, with equity curves and tables,
+// keeps them in object storage and hands out signed URLs to download them by.
+package reports
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/summary"
+)
+
+const (
+	// DefaultURLExpiry is how long download URLs are valid for
+	DefaultURLExpiry = 15 * time.Minute
+
+	// DefaultBrandName is the name in the header of reports when none is configured
+	DefaultBrandName = "MarvelQuant"
+
+	// DefaultBrandColor is the color of reports' header, headings and charts when
+	// none is configured
+	DefaultBrandColor = "#1f4e79"
+
+	// pageSize is the number of orders and positions read at a time
+	pageSize = 100
+
+	// maxReports is the number of reports listed for each user, the oldest being
+	// dropped from the list, not from storage
+	maxReports = 100
+
+	// dateFormat is the format of report periods
+	dateFormat = "2006-01-02"
+
+	// timeFormat is the format of times in reports
+	timeFormat = "2006-01-02 15:04"
+
+	// contentTypePDF is the content type of PDF reports
+	contentTypePDF = "application/pdf"
+
+	// contentTypeCSV is the content type of CSV reports
+	contentTypeCSV = "text/csv; charset=utf-8"
+)
+
+var (
+	// ErrInvalidDate is returned for period dates other than YYYY-MM-DD
+	ErrInvalidDate = errors.New("dates must be YYYY-MM-DD")
+	// ErrInvalidPeriod is returned for periods ending before they start
+	ErrInvalidPeriod = errors.New("from date cannot be after to date")
+	// ErrPortfolioNotFound is returned for portfolios that do not exist
+	ErrPortfolioNotFound = errors.New("portfolio not found")
+	// ErrStrategyNotFound is returned for strategies that do not exist
+	ErrStrategyNotFound = errors.New("strategy not found")
+	// ErrAccessDenied is returned for portfolios and strategies of another user
+	ErrAccessDenied = errors.New("access denied")
+	// ErrReportNotFound is returned for reports that are not in storage
+	ErrReportNotFound = errors.New("report not found")
+	// ErrInvalidSignature is returned for download URLs that were not signed by
+	// the service or were tampered with
+	ErrInvalidSignature = errors.New("invalid download signature")
+	// ErrLinkExpired is returned for download URLs past their expiry
+	ErrLinkExpired = errors.New("download link has expired")
+)
+
+// Kind is what a report is of
+type Kind string
+
+const (
+	KindTradeStatement      Kind = "TRADE_STATEMENT"
+	KindPortfolioSummary    Kind = "PORTFOLIO_SUMMARY"
+	KindStrategyPerformance Kind = "STRATEGY_PERFORMANCE"
+	KindTaxReport           Kind = "TAX_REPORT"
+)
+
+// OrderProvider finds orders, as the order service does
+type OrderProvider interface {
+	GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error)
+}
+
+// PositionProvider finds positions, as the position service does
+type PositionProvider interface {
+	GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error)
+}
+
+// PortfolioProvider looks up portfolios
+type PortfolioProvider interface {
+	GetByID(id string) (*models.Portfolio, error)
+}
+
+// StrategyProvider looks up strategies
+type StrategyProvider interface {
+	GetByID(id string) (*models.Strategy, error)
+}
+
+// UserProvider looks up the users reports are addressed to
+type UserProvider interface {
+	GetByID(id string) (*models.User, error)
+}
+
+// Branding is how reports are branded
+type Branding struct {
+	Name   string `json:"name"`   // In the header band of every page, defaults to DefaultBrandName
+	Color  string `json:"color"`  // #RRGGBB of the header band, headings and charts, defaults to DefaultBrandColor
+	Footer string `json:"footer"` // At the foot of every page, as a disclaimer
+}
+
+// Config configures the report service
+type Config struct {
+	Location  *time.Location       // Time zone of report periods and times, defaults to the local time zone
+	Branding  Branding             // Of every report
+	BaseURL   string               // Of the API the download URLs the service signs point to
+	Secret    string               // Signs download URLs, random when empty so that URLs do not outlive the process
+	URLExpiry time.Duration        // Of download URLs, defaults to DefaultURLExpiry
+	Fees      *summary.FeeSchedule // Estimating the fees of trades, defaults to those of daily summaries
+}
+
+// Report is a rendered report kept in storage
+type Report struct {
+	Key         string    `json:"key"` // In the object store
+	Kind        Kind      `json:"kind"`
+	UserID      string    `json:"userId"`
+	SubjectID   string    `json:"subjectId,omitempty"` // Portfolio or strategy reported on, or financial year of tax reports
+	Title       string    `json:"title"`
+	FileName    string    `json:"fileName"`
+	Size        int       `json:"size"`
+	From        string    `json:"from,omitempty"` // YYYY-MM-DD
+	To          string    `json:"to,omitempty"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	URL         string    `json:"url"` // Signed download URL
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// Service renders reports into PDFs kept in an object store
+type Service struct {
+	orders     OrderProvider
+	positions  PositionProvider
+	portfolios PortfolioProvider
+	strategies StrategyProvider
+	users      UserProvider
+	store      ObjectStore
+	config     Config
+	fees       summary.FeeSchedule
+	secret     []byte
+	reports    map[string][]Report // By user, newest first
+	mutex      sync.Mutex
+}
+
+// NewService creates a new Service. Without a user provider reports are addressed
+// to user IDs.
+func NewService(
+	orders OrderProvider,
+	positions PositionProvider,
+	portfolios PortfolioProvider,
+	strategies StrategyProvider,
+	users UserProvider,
+	store ObjectStore,
+	config Config,
+) *Service {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.Branding.Name == "" {
+		config.Branding.Name = DefaultBrandName
+	}
+	if config.Branding.Color == "" {
+		config.Branding.Color = DefaultBrandColor
+	}
+	if config.URLExpiry <= 0 {
+		config.URLExpiry = DefaultURLExpiry
+	}
+	fees := summary.FeeSchedule{PerOrder: summary.DefaultFeePerOrder, Rate: summary.DefaultFeeRate}
+	if config.Fees != nil {
+		fees = *config.Fees
+	}
+
+	secret := []byte(config.Secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+
+	return &Service{
+		orders:     orders,
+		positions:  positions,
+		portfolios: portfolios,
+		strategies: strategies,
+		users:      users,
+		store:      store,
+		config:     config,
+		fees:       fees,
+		secret:     secret,
+		reports:    make(map[string][]Report),
+	}
+}
+
+// TradeStatement renders a user's statement of the orders filled and positions
+// closed from one date to another, YYYY-MM-DD, both included. The period defaults
+// to the month so far.
+func (s *Service) TradeStatement(userID, from, to string) (*Report, error) {
+	start, end, err := s.period(from, to, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.findOrders(models.OrderFilter{UserID: userID, FromDate: start, ToDate: end})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+	closed, err := s.findPositions(models.PositionFilter{UserID: userID, Status: models.PositionStatusClosed, ClosedFrom: start, ClosedBefore: end})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	var bought, sold, fees float64
+	var trades [][]string
+	for _, order := range orders {
+		if order.FilledQuantity <= 0 {
+			continue
+		}
+		price := order.AveragePrice
+		if price == 0 {
+			price = order.Price
+		}
+		value := float64(order.FilledQuantity) * price
+		fee := s.fees.Fee(value)
+		if order.Direction == models.OrderDirectionSell {
+			sold += value
+		} else {
+			bought += value
+		}
+		fees += fee
+		executed := order.ExecutionTime
+		if executed.IsZero() {
+			executed = order.UpdatedAt
+		}
+		trades = append(trades, []string{
+			s.formatTime(executed),
+			order.Symbol,
+			string(order.Direction),
+			strconv.Itoa(order.FilledQuantity),
+			formatAmount(price),
+			formatAmount(value),
+			formatAmount(fee),
+		})
+	}
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i][0] < trades[j][0] })
+	results := measure(closed)
+
+	title := "Trade Statement"
+	doc := newDocument(s.config.Branding, title)
+	doc.row([]string{s.displayName(userID)}, []float64{0}, 16, true)
+	doc.line(fmt.Sprintf("Statement of %s to %s", start.Format(dateFormat), end.AddDate(0, 0, -1).Format(dateFormat)), 10)
+
+	doc.heading("Summary")
+	doc.keyValues([][2]string{
+		{"Trades", strconv.Itoa(len(trades))},
+		{"Bought", formatAmount(bought)},
+		{"Sold", formatAmount(sold)},
+		{"Fees (estimated)", formatAmount(fees)},
+		{"Positions closed", strconv.Itoa(results.trades)},
+		{"Realized P&L", formatAmount(results.netPnL)},
+		{"Net P&L after fees", formatAmount(results.netPnL - fees)},
+	})
+
+	doc.heading("Realized P&L")
+	doc.chart(results.equity)
+
+	doc.heading("Trades")
+	doc.table(tradeColumns, trades)
+
+	doc.heading("Closed Positions")
+	doc.table(closedColumns, s.closedRows(results.positions))
+
+	return s.publish(doc, Report{
+		Kind:   KindTradeStatement,
+		UserID: userID,
+		Title:  title,
+		From:   start.Format(dateFormat),
+		To:     end.AddDate(0, 0, -1).Format(dateFormat),
+	})
+}
+
+// PortfolioSummary renders the summary of a portfolio of the user: its legs, its
+// open and closed positions and their P&L. Admins, with an empty user ID, may
+// report on anyone's.
+func (s *Service) PortfolioSummary(portfolioID, userID string) (*Report, error) {
+	portfolio, err := s.portfolios.GetByID(portfolioID)
+	if err != nil || portfolio == nil {
+		return nil, ErrPortfolioNotFound
+	}
+	if userID != "" && portfolio.UserID != userID {
+		return nil, ErrAccessDenied
+	}
+
+	var open, closed []models.Position
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial, models.PositionStatusClosed} {
+		positions, err := s.findPositions(models.PositionFilter{PortfolioID: portfolio.ID, Status: status})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get positions: %w", err)
+		}
+		if status == models.PositionStatusClosed {
+			closed = append(closed, positions...)
+		} else {
+			open = append(open, positions...)
+		}
+	}
+	results := measure(closed)
+
+	var realized, unrealized, exposure float64
+	openRows := make([][]string, 0, len(open))
+	for _, position := range open {
+		quantity := position.RemainingQuantity()
+		realized += position.RealizedPnL
+		unrealized += position.UnrealizedPnL
+		exposure += math.Abs(float64(quantity) * position.EntryPrice)
+		openRows = append(openRows, []string{
+			s.formatTime(position.CreatedAt),
+			position.Symbol,
+			string(position.Direction),
+			strconv.Itoa(quantity),
+			formatAmount(position.EntryPrice),
+			formatAmount(position.UnrealizedPnL),
+		})
+	}
+	realized += results.netPnL
+
+	legRows := make([][]string, 0, len(portfolio.Legs))
+	for _, leg := range portfolio.Legs {
+		instrument := string(leg.Type)
+		if leg.OptionType != "" {
+			instrument = leg.OptionType
+		}
+		strike := ""
+		if leg.StrikePrice > 0 {
+			strike = formatAmount(leg.StrikePrice)
+		}
+		expiry := ""
+		if !leg.Expiry.IsZero() {
+			expiry = leg.Expiry.Format(dateFormat)
+		}
+		legRows = append(legRows, []string{
+			strconv.Itoa(leg.ID),
+			leg.BuySell,
+			instrument,
+			strike,
+			expiry,
+			strconv.Itoa(leg.Lots),
+			strconv.Itoa(leg.Quantity),
+			formatAmount(leg.EntryPrice),
+			formatAmount(leg.CurrentPrice),
+			formatAmount(leg.TotalPnL),
+		})
+	}
+
+	title := "Portfolio Summary"
+	doc := newDocument(s.config.Branding, title)
+	doc.row([]string{portfolio.Name}, []float64{0}, 16, true)
+	details := fmt.Sprintf("%s on %s, %s", portfolio.Symbol, portfolio.Exchange, portfolio.Status)
+	if !portfolio.Expiry.IsZero() {
+		details += ", expiring " + portfolio.Expiry.Format(dateFormat)
+	}
+	doc.line(details, 10)
+	doc.line("Prepared for "+s.displayName(portfolio.UserID)+" on "+s.formatTime(time.Now()), 10)
+
+	doc.heading("Summary")
+	doc.keyValues([][2]string{
+		{"Legs", strconv.Itoa(len(portfolio.Legs))},
+		{"Open positions", strconv.Itoa(len(open))},
+		{"Gross exposure", formatAmount(exposure)},
+		{"Realized P&L", formatAmount(realized)},
+		{"Unrealized P&L", formatAmount(unrealized)},
+		{"Total P&L", formatAmount(realized + unrealized)},
+	})
+
+	doc.heading("Legs")
+	doc.table(legColumns, legRows)
+
+	doc.heading("Realized P&L")
+	doc.chart(results.equity)
+
+	doc.heading("Open Positions")
+	doc.table(openColumns, openRows)
+
+	doc.heading("Closed Positions")
+	doc.table(closedColumns, s.closedRows(results.positions))
+
+	return s.publish(doc, Report{
+		Kind:      KindPortfolioSummary,
+		UserID:    portfolio.UserID,
+		SubjectID: portfolio.ID,
+		Title:     title,
+	})
+}
+
+// StrategyPerformance renders the performance of a strategy of the user over the
+// positions it closed from one date to another, YYYY-MM-DD, both included: its
+// win rate, profit factor, drawdown, equity curve and trades. The period defaults
+// to the strategy's lifetime. Admins, with an empty user ID, may report on
+// anyone's.
+func (s *Service) StrategyPerformance(strategyID, userID, from, to string) (*Report, error) {
+	strategy, err := s.strategies.GetByID(strategyID)
+	if err != nil || strategy == nil {
+		return nil, ErrStrategyNotFound
+	}
+	if userID != "" && strategy.UserID != userID {
+		return nil, ErrAccessDenied
+	}
+
+	start, end, err := s.period(from, to, strategy.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	closed, err := s.findPositions(models.PositionFilter{StrategyID: strategy.ID, Status: models.PositionStatusClosed, ClosedFrom: start, ClosedBefore: end})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+	results := measure(closed)
+
+	title := "Strategy Performance"
+	doc := newDocument(s.config.Branding, title)
+	doc.row([]string{strategy.Name}, []float64{0}, 16, true)
+	doc.line(fmt.Sprintf("Performance of %s to %s", start.Format(dateFormat), end.AddDate(0, 0, -1).Format(dateFormat)), 10)
+	if strategy.Description != "" {
+		doc.line(strategy.Description, 10)
+	}
+
+	doc.heading("Performance")
+	doc.keyValues([][2]string{
+		{"Trades", strconv.Itoa(results.trades)},
+		{"Win rate", formatPercent(results.winRate)},
+		{"Net P&L", formatAmount(results.netPnL)},
+		{"Gross profit", formatAmount(results.grossProfit)},
+		{"Gross loss", formatAmount(results.grossLoss)},
+		{"Profit factor", formatRatio(results.profitFactor)},
+		{"Average win", formatAmount(results.averageWin)},
+		{"Average loss", formatAmount(results.averageLoss)},
+		{"Best trade", formatAmount(results.best)},
+		{"Worst trade", formatAmount(results.worst)},
+		{"Max drawdown", formatAmount(results.maxDrawdown)},
+	})
+
+	doc.heading("Equity Curve")
+	doc.chart(results.equity)
+
+	doc.heading("Drawdown")
+	doc.chart(results.drawdown)
+
+	doc.heading("Trades")
+	doc.table(closedColumns, s.closedRows(results.positions))
+
+	return s.publish(doc, Report{
+		Kind:      KindStrategyPerformance,
+		UserID:    strategy.UserID,
+		SubjectID: strategy.ID,
+		Title:     title,
+		From:      start.Format(dateFormat),
+		To:        end.AddDate(0, 0, -1).Format(dateFormat),
+	})
+}
+
+// Reports returns the reports rendered for a user, newest first, with download
+// URLs signed afresh, or for every user when the user ID is empty
+func (s *Service) Reports(userID string) ([]Report, error) {
+	s.mutex.Lock()
+	var reports []Report
+	if userID != "" {
+		reports = append(reports, s.reports[userID]...)
+	} else {
+		for _, userReports := range s.reports {
+			reports = append(reports, userReports...)
+		}
+		sort.SliceStable(reports, func(i, j int) bool { return reports[i].GeneratedAt.After(reports[j].GeneratedAt) })
+	}
+	s.mutex.Unlock()
+
+	for i := range reports {
+		if err := s.sign(&reports[i]); err != nil {
+			return nil, err
+		}
+	}
+	if reports == nil {
+		reports = []Report{}
+	}
+	return reports, nil
+}
+
+// Download returns the content and content type of the report a download URL the
+// service signed points to
+func (s *Service) Download(key, expires, signature string) ([]byte, string, error) {
+	if err := verify(s.secret, key, expires, signature, time.Now()); err != nil {
+		return nil, "", err
+	}
+
+	data, contentType, err := s.store.Get(key)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, "", ErrReportNotFound
+		}
+		return nil, "", err
+	}
+	return data, contentType, nil
+}
+
+// publish stores a rendered report and lists it for its user, with a signed URL
+// to download it by
+func (s *Service) publish(doc *document, report Report) (*Report, error) {
+	return s.save(doc.bytes(), "pdf", contentTypePDF, report)
+}
+
+// save stores a report of a content type, its file having extension, and lists it
+// for its user, with a signed URL to download it by
+func (s *Service) save(data []byte, extension, contentType string, report Report) (*Report, error) {
+	report.GeneratedAt = time.Now().In(s.config.Location)
+	slug := strings.ToLower(strings.ReplaceAll(string(report.Kind), "_", "-"))
+	report.FileName = slug + "-" + report.GeneratedAt.Format("20060102-150405") + "." + extension
+	if report.SubjectID != "" {
+		report.FileName = slug + "-" + report.SubjectID + "-" + report.GeneratedAt.Format("20060102-150405") + "." + extension
+	}
+	report.Key = fmt.Sprintf("reports/%s/%d-%s", report.UserID, report.GeneratedAt.UnixNano(), report.FileName)
+	report.Size = len(data)
+
+	if err := s.store.Put(report.Key, contentType, data); err != nil {
+		return nil, fmt.Errorf("failed to store report: %w", err)
+	}
+
+	s.mutex.Lock()
+	reports := append([]Report{report}, s.reports[report.UserID]...)
+	if len(reports) > maxReports {
+		reports = reports[:maxReports]
+	}
+	s.reports[report.UserID] = reports
+	s.mutex.Unlock()
+
+	if err := s.sign(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// sign sets the download URL of a report and when it expires, signed by the
+// object store when it signs its own URLs and by the service otherwise
+func (s *Service) sign(report *Report) error {
+	report.ExpiresAt = time.Now().Add(s.config.URLExpiry)
+
+	if signer, ok := s.store.(URLSigner); ok {
+		signed, err := signer.SignURL(report.Key, report.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to sign download URL: %w", err)
+		}
+		report.URL = signed
+		return nil
+	}
+
+	report.URL = downloadURL(s.config.BaseURL, s.secret, report.Key, report.ExpiresAt)
+	return nil
+}
+
+// period parses a period from one date to another, both included, returning when
+// it starts and the start of the day after it ends. The period ends today when no
+// end is given and starts at start, or at the start of the month it ends in when
+// start is zero, when no start is given.
+func (s *Service) period(from, to string, start time.Time) (time.Time, time.Time, error) {
+	location := s.config.Location
+	now := time.Now().In(location)
+
+	last := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	if to != "" {
+		parsed, err := time.ParseInLocation(dateFormat, to, location)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDate
+		}
+		last = parsed
+	}
+
+	first := time.Date(last.Year(), last.Month(), 1, 0, 0, 0, 0, location)
+	if !start.IsZero() {
+		start = start.In(location)
+		first = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location)
+	}
+	if from != "" {
+		parsed, err := time.ParseInLocation(dateFormat, from, location)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDate
+		}
+		first = parsed
+	}
+
+	if first.After(last) {
+		return time.Time{}, time.Time{}, ErrInvalidPeriod
+	}
+	return first, last.AddDate(0, 0, 1), nil
+}
+
+// findOrders returns all orders matching filter
+func (s *Service) findOrders(filter models.OrderFilter) ([]models.Order, error) {
+	var orders []models.Order
+	for page := 1; ; page++ {
+		batch, total, err := s.orders.GetOrders(filter, page, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, batch...)
+		if len(batch) == 0 || page*pageSize >= total {
+			break
+		}
+	}
+
+	return orders, nil
+}
+
+// findPositions returns all positions matching filter
+func (s *Service) findPositions(filter models.PositionFilter) ([]models.Position, error) {
+	var positions []models.Position
+	for page := 1; ; page++ {
+		batch, total, err := s.positions.GetPositions(filter, page, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, batch...)
+		if len(batch) == 0 || page*pageSize >= total {
+			break
+		}
+	}
+
+	return positions, nil
+}
+
+// closedRows returns the rows of the closed positions table
+func (s *Service) closedRows(positions []models.Position) [][]string {
+	rows := make([][]string, 0, len(positions))
+	for _, position := range positions {
+		rows = append(rows, []string{
+			s.formatTime(position.UpdatedAt),
+			position.Symbol,
+			string(position.Direction),
+			strconv.Itoa(position.Quantity),
+			formatAmount(position.EntryPrice),
+			formatAmount(position.ExitPrice),
+			formatAmount(position.RealizedPnL),
+		})
+	}
+	return rows
+}
+
+// displayName returns the name a user's reports are addressed to
+func (s *Service) displayName(userID string) string {
+	if s.users == nil {
+		return userID
+	}
+	user, err := s.users.GetByID(userID)
+	if err != nil || user == nil {
+		return userID
+	}
+
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = user.Username
+	}
+	if name == "" {
+		return userID
+	}
+	return name
+}
+
+// formatTime formats a time in the service's time zone
+func (s *Service) formatTime(at time.Time) string {
+	if at.IsZero() {
+		return ""
+	}
+	return at.In(s.config.Location).Format(timeFormat)
+}
+
+// Columns of the tables of reports
+var (
+	tradeColumns = []column{
+		{"Time", 0}, {"Symbol", 85}, {"Side", 200}, {"Quantity", 240}, {"Price", 295}, {"Value", 360}, {"Fee", 445},
+	}
+	closedColumns = []column{
+		{"Closed", 0}, {"Symbol", 85}, {"Side", 200}, {"Quantity", 240}, {"Entry", 295}, {"Exit", 360}, {"P&L", 445},
+	}
+	openColumns = []column{
+		{"Opened", 0}, {"Symbol", 85}, {"Side", 200}, {"Quantity", 240}, {"Entry", 295}, {"Unrealized P&L", 360},
+	}
+	legColumns = []column{
+		{"Leg", 0}, {"Side", 30}, {"Type", 70}, {"Strike", 115}, {"Expiry", 170}, {"Lots", 235},
+		{"Quantity", 270}, {"Entry", 320}, {"Current", 380}, {"P&L", 445},
+	}
+)
+
+// results are the performance of closed positions
+type results struct {
+	positions    []models.Position // By the time they closed
+	trades       int
+	wins         int
+	winRate      float64 // Percent
+	netPnL       float64
+	grossProfit  float64
+	grossLoss    float64 // Negative
+	profitFactor float64 // Gross profit over gross loss, infinite without losses
+	averageWin   float64
+	averageLoss  float64
+	best         float64
+	worst        float64
+	maxDrawdown  float64   // Largest fall of the equity curve from its peak
+	equity       []float64 // Cumulative P&L after each position, from zero
+	drawdown     []float64 // Below the peak of the equity curve after each position, as negatives
+}
+
+// measure measures the performance of closed positions in the order they closed
+func measure(closed []models.Position) results {
+	positions := append([]models.Position(nil), closed...)
+	sort.SliceStable(positions, func(i, j int) bool { return positions[i].UpdatedAt.Before(positions[j].UpdatedAt) })
+
+	r := results{
+		positions: positions,
+		trades:    len(positions),
+		equity:    []float64{0},
+		drawdown:  []float64{0},
+	}
+
+	peak, losses := 0.0, 0
+	for i, position := range positions {
+		pnl := position.RealizedPnL
+		r.netPnL += pnl
+		if pnl > 0 {
+			r.wins++
+			r.grossProfit += pnl
+		} else if pnl < 0 {
+			losses++
+			r.grossLoss += pnl
+		}
+		if i == 0 || pnl > r.best {
+			r.best = pnl
+		}
+		if i == 0 || pnl < r.worst {
+			r.worst = pnl
+		}
+
+		peak = math.Max(peak, r.netPnL)
+		r.maxDrawdown = math.Max(r.maxDrawdown, peak-r.netPnL)
+		r.equity = append(r.equity, r.netPnL)
+		r.drawdown = append(r.drawdown, r.netPnL-peak)
+	}
+
+	if r.trades > 0 {
+		r.winRate = float64(r.wins) / float64(r.trades) * 100
+	}
+	if r.wins > 0 {
+		r.averageWin = r.grossProfit / float64(r.wins)
+	}
+	if losses > 0 {
+		r.averageLoss = r.grossLoss / float64(losses)
+	}
+	switch {
+	case r.grossLoss < 0:
+		r.profitFactor = r.grossProfit / -r.grossLoss
+	case r.grossProfit > 0:
+		r.profitFactor = math.Inf(1)
+	}
+
+	return r
+}
+
+// formatAmount formats an amount with two decimals and thousands separators
+func formatAmount(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	whole := strconv.FormatFloat(amount, 'f', 2, 64)
+	integer, decimals := whole[:len(whole)-3], whole[len(whole)-3:]
+
+	var builder strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteRune(digit)
+	}
+	return sign + builder.String() + decimals
+}
+
+// formatPercent formats a percentage with one decimal
+func formatPercent(percent float64) string {
+	return strconv.FormatFloat(percent, 'f', 1, 64) + "%"
+}
+
+// formatRatio formats a ratio with two decimals, infinite ratios as such
+func formatRatio(ratio float64) string {
+	if math.IsInf(ratio, 1) {
+		return "Infinite"
+	}
+	return strconv.FormatFloat(ratio, 'f', 2, 64)
+}
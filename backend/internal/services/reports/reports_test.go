@@ -0,0 +1,419 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// stubOrders returns fixed orders of the user filtered on within the period
+type stubOrders struct {
+	orders []models.Order
+}
+
+func (s *stubOrders) GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error) {
+	var orders []models.Order
+	for _, order := range s.orders {
+		if order.UserID == filter.UserID && !order.UpdatedAt.Before(filter.FromDate) && order.UpdatedAt.Before(filter.ToDate) {
+			orders = append(orders, order)
+		}
+	}
+	return orders, len(orders), nil
+}
+
+// stubPositions returns fixed positions of the user, portfolio or strategy and
+// status filtered on. Like the repository, it dates them by when they were opened,
+// both bounds included, and by when they were closed, before the upper bound.
+type stubPositions struct {
+	positions []models.Position
+}
+
+func (s *stubPositions) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	var positions []models.Position
+	for _, position := range s.positions {
+		if (filter.UserID != "" && position.UserID != filter.UserID) ||
+			(filter.PortfolioID != "" && position.PortfolioID != filter.PortfolioID) ||
+			(filter.StrategyID != "" && position.StrategyID != filter.StrategyID) ||
+			position.Status != filter.Status {
+			continue
+		}
+		if (!filter.FromDate.IsZero() && position.CreatedAt.Before(filter.FromDate)) ||
+			(!filter.ToDate.IsZero() && position.CreatedAt.After(filter.ToDate)) {
+			continue
+		}
+		if (!filter.ClosedFrom.IsZero() && position.UpdatedAt.Before(filter.ClosedFrom)) ||
+			(!filter.ClosedBefore.IsZero() && !position.UpdatedAt.Before(filter.ClosedBefore)) {
+			continue
+		}
+		positions = append(positions, position)
+	}
+	return positions, len(positions), nil
+}
+
+type stubPortfolios map[string]*models.Portfolio
+
+func (s stubPortfolios) GetByID(id string) (*models.Portfolio, error) {
+	if portfolio, exists := s[id]; exists {
+		return portfolio, nil
+	}
+	return nil, errors.New("portfolio not found")
+}
+
+type stubStrategies map[string]*models.Strategy
+
+func (s stubStrategies) GetByID(id string) (*models.Strategy, error) {
+	if strategy, exists := s[id]; exists {
+		return strategy, nil
+	}
+	return nil, errors.New("strategy not found")
+}
+
+// memoryStore keeps reports in memory
+type memoryStore map[string][]byte
+
+func (s memoryStore) Put(key, contentType string, data []byte) error {
+	s[key] = data
+	return nil
+}
+
+func (s memoryStore) Get(key string) ([]byte, string, error) {
+	if data, exists := s[key]; exists {
+		return data, contentTypePDF, nil
+	}
+	return nil, "", ErrReportNotFound
+}
+
+// signingStore is an object store signing its own download URLs
+type signingStore struct {
+	memoryStore
+}
+
+func (s signingStore) SignURL(key string, expiresAt time.Time) (string, error) {
+	return "https://bucket.example.com/" + key + "?expires=" + strconv.FormatInt(expiresAt.Unix(), 10), nil
+}
+
+func newTestService(store ObjectStore) *Service {
+	day := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)
+	orders := &stubOrders{orders: []models.Order{
+		{ID: "o1", UserID: "user1", Symbol: "NIFTY24MAR22000CE", Direction: models.OrderDirectionBuy, Quantity: 50, FilledQuantity: 50, AveragePrice: 100, UpdatedAt: day.Add(10 * time.Hour)},
+		{ID: "o2", UserID: "user1", Symbol: "NIFTY24MAR22000CE", Direction: models.OrderDirectionSell, Quantity: 50, FilledQuantity: 50, AveragePrice: 120, UpdatedAt: day.Add(14 * time.Hour)},
+		{ID: "o3", UserID: "user1", Symbol: "BANKNIFTY", Direction: models.OrderDirectionBuy, Quantity: 15, UpdatedAt: day.Add(11 * time.Hour)},
+	}}
+	positions := &stubPositions{positions: []models.Position{
+		{ID: "p1", UserID: "user1", PortfolioID: "portfolio1", StrategyID: "strategy1", Symbol: "NIFTY24MAR22000CE", Direction: models.PositionDirectionLong, EntryPrice: 100, ExitPrice: 120, Quantity: 50, ExitQuantity: 50, RealizedPnL: 1000, Status: models.PositionStatusClosed, UpdatedAt: day.Add(14 * time.Hour)},
+		{ID: "p2", UserID: "user1", PortfolioID: "portfolio1", StrategyID: "strategy1", Symbol: "NIFTY24MAR22100PE", Direction: models.PositionDirectionShort, EntryPrice: 80, ExitPrice: 90, Quantity: 50, ExitQuantity: 50, RealizedPnL: -500, Status: models.PositionStatusClosed, UpdatedAt: day.Add(15 * time.Hour)},
+		{ID: "p3", UserID: "user1", PortfolioID: "portfolio1", StrategyID: "strategy1", Symbol: "NIFTY24MAR22200CE", Direction: models.PositionDirectionShort, EntryPrice: 60, Quantity: 50, UnrealizedPnL: 250, Status: models.PositionStatusOpen, CreatedAt: day.Add(15 * time.Hour)},
+	}}
+	portfolios := stubPortfolios{"portfolio1": {ID: "portfolio1", UserID: "user1", Name: "Weekly (NIFTY) straddle", Symbol: "NIFTY", Exchange: "NSE", Status: models.PortfolioStatusActive, Legs: []models.Leg{
+		{ID: 1, BuySell: "SELL", OptionType: "CE", StrikePrice: 22000, Lots: 1, Quantity: 50, EntryPrice: 100},
+		{ID: 2, BuySell: "SELL", OptionType: "PE", StrikePrice: 22000, Lots: 1, Quantity: 50, EntryPrice: 90},
+	}}}
+	strategies := stubStrategies{"strategy1": {ID: "strategy1", UserID: "user1", Name: "Straddle", CreatedAt: day}}
+
+	return NewService(orders, positions, portfolios, strategies, nil, store, Config{
+		Location: time.UTC,
+		BaseURL:  "https://api.example.com/",
+		Secret:   "secret",
+	})
+}
+
+// downloadParameters returns the key, expiry and signature of a download URL
+func downloadParameters(t *testing.T, downloadURL string) (string, string, string) {
+	parsed, err := url.Parse(downloadURL)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/reports/download", parsed.Path)
+	query := parsed.Query()
+	return query.Get("key"), query.Get("expires"), query.Get("signature")
+}
+
+func TestTradeStatement(t *testing.T) {
+	store := memoryStore{}
+	service := newTestService(store)
+
+	report, err := service.TradeStatement("user1", "2024-03-01", "2024-03-12")
+	require.NoError(t, err)
+	assert.Equal(t, KindTradeStatement, report.Kind)
+	assert.Equal(t, "user1", report.UserID)
+	assert.Equal(t, "2024-03-01", report.From)
+	assert.Equal(t, "2024-03-12", report.To)
+	assert.True(t, strings.HasPrefix(report.Key, "reports/user1/"))
+	assert.True(t, strings.HasSuffix(report.FileName, ".pdf"))
+
+	data := store[report.Key]
+	require.NotEmpty(t, data)
+	assert.Equal(t, len(data), report.Size)
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+	assert.Contains(t, string(data), "(Trade Statement)")
+	assert.Contains(t, string(data), "(MarvelQuant)")
+	assert.Contains(t, string(data), "(NIFTY24MAR22000CE)")
+	assert.Contains(t, string(data), "(1,000.00)")
+
+	key, expires, sig := downloadParameters(t, report.URL)
+	assert.Equal(t, report.Key, key)
+	assert.True(t, strings.HasPrefix(report.URL, "https://api.example.com/api/reports/download?"))
+
+	downloaded, contentType, err := service.Download(key, expires, sig)
+	require.NoError(t, err)
+	assert.Equal(t, data, downloaded)
+	assert.Equal(t, contentTypePDF, contentType)
+
+	_, _, err = service.Download("reports/user2/other.pdf", expires, sig)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	_, _, err = service.Download(key, strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10), sig)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	past := time.Now().Add(-time.Minute)
+	_, _, err = service.Download(key, strconv.FormatInt(past.Unix(), 10), signature(service.secret, key, past.Unix()))
+	assert.Equal(t, ErrLinkExpired, err)
+
+	// Statements are of dates, both included, the period not ending before it starts
+	_, err = service.TradeStatement("user1", "2024-03-12", "2024-03-01")
+	assert.Equal(t, ErrInvalidPeriod, err)
+	_, err = service.TradeStatement("user1", "12/03/2024", "")
+	assert.Equal(t, ErrInvalidDate, err)
+}
+
+func TestPortfolioSummary(t *testing.T) {
+	store := memoryStore{}
+	service := newTestService(store)
+
+	_, err := service.PortfolioSummary("missing", "user1")
+	assert.Equal(t, ErrPortfolioNotFound, err)
+	_, err = service.PortfolioSummary("portfolio1", "user2")
+	assert.Equal(t, ErrAccessDenied, err)
+
+	// Admins report on anyone's portfolio, addressed to its owner
+	report, err := service.PortfolioSummary("portfolio1", "")
+	require.NoError(t, err)
+	assert.Equal(t, KindPortfolioSummary, report.Kind)
+	assert.Equal(t, "user1", report.UserID)
+	assert.Equal(t, "portfolio1", report.SubjectID)
+
+	data := string(store[report.Key])
+	assert.Contains(t, data, `(Weekly \(NIFTY\) straddle)`)
+	assert.Contains(t, data, "(22,000.00)")
+	assert.Contains(t, data, "(NIFTY24MAR22200CE)")
+	assert.Contains(t, data, "(750.00)") // Realized 500 and unrealized 250
+}
+
+func TestStrategyPerformance(t *testing.T) {
+	service := newTestService(signingStore{memoryStore{}})
+
+	_, err := service.StrategyPerformance("missing", "user1", "", "")
+	assert.Equal(t, ErrStrategyNotFound, err)
+	_, err = service.StrategyPerformance("strategy1", "user2", "", "")
+	assert.Equal(t, ErrAccessDenied, err)
+
+	// The period defaults to the strategy's lifetime
+	report, err := service.StrategyPerformance("strategy1", "user1", "", "2024-03-31")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-12", report.From)
+	assert.Equal(t, "2024-03-31", report.To)
+
+	// Stores signing their own URLs are downloaded from directly
+	assert.True(t, strings.HasPrefix(report.URL, "https://bucket.example.com/"+report.Key))
+}
+
+func TestReports(t *testing.T) {
+	service := newTestService(memoryStore{})
+
+	first, err := service.TradeStatement("user1", "2024-03-01", "2024-03-12")
+	require.NoError(t, err)
+	second, err := service.PortfolioSummary("portfolio1", "user1")
+	require.NoError(t, err)
+
+	reports, err := service.Reports("user1")
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, second.Key, reports[0].Key)
+	assert.Equal(t, first.Key, reports[1].Key)
+	assert.NotEmpty(t, reports[1].URL)
+
+	reports, err = service.Reports("user2")
+	require.NoError(t, err)
+	assert.Empty(t, reports)
+
+	reports, err = service.Reports("")
+	require.NoError(t, err)
+	assert.Len(t, reports, 2)
+}
+
+func TestMeasure(t *testing.T) {
+	at := time.Date(2024, 3, 12, 10, 0, 0, 0, time.UTC)
+	var closed []models.Position
+	for i, pnl := range []float64{300, -100, -400, 500, 200} {
+		closed = append(closed, models.Position{RealizedPnL: pnl, UpdatedAt: at.Add(time.Duration(i) * time.Minute)})
+	}
+	// Positions are measured in the order they closed
+	closed[0], closed[4] = closed[4], closed[0]
+
+	results := measure(closed)
+	assert.Equal(t, 5, results.trades)
+	assert.Equal(t, 3, results.wins)
+	assert.InDelta(t, 60, results.winRate, 0.001)
+	assert.InDelta(t, 500, results.netPnL, 0.001)
+	assert.InDelta(t, 1000, results.grossProfit, 0.001)
+	assert.InDelta(t, -500, results.grossLoss, 0.001)
+	assert.InDelta(t, 2, results.profitFactor, 0.001)
+	assert.InDelta(t, 1000.0/3, results.averageWin, 0.001)
+	assert.InDelta(t, -250, results.averageLoss, 0.001)
+	assert.InDelta(t, 500, results.best, 0.001)
+	assert.InDelta(t, -400, results.worst, 0.001)
+	assert.InDelta(t, 500, results.maxDrawdown, 0.001)
+	assert.Equal(t, []float64{0, 300, 200, -200, 300, 500}, results.equity)
+	assert.Equal(t, []float64{0, 0, -100, -500, 0, 0}, results.drawdown)
+
+	assert.True(t, math.IsInf(measure(closed[:1]).profitFactor, 1))
+	assert.Equal(t, []float64{0}, measure(nil).equity)
+}
+
+func newTaxService(store ObjectStore) *Service {
+	opened := time.Date(2023, 6, 1, 9, 30, 0, 0, time.UTC)
+	positions := &stubPositions{positions: []models.Position{
+		{ID: "future", UserID: "user1", Symbol: "NIFTY23JUNFUT", InstrumentType: models.InstrumentTypeFuture, ProductType: models.ProductTypeNRML, Direction: models.PositionDirectionLong, EntryPrice: 18500, ExitPrice: 18600, Quantity: 50, ExitQuantity: 50, RealizedPnL: 5000, Status: models.PositionStatusClosed, CreatedAt: opened, UpdatedAt: opened.AddDate(0, 0, 3)},
+		{ID: "option", UserID: "user1", Symbol: "NIFTY23JUN18500CE", InstrumentType: models.InstrumentTypeOption, ProductType: models.ProductTypeMIS, Direction: models.PositionDirectionShort, EntryPrice: 100, ExitPrice: 130, Quantity: 50, ExitQuantity: 50, RealizedPnL: -1500, Status: models.PositionStatusClosed, CreatedAt: opened, UpdatedAt: opened.Add(4 * time.Hour)},
+		{ID: "intraday", UserID: "user1", Symbol: "RELIANCE", InstrumentType: models.InstrumentTypeStock, ProductType: models.ProductTypeCNC, Direction: models.PositionDirectionLong, EntryPrice: 2500, ExitPrice: 2490, Quantity: 10, RealizedPnL: -100, Status: models.PositionStatusClosed, CreatedAt: opened, UpdatedAt: opened.Add(5 * time.Hour)},
+		{ID: "short-term", UserID: "user1", Symbol: "INFY", InstrumentType: models.InstrumentTypeStock, ProductType: models.ProductTypeCNC, Direction: models.PositionDirectionLong, EntryPrice: 1400, ExitPrice: 1500, Quantity: 20, ExitQuantity: 20, RealizedPnL: 2000, Status: models.PositionStatusClosed, CreatedAt: opened, UpdatedAt: opened.AddDate(0, 2, 0)},
+		{ID: "long-term", UserID: "user1", Symbol: "TCS", InstrumentType: models.InstrumentTypeStock, ProductType: models.ProductTypeCNC, Direction: models.PositionDirectionLong, EntryPrice: 3000, ExitPrice: 3600, Quantity: 5, ExitQuantity: 5, RealizedPnL: 3000, Status: models.PositionStatusClosed, CreatedAt: opened.AddDate(-2, 0, 0), UpdatedAt: opened.AddDate(0, 1, 0)},
+		{ID: "next-year", UserID: "user1", Symbol: "NIFTY24APRFUT", InstrumentType: models.InstrumentTypeFuture, Direction: models.PositionDirectionLong, Quantity: 50, RealizedPnL: 700, Status: models.PositionStatusClosed, CreatedAt: time.Date(2024, 4, 1, 9, 15, 0, 0, time.UTC), UpdatedAt: time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)},
+		{ID: "year-end", UserID: "user1", Symbol: "NIFTY24APRFUT", InstrumentType: models.InstrumentTypeFuture, Direction: models.PositionDirectionShort, Quantity: 50, RealizedPnL: -200, Status: models.PositionStatusClosed, CreatedAt: time.Date(2024, 3, 28, 9, 15, 0, 0, time.UTC), UpdatedAt: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}}
+
+	return NewService(&stubOrders{}, positions, stubPortfolios{}, stubStrategies{}, nil, store, Config{
+		Location: time.UTC,
+		BaseURL:  "https://api.example.com/",
+		Secret:   "secret",
+	})
+}
+
+func TestTaxReport(t *testing.T) {
+	service := newTaxService(memoryStore{})
+
+	tax, err := service.TaxReport("user1", "2023-24")
+	require.NoError(t, err)
+	assert.Equal(t, "2023-24", tax.FinancialYear)
+	assert.Equal(t, "2024-25", tax.AssessmentYear)
+	assert.Equal(t, "2023-04-01", tax.From)
+	assert.Equal(t, "2024-03-31", tax.To)
+	// Trades are of the year they closed in, so the futures closed in April 2024 are
+	// of the next year and the equity bought two years before is of this one
+	require.Len(t, tax.Trades, 5)
+
+	// Futures and options are non-speculative business income, their turnover the
+	// absolute P&L of each trade
+	assert.Equal(t, 1, tax.Futures.Trades)
+	assert.Equal(t, 1, tax.Options.Trades)
+	assert.Equal(t, 2, tax.NonSpeculative.Trades)
+	assert.Equal(t, 6500.0, tax.NonSpeculative.Turnover)
+	assert.Equal(t, 3500.0, tax.NonSpeculative.PnL)
+	assert.InDelta(t, tax.NonSpeculative.PnL-tax.NonSpeculative.Charges, tax.NonSpeculative.Net, 1e-9)
+
+	// Equity bought and sold the same day is speculative, whatever its product
+	assert.Equal(t, 1, tax.Speculative.Trades)
+	assert.Equal(t, 100.0, tax.Speculative.Turnover)
+	assert.Equal(t, -100.0, tax.Speculative.PnL)
+
+	// Equity held over a year has long term gains
+	assert.Equal(t, 1, tax.ShortTermGains.Trades)
+	assert.Equal(t, 30000.0, tax.ShortTermGains.SellValue)
+	assert.Equal(t, 28000.0, tax.ShortTermGains.BuyValue)
+	assert.Equal(t, 0.0, tax.ShortTermGains.Turnover)
+	assert.Equal(t, 1, tax.LongTermGains.Trades)
+	assert.Equal(t, 3000.0, tax.LongTermGains.PnL)
+
+	// Short positions were sold first
+	var option TaxTrade
+	for _, trade := range tax.Trades {
+		if trade.PositionID == "option" {
+			option = trade
+		}
+	}
+	assert.Equal(t, TaxHeadNonSpeculative, option.Head)
+	assert.Equal(t, 6500.0, option.BuyValue)
+	assert.Equal(t, 5000.0, option.SellValue)
+
+	// Years may be given in full, and must be consecutive
+	tax, err = service.TaxReport("user1", "2024-2025")
+	require.NoError(t, err)
+	assert.Len(t, tax.Trades, 2)
+	for _, year := range []string{"2023", "2023-25", "23-24", "2023-2023", "FY2023-24"} {
+		_, err = service.TaxReport("user1", year)
+		assert.Equal(t, ErrInvalidFinancialYear, err, year)
+	}
+}
+
+func TestTaxStatement(t *testing.T) {
+	store := memoryStore{}
+	service := newTaxService(store)
+
+	report, err := service.TaxStatement("user1", "2023-24", FormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, KindTaxReport, report.Kind)
+	assert.Equal(t, "2023-04-01", report.From)
+	assert.Equal(t, "2024-03-31", report.To)
+	assert.True(t, strings.HasPrefix(report.FileName, "tax-report-fy2023-24-"))
+	assert.True(t, strings.HasSuffix(report.FileName, ".csv"))
+
+	reader := csv.NewReader(bytes.NewReader(store[report.Key]))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Financial Year", "2023-24", "Assessment Year", "2024-25"}, records[1])
+	// Blank lines between the sections are skipped by readers
+	assert.Equal(t, []string{"Futures", "1", "5000.00"}, records[4][:3])
+	assert.Equal(t, taxHeadLabels[TaxHeadNonSpeculative], records[6][0])
+	assert.Equal(t, "6500.00", records[6][2])
+	assert.Equal(t, "Head of Income", records[10][0])
+	require.Len(t, records, 16)
+	assert.Equal(t, []string{"option", "NIFTY23JUN18500CE"}, records[11][1:3])
+
+	report, err = service.TaxStatement("user1", "2023-24", FormatPDF)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(report.FileName, ".pdf"))
+	data := string(store[report.Key])
+	assert.Contains(t, data, "(Tax Report)")
+	assert.Contains(t, data, "(NIFTY23JUN18500CE)")
+	assert.Contains(t, data, "(6,500.00)")
+
+	_, err = service.TaxStatement("user1", "2023-24", "xlsx")
+	assert.Equal(t, ErrInvalidFormat, err)
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "0.00", formatAmount(0))
+	assert.Equal(t, "999.50", formatAmount(999.5))
+	assert.Equal(t, "1,000.00", formatAmount(1000))
+	assert.Equal(t, "-1,234,567.89", formatAmount(-1234567.891))
+}
+
+func TestFileObjectStore(t *testing.T) {
+	store := NewFileObjectStore(t.TempDir())
+
+	require.NoError(t, store.Put("reports/user1/report.pdf", contentTypePDF, []byte("%PDF-1.4")))
+	data, contentType, err := store.Get("reports/user1/report.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("%PDF-1.4"), data)
+	assert.Equal(t, contentTypePDF, contentType)
+
+	require.NoError(t, store.Put("reports/user1/tax-report.csv", contentTypeCSV, []byte("Tax Report\n")))
+	_, contentType, err = store.Get("reports/user1/tax-report.csv")
+	require.NoError(t, err)
+	assert.Equal(t, contentTypeCSV, contentType)
+
+	_, _, err = store.Get("reports/user1/missing.pdf")
+	assert.Equal(t, ErrReportNotFound, err)
+	_, _, err = store.Get("reports/../../etc/passwd")
+	assert.Equal(t, ErrReportNotFound, err)
+}
@@ -0,0 +1,128 @@
+package reports
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectStore stores rendered reports by key, as an object storage bucket does
+type ObjectStore interface {
+	Put(key, contentType string, data []byte) error
+	Get(key string) ([]byte, string, error)
+}
+
+// URLSigner is implemented by object stores that sign their own download URLs, as
+// S3 and GCS presigned URLs are. Reports kept in other stores are downloaded
+// through the platform with URLs it signs itself.
+type URLSigner interface {
+	SignURL(key string, expiresAt time.Time) (string, error)
+}
+
+// FileObjectStore stores reports on the local filesystem
+type FileObjectStore struct {
+	directory string
+}
+
+// NewFileObjectStore creates a new object store rooted at directory
+func NewFileObjectStore(directory string) *FileObjectStore {
+	return &FileObjectStore{directory: directory}
+}
+
+// Put implements ObjectStore
+func (s *FileObjectStore) Put(key, contentType string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// Get implements ObjectStore, taking the content type of reports from the
+// extension of their key
+func (s *FileObjectStore) Get(key string) ([]byte, string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", ErrReportNotFound
+		}
+		return nil, "", fmt.Errorf("failed to read report: %w", err)
+	}
+	if strings.HasSuffix(key, ".csv") {
+		return data, contentTypeCSV, nil
+	}
+	return data, contentTypePDF, nil
+}
+
+// path returns where a key is stored, refusing keys leading out of the directory
+func (s *FileObjectStore) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + filepath.FromSlash(key))
+	if cleaned == string(filepath.Separator) || strings.Contains(key, "..") {
+		return "", ErrReportNotFound
+	}
+	return filepath.Join(s.directory, cleaned), nil
+}
+
+// signature returns the hex encoded HMAC-SHA256 of "<key>.<expires>" keyed with
+// secret, expires being a Unix time
+func signature(secret []byte, key string, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(key))
+	mac.Write([]byte("."))
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// downloadURL returns the URL of the platform's download endpoint for a key,
+// signed until expiresAt
+func downloadURL(baseURL string, secret []byte, key string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	query := url.Values{}
+	query.Set("key", key)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", signature(secret, key, expires))
+	return strings.TrimSuffix(baseURL, "/") + "/api/reports/download?" + query.Encode()
+}
+
+// verify checks a download URL's signature and that it has not expired at now
+func verify(secret []byte, key, expires, sig string, now time.Time) error {
+	if key == "" || expires == "" || sig == "" {
+		return ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature(secret, key, unix)), []byte(sig)) {
+		return ErrInvalidSignature
+	}
+	if now.After(time.Unix(unix, 0)) {
+		return ErrLinkExpired
+	}
+	return nil
+}
+
+// isNotFound reports whether an object store failed for want of the key
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrReportNotFound) || errors.Is(err, os.ErrNotExist)
+}
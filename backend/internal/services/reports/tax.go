@@ -0,0 +1,422 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// longTermHoldingDays is the holding period of listed shares beyond which their
+// gains are long term
+const longTermHoldingDays = 365
+
+var (
+	// ErrInvalidFinancialYear is returned for financial years other than YYYY-YY
+	ErrInvalidFinancialYear = errors.New("financial year must be YYYY-YY, as 2023-24")
+	// ErrInvalidFormat is returned for tax report formats other than CSV and PDF
+	ErrInvalidFormat = errors.New("format must be csv or pdf")
+)
+
+// Format is the file format of a tax report
+type Format string
+
+const (
+	FormatCSV Format = "csv"
+	FormatPDF Format = "pdf"
+)
+
+// TaxHead is the head of income a trade is taxed under in India
+type TaxHead string
+
+const (
+	TaxHeadNonSpeculative TaxHead = "NON_SPECULATIVE_BUSINESS" // Futures and options
+	TaxHeadSpeculative    TaxHead = "SPECULATIVE_BUSINESS"     // Intraday equity
+	TaxHeadShortTermGains TaxHead = "SHORT_TERM_CAPITAL_GAINS" // Delivery equity held up to a year
+	TaxHeadLongTermGains  TaxHead = "LONG_TERM_CAPITAL_GAINS"  // Delivery equity held longer
+)
+
+// taxHeadLabels are the names accountants know heads of income by
+var taxHeadLabels = map[TaxHead]string{
+	TaxHeadNonSpeculative: "Non-speculative business income (F&O)",
+	TaxHeadSpeculative:    "Speculative business income (intraday equity)",
+	TaxHeadShortTermGains: "Short term capital gains",
+	TaxHeadLongTermGains:  "Long term capital gains",
+}
+
+// TaxTrade is a closed position as it is taxed
+type TaxTrade struct {
+	PositionID  string                `json:"positionId"`
+	Symbol      string                `json:"symbol"`
+	Exchange    string                `json:"exchange"`
+	Instrument  models.InstrumentType `json:"instrument"`
+	ProductType models.ProductType    `json:"productType"`
+	Head        TaxHead               `json:"head"`
+	Quantity    int                   `json:"quantity"`
+	OpenedAt    time.Time             `json:"openedAt"`
+	ClosedAt    time.Time             `json:"closedAt"`
+	HoldingDays int                   `json:"holdingDays"`
+	BuyValue    float64               `json:"buyValue"`  // Cost of acquisition for capital gains
+	SellValue   float64               `json:"sellValue"` // Sale consideration for capital gains
+	PnL         float64               `json:"pnl"`
+	Turnover    float64               `json:"turnover"` // Absolute P&L, for business income only
+	Charges     float64               `json:"charges"`  // Estimated
+}
+
+// TaxTotals totals trades taxed alike
+type TaxTotals struct {
+	Trades    int     `json:"trades"`
+	Turnover  float64 `json:"turnover"`
+	BuyValue  float64 `json:"buyValue"`
+	SellValue float64 `json:"sellValue"`
+	PnL       float64 `json:"pnl"`
+	Charges   float64 `json:"charges"`
+	Net       float64 `json:"net"` // P&L less charges
+}
+
+// add adds a trade to the totals
+func (t *TaxTotals) add(trade TaxTrade) {
+	t.Trades++
+	t.Turnover += trade.Turnover
+	t.BuyValue += trade.BuyValue
+	t.SellValue += trade.SellValue
+	t.PnL += trade.PnL
+	t.Charges += trade.Charges
+	t.Net = t.PnL - t.Charges
+}
+
+// TaxReport is a user's trades of an Indian financial year, April to March, by
+// the head of income they are taxed under. Turnover is the sum of the absolute
+// profit or loss of each trade, as the ICAI Guidance Note on Tax Audit computes
+// it for derivatives and speculative trades.
+type TaxReport struct {
+	UserID         string     `json:"userId"`
+	FinancialYear  string     `json:"financialYear"`  // As 2023-24
+	AssessmentYear string     `json:"assessmentYear"` // As 2024-25
+	From           string     `json:"from"`           // YYYY-MM-DD, both included
+	To             string     `json:"to"`
+	Futures        TaxTotals  `json:"futures"`
+	Options        TaxTotals  `json:"options"`
+	NonSpeculative TaxTotals  `json:"nonSpeculative"` // Futures and options together
+	Speculative    TaxTotals  `json:"speculative"`
+	ShortTermGains TaxTotals  `json:"shortTermGains"`
+	LongTermGains  TaxTotals  `json:"longTermGains"`
+	Trades         []TaxTrade `json:"trades"` // By the time they closed
+	GeneratedAt    time.Time  `json:"generatedAt"`
+}
+
+// TaxReport compiles a user's tax report of a financial year, YYYY-YY, the current
+// one when it is not given, from the positions they closed in it
+func (s *Service) TaxReport(userID, financialYear string) (*TaxReport, error) {
+	label, start, end, err := s.financialYear(financialYear)
+	if err != nil {
+		return nil, err
+	}
+
+	closed, err := s.findPositions(models.PositionFilter{UserID: userID, Status: models.PositionStatusClosed, ClosedFrom: start, ClosedBefore: end})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+	sort.SliceStable(closed, func(i, j int) bool { return closed[i].UpdatedAt.Before(closed[j].UpdatedAt) })
+
+	report := &TaxReport{
+		UserID:         userID,
+		FinancialYear:  label,
+		AssessmentYear: fmt.Sprintf("%d-%02d", start.Year()+1, (start.Year()+2)%100),
+		From:           start.Format(dateFormat),
+		To:             end.AddDate(0, 0, -1).Format(dateFormat),
+		Trades:         []TaxTrade{},
+		GeneratedAt:    time.Now(),
+	}
+
+	for _, position := range closed {
+		trade := s.taxTrade(position)
+		report.Trades = append(report.Trades, trade)
+
+		switch trade.Head {
+		case TaxHeadNonSpeculative:
+			report.NonSpeculative.add(trade)
+			if trade.Instrument == models.InstrumentTypeFuture {
+				report.Futures.add(trade)
+			} else {
+				report.Options.add(trade)
+			}
+		case TaxHeadSpeculative:
+			report.Speculative.add(trade)
+		case TaxHeadShortTermGains:
+			report.ShortTermGains.add(trade)
+		case TaxHeadLongTermGains:
+			report.LongTermGains.add(trade)
+		}
+	}
+
+	return report, nil
+}
+
+// TaxStatement renders a user's tax report of a financial year as CSV, for
+// accountants to work from, or as PDF
+func (s *Service) TaxStatement(userID, financialYear string, format Format) (*Report, error) {
+	if format == "" {
+		format = FormatPDF
+	}
+	if format != FormatCSV && format != FormatPDF {
+		return nil, ErrInvalidFormat
+	}
+
+	tax, err := s.TaxReport(userID, financialYear)
+	if err != nil {
+		return nil, err
+	}
+
+	report := Report{
+		Kind:      KindTaxReport,
+		UserID:    userID,
+		SubjectID: "fy" + tax.FinancialYear,
+		Title:     "Tax Report FY " + tax.FinancialYear,
+		From:      tax.From,
+		To:        tax.To,
+	}
+	if format == FormatCSV {
+		data, err := s.taxCSV(tax)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render tax report: %w", err)
+		}
+		return s.save(data, "csv", contentTypeCSV, report)
+	}
+	return s.publish(s.taxPDF(tax), report)
+}
+
+// taxTrade classifies a closed position by the head of income it is taxed under:
+// futures and options are non-speculative business income, equity bought and
+// sold the same day or intraday is speculative, and equity held is a capital
+// asset whose gains are long term when it is held over a year
+func (s *Service) taxTrade(position models.Position) TaxTrade {
+	quantity := position.ExitQuantity
+	if quantity <= 0 {
+		quantity = position.Quantity
+	}
+	opened := position.CreatedAt.In(s.config.Location)
+	closed := position.UpdatedAt.In(s.config.Location)
+
+	trade := TaxTrade{
+		PositionID:  position.ID,
+		Symbol:      position.Symbol,
+		Exchange:    position.Exchange,
+		Instrument:  position.InstrumentType,
+		ProductType: position.ProductType,
+		Quantity:    quantity,
+		OpenedAt:    opened,
+		ClosedAt:    closed,
+		HoldingDays: int(closed.Sub(opened).Hours() / 24),
+		PnL:         position.RealizedPnL,
+	}
+
+	entry := position.EntryPrice * float64(quantity)
+	exit := position.ExitPrice * float64(quantity)
+	if position.Direction == models.PositionDirectionShort {
+		trade.BuyValue, trade.SellValue = exit, entry
+	} else {
+		trade.BuyValue, trade.SellValue = entry, exit
+	}
+	trade.Charges = s.fees.Fee(trade.BuyValue) + s.fees.Fee(trade.SellValue)
+
+	if trade.Instrument == "" && position.OptionType != "" {
+		trade.Instrument = models.InstrumentTypeOption
+	}
+	sameDay := opened.Year() == closed.Year() && opened.YearDay() == closed.YearDay()
+	switch {
+	case trade.Instrument == models.InstrumentTypeFuture || trade.Instrument == models.InstrumentTypeOption:
+		trade.Head = TaxHeadNonSpeculative
+	case position.ProductType == models.ProductTypeMIS || sameDay:
+		trade.Head = TaxHeadSpeculative
+	case trade.HoldingDays > longTermHoldingDays:
+		trade.Head = TaxHeadLongTermGains
+	default:
+		trade.Head = TaxHeadShortTermGains
+	}
+	if trade.Head == TaxHeadNonSpeculative || trade.Head == TaxHeadSpeculative {
+		trade.Turnover = math.Abs(trade.PnL)
+	}
+
+	return trade
+}
+
+// financialYear parses a financial year, YYYY-YY or YYYY-YYYY, returning its label
+// and when it starts and the next one does. It defaults to the current one.
+func (s *Service) financialYear(year string) (string, time.Time, time.Time, error) {
+	var first int
+	if year == "" {
+		now := time.Now().In(s.config.Location)
+		first = now.Year()
+		if now.Month() < time.April {
+			first--
+		}
+	} else {
+		parts := strings.Split(year, "-")
+		if len(parts) != 2 {
+			return "", time.Time{}, time.Time{}, ErrInvalidFinancialYear
+		}
+		parsed, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts[0]) != 4 {
+			return "", time.Time{}, time.Time{}, ErrInvalidFinancialYear
+		}
+		last, err := strconv.Atoi(parts[1])
+		if err != nil || (len(parts[1]) != 2 && len(parts[1]) != 4) {
+			return "", time.Time{}, time.Time{}, ErrInvalidFinancialYear
+		}
+		if (len(parts[1]) == 2 && last != (parsed+1)%100) || (len(parts[1]) == 4 && last != parsed+1) {
+			return "", time.Time{}, time.Time{}, ErrInvalidFinancialYear
+		}
+		first = parsed
+	}
+
+	start := time.Date(first, time.April, 1, 0, 0, 0, 0, s.config.Location)
+	return fmt.Sprintf("%d-%02d", first, (first+1)%100), start, start.AddDate(1, 0, 0), nil
+}
+
+// taxCSV renders a tax report as CSV: a summary of each head of income followed,
+// after a blank line, by every trade
+func (s *Service) taxCSV(tax *TaxReport) ([]byte, error) {
+	var out bytes.Buffer
+	writer := csv.NewWriter(&out)
+
+	records := [][]string{
+		{"Tax Report", s.displayName(tax.UserID)},
+		{"Financial Year", tax.FinancialYear, "Assessment Year", tax.AssessmentYear},
+		{"From", tax.From, "To", tax.To},
+		{},
+		{"Head of Income", "Trades", "Turnover", "Buy Value", "Sell Value", "P&L", "Estimated Charges", "Net"},
+	}
+	summary := []struct {
+		label  string
+		totals TaxTotals
+	}{
+		{"Futures", tax.Futures},
+		{"Options", tax.Options},
+		{taxHeadLabels[TaxHeadNonSpeculative], tax.NonSpeculative},
+		{taxHeadLabels[TaxHeadSpeculative], tax.Speculative},
+		{taxHeadLabels[TaxHeadShortTermGains], tax.ShortTermGains},
+		{taxHeadLabels[TaxHeadLongTermGains], tax.LongTermGains},
+	}
+	for _, head := range summary {
+		records = append(records, []string{
+			head.label, strconv.Itoa(head.totals.Trades), csvAmount(head.totals.Turnover), csvAmount(head.totals.BuyValue),
+			csvAmount(head.totals.SellValue), csvAmount(head.totals.PnL), csvAmount(head.totals.Charges), csvAmount(head.totals.Net),
+		})
+	}
+
+	records = append(records, []string{}, []string{
+		"Head of Income", "Position ID", "Symbol", "Exchange", "Instrument", "Product", "Quantity", "Opened", "Closed",
+		"Holding Days", "Buy Value", "Sell Value", "P&L", "Turnover", "Estimated Charges",
+	})
+	for _, trade := range tax.Trades {
+		records = append(records, []string{
+			taxHeadLabels[trade.Head], trade.PositionID, trade.Symbol, trade.Exchange, string(trade.Instrument),
+			string(trade.ProductType), strconv.Itoa(trade.Quantity), trade.OpenedAt.Format(dateFormat),
+			trade.ClosedAt.Format(dateFormat), strconv.Itoa(trade.HoldingDays), csvAmount(trade.BuyValue),
+			csvAmount(trade.SellValue), csvAmount(trade.PnL), csvAmount(trade.Turnover), csvAmount(trade.Charges),
+		})
+	}
+
+	if err := writer.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// taxPDF renders a tax report as a PDF with the totals of each head of income and
+// tables of their trades
+func (s *Service) taxPDF(tax *TaxReport) *document {
+	doc := newDocument(s.config.Branding, "Tax Report")
+	doc.row([]string{s.displayName(tax.UserID)}, []float64{0}, 16, true)
+	doc.line(fmt.Sprintf("Financial year %s (assessment year %s), %s to %s", tax.FinancialYear, tax.AssessmentYear, tax.From, tax.To), 10)
+
+	doc.heading(taxHeadLabels[TaxHeadNonSpeculative])
+	doc.keyValues([][2]string{
+		{"Futures turnover", formatAmount(tax.Futures.Turnover)},
+		{"Futures P&L", formatAmount(tax.Futures.PnL)},
+		{"Options turnover", formatAmount(tax.Options.Turnover)},
+		{"Options P&L", formatAmount(tax.Options.PnL)},
+		{"Total turnover", formatAmount(tax.NonSpeculative.Turnover)},
+		{"Total P&L", formatAmount(tax.NonSpeculative.PnL)},
+		{"Estimated charges", formatAmount(tax.NonSpeculative.Charges)},
+		{"Net income", formatAmount(tax.NonSpeculative.Net)},
+	})
+
+	doc.heading(taxHeadLabels[TaxHeadSpeculative])
+	doc.keyValues([][2]string{
+		{"Turnover", formatAmount(tax.Speculative.Turnover)},
+		{"P&L", formatAmount(tax.Speculative.PnL)},
+		{"Estimated charges", formatAmount(tax.Speculative.Charges)},
+		{"Net income", formatAmount(tax.Speculative.Net)},
+	})
+
+	doc.heading("Capital Gains")
+	doc.keyValues([][2]string{
+		{"Short term sale consideration", formatAmount(tax.ShortTermGains.SellValue)},
+		{"Short term cost of acquisition", formatAmount(tax.ShortTermGains.BuyValue)},
+		{"Short term capital gains", formatAmount(tax.ShortTermGains.PnL)},
+		{"Long term sale consideration", formatAmount(tax.LongTermGains.SellValue)},
+		{"Long term cost of acquisition", formatAmount(tax.LongTermGains.BuyValue)},
+		{"Long term capital gains", formatAmount(tax.LongTermGains.PnL)},
+	})
+
+	business := map[TaxHead][][]string{}
+	var gains [][]string
+	for _, trade := range tax.Trades {
+		if trade.Head == TaxHeadShortTermGains || trade.Head == TaxHeadLongTermGains {
+			term := "Short"
+			if trade.Head == TaxHeadLongTermGains {
+				term = "Long"
+			}
+			gains = append(gains, []string{
+				trade.OpenedAt.Format(dateFormat), trade.ClosedAt.Format(dateFormat), trade.Symbol, term,
+				strconv.Itoa(trade.Quantity), formatAmount(trade.BuyValue), formatAmount(trade.SellValue), formatAmount(trade.PnL),
+			})
+			continue
+		}
+		business[trade.Head] = append(business[trade.Head], []string{
+			trade.ClosedAt.Format(dateFormat), trade.Symbol, string(trade.Instrument), strconv.Itoa(trade.Quantity),
+			formatAmount(trade.BuyValue), formatAmount(trade.SellValue), formatAmount(trade.PnL), formatAmount(trade.Turnover),
+		})
+	}
+
+	doc.heading("Futures and Options Trades")
+	doc.table(taxTradeColumns, business[TaxHeadNonSpeculative])
+
+	doc.heading("Intraday Equity Trades")
+	doc.table(taxTradeColumns, business[TaxHeadSpeculative])
+
+	doc.heading("Delivery Equity Trades")
+	doc.table(capitalGainColumns, gains)
+
+	doc.line("Turnover is the sum of the absolute profit or loss of each trade, as the ICAI Guidance Note on Tax Audit computes it.", 8)
+	doc.line("Charges are estimated from the platform's fee schedule; reconcile them with contract notes before filing.", 8)
+
+	return doc
+}
+
+// Columns of the trade tables of tax reports
+var (
+	taxTradeColumns = []column{
+		{"Closed", 0}, {"Symbol", 60}, {"Instrument", 180}, {"Quantity", 235}, {"Buy Value", 280}, {"Sell Value", 340},
+		{"P&L", 400}, {"Turnover", 460},
+	}
+	capitalGainColumns = []column{
+		{"Bought", 0}, {"Sold", 60}, {"Symbol", 120}, {"Term", 215}, {"Quantity", 250}, {"Cost", 300}, {"Sale", 370},
+		{"Gain", 440},
+	}
+)
+
+// csvAmount formats an amount with two decimals and no separators, for
+// spreadsheets to read as a number
+func csvAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
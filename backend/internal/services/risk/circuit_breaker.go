@@ -0,0 +1,574 @@
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// ErrCircuitBreakerTripped is returned for orders opening or adding to positions of
+// a user whose circuit breaker has tripped, until the cooling-off period ends
+var ErrCircuitBreakerTripped = errors.New("trading is paused, circuit breaker tripped")
+
+const (
+	// DefaultLossWindow is the rolling window losses are measured over
+	DefaultLossWindow = 15 * time.Minute
+
+	// DefaultMaxStopOuts is the number of consecutive stop-outs that trips the breaker
+	DefaultMaxStopOuts = 3
+
+	// DefaultCoolingOff is how long trading stays paused once the breaker trips
+	DefaultCoolingOff = 30 * time.Minute
+)
+
+// TripReason is why a circuit breaker tripped
+type TripReason string
+
+const (
+	TripReasonLossVelocity TripReason = "LOSS_VELOCITY"
+	TripReasonStopOuts     TripReason = "STOP_OUTS"
+)
+
+// NotificationType is the type of a notification sent to a user
+type NotificationType string
+
+const (
+	NotificationCircuitBreakerTripped NotificationType = "CIRCUIT_BREAKER_TRIPPED"
+	NotificationCircuitBreakerReset   NotificationType = "CIRCUIT_BREAKER_RESET"
+	NotificationRiskRuleTriggered     NotificationType = "RISK_RULE_TRIGGERED"
+	NotificationMarginUtilization     NotificationType = "MARGIN_UTILIZATION"
+	NotificationMarginDivergence      NotificationType = "MARGIN_DIVERGENCE"
+	NotificationStrategyHalted        NotificationType = "STRATEGY_HALTED"
+)
+
+// Notification is a message to a user about their risk controls
+type Notification struct {
+	UserID  string           `json:"userId"`
+	Type    NotificationType `json:"type"`
+	Message string           `json:"message"`
+	Time    time.Time        `json:"time"`
+}
+
+// Notifier sends notifications to users
+type Notifier interface {
+	Notify(notification Notification) error
+}
+
+// StrategyController pauses and resumes the strategies of a user
+type StrategyController interface {
+	GetStrategiesByUser(userID string) ([]models.Strategy, error)
+	PauseStrategy(strategyID string) error
+	ResumeStrategy(strategyID string) error
+}
+
+// CircuitBreakerConfig configures the circuit breaker
+type CircuitBreakerConfig struct {
+	Location    *time.Location // Time zone trading days start in, defaults to the local time zone
+	Interval    time.Duration  // Between runs, defaults to DefaultInterval
+	Window      time.Duration  // Rolling window losses are measured over, defaults to DefaultLossWindow
+	MaxStopOuts int            // Consecutive stop-outs that trip the breaker, defaults to DefaultMaxStopOuts
+	CoolingOff  time.Duration  // How long trading stays paused, defaults to DefaultCoolingOff
+}
+
+// BreakerStatus is the state of a user's circuit breaker
+type BreakerStatus struct {
+	UserID           string     `json:"userId"`
+	Threshold        float64    `json:"threshold"`  // Loss within the window that trips the breaker, 0 when the user has none
+	WindowLoss       float64    `json:"windowLoss"` // Loss from the highest P&L within the window
+	StopOuts         int        `json:"stopOuts"`   // Consecutive positions closed at a loss
+	MaxStopOuts      int        `json:"maxStopOuts"`
+	Tripped          bool       `json:"tripped"`
+	Reason           TripReason `json:"reason,omitempty"`
+	TrippedAt        *time.Time `json:"trippedAt,omitempty"`
+	CoolingOffUntil  *time.Time `json:"coolingOffUntil,omitempty"`
+	PausedStrategies []string   `json:"pausedStrategies,omitempty"` // Paused by the breaker, resumed once it resets
+	Errors           []string   `json:"errors,omitempty"`
+}
+
+// BreakerRunResult is the outcome of one run of the circuit breaker
+type BreakerRunResult struct {
+	Time   time.Time       `json:"time"`
+	Trips  []BreakerStatus `json:"trips"`  // Breakers tripped during the run
+	Resets []BreakerStatus `json:"resets"` // Breakers whose cooling-off period ended during the run
+	Errors []string        `json:"errors,omitempty"`
+}
+
+// pnlSample is a user's P&L at a point in time
+type pnlSample struct {
+	time time.Time
+	pnl  float64
+}
+
+// breakerState is what the circuit breaker tracks of a user
+type breakerState struct {
+	status  BreakerStatus
+	date    string      // Trading day the samples are for
+	samples []pnlSample // Within the window, oldest first
+	since   time.Time   // Stop-outs are counted from positions closed after
+}
+
+// CircuitBreaker implements the circuit breaker of users' preferences. A user's
+// breaker trips when their P&L of the day, as the daily loss monitor measures it,
+// falls by the preference or more within the rolling window, or when their last
+// positions were all closed at a loss. Tripping pauses the user's active
+// strategies and blocks their new entries for the cooling-off period, after which
+// the strategies are resumed. Users are notified of both.
+type CircuitBreaker struct {
+	positionService position.PositionService
+	closes          ClosePriceProvider
+	preferences     PreferencesProvider
+	strategies      StrategyController
+	notifier        Notifier
+	config          CircuitBreakerConfig
+	states          map[string]*breakerState // By user
+	stop            chan struct{}
+	mutex           sync.Mutex
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker. Without closes positions carried
+// over count their P&L since entry, without a strategy controller tripping only
+// blocks new entries, and without a notifier users are not notified.
+func NewCircuitBreaker(
+	positionService position.PositionService,
+	closes ClosePriceProvider,
+	preferences PreferencesProvider,
+	strategies StrategyController,
+	notifier Notifier,
+	config CircuitBreakerConfig,
+) *CircuitBreaker {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultLossWindow
+	}
+	if config.MaxStopOuts <= 0 {
+		config.MaxStopOuts = DefaultMaxStopOuts
+	}
+	if config.CoolingOff <= 0 {
+		config.CoolingOff = DefaultCoolingOff
+	}
+
+	return &CircuitBreaker{
+		positionService: positionService,
+		closes:          closes,
+		preferences:     preferences,
+		strategies:      strategies,
+		notifier:        notifier,
+		config:          config,
+		states:          make(map[string]*breakerState),
+	}
+}
+
+// Start runs the circuit breaker every interval until it is stopped
+func (b *CircuitBreaker) Start() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.stop != nil {
+		return errors.New("circuit breaker is already running")
+	}
+	b.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(b.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := b.Run(now)
+				if err != nil {
+					log.Printf("Error running circuit breaker: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Circuit breaker error: %s", message)
+				}
+			}
+		}
+	}(b.stop)
+
+	return nil
+}
+
+// Stop stops the circuit breaker
+func (b *CircuitBreaker) Stop() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.stop != nil {
+		close(b.stop)
+		b.stop = nil
+	}
+}
+
+// Run evaluates every user with open positions, and every user already tracked,
+// at now
+func (b *CircuitBreaker) Run(now time.Time) (*BreakerRunResult, error) {
+	result := &BreakerRunResult{
+		Time: now,
+	}
+
+	positions, err := openPositions(b.positionService, models.PositionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make(map[string]bool)
+	for _, openPosition := range positions {
+		userIDs[openPosition.UserID] = true
+	}
+	b.mutex.Lock()
+	for userID := range b.states {
+		userIDs[userID] = true
+	}
+	b.mutex.Unlock()
+
+	for userID := range userIDs {
+		status, tripped, reset, err := b.evaluate(userID, now)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("user %s: %v", userID, err))
+			continue
+		}
+		result.Errors = append(result.Errors, status.Errors...)
+		if tripped {
+			result.Trips = append(result.Trips, *status)
+		}
+		if reset {
+			result.Resets = append(result.Resets, *status)
+		}
+	}
+
+	return result, nil
+}
+
+// GetStatus evaluates a user's circuit breaker now
+func (b *CircuitBreaker) GetStatus(userID string) (*BreakerStatus, error) {
+	if userID == "" {
+		return nil, errors.New("user ID is required")
+	}
+
+	status, _, _, err := b.evaluate(userID, time.Now())
+	return status, err
+}
+
+// CheckOrder returns ErrCircuitBreakerTripped for an order of a user whose circuit
+// breaker has tripped, unless the order only reduces an open position. The user is
+// evaluated first, so a breaker is tripped by the order following the loss.
+func (b *CircuitBreaker) CheckOrder(order *models.Order) error {
+	if order == nil || order.UserID == "" {
+		return nil
+	}
+
+	status, _, _, err := b.evaluate(order.UserID, time.Now())
+	if err != nil {
+		// Fall back to the user's last evaluation, failing to evaluate the user
+		// not being a reason to stop trading
+		log.Printf("Error evaluating circuit breaker of user %s: %v", order.UserID, err)
+		b.mutex.Lock()
+		last := b.state(order.UserID).status
+		b.mutex.Unlock()
+		status = &last
+	}
+	if !status.Tripped {
+		return nil
+	}
+
+	reduces, err := reducesPosition(b.positionService, order)
+	if err != nil {
+		log.Printf("Error checking positions of user %s: %v", order.UserID, err)
+	}
+	if reduces {
+		return nil
+	}
+
+	return fmt.Errorf("%w: cooling off until %s", ErrCircuitBreakerTripped, status.CoolingOffUntil.Format(time.RFC3339))
+}
+
+// Reset ends a user's cooling-off period early, resuming the strategies the
+// breaker paused. Losses and stop-outs before the reset no longer count.
+func (b *CircuitBreaker) Reset(userID string, adminID string, reason string) (*BreakerStatus, error) {
+	if userID == "" {
+		return nil, errors.New("user ID is required")
+	}
+	if adminID == "" {
+		return nil, errors.New("admin ID is required")
+	}
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+
+	now := time.Now()
+
+	b.mutex.Lock()
+	state := b.state(userID)
+	if !state.status.Tripped {
+		b.mutex.Unlock()
+		return nil, errors.New("circuit breaker has not tripped")
+	}
+	paused := b.reset(state, now)
+	b.mutex.Unlock()
+
+	log.Printf("Circuit breaker of user %s reset by %s: %s", userID, adminID, reason)
+
+	errs := b.resumeStrategies(userID, paused)
+	errs = append(errs, b.notify(userID, NotificationCircuitBreakerReset, fmt.Sprintf("Trading resumed, circuit breaker reset by an admin: %s", reason), now)...)
+
+	status, _, _, err := b.evaluate(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	status.Errors = append(status.Errors, errs...)
+
+	return status, nil
+}
+
+// evaluate updates a user's breaker from the positions and preferences at now,
+// reporting whether it tripped or reset during this evaluation
+func (b *CircuitBreaker) evaluate(userID string, now time.Time) (*BreakerStatus, bool, bool, error) {
+	now = now.In(b.config.Location)
+
+	// A breaker whose cooling-off period is over is reset before anything else,
+	// so that the losses which tripped it do not trip it again
+	var errs []string
+	b.mutex.Lock()
+	state := b.state(userID)
+	reset := state.status.Tripped && !now.Before(*state.status.CoolingOffUntil)
+	var resumed []string
+	if reset {
+		resumed = b.reset(state, now)
+	}
+	b.mutex.Unlock()
+
+	if reset {
+		log.Printf("Circuit breaker of user %s reset after cooling off", userID)
+		errs = append(errs, b.resumeStrategies(userID, resumed)...)
+		errs = append(errs, b.notify(userID, NotificationCircuitBreakerReset, "Trading resumed, the circuit breaker's cooling-off period is over", now)...)
+	}
+
+	preferences, err := b.preferences.GetUserPreferences(userID)
+	if err != nil {
+		return nil, false, reset, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, b.config.Location)
+	open, closed, err := dayPositions(b.positionService, userID, startOfDay)
+	if err != nil {
+		return nil, false, reset, fmt.Errorf("failed to get positions: %w", err)
+	}
+	pnl := positionsPnL(b.closes, open, closed, startOfDay)
+
+	b.mutex.Lock()
+	state = b.state(userID)
+	date := now.Format(dateFormat)
+	if state.date != date {
+		// P&L is measured from the previous close, so P&L samples and stop-outs
+		// start over each trading day
+		state.date = date
+		state.samples = nil
+		if state.since.Before(startOfDay) {
+			state.since = startOfDay
+		}
+	}
+
+	state.samples = append(state.samples, pnlSample{time: now, pnl: pnl})
+	windowStart := now.Add(-b.config.Window)
+	for len(state.samples) > 1 && state.samples[0].time.Before(windowStart) {
+		state.samples = state.samples[1:]
+	}
+	high := pnl
+	for _, sample := range state.samples {
+		if sample.pnl > high {
+			high = sample.pnl
+		}
+	}
+
+	status := &state.status
+	status.Threshold = preferences.CircuitBreaker
+	status.WindowLoss = high - pnl
+	status.StopOuts = stopOuts(closed, state.since)
+	status.MaxStopOuts = b.config.MaxStopOuts
+	status.Errors = errs
+
+	var reason TripReason
+	if status.Threshold > 0 && !status.Tripped {
+		if status.WindowLoss >= status.Threshold {
+			reason = TripReasonLossVelocity
+		} else if status.StopOuts >= status.MaxStopOuts {
+			reason = TripReasonStopOuts
+		}
+	}
+	tripped := reason != ""
+	if tripped {
+		trippedAt := now
+		coolingOffUntil := now.Add(b.config.CoolingOff)
+		status.Tripped = true
+		status.Reason = reason
+		status.TrippedAt = &trippedAt
+		status.CoolingOffUntil = &coolingOffUntil
+	}
+	result := *status
+	b.mutex.Unlock()
+
+	if tripped {
+		var message string
+		if reason == TripReasonLossVelocity {
+			message = fmt.Sprintf("loss of %.2f within %s against a threshold of %.2f", result.WindowLoss, b.config.Window, result.Threshold)
+		} else {
+			message = fmt.Sprintf("%d consecutive stop-outs", result.StopOuts)
+		}
+		log.Printf("Circuit breaker of user %s tripped: %s", userID, message)
+
+		paused, pauseErrs := b.pauseStrategies(userID)
+		notifyErrs := b.notify(userID, NotificationCircuitBreakerTripped, fmt.Sprintf("Trading paused until %s, circuit breaker tripped by a %s", result.CoolingOffUntil.Format(time.RFC3339), message), now)
+
+		b.mutex.Lock()
+		status.PausedStrategies = paused
+		status.Errors = append(status.Errors, pauseErrs...)
+		status.Errors = append(status.Errors, notifyErrs...)
+		result = *status
+		b.mutex.Unlock()
+	}
+
+	return &result, tripped, reset, nil
+}
+
+// reset clears a tripped breaker at now, returning the strategies it paused. The
+// lock must be held.
+func (b *CircuitBreaker) reset(state *breakerState, now time.Time) []string {
+	paused := state.status.PausedStrategies
+
+	state.status.Tripped = false
+	state.status.Reason = ""
+	state.status.TrippedAt = nil
+	state.status.CoolingOffUntil = nil
+	state.status.PausedStrategies = nil
+	state.samples = nil
+	state.since = now
+
+	return paused
+}
+
+// state returns a user's state, starting one for a user not yet tracked. The lock
+// must be held.
+func (b *CircuitBreaker) state(userID string) *breakerState {
+	state, exists := b.states[userID]
+	if !exists {
+		state = &breakerState{
+			status: BreakerStatus{
+				UserID:      userID,
+				MaxStopOuts: b.config.MaxStopOuts,
+			},
+		}
+		b.states[userID] = state
+	}
+
+	return state
+}
+
+// pauseStrategies pauses a user's active strategies, returning those paused
+func (b *CircuitBreaker) pauseStrategies(userID string) ([]string, []string) {
+	if b.strategies == nil {
+		return nil, nil
+	}
+
+	strategies, err := b.strategies.GetStrategiesByUser(userID)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("strategies of user %s: %v", userID, err)}
+	}
+
+	var paused, errs []string
+	for _, strategy := range strategies {
+		if strategy.Status != models.StrategyStatusActive {
+			continue
+		}
+		if err := b.strategies.PauseStrategy(strategy.ID); err != nil {
+			errs = append(errs, fmt.Sprintf("pausing strategy %s: %v", strategy.ID, err))
+			continue
+		}
+		paused = append(paused, strategy.ID)
+	}
+
+	return paused, errs
+}
+
+// resumeStrategies resumes the strategies the breaker paused that are still paused
+func (b *CircuitBreaker) resumeStrategies(userID string, strategyIDs []string) []string {
+	if b.strategies == nil || len(strategyIDs) == 0 {
+		return nil
+	}
+
+	strategies, err := b.strategies.GetStrategiesByUser(userID)
+	if err != nil {
+		return []string{fmt.Sprintf("strategies of user %s: %v", userID, err)}
+	}
+	paused := make(map[string]bool)
+	for _, strategy := range strategies {
+		if strategy.Status == models.StrategyStatusPaused {
+			paused[strategy.ID] = true
+		}
+	}
+
+	var errs []string
+	for _, strategyID := range strategyIDs {
+		// Strategies stopped or resumed while cooling off are left alone
+		if !paused[strategyID] {
+			continue
+		}
+		if err := b.strategies.ResumeStrategy(strategyID); err != nil {
+			errs = append(errs, fmt.Sprintf("resuming strategy %s: %v", strategyID, err))
+		}
+	}
+
+	return errs
+}
+
+// notify sends a user a notification, returning any error as a message
+func (b *CircuitBreaker) notify(userID string, notificationType NotificationType, message string, now time.Time) []string {
+	if b.notifier == nil {
+		return nil
+	}
+
+	notification := Notification{
+		UserID:  userID,
+		Type:    notificationType,
+		Message: message,
+		Time:    now,
+	}
+	if err := b.notifier.Notify(notification); err != nil {
+		return []string{fmt.Sprintf("notifying user %s: %v", userID, err)}
+	}
+
+	return nil
+}
+
+// stopOuts returns the number of positions closed at a loss after since, counting
+// back from the last one closed until one closed without a loss
+func stopOuts(closed []models.Position, since time.Time) int {
+	var recent []models.Position
+	for _, closedPosition := range closed {
+		if closedPosition.UpdatedAt.After(since) {
+			recent = append(recent, closedPosition)
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool {
+		return recent[i].UpdatedAt.Before(recent[j].UpdatedAt)
+	})
+
+	count := 0
+	for i := len(recent) - 1; i >= 0 && recent[i].RealizedPnL < 0; i-- {
+		count++
+	}
+
+	return count
+}
@@ -0,0 +1,225 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockStrategyController is a mock implementation of the StrategyController interface
+type MockStrategyController struct {
+	mock.Mock
+}
+
+func (m *MockStrategyController) GetStrategiesByUser(userID string) ([]models.Strategy, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.Strategy), args.Error(1)
+}
+
+func (m *MockStrategyController) PauseStrategy(strategyID string) error {
+	args := m.Called(strategyID)
+	return args.Error(0)
+}
+
+func (m *MockStrategyController) ResumeStrategy(strategyID string) error {
+	args := m.Called(strategyID)
+	return args.Error(0)
+}
+
+// MockNotifier is a mock implementation of the Notifier interface
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(notification Notification) error {
+	args := m.Called(notification)
+	return args.Error(0)
+}
+
+// openPosition returns an open NIFTY position of user1 with the given unrealized P&L
+func openPosition(unrealizedPnL float64, createdAt time.Time) models.Position {
+	return models.Position{ID: "open1", UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.PositionDirectionLong, Quantity: 50, Status: models.PositionStatusOpen, UnrealizedPnL: unrealizedPnL, CreatedAt: createdAt}
+}
+
+// closedPosition returns a position of user closed at closedAt with the given P&L
+func closedPosition(id, userID string, realizedPnL float64, closedAt time.Time) models.Position {
+	return models.Position{ID: id, UserID: userID, Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.PositionDirectionShort, Quantity: 15, ExitQuantity: 15, Status: models.PositionStatusClosed, RealizedPnL: realizedPnL, CreatedAt: closedAt.Add(-time.Minute), UpdatedAt: closedAt}
+}
+
+func TestCircuitBreakerLossVelocity(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockPreferences := new(MockPreferencesProvider)
+	mockStrategies := new(MockStrategyController)
+	mockNotifier := new(MockNotifier)
+	breaker := NewCircuitBreaker(mockPositions, nil, mockPreferences, mockStrategies, mockNotifier, CircuitBreakerConfig{})
+
+	mockPreferences.On("GetUserPreferences", "user1").Return(&models.UserPreferences{UserID: "user1", CircuitBreaker: 5000}, nil)
+	mockStrategies.On("GetStrategiesByUser", "user1").Return([]models.Strategy{
+		{ID: "strategy1", UserID: "user1", Status: models.StrategyStatusActive},
+		{ID: "strategy2", UserID: "user1", Status: models.StrategyStatusStopped},
+	}, nil).Once()
+	mockStrategies.On("GetStrategiesByUser", "user1").Return([]models.Strategy{
+		{ID: "strategy1", UserID: "user1", Status: models.StrategyStatusPaused},
+		{ID: "strategy2", UserID: "user1", Status: models.StrategyStatusStopped},
+	}, nil)
+	mockStrategies.On("PauseStrategy", "strategy1").Return(nil)
+	mockStrategies.On("ResumeStrategy", "strategy1").Return(nil)
+	mockNotifier.On("Notify", mock.MatchedBy(func(notification Notification) bool {
+		return notification.UserID == "user1" && notification.Type == NotificationCircuitBreakerTripped
+	})).Return(nil).Once()
+	mockNotifier.On("Notify", mock.MatchedBy(func(notification Notification) bool {
+		return notification.UserID == "user1" && notification.Type == NotificationCircuitBreakerReset
+	})).Return(nil).Once()
+
+	entry := &models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50}
+	exit := &models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionSell, Quantity: 50}
+
+	// A loss of 3000 within the window is below the threshold
+	start := time.Now()
+	mockPositions.setPositions([]models.Position{openPosition(-1000, start)})
+	result, err := breaker.Run(start)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Trips)
+
+	mockPositions.setPositions([]models.Position{openPosition(-4000, start)})
+	result, err = breaker.Run(start.Add(5 * time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, result.Trips)
+
+	// A loss of 6000 trips the breaker, pausing the active strategies
+	mockPositions.setPositions([]models.Position{openPosition(-7000, start)})
+	result, err = breaker.Run(start.Add(10 * time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, result.Trips, 1)
+	assert.Equal(t, TripReasonLossVelocity, result.Trips[0].Reason)
+	assert.Equal(t, 6000.0, result.Trips[0].WindowLoss)
+	assert.Equal(t, []string{"strategy1"}, result.Trips[0].PausedStrategies)
+	assert.Equal(t, start.Add(40*time.Minute).Unix(), result.Trips[0].CoolingOffUntil.Unix())
+	mockStrategies.AssertNotCalled(t, "PauseStrategy", "strategy2")
+
+	// Entries are blocked while cooling off, exits are not
+	assert.True(t, errors.Is(breaker.CheckOrder(entry), ErrCircuitBreakerTripped))
+	assert.NoError(t, breaker.CheckOrder(exit))
+
+	result, err = breaker.Run(start.Add(20 * time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, result.Trips)
+	assert.Empty(t, result.Resets)
+
+	// Once the cooling-off period is over the strategies are resumed, and the
+	// loss that tripped the breaker does not trip it again
+	result, err = breaker.Run(start.Add(41 * time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, result.Trips)
+	assert.Len(t, result.Resets, 1)
+	assert.False(t, result.Resets[0].Tripped)
+	assert.Equal(t, 0.0, result.Resets[0].WindowLoss)
+
+	mockStrategies.AssertNumberOfCalls(t, "PauseStrategy", 1)
+	mockStrategies.AssertNumberOfCalls(t, "ResumeStrategy", 1)
+	mockNotifier.AssertExpectations(t)
+}
+
+func TestCircuitBreakerStopOuts(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockPreferences := new(MockPreferencesProvider)
+	mockNotifier := new(MockNotifier)
+	breaker := NewCircuitBreaker(mockPositions, nil, mockPreferences, nil, mockNotifier, CircuitBreakerConfig{})
+
+	mockPreferences.On("GetUserPreferences", "user1").Return(&models.UserPreferences{UserID: "user1", CircuitBreaker: 100000}, nil)
+	mockPreferences.On("GetUserPreferences", "user2").Return(&models.UserPreferences{UserID: "user2"}, nil)
+	mockNotifier.On("Notify", mock.Anything).Return(errors.New("notification channel unavailable"))
+
+	now := time.Now()
+	entry := &models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50}
+
+	// A win breaks a run of losses
+	mockPositions.setPositions([]models.Position{
+		closedPosition("closed1", "user1", -500, now.Add(-4*time.Minute)),
+		closedPosition("closed2", "user1", -500, now.Add(-3*time.Minute)),
+		closedPosition("closed3", "user1", 200, now.Add(-2*time.Minute)),
+		closedPosition("closed4", "user1", -500, now.Add(-time.Minute)),
+	})
+	status, err := breaker.GetStatus("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, status.StopOuts)
+	assert.False(t, status.Tripped)
+
+	// Three consecutive stop-outs trip the breaker of a user who has one
+	mockPositions.setPositions([]models.Position{
+		closedPosition("closed1", "user1", 200, now.Add(-4*time.Minute)),
+		closedPosition("closed2", "user1", -500, now.Add(-3*time.Minute)),
+		closedPosition("closed3", "user1", -500, now.Add(-2*time.Minute)),
+		closedPosition("closed4", "user1", -500, now.Add(-time.Minute)),
+		closedPosition("closed5", "user2", -500, now.Add(-3*time.Minute)),
+		closedPosition("closed6", "user2", -500, now.Add(-2*time.Minute)),
+		closedPosition("closed7", "user2", -500, now.Add(-time.Minute)),
+	})
+	err = breaker.CheckOrder(entry)
+	assert.True(t, errors.Is(err, ErrCircuitBreakerTripped))
+
+	status, err = breaker.GetStatus("user1")
+	assert.NoError(t, err)
+	assert.True(t, status.Tripped)
+	assert.Equal(t, TripReasonStopOuts, status.Reason)
+	assert.Equal(t, 3, status.StopOuts)
+
+	status, err = breaker.GetStatus("user2")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, status.StopOuts)
+	assert.False(t, status.Tripped)
+
+	// An admin reset ends the cooling-off period, the earlier stop-outs no longer counting
+	_, err = breaker.Reset("user1", "admin1", "")
+	assert.Error(t, err)
+	status, err = breaker.Reset("user1", "admin1", "strategy reviewed")
+	assert.NoError(t, err)
+	assert.False(t, status.Tripped)
+	assert.Equal(t, 0, status.StopOuts)
+	assert.NotEmpty(t, status.Errors)
+	assert.NoError(t, breaker.CheckOrder(entry))
+
+	_, err = breaker.Reset("user1", "admin1", "strategy reviewed")
+	assert.Error(t, err)
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 2)
+}
+
+func TestCircuitBreakerOvernightProfit(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockCloses := new(MockClosePriceProvider)
+	mockPreferences := new(MockPreferencesProvider)
+	mockStrategies := new(MockStrategyController)
+	breaker := NewCircuitBreaker(mockPositions, mockCloses, mockPreferences, mockStrategies, nil, CircuitBreakerConfig{})
+
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	mockPreferences.On("GetUserPreferences", "user1").Return(&models.UserPreferences{UserID: "user1", CircuitBreaker: 1000}, nil)
+	mockCloses.On("GetPreviousClose", "NIFTY", "NFO", startOfDay).Return(120.0, nil)
+
+	// Bought at 100 yesterday, 1500 up on the previous close of 120 at 150
+	held := models.Position{ID: "overnight", UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.PositionDirectionLong, EntryPrice: 100, Quantity: 50, Status: models.PositionStatusOpen, UnrealizedPnL: 2500, CreatedAt: startOfDay.Add(-12 * time.Hour)}
+	mockPositions.setPositions([]models.Position{held})
+	status, err := breaker.GetStatus("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, status.WindowLoss)
+
+	// Booking the profit at 150 leaves the day's P&L where it was
+	booked := held
+	booked.Status = models.PositionStatusClosed
+	booked.ExitPrice = 150
+	booked.ExitQuantity = 50
+	booked.UnrealizedPnL = 0
+	booked.RealizedPnL = 2500
+	booked.UpdatedAt = now
+	mockPositions.setPositions([]models.Position{booked})
+	status, err = breaker.GetStatus("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, status.WindowLoss)
+	assert.Equal(t, 0, status.StopOuts)
+	assert.False(t, status.Tripped)
+	mockStrategies.AssertNotCalled(t, "GetStrategiesByUser", "user1")
+}
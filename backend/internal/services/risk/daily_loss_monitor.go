@@ -0,0 +1,363 @@
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/killswitch"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// ErrEntriesBlocked is returned for orders opening or adding to positions of a user
+// whose max daily loss has been reached
+var ErrEntriesBlocked = errors.New("new entries are blocked, max daily loss reached")
+
+// DefaultInterval is how often the monitor re-evaluates users with open positions
+const DefaultInterval = 5 * time.Second
+
+// monitorActor is recorded as the trigger of the kill switches the monitor pulls
+const monitorActor = "daily-loss-monitor"
+
+// dateFormat is the format of the trading day a status is for
+const dateFormat = "2006-01-02"
+
+// PreferencesProvider looks up the preferences of a user
+type PreferencesProvider interface {
+	GetUserPreferences(userID string) (*models.UserPreferences, error)
+}
+
+// Config configures the daily loss monitor
+type Config struct {
+	Location *time.Location // Time zone trading days start in, defaults to the local time zone
+	Interval time.Duration  // Between runs, defaults to DefaultInterval
+}
+
+// Override is an admin's decision on a user's max daily loss for the rest of the
+// trading day. An override with a limit replaces the user's limit, one without
+// lifts it. Either unblocks a user who reached the limit.
+type Override struct {
+	AdminID   string    `json:"adminId"`
+	Limit     float64   `json:"limit,omitempty"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LossStatus is where a user stands against the max daily loss on a trading day
+type LossStatus struct {
+	UserID         string     `json:"userId"`
+	Date           string     `json:"date"`
+	PnL            float64    `json:"pnl"`   // Realized and unrealized P&L of the day
+	Limit          float64    `json:"limit"` // 0 when the user has no limit
+	Breached       bool       `json:"breached"`
+	BreachedAt     *time.Time `json:"breachedAt,omitempty"`
+	EntriesBlocked bool       `json:"entriesBlocked"`
+	SquaredOff     bool       `json:"squaredOff"` // Positions were squared off on the breach
+	Override       *Override  `json:"override,omitempty"`
+	Errors         []string   `json:"errors,omitempty"`
+}
+
+// RunResult is the outcome of one run of the monitor
+type RunResult struct {
+	Time     time.Time    `json:"time"`
+	Breaches []LossStatus `json:"breaches"` // Users who reached their limit during the run
+	Errors   []string     `json:"errors,omitempty"`
+}
+
+// DailyLossMonitor enforces the max daily loss of users' preferences. A user's
+// intraday P&L is the realized and unrealized P&L of the positions open or
+// closed that day, those carried over from the previous day counting from its
+// close. Once the loss reaches the limit new entries are blocked for the rest of
+// the day, and the user's orders are cancelled and positions squared off with the
+// kill switch when the user has auto square-off on. Admins can override a breach
+// by raising the day's limit or lifting the block.
+type DailyLossMonitor struct {
+	positionService position.PositionService
+	closes          ClosePriceProvider
+	preferences     PreferencesProvider
+	killSwitch      killswitch.KillSwitchService
+	config          Config
+	statuses        map[string]*LossStatus // By user, for the current trading day
+	stop            chan struct{}
+	mutex           sync.Mutex
+}
+
+// NewDailyLossMonitor creates a new DailyLossMonitor. Without closes positions
+// carried over count their P&L since entry, and without a kill switch breaches
+// only block new entries.
+func NewDailyLossMonitor(
+	positionService position.PositionService,
+	closes ClosePriceProvider,
+	preferences PreferencesProvider,
+	killSwitch killswitch.KillSwitchService,
+	config Config,
+) *DailyLossMonitor {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+
+	return &DailyLossMonitor{
+		positionService: positionService,
+		closes:          closes,
+		preferences:     preferences,
+		killSwitch:      killSwitch,
+		config:          config,
+		statuses:        make(map[string]*LossStatus),
+	}
+}
+
+// Start runs the monitor every interval until it is stopped
+func (m *DailyLossMonitor) Start() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		return errors.New("daily loss monitor is already running")
+	}
+	m.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := m.Run(now)
+				if err != nil {
+					log.Printf("Error running daily loss monitor: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Daily loss monitor error: %s", message)
+				}
+			}
+		}
+	}(m.stop)
+
+	return nil
+}
+
+// Stop stops the monitor
+func (m *DailyLossMonitor) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+// Run evaluates every user with open positions at now
+func (m *DailyLossMonitor) Run(now time.Time) (*RunResult, error) {
+	result := &RunResult{
+		Time: now,
+	}
+
+	userIDs := make(map[string]bool)
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial} {
+		positions, err := listPositions(m.positionService, models.PositionFilter{Status: status})
+		if err != nil {
+			return nil, err
+		}
+		for _, openPosition := range positions {
+			userIDs[openPosition.UserID] = true
+		}
+	}
+
+	for userID := range userIDs {
+		status, breached, err := m.evaluate(userID, now)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("user %s: %v", userID, err))
+			continue
+		}
+		result.Errors = append(result.Errors, status.Errors...)
+		if breached {
+			result.Breaches = append(result.Breaches, *status)
+		}
+	}
+
+	return result, nil
+}
+
+// GetStatus evaluates a user against the max daily loss now
+func (m *DailyLossMonitor) GetStatus(userID string) (*LossStatus, error) {
+	if userID == "" {
+		return nil, errors.New("user ID is required")
+	}
+
+	status, _, err := m.evaluate(userID, time.Now())
+	return status, err
+}
+
+// CheckOrder returns ErrEntriesBlocked for an order of a user whose max daily loss
+// has been reached, unless the order only reduces an open position. The user is
+// evaluated first, so a breach is caught by the order that would go past it.
+func (m *DailyLossMonitor) CheckOrder(order *models.Order) error {
+	if order == nil || order.UserID == "" {
+		return nil
+	}
+
+	now := time.Now()
+	status, _, err := m.evaluate(order.UserID, now)
+	if err != nil {
+		// Fall back to the user's last evaluation, failing to evaluate the user
+		// not being a reason to stop trading
+		log.Printf("Error evaluating daily loss of user %s: %v", order.UserID, err)
+		m.mutex.Lock()
+		last := *m.status(order.UserID, now)
+		m.mutex.Unlock()
+		status = &last
+	}
+	if !status.EntriesBlocked {
+		return nil
+	}
+
+	reduces, err := reducesPosition(m.positionService, order)
+	if err != nil {
+		log.Printf("Error checking positions of user %s: %v", order.UserID, err)
+	}
+	if reduces {
+		return nil
+	}
+
+	return fmt.Errorf("%w: loss of %.2f against a limit of %.2f", ErrEntriesBlocked, -status.PnL, status.Limit)
+}
+
+// Override records an admin's override of a user's max daily loss for the rest of
+// the trading day
+func (m *DailyLossMonitor) Override(userID string, override Override) (*LossStatus, error) {
+	if userID == "" {
+		return nil, errors.New("user ID is required")
+	}
+	if override.AdminID == "" {
+		return nil, errors.New("admin ID is required")
+	}
+	if override.Reason == "" {
+		return nil, errors.New("reason is required")
+	}
+	if override.Limit < 0 {
+		return nil, errors.New("limit cannot be negative")
+	}
+
+	now := time.Now()
+	override.CreatedAt = now
+
+	m.mutex.Lock()
+	status := m.status(userID, now)
+	status.Override = &override
+	status.Breached = false
+	status.BreachedAt = nil
+	m.mutex.Unlock()
+
+	log.Printf("Max daily loss of user %s overridden by %s with limit %.2f: %s", userID, override.AdminID, override.Limit, override.Reason)
+
+	status, _, err := m.evaluate(userID, now)
+	return status, err
+}
+
+// evaluate updates a user's status from the positions and preferences at now,
+// reporting whether the limit was reached by this evaluation. A breach is acted
+// on once a day.
+func (m *DailyLossMonitor) evaluate(userID string, now time.Time) (*LossStatus, bool, error) {
+	now = now.In(m.config.Location)
+
+	preferences, err := m.preferences.GetUserPreferences(userID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	pnl, err := m.dayPnL(userID, now)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to calculate P&L: %w", err)
+	}
+
+	m.mutex.Lock()
+	status := m.status(userID, now)
+	status.PnL = pnl
+	status.Limit = preferences.MaxDailyLoss
+	status.Errors = nil
+	if status.Override != nil {
+		status.Limit = status.Override.Limit
+	}
+
+	reached := status.Limit > 0 && -pnl >= status.Limit
+	breached := reached && !status.Breached
+	if breached {
+		breachedAt := now
+		status.Breached = true
+		status.BreachedAt = &breachedAt
+	}
+	status.EntriesBlocked = status.Breached
+	squareOff := breached && preferences.AutoSquareOff && m.killSwitch != nil
+	if squareOff {
+		status.SquaredOff = true
+	}
+	m.mutex.Unlock()
+
+	if breached {
+		log.Printf("User %s reached the max daily loss: loss of %.2f against a limit of %.2f", userID, -pnl, status.Limit)
+	}
+
+	if squareOff {
+		request := killswitch.KillRequest{
+			Scope:            killswitch.ScopeUser,
+			UserID:           userID,
+			FlattenPositions: true,
+			Reason:           fmt.Sprintf("max daily loss of %.2f reached with a loss of %.2f", status.Limit, -pnl),
+		}
+		killResult, err := m.killSwitch.Kill(request, monitorActor)
+		m.mutex.Lock()
+		if err != nil {
+			status.Errors = append(status.Errors, fmt.Sprintf("square-off of user %s: %v", userID, err))
+		} else {
+			status.Errors = append(status.Errors, killResult.Errors...)
+		}
+		m.mutex.Unlock()
+	}
+
+	m.mutex.Lock()
+	result := *status
+	m.mutex.Unlock()
+
+	return &result, breached, nil
+}
+
+// status returns a user's status for the trading day of now, starting a new one on
+// a new day. The lock must be held.
+func (m *DailyLossMonitor) status(userID string, now time.Time) *LossStatus {
+	date := now.In(m.config.Location).Format(dateFormat)
+
+	status, exists := m.statuses[userID]
+	if !exists || status.Date != date {
+		status = &LossStatus{
+			UserID: userID,
+			Date:   date,
+		}
+		m.statuses[userID] = status
+	}
+
+	return status
+}
+
+// dayPnL returns a user's P&L on the trading day of now: the realized and
+// unrealized P&L of open positions, and the realized P&L of positions closed that
+// day, from the previous close for those opened before it
+func (m *DailyLossMonitor) dayPnL(userID string, now time.Time) (float64, error) {
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, m.config.Location)
+
+	open, closed, err := dayPositions(m.positionService, userID, startOfDay)
+	if err != nil {
+		return 0, err
+	}
+
+	return positionsPnL(m.closes, open, closed, startOfDay), nil
+}
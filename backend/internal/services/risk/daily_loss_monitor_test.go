@@ -0,0 +1,344 @@
+package risk
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/killswitch"
+)
+
+// MockOrderService is a mock implementation of the OrderService interface
+type MockOrderService struct {
+	mock.Mock
+}
+
+func (m *MockOrderService) CreateOrder(order *models.Order) (*models.Order, error) {
+	args := m.Called(order)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderByID(id string) (*models.Order, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error) {
+	args := m.Called(filter, page, limit)
+	return args.Get(0).([]models.Order), args.Int(1), args.Error(2)
+}
+
+func (m *MockOrderService) UpdateOrder(order *models.Order) (*models.Order, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) CancelOrder(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockOrderService) TransitionOrder(id string, status models.OrderStatus, filledQuantity int, source models.TransitionSource, reason string) (*models.Order, error) {
+	args := m.Called(id, status, filledQuantity, source, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderTimeline(id string) ([]models.OrderTransition, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OrderTransition), args.Error(1)
+}
+
+// MockPositionService is a mock implementation of the PositionService interface.
+// GetPositions filters the positions it is given like the repository does.
+type MockPositionService struct {
+	mock.Mock
+	positions []models.Position
+	mutex     sync.Mutex
+}
+
+func (m *MockPositionService) setPositions(positions []models.Position) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.positions = positions
+}
+
+func (m *MockPositionService) CreatePositionFromOrder(order *models.Order) (*models.Position, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositionByID(id string) (*models.Position, error) {
+	args := m.Called(id)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var matched []models.Position
+	for _, p := range m.positions {
+		if (filter.UserID != "" && p.UserID != filter.UserID) ||
+			(filter.Symbol != "" && p.Symbol != filter.Symbol) ||
+			(filter.StrategyID != "" && p.StrategyID != filter.StrategyID) ||
+			(filter.Status != "" && p.Status != filter.Status) ||
+			(!filter.FromDate.IsZero() && p.CreatedAt.Before(filter.FromDate)) ||
+			(!filter.ClosedFrom.IsZero() && p.UpdatedAt.Before(filter.ClosedFrom)) {
+			continue
+		}
+		matched = append(matched, p)
+	}
+	return matched, len(matched), nil
+}
+
+func (m *MockPositionService) UpdatePosition(position *models.Position) (*models.Position, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) ClosePosition(id string, exitPrice float64, exitQuantity int) (*models.Position, error) {
+	args := m.Called(id, exitPrice, exitQuantity)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) CalculatePnL(position *models.Position) (float64, error) {
+	args := m.Called(position)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateGreeks(position *models.Position) (*models.Greeks, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Greeks), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateExposure(positions []models.Position) (float64, error) {
+	args := m.Called(positions)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) AggregatePositions(positions []models.Position, groupBy string) (map[string]models.AggregatedPosition, error) {
+	args := m.Called(positions, groupBy)
+	return args.Get(0).(map[string]models.AggregatedPosition), args.Error(1)
+}
+
+// MockPreferencesProvider is a mock implementation of the PreferencesProvider interface
+type MockPreferencesProvider struct {
+	mock.Mock
+}
+
+func (m *MockPreferencesProvider) GetUserPreferences(userID string) (*models.UserPreferences, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserPreferences), args.Error(1)
+}
+
+// MockClosePriceProvider is a mock implementation of the ClosePriceProvider interface
+type MockClosePriceProvider struct {
+	mock.Mock
+}
+
+func (m *MockClosePriceProvider) GetPreviousClose(symbol, exchange string, startOfDay time.Time) (float64, error) {
+	args := m.Called(symbol, exchange, startOfDay)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+// MockKillSwitchService is a mock implementation of the KillSwitchService interface
+type MockKillSwitchService struct {
+	mock.Mock
+}
+
+func (m *MockKillSwitchService) RequestKill(request killswitch.KillRequest, requestedBy string) (*killswitch.Confirmation, error) {
+	args := m.Called(request, requestedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*killswitch.Confirmation), args.Error(1)
+}
+
+func (m *MockKillSwitchService) ConfirmKill(token string, confirmedBy string) (*killswitch.KillResult, error) {
+	args := m.Called(token, confirmedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*killswitch.KillResult), args.Error(1)
+}
+
+func (m *MockKillSwitchService) Kill(request killswitch.KillRequest, triggeredBy string) (*killswitch.KillResult, error) {
+	args := m.Called(request, triggeredBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*killswitch.KillResult), args.Error(1)
+}
+
+func (m *MockKillSwitchService) GetAuditLog(userID string) ([]killswitch.AuditEntry, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]killswitch.AuditEntry), args.Error(1)
+}
+
+// userPositions returns an open NIFTY position with the given unrealized P&L, a
+// position closed today at a loss of 3000 and one closed yesterday at a loss of 5000
+func userPositions(unrealizedPnL float64) []models.Position {
+	now := time.Now()
+	return []models.Position{
+		{ID: "open1", UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.PositionDirectionLong, Quantity: 50, Status: models.PositionStatusOpen, UnrealizedPnL: unrealizedPnL, CreatedAt: now},
+		{ID: "closed1", UserID: "user1", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.PositionDirectionShort, Quantity: 15, ExitQuantity: 15, Status: models.PositionStatusClosed, RealizedPnL: -3000, CreatedAt: now, UpdatedAt: now},
+		{ID: "closed0", UserID: "user1", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.PositionDirectionShort, Quantity: 15, ExitQuantity: 15, Status: models.PositionStatusClosed, RealizedPnL: -5000, CreatedAt: now.AddDate(0, 0, -1), UpdatedAt: now.AddDate(0, 0, -1)},
+	}
+}
+
+func TestDailyLossMonitor(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockPreferences := new(MockPreferencesProvider)
+	mockKillSwitch := new(MockKillSwitchService)
+	monitor := NewDailyLossMonitor(mockPositions, nil, mockPreferences, mockKillSwitch, Config{})
+
+	mockPreferences.On("GetUserPreferences", "user1").Return(&models.UserPreferences{UserID: "user1", MaxDailyLoss: 10000, AutoSquareOff: true}, nil)
+	mockKillSwitch.On("Kill", mock.MatchedBy(func(request killswitch.KillRequest) bool {
+		return request.Scope == killswitch.ScopeUser && request.UserID == "user1" && request.FlattenPositions
+	}), monitorActor).Return(&killswitch.KillResult{}, nil)
+
+	entry := &models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50}
+	exit := &models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionSell, Quantity: 50}
+	reversal := &models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionSell, Quantity: 100}
+
+	// A loss of 9000 today is within the limit, yesterday's loss not counting
+	mockPositions.setPositions(userPositions(-6000))
+	status, err := monitor.GetStatus("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, -9000.0, status.PnL)
+	assert.Equal(t, 10000.0, status.Limit)
+	assert.False(t, status.EntriesBlocked)
+	assert.NoError(t, monitor.CheckOrder(entry))
+
+	// Reaching the limit blocks entries and squares off once
+	mockPositions.setPositions(userPositions(-7500))
+	result, err := monitor.Run(time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, result.Breaches, 1)
+	assert.True(t, result.Breaches[0].SquaredOff)
+	assert.True(t, result.Breaches[0].EntriesBlocked)
+
+	err = monitor.CheckOrder(entry)
+	assert.True(t, errors.Is(err, ErrEntriesBlocked))
+	assert.NoError(t, monitor.CheckOrder(exit))
+	assert.True(t, errors.Is(monitor.CheckOrder(reversal), ErrEntriesBlocked))
+
+	result, err = monitor.Run(time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, result.Breaches)
+	mockKillSwitch.AssertNumberOfCalls(t, "Kill", 1)
+
+	// An admin raising the limit unblocks the user until the new limit is reached
+	_, err = monitor.Override("user1", Override{AdminID: "admin1", Limit: 15000})
+	assert.Error(t, err)
+	status, err = monitor.Override("user1", Override{AdminID: "admin1", Limit: 15000, Reason: "hedged book"})
+	assert.NoError(t, err)
+	assert.False(t, status.EntriesBlocked)
+	assert.Equal(t, 15000.0, status.Limit)
+	assert.NoError(t, monitor.CheckOrder(entry))
+
+	mockPositions.setPositions(userPositions(-13000))
+	assert.True(t, errors.Is(monitor.CheckOrder(entry), ErrEntriesBlocked))
+	mockKillSwitch.AssertNumberOfCalls(t, "Kill", 2)
+
+	// Lifting the block leaves the user without a limit for the day
+	status, err = monitor.Override("user1", Override{AdminID: "admin1", Reason: "client instruction"})
+	assert.NoError(t, err)
+	assert.False(t, status.EntriesBlocked)
+	assert.Equal(t, 0.0, status.Limit)
+	assert.Equal(t, "admin1", status.Override.AdminID)
+	assert.NoError(t, monitor.CheckOrder(entry))
+	mockKillSwitch.AssertNumberOfCalls(t, "Kill", 2)
+}
+
+func TestDailyLossMonitorOvernightPositions(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockCloses := new(MockClosePriceProvider)
+	mockPreferences := new(MockPreferencesProvider)
+	monitor := NewDailyLossMonitor(mockPositions, mockCloses, mockPreferences, nil, Config{})
+
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	yesterday := startOfDay.Add(-12 * time.Hour)
+	mockPreferences.On("GetUserPreferences", "user1").Return(&models.UserPreferences{UserID: "user1", MaxDailyLoss: 2000}, nil)
+	mockCloses.On("GetPreviousClose", "NIFTY", "NFO", startOfDay).Return(120.0, nil)
+	mockCloses.On("GetPreviousClose", "BANKNIFTY", "NFO", startOfDay).Return(0.0, errors.New("no close"))
+
+	// Bought at 100 yesterday and closed at 90 today, a loss of 30 a lot from the
+	// previous close of 120 but of only 10 overall
+	overnight := models.Position{ID: "overnight", UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.PositionDirectionLong, EntryPrice: 100, ExitPrice: 90, Quantity: 50, ExitQuantity: 50, Status: models.PositionStatusClosed, RealizedPnL: -500, CreatedAt: yesterday, UpdatedAt: now}
+	mockPositions.setPositions([]models.Position{overnight})
+	status, err := monitor.GetStatus("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, -1500.0, status.PnL)
+	assert.False(t, status.Breached)
+
+	// Open positions carried over count today's move, those without a previous
+	// close their P&L since entry
+	held := models.Position{ID: "held", UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.PositionDirectionLong, EntryPrice: 100, Quantity: 50, Status: models.PositionStatusOpen, UnrealizedPnL: 500, CreatedAt: yesterday}
+	unquoted := models.Position{ID: "unquoted", UserID: "user1", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.PositionDirectionShort, EntryPrice: 300, Quantity: 15, Status: models.PositionStatusOpen, UnrealizedPnL: 100, CreatedAt: yesterday}
+	mockPositions.setPositions([]models.Position{overnight, held, unquoted})
+	status, err = monitor.GetStatus("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, -1900.0, status.PnL)
+	assert.False(t, status.Breached)
+
+	// The loss on the overnight position trips the limit with the day's others
+	today := models.Position{ID: "today", UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.PositionDirectionLong, EntryPrice: 110, ExitPrice: 108, Quantity: 50, ExitQuantity: 50, Status: models.PositionStatusClosed, RealizedPnL: -100, CreatedAt: now, UpdatedAt: now}
+	mockPositions.setPositions([]models.Position{overnight, held, unquoted, today})
+	status, err = monitor.GetStatus("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, -2000.0, status.PnL)
+	assert.True(t, status.Breached)
+}
+
+func TestGuardedOrderService(t *testing.T) {
+	mockOrders := new(MockOrderService)
+	mockPositions := new(MockPositionService)
+	mockPreferences := new(MockPreferencesProvider)
+	monitor := NewDailyLossMonitor(mockPositions, nil, mockPreferences, nil, Config{})
+	service := NewGuardedOrderService(mockOrders, monitor)
+
+	mockPreferences.On("GetUserPreferences", "user1").Return(&models.UserPreferences{UserID: "user1", MaxDailyLoss: 5000}, nil)
+	mockPreferences.On("GetUserPreferences", "user2").Return(nil, errors.New("preferences not found"))
+	mockPositions.setPositions(userPositions(-4000))
+
+	// Orders of a user within the limit, or whose preferences are unavailable, go through
+	allowed := &models.Order{UserID: "user2", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50}
+	mockOrders.On("CreateOrder", allowed).Return(allowed, nil)
+	_, err := service.CreateOrder(allowed)
+	assert.NoError(t, err)
+
+	// Entries of a user past the limit never reach the order service, without a
+	// kill switch nothing is squared off
+	blocked := &models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50}
+	_, err = service.CreateOrder(blocked)
+	assert.True(t, errors.Is(err, ErrEntriesBlocked))
+
+	status, err := monitor.GetStatus("user1")
+	assert.NoError(t, err)
+	assert.True(t, status.Breached)
+	assert.False(t, status.SquaredOff)
+
+	mockOrders.AssertNotCalled(t, "CreateOrder", blocked)
+	mockOrders.AssertExpectations(t)
+}
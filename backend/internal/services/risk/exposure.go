@@ -0,0 +1,227 @@
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/trading-platform/backend/internal/broker/common"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// ErrAccountNotFound is returned for broker accounts a user has not connected
+var ErrAccountNotFound = errors.New("broker account not found")
+
+// AccountProvider retrieves the broker accounts users have connected
+type AccountProvider interface {
+	GetUserApiKeys(userID string) ([]models.UserApiKey, error)
+}
+
+// BrokerAccountClient queries the positions and margin of a user's broker account
+type BrokerAccountClient interface {
+	GetAccountPositions(userID, accountID string) ([]common.Position, error)
+	GetAccountMargin(userID, accountID string) (*common.AccountMargin, error)
+}
+
+// Exposure is the exposure, margin and risk of a set of open positions
+type Exposure struct {
+	GrossExposure     float64 `json:"grossExposure"` // Value of the long and short positions together
+	NetExposure       float64 `json:"netExposure"`   // Value of the long positions less the short
+	LongExposure      float64 `json:"longExposure"`
+	ShortExposure     float64 `json:"shortExposure"`
+	UnrealizedPnL     float64 `json:"unrealizedPnL"`
+	OpenRisk          float64 `json:"openRisk"` // Unrealized losses of the losing positions
+	OpenPositions     int     `json:"openPositions"`
+	Funds             float64 `json:"funds"` // Margin available and utilized
+	MarginUtilized    float64 `json:"marginUtilized"`
+	MarginUtilization float64 `json:"marginUtilization"` // Percentage of the funds utilized
+	Leverage          float64 `json:"leverage"`          // Gross exposure over the funds
+}
+
+// AccountExposure is the exposure of one of a user's broker accounts
+type AccountExposure struct {
+	AccountID string `json:"accountId"`
+	Name      string `json:"name"`
+	Broker    string `json:"broker"`
+	Exposure
+	Error string `json:"error,omitempty"` // Why the broker could not be queried
+}
+
+// UserExposure is the exposure of a user across their broker accounts
+type UserExposure struct {
+	UserID string `json:"userId"`
+	Exposure
+	Accounts []AccountExposure `json:"accounts"`
+	Time     time.Time         `json:"time"`
+}
+
+// ExposureReporter reports the live exposure of users and of their broker
+// accounts. A user's exposure is that of their open positions, valued at the last
+// price, with the margin of their active broker accounts. An account's exposure is
+// that of the positions and margin its broker reports.
+type ExposureReporter struct {
+	positionService position.PositionService
+	accounts        AccountProvider
+	broker          BrokerAccountClient
+	quotes          QuoteProvider
+}
+
+// NewExposureReporter creates a new ExposureReporter. Quotes are optional, positions
+// being valued at their entry price without them.
+func NewExposureReporter(
+	positionService position.PositionService,
+	accounts AccountProvider,
+	broker BrokerAccountClient,
+	quotes QuoteProvider,
+) *ExposureReporter {
+	return &ExposureReporter{
+		positionService: positionService,
+		accounts:        accounts,
+		broker:          broker,
+		quotes:          quotes,
+	}
+}
+
+// GetUserExposure returns a user's exposure and that of each of their active broker
+// accounts. Accounts whose broker cannot be queried are reported with the error and
+// left out of the user's margin.
+func (r *ExposureReporter) GetUserExposure(userID string) (*UserExposure, error) {
+	if userID == "" {
+		return nil, errors.New("user ID is required")
+	}
+
+	positions, err := openPositions(r.positionService, models.PositionFilter{UserID: userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions of user %s: %w", userID, err)
+	}
+
+	keys, err := r.accounts.GetUserApiKeys(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get broker accounts of user %s: %w", userID, err)
+	}
+
+	result := &UserExposure{
+		UserID:   userID,
+		Accounts: []AccountExposure{},
+		Time:     time.Now(),
+	}
+	for _, openPosition := range positions {
+		price := openPosition.EntryPrice
+		pnl := openPosition.UnrealizedPnL
+		if r.quotes != nil {
+			if lastPrice, err := r.quotes.GetLastPrice(openPosition.Symbol, openPosition.Exchange); err == nil && lastPrice > 0 {
+				price = lastPrice
+				pnl = (lastPrice - openPosition.EntryPrice) * float64(openPosition.RemainingQuantity())
+				if openPosition.Direction == models.PositionDirectionShort {
+					pnl = -pnl
+				}
+			}
+		}
+
+		quantity := openPosition.RemainingQuantity()
+		if openPosition.Direction == models.PositionDirectionShort {
+			quantity = -quantity
+		}
+		result.add(quantity, price, pnl)
+	}
+
+	for _, key := range keys {
+		if !key.IsActive {
+			continue
+		}
+		account := r.accountExposure(userID, key)
+		if account.Error == "" {
+			result.Funds += account.Funds
+			result.MarginUtilized += account.MarginUtilized
+		}
+		result.Accounts = append(result.Accounts, *account)
+	}
+	result.finish()
+
+	return result, nil
+}
+
+// GetAccountExposure returns the exposure of one of a user's broker accounts
+func (r *ExposureReporter) GetAccountExposure(userID, accountID string) (*AccountExposure, error) {
+	keys, err := r.accounts.GetUserApiKeys(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get broker accounts of user %s: %w", userID, err)
+	}
+
+	for _, key := range keys {
+		if key.ID == accountID {
+			return r.accountExposure(userID, key), nil
+		}
+	}
+
+	return nil, ErrAccountNotFound
+}
+
+// accountExposure queries the broker for the positions and margin of an account
+func (r *ExposureReporter) accountExposure(userID string, key models.UserApiKey) *AccountExposure {
+	account := &AccountExposure{
+		AccountID: key.ID,
+		Name:      key.Name,
+		Broker:    key.Broker,
+	}
+
+	var errs []string
+	positions, err := r.broker.GetAccountPositions(userID, key.ID)
+	if err != nil {
+		log.Printf("Error getting positions of broker account %s of user %s: %v", key.ID, userID, err)
+		errs = append(errs, fmt.Sprintf("failed to get positions: %v", err))
+	}
+	for _, brokerPosition := range positions {
+		if brokerPosition.NetQuantity == 0 {
+			continue
+		}
+		price := brokerPosition.LastPrice
+		if price <= 0 {
+			price = brokerPosition.AveragePrice
+		}
+		account.add(brokerPosition.NetQuantity, price, brokerPosition.UnrealizedProfit)
+	}
+
+	margin, err := r.broker.GetAccountMargin(userID, key.ID)
+	if err != nil {
+		log.Printf("Error getting margin of broker account %s of user %s: %v", key.ID, userID, err)
+		errs = append(errs, fmt.Sprintf("failed to get margin: %v", err))
+	} else {
+		account.Funds = margin.Available + margin.Utilized
+		account.MarginUtilized = margin.Utilized
+	}
+
+	account.Error = strings.Join(errs, "; ")
+	account.finish()
+	return account
+}
+
+// add adds a position of a signed quantity, long being positive, to the exposure
+func (e *Exposure) add(quantity int, price, unrealizedPnL float64) {
+	value := price * float64(quantity)
+	if quantity > 0 {
+		e.LongExposure += value
+	} else {
+		e.ShortExposure -= value
+	}
+	e.GrossExposure += math.Abs(value)
+	e.NetExposure += value
+	e.UnrealizedPnL += unrealizedPnL
+	if unrealizedPnL < 0 {
+		e.OpenRisk -= unrealizedPnL
+	}
+	e.OpenPositions++
+}
+
+// finish works out the ratios of the exposure to the funds
+func (e *Exposure) finish() {
+	if e.Funds <= 0 {
+		return
+	}
+	e.MarginUtilization = e.MarginUtilized / e.Funds * 100
+	e.Leverage = e.GrossExposure / e.Funds
+}
@@ -0,0 +1,98 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/broker/common"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockAccountProvider is a mock implementation of the AccountProvider interface
+type MockAccountProvider struct {
+	mock.Mock
+}
+
+func (m *MockAccountProvider) GetUserApiKeys(userID string) ([]models.UserApiKey, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.UserApiKey), args.Error(1)
+}
+
+// MockBrokerAccountClient is a mock implementation of the BrokerAccountClient interface
+type MockBrokerAccountClient struct {
+	mock.Mock
+}
+
+func (m *MockBrokerAccountClient) GetAccountPositions(userID, accountID string) ([]common.Position, error) {
+	args := m.Called(userID, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]common.Position), args.Error(1)
+}
+
+func (m *MockBrokerAccountClient) GetAccountMargin(userID, accountID string) (*common.AccountMargin, error) {
+	args := m.Called(userID, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.AccountMargin), args.Error(1)
+}
+
+func TestExposureReporter(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockAccounts := new(MockAccountProvider)
+	mockBroker := new(MockBrokerAccountClient)
+	mockQuotes := new(MockQuoteProvider)
+	reporter := NewExposureReporter(mockPositions, mockAccounts, mockBroker, mockQuotes)
+	mockPositions.setPositions(limitPositions())
+	mockQuotes.On("GetLastPrice", "NIFTY", "NFO").Return(110.0, nil)
+	mockQuotes.On("GetLastPrice", "BANKNIFTY", "NFO").Return(220.0, nil)
+
+	mockAccounts.On("GetUserApiKeys", "user1").Return([]models.UserApiKey{
+		{ID: "account1", Name: "Main", Broker: "ZERODHA", IsActive: true},
+		{ID: "account2", Name: "Second", Broker: "XTS_PRO", IsActive: true},
+		{ID: "account3", Name: "Old", Broker: "XTS_PRO"},
+	}, nil)
+	mockBroker.On("GetAccountPositions", "user1", "account1").Return([]common.Position{
+		{NetQuantity: 100, LastPrice: 110, UnrealizedProfit: 1000},
+		{NetQuantity: -15, LastPrice: 220, UnrealizedProfit: -300},
+		{NetQuantity: 0, LastPrice: 50, RealizedProfit: 200},
+	}, nil)
+	mockBroker.On("GetAccountMargin", "user1", "account1").Return(&common.AccountMargin{Available: 30000, Utilized: 20000}, nil)
+	mockBroker.On("GetAccountPositions", "user1", "account2").Return(nil, errors.New("session expired"))
+	mockBroker.On("GetAccountMargin", "user1", "account2").Return(nil, errors.New("session expired"))
+
+	// NIFTY is 1000 up and the short BANKNIFTY 300 down, marked to the last price
+	exposure, err := reporter.GetUserExposure("user1")
+	assert.NoError(t, err)
+	assert.Equal(t, 14300.0, exposure.GrossExposure)
+	assert.Equal(t, 7700.0, exposure.NetExposure)
+	assert.Equal(t, 11000.0, exposure.LongExposure)
+	assert.Equal(t, 3300.0, exposure.ShortExposure)
+	assert.Equal(t, 700.0, exposure.UnrealizedPnL)
+	assert.Equal(t, 300.0, exposure.OpenRisk)
+	assert.Equal(t, 2, exposure.OpenPositions)
+
+	// Only the inactive account is left out, and the one the broker failed for
+	// carries no margin
+	assert.Len(t, exposure.Accounts, 2)
+	assert.Equal(t, 50000.0, exposure.Funds)
+	assert.Equal(t, 40.0, exposure.MarginUtilization)
+	assert.InDelta(t, 0.286, exposure.Leverage, 0.0001)
+	assert.Empty(t, exposure.Accounts[0].Error)
+	assert.Equal(t, 14300.0, exposure.Accounts[0].GrossExposure)
+	assert.Equal(t, 2, exposure.Accounts[0].OpenPositions)
+	assert.Contains(t, exposure.Accounts[1].Error, "session expired")
+
+	account, err := reporter.GetAccountExposure("user1", "account1")
+	assert.NoError(t, err)
+	assert.Equal(t, 7700.0, account.NetExposure)
+	assert.Equal(t, 300.0, account.OpenRisk)
+	assert.Equal(t, 20000.0, account.MarginUtilized)
+
+	_, err = reporter.GetAccountExposure("user1", "account9")
+	assert.Equal(t, ErrAccountNotFound, err)
+}
@@ -0,0 +1,457 @@
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// ErrLimitExceeded is returned for orders that would take a user past one of their
+// position or notional limits
+var ErrLimitExceeded = errors.New("order exceeds a position limit")
+
+// ErrLimitNotFound is returned when a limit does not exist
+var ErrLimitNotFound = errors.New("limit not found")
+
+// LimitType is what a limit caps
+type LimitType string
+
+const (
+	LimitTypeMaxLots          LimitType = "MAX_LOTS"           // Lots open in a symbol
+	LimitTypeMaxNotional      LimitType = "MAX_NOTIONAL"       // Value of the open positions
+	LimitTypeMaxOpenPositions LimitType = "MAX_OPEN_POSITIONS" // Number of open positions
+)
+
+// QuoteProvider looks up the last traded price of an instrument, used to value
+// market orders and mark open positions
+type QuoteProvider interface {
+	GetLastPrice(symbol, exchange string) (float64, error)
+}
+
+// Limit is a hard cap on a user's open positions. A limit applies to the positions
+// matching its scope: those of its user, or of every user when it has none, in its
+// symbol and strategy when it has them.
+type Limit struct {
+	ID         string    `json:"id"`
+	Type       LimitType `json:"type"`
+	UserID     string    `json:"userId,omitempty"`
+	Symbol     string    `json:"symbol,omitempty"`
+	StrategyID string    `json:"strategyId,omitempty"`
+	LotSize    int       `json:"lotSize,omitempty"` // For MAX_LOTS, 1 when not set
+	Value      float64   `json:"value"`
+	CreatedBy  string    `json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Validate validates the limit
+func (l *Limit) Validate() error {
+	switch l.Type {
+	case LimitTypeMaxLots:
+		if l.Symbol == "" {
+			return errors.New("symbol is required for a lots limit")
+		}
+		if l.LotSize < 0 {
+			return errors.New("lot size cannot be negative")
+		}
+	case LimitTypeMaxNotional, LimitTypeMaxOpenPositions:
+	default:
+		return errors.New("invalid limit type")
+	}
+	if l.Value <= 0 {
+		return errors.New("value must be greater than zero")
+	}
+	if l.Type != LimitTypeMaxNotional && l.Value != math.Trunc(l.Value) {
+		return errors.New("value must be a whole number")
+	}
+
+	return nil
+}
+
+// appliesTo reports whether a position or order of a user in a symbol and
+// strategy falls within the limit's scope
+func (l *Limit) appliesTo(userID, symbol, strategyID string) bool {
+	return (l.UserID == "" || l.UserID == userID) &&
+		(l.Symbol == "" || l.Symbol == symbol) &&
+		(l.StrategyID == "" || l.StrategyID == strategyID)
+}
+
+// Breach is a user's open positions being past a limit
+type Breach struct {
+	Limit  Limit   `json:"limit"`
+	UserID string  `json:"userId"`
+	Usage  float64 `json:"usage"` // Lots, value or number of positions open
+}
+
+// LimitRunResult is the outcome of one run of the limit monitor
+type LimitRunResult struct {
+	Time     time.Time `json:"time"`
+	Breaches []Breach  `json:"breaches"`
+	Errors   []string  `json:"errors,omitempty"`
+}
+
+// LimitMonitor checks orders against the position and notional limits before they
+// are placed, and monitors open positions for limits breached by fills or price
+// moves after the fact
+type LimitMonitor struct {
+	positionService position.PositionService
+	quotes          QuoteProvider
+	interval        time.Duration
+	limits          map[string]*Limit
+	lastRun         *LimitRunResult
+	stop            chan struct{}
+	mutex           sync.RWMutex
+}
+
+// NewLimitMonitor creates a new LimitMonitor running every interval, DefaultInterval
+// when not set. Without quotes positions are valued at their entry price and
+// market orders at the entry price of the user's open position in the symbol.
+func NewLimitMonitor(positionService position.PositionService, quotes QuoteProvider, interval time.Duration) *LimitMonitor {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &LimitMonitor{
+		positionService: positionService,
+		quotes:          quotes,
+		interval:        interval,
+		limits:          make(map[string]*Limit),
+	}
+}
+
+// CreateLimit adds a limit
+func (m *LimitMonitor) CreateLimit(limit *Limit) (*Limit, error) {
+	if err := limit.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	created := *limit
+	created.ID = uuid.New().String()
+	created.CreatedAt = now
+	created.UpdatedAt = now
+
+	m.mutex.Lock()
+	m.limits[created.ID] = &created
+	m.mutex.Unlock()
+
+	result := created
+	return &result, nil
+}
+
+// GetLimit returns a limit by ID
+func (m *LimitMonitor) GetLimit(id string) (*Limit, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	limit, exists := m.limits[id]
+	if !exists {
+		return nil, ErrLimitNotFound
+	}
+
+	result := *limit
+	return &result, nil
+}
+
+// GetLimits returns the limits applying to a user, or all limits when userID is
+// empty
+func (m *LimitMonitor) GetLimits(userID string) []Limit {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	limits := make([]Limit, 0, len(m.limits))
+	for _, limit := range m.limits {
+		if userID == "" || limit.UserID == "" || limit.UserID == userID {
+			limits = append(limits, *limit)
+		}
+	}
+	sort.Slice(limits, func(i, j int) bool {
+		return limits[i].CreatedAt.Before(limits[j].CreatedAt)
+	})
+
+	return limits
+}
+
+// UpdateLimit replaces a limit's scope and value
+func (m *LimitMonitor) UpdateLimit(limit *Limit) (*Limit, error) {
+	if err := limit.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	existing, exists := m.limits[limit.ID]
+	if !exists {
+		return nil, ErrLimitNotFound
+	}
+
+	updated := *limit
+	updated.CreatedBy = existing.CreatedBy
+	updated.CreatedAt = existing.CreatedAt
+	updated.UpdatedAt = time.Now()
+	m.limits[updated.ID] = &updated
+
+	result := updated
+	return &result, nil
+}
+
+// DeleteLimit removes a limit
+func (m *LimitMonitor) DeleteLimit(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.limits[id]; !exists {
+		return ErrLimitNotFound
+	}
+	delete(m.limits, id)
+
+	return nil
+}
+
+// CheckOrder returns ErrLimitExceeded for an order that would take its user's open
+// positions past a limit. Orders that only reduce an open position are always
+// allowed.
+func (m *LimitMonitor) CheckOrder(order *models.Order) error {
+	if order == nil || order.UserID == "" {
+		return nil
+	}
+
+	limits := m.applicableLimits(order)
+	if len(limits) == 0 {
+		return nil
+	}
+
+	reduces, err := reducesPosition(m.positionService, order)
+	if err != nil {
+		return fmt.Errorf("failed to check positions of user %s: %w", order.UserID, err)
+	}
+	if reduces {
+		return nil
+	}
+
+	positions, err := openPositions(m.positionService, models.PositionFilter{UserID: order.UserID})
+	if err != nil {
+		return fmt.Errorf("failed to check positions of user %s: %w", order.UserID, err)
+	}
+
+	for _, limit := range limits {
+		usage := m.usage(&limit, positions)
+		added, err := m.orderUsage(&limit, order, positions)
+		if err != nil {
+			return err
+		}
+		if usage+added > limit.Value {
+			return fmt.Errorf("%w: %s limit of %s at %s with the order", ErrLimitExceeded, limit.Type, formatUsage(limit.Type, limit.Value), formatUsage(limit.Type, usage+added))
+		}
+	}
+
+	return nil
+}
+
+// Start runs the monitor every interval until it is stopped
+func (m *LimitMonitor) Start() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		return errors.New("limit monitor is already running")
+	}
+	m.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := m.Run(now)
+				if err != nil {
+					log.Printf("Error running limit monitor: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Limit monitor error: %s", message)
+				}
+			}
+		}
+	}(m.stop)
+
+	return nil
+}
+
+// Stop stops the monitor
+func (m *LimitMonitor) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+// Run checks the open positions of every user against the limits at now
+func (m *LimitMonitor) Run(now time.Time) (*LimitRunResult, error) {
+	result := &LimitRunResult{
+		Time:     now,
+		Breaches: []Breach{},
+	}
+
+	positions, err := openPositions(m.positionService, models.PositionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	byUser := make(map[string][]models.Position)
+	for _, openPosition := range positions {
+		byUser[openPosition.UserID] = append(byUser[openPosition.UserID], openPosition)
+	}
+
+	for _, limit := range m.GetLimits("") {
+		for userID, userPositions := range byUser {
+			if limit.UserID != "" && limit.UserID != userID {
+				continue
+			}
+			if usage := m.usage(&limit, userPositions); usage > limit.Value {
+				log.Printf("User %s is past the %s limit %s: %s against %s", userID, limit.Type, limit.ID, formatUsage(limit.Type, usage), formatUsage(limit.Type, limit.Value))
+				result.Breaches = append(result.Breaches, Breach{Limit: limit, UserID: userID, Usage: usage})
+			}
+		}
+	}
+
+	m.mutex.Lock()
+	m.lastRun = result
+	m.mutex.Unlock()
+
+	return result, nil
+}
+
+// GetBreaches returns the breaches found by the last run
+func (m *LimitMonitor) GetBreaches() []Breach {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.lastRun == nil {
+		return []Breach{}
+	}
+	return append([]Breach{}, m.lastRun.Breaches...)
+}
+
+// applicableLimits returns the limits an order falls within
+func (m *LimitMonitor) applicableLimits(order *models.Order) []Limit {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var limits []Limit
+	for _, limit := range m.limits {
+		if limit.appliesTo(order.UserID, order.Symbol, order.StrategyID) {
+			limits = append(limits, *limit)
+		}
+	}
+
+	return limits
+}
+
+// usage returns how much of a limit a user's open positions use
+func (m *LimitMonitor) usage(limit *Limit, positions []models.Position) float64 {
+	var usage float64
+	for _, openPosition := range positions {
+		if !limit.appliesTo(openPosition.UserID, openPosition.Symbol, openPosition.StrategyID) {
+			continue
+		}
+
+		switch limit.Type {
+		case LimitTypeMaxLots:
+			usage += lots(openPosition.RemainingQuantity(), limit.LotSize)
+		case LimitTypeMaxNotional:
+			price := openPosition.EntryPrice
+			if m.quotes != nil {
+				if lastPrice, err := m.quotes.GetLastPrice(openPosition.Symbol, openPosition.Exchange); err == nil && lastPrice > 0 {
+					price = lastPrice
+				}
+			}
+			usage += price * float64(openPosition.RemainingQuantity())
+		case LimitTypeMaxOpenPositions:
+			usage++
+		}
+	}
+
+	return usage
+}
+
+// orderUsage returns how much of a limit an order would add to a user's open
+// positions once filled
+func (m *LimitMonitor) orderUsage(limit *Limit, order *models.Order, positions []models.Position) (float64, error) {
+	switch limit.Type {
+	case LimitTypeMaxLots:
+		return lots(order.Quantity, limit.LotSize), nil
+	case LimitTypeMaxNotional:
+		price, err := m.orderPrice(order, positions)
+		if err != nil {
+			return 0, err
+		}
+		return price * float64(order.Quantity), nil
+	case LimitTypeMaxOpenPositions:
+		// Adding to an open position does not open another
+		for _, openPosition := range positions {
+			if openPosition.Symbol == order.Symbol && openPosition.Exchange == order.Exchange &&
+				limit.appliesTo(openPosition.UserID, openPosition.Symbol, openPosition.StrategyID) {
+				return 0, nil
+			}
+		}
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// orderPrice returns the price an order is valued at: its limit or trigger price,
+// else the last price or the entry price of the user's open position in the symbol
+func (m *LimitMonitor) orderPrice(order *models.Order, positions []models.Position) (float64, error) {
+	if order.Price > 0 {
+		return order.Price, nil
+	}
+	if order.TriggerPrice > 0 {
+		return order.TriggerPrice, nil
+	}
+	if m.quotes != nil {
+		if price, err := m.quotes.GetLastPrice(order.Symbol, order.Exchange); err == nil && price > 0 {
+			return price, nil
+		}
+	}
+	for _, openPosition := range positions {
+		if openPosition.Symbol == order.Symbol && openPosition.Exchange == order.Exchange {
+			return openPosition.EntryPrice, nil
+		}
+	}
+
+	// A hard limit is not waived for orders that cannot be valued
+	return 0, fmt.Errorf("%w: no price to value the order of %s against the notional limit", ErrLimitExceeded, order.Symbol)
+}
+
+// lots returns the lots a quantity takes up, a part lot counting as a whole one
+func lots(quantity, lotSize int) float64 {
+	if lotSize <= 0 {
+		lotSize = 1
+	}
+	return float64((quantity + lotSize - 1) / lotSize)
+}
+
+// formatUsage formats a usage or value of a limit type for messages
+func formatUsage(limitType LimitType, value float64) string {
+	if limitType == LimitTypeMaxNotional {
+		return fmt.Sprintf("%.2f", value)
+	}
+	return fmt.Sprintf("%.0f", value)
+}
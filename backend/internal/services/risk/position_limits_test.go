@@ -0,0 +1,162 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockQuoteProvider is a mock implementation of the QuoteProvider interface
+type MockQuoteProvider struct {
+	mock.Mock
+}
+
+func (m *MockQuoteProvider) GetLastPrice(symbol, exchange string) (float64, error) {
+	args := m.Called(symbol, exchange)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+// limitPositions returns two lots of NIFTY and one of BANKNIFTY open for user1 in
+// strategy1, and a position of user2
+func limitPositions() []models.Position {
+	return []models.Position{
+		{ID: "nifty1", UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.PositionDirectionLong, EntryPrice: 100, Quantity: 100, Status: models.PositionStatusOpen, StrategyID: "strategy1"},
+		{ID: "banknifty1", UserID: "user1", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.PositionDirectionShort, EntryPrice: 200, Quantity: 30, ExitQuantity: 15, Status: models.PositionStatusPartial, StrategyID: "strategy1"},
+		{ID: "nifty2", UserID: "user2", Symbol: "NIFTY", Exchange: "NFO", Direction: models.PositionDirectionLong, EntryPrice: 100, Quantity: 50, Status: models.PositionStatusOpen},
+	}
+}
+
+func TestLimitCRUD(t *testing.T) {
+	monitor := NewLimitMonitor(new(MockPositionService), nil, 0)
+
+	// Lots limits need a symbol
+	_, err := monitor.CreateLimit(&Limit{Type: LimitTypeMaxLots, Value: 2})
+	assert.Error(t, err)
+	_, err = monitor.CreateLimit(&Limit{Type: LimitTypeMaxOpenPositions, Value: 2.5})
+	assert.Error(t, err)
+
+	limit, err := monitor.CreateLimit(&Limit{Type: LimitTypeMaxLots, UserID: "user1", Symbol: "NIFTY", LotSize: 50, Value: 2, CreatedBy: "admin1"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, limit.ID)
+	_, err = monitor.CreateLimit(&Limit{Type: LimitTypeMaxOpenPositions, Value: 10, CreatedBy: "admin1"})
+	assert.NoError(t, err)
+	_, err = monitor.CreateLimit(&Limit{Type: LimitTypeMaxOpenPositions, UserID: "user2", Value: 1, CreatedBy: "admin1"})
+	assert.NoError(t, err)
+
+	// Users see their own limits and those applying to everyone
+	assert.Len(t, monitor.GetLimits(""), 3)
+	assert.Len(t, monitor.GetLimits("user1"), 2)
+
+	limit.Value = 4
+	limit.CreatedBy = "admin2"
+	updated, err := monitor.UpdateLimit(limit)
+	assert.NoError(t, err)
+	assert.Equal(t, 4.0, updated.Value)
+	assert.Equal(t, "admin1", updated.CreatedBy)
+
+	assert.NoError(t, monitor.DeleteLimit(limit.ID))
+	_, err = monitor.GetLimit(limit.ID)
+	assert.Equal(t, ErrLimitNotFound, err)
+	assert.Equal(t, ErrLimitNotFound, monitor.DeleteLimit(limit.ID))
+}
+
+func TestLimitMonitorCheckOrder(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockQuotes := new(MockQuoteProvider)
+	monitor := NewLimitMonitor(mockPositions, mockQuotes, 0)
+	mockPositions.setPositions(limitPositions())
+	mockQuotes.On("GetLastPrice", "NIFTY", "NFO").Return(110.0, nil)
+	mockQuotes.On("GetLastPrice", "BANKNIFTY", "NFO").Return(0.0, errors.New("no quote"))
+
+	_, err := monitor.CreateLimit(&Limit{Type: LimitTypeMaxLots, Symbol: "NIFTY", LotSize: 50, Value: 3})
+	assert.NoError(t, err)
+
+	// Two lots of NIFTY are open, a third fits and a part lot counts as a whole one
+	assert.NoError(t, monitor.CheckOrder(&models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50, Price: 100}))
+	err = monitor.CheckOrder(&models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 60, Price: 100})
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+	// Exits are always allowed
+	assert.NoError(t, monitor.CheckOrder(&models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionSell, Quantity: 100}))
+
+	// The strategy's open notional is 11000 marked to the last price plus 3000 at
+	// entry, without a quote
+	_, err = monitor.CreateLimit(&Limit{Type: LimitTypeMaxNotional, StrategyID: "strategy1", Value: 20000})
+	assert.NoError(t, err)
+	assert.NoError(t, monitor.CheckOrder(&models.Order{UserID: "user1", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.OrderDirectionSell, Quantity: 15, Price: 200, StrategyID: "strategy1"}))
+	err = monitor.CheckOrder(&models.Order{UserID: "user1", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.OrderDirectionSell, Quantity: 15, Price: 500, StrategyID: "strategy1"})
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+	// Market orders are valued at the last price, else refused
+	err = monitor.CheckOrder(&models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50, StrategyID: "strategy1"})
+	assert.NoError(t, err)
+	mockQuotes.On("GetLastPrice", "FINNIFTY", "NFO").Return(0.0, errors.New("no quote"))
+	err = monitor.CheckOrder(&models.Order{UserID: "user1", Symbol: "FINNIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 40, StrategyID: "strategy1"})
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+	// Adding to an open position does not open another
+	_, err = monitor.CreateLimit(&Limit{Type: LimitTypeMaxOpenPositions, UserID: "user2", Value: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, monitor.CheckOrder(&models.Order{UserID: "user2", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50, Price: 100}))
+	err = monitor.CheckOrder(&models.Order{UserID: "user2", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 15, Price: 200})
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+}
+
+func TestLimitMonitorRun(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockQuotes := new(MockQuoteProvider)
+	monitor := NewLimitMonitor(mockPositions, mockQuotes, 0)
+	mockPositions.setPositions(limitPositions())
+	mockQuotes.On("GetLastPrice", "NIFTY", "NFO").Return(100.0, nil).Once()
+	mockQuotes.On("GetLastPrice", "NIFTY", "NFO").Return(120.0, nil)
+	mockQuotes.On("GetLastPrice", mock.Anything, "NFO").Return(0.0, errors.New("no quote"))
+
+	limit, err := monitor.CreateLimit(&Limit{Type: LimitTypeMaxNotional, UserID: "user1", Value: 13000})
+	assert.NoError(t, err)
+	assert.Empty(t, monitor.GetBreaches())
+
+	// Within the limit at the last price
+	result, err := monitor.Run(time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, result.Breaches)
+
+	// A price move takes the user past it
+	result, err = monitor.Run(time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, result.Breaches, 1)
+	assert.Equal(t, limit.ID, result.Breaches[0].Limit.ID)
+	assert.Equal(t, "user1", result.Breaches[0].UserID)
+	assert.Equal(t, 15000.0, result.Breaches[0].Usage)
+	assert.Equal(t, result.Breaches, monitor.GetBreaches())
+}
+
+func TestGuardedOrderServiceChecks(t *testing.T) {
+	mockOrders := new(MockOrderService)
+	mockPositions := new(MockPositionService)
+	mockPreferences := new(MockPreferencesProvider)
+	dailyLoss := NewDailyLossMonitor(mockPositions, nil, mockPreferences, nil, Config{})
+	limits := NewLimitMonitor(mockPositions, nil, 0)
+	service := NewGuardedOrderService(mockOrders, dailyLoss, limits)
+
+	mockPreferences.On("GetUserPreferences", "user1").Return(&models.UserPreferences{UserID: "user1"}, nil)
+	mockPositions.setPositions(limitPositions())
+	_, err := limits.CreateLimit(&Limit{Type: LimitTypeMaxOpenPositions, UserID: "user1", Value: 2})
+	assert.NoError(t, err)
+
+	// Every check must pass
+	refused := &models.Order{UserID: "user1", Symbol: "FINNIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 40, Price: 20}
+	_, err = service.CreateOrder(refused)
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+	allowed := &models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50, Price: 100}
+	mockOrders.On("CreateOrder", allowed).Return(allowed, nil)
+	_, err = service.CreateOrder(allowed)
+	assert.NoError(t, err)
+
+	mockOrders.AssertNotCalled(t, "CreateOrder", refused)
+	mockOrders.AssertExpectations(t)
+}
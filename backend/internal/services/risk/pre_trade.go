@@ -0,0 +1,161 @@
+package risk
+
+import (
+	"log"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// pageSize is the number of positions fetched at a time
+const pageSize = 100
+
+// OrderCheck is a pre-trade check, returning an error for an order that must not
+// be placed
+type OrderCheck interface {
+	CheckOrder(order *models.Order) error
+}
+
+// GuardedOrderService is an OrderService running new orders through the pre-trade
+// checks before creating them
+type GuardedOrderService struct {
+	services.OrderService
+	checks []OrderCheck
+}
+
+// NewGuardedOrderService wraps an OrderService so that new orders failing any of
+// the checks, such as entries of users who reached their max daily loss, are
+// refused. Checks run in the order given.
+func NewGuardedOrderService(orderService services.OrderService, checks ...OrderCheck) services.OrderService {
+	return &GuardedOrderService{
+		OrderService: orderService,
+		checks:       checks,
+	}
+}
+
+// CreateOrder creates an order unless a pre-trade check refuses it
+func (s *GuardedOrderService) CreateOrder(order *models.Order) (*models.Order, error) {
+	for _, check := range s.checks {
+		if err := check.CheckOrder(order); err != nil {
+			return nil, err
+		}
+	}
+	return s.OrderService.CreateOrder(order)
+}
+
+// listPositions returns all positions matching filter
+func listPositions(positionService position.PositionService, filter models.PositionFilter) ([]models.Position, error) {
+	var positions []models.Position
+	for page := 1; ; page++ {
+		batch, total, err := positionService.GetPositions(filter, page, pageSize)
+		if err != nil {
+			return positions, err
+		}
+		positions = append(positions, batch...)
+		if len(batch) == 0 || page*pageSize >= total {
+			break
+		}
+	}
+
+	return positions, nil
+}
+
+// openPositions returns the open and partially closed positions matching filter
+func openPositions(positionService position.PositionService, filter models.PositionFilter) ([]models.Position, error) {
+	var positions []models.Position
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial} {
+		filter.Status = status
+		batch, err := listPositions(positionService, filter)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, batch...)
+	}
+
+	return positions, nil
+}
+
+// ClosePriceProvider looks up the closing price of an instrument on the last
+// trading day before the one starting at startOfDay, the P&L of positions carried
+// over into a day being measured from it
+type ClosePriceProvider interface {
+	GetPreviousClose(symbol, exchange string, startOfDay time.Time) (float64, error)
+}
+
+// dayPositions returns a user's open positions, and the positions closed since
+// startOfDay whenever they were opened
+func dayPositions(positionService position.PositionService, userID string, startOfDay time.Time) ([]models.Position, []models.Position, error) {
+	open, err := openPositions(positionService, models.PositionFilter{UserID: userID})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	closed, err := listPositions(positionService, models.PositionFilter{UserID: userID, Status: models.PositionStatusClosed, ClosedFrom: startOfDay})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return open, closed, nil
+}
+
+// positionsPnL returns the P&L on the trading day starting at startOfDay of open
+// positions, realized and unrealized, and of positions closed that day, realized.
+// Positions carried over into the day count from the previous close.
+func positionsPnL(closes ClosePriceProvider, open, closed []models.Position, startOfDay time.Time) float64 {
+	var pnl float64
+	for _, openPosition := range open {
+		pnl += openPosition.CalculateTotalPnL() - closePnL(closes, openPosition, startOfDay)
+	}
+	for _, closedPosition := range closed {
+		pnl += closedPosition.RealizedPnL - closePnL(closes, closedPosition, startOfDay)
+	}
+
+	return pnl
+}
+
+// closePnL returns the P&L at the previous close of a position carried over into
+// the trading day starting at startOfDay, as if all of its quantity was still held
+// then. It is 0 for positions opened that day, and for positions without a
+// previous close, which count their P&L since entry.
+func closePnL(closes ClosePriceProvider, carried models.Position, startOfDay time.Time) float64 {
+	if closes == nil || !carried.CreatedAt.Before(startOfDay) {
+		return 0
+	}
+
+	closePrice, err := closes.GetPreviousClose(carried.Symbol, carried.Exchange, startOfDay)
+	if err != nil || closePrice <= 0 {
+		if err != nil {
+			log.Printf("Error getting previous close of %s on %s: %v", carried.Symbol, carried.Exchange, err)
+		}
+		return 0
+	}
+
+	if carried.Direction == models.PositionDirectionShort {
+		return (carried.EntryPrice - closePrice) * float64(carried.Quantity)
+	}
+	return (closePrice - carried.EntryPrice) * float64(carried.Quantity)
+}
+
+// reducesPosition reports whether an order only reduces a user's open position in
+// its instrument, as exits and square-offs do
+func reducesPosition(positionService position.PositionService, order *models.Order) (bool, error) {
+	positions, err := openPositions(positionService, models.PositionFilter{UserID: order.UserID, Symbol: order.Symbol})
+	if err != nil {
+		return false, err
+	}
+
+	for _, openPosition := range positions {
+		if openPosition.Exchange != order.Exchange {
+			continue
+		}
+		exit := (openPosition.Direction == models.PositionDirectionLong && order.Direction == models.OrderDirectionSell) ||
+			(openPosition.Direction == models.PositionDirectionShort && order.Direction == models.OrderDirectionBuy)
+		if exit && order.Quantity <= openPosition.RemainingQuantity() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,702 @@
+package risk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/messagequeue"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/killswitch"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// ErrRuleBlocked is returned for orders refused by a risk rule with the BLOCK action
+var ErrRuleBlocked = errors.New("order blocked by a risk rule")
+
+// ErrRuleNotFound is returned when a risk rule does not exist
+var ErrRuleNotFound = errors.New("rule not found")
+
+// DefaultRateWindow is the window the order rate is measured over
+const DefaultRateWindow = time.Minute
+
+// maxTriggers is the number of recent rule triggers kept
+const maxTriggers = 100
+
+// ruleEngineActor is recorded as the trigger of the kill switches the engine pulls
+const ruleEngineActor = "risk-rule-engine"
+
+// timeOfDayFormat is the format of the times of TIME_OF_DAY conditions
+const timeOfDayFormat = "15:04"
+
+// RuleMetric is what a rule condition is on
+type RuleMetric string
+
+const (
+	RuleMetricPnL       RuleMetric = "PNL"         // Realized and unrealized P&L of the trading day
+	RuleMetricDelta     RuleMetric = "DELTA"       // Of the open positions together
+	RuleMetricGamma     RuleMetric = "GAMMA"       // Of the open positions together
+	RuleMetricTheta     RuleMetric = "THETA"       // Of the open positions together
+	RuleMetricVega      RuleMetric = "VEGA"        // Of the open positions together
+	RuleMetricOrderRate RuleMetric = "ORDER_RATE"  // Orders placed over the rate window
+	RuleMetricTimeOfDay RuleMetric = "TIME_OF_DAY" // Against the condition's time
+)
+
+// RuleOperator compares a metric with a condition's value
+type RuleOperator string
+
+const (
+	RuleOperatorGT  RuleOperator = "GT"
+	RuleOperatorGTE RuleOperator = "GTE"
+	RuleOperatorLT  RuleOperator = "LT"
+	RuleOperatorLTE RuleOperator = "LTE"
+)
+
+// RuleAction is what is done when a rule's conditions hold
+type RuleAction string
+
+const (
+	RuleActionBlock     RuleAction = "BLOCK"      // Refuse new entries while the conditions hold
+	RuleActionAlert     RuleAction = "ALERT"      // Notify the user
+	RuleActionSquareOff RuleAction = "SQUARE_OFF" // Cancel the user's orders and flatten their positions
+)
+
+// Condition is a comparison of a metric of a user's trading
+type Condition struct {
+	Metric   RuleMetric   `json:"metric"`
+	Operator RuleOperator `json:"operator"`
+	Value    float64      `json:"value,omitempty"`
+	Time     string       `json:"time,omitempty"` // HH:MM, for TIME_OF_DAY
+}
+
+// Validate validates the condition
+func (c *Condition) Validate() error {
+	switch c.Metric {
+	case RuleMetricPnL, RuleMetricDelta, RuleMetricGamma, RuleMetricTheta, RuleMetricVega:
+	case RuleMetricOrderRate:
+		if c.Value < 0 {
+			return errors.New("order rate cannot be negative")
+		}
+	case RuleMetricTimeOfDay:
+		if _, err := time.Parse(timeOfDayFormat, c.Time); err != nil {
+			return errors.New("time of day must be given as HH:MM")
+		}
+	default:
+		return fmt.Errorf("invalid metric %q", c.Metric)
+	}
+
+	switch c.Operator {
+	case RuleOperatorGT, RuleOperatorGTE, RuleOperatorLT, RuleOperatorLTE:
+	default:
+		return fmt.Errorf("invalid operator %q", c.Operator)
+	}
+
+	return nil
+}
+
+// holds reports whether the condition holds for a value of its metric
+func (c *Condition) holds(value float64) bool {
+	threshold := c.Value
+	if c.Metric == RuleMetricTimeOfDay {
+		parsed, _ := time.Parse(timeOfDayFormat, c.Time)
+		threshold = float64(parsed.Hour()*60 + parsed.Minute())
+	}
+
+	switch c.Operator {
+	case RuleOperatorGT:
+		return value > threshold
+	case RuleOperatorGTE:
+		return value >= threshold
+	case RuleOperatorLT:
+		return value < threshold
+	case RuleOperatorLTE:
+		return value <= threshold
+	}
+	return false
+}
+
+// String describes the condition
+func (c Condition) String() string {
+	if c.Metric == RuleMetricTimeOfDay {
+		return fmt.Sprintf("%s %s %s", c.Metric, c.Operator, c.Time)
+	}
+	return fmt.Sprintf("%s %s %.2f", c.Metric, c.Operator, c.Value)
+}
+
+// Rule is a risk rule defined by an admin: when all of its conditions hold for a
+// user within its scope, its action is taken. A rule applies to its user, or to
+// every user when it has none.
+type Rule struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	UserID     string      `json:"userId,omitempty"`
+	Conditions []Condition `json:"conditions"`
+	Action     RuleAction  `json:"action"`
+	CreatedBy  string      `json:"createdBy"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	UpdatedAt  time.Time   `json:"updatedAt"`
+}
+
+// Validate validates the rule
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(r.Conditions) == 0 {
+		return errors.New("at least one condition is required")
+	}
+	for i := range r.Conditions {
+		if err := r.Conditions[i].Validate(); err != nil {
+			return fmt.Errorf("condition %d: %w", i+1, err)
+		}
+	}
+
+	switch r.Action {
+	case RuleActionBlock, RuleActionAlert, RuleActionSquareOff:
+	default:
+		return errors.New("invalid action")
+	}
+
+	return nil
+}
+
+// holds reports whether all of the rule's conditions hold for the values of their
+// metrics
+func (r *Rule) holds(values map[RuleMetric]float64) bool {
+	for i := range r.Conditions {
+		if !r.Conditions[i].holds(values[r.Conditions[i].Metric]) {
+			return false
+		}
+	}
+	return true
+}
+
+// RuleTrigger is a rule's conditions coming to hold for a user
+type RuleTrigger struct {
+	RuleID   string                 `json:"ruleId"`
+	RuleName string                 `json:"ruleName"`
+	UserID   string                 `json:"userId"`
+	Action   RuleAction             `json:"action"`
+	Values   map[RuleMetric]float64 `json:"values"` // Of the metrics of the rule's conditions
+	Time     time.Time              `json:"time"`
+	Errors   []string               `json:"errors,omitempty"`
+}
+
+// RuleRunResult is the outcome of one run of the rule engine
+type RuleRunResult struct {
+	Time     time.Time     `json:"time"`
+	Triggers []RuleTrigger `json:"triggers"`
+	Errors   []string      `json:"errors,omitempty"`
+}
+
+// RuleEngineConfig configures the rule engine
+type RuleEngineConfig struct {
+	Location   *time.Location // Time zone of trading days and times of day, defaults to the local time zone
+	Interval   time.Duration  // Between runs, defaults to DefaultInterval
+	RateWindow time.Duration  // Order rates are measured over, defaults to DefaultRateWindow
+}
+
+// RuleEngine evaluates the risk rules admins define against users' trading. Rules
+// are evaluated as order events arrive and every interval for users with open
+// positions or recent orders. A rule triggers when its conditions come to hold,
+// alerting or squaring off the user once until they stop holding. Rules with the
+// BLOCK action are also checked before orders are placed, refusing entries while
+// their conditions hold.
+type RuleEngine struct {
+	positionService position.PositionService
+	closes          ClosePriceProvider
+	killSwitch      killswitch.KillSwitchService
+	notifier        Notifier
+	config          RuleEngineConfig
+	rules           map[string]*Rule
+	orders          map[string][]time.Time     // Times of recent orders, by user
+	active          map[string]map[string]bool // Users each rule holds for, by rule
+	triggers        []RuleTrigger
+	stop            chan struct{}
+	mutex           sync.RWMutex
+}
+
+// NewRuleEngine creates a new RuleEngine. Without closes positions carried over
+// count their P&L since entry, without a kill switch square-off rules only record
+// their triggers, and without a notifier alerts are not sent.
+func NewRuleEngine(
+	positionService position.PositionService,
+	closes ClosePriceProvider,
+	killSwitch killswitch.KillSwitchService,
+	notifier Notifier,
+	config RuleEngineConfig,
+) *RuleEngine {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.RateWindow <= 0 {
+		config.RateWindow = DefaultRateWindow
+	}
+
+	return &RuleEngine{
+		positionService: positionService,
+		closes:          closes,
+		killSwitch:      killSwitch,
+		notifier:        notifier,
+		config:          config,
+		rules:           make(map[string]*Rule),
+		orders:          make(map[string][]time.Time),
+		active:          make(map[string]map[string]bool),
+	}
+}
+
+// CreateRule adds a rule
+func (e *RuleEngine) CreateRule(rule *Rule) (*Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	created := *rule
+	created.ID = uuid.New().String()
+	created.Conditions = append([]Condition{}, rule.Conditions...)
+	created.CreatedAt = now
+	created.UpdatedAt = now
+
+	e.mutex.Lock()
+	e.rules[created.ID] = &created
+	e.mutex.Unlock()
+
+	result := created
+	return &result, nil
+}
+
+// GetRule returns a rule by ID
+func (e *RuleEngine) GetRule(id string) (*Rule, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	rule, exists := e.rules[id]
+	if !exists {
+		return nil, ErrRuleNotFound
+	}
+
+	result := *rule
+	return &result, nil
+}
+
+// GetRules returns the rules applying to a user, or all rules when userID is empty
+func (e *RuleEngine) GetRules(userID string) []Rule {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	rules := make([]Rule, 0, len(e.rules))
+	for _, rule := range e.rules {
+		if userID == "" || rule.UserID == "" || rule.UserID == userID {
+			rules = append(rules, *rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+
+	return rules
+}
+
+// UpdateRule replaces a rule's scope, conditions and action. The rule triggers
+// afresh for users its new conditions hold for.
+func (e *RuleEngine) UpdateRule(rule *Rule) (*Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	existing, exists := e.rules[rule.ID]
+	if !exists {
+		return nil, ErrRuleNotFound
+	}
+
+	updated := *rule
+	updated.Conditions = append([]Condition{}, rule.Conditions...)
+	updated.CreatedBy = existing.CreatedBy
+	updated.CreatedAt = existing.CreatedAt
+	updated.UpdatedAt = time.Now()
+	e.rules[updated.ID] = &updated
+	delete(e.active, updated.ID)
+
+	result := updated
+	return &result, nil
+}
+
+// DeleteRule removes a rule
+func (e *RuleEngine) DeleteRule(id string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, exists := e.rules[id]; !exists {
+		return ErrRuleNotFound
+	}
+	delete(e.rules, id)
+	delete(e.active, id)
+
+	return nil
+}
+
+// GetTriggers returns the most recent rule triggers, oldest first
+func (e *RuleEngine) GetTriggers() []RuleTrigger {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return append([]RuleTrigger{}, e.triggers...)
+}
+
+// CheckOrder returns ErrRuleBlocked for an order of a user a BLOCK rule holds for,
+// the order counting towards the user's order rate. Orders that only reduce an
+// open position are always allowed.
+func (e *RuleEngine) CheckOrder(order *models.Order) error {
+	if order == nil || order.UserID == "" {
+		return nil
+	}
+
+	var blocking []Rule
+	for _, rule := range e.GetRules(order.UserID) {
+		if rule.Action == RuleActionBlock {
+			blocking = append(blocking, rule)
+		}
+	}
+	if len(blocking) == 0 {
+		return nil
+	}
+
+	reduces, err := reducesPosition(e.positionService, order)
+	if err != nil {
+		return fmt.Errorf("failed to check positions of user %s: %w", order.UserID, err)
+	}
+	if reduces {
+		return nil
+	}
+
+	values, err := e.metrics(order.UserID, blocking, time.Now(), 1)
+	if err != nil {
+		return fmt.Errorf("failed to check risk rules of user %s: %w", order.UserID, err)
+	}
+	for _, rule := range blocking {
+		if rule.holds(values) {
+			return fmt.Errorf("%w: %s", ErrRuleBlocked, rule.Name)
+		}
+	}
+
+	return nil
+}
+
+// HandleOrderEvent evaluates the rules of the user of an order event, counting
+// new orders towards the user's order rate
+func (e *RuleEngine) HandleOrderEvent(event messagequeue.OrderEvent) {
+	if event.UserID == "" {
+		return
+	}
+
+	now := event.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if event.Type == messagequeue.OrderNew {
+		e.mutex.Lock()
+		e.orders[event.UserID] = append(e.orders[event.UserID], now)
+		e.mutex.Unlock()
+	}
+
+	triggers, err := e.evaluate(event.UserID, now)
+	if err != nil {
+		log.Printf("Error evaluating risk rules of user %s: %v", event.UserID, err)
+		return
+	}
+	for _, trigger := range triggers {
+		for _, message := range trigger.Errors {
+			log.Printf("Risk rule engine error: %s", message)
+		}
+	}
+}
+
+// HandleMessage handles an order event message of the order event stream, for
+// subscribing the engine to a message broker
+func (e *RuleEngine) HandleMessage(data []byte) error {
+	var message struct {
+		Payload messagequeue.OrderEvent `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return fmt.Errorf("failed to decode order event: %w", err)
+	}
+
+	e.HandleOrderEvent(message.Payload)
+	return nil
+}
+
+// Start runs the engine every interval until it is stopped
+func (e *RuleEngine) Start() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.stop != nil {
+		return errors.New("rule engine is already running")
+	}
+	e.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(e.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := e.Run(now)
+				if err != nil {
+					log.Printf("Error running risk rule engine: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Risk rule engine error: %s", message)
+				}
+			}
+		}
+	}(e.stop)
+
+	return nil
+}
+
+// Stop stops the engine
+func (e *RuleEngine) Stop() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.stop != nil {
+		close(e.stop)
+		e.stop = nil
+	}
+}
+
+// Run evaluates the rules of every user with open positions or recent orders at now
+func (e *RuleEngine) Run(now time.Time) (*RuleRunResult, error) {
+	result := &RuleRunResult{
+		Time:     now,
+		Triggers: []RuleTrigger{},
+	}
+
+	positions, err := openPositions(e.positionService, models.PositionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string]bool)
+	for _, openPosition := range positions {
+		users[openPosition.UserID] = true
+	}
+	e.mutex.RLock()
+	for userID := range e.orders {
+		users[userID] = true
+	}
+	e.mutex.RUnlock()
+
+	for userID := range users {
+		triggers, err := e.evaluate(userID, now)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("user %s: %v", userID, err))
+			continue
+		}
+		result.Triggers = append(result.Triggers, triggers...)
+		for _, trigger := range triggers {
+			result.Errors = append(result.Errors, trigger.Errors...)
+		}
+	}
+
+	return result, nil
+}
+
+// evaluate evaluates the rules applying to a user at now, taking the action of
+// those whose conditions have come to hold
+func (e *RuleEngine) evaluate(userID string, now time.Time) ([]RuleTrigger, error) {
+	rules := e.GetRules(userID)
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	values, err := e.metrics(userID, rules, now, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var fired []Rule
+	e.mutex.Lock()
+	for _, rule := range rules {
+		if !rule.holds(values) {
+			delete(e.active[rule.ID], userID)
+			continue
+		}
+		if e.active[rule.ID][userID] {
+			continue
+		}
+		if e.active[rule.ID] == nil {
+			e.active[rule.ID] = make(map[string]bool)
+		}
+		e.active[rule.ID][userID] = true
+		fired = append(fired, rule)
+	}
+	e.mutex.Unlock()
+
+	triggers := make([]RuleTrigger, 0, len(fired))
+	for _, rule := range fired {
+		trigger := RuleTrigger{
+			RuleID:   rule.ID,
+			RuleName: rule.Name,
+			UserID:   userID,
+			Action:   rule.Action,
+			Values:   make(map[RuleMetric]float64),
+			Time:     now,
+		}
+		for _, condition := range rule.Conditions {
+			trigger.Values[condition.Metric] = values[condition.Metric]
+		}
+
+		log.Printf("Risk rule %s (%s) triggered for user %s", rule.Name, rule.ID, userID)
+		trigger.Errors = e.act(&rule, userID, now)
+		triggers = append(triggers, trigger)
+	}
+
+	if len(triggers) > 0 {
+		e.mutex.Lock()
+		e.triggers = append(e.triggers, triggers...)
+		if len(e.triggers) > maxTriggers {
+			e.triggers = append([]RuleTrigger{}, e.triggers[len(e.triggers)-maxTriggers:]...)
+		}
+		e.mutex.Unlock()
+	}
+
+	return triggers, nil
+}
+
+// act takes the action of a rule triggered for a user, returning any errors as
+// messages. Blocking takes effect in CheckOrder.
+func (e *RuleEngine) act(rule *Rule, userID string, now time.Time) []string {
+	conditions := make([]string, len(rule.Conditions))
+	for i, condition := range rule.Conditions {
+		conditions[i] = condition.String()
+	}
+	reason := fmt.Sprintf("risk rule %s triggered: %s", rule.Name, strings.Join(conditions, " and "))
+
+	switch rule.Action {
+	case RuleActionAlert:
+		if e.notifier == nil {
+			return nil
+		}
+		notification := Notification{
+			UserID:  userID,
+			Type:    NotificationRiskRuleTriggered,
+			Message: reason,
+			Time:    now,
+		}
+		if err := e.notifier.Notify(notification); err != nil {
+			return []string{fmt.Sprintf("notifying user %s: %v", userID, err)}
+		}
+	case RuleActionSquareOff:
+		if e.killSwitch == nil {
+			return nil
+		}
+		request := killswitch.KillRequest{
+			Scope:            killswitch.ScopeUser,
+			UserID:           userID,
+			FlattenPositions: true,
+			Reason:           reason,
+		}
+		killResult, err := e.killSwitch.Kill(request, ruleEngineActor)
+		if err != nil {
+			return []string{fmt.Sprintf("square-off of user %s: %v", userID, err)}
+		}
+		return killResult.Errors
+	}
+
+	return nil
+}
+
+// metrics returns the values at now of the metrics a user's rules have conditions
+// on. Pending orders are counted towards the order rate, for orders about to be
+// placed.
+func (e *RuleEngine) metrics(userID string, rules []Rule, now time.Time, pending int) (map[RuleMetric]float64, error) {
+	needed := make(map[RuleMetric]bool)
+	for _, rule := range rules {
+		for _, condition := range rule.Conditions {
+			needed[condition.Metric] = true
+		}
+	}
+
+	values := make(map[RuleMetric]float64)
+	local := now.In(e.config.Location)
+
+	if needed[RuleMetricPnL] {
+		startOfDay := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, e.config.Location)
+		open, closed, err := dayPositions(e.positionService, userID, startOfDay)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get positions: %w", err)
+		}
+		values[RuleMetricPnL] = positionsPnL(e.closes, open, closed, startOfDay)
+	}
+
+	if needed[RuleMetricDelta] || needed[RuleMetricGamma] || needed[RuleMetricTheta] || needed[RuleMetricVega] {
+		positions, err := openPositions(e.positionService, models.PositionFilter{UserID: userID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get positions: %w", err)
+		}
+		for i := range positions {
+			greeks, err := e.positionService.CalculateGreeks(&positions[i])
+			if err != nil {
+				return nil, fmt.Errorf("position %s: %w", positions[i].ID, err)
+			}
+			values[RuleMetricDelta] += greeks.Delta
+			values[RuleMetricGamma] += greeks.Gamma
+			values[RuleMetricTheta] += greeks.Theta
+			values[RuleMetricVega] += greeks.Vega
+		}
+	}
+
+	if needed[RuleMetricOrderRate] {
+		values[RuleMetricOrderRate] = float64(e.orderCount(userID, now) + pending)
+	}
+
+	if needed[RuleMetricTimeOfDay] {
+		values[RuleMetricTimeOfDay] = float64(local.Hour()*60 + local.Minute())
+	}
+
+	return values, nil
+}
+
+// orderCount returns the number of orders a user placed over the rate window up to
+// now, forgetting older ones
+func (e *RuleEngine) orderCount(userID string, now time.Time) int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	since := now.Add(-e.config.RateWindow)
+	var recent []time.Time
+	count := 0
+	for _, placedAt := range e.orders[userID] {
+		if placedAt.After(since) {
+			recent = append(recent, placedAt)
+			if !placedAt.After(now) {
+				count++
+			}
+		}
+	}
+	if len(recent) == 0 {
+		delete(e.orders, userID)
+	} else {
+		e.orders[userID] = recent
+	}
+
+	return count
+}
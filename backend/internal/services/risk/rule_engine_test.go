@@ -0,0 +1,137 @@
+package risk
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/messagequeue"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/killswitch"
+)
+
+func TestRuleCRUD(t *testing.T) {
+	engine := NewRuleEngine(new(MockPositionService), nil, nil, nil, RuleEngineConfig{})
+
+	// Rules need conditions, and times of day a valid time
+	_, err := engine.CreateRule(&Rule{Name: "No conditions", Action: RuleActionAlert})
+	assert.Error(t, err)
+	_, err = engine.CreateRule(&Rule{Name: "Late", Conditions: []Condition{{Metric: RuleMetricTimeOfDay, Operator: RuleOperatorGTE, Time: "25:00"}}, Action: RuleActionBlock})
+	assert.Error(t, err)
+	_, err = engine.CreateRule(&Rule{Name: "Loss", Conditions: []Condition{{Metric: RuleMetricPnL, Operator: "BELOW", Value: -1000}}, Action: RuleActionAlert})
+	assert.Error(t, err)
+
+	rule, err := engine.CreateRule(&Rule{Name: "Loss", UserID: "user1", Conditions: []Condition{{Metric: RuleMetricPnL, Operator: RuleOperatorLT, Value: -1000}}, Action: RuleActionAlert, CreatedBy: "admin1"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rule.ID)
+	_, err = engine.CreateRule(&Rule{Name: "Late", Conditions: []Condition{{Metric: RuleMetricTimeOfDay, Operator: RuleOperatorGTE, Time: "15:15"}}, Action: RuleActionBlock, CreatedBy: "admin1"})
+	assert.NoError(t, err)
+
+	// Users see their own rules and those applying to everyone
+	assert.Len(t, engine.GetRules("user1"), 2)
+	assert.Len(t, engine.GetRules("user2"), 1)
+
+	rule.Action = RuleActionSquareOff
+	updated, err := engine.UpdateRule(rule)
+	assert.NoError(t, err)
+	assert.Equal(t, RuleActionSquareOff, updated.Action)
+	assert.Equal(t, "admin1", updated.CreatedBy)
+
+	assert.NoError(t, engine.DeleteRule(rule.ID))
+	_, err = engine.GetRule(rule.ID)
+	assert.Equal(t, ErrRuleNotFound, err)
+	assert.Equal(t, ErrRuleNotFound, engine.DeleteRule(rule.ID))
+}
+
+func TestRuleEngineRun(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockKillSwitch := new(MockKillSwitchService)
+	mockNotifier := new(MockNotifier)
+	engine := NewRuleEngine(mockPositions, nil, mockKillSwitch, mockNotifier, RuleEngineConfig{})
+
+	alert, err := engine.CreateRule(&Rule{Name: "Loss alert", Conditions: []Condition{{Metric: RuleMetricPnL, Operator: RuleOperatorLT, Value: -3000}}, Action: RuleActionAlert})
+	assert.NoError(t, err)
+	squareOff, err := engine.CreateRule(&Rule{Name: "Loss square-off", UserID: "user1", Conditions: []Condition{{Metric: RuleMetricPnL, Operator: RuleOperatorLTE, Value: -5000}}, Action: RuleActionSquareOff})
+	assert.NoError(t, err)
+
+	mockNotifier.On("Notify", mock.MatchedBy(func(n Notification) bool {
+		return n.UserID == "user1" && n.Type == NotificationRiskRuleTriggered
+	})).Return(nil)
+	mockKillSwitch.On("Kill", mock.MatchedBy(func(r killswitch.KillRequest) bool {
+		return r.Scope == killswitch.ScopeUser && r.UserID == "user1" && r.FlattenPositions
+	}), ruleEngineActor).Return(&killswitch.KillResult{}, nil)
+
+	// The day's loss is 4000, the alert triggers once while it holds
+	mockPositions.setPositions(userPositions(-1000))
+	result, err := engine.Run(time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, result.Triggers, 1)
+	assert.Equal(t, alert.ID, result.Triggers[0].RuleID)
+	assert.Equal(t, -4000.0, result.Triggers[0].Values[RuleMetricPnL])
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 1)
+
+	result, err = engine.Run(time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, result.Triggers)
+
+	// At a loss of 5500 the user is squared off
+	mockPositions.setPositions(userPositions(-2500))
+	result, err = engine.Run(time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, result.Triggers, 1)
+	assert.Equal(t, squareOff.ID, result.Triggers[0].RuleID)
+	mockKillSwitch.AssertNumberOfCalls(t, "Kill", 1)
+
+	// Once the loss recovers the rules trigger again when it returns
+	mockPositions.setPositions(userPositions(0))
+	result, err = engine.Run(time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, result.Triggers)
+
+	mockPositions.setPositions(userPositions(-1000))
+	result, err = engine.Run(time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, result.Triggers, 1)
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 2)
+	assert.Len(t, engine.GetTriggers(), 3)
+}
+
+func TestRuleEngineCheckOrder(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	engine := NewRuleEngine(mockPositions, nil, nil, nil, RuleEngineConfig{})
+	mockPositions.setPositions(userPositions(0))
+	mockPositions.On("CalculateGreeks", mock.Anything).Return(&models.Greeks{Delta: 150}, nil)
+
+	_, err := engine.CreateRule(&Rule{Name: "Order rate", Conditions: []Condition{{Metric: RuleMetricOrderRate, Operator: RuleOperatorGT, Value: 2}}, Action: RuleActionBlock})
+	assert.NoError(t, err)
+	_, err = engine.CreateRule(&Rule{Name: "Delta", UserID: "user1", Conditions: []Condition{
+		{Metric: RuleMetricDelta, Operator: RuleOperatorGT, Value: 100},
+		{Metric: RuleMetricTimeOfDay, Operator: RuleOperatorGTE, Time: "00:00"},
+	}, Action: RuleActionBlock})
+	assert.NoError(t, err)
+
+	// user1's delta is past 100, only exits are allowed
+	err = engine.CheckOrder(&models.Order{UserID: "user1", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 15})
+	assert.True(t, errors.Is(err, ErrRuleBlocked))
+	assert.Contains(t, err.Error(), "Delta")
+	assert.NoError(t, engine.CheckOrder(&models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionSell, Quantity: 50}))
+
+	// user2 placed two orders in the last minute, a third is one too many
+	order := &models.Order{UserID: "user2", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50}
+	assert.NoError(t, engine.CheckOrder(order))
+	engine.HandleOrderEvent(messagequeue.OrderEvent{Type: messagequeue.OrderNew, UserID: "user2", Timestamp: time.Now()})
+	data, err := json.Marshal(messagequeue.Message{Type: messagequeue.OrderNew, Timestamp: time.Now(), Payload: messagequeue.OrderEvent{Type: messagequeue.OrderNew, UserID: "user2", Timestamp: time.Now()}})
+	assert.NoError(t, err)
+	assert.NoError(t, engine.HandleMessage(data))
+	err = engine.CheckOrder(order)
+	assert.True(t, errors.Is(err, ErrRuleBlocked))
+
+	// Fills do not count, and orders fall out of the window
+	engine.HandleOrderEvent(messagequeue.OrderEvent{Type: messagequeue.OrderFill, UserID: "user3", Timestamp: time.Now()})
+	engine.HandleOrderEvent(messagequeue.OrderEvent{Type: messagequeue.OrderNew, UserID: "user3", Timestamp: time.Now().Add(-2 * time.Minute)})
+	engine.HandleOrderEvent(messagequeue.OrderEvent{Type: messagequeue.OrderNew, UserID: "user3", Timestamp: time.Now().Add(-2 * time.Minute)})
+	assert.NoError(t, engine.CheckOrder(&models.Order{UserID: "user3", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50}))
+}
@@ -0,0 +1,370 @@
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// ErrStrategyHalted is returned for orders opening or adding to positions of a
+// strategy halted on breaching a daily limit, until it is re-armed
+var ErrStrategyHalted = errors.New("strategy is halted, re-arm it to trade again")
+
+// HaltReason is the daily limit a strategy breached
+type HaltReason string
+
+const (
+	HaltReasonMaxLoss   HaltReason = "MAX_LOSS"
+	HaltReasonMaxTrades HaltReason = "MAX_TRADES"
+)
+
+// StrategyHalter looks up, halts and re-arms strategies
+type StrategyHalter interface {
+	GetStrategyByID(id string) (*models.Strategy, error)
+	HaltStrategy(strategyID string, reason string) error
+	RearmStrategy(strategyID string) error
+}
+
+// PositionExiter exits positions with their portfolios' exit settings, returning
+// the IDs of the exit orders placed and why the others failed
+type PositionExiter interface {
+	ExitPositions(positions []models.Position) ([]string, []string)
+}
+
+// StrategyLimitConfig configures the strategy limit monitor
+type StrategyLimitConfig struct {
+	Location *time.Location // Time zone trading days start in, defaults to the local time zone
+	Interval time.Duration  // Between runs, defaults to DefaultInterval
+}
+
+// StrategyLimitStatus is where a strategy stands against its daily limits
+type StrategyLimitStatus struct {
+	StrategyID string                `json:"strategyId"`
+	UserID     string                `json:"userId"`
+	Status     models.StrategyStatus `json:"status"`
+	Since      time.Time             `json:"since"` // Start of the trading day, or when the strategy was re-armed
+	PnL        float64               `json:"pnl"`
+	MaxLoss    float64               `json:"maxLoss"` // 0 when the strategy has none
+	Trades     int                   `json:"trades"`  // Positions opened or closed since
+	MaxTrades  int                   `json:"maxTrades"`
+	Halted     bool                  `json:"halted"`
+	HaltReason HaltReason            `json:"haltReason,omitempty"` // Of a halt by this evaluation
+	ExitOrders []string              `json:"exitOrders,omitempty"` // Placed on the halt
+	Errors     []string              `json:"errors,omitempty"`
+}
+
+// StrategyLimitRunResult is the outcome of one run of the strategy limit monitor
+type StrategyLimitRunResult struct {
+	Time   time.Time             `json:"time"`
+	Halts  []StrategyLimitStatus `json:"halts"` // Strategies halted during the run
+	Errors []string              `json:"errors,omitempty"`
+}
+
+// StrategyLimitMonitor enforces the daily limits of strategies: the max loss per
+// strategy and the max trades per day. A strategy's P&L is that of its open
+// positions and of its positions closed that day, those carried over counting
+// from the previous close, and its trades the positions it opened or closed that
+// day, both counting from when it was re-armed if it was that day.
+// A strategy breaching a limit is halted, its open positions exited with their
+// portfolios' exit settings and its user notified. A halted strategy stays halted,
+// its orders refused except exits, until it is explicitly re-armed.
+type StrategyLimitMonitor struct {
+	positionService position.PositionService
+	closes          ClosePriceProvider
+	strategies      StrategyHalter
+	exiter          PositionExiter
+	notifier        Notifier
+	config          StrategyLimitConfig
+	stop            chan struct{}
+	mutex           sync.Mutex
+}
+
+// NewStrategyLimitMonitor creates a new StrategyLimitMonitor. Without closes
+// positions carried over count their P&L since entry, without an exiter halted
+// strategies keep their positions, and without a notifier users are not notified.
+func NewStrategyLimitMonitor(
+	positionService position.PositionService,
+	closes ClosePriceProvider,
+	strategies StrategyHalter,
+	exiter PositionExiter,
+	notifier Notifier,
+	config StrategyLimitConfig,
+) *StrategyLimitMonitor {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+
+	return &StrategyLimitMonitor{
+		positionService: positionService,
+		closes:          closes,
+		strategies:      strategies,
+		exiter:          exiter,
+		notifier:        notifier,
+		config:          config,
+	}
+}
+
+// Start runs the monitor every interval until it is stopped
+func (m *StrategyLimitMonitor) Start() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		return errors.New("strategy limit monitor is already running")
+	}
+	m.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := m.Run(now)
+				if err != nil {
+					log.Printf("Error running strategy limit monitor: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Strategy limit monitor error: %s", message)
+				}
+			}
+		}
+	}(m.stop)
+
+	return nil
+}
+
+// Stop stops the monitor
+func (m *StrategyLimitMonitor) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+// Run evaluates every strategy with open positions, or positions closed on the
+// trading day, at now
+func (m *StrategyLimitMonitor) Run(now time.Time) (*StrategyLimitRunResult, error) {
+	result := &StrategyLimitRunResult{
+		Time:  now,
+		Halts: []StrategyLimitStatus{},
+	}
+
+	open, err := openPositions(m.positionService, models.PositionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	closed, err := listPositions(m.positionService, models.PositionFilter{Status: models.PositionStatusClosed, ClosedFrom: m.startOfDay(now)})
+	if err != nil {
+		return nil, err
+	}
+	strategyIDs := make(map[string]bool)
+	for _, dayPosition := range append(open, closed...) {
+		if dayPosition.StrategyID != "" {
+			strategyIDs[dayPosition.StrategyID] = true
+		}
+	}
+
+	for strategyID := range strategyIDs {
+		strategy, err := m.strategies.GetStrategyByID(strategyID)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("strategy %s: %v", strategyID, err))
+			continue
+		}
+		if strategy.MaxLossPerStrategy <= 0 && strategy.MaxTradesPerDay <= 0 {
+			continue
+		}
+
+		status, err := m.evaluate(strategy, now, 0)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("strategy %s: %v", strategyID, err))
+			continue
+		}
+		result.Errors = append(result.Errors, status.Errors...)
+		if status.HaltReason != "" {
+			result.Halts = append(result.Halts, *status)
+		}
+	}
+
+	return result, nil
+}
+
+// GetStatus evaluates a strategy against its daily limits now
+func (m *StrategyLimitMonitor) GetStatus(strategyID string) (*StrategyLimitStatus, error) {
+	if strategyID == "" {
+		return nil, errors.New("strategy ID is required")
+	}
+
+	strategy, err := m.strategies.GetStrategyByID(strategyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.evaluate(strategy, time.Now(), 0)
+}
+
+// CheckOrder returns ErrStrategyHalted for an order of a halted strategy, unless the
+// order only reduces an open position. The strategy is evaluated with the order
+// as one more trade, so the order that would go past the max trades per day halts
+// the strategy and is refused.
+func (m *StrategyLimitMonitor) CheckOrder(order *models.Order) error {
+	if order == nil || order.StrategyID == "" {
+		return nil
+	}
+
+	strategy, err := m.strategies.GetStrategyByID(order.StrategyID)
+	if err != nil {
+		// Failing to look up the strategy is not a reason to stop trading
+		log.Printf("Error getting strategy %s: %v", order.StrategyID, err)
+		return nil
+	}
+	if strategy.Status != models.StrategyStatusHalted && strategy.MaxLossPerStrategy <= 0 && strategy.MaxTradesPerDay <= 0 {
+		return nil
+	}
+
+	reduces, err := reducesPosition(m.positionService, order)
+	if err != nil {
+		log.Printf("Error checking positions of user %s: %v", order.UserID, err)
+	}
+	if reduces {
+		return nil
+	}
+
+	if strategy.Status == models.StrategyStatusHalted {
+		return fmt.Errorf("%w: %s", ErrStrategyHalted, strategy.HaltReason)
+	}
+
+	status, err := m.evaluate(strategy, time.Now(), 1)
+	if err != nil {
+		log.Printf("Error evaluating limits of strategy %s: %v", strategy.ID, err)
+		return nil
+	}
+	if status.Halted {
+		return fmt.Errorf("%w: %s", ErrStrategyHalted, haltMessage(status))
+	}
+
+	return nil
+}
+
+// Rearm re-arms a halted strategy, its daily limits counting afresh from now
+func (m *StrategyLimitMonitor) Rearm(strategyID string, rearmedBy string) (*StrategyLimitStatus, error) {
+	if strategyID == "" {
+		return nil, errors.New("strategy ID is required")
+	}
+
+	if err := m.strategies.RearmStrategy(strategyID); err != nil {
+		return nil, err
+	}
+	log.Printf("Strategy %s re-armed by %s", strategyID, rearmedBy)
+
+	return m.GetStatus(strategyID)
+}
+
+// evaluate works out a strategy's P&L and trades at now, pending being trades
+// about to be placed, and halts it when it breaches a daily limit it was within
+func (m *StrategyLimitMonitor) evaluate(strategy *models.Strategy, now time.Time, pending int) (*StrategyLimitStatus, error) {
+	since := m.startOfDay(now)
+	if strategy.RearmedAt.After(since) {
+		since = strategy.RearmedAt
+	}
+
+	open, err := openPositions(m.positionService, models.PositionFilter{StrategyID: strategy.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open positions: %w", err)
+	}
+	closed, err := listPositions(m.positionService, models.PositionFilter{StrategyID: strategy.ID, Status: models.PositionStatusClosed, ClosedFrom: since})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get closed positions: %w", err)
+	}
+
+	status := &StrategyLimitStatus{
+		StrategyID: strategy.ID,
+		UserID:     strategy.UserID,
+		Status:     strategy.Status,
+		Since:      since,
+		PnL:        positionsPnL(m.closes, open, closed, m.startOfDay(now)),
+		MaxLoss:    strategy.MaxLossPerStrategy,
+		Trades:     len(closed),
+		MaxTrades:  strategy.MaxTradesPerDay,
+		Halted:     strategy.Status == models.StrategyStatusHalted,
+	}
+	for _, openPosition := range open {
+		if !openPosition.CreatedAt.Before(since) {
+			status.Trades++
+		}
+	}
+
+	if status.Halted || (strategy.Status != models.StrategyStatusActive && strategy.Status != models.StrategyStatusPaused) {
+		return status, nil
+	}
+	switch {
+	case status.MaxLoss > 0 && -status.PnL >= status.MaxLoss:
+		status.HaltReason = HaltReasonMaxLoss
+	case status.MaxTrades > 0 && status.Trades+pending > status.MaxTrades:
+		status.HaltReason = HaltReasonMaxTrades
+	default:
+		return status, nil
+	}
+
+	message := haltMessage(status)
+	if err := m.strategies.HaltStrategy(strategy.ID, message); err != nil {
+		// Halted or stopped meanwhile, by another evaluation or the user
+		status.HaltReason = ""
+		return status, fmt.Errorf("failed to halt: %w", err)
+	}
+	status.Status = models.StrategyStatusHalted
+	status.Halted = true
+	log.Printf("Strategy %s of user %s halted: %s", strategy.ID, strategy.UserID, message)
+
+	if m.exiter != nil && len(open) > 0 {
+		orderIDs, errs := m.exiter.ExitPositions(open)
+		status.ExitOrders = orderIDs
+		for _, message := range errs {
+			status.Errors = append(status.Errors, fmt.Sprintf("strategy %s: %s", strategy.ID, message))
+		}
+	}
+
+	if m.notifier != nil {
+		notification := Notification{
+			UserID:  strategy.UserID,
+			Type:    NotificationStrategyHalted,
+			Message: fmt.Sprintf("Strategy %s halted: %s. Re-arm it to trade again.", strategy.Name, message),
+			Time:    now,
+		}
+		if err := m.notifier.Notify(notification); err != nil {
+			log.Printf("Error notifying user %s of the halt of strategy %s: %v", strategy.UserID, strategy.ID, err)
+			status.Errors = append(status.Errors, fmt.Sprintf("strategy %s: failed to notify: %v", strategy.ID, err))
+		}
+	}
+
+	return status, nil
+}
+
+// startOfDay returns the start of the trading day of now
+func (m *StrategyLimitMonitor) startOfDay(now time.Time) time.Time {
+	now = now.In(m.config.Location)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, m.config.Location)
+}
+
+// haltMessage describes the limit a strategy breached
+func haltMessage(status *StrategyLimitStatus) string {
+	if status.HaltReason == HaltReasonMaxTrades {
+		return fmt.Sprintf("max trades per day of %d reached", status.MaxTrades)
+	}
+	return fmt.Sprintf("max loss of %.2f reached with a loss of %.2f", status.MaxLoss, -status.PnL)
+}
@@ -0,0 +1,222 @@
+package risk
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockStrategyHalter is a mock implementation of the StrategyHalter interface,
+// halting and re-arming the strategies it holds when the calls succeed
+type MockStrategyHalter struct {
+	mock.Mock
+	mutex      sync.Mutex
+	strategies map[string]models.Strategy
+}
+
+func (m *MockStrategyHalter) setStrategies(strategies ...models.Strategy) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.strategies = make(map[string]models.Strategy)
+	for _, strategy := range strategies {
+		m.strategies[strategy.ID] = strategy
+	}
+}
+
+func (m *MockStrategyHalter) GetStrategyByID(id string) (*models.Strategy, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	strategy, exists := m.strategies[id]
+	if !exists {
+		return nil, errors.New("strategy not found")
+	}
+	return &strategy, nil
+}
+
+func (m *MockStrategyHalter) HaltStrategy(strategyID string, reason string) error {
+	args := m.Called(strategyID, reason)
+	if args.Error(0) == nil {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		strategy := m.strategies[strategyID]
+		strategy.Status = models.StrategyStatusHalted
+		strategy.HaltReason = reason
+		strategy.HaltedAt = time.Now()
+		m.strategies[strategyID] = strategy
+	}
+	return args.Error(0)
+}
+
+func (m *MockStrategyHalter) RearmStrategy(strategyID string) error {
+	args := m.Called(strategyID)
+	if args.Error(0) == nil {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		strategy := m.strategies[strategyID]
+		strategy.Status = models.StrategyStatusActive
+		strategy.HaltReason = ""
+		strategy.RearmedAt = time.Now()
+		m.strategies[strategyID] = strategy
+	}
+	return args.Error(0)
+}
+
+// MockPositionExiter is a mock implementation of the PositionExiter interface
+type MockPositionExiter struct {
+	mock.Mock
+}
+
+func (m *MockPositionExiter) ExitPositions(positions []models.Position) ([]string, []string) {
+	args := m.Called(positions)
+	return args.Get(0).([]string), args.Get(1).([]string)
+}
+
+// strategyPosition assigns a position to strategy1
+func strategyPosition(p models.Position) models.Position {
+	p.StrategyID = "strategy1"
+	return p
+}
+
+func TestStrategyLimitMonitorMaxLoss(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockStrategies := new(MockStrategyHalter)
+	mockExiter := new(MockPositionExiter)
+	mockNotifier := new(MockNotifier)
+	monitor := NewStrategyLimitMonitor(mockPositions, nil, mockStrategies, mockExiter, mockNotifier, StrategyLimitConfig{})
+
+	mockStrategies.setStrategies(models.Strategy{ID: "strategy1", UserID: "user1", Name: "Short straddle", Status: models.StrategyStatusActive, MaxLossPerStrategy: 5000})
+	mockStrategies.On("HaltStrategy", "strategy1", mock.Anything).Return(nil)
+	mockStrategies.On("RearmStrategy", "strategy1").Return(nil)
+	mockExiter.On("ExitPositions", mock.Anything).Return([]string{"exit1"}, []string{})
+	mockNotifier.On("Notify", mock.Anything).Return(nil)
+
+	now := time.Now()
+	entry := &models.Order{UserID: "user1", StrategyID: "strategy1", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.OrderDirectionSell, Quantity: 15}
+	exit := &models.Order{UserID: "user1", StrategyID: "strategy1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionSell, Quantity: 50}
+
+	// Within the max loss, counting the positions of other strategies out
+	other := closedPosition("closed2", "user1", -10000, now.Add(-2*time.Minute))
+	mockPositions.setPositions([]models.Position{
+		strategyPosition(openPosition(-3000, now.Add(-5*time.Minute))),
+		strategyPosition(closedPosition("closed1", "user1", -1500, now.Add(-3*time.Minute))),
+		other,
+	})
+	result, err := monitor.Run(now)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Halts)
+	assert.NoError(t, monitor.CheckOrder(entry))
+
+	// Reaching it halts the strategy and exits its open positions
+	mockPositions.setPositions([]models.Position{
+		strategyPosition(openPosition(-3600, now.Add(-5*time.Minute))),
+		strategyPosition(closedPosition("closed1", "user1", -1500, now.Add(-3*time.Minute))),
+		other,
+	})
+	result, err = monitor.Run(now)
+	assert.NoError(t, err)
+	assert.Len(t, result.Halts, 1)
+	assert.Equal(t, HaltReasonMaxLoss, result.Halts[0].HaltReason)
+	assert.Equal(t, -5100.0, result.Halts[0].PnL)
+	assert.Equal(t, []string{"exit1"}, result.Halts[0].ExitOrders)
+	mockExiter.AssertCalled(t, "ExitPositions", []models.Position{strategyPosition(openPosition(-3600, now.Add(-5*time.Minute)))})
+
+	// Once only, the strategy staying halted
+	result, err = monitor.Run(now)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Halts)
+	mockStrategies.AssertNumberOfCalls(t, "HaltStrategy", 1)
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 1)
+
+	// Refusing its entries but not its exits
+	err = monitor.CheckOrder(entry)
+	assert.True(t, errors.Is(err, ErrStrategyHalted))
+	assert.NoError(t, monitor.CheckOrder(exit))
+
+	// Until it is re-armed, its losses counting afresh
+	mockPositions.setPositions([]models.Position{
+		strategyPosition(closedPosition("open1", "user1", -3600, now.Add(-time.Minute))),
+		strategyPosition(closedPosition("closed1", "user1", -1500, now.Add(-3*time.Minute))),
+	})
+	status, err := monitor.Rearm("strategy1", "user1")
+	assert.NoError(t, err)
+	assert.False(t, status.Halted)
+	assert.Equal(t, models.StrategyStatusActive, status.Status)
+	assert.Equal(t, 0.0, status.PnL)
+	assert.NoError(t, monitor.CheckOrder(entry))
+}
+
+func TestStrategyLimitMonitorMaxTrades(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockStrategies := new(MockStrategyHalter)
+	mockNotifier := new(MockNotifier)
+	monitor := NewStrategyLimitMonitor(mockPositions, nil, mockStrategies, nil, mockNotifier, StrategyLimitConfig{})
+
+	mockStrategies.setStrategies(
+		models.Strategy{ID: "strategy1", UserID: "user1", Name: "Scalper", Status: models.StrategyStatusActive, MaxTradesPerDay: 2},
+		models.Strategy{ID: "strategy2", UserID: "user1", Name: "Unlimited", Status: models.StrategyStatusActive},
+	)
+	mockStrategies.On("HaltStrategy", "strategy1", mock.Anything).Return(nil)
+	mockNotifier.On("Notify", mock.Anything).Return(errors.New("notification channel unavailable"))
+
+	now := time.Now()
+	entry := &models.Order{UserID: "user1", StrategyID: "strategy1", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.OrderDirectionSell, Quantity: 15}
+
+	// A position opened before the trading day is not a trade of it
+	mockPositions.setPositions([]models.Position{
+		strategyPosition(openPosition(0, now.Add(-48*time.Hour))),
+		strategyPosition(closedPosition("closed1", "user1", 500, now.Add(-2*time.Minute))),
+	})
+	status, err := monitor.GetStatus("strategy1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, status.Trades)
+	assert.NoError(t, monitor.CheckOrder(entry))
+
+	// The order that would go past the max trades halts the strategy
+	mockPositions.setPositions([]models.Position{
+		strategyPosition(openPosition(0, now.Add(-time.Minute))),
+		strategyPosition(closedPosition("closed1", "user1", 500, now.Add(-2*time.Minute))),
+	})
+	err = monitor.CheckOrder(entry)
+	assert.True(t, errors.Is(err, ErrStrategyHalted))
+	mockStrategies.AssertCalled(t, "HaltStrategy", "strategy1", "max trades per day of 2 reached")
+
+	status, err = monitor.GetStatus("strategy1")
+	assert.NoError(t, err)
+	assert.True(t, status.Halted)
+	assert.Equal(t, 2, status.Trades)
+
+	// Orders of strategies without limits, or of no strategy, are not checked
+	assert.NoError(t, monitor.CheckOrder(&models.Order{UserID: "user1", StrategyID: "strategy2", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50}))
+	assert.NoError(t, monitor.CheckOrder(&models.Order{UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Direction: models.OrderDirectionBuy, Quantity: 50}))
+}
+
+func TestStrategyLimitMonitorOvernightPositions(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockCloses := new(MockClosePriceProvider)
+	mockStrategies := new(MockStrategyHalter)
+	monitor := NewStrategyLimitMonitor(mockPositions, mockCloses, mockStrategies, nil, nil, StrategyLimitConfig{})
+
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	mockStrategies.setStrategies(models.Strategy{ID: "strategy1", UserID: "user1", Name: "Overnight short", Status: models.StrategyStatusActive, MaxLossPerStrategy: 1000, MaxTradesPerDay: 5})
+	mockStrategies.On("HaltStrategy", "strategy1", mock.Anything).Return(nil)
+	mockCloses.On("GetPreviousClose", "BANKNIFTY", "NFO", startOfDay).Return(210.0, nil)
+
+	// Sold at 200 yesterday and bought back at 280 today, a loss of 70 a lot on
+	// the previous close of 210
+	mockPositions.setPositions([]models.Position{
+		strategyPosition(models.Position{ID: "overnight", UserID: "user1", Symbol: "BANKNIFTY", Exchange: "NFO", Direction: models.PositionDirectionShort, EntryPrice: 200, ExitPrice: 280, Quantity: 15, ExitQuantity: 15, Status: models.PositionStatusClosed, RealizedPnL: -1200, CreatedAt: startOfDay.Add(-12 * time.Hour), UpdatedAt: now}),
+	})
+	result, err := monitor.Run(now)
+	assert.NoError(t, err)
+	assert.Len(t, result.Halts, 1)
+	assert.Equal(t, HaltReasonMaxLoss, result.Halts[0].HaltReason)
+	assert.Equal(t, -1050.0, result.Halts[0].PnL)
+	assert.Equal(t, 1, result.Halts[0].Trades)
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"trading_platform/backend/internal/models"
+)
+
+// customSymbolPrefix marks virtual symbols backed by uploaded data so they can
+// never shadow a symbol we have market data for
+const customSymbolPrefix = "CSV:"
+
+// maxCustomDataRows limits the size of a single uploaded dataset
+const maxCustomDataRows = 5000000
+
+// customDataset is an uploaded dataset and its rows, sorted by timestamp
+type customDataset struct {
+	info      models.CustomDataset
+	snapshots []models.MarketDataSnapshot
+}
+
+// customTimestampLayouts are the timestamp formats accepted in uploaded files
+var customTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02-01-2006 15:04:05",
+	"02-01-2006",
+}
+
+// csvColumnAliases maps accepted header names to canonical column names
+var csvColumnAliases = map[string]string{
+	"timestamp": "timestamp",
+	"datetime":  "timestamp",
+	"date":      "timestamp",
+	"time":      "timestamp",
+	"open":      "open",
+	"high":      "high",
+	"low":       "low",
+	"close":     "close",
+	"price":     "price",
+	"last":      "price",
+	"ltp":       "price",
+	"volume":    "volume",
+	"bid":       "bid",
+	"ask":       "ask",
+	"bidsize":   "bidSize",
+	"bid_size":  "bidSize",
+	"asksize":   "askSize",
+	"ask_size":  "askSize",
+}
+
+// CustomDataSymbol returns the virtual symbol backtests use for an uploaded dataset
+func CustomDataSymbol(name string) string {
+	if strings.HasPrefix(name, customSymbolPrefix) {
+		return name
+	}
+	return customSymbolPrefix + name
+}
+
+// ImportCustomData parses an OHLCV or tick CSV and registers it under a virtual
+// symbol that backtest sessions can then trade. The file needs a header row with
+// a timestamp column and either open/high/low/close columns (candles) or a
+// price/last column or bid and ask columns (ticks). Volume and bid/ask sizes are
+// optional. Uploading to an existing symbol replaces its data.
+func (s *BacktestService) ImportCustomData(accountID, name, timeframe string, reader io.Reader) (*models.CustomDataset, error) {
+	if accountID == "" {
+		return nil, errors.New("account ID is required")
+	}
+
+	if strings.TrimPrefix(name, customSymbolPrefix) == "" {
+		return nil, errors.New("symbol is required")
+	}
+
+	if reader == nil {
+		return nil, errors.New("data is required")
+	}
+
+	snapshots, isTick, err := parseCustomDataCSV(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if isTick {
+		timeframe = "tick"
+	} else if timeframe == "" {
+		return nil, errors.New("timeframe is required for candle data")
+	}
+
+	symbol := CustomDataSymbol(name)
+	for i := range snapshots {
+		snapshots[i].Symbol = symbol
+		snapshots[i].Timeframe = timeframe
+		snapshots[i].Source = "UPLOAD"
+	}
+
+	dataset := &customDataset{
+		info: models.CustomDataset{
+			ID:                  uuid.New().String(),
+			SimulationAccountID: accountID,
+			Symbol:              symbol,
+			Timeframe:           timeframe,
+			Rows:                len(snapshots),
+			StartDate:           snapshots[0].Timestamp,
+			EndDate:             snapshots[len(snapshots)-1].Timestamp,
+			CreatedAt:           time.Now(),
+		},
+		snapshots: snapshots,
+	}
+
+	s.customDataMutex.Lock()
+	if existing, exists := s.customData[symbol]; exists && existing.info.SimulationAccountID != accountID {
+		s.customDataMutex.Unlock()
+		return nil, errors.New("symbol is already used by another account")
+	}
+	s.customData[symbol] = dataset
+	s.customDataMutex.Unlock()
+
+	// Drop any series cached from a previous upload
+	s.dataCache.Invalidate(symbol)
+
+	// In a real implementation, we would persist the dataset to object storage
+
+	info := dataset.info
+	return &info, nil
+}
+
+// GetCustomDatasets returns the datasets uploaded by an account
+func (s *BacktestService) GetCustomDatasets(accountID string) ([]models.CustomDataset, error) {
+	if accountID == "" {
+		return nil, errors.New("account ID is required")
+	}
+
+	s.customDataMutex.RLock()
+	defer s.customDataMutex.RUnlock()
+
+	datasets := []models.CustomDataset{}
+	for _, dataset := range s.customData {
+		if dataset.info.SimulationAccountID == accountID {
+			datasets = append(datasets, dataset.info)
+		}
+	}
+
+	sort.Slice(datasets, func(i, j int) bool {
+		return datasets[i].Symbol < datasets[j].Symbol
+	})
+
+	return datasets, nil
+}
+
+// DeleteCustomDataset removes an uploaded dataset
+func (s *BacktestService) DeleteCustomDataset(accountID, symbol string) error {
+	if accountID == "" {
+		return errors.New("account ID is required")
+	}
+
+	symbol = CustomDataSymbol(symbol)
+
+	s.customDataMutex.Lock()
+	dataset, exists := s.customData[symbol]
+	if !exists || dataset.info.SimulationAccountID != accountID {
+		s.customDataMutex.Unlock()
+		return errors.New("dataset not found")
+	}
+	delete(s.customData, symbol)
+	s.customDataMutex.Unlock()
+
+	s.dataCache.Invalidate(symbol)
+	return nil
+}
+
+// loadHistoricalData loads market data for backtests, serving virtual symbols
+// from uploaded datasets and everything else from the market data provider
+func (s *BacktestService) loadHistoricalData(symbol string, startDate, endDate time.Time, timeframe string) ([]models.MarketDataSnapshot, error) {
+	if !strings.HasPrefix(symbol, customSymbolPrefix) {
+		return s.marketSimulationService.GetHistoricalMarketData(symbol, startDate, endDate, timeframe)
+	}
+
+	s.customDataMutex.RLock()
+	dataset, exists := s.customData[symbol]
+	s.customDataMutex.RUnlock()
+
+	if !exists {
+		return nil, errors.New("no uploaded data for symbol: " + symbol)
+	}
+
+	from := sort.Search(len(dataset.snapshots), func(i int) bool {
+		return !dataset.snapshots[i].Timestamp.Before(startDate)
+	})
+	to := sort.Search(len(dataset.snapshots), func(i int) bool {
+		return !dataset.snapshots[i].Timestamp.Before(endDate)
+	})
+
+	return append([]models.MarketDataSnapshot(nil), dataset.snapshots[from:to]...), nil
+}
+
+// parseCustomDataCSV parses uploaded rows sorted by timestamp and reports whether they are ticks
+func parseCustomDataCSV(reader io.Reader) ([]models.MarketDataSnapshot, bool, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, false, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if canonical, exists := csvColumnAliases[name]; exists {
+			if _, duplicate := columns[canonical]; !duplicate {
+				columns[canonical] = i
+			}
+		}
+	}
+
+	if _, exists := columns["timestamp"]; !exists {
+		return nil, false, errors.New("a timestamp column is required")
+	}
+
+	_, hasOpen := columns["open"]
+	_, hasHigh := columns["high"]
+	_, hasLow := columns["low"]
+	_, hasClose := columns["close"]
+	_, hasPrice := columns["price"]
+	_, hasBid := columns["bid"]
+	_, hasAsk := columns["ask"]
+
+	isCandle := hasOpen && hasHigh && hasLow && hasClose
+	isTick := !isCandle && (hasPrice || (hasBid && hasAsk))
+	if !isCandle && !isTick {
+		return nil, false, errors.New("open, high, low and close columns, or price or bid and ask columns, are required")
+	}
+
+	var snapshots []models.MarketDataSnapshot
+	line := 1
+	for {
+		record, err := csvReader.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if len(snapshots) >= maxCustomDataRows {
+			return nil, false, fmt.Errorf("file exceeds the limit of %d rows", maxCustomDataRows)
+		}
+
+		row := customDataRow{record: record, columns: columns}
+
+		var snapshot models.MarketDataSnapshot
+		snapshot.Timestamp = row.timestamp()
+		snapshot.Volume = int64(row.number("volume"))
+		snapshot.Bid = row.number("bid")
+		snapshot.Ask = row.number("ask")
+		snapshot.BidSize = int(row.number("bidSize"))
+		snapshot.AskSize = int(row.number("askSize"))
+
+		if isCandle {
+			snapshot.Open = row.number("open")
+			snapshot.High = row.number("high")
+			snapshot.Low = row.number("low")
+			snapshot.Close = row.number("close")
+		} else {
+			price := row.number("price")
+			if !hasPrice {
+				price = (snapshot.Bid + snapshot.Ask) / 2
+			}
+			snapshot.Open, snapshot.High, snapshot.Low, snapshot.Close = price, price, price, price
+		}
+
+		if row.err != nil {
+			return nil, false, fmt.Errorf("line %d: %w", line, row.err)
+		}
+
+		if snapshot.Close <= 0 || snapshot.Low <= 0 || snapshot.High < snapshot.Low || snapshot.Open > snapshot.High || snapshot.Open < snapshot.Low || snapshot.Close > snapshot.High || snapshot.Close < snapshot.Low {
+			return nil, false, fmt.Errorf("line %d: prices are inconsistent", line)
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if len(snapshots) == 0 {
+		return nil, false, errors.New("file has no data rows")
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+
+	// Ticks may share a timestamp, candles may not
+	if isCandle {
+		for i := 1; i < len(snapshots); i++ {
+			if snapshots[i].Timestamp.Equal(snapshots[i-1].Timestamp) {
+				return nil, false, fmt.Errorf("duplicate candle at %s", snapshots[i].Timestamp.Format(time.RFC3339))
+			}
+		}
+	}
+
+	return snapshots, isTick, nil
+}
+
+// customDataRow reads typed values from a CSV record, keeping the first error
+type customDataRow struct {
+	record  []string
+	columns map[string]int
+	err     error
+}
+
+// value returns the raw value of a column, empty when the column is missing
+func (r *customDataRow) value(column string) string {
+	index, exists := r.columns[column]
+	if !exists || index >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[index])
+}
+
+// number parses a numeric column, treating missing or empty values as zero
+func (r *customDataRow) number(column string) float64 {
+	value := r.value(column)
+	if value == "" || r.err != nil {
+		return 0
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		r.err = fmt.Errorf("invalid %s %q", column, value)
+		return 0
+	}
+	return number
+}
+
+// timestamp parses the timestamp column as a date/time or as Unix seconds or milliseconds
+func (r *customDataRow) timestamp() time.Time {
+	value := r.value("timestamp")
+	if value == "" {
+		r.err = errors.New("timestamp is required")
+		return time.Time{}
+	}
+
+	for _, layout := range customTimestampLayouts {
+		if timestamp, err := time.Parse(layout, value); err == nil {
+			return timestamp
+		}
+	}
+
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Values too large to be Unix seconds are taken to be milliseconds
+		if unix > 1e12 {
+			return time.UnixMilli(unix).UTC()
+		}
+		return time.Unix(unix, 0).UTC()
+	}
+
+	r.err = fmt.Errorf("invalid timestamp %q", value)
+	return time.Time{}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"trading_platform/backend/internal/models"
+)
+
+const (
+	// misSquareOffTag tags orders closing MIS positions at the square-off time
+	misSquareOffTag = "MIS_SQUARE_OFF"
+
+	// marginCallTag tags orders liquidating the book after a margin call
+	marginCallTag = "MARGIN_CALL"
+)
+
+// intradayMargin is the backtester's intraday margin model and its statistics
+type intradayMargin struct {
+	config      models.IntradayMarginConfig
+	location    *time.Location
+	squareOffAt time.Duration // Time of day of the square-off, negative when disabled
+	report      models.IntradayMarginReport
+}
+
+// marginExposure is the exposure margin is charged on for one symbol
+type marginExposure struct {
+	position    int
+	buys        int // Unfilled quantity of pending buys
+	sells       int // Unfilled quantity of pending sells
+	productType models.ProductType
+	price       float64
+}
+
+// SetIntradayMargin makes the backtester block margin for positions and pending
+// orders, reject orders the account cannot margin, liquidate the book when equity
+// falls below the maintenance margin, and square off MIS positions at the
+// square-off time. Without it position sizes are limited by nothing.
+func (b *StrategyBacktester) SetIntradayMargin(config models.IntradayMarginConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if config.MarginRate == 0 {
+		config.MarginRate = 1
+	}
+
+	if config.MISLeverage == 0 {
+		config.MISLeverage = 1
+	}
+
+	// Validate has already checked the timezone and time
+	location, _ := time.LoadLocation(config.Timezone)
+
+	margin := &intradayMargin{
+		config:      config,
+		location:    location,
+		squareOffAt: -1,
+	}
+
+	if config.SquareOffTime != "" {
+		clock, _ := time.Parse("15:04:05", config.SquareOffTime)
+		margin.squareOffAt = timeOfDay(clock)
+	}
+
+	b.margin = margin
+	return nil
+}
+
+// checkIntradayMargin rejects an order that would take the margin needed for the
+// book, including pending orders, beyond the account's equity, or that would add
+// to MIS exposure after the square-off time. Orders that reduce exposure are always
+// accepted. Orders without a product type become MIS orders.
+func (b *StrategyBacktester) checkIntradayMargin(order *models.Order) error {
+	if b.margin == nil {
+		return nil
+	}
+
+	if order.ProductType == "" {
+		order.ProductType = models.ProductTypeMIS
+	}
+
+	orders := make([]models.Order, 0, len(b.pending)+1)
+	for _, pending := range b.pending {
+		orders = append(orders, pending.Order)
+	}
+
+	before := b.marginRequired(orders)
+	after := b.marginRequired(append(orders, *order))
+	if after <= before {
+		return nil
+	}
+
+	if order.ProductType == models.ProductTypeMIS && b.margin.pastSquareOff(b.now) {
+		return errors.New("MIS orders cannot add exposure after the square-off time")
+	}
+
+	if equity := b.Equity(); after > equity {
+		b.margin.report.MarginRejections++
+		return fmt.Errorf("insufficient margin: %.2f required, %.2f available", after, equity)
+	}
+
+	return nil
+}
+
+// enforceIntradayMargin squares off MIS positions once the square-off time has
+// passed and liquidates every position when equity has fallen below the maintenance
+// margin. Positions are closed immediately at price for symbol, the price of the
+// current event, and at the last known price for other symbols.
+func (b *StrategyBacktester) enforceIntradayMargin(symbol string, price float64) error {
+	if b.margin == nil {
+		return nil
+	}
+
+	b.lastPrices[symbol] = price
+
+	used := b.marginRequired(nil)
+	if used > b.margin.report.PeakMarginUsed {
+		b.margin.report.PeakMarginUsed = used
+	}
+
+	var exits []*models.SimulationOrder
+	if b.margin.pastSquareOff(b.now) {
+		// Brokers cancel pending MIS orders along with closing the positions
+		var remaining []*models.SimulationOrder
+		for _, order := range b.pending {
+			if order.ProductType == models.ProductTypeMIS {
+				delete(b.triggered, order.ID)
+				continue
+			}
+			remaining = append(remaining, order)
+		}
+		b.pending = remaining
+
+		for _, held := range b.symbols {
+			if b.positions[held].productType != models.ProductTypeMIS {
+				continue
+			}
+
+			if order := b.exitPosition(held, "", misSquareOffTag); order != nil {
+				b.margin.report.SquareOffs++
+				exits = append(exits, order)
+			}
+		}
+
+		used = b.marginRequired(nil)
+	}
+
+	if b.margin.config.MaintenanceMargin > 0 && used > 0 && b.Equity() < b.margin.config.MaintenanceMargin*used {
+		b.margin.report.MarginCalls++
+		b.pending = nil
+		b.triggered = make(map[string]bool)
+
+		for _, held := range b.symbols {
+			if order := b.exitPosition(held, "", marginCallTag); order != nil {
+				exits = append(exits, order)
+			}
+		}
+	}
+
+	return b.notifyFills(exits)
+}
+
+// marginRequired returns the margin needed for the open positions and orders. Each
+// symbol is charged on the larger of its exposures should all buys or all sells fill.
+func (b *StrategyBacktester) marginRequired(orders []models.Order) float64 {
+	exposures := make(map[string]*marginExposure)
+	exposure := func(symbol string) *marginExposure {
+		if _, exists := exposures[symbol]; !exists {
+			exposures[symbol] = &marginExposure{price: b.lastPrices[symbol]}
+		}
+		return exposures[symbol]
+	}
+
+	for _, symbol := range b.symbols {
+		if position := b.positions[symbol]; position.quantity != 0 {
+			e := exposure(symbol)
+			e.position = position.quantity
+			e.productType = position.productType
+		}
+	}
+
+	for _, order := range orders {
+		e := exposure(order.Symbol)
+		if e.position == 0 && e.productType == "" {
+			e.productType = order.ProductType
+		}
+		if e.price == 0 {
+			e.price = order.Price
+		}
+
+		if order.Direction == models.OrderDirectionBuy {
+			e.buys += order.Quantity - order.FilledQuantity
+		} else {
+			e.sells += order.Quantity - order.FilledQuantity
+		}
+	}
+
+	symbols := make([]string, 0, len(exposures))
+	for symbol := range exposures {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	margin := 0.0
+	for _, symbol := range symbols {
+		e := exposures[symbol]
+
+		quantity := abs(e.position + e.buys)
+		if short := abs(e.position - e.sells); short > quantity {
+			quantity = short
+		}
+
+		margin += b.margin.symbolMargin(symbol, e.productType, quantity, e.price)
+	}
+
+	return margin
+}
+
+// symbolMargin returns the margin for holding quantity units of a symbol
+func (m *intradayMargin) symbolMargin(symbol string, productType models.ProductType, quantity int, price float64) float64 {
+	margin := m.config.MarginRate * price * float64(quantity)
+	if perUnit, exists := m.config.SymbolMargins[symbol]; exists {
+		margin = perUnit * float64(quantity)
+	}
+
+	if productType == models.ProductTypeMIS {
+		margin /= m.config.MISLeverage
+	}
+
+	return margin
+}
+
+// pastSquareOff reports whether the square-off time has passed on now's trading day
+func (m *intradayMargin) pastSquareOff(now time.Time) bool {
+	return m.squareOffAt >= 0 && timeOfDay(now.In(m.location)) >= m.squareOffAt
+}
+
+// timeOfDay returns the time elapsed since midnight
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+
+	"trading_platform/backend/internal/models"
+)
+
+const (
+	// defaultMonteCarloSimulations is the number of resamples run for performance metrics
+	defaultMonteCarloSimulations = 1000
+
+	// defaultRuinThreshold treats losing half of the initial equity as ruin
+	defaultRuinThreshold = 0.5
+)
+
+// RunMonteCarloAnalysis bootstraps the per-period returns of a backtest equity curve
+// with replacement to build simulated equity curves, and reports confidence intervals
+// for max drawdown, CAGR and final equity along with the risk of ruin.
+// periodsPerYear is used to annualize CAGR (252 for daily bars).
+func RunMonteCarloAnalysis(equityCurve []float64, simulations int, periodsPerYear float64, ruinThreshold float64, seed int64) (*models.MonteCarloResult, error) {
+	if len(equityCurve) < 2 {
+		return nil, errors.New("at least two equity points are required")
+	}
+
+	if simulations <= 0 {
+		return nil, errors.New("number of simulations must be greater than zero")
+	}
+
+	if periodsPerYear <= 0 {
+		return nil, errors.New("periods per year must be greater than zero")
+	}
+
+	if ruinThreshold <= 0 || ruinThreshold >= 1 {
+		return nil, errors.New("ruin threshold must be between 0 and 1")
+	}
+
+	initialEquity := equityCurve[0]
+	if initialEquity <= 0 {
+		return nil, errors.New("initial equity must be greater than zero")
+	}
+
+	returns := make([]float64, 0, len(equityCurve)-1)
+	for i := 1; i < len(equityCurve); i++ {
+		if equityCurve[i-1] <= 0 {
+			return nil, errors.New("equity curve must stay positive")
+		}
+		returns = append(returns, equityCurve[i]/equityCurve[i-1]-1)
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	years := float64(len(returns)) / periodsPerYear
+
+	drawdowns := make([]float64, simulations)
+	cagrs := make([]float64, simulations)
+	finals := make([]float64, simulations)
+	ruined := 0
+
+	for sim := 0; sim < simulations; sim++ {
+		equity := initialEquity
+		peak := equity
+		maxDrawdown := 0.0
+		isRuined := false
+
+		for range returns {
+			equity *= 1 + returns[random.Intn(len(returns))]
+			if equity < 0 {
+				equity = 0
+			}
+
+			if equity > peak {
+				peak = equity
+			}
+
+			if drawdown := (peak - equity) / peak * 100.0; drawdown > maxDrawdown {
+				maxDrawdown = drawdown
+			}
+
+			if equity <= initialEquity*(1-ruinThreshold) {
+				isRuined = true
+			}
+		}
+
+		if isRuined {
+			ruined++
+		}
+
+		drawdowns[sim] = maxDrawdown
+		finals[sim] = equity
+		cagrs[sim] = (math.Pow(equity/initialEquity, 1/years) - 1) * 100.0
+	}
+
+	return &models.MonteCarloResult{
+		Simulations:   simulations,
+		MaxDrawdown:   confidenceInterval(drawdowns),
+		CAGR:          confidenceInterval(cagrs),
+		FinalEquity:   confidenceInterval(finals),
+		RiskOfRuin:    float64(ruined) / float64(simulations),
+		RuinThreshold: ruinThreshold,
+	}, nil
+}
+
+// confidenceInterval returns the 5th, 50th and 95th percentiles of the values
+func confidenceInterval(values []float64) models.ConfidenceInterval {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	return models.ConfidenceInterval{
+		P5:     percentile(sorted, 0.05),
+		Median: percentile(sorted, 0.50),
+		P95:    percentile(sorted, 0.95),
+	}
+}
+
+// percentile returns the linearly interpolated percentile p (0-1) of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	position := p * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	if lower == upper {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
+
+// sessionSeed derives a stable random seed from a session ID
+func sessionSeed(sessionID string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(sessionID))
+	return int64(hash.Sum64() >> 1)
+}
@@ -0,0 +1,887 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"trading_platform/backend/internal/models"
+)
+
+// ParameterEvaluator runs a strategy with a parameter set over a date range and
+// returns the resulting performance metrics keyed by metric name
+type ParameterEvaluator func(strategyID string, parameters map[string]interface{}, startDate, endDate time.Time) (map[string]float64, error)
+
+// lowerIsBetterMetrics lists optimization metrics where smaller values are preferred
+var lowerIsBetterMetrics = map[string]bool{
+	"maxDrawdown": true,
+	"ulcerIndex":  true,
+}
+
+// SetParameterEvaluator overrides how parameter sets are evaluated during optimization
+func (s *BacktestService) SetParameterEvaluator(evaluator ParameterEvaluator) {
+	s.parameterEvaluator = evaluator
+}
+
+// evaluate runs the configured parameter evaluator, falling back to the default one
+func (s *BacktestService) evaluate(strategyID string, parameters map[string]interface{}, startDate, endDate time.Time) (map[string]float64, error) {
+	if s.parameterEvaluator != nil {
+		return s.parameterEvaluator(strategyID, parameters, startDate, endDate)
+	}
+	return s.evaluateParameters(strategyID, parameters, startDate, endDate)
+}
+
+// evaluateParameters is the default parameter evaluator
+func (s *BacktestService) evaluateParameters(strategyID string, parameters map[string]interface{}, startDate, endDate time.Time) (map[string]float64, error) {
+	// In a real implementation, we would create a temporary backtest session for
+	// the date range and run it through processBacktest with the given parameters
+
+	// For now, derive deterministic mock metrics from the inputs
+	keys := make([]string, 0, len(parameters))
+	for key := range parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := fnv.New64a()
+	hash.Write([]byte(strategyID))
+	for _, key := range keys {
+		hash.Write([]byte(fmt.Sprintf("%s=%v;", key, parameters[key])))
+	}
+	hash.Write([]byte(startDate.Format("2006-01-02") + endDate.Format("2006-01-02")))
+	seed := float64(hash.Sum64()%10000) / 10000.0 // Between 0 and 1
+
+	return map[string]float64{
+		"totalReturn":  -5.0 + seed*20.0,
+		"sharpeRatio":  -0.5 + seed*2.5,
+		"maxDrawdown":  2.0 + (1-seed)*10.0,
+		"winRate":      0.4 + seed*0.3,
+		"profitFactor": 0.8 + seed*1.7,
+	}, nil
+}
+
+// GenerateParameterCombinations expands parameter ranges into every combination of values.
+// Each range is either {"min", "max", "step"} for numeric parameters or {"values"} for
+// an explicit list of values.
+func GenerateParameterCombinations(parameterRanges map[string]map[string]interface{}) ([]map[string]interface{}, error) {
+	names := make([]string, 0, len(parameterRanges))
+	for name := range parameterRanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	combinations := []map[string]interface{}{{}}
+
+	for _, name := range names {
+		values, err := expandParameterRange(parameterRanges[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid range for parameter %s: %v", name, err)
+		}
+
+		var expanded []map[string]interface{}
+		for _, combination := range combinations {
+			for _, value := range values {
+				next := make(map[string]interface{}, len(combination)+1)
+				for key, existing := range combination {
+					next[key] = existing
+				}
+				next[name] = value
+				expanded = append(expanded, next)
+			}
+		}
+		combinations = expanded
+	}
+
+	return combinations, nil
+}
+
+// expandParameterRange returns every value described by a single parameter range
+func expandParameterRange(parameterRange map[string]interface{}) ([]interface{}, error) {
+	if rawValues, exists := parameterRange["values"]; exists {
+		var values []interface{}
+		switch v := rawValues.(type) {
+		case []interface{}:
+			values = v
+		case []string:
+			for _, value := range v {
+				values = append(values, value)
+			}
+		case []int:
+			for _, value := range v {
+				values = append(values, value)
+			}
+		case []float64:
+			for _, value := range v {
+				values = append(values, value)
+			}
+		default:
+			return nil, errors.New("values must be a list")
+		}
+
+		if len(values) == 0 {
+			return nil, errors.New("values cannot be empty")
+		}
+		return values, nil
+	}
+
+	min, okMin := toFloat(parameterRange["min"])
+	max, okMax := toFloat(parameterRange["max"])
+	step, okStep := toFloat(parameterRange["step"])
+	if !okMin || !okMax || !okStep {
+		return nil, errors.New("min, max and step are required")
+	}
+
+	if step <= 0 {
+		return nil, errors.New("step must be greater than zero")
+	}
+
+	if min > max {
+		return nil, errors.New("min must not be greater than max")
+	}
+
+	// Keep integer parameters integral so strategies receive the type they expect
+	_, minIsInt := parameterRange["min"].(int)
+	_, stepIsInt := parameterRange["step"].(int)
+
+	var values []interface{}
+	for i := 0; ; i++ {
+		value := min + float64(i)*step
+		if value > max+step*1e-9 {
+			break
+		}
+		if minIsInt && stepIsInt {
+			values = append(values, int(value))
+		} else {
+			values = append(values, value)
+		}
+	}
+
+	return values, nil
+}
+
+// toFloat converts a numeric interface value to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// isBetterMetric reports whether candidate beats current for the optimization metric
+func isBetterMetric(metric string, candidate, current float64) bool {
+	if lowerIsBetterMetrics[metric] {
+		return candidate < current
+	}
+	return candidate > current
+}
+
+// optimizeOverRange evaluates every parameter combination over a date range and
+// returns the best parameters together with their metric value
+func (s *BacktestService) optimizeOverRange(strategyID string, combinations []map[string]interface{}, optimizationMetric string, startDate, endDate time.Time) (map[string]interface{}, float64, error) {
+	var bestParameters map[string]interface{}
+	var bestValue float64
+
+	for _, parameters := range combinations {
+		metrics, err := s.evaluate(strategyID, parameters, startDate, endDate)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		value, exists := metrics[optimizationMetric]
+		if !exists {
+			return nil, 0, errors.New("unknown optimization metric: " + optimizationMetric)
+		}
+
+		if bestParameters == nil || isBetterMetric(optimizationMetric, value, bestValue) {
+			bestParameters = parameters
+			bestValue = value
+		}
+	}
+
+	if bestParameters == nil {
+		return nil, 0, errors.New("no parameter combinations to evaluate")
+	}
+
+	return bestParameters, bestValue, nil
+}
+
+// GenerateWalkForwardWindows splits a date range into rolling train/test windows.
+// Windows whose test period would extend past endDate are dropped.
+func GenerateWalkForwardWindows(startDate, endDate time.Time, config models.WalkForwardConfig) ([]models.WalkForwardWindow, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !startDate.Before(endDate) {
+		return nil, errors.New("start date must be before end date")
+	}
+
+	day := 24 * time.Hour
+	stepDays := config.StepDays
+	if stepDays == 0 {
+		stepDays = config.TestDays
+	}
+
+	var windows []models.WalkForwardWindow
+	for i := 0; ; i++ {
+		offset := time.Duration(i*stepDays) * day
+		trainingStart := startDate.Add(offset)
+		if config.Anchored {
+			trainingStart = startDate
+		}
+		trainingEnd := startDate.Add(offset + time.Duration(config.TrainingDays)*day)
+		testEnd := trainingEnd.Add(time.Duration(config.TestDays) * day)
+
+		if testEnd.After(endDate) {
+			break
+		}
+
+		windows = append(windows, models.WalkForwardWindow{
+			Index:         i,
+			TrainingStart: trainingStart,
+			TrainingEnd:   trainingEnd,
+			TestStart:     trainingEnd,
+			TestEnd:       testEnd,
+		})
+	}
+
+	if len(windows) == 0 {
+		return nil, errors.New("date range is too short for the walk-forward configuration")
+	}
+
+	return windows, nil
+}
+
+// RunWalkForwardAnalysis optimizes parameters on each in-sample window, evaluates the
+// winning parameters on the following out-of-sample window, and aggregates the
+// out-of-sample metrics across all windows
+func (s *BacktestService) RunWalkForwardAnalysis(strategyID string, startDate, endDate time.Time, parameterRanges map[string]map[string]interface{}, optimizationMetric string, config models.WalkForwardConfig) (*models.WalkForwardResult, error) {
+	if strategyID == "" {
+		return nil, errors.New("strategy ID is required")
+	}
+
+	if len(parameterRanges) == 0 {
+		return nil, errors.New("parameter ranges are required")
+	}
+
+	if optimizationMetric == "" {
+		return nil, errors.New("optimization metric is required")
+	}
+
+	windows, err := GenerateWalkForwardWindows(startDate, endDate, config)
+	if err != nil {
+		return nil, err
+	}
+
+	combinations, err := GenerateParameterCombinations(parameterRanges)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregate := make(map[string]float64)
+	var inSampleTotal, outOfSampleTotal float64
+	positiveWindows := 0
+
+	for i := range windows {
+		window := &windows[i]
+
+		parameters, inSampleValue, err := s.optimizeOverRange(strategyID, combinations, optimizationMetric, window.TrainingStart, window.TrainingEnd)
+		if err != nil {
+			return nil, err
+		}
+
+		metrics, err := s.evaluate(strategyID, parameters, window.TestStart, window.TestEnd)
+		if err != nil {
+			return nil, err
+		}
+
+		window.OptimalParameters = parameters
+		window.InSampleMetric = inSampleValue
+		window.OutOfSampleMetric = metrics[optimizationMetric]
+		window.OutOfSampleMetrics = metrics
+
+		inSampleTotal += inSampleValue
+		outOfSampleTotal += window.OutOfSampleMetric
+		if metrics["totalReturn"] > 0 {
+			positiveWindows++
+		}
+
+		for name, value := range metrics {
+			aggregate[name] += value / float64(len(windows))
+		}
+	}
+
+	aggregate["positiveWindowRatio"] = float64(positiveWindows) / float64(len(windows))
+
+	efficiency := 0.0
+	if inSampleTotal != 0 {
+		efficiency = outOfSampleTotal / inSampleTotal
+	}
+
+	return &models.WalkForwardResult{
+		StrategyID:            strategyID,
+		OptimizationMetric:    optimizationMetric,
+		Config:                config,
+		Windows:               windows,
+		AggregateOutOfSample:  aggregate,
+		WalkForwardEfficiency: efficiency,
+		CreatedAt:             time.Now(),
+	}, nil
+}
+
+// SplitHoldoutPeriod splits a date range into a training window and a holdout window
+// covering the last holdoutPercent of it, and returns the time between the two. The
+// split falls on a day boundary.
+func SplitHoldoutPeriod(startDate, endDate time.Time, holdoutPercent float64) (time.Time, error) {
+	if !startDate.Before(endDate) {
+		return time.Time{}, errors.New("start date must be before end date")
+	}
+
+	if holdoutPercent <= 0 || holdoutPercent >= 100 {
+		return time.Time{}, errors.New("holdout percent must be between 0 and 100")
+	}
+
+	training := time.Duration(float64(endDate.Sub(startDate)) * (100 - holdoutPercent) / 100)
+	split := startDate.Add(training).Truncate(24 * time.Hour)
+	if !split.After(startDate) || !split.Before(endDate) {
+		return time.Time{}, errors.New("date range is too short for a holdout period")
+	}
+
+	return split, nil
+}
+
+// RunHoldoutValidation optimizes the session's strategy on its training window only,
+// then evaluates the optimal parameters on both windows and reports how much each
+// metric degrades out of sample. The session's HoldoutPercent sets the holdout window;
+// the config's date range is replaced by the training window.
+func (s *BacktestService) RunHoldoutValidation(session *models.BacktestSession, parameterRanges map[string]map[string]interface{}, optimizationMetric string, config models.OptimizationConfig) (*models.HoldoutValidationResult, error) {
+	if session == nil {
+		return nil, errors.New("session is required")
+	}
+
+	if session.HoldoutPercent <= 0 {
+		return nil, errors.New("session has no holdout period")
+	}
+
+	trainingEnd, err := SplitHoldoutPeriod(session.StartDate, session.EndDate, session.HoldoutPercent)
+	if err != nil {
+		return nil, err
+	}
+
+	config.StartDate = session.StartDate
+	config.EndDate = trainingEnd
+
+	optimization, err := s.RunParameterOptimization(session.StrategyID, parameterRanges, optimizationMetric, config)
+	if err != nil {
+		return nil, err
+	}
+
+	inSample, err := s.evaluate(session.StrategyID, optimization.OptimalParameters, session.StartDate, trainingEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	outOfSample, err := s.evaluate(session.StrategyID, optimization.OptimalParameters, trainingEnd, session.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	degradation := make(map[string]float64)
+	for name, inSampleValue := range inSample {
+		if outOfSampleValue, exists := outOfSample[name]; exists {
+			degradation[name] = metricDegradation(name, inSampleValue, outOfSampleValue)
+		}
+	}
+
+	return &models.HoldoutValidationResult{
+		StrategyID:         session.StrategyID,
+		OptimizationMetric: optimizationMetric,
+		TrainingStart:      session.StartDate,
+		TrainingEnd:        trainingEnd,
+		HoldoutStart:       trainingEnd,
+		HoldoutEnd:         session.EndDate,
+		Optimization:       optimization,
+		InSampleMetrics:    inSample,
+		OutOfSampleMetrics: outOfSample,
+		Degradation:        degradation,
+		MetricDegradation:  degradation[optimizationMetric],
+		IsLikelyOverfit:    degradation[optimizationMetric] > maxHoldoutDegradation,
+		CreatedAt:          time.Now(),
+	}, nil
+}
+
+// metricDegradation returns the change from a metric's in-sample value to its
+// out-of-sample value relative to the in-sample magnitude, positive when worse
+func metricDegradation(metric string, inSample, outOfSample float64) float64 {
+	if inSample == 0 {
+		return 0
+	}
+
+	degradation := (inSample - outOfSample) / math.Abs(inSample)
+	if lowerIsBetterMetrics[metric] {
+		degradation = -degradation
+	}
+	return degradation
+}
+
+const (
+	// maxHoldoutDegradation is the largest relative drop of the optimization metric
+	// from the training window to the holdout window not flagged as overfitting
+	maxHoldoutDegradation = 0.5
+
+	// maxStabilityDegradation is the largest relative metric drop between the optimal
+	// value of a parameter and its neighbours that is still considered stable
+	maxStabilityDegradation = 0.2
+
+	// surrogateBandwidth is the kernel width, in normalized parameter space, used by
+	// the Bayesian optimizer's surrogate model
+	surrogateBandwidth = 0.2
+
+	// explorationWeight trades off exploitation and exploration in the acquisition function
+	explorationWeight = 1.5
+
+	// maxAcquisitionCandidates caps how many unevaluated points are scored per iteration
+	maxAcquisitionCandidates = 500
+)
+
+// parameterSpace is a discrete search space where each point is a vector of value
+// indices, one per parameter
+type parameterSpace struct {
+	names  []string
+	values [][]interface{}
+}
+
+// newParameterSpace builds a discrete search space from parameter ranges
+func newParameterSpace(parameterRanges map[string]map[string]interface{}) (*parameterSpace, error) {
+	space := &parameterSpace{}
+	for name := range parameterRanges {
+		space.names = append(space.names, name)
+	}
+	sort.Strings(space.names)
+
+	for _, name := range space.names {
+		values, err := expandParameterRange(parameterRanges[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid range for parameter %s: %v", name, err)
+		}
+		space.values = append(space.values, values)
+	}
+
+	return space, nil
+}
+
+// size returns the number of points in the search space
+func (p *parameterSpace) size() int {
+	size := 1
+	for _, values := range p.values {
+		size *= len(values)
+	}
+	return size
+}
+
+// parameters converts a point into a parameter map
+func (p *parameterSpace) parameters(point []int) map[string]interface{} {
+	parameters := make(map[string]interface{}, len(p.names))
+	for i, name := range p.names {
+		parameters[name] = p.values[i][point[i]]
+	}
+	return parameters
+}
+
+// random returns a uniformly random point
+func (p *parameterSpace) random(random *rand.Rand) []int {
+	point := make([]int, len(p.values))
+	for i, values := range p.values {
+		point[i] = random.Intn(len(values))
+	}
+	return point
+}
+
+// normalize maps a point into the unit hypercube
+func (p *parameterSpace) normalize(point []int) []float64 {
+	normalized := make([]float64, len(point))
+	for i, index := range point {
+		if len(p.values[i]) > 1 {
+			normalized[i] = float64(index) / float64(len(p.values[i])-1)
+		}
+	}
+	return normalized
+}
+
+// pointKey returns a map key for a point
+func pointKey(point []int) string {
+	return fmt.Sprint(point)
+}
+
+// optimizationRun holds the evaluation state shared by all search methods
+type optimizationRun struct {
+	service    *BacktestService
+	strategyID string
+	metric     string
+	startDate  time.Time
+	endDate    time.Time
+	space      *parameterSpace
+	scores     map[string]float64 // pointKey -> score, where higher is always better
+	trials     []models.OptimizationTrial
+	points     [][]int // Evaluated points, parallel to trials
+	bestPoint  []int
+	bestScore  float64
+}
+
+// score evaluates a point once, caching the result, and returns a score where
+// higher is better regardless of the metric's direction
+func (r *optimizationRun) score(point []int) (float64, error) {
+	key := pointKey(point)
+	if score, exists := r.scores[key]; exists {
+		return score, nil
+	}
+
+	parameters := r.space.parameters(point)
+	metrics, err := r.service.evaluate(r.strategyID, parameters, r.startDate, r.endDate)
+	if err != nil {
+		return 0, err
+	}
+
+	value, exists := metrics[r.metric]
+	if !exists {
+		return 0, errors.New("unknown optimization metric: " + r.metric)
+	}
+
+	score := value
+	if lowerIsBetterMetrics[r.metric] {
+		score = -value
+	}
+
+	r.scores[key] = score
+	r.trials = append(r.trials, models.OptimizationTrial{Parameters: parameters, MetricValue: value})
+	r.points = append(r.points, append([]int(nil), point...))
+
+	if r.bestPoint == nil || score > r.bestScore {
+		r.bestPoint = append([]int(nil), point...)
+		r.bestScore = score
+	}
+
+	return score, nil
+}
+
+// RunParameterOptimization searches the parameter space of a strategy using grid search,
+// a genetic algorithm, or Bayesian optimization, and reports how stable the optimal
+// parameters are to guard against overfitting
+func (s *BacktestService) RunParameterOptimization(strategyID string, parameterRanges map[string]map[string]interface{}, optimizationMetric string, config models.OptimizationConfig) (*models.OptimizationResult, error) {
+	if strategyID == "" {
+		return nil, errors.New("strategy ID is required")
+	}
+
+	if len(parameterRanges) == 0 {
+		return nil, errors.New("parameter ranges are required")
+	}
+
+	if optimizationMetric == "" {
+		return nil, errors.New("optimization metric is required")
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	space, err := newParameterSpace(parameterRanges)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	run := &optimizationRun{
+		service:    s,
+		strategyID: strategyID,
+		metric:     optimizationMetric,
+		startDate:  config.StartDate,
+		endDate:    config.EndDate,
+		space:      space,
+		scores:     make(map[string]float64),
+	}
+
+	// Default to optimizing over the last year
+	if run.endDate.IsZero() {
+		run.endDate = time.Now()
+	}
+	if run.startDate.IsZero() {
+		run.startDate = run.endDate.AddDate(-1, 0, 0)
+	}
+
+	random := rand.New(rand.NewSource(config.Seed))
+	stoppedEarly := false
+
+	switch config.Method {
+	case models.OptimizationMethodGrid:
+		combinations, err := GenerateParameterCombinations(parameterRanges)
+		if err != nil {
+			return nil, err
+		}
+		for i := range combinations {
+			point := make([]int, len(space.names))
+			remainder := i
+			for j := len(space.values) - 1; j >= 0; j-- {
+				point[j] = remainder % len(space.values[j])
+				remainder /= len(space.values[j])
+			}
+			if _, err := run.score(point); err != nil {
+				return nil, err
+			}
+		}
+	case models.OptimizationMethodGenetic:
+		stoppedEarly, err = run.genetic(config, random)
+	case models.OptimizationMethodBayesian:
+		stoppedEarly, err = run.bayesian(config, random)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stability, err := run.stability()
+	if err != nil {
+		return nil, err
+	}
+
+	isLikelyOverfit := false
+	for _, parameter := range stability {
+		if !parameter.IsStable {
+			isLikelyOverfit = true
+		}
+	}
+
+	metricValue := run.bestScore
+	if lowerIsBetterMetrics[optimizationMetric] {
+		metricValue = -metricValue
+	}
+
+	return &models.OptimizationResult{
+		StrategyID:         strategyID,
+		Method:             config.Method,
+		OptimizationMetric: optimizationMetric,
+		OptimalParameters:  space.parameters(run.bestPoint),
+		MetricValue:        metricValue,
+		Trials:             run.trials,
+		StoppedEarly:       stoppedEarly,
+		Stability:          stability,
+		IsLikelyOverfit:    isLikelyOverfit,
+		TimeElapsed:        time.Since(startTime),
+	}, nil
+}
+
+// genetic runs a genetic algorithm with elitism, tournament selection, uniform
+// crossover and per-gene mutation. It reports whether it stopped early.
+func (r *optimizationRun) genetic(config models.OptimizationConfig, random *rand.Rand) (bool, error) {
+	population := make([][]int, config.PopulationSize)
+	for i := range population {
+		population[i] = r.space.random(random)
+	}
+
+	roundsWithoutImprovement := 0
+	for generation := 0; generation < config.Generations; generation++ {
+		previousBest := r.bestScore
+		hadBest := r.bestPoint != nil
+
+		fitness := make([]float64, len(population))
+		for i, individual := range population {
+			score, err := r.score(individual)
+			if err != nil {
+				return false, err
+			}
+			fitness[i] = score
+		}
+
+		if hadBest && r.bestScore <= previousBest {
+			roundsWithoutImprovement++
+			if config.EarlyStoppingRounds > 0 && roundsWithoutImprovement >= config.EarlyStoppingRounds {
+				return true, nil
+			}
+		} else {
+			roundsWithoutImprovement = 0
+		}
+
+		// Tournament selection of size 3
+		selectParent := func() []int {
+			best := random.Intn(len(population))
+			for k := 0; k < 2; k++ {
+				challenger := random.Intn(len(population))
+				if fitness[challenger] > fitness[best] {
+					best = challenger
+				}
+			}
+			return population[best]
+		}
+
+		// Carry the best individual over unchanged
+		next := [][]int{append([]int(nil), r.bestPoint...)}
+		for len(next) < len(population) {
+			mother, father := selectParent(), selectParent()
+			child := make([]int, len(mother))
+			for gene := range child {
+				if random.Intn(2) == 0 {
+					child[gene] = mother[gene]
+				} else {
+					child[gene] = father[gene]
+				}
+				if random.Float64() < config.MutationRate {
+					child[gene] = random.Intn(len(r.space.values[gene]))
+				}
+			}
+			next = append(next, child)
+		}
+		population = next
+	}
+
+	return false, nil
+}
+
+// bayesian runs a sequential model-based optimization. A kernel-regression surrogate
+// estimates the mean and uncertainty of unevaluated points, and the point with the
+// highest upper confidence bound is evaluated next. It reports whether it stopped early.
+func (r *optimizationRun) bayesian(config models.OptimizationConfig, random *rand.Rand) (bool, error) {
+	spaceSize := r.space.size()
+
+	for i := 0; i < config.InitialSamples && len(r.scores) < spaceSize; i++ {
+		if _, err := r.score(r.space.random(random)); err != nil {
+			return false, err
+		}
+	}
+
+	roundsWithoutImprovement := 0
+	for iteration := 0; iteration < config.Iterations && len(r.scores) < spaceSize; iteration++ {
+		// Collect evaluated points for the surrogate
+		var observed [][]float64
+		var observedScores []float64
+		mean := 0.0
+		for _, point := range r.points {
+			score := r.scores[pointKey(point)]
+			observed = append(observed, r.space.normalize(point))
+			observedScores = append(observedScores, score)
+			mean += score
+		}
+		mean /= float64(len(observedScores))
+
+		variance := 0.0
+		for _, score := range observedScores {
+			variance += (score - mean) * (score - mean)
+		}
+		deviation := math.Sqrt(variance / float64(len(observedScores)))
+
+		var bestCandidate []int
+		bestAcquisition := math.Inf(-1)
+		for c := 0; c < maxAcquisitionCandidates; c++ {
+			candidate := r.space.random(random)
+			if _, evaluated := r.scores[pointKey(candidate)]; evaluated {
+				continue
+			}
+
+			x := r.space.normalize(candidate)
+			weightSum, weightedScore := 0.0, 0.0
+			for i, point := range observed {
+				distance := 0.0
+				for d := range x {
+					distance += (x[d] - point[d]) * (x[d] - point[d])
+				}
+				weight := math.Exp(-distance / (2 * surrogateBandwidth * surrogateBandwidth))
+				weightSum += weight
+				weightedScore += weight * observedScores[i]
+			}
+
+			predicted := mean
+			if weightSum > 1e-9 {
+				predicted = (weightedScore + mean) / (weightSum + 1)
+			}
+			uncertainty := deviation / math.Sqrt(1+weightSum)
+
+			if acquisition := predicted + explorationWeight*uncertainty; acquisition > bestAcquisition {
+				bestAcquisition = acquisition
+				bestCandidate = candidate
+			}
+		}
+
+		// Every sampled candidate was already evaluated
+		if bestCandidate == nil {
+			bestCandidate = r.space.random(random)
+		}
+
+		previousBest := r.bestScore
+		if _, err := r.score(bestCandidate); err != nil {
+			return false, err
+		}
+
+		if r.bestScore <= previousBest {
+			roundsWithoutImprovement++
+			if config.EarlyStoppingRounds > 0 && roundsWithoutImprovement >= config.EarlyStoppingRounds {
+				return true, nil
+			}
+		} else {
+			roundsWithoutImprovement = 0
+		}
+	}
+
+	return false, nil
+}
+
+// stability evaluates the neighbours of the best point along each parameter axis
+// and reports how much the metric degrades when each parameter is nudged
+func (r *optimizationRun) stability() ([]models.ParameterStability, error) {
+	var report []models.ParameterStability
+
+	for i, name := range r.space.names {
+		if len(r.space.values[i]) < 2 {
+			continue
+		}
+
+		var neighborScores []float64
+		for _, offset := range []int{-1, 1} {
+			index := r.bestPoint[i] + offset
+			if index < 0 || index >= len(r.space.values[i]) {
+				continue
+			}
+
+			neighbor := append([]int(nil), r.bestPoint...)
+			neighbor[i] = index
+			score, err := r.score(neighbor)
+			if err != nil {
+				return nil, err
+			}
+			neighborScores = append(neighborScores, score)
+		}
+
+		average := 0.0
+		for _, score := range neighborScores {
+			average += score
+		}
+		average /= float64(len(neighborScores))
+
+		degradation := 0.0
+		if r.bestScore != 0 {
+			degradation = (r.bestScore - average) / math.Abs(r.bestScore)
+		}
+
+		averageMetric := average
+		if lowerIsBetterMetrics[r.metric] {
+			averageMetric = -average
+		}
+
+		report = append(report, models.ParameterStability{
+			Parameter:             name,
+			AverageNeighborMetric: averageMetric,
+			RelativeDegradation:   degradation,
+			IsStable:              degradation <= maxStabilityDegradation,
+		})
+	}
+
+	return report, nil
+}
@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"trading_platform/backend/internal/models"
+)
+
+// portfolioStopTag tags orders generated when a portfolio stop rule flattens the book
+const portfolioStopTag = "PORTFOLIO_STOP"
+
+// PortfolioBacktestMember is a strategy instance taking part in a portfolio backtest
+type PortfolioBacktestMember struct {
+	Name       string
+	Strategy   BacktestStrategy
+	Symbols    []string // Symbols whose market data the strategy receives, all when empty
+	Parameters map[string]interface{}
+}
+
+// PortfolioBacktester runs several strategies over several symbols against one
+// account. Strategies share the capital, margin is checked on the whole book with
+// offsetting positions in the same underlying netted, and portfolio stop rules
+// flatten every position and halt trading when hit. Positions are net per symbol,
+// so strategies trading the same symbol see each other's positions.
+type PortfolioBacktester struct {
+	backtester *StrategyBacktester
+	portfolio  *portfolioStrategy
+}
+
+// NewPortfolioBacktester creates a new portfolio backtester
+func NewPortfolioBacktester(sessionID string, members []PortfolioBacktestMember, initialBalance float64, settings *models.MarketSettings, config models.PortfolioBacktestConfig) (*PortfolioBacktester, error) {
+	if len(members) == 0 {
+		return nil, errors.New("at least one strategy is required")
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	portfolio := &portfolioStrategy{
+		config: config,
+		report: models.PortfolioBacktestReport{StrategyOrders: make(map[string]int)},
+	}
+
+	names := make(map[string]bool)
+	for _, member := range members {
+		if member.Name == "" {
+			return nil, errors.New("strategy name is required")
+		}
+
+		if member.Strategy == nil {
+			return nil, errors.New("strategy is required for " + member.Name)
+		}
+
+		if names[member.Name] {
+			return nil, errors.New("duplicate strategy name: " + member.Name)
+		}
+		names[member.Name] = true
+
+		sleeve := &portfolioSleeve{
+			member:    member,
+			portfolio: portfolio,
+			symbols:   make(map[string]bool),
+		}
+		for _, symbol := range member.Symbols {
+			sleeve.symbols[symbol] = true
+		}
+		portfolio.sleeves = append(portfolio.sleeves, sleeve)
+	}
+
+	portfolio.backtester = NewStrategyBacktester(sessionID, portfolio, initialBalance, settings, nil)
+
+	return &PortfolioBacktester{
+		backtester: portfolio.backtester,
+		portfolio:  portfolio,
+	}, nil
+}
+
+// SetRandomSeed seeds the backtester's slippage and latency randomness
+func (p *PortfolioBacktester) SetRandomSeed(seed int64) {
+	p.backtester.SetRandomSeed(seed)
+}
+
+// SetContext makes runs stop with the context's error as soon as it is cancelled
+func (p *PortfolioBacktester) SetContext(ctx context.Context) {
+	p.backtester.SetContext(ctx)
+}
+
+// SetIntradayMargin models MIS leverage, margin calls and square-off for the whole book
+func (p *PortfolioBacktester) SetIntradayMargin(config models.IntradayMarginConfig) error {
+	return p.backtester.SetIntradayMargin(config)
+}
+
+// RunCandles runs the portfolio over bar data
+func (p *PortfolioBacktester) RunCandles(candles []models.MarketDataSnapshot) (*models.PortfolioBacktestReport, error) {
+	report, err := p.backtester.RunCandles(candles)
+	if err != nil {
+		return nil, err
+	}
+	return p.portfolio.finish(report), nil
+}
+
+// RunTicks runs the portfolio over tick data
+func (p *PortfolioBacktester) RunTicks(ticks []models.MarketDataSnapshot) (*models.PortfolioBacktestReport, error) {
+	report, err := p.backtester.RunTicks(ticks)
+	if err != nil {
+		return nil, err
+	}
+	return p.portfolio.finish(report), nil
+}
+
+// portfolioStrategy fans backtester events out to the member strategies and
+// enforces the portfolio's margin and stop rules
+type portfolioStrategy struct {
+	backtester *StrategyBacktester
+	sleeves    []*portfolioSleeve
+	config     models.PortfolioBacktestConfig
+
+	currentDay     string
+	dayStartEquity float64
+	peakEquity     float64
+
+	report models.PortfolioBacktestReport
+}
+
+// OnInit implements BacktestStrategy
+func (p *portfolioStrategy) OnInit(ctx BacktestStrategyContext) error {
+	p.peakEquity = ctx.Equity()
+
+	for _, sleeve := range p.sleeves {
+		if err := sleeve.member.Strategy.OnInit(sleeve); err != nil {
+			return fmt.Errorf("%s: %w", sleeve.member.Name, err)
+		}
+	}
+	return nil
+}
+
+// OnTick implements BacktestStrategy
+func (p *portfolioStrategy) OnTick(ctx BacktestStrategyContext, tick models.MarketDataSnapshot) error {
+	return p.dispatch(tick, func(sleeve *portfolioSleeve) error {
+		return sleeve.member.Strategy.OnTick(sleeve, tick)
+	})
+}
+
+// OnCandle implements BacktestStrategy
+func (p *portfolioStrategy) OnCandle(ctx BacktestStrategyContext, candle models.MarketDataSnapshot) error {
+	return p.dispatch(candle, func(sleeve *portfolioSleeve) error {
+		return sleeve.member.Strategy.OnCandle(sleeve, candle)
+	})
+}
+
+// OnFill implements BacktestStrategy
+func (p *portfolioStrategy) OnFill(ctx BacktestStrategyContext, fill models.SimulationOrder) error {
+	for _, sleeve := range p.sleeves {
+		if sleeve.member.Name != fill.StrategyID {
+			continue
+		}
+
+		if fill.Status == models.OrderStatusExecuted {
+			p.report.StrategyOrders[sleeve.member.Name]++
+		}
+		return sleeve.member.Strategy.OnFill(sleeve, fill)
+	}
+
+	// Fills of portfolio stop orders have no owning strategy
+	return nil
+}
+
+// dispatch checks the stop rules and, unless trading has halted, forwards an
+// event to every strategy subscribed to its symbol
+func (p *portfolioStrategy) dispatch(data models.MarketDataSnapshot, deliver func(sleeve *portfolioSleeve) error) error {
+	if p.report.Halted {
+		return nil
+	}
+
+	if p.checkStopRules() {
+		return nil
+	}
+
+	for _, sleeve := range p.sleeves {
+		if len(sleeve.symbols) > 0 && !sleeve.symbols[data.Symbol] {
+			continue
+		}
+
+		if err := deliver(sleeve); err != nil {
+			return fmt.Errorf("%s: %w", sleeve.member.Name, err)
+		}
+	}
+
+	if margin := p.marginRequired("", 0, 0); margin > p.report.PeakMarginUsed {
+		p.report.PeakMarginUsed = margin
+	}
+
+	return nil
+}
+
+// checkStopRules halts the portfolio when a stop rule is hit, returning true if it did
+func (p *portfolioStrategy) checkStopRules() bool {
+	b := p.backtester
+	equity := b.Equity()
+
+	day := b.now.Format("2006-01-02")
+	if day != p.currentDay {
+		p.currentDay = day
+		p.dayStartEquity = equity
+	}
+
+	if equity > p.peakEquity {
+		p.peakEquity = equity
+	}
+
+	reason := ""
+	switch {
+	case p.config.MaxDrawdownPct > 0 && p.peakEquity > 0 && (p.peakEquity-equity)/p.peakEquity*100.0 >= p.config.MaxDrawdownPct:
+		reason = fmt.Sprintf("max drawdown of %.2f%% reached", p.config.MaxDrawdownPct)
+	case p.config.MaxDailyLoss > 0 && p.dayStartEquity-equity >= p.config.MaxDailyLoss:
+		reason = fmt.Sprintf("max daily loss of %.2f reached", p.config.MaxDailyLoss)
+	case p.config.ProfitTarget > 0 && equity-b.initialEquity >= p.config.ProfitTarget:
+		reason = fmt.Sprintf("profit target of %.2f reached", p.config.ProfitTarget)
+	default:
+		return false
+	}
+
+	p.halt(reason)
+	return true
+}
+
+// halt cancels all pending orders and queues market orders to flatten every position
+func (p *portfolioStrategy) halt(reason string) {
+	b := p.backtester
+
+	haltedAt := b.now
+	p.report.Halted = true
+	p.report.HaltReason = reason
+	p.report.HaltedAt = &haltedAt
+
+	b.pending = nil
+	b.triggered = make(map[string]bool)
+	b.legGroups = make(map[string]*legGroup)
+
+	for _, symbol := range b.symbols {
+		quantity := b.positions[symbol].quantity
+		if quantity == 0 {
+			continue
+		}
+
+		direction := models.OrderDirectionSell
+		if quantity < 0 {
+			direction = models.OrderDirectionBuy
+		}
+
+		// Validated orders cannot fail to queue
+		b.SubmitOrder(models.Order{
+			Symbol:    symbol,
+			Direction: direction,
+			OrderType: models.OrderTypeMarket,
+			Quantity:  abs(quantity),
+			Tags:      []string{portfolioStopTag},
+		})
+	}
+}
+
+// marginRequired returns the margin needed for the current book plus pending orders
+// and an optional additional order. Within each underlying, margin is charged in full
+// on the net exposure and reduced by HedgeOffset on the exposure that offsets.
+func (p *portfolioStrategy) marginRequired(symbol string, quantity int, price float64) float64 {
+	if p.config.MarginRate <= 0 {
+		return 0
+	}
+
+	b := p.backtester
+	longs := make(map[string]float64)
+	shorts := make(map[string]float64)
+
+	add := func(symbol string, quantity int, price float64) {
+		underlying := symbol
+		if mapped, exists := p.config.Underlyings[symbol]; exists {
+			underlying = mapped
+		}
+
+		notional := float64(quantity) * price
+		if notional > 0 {
+			longs[underlying] += notional
+		} else {
+			shorts[underlying] -= notional
+		}
+	}
+
+	for _, symbol := range b.symbols {
+		if quantity := b.positions[symbol].quantity; quantity != 0 {
+			add(symbol, quantity, b.lastPrices[symbol])
+		}
+	}
+
+	for _, order := range b.pending {
+		add(order.Symbol, signedQuantity(order.Direction, order.Quantity-order.FilledQuantity), orderPrice(order.Order, b.lastPrices[order.Symbol]))
+	}
+
+	if symbol != "" {
+		add(symbol, quantity, price)
+	}
+
+	underlyings := make([]string, 0, len(longs)+len(shorts))
+	for underlying := range longs {
+		underlyings = append(underlyings, underlying)
+	}
+	for underlying := range shorts {
+		if _, exists := longs[underlying]; !exists {
+			underlyings = append(underlyings, underlying)
+		}
+	}
+	sort.Strings(underlyings)
+
+	margin := 0.0
+	for _, underlying := range underlyings {
+		net := math.Abs(longs[underlying] - shorts[underlying])
+		hedged := longs[underlying] + shorts[underlying] - net
+		margin += p.config.MarginRate * (net + (1-p.config.HedgeOffset)*hedged)
+	}
+
+	return margin
+}
+
+// finish combines the backtester's report with the portfolio statistics
+func (p *portfolioStrategy) finish(report *models.StrategyBacktestReport) *models.PortfolioBacktestReport {
+	p.report.StrategyBacktestReport = *report
+	return &p.report
+}
+
+// signedQuantity returns quantity as a position change, negative for sells
+func signedQuantity(direction models.OrderDirection, quantity int) int {
+	if direction == models.OrderDirectionSell {
+		return -quantity
+	}
+	return quantity
+}
+
+// orderPrice returns the price used to value an order: its limit price, or the
+// last price for market orders
+func orderPrice(order models.Order, lastPrice float64) float64 {
+	if order.OrderType != models.OrderTypeMarket && order.Price > 0 {
+		return order.Price
+	}
+	return lastPrice
+}
+
+// portfolioSleeve is the BacktestStrategyContext given to each member strategy.
+// It attributes orders to the strategy and checks them against the portfolio margin.
+type portfolioSleeve struct {
+	member    PortfolioBacktestMember
+	portfolio *portfolioStrategy
+	symbols   map[string]bool
+}
+
+// SubmitOrder implements BacktestStrategyContext
+func (s *portfolioSleeve) SubmitOrder(order models.Order) (string, error) {
+	p := s.portfolio
+	b := p.backtester
+
+	if p.report.Halted {
+		return "", errors.New("portfolio trading has been halted: " + p.report.HaltReason)
+	}
+
+	if p.config.MarginRate > 0 && order.Quantity > 0 {
+		price := orderPrice(order, b.lastPrices[order.Symbol])
+		required := p.marginRequired(order.Symbol, signedQuantity(order.Direction, order.Quantity), price)
+		if required > b.Equity() {
+			p.report.MarginRejections++
+			return "", fmt.Errorf("insufficient margin: %.2f required, %.2f available", required, b.Equity())
+		}
+	}
+
+	order.StrategyID = s.member.Name
+	return b.SubmitOrder(order)
+}
+
+// CancelOrder implements BacktestStrategyContext
+func (s *portfolioSleeve) CancelOrder(orderID string) error {
+	for _, order := range s.portfolio.backtester.pending {
+		if order.ID == orderID && order.StrategyID != s.member.Name {
+			return errors.New("order belongs to another strategy")
+		}
+	}
+	return s.portfolio.backtester.CancelOrder(orderID)
+}
+
+// Position implements BacktestStrategyContext
+func (s *portfolioSleeve) Position(symbol string) int {
+	return s.portfolio.backtester.Position(symbol)
+}
+
+// Cash implements BacktestStrategyContext
+func (s *portfolioSleeve) Cash() float64 {
+	return s.portfolio.backtester.Cash()
+}
+
+// Equity implements BacktestStrategyContext
+func (s *portfolioSleeve) Equity() float64 {
+	return s.portfolio.backtester.Equity()
+}
+
+// Parameters implements BacktestStrategyContext
+func (s *portfolioSleeve) Parameters() map[string]interface{} {
+	return s.member.Parameters
+}
+
+// Now implements BacktestStrategyContext
+func (s *portfolioSleeve) Now() time.Time {
+	return s.portfolio.backtester.Now()
+}
+
+// SetLegGroupExit implements BacktestStrategyContext. Group names are scoped to the strategy.
+func (s *portfolioSleeve) SetLegGroupExit(name string, symbols []string, stopLoss, target float64) error {
+	if name == "" {
+		return errors.New("leg group name is required")
+	}
+	return s.portfolio.backtester.setLegGroupExit(s.member.Name, s.member.Name+"/"+name, symbols, stopLoss, target)
+}
+
+// RunPortfolioBacktest runs a basket of registered Go strategies over the session's
+// period with shared capital and updates the session with the outcome
+func (s *BacktestService) RunPortfolioBacktest(session *models.BacktestSession, legs []models.PortfolioBacktestLeg, config models.PortfolioBacktestConfig, marketSettings *models.MarketSettings) (*models.PortfolioBacktestReport, error) {
+	if session == nil {
+		return nil, errors.New("session is required")
+	}
+
+	if len(legs) == 0 {
+		return nil, errors.New("at least one strategy is required")
+	}
+
+	var members []PortfolioBacktestMember
+	symbols := make(map[string]bool)
+	for _, leg := range legs {
+		strategy, err := NewBacktestStrategy(leg.StrategyName, leg.Parameters)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(leg.Symbols) == 0 {
+			return nil, errors.New("symbols are required for " + leg.Name)
+		}
+
+		members = append(members, PortfolioBacktestMember{
+			Name:       leg.Name,
+			Strategy:   strategy,
+			Symbols:    leg.Symbols,
+			Parameters: leg.Parameters,
+		})
+		for _, symbol := range leg.Symbols {
+			symbols[symbol] = true
+		}
+	}
+
+	backtester, err := NewPortfolioBacktester(session.ID, members, session.InitialBalance, marketSettings, config)
+	if err != nil {
+		return nil, err
+	}
+	backtester.SetRandomSeed(session.RandomSeed)
+
+	if session.IntradayMargin != nil {
+		if err := backtester.SetIntradayMargin(*session.IntradayMargin); err != nil {
+			return nil, err
+		}
+	}
+
+	sortedSymbols := make([]string, 0, len(symbols))
+	for symbol := range symbols {
+		sortedSymbols = append(sortedSymbols, symbol)
+	}
+	sort.Strings(sortedSymbols)
+
+	s.dataCache.Prefetch(sortedSymbols, session.StartDate, session.EndDate, session.Timeframe)
+
+	var data []models.MarketDataSnapshot
+	for _, symbol := range sortedSymbols {
+		snapshots, err := s.dataCache.Get(symbol, session.StartDate, session.EndDate, session.Timeframe)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, snapshots...)
+	}
+
+	session.Status = "RUNNING"
+
+	var report *models.PortfolioBacktestReport
+	if session.Timeframe == "tick" {
+		report, err = backtester.RunTicks(data)
+	} else {
+		report, err = backtester.RunCandles(data)
+	}
+	if err != nil {
+		session.Status = "FAILED"
+		return nil, err
+	}
+
+	completedAt := time.Now()
+	session.Status = "COMPLETED"
+	session.CompletedAt = &completedAt
+	session.FinalBalance = report.FinalBalance
+	session.TotalTrades = report.TotalTrades
+	session.WinningTrades = report.WinningTrades
+	session.LosingTrades = report.LosingTrades
+
+	maxDrawdown := 0.0
+	for _, result := range report.Results {
+		if result.DrawdownCurve > maxDrawdown {
+			maxDrawdown = result.DrawdownCurve
+		}
+	}
+	session.MaxDrawdown = maxDrawdown
+
+	// In a real implementation, we would save the session, results and trades to the database
+
+	return report, nil
+}
@@ -0,0 +1,543 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"trading_platform/backend/internal/models"
+)
+
+// BacktestExport is a rendered backtest report
+type BacktestExport struct {
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	Data        []byte `json:"-"`
+	Location    string `json:"location,omitempty"` // Set once the report has been stored
+}
+
+// ReportStorage stores rendered reports and returns where they can be fetched from
+type ReportStorage interface {
+	Store(key, contentType string, data []byte) (string, error)
+}
+
+// FileReportStorage stores reports on the local filesystem
+type FileReportStorage struct {
+	directory string
+}
+
+// NewFileReportStorage creates a new report storage rooted at directory
+func NewFileReportStorage(directory string) *FileReportStorage {
+	return &FileReportStorage{directory: directory}
+}
+
+// Store implements ReportStorage
+func (s *FileReportStorage) Store(key, contentType string, data []byte) (string, error) {
+	path := filepath.Join(s.directory, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+
+	return path, nil
+}
+
+// SetReportStorage replaces the storage used for backtest reports, e.g. with object storage
+func (s *BacktestService) SetReportStorage(storage ReportStorage) {
+	s.reportStorage = storage
+}
+
+// reportMetric is a formatted row of the report's metrics table
+type reportMetric struct {
+	Name  string
+	Value string
+}
+
+// backtestReport holds everything rendered into a backtest report
+type backtestReport struct {
+	Session     *models.BacktestSession
+	Results     []models.BacktestResult
+	Metrics     []reportMetric
+	MonteCarlo  *models.MonteCarloResult
+	Trades      []models.SimulationOrder
+	GeneratedAt time.Time
+}
+
+// ExportBacktestResults renders a backtest report as "csv" (the equity curve),
+// "html" (charts, metrics and trade list) or "pdf" (the same content as html)
+func (s *BacktestService) ExportBacktestResults(sessionID string, format string) (*BacktestExport, error) {
+	if sessionID == "" {
+		return nil, errors.New("session ID is required")
+	}
+
+	if format == "" {
+		return nil, errors.New("format is required")
+	}
+
+	format = strings.ToLower(format)
+	if format != "csv" && format != "html" && format != "pdf" {
+		return nil, errors.New("unsupported format: " + format)
+	}
+
+	report, err := s.buildBacktestReport(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &BacktestExport{FileName: "backtest_" + sessionID + "." + format}
+	switch format {
+	case "csv":
+		export.ContentType = "text/csv"
+		export.Data, err = renderBacktestCSV(report)
+	case "html":
+		export.ContentType = "text/html; charset=utf-8"
+		export.Data, err = renderBacktestHTML(report)
+	case "pdf":
+		export.ContentType = "application/pdf"
+		export.Data = renderBacktestPDF(report)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// StoreBacktestReport renders a backtest report and saves it to the report storage
+func (s *BacktestService) StoreBacktestReport(sessionID string, format string) (*BacktestExport, error) {
+	if s.reportStorage == nil {
+		return nil, errors.New("report storage is not configured")
+	}
+
+	export, err := s.ExportBacktestResults(sessionID, format)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := s.reportStorage.Store("backtests/"+sessionID+"/"+export.FileName, export.ContentType, export.Data)
+	if err != nil {
+		return nil, err
+	}
+	export.Location = location
+
+	return export, nil
+}
+
+// buildBacktestReport gathers a session's results, metrics and trades
+func (s *BacktestService) buildBacktestReport(sessionID string) (*backtestReport, error) {
+	session, err := s.GetBacktestSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.GetBacktestResults(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := s.GetBacktestPerformanceMetrics(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	trades, err := s.GetBacktestTrades(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &backtestReport{
+		Session:     session,
+		Results:     results,
+		Trades:      trades,
+		GeneratedAt: time.Now(),
+	}
+
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch value := metrics[name].(type) {
+		case *models.MonteCarloResult:
+			report.MonteCarlo = value
+		case float64:
+			report.Metrics = append(report.Metrics, reportMetric{Name: name, Value: strconv.FormatFloat(value, 'f', 2, 64)})
+		default:
+			report.Metrics = append(report.Metrics, reportMetric{Name: name, Value: fmt.Sprint(value)})
+		}
+	}
+
+	return report, nil
+}
+
+// renderBacktestCSV renders the equity curve as CSV
+func renderBacktestCSV(report *backtestReport) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	writer.Write([]string{"timestamp", "equity", "drawdownPct", "cumulativePnL", "dailyPnL", "openPositions", "cashBalance"})
+	for _, result := range report.Results {
+		writer.Write([]string{
+			result.Timestamp.Format(time.RFC3339),
+			strconv.FormatFloat(result.EquityCurve, 'f', 2, 64),
+			strconv.FormatFloat(result.DrawdownCurve, 'f', 4, 64),
+			strconv.FormatFloat(result.CumulativePnL, 'f', 2, 64),
+			strconv.FormatFloat(result.DailyPnL, 'f', 2, 64),
+			strconv.Itoa(result.OpenPositions),
+			strconv.FormatFloat(result.CashBalance, 'f', 2, 64),
+		})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("failed to render CSV: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Chart dimensions shared by the HTML and PDF reports
+const (
+	reportChartWidth  = 515.0
+	reportChartHeight = 150.0
+)
+
+// chartPoints scales values into a width x height box, y growing downwards
+func chartPoints(values []float64, width, height float64) [][2]float64 {
+	if len(values) == 0 {
+		return nil
+	}
+
+	low, high := values[0], values[0]
+	for _, value := range values {
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+
+	span := high - low
+	if span == 0 {
+		span = 1
+	}
+
+	step := 0.0
+	if len(values) > 1 {
+		step = width / float64(len(values)-1)
+	}
+
+	points := make([][2]float64, len(values))
+	for i, value := range values {
+		points[i] = [2]float64{float64(i) * step, height - (value-low)/span*height}
+	}
+	return points
+}
+
+// svgPolyline formats values as the points attribute of an SVG polyline
+func svgPolyline(values []float64) string {
+	var builder strings.Builder
+	for i, point := range chartPoints(values, reportChartWidth, reportChartHeight) {
+		if i > 0 {
+			builder.WriteByte(' ')
+		}
+		builder.WriteString(strconv.FormatFloat(point[0], 'f', 1, 64))
+		builder.WriteByte(',')
+		builder.WriteString(strconv.FormatFloat(point[1], 'f', 1, 64))
+	}
+	return builder.String()
+}
+
+// curves extracts the equity and drawdown series from the results
+func (r *backtestReport) curves() ([]float64, []float64) {
+	equity := make([]float64, len(r.Results))
+	drawdown := make([]float64, len(r.Results))
+	for i, result := range r.Results {
+		equity[i] = result.EquityCurve
+		// Drawdowns are plotted below zero
+		drawdown[i] = -result.DrawdownCurve
+	}
+	return equity, drawdown
+}
+
+var backtestReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"money": func(value float64) string { return strconv.FormatFloat(value, 'f', 2, 64) },
+	"date":  func(value time.Time) string { return value.Format("2006-01-02 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Backtest Report - {{.Report.Session.Name}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; margin: 32px; color: #222; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: right; font-size: 13px; }
+th { background: #f4f4f4; }
+td:first-child, th:first-child { text-align: left; }
+svg { border: 1px solid #ddd; margin-bottom: 24px; }
+</style>
+</head>
+<body>
+<h1>{{.Report.Session.Name}}</h1>
+<p>{{.Report.Session.Description}}</p>
+<p>{{date .Report.Session.StartDate}} to {{date .Report.Session.EndDate}} &middot; {{.Report.Session.Timeframe}} &middot; Initial balance {{money .Report.Session.InitialBalance}} &middot; Final balance {{money .Report.Session.FinalBalance}}</p>
+
+<h2>Equity Curve</h2>
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}"><polyline fill="none" stroke="#1f77b4" stroke-width="1.5" points="{{.EquityPoints}}"/></svg>
+
+<h2>Drawdown</h2>
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}"><polyline fill="none" stroke="#d62728" stroke-width="1.5" points="{{.DrawdownPoints}}"/></svg>
+
+<h2>Performance Metrics</h2>
+<table>
+<tr><th>Metric</th><th>Value</th></tr>
+{{range .Report.Metrics}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{with .Report.MonteCarlo}}
+<h2>Monte Carlo ({{.Simulations}} simulations)</h2>
+<table>
+<tr><th></th><th>5th percentile</th><th>Median</th><th>95th percentile</th></tr>
+<tr><td>Max drawdown %</td><td>{{money .MaxDrawdown.P5}}</td><td>{{money .MaxDrawdown.Median}}</td><td>{{money .MaxDrawdown.P95}}</td></tr>
+<tr><td>CAGR %</td><td>{{money .CAGR.P5}}</td><td>{{money .CAGR.Median}}</td><td>{{money .CAGR.P95}}</td></tr>
+<tr><td>Final equity</td><td>{{money .FinalEquity.P5}}</td><td>{{money .FinalEquity.Median}}</td><td>{{money .FinalEquity.P95}}</td></tr>
+</table>
+<p>Risk of ruin: {{money .RiskOfRuin}}</p>
+{{end}}
+<h2>Trades</h2>
+<table>
+<tr><th>Time</th><th>Symbol</th><th>Side</th><th>Quantity</th><th>Price</th><th>Commission</th><th>Status</th></tr>
+{{range .Report.Trades}}<tr><td>{{date .SimulatedFillTime}}</td><td>{{.Symbol}}</td><td>{{.Direction}}</td><td>{{.FilledQuantity}}</td><td>{{money .SimulatedFillPrice}}</td><td>{{money .CommissionAmount}}</td><td>{{.Status}}</td></tr>
+{{end}}</table>
+<p>Generated {{date .Report.GeneratedAt}}</p>
+</body>
+</html>
+`))
+
+// renderBacktestHTML renders the report as a standalone HTML page with inline SVG charts
+func renderBacktestHTML(report *backtestReport) ([]byte, error) {
+	equity, drawdown := report.curves()
+
+	var buffer bytes.Buffer
+	err := backtestReportTemplate.Execute(&buffer, map[string]interface{}{
+		"Report":         report,
+		"Width":          reportChartWidth,
+		"Height":         reportChartHeight,
+		"EquityPoints":   svgPolyline(equity),
+		"DrawdownPoints": svgPolyline(drawdown),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render HTML: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// renderBacktestPDF renders the report as a PDF with the same sections as the HTML report
+func renderBacktestPDF(report *backtestReport) []byte {
+	session := report.Session
+	equity, drawdown := report.curves()
+
+	pdf := newPDFWriter()
+	pdf.writeLine(session.Name, 18)
+	if session.Description != "" {
+		pdf.writeLine(session.Description, 10)
+	}
+	pdf.writeLine(fmt.Sprintf("%s to %s, %s, initial balance %.2f, final balance %.2f",
+		session.StartDate.Format("2006-01-02"), session.EndDate.Format("2006-01-02"), session.Timeframe, session.InitialBalance, session.FinalBalance), 10)
+	pdf.space(10)
+
+	pdf.writeLine("Equity Curve", 14)
+	pdf.chart(equity)
+	pdf.writeLine("Drawdown", 14)
+	pdf.chart(drawdown)
+
+	pdf.writeLine("Performance Metrics", 14)
+	for _, metric := range report.Metrics {
+		pdf.writeColumns([]string{metric.Name, metric.Value}, []float64{0, 250}, 10)
+	}
+	pdf.space(10)
+
+	if monteCarlo := report.MonteCarlo; monteCarlo != nil {
+		pdf.writeLine(fmt.Sprintf("Monte Carlo (%d simulations)", monteCarlo.Simulations), 14)
+		columns := []float64{0, 150, 250, 350}
+		pdf.writeColumns([]string{"", "5th percentile", "Median", "95th percentile"}, columns, 10)
+		for _, row := range []struct {
+			name     string
+			interval models.ConfidenceInterval
+		}{
+			{"Max drawdown %", monteCarlo.MaxDrawdown},
+			{"CAGR %", monteCarlo.CAGR},
+			{"Final equity", monteCarlo.FinalEquity},
+		} {
+			pdf.writeColumns([]string{row.name, fmt.Sprintf("%.2f", row.interval.P5), fmt.Sprintf("%.2f", row.interval.Median), fmt.Sprintf("%.2f", row.interval.P95)}, columns, 10)
+		}
+		pdf.writeLine(fmt.Sprintf("Risk of ruin: %.2f", monteCarlo.RiskOfRuin), 10)
+		pdf.space(10)
+	}
+
+	pdf.writeLine("Trades", 14)
+	columns := []float64{0, 100, 180, 230, 290, 360, 440}
+	pdf.writeColumns([]string{"Time", "Symbol", "Side", "Quantity", "Price", "Commission", "Status"}, columns, 9)
+	for _, trade := range report.Trades {
+		pdf.writeColumns([]string{
+			trade.SimulatedFillTime.Format("2006-01-02 15:04"),
+			trade.Symbol,
+			string(trade.Direction),
+			strconv.Itoa(trade.FilledQuantity),
+			fmt.Sprintf("%.2f", trade.SimulatedFillPrice),
+			fmt.Sprintf("%.2f", trade.CommissionAmount),
+			string(trade.Status),
+		}, columns, 9)
+	}
+
+	pdf.space(10)
+	pdf.writeLine("Generated "+report.GeneratedAt.Format("2006-01-02 15:04"), 8)
+
+	return pdf.bytes()
+}
+
+// A4 page layout of generated PDFs, in points
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 40.0
+)
+
+// pdfWriter lays out text and line charts top to bottom over as many pages as needed,
+// using the standard Helvetica font so no fonts have to be embedded
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// newPDFWriter creates a new writer with one empty page
+func newPDFWriter() *pdfWriter {
+	pdf := &pdfWriter{}
+	pdf.newPage()
+	return pdf
+}
+
+// newPage starts a new page
+func (p *pdfWriter) newPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+	p.y = pdfPageHeight - pdfMargin
+}
+
+// reserve starts a new page unless height points fit on the current one
+func (p *pdfWriter) reserve(height float64) {
+	if p.y-height < pdfMargin {
+		p.newPage()
+	}
+}
+
+// space adds vertical space
+func (p *pdfWriter) space(height float64) {
+	p.y -= height
+}
+
+// writeLine writes a line of text
+func (p *pdfWriter) writeLine(text string, size float64) {
+	p.writeColumns([]string{text}, []float64{0}, size)
+}
+
+// writeColumns writes a row of text with each cell at its column offset
+func (p *pdfWriter) writeColumns(cells []string, offsets []float64, size float64) {
+	lineHeight := size * 1.4
+	p.reserve(lineHeight)
+	p.y -= lineHeight
+
+	page := p.pages[len(p.pages)-1]
+	for i, cell := range cells {
+		fmt.Fprintf(page, "BT /F1 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", size, pdfMargin+offsets[i], p.y, pdfEscape(cell))
+	}
+}
+
+// chart draws values as a line chart inside a bordered box
+func (p *pdfWriter) chart(values []float64) {
+	p.reserve(reportChartHeight + 10)
+	top := p.y - 5
+	bottom := top - reportChartHeight
+
+	page := p.pages[len(p.pages)-1]
+	fmt.Fprintf(page, "0.8 G %.2f %.2f %.2f %.2f re S\n", pdfMargin, bottom, reportChartWidth, reportChartHeight)
+
+	points := chartPoints(values, reportChartWidth, reportChartHeight)
+	if len(points) > 1 {
+		page.WriteString("0.12 0.47 0.71 RG 1 w\n")
+		for i, point := range points {
+			operator := "l"
+			if i == 0 {
+				operator = "m"
+			}
+			fmt.Fprintf(page, "%.2f %.2f %s\n", pdfMargin+point[0], top-point[1], operator)
+		}
+		page.WriteString("S 0 G\n")
+	}
+
+	p.y = bottom - 10
+}
+
+// bytes assembles the PDF file
+func (p *pdfWriter) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// Objects 1-3 are the catalog, page tree and font; each page then takes a page and a content object
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+i*2)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range p.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 5+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// pdfEscape escapes text for a PDF string literal, replacing characters the
+// standard fonts cannot show
+func pdfEscape(text string) string {
+	var builder strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			builder.WriteByte('\\')
+			builder.WriteRune(r)
+		case r < 32 || r > 126:
+			builder.WriteByte('?')
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
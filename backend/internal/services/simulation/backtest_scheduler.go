@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"trading_platform/backend/internal/models"
+)
+
+// defaultBacktestUserQuota is the number of backtests a user may run at once
+const defaultBacktestUserQuota = 2
+
+// backtestPriorityRanks orders the priority tiers, lowest rank first
+var backtestPriorityRanks = map[string]int{
+	models.BacktestPriorityHigh:   0,
+	models.BacktestPriorityNormal: 1,
+	models.BacktestPriorityLow:    2,
+}
+
+// scheduledBacktest is a backtest known to the scheduler
+type scheduledBacktest struct {
+	info     models.ScheduledBacktest
+	sequence int64
+	cancel   context.CancelFunc // Set while running
+}
+
+// BacktestScheduler runs strategy backtests on a fixed number of workers. Queued
+// backtests start in priority order, first come first served within a tier, passing
+// over users who are already running as many backtests as their quota allows.
+// Cancelling a running backtest stops its worker at the next market data event.
+type BacktestScheduler struct {
+	backtestService *BacktestService
+	workers         int
+	defaultQuota    int
+
+	mutex     sync.Mutex
+	wake      *sync.Cond
+	backtests map[string]*scheduledBacktest
+	queue     []*scheduledBacktest
+	running   map[string]int // Running backtests per user
+	quotas    map[string]int
+	sequence  int64
+	started   bool
+	stopped   bool
+	cancel    context.CancelFunc
+	waitGroup sync.WaitGroup
+}
+
+// NewBacktestScheduler creates a new scheduler running at most workers backtests at
+// once and, unless SetUserQuota says otherwise, userQuota per user
+func NewBacktestScheduler(backtestService *BacktestService, workers, userQuota int) *BacktestScheduler {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	if userQuota <= 0 {
+		userQuota = defaultBacktestUserQuota
+	}
+
+	scheduler := &BacktestScheduler{
+		backtestService: backtestService,
+		workers:         workers,
+		defaultQuota:    userQuota,
+		backtests:       make(map[string]*scheduledBacktest),
+		running:         make(map[string]int),
+		quotas:          make(map[string]int),
+	}
+	scheduler.wake = sync.NewCond(&scheduler.mutex)
+
+	return scheduler
+}
+
+// Start starts the workers. They run until Stop is called or the context is cancelled.
+func (s *BacktestScheduler) Start(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.started {
+		return errors.New("backtest scheduler has already been started")
+	}
+	s.started = true
+
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	// Wake idle workers when the context ends so they can exit
+	s.waitGroup.Add(1)
+	go func() {
+		defer s.waitGroup.Done()
+		<-ctx.Done()
+
+		s.mutex.Lock()
+		s.stopped = true
+		s.wake.Broadcast()
+		s.mutex.Unlock()
+	}()
+
+	for i := 0; i < s.workers; i++ {
+		s.waitGroup.Add(1)
+		go func() {
+			defer s.waitGroup.Done()
+			s.runWorker(ctx)
+		}()
+	}
+
+	return nil
+}
+
+// Stop stops the workers, cancelling running backtests, and waits for them to exit
+func (s *BacktestScheduler) Stop() {
+	s.mutex.Lock()
+	cancel := s.cancel
+	s.mutex.Unlock()
+
+	if cancel != nil {
+		cancel()
+		s.waitGroup.Wait()
+	}
+}
+
+// SetUserQuota sets the number of backtests a user may run at once
+func (s *BacktestScheduler) SetUserQuota(userID string, quota int) error {
+	if userID == "" {
+		return errors.New("user ID is required")
+	}
+
+	if quota <= 0 {
+		return errors.New("quota must be greater than zero")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.quotas[userID] = quota
+
+	// A higher quota may let queued backtests start
+	s.wake.Broadcast()
+	return nil
+}
+
+// Submit queues a strategy backtest. Priority is HIGH, NORMAL or LOW and defaults to NORMAL.
+func (s *BacktestScheduler) Submit(userID, priority string, session models.BacktestSession, marketSettings *models.MarketSettings) (*models.ScheduledBacktest, error) {
+	if userID == "" {
+		return nil, errors.New("user ID is required")
+	}
+
+	if priority == "" {
+		priority = models.BacktestPriorityNormal
+	}
+	if _, exists := backtestPriorityRanks[priority]; !exists {
+		return nil, errors.New("invalid priority: " + priority)
+	}
+
+	if session.GoStrategyName == "" {
+		return nil, errors.New("session has no Go strategy")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopped {
+		return nil, errors.New("backtest scheduler is stopped")
+	}
+
+	id := uuid.New().String()
+	if session.ID == "" {
+		session.ID = id
+	}
+	session.Status = "PENDING"
+
+	s.sequence++
+	backtest := &scheduledBacktest{
+		info: models.ScheduledBacktest{
+			ID:             id,
+			UserID:         userID,
+			Priority:       priority,
+			Status:         models.ScheduledBacktestQueued,
+			Session:        session,
+			MarketSettings: marketSettings,
+			SubmittedAt:    time.Now(),
+		},
+		sequence: s.sequence,
+	}
+
+	s.backtests[id] = backtest
+	s.queue = append(s.queue, backtest)
+	s.wake.Signal()
+
+	// In a real implementation, we would persist the backtest so the queue survives restarts
+
+	info := backtest.info
+	return &info, nil
+}
+
+// Get returns one of a user's backtests
+func (s *BacktestScheduler) Get(userID, backtestID string) (*models.ScheduledBacktest, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	backtest, exists := s.backtests[backtestID]
+	if !exists || backtest.info.UserID != userID {
+		return nil, errors.New("backtest not found")
+	}
+
+	info := backtest.info
+	return &info, nil
+}
+
+// List returns a user's backtests in the order they were submitted
+func (s *BacktestScheduler) List(userID string) ([]models.ScheduledBacktest, error) {
+	if userID == "" {
+		return nil, errors.New("user ID is required")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var owned []*scheduledBacktest
+	for _, backtest := range s.backtests {
+		if backtest.info.UserID == userID {
+			owned = append(owned, backtest)
+		}
+	}
+
+	sort.Slice(owned, func(i, j int) bool {
+		return owned[i].sequence < owned[j].sequence
+	})
+
+	backtests := make([]models.ScheduledBacktest, 0, len(owned))
+	for _, backtest := range owned {
+		backtests = append(backtests, backtest.info)
+	}
+
+	return backtests, nil
+}
+
+// Cancel cancels a queued backtest, or stops a running one mid-run
+func (s *BacktestScheduler) Cancel(userID, backtestID string) (*models.ScheduledBacktest, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	backtest, exists := s.backtests[backtestID]
+	if !exists || backtest.info.UserID != userID {
+		return nil, errors.New("backtest not found")
+	}
+
+	switch backtest.info.Status {
+	case models.ScheduledBacktestQueued:
+		for i, queued := range s.queue {
+			if queued == backtest {
+				s.queue = append(s.queue[:i], s.queue[i+1:]...)
+				break
+			}
+		}
+
+		now := time.Now()
+		backtest.info.Status = models.ScheduledBacktestCancelled
+		backtest.info.Session.Status = "CANCELLED"
+		backtest.info.FinishedAt = &now
+
+	case models.ScheduledBacktestRunning:
+		// The worker sets FinishedAt once the backtest has stopped
+		backtest.info.Status = models.ScheduledBacktestCancelled
+		backtest.cancel()
+
+	default:
+		return nil, errors.New("backtest has already finished")
+	}
+
+	info := backtest.info
+	return &info, nil
+}
+
+// runWorker runs queued backtests until the context is cancelled
+func (s *BacktestScheduler) runWorker(ctx context.Context) {
+	for {
+		s.mutex.Lock()
+		var backtest *scheduledBacktest
+		for {
+			if s.stopped {
+				s.mutex.Unlock()
+				return
+			}
+			if backtest = s.next(); backtest != nil {
+				break
+			}
+			s.wake.Wait()
+		}
+
+		runCtx, cancel := context.WithCancel(ctx)
+		startedAt := time.Now()
+		backtest.cancel = cancel
+		backtest.info.Status = models.ScheduledBacktestRunning
+		backtest.info.StartedAt = &startedAt
+		s.running[backtest.info.UserID]++
+
+		session := backtest.info.Session
+		marketSettings := backtest.info.MarketSettings
+		s.mutex.Unlock()
+
+		report, err := s.backtestService.RunStrategyBacktestContext(runCtx, &session, marketSettings)
+		cancel()
+
+		s.mutex.Lock()
+		finishedAt := time.Now()
+		backtest.cancel = nil
+		backtest.info.FinishedAt = &finishedAt
+		backtest.info.Session = session
+
+		switch {
+		case backtest.info.Status == models.ScheduledBacktestCancelled:
+			backtest.info.Session.Status = "CANCELLED"
+		case err != nil:
+			backtest.info.Status = models.ScheduledBacktestFailed
+			backtest.info.Error = err.Error()
+		default:
+			backtest.info.Status = models.ScheduledBacktestCompleted
+			backtest.info.Report = report
+		}
+
+		s.running[backtest.info.UserID]--
+		if s.running[backtest.info.UserID] == 0 {
+			delete(s.running, backtest.info.UserID)
+		}
+
+		// The user may now be under quota for another queued backtest
+		s.wake.Broadcast()
+		s.mutex.Unlock()
+	}
+}
+
+// next removes and returns the queued backtest to run next: the highest priority,
+// earliest submitted backtest whose user is under quota. The caller must hold the mutex.
+func (s *BacktestScheduler) next() *scheduledBacktest {
+	best := -1
+	for i, backtest := range s.queue {
+		quota, exists := s.quotas[backtest.info.UserID]
+		if !exists {
+			quota = s.defaultQuota
+		}
+		if s.running[backtest.info.UserID] >= quota {
+			continue
+		}
+
+		if best == -1 || isScheduledBefore(backtest, s.queue[best]) {
+			best = i
+		}
+	}
+
+	if best == -1 {
+		return nil
+	}
+
+	backtest := s.queue[best]
+	s.queue = append(s.queue[:best], s.queue[best+1:]...)
+	return backtest
+}
+
+// isScheduledBefore reports whether a should run before b
+func isScheduledBefore(a, b *scheduledBacktest) bool {
+	rankA, rankB := backtestPriorityRanks[a.info.Priority], backtestPriorityRanks[b.info.Priority]
+	if rankA != rankB {
+		return rankA < rankB
+	}
+	return a.sequence < b.sequence
+}
@@ -2,6 +2,9 @@ package services
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 	"github.com/google/uuid"
 	"trading_platform/backend/internal/models"
@@ -14,15 +17,31 @@ type BacktestService struct {
 	marketSimulationService *MarketSimulationService
 	simulationOrderService  *SimulationOrderService
 	virtualBalanceService   *VirtualBalanceService
+	parameterEvaluator      ParameterEvaluator
+	dataCache               *HistoricalDataCache
+	customData              map[string]*customDataset
+	customDataMutex         sync.RWMutex
+	reportStorage           ReportStorage
 }
 
 // NewBacktestService creates a new instance of BacktestService
 func NewBacktestService() *BacktestService {
-	return &BacktestService{
+	service := &BacktestService{
 		marketSimulationService: NewMarketSimulationService(),
 		simulationOrderService:  NewSimulationOrderService(),
 		virtualBalanceService:   NewVirtualBalanceService(),
+		customData:              make(map[string]*customDataset),
+		// In a real implementation, this would be object storage such as S3
+		reportStorage:           NewFileReportStorage(filepath.Join(os.TempDir(), "backtest_reports")),
 	}
+	service.dataCache = NewHistoricalDataCache(service.loadHistoricalData, defaultDataCacheRows)
+	
+	return service
+}
+
+// GetDataCacheStats returns the statistics of the historical data cache shared by backtests
+func (s *BacktestService) GetDataCacheStats() DataCacheStats {
+	return s.dataCache.Stats()
 }
 
 // CreateBacktestSession creates a new backtest session
@@ -282,6 +301,28 @@ func (s *BacktestService) GetBacktestPerformanceMetrics(sessionID string) (map[s
 	// In a real implementation, we would calculate metrics based on
 	// backtest results in the database
 	
+	results, err := s.GetBacktestResults(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	
+	equityCurve := make([]float64, 0, len(results)+1)
+	if len(results) > 0 {
+		equityCurve = append(equityCurve, results[0].EquityCurve-results[0].DailyPnL)
+	}
+	for _, result := range results {
+		equityCurve = append(equityCurve, result.EquityCurve)
+	}
+	
+	// Bootstrap the equity curve to put confidence intervals around the point estimates
+	var monteCarlo *models.MonteCarloResult
+	if len(equityCurve) >= 2 {
+		monteCarlo, err = RunMonteCarloAnalysis(equityCurve, defaultMonteCarloSimulations, 252, defaultRuinThreshold, sessionSeed(sessionID))
+		if err != nil {
+			return nil, err
+		}
+	}
+	
 	// For now, return mock metrics
 	return map[string]interface{}{
 		"totalReturn":        15.0,
@@ -306,6 +347,7 @@ func (s *BacktestService) GetBacktestPerformanceMetrics(sessionID string) (map[s
 		"informationRatio":   1.5,
 		"alphaAnnualized":    5.2,
 		"betaVsMarket":       0.85,
+		"monteCarlo":         monteCarlo,
 	}, nil
 }
 
@@ -495,26 +537,6 @@ func (s *BacktestService) OptimizeStrategy(strategyID string, parameterRanges ma
 	}, nil
 }
 
-// ExportBacktestResults exports backtest results to a specified format
-func (s *BacktestService) ExportBacktestResults(sessionID string, format string) (string, error) {
-	if sessionID == "" {
-		return "", errors.New("session ID is required")
-	}
-	
-	if format == "" {
-		return "", errors.New("format is required")
-	}
-	
-	// In a real implementation, we would:
-	// 1. Retrieve the backtest results from the database
-	// 2. Format the results according to the specified format
-	// 3. Save the formatted results to a file
-	// 4. Return the file path
-	
-	// For now, return a mock file path
-	return "/tmp/backtest_results_" + sessionID + "." + format, nil
-}
-
 // processBacktest processes a backtest session
 func (s *BacktestService) processBacktest(session *models.BacktestSession) error {
 	// In a real implementation, this would be a complex process that:
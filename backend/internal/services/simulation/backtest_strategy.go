@@ -0,0 +1,772 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"trading_platform/backend/internal/models"
+)
+
+// BacktestStrategy is implemented by custom Go strategies run by the backtester.
+// Strategies are either compiled in and registered with RegisterBacktestStrategy,
+// or built as Go plugins and loaded with LoadBacktestStrategyPlugin.
+type BacktestStrategy interface {
+	// OnInit is called once before any market data is delivered
+	OnInit(ctx BacktestStrategyContext) error
+
+	// OnTick is called for every tick when running on tick data
+	OnTick(ctx BacktestStrategyContext, tick models.MarketDataSnapshot) error
+
+	// OnCandle is called for every completed candle when running on bar data
+	OnCandle(ctx BacktestStrategyContext, candle models.MarketDataSnapshot) error
+
+	// OnFill is called whenever one of the strategy's orders is filled
+	OnFill(ctx BacktestStrategyContext, fill models.SimulationOrder) error
+}
+
+// BacktestStrategyContext gives strategies access to the simulated account
+type BacktestStrategyContext interface {
+	// SubmitOrder queues an order and returns its ID. Orders are matched against
+	// market data delivered after the current event, never the current one.
+	SubmitOrder(order models.Order) (string, error)
+
+	// CancelOrder cancels a pending order
+	CancelOrder(orderID string) error
+
+	// Position returns the net quantity held in a symbol, negative when short
+	Position(symbol string) int
+
+	// Cash returns the available cash balance
+	Cash() float64
+
+	// Equity returns cash plus the marked-to-market value of open positions
+	Equity() float64
+
+	// Parameters returns the strategy parameters of the backtest
+	Parameters() map[string]interface{}
+
+	// Now returns the simulated time of the current event
+	Now() time.Time
+
+	// SetLegGroupExit squares off all legs in symbols as soon as their combined
+	// unrealized P&L falls to -stopLoss or reaches target. A zero value disables that side.
+	SetLegGroupExit(name string, symbols []string, stopLoss, target float64) error
+}
+
+// BacktestStrategyFactory creates a strategy instance from backtest parameters
+type BacktestStrategyFactory func(parameters map[string]interface{}) (BacktestStrategy, error)
+
+var (
+	backtestStrategies      = make(map[string]BacktestStrategyFactory)
+	backtestStrategiesMutex sync.RWMutex
+)
+
+// RegisterBacktestStrategy registers a compiled-in Go strategy under a name
+func RegisterBacktestStrategy(name string, factory BacktestStrategyFactory) error {
+	if name == "" {
+		return errors.New("strategy name is required")
+	}
+
+	if factory == nil {
+		return errors.New("strategy factory is required")
+	}
+
+	backtestStrategiesMutex.Lock()
+	defer backtestStrategiesMutex.Unlock()
+
+	if _, exists := backtestStrategies[name]; exists {
+		return errors.New("strategy already registered: " + name)
+	}
+
+	backtestStrategies[name] = factory
+	return nil
+}
+
+// NewBacktestStrategy creates an instance of a registered Go strategy
+func NewBacktestStrategy(name string, parameters map[string]interface{}) (BacktestStrategy, error) {
+	backtestStrategiesMutex.RLock()
+	factory, exists := backtestStrategies[name]
+	backtestStrategiesMutex.RUnlock()
+
+	if !exists {
+		return nil, errors.New("strategy not registered: " + name)
+	}
+
+	return factory(parameters)
+}
+
+// RegisteredBacktestStrategies returns the names of all registered Go strategies
+func RegisteredBacktestStrategies() []string {
+	backtestStrategiesMutex.RLock()
+	defer backtestStrategiesMutex.RUnlock()
+
+	names := make([]string, 0, len(backtestStrategies))
+	for name := range backtestStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// LoadBacktestStrategyPlugin loads a strategy built with -buildmode=plugin and registers it.
+// The plugin must export a NewStrategy function with the BacktestStrategyFactory signature,
+// and may export a StrategyName string; otherwise the file name is used as the name.
+func LoadBacktestStrategyPlugin(path string) (string, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open strategy plugin: %w", err)
+	}
+
+	symbol, err := p.Lookup("NewStrategy")
+	if err != nil {
+		return "", fmt.Errorf("strategy plugin does not export NewStrategy: %w", err)
+	}
+
+	var factory BacktestStrategyFactory
+	switch f := symbol.(type) {
+	case func(map[string]interface{}) (BacktestStrategy, error):
+		factory = f
+	case *BacktestStrategyFactory:
+		factory = *f
+	default:
+		return "", errors.New("NewStrategy has an unexpected signature")
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if symbol, err := p.Lookup("StrategyName"); err == nil {
+		if exported, ok := symbol.(*string); ok && *exported != "" {
+			name = *exported
+		}
+	}
+
+	if err := RegisterBacktestStrategy(name, factory); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// backtestPosition is the net position held in a symbol during a backtest
+type backtestPosition struct {
+	quantity     int
+	averagePrice float64
+	productType  models.ProductType // Product of the order that opened the position
+}
+
+// StrategyBacktester runs a BacktestStrategy over historical market data,
+// simulating fills, costs, and the account balance
+type StrategyBacktester struct {
+	strategy   BacktestStrategy
+	settings   *models.MarketSettings
+	parameters map[string]interface{}
+	sessionID  string
+
+	cash       float64
+	positions  map[string]*backtestPosition
+	symbols    []string // Symbols with positions, sorted so sums are computed in a fixed order
+	lastPrices map[string]float64
+	quotes     map[string]models.MarketDataSnapshot
+	pending    []*models.SimulationOrder
+	now        time.Time
+	nextID     int
+
+	legGroups map[string]*legGroup
+	triggered map[string]bool
+	random    *rand.Rand
+	ctx       context.Context
+	margin    *intradayMargin
+
+	initialEquity  float64
+	peakEquity     float64
+	previousEquity float64
+
+	totalCharges  float64
+	totalSlippage float64
+	legSlippage   map[string]float64
+
+	report models.StrategyBacktestReport
+}
+
+// NewStrategyBacktester creates a new backtester for a strategy
+func NewStrategyBacktester(sessionID string, strategy BacktestStrategy, initialBalance float64, settings *models.MarketSettings, parameters map[string]interface{}) *StrategyBacktester {
+	if settings == nil {
+		settings = &models.MarketSettings{AllowShortSelling: true}
+	}
+
+	return &StrategyBacktester{
+		strategy:    strategy,
+		settings:    settings,
+		parameters:  parameters,
+		sessionID:   sessionID,
+		cash:        initialBalance,
+		positions:   make(map[string]*backtestPosition),
+		lastPrices:  make(map[string]float64),
+		quotes:      make(map[string]models.MarketDataSnapshot),
+		legGroups:   make(map[string]*legGroup),
+		triggered:   make(map[string]bool),
+		legSlippage: make(map[string]float64),
+		random:      rand.New(rand.NewSource(0)),
+		ctx:         context.Background(),
+	}
+}
+
+// SetRandomSeed seeds the randomness of the VARIABLE and REALISTIC slippage and
+// latency models. Runs with the same seed and inputs produce identical results.
+func (b *StrategyBacktester) SetRandomSeed(seed int64) {
+	b.random = rand.New(rand.NewSource(seed))
+}
+
+// SetContext makes runs stop with the context's error as soon as it is cancelled
+func (b *StrategyBacktester) SetContext(ctx context.Context) {
+	b.ctx = ctx
+}
+
+// SubmitOrder implements BacktestStrategyContext
+func (b *StrategyBacktester) SubmitOrder(order models.Order) (string, error) {
+	if order.Symbol == "" {
+		return "", errors.New("symbol is required")
+	}
+
+	if order.Quantity <= 0 {
+		return "", errors.New("quantity must be greater than zero")
+	}
+
+	if order.Direction != models.OrderDirectionBuy && order.Direction != models.OrderDirectionSell {
+		return "", errors.New("invalid order direction")
+	}
+
+	switch order.OrderType {
+	case models.OrderTypeMarket:
+	case models.OrderTypeLimit:
+		if order.Price <= 0 {
+			return "", errors.New("limit orders require a price")
+		}
+	case models.OrderTypeSLLimit:
+		if order.Price <= 0 || order.TriggerPrice <= 0 {
+			return "", errors.New("stop-limit orders require a price and trigger price")
+		}
+	default:
+		return "", errors.New("invalid order type")
+	}
+
+	b.nextID++
+	order.ID = fmt.Sprintf("%s-%d", b.sessionID, b.nextID)
+	if err := b.checkIntradayMargin(&order); err != nil {
+		return "", err
+	}
+
+	order.Status = models.OrderStatusPending
+	order.CreatedAt = b.now
+	order.UpdatedAt = b.now
+
+	backtestDate := b.now
+	b.pending = append(b.pending, &models.SimulationOrder{
+		Order:           order,
+		LatencyMs:       b.latency(),
+		IsBacktestOrder: true,
+		BacktestDate:    &backtestDate,
+	})
+
+	return order.ID, nil
+}
+
+// CancelOrder implements BacktestStrategyContext
+func (b *StrategyBacktester) CancelOrder(orderID string) error {
+	for i, order := range b.pending {
+		if order.ID == orderID {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			delete(b.triggered, orderID)
+			return nil
+		}
+	}
+
+	return errors.New("order not found or already filled")
+}
+
+// Position implements BacktestStrategyContext
+func (b *StrategyBacktester) Position(symbol string) int {
+	if position, exists := b.positions[symbol]; exists {
+		return position.quantity
+	}
+	return 0
+}
+
+// Cash implements BacktestStrategyContext
+func (b *StrategyBacktester) Cash() float64 {
+	return b.cash
+}
+
+// Equity implements BacktestStrategyContext
+func (b *StrategyBacktester) Equity() float64 {
+	equity := b.cash
+	for _, symbol := range b.symbols {
+		equity += float64(b.positions[symbol].quantity) * b.lastPrices[symbol]
+	}
+	return equity
+}
+
+// Parameters implements BacktestStrategyContext
+func (b *StrategyBacktester) Parameters() map[string]interface{} {
+	return b.parameters
+}
+
+// Now implements BacktestStrategyContext
+func (b *StrategyBacktester) Now() time.Time {
+	return b.now
+}
+
+// RunCandles runs the strategy over candles for one or more symbols. Pending orders
+// are matched against each candle before the strategy sees it, so orders placed on
+// a candle's close fill on the following candle.
+func (b *StrategyBacktester) RunCandles(candles []models.MarketDataSnapshot) (*models.StrategyBacktestReport, error) {
+	if len(candles) == 0 {
+		return nil, errors.New("no market data to backtest")
+	}
+
+	sorted := append([]models.MarketDataSnapshot(nil), candles...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	b.now = sorted[0].Timestamp
+	if err := b.strategy.OnInit(b); err != nil {
+		return nil, fmt.Errorf("strategy initialization failed: %w", err)
+	}
+
+	b.startRecording()
+
+	for i, candle := range sorted {
+		if err := b.ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		b.now = candle.Timestamp
+
+		if err := b.enforceIntradayMargin(candle.Symbol, candle.Open); err != nil {
+			return nil, err
+		}
+
+		if err := b.matchOrders(candle); err != nil {
+			return nil, err
+		}
+
+		b.lastPrices[candle.Symbol] = candle.Close
+
+		if err := b.strategy.OnCandle(b, candle); err != nil {
+			return nil, fmt.Errorf("strategy failed on candle at %s: %w", candle.Timestamp, err)
+		}
+
+		// Record one equity point per timestamp, after every symbol has been processed
+		if i+1 < len(sorted) && sorted[i+1].Timestamp.Equal(candle.Timestamp) {
+			continue
+		}
+
+		b.recordResult(candle.Timestamp)
+	}
+
+	b.finishReport()
+	return &b.report, nil
+}
+
+// finishReport fills in the report's closing balance and cost breakdown
+func (b *StrategyBacktester) finishReport() {
+	b.report.FinalBalance = b.Equity()
+	b.report.NetPnL = b.report.FinalBalance - b.initialEquity
+	b.report.GrossPnL = b.report.NetPnL + b.totalCharges + b.totalSlippage
+	b.report.TotalCharges = b.totalCharges
+	b.report.TotalSlippage = b.totalSlippage
+	b.report.LegSlippage = copyLegSlippage(b.legSlippage)
+
+	if b.margin != nil {
+		marginReport := b.margin.report
+		b.report.IntradayMargin = &marginReport
+	}
+}
+
+// copyLegSlippage copies per-symbol slippage so each result keeps its own totals
+func copyLegSlippage(legSlippage map[string]float64) map[string]float64 {
+	copied := make(map[string]float64, len(legSlippage))
+	for symbol, slippage := range legSlippage {
+		copied[symbol] = slippage
+	}
+	return copied
+}
+
+// startRecording resets the equity tracking used by recordResult
+func (b *StrategyBacktester) startRecording() {
+	b.initialEquity = b.cash
+	b.peakEquity = b.cash
+	b.previousEquity = b.cash
+}
+
+// recordResult appends an equity curve point for the current account state
+func (b *StrategyBacktester) recordResult(timestamp time.Time) {
+	equity := b.Equity()
+	if equity > b.peakEquity {
+		b.peakEquity = equity
+	}
+
+	drawdown := 0.0
+	if b.peakEquity > 0 {
+		drawdown = (b.peakEquity - equity) / b.peakEquity * 100.0
+	}
+
+	openPositions := 0
+	for _, position := range b.positions {
+		if position.quantity != 0 {
+			openPositions++
+		}
+	}
+
+	b.report.Results = append(b.report.Results, models.BacktestResult{
+		ID:                fmt.Sprintf("%s-result-%d", b.sessionID, len(b.report.Results)+1),
+		BacktestSessionID: b.sessionID,
+		Timestamp:         timestamp,
+		EquityCurve:       equity,
+		DrawdownCurve:     drawdown,
+		OpenPositions:     openPositions,
+		CumulativePnL:     equity - b.initialEquity,
+		DailyPnL:          equity - b.previousEquity,
+		MarketValue:       equity - b.cash,
+		CashBalance:       b.cash,
+		GrossPnL:          equity - b.initialEquity + b.totalCharges + b.totalSlippage,
+		TotalCharges:      b.totalCharges,
+		TotalSlippage:     b.totalSlippage,
+		LegSlippage:       copyLegSlippage(b.legSlippage),
+	})
+	b.previousEquity = equity
+}
+
+// matchOrders fills pending orders for the candle's symbol that the candle trades through
+func (b *StrategyBacktester) matchOrders(candle models.MarketDataSnapshot) error {
+	var remaining []*models.SimulationOrder
+	var filled []*models.SimulationOrder
+
+	for _, order := range b.pending {
+		if order.Symbol != candle.Symbol || !b.arrived(order) {
+			remaining = append(remaining, order)
+			continue
+		}
+
+		price, ok := fillPrice(order, candle)
+		if !ok {
+			remaining = append(remaining, order)
+			continue
+		}
+
+		b.fill(order, price, order.Quantity-order.FilledQuantity)
+		filled = append(filled, order)
+	}
+	b.pending = remaining
+
+	// Notify the strategy after matching so orders it places in OnFill wait for the next event
+	for _, order := range filled {
+		if err := b.strategy.OnFill(b, *order); err != nil {
+			return fmt.Errorf("strategy failed on fill of order %s: %w", order.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// fillPrice returns the price at which an order fills on a candle, if it fills at all
+func fillPrice(order *models.SimulationOrder, candle models.MarketDataSnapshot) (float64, bool) {
+	isBuy := order.Direction == models.OrderDirectionBuy
+
+	switch order.OrderType {
+	case models.OrderTypeMarket:
+		return candle.Open, true
+	case models.OrderTypeLimit:
+		return limitFillPrice(isBuy, order.Price, candle)
+	case models.OrderTypeSLLimit:
+		triggered := (isBuy && candle.High >= order.TriggerPrice) || (!isBuy && candle.Low <= order.TriggerPrice)
+		if !triggered {
+			return 0, false
+		}
+		return limitFillPrice(isBuy, order.Price, candle)
+	}
+
+	return 0, false
+}
+
+// limitFillPrice fills a limit order at its price, or at the open if the candle gaps through it
+func limitFillPrice(isBuy bool, limit float64, candle models.MarketDataSnapshot) (float64, bool) {
+	if isBuy && candle.Low <= limit {
+		if candle.Open < limit {
+			return candle.Open, true
+		}
+		return limit, true
+	}
+
+	if !isBuy && candle.High >= limit {
+		if candle.Open > limit {
+			return candle.Open, true
+		}
+		return limit, true
+	}
+
+	return 0, false
+}
+
+// fill executes quantity of an order, applying slippage and commission and updating
+// the account. Orders left with unfilled quantity are marked partially filled.
+func (b *StrategyBacktester) fill(order *models.SimulationOrder, price float64, quantity int) {
+	isBuy := order.Direction == models.OrderDirectionBuy
+
+	// Market orders pay slippage; limit orders fill at their price or better
+	slippage := 0.0
+	if order.OrderType == models.OrderTypeMarket {
+		switch b.settings.SlippageModel {
+		case "FIXED":
+			slippage = b.settings.SlippageValue
+		case "PERCENTAGE":
+			slippage = price * b.settings.SlippageValue
+		case "VARIABLE":
+			// Uniform between none and twice the configured percentage
+			slippage = price * b.settings.SlippageValue * 2 * b.random.Float64()
+		}
+		if isBuy {
+			price += slippage
+		} else {
+			price -= slippage
+		}
+	}
+
+	signed := quantity
+	if !isBuy {
+		signed = -quantity
+	}
+
+	position := b.positions[order.Symbol]
+	if position == nil {
+		position = &backtestPosition{}
+		b.positions[order.Symbol] = position
+
+		index := sort.SearchStrings(b.symbols, order.Symbol)
+		b.symbols = append(b.symbols, "")
+		copy(b.symbols[index+1:], b.symbols[index:])
+		b.symbols[index] = order.Symbol
+	}
+
+	if !b.settings.AllowShortSelling && position.quantity+signed < 0 {
+		order.Status = models.OrderStatusRejected
+		order.ErrorMessage = "short selling is not allowed"
+		order.UpdatedAt = b.now
+		b.report.Trades = append(b.report.Trades, *order)
+		return
+	}
+
+	// Fixed commissions are charged once per order, on its first fill
+	commission := 0.0
+	switch b.settings.CommissionModel {
+	case "FIXED":
+		if order.FilledQuantity == 0 {
+			commission = b.settings.CommissionValue
+		}
+	case "PERCENTAGE":
+		commission = price * float64(quantity) * b.settings.CommissionValue
+	}
+
+	if position.quantity == 0 || (position.quantity > 0) == (signed > 0) {
+		// Opening or adding to a position
+		total := abs(position.quantity) + abs(signed)
+		if position.quantity == 0 {
+			position.productType = order.ProductType
+		}
+		position.averagePrice = (position.averagePrice*float64(abs(position.quantity)) + price*float64(abs(signed))) / float64(total)
+		position.quantity += signed
+	} else {
+		// Reducing, closing or reversing a position
+		closing := abs(signed)
+		if abs(position.quantity) < closing {
+			closing = abs(position.quantity)
+		}
+
+		direction := 1.0
+		if position.quantity < 0 {
+			direction = -1.0
+		}
+
+		pnl := float64(closing)*(price-position.averagePrice)*direction - commission
+		b.report.RealizedPnL += pnl
+		b.report.TotalTrades++
+		if pnl > 0 {
+			b.report.WinningTrades++
+		} else {
+			b.report.LosingTrades++
+		}
+
+		wasLong := position.quantity > 0
+		position.quantity += signed
+		if position.quantity == 0 {
+			position.averagePrice = 0
+		} else if (position.quantity > 0) != wasLong {
+			position.averagePrice = price
+			position.productType = order.ProductType
+		}
+	}
+
+	b.cash -= float64(signed)*price + commission
+
+	slippageCost := slippage * float64(quantity)
+	b.totalCharges += commission
+	b.totalSlippage += slippageCost
+	b.legSlippage[order.Symbol] += slippageCost
+
+	filledBefore := float64(order.FilledQuantity)
+	order.FilledQuantity += quantity
+	order.AveragePrice = (order.AveragePrice*filledBefore + price*float64(quantity)) / float64(order.FilledQuantity)
+	order.Status = models.OrderStatusExecuted
+	if order.FilledQuantity < order.Quantity {
+		order.Status = models.OrderStatusPartial
+	}
+	order.Slippage = slippage
+	order.ExecutionTime = b.now
+	order.UpdatedAt = b.now
+	order.SimulatedFillPrice = price
+	order.SimulatedFillTime = b.now
+	order.SlippageAmount = slippage
+	order.CommissionAmount += commission
+
+	b.report.Trades = append(b.report.Trades, *order)
+}
+
+// latency returns the simulated order latency in milliseconds for the latency model
+func (b *StrategyBacktester) latency() int {
+	switch b.settings.LatencyModel {
+	case "FIXED":
+		return b.settings.LatencyValue
+	case "VARIABLE":
+		return b.settings.LatencyValue + b.random.Intn(50)
+	case "REALISTIC":
+		// Occasional spikes on top of variable latency
+		if b.random.Intn(100) < 5 {
+			return b.settings.LatencyValue * 5
+		}
+		return b.settings.LatencyValue + b.random.Intn(50)
+	}
+	return 0
+}
+
+// arrived reports whether an order has reached the simulated exchange by the current time
+func (b *StrategyBacktester) arrived(order *models.SimulationOrder) bool {
+	return !order.CreatedAt.Add(time.Duration(order.LatencyMs) * time.Millisecond).After(b.now)
+}
+
+// abs returns the absolute value of an int
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// RunStrategyBacktest runs the session's registered Go strategy over historical data
+// for the session's symbols and updates the session with the outcome
+func (s *BacktestService) RunStrategyBacktest(session *models.BacktestSession, marketSettings *models.MarketSettings) (*models.StrategyBacktestReport, error) {
+	return s.RunStrategyBacktestContext(context.Background(), session, marketSettings)
+}
+
+// RunStrategyBacktestContext is RunStrategyBacktest stopping mid-run when ctx is cancelled
+func (s *BacktestService) RunStrategyBacktestContext(ctx context.Context, session *models.BacktestSession, marketSettings *models.MarketSettings) (*models.StrategyBacktestReport, error) {
+	if session == nil {
+		return nil, errors.New("session is required")
+	}
+
+	if session.GoStrategyName == "" {
+		return nil, errors.New("session has no Go strategy")
+	}
+
+	strategy, err := NewBacktestStrategy(session.GoStrategyName, session.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	s.dataCache.Prefetch(session.Symbols, session.StartDate, session.EndDate, session.Timeframe)
+
+	// Candles hold ticks when the session runs on tick data
+	var candles []models.MarketDataSnapshot
+	for _, symbol := range session.Symbols {
+		data, err := s.dataCache.Get(symbol, session.StartDate, session.EndDate, session.Timeframe)
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, data...)
+	}
+
+	session.Status = "RUNNING"
+
+	backtester := NewStrategyBacktester(session.ID, strategy, session.InitialBalance, marketSettings, session.Parameters)
+	backtester.SetRandomSeed(session.RandomSeed)
+	backtester.SetContext(ctx)
+
+	if session.IntradayMargin != nil {
+		if err := backtester.SetIntradayMargin(*session.IntradayMargin); err != nil {
+			return nil, err
+		}
+	}
+
+	var report *models.StrategyBacktestReport
+	if session.Timeframe == "tick" {
+		report, err = backtester.RunTicks(candles)
+	} else {
+		report, err = backtester.RunCandles(candles)
+	}
+	if err != nil {
+		session.Status = "FAILED"
+		if errors.Is(err, context.Canceled) {
+			session.Status = "CANCELLED"
+		}
+		return nil, err
+	}
+
+	completedAt := time.Now()
+	session.Status = "COMPLETED"
+	session.CompletedAt = &completedAt
+	session.FinalBalance = report.FinalBalance
+	session.TotalTrades = report.TotalTrades
+	session.WinningTrades = report.WinningTrades
+	session.LosingTrades = report.LosingTrades
+
+	maxDrawdown := 0.0
+	for _, result := range report.Results {
+		if result.DrawdownCurve > maxDrawdown {
+			maxDrawdown = result.DrawdownCurve
+		}
+	}
+	session.MaxDrawdown = maxDrawdown
+
+	// In a real implementation, we would save the session, results and trades to the database
+
+	return report, nil
+}
+
+// BacktestReportFingerprint returns a SHA-256 digest of a backtest report. Backtests
+// are deterministic for a given seed, so CI can store the fingerprint of a known-good
+// run as a regression baseline and fail when a change alters any result.
+func BacktestReportFingerprint(report *models.StrategyBacktestReport) (string, error) {
+	if report == nil {
+		return "", errors.New("report is required")
+	}
+
+	// Maps are encoded with sorted keys, so equal reports always encode identically
+	data, err := json.Marshal(report)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:]), nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"trading_platform/backend/internal/models"
+)
+
+// tcaCosts accumulates traded volume and costs for one symbol
+type tcaCosts struct {
+	quantity int
+	orders   int
+	notional float64
+	slippage float64
+	charges  float64
+}
+
+// GenerateTCAReport compares the costs assumed by a strategy backtest with the costs
+// realized when the same strategy traded live. liveCharges maps live order IDs to the
+// commission and charges reported by the broker; orders without an entry are taken
+// to have paid none. Realized slippage uses each order's Slippage when set, otherwise
+// the adverse difference between the average fill price and the order price.
+func (s *BacktestService) GenerateTCAReport(sessionID string, backtest *models.StrategyBacktestReport, liveOrders []models.Order, liveCharges map[string]float64) (*models.TCAReport, error) {
+	if backtest == nil {
+		return nil, errors.New("backtest report is required")
+	}
+
+	if len(liveOrders) == 0 {
+		return nil, errors.New("no live orders to analyze")
+	}
+
+	assumed := make(map[string]*tcaCosts)
+	for _, trade := range backtest.Trades {
+		// Trades hold one entry per fill with cumulative totals, so partial fills
+		// are counted through the order's final EXECUTED entry
+		if trade.Status != models.OrderStatusExecuted {
+			continue
+		}
+
+		costs := assumed[trade.Symbol]
+		if costs == nil {
+			costs = &tcaCosts{}
+			assumed[trade.Symbol] = costs
+		}
+
+		costs.quantity += trade.FilledQuantity
+		costs.orders++
+		costs.notional += trade.AveragePrice * float64(trade.FilledQuantity)
+		costs.slippage += trade.Slippage * float64(trade.FilledQuantity)
+		costs.charges += trade.CommissionAmount
+	}
+
+	realized := make(map[string]*tcaCosts)
+	for _, order := range liveOrders {
+		if order.FilledQuantity <= 0 {
+			continue
+		}
+
+		costs := realized[order.Symbol]
+		if costs == nil {
+			costs = &tcaCosts{}
+			realized[order.Symbol] = costs
+		}
+
+		slippage := order.Slippage
+		if slippage == 0 && order.Price > 0 && order.AveragePrice > 0 {
+			slippage = order.AveragePrice - order.Price
+			if order.Direction == models.OrderDirectionSell {
+				slippage = -slippage
+			}
+		}
+
+		costs.quantity += order.FilledQuantity
+		costs.orders++
+		costs.notional += order.AveragePrice * float64(order.FilledQuantity)
+		costs.slippage += slippage * float64(order.FilledQuantity)
+		costs.charges += liveCharges[order.ID]
+	}
+
+	if len(realized) == 0 {
+		return nil, errors.New("no filled live orders to analyze")
+	}
+
+	report := &models.TCAReport{
+		BacktestSessionID: sessionID,
+		GeneratedAt:       time.Now(),
+	}
+
+	symbols := make([]string, 0, len(realized))
+	for symbol := range realized {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		live := realized[symbol]
+		backtested, exists := assumed[symbol]
+		if !exists {
+			report.UnmatchedLiveSymbols = append(report.UnmatchedLiveSymbols, symbol)
+			continue
+		}
+
+		leg := models.TCALegReport{
+			Symbol:                  symbol,
+			BacktestQuantity:        backtested.quantity,
+			LiveQuantity:            live.quantity,
+			LiveOrders:              live.orders,
+			AssumedSlippagePerUnit:  backtested.slippage / float64(backtested.quantity),
+			RealizedSlippagePerUnit: live.slippage / float64(live.quantity),
+		}
+
+		if backtested.notional > 0 {
+			leg.AssumedChargesRate = backtested.charges / backtested.notional
+		}
+		if live.notional > 0 {
+			leg.RealizedChargesRate = live.charges / live.notional
+		}
+
+		// Scale the backtest's assumptions to the volume actually traded live
+		leg.AssumedCost = leg.AssumedSlippagePerUnit*float64(live.quantity) + leg.AssumedChargesRate*live.notional
+		leg.RealizedCost = live.slippage + live.charges
+		leg.CostDifference = leg.RealizedCost - leg.AssumedCost
+
+		report.Legs = append(report.Legs, leg)
+		report.TotalAssumedCost += leg.AssumedCost
+		report.TotalRealizedCost += leg.RealizedCost
+	}
+
+	report.TotalCostDifference = report.TotalRealizedCost - report.TotalAssumedCost
+
+	return report, nil
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"trading_platform/backend/internal/models"
+)
+
+// tickResultInterval is how often the tick engine records an equity curve point
+const tickResultInterval = time.Minute
+
+// legGroupExitTag tags orders generated when a leg group hits its stop-loss or target
+const legGroupExitTag = "LEG_GROUP_EXIT"
+
+// legGroup is a set of legs, e.g. the legs of an option spread, that are exited
+// together when their combined unrealized P&L hits a stop-loss or target
+type legGroup struct {
+	name       string
+	strategyID string
+	symbols    []string
+	stopLoss   float64
+	target     float64
+}
+
+// SetLegGroupExit implements BacktestStrategyContext
+func (b *StrategyBacktester) SetLegGroupExit(name string, symbols []string, stopLoss, target float64) error {
+	return b.setLegGroupExit("", name, symbols, stopLoss, target)
+}
+
+// setLegGroupExit registers a leg group whose exit orders are attributed to strategyID
+func (b *StrategyBacktester) setLegGroupExit(strategyID, name string, symbols []string, stopLoss, target float64) error {
+	if name == "" {
+		return errors.New("leg group name is required")
+	}
+
+	if stopLoss < 0 || target < 0 {
+		return errors.New("stop-loss and target must not be negative")
+	}
+
+	if stopLoss == 0 && target == 0 {
+		delete(b.legGroups, name)
+		return nil
+	}
+
+	if len(symbols) == 0 {
+		return errors.New("at least one symbol is required")
+	}
+
+	b.legGroups[name] = &legGroup{
+		name:       name,
+		strategyID: strategyID,
+		symbols:    append([]string(nil), symbols...),
+		stopLoss:   stopLoss,
+		target:     target,
+	}
+
+	return nil
+}
+
+// RunTicks runs the strategy event by event over recorded ticks for one or more
+// symbols. Each tick's bid/ask and sizes are treated as the top of the book:
+// orders fill at the touch, limited by the size available, and leg group
+// stop-losses and targets are checked on every tick so exits happen at the
+// first price that breaches them rather than at a bar's close.
+func (b *StrategyBacktester) RunTicks(ticks []models.MarketDataSnapshot) (*models.StrategyBacktestReport, error) {
+	if len(ticks) == 0 {
+		return nil, errors.New("no market data to backtest")
+	}
+
+	sorted := append([]models.MarketDataSnapshot(nil), ticks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	b.now = sorted[0].Timestamp
+	if err := b.strategy.OnInit(b); err != nil {
+		return nil, fmt.Errorf("strategy initialization failed: %w", err)
+	}
+
+	b.startRecording()
+
+	for i, tick := range sorted {
+		if err := b.ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		b.now = tick.Timestamp
+		b.quotes[tick.Symbol] = tick
+		b.lastPrices[tick.Symbol] = lastTradedPrice(tick)
+
+		if err := b.enforceIntradayMargin(tick.Symbol, lastTradedPrice(tick)); err != nil {
+			return nil, err
+		}
+
+		if err := b.matchTick(tick); err != nil {
+			return nil, err
+		}
+
+		if err := b.checkLegGroups(tick.Symbol); err != nil {
+			return nil, err
+		}
+
+		if err := b.strategy.OnTick(b, tick); err != nil {
+			return nil, fmt.Errorf("strategy failed on tick at %s: %w", tick.Timestamp, err)
+		}
+
+		// Record one equity point per interval rather than per tick
+		bucket := tick.Timestamp.Truncate(tickResultInterval)
+		if i+1 < len(sorted) && sorted[i+1].Timestamp.Truncate(tickResultInterval).Equal(bucket) {
+			continue
+		}
+
+		b.recordResult(tick.Timestamp)
+	}
+
+	b.finishReport()
+	return &b.report, nil
+}
+
+// matchTick fills pending orders for the tick's symbol against its top of book.
+// Depth is consumed as orders fill, so later orders on the same tick may only
+// partially fill and carry the rest over to the next tick.
+func (b *StrategyBacktester) matchTick(tick models.MarketDataSnapshot) error {
+	var remaining []*models.SimulationOrder
+	var filled []*models.SimulationOrder
+
+	// A zero size means the feed has no depth, in which case fills are unlimited
+	askSize, bidSize := tick.AskSize, tick.BidSize
+	askLimited, bidLimited := askSize > 0, bidSize > 0
+
+	for _, order := range b.pending {
+		if order.Symbol != tick.Symbol || !b.arrived(order) {
+			remaining = append(remaining, order)
+			continue
+		}
+
+		isBuy := order.Direction == models.OrderDirectionBuy
+
+		price, ok := b.tickFillPrice(order, tick)
+		if !ok || (isBuy && askLimited && askSize == 0) || (!isBuy && bidLimited && bidSize == 0) {
+			remaining = append(remaining, order)
+			continue
+		}
+
+		quantity := order.Quantity - order.FilledQuantity
+		if isBuy && askLimited {
+			if askSize < quantity {
+				quantity = askSize
+			}
+			askSize -= quantity
+		} else if !isBuy && bidLimited {
+			if bidSize < quantity {
+				quantity = bidSize
+			}
+			bidSize -= quantity
+		}
+
+		b.fill(order, price, quantity)
+		filled = append(filled, order)
+
+		if order.Status == models.OrderStatusPartial {
+			remaining = append(remaining, order)
+		} else {
+			delete(b.triggered, order.ID)
+		}
+	}
+	b.pending = remaining
+
+	for _, order := range filled {
+		if err := b.strategy.OnFill(b, *order); err != nil {
+			return fmt.Errorf("strategy failed on fill of order %s: %w", order.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// tickFillPrice returns the price at which an order fills on a tick, if it fills at all.
+// Buys take the ask and sells the bid; stop orders trigger on the last traded price
+// and stay triggered on later ticks.
+func (b *StrategyBacktester) tickFillPrice(order *models.SimulationOrder, tick models.MarketDataSnapshot) (float64, bool) {
+	isBuy := order.Direction == models.OrderDirectionBuy
+	touch := touchPrice(tick, isBuy)
+
+	switch order.OrderType {
+	case models.OrderTypeMarket:
+		return touch, true
+	case models.OrderTypeSLLimit:
+		if !b.triggered[order.ID] {
+			last := lastTradedPrice(tick)
+			if (isBuy && last < order.TriggerPrice) || (!isBuy && last > order.TriggerPrice) {
+				return 0, false
+			}
+			b.triggered[order.ID] = true
+		}
+		fallthrough
+	case models.OrderTypeLimit:
+		if (isBuy && touch <= order.Price) || (!isBuy && touch >= order.Price) {
+			return touch, true
+		}
+	}
+
+	return 0, false
+}
+
+// checkLegGroups squares off every leg group containing symbol whose combined
+// unrealized P&L has hit its stop-loss or target. Legs are exited immediately at
+// their current touch price and any pending orders on them are cancelled.
+func (b *StrategyBacktester) checkLegGroups(symbol string) error {
+	names := make([]string, 0, len(b.legGroups))
+	for name, group := range b.legGroups {
+		for _, leg := range group.symbols {
+			if leg == symbol {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var exits []*models.SimulationOrder
+	for _, name := range names {
+		group := b.legGroups[name]
+
+		pnl := 0.0
+		for _, leg := range group.symbols {
+			if position, exists := b.positions[leg]; exists && position.quantity != 0 {
+				pnl += float64(position.quantity) * (b.exitPrice(leg, position.quantity) - position.averagePrice)
+			}
+		}
+
+		hitStop := group.stopLoss > 0 && pnl <= -group.stopLoss
+		hitTarget := group.target > 0 && pnl >= group.target
+		if !hitStop && !hitTarget {
+			continue
+		}
+
+		delete(b.legGroups, name)
+
+		for _, leg := range group.symbols {
+			b.cancelPendingOrders(leg)
+
+			if order := b.exitPosition(leg, group.strategyID, legGroupExitTag, name); order != nil {
+				exits = append(exits, order)
+			}
+		}
+	}
+
+	return b.notifyFills(exits)
+}
+
+// exitPosition closes the position in symbol immediately at its exit price with a
+// market order attributed to strategyID, returning nil when there is no position
+func (b *StrategyBacktester) exitPosition(symbol, strategyID string, tags ...string) *models.SimulationOrder {
+	position, exists := b.positions[symbol]
+	if !exists || position.quantity == 0 {
+		return nil
+	}
+
+	direction := models.OrderDirectionSell
+	if position.quantity < 0 {
+		direction = models.OrderDirectionBuy
+	}
+
+	b.nextID++
+	backtestDate := b.now
+	order := &models.SimulationOrder{
+		Order: models.Order{
+			ID:          fmt.Sprintf("%s-%d", b.sessionID, b.nextID),
+			Symbol:      symbol,
+			OrderType:   models.OrderTypeMarket,
+			Direction:   direction,
+			Quantity:    abs(position.quantity),
+			ProductType: position.productType,
+			Status:      models.OrderStatusPending,
+			StrategyID:  strategyID,
+			CreatedAt:   b.now,
+			UpdatedAt:   b.now,
+			Tags:        tags,
+		},
+		IsBacktestOrder: true,
+		BacktestDate:    &backtestDate,
+	}
+
+	b.fill(order, b.exitPrice(symbol, position.quantity), order.Quantity)
+	return order
+}
+
+// notifyFills tells the strategy about orders filled outside normal matching
+func (b *StrategyBacktester) notifyFills(orders []*models.SimulationOrder) error {
+	for _, order := range orders {
+		if err := b.strategy.OnFill(b, *order); err != nil {
+			return fmt.Errorf("strategy failed on fill of order %s: %w", order.ID, err)
+		}
+	}
+	return nil
+}
+
+// cancelPendingOrders cancels all pending orders for a symbol
+func (b *StrategyBacktester) cancelPendingOrders(symbol string) {
+	var remaining []*models.SimulationOrder
+	for _, order := range b.pending {
+		if order.Symbol == symbol {
+			delete(b.triggered, order.ID)
+			continue
+		}
+		remaining = append(remaining, order)
+	}
+	b.pending = remaining
+}
+
+// exitPrice returns the price at which a position could be closed right now:
+// the bid for longs and the ask for shorts
+func (b *StrategyBacktester) exitPrice(symbol string, quantity int) float64 {
+	if quote, exists := b.quotes[symbol]; exists {
+		return touchPrice(quote, quantity < 0)
+	}
+	return b.lastPrices[symbol]
+}
+
+// touchPrice returns the ask for buys and the bid for sells, falling back to
+// the last traded price when the tick has no quote
+func touchPrice(tick models.MarketDataSnapshot, isBuy bool) float64 {
+	if isBuy && tick.Ask > 0 {
+		return tick.Ask
+	}
+	if !isBuy && tick.Bid > 0 {
+		return tick.Bid
+	}
+	return lastTradedPrice(tick)
+}
+
+// lastTradedPrice returns the tick's last traded price, or the mid when it only carries a quote
+func lastTradedPrice(tick models.MarketDataSnapshot) float64 {
+	if tick.Close > 0 {
+		return tick.Close
+	}
+	if tick.Bid > 0 && tick.Ask > 0 {
+		return (tick.Bid + tick.Ask) / 2
+	}
+	return tick.Bid + tick.Ask
+}
@@ -0,0 +1,401 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"trading_platform/backend/internal/errorreporting"
+	"trading_platform/backend/internal/messagequeue"
+	"trading_platform/backend/internal/metrics"
+	"trading_platform/backend/internal/models"
+)
+
+// BacktestJobQueue distributes backtest jobs to workers and collects their results.
+// Implementations may be in-process or backed by a shared broker so that workers
+// can run on other machines.
+type BacktestJobQueue interface {
+	// Enqueue adds a job to the queue
+	Enqueue(ctx context.Context, job models.BacktestJob) error
+
+	// Dequeue blocks until a job is available or the context is cancelled
+	Dequeue(ctx context.Context) (*models.BacktestJob, error)
+
+	// PublishResult reports the result of a job for its batch
+	PublishResult(ctx context.Context, result models.BacktestJobResult) error
+
+	// ConsumeResult blocks until a result for the batch is available or the context is cancelled
+	ConsumeResult(ctx context.Context, batchID string) (*models.BacktestJobResult, error)
+}
+
+// InMemoryBacktestJobQueue is a BacktestJobQueue for workers running in the same process
+type InMemoryBacktestJobQueue struct {
+	jobs    chan models.BacktestJob
+	results map[string]chan models.BacktestJobResult
+	mutex   sync.Mutex
+}
+
+// NewInMemoryBacktestJobQueue creates a new in-process job queue with the given capacity
+func NewInMemoryBacktestJobQueue(capacity int) *InMemoryBacktestJobQueue {
+	return &InMemoryBacktestJobQueue{
+		jobs:    make(chan models.BacktestJob, capacity),
+		results: make(map[string]chan models.BacktestJobResult),
+	}
+}
+
+// resultChannel returns the result channel for a batch, creating it if needed
+func (q *InMemoryBacktestJobQueue) resultChannel(batchID string) chan models.BacktestJobResult {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	channel, exists := q.results[batchID]
+	if !exists {
+		channel = make(chan models.BacktestJobResult, cap(q.jobs))
+		q.results[batchID] = channel
+	}
+	return channel
+}
+
+// Enqueue implements BacktestJobQueue
+func (q *InMemoryBacktestJobQueue) Enqueue(ctx context.Context, job models.BacktestJob) error {
+	select {
+	case q.jobs <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Dequeue implements BacktestJobQueue
+func (q *InMemoryBacktestJobQueue) Dequeue(ctx context.Context) (*models.BacktestJob, error) {
+	select {
+	case job := <-q.jobs:
+		return &job, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// PublishResult implements BacktestJobQueue
+func (q *InMemoryBacktestJobQueue) PublishResult(ctx context.Context, result models.BacktestJobResult) error {
+	select {
+	case q.resultChannel(result.BatchID) <- result:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ConsumeResult implements BacktestJobQueue
+func (q *InMemoryBacktestJobQueue) ConsumeResult(ctx context.Context, batchID string) (*models.BacktestJobResult, error) {
+	select {
+	case result := <-q.resultChannel(batchID):
+		return &result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// RedisBacktestJobQueue is a BacktestJobQueue backed by Redis lists, allowing
+// workers on multiple machines to share the same queue
+type RedisBacktestJobQueue struct {
+	redis        *messagequeue.RedisClient
+	jobsKey      string
+	pollInterval time.Duration
+}
+
+// NewRedisBacktestJobQueue creates a new Redis-backed job queue
+func NewRedisBacktestJobQueue(redis *messagequeue.RedisClient) *RedisBacktestJobQueue {
+	return &RedisBacktestJobQueue{
+		redis:        redis,
+		jobsKey:      "backtest:jobs",
+		pollInterval: time.Second,
+	}
+}
+
+// resultsKey returns the Redis list holding results for a batch
+func (q *RedisBacktestJobQueue) resultsKey(batchID string) string {
+	return "backtest:results:" + batchID
+}
+
+// Enqueue implements BacktestJobQueue
+func (q *RedisBacktestJobQueue) Enqueue(ctx context.Context, job models.BacktestJob) error {
+	return q.redis.LPush(ctx, q.jobsKey, job)
+}
+
+// Dequeue implements BacktestJobQueue
+func (q *RedisBacktestJobQueue) Dequeue(ctx context.Context) (*models.BacktestJob, error) {
+	for {
+		var job models.BacktestJob
+		err := q.redis.BRPop(ctx, q.pollInterval, q.jobsKey, &job)
+		if err == nil {
+			return &job, nil
+		}
+
+		// BRPop returns an error on timeout, so keep polling until the context ends
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// PublishResult implements BacktestJobQueue
+func (q *RedisBacktestJobQueue) PublishResult(ctx context.Context, result models.BacktestJobResult) error {
+	return q.redis.LPush(ctx, q.resultsKey(result.BatchID), result)
+}
+
+// ConsumeResult implements BacktestJobQueue
+func (q *RedisBacktestJobQueue) ConsumeResult(ctx context.Context, batchID string) (*models.BacktestJobResult, error) {
+	for {
+		var result models.BacktestJobResult
+		err := q.redis.BRPop(ctx, q.pollInterval, q.resultsKey(batchID), &result)
+		if err == nil {
+			return &result, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// BacktestWorkerPool runs backtest jobs from a shared queue on a set of workers.
+// Pools on several machines can consume the same Redis-backed queue.
+type BacktestWorkerPool struct {
+	queue           BacktestJobQueue
+	backtestService *BacktestService
+	workers         int
+	poolID          string
+
+	cancel    context.CancelFunc
+	waitGroup sync.WaitGroup
+	mutex     sync.Mutex
+}
+
+// NewBacktestWorkerPool creates a new worker pool consuming jobs from the queue
+func NewBacktestWorkerPool(queue BacktestJobQueue, backtestService *BacktestService, workers int) *BacktestWorkerPool {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &BacktestWorkerPool{
+		queue:           queue,
+		backtestService: backtestService,
+		workers:         workers,
+		poolID:          uuid.New().String(),
+	}
+}
+
+// Start starts the workers. They run until Stop is called or the context is cancelled.
+func (p *BacktestWorkerPool) Start(ctx context.Context) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.cancel != nil {
+		return errors.New("worker pool is already running")
+	}
+
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	for i := 0; i < p.workers; i++ {
+		workerID := fmt.Sprintf("%s-%d", p.poolID, i)
+		p.waitGroup.Add(1)
+		go func() {
+			defer p.waitGroup.Done()
+			p.runWorker(ctx, workerID)
+		}()
+	}
+
+	return nil
+}
+
+// Stop stops the workers and waits for in-flight jobs to finish
+func (p *BacktestWorkerPool) Stop() {
+	p.mutex.Lock()
+	cancel := p.cancel
+	p.cancel = nil
+	p.mutex.Unlock()
+
+	if cancel != nil {
+		cancel()
+		p.waitGroup.Wait()
+	}
+}
+
+// runWorker processes jobs until the context is cancelled
+func (p *BacktestWorkerPool) runWorker(ctx context.Context, workerID string) {
+	for {
+		job, err := p.queue.Dequeue(ctx)
+		if err != nil {
+			return
+		}
+
+		result := models.BacktestJobResult{
+			JobID:      job.ID,
+			BatchID:    job.BatchID,
+			WorkerID:   workerID,
+			Symbols:    job.Symbols,
+			Parameters: job.Parameters,
+		}
+
+		results, err := p.evaluate(ctx, job)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Metrics = results
+		result.CompletedAt = time.Now()
+
+		if err := p.queue.PublishResult(ctx, result); err != nil {
+			return
+		}
+	}
+}
+
+// evaluate runs the backtest of a job, counted as running until it returns. A
+// panic fails the job, reported, rather than the worker, whose batch would
+// otherwise wait on the job's result forever.
+func (p *BacktestWorkerPool) evaluate(ctx context.Context, job *models.BacktestJob) (results map[string]float64, err error) {
+	defer errorreporting.Recover(ctx, "backtest", &err)
+	metrics.BacktestStarted()
+	defer metrics.BacktestFinished()
+
+	// In a real implementation, the evaluator would run the backtest on the
+	// job's symbols only
+	return p.backtestService.evaluate(job.StrategyID, job.Parameters, job.StartDate, job.EndDate)
+}
+
+// ShardBacktestJobs creates one job per parameter combination and symbol shard
+func ShardBacktestJobs(batchID string, strategyID string, symbols []string, combinations []map[string]interface{}, startDate, endDate time.Time, symbolsPerShard int) []models.BacktestJob {
+	if symbolsPerShard <= 0 || symbolsPerShard > len(symbols) {
+		symbolsPerShard = len(symbols)
+	}
+
+	var shards [][]string
+	for start := 0; start < len(symbols); start += symbolsPerShard {
+		end := start + symbolsPerShard
+		if end > len(symbols) {
+			end = len(symbols)
+		}
+		shards = append(shards, symbols[start:end])
+	}
+
+	jobs := make([]models.BacktestJob, 0, len(combinations)*len(shards))
+	for _, parameters := range combinations {
+		for _, shard := range shards {
+			jobs = append(jobs, models.BacktestJob{
+				ID:         uuid.New().String(),
+				BatchID:    batchID,
+				StrategyID: strategyID,
+				Symbols:    shard,
+				Parameters: parameters,
+				StartDate:  startDate,
+				EndDate:    endDate,
+				CreatedAt:  time.Now(),
+			})
+		}
+	}
+
+	return jobs
+}
+
+// RunDistributedOptimization shards a grid search across the workers consuming the
+// queue and aggregates the per-shard metrics, weighted by shard size, into one
+// result per parameter set
+func (s *BacktestService) RunDistributedOptimization(ctx context.Context, queue BacktestJobQueue, strategyID string, symbols []string, parameterRanges map[string]map[string]interface{}, optimizationMetric string, startDate, endDate time.Time, symbolsPerShard int) (*models.OptimizationResult, error) {
+	if strategyID == "" {
+		return nil, errors.New("strategy ID is required")
+	}
+
+	if len(symbols) == 0 {
+		return nil, errors.New("at least one symbol is required")
+	}
+
+	if optimizationMetric == "" {
+		return nil, errors.New("optimization metric is required")
+	}
+
+	combinations, err := GenerateParameterCombinations(parameterRanges)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	batchID := uuid.New().String()
+	jobs := ShardBacktestJobs(batchID, strategyID, symbols, combinations, startDate, endDate, symbolsPerShard)
+
+	// Enqueue in the background so a bounded queue can't deadlock with result collection
+	enqueueErr := make(chan error, 1)
+	go func() {
+		for _, job := range jobs {
+			if err := queue.Enqueue(ctx, job); err != nil {
+				enqueueErr <- err
+				return
+			}
+		}
+		enqueueErr <- nil
+	}()
+
+	type aggregate struct {
+		parameters map[string]interface{}
+		weighted   float64
+		symbols    int
+	}
+	aggregates := make(map[string]*aggregate)
+
+	for received := 0; received < len(jobs); received++ {
+		result, err := queue.ConsumeResult(ctx, batchID)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Error != "" {
+			return nil, errors.New("backtest job failed: " + result.Error)
+		}
+
+		value, exists := result.Metrics[optimizationMetric]
+		if !exists {
+			return nil, errors.New("unknown optimization metric: " + optimizationMetric)
+		}
+
+		key := fmt.Sprint(result.Parameters)
+		if aggregates[key] == nil {
+			aggregates[key] = &aggregate{parameters: result.Parameters}
+		}
+		aggregates[key].weighted += value * float64(len(result.Symbols))
+		aggregates[key].symbols += len(result.Symbols)
+	}
+
+	if err := <-enqueueErr; err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(aggregates))
+	for key := range aggregates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := &models.OptimizationResult{
+		StrategyID:         strategyID,
+		Method:             models.OptimizationMethodGrid,
+		OptimizationMetric: optimizationMetric,
+	}
+
+	for _, key := range keys {
+		value := aggregates[key].weighted / float64(aggregates[key].symbols)
+		result.Trials = append(result.Trials, models.OptimizationTrial{Parameters: aggregates[key].parameters, MetricValue: value})
+
+		if result.OptimalParameters == nil || isBetterMetric(optimizationMetric, value, result.MetricValue) {
+			result.OptimalParameters = aggregates[key].parameters
+			result.MetricValue = value
+		}
+	}
+
+	result.TimeElapsed = time.Since(startTime)
+
+	return result, nil
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+
+	"trading_platform/backend/internal/models"
+)
+
+// defaultDataCacheRows is the number of candles or ticks the backtest data cache keeps
+const defaultDataCacheRows = 2000000
+
+// HistoricalDataLoader loads historical market data for a symbol over [startDate, endDate)
+type HistoricalDataLoader func(symbol string, startDate, endDate time.Time, timeframe string) ([]models.MarketDataSnapshot, error)
+
+// DataCacheStats reports the effectiveness of a HistoricalDataCache
+type DataCacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+	Rows      int   `json:"rows"`
+}
+
+// candleColumns stores market data column by column, which keeps large series
+// compact and lets sub-ranges be sliced without copying
+type candleColumns struct {
+	timestamps  []time.Time
+	open        []float64
+	high        []float64
+	low         []float64
+	close       []float64
+	volume      []int64
+	bid         []float64
+	ask         []float64
+	bidSize     []int
+	askSize     []int
+	source      string
+	isSimulated bool
+}
+
+// newCandleColumns converts snapshots into columns sorted by timestamp
+func newCandleColumns(snapshots []models.MarketDataSnapshot) *candleColumns {
+	sorted := append([]models.MarketDataSnapshot(nil), snapshots...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	rows := len(sorted)
+	columns := &candleColumns{
+		timestamps: make([]time.Time, rows),
+		open:       make([]float64, rows),
+		high:       make([]float64, rows),
+		low:        make([]float64, rows),
+		close:      make([]float64, rows),
+		volume:     make([]int64, rows),
+		bid:        make([]float64, rows),
+		ask:        make([]float64, rows),
+		bidSize:    make([]int, rows),
+		askSize:    make([]int, rows),
+	}
+
+	for i, snapshot := range sorted {
+		columns.timestamps[i] = snapshot.Timestamp
+		columns.open[i] = snapshot.Open
+		columns.high[i] = snapshot.High
+		columns.low[i] = snapshot.Low
+		columns.close[i] = snapshot.Close
+		columns.volume[i] = snapshot.Volume
+		columns.bid[i] = snapshot.Bid
+		columns.ask[i] = snapshot.Ask
+		columns.bidSize[i] = snapshot.BidSize
+		columns.askSize[i] = snapshot.AskSize
+	}
+
+	if rows > 0 {
+		columns.source = sorted[0].Source
+		columns.isSimulated = sorted[0].IsSimulated
+	}
+
+	return columns
+}
+
+// rows returns the number of rows in the columns
+func (c *candleColumns) rows() int {
+	return len(c.timestamps)
+}
+
+// slice returns the rows with timestamps in [startDate, endDate)
+func (c *candleColumns) slice(startDate, endDate time.Time) *candleColumns {
+	from := sort.Search(len(c.timestamps), func(i int) bool {
+		return !c.timestamps[i].Before(startDate)
+	})
+	to := sort.Search(len(c.timestamps), func(i int) bool {
+		return !c.timestamps[i].Before(endDate)
+	})
+
+	return &candleColumns{
+		timestamps:  c.timestamps[from:to],
+		open:        c.open[from:to],
+		high:        c.high[from:to],
+		low:         c.low[from:to],
+		close:       c.close[from:to],
+		volume:      c.volume[from:to],
+		bid:         c.bid[from:to],
+		ask:         c.ask[from:to],
+		bidSize:     c.bidSize[from:to],
+		askSize:     c.askSize[from:to],
+		source:      c.source,
+		isSimulated: c.isSimulated,
+	}
+}
+
+// snapshots materializes the columns as market data snapshots
+func (c *candleColumns) snapshots(symbol, timeframe string) []models.MarketDataSnapshot {
+	snapshots := make([]models.MarketDataSnapshot, c.rows())
+	for i := range snapshots {
+		snapshots[i] = models.MarketDataSnapshot{
+			Symbol:      symbol,
+			Timestamp:   c.timestamps[i],
+			Open:        c.open[i],
+			High:        c.high[i],
+			Low:         c.low[i],
+			Close:       c.close[i],
+			Volume:      c.volume[i],
+			Bid:         c.bid[i],
+			Ask:         c.ask[i],
+			BidSize:     c.bidSize[i],
+			AskSize:     c.askSize[i],
+			Timeframe:   timeframe,
+			Source:      c.source,
+			IsSimulated: c.isSimulated,
+		}
+	}
+	return snapshots
+}
+
+// dataCacheEntry is a cached series for one symbol, timeframe and date range
+type dataCacheEntry struct {
+	key       string
+	symbol    string
+	timeframe string
+	startDate time.Time
+	endDate   time.Time
+	columns   *candleColumns
+}
+
+// dataCacheLoad is a load in progress that concurrent requests for the same key wait on
+type dataCacheLoad struct {
+	done    chan struct{}
+	columns *candleColumns
+	err     error
+}
+
+// HistoricalDataCache caches historical market data so that the many runs of an
+// optimization or walk-forward analysis load each series only once. Series are
+// stored in columnar form and evicted least recently used first once the cache
+// holds more than its row budget. A request for a range inside a cached range is
+// served by slicing the cached series.
+type HistoricalDataCache struct {
+	loader  HistoricalDataLoader
+	maxRows int
+
+	mutex    sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	inflight map[string]*dataCacheLoad
+	rows     int
+	stats    DataCacheStats
+}
+
+// NewHistoricalDataCache creates a new cache in front of loader holding at most maxRows rows
+func NewHistoricalDataCache(loader HistoricalDataLoader, maxRows int) *HistoricalDataCache {
+	if maxRows <= 0 {
+		maxRows = defaultDataCacheRows
+	}
+
+	return &HistoricalDataCache{
+		loader:   loader,
+		maxRows:  maxRows,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[string]*dataCacheLoad),
+	}
+}
+
+// dataCacheKey identifies a cached series
+func dataCacheKey(symbol string, startDate, endDate time.Time, timeframe string) string {
+	return symbol + "|" + timeframe + "|" + startDate.UTC().Format(time.RFC3339Nano) + "|" + endDate.UTC().Format(time.RFC3339Nano)
+}
+
+// Get returns historical market data for a symbol, loading and caching it on a miss
+func (c *HistoricalDataCache) Get(symbol string, startDate, endDate time.Time, timeframe string) ([]models.MarketDataSnapshot, error) {
+	key := dataCacheKey(symbol, startDate, endDate, timeframe)
+
+	c.mutex.Lock()
+	if columns := c.lookup(key, symbol, startDate, endDate, timeframe); columns != nil {
+		c.stats.Hits++
+		c.mutex.Unlock()
+		return columns.snapshots(symbol, timeframe), nil
+	}
+
+	if load, exists := c.inflight[key]; exists {
+		c.stats.Hits++
+		c.mutex.Unlock()
+
+		<-load.done
+		if load.err != nil {
+			return nil, load.err
+		}
+		return load.columns.snapshots(symbol, timeframe), nil
+	}
+
+	c.stats.Misses++
+	load := &dataCacheLoad{done: make(chan struct{})}
+	c.inflight[key] = load
+	c.mutex.Unlock()
+
+	data, err := c.loader(symbol, startDate, endDate, timeframe)
+	if err == nil {
+		load.columns = newCandleColumns(data)
+	}
+	load.err = err
+
+	c.mutex.Lock()
+	delete(c.inflight, key)
+	if err == nil {
+		c.add(&dataCacheEntry{
+			key:       key,
+			symbol:    symbol,
+			timeframe: timeframe,
+			startDate: startDate,
+			endDate:   endDate,
+			columns:   load.columns,
+		})
+	}
+	c.mutex.Unlock()
+	close(load.done)
+
+	if err != nil {
+		return nil, err
+	}
+	return load.columns.snapshots(symbol, timeframe), nil
+}
+
+// Prefetch loads series in the background so later Get calls are served from the cache
+func (c *HistoricalDataCache) Prefetch(symbols []string, startDate, endDate time.Time, timeframe string) {
+	for _, symbol := range symbols {
+		go c.Get(symbol, startDate, endDate, timeframe)
+	}
+}
+
+// Invalidate removes every cached series for a symbol
+func (c *HistoricalDataCache) Invalidate(symbol string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, element := range c.entries {
+		if element.Value.(*dataCacheEntry).symbol == symbol {
+			c.remove(key, element)
+		}
+	}
+}
+
+// Stats returns the cache statistics
+func (c *HistoricalDataCache) Stats() DataCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.Rows = c.rows
+	return stats
+}
+
+// lookup returns the cached columns for a request, either an exact match or a
+// slice of a cached series covering the range. The caller must hold the mutex.
+func (c *HistoricalDataCache) lookup(key, symbol string, startDate, endDate time.Time, timeframe string) *candleColumns {
+	if element, exists := c.entries[key]; exists {
+		c.lru.MoveToFront(element)
+		return element.Value.(*dataCacheEntry).columns
+	}
+
+	for element := c.lru.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*dataCacheEntry)
+		if entry.symbol != symbol || entry.timeframe != timeframe {
+			continue
+		}
+
+		if !entry.startDate.After(startDate) && !entry.endDate.Before(endDate) {
+			c.lru.MoveToFront(element)
+			return entry.columns.slice(startDate, endDate)
+		}
+	}
+
+	return nil
+}
+
+// add inserts an entry and evicts the least recently used entries over the row
+// budget, always keeping the new entry. The caller must hold the mutex.
+func (c *HistoricalDataCache) add(entry *dataCacheEntry) {
+	if element, exists := c.entries[entry.key]; exists {
+		c.remove(entry.key, element)
+	}
+
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.rows += entry.columns.rows()
+
+	for c.rows > c.maxRows && c.lru.Len() > 1 {
+		oldest := c.lru.Back()
+		c.remove(oldest.Value.(*dataCacheEntry).key, oldest)
+		c.stats.Evictions++
+	}
+}
+
+// remove deletes an entry. The caller must hold the mutex.
+func (c *HistoricalDataCache) remove(key string, element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, key)
+	c.rows -= element.Value.(*dataCacheEntry).columns.rows()
+}
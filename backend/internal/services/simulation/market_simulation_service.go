@@ -90,6 +90,8 @@ func (s *MarketSimulationService) GetHistoricalMarketData(symbol string, startDa
 	// Determine time increment based on timeframe
 	var increment time.Duration
 	switch timeframe {
+	case "tick":
+		increment = 1 * time.Second
 	case "1m":
 		increment = 1 * time.Minute
 	case "5m":
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"trading_platform/backend/internal/models"
+)
+
+// cryptoExchange is the exchange code used for crypto perpetual positions
+const cryptoExchange = "CRYPTO"
+
+// daysPerYear is the day count convention used for annual funding rates
+const daysPerYear = 365.0
+
+// OvernightFundingService applies overnight carry costs (margin interest, futures
+// rollover and crypto funding) to simulation positions held past the trading day
+type OvernightFundingService struct {
+	// Dependencies would be injected here in a real implementation
+	// For example: database connection, etc.
+	simulationAccountService *SimulationAccountService
+	simulationOrderService   *SimulationOrderService
+
+	lastRun  map[string]time.Time // accountID -> last date funding was applied
+	applying map[string]bool      // accountID -> funding being applied
+	mutex    sync.Mutex
+	stopChan chan struct{}
+}
+
+// NewOvernightFundingService creates a new instance of OvernightFundingService
+func NewOvernightFundingService() *OvernightFundingService {
+	return &OvernightFundingService{
+		simulationAccountService: NewSimulationAccountService(),
+		simulationOrderService:   NewSimulationOrderService(),
+		lastRun:                  make(map[string]time.Time),
+		applying:                 make(map[string]bool),
+	}
+}
+
+// CalculateOvernightCharge calculates the carry cost of holding a position for the given
+// number of days. A positive result is a cost to the account, a negative result a credit.
+func CalculateOvernightCharge(position models.SimulationPosition, settings *models.MarketSettings, maxLeverage float64, days int) float64 {
+	if settings == nil || days <= 0 || position.Status == models.PositionStatusClosed {
+		return 0
+	}
+
+	// Intraday positions are squared off before the close and never carry overnight
+	if position.ProductType == models.ProductTypeMIS {
+		return 0
+	}
+
+	price := position.SimulatedMarketPrice
+	if price == 0 {
+		price = position.SimulatedEntryPrice
+	}
+	notional := price * float64(position.Quantity-position.ExitQuantity)
+	if notional <= 0 {
+		return 0
+	}
+
+	// Longs pay funding, shorts receive it
+	sign := 1.0
+	if position.Direction == models.PositionDirectionShort {
+		sign = -1.0
+	}
+
+	if position.Exchange == cryptoExchange {
+		return sign * notional * settings.CryptoFundingRate * float64(days)
+	}
+
+	switch position.InstrumentType {
+	case models.InstrumentTypeFuture:
+		return sign * notional * settings.FuturesRolloverCost / daysPerYear * float64(days)
+	case models.InstrumentTypeOption:
+		// Option premium is paid in full, so there is nothing to fund
+		return 0
+	default:
+		// Only the leveraged portion of a long equity position is borrowed
+		if position.Direction == models.PositionDirectionShort || maxLeverage <= 1 {
+			return 0
+		}
+		borrowed := notional * (1 - 1/maxLeverage)
+		return borrowed * settings.MarginInterestRate / daysPerYear * float64(days)
+	}
+}
+
+// ApplyOvernightFunding charges overnight carry for every open position in an account.
+// Funding is applied at most once per calendar day; weekends and holidays skipped since
+// the last run are charged together on the next run. A run that fails is not recorded,
+// so the day is charged again on the next run.
+func (s *OvernightFundingService) ApplyOvernightFunding(accountID string, asOf time.Time) ([]models.SimulationTransaction, error) {
+	if accountID == "" {
+		return nil, errors.New("account ID is required")
+	}
+
+	account, err := s.simulationAccountService.GetSimulationAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	day := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, asOf.Location())
+
+	s.mutex.Lock()
+	last, exists := s.lastRun[accountID]
+	if exists && !day.After(last) {
+		s.mutex.Unlock()
+		return nil, errors.New("overnight funding already applied for this date")
+	}
+	if s.applying[accountID] {
+		s.mutex.Unlock()
+		return nil, errors.New("overnight funding is already being applied")
+	}
+	s.applying[accountID] = true
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.applying, accountID)
+		s.mutex.Unlock()
+	}()
+
+	days := 1
+	if exists {
+		days = int(day.Sub(last).Hours() / 24)
+	}
+
+	positions, err := s.simulationOrderService.GetPositions(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	maxLeverage := 1.0
+	if account.RiskSettings != nil && account.RiskSettings.MaxLeverage > 0 {
+		maxLeverage = account.RiskSettings.MaxLeverage
+	}
+
+	balance := account.CurrentBalance
+	var transactions []models.SimulationTransaction
+
+	for _, position := range positions {
+		charge := CalculateOvernightCharge(*position, account.MarketSettings, maxLeverage, days)
+		if charge == 0 {
+			continue
+		}
+
+		balance -= charge
+
+		// In a real implementation, we would save the transaction and the
+		// updated account balance to the database here
+		transactions = append(transactions, models.SimulationTransaction{
+			ID:                  uuid.New().String(),
+			SimulationAccountID: accountID,
+			Type:                "INTEREST",
+			Amount:              -charge,
+			Balance:             balance,
+			Description:         "Overnight funding: " + position.Symbol,
+			ReferenceID:         position.ID,
+			ReferenceType:       "POSITION",
+			Timestamp:           asOf,
+		})
+	}
+
+	s.mutex.Lock()
+	s.lastRun[accountID] = day
+	s.mutex.Unlock()
+
+	return transactions, nil
+}
+
+// StartNightlyJob runs ApplyOvernightFunding for the accounts returned by accountIDs
+// every day at the given time of day (in the provided location)
+func (s *OvernightFundingService) StartNightlyJob(hour, minute int, location *time.Location, accountIDs func() []string) error {
+	if accountIDs == nil {
+		return errors.New("account ID source is required")
+	}
+
+	if location == nil {
+		location = time.Local
+	}
+
+	s.mutex.Lock()
+	if s.stopChan != nil {
+		s.mutex.Unlock()
+		return errors.New("nightly funding job is already running")
+	}
+	stopChan := make(chan struct{})
+	s.stopChan = stopChan
+	s.mutex.Unlock()
+
+	go func() {
+		for {
+			now := time.Now().In(location)
+			next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, location)
+			if !next.After(now) {
+				next = next.Add(24 * time.Hour)
+			}
+
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-stopChan:
+				timer.Stop()
+				return
+			case runAt := <-timer.C:
+				for _, accountID := range accountIDs() {
+					// Errors for individual accounts must not stop the job for the others
+					_, _ = s.ApplyOvernightFunding(accountID, runAt)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// StopNightlyJob stops the nightly funding job
+func (s *OvernightFundingService) StopNightlyJob() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+}
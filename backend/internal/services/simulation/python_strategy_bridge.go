@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"trading_platform/backend/internal/models"
+)
+
+// strategyBridgeMethod is the full gRPC method name of StrategyBridge.HandleEvent
+// (see proto/strategy_bridge.proto)
+const strategyBridgeMethod = "/strategybridge.StrategyBridge/HandleEvent"
+
+// pythonStrategyPrefix namespaces sidecar strategies in the strategy registry
+const pythonStrategyPrefix = "python:"
+
+// jsonCodec encodes gRPC messages as JSON so the bridge works without generated code
+type jsonCodec struct{}
+
+// Marshal implements encoding.Codec
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements encoding.Codec
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Name implements encoding.Codec
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// bridgeMarketData mirrors the MarketData message
+type bridgeMarketData struct {
+	Symbol    string  `json:"symbol"`
+	Timestamp string  `json:"timestamp"`
+	Open      float64 `json:"open"`
+	High      float64 `json:"high"`
+	Low       float64 `json:"low"`
+	Close     float64 `json:"close"`
+	Volume    int64   `json:"volume"`
+	Bid       float64 `json:"bid"`
+	Ask       float64 `json:"ask"`
+}
+
+// bridgeFill mirrors the Fill message
+type bridgeFill struct {
+	OrderID    string  `json:"orderId"`
+	Symbol     string  `json:"symbol"`
+	Direction  string  `json:"direction"`
+	Quantity   int     `json:"quantity"`
+	Price      float64 `json:"price"`
+	Commission float64 `json:"commission"`
+}
+
+// bridgeAccountState mirrors the AccountState message
+type bridgeAccountState struct {
+	Cash      float64        `json:"cash"`
+	Equity    float64        `json:"equity"`
+	Positions map[string]int `json:"positions"`
+}
+
+// bridgeEvent mirrors the StrategyEvent message
+type bridgeEvent struct {
+	Strategy       string              `json:"strategy"`
+	Type           string              `json:"type"`
+	Timestamp      string              `json:"timestamp"`
+	ParametersJSON string              `json:"parametersJson,omitempty"`
+	MarketData     *bridgeMarketData   `json:"marketData,omitempty"`
+	Fill           *bridgeFill         `json:"fill,omitempty"`
+	Account        *bridgeAccountState `json:"account"`
+}
+
+// bridgeOrderRequest mirrors the OrderRequest message
+type bridgeOrderRequest struct {
+	Symbol       string  `json:"symbol"`
+	Exchange     string  `json:"exchange"`
+	Direction    string  `json:"direction"`
+	OrderType    string  `json:"orderType"`
+	Quantity     int     `json:"quantity"`
+	Price        float64 `json:"price"`
+	TriggerPrice float64 `json:"triggerPrice"`
+	Tag          string  `json:"tag"`
+}
+
+// bridgeAction mirrors the StrategyAction message
+type bridgeAction struct {
+	Type    string              `json:"type"`
+	Order   *bridgeOrderRequest `json:"order,omitempty"`
+	OrderID string              `json:"orderId,omitempty"`
+}
+
+// bridgeActions mirrors the StrategyActions message
+type bridgeActions struct {
+	Actions []bridgeAction `json:"actions"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// DialStrategySidecar connects to a strategy sidecar listening on address
+func DialStrategySidecar(ctx context.Context, address string) (*grpc.ClientConn, error) {
+	if address == "" {
+		return nil, errors.New("sidecar address is required")
+	}
+
+	conn, err := grpc.DialContext(ctx, address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(jsonCodec{}.Name())),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to strategy sidecar: %w", err)
+	}
+
+	return conn, nil
+}
+
+// RegisterSidecarStrategy registers a strategy hosted by a sidecar under
+// "python:<name>" so backtest sessions can reference it by GoStrategyName
+func RegisterSidecarStrategy(conn *grpc.ClientConn, name string, timeout time.Duration) (string, error) {
+	if conn == nil {
+		return "", errors.New("sidecar connection is required")
+	}
+
+	if name == "" {
+		return "", errors.New("strategy name is required")
+	}
+
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	registeredName := pythonStrategyPrefix + name
+	err := RegisterBacktestStrategy(registeredName, func(parameters map[string]interface{}) (BacktestStrategy, error) {
+		parametersJSON, err := json.Marshal(parameters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode strategy parameters: %w", err)
+		}
+
+		return &SidecarStrategy{
+			conn:           conn,
+			name:           name,
+			timeout:        timeout,
+			parametersJSON: string(parametersJSON),
+		}, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return registeredName, nil
+}
+
+// SidecarStrategy is a BacktestStrategy whose logic runs in a sidecar process.
+// Every event is forwarded over gRPC and the returned actions are applied to the context.
+type SidecarStrategy struct {
+	conn           *grpc.ClientConn
+	name           string
+	timeout        time.Duration
+	parametersJSON string
+}
+
+// OnInit implements BacktestStrategy
+func (s *SidecarStrategy) OnInit(ctx BacktestStrategyContext) error {
+	return s.send(ctx, bridgeEvent{Type: "INIT", ParametersJSON: s.parametersJSON})
+}
+
+// OnTick implements BacktestStrategy
+func (s *SidecarStrategy) OnTick(ctx BacktestStrategyContext, tick models.MarketDataSnapshot) error {
+	return s.send(ctx, bridgeEvent{Type: "TICK", MarketData: toBridgeMarketData(tick)})
+}
+
+// OnCandle implements BacktestStrategy
+func (s *SidecarStrategy) OnCandle(ctx BacktestStrategyContext, candle models.MarketDataSnapshot) error {
+	return s.send(ctx, bridgeEvent{Type: "CANDLE", MarketData: toBridgeMarketData(candle)})
+}
+
+// OnFill implements BacktestStrategy
+func (s *SidecarStrategy) OnFill(ctx BacktestStrategyContext, fill models.SimulationOrder) error {
+	return s.send(ctx, bridgeEvent{
+		Type: "FILL",
+		Fill: &bridgeFill{
+			OrderID:    fill.ID,
+			Symbol:     fill.Symbol,
+			Direction:  string(fill.Direction),
+			Quantity:   fill.FilledQuantity,
+			Price:      fill.AveragePrice,
+			Commission: fill.CommissionAmount,
+		},
+	})
+}
+
+// send delivers an event to the sidecar and applies the returned actions
+func (s *SidecarStrategy) send(ctx BacktestStrategyContext, event bridgeEvent) error {
+	event.Strategy = s.name
+	event.Timestamp = ctx.Now().Format(time.RFC3339Nano)
+	event.Account = &bridgeAccountState{
+		Cash:      ctx.Cash(),
+		Equity:    ctx.Equity(),
+		Positions: make(map[string]int),
+	}
+
+	symbol := ""
+	if event.MarketData != nil {
+		symbol = event.MarketData.Symbol
+	} else if event.Fill != nil {
+		symbol = event.Fill.Symbol
+	}
+	if symbol != "" {
+		event.Account.Positions[symbol] = ctx.Position(symbol)
+	}
+
+	callCtx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	var response bridgeActions
+	if err := s.conn.Invoke(callCtx, strategyBridgeMethod, &event, &response, grpc.CallContentSubtype(jsonCodec{}.Name())); err != nil {
+		return fmt.Errorf("strategy sidecar call failed: %w", err)
+	}
+
+	if response.Error != "" {
+		return errors.New("strategy sidecar error: " + response.Error)
+	}
+
+	for _, action := range response.Actions {
+		switch action.Type {
+		case "SUBMIT":
+			if action.Order == nil {
+				return errors.New("strategy sidecar sent a SUBMIT action without an order")
+			}
+			order := models.Order{
+				Symbol:       action.Order.Symbol,
+				Exchange:     action.Order.Exchange,
+				Direction:    models.OrderDirection(action.Order.Direction),
+				OrderType:    models.OrderType(action.Order.OrderType),
+				Quantity:     action.Order.Quantity,
+				Price:        action.Order.Price,
+				TriggerPrice: action.Order.TriggerPrice,
+			}
+			if action.Order.Tag != "" {
+				order.Tags = []string{action.Order.Tag}
+			}
+			if _, err := ctx.SubmitOrder(order); err != nil {
+				return fmt.Errorf("strategy sidecar order rejected: %w", err)
+			}
+		case "CANCEL":
+			if err := ctx.CancelOrder(action.OrderID); err != nil {
+				return fmt.Errorf("strategy sidecar cancel failed: %w", err)
+			}
+		default:
+			return errors.New("strategy sidecar sent an unknown action: " + action.Type)
+		}
+	}
+
+	return nil
+}
+
+// toBridgeMarketData converts a snapshot into its bridge representation
+func toBridgeMarketData(snapshot models.MarketDataSnapshot) *bridgeMarketData {
+	return &bridgeMarketData{
+		Symbol:    snapshot.Symbol,
+		Timestamp: snapshot.Timestamp.Format(time.RFC3339Nano),
+		Open:      snapshot.Open,
+		High:      snapshot.High,
+		Low:       snapshot.Low,
+		Close:     snapshot.Close,
+		Volume:    snapshot.Volume,
+		Bid:       snapshot.Bid,
+		Ask:       snapshot.Ask,
+	}
+}
@@ -81,6 +81,9 @@ func (s *SimulationAccountService) CreateSimulationAccount(userID string, accoun
 			SpreadValue:         0.0,
 			AllowShortSelling:   true,
 			AllowFractionalLots: true,
+			MarginInterestRate:  0.12,   // 12% p.a. on borrowed funds
+			FuturesRolloverCost: 0.07,   // 7% p.a. cost of carry
+			CryptoFundingRate:   0.0003, // 0.03% per day
 		}
 	}
 	
@@ -211,6 +214,14 @@ func (s *SimulationAccountService) GetSimulationAccountsByUser(userID string) ([
 	}, nil
 }
 
+// GetActiveSimulationAccountIDs retrieves the IDs of all active simulation accounts
+func (s *SimulationAccountService) GetActiveSimulationAccountIDs() ([]string, error) {
+	// In a real implementation, we would retrieve the active accounts from the database
+	
+	// For now, return the mock accounts
+	return []string{"sim1", "sim2"}, nil
+}
+
 // AddFunds adds funds to a simulation account
 func (s *SimulationAccountService) AddFunds(accountID string, amount float64, description string) (*models.SimulationTransaction, error) {
 	if accountID == "" {
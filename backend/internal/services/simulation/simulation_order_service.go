@@ -456,3 +456,89 @@ func (s *SimulationOrderService) GetOrderStatistics(accountID string, startDate,
 		"smallestOrderSize":  10,
 	}, nil
 }
+
+// GetPositions retrieves all open simulation positions for an account
+func (s *SimulationOrderService) GetPositions(accountID string) ([]*models.SimulationPosition, error) {
+	if accountID == "" {
+		return nil, errors.New("account ID is required")
+	}
+
+	// In a real implementation, we would retrieve the open positions from the database
+
+	// For now, return mock positions matching the filled mock orders
+	return []*models.SimulationPosition{
+		{
+			Position: models.Position{
+				ID:         "position1",
+				UserID:     "user123",
+				OrderID:    "order1",
+				Symbol:     "AAPL",
+				Direction:  models.PositionDirectionLong,
+				EntryPrice: 150.25,
+				Quantity:   100,
+				Status:     models.PositionStatusOpen,
+				CreatedAt:  time.Now().Add(-30 * time.Minute),
+				UpdatedAt:  time.Now(),
+			},
+			SimulationAccountID:  accountID,
+			SimulatedEntryPrice:  150.25,
+			SimulatedMarketPrice: 151.10,
+			TotalCommission:      15.03,
+			TotalSlippage:        0.15,
+			IsBacktestPosition:   false,
+			BacktestDate:         nil,
+		},
+	}, nil
+}
+
+// ReplacePositions replaces the open simulation positions of an account with the given ones,
+// as restoring a snapshot does
+func (s *SimulationOrderService) ReplacePositions(accountID string, positions []models.SimulationPosition) ([]*models.SimulationPosition, error) {
+	if accountID == "" {
+		return nil, errors.New("account ID is required")
+	}
+
+	// In a real implementation, we would, within the caller's transaction:
+	// 1. Delete the account's open positions
+	// 2. Insert the given positions
+
+	// For now, return the positions as they would be stored
+	restored := make([]*models.SimulationPosition, 0, len(positions))
+	for i := range positions {
+		// A copy of each, pointers to the loop variable all being to the last one
+		position := positions[i]
+		if position.Symbol == "" || position.Quantity <= 0 {
+			return nil, errors.New("positions must have a symbol and a quantity")
+		}
+		position.SimulationAccountID = accountID
+		position.Status = models.PositionStatusOpen
+		position.UpdatedAt = time.Now()
+		restored = append(restored, &position)
+	}
+
+	return restored, nil
+}
+
+// RestoreOrders re-opens simulation orders of an account as they were, keeping their IDs,
+// rather than placing them afresh, so they are not filled again or re-priced
+func (s *SimulationOrderService) RestoreOrders(accountID string, orders []models.SimulationOrder) ([]models.SimulationOrder, error) {
+	if accountID == "" {
+		return nil, errors.New("account ID is required")
+	}
+
+	// In a real implementation, we would insert the orders into the database and hand them
+	// to the process that checks pending orders against market prices
+
+	// For now, return the orders as they would be stored
+	restored := make([]models.SimulationOrder, 0, len(orders))
+	for _, order := range orders {
+		if order.Status != models.OrderStatusPending && order.Status != models.OrderStatusPartial {
+			return nil, errors.New("only pending and partially filled orders can be restored")
+		}
+		order.SimulationAccountID = accountID
+		order.UpdatedAt = time.Now()
+		restored = append(restored, order)
+	}
+
+	return restored, nil
+}
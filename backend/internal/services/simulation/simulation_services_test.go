@@ -1,6 +1,11 @@
 package services_test
 
 import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 	"github.com/stretchr/testify/assert"
@@ -588,6 +593,7 @@ func TestBacktestService(t *testing.T) {
 		assert.NotNil(t, metrics["sharpeRatio"])
 		assert.NotNil(t, metrics["maxDrawdown"])
 		assert.NotNil(t, metrics["winRate"])
+		assert.NotNil(t, metrics["monteCarlo"])
 		
 		_, err = service.GetBacktestPerformanceMetrics("")
 		assert.Error(t, err)
@@ -640,4 +646,1432 @@ func TestBacktestService(t *testing.T) {
 		_, err = service.OptimizeStrategy("strategy1", parameterRanges, "")
 		assert.Error(t, err)
 	})
+	
+	t.Run("RunWalkForwardAnalysis", func(t *testing.T) {
+		parameterRanges := map[string]map[string]interface{}{
+			"shortMA": {"min": 5, "max": 15, "step": 5},
+			"longMA":  {"values": []int{50, 100}},
+		}
+		config := models.WalkForwardConfig{TrainingDays: 60, TestDays: 20}
+		startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		endDate := startDate.Add(180 * 24 * time.Hour)
+		
+		result, err := service.RunWalkForwardAnalysis("strategy1", startDate, endDate, parameterRanges, "sharpeRatio", config)
+		assert.NoError(t, err)
+		assert.NotNil(t, result)
+		assert.Equal(t, 6, len(result.Windows))
+		for i, window := range result.Windows {
+			assert.Equal(t, window.TrainingEnd, window.TestStart)
+			assert.False(t, window.TestEnd.After(endDate))
+			assert.NotNil(t, window.OptimalParameters)
+			if i > 0 {
+				assert.Equal(t, result.Windows[i-1].TestStart.Add(20*24*time.Hour), window.TestStart)
+			}
+		}
+		assert.Contains(t, result.AggregateOutOfSample, "sharpeRatio")
+		assert.Contains(t, result.AggregateOutOfSample, "positiveWindowRatio")
+		
+		// Date range shorter than a single window
+		_, err = service.RunWalkForwardAnalysis("strategy1", startDate, startDate.Add(30*24*time.Hour), parameterRanges, "sharpeRatio", config)
+		assert.Error(t, err)
+		
+		_, err = service.RunWalkForwardAnalysis("strategy1", startDate, endDate, parameterRanges, "sharpeRatio", models.WalkForwardConfig{})
+		assert.Error(t, err)
+	})
+	
+	t.Run("RunMonteCarloAnalysis", func(t *testing.T) {
+		equityCurve := []float64{100000.0}
+		for i := 0; i < 250; i++ {
+			dailyReturn := 0.001 + 0.01*float64(i%7-3)/3.0
+			equityCurve = append(equityCurve, equityCurve[len(equityCurve)-1]*(1+dailyReturn))
+		}
+		
+		result, err := simulation.RunMonteCarloAnalysis(equityCurve, 500, 252, 0.5, 42)
+		assert.NoError(t, err)
+		assert.Equal(t, 500, result.Simulations)
+		assert.LessOrEqual(t, result.MaxDrawdown.P5, result.MaxDrawdown.Median)
+		assert.LessOrEqual(t, result.MaxDrawdown.Median, result.MaxDrawdown.P95)
+		assert.LessOrEqual(t, result.CAGR.P5, result.CAGR.P95)
+		assert.GreaterOrEqual(t, result.RiskOfRuin, 0.0)
+		assert.LessOrEqual(t, result.RiskOfRuin, 1.0)
+		
+		// Identical seeds give identical results
+		again, err := simulation.RunMonteCarloAnalysis(equityCurve, 500, 252, 0.5, 42)
+		assert.NoError(t, err)
+		assert.Equal(t, result, again)
+		
+		_, err = simulation.RunMonteCarloAnalysis([]float64{100000.0}, 500, 252, 0.5, 42)
+		assert.Error(t, err)
+		
+		_, err = simulation.RunMonteCarloAnalysis(equityCurve, 500, 252, 1.5, 42)
+		assert.Error(t, err)
+	})
+	
+	t.Run("RunParameterOptimization", func(t *testing.T) {
+		parameterRanges := map[string]map[string]interface{}{
+			"period":     {"min": 5, "max": 50, "step": 5},
+			"multiplier": {"min": 0.5, "max": 3.0, "step": 0.5},
+		}
+		
+		// Use a smooth objective peaking at period=25, multiplier=1.5 so every method can find it
+		service.SetParameterEvaluator(func(strategyID string, parameters map[string]interface{}, startDate, endDate time.Time) (map[string]float64, error) {
+			period := float64(parameters["period"].(int))
+			multiplier := parameters["multiplier"].(float64)
+			sharpe := 2.0 - (period-25)*(period-25)/400 - (multiplier-1.5)*(multiplier-1.5)
+			return map[string]float64{"sharpeRatio": sharpe}, nil
+		})
+		defer service.SetParameterEvaluator(nil)
+		
+		configs := []models.OptimizationConfig{
+			{Method: models.OptimizationMethodGrid},
+			{Method: models.OptimizationMethodGenetic, PopulationSize: 20, Generations: 30, MutationRate: 0.2, Seed: 7},
+			{Method: models.OptimizationMethodBayesian, InitialSamples: 10, Iterations: 40, Seed: 7},
+		}
+		
+		for _, config := range configs {
+			result, err := service.RunParameterOptimization("strategy1", parameterRanges, "sharpeRatio", config)
+			assert.NoError(t, err, config.Method)
+			assert.Equal(t, config.Method, result.Method)
+			assert.InDelta(t, 2.0, result.MetricValue, 0.3, config.Method)
+			assert.NotEmpty(t, result.Trials)
+			assert.Equal(t, 2, len(result.Stability))
+			assert.False(t, result.IsLikelyOverfit, config.Method)
+		}
+		
+		// Early stopping ends the search once the best value stops improving
+		result, err := service.RunParameterOptimization("strategy1", parameterRanges, "sharpeRatio", models.OptimizationConfig{
+			Method: models.OptimizationMethodGenetic, PopulationSize: 20, Generations: 500, MutationRate: 0.2, EarlyStoppingRounds: 3, Seed: 7,
+		})
+		assert.NoError(t, err)
+		assert.True(t, result.StoppedEarly)
+		
+		_, err = service.RunParameterOptimization("strategy1", parameterRanges, "sharpeRatio", models.OptimizationConfig{Method: "ANNEALING"})
+		assert.Error(t, err)
+		
+		_, err = service.RunParameterOptimization("strategy1", parameterRanges, "sharpeRatio", models.OptimizationConfig{Method: models.OptimizationMethodGenetic, PopulationSize: 1, Generations: 10})
+		assert.Error(t, err)
+	})
+	
+	t.Run("RunHoldoutValidation", func(t *testing.T) {
+		startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		session := &models.BacktestSession{
+			StrategyID:     "strategy1",
+			StartDate:      startDate,
+			EndDate:        startDate.AddDate(0, 0, 100),
+			HoldoutPercent: 30,
+		}
+		parameterRanges := map[string]map[string]interface{}{
+			"period": {"min": 10, "max": 30, "step": 10},
+		}
+		
+		// The strategy only works on the first 70 days, so it falls apart on the holdout
+		splitDate := startDate.AddDate(0, 0, 70)
+		service.SetParameterEvaluator(func(strategyID string, parameters map[string]interface{}, from, to time.Time) (map[string]float64, error) {
+			// No evaluation may mix training and holdout data
+			assert.True(t, !to.After(splitDate) || !from.Before(splitDate))
+			sharpe := 2.0 - float64(parameters["period"].(int))/20
+			if !from.Before(splitDate) {
+				sharpe = 0.3
+			}
+			return map[string]float64{"sharpeRatio": sharpe, "maxDrawdown": 5.0 / sharpe}, nil
+		})
+		defer service.SetParameterEvaluator(nil)
+		
+		result, err := service.RunHoldoutValidation(session, parameterRanges, "sharpeRatio", models.OptimizationConfig{Method: models.OptimizationMethodGrid})
+		assert.NoError(t, err)
+		assert.Equal(t, splitDate, result.TrainingEnd)
+		assert.Equal(t, splitDate, result.HoldoutStart)
+		assert.Equal(t, session.EndDate, result.HoldoutEnd)
+		assert.Equal(t, 10, result.Optimization.OptimalParameters["period"])
+		assert.InDelta(t, 1.5, result.InSampleMetrics["sharpeRatio"], 0.0001)
+		assert.InDelta(t, 0.3, result.OutOfSampleMetrics["sharpeRatio"], 0.0001)
+		assert.InDelta(t, 0.8, result.MetricDegradation, 0.0001)
+		assert.InDelta(t, 4.0, result.Degradation["maxDrawdown"], 0.0001)
+		assert.True(t, result.IsLikelyOverfit)
+		
+		session.HoldoutPercent = 0
+		_, err = service.RunHoldoutValidation(session, parameterRanges, "sharpeRatio", models.OptimizationConfig{Method: models.OptimizationMethodGrid})
+		assert.Error(t, err)
+		
+		_, err = simulation.SplitHoldoutPeriod(startDate, startDate.AddDate(0, 0, 100), 100)
+		assert.Error(t, err)
+	})
+	
+	t.Run("GenerateParameterCombinations", func(t *testing.T) {
+		combinations, err := simulation.GenerateParameterCombinations(map[string]map[string]interface{}{
+			"period":    {"min": 10, "max": 20, "step": 5},
+			"threshold": {"values": []string{"low", "high"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 6, len(combinations))
+		assert.Equal(t, 10, combinations[0]["period"])
+		
+		_, err = simulation.GenerateParameterCombinations(map[string]map[string]interface{}{
+			"period": {"min": 10, "max": 20, "step": 0},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestSimulationSnapshotService(t *testing.T) {
+	service := simulation.NewSimulationSnapshotService()
+	
+	t.Run("CreateAndRestoreSnapshot", func(t *testing.T) {
+		snapshot, err := service.CreateSnapshot("sim123", "Before adjustment", "Branch point")
+		assert.NoError(t, err)
+		assert.NotNil(t, snapshot)
+		assert.Equal(t, "sim123", snapshot.SimulationAccountID)
+		assert.Equal(t, "Before adjustment", snapshot.Name)
+		assert.NotEmpty(t, snapshot.Positions)
+		for _, order := range snapshot.OpenOrders {
+			assert.Equal(t, "PENDING", order.Status)
+		}
+		
+		snapshots, err := service.GetSnapshots("sim123")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(snapshots))
+		
+		account, err := service.RestoreSnapshot("sim123", snapshot.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, snapshot.Balance, account.CurrentBalance)
+		
+		_, err = service.RestoreSnapshot("sim123", "missing")
+		assert.Error(t, err)
+		
+		// Snapshot positions and open orders are put back as they were
+		orderService := simulation.NewSimulationOrderService()
+		positions, err := orderService.ReplacePositions("sim123", snapshot.Positions)
+		assert.NoError(t, err)
+		assert.Equal(t, len(snapshot.Positions), len(positions))
+		assert.Equal(t, snapshot.Positions[0].ID, positions[0].ID)
+		
+		// Each position is restored, not the last one over and over
+		held := []models.SimulationPosition{
+			{Position: models.Position{ID: "pos1", Symbol: "AAPL", Quantity: 100}},
+			{Position: models.Position{ID: "pos2", Symbol: "MSFT", Quantity: 50}},
+			{Position: models.Position{ID: "pos3", Symbol: "GOOGL", Quantity: 10}},
+		}
+		positions, err = orderService.ReplacePositions("sim123", held)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(positions))
+		for i, position := range positions {
+			assert.Equal(t, held[i].ID, position.ID)
+			assert.Equal(t, held[i].Symbol, position.Symbol)
+			assert.Equal(t, "sim123", position.SimulationAccountID)
+		}
+		assert.Empty(t, held[0].SimulationAccountID)
+		
+		orders, err := orderService.RestoreOrders("sim123", snapshot.OpenOrders)
+		assert.NoError(t, err)
+		assert.Equal(t, len(snapshot.OpenOrders), len(orders))
+		
+		filled := models.SimulationOrder{Order: models.Order{ID: "order1", Status: "FILLED"}}
+		_, err = orderService.RestoreOrders("sim123", []models.SimulationOrder{filled})
+		assert.Error(t, err)
+		
+		_, err = service.CreateSnapshot("sim123", "", "")
+		assert.Error(t, err)
+	})
+	
+	t.Run("DeleteSnapshot", func(t *testing.T) {
+		snapshot, err := service.CreateSnapshot("sim456", "Temporary", "")
+		assert.NoError(t, err)
+		
+		err = service.DeleteSnapshot("sim456", snapshot.ID)
+		assert.NoError(t, err)
+		
+		snapshots, err := service.GetSnapshots("sim456")
+		assert.NoError(t, err)
+		assert.Empty(t, snapshots)
+		
+		err = service.DeleteSnapshot("sim456", snapshot.ID)
+		assert.Error(t, err)
+	})
+}
+
+func TestOvernightFundingService(t *testing.T) {
+	settings := &models.MarketSettings{
+		MarginInterestRate:  0.365,
+		FuturesRolloverCost: 0.0365,
+		CryptoFundingRate:   0.001,
+	}
+	
+	newPosition := func(instrumentType models.InstrumentType, productType models.ProductType, direction models.PositionDirection, exchange string) models.SimulationPosition {
+		return models.SimulationPosition{
+			Position: models.Position{
+				Symbol:         "TEST",
+				Exchange:       exchange,
+				Direction:      direction,
+				Quantity:       10,
+				Status:         models.PositionStatusOpen,
+				ProductType:    productType,
+				InstrumentType: instrumentType,
+			},
+			SimulatedEntryPrice:  100.0,
+			SimulatedMarketPrice: 100.0,
+		}
+	}
+	
+	t.Run("CalculateOvernightCharge", func(t *testing.T) {
+		// Leveraged equity long pays interest on the borrowed half: 500 * 0.365 / 365
+		stock := newPosition(models.InstrumentTypeStock, models.ProductTypeCNC, models.PositionDirectionLong, "NSE")
+		assert.InDelta(t, 0.5, simulation.CalculateOvernightCharge(stock, settings, 2.0, 1), 1e-9)
+		assert.Equal(t, 0.0, simulation.CalculateOvernightCharge(stock, settings, 1.0, 1))
+		
+		// Futures carry is charged on the full notional and scales with days held
+		future := newPosition(models.InstrumentTypeFuture, models.ProductTypeNRML, models.PositionDirectionLong, "NFO")
+		assert.InDelta(t, 0.3, simulation.CalculateOvernightCharge(future, settings, 1.0, 3), 1e-9)
+		
+		// Crypto shorts receive funding when the rate is positive
+		crypto := newPosition(models.InstrumentTypeFuture, models.ProductTypeNRML, models.PositionDirectionShort, "CRYPTO")
+		assert.InDelta(t, -1.0, simulation.CalculateOvernightCharge(crypto, settings, 1.0, 1), 1e-9)
+		
+		// Intraday positions never carry overnight
+		intraday := newPosition(models.InstrumentTypeFuture, models.ProductTypeMIS, models.PositionDirectionLong, "NFO")
+		assert.Equal(t, 0.0, simulation.CalculateOvernightCharge(intraday, settings, 1.0, 1))
+	})
+	
+	t.Run("ApplyOvernightFunding", func(t *testing.T) {
+		service := simulation.NewOvernightFundingService()
+		asOf := time.Date(2024, 1, 10, 21, 0, 0, 0, time.UTC)
+		
+		_, err := service.ApplyOvernightFunding("sim123", asOf)
+		assert.NoError(t, err)
+		
+		// Funding is applied at most once per day
+		_, err = service.ApplyOvernightFunding("sim123", asOf.Add(time.Hour))
+		assert.Error(t, err)
+		
+		_, err = service.ApplyOvernightFunding("", asOf)
+		assert.Error(t, err)
+		
+		// The next day is charged again
+		_, err = service.ApplyOvernightFunding("sim123", asOf.Add(24*time.Hour))
+		assert.NoError(t, err)
+	})
+	
+	t.Run("ConcurrentRuns", func(t *testing.T) {
+		service := simulation.NewOvernightFundingService()
+		asOf := time.Date(2024, 1, 10, 23, 0, 0, 0, time.UTC)
+		
+		// Runs racing for the same day charge it once
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		applied := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := service.ApplyOvernightFunding("sim123", asOf); err == nil {
+					mutex.Lock()
+					applied++
+					mutex.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		
+		assert.Equal(t, 1, applied)
+	})
+}
+
+func TestBacktestWorkerPool(t *testing.T) {
+	service := simulation.NewBacktestService()
+	
+	t.Run("ShardBacktestJobs", func(t *testing.T) {
+		combinations := []map[string]interface{}{{"period": 10}, {"period": 20}}
+		jobs := simulation.ShardBacktestJobs("batch1", "strategy1", []string{"A", "B", "C", "D", "E"}, combinations, time.Now().Add(-24*time.Hour), time.Now(), 2)
+		
+		// 2 parameter sets x 3 symbol shards
+		assert.Equal(t, 6, len(jobs))
+		assert.Equal(t, []string{"A", "B"}, jobs[0].Symbols)
+		assert.Equal(t, []string{"E"}, jobs[2].Symbols)
+		for _, job := range jobs {
+			assert.Equal(t, "batch1", job.BatchID)
+		}
+	})
+	
+	t.Run("RunDistributedOptimization", func(t *testing.T) {
+		queue := simulation.NewInMemoryBacktestJobQueue(8)
+		pool := simulation.NewBacktestWorkerPool(queue, service, 4)
+		assert.NoError(t, pool.Start(context.Background()))
+		defer pool.Stop()
+		
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		
+		parameterRanges := map[string]map[string]interface{}{
+			"period": {"min": 1, "max": 10, "step": 1},
+		}
+		symbols := []string{"AAPL", "MSFT", "GOOGL", "AMZN", "FB"}
+		
+		result, err := service.RunDistributedOptimization(ctx, queue, "strategy1", symbols, parameterRanges, "sharpeRatio", time.Now().Add(-365*24*time.Hour), time.Now(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, len(result.Trials))
+		assert.NotNil(t, result.OptimalParameters)
+		for _, trial := range result.Trials {
+			assert.LessOrEqual(t, trial.MetricValue, result.MetricValue)
+		}
+		
+		_, err = service.RunDistributedOptimization(ctx, queue, "strategy1", []string{}, parameterRanges, "sharpeRatio", time.Now().Add(-365*24*time.Hour), time.Now(), 2)
+		assert.Error(t, err)
+	})
+	
+	t.Run("PanickingJob", func(t *testing.T) {
+		panicking := simulation.NewBacktestService()
+		panicking.SetParameterEvaluator(func(strategyID string, parameters map[string]interface{}, startDate, endDate time.Time) (map[string]float64, error) {
+			panic("strategy not loaded")
+		})
+		queue := simulation.NewInMemoryBacktestJobQueue(1)
+		pool := simulation.NewBacktestWorkerPool(queue, panicking, 1)
+		assert.NoError(t, pool.Start(context.Background()))
+		defer pool.Stop()
+		
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		
+		// The job fails and the worker goes on with the next
+		for _, jobID := range []string{"job1", "job2"} {
+			assert.NoError(t, queue.Enqueue(ctx, models.BacktestJob{ID: jobID, BatchID: "batch1", StrategyID: "strategy1"}))
+			result, err := queue.ConsumeResult(ctx, "batch1")
+			if assert.NoError(t, err) {
+				assert.Equal(t, jobID, result.JobID)
+				assert.Contains(t, result.Error, "panic")
+			}
+		}
+	})
+	
+	t.Run("StartTwice", func(t *testing.T) {
+		pool := simulation.NewBacktestWorkerPool(simulation.NewInMemoryBacktestJobQueue(1), service, 1)
+		assert.NoError(t, pool.Start(context.Background()))
+		assert.Error(t, pool.Start(context.Background()))
+		pool.Stop()
+	})
+}
+
+// buyThenSellStrategy buys on the first candle and places a take-profit limit on the third
+type buyThenSellStrategy struct {
+	candles int
+	fills   int
+}
+
+func (s *buyThenSellStrategy) OnInit(ctx simulation.BacktestStrategyContext) error {
+	return nil
+}
+
+func (s *buyThenSellStrategy) OnTick(ctx simulation.BacktestStrategyContext, tick models.MarketDataSnapshot) error {
+	return nil
+}
+
+func (s *buyThenSellStrategy) OnCandle(ctx simulation.BacktestStrategyContext, candle models.MarketDataSnapshot) error {
+	s.candles++
+	
+	switch s.candles {
+	case 1:
+		_, err := ctx.SubmitOrder(models.Order{Symbol: candle.Symbol, Direction: models.OrderDirectionBuy, OrderType: models.OrderTypeMarket, Quantity: 10})
+		return err
+	case 3:
+		_, err := ctx.SubmitOrder(models.Order{Symbol: candle.Symbol, Direction: models.OrderDirectionSell, OrderType: models.OrderTypeLimit, Price: 104.0, Quantity: 10})
+		return err
+	}
+	
+	return nil
+}
+
+func (s *buyThenSellStrategy) OnFill(ctx simulation.BacktestStrategyContext, fill models.SimulationOrder) error {
+	s.fills++
+	return nil
+}
+
+func TestStrategyBacktester(t *testing.T) {
+	startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var candles []models.MarketDataSnapshot
+	for i := 0; i < 5; i++ {
+		price := 100.0 + float64(i)
+		candles = append(candles, models.MarketDataSnapshot{
+			Symbol:    "NIFTY",
+			Timestamp: startDate.Add(time.Duration(i) * 24 * time.Hour),
+			Open:      price,
+			High:      price + 1.5,
+			Low:       price - 1.0,
+			Close:     price + 0.5,
+		})
+	}
+	
+	t.Run("RunCandles", func(t *testing.T) {
+		strategy := &buyThenSellStrategy{}
+		settings := &models.MarketSettings{CommissionModel: "FIXED", CommissionValue: 1.0, AllowShortSelling: true}
+		backtester := simulation.NewStrategyBacktester("session1", strategy, 10000.0, settings, nil)
+		
+		report, err := backtester.RunCandles(candles)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, strategy.fills)
+		assert.Equal(t, 5, len(report.Results))
+		
+		// Market buy fills at the next candle's open, limit sell at its limit price
+		assert.Equal(t, 101.0, report.Trades[0].AveragePrice)
+		assert.Equal(t, 104.0, report.Trades[1].AveragePrice)
+		assert.Equal(t, 1, report.TotalTrades)
+		assert.Equal(t, 1, report.WinningTrades)
+		assert.InDelta(t, 29.0, report.RealizedPnL, 1e-9)
+		assert.InDelta(t, 10028.0, report.FinalBalance, 1e-9)
+		
+		_, err = backtester.RunCandles(nil)
+		assert.Error(t, err)
+	})
+	
+	t.Run("Registry", func(t *testing.T) {
+		err := simulation.RegisterBacktestStrategy("buy-then-sell", func(parameters map[string]interface{}) (simulation.BacktestStrategy, error) {
+			return &buyThenSellStrategy{}, nil
+		})
+		assert.NoError(t, err)
+		assert.Contains(t, simulation.RegisteredBacktestStrategies(), "buy-then-sell")
+		
+		// Names are unique
+		err = simulation.RegisterBacktestStrategy("buy-then-sell", func(parameters map[string]interface{}) (simulation.BacktestStrategy, error) {
+			return &buyThenSellStrategy{}, nil
+		})
+		assert.Error(t, err)
+		
+		session := &models.BacktestSession{
+			ID:             "session2",
+			StartDate:      startDate,
+			EndDate:        startDate.Add(10 * 24 * time.Hour),
+			Symbols:        []string{"AAPL"},
+			Timeframe:      "1d",
+			InitialBalance: 100000.0,
+			GoStrategyName: "buy-then-sell",
+		}
+		
+		report, err := simulation.NewBacktestService().RunStrategyBacktest(session, nil)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, report.Results)
+		assert.Equal(t, "COMPLETED", session.Status)
+		
+		_, err = simulation.NewBacktestStrategy("unknown", nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestSidecarStrategyRegistration(t *testing.T) {
+	t.Run("RegisterSidecarStrategy", func(t *testing.T) {
+		_, err := simulation.RegisterSidecarStrategy(nil, "mean_reversion", 0)
+		assert.Error(t, err)
+		
+		_, err = simulation.DialStrategySidecar(context.Background(), "")
+		assert.Error(t, err)
+	})
+}
+
+// legGroupStrategy buys on the first tick and protects the position with a leg group stop-loss
+type legGroupStrategy struct {
+	fills []models.SimulationOrder
+}
+
+func (s *legGroupStrategy) OnInit(ctx simulation.BacktestStrategyContext) error {
+	return nil
+}
+
+func (s *legGroupStrategy) OnTick(ctx simulation.BacktestStrategyContext, tick models.MarketDataSnapshot) error {
+	if ctx.Position(tick.Symbol) != 0 || len(s.fills) > 0 {
+		return nil
+	}
+	
+	if _, err := ctx.SubmitOrder(models.Order{Symbol: tick.Symbol, Direction: models.OrderDirectionBuy, OrderType: models.OrderTypeMarket, Quantity: 15}); err != nil {
+		return err
+	}
+	
+	return ctx.SetLegGroupExit("straddle", []string{tick.Symbol}, 50.0, 0)
+}
+
+func (s *legGroupStrategy) OnCandle(ctx simulation.BacktestStrategyContext, candle models.MarketDataSnapshot) error {
+	return nil
+}
+
+func (s *legGroupStrategy) OnFill(ctx simulation.BacktestStrategyContext, fill models.SimulationOrder) error {
+	s.fills = append(s.fills, fill)
+	return nil
+}
+
+func TestTickBacktester(t *testing.T) {
+	startTime := time.Date(2024, 1, 2, 9, 15, 0, 0, time.UTC)
+	ticks := []models.MarketDataSnapshot{
+		{Symbol: "NIFTY24JAN21500CE", Timestamp: startTime, Bid: 99, Ask: 101, BidSize: 10, AskSize: 10, Close: 100},
+		{Symbol: "NIFTY24JAN21500CE", Timestamp: startTime.Add(1 * time.Second), Bid: 100, Ask: 102, BidSize: 10, AskSize: 10, Close: 101},
+		{Symbol: "NIFTY24JAN21500CE", Timestamp: startTime.Add(2 * time.Second), Bid: 100, Ask: 103, Close: 101},
+		{Symbol: "NIFTY24JAN21500CE", Timestamp: startTime.Add(3 * time.Second), Bid: 98, Ask: 99, Close: 98},
+		{Symbol: "NIFTY24JAN21500CE", Timestamp: startTime.Add(70 * time.Second), Bid: 90, Ask: 91, Close: 90},
+	}
+	
+	t.Run("RunTicks", func(t *testing.T) {
+		strategy := &legGroupStrategy{}
+		backtester := simulation.NewStrategyBacktester("tick-session", strategy, 10000.0, nil, nil)
+		
+		report, err := backtester.RunTicks(ticks)
+		assert.NoError(t, err)
+		assert.Len(t, strategy.fills, 3)
+		
+		// The entry is limited by the 10 lots on the ask and completes on the next tick
+		assert.Equal(t, models.OrderStatusPartial, strategy.fills[0].Status)
+		assert.Equal(t, 10, strategy.fills[0].FilledQuantity)
+		assert.Equal(t, models.OrderStatusExecuted, strategy.fills[1].Status)
+		assert.InDelta(t, 102.333, strategy.fills[1].AveragePrice, 0.001)
+		
+		// The stop-loss exits at the bid of the first tick that breaches it
+		assert.Contains(t, strategy.fills[2].Tags, "LEG_GROUP_EXIT")
+		assert.Equal(t, 98.0, strategy.fills[2].AveragePrice)
+		assert.Equal(t, 0, backtester.Position("NIFTY24JAN21500CE"))
+		assert.InDelta(t, -65.0, report.RealizedPnL, 0.0001)
+		assert.InDelta(t, 9935.0, report.FinalBalance, 0.0001)
+		
+		// One equity point per minute
+		assert.Len(t, report.Results, 2)
+	})
+	
+	t.Run("SetLegGroupExit", func(t *testing.T) {
+		backtester := simulation.NewStrategyBacktester("tick-session", &legGroupStrategy{}, 10000.0, nil, nil)
+		
+		assert.Error(t, backtester.SetLegGroupExit("", []string{"NIFTY"}, 10, 0))
+		assert.Error(t, backtester.SetLegGroupExit("group", nil, 10, 0))
+		assert.Error(t, backtester.SetLegGroupExit("group", []string{"NIFTY"}, -10, 0))
+		assert.NoError(t, backtester.SetLegGroupExit("group", []string{"NIFTY"}, 0, 0))
+	})
+}
+
+func TestBacktestTCA(t *testing.T) {
+	startTime := time.Date(2024, 1, 2, 9, 15, 0, 0, time.UTC)
+	ticks := []models.MarketDataSnapshot{
+		{Symbol: "BANKNIFTY", Timestamp: startTime, Bid: 99, Ask: 101, Close: 100},
+		{Symbol: "BANKNIFTY", Timestamp: startTime.Add(1 * time.Second), Bid: 100, Ask: 100, Close: 100},
+		{Symbol: "BANKNIFTY", Timestamp: startTime.Add(2 * time.Second), Bid: 80, Ask: 80, Close: 80},
+	}
+	marketSettings := &models.MarketSettings{
+		SlippageModel:     "FIXED",
+		SlippageValue:     0.5,
+		CommissionModel:   "PERCENTAGE",
+		CommissionValue:   0.001,
+		AllowShortSelling: true,
+	}
+	
+	backtester := simulation.NewStrategyBacktester("tca-session", &legGroupStrategy{}, 10000.0, marketSettings, nil)
+	report, err := backtester.RunTicks(ticks)
+	assert.NoError(t, err)
+	
+	t.Run("CostBreakdown", func(t *testing.T) {
+		assert.InDelta(t, -300.0, report.GrossPnL, 0.0001)
+		assert.InDelta(t, -317.7, report.NetPnL, 0.0001)
+		assert.InDelta(t, 2.7, report.TotalCharges, 0.0001)
+		assert.InDelta(t, 15.0, report.TotalSlippage, 0.0001)
+		assert.InDelta(t, 15.0, report.LegSlippage["BANKNIFTY"], 0.0001)
+		
+		lastResult := report.Results[len(report.Results)-1]
+		assert.InDelta(t, report.GrossPnL, lastResult.GrossPnL, 0.0001)
+		assert.InDelta(t, report.NetPnL, lastResult.CumulativePnL, 0.0001)
+	})
+	
+	t.Run("GenerateTCAReport", func(t *testing.T) {
+		service := simulation.NewBacktestService()
+		liveOrders := []models.Order{
+			{ID: "live-1", Symbol: "BANKNIFTY", Direction: models.OrderDirectionBuy, Quantity: 15, FilledQuantity: 15, Price: 100, AveragePrice: 101},
+			{ID: "live-2", Symbol: "FINNIFTY", Direction: models.OrderDirectionBuy, Quantity: 1, FilledQuantity: 1, Price: 100, AveragePrice: 101},
+		}
+		
+		tca, err := service.GenerateTCAReport("tca-session", report, liveOrders, map[string]float64{"live-1": 3.03})
+		assert.NoError(t, err)
+		assert.Len(t, tca.Legs, 1)
+		assert.Equal(t, []string{"FINNIFTY"}, tca.UnmatchedLiveSymbols)
+		
+		leg := tca.Legs[0]
+		assert.InDelta(t, 0.5, leg.AssumedSlippagePerUnit, 0.0001)
+		assert.InDelta(t, 1.0, leg.RealizedSlippagePerUnit, 0.0001)
+		assert.InDelta(t, 0.001, leg.AssumedChargesRate, 0.000001)
+		assert.InDelta(t, 9.015, leg.AssumedCost, 0.0001)
+		assert.InDelta(t, 18.03, leg.RealizedCost, 0.0001)
+		assert.InDelta(t, 9.015, tca.TotalCostDifference, 0.0001)
+		
+		_, err = service.GenerateTCAReport("tca-session", nil, liveOrders, nil)
+		assert.Error(t, err)
+	})
+}
+
+// singleOrderStrategy places one market order on its first candle
+type singleOrderStrategy struct {
+	direction models.OrderDirection
+	quantity  int
+	submitErr error
+}
+
+func (s *singleOrderStrategy) OnInit(ctx simulation.BacktestStrategyContext) error {
+	return nil
+}
+
+func (s *singleOrderStrategy) OnTick(ctx simulation.BacktestStrategyContext, tick models.MarketDataSnapshot) error {
+	return nil
+}
+
+func (s *singleOrderStrategy) OnCandle(ctx simulation.BacktestStrategyContext, candle models.MarketDataSnapshot) error {
+	if s.quantity > 0 {
+		_, s.submitErr = ctx.SubmitOrder(models.Order{Symbol: candle.Symbol, Direction: s.direction, OrderType: models.OrderTypeMarket, Quantity: s.quantity})
+		s.quantity = 0
+	}
+	return nil
+}
+
+func (s *singleOrderStrategy) OnFill(ctx simulation.BacktestStrategyContext, fill models.SimulationOrder) error {
+	return nil
+}
+
+func TestPortfolioBacktester(t *testing.T) {
+	startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	futurePrices := []float64{100, 100, 90, 80, 70}
+	hedgePrices := []float64{100, 100, 95, 90, 85}
+	
+	var candles []models.MarketDataSnapshot
+	for i := range futurePrices {
+		timestamp := startDate.Add(time.Duration(i) * 24 * time.Hour)
+		candles = append(candles,
+			models.MarketDataSnapshot{Symbol: "NIFTYFUT", Timestamp: timestamp, Open: futurePrices[i], High: futurePrices[i], Low: futurePrices[i], Close: futurePrices[i]},
+			models.MarketDataSnapshot{Symbol: "NIFTYETF", Timestamp: timestamp, Open: hedgePrices[i], High: hedgePrices[i], Low: hedgePrices[i], Close: hedgePrices[i]},
+		)
+	}
+	
+	newBacktester := func(hedgeOffset float64) (*simulation.PortfolioBacktester, *singleOrderStrategy, *singleOrderStrategy) {
+		long := &singleOrderStrategy{direction: models.OrderDirectionBuy, quantity: 150}
+		short := &singleOrderStrategy{direction: models.OrderDirectionSell, quantity: 150}
+		members := []simulation.PortfolioBacktestMember{
+			{Name: "trend", Strategy: long, Symbols: []string{"NIFTYFUT"}},
+			{Name: "hedge", Strategy: short, Symbols: []string{"NIFTYETF"}},
+		}
+		config := models.PortfolioBacktestConfig{
+			MarginRate:     0.5,
+			HedgeOffset:    hedgeOffset,
+			Underlyings:    map[string]string{"NIFTYFUT": "NIFTY", "NIFTYETF": "NIFTY"},
+			MaxDrawdownPct: 5,
+		}
+		
+		backtester, err := simulation.NewPortfolioBacktester("portfolio-session", members, 10000.0, nil, config)
+		assert.NoError(t, err)
+		return backtester, long, short
+	}
+	
+	t.Run("MarginWithoutCrossMargining", func(t *testing.T) {
+		backtester, long, short := newBacktester(0)
+		
+		report, err := backtester.RunCandles(candles)
+		assert.NoError(t, err)
+		assert.NoError(t, long.submitErr)
+		assert.Error(t, short.submitErr)
+		assert.Equal(t, 1, report.MarginRejections)
+		assert.Equal(t, 1, report.StrategyOrders["trend"])
+		assert.InDelta(t, 7500.0, report.PeakMarginUsed, 0.0001)
+	})
+	
+	t.Run("CrossMarginingAndStopRule", func(t *testing.T) {
+		backtester, long, short := newBacktester(0.5)
+		
+		report, err := backtester.RunCandles(candles)
+		assert.NoError(t, err)
+		assert.NoError(t, long.submitErr)
+		assert.NoError(t, short.submitErr)
+		assert.Equal(t, 0, report.MarginRejections)
+		assert.InDelta(t, 7500.0, report.PeakMarginUsed, 0.0001)
+		
+		// The 15% drawdown on the third day halts trading and flattens both legs
+		assert.True(t, report.Halted)
+		assert.Equal(t, startDate.Add(48*time.Hour), *report.HaltedAt)
+		assert.InDelta(t, 7750.0, report.FinalBalance, 0.0001)
+	})
+	
+	t.Run("Validation", func(t *testing.T) {
+		_, err := simulation.NewPortfolioBacktester("portfolio-session", nil, 10000.0, nil, models.PortfolioBacktestConfig{})
+		assert.Error(t, err)
+		
+		members := []simulation.PortfolioBacktestMember{{Name: "trend", Strategy: &singleOrderStrategy{}}}
+		_, err = simulation.NewPortfolioBacktester("portfolio-session", members, 10000.0, nil, models.PortfolioBacktestConfig{HedgeOffset: 2})
+		assert.Error(t, err)
+	})
+}
+
+func TestHistoricalDataCache(t *testing.T) {
+	var mutex sync.Mutex
+	loads := 0
+	loader := func(symbol string, startDate, endDate time.Time, timeframe string) ([]models.MarketDataSnapshot, error) {
+		mutex.Lock()
+		loads++
+		mutex.Unlock()
+		
+		var snapshots []models.MarketDataSnapshot
+		for timestamp := startDate; timestamp.Before(endDate); timestamp = timestamp.Add(24 * time.Hour) {
+			snapshots = append(snapshots, models.MarketDataSnapshot{Symbol: symbol, Timestamp: timestamp, Close: float64(timestamp.Day())})
+		}
+		return snapshots, nil
+	}
+	
+	startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := startDate.Add(30 * 24 * time.Hour)
+	
+	t.Run("SharedLoads", func(t *testing.T) {
+		cache := simulation.NewHistoricalDataCache(loader, 50)
+		loads = 0
+		
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				data, err := cache.Get("NIFTY", startDate, endDate, "1d")
+				assert.NoError(t, err)
+				assert.Len(t, data, 30)
+			}()
+		}
+		wg.Wait()
+		
+		// A sub-range is sliced from the cached series
+		data, err := cache.Get("NIFTY", startDate.Add(5*24*time.Hour), startDate.Add(10*24*time.Hour), "1d")
+		assert.NoError(t, err)
+		assert.Len(t, data, 5)
+		assert.Equal(t, startDate.Add(5*24*time.Hour), data[0].Timestamp)
+		
+		assert.Equal(t, 1, loads)
+		stats := cache.Stats()
+		assert.Equal(t, int64(1), stats.Misses)
+		assert.Equal(t, int64(5), stats.Hits)
+	})
+	
+	t.Run("LRUEviction", func(t *testing.T) {
+		cache := simulation.NewHistoricalDataCache(loader, 50)
+		
+		_, err := cache.Get("NIFTY", startDate, endDate, "1d")
+		assert.NoError(t, err)
+		_, err = cache.Get("BANKNIFTY", startDate, endDate, "1d")
+		assert.NoError(t, err)
+		
+		stats := cache.Stats()
+		assert.Equal(t, int64(1), stats.Evictions)
+		assert.Equal(t, 1, stats.Entries)
+		assert.Equal(t, 30, stats.Rows)
+		
+		cache.Invalidate("BANKNIFTY")
+		assert.Equal(t, 0, cache.Stats().Entries)
+	})
+}
+
+// alternatingStrategy buys and sells on alternate candles
+type alternatingStrategy struct {
+	candles int
+}
+
+func (s *alternatingStrategy) OnInit(ctx simulation.BacktestStrategyContext) error {
+	return nil
+}
+
+func (s *alternatingStrategy) OnTick(ctx simulation.BacktestStrategyContext, tick models.MarketDataSnapshot) error {
+	return nil
+}
+
+func (s *alternatingStrategy) OnCandle(ctx simulation.BacktestStrategyContext, candle models.MarketDataSnapshot) error {
+	s.candles++
+	
+	direction := models.OrderDirectionBuy
+	if s.candles%2 == 0 {
+		direction = models.OrderDirectionSell
+	}
+	
+	_, err := ctx.SubmitOrder(models.Order{Symbol: candle.Symbol, Direction: direction, OrderType: models.OrderTypeMarket, Quantity: 1 + s.candles%3})
+	return err
+}
+
+func (s *alternatingStrategy) OnFill(ctx simulation.BacktestStrategyContext, fill models.SimulationOrder) error {
+	return nil
+}
+
+func TestDeterministicBacktests(t *testing.T) {
+	startTime := time.Date(2024, 1, 1, 9, 15, 0, 0, time.UTC)
+	var candles []models.MarketDataSnapshot
+	for i := 0; i < 50; i++ {
+		price := 100.0 + float64(i%7)
+		for _, symbol := range []string{"NIFTY", "BANKNIFTY", "FINNIFTY"} {
+			candles = append(candles, models.MarketDataSnapshot{
+				Symbol:    symbol,
+				Timestamp: startTime.Add(time.Duration(i) * time.Minute),
+				Open:      price,
+				High:      price + 1,
+				Low:       price - 1,
+				Close:     price,
+			})
+		}
+	}
+	
+	marketSettings := &models.MarketSettings{
+		SlippageModel:     "VARIABLE",
+		SlippageValue:     0.001,
+		LatencyModel:      "REALISTIC",
+		LatencyValue:      20000,
+		AllowShortSelling: true,
+	}
+	
+	run := func(seed int64) (*models.StrategyBacktestReport, string) {
+		backtester := simulation.NewStrategyBacktester("seeded-session", &alternatingStrategy{}, 10000.0, marketSettings, nil)
+		backtester.SetRandomSeed(seed)
+		
+		report, err := backtester.RunCandles(candles)
+		assert.NoError(t, err)
+		
+		fingerprint, err := simulation.BacktestReportFingerprint(report)
+		assert.NoError(t, err)
+		return report, fingerprint
+	}
+	
+	t.Run("SameSeed", func(t *testing.T) {
+		first, firstFingerprint := run(42)
+		second, secondFingerprint := run(42)
+		
+		assert.Equal(t, firstFingerprint, secondFingerprint)
+		assert.Equal(t, first.FinalBalance, second.FinalBalance)
+		assert.Equal(t, first.Trades, second.Trades)
+	})
+	
+	t.Run("DifferentSeed", func(t *testing.T) {
+		_, firstFingerprint := run(1)
+		_, secondFingerprint := run(2)
+		
+		assert.NotEqual(t, firstFingerprint, secondFingerprint)
+	})
+	
+	t.Run("Fingerprint", func(t *testing.T) {
+		_, err := simulation.BacktestReportFingerprint(nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestBacktestCustomData(t *testing.T) {
+	service := simulation.NewBacktestService()
+	candleCSV := strings.Join([]string{
+		"Date,Open,High,Low,Close,Volume",
+		"2024-01-01,100,101.5,99,100.5,1000",
+		"2024-01-02,101,102.5,100,101.5,1000",
+		"2024-01-03,102,103.5,101,102.5,1000",
+		"2024-01-04,103,104.5,102,103.5,1000",
+		"2024-01-05,104,105.5,103,104.5,1000",
+	}, "\n")
+	
+	t.Run("ImportCustomData", func(t *testing.T) {
+		dataset, err := service.ImportCustomData("account-1", "NIFTYCUSTOM", "1d", strings.NewReader(candleCSV))
+		assert.NoError(t, err)
+		assert.Equal(t, "CSV:NIFTYCUSTOM", dataset.Symbol)
+		assert.Equal(t, 5, dataset.Rows)
+		assert.Equal(t, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), dataset.EndDate)
+		
+		ticks, err := service.ImportCustomData("account-1", "TICKS", "", strings.NewReader("timestamp,bid,ask\n1704186000000,99.5,100.5\n1704186001,99,101\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, "tick", ticks.Timeframe)
+		
+		datasets, err := service.GetCustomDatasets("account-1")
+		assert.NoError(t, err)
+		assert.Len(t, datasets, 2)
+		
+		// Another account cannot overwrite the symbol
+		_, err = service.ImportCustomData("account-2", "NIFTYCUSTOM", "1d", strings.NewReader(candleCSV))
+		assert.Error(t, err)
+		
+		assert.NoError(t, service.DeleteCustomDataset("account-1", "TICKS"))
+		assert.Error(t, service.DeleteCustomDataset("account-1", "TICKS"))
+	})
+	
+	t.Run("InvalidData", func(t *testing.T) {
+		invalidFiles := []string{
+			"",
+			"open,high,low,close\n1,2,0.5,1\n",
+			"date,volume\n2024-01-01,100\n",
+			"date,open,high,low,close\n2024-01-01,1,1,2,1\n",
+			"date,open,high,low,close\n2024-01-01,1,1,1,1\n2024-01-01,1,1,1,1\n",
+			"date,price\nyesterday,1\n",
+		}
+		
+		for _, file := range invalidFiles {
+			_, err := service.ImportCustomData("account-1", "INVALID", "1d", strings.NewReader(file))
+			assert.Error(t, err)
+		}
+	})
+	
+	t.Run("RunStrategyBacktest", func(t *testing.T) {
+		err := simulation.RegisterBacktestStrategy("custom-data-buy-then-sell", func(parameters map[string]interface{}) (simulation.BacktestStrategy, error) {
+			return &buyThenSellStrategy{}, nil
+		})
+		assert.NoError(t, err)
+		
+		session := &models.BacktestSession{
+			ID:             "custom-data-session",
+			Symbols:        []string{"CSV:NIFTYCUSTOM"},
+			StartDate:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:        time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC),
+			Timeframe:      "1d",
+			InitialBalance: 10000.0,
+			GoStrategyName: "custom-data-buy-then-sell",
+		}
+		
+		report, err := service.RunStrategyBacktest(session, nil)
+		assert.NoError(t, err)
+		assert.Len(t, report.Results, 5)
+		assert.InDelta(t, 10030.0, report.FinalBalance, 0.0001)
+		assert.Equal(t, "COMPLETED", session.Status)
+	})
+}
+
+func TestBacktestReports(t *testing.T) {
+	service := simulation.NewBacktestService()
+	
+	t.Run("ExportFormats", func(t *testing.T) {
+		export, err := service.ExportBacktestResults("report-session", "csv")
+		assert.NoError(t, err)
+		assert.Equal(t, "text/csv", export.ContentType)
+		assert.Equal(t, "backtest_report-session.csv", export.FileName)
+		assert.True(t, strings.HasPrefix(string(export.Data), "timestamp,equity,drawdownPct"))
+		
+		export, err = service.ExportBacktestResults("report-session", "HTML")
+		assert.NoError(t, err)
+		assert.Equal(t, "text/html; charset=utf-8", export.ContentType)
+		assert.Contains(t, string(export.Data), "<polyline")
+		assert.Contains(t, string(export.Data), "Performance Metrics")
+		assert.Contains(t, string(export.Data), "Monte Carlo")
+		
+		export, err = service.ExportBacktestResults("report-session", "pdf")
+		assert.NoError(t, err)
+		assert.Equal(t, "application/pdf", export.ContentType)
+		assert.True(t, strings.HasPrefix(string(export.Data), "%PDF-1.4"))
+		assert.True(t, strings.HasSuffix(string(export.Data), "%%EOF\n"))
+		
+		_, err = service.ExportBacktestResults("report-session", "xlsx")
+		assert.Error(t, err)
+		
+		_, err = service.ExportBacktestResults("", "pdf")
+		assert.Error(t, err)
+	})
+	
+	t.Run("StoreReport", func(t *testing.T) {
+		service.SetReportStorage(simulation.NewFileReportStorage(t.TempDir()))
+		
+		export, err := service.StoreBacktestReport("report-session", "html")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, export.Location)
+		
+		data, err := os.ReadFile(export.Location)
+		assert.NoError(t, err)
+		assert.Equal(t, export.Data, data)
+	})
+}
+
+func TestRuleStrategy(t *testing.T) {
+	startDate := time.Date(2024, 1, 1, 9, 15, 0, 0, time.UTC)
+	var candles []models.MarketDataSnapshot
+	for i, price := range []float64{100, 99, 98, 101, 103, 102, 97, 96, 100} {
+		candles = append(candles, models.MarketDataSnapshot{
+			Symbol:    "NIFTY",
+			Timestamp: startDate.Add(time.Duration(i) * 24 * time.Hour),
+			Open:      price,
+			High:      price,
+			Low:       price,
+			Close:     price,
+		})
+	}
+	
+	rules := `
+name: sma-cross
+symbol: NIFTY
+indicators:
+  sma:
+    type: SMA
+    period: 3
+entry:
+  - when:
+      all:
+        - {left: close, op: crossesAbove, right: sma}
+        - {left: time, op: ">=", right: "09:15"}
+    actions:
+      - {direction: BUY, quantity: 10}
+exit:
+  - when: {left: close, op: crossesBelow, right: sma}
+maxEntries: 1
+`
+	
+	t.Run("ParseAndRun", func(t *testing.T) {
+		definition, err := simulation.ParseRuleStrategy([]byte(rules))
+		assert.NoError(t, err)
+		assert.Equal(t, "sma-cross", definition.Name)
+		
+		strategy, err := simulation.NewRuleStrategy(definition)
+		assert.NoError(t, err)
+		
+		settings := &models.MarketSettings{CommissionModel: "NONE", AllowShortSelling: true}
+		backtester := simulation.NewStrategyBacktester("rules-session", strategy, 10000.0, settings, nil)
+		report, err := backtester.RunCandles(candles)
+		assert.NoError(t, err)
+		
+		// Enters when the close crosses above its 3 day average and exits when it crosses back below;
+		// the second crossover above is ignored because of maxEntries
+		assert.Len(t, report.Trades, 2)
+		assert.Equal(t, 103.0, report.Trades[0].AveragePrice)
+		assert.Equal(t, 96.0, report.Trades[1].AveragePrice)
+		assert.InDelta(t, 9930.0, report.FinalBalance, 1e-9)
+	})
+	
+	t.Run("IndicatorLines", func(t *testing.T) {
+		// The middle Bollinger band is the same 3 day average
+		lines := strings.Replace(rules, "type: SMA", "type: BOLLINGER", 1)
+		lines = strings.Replace(lines, "right: sma}", "right: sma(middle)}", -1)
+		definition, err := simulation.ParseRuleStrategy([]byte(lines))
+		assert.NoError(t, err)
+		
+		strategy, err := simulation.NewRuleStrategy(definition)
+		assert.NoError(t, err)
+		
+		settings := &models.MarketSettings{CommissionModel: "NONE", AllowShortSelling: true}
+		report, err := simulation.NewStrategyBacktester("lines-session", strategy, 10000.0, settings, nil).RunCandles(candles)
+		assert.NoError(t, err)
+		assert.Len(t, report.Trades, 2)
+		assert.Equal(t, 103.0, report.Trades[0].AveragePrice)
+		assert.Equal(t, 96.0, report.Trades[1].AveragePrice)
+	})
+	
+	t.Run("JSON", func(t *testing.T) {
+		definition, err := simulation.ParseRuleStrategy([]byte(`{"name": "json-rules", "symbol": "NIFTY", "entry": [{"when": {"left": "close", "op": ">", "right": 99.5}, "actions": [{"direction": "SELL", "quantity": 5}]}]}`))
+		assert.NoError(t, err)
+		assert.Equal(t, models.RuleOperand("99.5"), definition.Entry[0].When.Right)
+	})
+	
+	t.Run("Greeks", func(t *testing.T) {
+		var data []models.MarketDataSnapshot
+		for i := 0; i < 2; i++ {
+			timestamp := startDate.Add(time.Duration(i) * 24 * time.Hour)
+			data = append(data,
+				models.MarketDataSnapshot{Symbol: "NIFTY", Timestamp: timestamp, Open: 100, High: 100, Low: 100, Close: 100},
+				models.MarketDataSnapshot{Symbol: "NIFTY100CE", Timestamp: timestamp, Open: 5, High: 5, Low: 5, Close: 5},
+			)
+		}
+		
+		run := func(minimumDelta float64) *models.StrategyBacktestReport {
+			strategy, err := simulation.NewRuleStrategy(&models.RuleStrategy{
+				Name:   "greeks",
+				Symbol: "NIFTY",
+				Options: map[string]models.RuleOption{
+					"atmCall": {Symbol: "NIFTY100CE", OptionType: "CE", Strike: 100, Expiry: startDate.Add(30 * 24 * time.Hour)},
+				},
+				Entry: []models.TradingRule{{
+					When: models.RuleCondition{All: []models.RuleCondition{
+						{Left: "delta(atmCall)", Op: ">", Right: models.RuleOperand(strconv.FormatFloat(minimumDelta, 'f', -1, 64))},
+						{Left: "iv(atmCall)", Op: ">", Right: "40"},
+					}},
+					Actions: []models.RuleAction{{Direction: models.OrderDirectionBuy, Symbol: "atmCall", Quantity: 50}},
+				}},
+			})
+			assert.NoError(t, err)
+			
+			report, err := simulation.NewStrategyBacktester("greeks-session", strategy, 10000.0, nil, nil).RunCandles(data)
+			assert.NoError(t, err)
+			return report
+		}
+		
+		// An at the money call priced at 5 with 30 days left implies about 43.7% volatility and 0.52 delta
+		report := run(0.5)
+		assert.Len(t, report.Trades, 1)
+		assert.Equal(t, "NIFTY100CE", report.Trades[0].Symbol)
+		
+		report = run(0.55)
+		assert.Len(t, report.Trades, 0)
+	})
+	
+	t.Run("Invalid", func(t *testing.T) {
+		invalidRules := []string{
+			`{"name": "x", "symbol": "NIFTY", "entry": []}`,
+			`{"name": "x", "symbol": "NIFTY", "entry": [{"when": {"left": "close", "op": "~", "right": 1}, "actions": [{"direction": "BUY", "quantity": 1}]}]}`,
+			`{"name": "x", "symbol": "NIFTY", "entry": [{"when": {"left": "macd", "op": ">", "right": 1}, "actions": [{"direction": "BUY", "quantity": 1}]}]}`,
+			`{"name": "x", "symbol": "NIFTY", "entry": [{"when": {"left": "close", "op": ">", "right": 1}, "actions": [{"direction": "HOLD", "quantity": 1}]}]}`,
+			`{"name": "x", "symbol": "NIFTY", "entry": [{"when": {"left": "delta(missing)", "op": ">", "right": 1}, "actions": [{"direction": "BUY", "quantity": 1}]}]}`,
+			`{"name": "x", "symbol": "NIFTY", "indicators": {"fast": {"type": "KAMA", "period": 3}}, "entry": [{"when": {"left": "close", "op": ">", "right": 1}, "actions": [{"direction": "BUY", "quantity": 1}]}]}`,
+			`{"name": "x", "symbol": "NIFTY", "indicators": {"sma": {"type": "SMA", "period": 3}}, "entry": [{"when": {"left": "close", "op": ">", "right": "sma(upper)"}, "actions": [{"direction": "BUY", "quantity": 1}]}]}`,
+			`{"name": "x", "symbol": "NIFTY", "entry": [{"when": {"left": "close", "op": ">", "right": 1}, "actions": [{"direction": "BUY", "quantity": 1}]}], "trailingStop": 10}`,
+			`name: [unterminated`,
+		}
+		
+		for _, rules := range invalidRules {
+			_, err := simulation.ParseRuleStrategy([]byte(rules))
+			assert.Error(t, err, rules)
+		}
+	})
+	
+	t.Run("Register", func(t *testing.T) {
+		definition, err := simulation.ParseRuleStrategy([]byte(rules))
+		assert.NoError(t, err)
+		
+		name, err := simulation.RegisterRuleStrategy(definition)
+		assert.NoError(t, err)
+		assert.Equal(t, "rules:sma-cross", name)
+		assert.Contains(t, simulation.RegisteredBacktestStrategies(), name)
+		
+		_, err = simulation.RegisterRuleStrategy(definition)
+		assert.Error(t, err)
+		
+		strategy, err := simulation.NewBacktestStrategy(name, nil)
+		assert.NoError(t, err)
+		assert.NotNil(t, strategy)
+	})
+}
+
+// schedulerRuns records the order in which scheduled backtests start
+var schedulerRuns struct {
+	sync.Mutex
+	names []string
+}
+
+type recordingStrategy struct{}
+
+func (s *recordingStrategy) OnInit(ctx simulation.BacktestStrategyContext) error {
+	schedulerRuns.Lock()
+	schedulerRuns.names = append(schedulerRuns.names, ctx.Parameters()["name"].(string))
+	schedulerRuns.Unlock()
+	return nil
+}
+
+func (s *recordingStrategy) OnTick(ctx simulation.BacktestStrategyContext, tick models.MarketDataSnapshot) error {
+	return nil
+}
+
+func (s *recordingStrategy) OnCandle(ctx simulation.BacktestStrategyContext, candle models.MarketDataSnapshot) error {
+	return nil
+}
+
+func (s *recordingStrategy) OnFill(ctx simulation.BacktestStrategyContext, fill models.SimulationOrder) error {
+	return nil
+}
+
+// blockingStrategy signals started on its first candle and then waits for release
+type blockingStrategy struct {
+	started chan string
+	release chan struct{}
+	candles int
+}
+
+func (s *blockingStrategy) OnInit(ctx simulation.BacktestStrategyContext) error {
+	return nil
+}
+
+func (s *blockingStrategy) OnTick(ctx simulation.BacktestStrategyContext, tick models.MarketDataSnapshot) error {
+	return nil
+}
+
+func (s *blockingStrategy) OnCandle(ctx simulation.BacktestStrategyContext, candle models.MarketDataSnapshot) error {
+	s.candles++
+	if s.candles == 1 {
+		s.started <- ctx.Parameters()["name"].(string)
+		<-s.release
+	}
+	return nil
+}
+
+func (s *blockingStrategy) OnFill(ctx simulation.BacktestStrategyContext, fill models.SimulationOrder) error {
+	return nil
+}
+
+func TestBacktestScheduler(t *testing.T) {
+	service := simulation.NewBacktestService()
+	_, err := service.ImportCustomData("account-1", "SCHEDULED", "1d", strings.NewReader("date,open,high,low,close\n2024-01-01,100,101,99,100\n2024-01-02,100,101,99,100\n2024-01-03,100,101,99,100\n"))
+	assert.NoError(t, err)
+	
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	assert.NoError(t, simulation.RegisterBacktestStrategy("scheduler-recording", func(parameters map[string]interface{}) (simulation.BacktestStrategy, error) {
+		return &recordingStrategy{}, nil
+	}))
+	assert.NoError(t, simulation.RegisterBacktestStrategy("scheduler-blocking", func(parameters map[string]interface{}) (simulation.BacktestStrategy, error) {
+		return &blockingStrategy{started: started, release: release}, nil
+	}))
+	
+	session := func(strategy, name string) models.BacktestSession {
+		return models.BacktestSession{
+			Symbols:        []string{"CSV:SCHEDULED"},
+			StartDate:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:        time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC),
+			Timeframe:      "1d",
+			InitialBalance: 10000.0,
+			GoStrategyName: strategy,
+			Parameters:     map[string]interface{}{"name": name},
+		}
+	}
+	
+	waitFor := func(scheduler *simulation.BacktestScheduler, userID, backtestID string) *models.ScheduledBacktest {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			backtest, err := scheduler.Get(userID, backtestID)
+			assert.NoError(t, err)
+			
+			// FinishedAt is only set once a cancelled backtest's worker has stopped
+			if backtest.FinishedAt != nil || time.Now().After(deadline) {
+				return backtest
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	
+	t.Run("Priorities", func(t *testing.T) {
+		scheduler := simulation.NewBacktestScheduler(service, 1, 1)
+		
+		// Queued before the workers start so that priority alone decides the order
+		var ids []string
+		for _, priority := range []string{models.BacktestPriorityLow, models.BacktestPriorityNormal, models.BacktestPriorityHigh, models.BacktestPriorityNormal} {
+			backtest, err := scheduler.Submit("user-1", priority, session("scheduler-recording", priority+"-"+strconv.Itoa(len(ids))), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, models.ScheduledBacktestQueued, backtest.Status)
+			ids = append(ids, backtest.ID)
+		}
+		
+		assert.NoError(t, scheduler.Start(context.Background()))
+		defer scheduler.Stop()
+		
+		for _, id := range ids {
+			backtest := waitFor(scheduler, "user-1", id)
+			assert.Equal(t, models.ScheduledBacktestCompleted, backtest.Status)
+			assert.NotNil(t, backtest.Report)
+		}
+		
+		assert.Equal(t, []string{"HIGH-2", "NORMAL-1", "NORMAL-3", "LOW-0"}, schedulerRuns.names)
+		
+		backtests, err := scheduler.List("user-1")
+		assert.NoError(t, err)
+		assert.Len(t, backtests, 4)
+	})
+	
+	t.Run("QuotasAndCancel", func(t *testing.T) {
+		scheduler := simulation.NewBacktestScheduler(service, 2, 2)
+		assert.NoError(t, scheduler.SetUserQuota("user-1", 1))
+		assert.NoError(t, scheduler.Start(context.Background()))
+		defer scheduler.Stop()
+		
+		first, err := scheduler.Submit("user-1", "", session("scheduler-blocking", "first"), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "first", <-started)
+		
+		// user-1 is at quota, so the second worker takes user-2's lower priority backtest
+		second, err := scheduler.Submit("user-1", models.BacktestPriorityHigh, session("scheduler-blocking", "second"), nil)
+		assert.NoError(t, err)
+		other, err := scheduler.Submit("user-2", models.BacktestPriorityLow, session("scheduler-blocking", "other"), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "other", <-started)
+		
+		backtest, err := scheduler.Get("user-1", second.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ScheduledBacktestQueued, backtest.Status)
+		
+		// Only the owner can cancel
+		_, err = scheduler.Cancel("user-2", first.ID)
+		assert.Error(t, err)
+		
+		backtest, err = scheduler.Cancel("user-1", first.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ScheduledBacktestCancelled, backtest.Status)
+		close(release)
+		
+		backtest = waitFor(scheduler, "user-1", first.ID)
+		assert.Equal(t, models.ScheduledBacktestCancelled, backtest.Status)
+		assert.Equal(t, "CANCELLED", backtest.Session.Status)
+		assert.Nil(t, backtest.Report)
+		
+		backtest = waitFor(scheduler, "user-2", other.ID)
+		assert.Equal(t, models.ScheduledBacktestCompleted, backtest.Status)
+		
+		backtest = waitFor(scheduler, "user-1", second.ID)
+		assert.Equal(t, models.ScheduledBacktestCompleted, backtest.Status)
+		assert.Len(t, backtest.Report.Results, 3)
+		
+		_, err = scheduler.Cancel("user-1", second.ID)
+		assert.Error(t, err)
+	})
+	
+	t.Run("CancelQueued", func(t *testing.T) {
+		scheduler := simulation.NewBacktestScheduler(service, 1, 1)
+		
+		backtest, err := scheduler.Submit("user-1", "", session("scheduler-recording", "queued"), nil)
+		assert.NoError(t, err)
+		
+		backtest, err = scheduler.Cancel("user-1", backtest.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ScheduledBacktestCancelled, backtest.Status)
+		assert.NotNil(t, backtest.FinishedAt)
+		
+		_, err = scheduler.Submit("user-1", "URGENT", session("scheduler-recording", "invalid"), nil)
+		assert.Error(t, err)
+		
+		_, err = scheduler.Submit("", "", session("scheduler-recording", "invalid"), nil)
+		assert.Error(t, err)
+		
+		_, err = scheduler.Submit("user-1", "", models.BacktestSession{}, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestIntradayMargin(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	prices := map[time.Duration]float64{
+		9*time.Hour + 15*time.Minute:  100,
+		12 * time.Hour:                110,
+		15*time.Hour + 20*time.Minute: 120,
+		15*time.Hour + 25*time.Minute: 130,
+	}
+	
+	var candles []models.MarketDataSnapshot
+	for offset, price := range prices {
+		candles = append(candles, models.MarketDataSnapshot{Symbol: "NIFTY24JAN21000CE", Timestamp: day.Add(offset), Open: price, High: price, Low: price, Close: price})
+	}
+	
+	run := func(config models.IntradayMarginConfig, quantity int) (*models.StrategyBacktestReport, *singleOrderStrategy) {
+		strategy := &singleOrderStrategy{direction: models.OrderDirectionSell, quantity: quantity}
+		backtester := simulation.NewStrategyBacktester("margin-session", strategy, 10000.0, nil, nil)
+		assert.NoError(t, backtester.SetIntradayMargin(config))
+		
+		report, err := backtester.RunCandles(candles)
+		assert.NoError(t, err)
+		assert.NotNil(t, report.IntradayMargin)
+		return report, strategy
+	}
+	
+	t.Run("LeverageAndSquareOff", func(t *testing.T) {
+		// 400 units at 100 need 8000 of margin at 5x leverage; sold at 110, bought back at 120
+		report, strategy := run(models.IntradayMarginConfig{MISLeverage: 5, SquareOffTime: "15:20:00"}, 400)
+		assert.NoError(t, strategy.submitErr)
+		assert.Equal(t, 1, report.IntradayMargin.SquareOffs)
+		assert.Equal(t, 0, report.IntradayMargin.MarginCalls)
+		assert.InDelta(t, 9600.0, report.IntradayMargin.PeakMarginUsed, 0.0001)
+		assert.InDelta(t, 6000.0, report.FinalBalance, 0.0001)
+		
+		squareOff := report.Trades[len(report.Trades)-1]
+		assert.Equal(t, models.OrderDirectionBuy, squareOff.Direction)
+		assert.Equal(t, models.ProductTypeMIS, squareOff.ProductType)
+		assert.Equal(t, day.Add(15*time.Hour+20*time.Minute), squareOff.ExecutionTime)
+		assert.Contains(t, squareOff.Tags, "MIS_SQUARE_OFF")
+	})
+	
+	t.Run("InsufficientMargin", func(t *testing.T) {
+		report, strategy := run(models.IntradayMarginConfig{MISLeverage: 5}, 600)
+		assert.Error(t, strategy.submitErr)
+		assert.Equal(t, 1, report.IntradayMargin.MarginRejections)
+		assert.Len(t, report.Trades, 0)
+		assert.InDelta(t, 10000.0, report.FinalBalance, 0.0001)
+	})
+	
+	t.Run("MarginCall", func(t *testing.T) {
+		// At 120 the position needs 9600 of margin while equity has fallen to 6000
+		report, strategy := run(models.IntradayMarginConfig{MISLeverage: 5, MaintenanceMargin: 0.8}, 400)
+		assert.NoError(t, strategy.submitErr)
+		assert.Equal(t, 1, report.IntradayMargin.MarginCalls)
+		assert.Equal(t, 0, report.IntradayMargin.SquareOffs)
+		assert.InDelta(t, 6000.0, report.FinalBalance, 0.0001)
+		assert.Contains(t, report.Trades[len(report.Trades)-1].Tags, "MARGIN_CALL")
+	})
+	
+	t.Run("Validation", func(t *testing.T) {
+		backtester := simulation.NewStrategyBacktester("margin-session", &singleOrderStrategy{}, 10000.0, nil, nil)
+		assert.Error(t, backtester.SetIntradayMargin(models.IntradayMarginConfig{SquareOffTime: "3:20pm"}))
+		assert.Error(t, backtester.SetIntradayMargin(models.IntradayMarginConfig{Timezone: "Nowhere/City"}))
+		assert.Error(t, backtester.SetIntradayMargin(models.IntradayMarginConfig{MaintenanceMargin: 1.5}))
+		assert.NoError(t, backtester.SetIntradayMargin(models.IntradayMarginConfig{SquareOffTime: "15:20:00", Timezone: "Asia/Kolkata"}))
+	})
 }
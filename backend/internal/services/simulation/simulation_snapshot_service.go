@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"trading_platform/backend/internal/models"
+)
+
+// SimulationSnapshotService captures and restores point-in-time copies of simulation
+// accounts so users can branch experiments instead of resetting the whole account
+type SimulationSnapshotService struct {
+	// Dependencies would be injected here in a real implementation
+	// For example: database connection, etc.
+	simulationAccountService *SimulationAccountService
+	simulationOrderService   *SimulationOrderService
+
+	// In a real implementation, snapshots would be persisted to the database
+	snapshots map[string]map[string]*models.SimulationSnapshot // accountID -> snapshotID -> snapshot
+	mutex     sync.RWMutex
+}
+
+// NewSimulationSnapshotService creates a new instance of SimulationSnapshotService
+func NewSimulationSnapshotService() *SimulationSnapshotService {
+	return &SimulationSnapshotService{
+		simulationAccountService: NewSimulationAccountService(),
+		simulationOrderService:   NewSimulationOrderService(),
+		snapshots:                make(map[string]map[string]*models.SimulationSnapshot),
+	}
+}
+
+// CreateSnapshot captures the balance, open positions and open orders of a simulation account
+func (s *SimulationSnapshotService) CreateSnapshot(accountID string, name string, description string) (*models.SimulationSnapshot, error) {
+	if accountID == "" {
+		return nil, errors.New("account ID is required")
+	}
+
+	if name == "" {
+		return nil, errors.New("snapshot name is required")
+	}
+
+	account, err := s.simulationAccountService.GetSimulationAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := s.simulationOrderService.GetPositions(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.simulationOrderService.GetOrdersByAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &models.SimulationSnapshot{
+		ID:                  uuid.New().String(),
+		SimulationAccountID: accountID,
+		Name:                name,
+		Description:         description,
+		Balance:             account.CurrentBalance,
+		Positions:           make([]models.SimulationPosition, 0, len(positions)),
+		OpenOrders:          make([]models.SimulationOrder, 0, len(orders)),
+		CreatedAt:           time.Now(),
+	}
+
+	// Copy positions and orders by value so later changes don't leak into the snapshot
+	for _, position := range positions {
+		snapshot.Positions = append(snapshot.Positions, *position)
+	}
+
+	for _, order := range orders {
+		if order.Status == models.OrderStatusPending || order.Status == models.OrderStatusPartial {
+			snapshot.OpenOrders = append(snapshot.OpenOrders, order)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.snapshots[accountID]; !exists {
+		s.snapshots[accountID] = make(map[string]*models.SimulationSnapshot)
+	}
+	s.snapshots[accountID][snapshot.ID] = snapshot
+
+	return snapshot, nil
+}
+
+// GetSnapshots retrieves all snapshots for a simulation account, oldest first
+func (s *SimulationSnapshotService) GetSnapshots(accountID string) ([]models.SimulationSnapshot, error) {
+	if accountID == "" {
+		return nil, errors.New("account ID is required")
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	snapshots := make([]models.SimulationSnapshot, 0, len(s.snapshots[accountID]))
+	for _, snapshot := range s.snapshots[accountID] {
+		snapshots = append(snapshots, *snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+
+	return snapshots, nil
+}
+
+// RestoreSnapshot restores a simulation account to the state captured in a snapshot: its
+// balance, open positions and open orders, cancelling orders opened since.
+// Snapshots taken after the restored one are kept so the user can switch between branches.
+func (s *SimulationSnapshotService) RestoreSnapshot(accountID string, snapshotID string) (*models.SimulationAccount, error) {
+	if accountID == "" {
+		return nil, errors.New("account ID is required")
+	}
+
+	if snapshotID == "" {
+		return nil, errors.New("snapshot ID is required")
+	}
+
+	s.mutex.RLock()
+	snapshot, exists := s.snapshots[accountID][snapshotID]
+	s.mutex.RUnlock()
+
+	if !exists {
+		return nil, errors.New("snapshot not found")
+	}
+
+	account, err := s.simulationAccountService.GetSimulationAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cancel the orders opened since the snapshot was taken
+	orders, err := s.simulationOrderService.GetOrdersByAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		if order.Status == models.OrderStatusPending || order.Status == models.OrderStatusPartial {
+			if _, err := s.simulationOrderService.CancelOrder(order.ID); err != nil {
+				return nil, fmt.Errorf("failed to cancel order %s: %w", order.ID, err)
+			}
+		}
+	}
+
+	// Put back the snapshot's positions and open orders
+	if _, err := s.simulationOrderService.ReplacePositions(accountID, snapshot.Positions); err != nil {
+		return nil, fmt.Errorf("failed to restore positions: %w", err)
+	}
+	if _, err := s.simulationOrderService.RestoreOrders(accountID, snapshot.OpenOrders); err != nil {
+		return nil, fmt.Errorf("failed to restore open orders: %w", err)
+	}
+
+	// In a real implementation, all of the above and the balance update would run within a
+	// single transaction, with a restore transaction referencing the snapshot
+
+	account.CurrentBalance = snapshot.Balance
+	account.UpdatedAt = time.Now()
+
+	return account, nil
+}
+
+// DeleteSnapshot deletes a snapshot of a simulation account
+func (s *SimulationSnapshotService) DeleteSnapshot(accountID string, snapshotID string) error {
+	if accountID == "" {
+		return errors.New("account ID is required")
+	}
+
+	if snapshotID == "" {
+		return errors.New("snapshot ID is required")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.snapshots[accountID][snapshotID]; !exists {
+		return errors.New("snapshot not found")
+	}
+
+	delete(s.snapshots[accountID], snapshotID)
+
+	return nil
+}
@@ -0,0 +1,750 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"trading_platform/backend/internal/indicators"
+	"trading_platform/backend/internal/models"
+)
+
+// ruleStrategyPrefix namespaces rule strategies among registered strategies
+const ruleStrategyPrefix = "rules:"
+
+// ruleOperandPattern matches references such as "close", "rsi", "macd(signal)" or "delta(atmCall)"
+var ruleOperandPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(\(([^()]*)\))?$`)
+
+// ruleOperators are the comparison operators rule conditions support
+var ruleOperators = map[string]bool{
+	">": true, ">=": true, "<": true, "<=": true, "==": true, "!=": true,
+	"crossesAbove": true, "crossesBelow": true,
+}
+
+// ParseRuleStrategy parses a rule strategy written in JSON or YAML and checks that it compiles
+func ParseRuleStrategy(data []byte) (*models.RuleStrategy, error) {
+	// YAML is a superset of JSON, so both are decoded as YAML and then mapped onto
+	// the model through its JSON tags
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid rule strategy: %w", err)
+	}
+
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule strategy: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+
+	var definition models.RuleStrategy
+	if err := decoder.Decode(&definition); err != nil {
+		return nil, fmt.Errorf("invalid rule strategy: %w", err)
+	}
+
+	if _, err := NewRuleStrategy(&definition); err != nil {
+		return nil, err
+	}
+
+	return &definition, nil
+}
+
+// RegisterRuleStrategy compiles a rule strategy and registers it as "rules:<name>" so
+// backtest sessions can run it through GoStrategyName. The compiled strategy uses
+// nothing but BacktestStrategyContext, so any engine that drives a BacktestStrategy
+// against a live account runs it unchanged.
+func RegisterRuleStrategy(definition *models.RuleStrategy) (string, error) {
+	if definition == nil {
+		return "", errors.New("rule strategy is required")
+	}
+
+	if _, err := NewRuleStrategy(definition); err != nil {
+		return "", err
+	}
+
+	// Later changes to the caller's definition must not change the registered strategy
+	encoded, err := json.Marshal(definition)
+	if err != nil {
+		return "", err
+	}
+
+	var registered models.RuleStrategy
+	if err := json.Unmarshal(encoded, &registered); err != nil {
+		return "", err
+	}
+
+	name := ruleStrategyPrefix + registered.Name
+	err = RegisterBacktestStrategy(name, func(parameters map[string]interface{}) (BacktestStrategy, error) {
+		return NewRuleStrategy(&registered)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// NewRuleStrategy compiles a rule strategy into an executable strategy. Each call
+// returns an instance with its own indicator and rule state.
+func NewRuleStrategy(definition *models.RuleStrategy) (BacktestStrategy, error) {
+	if definition == nil {
+		return nil, errors.New("rule strategy is required")
+	}
+
+	if err := definition.Validate(); err != nil {
+		return nil, err
+	}
+
+	strategy := &ruleStrategy{
+		definition: *definition,
+		location:   time.UTC,
+		indicators: make(map[string]*ruleIndicator),
+		last:       make(map[string]models.MarketDataSnapshot),
+		pending:    make(map[string]bool),
+	}
+
+	if definition.Timezone != "" {
+		location, err := time.LoadLocation(definition.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone: %w", err)
+		}
+		strategy.location = location
+	}
+
+	for name, option := range definition.Options {
+		if option.Symbol == "" {
+			return nil, fmt.Errorf("option %s: symbol is required", name)
+		}
+		if option.OptionType != "CE" && option.OptionType != "PE" {
+			return nil, fmt.Errorf("option %s: option type must be CE or PE", name)
+		}
+		if option.Strike <= 0 || option.Expiry.IsZero() {
+			return nil, fmt.Errorf("option %s: strike and expiry are required", name)
+		}
+	}
+
+	for name, indicator := range definition.Indicators {
+		compiled, err := strategy.compileIndicator(indicator)
+		if err != nil {
+			return nil, fmt.Errorf("indicator %s: %w", name, err)
+		}
+		strategy.indicators[name] = compiled
+	}
+
+	traded := make(map[string]bool)
+	for _, rules := range []struct {
+		kind     string
+		rules    []models.TradingRule
+		compiled *[]*compiledRule
+	}{
+		{"entry", definition.Entry, &strategy.entry},
+		{"exit", definition.Exit, &strategy.exit},
+	} {
+		for i, rule := range rules.rules {
+			compiled, err := strategy.compileRule(rule)
+			if err != nil {
+				return nil, fmt.Errorf("%s rule %d: %w", rules.kind, i+1, err)
+			}
+			*rules.compiled = append(*rules.compiled, compiled)
+
+			for _, order := range compiled.orders {
+				traded[order.Symbol] = true
+			}
+		}
+	}
+
+	for symbol := range traded {
+		strategy.traded = append(strategy.traded, symbol)
+	}
+	sort.Strings(strategy.traded)
+
+	return strategy, nil
+}
+
+// ruleValue evaluates an operand, reporting false while it has no value yet
+type ruleValue func() (float64, bool)
+
+// ruleCondition is a compiled RuleCondition
+type ruleCondition struct {
+	all   []*ruleCondition
+	any   []*ruleCondition
+	not   *ruleCondition
+	left  ruleValue
+	op    string
+	right ruleValue
+
+	// Operand values at the previous evaluation, for crossovers
+	previousLeft  float64
+	previousRight float64
+	hasPrevious   bool
+}
+
+// evaluate evaluates the condition. Every branch is evaluated, without short
+// circuiting, so crossovers are always measured between consecutive events.
+func (c *ruleCondition) evaluate() bool {
+	switch {
+	case c.all != nil:
+		result := true
+		for _, condition := range c.all {
+			if !condition.evaluate() {
+				result = false
+			}
+		}
+		return result
+	case c.any != nil:
+		result := false
+		for _, condition := range c.any {
+			if condition.evaluate() {
+				result = true
+			}
+		}
+		return result
+	case c.not != nil:
+		return !c.not.evaluate()
+	}
+
+	left, leftOK := c.left()
+	right, rightOK := c.right()
+	if !leftOK || !rightOK {
+		c.hasPrevious = false
+		return false
+	}
+
+	previousLeft, previousRight, hasPrevious := c.previousLeft, c.previousRight, c.hasPrevious
+	c.previousLeft, c.previousRight, c.hasPrevious = left, right, true
+
+	switch c.op {
+	case ">":
+		return left > right
+	case ">=":
+		return left >= right
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "crossesAbove":
+		return hasPrevious && previousLeft <= previousRight && left > right
+	case "crossesBelow":
+		return hasPrevious && previousLeft >= previousRight && left < right
+	}
+
+	return false
+}
+
+// compiledRule is a compiled TradingRule
+type compiledRule struct {
+	condition *ruleCondition
+	orders    []models.Order
+}
+
+// ruleStrategy runs a compiled rule strategy
+type ruleStrategy struct {
+	definition models.RuleStrategy
+	location   *time.Location
+	indicators map[string]*ruleIndicator
+	entry      []*compiledRule
+	exit       []*compiledRule
+	traded     []string // Symbols the rules place orders in, sorted
+
+	ctx         BacktestStrategyContext
+	last        map[string]models.MarketDataSnapshot
+	pending     map[string]bool
+	open        bool
+	entries     int
+	entryEquity float64
+}
+
+// compileRule compiles a rule's condition and orders
+func (s *ruleStrategy) compileRule(rule models.TradingRule) (*compiledRule, error) {
+	condition, err := s.compileCondition(rule.When)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := &compiledRule{condition: condition}
+	for _, action := range rule.Actions {
+		if action.Direction != models.OrderDirectionBuy && action.Direction != models.OrderDirectionSell {
+			return nil, errors.New("action direction must be BUY or SELL")
+		}
+		if action.Quantity <= 0 {
+			return nil, errors.New("action quantity must be greater than zero")
+		}
+
+		compiled.orders = append(compiled.orders, models.Order{
+			Symbol:    s.resolveSymbol(action.Symbol),
+			Direction: action.Direction,
+			OrderType: models.OrderTypeMarket,
+			Quantity:  action.Quantity,
+		})
+	}
+
+	return compiled, nil
+}
+
+// compileCondition compiles a condition tree
+func (s *ruleStrategy) compileCondition(condition models.RuleCondition) (*ruleCondition, error) {
+	compiled := &ruleCondition{}
+	kinds := 0
+
+	if len(condition.All) > 0 {
+		kinds++
+		for _, child := range condition.All {
+			childCondition, err := s.compileCondition(child)
+			if err != nil {
+				return nil, err
+			}
+			compiled.all = append(compiled.all, childCondition)
+		}
+	}
+
+	if len(condition.Any) > 0 {
+		kinds++
+		for _, child := range condition.Any {
+			childCondition, err := s.compileCondition(child)
+			if err != nil {
+				return nil, err
+			}
+			compiled.any = append(compiled.any, childCondition)
+		}
+	}
+
+	if condition.Not != nil {
+		kinds++
+		childCondition, err := s.compileCondition(*condition.Not)
+		if err != nil {
+			return nil, err
+		}
+		compiled.not = childCondition
+	}
+
+	if condition.Op != "" || condition.Left != "" || condition.Right != "" {
+		kinds++
+		if !ruleOperators[condition.Op] {
+			return nil, errors.New("unsupported operator: " + condition.Op)
+		}
+
+		var err error
+		if compiled.left, err = s.compileOperand(condition.Left); err != nil {
+			return nil, err
+		}
+		if compiled.right, err = s.compileOperand(condition.Right); err != nil {
+			return nil, err
+		}
+		compiled.op = condition.Op
+	}
+
+	if kinds != 1 {
+		return nil, errors.New("a condition needs exactly one of all, any, not or a comparison")
+	}
+
+	return compiled, nil
+}
+
+// compileOperand compiles an operand into a function returning its current value
+func (s *ruleStrategy) compileOperand(operand models.RuleOperand) (ruleValue, error) {
+	text := strings.TrimSpace(string(operand))
+	if text == "" {
+		return nil, errors.New("operand is required")
+	}
+
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		return func() (float64, bool) { return number, true }, nil
+	}
+
+	// Clock times compare against "time" as minutes after midnight
+	if clock, err := time.Parse("15:04", text); err == nil {
+		minutes := float64(clock.Hour()*60 + clock.Minute())
+		return func() (float64, bool) { return minutes, true }, nil
+	}
+
+	match := ruleOperandPattern.FindStringSubmatch(text)
+	if match == nil {
+		return nil, errors.New("invalid operand: " + text)
+	}
+	name, hasArgument, argument := match[1], match[2] != "", strings.TrimSpace(match[3])
+
+	if indicator, exists := s.indicators[name]; exists {
+		if !hasArgument {
+			return indicator.indicator.Value, nil
+		}
+		return indicator.line(argument)
+	}
+
+	switch name {
+	case "open", "high", "low", "close", "volume", "bid", "ask":
+		symbol := s.resolveSymbol(argument)
+		field := name
+		return func() (float64, bool) {
+			snapshot, exists := s.last[symbol]
+			if !exists {
+				return 0, false
+			}
+			return snapshotField(snapshot, field), true
+		}, nil
+
+	case "time":
+		if hasArgument {
+			return nil, errors.New("time takes no argument")
+		}
+		return func() (float64, bool) {
+			now := s.ctx.Now().In(s.location)
+			return float64(now.Hour()*60 + now.Minute()), true
+		}, nil
+
+	case "position":
+		symbol := s.resolveSymbol(argument)
+		return func() (float64, bool) {
+			return float64(s.ctx.Position(symbol)), true
+		}, nil
+
+	case "param":
+		if argument == "" {
+			return nil, errors.New("param needs a parameter name")
+		}
+		return func() (float64, bool) {
+			return toFloat(s.ctx.Parameters()[argument])
+		}, nil
+
+	case "delta", "gamma", "theta", "vega", "iv":
+		option, exists := s.definition.Options[argument]
+		if !exists {
+			return nil, errors.New("unknown option: " + argument)
+		}
+		greek := name
+		return func() (float64, bool) {
+			greeks, ok := s.optionGreeks(option)
+			if !ok {
+				return 0, false
+			}
+			return greeks[greek], true
+		}, nil
+	}
+
+	return nil, errors.New("unknown operand: " + text)
+}
+
+// compileIndicator validates an indicator declaration
+func (s *ruleStrategy) compileIndicator(indicator models.RuleIndicator) (*ruleIndicator, error) {
+	spec := indicators.Spec{
+		Type:       indicators.Type(indicator.Type),
+		Period:     indicator.Period,
+		Source:     indicators.Source(indicator.Source),
+		Fast:       indicator.Fast,
+		Slow:       indicator.Slow,
+		Signal:     indicator.Signal,
+		Multiplier: indicator.Multiplier,
+	}
+
+	compiled, err := indicators.New(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ruleIndicator{
+		symbol:    s.resolveSymbol(indicator.Symbol),
+		spec:      spec,
+		indicator: compiled,
+	}, nil
+}
+
+// resolveSymbol maps an option name to its contract symbol and an empty symbol to the strategy symbol
+func (s *ruleStrategy) resolveSymbol(symbol string) string {
+	if symbol == "" {
+		return s.definition.Symbol
+	}
+
+	if option, exists := s.definition.Options[symbol]; exists {
+		return option.Symbol
+	}
+
+	return symbol
+}
+
+// OnInit implements BacktestStrategy
+func (s *ruleStrategy) OnInit(ctx BacktestStrategyContext) error {
+	s.ctx = ctx
+	return nil
+}
+
+// OnTick implements BacktestStrategy
+func (s *ruleStrategy) OnTick(ctx BacktestStrategyContext, tick models.MarketDataSnapshot) error {
+	return s.onMarketData(ctx, tick)
+}
+
+// OnCandle implements BacktestStrategy
+func (s *ruleStrategy) OnCandle(ctx BacktestStrategyContext, candle models.MarketDataSnapshot) error {
+	return s.onMarketData(ctx, candle)
+}
+
+// OnFill implements BacktestStrategy
+func (s *ruleStrategy) OnFill(ctx BacktestStrategyContext, fill models.SimulationOrder) error {
+	if fill.Status != models.OrderStatusPartial {
+		delete(s.pending, fill.ID)
+	}
+
+	// The position is closed once nothing is in flight and every traded symbol is flat
+	if s.open && len(s.pending) == 0 && s.flat() {
+		s.open = false
+	}
+
+	return nil
+}
+
+// onMarketData updates prices and indicators and then acts on the rules
+func (s *ruleStrategy) onMarketData(ctx BacktestStrategyContext, snapshot models.MarketDataSnapshot) error {
+	s.ctx = ctx
+	s.last[snapshot.Symbol] = snapshot
+
+	for _, indicator := range s.indicators {
+		if indicator.symbol == snapshot.Symbol {
+			indicator.update(snapshot)
+		}
+	}
+
+	entry := firstMatchingRule(s.entry)
+	exit := firstMatchingRule(s.exit)
+
+	// Wait for orders in flight before acting again
+	if len(s.pending) > 0 {
+		return nil
+	}
+
+	if !s.open {
+		if entry == nil || (s.definition.MaxEntries > 0 && s.entries >= s.definition.MaxEntries) {
+			return nil
+		}
+
+		s.open = true
+		s.entries++
+		s.entryEquity = ctx.Equity()
+		return s.submit(entry.orders)
+	}
+
+	pnl := ctx.Equity() - s.entryEquity
+	if (s.definition.StopLoss > 0 && pnl <= -s.definition.StopLoss) || (s.definition.Target > 0 && pnl >= s.definition.Target) {
+		return s.closePositions()
+	}
+
+	if exit == nil {
+		return nil
+	}
+
+	if len(exit.orders) == 0 {
+		return s.closePositions()
+	}
+
+	return s.submit(exit.orders)
+}
+
+// firstMatchingRule evaluates every rule and returns the first whose condition holds
+func firstMatchingRule(rules []*compiledRule) *compiledRule {
+	var matched *compiledRule
+	for _, rule := range rules {
+		if rule.condition.evaluate() && matched == nil {
+			matched = rule
+		}
+	}
+	return matched
+}
+
+// submit places orders and tracks them until they are filled
+func (s *ruleStrategy) submit(orders []models.Order) error {
+	for _, order := range orders {
+		orderID, err := s.ctx.SubmitOrder(order)
+		if err != nil {
+			return err
+		}
+		s.pending[orderID] = true
+	}
+	return nil
+}
+
+// closePositions places market orders flattening every traded symbol
+func (s *ruleStrategy) closePositions() error {
+	var orders []models.Order
+	for _, symbol := range s.traded {
+		quantity := s.ctx.Position(symbol)
+		if quantity == 0 {
+			continue
+		}
+
+		direction := models.OrderDirectionSell
+		if quantity < 0 {
+			direction = models.OrderDirectionBuy
+			quantity = -quantity
+		}
+
+		orders = append(orders, models.Order{
+			Symbol:    symbol,
+			Direction: direction,
+			OrderType: models.OrderTypeMarket,
+			Quantity:  quantity,
+		})
+	}
+
+	if len(orders) == 0 {
+		s.open = false
+		return nil
+	}
+
+	return s.submit(orders)
+}
+
+// flat reports whether every traded symbol is flat
+func (s *ruleStrategy) flat() bool {
+	for _, symbol := range s.traded {
+		if s.ctx.Position(symbol) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// optionGreeks computes an option's greeks from the volatility implied by its last price.
+// Theta is per calendar day, vega per volatility point and iv in percent.
+func (s *ruleStrategy) optionGreeks(option models.RuleOption) (map[string]float64, bool) {
+	underlying, exists := s.last[s.resolveSymbol(option.Underlying)]
+	if !exists {
+		return nil, false
+	}
+
+	contract, exists := s.last[option.Symbol]
+	if !exists {
+		return nil, false
+	}
+
+	years := option.Expiry.Sub(s.ctx.Now()).Hours() / 24 / 365
+	if years <= 0 || underlying.Close <= 0 || contract.Close <= 0 {
+		return nil, false
+	}
+
+	isCall := option.OptionType == "CE"
+	volatility, ok := impliedVolatility(contract.Close, underlying.Close, option.Strike, years, option.RiskFreeRate, isCall)
+	if !ok {
+		return nil, false
+	}
+
+	_, delta, gamma, theta, vega := blackScholes(underlying.Close, option.Strike, years, option.RiskFreeRate, volatility, isCall)
+	return map[string]float64{
+		"delta": delta,
+		"gamma": gamma,
+		"theta": theta / 365,
+		"vega":  vega / 100,
+		"iv":    volatility * 100,
+	}, true
+}
+
+// blackScholes prices a European option and returns its greeks, with theta per year
+// and vega per unit of volatility
+func blackScholes(spot, strike, years, rate, volatility float64, isCall bool) (price, delta, gamma, theta, vega float64) {
+	sqrtYears := math.Sqrt(years)
+	d1 := (math.Log(spot/strike) + (rate+volatility*volatility/2)*years) / (volatility * sqrtYears)
+	d2 := d1 - volatility*sqrtYears
+	discount := math.Exp(-rate * years)
+	density := math.Exp(-d1*d1/2) / math.Sqrt(2*math.Pi)
+
+	gamma = density / (spot * volatility * sqrtYears)
+	vega = spot * density * sqrtYears
+
+	if isCall {
+		price = spot*normalCDF(d1) - strike*discount*normalCDF(d2)
+		delta = normalCDF(d1)
+		theta = -spot*density*volatility/(2*sqrtYears) - rate*strike*discount*normalCDF(d2)
+	} else {
+		price = strike*discount*normalCDF(-d2) - spot*normalCDF(-d1)
+		delta = normalCDF(d1) - 1
+		theta = -spot*density*volatility/(2*sqrtYears) + rate*strike*discount*normalCDF(-d2)
+	}
+
+	return price, delta, gamma, theta, vega
+}
+
+// impliedVolatility finds the volatility at which Black-Scholes gives price by bisection
+func impliedVolatility(price, spot, strike, years, rate float64, isCall bool) (float64, bool) {
+	low, high := 0.0001, 5.0
+
+	lowPrice, _, _, _, _ := blackScholes(spot, strike, years, rate, low, isCall)
+	highPrice, _, _, _, _ := blackScholes(spot, strike, years, rate, high, isCall)
+	if price < lowPrice || price > highPrice {
+		return 0, false
+	}
+
+	for i := 0; i < 100; i++ {
+		middle := (low + high) / 2
+		middlePrice, _, _, _, _ := blackScholes(spot, strike, years, rate, middle, isCall)
+		if middlePrice < price {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+
+	return (low + high) / 2, true
+}
+
+// normalCDF is the standard normal cumulative distribution function
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// snapshotField returns a named price field of a snapshot
+func snapshotField(snapshot models.MarketDataSnapshot, field string) float64 {
+	switch field {
+	case "open":
+		return snapshot.Open
+	case "high":
+		return snapshot.High
+	case "low":
+		return snapshot.Low
+	case "volume":
+		return float64(snapshot.Volume)
+	case "bid":
+		return snapshot.Bid
+	case "ask":
+		return snapshot.Ask
+	}
+	return snapshot.Close
+}
+
+// ruleIndicator computes an indicator on a symbol's prices as they arrive
+type ruleIndicator struct {
+	symbol    string
+	spec      indicators.Spec
+	indicator indicators.Indicator
+}
+
+// update adds a price to the indicator
+func (i *ruleIndicator) update(snapshot models.MarketDataSnapshot) {
+	i.indicator.Update(indicators.Candle{
+		Time:   snapshot.Timestamp,
+		Open:   snapshot.Open,
+		High:   snapshot.High,
+		Low:    snapshot.Low,
+		Close:  snapshot.Close,
+		Volume: float64(snapshot.Volume),
+	})
+}
+
+// line returns a function returning one of the indicator's lines once it has seen
+// enough prices
+func (i *ruleIndicator) line(name string) (ruleValue, error) {
+	for _, line := range indicators.Lines(i.spec.Type) {
+		if line == name {
+			return func() (float64, bool) { return i.indicator.Line(name) }, nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no line %s", i.spec, name)
+}
@@ -0,0 +1,58 @@
+package squareoff
+
+import (
+	"sync"
+	"time"
+)
+
+// allExchanges is the exchange key of holidays observed by every exchange
+const allExchanges = ""
+
+// HolidayCalendar knows the days exchanges are closed. Weekends are always closed,
+// holidays are added per exchange or for every exchange.
+type HolidayCalendar struct {
+	holidays map[string]map[string]string // Exchange to date to holiday name
+	mutex    sync.RWMutex
+}
+
+// NewHolidayCalendar creates a new HolidayCalendar with no holidays
+func NewHolidayCalendar() *HolidayCalendar {
+	return &HolidayCalendar{
+		holidays: make(map[string]map[string]string),
+	}
+}
+
+// AddHoliday marks a date as a holiday of an exchange, or of every exchange when
+// exchange is empty
+func (c *HolidayCalendar) AddHoliday(exchange string, date time.Time, name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.holidays[exchange] == nil {
+		c.holidays[exchange] = make(map[string]string)
+	}
+	c.holidays[exchange][date.Format("2006-01-02")] = name
+}
+
+// Holiday returns the name of the holiday an exchange observes on a date
+func (c *HolidayCalendar) Holiday(exchange string, date time.Time) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	day := date.Format("2006-01-02")
+	if name, exists := c.holidays[exchange][day]; exists {
+		return name, true
+	}
+	name, exists := c.holidays[allExchanges][day]
+	return name, exists
+}
+
+// IsTradingDay checks if an exchange is open on a date
+func (c *HolidayCalendar) IsTradingDay(exchange string, date time.Time) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+
+	_, holiday := c.Holiday(exchange, date)
+	return !holiday
+}
@@ -0,0 +1,497 @@
+package squareoff
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// DefaultExchangeCutoffs are the times MIS positions are exited by on each exchange,
+// a few minutes ahead of the brokers' own square-off
+var DefaultExchangeCutoffs = map[string]string{
+	"NSE": "15:15:00",
+	"BSE": "15:15:00",
+	"NFO": "15:15:00",
+	"BFO": "15:15:00",
+	"CDS": "16:40:00",
+	"MCX": "23:20:00",
+}
+
+// DefaultInterval is how often the scheduler checks for positions to square off
+const DefaultInterval = 30 * time.Second
+
+// pageSize is the number of positions fetched at a time
+const pageSize = 100
+
+// clockFormat is the format of square-off times
+const clockFormat = "15:04:05"
+
+// CutoffSource represents which setting a square-off time came from
+type CutoffSource string
+
+const (
+	CutoffSourcePortfolio CutoffSource = "PORTFOLIO"
+	CutoffSourceUser      CutoffSource = "USER"
+	CutoffSourceExchange  CutoffSource = "EXCHANGE"
+)
+
+// PortfolioProvider looks up the portfolio a position belongs to
+type PortfolioProvider interface {
+	GetByID(id string) (*models.Portfolio, error)
+}
+
+// PreferencesProvider looks up the preferences of a user
+type PreferencesProvider interface {
+	GetUserPreferences(userID string) (*models.UserPreferences, error)
+}
+
+// QuoteProvider looks up the last traded price of an instrument, used to price
+// limit exits
+type QuoteProvider interface {
+	GetLastPrice(symbol, exchange string) (float64, error)
+}
+
+// Config configures the square-off scheduler
+type Config struct {
+	Location        *time.Location    // Time zone of the square-off times, defaults to the local time zone
+	ExchangeCutoffs map[string]string // HH:MM:SS by exchange, defaults to DefaultExchangeCutoffs
+	Interval        time.Duration     // Between runs, defaults to DefaultInterval
+}
+
+// Exit is a position squared off by the scheduler
+type Exit struct {
+	PositionID  string           `json:"positionId"`
+	UserID      string           `json:"userId"`
+	PortfolioID string           `json:"portfolioId,omitempty"`
+	OrderID     string           `json:"orderId"`
+	Quantity    int              `json:"quantity"`
+	OrderType   models.OrderType `json:"orderType"`
+	Price       float64          `json:"price,omitempty"`
+	Cutoff      string           `json:"cutoff"`
+	Source      CutoffSource     `json:"source"`
+	Attempts    int              `json:"attempts"`
+}
+
+// RunResult is the outcome of one run of the scheduler
+type RunResult struct {
+	Time     time.Time         `json:"time"`
+	Exits    []Exit            `json:"exits"`
+	Holidays map[string]string `json:"holidays,omitempty"` // Exchanges closed on the day, skipped
+	Errors   []string          `json:"errors,omitempty"`
+}
+
+// SquareOffScheduler exits open MIS positions once their square-off time has
+// passed. A position's square-off time is the earliest of its portfolio's
+// square-off time, or its user's default square-off time when the user has auto
+// square-off on and the portfolio has none, and the cutoff of its exchange.
+// Positions are left alone on days their exchange is closed.
+type SquareOffScheduler struct {
+	orderService    services.OrderService
+	positionService position.PositionService
+	portfolios      PortfolioProvider
+	preferences     PreferencesProvider
+	quotes          QuoteProvider
+	calendar        *HolidayCalendar
+	config          Config
+	squaredOff      map[string]string // Position ID to the date it was squared off
+	stop            chan struct{}
+	sleep           func(time.Duration)
+	mutex           sync.Mutex
+}
+
+// NewSquareOffScheduler creates a new SquareOffScheduler. Without a calendar only
+// weekends are treated as closed.
+func NewSquareOffScheduler(
+	orderService services.OrderService,
+	positionService position.PositionService,
+	portfolios PortfolioProvider,
+	preferences PreferencesProvider,
+	calendar *HolidayCalendar,
+	config Config,
+) *SquareOffScheduler {
+	if calendar == nil {
+		calendar = NewHolidayCalendar()
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.ExchangeCutoffs == nil {
+		config.ExchangeCutoffs = DefaultExchangeCutoffs
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+
+	return &SquareOffScheduler{
+		orderService:    orderService,
+		positionService: positionService,
+		portfolios:      portfolios,
+		preferences:     preferences,
+		calendar:        calendar,
+		config:          config,
+		squaredOff:      make(map[string]string),
+		sleep:           time.Sleep,
+	}
+}
+
+// SetQuoteProvider sets the quotes limit exits are priced from. Without one every
+// exit is placed at market.
+func (s *SquareOffScheduler) SetQuoteProvider(quotes QuoteProvider) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.quotes = quotes
+}
+
+// Start runs the scheduler every interval until it is stopped
+func (s *SquareOffScheduler) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stop != nil {
+		return errors.New("square-off scheduler is already running")
+	}
+	s.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := s.Run(now)
+				if err != nil {
+					log.Printf("Error running square-off scheduler: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Square-off error: %s", message)
+				}
+			}
+		}
+	}(s.stop)
+
+	return nil
+}
+
+// Stop stops the scheduler
+func (s *SquareOffScheduler) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// Run squares off the positions whose square-off time has passed at now. A
+// position is squared off at most once a day, its exit order taking it out of the
+// open positions once filled.
+func (s *SquareOffScheduler) Run(now time.Time) (*RunResult, error) {
+	now = now.In(s.config.Location)
+	date := now.Format("2006-01-02")
+	clock := now.Format(clockFormat)
+	result := &RunResult{
+		Time: now,
+	}
+
+	positions, err := s.openPositions()
+	if err != nil {
+		return nil, err
+	}
+
+	// Portfolios and preferences are looked up once a run
+	portfolios := make(map[string]*models.Portfolio)
+	preferences := make(map[string]*models.UserPreferences)
+
+	for _, openPosition := range positions {
+		s.mutex.Lock()
+		done := s.squaredOff[openPosition.ID] == date
+		s.mutex.Unlock()
+		if done {
+			continue
+		}
+
+		if !s.calendar.IsTradingDay(openPosition.Exchange, now) {
+			if result.Holidays == nil {
+				result.Holidays = make(map[string]string)
+			}
+			name, _ := s.calendar.Holiday(openPosition.Exchange, now)
+			result.Holidays[openPosition.Exchange] = name
+			continue
+		}
+
+		var portfolio *models.Portfolio
+		if openPosition.PortfolioID != "" {
+			cached, exists := portfolios[openPosition.PortfolioID]
+			if !exists {
+				cached, err = s.portfolios.GetByID(openPosition.PortfolioID)
+				if err != nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("portfolio %s of position %s: %v", openPosition.PortfolioID, openPosition.ID, err))
+				}
+				portfolios[openPosition.PortfolioID] = cached
+			}
+			portfolio = cached
+		}
+
+		var userPreferences *models.UserPreferences
+		if portfolio == nil || !validClock(portfolio.SquareOffTime) {
+			cached, exists := preferences[openPosition.UserID]
+			if !exists {
+				cached, err = s.preferences.GetUserPreferences(openPosition.UserID)
+				if err != nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("preferences of user %s: %v", openPosition.UserID, err))
+				}
+				preferences[openPosition.UserID] = cached
+			}
+			userPreferences = cached
+		}
+
+		cutoff, source := s.cutoff(openPosition, portfolio, userPreferences)
+		if cutoff == "" || clock < cutoff {
+			continue
+		}
+
+		exit, err := s.exitPosition(openPosition, portfolio)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("square-off of position %s: %v", openPosition.ID, err))
+			continue
+		}
+
+		s.mutex.Lock()
+		s.squaredOff[openPosition.ID] = date
+		s.mutex.Unlock()
+
+		if exit != nil {
+			exit.Cutoff = cutoff
+			exit.Source = source
+			result.Exits = append(result.Exits, *exit)
+			log.Printf("Squared off position %s of user %s with order %s at %s cutoff %s", exit.PositionID, exit.UserID, exit.OrderID, source, cutoff)
+		}
+	}
+
+	return result, nil
+}
+
+// ExitPositions exits what is left of positions right away with their portfolios'
+// exit settings, as when a strategy is halted, returning the IDs of the exit orders
+// placed and why the others failed
+func (s *SquareOffScheduler) ExitPositions(positions []models.Position) ([]string, []string) {
+	var orderIDs []string
+	var errs []string
+
+	portfolios := make(map[string]*models.Portfolio)
+	for _, openPosition := range positions {
+		var portfolio *models.Portfolio
+		if openPosition.PortfolioID != "" {
+			cached, exists := portfolios[openPosition.PortfolioID]
+			if !exists {
+				var err error
+				cached, err = s.portfolios.GetByID(openPosition.PortfolioID)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("portfolio %s of position %s: %v", openPosition.PortfolioID, openPosition.ID, err))
+				}
+				portfolios[openPosition.PortfolioID] = cached
+			}
+			portfolio = cached
+		}
+
+		exit, err := s.exitPosition(openPosition, portfolio)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("exit of position %s: %v", openPosition.ID, err))
+			continue
+		}
+		if exit != nil {
+			orderIDs = append(orderIDs, exit.OrderID)
+			log.Printf("Exited position %s of user %s with order %s", exit.PositionID, exit.UserID, exit.OrderID)
+		}
+	}
+
+	return orderIDs, errs
+}
+
+// cutoff returns the square-off time of a position and where it came from, or an
+// empty time when the position has none
+func (s *SquareOffScheduler) cutoff(openPosition models.Position, portfolio *models.Portfolio, userPreferences *models.UserPreferences) (string, CutoffSource) {
+	var cutoff string
+	var source CutoffSource
+
+	if portfolio != nil && validClock(portfolio.SquareOffTime) {
+		cutoff, source = normalizeClock(portfolio.SquareOffTime), CutoffSourcePortfolio
+	} else if userPreferences != nil && userPreferences.AutoSquareOff && validClock(userPreferences.DefaultSquareOffTime) {
+		cutoff, source = normalizeClock(userPreferences.DefaultSquareOffTime), CutoffSourceUser
+	}
+
+	// The exchange's cutoff applies whatever was configured
+	if exchangeCutoff, exists := s.config.ExchangeCutoffs[openPosition.Exchange]; exists && validClock(exchangeCutoff) {
+		exchangeCutoff = normalizeClock(exchangeCutoff)
+		if cutoff == "" || exchangeCutoff < cutoff {
+			cutoff, source = exchangeCutoff, CutoffSourceExchange
+		}
+	}
+
+	return cutoff, source
+}
+
+// exitPosition places the order closing what is left of a position using its
+// portfolio's exit settings: a limit exit priced off the last price with the exit
+// buffer when quotes are available, retried up to the maximum exit retries and
+// converted to market at the end if the portfolio allows. Positions outside a
+// portfolio are exited at market. It returns no exit when nothing is left.
+func (s *SquareOffScheduler) exitPosition(openPosition models.Position, portfolio *models.Portfolio) (*Exit, error) {
+	quantity := openPosition.Quantity - openPosition.ExitQuantity
+	if quantity <= 0 {
+		return nil, nil
+	}
+
+	direction := models.OrderDirectionSell
+	if openPosition.Direction == models.PositionDirectionShort {
+		direction = models.OrderDirectionBuy
+	}
+
+	order := models.Order{
+		UserID:         openPosition.UserID,
+		Symbol:         openPosition.Symbol,
+		Exchange:       openPosition.Exchange,
+		OrderType:      models.OrderTypeMarket,
+		Direction:      direction,
+		Quantity:       quantity,
+		ProductType:    openPosition.ProductType,
+		InstrumentType: openPosition.InstrumentType,
+		OptionType:     openPosition.OptionType,
+		StrikePrice:    openPosition.StrikePrice,
+		Expiry:         openPosition.Expiry,
+		PortfolioID:    openPosition.PortfolioID,
+		StrategyID:     openPosition.StrategyID,
+		Tags:           []string{"square_off"},
+		Notes:          "automatic square-off",
+	}
+
+	retries := 0
+	var interval time.Duration
+	if portfolio != nil {
+		if portfolio.MaxExitRetries > 0 {
+			retries = portfolio.MaxExitRetries
+		}
+		interval = time.Duration(portfolio.ExitRetryInterval) * time.Second
+	}
+
+	var lastErr error
+	attempts := 0
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			s.sleep(interval)
+		}
+		attempts++
+
+		exitOrder := order
+		if portfolio != nil && portfolio.ExitOrderType == models.OrderTypeLimit {
+			if price, ok := s.exitPrice(openPosition, direction, portfolio.ExitPriceBuffer); ok {
+				exitOrder.OrderType = models.OrderTypeLimit
+				exitOrder.Price = price
+			}
+		}
+
+		created, err := s.orderService.CreateOrder(&exitOrder)
+		if err == nil {
+			return newExit(openPosition, created.ID, &exitOrder, attempts), nil
+		}
+		lastErr = err
+		log.Printf("Square-off of position %s failed, attempt %d: %v", openPosition.ID, attempts, err)
+
+		// Out of retries, a limit exit gets one last attempt at market
+		if attempt == retries && exitOrder.OrderType == models.OrderTypeLimit && portfolio.ConvertToMarket {
+			attempts++
+			exitOrder = order
+			created, err = s.orderService.CreateOrder(&exitOrder)
+			if err == nil {
+				return newExit(openPosition, created.ID, &exitOrder, attempts), nil
+			}
+			lastErr = err
+		}
+	}
+
+	return nil, fmt.Errorf("%d attempts failed: %w", attempts, lastErr)
+}
+
+// exitPrice returns the limit price of an exit, the last price moved by the exit
+// buffer in the direction of the exit
+func (s *SquareOffScheduler) exitPrice(openPosition models.Position, direction models.OrderDirection, buffer float64) (float64, bool) {
+	s.mutex.Lock()
+	quotes := s.quotes
+	s.mutex.Unlock()
+	if quotes == nil {
+		return 0, false
+	}
+
+	lastPrice, err := quotes.GetLastPrice(openPosition.Symbol, openPosition.Exchange)
+	if err != nil || lastPrice <= 0 {
+		log.Printf("No price for square-off of position %s, exiting at market: %v", openPosition.ID, err)
+		return 0, false
+	}
+
+	if direction == models.OrderDirectionBuy {
+		return math.Round(lastPrice*(1+buffer/100)*100) / 100, true
+	}
+	return math.Round(lastPrice*(1-buffer/100)*100) / 100, true
+}
+
+// openPositions returns the open and partially closed MIS positions
+func (s *SquareOffScheduler) openPositions() ([]models.Position, error) {
+	var positions []models.Position
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial} {
+		filter := models.PositionFilter{
+			Status:      status,
+			ProductType: models.ProductTypeMIS,
+		}
+
+		for page := 1; ; page++ {
+			batch, total, err := s.positionService.GetPositions(filter, page, pageSize)
+			if err != nil {
+				return positions, err
+			}
+			positions = append(positions, batch...)
+			if len(batch) == 0 || page*pageSize >= total {
+				break
+			}
+		}
+	}
+
+	return positions, nil
+}
+
+// newExit creates the record of a position's exit order
+func newExit(openPosition models.Position, orderID string, order *models.Order, attempts int) *Exit {
+	return &Exit{
+		PositionID:  openPosition.ID,
+		UserID:      openPosition.UserID,
+		PortfolioID: openPosition.PortfolioID,
+		OrderID:     orderID,
+		Quantity:    order.Quantity,
+		OrderType:   order.OrderType,
+		Price:       order.Price,
+		Attempts:    attempts,
+	}
+}
+
+// validClock checks if a square-off time is a valid HH:MM:SS
+func validClock(clock string) bool {
+	_, err := time.Parse(clockFormat, clock)
+	return err == nil
+}
+
+// normalizeClock zero-pads a square-off time so times compare as strings
+func normalizeClock(clock string) string {
+	parsed, _ := time.Parse(clockFormat, clock)
+	return parsed.Format(clockFormat)
+}
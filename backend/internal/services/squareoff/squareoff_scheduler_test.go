@@ -0,0 +1,360 @@
+package squareoff
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockOrderService is a mock implementation of the OrderService interface
+type MockOrderService struct {
+	mock.Mock
+}
+
+func (m *MockOrderService) CreateOrder(order *models.Order) (*models.Order, error) {
+	args := m.Called(order)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderByID(id string) (*models.Order, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error) {
+	args := m.Called(filter, page, limit)
+	return args.Get(0).([]models.Order), args.Int(1), args.Error(2)
+}
+
+func (m *MockOrderService) UpdateOrder(order *models.Order) (*models.Order, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) CancelOrder(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockOrderService) TransitionOrder(id string, status models.OrderStatus, filledQuantity int, source models.TransitionSource, reason string) (*models.Order, error) {
+	args := m.Called(id, status, filledQuantity, source, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderTimeline(id string) ([]models.OrderTransition, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OrderTransition), args.Error(1)
+}
+
+// MockPositionService is a mock implementation of the PositionService interface
+type MockPositionService struct {
+	mock.Mock
+}
+
+func (m *MockPositionService) CreatePositionFromOrder(order *models.Order) (*models.Position, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositionByID(id string) (*models.Position, error) {
+	args := m.Called(id)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	args := m.Called(filter, page, limit)
+	return args.Get(0).([]models.Position), args.Int(1), args.Error(2)
+}
+
+func (m *MockPositionService) UpdatePosition(position *models.Position) (*models.Position, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) ClosePosition(id string, exitPrice float64, exitQuantity int) (*models.Position, error) {
+	args := m.Called(id, exitPrice, exitQuantity)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) CalculatePnL(position *models.Position) (float64, error) {
+	args := m.Called(position)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateGreeks(position *models.Position) (*models.Greeks, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Greeks), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateExposure(positions []models.Position) (float64, error) {
+	args := m.Called(positions)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) AggregatePositions(positions []models.Position, groupBy string) (map[string]models.AggregatedPosition, error) {
+	args := m.Called(positions, groupBy)
+	return args.Get(0).(map[string]models.AggregatedPosition), args.Error(1)
+}
+
+// MockPortfolioProvider is a mock implementation of the PortfolioProvider interface
+type MockPortfolioProvider struct {
+	mock.Mock
+}
+
+func (m *MockPortfolioProvider) GetByID(id string) (*models.Portfolio, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Portfolio), args.Error(1)
+}
+
+// MockPreferencesProvider is a mock implementation of the PreferencesProvider interface
+type MockPreferencesProvider struct {
+	mock.Mock
+}
+
+func (m *MockPreferencesProvider) GetUserPreferences(userID string) (*models.UserPreferences, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserPreferences), args.Error(1)
+}
+
+// MockQuoteProvider is a mock implementation of the QuoteProvider interface
+type MockQuoteProvider struct {
+	mock.Mock
+}
+
+func (m *MockQuoteProvider) GetLastPrice(symbol, exchange string) (float64, error) {
+	args := m.Called(symbol, exchange)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+var ist = time.FixedZone("IST", 5*3600+1800)
+
+// at returns a time on Wednesday 14 October 2026 in IST
+func at(clock string) time.Time {
+	parsed, _ := time.Parse(clockFormat, clock)
+	return time.Date(2026, time.October, 14, parsed.Hour(), parsed.Minute(), parsed.Second(), 0, ist)
+}
+
+// mockOpenPositions sets up the position service to return positions as the open MIS positions
+func mockOpenPositions(mockPositions *MockPositionService, positions []models.Position) {
+	mockPositions.On("GetPositions", models.PositionFilter{Status: models.PositionStatusOpen, ProductType: models.ProductTypeMIS}, 1, pageSize).Return(positions, len(positions), nil)
+	mockPositions.On("GetPositions", models.PositionFilter{Status: models.PositionStatusPartial, ProductType: models.ProductTypeMIS}, 1, pageSize).Return([]models.Position{}, 0, nil)
+}
+
+func TestRun(t *testing.T) {
+	mockOrders := new(MockOrderService)
+	mockPositions := new(MockPositionService)
+	mockPortfolios := new(MockPortfolioProvider)
+	mockPreferences := new(MockPreferencesProvider)
+	mockQuotes := new(MockQuoteProvider)
+
+	positions := []models.Position{
+		{ID: "position1", UserID: "user1", PortfolioID: "portfolio1", Symbol: "NIFTY24OCTFUT", Exchange: "NFO", Direction: models.PositionDirectionLong, Quantity: 50, ProductType: models.ProductTypeMIS, InstrumentType: models.InstrumentTypeFuture},
+		{ID: "position2", UserID: "user2", Symbol: "RELIANCE", Exchange: "NSE", Direction: models.PositionDirectionShort, Quantity: 10, ExitQuantity: 4, ProductType: models.ProductTypeMIS, InstrumentType: models.InstrumentTypeStock},
+		{ID: "position3", UserID: "user3", Symbol: "INFY", Exchange: "NSE", Direction: models.PositionDirectionLong, Quantity: 20, ProductType: models.ProductTypeMIS, InstrumentType: models.InstrumentTypeStock},
+		{ID: "position4", UserID: "user3", Symbol: "CRUDEOIL24OCTFUT", Exchange: "MCX", Direction: models.PositionDirectionLong, Quantity: 1, ProductType: models.ProductTypeMIS, InstrumentType: models.InstrumentTypeFuture},
+	}
+	mockOpenPositions(mockPositions, positions)
+
+	// The portfolio squares off at 14:30 with limit exits, user2 at 15:00 and user3
+	// has no square-off time of their own
+	mockPortfolios.On("GetByID", "portfolio1").Return(&models.Portfolio{ID: "portfolio1", SquareOffTime: "14:30:00", ExitOrderType: models.OrderTypeLimit, ExitPriceBuffer: 1}, nil)
+	mockPreferences.On("GetUserPreferences", "user2").Return(&models.UserPreferences{AutoSquareOff: true, DefaultSquareOffTime: "15:00:00"}, nil)
+	mockPreferences.On("GetUserPreferences", "user3").Return(&models.UserPreferences{AutoSquareOff: false, DefaultSquareOffTime: "14:00:00"}, nil)
+	mockQuotes.On("GetLastPrice", "NIFTY24OCTFUT", "NFO").Return(22000.0, nil)
+
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "NIFTY24OCTFUT" && order.Direction == models.OrderDirectionSell &&
+			order.OrderType == models.OrderTypeLimit && order.Price == 21780 && order.Quantity == 50
+	})).Return(&models.Order{ID: "exit1"}, nil).Once()
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "RELIANCE" && order.Direction == models.OrderDirectionBuy &&
+			order.OrderType == models.OrderTypeMarket && order.Quantity == 6
+	})).Return(&models.Order{ID: "exit2"}, nil).Once()
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "INFY" && order.Direction == models.OrderDirectionSell
+	})).Return(&models.Order{ID: "exit3"}, nil).Once()
+
+	scheduler := NewSquareOffScheduler(mockOrders, mockPositions, mockPortfolios, mockPreferences, nil, Config{Location: ist})
+	scheduler.SetQuoteProvider(mockQuotes)
+
+	// Test nothing is squared off before the first cutoff
+	result, err := scheduler.Run(at("14:29:59"))
+	assert.NoError(t, err)
+	assert.Empty(t, result.Exits)
+
+	// Test the portfolio's square-off time
+	result, err = scheduler.Run(at("14:30:00"))
+	assert.NoError(t, err)
+	assert.Len(t, result.Exits, 1)
+	assert.Equal(t, "exit1", result.Exits[0].OrderID)
+	assert.Equal(t, CutoffSourcePortfolio, result.Exits[0].Source)
+
+	// Test the user's default square-off time, the portfolio's position only being
+	// squared off once
+	result, err = scheduler.Run(at("15:05:00").UTC())
+	assert.NoError(t, err)
+	assert.Len(t, result.Exits, 1)
+	assert.Equal(t, "exit2", result.Exits[0].OrderID)
+	assert.Equal(t, CutoffSourceUser, result.Exits[0].Source)
+	assert.Equal(t, "15:00:00", result.Exits[0].Cutoff)
+
+	// Test the exchange cutoffs, MCX trading later than NSE
+	result, err = scheduler.Run(at("15:15:00"))
+	assert.NoError(t, err)
+	assert.Len(t, result.Exits, 1)
+	assert.Equal(t, "exit3", result.Exits[0].OrderID)
+	assert.Equal(t, CutoffSourceExchange, result.Exits[0].Source)
+	assert.Empty(t, result.Errors)
+
+	mockOrders.AssertExpectations(t)
+	mockPositions.AssertExpectations(t)
+}
+
+func TestRunHolidays(t *testing.T) {
+	mockOrders := new(MockOrderService)
+	mockPositions := new(MockPositionService)
+	mockPreferences := new(MockPreferencesProvider)
+
+	mockOpenPositions(mockPositions, []models.Position{
+		{ID: "position1", UserID: "user1", Symbol: "GOLD24DECFUT", Exchange: "MCX", Direction: models.PositionDirectionLong, Quantity: 1, ProductType: models.ProductTypeMIS, InstrumentType: models.InstrumentTypeFuture},
+		{ID: "position2", UserID: "user1", Symbol: "INFY", Exchange: "NSE", Direction: models.PositionDirectionLong, Quantity: 20, ProductType: models.ProductTypeMIS, InstrumentType: models.InstrumentTypeStock},
+	})
+	mockPreferences.On("GetUserPreferences", "user1").Return(&models.UserPreferences{AutoSquareOff: true, DefaultSquareOffTime: "15:00:00"}, nil)
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "GOLD24DECFUT"
+	})).Return(&models.Order{ID: "exit1"}, nil).Once()
+
+	// NSE is closed for the day, MCX is open
+	calendar := NewHolidayCalendar()
+	calendar.AddHoliday("NSE", at("00:00:00"), "Dussehra")
+	scheduler := NewSquareOffScheduler(mockOrders, mockPositions, new(MockPortfolioProvider), mockPreferences, calendar, Config{Location: ist})
+
+	result, err := scheduler.Run(at("15:30:00"))
+	assert.NoError(t, err)
+	assert.Len(t, result.Exits, 1)
+	assert.Equal(t, "exit1", result.Exits[0].OrderID)
+	assert.Equal(t, map[string]string{"NSE": "Dussehra"}, result.Holidays)
+
+	// Test nothing is squared off at the weekend
+	result, err = scheduler.Run(at("15:30:00").AddDate(0, 0, 3))
+	assert.NoError(t, err)
+	assert.Empty(t, result.Exits)
+	assert.Len(t, result.Holidays, 2)
+
+	mockOrders.AssertExpectations(t)
+}
+
+func TestRunRetries(t *testing.T) {
+	mockOrders := new(MockOrderService)
+	mockPositions := new(MockPositionService)
+	mockPortfolios := new(MockPortfolioProvider)
+	mockQuotes := new(MockQuoteProvider)
+
+	mockOpenPositions(mockPositions, []models.Position{
+		{ID: "position1", UserID: "user1", PortfolioID: "portfolio1", Symbol: "NIFTY24OCTFUT", Exchange: "NFO", Direction: models.PositionDirectionShort, Quantity: 50, ProductType: models.ProductTypeMIS, InstrumentType: models.InstrumentTypeFuture},
+	})
+	mockPortfolios.On("GetByID", "portfolio1").Return(&models.Portfolio{
+		ID:                "portfolio1",
+		SquareOffTime:     "15:00:00",
+		ExitOrderType:     models.OrderTypeLimit,
+		ExitPriceBuffer:   0.5,
+		MaxExitRetries:    1,
+		ExitRetryInterval: 2,
+		ConvertToMarket:   true,
+	}, nil)
+	mockQuotes.On("GetLastPrice", "NIFTY24OCTFUT", "NFO").Return(22000.0, nil)
+
+	// Limit exits are rejected, the market exit goes through
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.OrderType == models.OrderTypeLimit && order.Price == 22110 && order.Direction == models.OrderDirectionBuy
+	})).Return(nil, errors.New("price outside circuit limits")).Twice()
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.OrderType == models.OrderTypeMarket
+	})).Return(&models.Order{ID: "exit1"}, nil).Once()
+
+	scheduler := NewSquareOffScheduler(mockOrders, mockPositions, mockPortfolios, new(MockPreferencesProvider), nil, Config{Location: ist})
+	scheduler.SetQuoteProvider(mockQuotes)
+	var waits []time.Duration
+	scheduler.sleep = func(d time.Duration) {
+		waits = append(waits, d)
+	}
+
+	result, err := scheduler.Run(at("15:00:00"))
+	assert.NoError(t, err)
+	assert.Len(t, result.Exits, 1)
+	assert.Equal(t, 3, result.Exits[0].Attempts)
+	assert.Equal(t, models.OrderTypeMarket, result.Exits[0].OrderType)
+	assert.Equal(t, []time.Duration{2 * time.Second}, waits)
+
+	mockOrders.AssertExpectations(t)
+}
+
+func TestExitPositions(t *testing.T) {
+	mockOrders := new(MockOrderService)
+	mockPortfolios := new(MockPortfolioProvider)
+	mockQuotes := new(MockQuoteProvider)
+
+	mockPortfolios.On("GetByID", "portfolio1").Return(&models.Portfolio{ID: "portfolio1", ExitOrderType: models.OrderTypeLimit, ExitPriceBuffer: 1}, nil).Once()
+	mockQuotes.On("GetLastPrice", "NIFTY24OCTFUT", "NFO").Return(22000.0, nil)
+	mockQuotes.On("GetLastPrice", "NIFTY24OCT22000CE", "NFO").Return(0.0, errors.New("no quote"))
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "NIFTY24OCTFUT" && order.OrderType == models.OrderTypeLimit && order.Price == 21780
+	})).Return(&models.Order{ID: "exit1"}, nil).Once()
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "NIFTY24OCT22000CE"
+	})).Return(nil, errors.New("broker unavailable"))
+
+	scheduler := NewSquareOffScheduler(mockOrders, new(MockPositionService), mockPortfolios, new(MockPreferencesProvider), nil, Config{Location: ist})
+	scheduler.SetQuoteProvider(mockQuotes)
+
+	// Positions are exited whatever the time with their portfolio's exit settings,
+	// NRML positions included, and nothing is left of closed ones
+	orderIDs, errs := scheduler.ExitPositions([]models.Position{
+		{ID: "position1", UserID: "user1", PortfolioID: "portfolio1", Symbol: "NIFTY24OCTFUT", Exchange: "NFO", Direction: models.PositionDirectionLong, Quantity: 50, ProductType: models.ProductTypeNRML, InstrumentType: models.InstrumentTypeFuture},
+		{ID: "position2", UserID: "user1", PortfolioID: "portfolio1", Symbol: "NIFTY24OCT22000CE", Exchange: "NFO", Direction: models.PositionDirectionShort, Quantity: 50, ProductType: models.ProductTypeNRML, InstrumentType: models.InstrumentTypeOption},
+		{ID: "position3", UserID: "user1", Symbol: "INFY", Exchange: "NSE", Direction: models.PositionDirectionLong, Quantity: 20, ExitQuantity: 20, ProductType: models.ProductTypeCNC, InstrumentType: models.InstrumentTypeStock},
+	})
+	assert.Equal(t, []string{"exit1"}, orderIDs)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "position2")
+
+	mockOrders.AssertExpectations(t)
+	mockPortfolios.AssertExpectations(t)
+}
+
+func TestHolidayCalendar(t *testing.T) {
+	calendar := NewHolidayCalendar()
+	calendar.AddHoliday("", time.Date(2026, time.January, 26, 0, 0, 0, 0, ist), "Republic Day")
+	calendar.AddHoliday("NSE", time.Date(2026, time.October, 14, 0, 0, 0, 0, ist), "Dussehra")
+
+	assert.False(t, calendar.IsTradingDay("MCX", time.Date(2026, time.January, 26, 10, 0, 0, 0, ist)))
+	assert.False(t, calendar.IsTradingDay("NSE", time.Date(2026, time.October, 14, 10, 0, 0, 0, ist)))
+	assert.True(t, calendar.IsTradingDay("MCX", time.Date(2026, time.October, 14, 10, 0, 0, 0, ist)))
+	assert.False(t, calendar.IsTradingDay("NSE", time.Date(2026, time.October, 17, 10, 0, 0, 0, ist)))
+
+	name, holiday := calendar.Holiday("NSE", time.Date(2026, time.January, 26, 10, 0, 0, 0, ist))
+	assert.True(t, holiday)
+	assert.Equal(t, "Republic Day", name)
+}
@@ -0,0 +1,440 @@
+package stoploss
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+const (
+	// DefaultInterval is how often the watchdog checks open positions
+	DefaultInterval = 10 * time.Second
+
+	// DefaultStaleAfter is how long server-side monitoring and the price feed may
+	// go without an update before they are considered failed
+	DefaultStaleAfter = 30 * time.Second
+
+	// DefaultLimitBuffer is how far past the trigger price, as a fraction of it,
+	// failsafe orders are limited to when the portfolio has no exit price buffer
+	DefaultLimitBuffer = 0.05
+)
+
+// pageSize is the number of positions and orders fetched at a time
+const pageSize = 100
+
+// tickSize is the price step failsafe order prices are rounded to
+const tickSize = 0.05
+
+// failsafeTag tags the stop-loss orders the watchdog places
+const failsafeTag = "sl-failsafe"
+
+// ProtectionStatus is how an open position is protected against its stop loss
+type ProtectionStatus string
+
+const (
+	ProtectionStatusProtected   ProtectionStatus = "PROTECTED"   // A live stop-loss order covers the position
+	ProtectionStatusMonitored   ProtectionStatus = "MONITORED"   // Server-side monitoring is healthy
+	ProtectionStatusFailsafe    ProtectionStatus = "FAILSAFE"    // The watchdog placed a stop-loss order
+	ProtectionStatusUnprotected ProtectionStatus = "UNPROTECTED" // Monitoring failed and no order could be placed
+)
+
+// PortfolioProvider looks up portfolios
+type PortfolioProvider interface {
+	GetByID(id string) (*models.Portfolio, error)
+}
+
+// FeedMonitor reports when the price feed last updated an instrument
+type FeedMonitor interface {
+	LastTickTime(symbol, exchange string) (time.Time, error)
+}
+
+// Config configures the watchdog
+type Config struct {
+	Interval    time.Duration // Between runs, defaults to DefaultInterval
+	StaleAfter  time.Duration // Without updates before monitoring is considered failed, defaults to DefaultStaleAfter
+	LimitBuffer float64       // Fraction of the trigger price, defaults to DefaultLimitBuffer
+}
+
+// PositionCheck is the outcome of checking an open position's protection
+type PositionCheck struct {
+	PositionID  string           `json:"positionId"`
+	PortfolioID string           `json:"portfolioId"`
+	UserID      string           `json:"userId"`
+	Symbol      string           `json:"symbol"`
+	Status      ProtectionStatus `json:"status"`
+	StopPrice   float64          `json:"stopPrice,omitempty"`
+	OrderID     string           `json:"orderId,omitempty"` // Of the failsafe order placed
+	Reason      string           `json:"reason,omitempty"`
+}
+
+// RunResult is the outcome of one run of the watchdog
+type RunResult struct {
+	Time   time.Time       `json:"time"`
+	Checks []PositionCheck `json:"checks"`
+	Errors []string        `json:"errors,omitempty"`
+}
+
+// Watchdog verifies that the open positions of portfolios with a stop loss are
+// protected. A position is protected by live stop-loss orders on the other side
+// covering its quantity, or by the server-side monitoring of its portfolio while
+// that keeps up. When monitoring stalls, as on a crash or a feed outage, the
+// watchdog places broker-side stop-loss orders for the uncovered quantity at the
+// stop price of the leg, or of the portfolio's combined loss on the position alone.
+type Watchdog struct {
+	positionService position.PositionService
+	orderService    services.OrderService
+	portfolios      PortfolioProvider
+	feed            FeedMonitor
+	config          Config
+	lastRun         *RunResult
+	stop            chan struct{}
+	mutex           sync.Mutex
+}
+
+// NewWatchdog creates a new Watchdog. The feed is optional, only portfolio
+// monitoring being checked without it.
+func NewWatchdog(
+	positionService position.PositionService,
+	orderService services.OrderService,
+	portfolios PortfolioProvider,
+	feed FeedMonitor,
+	config Config,
+) *Watchdog {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.StaleAfter <= 0 {
+		config.StaleAfter = DefaultStaleAfter
+	}
+	if config.LimitBuffer <= 0 {
+		config.LimitBuffer = DefaultLimitBuffer
+	}
+
+	return &Watchdog{
+		positionService: positionService,
+		orderService:    orderService,
+		portfolios:      portfolios,
+		feed:            feed,
+		config:          config,
+	}
+}
+
+// Start runs the watchdog every interval until it is stopped
+func (w *Watchdog) Start() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.stop != nil {
+		return errors.New("stop-loss watchdog is already running")
+	}
+	w.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(w.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := w.Run(now)
+				if err != nil {
+					log.Printf("Error running stop-loss watchdog: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Stop-loss watchdog error: %s", message)
+				}
+			}
+		}
+	}(w.stop)
+
+	return nil
+}
+
+// Stop stops the watchdog
+func (w *Watchdog) Stop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+// GetLastRun returns the outcome of the last run, nil before the first
+func (w *Watchdog) GetLastRun() *RunResult {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.lastRun
+}
+
+// Run checks the protection of every open position of a portfolio with a stop loss
+// at now, placing failsafe orders where monitoring has failed
+func (w *Watchdog) Run(now time.Time) (*RunResult, error) {
+	result := &RunResult{
+		Time:   now,
+		Checks: []PositionCheck{},
+	}
+
+	positions, err := w.openPositions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open positions: %w", err)
+	}
+
+	portfolios := make(map[string]*models.Portfolio)
+	coverage := make(map[string]map[string]int) // By user
+	for i := range positions {
+		openPosition := &positions[i]
+		if openPosition.PortfolioID == "" {
+			continue
+		}
+
+		portfolio, exists := portfolios[openPosition.PortfolioID]
+		if !exists {
+			portfolio, err = w.portfolios.GetByID(openPosition.PortfolioID)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("portfolio %s: %v", openPosition.PortfolioID, err))
+			}
+			portfolios[openPosition.PortfolioID] = portfolio
+		}
+		if portfolio == nil || portfolio.StopLossType == "" {
+			continue
+		}
+
+		cover, exists := coverage[openPosition.UserID]
+		if !exists {
+			orders, err := w.liveOrders(openPosition.UserID)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("orders of user %s: %v", openPosition.UserID, err))
+				continue
+			}
+			cover = stopCoverage(orders)
+			coverage[openPosition.UserID] = cover
+		}
+
+		check, err := w.check(openPosition, portfolio, cover, now)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("position %s: %v", openPosition.ID, err))
+		}
+		result.Checks = append(result.Checks, *check)
+	}
+
+	w.mutex.Lock()
+	w.lastRun = result
+	w.mutex.Unlock()
+
+	return result, nil
+}
+
+// check checks the protection of an open position, placing a failsafe order for the
+// quantity live stop-loss orders do not cover when monitoring has failed. The
+// quantity the position takes up of the cover is used up.
+func (w *Watchdog) check(openPosition *models.Position, portfolio *models.Portfolio, cover map[string]int, now time.Time) (*PositionCheck, error) {
+	check := &PositionCheck{
+		PositionID:  openPosition.ID,
+		PortfolioID: portfolio.ID,
+		UserID:      openPosition.UserID,
+		Symbol:      openPosition.Symbol,
+	}
+
+	exitDirection := models.OrderDirectionSell
+	if openPosition.Direction == models.PositionDirectionShort {
+		exitDirection = models.OrderDirectionBuy
+	}
+
+	key := coverKey(openPosition.Symbol, openPosition.Exchange, exitDirection)
+	covered := cover[key]
+	if covered > openPosition.RemainingQuantity() {
+		covered = openPosition.RemainingQuantity()
+	}
+	cover[key] -= covered
+	uncovered := openPosition.RemainingQuantity() - covered
+	if uncovered <= 0 {
+		check.Status = ProtectionStatusProtected
+		return check, nil
+	}
+
+	reason := w.monitoringFailure(openPosition, portfolio, now)
+	if reason == "" {
+		check.Status = ProtectionStatusMonitored
+		return check, nil
+	}
+
+	check.Status = ProtectionStatusUnprotected
+	check.Reason = reason
+	stopPrice, err := stopPrice(openPosition, portfolio)
+	if err != nil {
+		check.Reason = fmt.Sprintf("%s, %v", reason, err)
+		return check, nil
+	}
+	check.StopPrice = stopPrice
+
+	buffer := portfolio.ExitPriceBuffer
+	if buffer <= 0 {
+		buffer = stopPrice * w.config.LimitBuffer
+	}
+	limitPrice := stopPrice + buffer
+	if exitDirection == models.OrderDirectionSell {
+		limitPrice = math.Max(stopPrice-buffer, tickSize)
+	}
+
+	legID, _ := strconv.Atoi(openPosition.LegID)
+	order := &models.Order{
+		UserID:         openPosition.UserID,
+		Symbol:         openPosition.Symbol,
+		Exchange:       openPosition.Exchange,
+		OrderType:      models.OrderTypeSLLimit,
+		Direction:      exitDirection,
+		Quantity:       uncovered,
+		Price:          roundToTick(limitPrice),
+		TriggerPrice:   stopPrice,
+		ProductType:    openPosition.ProductType,
+		InstrumentType: openPosition.InstrumentType,
+		OptionType:     openPosition.OptionType,
+		StrikePrice:    openPosition.StrikePrice,
+		Expiry:         openPosition.Expiry,
+		PortfolioID:    openPosition.PortfolioID,
+		StrategyID:     openPosition.StrategyID,
+		LegID:          legID,
+		Tags:           []string{failsafeTag},
+		Notes:          fmt.Sprintf("Failsafe stop loss at %.2f, %s", stopPrice, reason),
+	}
+	created, err := w.orderService.CreateOrder(order)
+	if err != nil {
+		check.Reason = fmt.Sprintf("%s, failsafe order failed: %v", reason, err)
+		return check, fmt.Errorf("failed to place failsafe order: %w", err)
+	}
+
+	log.Printf("Placed failsafe stop loss %s for position %s of portfolio %s at %.2f: %s", created.ID, openPosition.ID, portfolio.ID, stopPrice, reason)
+	check.Status = ProtectionStatusFailsafe
+	check.OrderID = created.ID
+	return check, nil
+}
+
+// monitoringFailure returns why the server-side monitoring of a position has
+// failed, empty while it keeps up
+func (w *Watchdog) monitoringFailure(openPosition *models.Position, portfolio *models.Portfolio, now time.Time) string {
+	staleAfter := w.config.StaleAfter
+	if interval := 3 * time.Duration(portfolio.MonitoringInterval) * time.Second; interval > staleAfter {
+		staleAfter = interval
+	}
+	if portfolio.LastMonitorTime.IsZero() {
+		return "portfolio has not been monitored"
+	}
+	if since := now.Sub(portfolio.LastMonitorTime); since > staleAfter {
+		return fmt.Sprintf("portfolio not monitored for %s", since.Round(time.Second))
+	}
+
+	if w.feed != nil {
+		lastTick, err := w.feed.LastTickTime(openPosition.Symbol, openPosition.Exchange)
+		if err != nil {
+			return fmt.Sprintf("price feed unavailable: %v", err)
+		}
+		if since := now.Sub(lastTick); since > w.config.StaleAfter {
+			return fmt.Sprintf("no prices for %s", since.Round(time.Second))
+		}
+	}
+
+	return ""
+}
+
+// openPositions returns every open and partially closed position
+func (w *Watchdog) openPositions() ([]models.Position, error) {
+	var positions []models.Position
+	for _, status := range []models.PositionStatus{models.PositionStatusOpen, models.PositionStatusPartial} {
+		filter := models.PositionFilter{Status: status}
+		for page := 1; ; page++ {
+			batch, total, err := w.positionService.GetPositions(filter, page, pageSize)
+			if err != nil {
+				return nil, err
+			}
+			positions = append(positions, batch...)
+			if len(batch) == 0 || page*pageSize >= total {
+				break
+			}
+		}
+	}
+
+	return positions, nil
+}
+
+// liveOrders returns a user's orders that have not completed
+func (w *Watchdog) liveOrders(userID string) ([]models.Order, error) {
+	var orders []models.Order
+	for _, status := range []models.OrderStatus{models.OrderStatusNew, models.OrderStatusPending, models.OrderStatusPartial} {
+		filter := models.OrderFilter{UserID: userID, Status: status}
+		for page := 1; ; page++ {
+			batch, total, err := w.orderService.GetOrders(filter, page, pageSize)
+			if err != nil {
+				return nil, err
+			}
+			orders = append(orders, batch...)
+			if len(batch) == 0 || page*pageSize >= total {
+				break
+			}
+		}
+	}
+
+	return orders, nil
+}
+
+// stopCoverage returns the quantity live stop-loss orders have left to fill, by
+// instrument and direction
+func stopCoverage(orders []models.Order) map[string]int {
+	cover := make(map[string]int)
+	for _, order := range orders {
+		if order.OrderType == models.OrderTypeSLLimit {
+			cover[coverKey(order.Symbol, order.Exchange, order.Direction)] += order.Quantity - order.FilledQuantity
+		}
+	}
+	return cover
+}
+
+// coverKey identifies the stop-loss orders of an instrument and direction
+func coverKey(symbol, exchange string, direction models.OrderDirection) string {
+	return exchange + ":" + symbol + ":" + string(direction)
+}
+
+// stopPrice returns the price a position's stop loss triggers at: the stop loss of
+// its leg in points from entry, else the portfolio's combined loss taken on the
+// position alone
+func stopPrice(openPosition *models.Position, portfolio *models.Portfolio) (float64, error) {
+	var points float64
+	for _, leg := range portfolio.Legs {
+		if strconv.Itoa(leg.ID) == openPosition.LegID && leg.IndividualStopLoss > 0 {
+			points = leg.IndividualStopLoss
+		}
+	}
+	if points == 0 && portfolio.StopLossType == models.StopLossTypeCombinedLoss && portfolio.StopLossValue > 0 {
+		points = portfolio.StopLossValue / float64(openPosition.RemainingQuantity())
+	}
+	if points == 0 {
+		return 0, fmt.Errorf("no stop price for stop loss type %s", portfolio.StopLossType)
+	}
+
+	if openPosition.Direction == models.PositionDirectionShort {
+		return roundToTick(openPosition.EntryPrice + points), nil
+	}
+	price := roundToTick(openPosition.EntryPrice - points)
+	if price <= 0 {
+		return 0, errors.New("stop price is below zero")
+	}
+	return price, nil
+}
+
+// roundToTick rounds a price to the tick size
+func roundToTick(price float64) float64 {
+	return math.Round(price/tickSize) * tickSize
+}
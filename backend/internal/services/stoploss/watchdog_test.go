@@ -0,0 +1,299 @@
+package stoploss
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// MockPortfolioProvider is a mock implementation of the PortfolioProvider interface
+type MockPortfolioProvider struct {
+	mock.Mock
+}
+
+func (m *MockPortfolioProvider) GetByID(id string) (*models.Portfolio, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Portfolio), args.Error(1)
+}
+
+// MockFeedMonitor is a mock implementation of the FeedMonitor interface
+type MockFeedMonitor struct {
+	mock.Mock
+}
+
+func (m *MockFeedMonitor) LastTickTime(symbol, exchange string) (time.Time, error) {
+	args := m.Called(symbol, exchange)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+// MockPositionService is a mock implementation of the PositionService interface.
+// GetPositions filters the positions it is given by status.
+type MockPositionService struct {
+	mock.Mock
+	positions []models.Position
+	mutex     sync.Mutex
+}
+
+func (m *MockPositionService) setPositions(positions []models.Position) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.positions = positions
+}
+
+func (m *MockPositionService) CreatePositionFromOrder(order *models.Order) (*models.Position, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositionByID(id string) (*models.Position, error) {
+	args := m.Called(id)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var matched []models.Position
+	for _, p := range m.positions {
+		if filter.Status != "" && p.Status != filter.Status {
+			continue
+		}
+		matched = append(matched, p)
+	}
+	return matched, len(matched), nil
+}
+
+func (m *MockPositionService) UpdatePosition(position *models.Position) (*models.Position, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) ClosePosition(id string, exitPrice float64, exitQuantity int) (*models.Position, error) {
+	args := m.Called(id, exitPrice, exitQuantity)
+	return args.Get(0).(*models.Position), args.Error(1)
+}
+
+func (m *MockPositionService) CalculatePnL(position *models.Position) (float64, error) {
+	args := m.Called(position)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateGreeks(position *models.Position) (*models.Greeks, error) {
+	args := m.Called(position)
+	return args.Get(0).(*models.Greeks), args.Error(1)
+}
+
+func (m *MockPositionService) CalculateExposure(positions []models.Position) (float64, error) {
+	args := m.Called(positions)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPositionService) AggregatePositions(positions []models.Position, groupBy string) (map[string]models.AggregatedPosition, error) {
+	args := m.Called(positions, groupBy)
+	return args.Get(0).(map[string]models.AggregatedPosition), args.Error(1)
+}
+
+// MockOrderService is a mock implementation of the OrderService interface.
+// GetOrders filters the orders it is given by user and status.
+type MockOrderService struct {
+	mock.Mock
+	orders []models.Order
+	mutex  sync.Mutex
+}
+
+func (m *MockOrderService) setOrders(orders []models.Order) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.orders = orders
+}
+
+func (m *MockOrderService) CreateOrder(order *models.Order) (*models.Order, error) {
+	args := m.Called(order)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderByID(id string) (*models.Order, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var matched []models.Order
+	for _, o := range m.orders {
+		if (filter.UserID != "" && o.UserID != filter.UserID) || (filter.Status != "" && o.Status != filter.Status) {
+			continue
+		}
+		matched = append(matched, o)
+	}
+	return matched, len(matched), nil
+}
+
+func (m *MockOrderService) UpdateOrder(order *models.Order) (*models.Order, error) {
+	args := m.Called(order)
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) CancelOrder(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockOrderService) TransitionOrder(id string, status models.OrderStatus, filledQuantity int, source models.TransitionSource, reason string) (*models.Order, error) {
+	args := m.Called(id, status, filledQuantity, source, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderTimeline(id string) ([]models.OrderTransition, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OrderTransition), args.Error(1)
+}
+
+// stopLossPortfolio returns a portfolio of user1 with a 20 point stop loss on leg 1
+// and a combined loss of 5000, last monitored at monitored
+func stopLossPortfolio(monitored time.Time) *models.Portfolio {
+	return &models.Portfolio{
+		ID:              "portfolio1",
+		UserID:          "user1",
+		StopLossType:    models.StopLossTypeCombinedLoss,
+		StopLossValue:   5000,
+		LastMonitorTime: monitored,
+		Legs:            []models.Leg{{ID: 1, IndividualStopLoss: 20}, {ID: 2}},
+	}
+}
+
+// legPositions returns a short position of 100 on leg 1 entered at 100, and a long
+// position of 50 on leg 2 entered at 150
+func legPositions() []models.Position {
+	return []models.Position{
+		{ID: "position1", UserID: "user1", PortfolioID: "portfolio1", LegID: "1", Symbol: "NIFTY24JUN20000CE", Exchange: "NFO", Direction: models.PositionDirectionShort, Quantity: 100, EntryPrice: 100, Status: models.PositionStatusOpen},
+		{ID: "position2", UserID: "user1", PortfolioID: "portfolio1", LegID: "2", Symbol: "NIFTY24JUN20000PE", Exchange: "NFO", Direction: models.PositionDirectionLong, Quantity: 50, EntryPrice: 150, Status: models.PositionStatusOpen},
+	}
+}
+
+func TestWatchdogMonitored(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockOrders := new(MockOrderService)
+	mockPortfolios := new(MockPortfolioProvider)
+	mockFeed := new(MockFeedMonitor)
+	watchdog := NewWatchdog(mockPositions, mockOrders, mockPortfolios, mockFeed, Config{})
+
+	now := time.Now()
+	mockPositions.setPositions(append(legPositions(),
+		models.Position{ID: "position3", UserID: "user2", PortfolioID: "portfolio2", Symbol: "BANKNIFTY", Exchange: "NFO", Quantity: 15, Status: models.PositionStatusOpen},
+		models.Position{ID: "position4", UserID: "user2", Symbol: "RELIANCE", Exchange: "NSE", Quantity: 10, Status: models.PositionStatusOpen},
+	))
+	mockPortfolios.On("GetByID", "portfolio1").Return(stopLossPortfolio(now.Add(-5*time.Second)), nil)
+	mockPortfolios.On("GetByID", "portfolio2").Return(&models.Portfolio{ID: "portfolio2"}, nil)
+	mockFeed.On("LastTickTime", mock.Anything, "NFO").Return(now.Add(-time.Second), nil)
+
+	// Positions without a stop loss are not checked
+	result, err := watchdog.Run(now)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Len(t, result.Checks, 2)
+	for _, check := range result.Checks {
+		assert.Equal(t, ProtectionStatusMonitored, check.Status)
+	}
+	assert.Equal(t, result, watchdog.GetLastRun())
+	mockOrders.AssertNotCalled(t, "CreateOrder", mock.Anything)
+}
+
+func TestWatchdogFailsafe(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockOrders := new(MockOrderService)
+	mockPortfolios := new(MockPortfolioProvider)
+	watchdog := NewWatchdog(mockPositions, mockOrders, mockPortfolios, nil, Config{})
+
+	now := time.Now()
+	mockPositions.setPositions(legPositions())
+	mockPortfolios.On("GetByID", "portfolio1").Return(stopLossPortfolio(now.Add(-time.Minute)), nil)
+
+	// A live stop-loss order covers 40 of the short position, the rest is stopped
+	// 20 points above entry
+	mockOrders.setOrders([]models.Order{
+		{ID: "order1", UserID: "user1", Symbol: "NIFTY24JUN20000CE", Exchange: "NFO", OrderType: models.OrderTypeSLLimit, Direction: models.OrderDirectionBuy, Quantity: 50, FilledQuantity: 10, Status: models.OrderStatusPartial},
+		{ID: "order2", UserID: "user1", Symbol: "NIFTY24JUN20000CE", Exchange: "NFO", OrderType: models.OrderTypeSLLimit, Direction: models.OrderDirectionBuy, Quantity: 60, Status: models.OrderStatusCancelled},
+	})
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "NIFTY24JUN20000CE" && order.Direction == models.OrderDirectionBuy && order.Quantity == 60 && order.LegID == 1
+	})).Return(&models.Order{ID: "failsafe1"}, nil)
+
+	// The long position has no leg stop loss, the combined loss on it alone is 100
+	// points below entry
+	mockOrders.On("CreateOrder", mock.MatchedBy(func(order *models.Order) bool {
+		return order.Symbol == "NIFTY24JUN20000PE"
+	})).Return(&models.Order{ID: "failsafe2"}, nil)
+
+	result, err := watchdog.Run(now)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Len(t, result.Checks, 2)
+	assert.Equal(t, ProtectionStatusFailsafe, result.Checks[0].Status)
+	assert.Equal(t, "failsafe1", result.Checks[0].OrderID)
+	assert.Contains(t, result.Checks[0].Reason, "not monitored")
+	assert.InDelta(t, 120, result.Checks[0].StopPrice, 1e-9)
+	assert.Equal(t, ProtectionStatusFailsafe, result.Checks[1].Status)
+	assert.InDelta(t, 50, result.Checks[1].StopPrice, 1e-9)
+
+	short := mockOrders.Calls[0].Arguments.Get(0).(*models.Order)
+	assert.Equal(t, models.OrderTypeSLLimit, short.OrderType)
+	assert.Equal(t, models.OrderDirectionBuy, short.Direction)
+	assert.InDelta(t, 126, short.Price, 1e-9)
+	assert.Equal(t, []string{failsafeTag}, short.Tags)
+	long := mockOrders.Calls[1].Arguments.Get(0).(*models.Order)
+	assert.Equal(t, models.OrderDirectionSell, long.Direction)
+	assert.Equal(t, 50, long.Quantity)
+	assert.InDelta(t, 47.5, long.Price, 1e-9)
+}
+
+func TestWatchdogUnprotected(t *testing.T) {
+	mockPositions := new(MockPositionService)
+	mockOrders := new(MockOrderService)
+	mockPortfolios := new(MockPortfolioProvider)
+	mockFeed := new(MockFeedMonitor)
+	watchdog := NewWatchdog(mockPositions, mockOrders, mockPortfolios, mockFeed, Config{})
+
+	now := time.Now()
+	portfolio := stopLossPortfolio(now)
+	portfolio.StopLossType = models.StopLossTypeCombinedPremium
+	mockPositions.setPositions(legPositions())
+	mockPortfolios.On("GetByID", "portfolio1").Return(portfolio, nil)
+	mockFeed.On("LastTickTime", mock.Anything, "NFO").Return(time.Time{}, errors.New("feed disconnected"))
+	mockOrders.On("CreateOrder", mock.Anything).Return(nil, errors.New("broker unavailable"))
+
+	// With the feed down, the leg stop loss cannot be placed and the combined premium
+	// gives no stop price for the other leg
+	result, err := watchdog.Run(now)
+	assert.NoError(t, err)
+	assert.Len(t, result.Errors, 1)
+	assert.Len(t, result.Checks, 2)
+	assert.Equal(t, ProtectionStatusUnprotected, result.Checks[0].Status)
+	assert.Contains(t, result.Checks[0].Reason, "failsafe order failed")
+	assert.Equal(t, ProtectionStatusUnprotected, result.Checks[1].Status)
+	assert.Contains(t, result.Checks[1].Reason, "no stop price")
+	mockOrders.AssertNumberOfCalls(t, "CreateOrder", 1)
+}
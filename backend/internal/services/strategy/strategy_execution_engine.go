@@ -192,16 +192,17 @@ func (e *StrategyExecutionEngine) processEntryConditions(strategy *models.Strate
 	if len(strategy.EntryConditions) > 0 && len(strategy.Instruments) > 0 {
 		// Create a sample order
 		order := &models.Order{
-			UserID:      strategy.UserID,
-			StrategyID:  strategy.ID,
-			Symbol:      strategy.Instruments[0],
-			OrderType:   models.OrderTypeLimit,
-			Side:        models.OrderSideBuy,
-			Quantity:    1,
-			Price:       100.0, // Sample price
-			Status:      models.OrderStatusNew,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			UserID:          strategy.UserID,
+			StrategyID:      strategy.ID,
+			StrategyVersion: strategy.Version,
+			Symbol:          strategy.Instruments[0],
+			OrderType:       models.OrderTypeLimit,
+			Side:            models.OrderSideBuy,
+			Quantity:        1,
+			Price:           100.0, // Sample price
+			Status:          models.OrderStatusNew,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
 		}
 		
 		// Submit the order
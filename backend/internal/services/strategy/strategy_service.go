@@ -2,10 +2,12 @@ package strategy
 
 import (
 	"errors"
+	"log"
 	"time"
 
 	"github.com/trading-platform/backend/internal/models"
 	"github.com/trading-platform/backend/internal/repositories"
+	"github.com/trading-platform/backend/internal/versions"
 )
 
 // StrategyService defines the interface for strategy-related operations
@@ -22,6 +24,8 @@ type StrategyService interface {
 	PauseStrategy(strategyID string) error
 	ResumeStrategy(strategyID string) error
 	StopStrategy(strategyID string) error
+	HaltStrategy(strategyID string, reason string) error
+	RearmStrategy(strategyID string) error
 	
 	// Strategy monitoring operations
 	GetStrategyStatus(strategyID string) (models.StrategyStatus, error)
@@ -39,11 +43,18 @@ type StrategyService interface {
 	GetStrategiesByTag(tag string) ([]models.Strategy, error)
 }
 
+// VersionRecorder records the configuration versions of strategies as they are
+// saved, as the versions service does
+type VersionRecorder interface {
+	RecordStrategy(strategy *models.Strategy, userID string) (*versions.Version, error)
+}
+
 // StrategyServiceImpl implements the StrategyService interface
 type StrategyServiceImpl struct {
 	strategyRepo repositories.StrategyRepository
 	orderRepo    repositories.OrderRepository
 	positionRepo repositories.PositionRepository
+	versions     VersionRecorder
 }
 
 // NewStrategyService creates a new StrategyService
@@ -59,6 +70,12 @@ func NewStrategyService(
 	}
 }
 
+// SetVersions sets where the versions of strategies are recorded as they are
+// created and updated
+func (s *StrategyServiceImpl) SetVersions(recorder VersionRecorder) {
+	s.versions = recorder
+}
+
 // CreateStrategy creates a new strategy
 func (s *StrategyServiceImpl) CreateStrategy(strategy *models.Strategy) (*models.Strategy, error) {
 	if strategy == nil {
@@ -70,6 +87,11 @@ func (s *StrategyServiceImpl) CreateStrategy(strategy *models.Strategy) (*models
 		return nil, err
 	}
 	
+	// Live strategies only come from promoting a paper strategy
+	if strategy.IsLive() {
+		return nil, errors.New("live strategies are created by promoting a paper strategy")
+	}
+	
 	// Set timestamps
 	now := time.Now()
 	strategy.CreatedAt = now
@@ -77,9 +99,16 @@ func (s *StrategyServiceImpl) CreateStrategy(strategy *models.Strategy) (*models
 	
 	// Set initial status
 	strategy.Status = models.StrategyStatusDraft
+	strategy.Version = 0
 	
 	// Create strategy
-	return s.strategyRepo.Create(strategy)
+	created, err := s.strategyRepo.Create(strategy)
+	if err != nil {
+		return nil, err
+	}
+	
+	s.recordVersion(created)
+	return created, nil
 }
 
 // GetStrategyByID retrieves a strategy by ID
@@ -120,10 +149,43 @@ func (s *StrategyServiceImpl) UpdateStrategy(strategy *models.Strategy) (*models
 	// Preserve creation time and status
 	strategy.CreatedAt = existingStrategy.CreatedAt
 	strategy.Status = existingStrategy.Status
+	
+	// Preserve the environment and promotion, which only promotion changes
+	strategy.Environment = existingStrategy.Environment
+	strategy.BrokerName = existingStrategy.BrokerName
+	strategy.PaperStrategyID = existingStrategy.PaperStrategyID
+	strategy.LiveStrategyID = existingStrategy.LiveStrategyID
+	strategy.PromotedAt = existingStrategy.PromotedAt
+	
+	// Preserve the halt, which only halting and re-arming change
+	strategy.HaltReason = existingStrategy.HaltReason
+	strategy.HaltedAt = existingStrategy.HaltedAt
+	strategy.RearmedAt = existingStrategy.RearmedAt
+	
+	// Preserve the version, which is only stamped once the update is recorded
+	strategy.Version = existingStrategy.Version
 	strategy.UpdatedAt = time.Now()
 	
 	// Update strategy
-	return s.strategyRepo.Update(strategy)
+	updated, err := s.strategyRepo.Update(strategy)
+	if err != nil {
+		return nil, err
+	}
+	
+	s.recordVersion(updated)
+	return updated, nil
+}
+
+// recordVersion records the configuration of a strategy that was saved as its
+// next version when it changed. Failing to record it does not fail the save.
+func (s *StrategyServiceImpl) recordVersion(strategy *models.Strategy) {
+	if s.versions == nil || strategy == nil {
+		return
+	}
+	
+	if _, err := s.versions.RecordStrategy(strategy, strategy.UserID); err != nil {
+		log.Printf("Error recording version of strategy %s: %v", strategy.ID, err)
+	}
 }
 
 // DeleteStrategy deletes a strategy
@@ -165,6 +227,11 @@ func (s *StrategyServiceImpl) ExecuteStrategy(strategyID string) error {
 		return errors.New("strategy is already active")
 	}
 	
+	// Halted strategies only trade again once re-armed
+	if strategy.Status == models.StrategyStatusHalted {
+		return errors.New("strategy is halted and must be re-armed")
+	}
+	
 	// Update strategy status
 	strategy.Status = models.StrategyStatusActive
 	strategy.UpdatedAt = time.Now()
@@ -195,8 +262,8 @@ func (s *StrategyServiceImpl) PauseStrategy(strategyID string) error {
 		return errors.New("strategy not found")
 	}
 	
-	// Check if strategy is active
-	if strategy.Status != models.StrategyStatusActive {
+	// Check if strategy is active, or waiting for its trading window
+	if strategy.Status != models.StrategyStatusActive && strategy.Status != models.StrategyStatusScheduled {
 		return errors.New("strategy is not active")
 	}
 	
@@ -255,14 +322,82 @@ func (s *StrategyServiceImpl) StopStrategy(strategyID string) error {
 		return errors.New("strategy not found")
 	}
 	
+	// Check if strategy is active, paused or halted
+	if strategy.Status != models.StrategyStatusActive && strategy.Status != models.StrategyStatusPaused && strategy.Status != models.StrategyStatusHalted {
+		return errors.New("strategy is not active, paused or halted")
+	}
+	
+	// Update strategy status
+	strategy.Status = models.StrategyStatusStopped
+	strategy.UpdatedAt = time.Now()
+	
+	// Update strategy
+	_, err = s.strategyRepo.Update(strategy)
+	if err != nil {
+		return err
+	}
+	
+	return nil
+}
+
+// HaltStrategy halts an active or paused strategy that breached a daily limit
+func (s *StrategyServiceImpl) HaltStrategy(strategyID string, reason string) error {
+	if strategyID == "" {
+		return errors.New("strategy ID cannot be empty")
+	}
+	
+	// Check if strategy exists
+	strategy, err := s.strategyRepo.GetByID(strategyID)
+	if err != nil {
+		return errors.New("strategy not found")
+	}
+	
 	// Check if strategy is active or paused
 	if strategy.Status != models.StrategyStatusActive && strategy.Status != models.StrategyStatusPaused {
 		return errors.New("strategy is not active or paused")
 	}
 	
 	// Update strategy status
-	strategy.Status = models.StrategyStatusStopped
-	strategy.UpdatedAt = time.Now()
+	now := time.Now()
+	strategy.Status = models.StrategyStatusHalted
+	strategy.HaltReason = reason
+	strategy.HaltedAt = now
+	strategy.UpdatedAt = now
+	
+	// Update strategy
+	_, err = s.strategyRepo.Update(strategy)
+	if err != nil {
+		return err
+	}
+	
+	return nil
+}
+
+// RearmStrategy re-arms a halted strategy, its daily limits counting afresh from
+// now
+func (s *StrategyServiceImpl) RearmStrategy(strategyID string) error {
+	if strategyID == "" {
+		return errors.New("strategy ID cannot be empty")
+	}
+	
+	// Check if strategy exists
+	strategy, err := s.strategyRepo.GetByID(strategyID)
+	if err != nil {
+		return errors.New("strategy not found")
+	}
+	
+	// Check if strategy is halted
+	if strategy.Status != models.StrategyStatusHalted {
+		return errors.New("strategy is not halted")
+	}
+	
+	// Update strategy status
+	now := time.Now()
+	strategy.Status = models.StrategyStatusActive
+	strategy.HaltReason = ""
+	strategy.HaltedAt = time.Time{}
+	strategy.RearmedAt = now
+	strategy.UpdatedAt = now
 	
 	// Update strategy
 	_, err = s.strategyRepo.Update(strategy)
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/versions"
 )
 
 // MockStrategyRepository is a mock implementation of the StrategyRepository interface
@@ -34,6 +35,11 @@ func (m *MockStrategyRepository) GetByTag(tag string) ([]models.Strategy, error)
 	return args.Get(0).([]models.Strategy), args.Error(1)
 }
 
+func (m *MockStrategyRepository) GetScheduled() ([]models.Strategy, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Strategy), args.Error(1)
+}
+
 func (m *MockStrategyRepository) Update(strategy *models.Strategy) (*models.Strategy, error) {
 	args := m.Called(strategy)
 	return args.Get(0).(*models.Strategy), args.Error(1)
@@ -269,6 +275,64 @@ func TestUpdateStrategy(t *testing.T) {
 	mockStrategyRepo.AssertExpectations(t)
 }
 
+// TestUpdateStrategyRecordsVersion tests that updates record the versions of strategies
+func TestUpdateStrategyRecordsVersion(t *testing.T) {
+	// Create mock repositories
+	mockStrategyRepo := new(MockStrategyRepository)
+	mockOrderRepo := new(MockOrderRepository)
+	mockPositionRepo := new(MockPositionRepository)
+	
+	// Create the service, recording versions in memory
+	service := NewStrategyService(mockStrategyRepo, mockOrderRepo, mockPositionRepo)
+	versionService := versions.NewService(versions.NewMemoryStore(), mockStrategyRepo, nil)
+	service.(*StrategyServiceImpl).SetVersions(versionService)
+	
+	strategy := &models.Strategy{
+		ID:          "strategy123",
+		Name:        "Test Strategy",
+		UserID:      "user123",
+		Type:        models.StrategyTypeManual,
+		Status:      models.StrategyStatusActive,
+		Instruments: []string{"AAPL"},
+		Version:     1,
+	}
+	updatedStrategy := &models.Strategy{
+		ID:          "strategy123",
+		Name:        "Test Strategy",
+		UserID:      "user123",
+		Type:        models.StrategyTypeManual,
+		Instruments: []string{"AAPL"},
+		RiskParameters: models.RiskParameters{
+			MaxPositionSize: 200,
+			MaxLoss:         2000,
+		},
+	}
+	
+	// Set up the mock expectations
+	mockStrategyRepo.On("GetByID", "strategy123").Return(strategy, nil)
+	mockStrategyRepo.On("Update", mock.AnythingOfType("*models.Strategy")).Return(updatedStrategy, nil)
+	
+	// The first update is version 1, the version of the strategy being kept
+	result, err := service.UpdateStrategy(updatedStrategy)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Version)
+	
+	// Changing the configuration records and stamps version 2
+	updatedStrategy.Instruments = []string{"AAPL", "MSFT"}
+	result, err = service.UpdateStrategy(updatedStrategy)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Version)
+	assert.Equal(t, models.StrategyStatusActive, result.Status)
+	
+	recorded, err := versionService.ListVersions(versions.KindStrategy, "strategy123")
+	assert.NoError(t, err)
+	assert.Len(t, recorded, 2)
+	
+	diff, err := versionService.Diff(versions.KindStrategy, "strategy123", 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []versions.Change{{Path: "instruments.1", To: "MSFT"}}, diff.Changes)
+}
+
 // TestDeleteStrategy tests the DeleteStrategy method
 func TestDeleteStrategy(t *testing.T) {
 	// Create mock repositories
@@ -343,6 +407,51 @@ func TestExecuteStrategy(t *testing.T) {
 	mockStrategyRepo.AssertExpectations(t)
 }
 
+// TestHaltAndRearmStrategy tests the HaltStrategy and RearmStrategy methods
+func TestHaltAndRearmStrategy(t *testing.T) {
+	// Create mock repositories
+	mockStrategyRepo := new(MockStrategyRepository)
+	mockOrderRepo := new(MockOrderRepository)
+	mockPositionRepo := new(MockPositionRepository)
+	
+	// Create the service
+	service := NewStrategyService(mockStrategyRepo, mockOrderRepo, mockPositionRepo)
+	
+	// Create a sample strategy
+	strategy := &models.Strategy{
+		ID:                 "strategy123",
+		Name:               "Test Strategy",
+		UserID:             "user123",
+		Type:               models.StrategyTypeAlgo,
+		Status:             models.StrategyStatusActive,
+		Instruments:        []string{"NIFTY"},
+		MaxLossPerStrategy: 5000,
+	}
+	
+	// Set up the mock expectations
+	mockStrategyRepo.On("GetByID", "strategy123").Return(strategy, nil)
+	mockStrategyRepo.On("Update", mock.AnythingOfType("*models.Strategy")).Return(strategy, nil)
+	
+	// Halt the strategy
+	err := service.HaltStrategy("strategy123", "max loss of 5000.00 reached")
+	assert.NoError(t, err)
+	assert.Equal(t, models.StrategyStatusHalted, strategy.Status)
+	assert.Equal(t, "max loss of 5000.00 reached", strategy.HaltReason)
+	assert.False(t, strategy.HaltedAt.IsZero())
+	
+	// A halted strategy is neither resumed nor executed, only re-armed
+	assert.Error(t, service.ResumeStrategy("strategy123"))
+	assert.Error(t, service.ExecuteStrategy("strategy123"))
+	assert.Error(t, service.HaltStrategy("strategy123", "again"))
+	
+	err = service.RearmStrategy("strategy123")
+	assert.NoError(t, err)
+	assert.Equal(t, models.StrategyStatusActive, strategy.Status)
+	assert.Empty(t, strategy.HaltReason)
+	assert.False(t, strategy.RearmedAt.IsZero())
+	assert.Error(t, service.RearmStrategy("strategy123"))
+}
+
 // TestGetStrategyPerformance tests the GetStrategyPerformance method
 func TestGetStrategyPerformance(t *testing.T) {
 	// Create mock repositories
@@ -0,0 +1,99 @@
+package summary
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mailer emails rendered summaries
+type Mailer interface {
+	Send(to string, email *Email) error
+}
+
+// SMTPConfig configures the mail server summaries are emailed through
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // No authentication when empty
+	Password string
+	From     string
+}
+
+// SMTPMailer emails summaries through a mail server, as multipart messages with
+// plain text and HTML alternatives
+type SMTPMailer struct {
+	config   SMTPConfig
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPMailer creates a new SMTPMailer
+func NewSMTPMailer(config SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{
+		config:   config,
+		sendMail: smtp.SendMail,
+	}
+}
+
+// Send emails a summary to an address
+func (m *SMTPMailer) Send(to string, email *Email) error {
+	message, err := mimeMessage(m.config.From, to, email, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+
+	return m.sendMail(addr, auth, m.config.From, []string{to}, message)
+}
+
+// mimeMessage returns the multipart/alternative message of an email
+func mimeMessage(from, to string, email *Email, date time.Time) ([]byte, error) {
+	header := strings.NewReplacer("\r", "", "\n", " ")
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, alternative := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", email.Text},
+		{"text/html; charset=UTF-8", email.HTML},
+	} {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alternative.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write([]byte(alternative.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(&message, "To: %s\r\n", header.Replace(to))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", header.Replace(email.Subject)))
+	fmt.Fprintf(&message, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
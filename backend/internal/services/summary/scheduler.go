@@ -0,0 +1,175 @@
+package summary
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultInterval is how often the service checks for summaries due
+const DefaultInterval = time.Minute
+
+// errNoEmail is returned for summaries of users without an email address, which
+// are not retried
+var errNoEmail = errors.New("user has no email address")
+
+// Delivery is a summary the scheduler handled
+type Delivery struct {
+	UserID string `json:"userId"`
+	Date   string `json:"date"`
+	Email  string `json:"email,omitempty"`
+	Sent   bool   `json:"sent"` // False for days without activity, which are not emailed
+}
+
+// RunResult is the outcome of one run of the scheduler
+type RunResult struct {
+	Time       time.Time  `json:"time"`
+	Deliveries []Delivery `json:"deliveries"`
+	Errors     []string   `json:"errors,omitempty"`
+}
+
+// Start emails the summaries due every interval until the service is stopped
+func (s *Service) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.mailer == nil {
+		return errors.New("summary service has no mailer")
+	}
+	if s.stop != nil {
+		return errors.New("summary service is already running")
+	}
+	s.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := s.Run(now)
+				if err != nil {
+					log.Printf("Error sending daily summaries: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Daily summary error: %s", message)
+				}
+			}
+		}
+	}(s.stop)
+
+	return nil
+}
+
+// Stop stops the service
+func (s *Service) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// Run emails the summary of the day to every user opted in whose send time has
+// passed in their time zone, once a day. Days without activity and users without
+// an email address are recorded as handled without being emailed, and summaries
+// that fail to send are retried on the next run.
+func (s *Service) Run(now time.Time) (*RunResult, error) {
+	if s.mailer == nil {
+		return nil, errors.New("summary service has no mailer")
+	}
+
+	subscribers, err := s.users.GetDailySummarySubscribers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily summary subscribers: %w", err)
+	}
+
+	result := &RunResult{
+		Time:       now,
+		Deliveries: []Delivery{},
+	}
+	for i := range subscribers {
+		settings := &subscribers[i]
+		if !settings.DailySummaryEmails {
+			continue
+		}
+
+		location := s.location(settings.UserID)
+		local := now.In(location)
+		date := local.Format(dateFormat)
+		if settings.LastDailySummary == date || local.Before(s.sendTime(settings.DailySummaryTime, local)) {
+			continue
+		}
+
+		start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+		delivery, err := s.deliver(settings.UserID, start, now)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("user %s: %v", settings.UserID, err))
+			if !errors.Is(err, errNoEmail) {
+				continue
+			}
+		} else {
+			result.Deliveries = append(result.Deliveries, *delivery)
+		}
+
+		settings.LastDailySummary = date
+		if _, err := s.users.UpdateUserNotificationSettings(settings); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("user %s: failed to record summary of %s: %v", settings.UserID, date, err))
+		}
+	}
+
+	return result, nil
+}
+
+// sendTime returns when on the day of local a summary is due, at a user's send
+// time or the configured send time when theirs is empty or invalid
+func (s *Service) sendTime(userSendTime string, local time.Time) time.Time {
+	clock, err := time.Parse(clockFormat, userSendTime)
+	if err != nil {
+		clock, _ = time.Parse(clockFormat, s.config.SendTime)
+	}
+	return time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, local.Location())
+}
+
+// deliver compiles a user's summary of the day starting at start and emails it,
+// unless there was no activity or the user has no email address
+func (s *Service) deliver(userID string, start, now time.Time) (*Delivery, error) {
+	summary, err := s.compile(userID, start, now)
+	if err != nil {
+		return nil, err
+	}
+	delivery := &Delivery{
+		UserID: userID,
+		Date:   summary.Date,
+	}
+	if summary.Empty() {
+		return delivery, nil
+	}
+
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.Email == "" {
+		return nil, errNoEmail
+	}
+
+	email, err := Render(summary)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mailer.Send(user.Email, email); err != nil {
+		return nil, fmt.Errorf("failed to email summary: %w", err)
+	}
+	delivery.Email = user.Email
+	delivery.Sent = true
+
+	return delivery, nil
+}
@@ -0,0 +1,408 @@
+// Package summary compiles end-of-day summaries of users' trading, their P&L,
+// trades, fees and open risk, and emails them to the users who opted in at the
+// time they chose in their own time zone.
+package summary
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+const (
+	// DefaultSendTime is when summaries are sent to users who did not choose a
+	// time, in their time zone
+	DefaultSendTime = "18:00"
+
+	// DefaultFeePerOrder is the brokerage estimated for each filled order
+	DefaultFeePerOrder = 20.0
+
+	// DefaultFeeRate is the exchange and regulatory charges estimated as a fraction
+	// of the value traded
+	DefaultFeeRate = 0.0005
+
+	// pageSize is the number of orders and positions read at a time
+	pageSize = 100
+
+	// dateFormat is the format of summary dates
+	dateFormat = "2006-01-02"
+
+	// clockFormat is the format of send times
+	clockFormat = "15:04"
+)
+
+var (
+	// ErrInvalidDate is returned for summary dates other than YYYY-MM-DD
+	ErrInvalidDate = errors.New("date must be YYYY-MM-DD")
+	// ErrInvalidSendTime is returned for send times other than HH:MM
+	ErrInvalidSendTime = errors.New("send time must be HH:MM")
+)
+
+// UserProvider looks users up along with their settings and notification
+// settings, as the user repository does
+type UserProvider interface {
+	GetByID(id string) (*models.User, error)
+	GetUserSettings(userID string) (*models.UserSettings, error)
+	GetUserNotificationSettings(userID string) (*models.UserNotificationSettings, error)
+	CreateUserNotificationSettings(settings *models.UserNotificationSettings) (*models.UserNotificationSettings, error)
+	UpdateUserNotificationSettings(settings *models.UserNotificationSettings) (*models.UserNotificationSettings, error)
+	GetDailySummarySubscribers() ([]models.UserNotificationSettings, error)
+}
+
+// FeeSchedule estimates the fees of filled orders
+type FeeSchedule struct {
+	PerOrder float64 // Brokerage of each filled order
+	Rate     float64 // Charges as a fraction of the value traded
+}
+
+// Fee returns the estimated fee of an order filled for a value
+func (f FeeSchedule) Fee(value float64) float64 {
+	return f.PerOrder + f.Rate*math.Abs(value)
+}
+
+// Config configures the summary service
+type Config struct {
+	Location *time.Location // Time zone of users without one in their settings, defaults to the local time zone
+	SendTime string         // HH:MM, defaults to DefaultSendTime
+	Interval time.Duration  // Between checks for summaries due, defaults to DefaultInterval
+	Fees     *FeeSchedule   // Defaults to DefaultFeePerOrder and DefaultFeeRate
+}
+
+// Trade is an order filled during the day
+type Trade struct {
+	OrderID   string                `json:"orderId"`
+	Symbol    string                `json:"symbol"`
+	Exchange  string                `json:"exchange"`
+	Direction models.OrderDirection `json:"direction"`
+	Quantity  int                   `json:"quantity"` // Filled
+	Price     float64               `json:"price"`    // Average fill price
+	Value     float64               `json:"value"`
+	Fee       float64               `json:"fee"`
+	Time      time.Time             `json:"time"`
+}
+
+// OpenPosition is a position open at the end of the day
+type OpenPosition struct {
+	PositionID    string                   `json:"positionId"`
+	Symbol        string                   `json:"symbol"`
+	Exchange      string                   `json:"exchange"`
+	Direction     models.PositionDirection `json:"direction"`
+	Quantity      int                      `json:"quantity"` // Remaining
+	EntryPrice    float64                  `json:"entryPrice"`
+	UnrealizedPnL float64                  `json:"unrealizedPnL"`
+}
+
+// Summary is a user's trading over a day
+type Summary struct {
+	UserID        string         `json:"userId"`
+	Name          string         `json:"name"`
+	Date          string         `json:"date"` // YYYY-MM-DD in the user's time zone
+	TimeZone      string         `json:"timeZone"`
+	RealizedPnL   float64        `json:"realizedPnL"`
+	UnrealizedPnL float64        `json:"unrealizedPnL"`
+	GrossPnL      float64        `json:"grossPnL"` // Realized and unrealized
+	Fees          float64        `json:"fees"`     // Estimated from the fee schedule
+	NetPnL        float64        `json:"netPnL"`   // Gross P&L less fees
+	TradeCount    int            `json:"tradeCount"`
+	TradedValue   float64        `json:"tradedValue"`
+	GrossExposure float64        `json:"grossExposure"` // Value of the open positions at entry
+	OpenRisk      float64        `json:"openRisk"`      // Unrealized losses of the losing open positions
+	Greeks        models.Greeks  `json:"greeks"`        // Of the open positions
+	Trades        []Trade        `json:"trades"`
+	OpenPositions []OpenPosition `json:"openPositions"`
+	GeneratedAt   time.Time      `json:"generatedAt"`
+}
+
+// Empty reports whether there was nothing to summarize, no trades and no open
+// positions, as on weekends and holidays
+func (s *Summary) Empty() bool {
+	return len(s.Trades) == 0 && len(s.OpenPositions) == 0 && s.RealizedPnL == 0
+}
+
+// Subscription is a user's opt-in to daily summary emails
+type Subscription struct {
+	Enabled  bool   `json:"enabled"`
+	SendTime string `json:"sendTime"`           // HH:MM in the user's time zone
+	TimeZone string `json:"timeZone,omitempty"` // From the user's settings
+}
+
+// Service compiles daily summaries and emails them to the users who opted in
+type Service struct {
+	orderService    services.OrderService
+	positionService position.PositionService
+	users           UserProvider
+	mailer          Mailer
+	config          Config
+	fees            FeeSchedule
+	stop            chan struct{}
+	mutex           sync.Mutex
+}
+
+// NewService creates a new Service. Without a mailer summaries can be compiled
+// but are not emailed.
+func NewService(
+	orderService services.OrderService,
+	positionService position.PositionService,
+	users UserProvider,
+	mailer Mailer,
+	config Config,
+) *Service {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.SendTime == "" {
+		config.SendTime = DefaultSendTime
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	fees := FeeSchedule{PerOrder: DefaultFeePerOrder, Rate: DefaultFeeRate}
+	if config.Fees != nil {
+		fees = *config.Fees
+	}
+
+	return &Service{
+		orderService:    orderService,
+		positionService: positionService,
+		users:           users,
+		mailer:          mailer,
+		config:          config,
+		fees:            fees,
+	}
+}
+
+// Compile compiles a user's summary of a date in their time zone, YYYY-MM-DD, or
+// of today so far when date is empty
+func (s *Service) Compile(userID, date string) (*Summary, error) {
+	location := s.location(userID)
+	now := time.Now().In(location)
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	if date != "" {
+		parsed, err := time.ParseInLocation(dateFormat, date, location)
+		if err != nil {
+			return nil, ErrInvalidDate
+		}
+		day = parsed
+	}
+
+	return s.compile(userID, day, now)
+}
+
+// compile compiles a user's summary of the day starting at start, as of now
+func (s *Service) compile(userID string, start, now time.Time) (*Summary, error) {
+	end := start.AddDate(0, 0, 1)
+
+	summary := &Summary{
+		UserID:        userID,
+		Name:          userID,
+		Date:          start.Format(dateFormat),
+		TimeZone:      start.Location().String(),
+		Trades:        []Trade{},
+		OpenPositions: []OpenPosition{},
+		GeneratedAt:   now,
+	}
+	if user, err := s.users.GetByID(userID); err == nil {
+		summary.Name = displayName(user)
+	}
+
+	orders, err := s.dayOrders(userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+	for _, order := range orders {
+		if order.FilledQuantity <= 0 {
+			continue
+		}
+		price := order.AveragePrice
+		if price == 0 {
+			price = order.Price
+		}
+		value := float64(order.FilledQuantity) * price
+		trade := Trade{
+			OrderID:   order.ID,
+			Symbol:    order.Symbol,
+			Exchange:  order.Exchange,
+			Direction: order.Direction,
+			Quantity:  order.FilledQuantity,
+			Price:     price,
+			Value:     value,
+			Fee:       s.fees.Fee(value),
+			Time:      order.UpdatedAt,
+		}
+		summary.Trades = append(summary.Trades, trade)
+		summary.TradedValue += value
+		summary.Fees += trade.Fee
+	}
+	sort.Slice(summary.Trades, func(i, j int) bool {
+		return summary.Trades[i].Time.Before(summary.Trades[j].Time)
+	})
+	summary.TradeCount = len(summary.Trades)
+
+	open, err := s.positions(models.PositionFilter{UserID: userID, Status: models.PositionStatusOpen})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+	partial, err := s.positions(models.PositionFilter{UserID: userID, Status: models.PositionStatusPartial})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+	closed, err := s.positions(models.PositionFilter{UserID: userID, Status: models.PositionStatusClosed, ClosedFrom: start, ClosedBefore: end})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	for _, openPosition := range append(open, partial...) {
+		summary.RealizedPnL += openPosition.RealizedPnL
+		summary.UnrealizedPnL += openPosition.UnrealizedPnL
+		if openPosition.UnrealizedPnL < 0 {
+			summary.OpenRisk -= openPosition.UnrealizedPnL
+		}
+		quantity := openPosition.RemainingQuantity()
+		summary.GrossExposure += math.Abs(float64(quantity) * openPosition.EntryPrice)
+		summary.Greeks.Delta += openPosition.Greeks.Delta
+		summary.Greeks.Gamma += openPosition.Greeks.Gamma
+		summary.Greeks.Theta += openPosition.Greeks.Theta
+		summary.Greeks.Vega += openPosition.Greeks.Vega
+		summary.OpenPositions = append(summary.OpenPositions, OpenPosition{
+			PositionID:    openPosition.ID,
+			Symbol:        openPosition.Symbol,
+			Exchange:      openPosition.Exchange,
+			Direction:     openPosition.Direction,
+			Quantity:      quantity,
+			EntryPrice:    openPosition.EntryPrice,
+			UnrealizedPnL: openPosition.UnrealizedPnL,
+		})
+	}
+	for _, closedPosition := range closed {
+		summary.RealizedPnL += closedPosition.RealizedPnL
+	}
+
+	summary.GrossPnL = summary.RealizedPnL + summary.UnrealizedPnL
+	summary.NetPnL = summary.GrossPnL - summary.Fees
+
+	return summary, nil
+}
+
+// GetSubscription returns a user's opt-in to daily summary emails
+func (s *Service) GetSubscription(userID string) (*Subscription, error) {
+	subscription := &Subscription{
+		SendTime: s.config.SendTime,
+		TimeZone: s.location(userID).String(),
+	}
+
+	settings, err := s.users.GetUserNotificationSettings(userID)
+	if err != nil {
+		// Users without notification settings have not opted in
+		return subscription, nil
+	}
+	subscription.Enabled = settings.DailySummaryEmails
+	if settings.DailySummaryTime != "" {
+		subscription.SendTime = settings.DailySummaryTime
+	}
+
+	return subscription, nil
+}
+
+// UpdateSubscription opts a user in to or out of daily summary emails, sent at
+// the send time given or the default send time when it is empty
+func (s *Service) UpdateSubscription(userID string, subscription Subscription) (*Subscription, error) {
+	if subscription.SendTime != "" {
+		if _, err := time.Parse(clockFormat, subscription.SendTime); err != nil {
+			return nil, ErrInvalidSendTime
+		}
+	}
+
+	settings, err := s.users.GetUserNotificationSettings(userID)
+	if err != nil {
+		now := time.Now()
+		settings = &models.UserNotificationSettings{
+			UserID:             userID,
+			DailySummaryEmails: subscription.Enabled,
+			DailySummaryTime:   subscription.SendTime,
+			CreatedAt:          now,
+			UpdatedAt:          now,
+		}
+		if _, err := s.users.CreateUserNotificationSettings(settings); err != nil {
+			return nil, err
+		}
+		return s.GetSubscription(userID)
+	}
+
+	settings.DailySummaryEmails = subscription.Enabled
+	settings.DailySummaryTime = subscription.SendTime
+	settings.UpdatedAt = time.Now()
+	if _, err := s.users.UpdateUserNotificationSettings(settings); err != nil {
+		return nil, err
+	}
+
+	return s.GetSubscription(userID)
+}
+
+// location returns the time zone of a user's settings, or the configured time
+// zone when they have none or it is unknown
+func (s *Service) location(userID string) *time.Location {
+	settings, err := s.users.GetUserSettings(userID)
+	if err != nil || settings.TimeZone == "" {
+		return s.config.Location
+	}
+	location, err := time.LoadLocation(settings.TimeZone)
+	if err != nil {
+		return s.config.Location
+	}
+	return location
+}
+
+// dayOrders returns a user's orders placed from start until end
+func (s *Service) dayOrders(userID string, start, end time.Time) ([]models.Order, error) {
+	filter := models.OrderFilter{UserID: userID, FromDate: start, ToDate: end}
+
+	var orders []models.Order
+	for page := 1; ; page++ {
+		batch, total, err := s.orderService.GetOrders(filter, page, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, batch...)
+		if len(batch) == 0 || page*pageSize >= total {
+			break
+		}
+	}
+
+	return orders, nil
+}
+
+// positions returns all positions matching filter
+func (s *Service) positions(filter models.PositionFilter) ([]models.Position, error) {
+	var positions []models.Position
+	for page := 1; ; page++ {
+		batch, total, err := s.positionService.GetPositions(filter, page, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, batch...)
+		if len(batch) == 0 || page*pageSize >= total {
+			break
+		}
+	}
+
+	return positions, nil
+}
+
+// displayName returns the name a user is addressed by
+func displayName(user *models.User) string {
+	if user.FirstName != "" {
+		return user.FirstName
+	}
+	if user.Username != "" {
+		return user.Username
+	}
+	return user.ID
+}
@@ -0,0 +1,274 @@
+package summary
+
+import (
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/services"
+	"github.com/trading-platform/backend/internal/services/position"
+)
+
+// stubOrders is an order service returning fixed orders of the user filtered on
+type stubOrders struct {
+	services.OrderService
+	orders []models.Order
+}
+
+func (s *stubOrders) GetOrders(filter models.OrderFilter, page, limit int) ([]models.Order, int, error) {
+	var orders []models.Order
+	for _, order := range s.orders {
+		if order.UserID == filter.UserID && !order.CreatedAt.Before(filter.FromDate) && order.CreatedAt.Before(filter.ToDate) {
+			orders = append(orders, order)
+		}
+	}
+	return orders, len(orders), nil
+}
+
+// stubPositions is a position service returning fixed positions of the user and
+// status filtered on, and closed within the range filtered on
+type stubPositions struct {
+	position.PositionService
+	positions []models.Position
+}
+
+func (s *stubPositions) GetPositions(filter models.PositionFilter, page, limit int) ([]models.Position, int, error) {
+	var positions []models.Position
+	for _, candidate := range s.positions {
+		if candidate.UserID != filter.UserID || candidate.Status != filter.Status ||
+			(!filter.ClosedFrom.IsZero() && candidate.UpdatedAt.Before(filter.ClosedFrom)) ||
+			(!filter.ClosedBefore.IsZero() && !candidate.UpdatedAt.Before(filter.ClosedBefore)) {
+			continue
+		}
+		positions = append(positions, candidate)
+	}
+	return positions, len(positions), nil
+}
+
+// stubUsers keeps users and their settings in memory
+type stubUsers struct {
+	users         map[string]*models.User
+	settings      map[string]*models.UserSettings
+	notifications map[string]*models.UserNotificationSettings
+}
+
+func newStubUsers() *stubUsers {
+	return &stubUsers{
+		users:         make(map[string]*models.User),
+		settings:      make(map[string]*models.UserSettings),
+		notifications: make(map[string]*models.UserNotificationSettings),
+	}
+}
+
+func (s *stubUsers) GetByID(id string) (*models.User, error) {
+	if user, exists := s.users[id]; exists {
+		return user, nil
+	}
+	return nil, errors.New("user not found")
+}
+
+func (s *stubUsers) GetUserSettings(userID string) (*models.UserSettings, error) {
+	if settings, exists := s.settings[userID]; exists {
+		return settings, nil
+	}
+	return nil, errors.New("user settings not found")
+}
+
+func (s *stubUsers) GetUserNotificationSettings(userID string) (*models.UserNotificationSettings, error) {
+	if settings, exists := s.notifications[userID]; exists {
+		copied := *settings
+		return &copied, nil
+	}
+	return nil, errors.New("user notification settings not found")
+}
+
+func (s *stubUsers) CreateUserNotificationSettings(settings *models.UserNotificationSettings) (*models.UserNotificationSettings, error) {
+	copied := *settings
+	s.notifications[settings.UserID] = &copied
+	return settings, nil
+}
+
+func (s *stubUsers) UpdateUserNotificationSettings(settings *models.UserNotificationSettings) (*models.UserNotificationSettings, error) {
+	copied := *settings
+	s.notifications[settings.UserID] = &copied
+	return settings, nil
+}
+
+func (s *stubUsers) GetDailySummarySubscribers() ([]models.UserNotificationSettings, error) {
+	var subscribers []models.UserNotificationSettings
+	for _, settings := range s.notifications {
+		if settings.DailySummaryEmails {
+			subscribers = append(subscribers, *settings)
+		}
+	}
+	return subscribers, nil
+}
+
+// recordingMailer records the emails sent through it
+type recordingMailer struct {
+	sent map[string][]*Email
+}
+
+func (m *recordingMailer) Send(to string, email *Email) error {
+	if m.sent == nil {
+		m.sent = make(map[string][]*Email)
+	}
+	m.sent[to] = append(m.sent[to], email)
+	return nil
+}
+
+func TestCompile(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+	day := time.Date(2024, 3, 14, 0, 0, 0, 0, kolkata)
+
+	orders := &stubOrders{orders: []models.Order{
+		{ID: "order1", UserID: "user1", Symbol: "NIFTY24MAR22000CE", Direction: models.OrderDirectionBuy, FilledQuantity: 50, AveragePrice: 100, CreatedAt: day.Add(10 * time.Hour)},
+		{ID: "order2", UserID: "user1", Symbol: "NIFTY24MAR22000CE", Direction: models.OrderDirectionSell, FilledQuantity: 50, Price: 120, CreatedAt: day.Add(11 * time.Hour)},
+		{ID: "order3", UserID: "user1", Symbol: "BANKNIFTY", Direction: models.OrderDirectionBuy, CreatedAt: day.Add(12 * time.Hour)},
+		{ID: "order4", UserID: "user1", Symbol: "RELIANCE", FilledQuantity: 10, AveragePrice: 2900, CreatedAt: day.Add(-time.Hour)},
+	}}
+	positions := &stubPositions{positions: []models.Position{
+		{ID: "position1", UserID: "user1", Symbol: "NIFTY24MAR22000CE", Status: models.PositionStatusClosed, RealizedPnL: 1000, CreatedAt: day.Add(-14 * time.Hour), UpdatedAt: day.Add(11 * time.Hour)},
+		{ID: "position4", UserID: "user1", Symbol: "RELIANCE", Status: models.PositionStatusClosed, RealizedPnL: 700, CreatedAt: day.Add(-2 * time.Hour), UpdatedAt: day.Add(-time.Hour)},
+		{ID: "position2", UserID: "user1", Symbol: "INFY", Direction: models.PositionDirectionLong, Status: models.PositionStatusOpen, Quantity: 100, EntryPrice: 1500, UnrealizedPnL: -2500, Greeks: models.Greeks{Delta: 100}},
+		{ID: "position3", UserID: "user1", Symbol: "TCS", Direction: models.PositionDirectionShort, Status: models.PositionStatusPartial, Quantity: 20, ExitQuantity: 10, EntryPrice: 4000, RealizedPnL: 300, UnrealizedPnL: 400, Greeks: models.Greeks{Delta: -10}},
+	}}
+	users := newStubUsers()
+	users.users["user1"] = &models.User{ID: "user1", FirstName: "Asha"}
+	users.settings["user1"] = &models.UserSettings{UserID: "user1", TimeZone: "Asia/Kolkata"}
+
+	service := NewService(orders, positions, users, nil, Config{Location: time.UTC, Fees: &FeeSchedule{PerOrder: 20, Rate: 0.001}})
+
+	summary, err := service.Compile("user1", "2024-03-14")
+	require.NoError(t, err)
+	assert.Equal(t, "Asha", summary.Name)
+	assert.Equal(t, "Asia/Kolkata", summary.TimeZone)
+
+	// Only the day's filled orders are trades, each paying the fee schedule
+	assert.Equal(t, 2, summary.TradeCount)
+	assert.Equal(t, "order1", summary.Trades[0].OrderID)
+	assert.Equal(t, 11000.0, summary.TradedValue)
+	assert.InDelta(t, 51.0, summary.Fees, 1e-9)
+
+	// Positions closed on the day count whenever they were opened
+	assert.Equal(t, 1300.0, summary.RealizedPnL)
+	assert.Equal(t, -2100.0, summary.UnrealizedPnL)
+	assert.InDelta(t, -851.0, summary.NetPnL, 1e-9)
+
+	// Open risk counts the losing positions' unrealized losses
+	assert.Len(t, summary.OpenPositions, 2)
+	assert.Equal(t, 2500.0, summary.OpenRisk)
+	assert.Equal(t, 190000.0, summary.GrossExposure)
+	assert.Equal(t, 90.0, summary.Greeks.Delta)
+	assert.False(t, summary.Empty())
+
+	_, err = service.Compile("user1", "14/03/2024")
+	assert.ErrorIs(t, err, ErrInvalidDate)
+}
+
+func TestRender(t *testing.T) {
+	summary := &Summary{
+		Name:        "<Asha>",
+		Date:        "2024-03-14",
+		TimeZone:    "Asia/Kolkata",
+		RealizedPnL: 125000.5,
+		NetPnL:      -1234567.891,
+		Trades:      []Trade{{Symbol: "NIFTY", Direction: models.OrderDirectionBuy, Quantity: 50, Price: 22010}},
+	}
+
+	email, err := Render(summary)
+	require.NoError(t, err)
+	assert.Equal(t, "Daily summary for 2024-03-14: net P&L -1,234,567.89", email.Subject)
+	assert.Contains(t, email.Text, "Hi <Asha>,")
+	assert.Contains(t, email.Text, "Realized:    +125,000.50")
+	assert.Contains(t, email.Text, "BUY 50 NIFTY @ 22010.00")
+
+	// Names are escaped in HTML
+	assert.Contains(t, email.HTML, "Hi &lt;Asha&gt;,")
+	assert.Contains(t, email.HTML, "<td>NIFTY</td>")
+}
+
+func TestRun(t *testing.T) {
+	positions := &stubPositions{positions: []models.Position{
+		{ID: "position1", UserID: "user1", Status: models.PositionStatusOpen, Quantity: 10, EntryPrice: 100},
+		{ID: "position2", UserID: "user2", Status: models.PositionStatusOpen, Quantity: 10, EntryPrice: 100},
+	}}
+	users := newStubUsers()
+	users.users["user1"] = &models.User{ID: "user1", Email: "user1@example.com"}
+	users.users["user2"] = &models.User{ID: "user2", Email: "user2@example.com"}
+	users.users["user3"] = &models.User{ID: "user3", Email: "user3@example.com"}
+	users.settings["user1"] = &models.UserSettings{UserID: "user1", TimeZone: "Asia/Kolkata"}
+	users.settings["user2"] = &models.UserSettings{UserID: "user2", TimeZone: "America/New_York"}
+	users.settings["user3"] = &models.UserSettings{UserID: "user3", TimeZone: "Asia/Kolkata"}
+	mailer := &recordingMailer{}
+
+	service := NewService(&stubOrders{}, positions, users, mailer, Config{Location: time.UTC})
+	for _, userID := range []string{"user1", "user2", "user3"} {
+		_, err := service.UpdateSubscription(userID, Subscription{Enabled: true})
+		require.NoError(t, err)
+	}
+	_, err := service.UpdateSubscription("user1", Subscription{Enabled: true, SendTime: "6pm"})
+	assert.ErrorIs(t, err, ErrInvalidSendTime)
+
+	subscription, err := service.GetSubscription("user2")
+	require.NoError(t, err)
+	assert.Equal(t, &Subscription{Enabled: true, SendTime: DefaultSendTime, TimeZone: "America/New_York"}, subscription)
+
+	// At 18:30 in Kolkata it is still morning in New York. The summary of user3,
+	// who had no activity, is not emailed.
+	now := time.Date(2024, 3, 14, 13, 0, 0, 0, time.UTC)
+	result, err := service.Run(now)
+	require.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Len(t, result.Deliveries, 2)
+	assert.Len(t, mailer.sent["user1@example.com"], 1)
+	assert.Empty(t, mailer.sent["user2@example.com"])
+	assert.Empty(t, mailer.sent["user3@example.com"])
+	assert.Equal(t, "2024-03-14", users.notifications["user1"].LastDailySummary)
+
+	// Summaries are sent once a day
+	result, err = service.Run(now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, result.Deliveries)
+
+	result, err = service.Run(time.Date(2024, 3, 14, 22, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Len(t, result.Deliveries, 1)
+	assert.Len(t, mailer.sent["user2@example.com"], 1)
+	assert.Len(t, mailer.sent["user1@example.com"], 1)
+
+	// Users opted out are not emailed
+	_, err = service.UpdateSubscription("user1", Subscription{Enabled: false})
+	require.NoError(t, err)
+	result, err = service.Run(time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Len(t, mailer.sent["user1@example.com"], 1)
+}
+
+func TestSMTPMailer(t *testing.T) {
+	mailer := NewSMTPMailer(SMTPConfig{Host: "smtp.example.com", Port: 587, From: "reports@example.com"})
+
+	var addr string
+	var message []byte
+	mailer.sendMail = func(a string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		addr, message = a, msg
+		return nil
+	}
+
+	err := mailer.Send("user1@example.com", &Email{Subject: "Daily summary", Text: "P&L +100.00", HTML: "<p>P&amp;L +100.00</p>"})
+	require.NoError(t, err)
+	assert.Equal(t, "smtp.example.com:587", addr)
+
+	content := string(message)
+	assert.Contains(t, content, "To: user1@example.com\r\n")
+	assert.Contains(t, content, "Content-Type: multipart/alternative; boundary=")
+	assert.Contains(t, content, "Content-Type: text/plain; charset=UTF-8")
+	assert.Contains(t, content, "Content-Type: text/html; charset=UTF-8")
+	assert.True(t, strings.Index(content, "P&L +100.00") < strings.Index(content, "<p>P&amp;L +100.00</p>"))
+}
@@ -0,0 +1,143 @@
+package summary
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"strings"
+	texttemplate "text/template"
+)
+
+// templateFuncs format the figures of summaries
+var templateFuncs = map[string]interface{}{
+	"money":  formatMoney,
+	"signed": formatSigned,
+	"number": func(value float64) string { return fmt.Sprintf("%.2f", value) },
+}
+
+// subjectTemplate is the subject of summary emails
+var subjectTemplate = texttemplate.Must(texttemplate.New("subject").Funcs(templateFuncs).Parse(
+	`Daily summary for {{.Date}}: net P&L {{signed .NetPnL}}`,
+))
+
+// textTemplate is the plain text body of summary emails
+var textTemplate = texttemplate.Must(texttemplate.New("text").Funcs(templateFuncs).Parse(`Hi {{.Name}},
+
+Here is your trading summary for {{.Date}} ({{.TimeZone}}).
+
+P&L
+  Realized:    {{signed .RealizedPnL}}
+  Unrealized:  {{signed .UnrealizedPnL}}
+  Fees (est.): {{money .Fees}}
+  Net:         {{signed .NetPnL}}
+
+Activity
+  Trades:       {{.TradeCount}}
+  Traded value: {{money .TradedValue}}
+{{range .Trades}}  {{.Time.Format "15:04"}} {{.Direction}} {{.Quantity}} {{.Symbol}} @ {{number .Price}}
+{{end}}
+Open risk
+  Open positions: {{len .OpenPositions}}
+  Gross exposure: {{money .GrossExposure}}
+  Open risk:      {{money .OpenRisk}}
+  Delta {{number .Greeks.Delta}}, gamma {{number .Greeks.Gamma}}, theta {{number .Greeks.Theta}}, vega {{number .Greeks.Vega}}
+{{range .OpenPositions}}  {{.Direction}} {{.Quantity}} {{.Symbol}} from {{number .EntryPrice}}, unrealized {{signed .UnrealizedPnL}}
+{{end}}
+Fees are estimates. You receive this email because you opted in to daily summaries.
+`))
+
+// htmlTemplate is the HTML body of summary emails, and the HTML report
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Daily summary for {{.Date}}</title></head>
+<body style="font-family: sans-serif; color: #222;">
+<p>Hi {{.Name}},</p>
+<p>Here is your trading summary for <strong>{{.Date}}</strong> ({{.TimeZone}}).</p>
+
+<h3>P&amp;L</h3>
+<table cellpadding="4">
+<tr><td>Realized</td><td align="right">{{signed .RealizedPnL}}</td></tr>
+<tr><td>Unrealized</td><td align="right">{{signed .UnrealizedPnL}}</td></tr>
+<tr><td>Fees (est.)</td><td align="right">{{money .Fees}}</td></tr>
+<tr><td><strong>Net</strong></td><td align="right"><strong>{{signed .NetPnL}}</strong></td></tr>
+</table>
+
+<h3>Activity</h3>
+<p>{{.TradeCount}} trades, {{money .TradedValue}} traded.</p>
+{{if .Trades}}<table cellpadding="4" border="1" style="border-collapse: collapse;">
+<tr><th>Time</th><th>Side</th><th>Quantity</th><th>Symbol</th><th>Price</th><th>Value</th></tr>
+{{range .Trades}}<tr><td>{{.Time.Format "15:04"}}</td><td>{{.Direction}}</td><td align="right">{{.Quantity}}</td><td>{{.Symbol}}</td><td align="right">{{number .Price}}</td><td align="right">{{money .Value}}</td></tr>
+{{end}}</table>{{end}}
+
+<h3>Open risk</h3>
+<table cellpadding="4">
+<tr><td>Open positions</td><td align="right">{{len .OpenPositions}}</td></tr>
+<tr><td>Gross exposure</td><td align="right">{{money .GrossExposure}}</td></tr>
+<tr><td>Open risk</td><td align="right">{{money .OpenRisk}}</td></tr>
+<tr><td>Delta / gamma / theta / vega</td><td align="right">{{number .Greeks.Delta}} / {{number .Greeks.Gamma}} / {{number .Greeks.Theta}} / {{number .Greeks.Vega}}</td></tr>
+</table>
+{{if .OpenPositions}}<table cellpadding="4" border="1" style="border-collapse: collapse;">
+<tr><th>Side</th><th>Quantity</th><th>Symbol</th><th>Entry</th><th>Unrealized</th></tr>
+{{range .OpenPositions}}<tr><td>{{.Direction}}</td><td align="right">{{.Quantity}}</td><td>{{.Symbol}}</td><td align="right">{{number .EntryPrice}}</td><td align="right">{{signed .UnrealizedPnL}}</td></tr>
+{{end}}</table>{{end}}
+
+<p style="color: #888; font-size: small;">Fees are estimates. You receive this email because you opted in to daily summaries.</p>
+</body>
+</html>
+`))
+
+// Email is a rendered summary
+type Email struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Render renders a summary as an email, with plain text and HTML bodies
+func Render(summary *Summary) (*Email, error) {
+	var subject, text, html bytes.Buffer
+	if err := subjectTemplate.Execute(&subject, summary); err != nil {
+		return nil, fmt.Errorf("failed to render summary subject: %w", err)
+	}
+	if err := textTemplate.Execute(&text, summary); err != nil {
+		return nil, fmt.Errorf("failed to render summary text: %w", err)
+	}
+	if err := htmlTemplate.Execute(&html, summary); err != nil {
+		return nil, fmt.Errorf("failed to render summary HTML: %w", err)
+	}
+
+	return &Email{
+		Subject: subject.String(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// formatMoney formats an amount with two decimals and thousands separators
+func formatMoney(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+	whole := fmt.Sprintf("%.2f", math.Abs(amount))
+	integer, fraction := whole[:len(whole)-3], whole[len(whole)-3:]
+
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return sign + grouped.String() + fraction
+}
+
+// formatSigned formats an amount as money, with a plus sign when positive
+func formatSigned(amount float64) string {
+	if amount > 0 {
+		return "+" + formatMoney(amount)
+	}
+	return formatMoney(amount)
+}
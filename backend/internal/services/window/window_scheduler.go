@@ -0,0 +1,346 @@
+package window
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/orderexecution"
+)
+
+const (
+	// DefaultInterval is how often the scheduler checks trading windows
+	DefaultInterval = 5 * time.Second
+
+	// DefaultEntryTimeout limits how long placing a portfolio at its start time may
+	// take
+	DefaultEntryTimeout = 30 * time.Second
+
+	// pageSize is the number of portfolios fetched at a time
+	pageSize = 100
+)
+
+// StrategyStore reads and updates strategies, as the strategy repository does
+type StrategyStore interface {
+	GetScheduled() ([]models.Strategy, error)
+	GetByID(id string) (*models.Strategy, error)
+	Update(strategy *models.Strategy) (*models.Strategy, error)
+}
+
+// PortfolioStore finds and updates portfolios, as the portfolio repository does
+type PortfolioStore interface {
+	Find(filter models.PortfolioFilter, page, limit int) ([]*models.Portfolio, int, error)
+	Update(portfolio *models.Portfolio) error
+}
+
+// BasketExecutor places the legs of a portfolio, as orderexecution.BasketExecutor
+// does
+type BasketExecutor interface {
+	Execute(ctx context.Context, portfolio *models.Portfolio) (*orderexecution.BasketResult, error)
+}
+
+// Calendar knows the days exchanges are open, as squareoff.HolidayCalendar does.
+// An empty exchange stands for every exchange.
+type Calendar interface {
+	IsTradingDay(exchange string, date time.Time) bool
+	Holiday(exchange string, date time.Time) (string, bool)
+}
+
+// weekdays is the calendar of exchanges open every weekday
+type weekdays struct{}
+
+func (weekdays) IsTradingDay(exchange string, date time.Time) bool {
+	return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday
+}
+
+func (weekdays) Holiday(exchange string, date time.Time) (string, bool) {
+	return "", false
+}
+
+// Config configures the window scheduler
+type Config struct {
+	Location     *time.Location // Time zone of run days and hours, defaults to the local time zone
+	Interval     time.Duration  // Between runs, defaults to DefaultInterval
+	EntryTimeout time.Duration  // Of placing each portfolio, defaults to DefaultEntryTimeout
+}
+
+// Entry is a portfolio placed by the scheduler at its start time
+type Entry struct {
+	PortfolioID string `json:"portfolioId"`
+	UserID      string `json:"userId"`
+	Version     int    `json:"version,omitempty"` // Configuration version of the portfolio placed
+	Placed      bool   `json:"placed"`
+	Error       string `json:"error,omitempty"`
+}
+
+// RunResult is the outcome of one run of the scheduler
+type RunResult struct {
+	Time        time.Time         `json:"time"`
+	Activated   []string          `json:"activated,omitempty"`   // IDs of the strategies whose window opened
+	Deactivated []string          `json:"deactivated,omitempty"` // IDs of the strategies whose window closed
+	Entries     []Entry           `json:"entries,omitempty"`
+	Holidays    map[string]string `json:"holidays,omitempty"` // Exchanges closed on the day
+	Errors      []string          `json:"errors,omitempty"`
+}
+
+// WindowScheduler runs strategies and portfolios within their trading windows: on
+// their run days, between their start and end time, on days their exchange is
+// open. Strategies with run days are activated when their window opens and set
+// back to scheduled when it closes, strategies paused, halted or stopped being left
+// alone. Active portfolios in start time execution mode are placed once a day when
+// their window opens, provided their strategy is active, and not after their end
+// time when the window was missed.
+type WindowScheduler struct {
+	strategies StrategyStore
+	portfolios PortfolioStore
+	executor   BasketExecutor
+	calendar   Calendar
+	config     Config
+	entered    map[string]string // Portfolio ID to the date it was placed
+	stop       chan struct{}
+	mutex      sync.Mutex
+}
+
+// NewWindowScheduler creates a new WindowScheduler. Without a calendar only
+// weekends are treated as closed.
+func NewWindowScheduler(strategies StrategyStore, portfolios PortfolioStore, executor BasketExecutor, calendar Calendar, config Config) *WindowScheduler {
+	if calendar == nil {
+		calendar = weekdays{}
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.EntryTimeout <= 0 {
+		config.EntryTimeout = DefaultEntryTimeout
+	}
+
+	return &WindowScheduler{
+		strategies: strategies,
+		portfolios: portfolios,
+		executor:   executor,
+		calendar:   calendar,
+		config:     config,
+		entered:    make(map[string]string),
+	}
+}
+
+// Start runs the scheduler every interval until it is stopped
+func (s *WindowScheduler) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stop != nil {
+		return errors.New("window scheduler is already running")
+	}
+	s.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				result, err := s.Run(now)
+				if err != nil {
+					log.Printf("Error running window scheduler: %v", err)
+					continue
+				}
+				for _, message := range result.Errors {
+					log.Printf("Window scheduler error: %s", message)
+				}
+			}
+		}
+	}(s.stop)
+
+	return nil
+}
+
+// Stop stops the scheduler
+func (s *WindowScheduler) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// Run activates and deactivates strategies whose window opened or closed by now,
+// then places the portfolios whose window is open at now
+func (s *WindowScheduler) Run(now time.Time) (*RunResult, error) {
+	now = now.In(s.config.Location)
+	result := &RunResult{
+		Time: now,
+	}
+
+	if err := s.scheduleStrategies(now, result); err != nil {
+		return nil, err
+	}
+	if err := s.enterPortfolios(now, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// scheduleStrategies moves the strategies with run days between active and
+// scheduled as their window opens and closes
+func (s *WindowScheduler) scheduleStrategies(now time.Time, result *RunResult) error {
+	strategies, err := s.strategies.GetScheduled()
+	if err != nil {
+		return fmt.Errorf("failed to get scheduled strategies: %w", err)
+	}
+
+	for i := range strategies {
+		strategy := &strategies[i]
+		open := strategy.RunsAt(now) && s.tradingDay("", now, result)
+
+		switch {
+		case open && strategy.Status == models.StrategyStatusScheduled:
+			strategy.Status = models.StrategyStatusActive
+		case !open && strategy.Status == models.StrategyStatusActive:
+			strategy.Status = models.StrategyStatusScheduled
+		default:
+			continue
+		}
+
+		strategy.UpdatedAt = now
+		if _, err := s.strategies.Update(strategy); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("strategy %s: %v", strategy.ID, err))
+			continue
+		}
+
+		if open {
+			result.Activated = append(result.Activated, strategy.ID)
+			log.Printf("Activated strategy %s (%s) as its window opened at %s", strategy.Name, strategy.ID, strategy.StartTime)
+		} else {
+			result.Deactivated = append(result.Deactivated, strategy.ID)
+			log.Printf("Deactivated strategy %s (%s) outside its window", strategy.Name, strategy.ID)
+		}
+	}
+
+	return nil
+}
+
+// enterPortfolios places the active portfolios in start time execution mode whose
+// window is open and that were not placed yet today
+func (s *WindowScheduler) enterPortfolios(now time.Time, result *RunResult) error {
+	date := now.Format("2006-01-02")
+
+	// Strategies are looked up once a run
+	strategies := make(map[string]*models.Strategy)
+
+	filter := models.PortfolioFilter{Status: models.PortfolioStatusActive}
+	for page := 1; ; page++ {
+		portfolios, total, err := s.portfolios.Find(filter, page, pageSize)
+		if err != nil {
+			return fmt.Errorf("failed to get active portfolios: %w", err)
+		}
+
+		for _, portfolio := range portfolios {
+			if portfolio.ExecutionMode != models.ExecutionModeTime || s.enteredOn(portfolio, date) {
+				continue
+			}
+			if !portfolio.RunsAt(now) || !s.tradingDay(portfolio.Exchange, now, result) {
+				continue
+			}
+
+			if portfolio.StrategyID != "" {
+				strategy, exists := strategies[portfolio.StrategyID]
+				if !exists {
+					strategy, err = s.strategies.GetByID(portfolio.StrategyID)
+					if err != nil {
+						result.Errors = append(result.Errors, fmt.Sprintf("strategy %s of portfolio %s: %v", portfolio.StrategyID, portfolio.ID, err))
+					}
+					strategies[portfolio.StrategyID] = strategy
+				}
+				if strategy == nil || strategy.Status != models.StrategyStatusActive {
+					continue
+				}
+			}
+
+			result.Entries = append(result.Entries, s.enter(portfolio, now, result))
+		}
+
+		if len(portfolios) == 0 || page*pageSize >= total {
+			break
+		}
+	}
+
+	return nil
+}
+
+// enter places a portfolio once for the day, whatever the outcome, so that legs
+// already placed are not placed again
+func (s *WindowScheduler) enter(portfolio *models.Portfolio, now time.Time, result *RunResult) Entry {
+	s.mutex.Lock()
+	s.entered[portfolio.ID] = now.Format("2006-01-02")
+	s.mutex.Unlock()
+
+	entry := Entry{PortfolioID: portfolio.ID, UserID: portfolio.UserID, Version: portfolio.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.EntryTimeout)
+	basket, err := s.executor.Execute(ctx, portfolio)
+	cancel()
+
+	switch {
+	case err != nil:
+		entry.Error = err.Error()
+	case !basket.Status:
+		entry.Error = basket.Error
+	default:
+		entry.Placed = true
+	}
+
+	portfolio.ExecutionStartTime = now
+	if entry.Placed {
+		portfolio.AddExecutionLog("Entered at start time " + portfolio.StartTime)
+		log.Printf("Entered portfolio %s of user %s at start time %s", portfolio.ID, portfolio.UserID, portfolio.StartTime)
+	} else {
+		portfolio.AddExecutionLog("Entry at start time " + portfolio.StartTime + " failed: " + entry.Error)
+		log.Printf("Portfolio %s of user %s failed to be entered at start time %s: %s", portfolio.ID, portfolio.UserID, portfolio.StartTime, entry.Error)
+	}
+	if err := s.portfolios.Update(portfolio); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("portfolio %s: %v", portfolio.ID, err))
+	}
+
+	return entry
+}
+
+// enteredOn checks if a portfolio was placed on a date, by this scheduler or
+// before it started
+func (s *WindowScheduler) enteredOn(portfolio *models.Portfolio, date string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.entered[portfolio.ID] == date {
+		return true
+	}
+	return !portfolio.ExecutionStartTime.IsZero() && portfolio.ExecutionStartTime.In(s.config.Location).Format("2006-01-02") == date
+}
+
+// tradingDay checks if an exchange is open on the day of now, recording the
+// holiday with the result when it is not
+func (s *WindowScheduler) tradingDay(exchange string, now time.Time, result *RunResult) bool {
+	if s.calendar.IsTradingDay(exchange, now) {
+		return true
+	}
+
+	if name, holiday := s.calendar.Holiday(exchange, now); holiday {
+		if result.Holidays == nil {
+			result.Holidays = make(map[string]string)
+		}
+		result.Holidays[exchange] = name
+	}
+	return false
+}
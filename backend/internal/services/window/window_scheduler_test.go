@@ -0,0 +1,161 @@
+package window
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/orderexecution"
+	"github.com/trading-platform/backend/internal/services/squareoff"
+)
+
+// stubStrategies is a strategy store of strategies by ID
+type stubStrategies map[string]*models.Strategy
+
+func (s stubStrategies) GetScheduled() ([]models.Strategy, error) {
+	var strategies []models.Strategy
+	for _, strategy := range s {
+		if strategy.IsScheduled() {
+			strategies = append(strategies, *strategy)
+		}
+	}
+	return strategies, nil
+}
+
+func (s stubStrategies) GetByID(id string) (*models.Strategy, error) {
+	strategy, exists := s[id]
+	if !exists {
+		return nil, errors.New("strategy not found")
+	}
+	copied := *strategy
+	return &copied, nil
+}
+
+func (s stubStrategies) Update(strategy *models.Strategy) (*models.Strategy, error) {
+	copied := *strategy
+	s[strategy.ID] = &copied
+	return strategy, nil
+}
+
+// stubPortfolios is a portfolio store of portfolios by ID
+type stubPortfolios map[string]*models.Portfolio
+
+func (s stubPortfolios) Find(filter models.PortfolioFilter, page, limit int) ([]*models.Portfolio, int, error) {
+	var portfolios []*models.Portfolio
+	for _, portfolio := range s {
+		if portfolio.Status == filter.Status {
+			copied := *portfolio
+			portfolios = append(portfolios, &copied)
+		}
+	}
+	return portfolios, len(portfolios), nil
+}
+
+func (s stubPortfolios) Update(portfolio *models.Portfolio) error {
+	copied := *portfolio
+	s[portfolio.ID] = &copied
+	return nil
+}
+
+// recordingExecutor records the portfolios it places, failing those in failing
+type recordingExecutor struct {
+	placed  []string
+	failing map[string]bool
+	mutex   sync.Mutex
+}
+
+func (e *recordingExecutor) Execute(ctx context.Context, portfolio *models.Portfolio) (*orderexecution.BasketResult, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.placed = append(e.placed, portfolio.ID)
+	if e.failing[portfolio.ID] {
+		return &orderexecution.BasketResult{PortfolioID: portfolio.ID, Error: "leg rejected"}, nil
+	}
+	return &orderexecution.BasketResult{PortfolioID: portfolio.ID, Status: true}, nil
+}
+
+func windowPortfolio(id, strategyID string, mode models.ExecutionMode, start, end string) *models.Portfolio {
+	return &models.Portfolio{
+		ID:            id,
+		UserID:        "user1",
+		StrategyID:    strategyID,
+		Status:        models.PortfolioStatusActive,
+		Exchange:      "NSE",
+		ExecutionMode: mode,
+		RunOnDays:     []string{"MONDAY", "TUESDAY"},
+		StartTime:     start,
+		EndTime:       end,
+	}
+}
+
+func TestWindowScheduler(t *testing.T) {
+	strategies := stubStrategies{
+		"strategy1": {ID: "strategy1", Name: "Opening straddle", Status: models.StrategyStatusScheduled, RunOnDays: []string{"MONDAY", "TUESDAY"}, StartTime: "09:20:00", EndTime: "15:00:00"},
+		"strategy2": {ID: "strategy2", Name: "Paused", Status: models.StrategyStatusPaused, RunOnDays: []string{"MONDAY"}, StartTime: "09:20:00", EndTime: "15:00:00"},
+	}
+	portfolios := stubPortfolios{
+		"straddle": windowPortfolio("straddle", "strategy1", models.ExecutionModeTime, "09:20:00", "09:30:00"),
+		"paused":   windowPortfolio("paused", "strategy2", models.ExecutionModeTime, "09:20:00", "09:30:00"),
+		"signal":   windowPortfolio("signal", "", models.ExecutionModeSignal, "09:20:00", "09:30:00"),
+		"failing":  windowPortfolio("failing", "", models.ExecutionModeTime, "09:20:00", "09:30:00"),
+		"late":     windowPortfolio("late", "", models.ExecutionModeTime, "09:00:00", "09:10:00"),
+	}
+	executor := &recordingExecutor{failing: map[string]bool{"failing": true}}
+	calendar := squareoff.NewHolidayCalendar()
+	calendar.AddHoliday("NSE", time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC), "Dussehra")
+	scheduler := NewWindowScheduler(strategies, portfolios, executor, calendar, Config{Location: time.UTC})
+
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+
+	// Nothing runs before the windows open
+	result, err := scheduler.Run(monday.Add(9*time.Hour + 15*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, result.Activated)
+	assert.Empty(t, result.Entries)
+
+	// The strategy is activated and its portfolio placed as their window opens,
+	// failures being placed only once. The scheduler starting after a window
+	// closed does not place its portfolio.
+	result, err = scheduler.Run(monday.Add(9*time.Hour + 20*time.Minute + 5*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"strategy1"}, result.Activated)
+	assert.Equal(t, models.StrategyStatusActive, strategies["strategy1"].Status)
+	assert.Equal(t, models.StrategyStatusPaused, strategies["strategy2"].Status)
+	assert.ElementsMatch(t, []string{"straddle", "failing"}, executor.placed)
+	assert.ElementsMatch(t, []Entry{
+		{PortfolioID: "straddle", UserID: "user1", Placed: true},
+		{PortfolioID: "failing", UserID: "user1", Error: "leg rejected"},
+	}, result.Entries)
+	assert.False(t, portfolios["straddle"].ExecutionStartTime.IsZero())
+	assert.Len(t, portfolios["failing"].ExecutionLogs, 1)
+
+	result, err = scheduler.Run(monday.Add(9*time.Hour + 25*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, result.Entries)
+
+	// Placed portfolios are not placed again by a restarted scheduler
+	restarted := NewWindowScheduler(strategies, portfolios, executor, calendar, Config{Location: time.UTC})
+	result, err = restarted.Run(monday.Add(9*time.Hour + 26*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, result.Entries)
+
+	// The strategy is deactivated once its window closes
+	result, err = scheduler.Run(monday.Add(15*time.Hour + time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"strategy1"}, result.Deactivated)
+	assert.Equal(t, models.StrategyStatusScheduled, strategies["strategy1"].Status)
+
+	// Portfolios are not placed on days their exchange is closed
+	result, err = scheduler.Run(monday.Add(24*time.Hour + 9*time.Hour + 20*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"strategy1"}, result.Activated)
+	assert.Empty(t, result.Entries)
+	assert.Equal(t, map[string]string{"NSE": "Dussehra"}, result.Holidays)
+	assert.Len(t, executor.placed, 2)
+}
@@ -0,0 +1,297 @@
+package signals
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// ConditionTypeSignal is the type of the strategy entry conditions evaluated
+// against the signals entering the strategy's portfolios. Their parameter is one
+// of the signal's action, ticker, symbol, exchange, comment or price, compared with
+// == or != (and contains for text, >, >=, < and <= for the price).
+const ConditionTypeSignal = "SIGNAL"
+
+// StrategyProvider returns strategies by ID, as the strategy service does
+type StrategyProvider interface {
+	GetStrategyByID(id string) (*models.Strategy, error)
+}
+
+// Router routes signals to the portfolios they enter and launches the placing of
+// them. A signal matches the active portfolios of its webhook's user in signal
+// execution mode trading its instrument, and enters those whose entry conditions
+// hold: the portfolio runs at the time the signal is received, and its strategy,
+// when it has one, is active with its signal entry conditions holding for the
+// signal. Portfolios still being placed for an earlier signal are not placed
+// again.
+type Router struct {
+	portfolios PortfolioProvider
+	strategies StrategyProvider
+	executor   BasketExecutor
+	timeout    time.Duration
+	placing    map[string]bool // Portfolios being placed, by ID
+	executions sync.WaitGroup
+	mutex      sync.Mutex
+}
+
+// NewRouter creates a new Router placing portfolios through executor, each within
+// timeout
+func NewRouter(portfolios PortfolioProvider, executor BasketExecutor, timeout time.Duration) *Router {
+	return &Router{
+		portfolios: portfolios,
+		executor:   executor,
+		timeout:    timeout,
+		placing:    make(map[string]bool),
+	}
+}
+
+// SetStrategies sets where the strategies of portfolios are read from. The state
+// and entry conditions of strategies are not applied until it is set.
+func (r *Router) SetStrategies(strategies StrategyProvider) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.strategies = strategies
+}
+
+// Route returns the portfolios a signal enters, only the one of portfolioID when
+// it is given. The portfolios it matches but does not enter are added to the
+// signal's skipped portfolios.
+func (r *Router) Route(signal *Signal, portfolioID string) ([]*models.Portfolio, error) {
+	matched, err := r.match(signal, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	strategies := r.strategies
+	r.mutex.Unlock()
+
+	var entered []*models.Portfolio
+	for _, portfolio := range matched {
+		if !portfolio.ShouldExecuteOnSignal(signal.ReceivedAt) {
+			signal.skip(portfolio.ID, "portfolio does not run at this time")
+			continue
+		}
+		if portfolio.StrategyID != "" && strategies != nil {
+			strategy, err := strategies.GetStrategyByID(portfolio.StrategyID)
+			if err != nil {
+				signal.skip(portfolio.ID, fmt.Sprintf("strategy %s not found", portfolio.StrategyID))
+				continue
+			}
+			if strategy.Status != models.StrategyStatusActive {
+				signal.skip(portfolio.ID, fmt.Sprintf("strategy %s is %s", strategy.Name, strings.ToLower(string(strategy.Status))))
+				continue
+			}
+			if err := checkConditions(strategy.EntryConditions, signal); err != nil {
+				signal.skip(portfolio.ID, err.Error())
+				continue
+			}
+		}
+		entered = append(entered, portfolio)
+	}
+
+	return entered, nil
+}
+
+// Launch places the portfolios a signal entered in the background, one at a time,
+// passing the signal with the outcome of each to done once they are placed
+func (r *Router) Launch(signal Signal, portfolios []*models.Portfolio, done func(signal Signal)) {
+	r.executions.Add(1)
+	go func() {
+		defer r.executions.Done()
+
+		r.place(&signal, portfolios)
+		done(signal)
+	}()
+}
+
+// Wait waits for the portfolios launched so far to be placed
+func (r *Router) Wait() {
+	r.executions.Wait()
+}
+
+// match returns the active portfolios of a signal's user in signal execution mode
+// trading its instrument, only the one of portfolioID when it is given
+func (r *Router) match(signal *Signal, portfolioID string) ([]*models.Portfolio, error) {
+	filter := models.PortfolioFilter{
+		UserID: signal.UserID,
+		Status: models.PortfolioStatusActive,
+		Symbol: signal.Symbol,
+	}
+
+	var matched []*models.Portfolio
+	for page := 1; ; page++ {
+		batch, total, err := r.portfolios.Find(filter, page, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, portfolio := range batch {
+			if portfolio.ExecutionMode != models.ExecutionModeSignal ||
+				(signal.Exchange != "" && portfolio.Exchange != "" && !strings.EqualFold(portfolio.Exchange, signal.Exchange)) ||
+				(portfolioID != "" && portfolio.ID != portfolioID) {
+				continue
+			}
+			matched = append(matched, portfolio)
+		}
+		if len(batch) == 0 || page*pageSize >= total {
+			break
+		}
+	}
+
+	return matched, nil
+}
+
+// place places the portfolios a signal entered, recording the outcome with the
+// signal
+func (r *Router) place(signal *Signal, portfolios []*models.Portfolio) {
+	for _, portfolio := range portfolios {
+		if !r.claim(portfolio.ID) {
+			signal.skip(portfolio.ID, "portfolio is being placed for an earlier signal")
+			continue
+		}
+
+		execution := Execution{PortfolioID: portfolio.ID, Version: portfolio.Version}
+		ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+		result, err := r.executor.Execute(ctx, portfolio)
+		cancel()
+		r.release(portfolio.ID)
+
+		switch {
+		case err != nil:
+			execution.Error = err.Error()
+		case !result.Status:
+			execution.Error = result.Error
+		default:
+			execution.Placed = true
+		}
+		if !execution.Placed {
+			log.Printf("Portfolio %s entered by signal %s failed to be placed: %s", portfolio.ID, signal.ID, execution.Error)
+		}
+		signal.Executions = append(signal.Executions, execution)
+	}
+
+	signal.Status = SignalSkipped
+	for _, execution := range signal.Executions {
+		if !execution.Placed {
+			signal.Status = SignalFailed
+			break
+		}
+		signal.Status = SignalExecuted
+	}
+}
+
+// claim marks a portfolio as being placed, reporting false when it already is
+func (r *Router) claim(portfolioID string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.placing[portfolioID] {
+		return false
+	}
+	r.placing[portfolioID] = true
+	return true
+}
+
+// release marks a portfolio as placed
+func (r *Router) release(portfolioID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.placing, portfolioID)
+}
+
+// checkConditions returns an error naming the first of the signal entry
+// conditions among conditions that does not hold for a signal. Conditions of
+// other types are evaluated elsewhere, and ignored.
+func checkConditions(conditions []models.Condition, signal *Signal) error {
+	for _, condition := range conditions {
+		if !strings.EqualFold(condition.Type, ConditionTypeSignal) {
+			continue
+		}
+
+		holds, err := conditionHolds(condition, signal)
+		if err != nil {
+			return err
+		}
+		if !holds {
+			return fmt.Errorf("entry condition %s %s %v does not hold", condition.Parameter, condition.Operator, condition.Value)
+		}
+	}
+	return nil
+}
+
+// conditionHolds reports whether a signal entry condition holds for a signal
+func conditionHolds(condition models.Condition, signal *Signal) (bool, error) {
+	var text string
+	switch strings.ToLower(condition.Parameter) {
+	case "price":
+		value, ok := number(condition.Value)
+		if !ok {
+			return false, fmt.Errorf("invalid entry condition: price compared to %v", condition.Value)
+		}
+		switch condition.Operator {
+		case ">":
+			return signal.Price > value, nil
+		case ">=":
+			return signal.Price >= value, nil
+		case "<":
+			return signal.Price < value, nil
+		case "<=":
+			return signal.Price <= value, nil
+		case "==":
+			return signal.Price == value, nil
+		case "!=":
+			return signal.Price != value, nil
+		}
+		return false, fmt.Errorf("invalid entry condition: unknown operator %q", condition.Operator)
+	case "action":
+		text = string(signal.Action)
+	case "ticker":
+		text = signal.Ticker
+	case "symbol":
+		text = signal.Symbol
+	case "exchange":
+		text = signal.Exchange
+	case "comment":
+		text = signal.Comment
+	default:
+		return false, fmt.Errorf("invalid entry condition: unknown parameter %q", condition.Parameter)
+	}
+
+	value := fmt.Sprint(condition.Value)
+	switch condition.Operator {
+	case "==":
+		return strings.EqualFold(text, value), nil
+	case "!=":
+		return !strings.EqualFold(text, value), nil
+	case "contains":
+		return strings.Contains(strings.ToLower(text), strings.ToLower(value)), nil
+	}
+	return false, fmt.Errorf("invalid entry condition: unknown operator %q", condition.Operator)
+}
+
+// number returns the value of a condition as a number, as decoded from JSON or
+// BSON
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		parsed, err := strconv.ParseFloat(v, 64)
+		return parsed, err == nil
+	}
+	return 0, false
+}
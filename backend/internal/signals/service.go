@@ -0,0 +1,276 @@
+package signals
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/orderexecution"
+)
+
+const (
+	// DefaultMaxSkew is how far the time a signal was sent may be from the time it
+	// is received
+	DefaultMaxSkew = 5 * time.Minute
+
+	// DefaultExecuteTimeout limits how long placing a portfolio a signal triggered
+	// may take
+	DefaultExecuteTimeout = 30 * time.Second
+
+	// HeaderSecret is the header the webhook's secret may be sent in, instead of the
+	// payload's passphrase
+	HeaderSecret = "X-Signal-Secret"
+
+	// pageSize is the number of portfolios read at a time
+	pageSize = 100
+)
+
+// PortfolioProvider finds users' portfolios, as the portfolio repository does
+type PortfolioProvider interface {
+	Find(filter models.PortfolioFilter, page, limit int) ([]*models.Portfolio, int, error)
+}
+
+// BasketExecutor places the legs of a portfolio, as orderexecution.BasketExecutor
+// does
+type BasketExecutor interface {
+	Execute(ctx context.Context, portfolio *models.Portfolio) (*orderexecution.BasketResult, error)
+}
+
+// Config configures how signals are received
+type Config struct {
+	MaxSkew        time.Duration // Signals sent longer ago, or later, are stale. Defaults to DefaultMaxSkew.
+	ExecuteTimeout time.Duration // Of placing each portfolio, defaults to DefaultExecuteTimeout
+}
+
+// Service registers signal webhooks and receives the signals posted to them,
+// routing each to the portfolios it enters. Those are placed in the background, so
+// that the tools posting signals, which wait only seconds, are answered at once.
+type Service struct {
+	store  Store
+	router *Router
+	config Config
+	mutex  sync.Mutex // Serializes replay checks
+}
+
+// NewService creates a new Service
+func NewService(store Store, portfolios PortfolioProvider, executor BasketExecutor, config Config) *Service {
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = DefaultMaxSkew
+	}
+	if config.ExecuteTimeout <= 0 {
+		config.ExecuteTimeout = DefaultExecuteTimeout
+	}
+
+	return &Service{
+		store:  store,
+		router: NewRouter(portfolios, executor, config.ExecuteTimeout),
+		config: config,
+	}
+}
+
+// SetStrategies sets where the strategies of portfolios are read from, for their
+// state and signal entry conditions to apply to the signals entering portfolios
+func (s *Service) SetStrategies(strategies StrategyProvider) {
+	s.router.SetStrategies(strategies)
+}
+
+// CreateHook registers a signal webhook for a user
+func (s *Service) CreateHook(userID string, request HookRequest) (*Hook, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		generated, err := generateSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+
+	now := time.Now()
+	hook := &Hook{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      strings.TrimSpace(request.Name),
+		Secret:    secret,
+		Symbols:   append([]SymbolMapping{}, request.Symbols...),
+		Active:    request.Active == nil || *request.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.store.SaveHook(hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// GetHook returns a signal webhook by ID, with its secret
+func (s *Service) GetHook(id string) (*Hook, error) {
+	return s.store.GetHook(id)
+}
+
+// ListHooks returns the signal webhooks of a user, every webhook when userID is
+// empty
+func (s *Service) ListHooks(userID string) ([]Hook, error) {
+	return s.store.ListHooks(userID)
+}
+
+// UpdateHook changes the name, secret, symbol mappings or state of a signal
+// webhook
+func (s *Service) UpdateHook(id string, request HookRequest) (*Hook, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	hook, err := s.store.GetHook(id)
+	if err != nil {
+		return nil, err
+	}
+	hook.Name = strings.TrimSpace(request.Name)
+	hook.Symbols = append([]SymbolMapping{}, request.Symbols...)
+	if request.Secret != "" {
+		hook.Secret = request.Secret
+	}
+	if request.Active != nil {
+		hook.Active = *request.Active
+	}
+	hook.UpdatedAt = time.Now()
+
+	if err := s.store.SaveHook(hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// DeleteHook removes a signal webhook along with its signals
+func (s *Service) DeleteHook(id string) error {
+	return s.store.DeleteHook(id)
+}
+
+// ListSignals returns the signals selected by a filter, newest first
+func (s *Service) ListSignals(filter SignalFilter) ([]Signal, error) {
+	return s.store.ListSignals(filter)
+}
+
+// Receive handles the body of a signal posted to a webhook at now. secret is the
+// one sent in HeaderSecret, the payload's passphrase being used when it is empty.
+// The portfolios of the webhook's user the signal enters, as routed by the
+// Router, are placed in the background.
+func (s *Service) Receive(hookID string, body []byte, secret string, now time.Time) (*Signal, error) {
+	hook, err := s.store.GetHook(hookID)
+	if errors.Is(err, ErrHookNotFound) {
+		return nil, ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignal, err)
+	}
+	if secret == "" {
+		secret = payload.Passphrase
+	}
+	if !hook.Active || subtle.ConstantTimeCompare([]byte(secret), []byte(hook.Secret)) != 1 {
+		return nil, ErrUnauthorized
+	}
+	if err := payload.Validate(); err != nil {
+		return nil, err
+	}
+	if skew := now.Sub(payload.Time); skew > s.config.MaxSkew || skew < -s.config.MaxSkew {
+		return nil, fmt.Errorf("%w: sent at %s", ErrStaleSignal, payload.Time.Format(time.RFC3339))
+	}
+
+	// Signals without an ID are told apart by their body, which holds the time
+	// they were sent
+	key := payload.ID
+	if key == "" {
+		sum := sha256.Sum256(body)
+		key = hex.EncodeToString(sum[:])
+	}
+
+	symbol, exchange := hook.Instrument(strings.TrimSpace(payload.Ticker), payload.Exchange)
+	signal := &Signal{
+		ID:         uuid.New().String(),
+		HookID:     hook.ID,
+		UserID:     hook.UserID,
+		Key:        key,
+		Ticker:     payload.Ticker,
+		Symbol:     symbol,
+		Exchange:   exchange,
+		Action:     Action(strings.ToLower(string(payload.Action))),
+		Price:      payload.Price,
+		Comment:    payload.Comment,
+		SentAt:     payload.Time,
+		ReceivedAt: now,
+		Status:     SignalAccepted,
+	}
+
+	portfolios, err := s.router.Route(signal, payload.Portfolio)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find portfolios: %w", err)
+	}
+	switch {
+	case len(portfolios) > 0:
+	case len(signal.Skipped) > 0:
+		signal.Status = SignalSkipped
+	default:
+		signal.Status = SignalNoMatch
+	}
+
+	if err := s.record(signal); err != nil {
+		return nil, err
+	}
+	hook.LastSignalAt = now
+	if err := s.store.SaveHook(hook); err != nil {
+		log.Printf("Error saving signal webhook %s: %v", hook.ID, err)
+	}
+
+	log.Printf("Signal %s %s %s received on webhook %s of user %s, entering %d portfolios", signal.ID, signal.Action, signal.Symbol, hook.ID, hook.UserID, len(portfolios))
+	if len(portfolios) > 0 {
+		s.router.Launch(*signal, portfolios, s.placed)
+	}
+
+	return signal, nil
+}
+
+// Wait waits for the portfolios entered by the signals received so far to be
+// placed
+func (s *Service) Wait() {
+	s.router.Wait()
+}
+
+// record saves a signal unless one with its key was already received
+func (s *Service) record(signal *Signal) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	received, err := s.store.ListSignals(SignalFilter{HookID: signal.HookID, Key: signal.Key, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(received) > 0 {
+		return ErrReplayedSignal
+	}
+	return s.store.SaveSignal(signal)
+}
+
+// placed saves a signal once the portfolios it entered are placed
+func (s *Service) placed(signal Signal) {
+	if err := s.store.SaveSignal(&signal); err != nil {
+		log.Printf("Error saving signal %s: %v", signal.ID, err)
+	}
+}
@@ -0,0 +1,233 @@
+// Package signals ingests the trading signals external tools post to users'
+// signal webhooks, such as TradingView alerts, and routes them to the portfolios
+// users run in signal execution mode whose entry conditions hold. Signals
+// authenticate with the webhook's shared secret, are rejected when stale or
+// replayed, and are logged with the portfolios they entered for users to inspect.
+package signals
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// minSecretLength is the length of the shortest secret accepted
+const minSecretLength = 16
+
+var (
+	// ErrHookNotFound is returned when a signal webhook does not exist
+	ErrHookNotFound = errors.New("signal webhook not found")
+	// ErrInvalidHook is returned for signal webhooks that cannot be registered
+	ErrInvalidHook = errors.New("invalid signal webhook")
+	// ErrUnauthorized is returned for signals without the webhook's secret, or to
+	// webhooks that do not exist or are inactive
+	ErrUnauthorized = errors.New("signal not authorized")
+	// ErrInvalidSignal is returned for signals whose payload cannot be acted on
+	ErrInvalidSignal = errors.New("invalid signal")
+	// ErrStaleSignal is returned for signals sent too long ago, or in the future
+	ErrStaleSignal = errors.New("signal is stale")
+	// ErrReplayedSignal is returned for signals already received
+	ErrReplayedSignal = errors.New("signal already received")
+)
+
+// Action is what a signal asks for, as TradingView's {{strategy.order.action}}
+// gives it
+type Action string
+
+const (
+	ActionBuy  Action = "buy"
+	ActionSell Action = "sell"
+)
+
+// SymbolMapping maps a ticker signals name to the instrument of the platform it
+// stands for, such as TradingView's NIFTY1! to NIFTY on NFO
+type SymbolMapping struct {
+	Ticker   string `json:"ticker" bson:"ticker"`
+	Symbol   string `json:"symbol" bson:"symbol"`
+	Exchange string `json:"exchange,omitempty" bson:"exchange,omitempty"` // The signal's when empty
+}
+
+// Hook is a URL a user posts signals to, triggering their portfolios in signal
+// execution mode
+type Hook struct {
+	ID           string          `json:"id" bson:"_id"`
+	UserID       string          `json:"userId" bson:"userId"`
+	Name         string          `json:"name" bson:"name"`
+	Secret       string          `json:"secret,omitempty" bson:"secret"` // Only returned when the webhook is created
+	Symbols      []SymbolMapping `json:"symbols" bson:"symbols"`
+	Active       bool            `json:"active" bson:"active"`
+	CreatedAt    time.Time       `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt" bson:"updatedAt"`
+	LastSignalAt time.Time       `json:"lastSignalAt,omitempty" bson:"lastSignalAt,omitempty"`
+}
+
+// Instrument returns the symbol and exchange a signal's ticker stands for, by the
+// hook's symbol mappings. Tickers without a mapping stand for themselves, those
+// prefixed with an exchange such as NSE:NIFTY for the symbol on that exchange.
+func (h *Hook) Instrument(ticker, exchange string) (string, string) {
+	for _, mapping := range h.Symbols {
+		if strings.EqualFold(mapping.Ticker, ticker) {
+			if mapping.Exchange != "" {
+				exchange = mapping.Exchange
+			}
+			return mapping.Symbol, exchange
+		}
+	}
+	if prefix, symbol, found := strings.Cut(ticker, ":"); found {
+		return symbol, prefix
+	}
+	return ticker, exchange
+}
+
+// HookRequest registers or changes a signal webhook. A secret is generated when
+// none is given on registration, and kept when none is given on change.
+type HookRequest struct {
+	Name    string          `json:"name"`
+	Secret  string          `json:"secret"`
+	Symbols []SymbolMapping `json:"symbols"`
+	Active  *bool           `json:"active"` // Active when not given
+}
+
+// Validate checks the request is valid
+func (r *HookRequest) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidHook)
+	}
+	if r.Secret != "" && len(r.Secret) < minSecretLength {
+		return fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidHook, minSecretLength)
+	}
+
+	tickers := make(map[string]bool, len(r.Symbols))
+	for _, mapping := range r.Symbols {
+		if mapping.Ticker == "" || mapping.Symbol == "" {
+			return fmt.Errorf("%w: symbol mappings need a ticker and a symbol", ErrInvalidHook)
+		}
+		ticker := strings.ToUpper(mapping.Ticker)
+		if tickers[ticker] {
+			return fmt.Errorf("%w: ticker %s is mapped twice", ErrInvalidHook, mapping.Ticker)
+		}
+		tickers[ticker] = true
+	}
+
+	return nil
+}
+
+// Payload is the body of the signals posted to webhooks, in the shape of a
+// TradingView alert message such as:
+//
+//	{"passphrase": "...", "ticker": "{{ticker}}", "exchange": "{{exchange}}",
+//	 "action": "{{strategy.order.action}}", "price": {{close}}, "time": "{{timenow}}"}
+type Payload struct {
+	Passphrase string    `json:"passphrase"` // The webhook's secret, unless sent in HeaderSecret
+	ID         string    `json:"id"`         // Unique to the signal, its body's hash when not given
+	Ticker     string    `json:"ticker"`
+	Exchange   string    `json:"exchange"`
+	Action     Action    `json:"action"`
+	Price      float64   `json:"price"`
+	Time       time.Time `json:"time"`      // When it was sent, RFC 3339
+	Portfolio  string    `json:"portfolio"` // ID of the only portfolio to trigger, optional
+	Comment    string    `json:"comment"`
+}
+
+// Validate checks the payload is valid
+func (p *Payload) Validate() error {
+	if strings.TrimSpace(p.Ticker) == "" {
+		return fmt.Errorf("%w: ticker is required", ErrInvalidSignal)
+	}
+	switch Action(strings.ToLower(string(p.Action))) {
+	case ActionBuy, ActionSell:
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidSignal, p.Action)
+	}
+	if p.Price < 0 {
+		return fmt.Errorf("%w: price cannot be negative", ErrInvalidSignal)
+	}
+	if p.Time.IsZero() {
+		return fmt.Errorf("%w: time is required", ErrInvalidSignal)
+	}
+	return nil
+}
+
+// SignalStatus is the outcome of a signal
+type SignalStatus string
+
+const (
+	SignalAccepted SignalStatus = "ACCEPTED" // Placing the portfolios it entered
+	SignalNoMatch  SignalStatus = "NO_MATCH" // No portfolio in signal execution mode trades its instrument
+	SignalSkipped  SignalStatus = "SKIPPED"  // The entry conditions of no portfolio it matched held
+	SignalExecuted SignalStatus = "EXECUTED" // Every portfolio it entered was placed
+	SignalFailed   SignalStatus = "FAILED"   // A portfolio it entered failed to be placed
+)
+
+// Execution is the placing of a portfolio a signal entered
+type Execution struct {
+	PortfolioID string `json:"portfolioId" bson:"portfolioId"`
+	Version     int    `json:"version,omitempty" bson:"version,omitempty"` // Configuration version of the portfolio placed
+	Placed      bool   `json:"placed" bson:"placed"`                       // Whether every leg was placed
+	Error       string `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// Skip is a portfolio a signal matched but did not enter
+type Skip struct {
+	PortfolioID string `json:"portfolioId" bson:"portfolioId"`
+	Reason      string `json:"reason" bson:"reason"`
+}
+
+// Signal is a signal a webhook received, logged with the portfolios it entered
+type Signal struct {
+	ID         string       `json:"id" bson:"_id"`
+	HookID     string       `json:"hookId" bson:"hookId"`
+	UserID     string       `json:"userId" bson:"userId"`
+	Key        string       `json:"key" bson:"key"` // Identifying it against replays
+	Ticker     string       `json:"ticker" bson:"ticker"`
+	Symbol     string       `json:"symbol" bson:"symbol"`
+	Exchange   string       `json:"exchange,omitempty" bson:"exchange,omitempty"`
+	Action     Action       `json:"action" bson:"action"`
+	Price      float64      `json:"price,omitempty" bson:"price,omitempty"`
+	Comment    string       `json:"comment,omitempty" bson:"comment,omitempty"`
+	SentAt     time.Time    `json:"sentAt" bson:"sentAt"`
+	ReceivedAt time.Time    `json:"receivedAt" bson:"receivedAt"`
+	Status     SignalStatus `json:"status" bson:"status"`
+	Executions []Execution  `json:"executions,omitempty" bson:"executions,omitempty"`
+	Skipped    []Skip       `json:"skipped,omitempty" bson:"skipped,omitempty"`
+}
+
+// skip records that the signal did not enter a portfolio it matched
+func (s *Signal) skip(portfolioID, reason string) {
+	s.Skipped = append(s.Skipped, Skip{PortfolioID: portfolioID, Reason: reason})
+}
+
+// SignalFilter selects signals, empty fields matching every signal
+type SignalFilter struct {
+	HookID string
+	Key    string
+	Limit  int // Of the signals returned, newest first
+}
+
+// Matches reports whether a signal is selected by the filter
+func (f SignalFilter) Matches(signal Signal) bool {
+	return (f.HookID == "" || signal.HookID == f.HookID) &&
+		(f.Key == "" || signal.Key == f.Key)
+}
+
+// Store persists signal webhooks and the signals they receive
+type Store interface {
+	SaveHook(hook *Hook) error
+	GetHook(id string) (*Hook, error)
+	ListHooks(userID string) ([]Hook, error) // Every webhook when userID is empty
+	DeleteHook(id string) error
+	SaveSignal(signal *Signal) error
+	ListSignals(filter SignalFilter) ([]Signal, error)
+}
+
+// generateSecret returns a random secret for a webhook
+func generateSecret() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "sigsec_" + hex.EncodeToString(secret), nil
+}
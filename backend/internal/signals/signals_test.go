@@ -0,0 +1,258 @@
+package signals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/models"
+	"github.com/trading-platform/backend/internal/orderexecution"
+)
+
+// stubPortfolios is a portfolio provider of fixed portfolios
+type stubPortfolios []*models.Portfolio
+
+func (s stubPortfolios) Find(filter models.PortfolioFilter, page, limit int) ([]*models.Portfolio, int, error) {
+	var found []*models.Portfolio
+	for _, portfolio := range s {
+		if portfolio.UserID == filter.UserID && portfolio.Status == filter.Status && portfolio.Symbol == filter.Symbol {
+			found = append(found, portfolio)
+		}
+	}
+	return found, len(found), nil
+}
+
+// recordingExecutor records the portfolios placed through it, failing those it is
+// given errors of
+type recordingExecutor struct {
+	placed []string
+	errs   map[string]error
+	mutex  sync.Mutex
+}
+
+func (e *recordingExecutor) Execute(ctx context.Context, portfolio *models.Portfolio) (*orderexecution.BasketResult, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if err, exists := e.errs[portfolio.ID]; exists {
+		return nil, err
+	}
+	e.placed = append(e.placed, portfolio.ID)
+	return &orderexecution.BasketResult{PortfolioID: portfolio.ID, Status: true}, nil
+}
+
+const secret = "tradingview-secret"
+
+// signalPortfolio returns an active NIFTY portfolio of a user in signal execution
+// mode, running all day every day
+func signalPortfolio(id, userID string) *models.Portfolio {
+	return &models.Portfolio{
+		ID:            id,
+		UserID:        userID,
+		Symbol:        "NIFTY",
+		Exchange:      "NFO",
+		Status:        models.PortfolioStatusActive,
+		ExecutionMode: models.ExecutionModeSignal,
+		RunOnDays:     []string{"MONDAY", "TUESDAY", "WEDNESDAY", "THURSDAY", "FRIDAY", "SATURDAY", "SUNDAY"},
+		StartTime:     "00:00:00",
+		EndTime:       "23:59:59",
+	}
+}
+
+// body returns the body of a TradingView alert sent at a time
+func body(ticker, action string, sentAt time.Time, extra string) []byte {
+	return []byte(fmt.Sprintf(`{"passphrase":%q,"ticker":%q,"exchange":"NSE","action":%q,"price":22010.5,"time":%q%s}`,
+		secret, ticker, action, sentAt.Format(time.RFC3339), extra))
+}
+
+func TestHookRequestValidate(t *testing.T) {
+	for _, request := range []HookRequest{
+		{},
+		{Name: "TradingView", Secret: "short"},
+		{Name: "TradingView", Symbols: []SymbolMapping{{Ticker: "NIFTY1!"}}},
+		{Name: "TradingView", Symbols: []SymbolMapping{{Ticker: "NIFTY1!", Symbol: "NIFTY"}, {Ticker: "nifty1!", Symbol: "NIFTY"}}},
+	} {
+		assert.ErrorIs(t, request.Validate(), ErrInvalidHook, request)
+	}
+
+	hook := &Hook{Symbols: []SymbolMapping{{Ticker: "NIFTY1!", Symbol: "NIFTY", Exchange: "NFO"}}}
+	symbol, exchange := hook.Instrument("nifty1!", "NSE")
+	assert.Equal(t, "NIFTY", symbol)
+	assert.Equal(t, "NFO", exchange)
+	symbol, exchange = hook.Instrument("NSE:BANKNIFTY", "")
+	assert.Equal(t, "BANKNIFTY", symbol)
+	assert.Equal(t, "NSE", exchange)
+}
+
+func TestReceive(t *testing.T) {
+	portfolios := stubPortfolios{
+		signalPortfolio("signal", "user1"),
+		{ID: "timed", UserID: "user1", Symbol: "NIFTY", Status: models.PortfolioStatusActive, ExecutionMode: models.ExecutionModeTime},
+		{ID: "pending", UserID: "user1", Symbol: "NIFTY", Status: models.PortfolioStatusPending, ExecutionMode: models.ExecutionModeSignal},
+		signalPortfolio("other", "user2"),
+	}
+	executor := &recordingExecutor{}
+	service := NewService(NewMemoryStore(), portfolios, executor, Config{})
+
+	hook, err := service.CreateHook("user1", HookRequest{Name: "TradingView", Secret: secret, Symbols: []SymbolMapping{{Ticker: "NIFTY1!", Symbol: "NIFTY", Exchange: "NFO"}}})
+	require.NoError(t, err)
+	assert.True(t, hook.Active)
+
+	now := time.Now()
+	signal, err := service.Receive(hook.ID, body("NIFTY1!", "BUY", now, ""), "", now)
+	require.NoError(t, err)
+	assert.Equal(t, "NIFTY", signal.Symbol)
+	assert.Equal(t, "NFO", signal.Exchange)
+	assert.Equal(t, ActionBuy, signal.Action)
+	assert.Equal(t, SignalAccepted, signal.Status)
+
+	// Only the active portfolio of the user in signal execution mode is placed
+	service.Wait()
+	assert.Equal(t, []string{"signal"}, executor.placed)
+	received, err := service.ListSignals(SignalFilter{HookID: hook.ID})
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	assert.Equal(t, SignalExecuted, received[0].Status)
+	assert.Equal(t, []Execution{{PortfolioID: "signal", Placed: true}}, received[0].Executions)
+
+	// Instruments no portfolio trades match nothing
+	signal, err = service.Receive(hook.ID, body("BANKNIFTY", "sell", now, ""), "", now)
+	require.NoError(t, err)
+	assert.Equal(t, SignalNoMatch, signal.Status)
+
+	// Failures to place portfolios are logged with the signal
+	executor.errs = map[string]error{"signal": errors.New("broker unavailable")}
+	_, err = service.Receive(hook.ID, body("NIFTY1!", "sell", now, `,"id":"alert-2"`), "", now)
+	require.NoError(t, err)
+	service.Wait()
+	received, err = service.ListSignals(SignalFilter{HookID: hook.ID, Key: "alert-2"})
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	assert.Equal(t, SignalFailed, received[0].Status)
+	assert.Equal(t, "broker unavailable", received[0].Executions[0].Error)
+}
+
+func TestReceiveRejected(t *testing.T) {
+	service := NewService(NewMemoryStore(), stubPortfolios{}, &recordingExecutor{}, Config{MaxSkew: time.Minute})
+	hook, err := service.CreateHook("user1", HookRequest{Name: "TradingView", Secret: secret})
+	require.NoError(t, err)
+
+	now := time.Now()
+	valid := body("NIFTY", "buy", now, "")
+
+	_, err = service.Receive("unknown", valid, "", now)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = service.Receive(hook.ID, []byte(`{"passphrase":"wrong-secret-value","ticker":"NIFTY"}`), "", now)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = service.Receive(hook.ID, valid, "wrong-secret-value", now)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = service.Receive(hook.ID, []byte(`not json`), "", now)
+	assert.ErrorIs(t, err, ErrInvalidSignal)
+	_, err = service.Receive(hook.ID, body("NIFTY", "hold", now, ""), "", now)
+	assert.ErrorIs(t, err, ErrInvalidSignal)
+	_, err = service.Receive(hook.ID, []byte(fmt.Sprintf(`{"passphrase":%q,"ticker":"NIFTY","action":"buy"}`, secret)), "", now)
+	assert.ErrorIs(t, err, ErrInvalidSignal)
+
+	// Stale signals, and signals received before, are rejected
+	_, err = service.Receive(hook.ID, body("NIFTY", "buy", now.Add(-2*time.Minute), ""), "", now)
+	assert.ErrorIs(t, err, ErrStaleSignal)
+	_, err = service.Receive(hook.ID, body("NIFTY", "buy", now.Add(2*time.Minute), ""), "", now)
+	assert.ErrorIs(t, err, ErrStaleSignal)
+
+	_, err = service.Receive(hook.ID, valid, "", now)
+	require.NoError(t, err)
+	_, err = service.Receive(hook.ID, valid, "", now.Add(time.Second))
+	assert.ErrorIs(t, err, ErrReplayedSignal)
+
+	// The secret may be sent in a header instead
+	_, err = service.Receive(hook.ID, []byte(fmt.Sprintf(`{"ticker":"NIFTY","action":"buy","time":%q}`, now.Format(time.RFC3339))), secret, now)
+	assert.NoError(t, err)
+
+	// Inactive webhooks receive nothing
+	inactive := false
+	_, err = service.UpdateHook(hook.ID, HookRequest{Name: "TradingView", Active: &inactive})
+	require.NoError(t, err)
+	_, err = service.Receive(hook.ID, body("NIFTY", "sell", now, ""), "", now)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+// stubStrategies is a strategy provider of fixed strategies, by ID
+type stubStrategies map[string]*models.Strategy
+
+func (s stubStrategies) GetStrategyByID(id string) (*models.Strategy, error) {
+	strategy, exists := s[id]
+	if !exists {
+		return nil, errors.New("strategy not found")
+	}
+	return strategy, nil
+}
+
+func TestRoute(t *testing.T) {
+	closed := signalPortfolio("closed", "user1")
+	closed.StartTime, closed.EndTime = "09:15:00", "15:30:00"
+	weekends := signalPortfolio("weekends", "user1")
+	weekends.RunOnDays = []string{"SATURDAY", "SUNDAY"}
+	paused := signalPortfolio("paused", "user1")
+	paused.StrategyID = "paused"
+	breakout := signalPortfolio("breakout", "user1")
+	breakout.StrategyID = "breakout"
+	invalid := signalPortfolio("invalid", "user1")
+	invalid.StrategyID = "invalid"
+
+	router := NewRouter(stubPortfolios{closed, weekends, paused, breakout, invalid}, &recordingExecutor{}, time.Second)
+	router.SetStrategies(stubStrategies{
+		"paused": {ID: "paused", Name: "Paused", Status: models.StrategyStatusPaused},
+		"breakout": {ID: "breakout", Name: "Breakout", Status: models.StrategyStatusActive, EntryConditions: []models.Condition{
+			{Type: "SIGNAL", Parameter: "action", Operator: "==", Value: "buy"},
+			{Type: "SIGNAL", Parameter: "price", Operator: ">=", Value: 22000.0},
+			{Type: "INDICATOR", Parameter: "rsi", Operator: ">", Value: 70.0}, // Not evaluated on signals
+		}},
+		"invalid": {ID: "invalid", Name: "Invalid", Status: models.StrategyStatusActive, EntryConditions: []models.Condition{
+			{Type: "SIGNAL", Parameter: "volume", Operator: ">", Value: 1.0},
+		}},
+	})
+
+	// Wednesday evening, when only the breakout strategy's conditions may hold
+	receivedAt := time.Date(2026, 10, 14, 18, 0, 0, 0, time.Local)
+	signal := &Signal{ID: "signal1", UserID: "user1", Symbol: "NIFTY", Exchange: "NFO", Action: ActionBuy, Price: 22010.5, ReceivedAt: receivedAt}
+	entered, err := router.Route(signal, "")
+	assert.NoError(t, err)
+	require.Len(t, entered, 1)
+	assert.Equal(t, "breakout", entered[0].ID)
+	reasons := make(map[string]string)
+	for _, skipped := range signal.Skipped {
+		reasons[skipped.PortfolioID] = skipped.Reason
+	}
+	assert.Len(t, reasons, 4)
+	assert.Contains(t, reasons["closed"], "does not run")
+	assert.Contains(t, reasons["weekends"], "does not run")
+	assert.Contains(t, reasons["paused"], "paused")
+	assert.Contains(t, reasons["invalid"], "unknown parameter")
+
+	// Signals the strategy's conditions do not hold for skip its portfolio
+	signal = &Signal{ID: "signal2", UserID: "user1", Symbol: "NIFTY", Action: ActionSell, Price: 22010.5, ReceivedAt: receivedAt}
+	entered, err = router.Route(signal, "breakout")
+	assert.NoError(t, err)
+	assert.Empty(t, entered)
+	require.Len(t, signal.Skipped, 1)
+	assert.Contains(t, signal.Skipped[0].Reason, "action == buy")
+
+	// Portfolios being placed for an earlier signal are not placed again
+	require.True(t, router.claim("breakout"))
+	var placed Signal
+	router.Launch(Signal{ID: "signal3"}, []*models.Portfolio{breakout}, func(signal Signal) { placed = signal })
+	router.Wait()
+	assert.Equal(t, SignalSkipped, placed.Status)
+	assert.Empty(t, placed.Executions)
+	require.Len(t, placed.Skipped, 1)
+
+	router.release("breakout")
+	router.Launch(Signal{ID: "signal4"}, []*models.Portfolio{breakout}, func(signal Signal) { placed = signal })
+	router.Wait()
+	assert.Equal(t, SignalExecuted, placed.Status)
+}
@@ -0,0 +1,252 @@
+package signals
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxMemorySignals is the number of signals the memory store keeps per webhook,
+// the oldest being dropped
+const maxMemorySignals = 1000
+
+// MemoryStore keeps signal webhooks and their signals in memory
+type MemoryStore struct {
+	hooks   map[string]Hook
+	signals map[string][]Signal // By webhook, oldest first
+	mutex   sync.RWMutex
+}
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		hooks:   make(map[string]Hook),
+		signals: make(map[string][]Signal),
+	}
+}
+
+// SaveHook adds or replaces a webhook
+func (s *MemoryStore) SaveHook(hook *Hook) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	saved := *hook
+	saved.Symbols = append([]SymbolMapping{}, hook.Symbols...)
+	s.hooks[hook.ID] = saved
+	return nil
+}
+
+// GetHook returns a webhook by ID
+func (s *MemoryStore) GetHook(id string) (*Hook, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	hook, exists := s.hooks[id]
+	if !exists {
+		return nil, ErrHookNotFound
+	}
+	return &hook, nil
+}
+
+// ListHooks returns the webhooks of a user, every webhook when userID is empty,
+// oldest first
+func (s *MemoryStore) ListHooks(userID string) ([]Hook, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	hooks := make([]Hook, 0)
+	for _, hook := range s.hooks {
+		if userID == "" || hook.UserID == userID {
+			hooks = append(hooks, hook)
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+	})
+	return hooks, nil
+}
+
+// DeleteHook removes a webhook along with its signals
+func (s *MemoryStore) DeleteHook(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.hooks[id]; !exists {
+		return ErrHookNotFound
+	}
+	delete(s.hooks, id)
+	delete(s.signals, id)
+	return nil
+}
+
+// SaveSignal adds or replaces a signal
+func (s *MemoryStore) SaveSignal(signal *Signal) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	saved := *signal
+	saved.Executions = append([]Execution(nil), signal.Executions...)
+	saved.Skipped = append([]Skip(nil), signal.Skipped...)
+
+	signals := s.signals[signal.HookID]
+	for i := range signals {
+		if signals[i].ID == signal.ID {
+			signals[i] = saved
+			return nil
+		}
+	}
+	if len(signals) >= maxMemorySignals {
+		signals = signals[1:]
+	}
+	s.signals[signal.HookID] = append(signals, saved)
+	return nil
+}
+
+// ListSignals returns the signals selected by a filter, newest first
+func (s *MemoryStore) ListSignals(filter SignalFilter) ([]Signal, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	signals := make([]Signal, 0)
+	for hookID, received := range s.signals {
+		if filter.HookID != "" && hookID != filter.HookID {
+			continue
+		}
+		for _, signal := range received {
+			if filter.Matches(signal) {
+				signals = append(signals, signal)
+			}
+		}
+	}
+	sort.Slice(signals, func(i, j int) bool {
+		return signals[i].ReceivedAt.After(signals[j].ReceivedAt)
+	})
+	if filter.Limit > 0 && len(signals) > filter.Limit {
+		signals = signals[:filter.Limit]
+	}
+	return signals, nil
+}
+
+// MongoStore keeps signal webhooks and their signals in MongoDB collections
+type MongoStore struct {
+	hooks   *mongo.Collection
+	signals *mongo.Collection
+}
+
+// NewMongoStore creates a new MongoStore
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		hooks:   db.Collection("signal_hooks"),
+		signals: db.Collection("signals"),
+	}
+}
+
+// SaveHook adds or replaces a webhook
+func (s *MongoStore) SaveHook(hook *Hook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.hooks.ReplaceOne(ctx, bson.M{"_id": hook.ID}, hook, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetHook returns a webhook by ID
+func (s *MongoStore) GetHook(id string) (*Hook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var hook Hook
+	err := s.hooks.FindOne(ctx, bson.M{"_id": id}).Decode(&hook)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrHookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// ListHooks returns the webhooks of a user, every webhook when userID is empty,
+// oldest first
+func (s *MongoStore) ListHooks(userID string) ([]Hook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	if userID != "" {
+		query["userId"] = userID
+	}
+
+	cursor, err := s.hooks.Find(ctx, query, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	hooks := make([]Hook, 0)
+	if err := cursor.All(ctx, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// DeleteHook removes a webhook along with its signals
+func (s *MongoStore) DeleteHook(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.hooks.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrHookNotFound
+	}
+	_, err = s.signals.DeleteMany(ctx, bson.M{"hookId": id})
+	return err
+}
+
+// SaveSignal adds or replaces a signal
+func (s *MongoStore) SaveSignal(signal *Signal) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.signals.ReplaceOne(ctx, bson.M{"_id": signal.ID}, signal, options.Replace().SetUpsert(true))
+	return err
+}
+
+// ListSignals returns the signals selected by a filter, newest first
+func (s *MongoStore) ListSignals(filter SignalFilter) ([]Signal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.HookID != "" {
+		query["hookId"] = filter.HookID
+	}
+	if filter.Key != "" {
+		query["key"] = filter.Key
+	}
+
+	findOptions := options.Find().SetSort(bson.M{"receivedAt": -1})
+	if filter.Limit > 0 {
+		findOptions.SetLimit(int64(filter.Limit))
+	}
+
+	cursor, err := s.signals.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	signals := make([]Signal, 0)
+	if err := cursor.All(ctx, &signals); err != nil {
+		return nil, err
+	}
+	return signals, nil
+}
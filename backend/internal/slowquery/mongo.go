@@ -0,0 +1,299 @@
+package slowquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// sanitizedValue replaces the values of the filters of slow commands
+const sanitizedValue = "?"
+
+// filteredCommands are the commands whose filters are sanitized and hinted indexes
+// for when slow
+var filteredCommands = map[string]bool{
+	"find": true, "aggregate": true, "count": true, "distinct": true,
+	"findAndModify": true, "update": true, "delete": true,
+}
+
+// pendingKey identifies a command started on a connection
+type pendingKey struct {
+	connectionID string
+	requestID    int64
+}
+
+// pendingCommand is a command started and not finished yet
+type pendingCommand struct {
+	collection string
+	command    bson.Raw // Copied for filtered commands only
+}
+
+// MongoMonitor returns a command monitor detecting the slow commands a MongoDB
+// client runs, with their filters and sorts and the index that would serve them
+func MongoMonitor(detector *Detector) *event.CommandMonitor {
+	var pending sync.Map // pendingKey to pendingCommand
+
+	finished := func(e event.CommandFinishedEvent) {
+		value, ok := pending.LoadAndDelete(pendingKey{e.ConnectionID, e.RequestID})
+		if !ok || !detector.IsSlow(e.Duration) {
+			return
+		}
+		started := value.(pendingCommand)
+		query := Query{
+			System:    SystemMongoDB,
+			Operation: e.CommandName,
+			Source:    started.collection,
+			Statement: e.CommandName,
+			Duration:  e.Duration,
+		}
+		if started.command != nil {
+			filter, sort := commandFilter(e.CommandName, started.command)
+			query.Statement = mongoStatement(filter, sort)
+			query.IndexHint = mongoIndexHint(filter, sort)
+		}
+		detector.Observe(query)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			started := pendingCommand{}
+			if collection, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+				started.collection = collection
+			}
+			if filteredCommands[e.CommandName] {
+				// The command is only borrowed for the duration of the event
+				started.command = append(bson.Raw(nil), e.Command...)
+			}
+			pending.Store(pendingKey{e.ConnectionID, e.RequestID}, started)
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			finished(e.CommandFinishedEvent)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			finished(e.CommandFinishedEvent)
+		},
+	}
+}
+
+// commandFilter returns the filter and sort of a command, nil for those it has
+// none of
+func commandFilter(commandName string, command bson.Raw) (filter, sort bson.Raw) {
+	document := func(value bson.RawValue) bson.Raw {
+		document, _ := value.DocumentOK()
+		return document
+	}
+	first := func(key string) bson.Raw {
+		statements, ok := command.Lookup(key).ArrayOK()
+		if !ok {
+			return nil
+		}
+		values, err := statements.Values()
+		if err != nil || len(values) == 0 {
+			return nil
+		}
+		return document(values[0])
+	}
+
+	switch commandName {
+	case "find":
+		return document(command.Lookup("filter")), document(command.Lookup("sort"))
+	case "findAndModify":
+		return document(command.Lookup("query")), document(command.Lookup("sort"))
+	case "count", "distinct":
+		return document(command.Lookup("query")), nil
+	case "update", "delete":
+		// Only the first statement of a batch, as batches share their filters
+		statement := first(commandName + "s")
+		if statement == nil {
+			return nil, nil
+		}
+		return document(statement.Lookup("q")), nil
+	case "aggregate":
+		// Only a leading $match and the $sort following it can use an index
+		pipeline, ok := command.Lookup("pipeline").ArrayOK()
+		if !ok {
+			return nil, nil
+		}
+		stages, err := pipeline.Values()
+		if err != nil {
+			return nil, nil
+		}
+		if len(stages) > 0 {
+			if match, ok := document(stages[0]).Lookup("$match").DocumentOK(); ok {
+				filter = match
+				stages = stages[1:]
+			}
+		}
+		if len(stages) > 0 {
+			sort, _ = document(stages[0]).Lookup("$sort").DocumentOK()
+		}
+		return filter, sort
+	}
+	return nil, nil
+}
+
+// mongoStatement returns the statement of a slow command: its filter, sanitized of
+// the values filtered by, and its sort
+func mongoStatement(filter, sort bson.Raw) string {
+	statement := "{}"
+	if filter != nil {
+		if data, err := bson.MarshalExtJSON(SanitizeFilter(filter), false, false); err == nil {
+			statement = string(data)
+		}
+	}
+	if len(sortFields(sort)) > 0 {
+		if data, err := bson.MarshalExtJSON(sort, false, false); err == nil {
+			statement += " sort " + string(data)
+		}
+	}
+	return statement
+}
+
+// SanitizeFilter returns a filter with the values filtered by replaced, keeping
+// its fields and operators
+func SanitizeFilter(filter bson.Raw) bson.D {
+	elements, err := filter.Elements()
+	if err != nil {
+		return bson.D{}
+	}
+	sanitized := make(bson.D, 0, len(elements))
+	for _, element := range elements {
+		sanitized = append(sanitized, bson.E{Key: element.Key(), Value: sanitizeValue(element.Key(), element.Value())})
+	}
+	return sanitized
+}
+
+// sanitizeValue returns the value of a field or operator of a filter, sanitized
+func sanitizeValue(key string, value bson.RawValue) interface{} {
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		document := value.Document()
+		if strings.HasPrefix(key, "$") || isOperatorDocument(document) {
+			return SanitizeFilter(document)
+		}
+	case bsontype.Array:
+		if key == "$and" || key == "$or" || key == "$nor" {
+			values, _ := value.Array().Values()
+			clauses := make(bson.A, 0, len(values))
+			for _, clause := range values {
+				if document, ok := clause.DocumentOK(); ok {
+					clauses = append(clauses, SanitizeFilter(document))
+				}
+			}
+			return clauses
+		}
+	}
+	return sanitizedValue
+}
+
+// isOperatorDocument reports whether a document holds query operators, such as
+// {$gte: 100}, rather than being a value
+func isOperatorDocument(document bson.Raw) bool {
+	elements, err := document.Elements()
+	return err == nil && len(elements) > 0 && strings.HasPrefix(elements[0].Key(), "$")
+}
+
+// indexField is a field of an index hinted and its direction
+type indexField struct {
+	name      string
+	direction int
+}
+
+// mongoIndexHint returns the index that would serve a filter and sort, following
+// the equality, sort, range rule: the fields matched exactly, then those sorted
+// by, then those matched by range. No index is hinted for filters on _id alone,
+// which is always indexed.
+func mongoIndexHint(filter, sort bson.Raw) string {
+	var equality, ranges []string
+	filterFields(filter, &equality, &ranges)
+	sorted := sortFields(sort)
+	if len(sorted) == 0 && len(ranges) == 0 && (len(equality) == 0 || (len(equality) == 1 && equality[0] == "_id")) {
+		return ""
+	}
+
+	var fields []indexField
+	seen := make(map[string]bool)
+	add := func(name string, direction int) {
+		if !seen[name] {
+			seen[name] = true
+			fields = append(fields, indexField{name, direction})
+		}
+	}
+	for _, name := range equality {
+		add(name, 1)
+	}
+	for _, field := range sorted {
+		add(field.name, field.direction)
+	}
+	for _, name := range ranges {
+		add(name, 1)
+	}
+
+	keys := make([]string, len(fields))
+	for i, field := range fields {
+		keys[i] = fmt.Sprintf("%s: %d", field.name, field.direction)
+	}
+	return "{" + strings.Join(keys, ", ") + "}"
+}
+
+// filterFields adds the fields a filter matches exactly to equality and those it
+// matches by range or other operators to ranges. Fields of $or and $nor clauses
+// are left out, each clause needing an index of its own.
+func filterFields(filter bson.Raw, equality, ranges *[]string) {
+	elements, err := filter.Elements()
+	if err != nil {
+		return
+	}
+	for _, element := range elements {
+		key := element.Key()
+		if key == "$and" {
+			values, _ := element.Value().Array().Values()
+			for _, clause := range values {
+				if document, ok := clause.DocumentOK(); ok {
+					filterFields(document, equality, ranges)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+
+		exact := true
+		if document, ok := element.Value().DocumentOK(); ok && isOperatorDocument(document) {
+			operators, _ := document.Elements()
+			for _, operator := range operators {
+				if operator.Key() != "$eq" && operator.Key() != "$in" {
+					exact = false
+				}
+			}
+		}
+		if exact {
+			*equality = append(*equality, key)
+		} else {
+			*ranges = append(*ranges, key)
+		}
+	}
+}
+
+// sortFields returns the fields of a sort in order
+func sortFields(sort bson.Raw) []indexField {
+	elements, err := sort.Elements()
+	if err != nil {
+		return nil
+	}
+	fields := make([]indexField, 0, len(elements))
+	for _, element := range elements {
+		direction := 1
+		if value, ok := element.Value().AsInt64OK(); ok && value < 0 {
+			direction = -1
+		}
+		fields = append(fields, indexField{element.Key(), direction})
+	}
+	return fields
+}
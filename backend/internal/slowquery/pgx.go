@@ -0,0 +1,24 @@
+package slowquery
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// PgxLogger returns a pgx logger detecting slow queries from those pgx logs,
+// passing every message on to next if not nil. Connections must log at
+// pgx.LogLevelInfo or below, as they do by default, for queries to be logged.
+func PgxLogger(detector *Detector, next pgx.Logger) pgx.Logger {
+	return pgx.LoggerFunc(func(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+		if msg == "Query" || msg == "Exec" {
+			query, _ := data["sql"].(string)
+			duration, _ := data["time"].(time.Duration)
+			detector.observeSQL(query, duration)
+		}
+		if next != nil {
+			next.Log(ctx, level, msg, data)
+		}
+	})
+}
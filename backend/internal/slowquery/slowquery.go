@@ -0,0 +1,168 @@
+// Package slowquery detects the MongoDB commands and SQL queries taking longer
+// than a threshold. Slow queries are logged and counted with their statement,
+// sanitized of the values they were run with, and a hint of the index that would
+// serve them, for hot spots to be found and indexed.
+package slowquery
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/trading-platform/backend/internal/metrics"
+)
+
+// DefaultThreshold is the duration beyond which queries are slow unless configured
+// otherwise
+const DefaultThreshold = 100 * time.Millisecond
+
+// Systems of the queries detected
+const (
+	SystemMongoDB    = "mongodb"
+	SystemPostgreSQL = "postgresql"
+)
+
+// maxStatements bounds the number of statements tracked, beyond which new ones are
+// still logged and counted but not tracked
+const maxStatements = 1000
+
+// logInterval is how often slow runs of the same statement are logged
+const logInterval = time.Minute
+
+// Query is a query run against a database
+type Query struct {
+	System    string        // SystemMongoDB or SystemPostgreSQL
+	Operation string        // Command name or SQL verb
+	Source    string        // Collection or table queried
+	Statement string        // Filter or SQL, sanitized of the values queried
+	IndexHint string        // Index that would serve the query, if one can be told
+	Duration  time.Duration // Time the query took
+}
+
+// Stats are the slow runs of a statement
+type Stats struct {
+	System        string        `json:"system"`
+	Operation     string        `json:"operation"`
+	Source        string        `json:"source"`
+	Statement     string        `json:"statement"`
+	IndexHint     string        `json:"indexHint,omitempty"`
+	Count         int64         `json:"count"`
+	TotalDuration time.Duration `json:"totalDuration"`
+	MaxDuration   time.Duration `json:"maxDuration"`
+	LastSeen      time.Time     `json:"lastSeen"`
+}
+
+// statementStats are the stats of a statement along with when it was last logged
+type statementStats struct {
+	Stats
+	lastLogged time.Time
+	unlogged   int64 // Slow runs since last logged
+}
+
+// Detector logs and counts the queries slower than its threshold
+type Detector struct {
+	threshold  time.Duration
+	statements map[string]*statementStats // By system and statement
+	mutex      sync.Mutex
+}
+
+// NewDetector creates a new detector of the queries slower than threshold,
+// DefaultThreshold when not positive
+func NewDetector(threshold time.Duration) *Detector {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	return &Detector{
+		threshold:  threshold,
+		statements: make(map[string]*statementStats),
+	}
+}
+
+// Threshold returns the duration beyond which queries are slow
+func (d *Detector) Threshold() time.Duration {
+	return d.threshold
+}
+
+// IsSlow reports whether a query that took duration is slow
+func (d *Detector) IsSlow(duration time.Duration) bool {
+	return duration >= d.threshold
+}
+
+// Observe logs and counts a query if it is slow. Slow runs of the same statement
+// are logged at most once a minute, with the number of runs since.
+func (d *Detector) Observe(query Query) {
+	if !d.IsSlow(query.Duration) {
+		return
+	}
+	metrics.ObserveSlowQuery(query.System, query.Operation, query.Source)
+
+	now := time.Now()
+	key := query.System + " " + query.Statement
+
+	d.mutex.Lock()
+	stats, ok := d.statements[key]
+	if !ok {
+		if len(d.statements) >= maxStatements {
+			d.mutex.Unlock()
+			logQuery(query, 1)
+			return
+		}
+		stats = &statementStats{Stats: Stats{
+			System:    query.System,
+			Operation: query.Operation,
+			Source:    query.Source,
+			Statement: query.Statement,
+			IndexHint: query.IndexHint,
+		}}
+		d.statements[key] = stats
+	}
+	stats.Count++
+	stats.TotalDuration += query.Duration
+	if query.Duration > stats.MaxDuration {
+		stats.MaxDuration = query.Duration
+	}
+	stats.LastSeen = now
+	stats.unlogged++
+
+	runs := stats.unlogged
+	shouldLog := now.Sub(stats.lastLogged) >= logInterval
+	if shouldLog {
+		stats.lastLogged = now
+		stats.unlogged = 0
+	}
+	d.mutex.Unlock()
+
+	if shouldLog {
+		logQuery(query, runs)
+	}
+}
+
+// logQuery logs a slow query, slow runs times since its statement was last logged
+func logQuery(query Query, runs int64) {
+	hint := ""
+	if query.IndexHint != "" {
+		hint = ", consider an index on " + query.IndexHint
+	}
+	log.Printf("Slow %s %s on %s took %v (%d slow runs since last logged): %s%s",
+		query.System, query.Operation, query.Source, query.Duration, runs, query.Statement, hint)
+}
+
+// Top returns the stats of the n statements slow for the longest in total, all of
+// them when n is not positive
+func (d *Detector) Top(n int) []Stats {
+	d.mutex.Lock()
+	top := make([]Stats, 0, len(d.statements))
+	for _, stats := range d.statements {
+		top = append(top, stats.Stats)
+	}
+	d.mutex.Unlock()
+
+	sort.Slice(top, func(i, j int) bool {
+		return top[i].TotalDuration > top[j].TotalDuration
+	})
+	if n > 0 && len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
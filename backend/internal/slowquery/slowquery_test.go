@@ -0,0 +1,172 @@
+package slowquery
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestDetector(t *testing.T) {
+	detector := NewDetector(50 * time.Millisecond)
+
+	detector.Observe(Query{System: SystemPostgreSQL, Statement: "SELECT ? FROM orders", Duration: 10 * time.Millisecond})
+	assert.Empty(t, detector.Top(0))
+
+	for _, duration := range []time.Duration{60 * time.Millisecond, 90 * time.Millisecond} {
+		detector.Observe(Query{System: SystemMongoDB, Operation: "find", Source: "orders", Statement: `{"userId":"?"}`, Duration: duration})
+	}
+	detector.Observe(Query{System: SystemPostgreSQL, Operation: "SELECT", Source: "trades", Statement: "SELECT * FROM trades", Duration: time.Second})
+
+	top := detector.Top(0)
+	require.Len(t, top, 2)
+	assert.Equal(t, "trades", top[0].Source)
+	assert.Equal(t, "orders", top[1].Source)
+	assert.Equal(t, int64(2), top[1].Count)
+	assert.Equal(t, 150*time.Millisecond, top[1].TotalDuration)
+	assert.Equal(t, 90*time.Millisecond, top[1].MaxDuration)
+	assert.Len(t, detector.Top(1), 1)
+}
+
+// runCommand notifies a monitor of a command that took duration
+func runCommand(monitor *event.CommandMonitor, requestID int64, name string, command bson.D, duration time.Duration) {
+	raw, _ := bson.Marshal(command)
+	monitor.Started(context.Background(), &event.CommandStartedEvent{
+		Command:      raw,
+		CommandName:  name,
+		RequestID:    requestID,
+		ConnectionID: "localhost:27017[-1]",
+	})
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{
+			CommandName:  name,
+			RequestID:    requestID,
+			ConnectionID: "localhost:27017[-1]",
+			Duration:     duration,
+		},
+	})
+}
+
+func TestMongoMonitor(t *testing.T) {
+	detector := NewDetector(50 * time.Millisecond)
+	monitor := MongoMonitor(detector)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	runCommand(monitor, 1, "find", bson.D{
+		{Key: "find", Value: "orders"},
+		{Key: "filter", Value: bson.D{
+			{Key: "userId", Value: "user1"},
+			{Key: "createdAt", Value: bson.D{{Key: "$gte", Value: from}}},
+			{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{"OPEN", "PENDING"}}}},
+		}},
+		{Key: "sort", Value: bson.D{{Key: "createdAt", Value: -1}}},
+	}, 80*time.Millisecond)
+	runCommand(monitor, 2, "aggregate", bson.D{
+		{Key: "aggregate", Value: "positions"},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "userId", Value: "user1"}},
+				bson.D{{Key: "portfolioId", Value: "portfolio1"}},
+			}}}}},
+			bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$symbol"}}}},
+		}},
+	}, 60*time.Millisecond)
+	runCommand(monitor, 3, "find", bson.D{
+		{Key: "find", Value: "users"},
+		{Key: "filter", Value: bson.D{{Key: "_id", Value: "user1"}}},
+	}, 70*time.Millisecond)
+	runCommand(monitor, 4, "find", bson.D{{Key: "find", Value: "orders"}}, 10*time.Millisecond)
+
+	top := detector.Top(0)
+	require.Len(t, top, 3)
+
+	find := top[0]
+	assert.Equal(t, "find", find.Operation)
+	assert.Equal(t, "orders", find.Source)
+	assert.Equal(t, `{"userId":"?","createdAt":{"$gte":"?"},"status":{"$in":"?"}} sort {"createdAt":-1}`, find.Statement)
+	assert.Equal(t, "{userId: 1, status: 1, createdAt: -1}", find.IndexHint)
+	assert.NotContains(t, find.Statement, "user1")
+
+	// Fields of $or clauses each need an index of their own
+	aggregate := top[2]
+	assert.Equal(t, "positions", aggregate.Source)
+	assert.Equal(t, `{"$or":[{"userId":"?"},{"portfolioId":"?"}]}`, aggregate.Statement)
+	assert.Empty(t, aggregate.IndexHint)
+
+	// _id is always indexed
+	assert.Equal(t, "users", top[1].Source)
+	assert.Empty(t, top[1].IndexHint)
+}
+
+func TestSanitizeSQL(t *testing.T) {
+	assert.Equal(t,
+		"SELECT * FROM orders WHERE user_id = ? AND price > ? AND status IN (?) LIMIT ?",
+		SanitizeSQL("SELECT *\n\tFROM orders WHERE user_id = 'it''s me' AND price > -12.5 AND status IN ('OPEN', 'PENDING') LIMIT 50"))
+	assert.Equal(t,
+		"SELECT * FROM orders2 WHERE user_id = $1 AND quantity >= ?",
+		SanitizeSQL("SELECT * FROM orders2 WHERE user_id = $1 AND quantity >= 10"))
+}
+
+func TestSQLIndexHint(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT * FROM trades WHERE portfolio_id = $1 AND executed_at >= $2 ORDER BY executed_at DESC LIMIT ?", "trades (portfolio_id, executed_at)"},
+		{"SELECT * FROM positions WHERE created_at > $1 AND user_id = $2 AND status IN (?) ORDER BY symbol", "positions (user_id, status, symbol, created_at)"},
+		{"UPDATE orders SET status = $1 WHERE id = $2", "orders (id)"},
+		{"SELECT * FROM orders ORDER BY created_at", ""},
+		{"INSERT INTO orders (id, status) VALUES ($1, $2)", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, sqlIndexHint(tt.query, sqlTable(tt.query)), tt.query)
+	}
+}
+
+// fakeDriver is a driver whose queries take delay
+type fakeDriver struct {
+	delay time.Duration
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{delay: d.delay}, nil
+}
+
+type fakeConn struct {
+	delay time.Duration
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	time.Sleep(c.delay)
+	return driver.RowsAffected(1), nil
+}
+
+func TestRegisterDriver(t *testing.T) {
+	detector := NewDetector(20 * time.Millisecond)
+	RegisterDriver("slowquery-test", &fakeDriver{delay: 30 * time.Millisecond}, detector)
+
+	db, err := sql.Open("slowquery-test", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("UPDATE orders SET status = 'CANCELLED' WHERE user_id = $1", "user1")
+	require.NoError(t, err)
+
+	top := detector.Top(0)
+	require.Len(t, top, 1)
+	assert.Equal(t, SystemPostgreSQL, top[0].System)
+	assert.Equal(t, "UPDATE", top[0].Operation)
+	assert.Equal(t, "orders", top[0].Source)
+	assert.Equal(t, "UPDATE orders SET status = ? WHERE user_id = $1", top[0].Statement)
+	assert.Equal(t, "orders (user_id)", top[0].IndexHint)
+}
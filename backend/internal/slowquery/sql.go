@@ -0,0 +1,221 @@
+package slowquery
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	stringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberLiteral  = regexp.MustCompile(`(^|[^\w$.])-?\d+(?:\.\d+)?`)
+	placeholderSet = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+	whitespace     = regexp.MustCompile(`\s+`)
+
+	tableClause   = regexp.MustCompile(`(?i)\b(?:from|update|into)\s+([\w.]+)`)
+	whereClause   = regexp.MustCompile(`(?i)\bwhere\b(.*?)(?:\bgroup\s+by\b|\border\s+by\b|\blimit\b|\breturning\b|\bfor\s+update\b|$)`)
+	orderClause   = regexp.MustCompile(`(?i)\border\s+by\b(.*?)(?:\blimit\b|\boffset\b|\bfor\s+update\b|$)`)
+	comparison    = regexp.MustCompile(`(?i)([\w.]+)\s*(=|<>|!=|<=|>=|<|>|\bin\b|\blike\b|\bilike\b|\bbetween\b|\bis\b)`)
+	sqlKeywords   = map[string]bool{"and": true, "or": true, "not": true, "null": true}
+	sqlOperations = map[string]bool{"SELECT": true, "INSERT": true, "UPDATE": true, "DELETE": true, "WITH": true}
+)
+
+// SanitizeSQL returns a SQL query with its literals replaced and its whitespace
+// collapsed, for the runs of a query with different values to read the same
+func SanitizeSQL(query string) string {
+	query = stringLiteral.ReplaceAllString(query, "?")
+	query = numberLiteral.ReplaceAllString(query, "$1?")
+	query = placeholderSet.ReplaceAllString(query, "(?)")
+	return strings.TrimSpace(whitespace.ReplaceAllString(query, " "))
+}
+
+// sqlOperation returns the verb of a SQL query, such as SELECT
+func sqlOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	operation := strings.ToUpper(fields[0])
+	if !sqlOperations[operation] {
+		return "other"
+	}
+	return operation
+}
+
+// sqlTable returns the first table a SQL query reads or writes
+func sqlTable(query string) string {
+	if match := tableClause.FindStringSubmatch(query); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// sqlIndexHint returns the index that would serve a SQL query filtering on a
+// table: the columns compared for equality, then those ordered by, then those
+// compared by range
+func sqlIndexHint(query, table string) string {
+	where := whereClause.FindStringSubmatch(query)
+	if table == "" || where == nil {
+		return ""
+	}
+
+	var equality, ranges, ordered []string
+	for _, match := range comparison.FindAllStringSubmatch(where[1], -1) {
+		column := match[1]
+		if sqlKeywords[strings.ToLower(column)] || column == "?" || strings.HasPrefix(column, "$") {
+			continue
+		}
+		switch strings.ToLower(match[2]) {
+		case "=", "in", "is":
+			equality = append(equality, column)
+		default:
+			ranges = append(ranges, column)
+		}
+	}
+	if order := orderClause.FindStringSubmatch(query); order != nil {
+		for _, term := range strings.Split(order[1], ",") {
+			if fields := strings.Fields(term); len(fields) > 0 {
+				ordered = append(ordered, fields[0])
+			}
+		}
+	}
+	if len(equality) == 0 && len(ranges) == 0 {
+		return ""
+	}
+
+	var columns []string
+	seen := make(map[string]bool)
+	for _, group := range [][]string{equality, ordered, ranges} {
+		for _, column := range group {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	return table + " (" + strings.Join(columns, ", ") + ")"
+}
+
+// observeSQL observes a SQL query that took duration
+func (d *Detector) observeSQL(query string, duration time.Duration) {
+	if !d.IsSlow(duration) {
+		return
+	}
+	statement := SanitizeSQL(query)
+	table := sqlTable(statement)
+	d.Observe(Query{
+		System:    SystemPostgreSQL,
+		Operation: sqlOperation(statement),
+		Source:    table,
+		Statement: statement,
+		IndexHint: sqlIndexHint(statement, table),
+		Duration:  duration,
+	})
+}
+
+// RegisterDriver registers a database/sql driver under name, wrapped to detect the
+// slow queries run on its connections, for databases to be opened with name
+func RegisterDriver(name string, base driver.Driver, detector *Detector) {
+	sql.Register(name, &slowDriver{Driver: base, detector: detector})
+}
+
+// slowDriver wraps the connections of a driver
+type slowDriver struct {
+	driver.Driver
+	detector *Detector
+}
+
+// Open opens a connection, wrapped
+func (d *slowDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &slowConn{Conn: conn, detector: d.detector}, nil
+}
+
+// slowConn times the queries run on a connection. Statements prepared explicitly
+// are not timed.
+type slowConn struct {
+	driver.Conn
+	detector *Detector
+}
+
+// QueryContext runs and times a query
+func (c *slowConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.detector.observeSQL(query, time.Since(start))
+	}
+	return rows, err
+}
+
+// ExecContext runs and times a statement
+func (c *slowConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.detector.observeSQL(query, time.Since(start))
+	}
+	return result, err
+}
+
+// PrepareContext prepares a statement
+func (c *slowConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx begins a transaction
+func (c *slowConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping checks the connection is alive
+func (c *slowConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession resets the connection before it is reused
+func (c *slowConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid reports whether the connection can be reused
+func (c *slowConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue converts the arguments of queries as the driver does
+func (c *slowConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,113 @@
+// Package tracing traces requests through the platform with OpenTelemetry, from
+// the HTTP middleware through the gateway, order execution and broker adapters
+// down to the database drivers, so that the latency of orders can be diagnosed
+// across services. Until Init is called, spans are not recorded.
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/XSAM/otelsql"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the platform's own spans
+const instrumentationName = "trading-platform/backend"
+
+// Config configures how spans are sampled and exported
+type Config struct {
+	ServiceName string
+	Endpoint    string  // Of the OTLP collector, as host:port
+	Insecure    bool    // Exports without TLS, to a collector next to the service
+	SampleRatio float64 // Of the traces started by the service, those propagated to it being sampled as their parent is
+}
+
+// Init exports spans to an OTLP collector and propagates trace context in W3C
+// headers, returning a function flushing the spans left and stopping the export
+func Init(ctx context.Context, config Config) (func(context.Context) error, error) {
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoint)}
+	if config.Insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", config.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Start starts the span of an operation of a component, a child of the span of
+// ctx if any, returning the context carrying it. Until Init is called there is
+// no trace to propagate, and ctx is returned as is.
+func Start(ctx context.Context, component, operation string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	spanCtx, span := otel.Tracer(instrumentationName).Start(ctx, component+"."+operation, trace.WithAttributes(attributes...))
+	if !span.SpanContext().IsValid() {
+		return ctx, span
+	}
+	return spanCtx, span
+}
+
+// RecordError marks the span of ctx as failed with err, if not nil, returning it
+func RecordError(ctx context.Context, err error) error {
+	if err != nil {
+		span := trace.SpanFromContext(ctx)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// Middleware starts a span for every request to the routes of a router, named
+// after the route template, continuing the trace of the caller if propagated.
+// Connections can still be hijacked, as WebSocket upgrades do.
+func Middleware(service string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, service, otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					return r.Method + " " + template
+				}
+			}
+			return r.Method
+		}))
+	}
+}
+
+// Transport wraps an HTTP transport, starting a span for every request sent
+// through it and propagating the trace to the server called
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+// OpenDB opens a database as sql.Open does, starting a span for every query
+func OpenDB(driverName, dataSourceName, system string) (*sql.DB, error) {
+	return otelsql.Open(driverName, dataSourceName, otelsql.WithAttributes(attribute.String("db.system", system)))
+}
+
+// MongoMonitor returns a command monitor starting a span for every command a
+// MongoDB client runs
+func MongoMonitor() *event.CommandMonitor {
+	return otelmongo.NewMonitor()
+}
@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestStart(t *testing.T) {
+	// Without tracing initialized, contexts are passed on as they are
+	ctx := context.WithValue(context.Background(), "userID", "user123")
+	spanCtx, span := Start(ctx, "gateway", "CreateOrder")
+	span.End()
+	assert.Equal(t, ctx, spanCtx)
+
+	// Record spans
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	// Spans started from the context of another are its children
+	ctx, parent := Start(context.Background(), "gateway", "CreateOrder")
+	brokerCtx, child := Start(ctx, "broker", "PlaceOrder")
+	err := errors.New("order rejected")
+	assert.Equal(t, err, RecordError(brokerCtx, err))
+	child.End()
+	assert.NoError(t, RecordError(ctx, nil))
+	parent.End()
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "broker.PlaceOrder", spans[0].Name())
+		assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Equal(t, "gateway.CreateOrder", spans[1].Name())
+		assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	}
+}
@@ -0,0 +1,225 @@
+package versions
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// StrategyStore reads and saves strategies, as the strategy repository does
+type StrategyStore interface {
+	GetByID(id string) (*models.Strategy, error)
+	Update(strategy *models.Strategy) (*models.Strategy, error)
+}
+
+// PortfolioStore reads and saves portfolios, as the portfolio repository does
+type PortfolioStore interface {
+	GetByID(id string) (*models.Portfolio, error)
+	Update(portfolio *models.Portfolio) error
+}
+
+// Service records the versions of strategies and portfolios as they are saved,
+// and rolls them back to earlier versions
+type Service struct {
+	store      Store
+	strategies StrategyStore
+	portfolios PortfolioStore
+	mutex      sync.Mutex // Serializes the numbering of versions
+}
+
+// NewService creates a new Service
+func NewService(store Store, strategies StrategyStore, portfolios PortfolioStore) *Service {
+	return &Service{
+		store:      store,
+		strategies: strategies,
+		portfolios: portfolios,
+	}
+}
+
+// RecordStrategy records a strategy that was saved, by userID, as a new version
+// when its configuration changed, and stamps the strategy with its latest version
+func (s *Service) RecordStrategy(strategy *models.Strategy, userID string) (*Version, error) {
+	return s.recordStrategy(strategy, userID, 0)
+}
+
+// RecordPortfolio records a portfolio that was saved, by userID, as a new version
+// when its configuration changed, and stamps the portfolio with its latest version
+func (s *Service) RecordPortfolio(portfolio *models.Portfolio, userID string) (*Version, error) {
+	return s.recordPortfolio(portfolio, userID, 0)
+}
+
+// Owner returns the ID of the user a strategy or portfolio belongs to
+func (s *Service) Owner(kind Kind, entityID string) (string, error) {
+	switch kind {
+	case KindStrategy:
+		strategy, err := s.strategies.GetByID(entityID)
+		if err != nil {
+			return "", err
+		}
+		return strategy.UserID, nil
+	case KindPortfolio:
+		portfolio, err := s.portfolios.GetByID(entityID)
+		if err != nil {
+			return "", err
+		}
+		return portfolio.UserID, nil
+	default:
+		return "", fmt.Errorf("unknown kind: %s", kind)
+	}
+}
+
+// ListVersions returns the versions of a strategy or portfolio, newest first
+func (s *Service) ListVersions(kind Kind, entityID string) ([]Version, error) {
+	return s.store.ListVersions(kind, entityID)
+}
+
+// GetVersion returns a version of a strategy or portfolio by number
+func (s *Service) GetVersion(kind Kind, entityID string, number int) (*Version, error) {
+	return s.store.GetVersion(kind, entityID, number)
+}
+
+// Diff returns the settings changed from one version of a strategy or portfolio
+// to another
+func (s *Service) Diff(kind Kind, entityID string, from, to int) (*Diff, error) {
+	fromVersion, err := s.store.GetVersion(kind, entityID, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := s.store.GetVersion(kind, entityID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := Compare(fromVersion.Config, toVersion.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &Diff{From: from, To: to, Changes: changes}, nil
+}
+
+// Rollback restores the configuration of a version of a strategy or portfolio,
+// keeping its state, and records it as a new version saved by userID. Active
+// portfolios cannot be rolled back.
+func (s *Service) Rollback(kind Kind, entityID string, number int, userID string) (*Version, error) {
+	target, err := s.store.GetVersion(kind, entityID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case KindStrategy:
+		current, err := s.strategies.GetByID(entityID)
+		if err != nil {
+			return nil, err
+		}
+		restored := &models.Strategy{}
+		if err := Restore(kind, current, target.Config, restored); err != nil {
+			return nil, err
+		}
+		restored.UpdatedAt = time.Now()
+		if _, err := s.strategies.Update(restored); err != nil {
+			return nil, fmt.Errorf("failed to save strategy %s: %w", entityID, err)
+		}
+		return s.recordStrategy(restored, userID, number)
+
+	case KindPortfolio:
+		current, err := s.portfolios.GetByID(entityID)
+		if err != nil {
+			return nil, err
+		}
+		if current.Status == models.PortfolioStatusActive {
+			return nil, ErrActivePortfolio
+		}
+		restored := &models.Portfolio{}
+		if err := Restore(kind, current, target.Config, restored); err != nil {
+			return nil, err
+		}
+		restored.UpdatedAt = time.Now()
+		if err := s.portfolios.Update(restored); err != nil {
+			return nil, fmt.Errorf("failed to save portfolio %s: %w", entityID, err)
+		}
+		return s.recordPortfolio(restored, userID, number)
+
+	default:
+		return nil, fmt.Errorf("unknown kind: %s", kind)
+	}
+}
+
+// recordStrategy records a strategy, stamping it with its latest version
+func (s *Service) recordStrategy(strategy *models.Strategy, userID string, rolledBackFrom int) (*Version, error) {
+	version, err := s.record(KindStrategy, strategy.ID, userID, strategy, rolledBackFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	if strategy.Version != version.Number {
+		strategy.Version = version.Number
+		if _, err := s.strategies.Update(strategy); err != nil {
+			return nil, fmt.Errorf("failed to stamp strategy %s with version %d: %w", strategy.ID, version.Number, err)
+		}
+	}
+	return version, nil
+}
+
+// recordPortfolio records a portfolio, stamping it with its latest version
+func (s *Service) recordPortfolio(portfolio *models.Portfolio, userID string, rolledBackFrom int) (*Version, error) {
+	version, err := s.record(KindPortfolio, portfolio.ID, userID, portfolio, rolledBackFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	if portfolio.Version != version.Number {
+		portfolio.Version = version.Number
+		if err := s.portfolios.Update(portfolio); err != nil {
+			return nil, fmt.Errorf("failed to stamp portfolio %s with version %d: %w", portfolio.ID, version.Number, err)
+		}
+	}
+	return version, nil
+}
+
+// record saves the configuration of an entity as its next version, returning its
+// latest version instead when the configuration did not change
+func (s *Service) record(kind Kind, entityID, userID string, entity interface{}, rolledBackFrom int) (*Version, error) {
+	if entityID == "" {
+		return nil, errors.New("cannot version an entity without an ID")
+	}
+
+	config, err := Snapshot(kind, entity)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	latest, err := s.store.LatestVersion(kind, entityID)
+	if err != nil && !errors.Is(err, ErrVersionNotFound) {
+		return nil, err
+	}
+	if latest != nil && bytes.Equal(latest.Config, config) {
+		return latest, nil
+	}
+
+	version := &Version{
+		ID:             uuid.New().String(),
+		Kind:           kind,
+		EntityID:       entityID,
+		Number:         1,
+		UserID:         userID,
+		Config:         config,
+		RolledBackFrom: rolledBackFrom,
+		CreatedAt:      time.Now(),
+	}
+	if latest != nil {
+		version.Number = latest.Number + 1
+	}
+	if err := s.store.SaveVersion(version); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
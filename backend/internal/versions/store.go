@@ -0,0 +1,147 @@
+package versions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MemoryStore keeps versions in memory
+type MemoryStore struct {
+	versions map[string][]Version // By kind and entity, oldest first
+	mutex    sync.RWMutex
+}
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		versions: make(map[string][]Version),
+	}
+}
+
+// SaveVersion adds a version
+func (s *MemoryStore) SaveVersion(version *Version) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := memoryKey(version.Kind, version.EntityID)
+	s.versions[key] = append(s.versions[key], *version)
+	return nil
+}
+
+// GetVersion returns a version of a strategy or portfolio by number
+func (s *MemoryStore) GetVersion(kind Kind, entityID string, number int) (*Version, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, version := range s.versions[memoryKey(kind, entityID)] {
+		if version.Number == number {
+			return &version, nil
+		}
+	}
+	return nil, ErrVersionNotFound
+}
+
+// LatestVersion returns the latest version of a strategy or portfolio
+func (s *MemoryStore) LatestVersion(kind Kind, entityID string) (*Version, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	versions := s.versions[memoryKey(kind, entityID)]
+	if len(versions) == 0 {
+		return nil, ErrVersionNotFound
+	}
+	latest := versions[len(versions)-1]
+	return &latest, nil
+}
+
+// ListVersions returns the versions of a strategy or portfolio, newest first
+func (s *MemoryStore) ListVersions(kind Kind, entityID string) ([]Version, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	saved := s.versions[memoryKey(kind, entityID)]
+	versions := make([]Version, 0, len(saved))
+	for i := len(saved) - 1; i >= 0; i-- {
+		versions = append(versions, saved[i])
+	}
+	return versions, nil
+}
+
+// memoryKey is the key of the versions of a strategy or portfolio
+func memoryKey(kind Kind, entityID string) string {
+	return string(kind) + "/" + entityID
+}
+
+// MongoStore keeps versions in a MongoDB collection
+type MongoStore struct {
+	versions *mongo.Collection
+}
+
+// NewMongoStore creates a new MongoStore
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		versions: db.Collection("config_versions"),
+	}
+}
+
+// SaveVersion adds a version
+func (s *MongoStore) SaveVersion(version *Version) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.versions.InsertOne(ctx, version)
+	return err
+}
+
+// GetVersion returns a version of a strategy or portfolio by number
+func (s *MongoStore) GetVersion(kind Kind, entityID string, number int) (*Version, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.findOne(ctx, bson.M{"kind": kind, "entityId": entityID, "number": number}, options.FindOne())
+}
+
+// LatestVersion returns the latest version of a strategy or portfolio
+func (s *MongoStore) LatestVersion(kind Kind, entityID string) (*Version, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.findOne(ctx, bson.M{"kind": kind, "entityId": entityID}, options.FindOne().SetSort(bson.M{"number": -1}))
+}
+
+// ListVersions returns the versions of a strategy or portfolio, newest first
+func (s *MongoStore) ListVersions(kind Kind, entityID string) ([]Version, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query := bson.M{"kind": kind, "entityId": entityID}
+	cursor, err := s.versions.Find(ctx, query, options.Find().SetSort(bson.M{"number": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	versions := make([]Version, 0)
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// findOne returns the version found by a query
+func (s *MongoStore) findOne(ctx context.Context, query bson.M, findOptions *options.FindOneOptions) (*Version, error) {
+	var version Version
+	err := s.versions.FindOne(ctx, query, findOptions).Decode(&version)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
@@ -0,0 +1,235 @@
+// Package versions keeps the history of the configuration of strategies and
+// portfolios. Every save changing a configuration records an immutable, numbered
+// version, which users can compare with any other and roll back to. The number
+// of the latest version is stamped on the strategy or portfolio, so that the
+// orders and executions it places record the version they ran for post-mortems.
+package versions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrVersionNotFound is returned when a version does not exist
+	ErrVersionNotFound = errors.New("version not found")
+	// ErrActivePortfolio is returned when rolling back a portfolio that is running,
+	// whose legs would lose track of their orders
+	ErrActivePortfolio = errors.New("cannot roll back an active portfolio")
+)
+
+// Kind is the kind of entity a version is the configuration of
+type Kind string
+
+const (
+	KindStrategy  Kind = "STRATEGY"
+	KindPortfolio Kind = "PORTFOLIO"
+)
+
+// volatileFields are the fields of each kind of entity that are state rather than
+// configuration, by their JSON name. They are left out of versions and kept as
+// they are by rollbacks.
+var volatileFields = map[Kind][]string{
+	KindStrategy: {
+		"id", "userId", "status", "version", "createdAt", "updatedAt", "lastExecutedAt",
+		"haltReason", "haltedAt", "rearmedAt",
+		"paperStrategyId", "liveStrategyId", "promotedAt", "backtestSessionId",
+	},
+	KindPortfolio: {
+		"id", "userId", "status", "version", "createdAt", "updatedAt",
+		"estimatedMargin", "entryValue", "currentValue", "maxValue", "minValue",
+		"unrealizedPnL", "realizedPnL", "totalPnL", "pnLPercentage",
+		"delta", "gamma", "theta", "vega",
+		"executionStartTime", "executionEndTime", "lastMonitorTime", "executionLogs",
+	},
+}
+
+// volatileLegFields are the fields of portfolio legs that are state rather than
+// configuration
+var volatileLegFields = []string{
+	"entryPrice", "currentPrice", "exitPrice",
+	"delta", "gamma", "theta", "vega",
+	"status", "entryOrderId", "exitOrderId", "entryTime", "exitTime", "entrySlippage", "exitSlippage",
+	"unrealizedPnL", "realizedPnL", "totalPnL", "pnLPercentage",
+	"createdAt", "updatedAt",
+}
+
+// Version is the configuration of a strategy or portfolio as it was saved
+type Version struct {
+	ID             string          `json:"id" bson:"_id"`
+	Kind           Kind            `json:"kind" bson:"kind"`
+	EntityID       string          `json:"entityId" bson:"entityId"`
+	Number         int             `json:"number" bson:"number"` // From 1, in the order versions were saved
+	UserID         string          `json:"userId" bson:"userId"` // Who saved it
+	Config         json.RawMessage `json:"config" bson:"config"`
+	RolledBackFrom int             `json:"rolledBackFrom,omitempty" bson:"rolledBackFrom,omitempty"` // Version rolled back to by saving this one
+	CreatedAt      time.Time       `json:"createdAt" bson:"createdAt"`
+}
+
+// Change is a setting that differs between two versions, by its path such as
+// stopLossValue or legs.1.lots. From is nil for settings added, To for those
+// removed.
+type Change struct {
+	Path string      `json:"path"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Diff is the settings changed from one version to another
+type Diff struct {
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Changes []Change `json:"changes"`
+}
+
+// Store keeps versions, which are never changed once saved
+type Store interface {
+	SaveVersion(version *Version) error
+	GetVersion(kind Kind, entityID string, number int) (*Version, error)
+	LatestVersion(kind Kind, entityID string) (*Version, error) // ErrVersionNotFound when there is none
+	ListVersions(kind Kind, entityID string) ([]Version, error) // Newest first
+}
+
+// Snapshot returns the configuration of a strategy or portfolio, without its
+// state
+func Snapshot(kind Kind, entity interface{}) (json.RawMessage, error) {
+	fields, err := toFields(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range volatileFields[kind] {
+		delete(fields, name)
+	}
+	if legs, ok := fields["legs"].([]interface{}); ok && kind == KindPortfolio {
+		for _, leg := range legs {
+			if legFields, ok := leg.(map[string]interface{}); ok {
+				for _, name := range volatileLegFields {
+					delete(legFields, name)
+				}
+			}
+		}
+	}
+
+	// Maps marshal with sorted keys, so equal configurations are equal bytes
+	return json.Marshal(fields)
+}
+
+// Restore sets restored to the configuration of a version, keeping the state of
+// current, the strategy or portfolio it is a version of
+func Restore(kind Kind, current interface{}, config json.RawMessage, restored interface{}) error {
+	state, err := toFields(current)
+	if err != nil {
+		return err
+	}
+
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(config, &fields); err != nil {
+		return fmt.Errorf("invalid version configuration: %w", err)
+	}
+	for _, name := range volatileFields[kind] {
+		if value, exists := state[name]; exists {
+			fields[name] = value
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, restored)
+}
+
+// Compare returns the settings that differ between two configurations, sorted by
+// path
+func Compare(from, to json.RawMessage) ([]Change, error) {
+	fromSettings, err := settings(from)
+	if err != nil {
+		return nil, err
+	}
+	toSettings, err := settings(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, 0)
+	for path, value := range fromSettings {
+		changed, exists := toSettings[path]
+		if !exists {
+			changes = append(changes, Change{Path: path, From: value})
+		} else if !reflect.DeepEqual(value, changed) {
+			changes = append(changes, Change{Path: path, From: value, To: changed})
+		}
+	}
+	for path, value := range toSettings {
+		if _, exists := fromSettings[path]; !exists {
+			changes = append(changes, Change{Path: path, To: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// settings flattens a configuration into its settings by path
+func settings(config json.RawMessage) (map[string]interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(config, &value); err != nil {
+		return nil, fmt.Errorf("invalid version configuration: %w", err)
+	}
+
+	flattened := make(map[string]interface{})
+	flatten("", value, flattened)
+	return flattened, nil
+}
+
+// flatten adds the settings of value under prefix. Empty objects and arrays are
+// settings of their own, so that they differ from missing ones.
+func flatten(prefix string, value interface{}, flattened map[string]interface{}) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if len(typed) == 0 && prefix != "" {
+			flattened[prefix] = typed
+		}
+		for key, nested := range typed {
+			flatten(join(key), nested, flattened)
+		}
+	case []interface{}:
+		if len(typed) == 0 {
+			flattened[prefix] = typed
+		}
+		for i, nested := range typed {
+			flatten(join(strconv.Itoa(i)), nested, flattened)
+		}
+	default:
+		flattened[prefix] = typed
+	}
+}
+
+// toFields returns the fields of an entity by their JSON name
+func toFields(entity interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
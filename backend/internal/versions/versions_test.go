@@ -0,0 +1,195 @@
+package versions
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// stubStrategies is a strategy store of strategies by ID
+type stubStrategies map[string]*models.Strategy
+
+func (s stubStrategies) GetByID(id string) (*models.Strategy, error) {
+	strategy, exists := s[id]
+	if !exists {
+		return nil, errors.New("strategy not found")
+	}
+	copied := *strategy
+	return &copied, nil
+}
+
+func (s stubStrategies) Update(strategy *models.Strategy) (*models.Strategy, error) {
+	copied := *strategy
+	s[strategy.ID] = &copied
+	return strategy, nil
+}
+
+// stubPortfolios is a portfolio store of portfolios by ID
+type stubPortfolios map[string]*models.Portfolio
+
+func (s stubPortfolios) GetByID(id string) (*models.Portfolio, error) {
+	portfolio, exists := s[id]
+	if !exists {
+		return nil, errors.New("portfolio not found")
+	}
+	copied := *portfolio
+	copied.Legs = append([]models.Leg(nil), portfolio.Legs...)
+	return &copied, nil
+}
+
+func (s stubPortfolios) Update(portfolio *models.Portfolio) error {
+	copied := *portfolio
+	copied.Legs = append([]models.Leg(nil), portfolio.Legs...)
+	s[portfolio.ID] = &copied
+	return nil
+}
+
+func TestPortfolioVersions(t *testing.T) {
+	portfolios := stubPortfolios{}
+	service := NewService(NewMemoryStore(), stubStrategies{}, portfolios)
+
+	portfolio := &models.Portfolio{
+		ID:            "straddle",
+		UserID:        "user1",
+		Name:          "Straddle",
+		Status:        models.PortfolioStatusActive,
+		StopLossValue: 1000,
+		Legs: []models.Leg{
+			{ID: 1, Symbol: "NIFTY", Lots: 1, BuySell: "SELL"},
+			{ID: 2, Symbol: "NIFTY", Lots: 1, BuySell: "SELL"},
+		},
+	}
+	require.NoError(t, portfolios.Update(portfolio))
+
+	// The first save is version 1, stamped on the portfolio
+	version, err := service.RecordPortfolio(portfolio, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, version.Number)
+	assert.Equal(t, 1, portfolio.Version)
+	assert.Equal(t, 1, portfolios["straddle"].Version)
+
+	// Saving state alone records no version
+	portfolio.TotalPnL = -250
+	portfolio.ExecutionLogs = []string{"Entered"}
+	portfolio.Legs[0].CurrentPrice = 120
+	portfolio.Legs[0].Status = "OPEN"
+	require.NoError(t, portfolios.Update(portfolio))
+	version, err = service.RecordPortfolio(portfolio, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, version.Number)
+
+	// Changing the configuration records the next version
+	portfolio.StopLossValue = 1500
+	portfolio.Legs[1].Lots = 2
+	portfolio.Legs = append(portfolio.Legs, models.Leg{ID: 3, Symbol: "BANKNIFTY", Lots: 1, BuySell: "BUY"})
+	require.NoError(t, portfolios.Update(portfolio))
+	version, err = service.RecordPortfolio(portfolio, "user2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, version.Number)
+	assert.Equal(t, "user2", version.UserID)
+	assert.Equal(t, 2, portfolios["straddle"].Version)
+
+	listed, err := service.ListVersions(KindPortfolio, "straddle")
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, 2, listed[0].Number)
+
+	diff, err := service.Diff(KindPortfolio, "straddle", 1, 2)
+	require.NoError(t, err)
+	paths := make([]string, 0, len(diff.Changes))
+	for _, change := range diff.Changes {
+		paths = append(paths, change.Path)
+	}
+	assert.Contains(t, paths, "stopLossValue")
+	assert.Contains(t, paths, "legs.1.lots")
+	assert.Contains(t, paths, "legs.2.symbol")
+	assert.NotContains(t, paths, "totalPnL")
+	assert.NotContains(t, paths, "legs.0.currentPrice")
+	assert.Equal(t, Change{Path: "stopLossValue", From: float64(1000), To: float64(1500)}, diff.Changes[len(diff.Changes)-1])
+
+	_, err = service.GetVersion(KindPortfolio, "straddle", 3)
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+
+	// Active portfolios are not rolled back
+	_, err = service.Rollback(KindPortfolio, "straddle", 1, "user1")
+	assert.ErrorIs(t, err, ErrActivePortfolio)
+
+	// Rolling back restores the configuration as a new version, keeping the state
+	portfolios["straddle"].Status = models.PortfolioStatusCompleted
+	version, err = service.Rollback(KindPortfolio, "straddle", 1, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, version.Number)
+	assert.Equal(t, 1, version.RolledBackFrom)
+
+	restored := portfolios["straddle"]
+	assert.Equal(t, 3, restored.Version)
+	assert.Equal(t, float64(1000), restored.StopLossValue)
+	assert.Len(t, restored.Legs, 2)
+	assert.Equal(t, 1, restored.Legs[1].Lots)
+	assert.Equal(t, float64(-250), restored.TotalPnL)
+	assert.Equal(t, models.PortfolioStatusCompleted, restored.Status)
+
+	diff, err = service.Diff(KindPortfolio, "straddle", 1, 3)
+	require.NoError(t, err)
+	assert.Empty(t, diff.Changes)
+}
+
+func TestStrategyVersions(t *testing.T) {
+	strategies := stubStrategies{}
+	service := NewService(NewMemoryStore(), strategies, stubPortfolios{})
+
+	strategy := &models.Strategy{
+		ID:          "strategy1",
+		UserID:      "user1",
+		Name:        "Momentum",
+		Status:      models.StrategyStatusActive,
+		Instruments: []string{"NIFTY"},
+	}
+	_, err := strategies.Update(strategy)
+	require.NoError(t, err)
+	_, err = service.RecordStrategy(strategy, "user1")
+	require.NoError(t, err)
+
+	strategy.Instruments = []string{"NIFTY", "BANKNIFTY"}
+	strategy.MaxTradesPerDay = 5
+	_, err = strategies.Update(strategy)
+	require.NoError(t, err)
+	version, err := service.RecordStrategy(strategy, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, version.Number)
+
+	diff, err := service.Diff(KindStrategy, "strategy1", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Path: "instruments.1", To: "BANKNIFTY"},
+		{Path: "maxTradesPerDay", To: float64(5)},
+	}, diff.Changes)
+
+	// Active strategies are rolled back, staying active
+	version, err = service.Rollback(KindStrategy, "strategy1", 1, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, version.Number)
+	assert.Equal(t, []string{"NIFTY"}, strategies["strategy1"].Instruments)
+	assert.Zero(t, strategies["strategy1"].MaxTradesPerDay)
+	assert.Equal(t, models.StrategyStatusActive, strategies["strategy1"].Status)
+	assert.Equal(t, 3, strategies["strategy1"].Version)
+}
+
+func TestCompare(t *testing.T) {
+	changes, err := Compare(
+		json.RawMessage(`{"name":"a","tags":["x"],"settings":{"lots":1}}`),
+		json.RawMessage(`{"name":"b","tags":[],"settings":{}}`),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Path: "name", From: "a", To: "b"},
+		{Path: "settings", To: map[string]interface{}{}},
+		{Path: "settings.lots", From: float64(1)},
+		{Path: "tags", To: []interface{}{}},
+		{Path: "tags.0", From: "x"},
+	}, changes)
+}
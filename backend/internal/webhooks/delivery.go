@@ -0,0 +1,311 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Headers of the requests made to webhooks
+const (
+	HeaderWebhookID = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature" // sha256=<Sign(secret, timestamp, body)>
+)
+
+// maxResponseSize is how much of the responses of webhooks is read, responses
+// being discarded
+const maxResponseSize = 64 << 10
+
+// Config configures how events are delivered to webhooks
+type Config struct {
+	Workers        int           // Delivering events concurrently
+	QueueSize      int           // Of the deliveries waiting for a worker
+	Timeout        time.Duration // Of every attempt
+	MaxAttempts    int           // Before a delivery is given up on
+	InitialBackoff time.Duration // Before the first retry, doubling for every retry after
+	MaxBackoff     time.Duration // Between retries
+
+	// AllowPrivateNetworks lets webhooks be delivered to loopback, private and
+	// link-local addresses, for development and tests only
+	AllowPrivateNetworks bool
+}
+
+// DefaultConfig returns the default delivery configuration, retrying failed
+// deliveries for about two hours
+func DefaultConfig() Config {
+	return Config{
+		Workers:        4,
+		QueueSize:      1000,
+		Timeout:        10 * time.Second,
+		MaxAttempts:    8,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     time.Hour,
+	}
+}
+
+// withDefaults returns the configuration with the values of DefaultConfig for those
+// not positive
+func (c Config) withDefaults() Config {
+	defaults := DefaultConfig()
+	if c.Workers <= 0 {
+		c.Workers = defaults.Workers
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaults.QueueSize
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaults.Timeout
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaults.MaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaults.InitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaults.MaxBackoff
+	}
+	return c
+}
+
+// backoff returns how long to wait before retrying a delivery after its attempts
+func (c Config) backoff(attempts int) time.Duration {
+	delay := c.InitialBackoff
+	for i := 1; i < attempts && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+	return delay
+}
+
+// newHTTPClient returns a client making requests to webhooks, not following
+// redirects for webhooks to be registered with their final URL. Unless private
+// networks are allowed, connections to forbidden addresses are refused as they
+// are dialed, so that a host name resolving to one after it was checked cannot
+// reach the platform's own network. Proxies are not used, for the addresses
+// dialed to be those of the webhooks.
+func newHTTPClient(timeout time.Duration, allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateNetworks {
+		dialer.Control = refuseForbiddenAddress
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// forbiddenIP reports whether an address is of the platform's own network:
+// loopback, private, link-local or unspecified
+func forbiddenIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// refuseForbiddenAddress is the dialer control refusing connections to forbidden
+// addresses
+func refuseForbiddenAddress(network, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || forbiddenIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// checkHost resolves the host of a webhook, failing when any of its addresses is
+// forbidden
+func checkHost(ctx context.Context, host string) error {
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	for _, address := range addresses {
+		if forbiddenIP(address.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenAddress, host, address.IP)
+		}
+	}
+	return nil
+}
+
+// Sign returns the signature of a payload sent at timestamp, the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook's secret. Receivers
+// verify it to know the payload came from the platform and was not replayed.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Start starts delivering the events published
+func (s *Service) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isRunning {
+		return errors.New("webhook service is already running")
+	}
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+
+	for i := 0; i < s.config.Workers; i++ {
+		s.workers.Add(1)
+		go s.worker(s.stopChan)
+	}
+	return nil
+}
+
+// Stop stops delivering events, waiting for the attempts in progress. Deliveries
+// waiting to be attempted are left pending in the delivery log.
+func (s *Service) Stop() {
+	s.mutex.Lock()
+	if !s.isRunning {
+		s.mutex.Unlock()
+		return
+	}
+	close(s.stopChan)
+	s.isRunning = false
+	s.mutex.Unlock()
+
+	s.workers.Wait()
+}
+
+// enqueue queues a delivery for a worker, retrying later when the queue is full
+func (s *Service) enqueue(delivery *Delivery) {
+	select {
+	case s.queue <- delivery:
+	default:
+		log.Printf("Webhook delivery queue is full, delaying delivery %s", delivery.ID)
+		time.AfterFunc(s.config.InitialBackoff, func() {
+			s.enqueue(delivery)
+		})
+	}
+}
+
+// worker attempts the deliveries queued until stop is closed
+func (s *Service) worker(stop chan struct{}) {
+	defer s.workers.Done()
+
+	for {
+		select {
+		case delivery := <-s.queue:
+			s.attempt(delivery)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// attempt attempts a delivery, scheduling a retry when it fails and attempts are
+// left, and logs its outcome
+func (s *Service) attempt(delivery *Delivery) {
+	webhook, err := s.store.GetWebhook(delivery.WebhookID)
+	if err != nil || !webhook.Active {
+		delivery.Status = DeliveryFailed
+		delivery.Error = "webhook was deleted or disabled"
+		delivery.NextAttemptAt = time.Time{}
+		s.saveDelivery(delivery)
+		return
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = now
+	delivery.ResponseStatus, err = s.send(webhook, delivery, now)
+
+	var retryIn time.Duration
+	switch {
+	case err == nil:
+		delivery.Status = DeliverySucceeded
+		delivery.Error = ""
+		delivery.DeliveredAt = time.Now()
+		delivery.NextAttemptAt = time.Time{}
+	case delivery.Attempts >= s.config.MaxAttempts || errors.Is(err, ErrForbiddenAddress):
+		// Forbidden addresses are not retried, the webhook has to be changed
+		delivery.Status = DeliveryFailed
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = time.Time{}
+	default:
+		retryIn = s.config.backoff(delivery.Attempts)
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = now.Add(retryIn)
+	}
+	s.saveDelivery(delivery)
+
+	if retryIn > 0 {
+		time.AfterFunc(retryIn, func() {
+			s.enqueue(delivery)
+		})
+	}
+}
+
+// send posts the payload of a delivery to a webhook, signed, returning the status
+// of the response if any
+func (s *Service) send(webhook *Webhook, delivery *Delivery, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	if !s.config.AllowPrivateNetworks {
+		if err := checkHost(ctx, req.URL.Hostname()); err != nil {
+			return 0, err
+		}
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookID, webhook.ID)
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// saveDelivery logs the outcome of a delivery
+func (s *Service) saveDelivery(delivery *Delivery) {
+	if err := s.store.SaveDelivery(delivery); err != nil {
+		log.Printf("Error logging delivery %s to webhook %s: %v", delivery.ID, delivery.WebhookID, err)
+	}
+}
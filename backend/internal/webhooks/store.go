@@ -0,0 +1,245 @@
+package webhooks
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxMemoryDeliveries is the number of deliveries the memory store keeps per
+// webhook, the oldest being dropped
+const maxMemoryDeliveries = 1000
+
+// MemoryStore keeps webhooks and their deliveries in memory
+type MemoryStore struct {
+	webhooks   map[string]Webhook
+	deliveries map[string][]Delivery // By webhook, oldest first
+	mutex      sync.RWMutex
+}
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		webhooks:   make(map[string]Webhook),
+		deliveries: make(map[string][]Delivery),
+	}
+}
+
+// SaveWebhook adds or replaces a webhook
+func (s *MemoryStore) SaveWebhook(webhook *Webhook) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+// GetWebhook returns a webhook by ID
+func (s *MemoryStore) GetWebhook(id string) (*Webhook, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	webhook, exists := s.webhooks[id]
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks returns the webhooks of a user, every webhook when userID is empty,
+// oldest first
+func (s *MemoryStore) ListWebhooks(userID string) ([]Webhook, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	webhooks := make([]Webhook, 0)
+	for _, webhook := range s.webhooks {
+		if userID == "" || webhook.UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook along with its deliveries
+func (s *MemoryStore) DeleteWebhook(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.webhooks[id]; !exists {
+		return ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+	delete(s.deliveries, id)
+	return nil
+}
+
+// SaveDelivery adds or replaces a delivery
+func (s *MemoryStore) SaveDelivery(delivery *Delivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	deliveries := s.deliveries[delivery.WebhookID]
+	for i := range deliveries {
+		if deliveries[i].ID == delivery.ID {
+			deliveries[i] = *delivery
+			return nil
+		}
+	}
+	if len(deliveries) >= maxMemoryDeliveries {
+		deliveries = deliveries[1:]
+	}
+	s.deliveries[delivery.WebhookID] = append(deliveries, *delivery)
+	return nil
+}
+
+// ListDeliveries returns the deliveries selected by a filter, newest first
+func (s *MemoryStore) ListDeliveries(filter DeliveryFilter) ([]Delivery, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	deliveries := make([]Delivery, 0)
+	for webhookID, logged := range s.deliveries {
+		if filter.WebhookID != "" && webhookID != filter.WebhookID {
+			continue
+		}
+		for _, delivery := range logged {
+			if filter.Matches(delivery) {
+				deliveries = append(deliveries, delivery)
+			}
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+	if filter.Limit > 0 && len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+	return deliveries, nil
+}
+
+// MongoStore keeps webhooks and their deliveries in MongoDB collections
+type MongoStore struct {
+	webhooks   *mongo.Collection
+	deliveries *mongo.Collection
+}
+
+// NewMongoStore creates a new MongoStore
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		webhooks:   db.Collection("webhooks"),
+		deliveries: db.Collection("webhook_deliveries"),
+	}
+}
+
+// SaveWebhook adds or replaces a webhook
+func (s *MongoStore) SaveWebhook(webhook *Webhook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.webhooks.ReplaceOne(ctx, bson.M{"_id": webhook.ID}, webhook, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetWebhook returns a webhook by ID
+func (s *MongoStore) GetWebhook(id string) (*Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var webhook Webhook
+	err := s.webhooks.FindOne(ctx, bson.M{"_id": id}).Decode(&webhook)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks returns the webhooks of a user, every webhook when userID is empty,
+// oldest first
+func (s *MongoStore) ListWebhooks(userID string) ([]Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	if userID != "" {
+		query["userId"] = userID
+	}
+
+	cursor, err := s.webhooks.Find(ctx, query, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	webhooks := make([]Webhook, 0)
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook along with its deliveries
+func (s *MongoStore) DeleteWebhook(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := s.webhooks.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrWebhookNotFound
+	}
+	_, err = s.deliveries.DeleteMany(ctx, bson.M{"webhookId": id})
+	return err
+}
+
+// SaveDelivery adds or replaces a delivery
+func (s *MongoStore) SaveDelivery(delivery *Delivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.deliveries.ReplaceOne(ctx, bson.M{"_id": delivery.ID}, delivery, options.Replace().SetUpsert(true))
+	return err
+}
+
+// ListDeliveries returns the deliveries selected by a filter, newest first
+func (s *MongoStore) ListDeliveries(filter DeliveryFilter) ([]Delivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.WebhookID != "" {
+		query["webhookId"] = filter.WebhookID
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	findOptions := options.Find().SetSort(bson.M{"createdAt": -1})
+	if filter.Limit > 0 {
+		findOptions.SetLimit(int64(filter.Limit))
+	}
+
+	cursor, err := s.deliveries.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := make([]Delivery, 0)
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,407 @@
+// Package webhooks delivers order lifecycle events, position closes and alerts to
+// the URLs users register. Every payload is signed with the webhook's secret, failed
+// deliveries are retried with exponential backoff and each delivery is logged for
+// users to inspect.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trading-platform/backend/internal/messagequeue"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// EventType is the type of the events webhooks subscribe to
+type EventType string
+
+// Events delivered to webhooks. Order events are named like the order events
+// published to the message queue.
+const (
+	EventOrderNew       EventType = EventType(messagequeue.OrderNew)
+	EventOrderAcked     EventType = EventType(messagequeue.OrderAcked)
+	EventOrderFill      EventType = EventType(messagequeue.OrderFill)
+	EventOrderCancel    EventType = EventType(messagequeue.OrderCancel)
+	EventOrderReject    EventType = EventType(messagequeue.OrderReject)
+	EventPositionClosed EventType = "position.closed"
+	EventAlertTriggered EventType = "alert.triggered"
+)
+
+// eventTypes are the events webhooks can subscribe to
+var eventTypes = map[EventType]bool{
+	EventOrderNew: true, EventOrderAcked: true, EventOrderFill: true,
+	EventOrderCancel: true, EventOrderReject: true, EventPositionClosed: true,
+	EventAlertTriggered: true,
+}
+
+// minSecretLength is the length of the shortest secret accepted
+const minSecretLength = 16
+
+var (
+	// ErrWebhookNotFound is returned when a webhook does not exist
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrInvalidURL is returned for webhook URLs other than absolute HTTP(S) URLs
+	ErrInvalidURL = errors.New("webhook URL must be an absolute http or https URL")
+	// ErrSecretTooShort is returned for secrets too short to sign payloads safely
+	ErrSecretTooShort = errors.New("webhook secret must be at least 16 characters")
+	// ErrUnknownEvent is returned when subscribing to an event that does not exist
+	ErrUnknownEvent = errors.New("unknown webhook event")
+	// ErrForbiddenAddress is returned for webhooks at addresses of the platform's own
+	// network, which users must not be able to make requests to
+	ErrForbiddenAddress = errors.New("webhook URL must not be a loopback, private, link-local or unspecified address")
+)
+
+// Webhook is a URL a user registered to be notified of their events
+type Webhook struct {
+	ID        string      `json:"id" bson:"_id"`
+	UserID    string      `json:"userId" bson:"userId"`
+	URL       string      `json:"url" bson:"url"`
+	Secret    string      `json:"secret,omitempty" bson:"secret"` // Only returned when the webhook is created
+	Events    []EventType `json:"events" bson:"events"`           // Every event when empty
+	Active    bool        `json:"active" bson:"active"`
+	CreatedAt time.Time   `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Subscribes reports whether the webhook is notified of an event type
+func (w *Webhook) Subscribes(eventType EventType) bool {
+	if !w.Active {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, subscribed := range w.Events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Request registers or changes a webhook. A secret is generated when none is
+// given on registration, and kept when none is given on change.
+type Request struct {
+	URL    string      `json:"url"`
+	Secret string      `json:"secret"`
+	Events []EventType `json:"events"`
+	Active *bool       `json:"active"` // Active when not given
+}
+
+// Validate checks the request is valid
+func (r *Request) Validate() error {
+	parsed, err := url.Parse(r.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidURL
+	}
+	if r.Secret != "" && len(r.Secret) < minSecretLength {
+		return ErrSecretTooShort
+	}
+	for _, eventType := range r.Events {
+		if !eventTypes[eventType] {
+			return ErrUnknownEvent
+		}
+	}
+	return nil
+}
+
+// validate checks a request is valid and, unless private networks are allowed,
+// that its URL is not the address of a host of the platform's own network. Host
+// names are checked when they are resolved, on delivery.
+func (s *Service) validate(request Request) error {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+	if s.config.AllowPrivateNetworks {
+		return nil
+	}
+
+	parsed, err := url.Parse(request.URL)
+	if err != nil {
+		return ErrInvalidURL
+	}
+	if ip := net.ParseIP(parsed.Hostname()); ip != nil && forbiddenIP(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// DeliveryStatus is the state of the delivery of an event to a webhook
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "PENDING" // Not attempted yet, or waiting to be retried
+	DeliverySucceeded DeliveryStatus = "SUCCEEDED"
+	DeliveryFailed    DeliveryStatus = "FAILED" // Given up on after the last attempt
+)
+
+// Delivery is the delivery of an event to a webhook, logged with its outcome
+type Delivery struct {
+	ID             string          `json:"id" bson:"_id"`
+	WebhookID      string          `json:"webhookId" bson:"webhookId"`
+	UserID         string          `json:"userId" bson:"userId"`
+	EventID        string          `json:"eventId" bson:"eventId"`
+	EventType      EventType       `json:"eventType" bson:"eventType"`
+	Payload        json.RawMessage `json:"payload" bson:"payload"`
+	Status         DeliveryStatus  `json:"status" bson:"status"`
+	Attempts       int             `json:"attempts" bson:"attempts"`
+	ResponseStatus int             `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"` // Of the last attempt
+	Error          string          `json:"error,omitempty" bson:"error,omitempty"`                   // Of the last attempt
+	CreatedAt      time.Time       `json:"createdAt" bson:"createdAt"`
+	LastAttemptAt  time.Time       `json:"lastAttemptAt,omitempty" bson:"lastAttemptAt,omitempty"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt,omitempty" bson:"nextAttemptAt,omitempty"`
+	DeliveredAt    time.Time       `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+}
+
+// DeliveryFilter selects deliveries, empty fields matching every delivery
+type DeliveryFilter struct {
+	WebhookID string
+	Status    DeliveryStatus
+	Limit     int // Of the deliveries returned, newest first
+}
+
+// Matches reports whether a delivery is selected by the filter
+func (f DeliveryFilter) Matches(delivery Delivery) bool {
+	return (f.WebhookID == "" || delivery.WebhookID == f.WebhookID) &&
+		(f.Status == "" || delivery.Status == f.Status)
+}
+
+// Payload is the body of the requests made to webhooks
+type Payload struct {
+	ID        string      `json:"id"` // Of the event, the same for every attempt and webhook
+	Type      EventType   `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// Store persists webhooks and their deliveries
+type Store interface {
+	SaveWebhook(webhook *Webhook) error
+	GetWebhook(id string) (*Webhook, error)
+	ListWebhooks(userID string) ([]Webhook, error) // Every webhook when userID is empty
+	DeleteWebhook(id string) error
+	SaveDelivery(delivery *Delivery) error
+	ListDeliveries(filter DeliveryFilter) ([]Delivery, error)
+}
+
+// Service registers webhooks and delivers events to them
+type Service struct {
+	store     Store
+	config    Config
+	client    *http.Client
+	queue     chan *Delivery
+	stopChan  chan struct{}
+	isRunning bool
+	workers   sync.WaitGroup
+	mutex     sync.Mutex
+}
+
+// NewService creates a new Service. Events are delivered once started.
+func NewService(store Store, config Config) *Service {
+	config = config.withDefaults()
+	return &Service{
+		store:  store,
+		config: config,
+		client: newHTTPClient(config.Timeout, config.AllowPrivateNetworks),
+		queue:  make(chan *Delivery, config.QueueSize),
+	}
+}
+
+// CreateWebhook registers a webhook for a user
+func (s *Service) CreateWebhook(userID string, request Request) (*Webhook, error) {
+	if err := s.validate(request); err != nil {
+		return nil, err
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		generated, err := generateSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+
+	now := time.Now()
+	webhook := &Webhook{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		URL:       request.URL,
+		Secret:    secret,
+		Events:    request.Events,
+		Active:    request.Active == nil || *request.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.store.SaveWebhook(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// GetWebhook returns a webhook by ID, with its secret
+func (s *Service) GetWebhook(id string) (*Webhook, error) {
+	return s.store.GetWebhook(id)
+}
+
+// ListWebhooks returns the webhooks of a user, every webhook when userID is empty
+func (s *Service) ListWebhooks(userID string) ([]Webhook, error) {
+	return s.store.ListWebhooks(userID)
+}
+
+// UpdateWebhook changes the URL, events, secret or state of a webhook. Deliveries
+// pending are made to the webhook as changed.
+func (s *Service) UpdateWebhook(id string, request Request) (*Webhook, error) {
+	if err := s.validate(request); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.store.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+	webhook.URL = request.URL
+	webhook.Events = request.Events
+	if request.Secret != "" {
+		webhook.Secret = request.Secret
+	}
+	if request.Active != nil {
+		webhook.Active = *request.Active
+	}
+	webhook.UpdatedAt = time.Now()
+
+	if err := s.store.SaveWebhook(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes a webhook. Deliveries pending to it are given up on.
+func (s *Service) DeleteWebhook(id string) error {
+	return s.store.DeleteWebhook(id)
+}
+
+// ListDeliveries returns the deliveries selected by a filter, newest first
+func (s *Service) ListDeliveries(filter DeliveryFilter) ([]Delivery, error) {
+	return s.store.ListDeliveries(filter)
+}
+
+// PublishOrderEvent delivers an order event to the webhooks of the order's user
+// subscribed to it. It implements services.OrderEventPublisher, for the order
+// service to publish order lifecycle events to webhooks.
+func (s *Service) PublishOrderEvent(ctx context.Context, msgType messagequeue.MessageType, data interface{}) error {
+	var event messagequeue.OrderEvent
+	switch data := data.(type) {
+	case messagequeue.OrderEvent:
+		event = data
+	case *messagequeue.OrderEvent:
+		event = *data
+	default:
+		return errors.New("order events must be messagequeue.OrderEvent")
+	}
+
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	createdAt := event.Timestamp
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	return s.publish(event.UserID, Payload{
+		ID:        event.ID,
+		Type:      EventType(msgType),
+		CreatedAt: createdAt,
+		Data:      event,
+	})
+}
+
+// PublishPositionClosed delivers the close of a position to the webhooks of its
+// user subscribed to it. A nil service delivers nothing, so that webhooks stay
+// optional for the components publishing to them.
+func (s *Service) PublishPositionClosed(ctx context.Context, position *models.Position) {
+	if s == nil || position == nil {
+		return
+	}
+	err := s.publish(position.UserID, Payload{
+		ID:        uuid.New().String(),
+		Type:      EventPositionClosed,
+		CreatedAt: time.Now(),
+		Data:      position,
+	})
+	if err != nil {
+		log.Printf("Error publishing close of position %s to webhooks: %v", position.ID, err)
+	}
+}
+
+// PublishAlert delivers an alert triggered for a user to their webhooks subscribed
+// to alerts
+func (s *Service) PublishAlert(ctx context.Context, userID, alertID string, alert interface{}) error {
+	return s.publish(userID, Payload{
+		ID:        alertID,
+		Type:      EventAlertTriggered,
+		CreatedAt: time.Now(),
+		Data:      alert,
+	})
+}
+
+// publish logs a delivery of a payload to every webhook of a user subscribed to
+// it and queues them
+func (s *Service) publish(userID string, payload Payload) error {
+	if userID == "" {
+		return nil
+	}
+	webhooks, err := s.store.ListWebhooks(userID)
+	if err != nil {
+		return err
+	}
+
+	var body json.RawMessage
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if !webhook.Subscribes(payload.Type) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(payload); err != nil {
+				return err
+			}
+		}
+
+		delivery := &Delivery{
+			ID:            uuid.New().String(),
+			WebhookID:     webhook.ID,
+			UserID:        userID,
+			EventID:       payload.ID,
+			EventType:     payload.Type,
+			Payload:       body,
+			Status:        DeliveryPending,
+			CreatedAt:     time.Now(),
+			NextAttemptAt: time.Now(),
+		}
+		if err := s.store.SaveDelivery(delivery); err != nil {
+			log.Printf("Error logging delivery of %s %s to webhook %s: %v", payload.Type, payload.ID, webhook.ID, err)
+			continue
+		}
+		s.enqueue(delivery)
+	}
+	return nil
+}
+
+// generateSecret returns a random secret to sign payloads with
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
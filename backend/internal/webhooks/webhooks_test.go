@@ -0,0 +1,231 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trading-platform/backend/internal/messagequeue"
+	"github.com/trading-platform/backend/internal/models"
+)
+
+// receiver is a webhook endpoint recording the requests it receives, failing the
+// first failures of them
+type receiver struct {
+	server   *httptest.Server
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+	mutex    sync.Mutex
+}
+
+func newReceiver(failures int) *receiver {
+	r := &receiver{failures: failures}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		if len(r.requests) <= r.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return r
+}
+
+func (r *receiver) received() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.requests)
+}
+
+// waitForDelivery waits for the only delivery of a webhook to leave the pending
+// state
+func waitForDelivery(t *testing.T, service *Service, webhookID string) Delivery {
+	var deliveries []Delivery
+	require.Eventually(t, func() bool {
+		deliveries, _ = service.ListDeliveries(DeliveryFilter{WebhookID: webhookID})
+		return len(deliveries) == 1 && deliveries[0].Status != DeliveryPending
+	}, 2*time.Second, 5*time.Millisecond)
+	return deliveries[0]
+}
+
+func TestCreateWebhook(t *testing.T) {
+	service := NewService(NewMemoryStore(), Config{})
+
+	webhook, err := service.CreateWebhook("user1", Request{URL: "https://example.com/hooks", Events: []EventType{EventOrderFill}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, webhook.ID)
+	assert.True(t, strings.HasPrefix(webhook.Secret, "whsec_"))
+	assert.True(t, webhook.Active)
+
+	for _, request := range []Request{
+		{URL: "ftp://example.com/hooks"},
+		{URL: "/hooks"},
+		{URL: "https://example.com/hooks", Secret: "short"},
+		{URL: "https://example.com/hooks", Events: []EventType{"order.deleted"}},
+	} {
+		_, err := service.CreateWebhook("user1", request)
+		assert.Error(t, err, request)
+	}
+
+	// Secrets are kept unless changed
+	inactive := false
+	updated, err := service.UpdateWebhook(webhook.ID, Request{URL: "https://example.com/v2/hooks", Active: &inactive})
+	require.NoError(t, err)
+	assert.Equal(t, webhook.Secret, updated.Secret)
+	assert.False(t, updated.Active)
+	assert.Empty(t, updated.Events)
+
+	_, err = service.UpdateWebhook("webhook2", Request{URL: "https://example.com/hooks"})
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
+
+func TestDeliverOrderEvent(t *testing.T) {
+	endpoint := newReceiver(0)
+	defer endpoint.server.Close()
+
+	service := NewService(NewMemoryStore(), Config{Workers: 1, AllowPrivateNetworks: true})
+	require.NoError(t, service.Start())
+	defer service.Stop()
+
+	webhook, err := service.CreateWebhook("user1", Request{URL: endpoint.server.URL, Secret: "0123456789abcdef", Events: []EventType{EventOrderFill}})
+	require.NoError(t, err)
+	_, err = service.CreateWebhook("user2", Request{URL: endpoint.server.URL})
+	require.NoError(t, err)
+
+	// Only the events subscribed to of the webhook's user are delivered
+	require.NoError(t, service.PublishOrderEvent(context.Background(), messagequeue.OrderNew, messagequeue.OrderEvent{ID: "event1", UserID: "user1"}))
+	require.NoError(t, service.PublishOrderEvent(context.Background(), messagequeue.OrderFill, messagequeue.OrderEvent{
+		ID:             "event2",
+		OrderID:        "order1",
+		UserID:         "user1",
+		FilledQuantity: 50,
+	}))
+
+	delivery := waitForDelivery(t, service, webhook.ID)
+	assert.Equal(t, DeliverySucceeded, delivery.Status)
+	assert.Equal(t, "event2", delivery.EventID)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusNoContent, delivery.ResponseStatus)
+	require.Equal(t, 1, endpoint.received())
+
+	// The payload is signed with the webhook's secret
+	req, body := endpoint.requests[0], endpoint.bodies[0]
+	assert.Equal(t, "order.fill", req.Header.Get(HeaderEvent))
+	assert.Equal(t, delivery.ID, req.Header.Get(HeaderDelivery))
+	assert.Equal(t, "sha256="+Sign("0123456789abcdef", req.Header.Get(HeaderTimestamp), body), req.Header.Get(HeaderSignature))
+
+	var payload struct {
+		ID   string                  `json:"id"`
+		Type EventType               `json:"type"`
+		Data messagequeue.OrderEvent `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, "event2", payload.ID)
+	assert.Equal(t, EventOrderFill, payload.Type)
+	assert.Equal(t, "order1", payload.Data.OrderID)
+	assert.Equal(t, 50, payload.Data.FilledQuantity)
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	config := Config{Workers: 1, MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, AllowPrivateNetworks: true}
+
+	// Deliveries are retried until they succeed
+	endpoint := newReceiver(2)
+	defer endpoint.server.Close()
+
+	service := NewService(NewMemoryStore(), config)
+	require.NoError(t, service.Start())
+	defer service.Stop()
+
+	webhook, err := service.CreateWebhook("user1", Request{URL: endpoint.server.URL})
+	require.NoError(t, err)
+	service.PublishPositionClosed(context.Background(), &models.Position{ID: "position1", UserID: "user1", Status: models.PositionStatusClosed})
+
+	delivery := waitForDelivery(t, service, webhook.ID)
+	assert.Equal(t, DeliverySucceeded, delivery.Status)
+	assert.Equal(t, EventPositionClosed, delivery.EventType)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Empty(t, delivery.Error)
+
+	// and given up on after the last attempt
+	failing := newReceiver(5)
+	defer failing.server.Close()
+
+	webhook, err = service.CreateWebhook("user2", Request{URL: failing.server.URL})
+	require.NoError(t, err)
+	service.PublishPositionClosed(context.Background(), &models.Position{ID: "position2", UserID: "user2", Status: models.PositionStatusClosed})
+
+	delivery = waitForDelivery(t, service, webhook.ID)
+	assert.Equal(t, DeliveryFailed, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, delivery.ResponseStatus)
+	assert.Contains(t, delivery.Error, "503")
+	assert.Equal(t, 3, failing.received())
+}
+
+func TestForbiddenAddresses(t *testing.T) {
+	endpoint := newReceiver(0)
+	defer endpoint.server.Close()
+
+	service := NewService(NewMemoryStore(), Config{Workers: 1, MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond})
+	require.NoError(t, service.Start())
+	defer service.Stop()
+
+	// Addresses of the platform's own network are refused on registration
+	for _, hookURL := range []string{
+		endpoint.server.URL,
+		"http://[::1]/hooks",
+		"http://10.1.2.3/hooks",
+		"http://192.168.0.10/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hooks",
+		"http://[fe80::1]/hooks",
+		"http://[::ffff:127.0.0.1]/hooks",
+	} {
+		_, err := service.CreateWebhook("user1", Request{URL: hookURL})
+		assert.ErrorIs(t, err, ErrForbiddenAddress, hookURL)
+	}
+
+	// and host names resolving to them on delivery, without retrying
+	webhook, err := service.CreateWebhook("user1", Request{URL: strings.Replace(endpoint.server.URL, "127.0.0.1", "localhost", 1)})
+	require.NoError(t, err)
+	service.PublishPositionClosed(context.Background(), &models.Position{ID: "position1", UserID: "user1", Status: models.PositionStatusClosed})
+
+	delivery := waitForDelivery(t, service, webhook.ID)
+	assert.Equal(t, DeliveryFailed, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Contains(t, delivery.Error, ErrForbiddenAddress.Error())
+
+	// The dialer refuses them too, whatever a host name resolved to before
+	_, err = service.client.Post(endpoint.server.URL, "application/json", strings.NewReader("{}"))
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+	assert.Equal(t, 0, endpoint.received())
+
+	for _, ip := range []string{"93.184.216.34", "8.8.8.8", "2606:4700::1111"} {
+		assert.False(t, forbiddenIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	config := Config{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, config.backoff(1))
+	assert.Equal(t, 2*time.Second, config.backoff(2))
+	assert.Equal(t, 4*time.Second, config.backoff(3))
+	assert.Equal(t, 5*time.Second, config.backoff(4))
+	assert.Equal(t, 5*time.Second, config.backoff(10))
+}
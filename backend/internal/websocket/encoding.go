@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"encoding/json"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Encodings of event frames, negotiated with the encoding query parameter on
+// connecting. With MessagePack, the events of market data topics are sent as
+// binary frames, while other events and every other frame stay JSON text frames.
+const (
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack"
+)
+
+// msgpackHandle encodes MessagePack frames, struct fields keyed by their json tags
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+// binaryEvent is the MessagePack frame of an event
+type binaryEvent struct {
+	Type    string      `codec:"type"`
+	Topic   string      `codec:"topic"`
+	Seq     uint64      `codec:"seq,omitempty"`
+	Payload interface{} `codec:"payload"`
+}
+
+// supportedEncoding checks an encoding can be negotiated
+func supportedEncoding(encoding string) bool {
+	return encoding == EncodingJSON || encoding == EncodingMsgpack
+}
+
+// encode encodes an event frame in the client's encoding, reporting whether the
+// frame is binary
+func (c *Client) encode(message Message) ([]byte, bool, error) {
+	kind, _, _ := parseTopic(message.Topic)
+	if c.encoding != EncodingMsgpack || !marketData(kind) {
+		data, err := json.Marshal(message)
+		return data, false, err
+	}
+
+	// Payloads marshalled by the portfolio service are decoded to be re-encoded
+	payload := message.data
+	if raw, ok := payload.(json.RawMessage); ok {
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var data []byte
+	err := codec.NewEncoderBytes(&data, msgpackHandle).Encode(binaryEvent{
+		Type:    message.Type,
+		Topic:   message.Topic,
+		Seq:     message.Seq,
+		Payload: payload,
+	})
+	return data, true, err
+}
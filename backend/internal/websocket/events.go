@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// Event bus topics the handler follows, named like the routing keys of the
+// message service: the events of every order status change, and of positions
+const (
+	OrderEventsTopic    = "order.events.*"
+	PositionEventsTopic = "portfolio.events.position"
+)
+
+// recentEventsKept is how many event IDs are kept to drop events the bus
+// delivers again
+const recentEventsKept = 4096
+
+// EventSubscriber subscribes to topics of the event bus, as a
+// messagequeue.MessageBroker does
+type EventSubscriber interface {
+	Subscribe(ctx context.Context, topic string, handler func([]byte) error) error
+}
+
+// busMessage is a message of the event bus, its payload kept to be pushed as is
+type busMessage struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// routedEvent is the part of an order or position event the handler routes it by
+type routedEvent struct {
+	ID          string `json:"id"`
+	UserID      string `json:"userId"`
+	PortfolioID string `json:"portfolioId"`
+}
+
+// FollowEvents pushes the order and position events of the event bus to the
+// subscribers of their topics until ctx is done, so that changes made by
+// background workers and reconciliation jobs, not only by requests to this
+// process, reach clients as they happen. Every replica follows the bus for its
+// own clients, the events not being relayed.
+func (h *Handler) FollowEvents(ctx context.Context, bus EventSubscriber) error {
+	if err := bus.Subscribe(ctx, OrderEventsTopic, h.handleOrderEvent); err != nil {
+		return fmt.Errorf("failed to follow order events: %w", err)
+	}
+	if err := bus.Subscribe(ctx, PositionEventsTopic, h.handlePositionEvent); err != nil {
+		return fmt.Errorf("failed to follow position events: %w", err)
+	}
+	return nil
+}
+
+// handleOrderEvent pushes an order event to its user's orders topic
+func (h *Handler) handleOrderEvent(data []byte) error {
+	payload, event, err := decodeBusEvent(data)
+	if err != nil {
+		return err
+	}
+	if event.UserID == "" {
+		return fmt.Errorf("order event %s has no user", event.ID)
+	}
+	if !h.firstDelivery(event.ID) {
+		return nil
+	}
+	return h.publishLocal(TopicOrders+":"+event.UserID, payload)
+}
+
+// handlePositionEvent pushes a position event to its portfolio's positions topic
+func (h *Handler) handlePositionEvent(data []byte) error {
+	payload, event, err := decodeBusEvent(data)
+	if err != nil {
+		return err
+	}
+	if event.PortfolioID == "" {
+		return fmt.Errorf("position event %s has no portfolio", event.ID)
+	}
+	if !h.firstDelivery(event.ID) {
+		return nil
+	}
+	return h.publishLocal(TopicPositions+":"+event.PortfolioID, payload)
+}
+
+// decodeBusEvent decodes a message of the event bus, returning its payload and
+// what it is routed by
+func decodeBusEvent(data []byte) (json.RawMessage, routedEvent, error) {
+	var message busMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, routedEvent{}, fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	var event routedEvent
+	if err := json.Unmarshal(message.Payload, &event); err != nil {
+		return nil, routedEvent{}, fmt.Errorf("failed to decode %s event: %w", message.Type, err)
+	}
+	return message.Payload, event, nil
+}
+
+// firstDelivery checks an event was not pushed already, the bus delivering
+// events at least once. Events without an ID are always pushed.
+func (h *Handler) firstDelivery(eventID string) bool {
+	if eventID == "" {
+		return true
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.recentEvents[eventID] {
+		log.Printf("Dropped event %s delivered again", eventID)
+		return false
+	}
+
+	if len(h.recentEventIDs) >= recentEventsKept {
+		delete(h.recentEvents, h.recentEventIDs[0])
+		h.recentEventIDs = h.recentEventIDs[1:]
+	}
+	h.recentEvents[eventID] = true
+	h.recentEventIDs = append(h.recentEventIDs, eventID)
+	return true
+}
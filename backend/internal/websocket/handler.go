@@ -1,47 +1,142 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"trading-platform/backend/internal/portfolioanalytics"
+	"github.com/prometheus/client_golang/prometheus"
+	"trading-platform/backend/internal/auth"
 	"trading-platform/backend/internal/orderexecution"
+	"trading-platform/backend/internal/portfolioanalytics"
+)
+
+// Message types of the topic protocol. Clients not authenticated on connecting
+// send an auth request first. Once authenticated, clients get a session frame
+// with the resume token of their session, then send subscribe, unsubscribe and
+// resume requests, each answered by an ack or an error frame carrying the
+// request's ID, and receive an event frame, numbered in their session, for every
+// update of the topics they subscribed to. Heartbeat frames are sent to them
+// every heartbeat interval, and their ping requests answered by pong frames.
+const (
+	MessageAuth        = "auth"
+	MessageSession     = "session"
+	MessageSubscribe   = "subscribe"
+	MessageUnsubscribe = "unsubscribe"
+	MessageResume      = "resume"
+	MessagePing        = "ping"
+	MessageAck         = "ack"
+	MessageError       = "error"
+	MessageEvent       = "event"
+	MessageHeartbeat   = "heartbeat"
+	MessagePong        = "pong"
+)
+
+// Error codes of error frames
+const (
+	ErrorInvalidMessage     = "INVALID_MESSAGE"     // Not JSON, or of no known type
+	ErrorUnauthenticated    = "UNAUTHENTICATED"     // No valid token, the connection being closed
+	ErrorInvalidTopic       = "INVALID_TOPIC"       // Of no known kind, or without an ID
+	ErrorForbidden          = "FORBIDDEN"           // The user may not follow the topic
+	ErrorNotSubscribed      = "NOT_SUBSCRIBED"      // Unsubscribing from a topic not subscribed to
+	ErrorSubscriptionFailed = "SUBSCRIPTION_FAILED" // The updates of the topic could not be followed
+	ErrorResumeFailed       = "RESUME_FAILED"       // The session is unknown, expired, or no longer has the events missed
+	ErrorRateLimited        = "RATE_LIMITED"        // Too many messages of the connection or its user, the message being discarded
 )
 
-// Handler handles WebSocket connections
+// authTimeout is how long a connection not authenticated on connecting has to
+// send its auth request
+const authTimeout = 10 * time.Second
+
+// PermissionChecker checks users' permissions, as the API gateway does
+type PermissionChecker interface {
+	Authorize(userID, userType, permission string) error
+}
+
+// PortfolioUpdates is the part of the portfolio analytics service the handler
+// uses, to authorize portfolio topics and follow portfolio metrics and greeks
+type PortfolioUpdates interface {
+	GetPortfolio(ctx context.Context, portfolioID string) (*portfolioanalytics.Portfolio, error)
+	SubscribeToUpdates(portfolioID string, callback func(interface{})) (string, error)
+	UnsubscribeFromUpdates(subscriptionID string) error
+	SubscribeToGreeks(scope, id string, callback func(interface{})) (string, error)
+	UnsubscribeFromGreeks(subscriptionID string) error
+}
+
+// Handler handles WebSocket connections, fanning the updates of topics out to the
+// clients subscribed to them
 type Handler struct {
-	portfolioService portfolioanalytics.Service
+	portfolioService PortfolioUpdates
 	orderService     orderexecution.Service
+	permissions      PermissionChecker
 	clients          map[*Client]bool
-	register         chan *Client
-	unregister       chan *Client
-	broadcast        chan []byte
+	topics           map[string]map[*Client]bool
+	sessions         map[string]*session // By resume token
+	heartbeat        HeartbeatConfig
+	rateLimits       RateLimitConfig
+	userMessages     map[string][]time.Time // Times of the last messages of each user, oldest first
+	relay            Relay
+	watched          map[string]*relayWatch // Topics of which relayed events are pushed
+	recentEvents     map[string]bool        // IDs of the last bus events pushed
+	recentEventIDs   []string               // Oldest first
+	stats            HubStats
+	fanOutLatency    prometheus.Summary
 	mutex            sync.Mutex
 }
 
+// HubStats reports how the handler keeps up fanning events out to its clients
+type HubStats struct {
+	Connections        int   `json:"connections"`
+	QueuedFrames       int   `json:"queuedFrames"`       // Waiting in the send queues of all clients
+	MaxQueueLength     int   `json:"maxQueueLength"`     // Of the most backed up client
+	OverflowingClients int   `json:"overflowingClients"` // Past the send queue limit
+	FramesQueued       int64 `json:"framesQueued"`
+	DroppedFrames      int64 `json:"droppedFrames"`   // Market data dropped for slow clients
+	SlowDisconnects    int64 `json:"slowDisconnects"` // Clients disconnected on overflowing for too long
+	IdleDisconnects    int64 `json:"idleDisconnects"`
+	DeadConnections    int64 `json:"deadConnections"` // Not answering pings in time
+	RateLimited        int64 `json:"rateLimited"`     // Messages discarded for exceeding rate limits
+}
+
 // Client represents a WebSocket client
 type Client struct {
-	conn       *websocket.Conn
-	handler    *Handler
-	send       chan []byte
-	userID     string
-	subscriptions map[string]string // Map of subscription type to ID
+	conn     *websocket.Conn
+	handler  *Handler
+	queue    *sendQueue
+	userID   string // Empty until the connection is authenticated
+	role     string
+	userType string
+	topics   map[string]string // Subscribed topics, to the ID of the portfolio service subscription backing them if any
+	session  *session
+	encoding string // Of market data events
+
+	heartbeat  HeartbeatConfig
+	lastActive time.Time   // Of the last request
+	messages   []time.Time // Times of the last messages, oldest first
 }
 
 // Message represents a WebSocket message
 type Message struct {
-	Type    string          `json:"type"`
-	Payload json.RawMessage `json:"payload"`
-}
+	Type        string          `json:"type"`
+	ID          string          `json:"id,omitempty"` // Of a request, echoed by its ack or error frame
+	Topic       string          `json:"topic,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Seq         uint64          `json:"seq,omitempty"`   // Of an event, or of the last event received for a resume request
+	Token       string          `json:"token,omitempty"` // Of an auth request
+	Code        string          `json:"code,omitempty"`  // Of an error frame
+	Error       string          `json:"error,omitempty"`
+	ResumeToken string          `json:"resumeToken,omitempty"` // Of a session frame, or of a resume request and its ack
 
-// Subscription represents a subscription request
-type Subscription struct {
-	Type string `json:"type"`
-	ID   string `json:"id"`
+	HeartbeatInterval int64 `json:"heartbeatInterval,omitempty"` // Of a session frame, in milliseconds
+	RetryAfter        int64 `json:"retryAfter,omitempty"`        // Of a rate limited error frame, in milliseconds
+
+	data interface{} // Of an event, as published, for binary encodings
 }
 
 // Upgrader upgrades HTTP connections to WebSocket connections
@@ -55,19 +150,57 @@ var upgrader = websocket.Upgrader{
 }
 
 // NewHandler creates a new WebSocket handler
-func NewHandler(portfolioService portfolioanalytics.Service, orderService orderexecution.Service) *Handler {
+func NewHandler(portfolioService PortfolioUpdates, orderService orderexecution.Service) *Handler {
 	return &Handler{
 		portfolioService: portfolioService,
 		orderService:     orderService,
 		clients:          make(map[*Client]bool),
-		register:         make(chan *Client),
-		unregister:       make(chan *Client),
-		broadcast:        make(chan []byte),
+		topics:           make(map[string]map[*Client]bool),
+		sessions:         make(map[string]*session),
+		heartbeat:        DefaultHeartbeatConfig(),
+		rateLimits:       DefaultRateLimitConfig(),
+		userMessages:     make(map[string][]time.Time),
+		watched:          make(map[string]*relayWatch),
+		recentEvents:     make(map[string]bool),
+		fanOutLatency:    newFanOutLatency(),
 	}
 }
 
-// HandleConnection handles a WebSocket connection
+// SetPermissions makes the handler check every subscription against a permission
+// model, each topic kind needing the stream:{kind}:subscribe permission
+func (h *Handler) SetPermissions(permissions PermissionChecker) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.permissions = permissions
+}
+
+// HandleConnection handles a WebSocket connection. The connection is bound to the
+// user set in the request context by the auth middleware, or to the user of the
+// JWT given as the token query parameter, as browsers cannot set headers on
+// WebSocket handshakes, or else to the user of the JWT of its first frame, an auth
+// request. Market data events are encoded as the encoding query parameter asks,
+// JSON by default.
 func (h *Handler) HandleConnection(w http.ResponseWriter, r *http.Request) {
+	encoding := r.URL.Query().Get("encoding")
+	if encoding == "" {
+		encoding = EncodingJSON
+	}
+	if !supportedEncoding(encoding) {
+		http.Error(w, "Unsupported encoding "+encoding, http.StatusBadRequest)
+		return
+	}
+
+	userID := auth.GetUserIDFromContext(r.Context())
+	role, userType := auth.GetRoleFromContext(r.Context()), auth.GetUserTypeFromContext(r.Context())
+	if token := r.URL.Query().Get("token"); userID == "" && token != "" {
+		claims, err := auth.ValidateToken(token)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userID, role, userType = claims.UserID, claims.Role, claims.UserType
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -75,119 +208,280 @@ func (h *Handler) HandleConnection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user ID from request (in a real implementation, this would come from the JWT token)
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		userID = "anonymous"
-	}
-
-	// Create new client
+	// Create and register new client
 	client := &Client{
-		conn:         conn,
-		handler:      h,
-		send:         make(chan []byte, 256),
-		userID:       userID,
-		subscriptions: make(map[string]string),
-	}
+		conn:     conn,
+		handler:  h,
+		queue:    newSendQueue(),
+		userID:   userID,
+		role:     role,
+		userType: userType,
+		topics:   make(map[string]string),
+		encoding: encoding,
 
-	// Register client
-	h.register <- client
+		lastActive: time.Now(),
+	}
+	h.mutex.Lock()
+	client.heartbeat = h.heartbeat
+	h.clients[client] = true
+	if userID != "" {
+		h.startSession(client)
+	}
+	h.mutex.Unlock()
 
 	// Start client goroutines
 	go client.readPump()
 	go client.writePump()
 }
 
-// Run starts the WebSocket handler
-func (h *Handler) Run() {
-	for {
-		select {
-		case client := <-h.register:
-			h.mutex.Lock()
-			h.clients[client] = true
-			h.mutex.Unlock()
-		case client := <-h.unregister:
-			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				// Unsubscribe from all subscriptions
-				for subType, subID := range client.subscriptions {
-					switch subType {
-					case "portfolio":
-						h.portfolioService.UnsubscribeFromUpdates(subID)
-					}
-				}
-			}
-			h.mutex.Unlock()
-		case message := <-h.broadcast:
-			h.mutex.Lock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
-			}
-			h.mutex.Unlock()
+// Publish sends an event of a topic to the clients subscribed to it, on every
+// replica when the handler has a relay
+func (h *Handler) Publish(topic string, data interface{}) error {
+	if relayed, err := h.relayEvent(topic, data); relayed {
+		return err
+	}
+	return h.publishLocal(topic, data)
+}
+
+// publishLocal sends an event of a topic to the clients of this replica
+// subscribed to it, buffering it for the disconnected clients that may resume
+// their sessions
+func (h *Handler) publishLocal(topic string, data interface{}) error {
+	start := time.Now()
+	message, err := event(topic, data)
+	if err != nil {
+		return err
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for client := range h.topics[topic] {
+		h.sendEvent(client, message)
+	}
+	h.bufferDetached(message)
+	if len(h.topics[topic]) > 0 {
+		h.fanOutLatency.Observe(time.Since(start).Seconds())
+	}
+
+	return nil
+}
+
+// publishTo sends an event of a topic to one client, as long as it is subscribed
+func (h *Handler) publishTo(client *Client, topic string, data interface{}) error {
+	message, err := event(topic, data)
+	if err != nil {
+		return err
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.topics[topic][client] {
+		h.sendEvent(client, message)
+	}
+
+	return nil
+}
+
+// PublishOrder sends an update of an order of a user to the subscribers of the
+// user's orders topic
+func (h *Handler) PublishOrder(userID string, order interface{}) error {
+	return h.Publish(TopicOrders+":"+userID, order)
+}
+
+// PublishPosition sends an update of a position in a portfolio to the subscribers
+// of the portfolio's positions topic
+func (h *Handler) PublishPosition(portfolioID string, position interface{}) error {
+	return h.Publish(TopicPositions+":"+portfolioID, position)
+}
+
+// PublishTick sends a tick of an instrument to the subscribers of its ticks topic
+func (h *Handler) PublishTick(symbol string, tick interface{}) error {
+	return h.Publish(TopicTicks+":"+symbol, tick)
+}
+
+// PublishDepth sends the market depth of an instrument to the subscribers of its
+// depth topic
+func (h *Handler) PublishDepth(symbol string, depth interface{}) error {
+	return h.Publish(TopicDepth+":"+symbol, depth)
+}
+
+// GetSubscriberCount returns the number of clients subscribed to a topic
+func (h *Handler) GetSubscriberCount(topic string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.topics[topic])
+}
+
+// Stats returns how the handler keeps up fanning events out to its clients
+func (h *Handler) Stats() HubStats {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	stats := h.stats
+	stats.Connections = len(h.clients)
+	for client := range h.clients {
+		length := client.queue.length()
+		stats.QueuedFrames += length
+		if length > stats.MaxQueueLength {
+			stats.MaxQueueLength = length
+		}
+		if length > sendQueueLimit {
+			stats.OverflowingClients++
+		}
+	}
+	return stats
+}
+
+// GetStats handles retrieving the handler's stats
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Stats()); err != nil {
+		log.Printf("Failed to encode WebSocket stats: %v", err)
+	}
+}
+
+// deliver queues a frame for a registered client without ever blocking, market
+// data being droppable for a slow client to keep up, and disconnects the client
+// when it overflows its send queue for too long. The handler's mutex must be held.
+func (h *Handler) deliver(client *Client, frame queuedFrame) {
+	if !h.clients[client] {
+		return
+	}
+
+	dropped, overflowed := client.queue.push(frame, time.Now())
+	h.stats.FramesQueued++
+	if dropped {
+		h.stats.DroppedFrames++
+	}
+	if overflowed {
+		log.Printf("Disconnecting user %s, send queue overflowing", client.userID)
+		h.stats.SlowDisconnects++
+		client.conn.Close()
+	}
+}
+
+// unregister removes a client and its subscriptions
+func (h *Handler) unregister(client *Client) {
+	h.mutex.Lock()
+	if !h.clients[client] {
+		h.mutex.Unlock()
+		return
+	}
+	delete(h.clients, client)
+	h.detach(client)
+	h.forgetMessages(client.userID)
+	for topic := range client.topics {
+		delete(h.topics[topic], client)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+			h.unwatch(topic)
+		}
+	}
+	client.queue.close()
+	h.mutex.Unlock()
+
+	// Unsubscribe from the portfolio service subscriptions backing its topics
+	for topic, subscriptionID := range client.topics {
+		if subscriptionID == "" {
+			continue
+		}
+		if err := client.unfollow(topic, subscriptionID); err != nil {
+			log.Printf("Failed to unsubscribe from %s: %v", topic, err)
 		}
 	}
 }
 
 // readPump pumps messages from the WebSocket connection to the handler
 func (c *Client) readPump() {
-	defer func() {
-		c.handler.unregister <- c
-		c.conn.Close()
-	}()
+	// Unregistering closes the send queue, the write pump then flushing the
+	// frames left, such as why an auth request failed, and closing the connection
+	defer c.handler.unregister(c)
 
 	c.conn.SetReadLimit(512 * 1024) // 512KB
-	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	authenticated := c.user() != ""
+	if authenticated {
+		c.extendDeadline()
+	} else {
+		c.conn.SetReadDeadline(time.Now().Add(authTimeout))
+	}
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		if c.user() != "" {
+			c.extendDeadline()
+		}
 		return nil
 	})
 
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			var netErr net.Error
+			switch {
+			case !authenticated:
+				c.reply(Message{Type: MessageError, Code: ErrorUnauthenticated, Error: "no auth request received"})
+			case errors.As(err, &netErr) && netErr.Timeout():
+				// Half-open connections stop answering pings
+				log.Printf("Closing dead connection of user %s", c.userID)
+				c.handler.mutex.Lock()
+				c.handler.stats.DeadConnections++
+				c.handler.mutex.Unlock()
+			case websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure):
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
+		if authenticated {
+			c.extendDeadline()
+		}
 
 		// Parse message
 		var msg Message
-		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("Failed to parse message: %v", err)
+		parseErr := json.Unmarshal(message, &msg)
+		if authenticated {
+			// Every message counts against the rate limits, invalid ones too
+			if retryAfter, ok := c.allow(time.Now()); !ok {
+				c.reply(Message{Type: MessageError, ID: msg.ID, Code: ErrorRateLimited, Error: "rate limit exceeded", RetryAfter: retryAfter.Milliseconds()})
+				continue
+			}
+			if parseErr != nil {
+				c.reply(Message{Type: MessageError, Code: ErrorInvalidMessage, Error: "message is not valid JSON"})
+				continue
+			}
+		}
+
+		// The first frame of a connection not authenticated yet must authenticate it
+		if !authenticated {
+			if !c.authenticate(msg) {
+				break
+			}
+			authenticated = true
+			c.extendDeadline()
 			continue
 		}
+		if msg.Type != MessagePing {
+			c.touch()
+		}
 
 		// Handle message based on type
 		switch msg.Type {
-		case "subscribe":
-			var sub Subscription
-			if err := json.Unmarshal(msg.Payload, &sub); err != nil {
-				log.Printf("Failed to parse subscription: %v", err)
-				continue
-			}
-			c.handleSubscription(sub)
-		case "unsubscribe":
-			var sub Subscription
-			if err := json.Unmarshal(msg.Payload, &sub); err != nil {
-				log.Printf("Failed to parse subscription: %v", err)
-				continue
-			}
-			c.handleUnsubscription(sub)
+		case MessagePing:
+			c.reply(Message{Type: MessagePong, ID: msg.ID})
+		case MessageAuth:
+			c.fail(msg, ErrorInvalidMessage, "connection is already authenticated")
+		case MessageSubscribe:
+			c.handleSubscription(msg)
+		case MessageUnsubscribe:
+			c.handleUnsubscription(msg)
+		case MessageResume:
+			c.handleResume(msg)
+		default:
+			c.reply(Message{Type: MessageError, ID: msg.ID, Code: ErrorInvalidMessage, Error: "unknown message type " + msg.Type})
 		}
 	}
 }
 
 // writePump pumps messages from the handler to the WebSocket connection
 func (c *Client) writePump() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(c.heartbeat.PingInterval)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -195,32 +489,35 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				// The handler closed the channel
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
+		case <-c.queue.ready:
+			for {
+				frame, ok, closed := c.queue.pop()
+				c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				if closed {
+					// The handler closed the queue
+					c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+					return
+				}
+				if !ok {
+					break
+				}
 
-			// Add queued messages to the current WebSocket message
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+				// One frame per message, for clients to parse each on its own
+				messageType := websocket.TextMessage
+				if frame.binary {
+					messageType = websocket.BinaryMessage
+				}
+				if err := c.conn.WriteMessage(messageType, frame.data); err != nil {
+					return
+				}
 			}
-
-			if err := w.Close(); err != nil {
+		case now := <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if !c.beat(now) {
+				log.Printf("Closing idle connection of user %s", c.user())
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "idle timeout"))
 				return
 			}
-		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -228,46 +525,182 @@ func (c *Client) writePump() {
 	}
 }
 
-// handleSubscription handles a subscription request
-func (c *Client) handleSubscription(sub Subscription) {
-	switch sub.Type {
-	case "portfolio":
-		// Subscribe to portfolio updates
-		subID, err := c.handler.portfolioService.SubscribeToUpdates(sub.ID, func(data interface{}) {
-			// Send update to client
-			message, err := json.Marshal(Message{
-				Type:    "portfolio_update",
-				Payload: data.(json.RawMessage),
-			})
-			if err != nil {
-				log.Printf("Failed to marshal portfolio update: %v", err)
-				return
-			}
-			c.send <- message
-		})
-		if err != nil {
-			log.Printf("Failed to subscribe to portfolio updates: %v", err)
-			return
-		}
-		c.subscriptions[sub.Type] = subID
+// authenticate binds the connection to the user of the JWT of an auth request,
+// answering it with an ack, or with an error frame when it is not a valid auth
+// request, the connection then being closed
+func (c *Client) authenticate(request Message) bool {
+	if request.Type != MessageAuth {
+		c.fail(request, ErrorUnauthenticated, "the first message must be an auth request")
+		return false
 	}
+
+	claims, err := auth.ValidateToken(request.Token)
+	if err != nil || claims.UserID == "" {
+		c.fail(request, ErrorUnauthenticated, "invalid token")
+		return false
+	}
+
+	c.handler.mutex.Lock()
+	defer c.handler.mutex.Unlock()
+	c.userID, c.role, c.userType = claims.UserID, claims.Role, claims.UserType
+	c.handler.respond(c, Message{Type: MessageAck, ID: request.ID})
+	c.handler.startSession(c)
+	return true
 }
 
-// handleUnsubscription handles an unsubscription request
-func (c *Client) handleUnsubscription(sub Subscription) {
-	subID, ok := c.subscriptions[sub.Type]
-	if !ok {
+// user returns the user the connection is bound to, empty until it is authenticated
+func (c *Client) user() string {
+	c.handler.mutex.Lock()
+	defer c.handler.mutex.Unlock()
+	return c.userID
+}
+
+// reply sends an ack or error frame to the client
+func (c *Client) reply(message Message) {
+	c.handler.mutex.Lock()
+	defer c.handler.mutex.Unlock()
+	c.handler.respond(c, message)
+}
+
+// respond queues a frame other than an event for a client. The handler's mutex
+// must be held.
+func (h *Handler) respond(client *Client, message Message) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal %s frame: %v", message.Type, err)
 		return
 	}
+	h.deliver(client, queuedFrame{data: data})
+}
 
-	switch sub.Type {
-	case "portfolio":
-		// Unsubscribe from portfolio updates
-		err := c.handler.portfolioService.UnsubscribeFromUpdates(subID)
-		if err != nil {
-			log.Printf("Failed to unsubscribe from portfolio updates: %v", err)
-			return
+// fail sends an error frame answering a request
+func (c *Client) fail(request Message, code, reason string) {
+	c.reply(Message{Type: MessageError, ID: request.ID, Topic: request.Topic, Code: code, Error: reason})
+}
+
+// handleSubscription handles a subscription request, acked once the client
+// receives the topic's events. Subscribing again to a topic is acked as well.
+func (c *Client) handleSubscription(request Message) {
+	kind, id, err := parseTopic(request.Topic)
+	if err != nil {
+		c.fail(request, ErrorInvalidTopic, err.Error())
+		return
+	}
+
+	c.handler.mutex.Lock()
+	_, subscribed := c.topics[request.Topic]
+	c.handler.mutex.Unlock()
+	if subscribed {
+		c.reply(Message{Type: MessageAck, ID: request.ID, Topic: request.Topic})
+		return
+	}
+
+	if !c.authorize(kind, id) {
+		log.Printf("User %s may not subscribe to %s", c.userID, request.Topic)
+		c.fail(request, ErrorForbidden, "access denied")
+		return
+	}
+
+	// Relayed events are followed from other replicas, and portfolio metrics and
+	// greeks through the portfolio service, outside the handler's mutex its
+	// callbacks take
+	if err := c.handler.watch(request.Topic); err != nil {
+		log.Printf("Failed to follow relayed events of %s: %v", request.Topic, err)
+		c.fail(request, ErrorSubscriptionFailed, err.Error())
+		return
+	}
+	subscriptionID, err := c.follow(request.Topic, kind, id)
+	if err != nil {
+		log.Printf("Failed to subscribe user %s to %s: %v", c.userID, request.Topic, err)
+		c.fail(request, ErrorSubscriptionFailed, err.Error())
+		return
+	}
+
+	c.handler.mutex.Lock()
+	if _, exists := c.handler.topics[request.Topic]; !exists {
+		c.handler.topics[request.Topic] = make(map[*Client]bool)
+	}
+	c.handler.topics[request.Topic][c] = true
+	c.topics[request.Topic] = subscriptionID
+	c.handler.mutex.Unlock()
+
+	c.reply(Message{Type: MessageAck, ID: request.ID, Topic: request.Topic})
+}
+
+// handleUnsubscription handles an unsubscription request, acked once the client
+// no longer receives the topic's events
+func (c *Client) handleUnsubscription(request Message) {
+	c.handler.mutex.Lock()
+	subscriptionID, subscribed := c.topics[request.Topic]
+	if subscribed {
+		delete(c.topics, request.Topic)
+		delete(c.handler.topics[request.Topic], c)
+		if len(c.handler.topics[request.Topic]) == 0 {
+			delete(c.handler.topics, request.Topic)
+			c.handler.unwatch(request.Topic)
 		}
-		delete(c.subscriptions, sub.Type)
 	}
+	c.handler.mutex.Unlock()
+	if !subscribed {
+		c.fail(request, ErrorNotSubscribed, "not subscribed to "+request.Topic)
+		return
+	}
+
+	if subscriptionID != "" {
+		if err := c.unfollow(request.Topic, subscriptionID); err != nil {
+			log.Printf("Failed to unsubscribe user %s from %s: %v", c.userID, request.Topic, err)
+		}
+	}
+
+	c.reply(Message{Type: MessageAck, ID: request.ID, Topic: request.Topic})
+}
+
+// follow subscribes to the portfolio service updates backing a topic, returning
+// the subscription ID, or an empty ID for topics published through the handler
+func (c *Client) follow(topic, kind, id string) (string, error) {
+	// Send updates to the client as events of the topic
+	callback := func(data interface{}) {
+		if err := c.handler.publishTo(c, topic, data); err != nil {
+			log.Printf("Failed to marshal %s update: %v", topic, err)
+		}
+	}
+
+	switch kind {
+	case TopicPortfolio:
+		return c.handler.portfolioService.SubscribeToUpdates(id, callback)
+	case TopicGreeks:
+		return c.handler.portfolioService.SubscribeToGreeks(portfolioanalytics.GreeksScopePortfolio, id, callback)
+	case TopicAccountGreeks:
+		return c.handler.portfolioService.SubscribeToGreeks(portfolioanalytics.GreeksScopeAccount, id, callback)
+	}
+
+	return "", nil
+}
+
+// unfollow unsubscribes from the portfolio service updates backing a topic
+func (c *Client) unfollow(topic, subscriptionID string) error {
+	kind, _, _ := parseTopic(topic)
+	if kind == TopicPortfolio {
+		return c.handler.portfolioService.UnsubscribeFromUpdates(subscriptionID)
+	}
+	return c.handler.portfolioService.UnsubscribeFromGreeks(subscriptionID)
+}
+
+// event builds an event frame of a topic, data already marshalled by the
+// portfolio service being used as is
+func event(topic string, data interface{}) (Message, error) {
+	payload, ok := data.(json.RawMessage)
+	if !ok {
+		var err error
+		if payload, err = json.Marshal(data); err != nil {
+			return Message{}, err
+		}
+	}
+
+	return Message{
+		Type:    MessageEvent,
+		Topic:   topic,
+		Payload: payload,
+		data:    data,
+	}, nil
 }
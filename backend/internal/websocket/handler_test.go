@@ -0,0 +1,763 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ugorji/go/codec"
+	"trading-platform/backend/internal/auth"
+	"trading-platform/backend/internal/portfolioanalytics"
+)
+
+// MockPortfolioUpdates is a mock implementation of the PortfolioUpdates interface
+type MockPortfolioUpdates struct {
+	mock.Mock
+}
+
+func (m *MockPortfolioUpdates) GetPortfolio(ctx context.Context, portfolioID string) (*portfolioanalytics.Portfolio, error) {
+	args := m.Called(portfolioID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*portfolioanalytics.Portfolio), args.Error(1)
+}
+
+func (m *MockPortfolioUpdates) SubscribeToUpdates(portfolioID string, callback func(interface{})) (string, error) {
+	args := m.Called(portfolioID, callback)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPortfolioUpdates) UnsubscribeFromUpdates(subscriptionID string) error {
+	args := m.Called(subscriptionID)
+	return args.Error(0)
+}
+
+func (m *MockPortfolioUpdates) SubscribeToGreeks(scope, id string, callback func(interface{})) (string, error) {
+	args := m.Called(scope, id, callback)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPortfolioUpdates) UnsubscribeFromGreeks(subscriptionID string) error {
+	args := m.Called(subscriptionID)
+	return args.Error(0)
+}
+
+// MockPermissionChecker is a mock implementation of the PermissionChecker interface
+type MockPermissionChecker struct {
+	mock.Mock
+}
+
+func (m *MockPermissionChecker) Authorize(userID, userType, permission string) error {
+	args := m.Called(userID, userType, permission)
+	return args.Error(0)
+}
+
+// MockEventSubscriber is a mock implementation of the EventSubscriber interface
+type MockEventSubscriber struct {
+	mock.Mock
+}
+
+func (m *MockEventSubscriber) Subscribe(ctx context.Context, topic string, handler func([]byte) error) error {
+	args := m.Called(topic, handler)
+	return args.Error(0)
+}
+
+// fakeRelay relays events between handlers in memory, as Redis pub/sub would
+// between replicas
+type fakeRelay struct {
+	mutex         sync.Mutex
+	subscriptions map[string][]fakeSubscription
+}
+
+type fakeSubscription struct {
+	ctx     context.Context
+	handler func([]byte) error
+}
+
+func (r *fakeRelay) Publish(ctx context.Context, topic string, message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	var handlers []func([]byte) error
+	for _, subscription := range r.subscriptions[topic] {
+		if subscription.ctx.Err() == nil {
+			handlers = append(handlers, subscription.handler)
+		}
+	}
+	r.mutex.Unlock()
+
+	for _, handler := range handlers {
+		if err := handler(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeRelay) Subscribe(ctx context.Context, topic string, handler func([]byte) error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.subscriptions[topic] = append(r.subscriptions[topic], fakeSubscription{ctx: ctx, handler: handler})
+	return nil
+}
+
+// watching returns the number of live subscriptions to a relay channel
+func (r *fakeRelay) watching(topic string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	count := 0
+	for _, subscription := range r.subscriptions[topic] {
+		if subscription.ctx.Err() == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// connect opens a WebSocket connection to a handler as a user, reading the
+// session frame, or as no user when the user ID is empty
+func connect(t *testing.T, handler *Handler, userID, role string) *websocket.Conn {
+	conn, _ := connectSession(t, handler, userID, role)
+	return conn
+}
+
+// connectSession opens a WebSocket connection to a handler as a user, or as no
+// user when the user ID is empty, returning the resume token of its session
+func connectSession(t *testing.T, handler *Handler, userID, role string) (*websocket.Conn, string) {
+	return connectQuery(t, handler, userID, role, "")
+}
+
+// connectQuery opens a WebSocket connection with a query string to a handler as
+// a user, or as no user when the user ID is empty, returning the resume token of
+// its session
+func connectQuery(t *testing.T, handler *Handler, userID, role, query string) (*websocket.Conn, string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := auth.SetRoleInContext(auth.SetUserIDInContext(r.Context(), userID), role)
+		handler.HandleConnection(w, r.WithContext(ctx))
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+query, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if userID == "" {
+		return conn, ""
+	}
+
+	frame := readFrame(t, conn)
+	if frame.Type != MessageSession || frame.ResumeToken == "" {
+		t.Fatalf("Expected a session frame, got %+v", frame)
+	}
+	return conn, frame.ResumeToken
+}
+
+// request sends a request and returns the frame that answers it
+func request(t *testing.T, conn *websocket.Conn, message Message) Message {
+	if err := conn.WriteJSON(message); err != nil {
+		t.Fatalf("Failed to send %s: %v", message.Type, err)
+	}
+	return readFrame(t, conn)
+}
+
+// readFrame reads the next frame of a connection
+func readFrame(t *testing.T, conn *websocket.Conn) Message {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var frame Message
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	return frame
+}
+
+func TestTopicSubscriptions(t *testing.T) {
+	// Create handler with mock portfolio service
+	mockPortfolios := new(MockPortfolioUpdates)
+	handler := NewHandler(mockPortfolios, nil)
+	conn := connect(t, handler, "user123", "TRADER")
+
+	mockPortfolios.On("GetPortfolio", "portfolio1").Return(&portfolioanalytics.Portfolio{ID: "portfolio1", UserID: "user123"}, nil)
+	mockPortfolios.On("GetPortfolio", "portfolio2").Return(&portfolioanalytics.Portfolio{ID: "portfolio2", UserID: "user456"}, nil)
+
+	// Subscriptions to topics the user may follow are acked
+	for i, topic := range []string{"orders:user123", "positions:portfolio1", "ticks:NIFTY"} {
+		frame := request(t, conn, Message{Type: MessageSubscribe, ID: strconv.Itoa(i + 1), Topic: topic})
+		assert.Equal(t, MessageAck, frame.Type)
+		assert.Equal(t, strconv.Itoa(i+1), frame.ID)
+		assert.Equal(t, topic, frame.Topic)
+	}
+	assert.Equal(t, 1, handler.GetSubscriberCount("orders:user123"))
+
+	// Others' orders and portfolios are forbidden
+	frame := request(t, conn, Message{Type: MessageSubscribe, ID: "4", Topic: "orders:user456"})
+	assert.Equal(t, MessageError, frame.Type)
+	assert.Equal(t, "4", frame.ID)
+	assert.Equal(t, ErrorForbidden, frame.Code)
+
+	frame = request(t, conn, Message{Type: MessageSubscribe, ID: "5", Topic: "positions:portfolio2"})
+	assert.Equal(t, ErrorForbidden, frame.Code)
+
+	// And topics of no known kind, or without an ID, invalid
+	frame = request(t, conn, Message{Type: MessageSubscribe, ID: "6", Topic: "trades:NIFTY"})
+	assert.Equal(t, ErrorInvalidTopic, frame.Code)
+
+	frame = request(t, conn, Message{Type: MessageSubscribe, ID: "7", Topic: "orders"})
+	assert.Equal(t, ErrorInvalidTopic, frame.Code)
+
+	// Events of the topics subscribed to are pushed, others' are not
+	assert.NoError(t, handler.PublishOrder("user456", map[string]string{"orderId": "order2"}))
+	assert.NoError(t, handler.PublishOrder("user123", map[string]string{"orderId": "order1"}))
+	frame = readFrame(t, conn)
+	assert.Equal(t, MessageEvent, frame.Type)
+	assert.Equal(t, "orders:user123", frame.Topic)
+	assert.JSONEq(t, `{"orderId": "order1"}`, string(frame.Payload))
+
+	assert.NoError(t, handler.PublishTick("NIFTY", map[string]float64{"lastPrice": 22000}))
+	frame = readFrame(t, conn)
+	assert.Equal(t, "ticks:NIFTY", frame.Topic)
+
+	// Unsubscribing stops the events of a topic
+	frame = request(t, conn, Message{Type: MessageUnsubscribe, ID: "8", Topic: "ticks:NIFTY"})
+	assert.Equal(t, MessageAck, frame.Type)
+	assert.NoError(t, handler.PublishTick("NIFTY", map[string]float64{"lastPrice": 22010}))
+
+	frame = request(t, conn, Message{Type: MessageUnsubscribe, ID: "9", Topic: "ticks:NIFTY"})
+	assert.Equal(t, MessageError, frame.Type)
+	assert.Equal(t, ErrorNotSubscribed, frame.Code)
+
+	// Malformed messages are answered with error frames too
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("subscribe orders")))
+	frame = readFrame(t, conn)
+	assert.Equal(t, ErrorInvalidMessage, frame.Code)
+
+	frame = request(t, conn, Message{Type: "publish", ID: "10", Topic: "orders:user123"})
+	assert.Equal(t, "10", frame.ID)
+	assert.Equal(t, ErrorInvalidMessage, frame.Code)
+}
+
+func TestPortfolioTopics(t *testing.T) {
+	// Create handler with mock portfolio service
+	mockPortfolios := new(MockPortfolioUpdates)
+	handler := NewHandler(mockPortfolios, nil)
+	conn := connect(t, handler, "admin1", "ADMIN")
+
+	var callback func(interface{})
+	mockPortfolios.On("SubscribeToUpdates", "portfolio1", mock.Anything).Run(func(args mock.Arguments) {
+		callback = args.Get(1).(func(interface{}))
+	}).Return("sub1", nil)
+	mockPortfolios.On("UnsubscribeFromUpdates", "sub1").Return(nil)
+	mockPortfolios.On("SubscribeToGreeks", portfolioanalytics.GreeksScopeAccount, "user123", mock.Anything).Return("sub2", nil)
+	mockPortfolios.On("SubscribeToGreeks", portfolioanalytics.GreeksScopePortfolio, "portfolio3", mock.Anything).Return("", errors.New("portfolio not found"))
+	unsubscribed := make(chan struct{})
+	mockPortfolios.On("UnsubscribeFromGreeks", "sub2").Run(func(args mock.Arguments) {
+		close(unsubscribed)
+	}).Return(nil)
+
+	// Admins may follow anyone's portfolios, through the portfolio service
+	frame := request(t, conn, Message{Type: MessageSubscribe, ID: "1", Topic: "portfolio:portfolio1"})
+	assert.Equal(t, MessageAck, frame.Type)
+	mockPortfolios.AssertNotCalled(t, "GetPortfolio", mock.Anything)
+
+	callback(json.RawMessage(`{"totalValue": 150000}`))
+	frame = readFrame(t, conn)
+	assert.Equal(t, MessageEvent, frame.Type)
+	assert.Equal(t, "portfolio:portfolio1", frame.Topic)
+	assert.JSONEq(t, `{"totalValue": 150000}`, string(frame.Payload))
+
+	frame = request(t, conn, Message{Type: MessageUnsubscribe, ID: "2", Topic: "portfolio:portfolio1"})
+	assert.Equal(t, MessageAck, frame.Type)
+	mockPortfolios.AssertCalled(t, "UnsubscribeFromUpdates", "sub1")
+
+	// Failing to follow a topic is an error frame
+	frame = request(t, conn, Message{Type: MessageSubscribe, ID: "3", Topic: "greeks:portfolio3"})
+	assert.Equal(t, ErrorSubscriptionFailed, frame.Code)
+	assert.Equal(t, 0, handler.GetSubscriberCount("greeks:portfolio3"))
+
+	// Disconnecting ends the subscriptions of a client
+	frame = request(t, conn, Message{Type: MessageSubscribe, ID: "4", Topic: "account_greeks:user123"})
+	assert.Equal(t, MessageAck, frame.Type)
+	conn.Close()
+	assert.Eventually(t, func() bool {
+		return handler.GetSubscriberCount("account_greeks:user123") == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	select {
+	case <-unsubscribed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Greeks subscription not ended")
+	}
+}
+
+func TestHandleConnectionUnauthorized(t *testing.T) {
+	// Connections without a user or a valid token are refused
+	handler := NewHandler(new(MockPortfolioUpdates), nil)
+	req := httptest.NewRequest("GET", "/ws?token=invalid", nil)
+	rr := httptest.NewRecorder()
+
+	handler.HandleConnection(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAuthRequest(t *testing.T) {
+	// Create handler with mock portfolio service
+	handler := NewHandler(new(MockPortfolioUpdates), nil)
+	token, err := auth.GenerateToken("user123", "trader1", "TRADER", "STANDARD", "LIVE")
+	assert.NoError(t, err)
+
+	// Connections without a user are bound to the user of their auth request
+	conn := connect(t, handler, "", "")
+	frame := request(t, conn, Message{Type: MessageAuth, ID: "1", Token: token})
+	assert.Equal(t, MessageAck, frame.Type)
+	assert.Equal(t, "1", frame.ID)
+	frame = readFrame(t, conn)
+	assert.Equal(t, MessageSession, frame.Type)
+	assert.NotEmpty(t, frame.ResumeToken)
+
+	frame = request(t, conn, Message{Type: MessageSubscribe, ID: "2", Topic: "orders:user123"})
+	assert.Equal(t, MessageAck, frame.Type)
+	frame = request(t, conn, Message{Type: MessageSubscribe, ID: "3", Topic: "orders:user456"})
+	assert.Equal(t, ErrorForbidden, frame.Code)
+
+	frame = request(t, conn, Message{Type: MessageAuth, ID: "4", Token: token})
+	assert.Equal(t, ErrorInvalidMessage, frame.Code)
+
+	// Connections whose first frame is no valid auth request are closed
+	for i, message := range []Message{
+		{Type: MessageSubscribe, ID: "1", Topic: "orders:user123"},
+		{Type: MessageAuth, ID: "2", Token: "invalid"},
+	} {
+		conn = connect(t, handler, "", "")
+		frame = request(t, conn, message)
+		assert.Equal(t, MessageError, frame.Type)
+		assert.Equal(t, strconv.Itoa(i+1), frame.ID)
+		assert.Equal(t, ErrorUnauthenticated, frame.Code)
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, _, err = conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseNoStatusReceived), "connection not closed: %v", err)
+	}
+}
+
+func TestSubscriptionPermissions(t *testing.T) {
+	// Create handler checking permissions with a mock checker
+	mockPermissions := new(MockPermissionChecker)
+	handler := NewHandler(new(MockPortfolioUpdates), nil)
+	handler.SetPermissions(mockPermissions)
+	conn := connect(t, handler, "admin1", "ADMIN")
+
+	mockPermissions.On("Authorize", "admin1", mock.Anything, "stream:ticks:subscribe").Return(nil)
+	mockPermissions.On("Authorize", "admin1", mock.Anything, "stream:orders:subscribe").Return(errors.New("user does not have required permission"))
+
+	// Topics are only followed with the permission of their kind, even by admins
+	frame := request(t, conn, Message{Type: MessageSubscribe, ID: "1", Topic: "ticks:NIFTY"})
+	assert.Equal(t, MessageAck, frame.Type)
+
+	frame = request(t, conn, Message{Type: MessageSubscribe, ID: "2", Topic: "orders:user123"})
+	assert.Equal(t, ErrorForbidden, frame.Code)
+	assert.Equal(t, 0, handler.GetSubscriberCount("orders:user123"))
+}
+
+func TestResumeSession(t *testing.T) {
+	// Create handler with mock portfolio service
+	handler := NewHandler(new(MockPortfolioUpdates), nil)
+	conn, resumeToken := connectSession(t, handler, "user123", "TRADER")
+
+	for i, topic := range []string{"orders:user123", "ticks:NIFTY"} {
+		frame := request(t, conn, Message{Type: MessageSubscribe, ID: strconv.Itoa(i + 1), Topic: topic})
+		assert.Equal(t, MessageAck, frame.Type)
+	}
+
+	// Events are numbered in the session
+	assert.NoError(t, handler.PublishOrder("user123", map[string]string{"orderId": "order1"}))
+	assert.NoError(t, handler.PublishTick("NIFTY", map[string]float64{"lastPrice": 22000}))
+	for seq := uint64(1); seq <= 2; seq++ {
+		frame := readFrame(t, conn)
+		assert.Equal(t, MessageEvent, frame.Type)
+		assert.Equal(t, seq, frame.Seq)
+	}
+
+	// Order events published while disconnected are buffered, ticks are not
+	conn.Close()
+	assert.Eventually(t, func() bool {
+		return handler.GetSubscriberCount("orders:user123") == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.NoError(t, handler.PublishOrder("user123", map[string]string{"orderId": "order2"}))
+	assert.NoError(t, handler.PublishTick("NIFTY", map[string]float64{"lastPrice": 22010}))
+	assert.NoError(t, handler.PublishOrder("user123", map[string]string{"orderId": "order3"}))
+
+	// Others may not resume the session
+	other := connect(t, handler, "user456", "TRADER")
+	frame := request(t, other, Message{Type: MessageResume, ID: "1", ResumeToken: resumeToken, Seq: 2})
+	assert.Equal(t, ErrorResumeFailed, frame.Code)
+
+	// Resuming replays the events missed after the ack, and restores the orders topic
+	conn = connect(t, handler, "user123", "TRADER")
+	frame = request(t, conn, Message{Type: MessageResume, ID: "1", ResumeToken: resumeToken, Seq: 2})
+	assert.Equal(t, MessageAck, frame.Type)
+	assert.Equal(t, resumeToken, frame.ResumeToken)
+	assert.Equal(t, uint64(4), frame.Seq)
+	for i, orderID := range []string{"order2", "order3"} {
+		frame = readFrame(t, conn)
+		assert.Equal(t, uint64(i+3), frame.Seq)
+		assert.Equal(t, "orders:user123", frame.Topic)
+		assert.JSONEq(t, `{"orderId": "`+orderID+`"}`, string(frame.Payload))
+	}
+
+	assert.NoError(t, handler.PublishOrder("user123", map[string]string{"orderId": "order4"}))
+	frame = readFrame(t, conn)
+	assert.Equal(t, uint64(5), frame.Seq)
+	assert.Equal(t, 0, handler.GetSubscriberCount("ticks:NIFTY"))
+
+	frame = request(t, conn, Message{Type: MessageResume, ID: "2", ResumeToken: resumeToken, Seq: 5})
+	assert.Equal(t, ErrorResumeFailed, frame.Code)
+	frame = request(t, conn, Message{Type: MessageResume, ID: "3", ResumeToken: "unknown", Seq: 5})
+	assert.Equal(t, ErrorResumeFailed, frame.Code)
+}
+
+func TestSendQueueBackpressure(t *testing.T) {
+	now := time.Now()
+	queue := newSendQueue()
+	for i := 0; i < sendQueueLimit; i++ {
+		queue.push(queuedFrame{data: []byte("tick" + strconv.Itoa(i)), droppable: true}, now)
+	}
+
+	// Past the limit, the oldest tick is dropped to make room for new frames
+	dropped, overflowed := queue.push(queuedFrame{data: []byte("order1")}, now)
+	assert.True(t, dropped)
+	assert.False(t, overflowed)
+	assert.Equal(t, sendQueueLimit, queue.length())
+	frame, ok, _ := queue.pop()
+	assert.True(t, ok)
+	assert.Equal(t, "tick1", string(frame.data))
+
+	// Without ticks left to drop, new ticks are dropped and other frames queued
+	queue = newSendQueue()
+	for i := 0; i < sendQueueLimit; i++ {
+		queue.push(queuedFrame{data: []byte("order" + strconv.Itoa(i))}, now)
+	}
+	dropped, _ = queue.push(queuedFrame{data: []byte("tick"), droppable: true}, now)
+	assert.True(t, dropped)
+	dropped, overflowed = queue.push(queuedFrame{data: []byte("order")}, now)
+	assert.False(t, dropped)
+	assert.False(t, overflowed)
+	assert.Equal(t, sendQueueLimit+1, queue.length())
+
+	// Until the client stays past the limit for too long
+	_, overflowed = queue.push(queuedFrame{data: []byte("order")}, now.Add(overflowGrace+time.Second))
+	assert.True(t, overflowed)
+	_, overflowed = queue.push(queuedFrame{data: []byte("order")}, now.Add(overflowGrace+time.Second))
+	assert.False(t, overflowed)
+	assert.Equal(t, sendQueueLimit+2, queue.length())
+
+	// Or reaches the hard limit
+	queue = newSendQueue()
+	for i := 1; i < sendQueueHardLimit; i++ {
+		_, overflowed = queue.push(queuedFrame{data: []byte("order")}, now)
+		assert.False(t, overflowed)
+	}
+	_, overflowed = queue.push(queuedFrame{data: []byte("order")}, now)
+	assert.True(t, overflowed)
+
+	// A closed queue is reported closed once the frames queued are taken
+	queue = newSendQueue()
+	queue.push(queuedFrame{data: []byte("order")}, now)
+	queue.close()
+	_, ok, closed := queue.pop()
+	assert.True(t, ok)
+	assert.False(t, closed)
+	_, ok, closed = queue.pop()
+	assert.False(t, ok)
+	assert.True(t, closed)
+}
+
+func TestGetStats(t *testing.T) {
+	// Create handler with mock portfolio service
+	handler := NewHandler(new(MockPortfolioUpdates), nil)
+	conn := connect(t, handler, "user123", "TRADER")
+	frame := request(t, conn, Message{Type: MessageSubscribe, ID: "1", Topic: "ticks:NIFTY"})
+	assert.Equal(t, MessageAck, frame.Type)
+	assert.NoError(t, handler.PublishTick("NIFTY", map[string]float64{"lastPrice": 22000}))
+	readFrame(t, conn)
+
+	rr := httptest.NewRecorder()
+	handler.GetStats(rr, httptest.NewRequest("GET", "/ws/stats", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var stats HubStats
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	assert.Equal(t, 1, stats.Connections)
+	assert.Equal(t, int64(3), stats.FramesQueued) // Session frame, ack and tick
+	assert.Equal(t, int64(0), stats.DroppedFrames)
+}
+
+func TestMsgpackEncoding(t *testing.T) {
+	// Create handler with mock portfolio service
+	handler := NewHandler(new(MockPortfolioUpdates), nil)
+	conn, _ := connectQuery(t, handler, "user123", "TRADER", "?encoding=msgpack")
+	for i, topic := range []string{"depth:NIFTY", "orders:user123"} {
+		frame := request(t, conn, Message{Type: MessageSubscribe, ID: strconv.Itoa(i + 1), Topic: topic})
+		assert.Equal(t, MessageAck, frame.Type)
+	}
+
+	// Market data events are binary MessagePack frames
+	assert.NoError(t, handler.PublishDepth("NIFTY", map[string]interface{}{"bids": []float64{21995, 21990}}))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	messageType, data, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, messageType)
+
+	var frame struct {
+		Type    string                 `codec:"type"`
+		Topic   string                 `codec:"topic"`
+		Seq     uint64                 `codec:"seq"`
+		Payload map[string]interface{} `codec:"payload"`
+	}
+	assert.NoError(t, codec.NewDecoderBytes(data, msgpackHandle).Decode(&frame))
+	assert.Equal(t, MessageEvent, frame.Type)
+	assert.Equal(t, "depth:NIFTY", frame.Topic)
+	assert.Equal(t, uint64(1), frame.Seq)
+	assert.Len(t, frame.Payload["bids"], 2)
+
+	// Order events stay JSON
+	assert.NoError(t, handler.PublishOrder("user123", map[string]string{"orderId": "order1"}))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	messageType, data, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, messageType)
+	assert.Contains(t, string(data), `"seq":2`)
+
+	// Encodings not supported are refused
+	rr := httptest.NewRecorder()
+	handler.HandleConnection(rr, httptest.NewRequest("GET", "/ws?encoding=protobuf", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestHeartbeats(t *testing.T) {
+	// Create handler with short heartbeat intervals
+	handler := NewHandler(new(MockPortfolioUpdates), nil)
+	handler.SetHeartbeat(HeartbeatConfig{
+		PingInterval: 50 * time.Millisecond,
+		PongTimeout:  100 * time.Millisecond,
+		IdleTimeout:  200 * time.Millisecond,
+	})
+	token, err := auth.GenerateToken("user123", "trader1", "TRADER", "STANDARD", "LIVE")
+	assert.NoError(t, err)
+
+	// The session frame tells the heartbeat interval
+	conn := connect(t, handler, "", "")
+	frame := request(t, conn, Message{Type: MessageAuth, ID: "1", Token: token})
+	assert.Equal(t, MessageAck, frame.Type)
+	frame = readFrame(t, conn)
+	assert.Equal(t, MessageSession, frame.Type)
+	assert.Equal(t, int64(50), frame.HeartbeatInterval)
+
+	// Heartbeats carry the sequence number of the last event
+	frame = request(t, conn, Message{Type: MessageSubscribe, ID: "2", Topic: "orders:user123"})
+	assert.Equal(t, MessageAck, frame.Type)
+	assert.NoError(t, handler.PublishOrder("user123", map[string]string{"orderId": "order1"}))
+	frame = readFrame(t, conn)
+	for frame.Type != MessageHeartbeat {
+		frame = readFrame(t, conn)
+	}
+	assert.Equal(t, uint64(1), frame.Seq)
+
+	// And ping requests are answered
+	assert.NoError(t, conn.WriteJSON(Message{Type: MessagePing, ID: "3"}))
+	frame = readFrame(t, conn)
+	for frame.Type == MessageHeartbeat {
+		frame = readFrame(t, conn)
+	}
+	assert.Equal(t, MessagePong, frame.Type)
+	assert.Equal(t, "3", frame.ID)
+	conn.Close()
+
+	// Connections without subscriptions are closed once idle
+	idle := connect(t, handler, "user456", "TRADER")
+	idle.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for err == nil {
+		_, _, err = idle.ReadMessage()
+	}
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "connection not closed: %v", err)
+
+	// And connections not answering pings as dead
+	dead := connect(t, handler, "user789", "TRADER")
+	frame = request(t, dead, Message{Type: MessageSubscribe, ID: "1", Topic: "ticks:NIFTY"})
+	assert.Equal(t, MessageAck, frame.Type)
+	assert.Eventually(t, func() bool {
+		return handler.GetSubscriberCount("ticks:NIFTY") == 0
+	}, 2*time.Second, 10*time.Millisecond)
+
+	stats := handler.Stats()
+	assert.Equal(t, int64(1), stats.IdleDisconnects)
+	assert.Equal(t, int64(1), stats.DeadConnections)
+}
+
+func TestFollowEvents(t *testing.T) {
+	// Create handler with mock portfolio service and event bus
+	mockPortfolios := new(MockPortfolioUpdates)
+	mockBus := new(MockEventSubscriber)
+	handler := NewHandler(mockPortfolios, nil)
+
+	handlers := make(map[string]func([]byte) error)
+	mockBus.On("Subscribe", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		handlers[args.String(0)] = args.Get(1).(func([]byte) error)
+	}).Return(nil)
+	assert.NoError(t, handler.FollowEvents(context.Background(), mockBus))
+
+	mockPortfolios.On("GetPortfolio", "portfolio1").Return(&portfolioanalytics.Portfolio{ID: "portfolio1", UserID: "user123"}, nil)
+	conn := connect(t, handler, "user123", "TRADER")
+	for i, topic := range []string{"orders:user123", "positions:portfolio1"} {
+		frame := request(t, conn, Message{Type: MessageSubscribe, ID: strconv.Itoa(i + 1), Topic: topic})
+		assert.Equal(t, MessageAck, frame.Type)
+	}
+
+	// Order events of the bus are pushed to their user's orders topic, once
+	fill := `{"type": "order.fill", "payload": {"id": "transition1", "orderId": "order1", "userId": "user123", "status": "EXECUTED"}}`
+	assert.NoError(t, handlers[OrderEventsTopic]([]byte(fill)))
+	assert.NoError(t, handlers[OrderEventsTopic]([]byte(fill)))
+	frame := readFrame(t, conn)
+	assert.Equal(t, "orders:user123", frame.Topic)
+	assert.JSONEq(t, `{"id": "transition1", "orderId": "order1", "userId": "user123", "status": "EXECUTED"}`, string(frame.Payload))
+
+	// Position events to their portfolio's positions topic
+	position := `{"type": "portfolio.position", "payload": {"id": "position1", "portfolioId": "portfolio1", "quantity": 50}}`
+	assert.NoError(t, handlers[PositionEventsTopic]([]byte(position)))
+	frame = readFrame(t, conn)
+	assert.Equal(t, "positions:portfolio1", frame.Topic)
+	assert.Equal(t, uint64(2), frame.Seq)
+
+	// Events that cannot be routed are errors
+	assert.Error(t, handlers[OrderEventsTopic]([]byte(`{"type": "order.fill", "payload": {"id": "transition2"}}`)))
+	assert.Error(t, handlers[PositionEventsTopic]([]byte("position1")))
+
+	// As is failing to follow the bus
+	failingBus := new(MockEventSubscriber)
+	failingBus.On("Subscribe", OrderEventsTopic, mock.Anything).Return(errors.New("not connected"))
+	assert.Error(t, handler.FollowEvents(context.Background(), failingBus))
+}
+
+func TestRelay(t *testing.T) {
+	// Create two replicas relaying events to each other
+	relay := &fakeRelay{subscriptions: make(map[string][]fakeSubscription)}
+	replica1 := NewHandler(new(MockPortfolioUpdates), nil)
+	replica2 := NewHandler(new(MockPortfolioUpdates), nil)
+	replica1.SetRelay(relay)
+	replica2.SetRelay(relay)
+
+	conn := connect(t, replica2, "user123", "TRADER")
+	for i, topic := range []string{"orders:user123", "ticks:NIFTY"} {
+		frame := request(t, conn, Message{Type: MessageSubscribe, ID: strconv.Itoa(i + 1), Topic: topic})
+		assert.Equal(t, MessageAck, frame.Type)
+	}
+	assert.Equal(t, 1, relay.watching("ws.events.orders:user123"))
+
+	// Events published on one replica reach the clients of the others
+	assert.NoError(t, replica1.PublishOrder("user123", map[string]string{"orderId": "order1"}))
+	frame := readFrame(t, conn)
+	assert.Equal(t, "orders:user123", frame.Topic)
+	assert.Equal(t, uint64(1), frame.Seq)
+	assert.JSONEq(t, `{"orderId": "order1"}`, string(frame.Payload))
+
+	// Only the channels of the topics followed are watched
+	frame = request(t, conn, Message{Type: MessageUnsubscribe, ID: "3", Topic: "ticks:NIFTY"})
+	assert.Equal(t, MessageAck, frame.Type)
+	assert.Equal(t, 0, relay.watching("ws.events.ticks:NIFTY"))
+
+	// Channels stay watched while a detached session buffers their events
+	conn.Close()
+	assert.Eventually(t, func() bool {
+		return replica2.GetSubscriberCount("orders:user123") == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, relay.watching("ws.events.orders:user123"))
+}
+
+func TestRateLimits(t *testing.T) {
+	// Create handler with low rate limits
+	handler := NewHandler(new(MockPortfolioUpdates), nil)
+	handler.SetRateLimits(RateLimitConfig{
+		PerConnection: MessageRateLimit{MaxMessages: 3, TimeWindow: time.Minute},
+		PerUser:       MessageRateLimit{MaxMessages: 5, TimeWindow: time.Minute},
+	})
+
+	// Messages past the limit of a connection are discarded
+	conn := connect(t, handler, "user123", "TRADER")
+	for i := 0; i < 3; i++ {
+		frame := request(t, conn, Message{Type: MessagePing, ID: "ping"})
+		assert.Equal(t, MessagePong, frame.Type)
+	}
+	frame := request(t, conn, Message{Type: MessageSubscribe, ID: "4", Topic: "ticks:NIFTY"})
+	assert.Equal(t, MessageError, frame.Type)
+	assert.Equal(t, "4", frame.ID)
+	assert.Equal(t, ErrorRateLimited, frame.Code)
+	assert.True(t, frame.RetryAfter > 0)
+	assert.Equal(t, 0, handler.GetSubscriberCount("ticks:NIFTY"))
+
+	// Those past the limit of a user across its connections too
+	other := connect(t, handler, "user123", "TRADER")
+	for i := 0; i < 2; i++ {
+		frame = request(t, other, Message{Type: MessagePing, ID: "ping"})
+		assert.Equal(t, MessagePong, frame.Type)
+	}
+	frame = request(t, other, Message{Type: MessagePing, ID: "3"})
+	assert.Equal(t, ErrorRateLimited, frame.Code)
+	assert.Equal(t, "3", frame.ID)
+
+	// Other users are not limited
+	conn = connect(t, handler, "user456", "TRADER")
+	frame = request(t, conn, Message{Type: MessagePing, ID: "1"})
+	assert.Equal(t, MessagePong, frame.Type)
+
+	assert.Equal(t, int64(2), handler.Stats().RateLimited)
+}
+
+func TestMetrics(t *testing.T) {
+	// Create handler registered with a registry
+	handler := NewHandler(new(MockPortfolioUpdates), nil)
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(handler))
+
+	// Subscribe to a ticks topic and publish a tick to it
+	conn := connect(t, handler, "user123", "TRADER")
+	frame := request(t, conn, Message{Type: MessageSubscribe, ID: "1", Topic: "ticks:NIFTY"})
+	assert.Equal(t, MessageAck, frame.Type)
+	assert.NoError(t, handler.PublishTick("NIFTY", map[string]float64{"ltp": 22150.5}))
+	frame = readFrame(t, conn)
+	assert.Equal(t, MessageEvent, frame.Type)
+
+	// Gather metrics
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	metrics := make(map[string][]*dto.Metric)
+	for _, family := range families {
+		metrics[family.GetName()] = family.GetMetric()
+	}
+
+	assert.Len(t, metrics["websocket_connections"], 1)
+	assert.Equal(t, float64(1), metrics["websocket_connections"][0].GetGauge().GetValue())
+	subscriptions := make(map[string]float64)
+	for _, metric := range metrics["websocket_subscriptions"] {
+		subscriptions[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+	}
+	assert.Equal(t, float64(1), subscriptions[TopicTicks])
+	assert.Equal(t, float64(0), subscriptions[TopicOrders])
+	assert.Len(t, metrics["websocket_disconnects_total"], 3)
+	assert.Len(t, metrics["websocket_fan_out_latency_seconds"], 1)
+	assert.Equal(t, uint64(1), metrics["websocket_fan_out_latency_seconds"][0].GetSummary().GetSampleCount())
+}
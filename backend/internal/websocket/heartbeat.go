@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"time"
+)
+
+// HeartbeatConfig configures how the handler checks its connections are alive
+type HeartbeatConfig struct {
+	PingInterval time.Duration // Between pings, and heartbeat frames to authenticated clients
+	PongTimeout  time.Duration // Past a ping, for a pong or any other frame before the connection is deemed dead
+	IdleTimeout  time.Duration // Connections without subscriptions nor requests for this long are closed
+}
+
+// DefaultHeartbeatConfig returns the default heartbeat configuration, detecting
+// half-open connections within half a minute
+func DefaultHeartbeatConfig() HeartbeatConfig {
+	return HeartbeatConfig{
+		PingInterval: 15 * time.Second,
+		PongTimeout:  10 * time.Second,
+		IdleTimeout:  5 * time.Minute,
+	}
+}
+
+// SetHeartbeat configures the heartbeats of the connections made from then on,
+// zero durations keeping their defaults
+func (h *Handler) SetHeartbeat(config HeartbeatConfig) {
+	defaults := DefaultHeartbeatConfig()
+	if config.PingInterval <= 0 {
+		config.PingInterval = defaults.PingInterval
+	}
+	if config.PongTimeout <= 0 {
+		config.PongTimeout = defaults.PongTimeout
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaults.IdleTimeout
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.heartbeat = config
+}
+
+// startSession starts a session for a client and sends it the session frame,
+// telling the heartbeat interval for the client to detect a dead connection when
+// heartbeats stop. The handler's mutex must be held.
+func (h *Handler) startSession(client *Client) {
+	h.respond(client, Message{
+		Type:              MessageSession,
+		ResumeToken:       h.newSession(client).token,
+		HeartbeatInterval: client.heartbeat.PingInterval.Milliseconds(),
+	})
+}
+
+// beat sends a heartbeat frame to an authenticated client, with the sequence
+// number of the last event of its session for the client to detect missed
+// events, reporting false instead when the client has been idle for too long
+func (c *Client) beat(now time.Time) bool {
+	h := c.handler
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(c.topics) == 0 && now.Sub(c.lastActive) > c.heartbeat.IdleTimeout {
+		h.stats.IdleDisconnects++
+		return false
+	}
+	if c.session != nil {
+		h.respond(c, Message{Type: MessageHeartbeat, Seq: c.session.seq})
+	}
+	return true
+}
+
+// touch records a request of the client, which is then not idle
+func (c *Client) touch() {
+	c.handler.mutex.Lock()
+	defer c.handler.mutex.Unlock()
+	c.lastActive = time.Now()
+}
+
+// extendDeadline gives the client another ping interval, and the time to answer
+// the next ping, to show it is alive
+func (c *Client) extendDeadline() {
+	c.conn.SetReadDeadline(time.Now().Add(c.heartbeat.PingInterval + c.heartbeat.PongTimeout))
+}
@@ -0,0 +1,119 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Descriptions of the handler's metrics, taken from its stats on every scrape
+var (
+	connectionsDesc = prometheus.NewDesc(
+		"websocket_connections",
+		"Open WebSocket connections.",
+		nil, nil,
+	)
+	subscriptionsDesc = prometheus.NewDesc(
+		"websocket_subscriptions",
+		"Subscriptions of WebSocket clients, by topic kind.",
+		[]string{"topic"}, nil,
+	)
+	queuedFramesDesc = prometheus.NewDesc(
+		"websocket_send_queue_frames",
+		"Frames waiting in the send queues of all WebSocket clients.",
+		nil, nil,
+	)
+	maxQueueLengthDesc = prometheus.NewDesc(
+		"websocket_send_queue_max_length",
+		"Send queue length of the most backed up WebSocket client.",
+		nil, nil,
+	)
+	overflowingClientsDesc = prometheus.NewDesc(
+		"websocket_overflowing_clients",
+		"WebSocket clients past the send queue limit.",
+		nil, nil,
+	)
+	framesQueuedDesc = prometheus.NewDesc(
+		"websocket_frames_queued_total",
+		"Frames queued for WebSocket clients.",
+		nil, nil,
+	)
+	droppedFramesDesc = prometheus.NewDesc(
+		"websocket_dropped_frames_total",
+		"Market data frames dropped for slow WebSocket clients.",
+		nil, nil,
+	)
+	disconnectsDesc = prometheus.NewDesc(
+		"websocket_disconnects_total",
+		"WebSocket connections closed by the server, by reason.",
+		[]string{"reason"}, nil,
+	)
+	rateLimitedDesc = prometheus.NewDesc(
+		"websocket_rate_limited_messages_total",
+		"WebSocket client messages discarded for exceeding rate limits.",
+		nil, nil,
+	)
+)
+
+// newFanOutLatency creates the summary of how long publishing an event takes to
+// queue it for all the subscribers of its topic
+func newFanOutLatency() prometheus.Summary {
+	return prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "websocket_fan_out_latency_seconds",
+		Help:       "Time taken to queue a published event for all the subscribers of its topic.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		MaxAge:     time.Minute,
+	})
+}
+
+// Describe implements prometheus.Collector
+func (h *Handler) Describe(ch chan<- *prometheus.Desc) {
+	ch <- connectionsDesc
+	ch <- subscriptionsDesc
+	ch <- queuedFramesDesc
+	ch <- maxQueueLengthDesc
+	ch <- overflowingClientsDesc
+	ch <- framesQueuedDesc
+	ch <- droppedFramesDesc
+	ch <- disconnectsDesc
+	ch <- rateLimitedDesc
+	h.fanOutLatency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector. Subscriptions are counted by topic
+// kind, as topics are per user, portfolio or instrument.
+func (h *Handler) Collect(ch chan<- prometheus.Metric) {
+	stats := h.Stats()
+	ch <- prometheus.MustNewConstMetric(connectionsDesc, prometheus.GaugeValue, float64(stats.Connections))
+	ch <- prometheus.MustNewConstMetric(queuedFramesDesc, prometheus.GaugeValue, float64(stats.QueuedFrames))
+	ch <- prometheus.MustNewConstMetric(maxQueueLengthDesc, prometheus.GaugeValue, float64(stats.MaxQueueLength))
+	ch <- prometheus.MustNewConstMetric(overflowingClientsDesc, prometheus.GaugeValue, float64(stats.OverflowingClients))
+	ch <- prometheus.MustNewConstMetric(framesQueuedDesc, prometheus.CounterValue, float64(stats.FramesQueued))
+	ch <- prometheus.MustNewConstMetric(droppedFramesDesc, prometheus.CounterValue, float64(stats.DroppedFrames))
+	ch <- prometheus.MustNewConstMetric(disconnectsDesc, prometheus.CounterValue, float64(stats.SlowDisconnects), "slow")
+	ch <- prometheus.MustNewConstMetric(disconnectsDesc, prometheus.CounterValue, float64(stats.IdleDisconnects), "idle")
+	ch <- prometheus.MustNewConstMetric(disconnectsDesc, prometheus.CounterValue, float64(stats.DeadConnections), "dead")
+	ch <- prometheus.MustNewConstMetric(rateLimitedDesc, prometheus.CounterValue, float64(stats.RateLimited))
+
+	for kind, subscriptions := range h.subscriptionsByKind() {
+		ch <- prometheus.MustNewConstMetric(subscriptionsDesc, prometheus.GaugeValue, float64(subscriptions), kind)
+	}
+	h.fanOutLatency.Collect(ch)
+}
+
+// subscriptionsByKind counts the subscriptions of the handler's clients by topic
+// kind, every kind being reported
+func (h *Handler) subscriptionsByKind() map[string]int {
+	subscriptions := make(map[string]int, len(topicKinds))
+	for _, kind := range topicKinds {
+		subscriptions[kind] = 0
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for topic, clients := range h.topics {
+		kind, _, _ := parseTopic(topic)
+		subscriptions[kind] += len(clients)
+	}
+	return subscriptions
+}
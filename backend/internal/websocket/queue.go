@@ -0,0 +1,137 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// Send queue limits of a connection. Past sendQueueLimit frames, market data make
+// room by dropping the oldest market data frame queued, while other frames, never
+// dropped, keep queueing up to sendQueueHardLimit. A client reaching it, or
+// staying past sendQueueLimit for longer than overflowGrace, is disconnected.
+const (
+	sendQueueLimit     = 256
+	sendQueueHardLimit = 1024
+	overflowGrace      = 5 * time.Second
+)
+
+// queuedFrame is a frame waiting to be written to a connection
+type queuedFrame struct {
+	data      []byte
+	droppable bool // Market data, superseded by the next updates anyway
+	binary    bool
+}
+
+// sendQueue is the bounded queue of the frames waiting to be written to a
+// connection, so that queueing for a slow client never blocks the fan-out
+type sendQueue struct {
+	frames        []queuedFrame
+	droppable     int           // Droppable frames queued
+	ready         chan struct{} // Signalled when frames are queued or the queue closed
+	closed        bool
+	overflowed    bool      // The client overflowed for too long, further frames being discarded
+	overflowSince time.Time // When the queue went past its limit, zero while within it
+	mutex         sync.Mutex
+}
+
+// newSendQueue creates an empty send queue
+func newSendQueue() *sendQueue {
+	return &sendQueue{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// push queues a frame, reporting whether a market data frame was dropped to bound
+// the queue, and whether the client overflowed for too long and is to be
+// disconnected
+func (q *sendQueue) push(frame queuedFrame, now time.Time) (bool, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed || q.overflowed {
+		return false, false
+	}
+
+	dropped := false
+	if len(q.frames) >= sendQueueLimit {
+		if q.droppable == 0 && frame.droppable {
+			// Nothing older to drop for market data: drop the frame itself
+			return true, false
+		}
+		if q.droppable > 0 {
+			q.dropOldest()
+			dropped = true
+		}
+	}
+
+	q.frames = append(q.frames, frame)
+	if frame.droppable {
+		q.droppable++
+	}
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+
+	if len(q.frames) <= sendQueueLimit {
+		q.overflowSince = time.Time{}
+		return dropped, false
+	}
+	if q.overflowSince.IsZero() {
+		q.overflowSince = now
+	}
+	if len(q.frames) >= sendQueueHardLimit || now.Sub(q.overflowSince) > overflowGrace {
+		q.overflowed = true
+		return dropped, true
+	}
+	return dropped, false
+}
+
+// dropOldest drops the oldest droppable frame queued
+func (q *sendQueue) dropOldest() {
+	for i, frame := range q.frames {
+		if frame.droppable {
+			q.frames = append(q.frames[:i], q.frames[i+1:]...)
+			q.droppable--
+			return
+		}
+	}
+}
+
+// pop takes the oldest frame queued. Without any, it reports whether the queue
+// is closed.
+func (q *sendQueue) pop() (queuedFrame, bool, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.frames) == 0 {
+		return queuedFrame{}, false, q.closed
+	}
+
+	frame := q.frames[0]
+	q.frames[0] = queuedFrame{}
+	q.frames = q.frames[1:]
+	if frame.droppable {
+		q.droppable--
+	}
+	if len(q.frames) <= sendQueueLimit {
+		q.overflowSince = time.Time{}
+	}
+	return frame, true, false
+}
+
+// length returns the number of frames queued
+func (q *sendQueue) length() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.frames)
+}
+
+// close closes the queue once the frames queued are written, no more being queued
+func (q *sendQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.closed = true
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"time"
+)
+
+// MessageRateLimit limits how many messages may be sent within a time window,
+// as the API gateway limits requests
+type MessageRateLimit struct {
+	MaxMessages int
+	TimeWindow  time.Duration
+}
+
+// RateLimitConfig configures the rate limits of the messages clients send
+type RateLimitConfig struct {
+	PerConnection MessageRateLimit
+	PerUser       MessageRateLimit // Across all the connections of a user
+}
+
+// DefaultRateLimitConfig returns the default rate limits of client messages
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		PerConnection: MessageRateLimit{MaxMessages: 20, TimeWindow: time.Second},
+		PerUser:       MessageRateLimit{MaxMessages: 50, TimeWindow: time.Second},
+	}
+}
+
+// SetRateLimits configures the rate limits of client messages, limits without
+// messages or a time window keeping their defaults
+func (h *Handler) SetRateLimits(config RateLimitConfig) {
+	defaults := DefaultRateLimitConfig()
+	if config.PerConnection.MaxMessages <= 0 || config.PerConnection.TimeWindow <= 0 {
+		config.PerConnection = defaults.PerConnection
+	}
+	if config.PerUser.MaxMessages <= 0 || config.PerUser.TimeWindow <= 0 {
+		config.PerUser = defaults.PerUser
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.rateLimits = config
+}
+
+// allow checks a message of the client is within the rate limits of its
+// connection and its user, recording it if so, and otherwise returning how long
+// until it would be
+func (c *Client) allow(now time.Time) (time.Duration, bool) {
+	h := c.handler
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	c.messages = withinWindow(c.messages, now, h.rateLimits.PerConnection.TimeWindow)
+	userMessages := withinWindow(h.userMessages[c.userID], now, h.rateLimits.PerUser.TimeWindow)
+	h.userMessages[c.userID] = userMessages
+
+	if len(c.messages) >= h.rateLimits.PerConnection.MaxMessages {
+		h.stats.RateLimited++
+		return c.messages[0].Add(h.rateLimits.PerConnection.TimeWindow).Sub(now), false
+	}
+	if len(userMessages) >= h.rateLimits.PerUser.MaxMessages {
+		h.stats.RateLimited++
+		return userMessages[0].Add(h.rateLimits.PerUser.TimeWindow).Sub(now), false
+	}
+
+	c.messages = append(c.messages, now)
+	h.userMessages[c.userID] = append(userMessages, now)
+	return 0, true
+}
+
+// withinWindow drops the times of messages past a time window
+func withinWindow(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	cutoff := now.Add(-window)
+	for len(times) > 0 && !times[0].After(cutoff) {
+		times = times[1:]
+	}
+	return times
+}
+
+// forgetMessages drops the times of the messages of a user without connections
+// left. The handler's mutex must be held.
+func (h *Handler) forgetMessages(userID string) {
+	for client := range h.clients {
+		if client.userID == userID {
+			return
+		}
+	}
+	delete(h.userMessages, userID)
+}
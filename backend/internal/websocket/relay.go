@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// relayPublishTimeout limits how long relaying an event may take
+const relayPublishTimeout = 5 * time.Second
+
+// Relay relays events between the replicas serving WebSocket clients, such as a
+// messagequeue.RedisBroker or NATSClient, one channel per topic
+type Relay interface {
+	Publish(ctx context.Context, topic string, message interface{}) error
+	Subscribe(ctx context.Context, topic string, handler func([]byte) error) error
+}
+
+// SetRelay makes the handler publish the events of its orders, positions and
+// market data topics through a relay, and push those relayed for the topics its
+// clients follow, so that any replica can serve any client. Sessions can only be
+// resumed on the replica they were started on.
+func (h *Handler) SetRelay(relay Relay) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.relay = relay
+}
+
+// relayed checks the events of a topic are relayed, as are those of the topics
+// published through the handler, those followed through the portfolio service
+// being published to each replica's own clients
+func relayed(topic string) bool {
+	kind, _, _ := parseTopic(topic)
+	return kind == TopicOrders || kind == TopicPositions || marketData(kind)
+}
+
+// relayChannel returns the relay channel of a topic
+func relayChannel(topic string) string {
+	return "ws.events." + topic
+}
+
+// relayEvent publishes an event through the relay, reporting false when the
+// handler has none
+func (h *Handler) relayEvent(topic string, data interface{}) (bool, error) {
+	h.mutex.Lock()
+	relay := h.relay
+	h.mutex.Unlock()
+	if relay == nil || !relayed(topic) {
+		return false, nil
+	}
+
+	payload, ok := data.(json.RawMessage)
+	if !ok {
+		var err error
+		if payload, err = json.Marshal(data); err != nil {
+			return true, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), relayPublishTimeout)
+	defer cancel()
+	return true, relay.Publish(ctx, relayChannel(topic), payload)
+}
+
+// relayWatch is a subscription to the relay channel of a topic
+type relayWatch struct {
+	cancel context.CancelFunc
+}
+
+// watch subscribes to the relay channel of a topic, unless already subscribed
+func (h *Handler) watch(topic string) error {
+	h.mutex.Lock()
+	if h.relay == nil || !relayed(topic) || h.watched[topic] != nil {
+		h.mutex.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &relayWatch{cancel: cancel}
+	h.watched[topic] = w
+	relay := h.relay
+	h.mutex.Unlock()
+
+	err := relay.Subscribe(ctx, relayChannel(topic), func(data []byte) error {
+		return h.publishLocal(topic, json.RawMessage(data))
+	})
+	if err != nil {
+		h.mutex.Lock()
+		if h.watched[topic] == w {
+			delete(h.watched, topic)
+		}
+		h.mutex.Unlock()
+		cancel()
+	}
+	return err
+}
+
+// unwatch unsubscribes from the relay channel of a topic no client follows any
+// more, nor any detached session buffers. The handler's mutex must be held.
+func (h *Handler) unwatch(topic string) {
+	w := h.watched[topic]
+	if w == nil || len(h.topics[topic]) > 0 {
+		return
+	}
+	for _, s := range h.sessions {
+		if s.client == nil && s.topics[topic] {
+			return
+		}
+	}
+
+	delete(h.watched, topic)
+	w.cancel()
+}
@@ -0,0 +1,213 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// resumeWindow is how long the session of a disconnected client is kept, buffering
+// the events of its orders and positions topics, for a reconnecting client to
+// resume it
+const resumeWindow = 2 * time.Minute
+
+// maxResumeEvents is the most events a session buffers for replay
+const maxResumeEvents = 200
+
+// session numbers the events sent to a client, buffering those of its orders and
+// positions topics so that a client reconnecting with the session's resume token
+// gets those it missed replayed
+type session struct {
+	token      string
+	userID     string
+	client     *Client          // Nil while the session is detached, its client having disconnected
+	seq        uint64           // Of the last event of the session
+	events     []sequencedEvent // Buffered events, oldest first
+	trimmed    uint64           // Of the last event dropped from the buffer
+	topics     map[string]bool  // Topics buffered while detached
+	detachedAt time.Time
+}
+
+// sequencedEvent is a buffered event frame
+type sequencedEvent struct {
+	seq   uint64
+	at    time.Time
+	frame []byte
+}
+
+// replayable checks the events of a topic are buffered for replay, fills of
+// orders and the positions they change not to be missed
+func replayable(topic string) bool {
+	kind, _, _ := parseTopic(topic)
+	return kind == TopicOrders || kind == TopicPositions
+}
+
+// newSession starts a session for a client, ending the detached sessions past
+// their resume window. The handler's mutex must be held.
+func (h *Handler) newSession(client *Client) *session {
+	now := time.Now()
+	for token, s := range h.sessions {
+		if s.client == nil && now.Sub(s.detachedAt) > resumeWindow {
+			delete(h.sessions, token)
+			for topic := range s.topics {
+				h.unwatch(topic)
+			}
+		}
+	}
+
+	s := &session{
+		token:  uuid.New().String(),
+		userID: client.userID,
+		client: client,
+		topics: make(map[string]bool),
+	}
+	h.sessions[s.token] = s
+	client.session = s
+	return s
+}
+
+// sendEvent numbers an event frame in the client's session and queues it for the
+// client. The handler's mutex must be held.
+func (h *Handler) sendEvent(client *Client, message Message) {
+	if client.session == nil {
+		return
+	}
+
+	message, err := client.session.record(message)
+	if err != nil {
+		log.Printf("Failed to marshal %s event: %v", message.Topic, err)
+		return
+	}
+	data, binary, err := client.encode(message)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", message.Topic, err)
+		return
+	}
+
+	kind, _, _ := parseTopic(message.Topic)
+	h.deliver(client, queuedFrame{data: data, droppable: marketData(kind), binary: binary})
+}
+
+// bufferDetached numbers an event frame in the detached sessions buffering its
+// topic. The handler's mutex must be held.
+func (h *Handler) bufferDetached(message Message) {
+	if !replayable(message.Topic) {
+		return
+	}
+
+	for _, s := range h.sessions {
+		if s.client == nil && s.topics[message.Topic] {
+			if _, err := s.record(message); err != nil {
+				log.Printf("Failed to marshal %s event: %v", message.Topic, err)
+				return
+			}
+		}
+	}
+}
+
+// detach keeps the session of a disconnecting client for it to be resumed,
+// buffering the events of its orders and positions topics. The handler's mutex
+// must be held.
+func (h *Handler) detach(client *Client) {
+	s := client.session
+	if s == nil || s.client != client {
+		return
+	}
+
+	s.client = nil
+	s.detachedAt = time.Now()
+	for topic := range client.topics {
+		if replayable(topic) {
+			s.topics[topic] = true
+		}
+	}
+}
+
+// record numbers an event in the session, buffering its frame when its topic is
+// replayable
+func (s *session) record(message Message) (Message, error) {
+	message.Seq = s.seq + 1
+	if !replayable(message.Topic) {
+		s.seq++
+		return message, nil
+	}
+
+	frame, err := json.Marshal(message)
+	if err != nil {
+		return message, err
+	}
+	s.seq++
+
+	now := time.Now()
+	s.events = append(s.events, sequencedEvent{seq: s.seq, at: now, frame: frame})
+	for len(s.events) > 0 && (len(s.events) > maxResumeEvents || now.Sub(s.events[0].at) > resumeWindow) {
+		s.trimmed = s.events[0].seq
+		s.events = s.events[1:]
+	}
+
+	return message, nil
+}
+
+// handleResume handles a resume request, moving the client onto the session of a
+// resume token, restoring the orders and positions topics it followed, and
+// replaying the events after the last one the client received. It is acked
+// before the events replayed. Other topics are to be subscribed to again.
+func (c *Client) handleResume(request Message) {
+	h := c.handler
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	fail := func(reason string) {
+		h.respond(c, Message{Type: MessageError, ID: request.ID, Code: ErrorResumeFailed, Error: reason})
+	}
+
+	s, exists := h.sessions[request.ResumeToken]
+	switch {
+	case !exists || s.userID != c.userID || (s.client == nil && time.Since(s.detachedAt) > resumeWindow):
+		fail("unknown or expired resume token")
+		return
+	case s.client == c:
+		fail("session already attached to this connection")
+		return
+	case c.session != nil && c.session.seq > 0:
+		fail("events were already sent on this connection")
+		return
+	case request.Seq > s.seq || request.Seq < s.trimmed:
+		fail("events after the sequence number given are no longer buffered")
+		return
+	}
+
+	// A connection the session is still attached to has not been seen dropping
+	// yet, and is closed
+	if previous := s.client; previous != nil {
+		h.detach(previous)
+		previous.session = nil
+		previous.conn.Close()
+	}
+
+	if c.session != nil {
+		delete(h.sessions, c.session.token)
+	}
+	s.client = c
+	c.session = s
+	for topic := range s.topics {
+		if _, subscribed := c.topics[topic]; subscribed {
+			continue
+		}
+		if _, exists := h.topics[topic]; !exists {
+			h.topics[topic] = make(map[*Client]bool)
+		}
+		h.topics[topic][c] = true
+		c.topics[topic] = ""
+	}
+	s.topics = make(map[string]bool)
+
+	h.respond(c, Message{Type: MessageAck, ID: request.ID, ResumeToken: s.token, Seq: s.seq})
+	for _, e := range s.events {
+		if e.seq > request.Seq {
+			h.deliver(c, queuedFrame{data: e.frame})
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"trading-platform/backend/internal/models"
+)
+
+// Topic kinds. A topic is its kind and the ID of what it follows joined by a colon,
+// as in orders:user123.
+const (
+	TopicOrders        = "orders"         // orders:{userID}, updates of a user's orders
+	TopicPositions     = "positions"      // positions:{portfolioID}, updates of a portfolio's positions
+	TopicTicks         = "ticks"          // ticks:{symbol}, ticks of an instrument
+	TopicDepth         = "depth"          // depth:{symbol}, the market depth of an instrument
+	TopicPortfolio     = "portfolio"      // portfolio:{portfolioID}, a portfolio's recalculated metrics
+	TopicGreeks        = "greeks"         // greeks:{portfolioID}, a portfolio's greeks
+	TopicAccountGreeks = "account_greeks" // account_greeks:{userID}, the greeks of a user's account
+)
+
+// topicKinds lists the topic kinds
+var topicKinds = []string{TopicOrders, TopicPositions, TopicTicks, TopicDepth, TopicPortfolio, TopicGreeks, TopicAccountGreeks}
+
+// parseTopic splits a topic into its kind and ID
+func parseTopic(topic string) (string, string, error) {
+	kind, id, found := strings.Cut(topic, ":")
+	if !found || id == "" {
+		return "", "", errors.New("topic must be a kind and an ID joined by a colon")
+	}
+
+	for _, known := range topicKinds {
+		if kind == known {
+			return kind, id, nil
+		}
+	}
+	return "", "", errors.New("unknown topic kind " + kind)
+}
+
+// marketData checks a topic kind is high-volume market data, droppable for slow
+// clients and sent as binary frames to clients negotiating a binary encoding
+func marketData(kind string) bool {
+	return kind == TopicTicks || kind == TopicDepth
+}
+
+// authorize checks the client's user may follow a topic: that they have the
+// stream:{kind}:subscribe permission when the handler checks permissions, and
+// that the topic is their own orders or account greeks, the positions, metrics or
+// greeks of one of their portfolios, or the market data of any instrument.
+// Admins may follow anyone's topics.
+func (c *Client) authorize(kind, id string) bool {
+	c.handler.mutex.Lock()
+	permissions, userID, role, userType := c.handler.permissions, c.userID, c.role, c.userType
+	c.handler.mutex.Unlock()
+
+	if permissions != nil && permissions.Authorize(userID, userType, "stream:"+kind+":subscribe") != nil {
+		return false
+	}
+	if role == string(models.UserRoleAdmin) {
+		return true
+	}
+
+	switch kind {
+	case TopicTicks, TopicDepth:
+		return true
+	case TopicOrders, TopicAccountGreeks:
+		return id == userID
+	case TopicPositions, TopicPortfolio, TopicGreeks:
+		return c.ownsPortfolio(userID, id)
+	}
+	return false
+}
+
+// ownsPortfolio checks a user owns a portfolio
+func (c *Client) ownsPortfolio(userID, portfolioID string) bool {
+	portfolio, err := c.handler.portfolioService.GetPortfolio(context.Background(), portfolioID)
+	return err == nil && portfolio.UserID == userID
+}
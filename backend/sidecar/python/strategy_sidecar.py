@@ -0,0 +1,146 @@
+"""
+Strategy sidecar for running Python strategies inside the Go backtester and
+simulation engine.
+
+The Go side (internal/services/simulation/python_strategy_bridge.go) forwards
+market events to this process over gRPC and applies the returned order actions.
+Messages follow proto/strategy_bridge.proto and are encoded with the "json"
+gRPC codec, so no generated code is needed on either side.
+
+Usage:
+    python strategy_sidecar.py --port 50051 --strategy my_module:MyStrategy
+"""
+
+import argparse
+import importlib
+import json
+import logging
+from concurrent import futures
+
+import grpc
+
+SERVICE_NAME = "strategybridge.StrategyBridge"
+
+
+class Strategy:
+    """Base class for Python strategies. Override the on_* hooks you need."""
+
+    def __init__(self):
+        self.parameters = {}
+        self._actions = []
+
+    def on_init(self, account):
+        pass
+
+    def on_tick(self, tick, account):
+        pass
+
+    def on_candle(self, candle, account):
+        pass
+
+    def on_fill(self, fill, account):
+        pass
+
+    def buy(self, symbol, quantity, order_type="MARKET", price=0.0, trigger_price=0.0, exchange="NSE", tag=""):
+        self._submit(symbol, "BUY", quantity, order_type, price, trigger_price, exchange, tag)
+
+    def sell(self, symbol, quantity, order_type="MARKET", price=0.0, trigger_price=0.0, exchange="NSE", tag=""):
+        self._submit(symbol, "SELL", quantity, order_type, price, trigger_price, exchange, tag)
+
+    def cancel(self, order_id):
+        self._actions.append({"type": "CANCEL", "orderId": order_id})
+
+    def _submit(self, symbol, direction, quantity, order_type, price, trigger_price, exchange, tag):
+        self._actions.append({
+            "type": "SUBMIT",
+            "order": {
+                "symbol": symbol,
+                "exchange": exchange,
+                "direction": direction,
+                "orderType": order_type,
+                "quantity": quantity,
+                "price": price,
+                "triggerPrice": trigger_price,
+                "tag": tag,
+            },
+        })
+
+    def _drain(self):
+        actions, self._actions = self._actions, []
+        return actions
+
+
+class StrategyBridgeServicer:
+    """Dispatches StrategyBridge events to a strategy instance."""
+
+    def __init__(self, strategy_class):
+        self.strategy_class = strategy_class
+        self.strategy = None
+
+    def handle_event(self, event, context):
+        try:
+            event_type = event.get("type")
+            account = event.get("account") or {}
+
+            if event_type == "INIT":
+                # Every INIT starts a fresh run with its own strategy state
+                self.strategy = self.strategy_class()
+                self.strategy.parameters = json.loads(event.get("parametersJson") or "{}")
+                self.strategy.on_init(account)
+            elif self.strategy is None:
+                return {"actions": [], "error": "strategy not initialized"}
+            elif event_type == "TICK":
+                self.strategy.on_tick(event.get("marketData"), account)
+            elif event_type == "CANDLE":
+                self.strategy.on_candle(event.get("marketData"), account)
+            elif event_type == "FILL":
+                self.strategy.on_fill(event.get("fill"), account)
+            else:
+                return {"actions": [], "error": "unknown event type: %s" % event_type}
+
+            return {"actions": self.strategy._drain()}
+        except Exception as e:
+            logging.exception("Strategy raised an error")
+            return {"actions": [], "error": str(e)}
+
+
+def _decode(data):
+    return json.loads(data.decode("utf-8"))
+
+
+def _encode(message):
+    return json.dumps(message).encode("utf-8")
+
+
+def load_strategy(spec):
+    """Load a strategy class from a "module:ClassName" spec."""
+    module_name, class_name = spec.split(":", 1)
+    return getattr(importlib.import_module(module_name), class_name)
+
+
+def serve(strategy_class, port, max_workers=4):
+    servicer = StrategyBridgeServicer(strategy_class)
+    handler = grpc.method_handlers_generic_handler(SERVICE_NAME, {
+        "HandleEvent": grpc.unary_unary_rpc_method_handler(
+            servicer.handle_event,
+            request_deserializer=_decode,
+            response_serializer=_encode,
+        ),
+    })
+
+    server = grpc.server(futures.ThreadPoolExecutor(max_workers=max_workers))
+    server.add_generic_rpc_handlers((handler,))
+    server.add_insecure_port("[::]:%d" % port)
+    server.start()
+    logging.info("Strategy sidecar listening on port %d", port)
+    server.wait_for_termination()
+
+
+if __name__ == "__main__":
+    parser = argparse.ArgumentParser(description="Python strategy sidecar")
+    parser.add_argument("--port", type=int, default=50051)
+    parser.add_argument("--strategy", required=True, help="Strategy class as module:ClassName")
+    args = parser.parse_args()
+
+    logging.basicConfig(level=logging.INFO)
+    serve(load_strategy(args.strategy), args.port)